@@ -0,0 +1,340 @@
+package app
+
+import (
+	"context"
+	"strings"
+
+	"github.com/alesr/usrsvc/internal/audit"
+	"github.com/alesr/usrsvc/internal/tenant"
+	"github.com/alesr/usrsvc/internal/users/service"
+	apiv1 "github.com/alesr/usrsvc/proto/users/v1"
+	apiv2 "github.com/alesr/usrsvc/proto/users/v2"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	authorizationHeader string = "authorization"
+	apiKeyHeader        string = "x-api-key"
+	bearerPrefix        string = "Bearer "
+)
+
+// anonymous are the RPCs open to anonymous callers, to sign up, sign in and out, reset a
+// forgotten password and check the health of the service. The function reports whether the
+// given request may be anonymous. Every other RPC requires a caller, and the RPCs missing
+// from adminOnly, ownerOnly and authenticated too are denied to everyone.
+var anonymous = map[string]func(req any) bool{
+	"CreateUser":             always,
+	"CheckNicknameAvailable": always,
+	"Authenticate":           always,
+	"RefreshToken":           always,
+	"RequestPasswordReset":   always,
+	"ConfirmPasswordReset":   always,
+	"CheckHealth":            always,
+	"CheckHeath":             always,
+
+	// The bootstrap token authorizes the first admin.
+	"Bootstrap": always,
+
+	// Registered with GRPC_REFLECTION only.
+	"ServerReflectionInfo": always,
+
+	"RevokeSession": func(req any) bool {
+		// Users sign out by refresh token.
+		r, ok := req.(*apiv1.RevokeSessionRequest)
+		return ok && r.Id == ""
+	},
+}
+
+// adminOnly are the RPCs that require an admin caller.
+// The function reports whether the given request needs one.
+var adminOnly = map[string]func(req any) bool{
+	"DeleteUser":       func(any) bool { return true },
+	"AnonymizeUser":    func(any) bool { return true },
+	"SuspendUser":      func(any) bool { return true },
+	"DeactivateUser":   func(any) bool { return true },
+	"ReactivateUser":   func(any) bool { return true },
+	"ListAuditEvents":  func(any) bool { return true },
+	"ListUserChanges":  func(any) bool { return true },
+	"GetTenantPolicy":  func(any) bool { return true },
+	"SetTenantPolicy":  func(any) bool { return true },
+	"LockUserFields":   func(any) bool { return true },
+	"UnlockUserFields": func(any) bool { return true },
+	"ListFieldLocks":   func(any) bool { return true },
+	"UnlockUser":       func(any) bool { return true },
+	"ListSessions":     func(any) bool { return true },
+	"ListOperations":   func(any) bool { return true },
+	"SearchUsers":      func(any) bool { return true },
+	"CreateAPIKey":     func(any) bool { return true },
+	"RevokeAPIKey":     func(any) bool { return true },
+	"ListAPIKeys":      func(any) bool { return true },
+	"ImportUsers":      func(any) bool { return true },
+	"ExportUsers":      func(any) bool { return true },
+
+	// Merging tombstones the duplicate, whoever owns it.
+	"MergeUsers": func(any) bool { return true },
+
+	// The duplicates report pairs the names, emails and countries of every user.
+	"FindDuplicateUsers": func(any) bool { return true },
+
+	"DeleteUsersByFilter":      func(any) bool { return true },
+	"StartDeleteUsersByFilter": func(any) bool { return true },
+
+	// The background jobs are gated like their synchronous RPCs, and their results,
+	// e.g. the duplicates reports, are only returned to admins.
+	"StartMergeUsers":         func(any) bool { return true },
+	"StartFindDuplicateUsers": func(any) bool { return true },
+	"GetOperation":            func(any) bool { return true },
+
+	// Unlinking needs no proof of the identity, unlike linking with an ID token.
+	"UnlinkExternalIdentity": func(any) bool { return true },
+	"GetUserByIdentity":      func(any) bool { return true },
+
+	"ListUsers": func(req any) bool {
+		// Listing users across countries is reserved to admins, in v1 and v2.
+		r, ok := req.(interface{ GetCountry() string })
+		return !ok || r.GetCountry() == ""
+	},
+	"RevokeSession": func(req any) bool {
+		// Users revoke their own session by refresh token, admins any session by id.
+		r, ok := req.(*apiv1.RevokeSessionRequest)
+		return !ok || r.Id != ""
+	},
+}
+
+// ownerOnly are the RPCs on a user that only the user and the admins may call.
+// The function returns the id of the user of the given request.
+var ownerOnly = map[string]func(req any) string{
+	"GetUser":                  requestID,
+	"UpdateUser":               updatedUserID,
+	"SetAvatar":                requestID,
+	"RequestPhoneVerification": requestID,
+	"VerifyPhone":              requestID,
+	"ChangePassword":           requestID,
+	"EnrollTOTP":               requestID,
+	"VerifyTOTP":               requestID,
+	"ListLinkedIdentities":     requestUserID,
+
+	// Linking an identity lets it sign in as the user.
+	"LinkExternalIdentity": requestUserID,
+}
+
+// authenticated are the RPCs open to any authenticated caller.
+var authenticated = map[string]bool{
+	// The avatars are shown to the other users.
+	"GetAvatar":    true,
+	"GetUserStats": true,
+}
+
+func always(any) bool { return true }
+
+// requestID returns the id field of the request, empty if it has none.
+func requestID(req any) string {
+	r, ok := req.(interface{ GetId() string })
+	if !ok {
+		return ""
+	}
+	return r.GetId()
+}
+
+// requestUserID returns the user_id field of the request, empty if it has none.
+func requestUserID(req any) string {
+	r, ok := req.(interface{ GetUserId() string })
+	if !ok {
+		return ""
+	}
+	return r.GetUserId()
+}
+
+// updatedUserID returns the id of the user to update, in v1 and v2.
+func updatedUserID(req any) string {
+	if r, ok := req.(*apiv2.UpdateUserRequest); ok {
+		return r.GetUser().GetId()
+	}
+	return requestID(req)
+}
+
+// knownMethod reports whether the method is in the authorization maps.
+func knownMethod(method string) bool {
+	_, isAnonymous := anonymous[method]
+	_, isAdminOnly := adminOnly[method]
+	_, isOwnerOnly := ownerOnly[method]
+	return isAnonymous || isAdminOnly || isOwnerOnly || authenticated[method]
+}
+
+type callerKey struct{}
+
+// callerAuthenticator authenticates the callers by API key or session access token.
+type callerAuthenticator interface {
+	AuthenticateAPIKey(ctx context.Context, apiKey string) (*service.User, *service.APIKey, error)
+	AuthenticateAccessToken(ctx context.Context, accessToken string) (*service.User, error)
+}
+
+// Authorization authenticates the callers and enforces the role required by each RPC.
+type Authorization struct {
+	logger        *zap.Logger
+	authenticator callerAuthenticator
+}
+
+// NewAuthorization creates a new authorization interceptor.
+func NewAuthorization(logger *zap.Logger, authenticator callerAuthenticator) *Authorization {
+	return &Authorization{
+		logger:        logger,
+		authenticator: authenticator,
+	}
+}
+
+// CallerFromContext returns the authenticated caller of the RPC, if any.
+func CallerFromContext(ctx context.Context) (*service.User, bool) {
+	caller, ok := ctx.Value(callerKey{}).(*service.User)
+	return caller, ok
+}
+
+// UnaryServerInterceptor authenticates the caller from the "authorization: Bearer <token>"
+// metadata, where the token is an API key or a session access token, or from the
+// "x-api-key: <key>" metadata, and attaches it to the context. The RPCs that are not open to
+// anonymous callers are rejected with Unauthenticated when there is no caller, and with
+// PermissionDenied when they require an admin, or the user of the request or an admin, and
+// the caller isn't one. The unknown RPCs are rejected with PermissionDenied. The callers authenticated by API key are also rejected with PermissionDenied
+// when the key lacks the scope of the RPC, and all the callers when they don't belong to the
+// tenant the RPC is scoped to.
+func (a *Authorization) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := a.authorize(ctx, info.FullMethod, req)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor authenticates the callers of the streaming RPCs like
+// UnaryServerInterceptor. The messages are not received yet, so the RPCs whose
+// role depends on the request require an admin.
+func (a *Authorization) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := a.authorize(ss.Context(), info.FullMethod, nil)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// authorize authenticates the caller of the RPC, checks that it is allowed to call the
+// method with the request and returns the context with the caller attached.
+func (a *Authorization) authorize(ctx context.Context, fullMethod string, req any) (context.Context, error) {
+	caller, key, err := a.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if caller != nil {
+		// The tenant comes from the metadata set by the client, so it must be the caller's.
+		if !tenant.Visible(ctx, caller.TenantID) {
+			a.logger.Warn("caller belongs to another tenant", zap.String("caller_id", caller.ID), zap.String("tenant_id", tenant.OrDefault(ctx)))
+			return nil, ErrTenantMismatch
+		}
+
+		ctx = context.WithValue(ctx, callerKey{}, caller)
+		ctx = audit.ContextWithActor(ctx, caller.ID)
+	}
+
+	method := fullMethod[strings.LastIndex(fullMethod, "/")+1:]
+	if key != nil && !key.HasScope(requiredScope(fullMethod)) {
+		a.logger.Warn("api key lacks the scope", zap.String("api_key_id", key.ID), zap.String("method", method))
+		return nil, ErrAPIKeyScopeRequired
+	}
+
+	// The new RPCs are closed until they are added to the maps.
+	if !knownMethod(method) {
+		a.logger.Warn("method is not authorized", zap.String("method", method))
+		return nil, ErrMethodNotAllowed
+	}
+
+	if caller == nil {
+		if allowed, ok := anonymous[method]; ok && allowed(req) {
+			return ctx, nil
+		}
+		return nil, ErrAuthRequired
+	}
+
+	if requiresAdmin, ok := adminOnly[method]; ok && requiresAdmin(req) && !caller.IsAdmin() {
+		a.logger.Warn("caller is not allowed", zap.String("caller_id", caller.ID), zap.String("method", method))
+		return nil, ErrAdminRequired
+	}
+
+	if userID, ok := ownerOnly[method]; ok {
+		if caller.ID != userID(req) && !caller.IsAdmin() {
+			a.logger.Warn("caller is not the user", zap.String("caller_id", caller.ID), zap.String("method", method))
+			return nil, ErrOwnerRequired
+		}
+	}
+	return ctx, nil
+}
+
+// contextStream is a server stream carrying the context set by the interceptors.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
+
+// authenticate returns the caller identified by the request metadata, or nil for anonymous
+// requests, and the API key it authenticated with, if any.
+func (a *Authorization) authenticate(ctx context.Context) (*service.User, *service.APIKey, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	values := md.Get(authorizationHeader)
+	apiKeys := md.Get(apiKeyHeader)
+
+	if len(values) > 0 && len(apiKeys) > 0 {
+		return nil, nil, ErrAPIKeyConflict
+	}
+
+	if len(apiKeys) > 0 {
+		return a.authenticateAPIKey(ctx, apiKeys[0])
+	}
+
+	if len(values) == 0 {
+		return nil, nil, nil
+	}
+
+	token := strings.TrimPrefix(values[0], bearerPrefix)
+	if token == values[0] {
+		return nil, nil, ErrUnauthenticated
+	}
+
+	if !service.IsAccessToken(token) {
+		return a.authenticateAPIKey(ctx, token)
+	}
+
+	caller, err := a.authenticator.AuthenticateAccessToken(ctx, token)
+	if err != nil {
+		a.logger.Warn("failed to authenticate caller", zap.Error(err))
+		return nil, nil, convertServiceError(err)
+	}
+	return caller, nil, nil
+}
+
+func (a *Authorization) authenticateAPIKey(ctx context.Context, apiKey string) (*service.User, *service.APIKey, error) {
+	caller, key, err := a.authenticator.AuthenticateAPIKey(ctx, apiKey)
+	if err != nil {
+		a.logger.Warn("failed to authenticate caller", zap.Error(err))
+		return nil, nil, convertServiceError(err)
+	}
+	return caller, key, nil
+}
+
+// requiredScope returns the API key scope required by the full gRPC method name:
+// ScopeUsersRead for the read-only RPCs and ScopeUsersWrite for the other ones.
+func requiredScope(fullMethod string) string {
+	if isReadOnlyMethod(fullMethod) {
+		return service.ScopeUsersRead
+	}
+	return service.ScopeUsersWrite
+}
@@ -0,0 +1,50 @@
+package app
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+const forwardedForHeader string = "x-forwarded-for"
+
+// ServerOption configures the gRPC server.
+type ServerOption func(*GRPCServer)
+
+// WithTrustForwardedFor makes the server take the client IP from the x-forwarded-for
+// metadata. Only use it behind a proxy that sets the header, as clients can forge it.
+func WithTrustForwardedFor() ServerOption {
+	return func(s *GRPCServer) {
+		s.trustForwardedFor = true
+	}
+}
+
+// clientIP returns the IP of the client, or nil if it is not known
+// (e.g. when the server listens on a Unix socket).
+func clientIP(ctx context.Context, trustForwardedFor bool) net.IP {
+	if trustForwardedFor {
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get(forwardedForHeader); len(values) > 0 {
+				// The first address is the client, the next ones are the proxies.
+				first, _, _ := strings.Cut(values[0], ",")
+				if ip := net.ParseIP(strings.TrimSpace(first)); ip != nil {
+					return ip
+				}
+			}
+		}
+	}
+
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return nil
+	}
+	return net.ParseIP(host)
+}
@@ -0,0 +1,77 @@
+package app
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+func TestClientIP(t *testing.T) {
+	t.Parallel()
+
+	peerCtx := peer.NewContext(context.TODO(), &peer.Peer{
+		Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 43210},
+	})
+	forwardedCtx := metadata.NewIncomingContext(peerCtx, metadata.Pairs(forwardedForHeader, "203.0.113.7, 10.0.0.2"))
+
+	testCases := []struct {
+		name              string
+		ctx               context.Context
+		trustForwardedFor bool
+		expected          string
+	}{
+		{
+			name:     "peer address",
+			ctx:      peerCtx,
+			expected: "10.0.0.1",
+		},
+		{
+			name:     "forwarded for is ignored by default",
+			ctx:      forwardedCtx,
+			expected: "10.0.0.1",
+		},
+		{
+			name:              "forwarded for",
+			ctx:               forwardedCtx,
+			trustForwardedFor: true,
+			expected:          "203.0.113.7",
+		},
+		{
+			name:              "invalid forwarded for",
+			ctx:               metadata.NewIncomingContext(peerCtx, metadata.Pairs(forwardedForHeader, "unknown")),
+			trustForwardedFor: true,
+			expected:          "10.0.0.1",
+		},
+		{
+			name: "unix socket",
+			ctx: peer.NewContext(context.TODO(), &peer.Peer{
+				Addr: &net.UnixAddr{Name: "/tmp/usrsvc.sock", Net: "unix"},
+			}),
+		},
+		{
+			name: "no peer",
+			ctx:  context.TODO(),
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Act
+			ip := clientIP(tc.ctx, tc.trustForwardedFor)
+
+			// Assert
+			if tc.expected == "" {
+				assert.Nil(t, ip)
+				return
+			}
+			assert.Equal(t, tc.expected, ip.String())
+		})
+	}
+}
@@ -0,0 +1,275 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/bufbuild/connect-go"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// connectReadMaxBytes is the largest request message accepted, the default of the gRPC server.
+const connectReadMaxBytes int = 4 << 20
+
+// ConnectHandler serves gRPC services over HTTP with connect-go, in the Connect, gRPC-Web
+// and gRPC protocols, so browsers can call them without a translating proxy like Envoy.
+// The RPCs run through the interceptors of the gRPC server, so they are authorized, rate
+// limited and counted like the RPCs of the gRPC port. The client streaming RPCs are not
+// served, browsers cannot stream requests.
+type ConnectHandler struct {
+	logger *zap.Logger
+	unary  grpc.UnaryServerInterceptor
+	stream grpc.StreamServerInterceptor
+	mux    *http.ServeMux
+}
+
+var _ grpc.ServiceRegistrar = (*ConnectHandler)(nil)
+
+// NewConnectHandler creates a Connect handler with the interceptors of the gRPC server in
+// the same order. The services are added with RegisterService.
+func NewConnectHandler(logger *zap.Logger, unary []grpc.UnaryServerInterceptor, stream []grpc.StreamServerInterceptor) *ConnectHandler {
+	return &ConnectHandler{
+		logger: logger,
+		unary:  chainUnaryInterceptors(unary),
+		stream: chainStreamInterceptors(stream),
+		mux:    http.NewServeMux(),
+	}
+}
+
+// RegisterService serves the RPCs of a gRPC service implemented by impl, under
+// /<service name>/<method> like the generated connect-go handlers.
+func (h *ConnectHandler) RegisterService(desc *grpc.ServiceDesc, impl any) {
+	options := []connect.HandlerOption{
+		connect.WithCodec(connectCodec{name: "proto"}),
+		connect.WithCodec(connectCodec{name: "json"}),
+		connect.WithCodec(connectCodec{name: "json; charset=utf-8"}),
+		connect.WithReadMaxBytes(connectReadMaxBytes),
+	}
+
+	for _, method := range desc.Methods {
+		procedure := "/" + desc.ServiceName + "/" + method.MethodName
+		h.mux.Handle(procedure, connect.NewUnaryHandler(procedure, h.unaryHandler(impl, method), options...))
+	}
+
+	for _, stream := range desc.Streams {
+		if stream.ClientStreams {
+			continue
+		}
+
+		procedure := "/" + desc.ServiceName + "/" + stream.StreamName
+		h.mux.Handle(procedure, connect.NewServerStreamHandler(procedure, h.streamHandler(impl, procedure, stream), options...))
+	}
+}
+
+// ServeHTTP passes the headers of the request as incoming gRPC metadata and routes it.
+func (h *ConnectHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r.WithContext(incomingContext(r)))
+}
+
+func (h *ConnectHandler) unaryHandler(impl any, method grpc.MethodDesc) func(context.Context, *connect.Request[connectMessage]) (*connect.Response[connectMessage], error) {
+	return func(ctx context.Context, req *connect.Request[connectMessage]) (*connect.Response[connectMessage], error) {
+		transport := &connectTransportStream{method: req.Spec().Procedure}
+		ctx = grpc.NewContextWithServerTransportStream(ctx, transport)
+
+		resp, err := method.Handler(impl, ctx, req.Msg.decode, h.unary)
+		if err != nil {
+			return nil, connectError(err, transport.header, transport.trailer)
+		}
+
+		msg, ok := resp.(proto.Message)
+		if !ok {
+			h.logger.Error("unexpected response type", zap.String("procedure", req.Spec().Procedure))
+			return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("unexpected response type %T", resp))
+		}
+
+		out := connect.NewResponse(&connectMessage{msg: msg})
+		copyMetadata(out.Header(), transport.header)
+		copyMetadata(out.Trailer(), transport.trailer)
+		return out, nil
+	}
+}
+
+func (h *ConnectHandler) streamHandler(impl any, procedure string, desc grpc.StreamDesc) func(context.Context, *connect.Request[connectMessage], *connect.ServerStream[connectMessage]) error {
+	return func(ctx context.Context, req *connect.Request[connectMessage], stream *connect.ServerStream[connectMessage]) error {
+		ss := &connectServerStream{ctx: ctx, req: req.Msg, stream: stream}
+		info := &grpc.StreamServerInfo{FullMethod: procedure, IsServerStream: true}
+
+		if err := h.stream(impl, ss, info, desc.Handler); err != nil {
+			return connectError(err, nil, ss.trailer)
+		}
+
+		copyMetadata(stream.ResponseTrailer(), ss.trailer)
+		return nil
+	}
+}
+
+// connectMessage carries the messages of the gRPC handlers through connect-go, which
+// needs their types at compile time: a request is kept encoded until the handler decodes
+// it into its request type, and a response is encoded by the codec.
+type connectMessage struct {
+	data  []byte
+	codec string
+	msg   proto.Message
+}
+
+// decode decodes the request into msg, the request type of the handler.
+func (m *connectMessage) decode(msg any) error {
+	pb, ok := msg.(proto.Message)
+	if !ok {
+		return fmt.Errorf("%T is not a proto message", msg)
+	}
+
+	if m.codec == "proto" {
+		return proto.Unmarshal(m.data, pb)
+	}
+
+	if len(m.data) == 0 {
+		return errors.New("zero-length payload is not a valid JSON object")
+	}
+	return protojson.Unmarshal(m.data, pb)
+}
+
+// connectCodec is the codec of the connectMessages, in binary or JSON protobuf.
+type connectCodec struct {
+	name string
+}
+
+func (c connectCodec) Name() string {
+	return c.name
+}
+
+// Marshal encodes the responses and the messages of connect-go itself, e.g. the status
+// of the errors in the gRPC protocols.
+func (c connectCodec) Marshal(v any) ([]byte, error) {
+	msg, ok := v.(proto.Message)
+	if m, isConnect := v.(*connectMessage); isConnect {
+		msg, ok = m.msg, m.msg != nil
+	}
+
+	if !ok {
+		return nil, fmt.Errorf("unexpected message type %T", v)
+	}
+
+	if c.name == "proto" {
+		return proto.Marshal(msg)
+	}
+	return protojson.Marshal(msg)
+}
+
+// Unmarshal keeps the requests encoded, and decodes the messages of connect-go itself.
+func (c connectCodec) Unmarshal(data []byte, v any) error {
+	m, ok := v.(*connectMessage)
+	if !ok {
+		m = &connectMessage{}
+	}
+
+	m.data = append(m.data[:0], data...)
+	m.codec = c.name
+	if !ok {
+		return m.decode(v)
+	}
+	return nil
+}
+
+// connectTransportStream collects the header and trailer set by the unary handlers and
+// interceptors with grpc.SetHeader and grpc.SetTrailer.
+type connectTransportStream struct {
+	method  string
+	header  metadata.MD
+	trailer metadata.MD
+}
+
+func (s *connectTransportStream) Method() string {
+	return s.method
+}
+
+func (s *connectTransportStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func (s *connectTransportStream) SendHeader(md metadata.MD) error {
+	return s.SetHeader(md)
+}
+
+func (s *connectTransportStream) SetTrailer(md metadata.MD) error {
+	s.trailer = metadata.Join(s.trailer, md)
+	return nil
+}
+
+// connectServerStream is the grpc.ServerStream of a server streaming RPC served by
+// connect-go, receiving its only request and sending the responses to the client.
+type connectServerStream struct {
+	ctx      context.Context
+	req      *connectMessage
+	received bool
+	stream   *connect.ServerStream[connectMessage]
+	trailer  metadata.MD
+}
+
+func (s *connectServerStream) SetHeader(md metadata.MD) error {
+	copyMetadata(s.stream.ResponseHeader(), md)
+	return nil
+}
+
+func (s *connectServerStream) SendHeader(md metadata.MD) error {
+	return s.SetHeader(md)
+}
+
+func (s *connectServerStream) SetTrailer(md metadata.MD) {
+	s.trailer = metadata.Join(s.trailer, md)
+}
+
+func (s *connectServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *connectServerStream) SendMsg(m any) error {
+	msg, ok := m.(proto.Message)
+	if !ok {
+		return fmt.Errorf("%T is not a proto message", m)
+	}
+	return s.stream.Send(&connectMessage{msg: msg})
+}
+
+func (s *connectServerStream) RecvMsg(m any) error {
+	if s.received {
+		return io.EOF
+	}
+
+	s.received = true
+	return s.req.decode(m)
+}
+
+// connectError returns the connect error of a gRPC error, with the same code, message
+// and details, and the metadata set by the handler.
+func connectError(err error, header, trailer metadata.MD) error {
+	st := status.Convert(err)
+
+	connectErr := connect.NewError(connect.Code(st.Code()), errors.New(st.Message()))
+	for _, detail := range st.Proto().Details {
+		if errDetail, err := connect.NewErrorDetail(detail); err == nil {
+			connectErr.AddDetail(errDetail)
+		}
+	}
+
+	copyMetadata(connectErr.Meta(), header)
+	copyMetadata(connectErr.Meta(), trailer)
+	return connectErr
+}
+
+func copyMetadata(dst http.Header, md metadata.MD) {
+	for key, values := range md {
+		for _, value := range values {
+			dst.Add(key, value)
+		}
+	}
+}
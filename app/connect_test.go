@@ -0,0 +1,184 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alesr/usrsvc/internal/users/service"
+	apiv1 "github.com/alesr/usrsvc/proto/users/v1"
+	apiv2 "github.com/alesr/usrsvc/proto/users/v2"
+	"github.com/bufbuild/connect-go"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func newConnectServerHelper(t *testing.T, svc userService, unary ...grpc.UnaryServerInterceptor) *httptest.Server {
+	t.Helper()
+
+	v1 := NewGRPCServer(zap.NewNop(), svc)
+
+	h := NewConnectHandler(zap.NewNop(), unary, nil)
+	h.RegisterService(&apiv1.UserService_ServiceDesc, v1)
+	h.RegisterService(&apiv2.UserService_ServiceDesc, NewGRPCServerV2(v1))
+
+	server := httptest.NewServer(h)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestConnectUnary(t *testing.T) {
+	t.Parallel()
+
+	id := uuid.New().String()
+
+	var observedMethod string
+	interceptor := func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		observedMethod = info.FullMethod
+
+		md, _ := metadata.FromIncomingContext(ctx)
+		if got := md.Get(authorizationHeader); len(got) != 1 || got[0] != "Bearer s3cret" {
+			return nil, ErrAuthRequired
+		}
+
+		if err := grpc.SetHeader(ctx, metadata.Pairs(warningHeader, "nickname:test")); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+
+	svc := &serviceMock{
+		FetchFunc: func(ctx context.Context, userID string) (*service.User, error) {
+			return &service.User{ID: userID, Nickname: "jdoe", Role: service.RoleUser}, nil
+		},
+	}
+
+	server := newConnectServerHelper(t, svc, interceptor)
+
+	t.Run("connect protocol with JSON", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/usrsvc.users.v2.UserService/GetUser", strings.NewReader(`{"id": "`+id+`"}`))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer s3cret")
+
+		resp, err := server.Client().Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "nickname:test", resp.Header.Get(warningHeader))
+		assert.Equal(t, "/usrsvc.users.v2.UserService/GetUser", observedMethod)
+
+		var body struct {
+			User struct {
+				ID       string `json:"id"`
+				Nickname string `json:"nickname"`
+				Role     string `json:"role"`
+			} `json:"user"`
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+
+		assert.Equal(t, id, body.User.ID)
+		assert.Equal(t, "jdoe", body.User.Nickname)
+		assert.Equal(t, "ROLE_USER", body.User.Role)
+	})
+
+	t.Run("grpc-web protocol", func(t *testing.T) {
+		client := connect.NewClient[apiv1.GetUserRequest, apiv1.GetUserResponse](server.Client(), server.URL+"/UserService/GetUser", connect.WithGRPCWeb())
+
+		req := connect.NewRequest(&apiv1.GetUserRequest{Id: id})
+		req.Header().Set("Authorization", "Bearer s3cret")
+
+		resp, err := client.CallUnary(context.TODO(), req)
+		require.NoError(t, err)
+
+		assert.Equal(t, id, resp.Msg.User.Id)
+		assert.Equal(t, "nickname:test", resp.Header().Get(warningHeader))
+		assert.Equal(t, "/UserService/GetUser", observedMethod)
+	})
+
+	t.Run("rejected by the interceptors", func(t *testing.T) {
+		client := connect.NewClient[apiv1.GetUserRequest, apiv1.GetUserResponse](server.Client(), server.URL+"/UserService/GetUser", connect.WithGRPCWeb())
+
+		_, err := client.CallUnary(context.TODO(), connect.NewRequest(&apiv1.GetUserRequest{Id: id}))
+
+		assert.Equal(t, connect.CodeUnauthenticated, connect.CodeOf(err), err)
+	})
+}
+
+func TestConnectErrorDetails(t *testing.T) {
+	t.Parallel()
+
+	server := newConnectServerHelper(t, &serviceMock{})
+	client := connect.NewClient[apiv2.GetUserRequest, apiv2.GetUserResponse](server.Client(), server.URL+"/usrsvc.users.v2.UserService/GetUser")
+
+	_, err := client.CallUnary(context.TODO(), connect.NewRequest(&apiv2.GetUserRequest{Id: "42"}))
+
+	var connectErr *connect.Error
+	require.True(t, errors.As(err, &connectErr))
+	assert.Equal(t, connect.CodeInvalidArgument, connectErr.Code())
+
+	var fields []string
+	for _, detail := range connectErr.Details() {
+		value, err := detail.Value()
+		require.NoError(t, err)
+
+		if badRequest, ok := value.(*errdetails.BadRequest); ok {
+			for _, violation := range badRequest.FieldViolations {
+				fields = append(fields, violation.Field)
+			}
+		}
+	}
+	assert.Equal(t, []string{"id"}, fields)
+}
+
+func TestConnectServerStream(t *testing.T) {
+	t.Parallel()
+
+	svc := &serviceMock{
+		ExportUsersFunc: func(ctx context.Context, filter service.FilterParams, fn func(user *service.User) error) error {
+			require.NotNil(t, filter.Country)
+			assert.Equal(t, "BR", *filter.Country)
+
+			return fn(&service.User{ID: uuid.New().String(), Nickname: "jdoe", Country: "BR"})
+		},
+	}
+
+	server := newConnectServerHelper(t, svc)
+	client := connect.NewClient[apiv1.ExportUsersRequest, apiv1.ExportUsersResponse](server.Client(), server.URL+"/UserService/ExportUsers", connect.WithGRPCWeb())
+
+	stream, err := client.CallServerStream(context.TODO(), connect.NewRequest(&apiv1.ExportUsersRequest{Country: "BR", Format: "jsonl"}))
+	require.NoError(t, err)
+	defer stream.Close()
+
+	var export strings.Builder
+	for stream.Receive() {
+		export.Write(stream.Msg().Chunk)
+	}
+	require.NoError(t, stream.Err())
+
+	assert.Contains(t, export.String(), `"nickname":"jdoe"`)
+}
+
+func TestConnectClientStreamNotServed(t *testing.T) {
+	t.Parallel()
+
+	server := newConnectServerHelper(t, &serviceMock{})
+
+	resp, err := server.Client().Post(server.URL+"/UserService/ImportUsers", "application/grpc-web+proto", strings.NewReader(""))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
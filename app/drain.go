@@ -0,0 +1,65 @@
+package app
+
+import (
+	"sync"
+	"time"
+)
+
+// Drain is the pre-stop switch. While draining, the health check reports NOT_SERVING
+// so the load balancers stop sending new traffic to the instance, but the RPCs keep
+// being served until the shutdown closes the connections.
+type Drain struct {
+	gracePeriod time.Duration
+	now         func() time.Time
+
+	mu      sync.Mutex
+	started time.Time
+}
+
+// NewDrain creates a drain switch that waits the grace period before shutting down.
+func NewDrain(gracePeriod time.Duration) *Drain {
+	return &Drain{
+		gracePeriod: gracePeriod,
+		now:         time.Now,
+	}
+}
+
+// WithDrain makes the health check report NOT_SERVING once the drain has started.
+func WithDrain(drain *Drain) ServerOption {
+	return func(s *GRPCServer) {
+		s.drain = drain
+	}
+}
+
+// Draining reports whether the drain has started.
+func (d *Drain) Draining() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return !d.started.IsZero()
+}
+
+// Start starts the drain, unless it has already started, and returns what is left
+// of the grace period. Calling it again does not restart the grace period.
+func (d *Drain) Start() time.Duration {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+	if d.started.IsZero() {
+		d.started = now
+	}
+
+	if remaining := d.gracePeriod - now.Sub(d.started); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+// Cancel stops the drain, e.g. when it was started by mistake.
+func (d *Drain) Cancel() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.started = time.Time{}
+}
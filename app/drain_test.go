@@ -0,0 +1,39 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDrain(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	drain := NewDrain(10 * time.Second)
+	drain.now = func() time.Time { return now }
+
+	// Act
+	drainingBefore := drain.Draining()
+	firstStart := drain.Start()
+
+	now = now.Add(4 * time.Second)
+	secondStart := drain.Start()
+
+	now = now.Add(10 * time.Second)
+	lateStart := drain.Start()
+
+	drainingAfter := drain.Draining()
+	drain.Cancel()
+
+	// Assert
+	assert.False(t, drainingBefore)
+	assert.Equal(t, 10*time.Second, firstStart)
+	assert.Equal(t, 6*time.Second, secondStart)
+	assert.Zero(t, lateStart)
+	assert.True(t, drainingAfter)
+	assert.False(t, drain.Draining())
+}
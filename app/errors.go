@@ -3,31 +3,138 @@ package app
 import (
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/alesr/usrsvc/internal/users/service"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+const (
+	// fieldLockedViolation is the type of the precondition violations of locked fields.
+	fieldLockedViolation string = "FIELD_LOCKED"
+
+	// duplicateReason is the reason of the error info of the duplicate emails and nicknames.
+	duplicateReason string = "DUPLICATE"
+
+	// totpRequiredReason is the reason of the error info of the logins missing a TOTP code,
+	// telling the clients to prompt for one rather than for the password again.
+	totpRequiredReason string = "TOTP_REQUIRED"
+
+	// errorDomain is the domain of the error infos.
+	errorDomain string = "usrsvc"
 )
 
 var (
 	// Enumerate all possible errors that can be returned by the transport layer.
 
-	ErrCountryCodeInvalid  error = status.Errorf(codes.InvalidArgument, "invalid country")
-	ErrCountryCodeRequired error = status.Errorf(codes.Internal, "country is required")
-	ErrEmailFormat         error = status.Errorf(codes.Internal, "email is invalid")
-	ErrEmailRequired       error = status.Errorf(codes.Internal, "email is required")
-	ErrIDFormat            error = status.Errorf(codes.Internal, "id is invalid")
-	ErrIDRequired          error = status.Errorf(codes.Internal, "id is required")
-	ErrInternal            error = status.Errorf(codes.Internal, "internal error")
-	ErrNameFormat          error = status.Errorf(codes.Internal, "name must only contain letters and spaces")
-	ErrNameLength          error = status.Errorf(codes.Internal, fmt.Sprintf("name must be between %d and %d characters", minNameLength, maxNameLength))
-	ErrNameRequired        error = status.Errorf(codes.Internal, "name is required")
-	ErrPageTokenInvalid    error = status.Errorf(codes.InvalidArgument, "invalid page token")
-	ErrPasswordFormat      error = status.Errorf(codes.Internal, "password must contain at least one letter, one number and one special character")
-	ErrPasswordLength      error = status.Errorf(codes.Internal, fmt.Sprintf("password must be between %d and %d characters", minPasswordLength, maxPasswordLength))
-	ErrPasswordRequired    error = status.Errorf(codes.Internal, "password is required")
-	ErrUserAlreadyExists   error = status.Errorf(codes.AlreadyExists, "user already exists")
-	ErrUserNotFound        error = status.Errorf(codes.NotFound, "user not found")
+	ErrAdminRequired             error = status.Errorf(codes.PermissionDenied, "admin role required")
+	ErrAdminUserRequired         error = status.Errorf(codes.InvalidArgument, "admin user is required")
+	ErrAlreadyBootstrapped       error = status.Errorf(codes.FailedPrecondition, "service already bootstrapped")
+	ErrAPIKeyConflict            error = status.Errorf(codes.InvalidArgument, "send either an authorization or an x-api-key header, not both")
+	ErrAPIKeyNameRequired        error = status.Errorf(codes.InvalidArgument, "api key name is required")
+	ErrAPIKeyNotFound            error = status.Errorf(codes.NotFound, "api key not found")
+	ErrAPIKeyScopeRequired       error = status.Errorf(codes.PermissionDenied, "api key lacks the required scope")
+	ErrAPIKeyScopesInvalid       error = status.Errorf(codes.InvalidArgument, fmt.Sprintf("invalid api key scopes, valid scopes are %s", strings.Join(service.APIKeyScopes, " and ")))
+	ErrAPIKeyScopesRequired      error = status.Errorf(codes.InvalidArgument, "api key scopes are required")
+	ErrAuditDisabled             error = status.Errorf(codes.FailedPrecondition, "audit log is disabled")
+	ErrAuthRequired              error = status.Errorf(codes.Unauthenticated, "authentication required")
+	ErrAvatarInvalid             error = status.Errorf(codes.InvalidArgument, fmt.Sprintf("avatar must be a png, jpeg, gif or webp image of at most %d bytes", service.MaxAvatarSize))
+	ErrAvatarNotFound            error = status.Errorf(codes.NotFound, "avatar not found")
+	ErrAvatarsDisabled           error = status.Errorf(codes.FailedPrecondition, "avatars are disabled")
+	ErrBirthdateFormat           error = status.Errorf(codes.InvalidArgument, "birthdate must be a date in YYYY-MM-DD format")
+	ErrBirthdateInvalid          error = status.Errorf(codes.InvalidArgument, "birthdate must be in the past and at most 150 years ago")
+	ErrBootstrapDisabled         error = status.Errorf(codes.FailedPrecondition, "bootstrap is disabled")
+	ErrBootstrapToken            error = status.Errorf(codes.PermissionDenied, "invalid bootstrap token")
+	ErrChangeLogDisabled         error = status.Errorf(codes.FailedPrecondition, "change log is disabled")
+	ErrCountryCodeInvalid        error = status.Errorf(codes.InvalidArgument, "invalid country")
+	ErrCountryCodeRequired       error = status.Errorf(codes.InvalidArgument, "country is required")
+	ErrCreatedAtInvalid          error = status.Errorf(codes.InvalidArgument, "creation date must be a valid time in the past")
+	ErrCreatedRangeInvalid       error = status.Errorf(codes.InvalidArgument, "invalid creation time range")
+	ErrDefaultTenantRequired     error = status.Errorf(codes.PermissionDenied, "only available to the default tenant")
+	ErrEmailAmbiguous            error = status.Errorf(codes.FailedPrecondition, "email is used by several users")
+	ErrEmailFormat               error = status.Errorf(codes.InvalidArgument, "email is invalid")
+	ErrEmailRequired             error = status.Errorf(codes.InvalidArgument, "email is required")
+	ErrExportFormatInvalid       error = status.Errorf(codes.InvalidArgument, "format must be csv or jsonl")
+	ErrIDFormat                  error = status.Errorf(codes.InvalidArgument, "id is invalid")
+	ErrIDRequired                error = status.Errorf(codes.InvalidArgument, "id is required")
+	ErrIDTokenInvalid            error = status.Errorf(codes.Unauthenticated, "invalid id token")
+	ErrIDTokenRequired           error = status.Errorf(codes.InvalidArgument, "id token is required")
+	ErrFieldLocked               error = status.Errorf(codes.FailedPrecondition, "field is locked")
+	ErrFieldNotClearable         error = status.Errorf(codes.InvalidArgument, "phone, locale, timezone and birthdate cannot be cleared")
+	ErrFieldNotLockable          error = status.Errorf(codes.InvalidArgument, "field cannot be locked, lockable fields are first_name, last_name, nickname, email and country")
+	ErrFilterRequired            error = status.Errorf(codes.InvalidArgument, "country or created_before is required")
+	ErrIdentityEmailMismatch     error = status.Errorf(codes.PermissionDenied, "the id token must assert the verified email of the user")
+	ErrIdentityLinked            error = status.Errorf(codes.AlreadyExists, "identity already linked to a user")
+	ErrIdentityNotFound          error = status.Errorf(codes.NotFound, "identity not linked to the user")
+	ErrIdentityProvider          error = status.Errorf(codes.InvalidArgument, "unknown identity provider")
+	ErrInternal                  error = status.Errorf(codes.Internal, "internal error")
+	ErrLocaleFormat              error = status.Errorf(codes.InvalidArgument, "locale must be a BCP 47 language tag, e.g. pt-BR")
+	ErrLockFieldsRequired        error = status.Errorf(codes.InvalidArgument, "fields are required")
+	ErrLockReasonLength          error = status.Errorf(codes.InvalidArgument, fmt.Sprintf("lock reason must be at most %d characters", maxLockReasonLength))
+	ErrLockReasonRequired        error = status.Errorf(codes.InvalidArgument, "lock reason is required")
+	ErrMaintenance               error = status.Errorf(codes.Unavailable, "service is in maintenance mode, please retry later")
+	ErrMergeSameUser             error = status.Errorf(codes.InvalidArgument, "cannot merge a user into itself")
+	ErrMetadataInvalid           error = status.Errorf(codes.InvalidArgument, fmt.Sprintf("invalid metadata, up to %d entries with lowercase keys of at most %d characters and values of at most %d bytes", service.MaxMetadataEntries, service.MaxMetadataKeyLength, service.MaxMetadataValueLength))
+	ErrMethodNotAllowed          error = status.Errorf(codes.PermissionDenied, "method not allowed")
+	ErrMinAgeInvalid             error = status.Errorf(codes.InvalidArgument, fmt.Sprintf("min age must be between 0 and %d years", maxPolicyMinAge))
+	ErrMinPasswordLengthInvalid  error = status.Errorf(codes.InvalidArgument, fmt.Sprintf("min password length must be between 0 and %d characters", userValidation.MaxPasswordLength))
+	ErrNameFormat                error = status.Errorf(codes.InvalidArgument, "name must only contain letters, spaces, hyphens and apostrophes")
+	ErrNameLength                error = status.Errorf(codes.InvalidArgument, fmt.Sprintf("name must be between %d and %d characters", userValidation.MinNameLength, userValidation.MaxNameLength))
+	ErrNameRequired              error = status.Errorf(codes.InvalidArgument, "name is required")
+	ErrNameSeparatorsInvalid     error = status.Errorf(codes.InvalidArgument, fmt.Sprintf("name separators must be up to %d punctuation marks or symbols", maxPolicyNameSeparators))
+	ErrNicknameTaken             error = status.Errorf(codes.FailedPrecondition, "nickname already taken")
+	ErrOperationNameFormat       error = status.Errorf(codes.InvalidArgument, "operation name is invalid")
+	ErrOperationNotFound         error = status.Errorf(codes.NotFound, "operation not found")
+	ErrOperationsClosed          error = status.Errorf(codes.Unavailable, "server is shutting down, please retry later")
+	ErrOwnerRequired             error = status.Errorf(codes.PermissionDenied, "only the user or an admin can do this")
+	ErrPageTokenInvalid          error = status.Errorf(codes.InvalidArgument, "invalid page token")
+	ErrPasswordBreached          error = status.Errorf(codes.InvalidArgument, "password has appeared in a data breach, please choose another one")
+	ErrPasswordFormat            error = status.Errorf(codes.InvalidArgument, "password must contain at least one letter, one number and one special character")
+	ErrPasswordLength            error = status.Errorf(codes.InvalidArgument, fmt.Sprintf("password must be between %d and %d characters", userValidation.MinPasswordLength, userValidation.MaxPasswordLength))
+	ErrPasswordNotUpdatable      error = status.Errorf(codes.InvalidArgument, "password can't be updated, use ChangePassword")
+	ErrPasswordRequired          error = status.Errorf(codes.InvalidArgument, "password is required")
+	ErrPhoneCodeInvalid          error = status.Errorf(codes.InvalidArgument, "invalid or expired phone verification code, please request a new one")
+	ErrPhoneCodeRequired         error = status.Errorf(codes.InvalidArgument, "phone verification code is required")
+	ErrPhoneFormat               error = status.Errorf(codes.InvalidArgument, "phone must be in E.164 format, e.g. +14155550123")
+	ErrPhoneNotSet               error = status.Errorf(codes.FailedPrecondition, "phone number not set")
+	ErrPhoneVerificationDisabled error = status.Errorf(codes.FailedPrecondition, "phone verification is disabled")
+	ErrRateLimited               error = status.Errorf(codes.ResourceExhausted, "too many requests, please retry later")
+	ErrRefreshTokenRequired      error = status.Errorf(codes.InvalidArgument, "refresh token is required")
+	ErrRegionChange              error = status.Errorf(codes.FailedPrecondition, "user cannot be moved to another data region")
+	ErrRequestRequired           error = status.Errorf(codes.InvalidArgument, "request is required")
+	ErrResetTokenInvalid         error = status.Errorf(codes.InvalidArgument, "invalid or expired password reset token")
+	ErrResetTokenRequired        error = status.Errorf(codes.InvalidArgument, "password reset token is required")
+	ErrResourceExhausted         error = status.Errorf(codes.ResourceExhausted, "server is busy, please retry later")
+	ErrSearchQueryInvalid        error = status.Errorf(codes.InvalidArgument, fmt.Sprintf("search query must contain letters or digits and at most %d characters", maxSearchQueryLength))
+	ErrSearchQueryRequired       error = status.Errorf(codes.InvalidArgument, "search query is required")
+	ErrSessionNotFound           error = status.Errorf(codes.NotFound, "session not found")
+	ErrSessionRequired           error = status.Errorf(codes.InvalidArgument, "session id or refresh token is required")
+	ErrSinceTokenInvalid         error = status.Errorf(codes.InvalidArgument, "invalid since token")
+	ErrSubjectRequired           error = status.Errorf(codes.InvalidArgument, "identity subject is required")
+	ErrTOTPCodeInvalid           error = status.Errorf(codes.InvalidArgument, "invalid totp code")
+	ErrTOTPCodeRequired          error = status.Errorf(codes.InvalidArgument, "totp code is required")
+	ErrTOTPDisabled              error = status.Errorf(codes.FailedPrecondition, "totp is disabled")
+	ErrTOTPEnabled               error = status.Errorf(codes.FailedPrecondition, "totp already enabled")
+	ErrTOTPNotEnrolled           error = status.Errorf(codes.FailedPrecondition, "totp not enrolled")
+	ErrTOTPRequired              error = status.Errorf(codes.Unauthenticated, "totp code required")
+	ErrTenantInvalid             error = status.Errorf(codes.InvalidArgument, "tenant must be up to 63 lowercase letters, digits and dashes")
+	ErrTenantMismatch            error = status.Errorf(codes.PermissionDenied, "caller belongs to another tenant")
+	ErrTenantPolicyRequired      error = status.Errorf(codes.InvalidArgument, "tenant policy is required")
+	ErrTimezoneFormat            error = status.Errorf(codes.InvalidArgument, "timezone must be an IANA time zone, e.g. America/Sao_Paulo")
+	ErrUnauthenticated           error = status.Errorf(codes.Unauthenticated, "invalid credentials")
+	ErrUnderMinimumAge           error = status.Errorf(codes.InvalidArgument, "user is under the minimum age")
+	ErrUpdateMaskInvalid         error = status.Errorf(codes.InvalidArgument, "update mask must only list updatable user fields")
+	ErrUpdateMaskRequired        error = status.Errorf(codes.InvalidArgument, "update mask is required")
+	ErrUserAlreadyExists         error = status.Errorf(codes.AlreadyExists, "user already exists")
+	ErrUserAnonymized            error = status.Errorf(codes.FailedPrecondition, "user is anonymized")
+	ErrUserDeactivated           error = status.Errorf(codes.PermissionDenied, "user is deactivated")
+	ErrUserLocked                error = status.Errorf(codes.ResourceExhausted, "user is locked out after too many failed logins, please retry later")
+	ErrUserNotFound              error = status.Errorf(codes.NotFound, "user not found")
+	ErrUserSuspended             error = status.Errorf(codes.PermissionDenied, "user is suspended")
 )
 
 // convertServiceError converts a domain layer error to a transport error.
@@ -35,11 +142,198 @@ func convertServiceError(svcErr error) error {
 	switch {
 	case errors.Is(svcErr, service.ErrCountryCodeInvalid):
 		return ErrCountryCodeInvalid
+	case errors.Is(svcErr, service.ErrCreatedRangeInvalid):
+		return ErrCreatedRangeInvalid
+	case errors.Is(svcErr, service.ErrFilterRequired):
+		return ErrFilterRequired
+	case errors.Is(svcErr, service.ErrSearchQueryInvalid):
+		return ErrSearchQueryInvalid
 	case errors.Is(svcErr, service.ErrUserNotFound):
 		return ErrUserNotFound
+	case errors.Is(svcErr, service.ErrUserAnonymized):
+		return ErrUserAnonymized
+	case errors.Is(svcErr, service.ErrSessionNotFound):
+		return ErrSessionNotFound
+	case errors.Is(svcErr, service.ErrAPIKeyNotFound):
+		return ErrAPIKeyNotFound
+	case errors.Is(svcErr, service.ErrAPIKeyScopesInvalid):
+		return ErrAPIKeyScopesInvalid
 	case errors.Is(svcErr, service.ErrUserAlreadyExists):
-		return ErrUserAlreadyExists
+		return duplicateError(svcErr, ErrUserAlreadyExists)
+	case errors.Is(svcErr, service.ErrNicknameTaken):
+		return duplicateError(svcErr, ErrNicknameTaken)
+	case errors.Is(svcErr, service.ErrEmailAmbiguous):
+		return ErrEmailAmbiguous
+	case errors.Is(svcErr, service.ErrFieldLocked):
+		return fieldLockedError(svcErr)
+	case errors.Is(svcErr, service.ErrFieldNotLockable):
+		return ErrFieldNotLockable
+	case errors.Is(svcErr, service.ErrMergeSameUser):
+		return ErrMergeSameUser
+	case errors.Is(svcErr, service.ErrMetadataInvalid):
+		return ErrMetadataInvalid
+	case errors.Is(svcErr, service.ErrRegionChange):
+		return ErrRegionChange
+	case errors.Is(svcErr, service.ErrAuditDisabled):
+		return ErrAuditDisabled
+	case errors.Is(svcErr, service.ErrChangeLogDisabled):
+		return ErrChangeLogDisabled
+	case errors.Is(svcErr, service.ErrAvatarInvalid):
+		return ErrAvatarInvalid
+	case errors.Is(svcErr, service.ErrAvatarNotFound):
+		return ErrAvatarNotFound
+	case errors.Is(svcErr, service.ErrAvatarsDisabled):
+		return ErrAvatarsDisabled
+	case errors.Is(svcErr, service.ErrAlreadyBootstrapped):
+		return ErrAlreadyBootstrapped
+	case errors.Is(svcErr, service.ErrBirthdateInvalid):
+		return ErrBirthdateInvalid
+	case errors.Is(svcErr, service.ErrBootstrapDisabled):
+		return ErrBootstrapDisabled
+	case errors.Is(svcErr, service.ErrBootstrapTokenInvalid):
+		return ErrBootstrapToken
+	case errors.Is(svcErr, service.ErrIdentityEmailMismatch):
+		return ErrIdentityEmailMismatch
+	case errors.Is(svcErr, service.ErrIdentityLinked):
+		return ErrIdentityLinked
+	case errors.Is(svcErr, service.ErrIdentityNotFound):
+		return ErrIdentityNotFound
+	case errors.Is(svcErr, service.ErrIdentityProvider):
+		return ErrIdentityProvider
+	case errors.Is(svcErr, service.ErrInvalidIDToken):
+		return ErrIDTokenInvalid
+	case errors.Is(svcErr, service.ErrLocaleInvalid):
+		return ErrLocaleFormat
+	case errors.Is(svcErr, service.ErrPasswordBreached):
+		return ErrPasswordBreached
+	case errors.Is(svcErr, service.ErrPhoneInvalid):
+		return ErrPhoneFormat
+	case errors.Is(svcErr, service.ErrPhoneCodeInvalid):
+		return ErrPhoneCodeInvalid
+	case errors.Is(svcErr, service.ErrPhoneNotSet):
+		return ErrPhoneNotSet
+	case errors.Is(svcErr, service.ErrPhoneVerificationDisabled):
+		return ErrPhoneVerificationDisabled
+	case errors.Is(svcErr, service.ErrTimezoneInvalid):
+		return ErrTimezoneFormat
+	case errors.Is(svcErr, service.ErrUnderMinimumAge):
+		return ErrUnderMinimumAge
+	case errors.Is(svcErr, service.ErrResetTokenInvalid):
+		return ErrResetTokenInvalid
+	case errors.Is(svcErr, service.ErrInvalidCredentials):
+		return ErrUnauthenticated
+	case errors.Is(svcErr, service.ErrTOTPRequired):
+		return totpRequiredError()
+	case errors.Is(svcErr, service.ErrTOTPInvalid):
+		return ErrTOTPCodeInvalid
+	case errors.Is(svcErr, service.ErrTOTPDisabled):
+		return ErrTOTPDisabled
+	case errors.Is(svcErr, service.ErrTOTPEnabled):
+		return ErrTOTPEnabled
+	case errors.Is(svcErr, service.ErrTOTPNotEnrolled):
+		return ErrTOTPNotEnrolled
+	case errors.Is(svcErr, service.ErrUserLocked):
+		return userLockedError(svcErr)
+	case errors.Is(svcErr, service.ErrUserSuspended):
+		return ErrUserSuspended
+	case errors.Is(svcErr, service.ErrUserDeactivated):
+		return ErrUserDeactivated
+	case errors.Is(svcErr, service.ErrServiceBusy):
+		return ErrResourceExhausted
 	default:
 		return ErrInternal
 	}
 }
+
+// fieldLockedError returns ErrFieldLocked with a PreconditionFailure detail listing
+// the locked fields and the reasons they were locked for.
+func fieldLockedError(svcErr error) error {
+	var lockedErr *service.FieldLockedError
+	if !errors.As(svcErr, &lockedErr) {
+		return ErrFieldLocked
+	}
+
+	failure := errdetails.PreconditionFailure{
+		Violations: make([]*errdetails.PreconditionFailure_Violation, 0, len(lockedErr.Locks)),
+	}
+	for _, lock := range lockedErr.Locks {
+		failure.Violations = append(failure.Violations, &errdetails.PreconditionFailure_Violation{
+			Type:        fieldLockedViolation,
+			Subject:     lock.Field,
+			Description: lock.Reason,
+		})
+	}
+
+	st, err := status.Convert(ErrFieldLocked).WithDetails(&failure)
+	if err != nil {
+		return ErrFieldLocked
+	}
+	return st.Err()
+}
+
+// userLockedError returns ErrUserLocked with a RetryInfo detail telling when the lockout ends.
+func userLockedError(svcErr error) error {
+	var lockedErr *service.UserLockedError
+	if !errors.As(svcErr, &lockedErr) {
+		return ErrUserLocked
+	}
+
+	st, err := status.Convert(ErrUserLocked).WithDetails(&errdetails.RetryInfo{
+		RetryDelay: durationpb.New(time.Until(lockedErr.LockedUntil)),
+	})
+	if err != nil {
+		return ErrUserLocked
+	}
+	return st.Err()
+}
+
+// totpRequiredError returns ErrTOTPRequired with an ErrorInfo detail, so the clients can tell
+// it from the other Unauthenticated errors and prompt for a TOTP code.
+func totpRequiredError() error {
+	st, err := status.Convert(ErrTOTPRequired).WithDetails(&errdetails.ErrorInfo{
+		Reason: totpRequiredReason,
+		Domain: errorDomain,
+	})
+	if err != nil {
+		return ErrTOTPRequired
+	}
+	return st.Err()
+}
+
+// badRequestError returns the transport error with a BadRequest detail naming the invalid
+// field by its path in the request, e.g. "admin.email" in a BootstrapRequest.
+func badRequestError(field string, transportErr error) error {
+	st := status.Convert(transportErr)
+
+	detailed, err := st.WithDetails(&errdetails.BadRequest{
+		FieldViolations: []*errdetails.BadRequest_FieldViolation{
+			{Field: field, Description: st.Message()},
+		},
+	})
+	if err != nil {
+		return transportErr
+	}
+	return detailed.Err()
+}
+
+// duplicateError returns the transport error with an ErrorInfo detail naming the duplicate
+// field and the scope it must be unique within, e.g. {"field": "email", "scope": "country"}.
+func duplicateError(svcErr, transportErr error) error {
+	var dupErr *service.DuplicateError
+	if !errors.As(svcErr, &dupErr) {
+		return transportErr
+	}
+
+	st, err := status.Convert(transportErr).WithDetails(&errdetails.ErrorInfo{
+		Reason: duplicateReason,
+		Domain: errorDomain,
+		Metadata: map[string]string{
+			"field": dupErr.Field,
+			"scope": string(dupErr.Scope),
+		},
+	})
+	if err != nil {
+		return transportErr
+	}
+	return st.Err()
+}
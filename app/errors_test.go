@@ -0,0 +1,82 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// assertStatusHelper asserts that the error has the code and message of the expected
+// transport error, whatever its details.
+func assertStatusHelper(t *testing.T, expected, observed error) {
+	t.Helper()
+
+	if expected == nil {
+		assert.NoError(t, observed)
+		return
+	}
+
+	assert.Equal(t, status.Code(expected), status.Code(observed))
+	assert.Equal(t, status.Convert(expected).Message(), status.Convert(observed).Message())
+}
+
+// fieldViolationsHelper returns the fields of the BadRequest detail of the error.
+func fieldViolationsHelper(t *testing.T, err error) []string {
+	t.Helper()
+
+	var fields []string
+	for _, detail := range status.Convert(err).Details() {
+		if badRequest, ok := detail.(*errdetails.BadRequest); ok {
+			for _, violation := range badRequest.FieldViolations {
+				fields = append(fields, violation.Field)
+			}
+		}
+	}
+	return fields
+}
+
+func TestValidationErrorCodes(t *testing.T) {
+	t.Parallel()
+
+	// Validation errors are the client's fault and must not be retried as server errors.
+	for _, err := range []error{
+		ErrCountryCodeInvalid,
+		ErrCountryCodeRequired,
+		ErrEmailFormat,
+		ErrEmailRequired,
+		ErrIDFormat,
+		ErrIDRequired,
+		ErrNameFormat,
+		ErrNameLength,
+		ErrNameRequired,
+		ErrPasswordFormat,
+		ErrPasswordLength,
+		ErrPasswordRequired,
+	} {
+		assert.Equal(t, codes.InvalidArgument, status.Code(err), status.Convert(err).Message())
+	}
+}
+
+func TestBadRequestError(t *testing.T) {
+	t.Parallel()
+
+	// Act
+	err := badRequestError("admin.email", ErrEmailFormat)
+
+	// Assert
+	st := status.Convert(err)
+	assert.Equal(t, codes.InvalidArgument, st.Code())
+	assert.Equal(t, "email is invalid", st.Message())
+
+	require.Len(t, st.Details(), 1)
+	badRequest, ok := st.Details()[0].(*errdetails.BadRequest)
+	require.True(t, ok)
+
+	require.Len(t, badRequest.FieldViolations, 1)
+	assert.Equal(t, "admin.email", badRequest.FieldViolations[0].Field)
+	assert.Equal(t, "email is invalid", badRequest.FieldViolations[0].Description)
+}
@@ -0,0 +1,209 @@
+package app
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/alesr/usrsvc/internal/users/service"
+	"github.com/alesr/usrsvc/pkg/uservalidation"
+	apiv1 "github.com/alesr/usrsvc/proto/users/v1"
+	"go.uber.org/zap"
+)
+
+const (
+	// exportChunkSize is the size from which the buffered users are sent as a chunk.
+	exportChunkSize int = 64 << 10
+
+	// Enumerate the export formats.
+
+	exportFormatCSV   string = "csv"
+	exportFormatJSONL string = "jsonl"
+)
+
+// exportColumns is the header of the CSV exports, in the order of exportedUser.csvRecord.
+var exportColumns = []string{
+	"id", "first_name", "last_name", "nickname", "email", "country", "role", "status",
+	"anonymized", "phone", "phone_verified", "locale", "timezone", "birthdate", "avatar_hash",
+	"metadata", "created_at", "updated_at",
+}
+
+// ExportUsers streams the users selected by the filters of the request, as CSV or JSONL
+// chunks, e.g. for analytics ingestion. The password hashes are never exported. The export
+// isn't bound by the request timeout but by the database timeout of each batch of users,
+// and an error can interrupt it after chunks were sent.
+func (s *GRPCServer) ExportUsers(req *apiv1.ExportUsersRequest, stream apiv1.UserService_ExportUsersServer) error {
+	if req == nil {
+		return ErrRequestRequired
+	}
+
+	if req.Country != "" && !uservalidation.IsCountryCode(req.Country) {
+		return ErrCountryCodeInvalid
+	}
+
+	filters, err := newFilterParamsFromListRequest(&apiv1.ListUsersRequest{
+		Country:        req.Country,
+		NicknamePrefix: req.NicknamePrefix,
+		Email:          req.Email,
+		FirstName:      req.FirstName,
+		LastName:       req.LastName,
+		CreatedAfter:   req.CreatedAfter,
+		CreatedBefore:  req.CreatedBefore,
+	})
+	if err != nil {
+		return err
+	}
+
+	w, err := newExportWriter(stream, req.Format)
+	if err != nil {
+		return err
+	}
+
+	if err := s.service.ExportUsers(stream.Context(), filters, w.write); err != nil {
+		// The stream errors are returned as is, e.g. when the client went away.
+		if w.sendErr != nil {
+			return w.sendErr
+		}
+		s.logger.Error("failed to export users", zap.Error(err))
+		return convertServiceError(err)
+	}
+	return w.flush()
+}
+
+// exportWriter encodes the exported users and sends them in chunks of about exportChunkSize.
+type exportWriter struct {
+	stream apiv1.UserService_ExportUsersServer
+	buf    bytes.Buffer
+
+	// Only one of them is set, depending on the format.
+	csv  *csv.Writer
+	json *json.Encoder
+
+	sendErr error
+}
+
+func newExportWriter(stream apiv1.UserService_ExportUsersServer, format string) (*exportWriter, error) {
+	w := exportWriter{stream: stream}
+
+	switch format {
+	case "", exportFormatCSV:
+		w.csv = csv.NewWriter(&w.buf)
+		if err := w.csv.Write(exportColumns); err != nil {
+			return nil, err
+		}
+	case exportFormatJSONL:
+		w.json = json.NewEncoder(&w.buf)
+	default:
+		return nil, ErrExportFormatInvalid
+	}
+	return &w, nil
+}
+
+// write encodes the user, sending the buffered users once they fill a chunk.
+func (w *exportWriter) write(user *service.User) error {
+	exported := newExportedUser(user)
+
+	if w.csv != nil {
+		record, err := exported.csvRecord()
+		if err != nil {
+			return err
+		}
+
+		if err := w.csv.Write(record); err != nil {
+			return err
+		}
+
+		w.csv.Flush()
+		if err := w.csv.Error(); err != nil {
+			return err
+		}
+	} else if err := w.json.Encode(exported); err != nil {
+		return err
+	}
+
+	if w.buf.Len() < exportChunkSize {
+		return nil
+	}
+	return w.flush()
+}
+
+// flush sends the buffered users, if any.
+func (w *exportWriter) flush() error {
+	if w.buf.Len() == 0 {
+		return nil
+	}
+
+	if err := w.stream.Send(&apiv1.ExportUsersResponse{Chunk: bytes.Clone(w.buf.Bytes())}); err != nil {
+		w.sendErr = err
+		return err
+	}
+
+	w.buf.Reset()
+	return nil
+}
+
+// exportedUser is the representation of the users in the exports.
+type exportedUser struct {
+	ID            string            `json:"id"`
+	FirstName     string            `json:"first_name"`
+	LastName      string            `json:"last_name"`
+	Nickname      string            `json:"nickname"`
+	Email         string            `json:"email"`
+	Country       string            `json:"country"`
+	Role          string            `json:"role"`
+	Status        string            `json:"status"`
+	Anonymized    bool              `json:"anonymized"`
+	Phone         string            `json:"phone"`
+	PhoneVerified bool              `json:"phone_verified"`
+	Locale        string            `json:"locale"`
+	Timezone      string            `json:"timezone"`
+	Birthdate     string            `json:"birthdate"`
+	AvatarHash    string            `json:"avatar_hash"`
+	Metadata      map[string]string `json:"metadata,omitempty"`
+	CreatedAt     time.Time         `json:"created_at"`
+	UpdatedAt     time.Time         `json:"updated_at"`
+}
+
+func newExportedUser(user *service.User) exportedUser {
+	return exportedUser{
+		ID:            user.ID,
+		FirstName:     user.FirstName,
+		LastName:      user.LastName,
+		Nickname:      user.Nickname,
+		Email:         user.Email,
+		Country:       user.Country,
+		Role:          user.Role,
+		Status:        user.Status,
+		Anonymized:    user.Anonymized,
+		Phone:         user.Phone,
+		PhoneVerified: user.PhoneVerified,
+		Locale:        user.Locale,
+		Timezone:      user.Timezone,
+		Birthdate:     formatBirthdate(user.Birthdate),
+		AvatarHash:    user.AvatarHash,
+		Metadata:      user.Metadata,
+		CreatedAt:     user.CreatedAt.UTC(),
+		UpdatedAt:     user.UpdatedAt.UTC(),
+	}
+}
+
+// csvRecord returns the CSV record of the user, with the metadata as a JSON object.
+func (u exportedUser) csvRecord() ([]string, error) {
+	var metadata string
+	if len(u.Metadata) > 0 {
+		b, err := json.Marshal(u.Metadata)
+		if err != nil {
+			return nil, err
+		}
+		metadata = string(b)
+	}
+
+	return []string{
+		u.ID, u.FirstName, u.LastName, u.Nickname, u.Email, u.Country, u.Role, u.Status,
+		strconv.FormatBool(u.Anonymized), u.Phone, strconv.FormatBool(u.PhoneVerified),
+		u.Locale, u.Timezone, u.Birthdate, u.AvatarHash, metadata,
+		u.CreatedAt.Format(time.RFC3339Nano), u.UpdatedAt.Format(time.RFC3339Nano),
+	}, nil
+}
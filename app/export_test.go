@@ -0,0 +1,203 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alesr/usrsvc/internal/users/service"
+	apiv1 "github.com/alesr/usrsvc/proto/users/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+)
+
+// exportStreamMock is an ExportUsers stream collecting the chunks sent.
+type exportStreamMock struct {
+	grpc.ServerStream
+
+	chunks  [][]byte
+	sendErr error
+}
+
+func (s *exportStreamMock) Context() context.Context {
+	return context.TODO()
+}
+
+func (s *exportStreamMock) Send(resp *apiv1.ExportUsersResponse) error {
+	if s.sendErr != nil {
+		return s.sendErr
+	}
+	s.chunks = append(s.chunks, resp.Chunk)
+	return nil
+}
+
+func TestExportUsers(t *testing.T) {
+	t.Parallel()
+
+	createdAt := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	user := &service.User{
+		ID:        "00000000-0000-0000-0000-000000000001",
+		FirstName: "John",
+		LastName:  "Doe",
+		Nickname:  "jdoe",
+		Email:     "joedoe@foo.bar",
+		Country:   "BR",
+		Role:      service.RoleUser,
+		Status:    service.StatusActive,
+		Metadata:  map[string]string{"theme": "dark"},
+		Birthdate: time.Date(1990, 5, 17, 0, 0, 0, 0, time.UTC),
+		CreatedAt: createdAt,
+		UpdatedAt: createdAt,
+	}
+
+	exportHelper := func(users ...*service.User) *serviceMock {
+		return &serviceMock{
+			ExportUsersFunc: func(ctx context.Context, filter service.FilterParams, fn func(user *service.User) error) error {
+				for _, user := range users {
+					if err := fn(user); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		}
+	}
+
+	t.Run("csv", func(t *testing.T) {
+		server := NewGRPCServer(zap.NewNop(), exportHelper(user))
+		stream := &exportStreamMock{}
+
+		err := server.ExportUsers(&apiv1.ExportUsersRequest{Country: "BR"}, stream)
+		require.NoError(t, err)
+
+		require.Len(t, stream.chunks, 1)
+
+		records, err := csv.NewReader(bytes.NewReader(stream.chunks[0])).ReadAll()
+		require.NoError(t, err)
+
+		assert.Equal(t, [][]string{
+			exportColumns,
+			{
+				user.ID, "John", "Doe", "jdoe", "joedoe@foo.bar", "BR", service.RoleUser, service.StatusActive,
+				"false", "", "false", "", "", "1990-05-17", "", `{"theme":"dark"}`,
+				"2020-01-02T03:04:05Z", "2020-01-02T03:04:05Z",
+			},
+		}, records)
+	})
+
+	t.Run("jsonl", func(t *testing.T) {
+		server := NewGRPCServer(zap.NewNop(), exportHelper(user, user))
+		stream := &exportStreamMock{}
+
+		err := server.ExportUsers(&apiv1.ExportUsersRequest{Format: "jsonl"}, stream)
+		require.NoError(t, err)
+
+		require.Len(t, stream.chunks, 1)
+
+		lines := strings.Split(strings.TrimSuffix(string(stream.chunks[0]), "\n"), "\n")
+		require.Len(t, lines, 2)
+
+		var observed map[string]any
+		require.NoError(t, json.Unmarshal([]byte(lines[0]), &observed))
+
+		assert.Equal(t, user.ID, observed["id"])
+		assert.Equal(t, "2020-01-02T03:04:05Z", observed["created_at"])
+		assert.NotContains(t, observed, "password")
+	})
+
+	t.Run("large exports are sent in chunks of whole lines", func(t *testing.T) {
+		users := make([]*service.User, 0, 2000)
+		for i := 0; i < cap(users); i++ {
+			u := *user
+			u.ID = fmt.Sprintf("00000000-0000-0000-0000-%012d", i)
+			users = append(users, &u)
+		}
+
+		server := NewGRPCServer(zap.NewNop(), exportHelper(users...))
+		stream := &exportStreamMock{}
+
+		err := server.ExportUsers(&apiv1.ExportUsersRequest{Format: "jsonl"}, stream)
+		require.NoError(t, err)
+
+		require.Greater(t, len(stream.chunks), 1)
+
+		var lines int
+		for _, chunk := range stream.chunks {
+			assert.True(t, bytes.HasSuffix(chunk, []byte("\n")))
+			lines += bytes.Count(chunk, []byte("\n"))
+		}
+		assert.Equal(t, len(users), lines)
+	})
+
+	testCases := []struct {
+		name        string
+		given       *apiv1.ExportUsersRequest
+		expectedErr error
+	}{
+		{
+			name:        "nil request",
+			given:       nil,
+			expectedErr: ErrRequestRequired,
+		},
+		{
+			name:        "invalid format",
+			given:       &apiv1.ExportUsersRequest{Format: "xml"},
+			expectedErr: ErrExportFormatInvalid,
+		},
+		{
+			name:        "invalid country",
+			given:       &apiv1.ExportUsersRequest{Country: "BRA"},
+			expectedErr: ErrCountryCodeInvalid,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			server := NewGRPCServer(zap.NewNop(), &serviceMock{})
+			stream := &exportStreamMock{}
+
+			err := server.ExportUsers(tc.given, stream)
+
+			assert.Equal(t, tc.expectedErr, err)
+			assert.Empty(t, stream.chunks)
+		})
+	}
+
+	t.Run("when the service fails", func(t *testing.T) {
+		svc := &serviceMock{
+			ExportUsersFunc: func(ctx context.Context, filter service.FilterParams, fn func(user *service.User) error) error {
+				return errors.New("some error")
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		err := server.ExportUsers(&apiv1.ExportUsersRequest{}, &exportStreamMock{})
+
+		assert.Equal(t, ErrInternal, err)
+	})
+
+	t.Run("when the stream fails", func(t *testing.T) {
+		users := make([]*service.User, 1000)
+		for i := range users {
+			users[i] = user
+		}
+
+		server := NewGRPCServer(zap.NewNop(), exportHelper(users...))
+
+		sendErr := errors.New("stream closed")
+		err := server.ExportUsers(&apiv1.ExportUsersRequest{}, &exportStreamMock{sendErr: sendErr})
+
+		assert.Equal(t, sendErr, err)
+	})
+}
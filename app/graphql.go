@@ -0,0 +1,448 @@
+package app
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+
+	apiv2 "github.com/alesr/usrsvc/proto/users/v2"
+	graphql "github.com/graph-gophers/graphql-go"
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// maxGraphQLRequestSize is the largest GraphQL request body accepted.
+const maxGraphQLRequestSize int64 = 1 << 20
+
+//go:embed schema.graphql
+var graphQLSchema string
+
+// graphQLHeaders are the HTTP headers passed to the interceptors as gRPC metadata.
+var graphQLHeaders = []string{authorizationHeader, apiKeyHeader, forwardedForHeader, tenantHeader}
+
+// GraphQLHandler serves a GraphQL facade of the users API over HTTP, for the clients
+// standardized on GraphQL. The operations call the handlers of the v2 server through
+// the unary interceptors of the gRPC server, so the authorization, the rate limits and
+// maintenance mode apply as for the RPCs they map to.
+type GraphQLHandler struct {
+	logger *zap.Logger
+	schema *graphql.Schema
+}
+
+// NewGraphQLHandler creates the GraphQL handler on top of the v2 server, with the unary
+// interceptors of the gRPC server in the same order.
+func NewGraphQLHandler(logger *zap.Logger, server *GRPCServerV2, interceptors []grpc.UnaryServerInterceptor) *GraphQLHandler {
+	resolver := &graphQLResolver{server: server, interceptor: chainUnaryInterceptors(interceptors)}
+
+	return &GraphQLHandler{
+		logger: logger,
+		schema: graphql.MustParseSchema(graphQLSchema, resolver, graphql.MaxDepth(8)),
+	}
+}
+
+// ServeHTTP executes the GraphQL operation of a POST request with a JSON body.
+func (h *GraphQLHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Only JSON bodies, which browsers cannot send cross-origin without a CORS preflight.
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		http.Error(w, http.StatusText(http.StatusUnsupportedMediaType), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	var params struct {
+		Query         string         `json:"query"`
+		OperationName string         `json:"operationName"`
+		Variables     map[string]any `json:"variables"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxGraphQLRequestSize)).Decode(&params); err != nil {
+		http.Error(w, "invalid GraphQL request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp := h.schema.Exec(incomingContext(r, graphQLHeaders...), params.Query, params.OperationName, params.Variables)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		h.logger.Error("failed to write GraphQL response", zap.Error(err))
+	}
+}
+
+// graphQLError is the GraphQL error of a gRPC error, with its code and field
+// violations in the extensions.
+type graphQLError struct {
+	st *status.Status
+}
+
+func (e *graphQLError) Error() string {
+	return e.st.Message()
+}
+
+// Extensions returns the code of the error, e.g. "INVALID_ARGUMENT", and the field
+// violations named after the GraphQL arguments, e.g. "input.firstName".
+func (e *graphQLError) Extensions() map[string]any {
+	extensions := map[string]any{
+		"code": strings.ToUpper(camelToSnake(e.st.Code().String())),
+	}
+
+	var violations []map[string]string
+	for _, detail := range e.st.Details() {
+		if badRequest, ok := detail.(*errdetails.BadRequest); ok {
+			for _, violation := range badRequest.FieldViolations {
+				violations = append(violations, map[string]string{
+					"field":       graphQLField(violation.Field),
+					"description": violation.Description,
+				})
+			}
+		}
+	}
+
+	if len(violations) > 0 {
+		extensions["fieldViolations"] = violations
+	}
+	return extensions
+}
+
+// graphQLField returns the GraphQL argument of a field of a v2 request,
+// e.g. "input.firstName" for "user.first_name".
+func graphQLField(field string) string {
+	if field == "update_mask" {
+		return "input"
+	}
+
+	prefix := ""
+	if strings.HasPrefix(field, "user.") {
+		field, prefix = strings.TrimPrefix(field, "user."), "input."
+	}
+
+	parts := strings.Split(field, "_")
+	for i := 1; i < len(parts); i++ {
+		parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+	}
+	return prefix + strings.Join(parts, "")
+}
+
+// camelToSnake returns the snake case of a camel case name, e.g. "invalid_argument" for
+// "InvalidArgument".
+func camelToSnake(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if r >= 'A' && r <= 'Z' {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r += 'a' - 'A'
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// graphQLResolver is the root resolver of the GraphQL schema.
+type graphQLResolver struct {
+	server      *GRPCServerV2
+	interceptor grpc.UnaryServerInterceptor
+}
+
+// invokeGraphQL calls a v2 handler through the interceptors as the named RPC.
+func invokeGraphQL[Req, Resp any](ctx context.Context, r *graphQLResolver, method string, req Req, handler func(context.Context, Req) (Resp, error)) (Resp, error) {
+	info := &grpc.UnaryServerInfo{
+		Server:     r.server,
+		FullMethod: "/" + apiv2.UserService_ServiceDesc.ServiceName + "/" + method,
+	}
+
+	var zero Resp
+	resp, err := r.interceptor(ctx, req, info, func(ctx context.Context, req any) (any, error) {
+		return handler(ctx, req.(Req))
+	})
+	if err != nil {
+		return zero, &graphQLError{st: status.Convert(err)}
+	}
+	return resp.(Resp), nil
+}
+
+type metadataEntryInput struct {
+	Key   string
+	Value string
+}
+
+type userFilterInput struct {
+	Country        *string
+	NicknamePrefix *string
+	Email          *string
+	FirstName      *string
+	LastName       *string
+	CreatedAfter   *graphql.Time
+	CreatedBefore  *graphql.Time
+}
+
+type createUserInput struct {
+	FirstName string
+	LastName  string
+	Nickname  string
+	Email     string
+	Password  string
+	Country   string
+	Metadata  *[]metadataEntryInput
+	Phone     *string
+	Locale    *string
+	Timezone  *string
+	Birthdate *string
+}
+
+type updateUserInput struct {
+	ID        graphql.ID
+	FirstName *string
+	LastName  *string
+	Nickname  *string
+	Email     *string
+	Password  *string
+	Country   *string
+	Metadata  *[]metadataEntryInput
+	Phone     *string
+	Locale    *string
+	Timezone  *string
+	Birthdate *string
+}
+
+// User resolves the user query with GetUser.
+func (r *graphQLResolver) User(ctx context.Context, args struct{ ID graphql.ID }) (*userResolver, error) {
+	resp, err := invokeGraphQL(ctx, r, "GetUser", &apiv2.GetUserRequest{Id: string(args.ID)}, r.server.GetUser)
+	if err != nil {
+		return nil, err
+	}
+	return &userResolver{user: resp.User}, nil
+}
+
+// Users resolves the users query with ListUsers.
+func (r *graphQLResolver) Users(ctx context.Context, args struct {
+	Filter            *userFilterInput
+	First             *int32
+	After             *string
+	IncludeTotalCount bool
+}) (*userConnectionResolver, error) {
+	req := &apiv2.ListUsersRequest{IncludeTotalSize: args.IncludeTotalCount}
+
+	if args.First != nil {
+		req.PageSize = *args.First
+	}
+	if args.After != nil {
+		req.PageToken = *args.After
+	}
+
+	if f := args.Filter; f != nil {
+		req.Country = f.Country
+		req.NicknamePrefix = f.NicknamePrefix
+		req.Email = f.Email
+		req.FirstName = f.FirstName
+		req.LastName = f.LastName
+
+		if f.CreatedAfter != nil {
+			req.CreatedAfter = timestamppb.New(f.CreatedAfter.Time)
+		}
+		if f.CreatedBefore != nil {
+			req.CreatedBefore = timestamppb.New(f.CreatedBefore.Time)
+		}
+	}
+
+	resp, err := invokeGraphQL(ctx, r, "ListUsers", req, r.server.ListUsers)
+	if err != nil {
+		return nil, err
+	}
+	return &userConnectionResolver{resp: resp}, nil
+}
+
+// CreateUser resolves the createUser mutation with CreateUser.
+func (r *graphQLResolver) CreateUser(ctx context.Context, args struct{ Input createUserInput }) (*userResolver, error) {
+	in := args.Input
+
+	req := &apiv2.CreateUserRequest{
+		User: &apiv2.User{
+			FirstName: in.FirstName,
+			LastName:  in.LastName,
+			Nickname:  in.Nickname,
+			Email:     in.Email,
+			Country:   in.Country,
+			Phone:     in.Phone,
+			Locale:    in.Locale,
+			Timezone:  in.Timezone,
+			Birthdate: in.Birthdate,
+		},
+		Password: in.Password,
+	}
+
+	if in.Metadata != nil {
+		req.User.Metadata = metadataFromGraphQL(*in.Metadata)
+	}
+
+	resp, err := invokeGraphQL(ctx, r, "CreateUser", req, r.server.CreateUser)
+	if err != nil {
+		return nil, err
+	}
+	return &userResolver{user: resp.User}, nil
+}
+
+// UpdateUser resolves the updateUser mutation with UpdateUser, the fields given in the
+// input making the update mask.
+func (r *graphQLResolver) UpdateUser(ctx context.Context, args struct{ Input updateUserInput }) (*userResolver, error) {
+	in := args.Input
+
+	req := &apiv2.UpdateUserRequest{
+		User:       &apiv2.User{Id: string(in.ID)},
+		UpdateMask: &fieldmaskpb.FieldMask{},
+		Password:   in.Password,
+	}
+
+	for _, field := range []struct {
+		path   string
+		value  *string
+		target *string
+	}{
+		{path: "first_name", value: in.FirstName, target: &req.User.FirstName},
+		{path: "last_name", value: in.LastName, target: &req.User.LastName},
+		{path: "nickname", value: in.Nickname, target: &req.User.Nickname},
+		{path: "email", value: in.Email, target: &req.User.Email},
+		{path: "country", value: in.Country, target: &req.User.Country},
+	} {
+		if field.value != nil {
+			*field.target = *field.value
+			req.UpdateMask.Paths = append(req.UpdateMask.Paths, field.path)
+		}
+	}
+
+	for _, field := range []struct {
+		path   string
+		value  *string
+		target **string
+	}{
+		{path: "phone", value: in.Phone, target: &req.User.Phone},
+		{path: "locale", value: in.Locale, target: &req.User.Locale},
+		{path: "timezone", value: in.Timezone, target: &req.User.Timezone},
+		{path: "birthdate", value: in.Birthdate, target: &req.User.Birthdate},
+	} {
+		if field.value != nil {
+			*field.target = field.value
+			req.UpdateMask.Paths = append(req.UpdateMask.Paths, field.path)
+		}
+	}
+
+	if in.Metadata != nil {
+		req.User.Metadata = metadataFromGraphQL(*in.Metadata)
+		req.UpdateMask.Paths = append(req.UpdateMask.Paths, "metadata")
+	}
+
+	resp, err := invokeGraphQL(ctx, r, "UpdateUser", req, r.server.UpdateUser)
+	if err != nil {
+		return nil, err
+	}
+	return &userResolver{user: resp.User}, nil
+}
+
+func metadataFromGraphQL(entries []metadataEntryInput) map[string]string {
+	md := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		md[entry.Key] = entry.Value
+	}
+	return md
+}
+
+type userConnectionResolver struct {
+	resp *apiv2.ListUsersResponse
+}
+
+func (r *userConnectionResolver) Nodes() []*userResolver {
+	nodes := make([]*userResolver, 0, len(r.resp.Users))
+	for _, user := range r.resp.Users {
+		nodes = append(nodes, &userResolver{user: user})
+	}
+	return nodes
+}
+
+func (r *userConnectionResolver) PageInfo() *pageInfoResolver {
+	return &pageInfoResolver{nextPageToken: r.resp.NextPageToken}
+}
+
+func (r *userConnectionResolver) TotalCount() *int32 {
+	if r.resp.TotalSize == nil {
+		return nil
+	}
+
+	count := int32(*r.resp.TotalSize)
+	return &count
+}
+
+type pageInfoResolver struct {
+	nextPageToken string
+}
+
+func (r *pageInfoResolver) EndCursor() *string {
+	if r.nextPageToken == "" {
+		return nil
+	}
+	return &r.nextPageToken
+}
+
+func (r *pageInfoResolver) HasNextPage() bool {
+	return r.nextPageToken != ""
+}
+
+type metadataEntryResolver struct {
+	key   string
+	value string
+}
+
+func (r *metadataEntryResolver) Key() string   { return r.key }
+func (r *metadataEntryResolver) Value() string { return r.value }
+
+type userResolver struct {
+	user *apiv2.User
+}
+
+func (r *userResolver) ID() graphql.ID          { return graphql.ID(r.user.Id) }
+func (r *userResolver) FirstName() string       { return r.user.FirstName }
+func (r *userResolver) LastName() string        { return r.user.LastName }
+func (r *userResolver) Nickname() string        { return r.user.Nickname }
+func (r *userResolver) Email() string           { return r.user.Email }
+func (r *userResolver) Country() string         { return r.user.Country }
+func (r *userResolver) Anonymized() bool        { return r.user.Anonymized }
+func (r *userResolver) AvatarHash() *string     { return r.user.AvatarHash }
+func (r *userResolver) Phone() *string          { return r.user.Phone }
+func (r *userResolver) PhoneVerified() bool     { return r.user.PhoneVerified }
+func (r *userResolver) Locale() *string         { return r.user.Locale }
+func (r *userResolver) Timezone() *string       { return r.user.Timezone }
+func (r *userResolver) Birthdate() *string      { return r.user.Birthdate }
+func (r *userResolver) CreatedAt() graphql.Time { return graphql.Time{Time: r.user.CreatedAt.AsTime()} }
+func (r *userResolver) UpdatedAt() graphql.Time { return graphql.Time{Time: r.user.UpdatedAt.AsTime()} }
+
+func (r *userResolver) Role() string {
+	return strings.TrimPrefix(r.user.Role.String(), "ROLE_")
+}
+
+func (r *userResolver) Status() string {
+	return strings.TrimPrefix(r.user.Status.String(), "USER_STATUS_")
+}
+
+// Metadata returns the metadata sorted by key, for stable responses.
+func (r *userResolver) Metadata() []*metadataEntryResolver {
+	entries := make([]*metadataEntryResolver, 0, len(r.user.Metadata))
+	for key, value := range r.user.Metadata {
+		entries = append(entries, &metadataEntryResolver{key: key, value: value})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].key < entries[j].key
+	})
+	return entries
+}
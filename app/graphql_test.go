@@ -0,0 +1,311 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alesr/usrsvc/internal/users/service"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+type graphQLResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message    string `json:"message"`
+		Extensions struct {
+			Code            string `json:"code"`
+			FieldViolations []struct {
+				Field string `json:"field"`
+			} `json:"fieldViolations"`
+		} `json:"extensions"`
+	} `json:"errors"`
+}
+
+func newGraphQLHandlerHelper(svc userService, interceptors ...grpc.UnaryServerInterceptor) *GraphQLHandler {
+	return NewGraphQLHandler(zap.NewNop(), NewGRPCServerV2(NewGRPCServer(zap.NewNop(), svc)), interceptors)
+}
+
+func graphQLRequestHelper(t *testing.T, h http.Handler, query string, variables map[string]any, headers ...string) graphQLResponse {
+	t.Helper()
+
+	body, err := json.Marshal(map[string]any{"query": query, "variables": variables})
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var resp graphQLResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	return resp
+}
+
+func TestGraphQLUser(t *testing.T) {
+	t.Parallel()
+
+	id := uuid.New().String()
+	createdAt := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	svc := &serviceMock{
+		FetchFunc: func(ctx context.Context, userID string) (*service.User, error) {
+			assert.Equal(t, id, userID)
+
+			return &service.User{
+				ID:        id,
+				FirstName: "John",
+				Nickname:  "jdoe",
+				Country:   "BR",
+				Role:      service.RoleAdmin,
+				Status:    service.StatusSuspended,
+				Metadata:  map[string]string{"theme": "dark", "plan": "pro"},
+				Locale:    "pt-BR",
+				CreatedAt: createdAt,
+			}, nil
+		},
+	}
+
+	resp := graphQLRequestHelper(t, newGraphQLHandlerHelper(svc), `query($id: ID!) {
+		user(id: $id) { id firstName role status locale phone createdAt metadata { key value } }
+	}`, map[string]any{"id": id})
+	require.Empty(t, resp.Errors)
+
+	assert.JSONEq(t, `{"user": {
+		"id": "`+id+`",
+		"firstName": "John",
+		"role": "ADMIN",
+		"status": "SUSPENDED",
+		"locale": "pt-BR",
+		"phone": null,
+		"createdAt": "2023-03-01T12:00:00Z",
+		"metadata": [{"key": "plan", "value": "pro"}, {"key": "theme", "value": "dark"}]
+	}}`, string(resp.Data))
+}
+
+func TestGraphQLUsers(t *testing.T) {
+	t.Parallel()
+
+	ids := []string{uuid.New().String(), uuid.New().String()}
+
+	svc := &serviceMock{
+		FetchAllFunc: func(ctx context.Context, filter service.FilterParams, pag service.PaginationParams) ([]*service.User, error) {
+			require.NotNil(t, filter.Country)
+			assert.Equal(t, "BR", *filter.Country)
+			require.NotNil(t, filter.CreatedAfter)
+			assert.Equal(t, 2023, filter.CreatedAfter.Year())
+			assert.Nil(t, filter.Email)
+			assert.Equal(t, 2, pag.Limit)
+			assert.Equal(t, ids[0], pag.Cursor)
+
+			return []*service.User{{ID: ids[1], Country: "BR"}, {ID: ids[0], Country: "BR"}}, nil
+		},
+		CountFunc: func(ctx context.Context, filter service.FilterParams) (int64, error) {
+			return 42, nil
+		},
+	}
+
+	resp := graphQLRequestHelper(t, newGraphQLHandlerHelper(svc), `query($after: String) {
+		users(filter: {country: "BR", createdAfter: "2023-01-01T00:00:00Z"}, first: 2, after: $after, includeTotalCount: true) {
+			nodes { id }
+			pageInfo { endCursor hasNextPage }
+			totalCount
+		}
+	}`, map[string]any{"after": ids[0]})
+	require.Empty(t, resp.Errors)
+
+	assert.JSONEq(t, `{"users": {
+		"nodes": [{"id": "`+ids[1]+`"}, {"id": "`+ids[0]+`"}],
+		"pageInfo": {"endCursor": "`+ids[0]+`", "hasNextPage": true},
+		"totalCount": 42
+	}}`, string(resp.Data))
+}
+
+func TestGraphQLCreateUser(t *testing.T) {
+	t.Parallel()
+
+	const mutation = `mutation($input: CreateUserInput!) { createUser(input: $input) { id nickname metadata { key value } } }`
+
+	input := func(email string) map[string]any {
+		return map[string]any{"input": map[string]any{
+			"firstName": "John",
+			"lastName":  "Doe",
+			"nickname":  "jdoe",
+			"email":     email,
+			"password":  "password1!",
+			"country":   "BR",
+			"metadata":  []map[string]string{{"key": "theme", "value": "dark"}},
+		}}
+	}
+
+	t.Run("happy path", func(t *testing.T) {
+		id := uuid.New().String()
+
+		svc := &serviceMock{
+			CreateFunc: func(ctx context.Context, user *service.User) (*service.User, error) {
+				assert.Equal(t, "password1!", user.Password)
+				assert.Equal(t, map[string]string{"theme": "dark"}, user.Metadata)
+
+				created := *user
+				created.ID = id
+				return &created, nil
+			},
+		}
+
+		resp := graphQLRequestHelper(t, newGraphQLHandlerHelper(svc), mutation, input("john@foo.bar"))
+		require.Empty(t, resp.Errors)
+
+		assert.JSONEq(t, `{"createUser": {"id": "`+id+`", "nickname": "jdoe", "metadata": [{"key": "theme", "value": "dark"}]}}`, string(resp.Data))
+	})
+
+	t.Run("field violations name the input fields", func(t *testing.T) {
+		resp := graphQLRequestHelper(t, newGraphQLHandlerHelper(&serviceMock{}), mutation, input("john"))
+
+		require.Len(t, resp.Errors, 1)
+		assert.Equal(t, "INVALID_ARGUMENT", resp.Errors[0].Extensions.Code)
+		require.Len(t, resp.Errors[0].Extensions.FieldViolations, 1)
+		assert.Equal(t, "input.email", resp.Errors[0].Extensions.FieldViolations[0].Field)
+	})
+}
+
+func TestGraphQLUpdateUser(t *testing.T) {
+	t.Parallel()
+
+	id := uuid.New().String()
+
+	t.Run("only the fields given change", func(t *testing.T) {
+		svc := &serviceMock{
+			FetchFunc: func(ctx context.Context, userID string) (*service.User, error) {
+				return &service.User{ID: id, FirstName: "John", LastName: "Doe", Nickname: "jdoe", Email: "john@foo.bar", Country: "US"}, nil
+			},
+			UpdateFunc: func(ctx context.Context, user *service.User) (*service.User, error) {
+				assert.Equal(t, "Johnny", user.FirstName)
+				assert.Equal(t, "Doe", user.LastName)
+				assert.Equal(t, "Europe/Lisbon", user.Timezone)
+				assert.Empty(t, user.Password)
+				return user, nil
+			},
+		}
+
+		resp := graphQLRequestHelper(t, newGraphQLHandlerHelper(svc), `mutation($id: ID!) {
+			updateUser(input: {id: $id, firstName: "Johnny", timezone: "Europe/Lisbon"}) { firstName lastName }
+		}`, map[string]any{"id": id})
+		require.Empty(t, resp.Errors)
+
+		assert.JSONEq(t, `{"updateUser": {"firstName": "Johnny", "lastName": "Doe"}}`, string(resp.Data))
+	})
+
+	t.Run("without fields", func(t *testing.T) {
+		resp := graphQLRequestHelper(t, newGraphQLHandlerHelper(&serviceMock{}), `mutation($id: ID!) {
+			updateUser(input: {id: $id}) { id }
+		}`, map[string]any{"id": id})
+
+		require.Len(t, resp.Errors, 1)
+		assert.Equal(t, "INVALID_ARGUMENT", resp.Errors[0].Extensions.Code)
+		require.Len(t, resp.Errors[0].Extensions.FieldViolations, 1)
+		assert.Equal(t, "input", resp.Errors[0].Extensions.FieldViolations[0].Field)
+	})
+}
+
+func TestGraphQLInterceptors(t *testing.T) {
+	t.Parallel()
+
+	var observedMethod string
+	interceptor := func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		observedMethod = info.FullMethod
+
+		md, _ := metadata.FromIncomingContext(ctx)
+		if got := md.Get(authorizationHeader); len(got) != 1 || got[0] != "Bearer s3cret" {
+			return nil, ErrAuthRequired
+		}
+		return handler(ctx, req)
+	}
+
+	svc := &serviceMock{
+		FetchFunc: func(ctx context.Context, id string) (*service.User, error) {
+			return &service.User{ID: id}, nil
+		},
+	}
+
+	h := newGraphQLHandlerHelper(svc, interceptor)
+	query := `query($id: ID!) { user(id: $id) { id } }`
+	variables := map[string]any{"id": uuid.New().String()}
+
+	t.Run("rejected", func(t *testing.T) {
+		resp := graphQLRequestHelper(t, h, query, variables)
+
+		require.Len(t, resp.Errors, 1)
+		assert.Equal(t, "UNAUTHENTICATED", resp.Errors[0].Extensions.Code)
+		assert.Equal(t, "authentication required", resp.Errors[0].Message)
+	})
+
+	t.Run("accepted", func(t *testing.T) {
+		resp := graphQLRequestHelper(t, h, query, variables, "Authorization", "Bearer s3cret")
+
+		require.Empty(t, resp.Errors)
+		assert.Equal(t, "/usrsvc.users.v2.UserService/GetUser", observedMethod)
+	})
+}
+
+func TestGraphQLHandlerRequests(t *testing.T) {
+	t.Parallel()
+
+	h := newGraphQLHandlerHelper(&serviceMock{})
+
+	testCases := []struct {
+		name        string
+		method      string
+		contentType string
+		body        string
+		expected    int
+	}{
+		{name: "not a POST", method: http.MethodGet, contentType: "application/json", expected: http.StatusMethodNotAllowed},
+		{name: "not JSON", method: http.MethodPost, contentType: "text/plain", body: `{"query": "{ user(id: \"1\") { id } }"}`, expected: http.StatusUnsupportedMediaType},
+		{name: "invalid JSON", method: http.MethodPost, contentType: "application/json", body: `{`, expected: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, "/graphql", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", tc.contentType)
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.expected, rec.Code)
+		})
+	}
+}
+
+func TestGraphQLField(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		given    string
+		expected string
+	}{
+		{given: "id", expected: "id"},
+		{given: "user.email", expected: "input.email"},
+		{given: "user.first_name", expected: "input.firstName"},
+		{given: "update_mask", expected: "input"},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, graphQLField(tc.given))
+	}
+}
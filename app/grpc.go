@@ -2,29 +2,83 @@ package app
 
 import (
 	"context"
+	"errors"
+	"io"
 	"log"
+	"sort"
+	"strconv"
 	"time"
 
+	"github.com/alesr/usrsvc/internal/audit"
+	"github.com/alesr/usrsvc/internal/operations"
+	"github.com/alesr/usrsvc/internal/policies"
+	"github.com/alesr/usrsvc/internal/timeouts"
 	"github.com/alesr/usrsvc/internal/users/service"
+	"github.com/alesr/usrsvc/pkg/uservalidation"
 	apiv1 "github.com/alesr/usrsvc/proto/users/v1"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
-	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 const (
+	// ctxTimeout is the default timeout of the RPCs sent without a deadline.
 	ctxTimeout      time.Duration = 5 * time.Second
 	defaultPageSize int32         = 100
+
+	defaultBootstrapKeyName string = "bootstrap"
 )
 
 // userService is the interface that provides the business logic for the gRPC server.
 type userService interface {
 	Fetch(ctx context.Context, id string) (*service.User, error)
 	FetchAll(ctx context.Context, filter service.FilterParams, pag service.PaginationParams) ([]*service.User, error)
+	Count(ctx context.Context, filter service.FilterParams) (int64, error)
+	Search(ctx context.Context, query string, offset, limit int) ([]*service.User, error)
 	Create(ctx context.Context, user *service.User) (*service.User, error)
+	ImportUsers(ctx context.Context, next func() (*service.ImportRecord, error)) (*service.ImportReport, error)
+	CheckNicknameAvailable(ctx context.Context, nickname, country string) (bool, error)
 	Update(ctx context.Context, user *service.User) (*service.User, error)
 	Delete(ctx context.Context, id string) error
+	AnonymizeUser(ctx context.Context, id string) (*service.User, error)
+	SuspendUser(ctx context.Context, id string) (*service.User, error)
+	DeactivateUser(ctx context.Context, id string) (*service.User, error)
+	ReactivateUser(ctx context.Context, id string) (*service.User, error)
+	SetAvatar(ctx context.Context, id string, image []byte) (*service.User, error)
+	GetAvatar(ctx context.Context, id string) (*service.Avatar, error)
+	RequestPhoneVerification(ctx context.Context, id string) error
+	VerifyPhone(ctx context.Context, id, code string) (*service.User, error)
+	Authenticate(ctx context.Context, email, password, totpCode string) (*service.User, error)
+	Stats(ctx context.Context) (*service.UserStats, error)
+	ExportUsers(ctx context.Context, filter service.FilterParams, fn func(user *service.User) error) error
+	FindDuplicates(ctx context.Context, filter service.FilterParams) (*service.DuplicateReport, error)
+	Merge(ctx context.Context, params service.MergeParams) (*service.User, error)
+	DeleteUsersByFilter(ctx context.Context, filter service.BulkDeleteFilter, dryRun bool) (*service.BulkDeleteReport, error)
+	Bootstrap(ctx context.Context, token string, admin *service.User, keyName string) (*service.User, string, error)
+	CreateAPIKey(ctx context.Context, userID, name string, scopes []string) (*service.APIKey, string, error)
+	RevokeAPIKey(ctx context.Context, id string) error
+	APIKeys(ctx context.Context, userID string) ([]*service.APIKey, error)
+	LinkIdentity(ctx context.Context, userID, provider, idToken string) (*service.LinkedIdentity, error)
+	LinkedIdentities(ctx context.Context, userID string) ([]*service.LinkedIdentity, error)
+	UnlinkIdentity(ctx context.Context, userID, provider, subject string) error
+	FetchByIdentity(ctx context.Context, provider, subject string) (*service.User, error)
+	AuthenticateWithIDToken(ctx context.Context, provider, idToken, totpCode string) (*service.User, error)
+	LockFields(ctx context.Context, userID string, fields []string, reason string) ([]*service.FieldLock, error)
+	UnlockFields(ctx context.Context, userID string, fields []string) ([]*service.FieldLock, error)
+	FieldLocks(ctx context.Context, userID string) ([]*service.FieldLock, error)
+	UnlockUser(ctx context.Context, id string) error
+	CreateSession(ctx context.Context, userID string) (*service.SessionTokens, error)
+	RefreshSession(ctx context.Context, refreshToken string) (*service.SessionTokens, error)
+	RevokeSession(ctx context.Context, id string) error
+	RevokeRefreshToken(ctx context.Context, refreshToken string) error
+	Sessions(ctx context.Context, userID string) ([]*service.Session, error)
+	ChangePassword(ctx context.Context, id, oldPassword, newPassword string) error
+	EnrollTOTP(ctx context.Context, id, password string) (*service.TOTPEnrollment, error)
+	VerifyTOTP(ctx context.Context, id, code string) error
+	RequestPasswordReset(ctx context.Context, email string) error
+	ConfirmPasswordReset(ctx context.Context, token, password string) error
+	AuditEvents(ctx context.Context, filter audit.Filter) ([]*audit.Event, error)
+	ListUserChanges(ctx context.Context, since int64, limit int) ([]*service.UserChange, error)
 	CheckServiceHealth(ctx context.Context) error
 }
 
@@ -33,13 +87,37 @@ type GRPCServer struct {
 	apiv1.UnimplementedUserServiceServer
 	logger  *zap.Logger
 	service userService
+
+	trustForwardedFor bool
+	drain             *Drain
+	warningObserver   WarningObserver
+	operations        *operations.Manager
+	policies          *TenantPolicies
+	timeouts          timeouts.Policy
 }
 
 // NewGRPCServer creates a new gRPC server.
-func NewGRPCServer(logger *zap.Logger, service userService) *GRPCServer {
-	return &GRPCServer{
-		logger:  logger,
-		service: service,
+func NewGRPCServer(logger *zap.Logger, service userService, opts ...ServerOption) *GRPCServer {
+	s := &GRPCServer{
+		logger:     logger,
+		service:    service,
+		operations: operations.NewManager(defaultOperationRetention),
+		policies:   NewTenantPolicies(logger, policies.NewMemory(), 0),
+		timeouts:   timeouts.Policy{Default: ctxTimeout},
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// WithTimeoutPolicy configures the deadline of the unary RPCs. The deadlines set by the
+// callers are honored, within the maximum of the policy, and the RPCs sent without one
+// get the default timeout (5s unless configured).
+func WithTimeoutPolicy(policy timeouts.Policy) ServerOption {
+	return func(s *GRPCServer) {
+		s.timeouts = policy
 	}
 }
 
@@ -51,22 +129,20 @@ func (s *GRPCServer) Register(server *grpc.Server) {
 
 // GetUser returns a user by ID.
 func (s *GRPCServer) CreateUser(ctx context.Context, req *apiv1.CreateUserRequest) (*apiv1.CreateUserResponse, error) {
-	if err := validateCreateUserRequest(req); err != nil {
+	if req == nil {
+		return nil, ErrRequestRequired
+	}
+
+	if err := validateRequest(ctx, req); err != nil {
 		s.logger.Error("failed to validate request", zap.Error(err))
 		return nil, err
 	}
+	s.warn(ctx, createUserWarnings(req))
 
-	ctx, cancel := context.WithTimeout(ctx, ctxTimeout)
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
 	defer cancel()
 
-	user := &service.User{
-		FirstName: req.FirstName,
-		LastName:  req.LastName,
-		Nickname:  req.Nickname,
-		Email:     req.Email,
-		Password:  req.Password,
-		Country:   req.Country,
-	}
+	user := newCreateUserFromRequest(req)
 
 	user, err := s.service.Create(ctx, user)
 	if err != nil {
@@ -79,15 +155,70 @@ func (s *GRPCServer) CreateUser(ctx context.Context, req *apiv1.CreateUserReques
 	}, nil
 }
 
+// ImportUsers creates the users streamed by the client, e.g. to migrate them from a legacy
+// system, and reports the failed records once the client closes the stream.
+func (s *GRPCServer) ImportUsers(stream apiv1.UserService_ImportUsersServer) error {
+	// The errors of the stream are returned as is, e.g. when the client cancels.
+	var recvErr error
+
+	report, err := s.service.ImportUsers(stream.Context(), func() (*service.ImportRecord, error) {
+		req, err := stream.Recv()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				recvErr = err
+			}
+			return nil, err
+		}
+		return newImportRecordFromRequest(stream.Context(), req), nil
+	})
+
+	if recvErr != nil {
+		return recvErr
+	}
+
+	if err != nil {
+		s.logger.Error("failed to import users", zap.Error(err))
+		return convertServiceError(err)
+	}
+	return stream.SendAndClose(newImportUsersResponse(report))
+}
+
+// CheckNicknameAvailable reports whether a nickname is free, for the signup forms.
+func (s *GRPCServer) CheckNicknameAvailable(ctx context.Context, req *apiv1.CheckNicknameAvailableRequest) (*apiv1.CheckNicknameAvailableResponse, error) {
+	if req == nil {
+		return nil, ErrRequestRequired
+	}
+
+	if err := validateRequest(ctx, req); err != nil {
+		s.logger.Error("failed to validate request", zap.Error(err))
+		return nil, err
+	}
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	available, err := s.service.CheckNicknameAvailable(ctx, req.Nickname, req.Country)
+	if err != nil {
+		s.logger.Error("failed to check nickname", zap.Error(err))
+		return nil, convertServiceError(err)
+	}
+	return &apiv1.CheckNicknameAvailableResponse{Available: available}, nil
+}
+
 // UpdateUser updates a user by ID.
 // For the sake of simplicity, we update all the fields of the user but the ID.
 func (s *GRPCServer) UpdateUser(ctx context.Context, req *apiv1.UpdateUserRequest) (*apiv1.UpdateUserResponse, error) {
-	if err := validateUpdateUserRequest(req); err != nil {
+	if req == nil {
+		return nil, ErrRequestRequired
+	}
+
+	if err := validateRequest(ctx, req); err != nil {
 		s.logger.Error("failed to validate request", zap.Error(err))
 		return nil, err
 	}
+	s.warn(ctx, updateUserWarnings(req))
 
-	ctx, cancel := context.WithTimeout(ctx, ctxTimeout)
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
 	defer cancel()
 
 	user := &service.User{
@@ -96,8 +227,12 @@ func (s *GRPCServer) UpdateUser(ctx context.Context, req *apiv1.UpdateUserReques
 		LastName:  req.LastName,
 		Nickname:  req.Nickname,
 		Email:     req.Email,
-		Password:  req.Password,
 		Country:   req.Country,
+		Metadata:  req.Metadata,
+		Phone:     req.Phone,
+		Locale:    req.Locale,
+		Timezone:  req.Timezone,
+		Birthdate: parseBirthdate(req.Birthdate),
 	}
 
 	user, err := s.service.Update(ctx, user)
@@ -111,14 +246,66 @@ func (s *GRPCServer) UpdateUser(ctx context.Context, req *apiv1.UpdateUserReques
 	}, nil
 }
 
+// SearchUsers returns the users whose names or nickname match the query, best matches first.
+// The page token is the offset of the next page.
+func (s *GRPCServer) SearchUsers(ctx context.Context, req *apiv1.SearchUsersRequest) (*apiv1.SearchUsersResponse, error) {
+	if req == nil {
+		return nil, ErrRequestRequired
+	}
+
+	if err := validateRequest(ctx, req); err != nil {
+		s.logger.Error("failed to validate search users request", zap.Error(err))
+		return nil, err
+	}
+
+	if req.PageSize <= 0 || req.PageSize > defaultPageSize {
+		req.PageSize = defaultPageSize
+	}
+
+	var offset int
+	if req.PageToken != "" {
+		var err error
+		offset, err = strconv.Atoi(req.PageToken)
+		if err != nil || offset <= 0 {
+			s.logger.Error("failed to validate offset", zap.String("page_token", req.PageToken))
+			return nil, ErrPageTokenInvalid
+		}
+	}
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	users, err := s.service.Search(ctx, req.Query, offset, int(req.PageSize))
+	if err != nil {
+		s.logger.Error("failed to search users", zap.Error(err))
+		return nil, convertServiceError(err)
+	}
+
+	resp := apiv1.SearchUsersResponse{
+		Users: make([]*apiv1.User, 0, len(users)),
+	}
+	for _, user := range users {
+		resp.Users = append(resp.Users, newUserResponseFromDomain(user))
+	}
+
+	if len(users) == int(req.PageSize) {
+		resp.NextPageToken = strconv.Itoa(offset + len(users))
+	}
+	return &resp, nil
+}
+
 // DeleteUser deletes a user by ID.
 func (s *GRPCServer) GetUser(ctx context.Context, req *apiv1.GetUserRequest) (*apiv1.GetUserResponse, error) {
-	if err := validateID(req.Id); err != nil {
+	if req == nil {
+		return nil, ErrRequestRequired
+	}
+
+	if err := validateRequest(ctx, req); err != nil {
 		s.logger.Error("failed to validate id", zap.Error(err))
 		return nil, err
 	}
 
-	ctx, cancel := context.WithTimeout(ctx, ctxTimeout)
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
 	defer cancel()
 
 	user, err := s.service.Fetch(ctx, req.Id)
@@ -135,7 +322,7 @@ func (s *GRPCServer) GetUser(ctx context.Context, req *apiv1.GetUserRequest) (*a
 /*
 ListUsers returns a list of users.
 
-The list can be filtered by country and paginated.
+The list can be filtered by country, nickname prefix, email, names and creation time, and paginated.
 The default page size is 100. If a page size is not provided or is invalid, the default page size is used.
 The default page token points to the last ID in the list.
 If a page token is not required, but if an invalid page token is provided, an error is returned.
@@ -143,6 +330,10 @@ If a page token is not required, but if an invalid page token is provided, an er
 The implementation for the pagination is based on https://cloud.google.com/apis/design/design_patterns#list_pagination
 */
 func (s *GRPCServer) ListUsers(ctx context.Context, req *apiv1.ListUsersRequest) (*apiv1.ListUsersResponse, error) {
+	if req == nil {
+		return nil, ErrRequestRequired
+	}
+
 	if req.PageSize <= 0 || req.PageSize > defaultPageSize {
 		req.PageSize = defaultPageSize
 	}
@@ -154,16 +345,16 @@ func (s *GRPCServer) ListUsers(ctx context.Context, req *apiv1.ListUsersRequest)
 		}
 	}
 
-	if req.Country != "" && len(req.Country) != 2 {
+	if req.Country != "" && !uservalidation.IsCountryCode(req.Country) {
 		return nil, ErrCountryCodeInvalid
 	}
 
-	ctx, cancel := context.WithTimeout(ctx, ctxTimeout)
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
 	defer cancel()
 
-	var filters service.FilterParams
-	if req.Country != "" {
-		filters.Country = &req.Country
+	filters, err := newFilterParamsFromListRequest(req)
+	if err != nil {
+		return nil, err
 	}
 
 	pagination := service.PaginationParams{
@@ -187,20 +378,34 @@ func (s *GRPCServer) ListUsers(ctx context.Context, req *apiv1.ListUsersRequest)
 		usersProto = append(usersProto, newUserResponseFromDomain(user))
 	}
 
-	return &apiv1.ListUsersResponse{
+	resp := apiv1.ListUsersResponse{
 		Users:         usersProto,
 		NextPageToken: nextPageToken,
-	}, nil
+	}
+
+	if req.IncludeTotalSize {
+		totalSize, err := s.service.Count(ctx, filters)
+		if err != nil {
+			s.logger.Error("failed to count users", zap.Error(err))
+			return nil, convertServiceError(err)
+		}
+		resp.TotalSize = &totalSize
+	}
+	return &resp, nil
 }
 
 // DeleteUser deletes a user by ID.
 func (s *GRPCServer) DeleteUser(ctx context.Context, req *apiv1.DeleteUserRequest) (*apiv1.DeleteUserResponse, error) {
-	if err := validateID(req.Id); err != nil {
+	if req == nil {
+		return nil, ErrRequestRequired
+	}
+
+	if err := validateRequest(ctx, req); err != nil {
 		s.logger.Error("failed to validate id", zap.Error(err))
 		return nil, err
 	}
 
-	ctx, cancel := context.WithTimeout(ctx, ctxTimeout)
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
 	defer cancel()
 
 	if err := s.service.Delete(ctx, req.Id); err != nil {
@@ -211,35 +416,1046 @@ func (s *GRPCServer) DeleteUser(ctx context.Context, req *apiv1.DeleteUserReques
 	return &apiv1.DeleteUserResponse{}, nil
 }
 
-// CheckHeath checks the health of the application going all the way down to the database.
-func (s *GRPCServer) CheckHeath(ctx context.Context, req *apiv1.HealthCheckRequest) (*apiv1.HealthCheckResponse, error) {
-	ctx, cancel := context.WithTimeout(ctx, ctxTimeout)
+// AnonymizeUser erases the personal data of a user by ID, keeping the user.
+func (s *GRPCServer) AnonymizeUser(ctx context.Context, req *apiv1.AnonymizeUserRequest) (*apiv1.AnonymizeUserResponse, error) {
+	if req == nil {
+		return nil, ErrRequestRequired
+	}
+
+	if err := validateRequest(ctx, req); err != nil {
+		s.logger.Error("failed to validate id", zap.Error(err))
+		return nil, err
+	}
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
 	defer cancel()
 
-	if err := s.service.CheckServiceHealth(ctx); err != nil {
-		return &apiv1.HealthCheckResponse{
-			Status: apiv1.HealthCheckResponse_NOT_SERVING,
-		}, nil
+	user, err := s.service.AnonymizeUser(ctx, req.Id)
+	if err != nil {
+		s.logger.Error("failed to anonymize user", zap.Error(err))
+		return nil, convertServiceError(err)
 	}
-	return &apiv1.HealthCheckResponse{
-		Status: apiv1.HealthCheckResponse_SERVING,
+
+	return &apiv1.AnonymizeUserResponse{
+		User: newUserResponseFromDomain(user),
+	}, nil
+}
+
+// SuspendUser suspends a user by ID, until it is reactivated.
+func (s *GRPCServer) SuspendUser(ctx context.Context, req *apiv1.SuspendUserRequest) (*apiv1.SuspendUserResponse, error) {
+	if req == nil {
+		return nil, ErrRequestRequired
+	}
+
+	if err := validateRequest(ctx, req); err != nil {
+		s.logger.Error("failed to validate id", zap.Error(err))
+		return nil, err
+	}
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	user, err := s.service.SuspendUser(ctx, req.Id)
+	if err != nil {
+		s.logger.Error("failed to suspend user", zap.Error(err))
+		return nil, convertServiceError(err)
+	}
+
+	return &apiv1.SuspendUserResponse{
+		User: newUserResponseFromDomain(user),
+	}, nil
+}
+
+// DeactivateUser deactivates a user by ID, until it is reactivated.
+func (s *GRPCServer) DeactivateUser(ctx context.Context, req *apiv1.DeactivateUserRequest) (*apiv1.DeactivateUserResponse, error) {
+	if req == nil {
+		return nil, ErrRequestRequired
+	}
+
+	if err := validateRequest(ctx, req); err != nil {
+		s.logger.Error("failed to validate id", zap.Error(err))
+		return nil, err
+	}
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	user, err := s.service.DeactivateUser(ctx, req.Id)
+	if err != nil {
+		s.logger.Error("failed to deactivate user", zap.Error(err))
+		return nil, convertServiceError(err)
+	}
+
+	return &apiv1.DeactivateUserResponse{
+		User: newUserResponseFromDomain(user),
+	}, nil
+}
+
+// ReactivateUser makes a suspended or deactivated user active again.
+func (s *GRPCServer) ReactivateUser(ctx context.Context, req *apiv1.ReactivateUserRequest) (*apiv1.ReactivateUserResponse, error) {
+	if req == nil {
+		return nil, ErrRequestRequired
+	}
+
+	if err := validateRequest(ctx, req); err != nil {
+		s.logger.Error("failed to validate id", zap.Error(err))
+		return nil, err
+	}
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	user, err := s.service.ReactivateUser(ctx, req.Id)
+	if err != nil {
+		s.logger.Error("failed to reactivate user", zap.Error(err))
+		return nil, convertServiceError(err)
+	}
+
+	return &apiv1.ReactivateUserResponse{
+		User: newUserResponseFromDomain(user),
+	}, nil
+}
+
+// SetAvatar sets or, with an empty image, removes the avatar image of a user.
+func (s *GRPCServer) SetAvatar(ctx context.Context, req *apiv1.SetAvatarRequest) (*apiv1.SetAvatarResponse, error) {
+	if req == nil {
+		return nil, ErrRequestRequired
+	}
+
+	if err := validateRequest(ctx, req); err != nil {
+		s.logger.Error("failed to validate id", zap.Error(err))
+		return nil, err
+	}
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	user, err := s.service.SetAvatar(ctx, req.Id, req.Image)
+	if err != nil {
+		s.logger.Error("failed to set avatar", zap.Error(err))
+		return nil, convertServiceError(err)
+	}
+
+	return &apiv1.SetAvatarResponse{
+		User: newUserResponseFromDomain(user),
+	}, nil
+}
+
+// GetAvatar returns the avatar image of a user, or a signed URL to it.
+func (s *GRPCServer) GetAvatar(ctx context.Context, req *apiv1.GetAvatarRequest) (*apiv1.GetAvatarResponse, error) {
+	if req == nil {
+		return nil, ErrRequestRequired
+	}
+
+	if err := validateRequest(ctx, req); err != nil {
+		s.logger.Error("failed to validate id", zap.Error(err))
+		return nil, err
+	}
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	avatar, err := s.service.GetAvatar(ctx, req.Id)
+	if err != nil {
+		s.logger.Error("failed to get avatar", zap.Error(err))
+		return nil, convertServiceError(err)
+	}
+
+	return &apiv1.GetAvatarResponse{
+		Image:       avatar.Image,
+		ContentType: avatar.ContentType,
+		Hash:        avatar.Hash,
+		Url:         avatar.URL,
+	}, nil
+}
+
+// RequestPhoneVerification texts a verification code to the phone of a user.
+func (s *GRPCServer) RequestPhoneVerification(ctx context.Context, req *apiv1.RequestPhoneVerificationRequest) (*apiv1.RequestPhoneVerificationResponse, error) {
+	if req == nil {
+		return nil, ErrRequestRequired
+	}
+
+	if err := validateRequest(ctx, req); err != nil {
+		s.logger.Error("failed to validate id", zap.Error(err))
+		return nil, err
+	}
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	if err := s.service.RequestPhoneVerification(ctx, req.Id); err != nil {
+		s.logger.Error("failed to request phone verification", zap.Error(err))
+		return nil, convertServiceError(err)
+	}
+	return &apiv1.RequestPhoneVerificationResponse{}, nil
+}
+
+// VerifyPhone verifies the phone of a user with the code texted to it.
+func (s *GRPCServer) VerifyPhone(ctx context.Context, req *apiv1.VerifyPhoneRequest) (*apiv1.VerifyPhoneResponse, error) {
+	if req == nil {
+		return nil, ErrRequestRequired
+	}
+
+	if err := validateRequest(ctx, req); err != nil {
+		s.logger.Error("failed to validate verify phone request", zap.Error(err))
+		return nil, err
+	}
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	user, err := s.service.VerifyPhone(ctx, req.Id, req.Code)
+	if err != nil {
+		s.logger.Error("failed to verify phone", zap.Error(err))
+		return nil, convertServiceError(err)
+	}
+
+	return &apiv1.VerifyPhoneResponse{
+		User: newUserResponseFromDomain(user),
+	}, nil
+}
+
+// Authenticate verifies the user credentials and returns the user on success.
+// The credentials are either an email and password or an ID token from a linked identity provider.
+func (s *GRPCServer) Authenticate(ctx context.Context, req *apiv1.AuthenticateRequest) (*apiv1.AuthenticateResponse, error) {
+	if req == nil {
+		return nil, ErrRequestRequired
+	}
+
+	if err := validateAuthenticateRequest(ctx, req); err != nil {
+		s.logger.Error("failed to validate request", zap.Error(err))
+		return nil, err
+	}
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	if ip := clientIP(ctx, s.trustForwardedFor); ip != nil {
+		ctx = service.ContextWithClientIP(ctx, ip)
+	}
+
+	var (
+		user *service.User
+		err  error
+	)
+	if req.IdToken != "" {
+		user, err = s.service.AuthenticateWithIDToken(ctx, req.Provider, req.IdToken, req.TotpCode)
+	} else {
+		user, err = s.service.Authenticate(ctx, req.Email, req.Password, req.TotpCode)
+	}
+	if err != nil {
+		s.logger.Error("failed to authenticate user", zap.Error(err))
+		return nil, convertServiceError(err)
+	}
+
+	tokens, err := s.service.CreateSession(ctx, user.ID)
+	if err != nil {
+		s.logger.Error("failed to create session", zap.Error(err))
+		return nil, convertServiceError(err)
+	}
+
+	return &apiv1.AuthenticateResponse{
+		User:   newUserResponseFromDomain(user),
+		Tokens: newSessionTokensResponseFromDomain(tokens),
+	}, nil
+}
+
+// RefreshToken trades the refresh token of a session for new session tokens.
+func (s *GRPCServer) RefreshToken(ctx context.Context, req *apiv1.RefreshTokenRequest) (*apiv1.RefreshTokenResponse, error) {
+	if req == nil {
+		return nil, ErrRequestRequired
+	}
+
+	if err := validateRequest(ctx, req); err != nil {
+		s.logger.Error("failed to validate request", zap.Error(err))
+		return nil, err
+	}
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	tokens, err := s.service.RefreshSession(ctx, req.RefreshToken)
+	if err != nil {
+		s.logger.Error("failed to refresh session", zap.Error(err))
+		return nil, convertServiceError(err)
+	}
+
+	return &apiv1.RefreshTokenResponse{
+		Tokens: newSessionTokensResponseFromDomain(tokens),
 	}, nil
 }
 
-func newUserResponseFromDomain(user *service.User) *apiv1.User {
-	// Better safe than sorry.
-	if user == nil {
-		return nil
+// RevokeSession logs out of a session, given its refresh token or, for admins, its id.
+func (s *GRPCServer) RevokeSession(ctx context.Context, req *apiv1.RevokeSessionRequest) (*apiv1.RevokeSessionResponse, error) {
+	if req == nil {
+		return nil, ErrRequestRequired
+	}
+
+	if err := validateRevokeSessionRequest(ctx, req); err != nil {
+		s.logger.Error("failed to validate request", zap.Error(err))
+		return nil, err
 	}
 
-	return &apiv1.User{
-		Id:        user.ID,
-		FirstName: user.FirstName,
-		LastName:  user.LastName,
-		Nickname:  user.Nickname,
-		Email:     user.Email,
-		Country:   user.Country,
-		CreatedAt: timestamppb.New(user.CreatedAt),
-		UpdatedAt: timestamppb.New(user.UpdatedAt),
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	var err error
+	if req.Id != "" {
+		err = s.service.RevokeSession(ctx, req.Id)
+	} else {
+		err = s.service.RevokeRefreshToken(ctx, req.RefreshToken)
+	}
+	if err != nil {
+		s.logger.Error("failed to revoke session", zap.Error(err))
+		return nil, convertServiceError(err)
 	}
+	return &apiv1.RevokeSessionResponse{}, nil
+}
+
+// ListSessions lists the active sessions of the user, most recent first.
+func (s *GRPCServer) ListSessions(ctx context.Context, req *apiv1.ListSessionsRequest) (*apiv1.ListSessionsResponse, error) {
+	if req == nil {
+		return nil, ErrRequestRequired
+	}
+
+	if err := validateRequest(ctx, req); err != nil {
+		s.logger.Error("failed to validate id", zap.Error(err))
+		return nil, err
+	}
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	sessions, err := s.service.Sessions(ctx, req.UserId)
+	if err != nil {
+		s.logger.Error("failed to list sessions", zap.Error(err))
+		return nil, convertServiceError(err)
+	}
+
+	resp := apiv1.ListSessionsResponse{
+		Sessions: make([]*apiv1.Session, 0, len(sessions)),
+	}
+	for _, session := range sessions {
+		resp.Sessions = append(resp.Sessions, newSessionResponseFromDomain(session))
+	}
+	return &resp, nil
+}
+
+// GetUserStats returns the number of users per country, sorted by country code.
+func (s *GRPCServer) GetUserStats(ctx context.Context, req *apiv1.GetUserStatsRequest) (*apiv1.GetUserStatsResponse, error) {
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	stats, err := s.service.Stats(ctx)
+	if err != nil {
+		s.logger.Error("failed to get user stats", zap.Error(err))
+		return nil, convertServiceError(err)
+	}
+
+	countries := make([]*apiv1.CountryCount, 0, len(stats.CountByCountry))
+	for country, count := range stats.CountByCountry {
+		countries = append(countries, &apiv1.CountryCount{
+			Country: country,
+			Count:   count,
+		})
+	}
+
+	sort.Slice(countries, func(i, j int) bool {
+		return countries[i].Country < countries[j].Country
+	})
+
+	return &apiv1.GetUserStatsResponse{
+		Countries:   countries,
+		Total:       stats.Total(),
+		RefreshedAt: newTimestamp(stats.RefreshedAt),
+	}, nil
+}
+
+// FindDuplicateUsers returns the accounts that likely belong to the same person, optionally
+// within a single country, with the account we suggest to keep when merging them.
+// It scans every user, so it is meant for support tooling rather than user-facing traffic.
+func (s *GRPCServer) FindDuplicateUsers(ctx context.Context, req *apiv1.FindDuplicateUsersRequest) (*apiv1.FindDuplicateUsersResponse, error) {
+	filters, err := findDuplicateUsersFilter(req)
+	if err != nil {
+		return nil, err
+	}
+	return s.findDuplicateUsers(ctx, filters)
+}
+
+// findDuplicateUsersFilter validates the request and returns its filter.
+func findDuplicateUsersFilter(req *apiv1.FindDuplicateUsersRequest) (service.FilterParams, error) {
+	var filters service.FilterParams
+	if req == nil {
+		return filters, ErrRequestRequired
+	}
+
+	if req.Country != "" && !uservalidation.IsCountryCode(req.Country) {
+		return filters, ErrCountryCodeInvalid
+	}
+
+	if req.Country != "" {
+		filters.Country = &req.Country
+	}
+	return filters, nil
+}
+
+func (s *GRPCServer) findDuplicateUsers(ctx context.Context, filters service.FilterParams) (*apiv1.FindDuplicateUsersResponse, error) {
+	// The scan is bounded by the database timeout of each page, not by the request timeout.
+	report, err := s.service.FindDuplicates(ctx, filters)
+	if err != nil {
+		s.logger.Error("failed to find duplicate users", zap.Error(err))
+		return nil, convertServiceError(err)
+	}
+
+	candidates := make([]*apiv1.DuplicateUserCandidate, 0, len(report.Candidates))
+	for _, candidate := range report.Candidates {
+		candidates = append(candidates, &apiv1.DuplicateUserCandidate{
+			Survivor:  newUserResponseFromDomain(candidate.Survivor),
+			Duplicate: newUserResponseFromDomain(candidate.Duplicate),
+			Score:     candidate.Score,
+			Reasons:   candidate.Reasons,
+		})
+	}
+
+	return &apiv1.FindDuplicateUsersResponse{
+		Candidates:   candidates,
+		ScannedUsers: int64(report.ScannedUsers),
+		GeneratedAt:  newTimestamp(report.GeneratedAt),
+	}, nil
+}
+
+// MergeUsers merges the duplicate account into the survivor. The survivor keeps its own
+// email and nickname unless the request asks to keep the duplicate's ones instead.
+func (s *GRPCServer) MergeUsers(ctx context.Context, req *apiv1.MergeUsersRequest) (*apiv1.MergeUsersResponse, error) {
+	params, err := s.mergeUsersParams(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return s.mergeUsers(ctx, params)
+}
+
+// mergeUsersParams validates the request and returns the merge parameters.
+func (s *GRPCServer) mergeUsersParams(ctx context.Context, req *apiv1.MergeUsersRequest) (service.MergeParams, error) {
+	if req == nil {
+		return service.MergeParams{}, ErrRequestRequired
+	}
+
+	if err := validateRequest(ctx, req); err != nil {
+		s.logger.Error("failed to validate id", zap.Error(err))
+		return service.MergeParams{}, err
+	}
+
+	return service.MergeParams{
+		SurvivorID:            req.SurvivorId,
+		DuplicateID:           req.DuplicateId,
+		KeepDuplicateEmail:    req.KeepDuplicateEmail,
+		KeepDuplicateNickname: req.KeepDuplicateNickname,
+	}, nil
+}
+
+func (s *GRPCServer) mergeUsers(ctx context.Context, params service.MergeParams) (*apiv1.MergeUsersResponse, error) {
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	user, err := s.service.Merge(ctx, params)
+	if err != nil {
+		s.logger.Error("failed to merge users", zap.Error(err))
+		return nil, convertServiceError(err)
+	}
+
+	return &apiv1.MergeUsersResponse{
+		User: newUserResponseFromDomain(user),
+	}, nil
+}
+
+// DeleteUsersByFilter soft-deletes the users of a country and/or created before a time, e.g.
+// stale test accounts, by deactivating them. Admins, anonymized and deactivated users are
+// left as they are. A dry run only counts the users it would delete.
+func (s *GRPCServer) DeleteUsersByFilter(ctx context.Context, req *apiv1.DeleteUsersByFilterRequest) (*apiv1.DeleteUsersByFilterResponse, error) {
+	filter, err := deleteUsersByFilterFilter(req)
+	if err != nil {
+		return nil, err
+	}
+	return s.deleteUsersByFilter(ctx, filter, req.DryRun)
+}
+
+// deleteUsersByFilterFilter validates the request and returns its filter.
+func deleteUsersByFilterFilter(req *apiv1.DeleteUsersByFilterRequest) (service.BulkDeleteFilter, error) {
+	var filter service.BulkDeleteFilter
+	if req == nil {
+		return filter, ErrRequestRequired
+	}
+
+	if req.Country != "" {
+		if !uservalidation.IsCountryCode(req.Country) {
+			return filter, ErrCountryCodeInvalid
+		}
+		filter.Country = &req.Country
+	}
+
+	createdBefore, ok, err := timeFromTimestamp(req.GetCreatedBefore())
+	if err != nil {
+		return filter, ErrCreatedRangeInvalid
+	}
+	if ok {
+		filter.CreatedBefore = &createdBefore
+	}
+
+	if filter.Country == nil && filter.CreatedBefore == nil {
+		return filter, ErrFilterRequired
+	}
+	return filter, nil
+}
+
+func (s *GRPCServer) deleteUsersByFilter(ctx context.Context, filter service.BulkDeleteFilter, dryRun bool) (*apiv1.DeleteUsersByFilterResponse, error) {
+	// Like the duplicates scan, it is bounded by the database timeouts, not by the request timeout.
+	report, err := s.service.DeleteUsersByFilter(ctx, filter, dryRun)
+	if err != nil {
+		s.logger.Error("failed to delete users by filter", zap.Error(err))
+		return nil, convertServiceError(err)
+	}
+
+	return &apiv1.DeleteUsersByFilterResponse{
+		ScannedUsers: report.Scanned,
+		MatchedUsers: report.Matched,
+		DeletedUsers: report.Deleted,
+		DryRun:       report.DryRun,
+	}, nil
+}
+
+// Bootstrap creates the initial admin user and API key of a fresh deployment.
+// It requires the bootstrap token the service was started with and fails once an admin exists.
+func (s *GRPCServer) Bootstrap(ctx context.Context, req *apiv1.BootstrapRequest) (*apiv1.BootstrapResponse, error) {
+	if req == nil {
+		return nil, ErrRequestRequired
+	}
+
+	if err := validateBootstrapRequest(ctx, req); err != nil {
+		s.logger.Error("failed to validate request", zap.Error(err))
+		return nil, err
+	}
+	s.warn(ctx, createUserWarnings(req.Admin))
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	keyName := req.ApiKeyName
+	if keyName == "" {
+		keyName = defaultBootstrapKeyName
+	}
+
+	admin, apiKey, err := s.service.Bootstrap(ctx, req.Token, newCreateUserFromRequest(req.Admin), keyName)
+	if err != nil {
+		s.logger.Error("failed to bootstrap", zap.Error(err))
+		return nil, convertServiceError(err)
+	}
+
+	return &apiv1.BootstrapResponse{
+		Admin:  newUserResponseFromDomain(admin),
+		ApiKey: apiKey,
+	}, nil
+}
+
+// CreateAPIKey creates an API key for the user. The key is only returned once.
+func (s *GRPCServer) CreateAPIKey(ctx context.Context, req *apiv1.CreateAPIKeyRequest) (*apiv1.CreateAPIKeyResponse, error) {
+	if req == nil {
+		return nil, ErrRequestRequired
+	}
+
+	if err := validateCreateAPIKeyRequest(ctx, req); err != nil {
+		s.logger.Error("failed to validate create api key request", zap.Error(err))
+		return nil, err
+	}
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	key, apiKey, err := s.service.CreateAPIKey(ctx, req.UserId, req.Name, req.Scopes)
+	if err != nil {
+		s.logger.Error("failed to create api key", zap.Error(err))
+		return nil, convertServiceError(err)
+	}
+
+	return &apiv1.CreateAPIKeyResponse{
+		ApiKey: newAPIKeyResponseFromDomain(key),
+		Key:    apiKey,
+	}, nil
+}
+
+// RevokeAPIKey revokes an API key by id.
+func (s *GRPCServer) RevokeAPIKey(ctx context.Context, req *apiv1.RevokeAPIKeyRequest) (*apiv1.RevokeAPIKeyResponse, error) {
+	if req == nil {
+		return nil, ErrRequestRequired
+	}
+
+	if err := validateRequest(ctx, req); err != nil {
+		s.logger.Error("failed to validate id", zap.Error(err))
+		return nil, err
+	}
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	if err := s.service.RevokeAPIKey(ctx, req.Id); err != nil {
+		s.logger.Error("failed to revoke api key", zap.Error(err))
+		return nil, convertServiceError(err)
+	}
+	return &apiv1.RevokeAPIKeyResponse{}, nil
+}
+
+// ListAPIKeys lists the API keys of the user, oldest first.
+func (s *GRPCServer) ListAPIKeys(ctx context.Context, req *apiv1.ListAPIKeysRequest) (*apiv1.ListAPIKeysResponse, error) {
+	if req == nil {
+		return nil, ErrRequestRequired
+	}
+
+	if err := validateRequest(ctx, req); err != nil {
+		s.logger.Error("failed to validate id", zap.Error(err))
+		return nil, err
+	}
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	keys, err := s.service.APIKeys(ctx, req.UserId)
+	if err != nil {
+		s.logger.Error("failed to list api keys", zap.Error(err))
+		return nil, convertServiceError(err)
+	}
+
+	resp := apiv1.ListAPIKeysResponse{
+		ApiKeys: make([]*apiv1.APIKey, 0, len(keys)),
+	}
+	for _, key := range keys {
+		resp.ApiKeys = append(resp.ApiKeys, newAPIKeyResponseFromDomain(key))
+	}
+	return &resp, nil
+}
+
+// LinkExternalIdentity links the identity asserted by an OIDC ID token to the user.
+func (s *GRPCServer) LinkExternalIdentity(ctx context.Context, req *apiv1.LinkExternalIdentityRequest) (*apiv1.LinkExternalIdentityResponse, error) {
+	if req == nil {
+		return nil, ErrRequestRequired
+	}
+
+	if err := validateRequest(ctx, req); err != nil {
+		s.logger.Error("failed to validate request", zap.Error(err))
+		return nil, err
+	}
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	identity, err := s.service.LinkIdentity(ctx, req.UserId, req.Provider, req.IdToken)
+	if err != nil {
+		s.logger.Error("failed to link identity", zap.Error(err))
+		return nil, convertServiceError(err)
+	}
+
+	return &apiv1.LinkExternalIdentityResponse{
+		Identity: newLinkedIdentityResponseFromDomain(identity),
+	}, nil
+}
+
+// ListLinkedIdentities lists the external identities linked to the user.
+func (s *GRPCServer) ListLinkedIdentities(ctx context.Context, req *apiv1.ListLinkedIdentitiesRequest) (*apiv1.ListLinkedIdentitiesResponse, error) {
+	if req == nil {
+		return nil, ErrRequestRequired
+	}
+
+	if err := validateRequest(ctx, req); err != nil {
+		s.logger.Error("failed to validate id", zap.Error(err))
+		return nil, err
+	}
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	identities, err := s.service.LinkedIdentities(ctx, req.UserId)
+	if err != nil {
+		s.logger.Error("failed to list linked identities", zap.Error(err))
+		return nil, convertServiceError(err)
+	}
+
+	resp := apiv1.ListLinkedIdentitiesResponse{
+		Identities: make([]*apiv1.LinkedIdentity, 0, len(identities)),
+	}
+	for _, identity := range identities {
+		resp.Identities = append(resp.Identities, newLinkedIdentityResponseFromDomain(identity))
+	}
+	return &resp, nil
+}
+
+// UnlinkExternalIdentity unlinks an external identity from the user.
+func (s *GRPCServer) UnlinkExternalIdentity(ctx context.Context, req *apiv1.UnlinkExternalIdentityRequest) (*apiv1.UnlinkExternalIdentityResponse, error) {
+	if req == nil {
+		return nil, ErrRequestRequired
+	}
+
+	if err := validateRequest(ctx, req); err != nil {
+		s.logger.Error("failed to validate request", zap.Error(err))
+		return nil, err
+	}
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	if err := s.service.UnlinkIdentity(ctx, req.UserId, req.Provider, req.Subject); err != nil {
+		s.logger.Error("failed to unlink identity", zap.Error(err))
+		return nil, convertServiceError(err)
+	}
+	return &apiv1.UnlinkExternalIdentityResponse{}, nil
+}
+
+// GetUserByIdentity returns the user linked to an external identity.
+func (s *GRPCServer) GetUserByIdentity(ctx context.Context, req *apiv1.GetUserByIdentityRequest) (*apiv1.GetUserByIdentityResponse, error) {
+	if req == nil {
+		return nil, ErrRequestRequired
+	}
+
+	if err := validateRequest(ctx, req); err != nil {
+		s.logger.Error("failed to validate request", zap.Error(err))
+		return nil, err
+	}
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	user, err := s.service.FetchByIdentity(ctx, req.Provider, req.Subject)
+	if err != nil {
+		s.logger.Error("failed to fetch user by identity", zap.Error(err))
+		return nil, convertServiceError(err)
+	}
+
+	return &apiv1.GetUserByIdentityResponse{
+		User: newUserResponseFromDomain(user),
+	}, nil
+}
+
+// LockUserFields locks fields of a user, e.g. the email pending an investigation.
+// Changes to locked fields are rejected until they are unlocked.
+func (s *GRPCServer) LockUserFields(ctx context.Context, req *apiv1.LockUserFieldsRequest) (*apiv1.LockUserFieldsResponse, error) {
+	if req == nil {
+		return nil, ErrRequestRequired
+	}
+
+	if err := validateLockUserFieldsRequest(ctx, req); err != nil {
+		s.logger.Error("failed to validate lock user fields request", zap.Error(err))
+		return nil, err
+	}
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	locks, err := s.service.LockFields(ctx, req.UserId, req.Fields, req.Reason)
+	if err != nil {
+		s.logger.Error("failed to lock user fields", zap.Error(err))
+		return nil, convertServiceError(err)
+	}
+
+	return &apiv1.LockUserFieldsResponse{
+		Locks: newFieldLocksResponseFromDomain(locks),
+	}, nil
+}
+
+// UnlockUserFields unlocks fields of a user and returns the fields left locked.
+func (s *GRPCServer) UnlockUserFields(ctx context.Context, req *apiv1.UnlockUserFieldsRequest) (*apiv1.UnlockUserFieldsResponse, error) {
+	if req == nil {
+		return nil, ErrRequestRequired
+	}
+
+	if err := validateUnlockUserFieldsRequest(ctx, req); err != nil {
+		s.logger.Error("failed to validate unlock user fields request", zap.Error(err))
+		return nil, err
+	}
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	locks, err := s.service.UnlockFields(ctx, req.UserId, req.Fields)
+	if err != nil {
+		s.logger.Error("failed to unlock user fields", zap.Error(err))
+		return nil, convertServiceError(err)
+	}
+
+	return &apiv1.UnlockUserFieldsResponse{
+		Locks: newFieldLocksResponseFromDomain(locks),
+	}, nil
+}
+
+// ListFieldLocks lists the locked fields of a user.
+func (s *GRPCServer) ListFieldLocks(ctx context.Context, req *apiv1.ListFieldLocksRequest) (*apiv1.ListFieldLocksResponse, error) {
+	if req == nil {
+		return nil, ErrRequestRequired
+	}
+
+	if err := validateRequest(ctx, req); err != nil {
+		s.logger.Error("failed to validate id", zap.Error(err))
+		return nil, err
+	}
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	locks, err := s.service.FieldLocks(ctx, req.UserId)
+	if err != nil {
+		s.logger.Error("failed to list field locks", zap.Error(err))
+		return nil, convertServiceError(err)
+	}
+
+	return &apiv1.ListFieldLocksResponse{
+		Locks: newFieldLocksResponseFromDomain(locks),
+	}, nil
+}
+
+// UnlockUser lifts the lockout of a user after too many failed logins.
+func (s *GRPCServer) UnlockUser(ctx context.Context, req *apiv1.UnlockUserRequest) (*apiv1.UnlockUserResponse, error) {
+	if req == nil {
+		return nil, ErrRequestRequired
+	}
+
+	if err := validateRequest(ctx, req); err != nil {
+		s.logger.Error("failed to validate id", zap.Error(err))
+		return nil, err
+	}
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	if err := s.service.UnlockUser(ctx, req.Id); err != nil {
+		s.logger.Error("failed to unlock user", zap.Error(err))
+		return nil, convertServiceError(err)
+	}
+	return &apiv1.UnlockUserResponse{}, nil
+}
+
+// ChangePassword sets a new password for the user, given their current password.
+func (s *GRPCServer) ChangePassword(ctx context.Context, req *apiv1.ChangePasswordRequest) (*apiv1.ChangePasswordResponse, error) {
+	if req == nil {
+		return nil, ErrRequestRequired
+	}
+
+	if err := validateRequest(ctx, req); err != nil {
+		s.logger.Error("failed to validate request", zap.Error(err))
+		return nil, err
+	}
+	s.warn(ctx, userValidation.PasswordWarnings(req.NewPassword))
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	if err := s.service.ChangePassword(ctx, req.Id, req.OldPassword, req.NewPassword); err != nil {
+		s.logger.Error("failed to change password", zap.Error(err))
+		return nil, convertServiceError(err)
+	}
+	return &apiv1.ChangePasswordResponse{}, nil
+}
+
+// EnrollTOTP generates a new TOTP secret for the user, given their current password.
+func (s *GRPCServer) EnrollTOTP(ctx context.Context, req *apiv1.EnrollTOTPRequest) (*apiv1.EnrollTOTPResponse, error) {
+	if req == nil {
+		return nil, ErrRequestRequired
+	}
+
+	if err := validateRequest(ctx, req); err != nil {
+		s.logger.Error("failed to validate request", zap.Error(err))
+		return nil, err
+	}
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	enrollment, err := s.service.EnrollTOTP(ctx, req.Id, req.Password)
+	if err != nil {
+		s.logger.Error("failed to enroll totp", zap.Error(err))
+		return nil, convertServiceError(err)
+	}
+
+	return &apiv1.EnrollTOTPResponse{
+		Secret: enrollment.Secret,
+		Uri:    enrollment.URI,
+	}, nil
+}
+
+// VerifyTOTP verifies a TOTP code of the user, enabling TOTP on the first one.
+func (s *GRPCServer) VerifyTOTP(ctx context.Context, req *apiv1.VerifyTOTPRequest) (*apiv1.VerifyTOTPResponse, error) {
+	if req == nil {
+		return nil, ErrRequestRequired
+	}
+
+	if err := validateRequest(ctx, req); err != nil {
+		s.logger.Error("failed to validate request", zap.Error(err))
+		return nil, err
+	}
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	if err := s.service.VerifyTOTP(ctx, req.Id, req.Code); err != nil {
+		s.logger.Error("failed to verify totp", zap.Error(err))
+		return nil, convertServiceError(err)
+	}
+	return &apiv1.VerifyTOTPResponse{}, nil
+}
+
+// RequestPasswordReset sends a password reset link to the user with the given email.
+// It succeeds for unknown emails too, so it can't be used to find out which emails are registered.
+func (s *GRPCServer) RequestPasswordReset(ctx context.Context, req *apiv1.RequestPasswordResetRequest) (*apiv1.RequestPasswordResetResponse, error) {
+	if req == nil {
+		return nil, ErrRequestRequired
+	}
+
+	if err := validateRequest(ctx, req); err != nil {
+		s.logger.Error("failed to validate email", zap.Error(err))
+		return nil, err
+	}
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	if err := s.service.RequestPasswordReset(ctx, req.Email); err != nil {
+		s.logger.Error("failed to request password reset", zap.Error(err))
+		return nil, convertServiceError(err)
+	}
+	return &apiv1.RequestPasswordResetResponse{}, nil
+}
+
+// ConfirmPasswordReset sets a new password with the token sent in the password reset link.
+func (s *GRPCServer) ConfirmPasswordReset(ctx context.Context, req *apiv1.ConfirmPasswordResetRequest) (*apiv1.ConfirmPasswordResetResponse, error) {
+	if req == nil {
+		return nil, ErrRequestRequired
+	}
+
+	if err := validateRequest(ctx, req); err != nil {
+		s.logger.Error("failed to validate request", zap.Error(err))
+		return nil, err
+	}
+	s.warn(ctx, userValidation.PasswordWarnings(req.NewPassword))
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	if err := s.service.ConfirmPasswordReset(ctx, req.Token, req.NewPassword); err != nil {
+		s.logger.Error("failed to confirm password reset", zap.Error(err))
+		return nil, convertServiceError(err)
+	}
+	return &apiv1.ConfirmPasswordResetResponse{}, nil
+}
+
+// ListAuditEvents lists the audit log, newest first, optionally for a single user.
+// The page token is the id of the last event of the previous page.
+func (s *GRPCServer) ListAuditEvents(ctx context.Context, req *apiv1.ListAuditEventsRequest) (*apiv1.ListAuditEventsResponse, error) {
+	if req == nil {
+		return nil, ErrRequestRequired
+	}
+
+	if req.PageSize <= 0 || req.PageSize > defaultPageSize {
+		req.PageSize = defaultPageSize
+	}
+
+	if err := validateRequest(ctx, req); err != nil {
+		s.logger.Error("failed to validate user id", zap.Error(err))
+		return nil, err
+	}
+
+	filter := audit.Filter{
+		UserID: req.UserId,
+		Limit:  int(req.PageSize),
+	}
+
+	if req.PageToken != "" {
+		cursor, err := strconv.ParseInt(req.PageToken, 10, 64)
+		if err != nil || cursor <= 0 {
+			s.logger.Error("failed to validate cursor", zap.String("page_token", req.PageToken))
+			return nil, ErrPageTokenInvalid
+		}
+		filter.Cursor = cursor
+	}
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	events, err := s.service.AuditEvents(ctx, filter)
+	if err != nil {
+		s.logger.Error("failed to list audit events", zap.Error(err))
+		return nil, convertServiceError(err)
+	}
+
+	resp := apiv1.ListAuditEventsResponse{
+		Events: make([]*apiv1.AuditEvent, 0, len(events)),
+	}
+	for _, event := range events {
+		resp.Events = append(resp.Events, newAuditEventResponseFromDomain(event))
+	}
+
+	if len(events) == int(req.PageSize) {
+		resp.NextPageToken = strconv.FormatInt(events[len(events)-1].ID, 10)
+	}
+	return &resp, nil
+}
+
+// ListUserChanges lists the changes of the users, oldest first, for incremental sync.
+// The tokens are the id of the last change listed.
+func (s *GRPCServer) ListUserChanges(ctx context.Context, req *apiv1.ListUserChangesRequest) (*apiv1.ListUserChangesResponse, error) {
+	if req == nil {
+		return nil, ErrRequestRequired
+	}
+
+	if req.PageSize <= 0 || req.PageSize > defaultPageSize {
+		req.PageSize = defaultPageSize
+	}
+
+	var since int64
+	if req.SinceToken != "" {
+		token, err := strconv.ParseInt(req.SinceToken, 10, 64)
+		if err != nil || token < 0 {
+			s.logger.Error("failed to validate since token", zap.String("since_token", req.SinceToken))
+			return nil, ErrSinceTokenInvalid
+		}
+		since = token
+	}
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	userChanges, err := s.service.ListUserChanges(ctx, since, int(req.PageSize))
+	if err != nil {
+		s.logger.Error("failed to list user changes", zap.Error(err))
+		return nil, convertServiceError(err)
+	}
+
+	resp := apiv1.ListUserChangesResponse{
+		Changes:   make([]*apiv1.UserChange, 0, len(userChanges)),
+		NextToken: strconv.FormatInt(since, 10),
+	}
+	for _, change := range userChanges {
+		resp.Changes = append(resp.Changes, newUserChangeResponseFromDomain(change))
+	}
+
+	if len(userChanges) > 0 {
+		resp.NextToken = strconv.FormatInt(userChanges[len(userChanges)-1].ID, 10)
+	}
+	return &resp, nil
+}
+
+// CheckHeath checks the health of the application going all the way down to the database.
+// A draining instance is reported as not serving.
+func (s *GRPCServer) CheckHeath(ctx context.Context, req *apiv1.HealthCheckRequest) (*apiv1.HealthCheckResponse, error) {
+	if s.drain != nil && s.drain.Draining() {
+		return &apiv1.HealthCheckResponse{
+			Status: apiv1.HealthCheckResponse_NOT_SERVING,
+		}, nil
+	}
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	if err := s.service.CheckServiceHealth(ctx); err != nil {
+		return &apiv1.HealthCheckResponse{
+			Status: apiv1.HealthCheckResponse_NOT_SERVING,
+		}, nil
+	}
+	return &apiv1.HealthCheckResponse{
+		Status: apiv1.HealthCheckResponse_SERVING,
+	}, nil
 }
@@ -2,14 +2,26 @@ package app
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/alesr/usrsvc/internal/audit"
+	"github.com/alesr/usrsvc/internal/changes"
+	"github.com/alesr/usrsvc/internal/users/repository"
 	"github.com/alesr/usrsvc/internal/users/service"
 	apiv1 "github.com/alesr/usrsvc/proto/users/v1"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
@@ -84,7 +96,54 @@ func TestCreateUser(t *testing.T) {
 	})
 
 	t.Run("when the email is already in use", func(t *testing.T) {
-		t.SkipNow()
+		svc := &serviceMock{
+			CreateFunc: func(ctx context.Context, user *service.User) (*service.User, error) {
+				return nil, fmt.Errorf("could not insert user: %w", &service.DuplicateError{
+					Field: "email",
+					Scope: repository.ScopeCountry,
+				})
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.CreateUser(context.TODO(), givenReq)
+
+		assert.Nil(t, observed)
+
+		st := status.Convert(err)
+		assert.Equal(t, codes.AlreadyExists, st.Code())
+
+		require.Len(t, st.Details(), 1)
+		info, ok := st.Details()[0].(*errdetails.ErrorInfo)
+		require.True(t, ok)
+
+		assert.Equal(t, duplicateReason, info.Reason)
+		assert.Equal(t, map[string]string{"field": "email", "scope": "country"}, info.Metadata)
+	})
+
+	t.Run("when the user is under the minimum age", func(t *testing.T) {
+		svc := &serviceMock{
+			CreateFunc: func(ctx context.Context, user *service.User) (*service.User, error) {
+				assert.True(t, time.Date(2015, time.March, 1, 0, 0, 0, 0, time.UTC).Equal(user.Birthdate))
+				return nil, fmt.Errorf("could not validate birthdate: %w", service.ErrUnderMinimumAge)
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.CreateUser(context.TODO(), &apiv1.CreateUserRequest{
+			FirstName: "Michael",
+			LastName:  "Jackson",
+			Nickname:  "mj",
+			Email:     "mj@foo.bar",
+			Password:  "some-passw0rd",
+			Country:   "US",
+			Birthdate: "2015-03-01",
+		})
+
+		assert.Nil(t, observed)
+		assert.Equal(t, ErrUnderMinimumAge, err)
 	})
 }
 
@@ -130,21 +189,202 @@ func TestUpdateUser(t *testing.T) {
 	t.Run("when the email is already in use", func(t *testing.T) {
 		t.SkipNow()
 	})
+
+	t.Run("when a password is given", func(t *testing.T) {
+		svc := &serviceMock{
+			UpdateFunc: func(ctx context.Context, user *service.User) (*service.User, error) {
+				t.Fatal("the password must be changed with ChangePassword")
+				return nil, nil
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.UpdateUser(context.TODO(), &apiv1.UpdateUserRequest{
+			Id:        uuid.New().String(),
+			FirstName: "John",
+			LastName:  "Doe",
+			Nickname:  "jdoe",
+			Email:     "john@foo.bar",
+			Password:  "password2!",
+			Country:   "US",
+		})
+
+		assert.Nil(t, observed)
+		assertStatusHelper(t, ErrPasswordNotUpdatable, err)
+		assert.Equal(t, []string{"password"}, fieldViolationsHelper(t, err))
+	})
 }
 
 func TestListUser(t *testing.T) {
 	t.Parallel()
 
 	t.Run("happy path", func(t *testing.T) {
-		t.SkipNow()
+		createdAfter := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+		id := uuid.New().String()
+
+		svc := &serviceMock{
+			FetchAllFunc: func(ctx context.Context, filter service.FilterParams, pag service.PaginationParams) ([]*service.User, error) {
+				require.NotNil(t, filter.Country)
+				require.NotNil(t, filter.NicknamePrefix)
+				require.NotNil(t, filter.CreatedAfter)
+
+				assert.Equal(t, "BR", *filter.Country)
+				assert.Equal(t, "jo", *filter.NicknamePrefix)
+				assert.Equal(t, createdAfter, *filter.CreatedAfter)
+				assert.Nil(t, filter.Email)
+				assert.Nil(t, filter.CreatedBefore)
+				assert.Equal(t, service.PaginationParams{Limit: 1}, pag)
+
+				return []*service.User{{ID: id, Nickname: "joedoe", Country: "BR"}}, nil
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.ListUsers(context.TODO(), &apiv1.ListUsersRequest{
+			Country:        "BR",
+			NicknamePrefix: "jo",
+			CreatedAfter:   timestamppb.New(createdAfter),
+			PageSize:       1,
+		})
+		require.NoError(t, err)
+
+		require.Len(t, observed.Users, 1)
+		assert.Equal(t, id, observed.NextPageToken)
+		assert.Nil(t, observed.TotalSize)
+	})
+
+	t.Run("with the total size", func(t *testing.T) {
+		svc := &serviceMock{
+			FetchAllFunc: func(ctx context.Context, filter service.FilterParams, pag service.PaginationParams) ([]*service.User, error) {
+				return []*service.User{{ID: uuid.New().String(), Country: "BR"}}, nil
+			},
+			CountFunc: func(ctx context.Context, filter service.FilterParams) (int64, error) {
+				require.NotNil(t, filter.Country)
+				assert.Equal(t, "BR", *filter.Country)
+				return 42, nil
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.ListUsers(context.TODO(), &apiv1.ListUsersRequest{
+			Country:          "BR",
+			IncludeTotalSize: true,
+		})
+		require.NoError(t, err)
+
+		require.NotNil(t, observed.TotalSize)
+		assert.Equal(t, int64(42), *observed.TotalSize)
 	})
 
 	t.Run("when the service returns an error", func(t *testing.T) {
-		t.SkipNow()
+		svc := &serviceMock{
+			FetchAllFunc: func(ctx context.Context, filter service.FilterParams, pag service.PaginationParams) ([]*service.User, error) {
+				return nil, service.ErrCreatedRangeInvalid
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.ListUsers(context.TODO(), &apiv1.ListUsersRequest{
+			CreatedAfter:  timestamppb.New(time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC)),
+			CreatedBefore: timestamppb.New(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)),
+		})
+
+		assert.Nil(t, observed)
+		assert.Equal(t, ErrCreatedRangeInvalid, err)
 	})
 
 	t.Run("when the request is invalid", func(t *testing.T) {
-		t.SkipNow()
+		server := NewGRPCServer(zap.NewNop(), &serviceMock{})
+
+		observed, err := server.ListUsers(context.TODO(), &apiv1.ListUsersRequest{
+			CreatedBefore: &timestamppb.Timestamp{Nanos: -1},
+		})
+
+		assert.Nil(t, observed)
+		assert.Equal(t, ErrCreatedRangeInvalid, err)
+	})
+}
+
+func TestSearchUsers(t *testing.T) {
+	t.Parallel()
+
+	t.Run("happy path", func(t *testing.T) {
+		svc := &serviceMock{
+			SearchFunc: func(ctx context.Context, query string, offset, limit int) ([]*service.User, error) {
+				assert.Equal(t, "john do", query)
+				assert.Equal(t, 2, offset)
+				assert.Equal(t, 1, limit)
+				return []*service.User{{ID: uuid.New().String(), FirstName: "John", LastName: "Doe"}}, nil
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.SearchUsers(context.TODO(), &apiv1.SearchUsersRequest{
+			Query:     "john do",
+			PageSize:  1,
+			PageToken: "2",
+		})
+		require.NoError(t, err)
+
+		require.Len(t, observed.Users, 1)
+		assert.Equal(t, "John", observed.Users[0].FirstName)
+		assert.Equal(t, "3", observed.NextPageToken)
+	})
+
+	t.Run("when the request is invalid", func(t *testing.T) {
+		testCases := []struct {
+			name        string
+			givenReq    *apiv1.SearchUsersRequest
+			expectedErr error
+		}{
+			{
+				name:        "empty query",
+				givenReq:    &apiv1.SearchUsersRequest{Query: " "},
+				expectedErr: ErrSearchQueryRequired,
+			},
+			{
+				name:        "query too long",
+				givenReq:    &apiv1.SearchUsersRequest{Query: strings.Repeat("a", maxSearchQueryLength+1)},
+				expectedErr: ErrSearchQueryInvalid,
+			},
+			{
+				name:        "invalid page token",
+				givenReq:    &apiv1.SearchUsersRequest{Query: "john", PageToken: "some-token"},
+				expectedErr: ErrPageTokenInvalid,
+			},
+		}
+
+		for _, tc := range testCases {
+			tc := tc
+			t.Run(tc.name, func(t *testing.T) {
+				server := NewGRPCServer(zap.NewNop(), &serviceMock{})
+
+				observed, err := server.SearchUsers(context.TODO(), tc.givenReq)
+
+				assert.Nil(t, observed)
+				assertStatusHelper(t, tc.expectedErr, err)
+			})
+		}
+	})
+
+	t.Run("when the service rejects the query", func(t *testing.T) {
+		svc := &serviceMock{
+			SearchFunc: func(ctx context.Context, query string, offset, limit int) ([]*service.User, error) {
+				return nil, service.ErrSearchQueryInvalid
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.SearchUsers(context.TODO(), &apiv1.SearchUsersRequest{Query: "!!"})
+
+		assert.Nil(t, observed)
+		assert.Equal(t, ErrSearchQueryInvalid, err)
 	})
 }
 
@@ -168,50 +408,2143 @@ func TestDeleteUser(t *testing.T) {
 	})
 }
 
-func TestNewUserResponseFromDomain(t *testing.T) {
+func TestAnonymizeUser(t *testing.T) {
 	t.Parallel()
 
-	id := uuid.New().String()
+	t.Run("happy path", func(t *testing.T) {
+		id := uuid.New().String()
 
-	testCases := []struct {
-		name     string
-		given    *service.User
-		expected *apiv1.User
-	}{
-		{
-			name: "happy path",
-			given: &service.User{
-				ID:        id,
-				FirstName: "Michael",
-				LastName:  "Jackson",
-				Nickname:  "mj",
-				Email:     "mj@foo.bar",
-				Country:   "US",
-				CreatedAt: time.Time{}.Add(1 * time.Second),
-				UpdatedAt: time.Time{}.Add(2 * time.Second),
+		svc := &serviceMock{
+			AnonymizeUserFunc: func(ctx context.Context, userID string) (*service.User, error) {
+				assert.Equal(t, id, userID)
+				return &service.User{
+					ID:         userID,
+					FirstName:  "Anonymized",
+					LastName:   "User",
+					Nickname:   "anonymized-" + userID,
+					Email:      userID + "@anonymized.invalid",
+					Country:    "US",
+					Anonymized: true,
+				}, nil
 			},
-			expected: &apiv1.User{
-				Id:        id,
-				FirstName: "Michael",
-				LastName:  "Jackson",
-				Nickname:  "mj",
-				Email:     "mj@foo.bar",
-				Country:   "US",
-				CreatedAt: timestamppb.New(time.Time{}.Add(1 * time.Second)),
-				UpdatedAt: timestamppb.New(time.Time{}.Add(2 * time.Second)),
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.AnonymizeUser(context.TODO(), &apiv1.AnonymizeUserRequest{Id: id})
+		require.NoError(t, err)
+
+		assert.Equal(t, id, observed.User.Id)
+		assert.Equal(t, id+"@anonymized.invalid", observed.User.Email)
+		assert.True(t, observed.User.Anonymized)
+	})
+
+	t.Run("when the user is already anonymized", func(t *testing.T) {
+		svc := &serviceMock{
+			AnonymizeUserFunc: func(ctx context.Context, userID string) (*service.User, error) {
+				return nil, service.ErrUserAnonymized
 			},
-		},
-		{
-			name:     "nil user",
-			given:    nil,
-			expected: nil,
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.AnonymizeUser(context.TODO(), &apiv1.AnonymizeUserRequest{Id: uuid.New().String()})
+
+		assert.Nil(t, observed)
+		assert.Equal(t, ErrUserAnonymized, err)
+	})
+
+	t.Run("when the request is invalid", func(t *testing.T) {
+		server := NewGRPCServer(zap.NewNop(), &serviceMock{})
+
+		observed, err := server.AnonymizeUser(context.TODO(), &apiv1.AnonymizeUserRequest{Id: "not-a-uuid"})
+
+		assert.Nil(t, observed)
+		assertStatusHelper(t, ErrIDFormat, err)
+	})
+}
+
+func TestSuspendUser(t *testing.T) {
+	t.Parallel()
+
+	t.Run("happy path", func(t *testing.T) {
+		id := uuid.New().String()
+
+		svc := &serviceMock{
+			SuspendUserFunc: func(ctx context.Context, userID string) (*service.User, error) {
+				assert.Equal(t, id, userID)
+				return &service.User{ID: userID, Status: service.StatusSuspended}, nil
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.SuspendUser(context.TODO(), &apiv1.SuspendUserRequest{Id: id})
+		require.NoError(t, err)
+
+		assert.Equal(t, id, observed.User.Id)
+		assert.Equal(t, "suspended", observed.User.Status)
+	})
+
+	t.Run("when the user is not found", func(t *testing.T) {
+		svc := &serviceMock{
+			SuspendUserFunc: func(ctx context.Context, userID string) (*service.User, error) {
+				return nil, service.ErrUserNotFound
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.SuspendUser(context.TODO(), &apiv1.SuspendUserRequest{Id: uuid.New().String()})
+
+		assert.Nil(t, observed)
+		assert.Equal(t, ErrUserNotFound, err)
+	})
+
+	t.Run("when the request is invalid", func(t *testing.T) {
+		server := NewGRPCServer(zap.NewNop(), &serviceMock{})
+
+		observed, err := server.SuspendUser(context.TODO(), &apiv1.SuspendUserRequest{Id: "not-a-uuid"})
+
+		assert.Nil(t, observed)
+		assertStatusHelper(t, ErrIDFormat, err)
+	})
+}
+
+func TestDeactivateUser(t *testing.T) {
+	t.Parallel()
+
+	id := uuid.New().String()
+
+	svc := &serviceMock{
+		DeactivateUserFunc: func(ctx context.Context, userID string) (*service.User, error) {
+			assert.Equal(t, id, userID)
+			return &service.User{ID: userID, Status: service.StatusDeactivated}, nil
 		},
 	}
 
-	for _, tc := range testCases {
-		t.Run(tc.name, func(t *testing.T) {
-			observed := newUserResponseFromDomain(tc.given)
-			assert.Equal(t, tc.expected, observed)
-		})
+	server := NewGRPCServer(zap.NewNop(), svc)
+
+	observed, err := server.DeactivateUser(context.TODO(), &apiv1.DeactivateUserRequest{Id: id})
+	require.NoError(t, err)
+
+	assert.Equal(t, "deactivated", observed.User.Status)
+}
+
+func TestReactivateUser(t *testing.T) {
+	t.Parallel()
+
+	t.Run("happy path", func(t *testing.T) {
+		id := uuid.New().String()
+
+		svc := &serviceMock{
+			ReactivateUserFunc: func(ctx context.Context, userID string) (*service.User, error) {
+				assert.Equal(t, id, userID)
+				return &service.User{ID: userID, Status: service.StatusActive}, nil
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.ReactivateUser(context.TODO(), &apiv1.ReactivateUserRequest{Id: id})
+		require.NoError(t, err)
+
+		assert.Equal(t, "active", observed.User.Status)
+	})
+
+	t.Run("when the user is anonymized", func(t *testing.T) {
+		svc := &serviceMock{
+			ReactivateUserFunc: func(ctx context.Context, userID string) (*service.User, error) {
+				return nil, service.ErrUserAnonymized
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.ReactivateUser(context.TODO(), &apiv1.ReactivateUserRequest{Id: uuid.New().String()})
+
+		assert.Nil(t, observed)
+		assert.Equal(t, ErrUserAnonymized, err)
+	})
+}
+
+func TestSetAvatar(t *testing.T) {
+	t.Parallel()
+
+	t.Run("happy path", func(t *testing.T) {
+		id := uuid.New().String()
+
+		svc := &serviceMock{
+			SetAvatarFunc: func(ctx context.Context, userID string, image []byte) (*service.User, error) {
+				assert.Equal(t, id, userID)
+				assert.Equal(t, []byte("image"), image)
+				return &service.User{ID: userID, AvatarHash: "abc"}, nil
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.SetAvatar(context.TODO(), &apiv1.SetAvatarRequest{Id: id, Image: []byte("image")})
+		require.NoError(t, err)
+
+		assert.Equal(t, "abc", observed.User.AvatarHash)
+	})
+
+	t.Run("when the image is invalid", func(t *testing.T) {
+		svc := &serviceMock{
+			SetAvatarFunc: func(ctx context.Context, userID string, image []byte) (*service.User, error) {
+				return nil, fmt.Errorf("could not validate avatar of type 'text/plain': %w", service.ErrAvatarInvalid)
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.SetAvatar(context.TODO(), &apiv1.SetAvatarRequest{Id: uuid.New().String(), Image: []byte("text")})
+
+		assert.Nil(t, observed)
+		assert.Equal(t, ErrAvatarInvalid, err)
+	})
+
+	t.Run("when the request is invalid", func(t *testing.T) {
+		server := NewGRPCServer(zap.NewNop(), &serviceMock{})
+
+		observed, err := server.SetAvatar(context.TODO(), &apiv1.SetAvatarRequest{Id: "not-a-uuid"})
+
+		assert.Nil(t, observed)
+		assertStatusHelper(t, ErrIDFormat, err)
+	})
+}
+
+func TestGetAvatar(t *testing.T) {
+	t.Parallel()
+
+	t.Run("happy path", func(t *testing.T) {
+		svc := &serviceMock{
+			GetAvatarFunc: func(ctx context.Context, userID string) (*service.Avatar, error) {
+				return &service.Avatar{Image: []byte("image"), ContentType: "image/png", Hash: "abc"}, nil
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.GetAvatar(context.TODO(), &apiv1.GetAvatarRequest{Id: uuid.New().String()})
+		require.NoError(t, err)
+
+		assert.Equal(t, []byte("image"), observed.Image)
+		assert.Equal(t, "image/png", observed.ContentType)
+		assert.Equal(t, "abc", observed.Hash)
+		assert.Empty(t, observed.Url)
+	})
+
+	t.Run("when the user has no avatar", func(t *testing.T) {
+		svc := &serviceMock{
+			GetAvatarFunc: func(ctx context.Context, userID string) (*service.Avatar, error) {
+				return nil, service.ErrAvatarNotFound
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.GetAvatar(context.TODO(), &apiv1.GetAvatarRequest{Id: uuid.New().String()})
+
+		assert.Nil(t, observed)
+		assert.Equal(t, ErrAvatarNotFound, err)
+	})
+}
+
+func TestRequestPhoneVerification(t *testing.T) {
+	t.Parallel()
+
+	t.Run("happy path", func(t *testing.T) {
+		id := uuid.New().String()
+
+		svc := &serviceMock{
+			RequestPhoneVerificationFunc: func(ctx context.Context, userID string) error {
+				assert.Equal(t, id, userID)
+				return nil
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.RequestPhoneVerification(context.TODO(), &apiv1.RequestPhoneVerificationRequest{Id: id})
+		require.NoError(t, err)
+
+		assert.NotNil(t, observed)
+	})
+
+	t.Run("when the user has no phone", func(t *testing.T) {
+		svc := &serviceMock{
+			RequestPhoneVerificationFunc: func(ctx context.Context, userID string) error {
+				return service.ErrPhoneNotSet
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.RequestPhoneVerification(context.TODO(), &apiv1.RequestPhoneVerificationRequest{Id: uuid.New().String()})
+
+		assert.Nil(t, observed)
+		assert.Equal(t, ErrPhoneNotSet, err)
+	})
+
+	t.Run("when the verification is disabled", func(t *testing.T) {
+		svc := &serviceMock{
+			RequestPhoneVerificationFunc: func(ctx context.Context, userID string) error {
+				return service.ErrPhoneVerificationDisabled
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.RequestPhoneVerification(context.TODO(), &apiv1.RequestPhoneVerificationRequest{Id: uuid.New().String()})
+
+		assert.Nil(t, observed)
+		assert.Equal(t, ErrPhoneVerificationDisabled, err)
+	})
+}
+
+func TestVerifyPhone(t *testing.T) {
+	t.Parallel()
+
+	t.Run("happy path", func(t *testing.T) {
+		id := uuid.New().String()
+
+		svc := &serviceMock{
+			VerifyPhoneFunc: func(ctx context.Context, userID, code string) (*service.User, error) {
+				assert.Equal(t, id, userID)
+				assert.Equal(t, "123456", code)
+				return &service.User{ID: userID, Phone: "+14155550123", PhoneVerified: true}, nil
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.VerifyPhone(context.TODO(), &apiv1.VerifyPhoneRequest{Id: id, Code: "123456"})
+		require.NoError(t, err)
+
+		assert.Equal(t, "+14155550123", observed.User.Phone)
+		assert.True(t, observed.User.PhoneVerified)
+	})
+
+	t.Run("when the code is invalid", func(t *testing.T) {
+		svc := &serviceMock{
+			VerifyPhoneFunc: func(ctx context.Context, userID, code string) (*service.User, error) {
+				return nil, service.ErrPhoneCodeInvalid
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.VerifyPhone(context.TODO(), &apiv1.VerifyPhoneRequest{Id: uuid.New().String(), Code: "000000"})
+
+		assert.Nil(t, observed)
+		assert.Equal(t, ErrPhoneCodeInvalid, err)
+	})
+
+	t.Run("when the code is missing", func(t *testing.T) {
+		server := NewGRPCServer(zap.NewNop(), &serviceMock{})
+
+		observed, err := server.VerifyPhone(context.TODO(), &apiv1.VerifyPhoneRequest{Id: uuid.New().String()})
+
+		assert.Nil(t, observed)
+		assertStatusHelper(t, ErrPhoneCodeRequired, err)
+	})
+}
+
+// importStreamMock is an ImportUsers stream receiving the requests in turn.
+type importStreamMock struct {
+	grpc.ServerStream
+
+	ctx      context.Context
+	requests []*apiv1.ImportUsersRequest
+	resp     *apiv1.ImportUsersResponse
+}
+
+func (s *importStreamMock) Context() context.Context {
+	return s.ctx
+}
+
+func (s *importStreamMock) Recv() (*apiv1.ImportUsersRequest, error) {
+	if len(s.requests) == 0 {
+		return nil, io.EOF
 	}
+
+	req := s.requests[0]
+	s.requests = s.requests[1:]
+	return req, nil
+}
+
+func (s *importStreamMock) SendAndClose(resp *apiv1.ImportUsersResponse) error {
+	s.resp = resp
+	return nil
+}
+
+func TestImportUsers(t *testing.T) {
+	t.Parallel()
+
+	t.Run("happy path", func(t *testing.T) {
+		createdAt := time.Date(2015, 3, 1, 10, 0, 0, 0, time.UTC)
+
+		svc := &serviceMock{
+			ImportUsersFunc: func(ctx context.Context, next func() (*service.ImportRecord, error)) (*service.ImportReport, error) {
+				var report service.ImportReport
+				for index := int64(0); ; index++ {
+					record, err := next()
+					if errors.Is(err, io.EOF) {
+						return &report, nil
+					}
+					require.NoError(t, err)
+
+					if record.Err != nil {
+						report.Failed++
+						report.Errors = append(report.Errors, service.ImportError{Index: index, Email: record.User.Email, Err: record.Err})
+						continue
+					}
+
+					assert.Equal(t, "legacy", record.User.Password)
+					assert.True(t, createdAt.Equal(record.User.CreatedAt))
+					report.Imported++
+				}
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		stream := &importStreamMock{
+			ctx: context.TODO(),
+			requests: []*apiv1.ImportUsersRequest{
+				{
+					FirstName: "John",
+					LastName:  "Doe",
+					Nickname:  "jdoe",
+					Password:  "legacy",
+					Email:     "joedoe@foo.bar",
+					Country:   "US",
+					CreatedAt: timestamppb.New(createdAt),
+				},
+				{
+					FirstName: "Jane",
+					LastName:  "Doe",
+					Nickname:  "janedoe",
+					Email:     "not-an-email",
+					Country:   "US",
+				},
+			},
+		}
+
+		err := server.ImportUsers(stream)
+		require.NoError(t, err)
+
+		require.NotNil(t, stream.resp)
+		assert.Equal(t, int64(1), stream.resp.Imported)
+		assert.Equal(t, int64(1), stream.resp.Failed)
+
+		require.Len(t, stream.resp.Errors, 1)
+		assert.Equal(t, int64(1), stream.resp.Errors[0].Index)
+		assert.Equal(t, "not-an-email", stream.resp.Errors[0].Email)
+		assert.Equal(t, int32(codes.InvalidArgument), stream.resp.Errors[0].Status.Code)
+	})
+
+	t.Run("when a record is a duplicate", func(t *testing.T) {
+		svc := &serviceMock{
+			ImportUsersFunc: func(ctx context.Context, next func() (*service.ImportRecord, error)) (*service.ImportReport, error) {
+				return &service.ImportReport{
+					Failed: 1,
+					Errors: []service.ImportError{{Index: 0, Email: "joedoe@foo.bar", Err: &service.DuplicateError{Field: "email", Scope: repository.ScopeGlobal}}},
+				}, nil
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+		stream := &importStreamMock{ctx: context.TODO()}
+
+		err := server.ImportUsers(stream)
+		require.NoError(t, err)
+
+		require.Len(t, stream.resp.Errors, 1)
+		assert.Equal(t, int32(codes.AlreadyExists), stream.resp.Errors[0].Status.Code)
+	})
+
+	t.Run("when the service returns an error", func(t *testing.T) {
+		svc := &serviceMock{
+			ImportUsersFunc: func(ctx context.Context, next func() (*service.ImportRecord, error)) (*service.ImportReport, error) {
+				return &service.ImportReport{}, errors.New("some error")
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+		stream := &importStreamMock{ctx: context.TODO()}
+
+		err := server.ImportUsers(stream)
+
+		assert.Equal(t, ErrInternal, err)
+		assert.Nil(t, stream.resp)
+	})
+
+	t.Run("when the creation date is in the future", func(t *testing.T) {
+		record := newImportRecordFromRequest(context.TODO(), &apiv1.ImportUsersRequest{
+			FirstName: "John",
+			LastName:  "Doe",
+			Nickname:  "jdoe",
+			Email:     "joedoe@foo.bar",
+			Country:   "US",
+			CreatedAt: timestamppb.New(time.Now().Add(time.Hour)),
+		})
+
+		assertStatusHelper(t, ErrCreatedAtInvalid, record.Err)
+	})
+}
+
+func TestCheckNicknameAvailable(t *testing.T) {
+	t.Parallel()
+
+	t.Run("happy path", func(t *testing.T) {
+		svc := &serviceMock{
+			CheckNicknameAvailableFunc: func(ctx context.Context, nickname, country string) (bool, error) {
+				assert.Equal(t, "jdoe", nickname)
+				assert.Empty(t, country)
+				return true, nil
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.CheckNicknameAvailable(context.TODO(), &apiv1.CheckNicknameAvailableRequest{Nickname: "jdoe"})
+		require.NoError(t, err)
+
+		assert.True(t, observed.Available)
+	})
+
+	t.Run("when the country is required", func(t *testing.T) {
+		svc := &serviceMock{
+			CheckNicknameAvailableFunc: func(ctx context.Context, nickname, country string) (bool, error) {
+				return false, service.ErrCountryCodeInvalid
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.CheckNicknameAvailable(context.TODO(), &apiv1.CheckNicknameAvailableRequest{Nickname: "jdoe"})
+
+		assert.Nil(t, observed)
+		assert.Equal(t, ErrCountryCodeInvalid, err)
+	})
+
+	t.Run("when the country is invalid", func(t *testing.T) {
+		server := NewGRPCServer(zap.NewNop(), &serviceMock{})
+
+		observed, err := server.CheckNicknameAvailable(context.TODO(), &apiv1.CheckNicknameAvailableRequest{Nickname: "jdoe", Country: "XX"})
+
+		assert.Nil(t, observed)
+		assert.Error(t, err)
+	})
+}
+
+func TestAuthenticate(t *testing.T) {
+	t.Parallel()
+
+	t.Run("happy path", func(t *testing.T) {
+		id := uuid.New().String()
+
+		var authenticateFuncWasCalled bool
+		svc := &serviceMock{
+			AuthenticateFunc: func(ctx context.Context, email, password, totpCode string) (*service.User, error) {
+				authenticateFuncWasCalled = true
+				assert.Equal(t, "mj@foo.bar", email)
+				assert.Equal(t, "some-passw0rd", password)
+				return &service.User{
+					ID:      id,
+					Email:   "mj@foo.bar",
+					Country: "US",
+				}, nil
+			},
+			CreateSessionFunc: func(ctx context.Context, userID string) (*service.SessionTokens, error) {
+				assert.Equal(t, id, userID)
+				return &service.SessionTokens{
+					Session:      &service.Session{ID: "session-id", UserID: userID},
+					AccessToken:  "usrsvc_at_session-id.access",
+					RefreshToken: "usrsvc_rt_session-id.refresh",
+				}, nil
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.Authenticate(context.TODO(), &apiv1.AuthenticateRequest{
+			Email:    "mj@foo.bar",
+			Password: "some-passw0rd",
+		})
+		require.NoError(t, err)
+
+		assert.True(t, authenticateFuncWasCalled)
+		assert.Equal(t, id, observed.User.Id)
+		assert.Equal(t, "mj@foo.bar", observed.User.Email)
+
+		assert.Equal(t, "session-id", observed.Tokens.Session.Id)
+		assert.Equal(t, "usrsvc_at_session-id.access", observed.Tokens.AccessToken)
+		assert.Equal(t, "usrsvc_rt_session-id.refresh", observed.Tokens.RefreshToken)
+	})
+
+	t.Run("when the credentials are invalid", func(t *testing.T) {
+		svc := &serviceMock{
+			AuthenticateFunc: func(ctx context.Context, email, password, totpCode string) (*service.User, error) {
+				return nil, service.ErrInvalidCredentials
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.Authenticate(context.TODO(), &apiv1.AuthenticateRequest{
+			Email:    "mj@foo.bar",
+			Password: "wrong-passw0rd",
+		})
+
+		assert.Nil(t, observed)
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("when the user is locked out", func(t *testing.T) {
+		svc := &serviceMock{
+			AuthenticateFunc: func(ctx context.Context, email, password, totpCode string) (*service.User, error) {
+				return nil, fmt.Errorf("could not authenticate user: %w", &service.UserLockedError{
+					LockedUntil: time.Now().Add(time.Minute),
+				})
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.Authenticate(context.TODO(), &apiv1.AuthenticateRequest{
+			Email:    "mj@foo.bar",
+			Password: "some-passw0rd",
+		})
+
+		assert.Nil(t, observed)
+
+		st := status.Convert(err)
+		assert.Equal(t, codes.ResourceExhausted, st.Code())
+
+		require.Len(t, st.Details(), 1)
+		retryInfo, ok := st.Details()[0].(*errdetails.RetryInfo)
+		require.True(t, ok)
+		assert.InDelta(t, time.Minute, retryInfo.RetryDelay.AsDuration(), float64(5*time.Second))
+	})
+
+	t.Run("when a totp code is required", func(t *testing.T) {
+		svc := &serviceMock{
+			AuthenticateFunc: func(ctx context.Context, email, password, totpCode string) (*service.User, error) {
+				assert.Equal(t, "", totpCode)
+				return nil, fmt.Errorf("could not authenticate user: %w", service.ErrTOTPRequired)
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.Authenticate(context.TODO(), &apiv1.AuthenticateRequest{
+			Email:    "mj@foo.bar",
+			Password: "some-passw0rd",
+		})
+
+		assert.Nil(t, observed)
+
+		st := status.Convert(err)
+		assert.Equal(t, codes.Unauthenticated, st.Code())
+
+		require.Len(t, st.Details(), 1)
+		info, ok := st.Details()[0].(*errdetails.ErrorInfo)
+		require.True(t, ok)
+		assert.Equal(t, totpRequiredReason, info.Reason)
+		assert.Equal(t, errorDomain, info.Domain)
+	})
+
+	t.Run("with a totp code", func(t *testing.T) {
+		svc := &serviceMock{
+			AuthenticateFunc: func(ctx context.Context, email, password, totpCode string) (*service.User, error) {
+				assert.Equal(t, "123456", totpCode)
+				return &service.User{ID: uuid.New().String()}, nil
+			},
+			CreateSessionFunc: func(ctx context.Context, userID string) (*service.SessionTokens, error) {
+				return &service.SessionTokens{Session: &service.Session{UserID: userID}}, nil
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		_, err := server.Authenticate(context.TODO(), &apiv1.AuthenticateRequest{
+			Email:    "mj@foo.bar",
+			Password: "some-passw0rd",
+			TotpCode: "123456",
+		})
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("when the request is invalid", func(t *testing.T) {
+		server := NewGRPCServer(zap.NewNop(), &serviceMock{})
+
+		observed, err := server.Authenticate(context.TODO(), &apiv1.AuthenticateRequest{
+			Email: "mj@foo.bar",
+		})
+
+		assert.Nil(t, observed)
+		assertStatusHelper(t, ErrPasswordRequired, err)
+	})
+
+	t.Run("with an id token", func(t *testing.T) {
+		id := uuid.New().String()
+
+		svc := &serviceMock{
+			AuthenticateWithIDTokenFunc: func(ctx context.Context, provider, idToken, totpCode string) (*service.User, error) {
+				assert.Equal(t, "google", provider)
+				assert.Equal(t, "some-id-token", idToken)
+				return &service.User{ID: id}, nil
+			},
+			CreateSessionFunc: func(ctx context.Context, userID string) (*service.SessionTokens, error) {
+				return &service.SessionTokens{Session: &service.Session{UserID: userID}}, nil
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.Authenticate(context.TODO(), &apiv1.AuthenticateRequest{
+			Provider: "google",
+			IdToken:  "some-id-token",
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, id, observed.User.Id)
+	})
+
+	t.Run("with an id token but no provider", func(t *testing.T) {
+		server := NewGRPCServer(zap.NewNop(), &serviceMock{})
+
+		observed, err := server.Authenticate(context.TODO(), &apiv1.AuthenticateRequest{
+			IdToken: "some-id-token",
+		})
+
+		assert.Nil(t, observed)
+		assertStatusHelper(t, ErrIdentityProvider, err)
+	})
+}
+
+func TestLinkExternalIdentity(t *testing.T) {
+	t.Parallel()
+
+	userID := uuid.New().String()
+
+	t.Run("happy path", func(t *testing.T) {
+		svc := &serviceMock{
+			LinkIdentityFunc: func(ctx context.Context, id, provider, idToken string) (*service.LinkedIdentity, error) {
+				assert.Equal(t, userID, id)
+				return &service.LinkedIdentity{Provider: provider, Subject: "1234567890"}, nil
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.LinkExternalIdentity(context.TODO(), &apiv1.LinkExternalIdentityRequest{
+			UserId:   userID,
+			Provider: "google",
+			IdToken:  "some-id-token",
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, "google", observed.Identity.Provider)
+		assert.Equal(t, "1234567890", observed.Identity.Subject)
+	})
+
+	t.Run("already linked", func(t *testing.T) {
+		svc := &serviceMock{
+			LinkIdentityFunc: func(ctx context.Context, id, provider, idToken string) (*service.LinkedIdentity, error) {
+				return nil, service.ErrIdentityLinked
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.LinkExternalIdentity(context.TODO(), &apiv1.LinkExternalIdentityRequest{
+			UserId:   userID,
+			Provider: "google",
+			IdToken:  "some-id-token",
+		})
+
+		assert.Nil(t, observed)
+		assert.Equal(t, codes.AlreadyExists, status.Code(err))
+	})
+
+	t.Run("missing id token", func(t *testing.T) {
+		server := NewGRPCServer(zap.NewNop(), &serviceMock{})
+
+		observed, err := server.LinkExternalIdentity(context.TODO(), &apiv1.LinkExternalIdentityRequest{
+			UserId:   userID,
+			Provider: "google",
+		})
+
+		assert.Nil(t, observed)
+		assertStatusHelper(t, ErrIDTokenRequired, err)
+	})
+}
+
+func TestUnlinkExternalIdentity(t *testing.T) {
+	t.Parallel()
+
+	userID := uuid.New().String()
+
+	t.Run("happy path", func(t *testing.T) {
+		svc := &serviceMock{
+			UnlinkIdentityFunc: func(ctx context.Context, id, provider, subject string) error {
+				assert.Equal(t, userID, id)
+				assert.Equal(t, "google", provider)
+				assert.Equal(t, "1234567890", subject)
+				return nil
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.UnlinkExternalIdentity(context.TODO(), &apiv1.UnlinkExternalIdentityRequest{
+			UserId:   userID,
+			Provider: "google",
+			Subject:  "1234567890",
+		})
+		require.NoError(t, err)
+
+		assert.NotNil(t, observed)
+	})
+
+	t.Run("identity not linked", func(t *testing.T) {
+		svc := &serviceMock{
+			UnlinkIdentityFunc: func(ctx context.Context, id, provider, subject string) error {
+				return service.ErrIdentityNotFound
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.UnlinkExternalIdentity(context.TODO(), &apiv1.UnlinkExternalIdentityRequest{
+			UserId:   userID,
+			Provider: "google",
+			Subject:  "1234567890",
+		})
+
+		assert.Nil(t, observed)
+		assertStatusHelper(t, ErrIdentityNotFound, err)
+	})
+
+	t.Run("missing subject", func(t *testing.T) {
+		server := NewGRPCServer(zap.NewNop(), &serviceMock{})
+
+		observed, err := server.UnlinkExternalIdentity(context.TODO(), &apiv1.UnlinkExternalIdentityRequest{
+			UserId:   userID,
+			Provider: "google",
+		})
+
+		assert.Nil(t, observed)
+		assertStatusHelper(t, ErrSubjectRequired, err)
+	})
+}
+
+func TestGetUserByIdentity(t *testing.T) {
+	t.Parallel()
+
+	t.Run("happy path", func(t *testing.T) {
+		id := uuid.New().String()
+
+		svc := &serviceMock{
+			FetchByIdentityFunc: func(ctx context.Context, provider, subject string) (*service.User, error) {
+				assert.Equal(t, "github", provider)
+				assert.Equal(t, "42", subject)
+				return &service.User{ID: id, Email: "mj@foo.bar"}, nil
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.GetUserByIdentity(context.TODO(), &apiv1.GetUserByIdentityRequest{
+			Provider: "github",
+			Subject:  "42",
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, id, observed.User.Id)
+	})
+
+	t.Run("identity not linked", func(t *testing.T) {
+		svc := &serviceMock{
+			FetchByIdentityFunc: func(ctx context.Context, provider, subject string) (*service.User, error) {
+				return nil, service.ErrUserNotFound
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.GetUserByIdentity(context.TODO(), &apiv1.GetUserByIdentityRequest{
+			Provider: "github",
+			Subject:  "42",
+		})
+
+		assert.Nil(t, observed)
+		assertStatusHelper(t, ErrUserNotFound, err)
+	})
+
+	t.Run("missing provider", func(t *testing.T) {
+		server := NewGRPCServer(zap.NewNop(), &serviceMock{})
+
+		observed, err := server.GetUserByIdentity(context.TODO(), &apiv1.GetUserByIdentityRequest{Subject: "42"})
+
+		assert.Nil(t, observed)
+		assertStatusHelper(t, ErrIdentityProvider, err)
+	})
+}
+
+func TestChangePassword(t *testing.T) {
+	t.Parallel()
+
+	userID := uuid.New().String()
+
+	t.Run("happy path", func(t *testing.T) {
+		svc := &serviceMock{
+			ChangePasswordFunc: func(ctx context.Context, id, oldPassword, newPassword string) error {
+				assert.Equal(t, userID, id)
+				assert.Equal(t, "password1!", oldPassword)
+				assert.Equal(t, "passw0rd2?", newPassword)
+				return nil
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.ChangePassword(context.TODO(), &apiv1.ChangePasswordRequest{
+			Id:          userID,
+			OldPassword: "password1!",
+			NewPassword: "passw0rd2?",
+		})
+		require.NoError(t, err)
+
+		assert.NotNil(t, observed)
+	})
+
+	t.Run("wrong current password", func(t *testing.T) {
+		svc := &serviceMock{
+			ChangePasswordFunc: func(ctx context.Context, id, oldPassword, newPassword string) error {
+				return service.ErrInvalidCredentials
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.ChangePassword(context.TODO(), &apiv1.ChangePasswordRequest{
+			Id:          userID,
+			OldPassword: "password1!",
+			NewPassword: "passw0rd2?",
+		})
+
+		assert.Nil(t, observed)
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("missing current password", func(t *testing.T) {
+		server := NewGRPCServer(zap.NewNop(), &serviceMock{})
+
+		observed, err := server.ChangePassword(context.TODO(), &apiv1.ChangePasswordRequest{
+			Id:          userID,
+			NewPassword: "passw0rd2?",
+		})
+
+		assert.Nil(t, observed)
+		assertStatusHelper(t, ErrPasswordRequired, err)
+	})
+}
+
+func TestEnrollTOTP(t *testing.T) {
+	t.Parallel()
+
+	userID := uuid.New().String()
+
+	t.Run("happy path", func(t *testing.T) {
+		svc := &serviceMock{
+			EnrollTOTPFunc: func(ctx context.Context, id, password string) (*service.TOTPEnrollment, error) {
+				assert.Equal(t, userID, id)
+				assert.Equal(t, "password1!", password)
+				return &service.TOTPEnrollment{
+					Secret: "JBSWY3DPEHPK3PXP",
+					URI:    "otpauth://totp/usrsvc:mj@foo.bar?secret=JBSWY3DPEHPK3PXP",
+				}, nil
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.EnrollTOTP(context.TODO(), &apiv1.EnrollTOTPRequest{
+			Id:       userID,
+			Password: "password1!",
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, "JBSWY3DPEHPK3PXP", observed.Secret)
+		assert.Equal(t, "otpauth://totp/usrsvc:mj@foo.bar?secret=JBSWY3DPEHPK3PXP", observed.Uri)
+	})
+
+	t.Run("when totp is already enabled", func(t *testing.T) {
+		svc := &serviceMock{
+			EnrollTOTPFunc: func(ctx context.Context, id, password string) (*service.TOTPEnrollment, error) {
+				return nil, service.ErrTOTPEnabled
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.EnrollTOTP(context.TODO(), &apiv1.EnrollTOTPRequest{
+			Id:       userID,
+			Password: "password1!",
+		})
+
+		assert.Nil(t, observed)
+		assertStatusHelper(t, ErrTOTPEnabled, err)
+	})
+
+	t.Run("missing password", func(t *testing.T) {
+		server := NewGRPCServer(zap.NewNop(), &serviceMock{})
+
+		observed, err := server.EnrollTOTP(context.TODO(), &apiv1.EnrollTOTPRequest{Id: userID})
+
+		assert.Nil(t, observed)
+		assertStatusHelper(t, ErrPasswordRequired, err)
+	})
+}
+
+func TestVerifyTOTP(t *testing.T) {
+	t.Parallel()
+
+	userID := uuid.New().String()
+
+	t.Run("happy path", func(t *testing.T) {
+		svc := &serviceMock{
+			VerifyTOTPFunc: func(ctx context.Context, id, code string) error {
+				assert.Equal(t, userID, id)
+				assert.Equal(t, "123456", code)
+				return nil
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.VerifyTOTP(context.TODO(), &apiv1.VerifyTOTPRequest{
+			Id:   userID,
+			Code: "123456",
+		})
+		require.NoError(t, err)
+
+		assert.NotNil(t, observed)
+	})
+
+	t.Run("wrong code", func(t *testing.T) {
+		svc := &serviceMock{
+			VerifyTOTPFunc: func(ctx context.Context, id, code string) error {
+				return service.ErrTOTPInvalid
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.VerifyTOTP(context.TODO(), &apiv1.VerifyTOTPRequest{
+			Id:   userID,
+			Code: "123456",
+		})
+
+		assert.Nil(t, observed)
+		assertStatusHelper(t, ErrTOTPCodeInvalid, err)
+	})
+
+	t.Run("missing code", func(t *testing.T) {
+		server := NewGRPCServer(zap.NewNop(), &serviceMock{})
+
+		observed, err := server.VerifyTOTP(context.TODO(), &apiv1.VerifyTOTPRequest{Id: userID})
+
+		assert.Nil(t, observed)
+		assertStatusHelper(t, ErrTOTPCodeRequired, err)
+	})
+}
+
+func TestLockUserFields(t *testing.T) {
+	t.Parallel()
+
+	userID := uuid.New().String()
+
+	t.Run("happy path", func(t *testing.T) {
+		lockedAt := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+		svc := &serviceMock{
+			LockFieldsFunc: func(ctx context.Context, id string, fields []string, reason string) ([]*service.FieldLock, error) {
+				assert.Equal(t, userID, id)
+				assert.Equal(t, []string{"email"}, fields)
+				assert.Equal(t, "pending investigation", reason)
+				return []*service.FieldLock{{Field: "email", Reason: reason, LockedBy: "admin-id", LockedAt: lockedAt}}, nil
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.LockUserFields(context.TODO(), &apiv1.LockUserFieldsRequest{
+			UserId: userID,
+			Fields: []string{"email"},
+			Reason: "pending investigation",
+		})
+		require.NoError(t, err)
+
+		require.Len(t, observed.Locks, 1)
+		assert.Equal(t, "email", observed.Locks[0].Field)
+		assert.Equal(t, "admin-id", observed.Locks[0].LockedBy)
+		assert.Equal(t, timestamppb.New(lockedAt), observed.Locks[0].LockedAt)
+	})
+
+	t.Run("missing reason", func(t *testing.T) {
+		server := NewGRPCServer(zap.NewNop(), &serviceMock{})
+
+		observed, err := server.LockUserFields(context.TODO(), &apiv1.LockUserFieldsRequest{
+			UserId: userID,
+			Fields: []string{"email"},
+		})
+
+		assert.Nil(t, observed)
+		assertStatusHelper(t, ErrLockReasonRequired, err)
+	})
+
+	t.Run("field not lockable", func(t *testing.T) {
+		svc := &serviceMock{
+			LockFieldsFunc: func(ctx context.Context, id string, fields []string, reason string) ([]*service.FieldLock, error) {
+				return nil, service.ErrFieldNotLockable
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.LockUserFields(context.TODO(), &apiv1.LockUserFieldsRequest{
+			UserId: userID,
+			Fields: []string{"password"},
+			Reason: "pending investigation",
+		})
+
+		assert.Nil(t, observed)
+		assert.Equal(t, ErrFieldNotLockable, err)
+	})
+}
+
+func TestUnlockUser(t *testing.T) {
+	t.Parallel()
+
+	t.Run("happy path", func(t *testing.T) {
+		id := uuid.New().String()
+
+		var unlocked string
+		svc := &serviceMock{
+			UnlockUserFunc: func(ctx context.Context, userID string) error {
+				unlocked = userID
+				return nil
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.UnlockUser(context.TODO(), &apiv1.UnlockUserRequest{Id: id})
+		require.NoError(t, err)
+
+		assert.NotNil(t, observed)
+		assert.Equal(t, id, unlocked)
+	})
+
+	t.Run("when the user is not found", func(t *testing.T) {
+		svc := &serviceMock{
+			UnlockUserFunc: func(ctx context.Context, userID string) error {
+				return service.ErrUserNotFound
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.UnlockUser(context.TODO(), &apiv1.UnlockUserRequest{Id: uuid.New().String()})
+
+		assert.Nil(t, observed)
+		assert.Equal(t, ErrUserNotFound, err)
+	})
+
+	t.Run("when the request is invalid", func(t *testing.T) {
+		server := NewGRPCServer(zap.NewNop(), &serviceMock{})
+
+		observed, err := server.UnlockUser(context.TODO(), &apiv1.UnlockUserRequest{Id: "not-a-uuid"})
+
+		assert.Nil(t, observed)
+		assertStatusHelper(t, ErrIDFormat, err)
+	})
+}
+
+func TestRefreshToken(t *testing.T) {
+	t.Parallel()
+
+	t.Run("happy path", func(t *testing.T) {
+		svc := &serviceMock{
+			RefreshSessionFunc: func(ctx context.Context, refreshToken string) (*service.SessionTokens, error) {
+				assert.Equal(t, "usrsvc_rt_session-id.refresh", refreshToken)
+				return &service.SessionTokens{
+					Session:      &service.Session{ID: "session-id"},
+					AccessToken:  "usrsvc_at_session-id.new-access",
+					RefreshToken: "usrsvc_rt_session-id.new-refresh",
+				}, nil
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.RefreshToken(context.TODO(), &apiv1.RefreshTokenRequest{
+			RefreshToken: "usrsvc_rt_session-id.refresh",
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, "usrsvc_at_session-id.new-access", observed.Tokens.AccessToken)
+		assert.Equal(t, "usrsvc_rt_session-id.new-refresh", observed.Tokens.RefreshToken)
+	})
+
+	t.Run("when the refresh token is invalid", func(t *testing.T) {
+		svc := &serviceMock{
+			RefreshSessionFunc: func(ctx context.Context, refreshToken string) (*service.SessionTokens, error) {
+				return nil, service.ErrInvalidCredentials
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.RefreshToken(context.TODO(), &apiv1.RefreshTokenRequest{RefreshToken: "reused"})
+
+		assert.Nil(t, observed)
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("when the request is invalid", func(t *testing.T) {
+		server := NewGRPCServer(zap.NewNop(), &serviceMock{})
+
+		observed, err := server.RefreshToken(context.TODO(), &apiv1.RefreshTokenRequest{})
+
+		assert.Nil(t, observed)
+		assertStatusHelper(t, ErrRefreshTokenRequired, err)
+	})
+}
+
+func TestRevokeSession(t *testing.T) {
+	t.Parallel()
+
+	t.Run("by id", func(t *testing.T) {
+		id := uuid.New().String()
+
+		var revoked string
+		svc := &serviceMock{
+			RevokeSessionFunc: func(ctx context.Context, sessionID string) error {
+				revoked = sessionID
+				return nil
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.RevokeSession(context.TODO(), &apiv1.RevokeSessionRequest{Id: id})
+		require.NoError(t, err)
+
+		assert.NotNil(t, observed)
+		assert.Equal(t, id, revoked)
+	})
+
+	t.Run("by refresh token", func(t *testing.T) {
+		var revoked string
+		svc := &serviceMock{
+			RevokeRefreshTokenFunc: func(ctx context.Context, refreshToken string) error {
+				revoked = refreshToken
+				return nil
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.RevokeSession(context.TODO(), &apiv1.RevokeSessionRequest{RefreshToken: "usrsvc_rt_session-id.refresh"})
+		require.NoError(t, err)
+
+		assert.NotNil(t, observed)
+		assert.Equal(t, "usrsvc_rt_session-id.refresh", revoked)
+	})
+
+	t.Run("when the session is not found", func(t *testing.T) {
+		svc := &serviceMock{
+			RevokeSessionFunc: func(ctx context.Context, sessionID string) error {
+				return service.ErrSessionNotFound
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.RevokeSession(context.TODO(), &apiv1.RevokeSessionRequest{Id: uuid.New().String()})
+
+		assert.Nil(t, observed)
+		assert.Equal(t, ErrSessionNotFound, err)
+	})
+
+	t.Run("when the request is invalid", func(t *testing.T) {
+		server := NewGRPCServer(zap.NewNop(), &serviceMock{})
+
+		missing, missingErr := server.RevokeSession(context.TODO(), &apiv1.RevokeSessionRequest{})
+		malformed, malformedErr := server.RevokeSession(context.TODO(), &apiv1.RevokeSessionRequest{Id: "not-a-uuid"})
+
+		assert.Nil(t, missing)
+		assertStatusHelper(t, ErrSessionRequired, missingErr)
+		assert.Nil(t, malformed)
+		assertStatusHelper(t, ErrIDFormat, malformedErr)
+	})
+}
+
+func TestListSessions(t *testing.T) {
+	t.Parallel()
+
+	t.Run("happy path", func(t *testing.T) {
+		userID := uuid.New().String()
+
+		svc := &serviceMock{
+			SessionsFunc: func(ctx context.Context, id string) ([]*service.Session, error) {
+				assert.Equal(t, userID, id)
+				return []*service.Session{
+					{ID: "newer", UserID: id, IP: "10.0.0.1"},
+					{ID: "older", UserID: id},
+				}, nil
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.ListSessions(context.TODO(), &apiv1.ListSessionsRequest{UserId: userID})
+		require.NoError(t, err)
+
+		require.Len(t, observed.Sessions, 2)
+		assert.Equal(t, "newer", observed.Sessions[0].Id)
+		assert.Equal(t, "10.0.0.1", observed.Sessions[0].Ip)
+		assert.Equal(t, "older", observed.Sessions[1].Id)
+	})
+
+	t.Run("when the request is invalid", func(t *testing.T) {
+		server := NewGRPCServer(zap.NewNop(), &serviceMock{})
+
+		observed, err := server.ListSessions(context.TODO(), &apiv1.ListSessionsRequest{UserId: "not-a-uuid"})
+
+		assert.Nil(t, observed)
+		assertStatusHelper(t, ErrIDFormat, err)
+	})
+}
+
+func TestUpdateUserMetadata(t *testing.T) {
+	t.Parallel()
+
+	newRequest := func(metadata map[string]string) *apiv1.UpdateUserRequest {
+		return &apiv1.UpdateUserRequest{
+			Id:        uuid.New().String(),
+			FirstName: "John",
+			LastName:  "Doe",
+			Nickname:  "jdoe",
+			Email:     "john@foo.bar",
+			Country:   "US",
+			Metadata:  metadata,
+		}
+	}
+
+	t.Run("happy path", func(t *testing.T) {
+		svc := &serviceMock{
+			UpdateFunc: func(ctx context.Context, user *service.User) (*service.User, error) {
+				assert.Equal(t, map[string]string{"theme": "dark", "locale": ""}, user.Metadata)
+
+				updated := *user
+				updated.Metadata = map[string]string{"theme": "dark"}
+				return &updated, nil
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.UpdateUser(context.TODO(), newRequest(map[string]string{"theme": "dark", "locale": ""}))
+		require.NoError(t, err)
+
+		assert.Equal(t, map[string]string{"theme": "dark"}, observed.User.Metadata)
+	})
+
+	t.Run("when the metadata is invalid", func(t *testing.T) {
+		svc := &serviceMock{
+			UpdateFunc: func(ctx context.Context, user *service.User) (*service.User, error) {
+				return nil, fmt.Errorf("could not validate metadata key 'Theme': %w", service.ErrMetadataInvalid)
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.UpdateUser(context.TODO(), newRequest(map[string]string{"Theme": "dark"}))
+
+		assert.Nil(t, observed)
+		assert.Equal(t, ErrMetadataInvalid, err)
+	})
+}
+
+func TestUpdateUserLockedField(t *testing.T) {
+	t.Parallel()
+
+	svc := &serviceMock{
+		UpdateFunc: func(ctx context.Context, user *service.User) (*service.User, error) {
+			return nil, fmt.Errorf("could not update user: %w", &service.FieldLockedError{
+				Locks: []*service.FieldLock{{Field: "email", Reason: "pending investigation"}},
+			})
+		},
+	}
+
+	server := NewGRPCServer(zap.NewNop(), svc)
+
+	observed, err := server.UpdateUser(context.TODO(), &apiv1.UpdateUserRequest{
+		Id:        uuid.New().String(),
+		FirstName: "John",
+		LastName:  "Doe",
+		Nickname:  "jdoe",
+		Email:     "john@foo.bar",
+		Country:   "US",
+	})
+
+	assert.Nil(t, observed)
+
+	st := status.Convert(err)
+	assert.Equal(t, codes.FailedPrecondition, st.Code())
+
+	require.Len(t, st.Details(), 1)
+	failure, ok := st.Details()[0].(*errdetails.PreconditionFailure)
+	require.True(t, ok)
+
+	require.Len(t, failure.Violations, 1)
+	assert.Equal(t, fieldLockedViolation, failure.Violations[0].Type)
+	assert.Equal(t, "email", failure.Violations[0].Subject)
+	assert.Equal(t, "pending investigation", failure.Violations[0].Description)
+}
+
+func TestPasswordReset(t *testing.T) {
+	t.Parallel()
+
+	t.Run("request", func(t *testing.T) {
+		svc := &serviceMock{
+			RequestPasswordResetFunc: func(ctx context.Context, email string) error {
+				assert.Equal(t, "joedoe@foo.bar", email)
+				return nil
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.RequestPasswordReset(context.TODO(), &apiv1.RequestPasswordResetRequest{
+			Email: "joedoe@foo.bar",
+		})
+		require.NoError(t, err)
+
+		assert.NotNil(t, observed)
+	})
+
+	t.Run("confirm", func(t *testing.T) {
+		svc := &serviceMock{
+			ConfirmPasswordResetFunc: func(ctx context.Context, token, password string) error {
+				assert.Equal(t, "some-token", token)
+				assert.Equal(t, "password1!", password)
+				return nil
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.ConfirmPasswordReset(context.TODO(), &apiv1.ConfirmPasswordResetRequest{
+			Token:       "some-token",
+			NewPassword: "password1!",
+		})
+		require.NoError(t, err)
+
+		assert.NotNil(t, observed)
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		svc := &serviceMock{
+			ConfirmPasswordResetFunc: func(ctx context.Context, token, password string) error {
+				return service.ErrResetTokenInvalid
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.ConfirmPasswordReset(context.TODO(), &apiv1.ConfirmPasswordResetRequest{
+			Token:       "some-token",
+			NewPassword: "password1!",
+		})
+
+		assert.Nil(t, observed)
+		assert.Equal(t, ErrResetTokenInvalid, err)
+	})
+
+	t.Run("weak password", func(t *testing.T) {
+		server := NewGRPCServer(zap.NewNop(), &serviceMock{})
+
+		observed, err := server.ConfirmPasswordReset(context.TODO(), &apiv1.ConfirmPasswordResetRequest{
+			Token:       "some-token",
+			NewPassword: "password",
+		})
+
+		assert.Nil(t, observed)
+		assertStatusHelper(t, ErrPasswordFormat, err)
+	})
+}
+
+func TestListAuditEvents(t *testing.T) {
+	t.Parallel()
+
+	userID := uuid.New().String()
+
+	t.Run("happy path", func(t *testing.T) {
+		svc := &serviceMock{
+			AuditEventsFunc: func(ctx context.Context, filter audit.Filter) ([]*audit.Event, error) {
+				assert.Equal(t, audit.Filter{UserID: userID, Cursor: 42, Limit: 1}, filter)
+				return []*audit.Event{
+					{
+						ID:      41,
+						Actor:   "admin-id",
+						Action:  audit.ActionUpdate,
+						UserID:  userID,
+						Changes: map[string]audit.Change{"country": {Before: "BR", After: "PT"}},
+					},
+				}, nil
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.ListAuditEvents(context.TODO(), &apiv1.ListAuditEventsRequest{
+			UserId:    userID,
+			PageSize:  1,
+			PageToken: "42",
+		})
+		require.NoError(t, err)
+
+		require.Len(t, observed.Events, 1)
+		assert.Equal(t, "41", observed.NextPageToken)
+		assert.Equal(t, "update", observed.Events[0].Action)
+		assert.Equal(t, "PT", observed.Events[0].Changes["country"].After)
+	})
+
+	t.Run("invalid page token", func(t *testing.T) {
+		server := NewGRPCServer(zap.NewNop(), &serviceMock{})
+
+		observed, err := server.ListAuditEvents(context.TODO(), &apiv1.ListAuditEventsRequest{
+			PageToken: "some-token",
+		})
+
+		assert.Nil(t, observed)
+		assert.Equal(t, ErrPageTokenInvalid, err)
+	})
+
+	t.Run("audit log disabled", func(t *testing.T) {
+		svc := &serviceMock{
+			AuditEventsFunc: func(ctx context.Context, filter audit.Filter) ([]*audit.Event, error) {
+				return nil, service.ErrAuditDisabled
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.ListAuditEvents(context.TODO(), &apiv1.ListAuditEventsRequest{})
+
+		assert.Nil(t, observed)
+		assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	})
+}
+
+func TestListUserChanges(t *testing.T) {
+	t.Parallel()
+
+	userID := uuid.New().String()
+
+	t.Run("happy path", func(t *testing.T) {
+		svc := &serviceMock{
+			ListUserChangesFunc: func(ctx context.Context, since int64, limit int) ([]*service.UserChange, error) {
+				assert.Equal(t, int64(42), since)
+				assert.Equal(t, 2, limit)
+				return []*service.UserChange{
+					{ID: 43, Type: changes.TypeUpdated, UserID: userID, User: &service.User{ID: userID, Country: "PT"}},
+					{ID: 44, Type: changes.TypeDeleted, UserID: userID},
+				}, nil
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.ListUserChanges(context.TODO(), &apiv1.ListUserChangesRequest{
+			SinceToken: "42",
+			PageSize:   2,
+		})
+		require.NoError(t, err)
+
+		require.Len(t, observed.Changes, 2)
+		assert.Equal(t, "44", observed.NextToken)
+		assert.Equal(t, "updated", observed.Changes[0].Type)
+		assert.Equal(t, "PT", observed.Changes[0].User.Country)
+		assert.Equal(t, "deleted", observed.Changes[1].Type)
+		assert.Nil(t, observed.Changes[1].User)
+	})
+
+	t.Run("no new changes keeps the token", func(t *testing.T) {
+		svc := &serviceMock{
+			ListUserChangesFunc: func(ctx context.Context, since int64, limit int) ([]*service.UserChange, error) {
+				assert.Equal(t, int(defaultPageSize), limit)
+				return nil, nil
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.ListUserChanges(context.TODO(), &apiv1.ListUserChangesRequest{SinceToken: "7"})
+		require.NoError(t, err)
+
+		assert.Empty(t, observed.Changes)
+		assert.Equal(t, "7", observed.NextToken)
+	})
+
+	t.Run("invalid since token", func(t *testing.T) {
+		server := NewGRPCServer(zap.NewNop(), &serviceMock{})
+
+		observed, err := server.ListUserChanges(context.TODO(), &apiv1.ListUserChangesRequest{
+			SinceToken: "-1",
+		})
+
+		assert.Nil(t, observed)
+		assert.Equal(t, ErrSinceTokenInvalid, err)
+	})
+
+	t.Run("change log disabled", func(t *testing.T) {
+		svc := &serviceMock{
+			ListUserChangesFunc: func(ctx context.Context, since int64, limit int) ([]*service.UserChange, error) {
+				return nil, service.ErrChangeLogDisabled
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.ListUserChanges(context.TODO(), &apiv1.ListUserChangesRequest{})
+
+		assert.Nil(t, observed)
+		assert.Equal(t, ErrChangeLogDisabled, err)
+	})
+}
+
+func TestGetUserStats(t *testing.T) {
+	t.Parallel()
+
+	t.Run("happy path", func(t *testing.T) {
+		refreshedAt := time.Time{}.Add(1 * time.Second)
+
+		svc := &serviceMock{
+			StatsFunc: func(ctx context.Context) (*service.UserStats, error) {
+				return &service.UserStats{
+					CountByCountry: map[string]int64{"US": 3, "BR": 2},
+					RefreshedAt:    refreshedAt,
+				}, nil
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.GetUserStats(context.TODO(), &apiv1.GetUserStatsRequest{})
+		require.NoError(t, err)
+
+		require.Len(t, observed.Countries, 2)
+		assert.Equal(t, "BR", observed.Countries[0].Country)
+		assert.Equal(t, int64(2), observed.Countries[0].Count)
+		assert.Equal(t, "US", observed.Countries[1].Country)
+		assert.Equal(t, int64(3), observed.Countries[1].Count)
+		assert.Equal(t, int64(5), observed.Total)
+		assert.Equal(t, timestamppb.New(refreshedAt), observed.RefreshedAt)
+	})
+
+	t.Run("when the service fails", func(t *testing.T) {
+		svc := &serviceMock{
+			StatsFunc: func(ctx context.Context) (*service.UserStats, error) {
+				return nil, errors.New("some error")
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.GetUserStats(context.TODO(), &apiv1.GetUserStatsRequest{})
+
+		assert.Nil(t, observed)
+		assert.Equal(t, ErrInternal, err)
+	})
+}
+
+func TestFindDuplicateUsers(t *testing.T) {
+	t.Parallel()
+
+	t.Run("happy path", func(t *testing.T) {
+		generatedAt := time.Time{}.Add(1 * time.Second)
+
+		svc := &serviceMock{
+			FindDuplicatesFunc: func(ctx context.Context, filter service.FilterParams) (*service.DuplicateReport, error) {
+				require.NotNil(t, filter.Country)
+				assert.Equal(t, "BR", *filter.Country)
+
+				return &service.DuplicateReport{
+					Candidates: []service.DuplicateCandidate{
+						{
+							Survivor:  &service.User{ID: "survivor"},
+							Duplicate: &service.User{ID: "duplicate"},
+							Score:     1,
+							Reasons:   []string{"same name and country", "same normalized email"},
+						},
+					},
+					ScannedUsers: 10,
+					GeneratedAt:  generatedAt,
+				}, nil
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.FindDuplicateUsers(context.TODO(), &apiv1.FindDuplicateUsersRequest{Country: "BR"})
+		require.NoError(t, err)
+
+		require.Len(t, observed.Candidates, 1)
+		assert.Equal(t, "survivor", observed.Candidates[0].Survivor.Id)
+		assert.Equal(t, "duplicate", observed.Candidates[0].Duplicate.Id)
+		assert.Equal(t, float64(1), observed.Candidates[0].Score)
+		assert.Equal(t, []string{"same name and country", "same normalized email"}, observed.Candidates[0].Reasons)
+		assert.Equal(t, int64(10), observed.ScannedUsers)
+		assert.Equal(t, timestamppb.New(generatedAt), observed.GeneratedAt)
+	})
+
+	t.Run("invalid country", func(t *testing.T) {
+		server := NewGRPCServer(zap.NewNop(), &serviceMock{})
+
+		observed, err := server.FindDuplicateUsers(context.TODO(), &apiv1.FindDuplicateUsersRequest{Country: "BRA"})
+
+		assert.Nil(t, observed)
+		assert.Equal(t, ErrCountryCodeInvalid, err)
+	})
+
+	t.Run("when the service fails", func(t *testing.T) {
+		svc := &serviceMock{
+			FindDuplicatesFunc: func(ctx context.Context, filter service.FilterParams) (*service.DuplicateReport, error) {
+				return nil, errors.New("some error")
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.FindDuplicateUsers(context.TODO(), &apiv1.FindDuplicateUsersRequest{})
+
+		assert.Nil(t, observed)
+		assert.Equal(t, ErrInternal, err)
+	})
+}
+
+func TestDeleteUsersByFilter(t *testing.T) {
+	t.Parallel()
+
+	t.Run("happy path", func(t *testing.T) {
+		createdBefore := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+		svc := &serviceMock{
+			DeleteUsersByFilterFunc: func(ctx context.Context, filter service.BulkDeleteFilter, dryRun bool) (*service.BulkDeleteReport, error) {
+				require.NotNil(t, filter.Country)
+				require.NotNil(t, filter.CreatedBefore)
+				assert.Equal(t, "BR", *filter.Country)
+				assert.True(t, createdBefore.Equal(*filter.CreatedBefore))
+				assert.True(t, dryRun)
+
+				return &service.BulkDeleteReport{Scanned: 10, Matched: 7, DryRun: true}, nil
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.DeleteUsersByFilter(context.TODO(), &apiv1.DeleteUsersByFilterRequest{
+			Country:       "BR",
+			CreatedBefore: timestamppb.New(createdBefore),
+			DryRun:        true,
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, int64(10), observed.ScannedUsers)
+		assert.Equal(t, int64(7), observed.MatchedUsers)
+		assert.Equal(t, int64(0), observed.DeletedUsers)
+		assert.True(t, observed.DryRun)
+	})
+
+	testCases := []struct {
+		name        string
+		given       *apiv1.DeleteUsersByFilterRequest
+		expectedErr error
+	}{
+		{
+			name:        "without filter",
+			given:       &apiv1.DeleteUsersByFilterRequest{DryRun: true},
+			expectedErr: ErrFilterRequired,
+		},
+		{
+			name:        "invalid country",
+			given:       &apiv1.DeleteUsersByFilterRequest{Country: "BRA"},
+			expectedErr: ErrCountryCodeInvalid,
+		},
+		{
+			name:        "zero creation time",
+			given:       &apiv1.DeleteUsersByFilterRequest{CreatedBefore: &timestamppb.Timestamp{}},
+			expectedErr: ErrCreatedRangeInvalid,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			server := NewGRPCServer(zap.NewNop(), &serviceMock{})
+
+			observed, err := server.DeleteUsersByFilter(context.TODO(), tc.given)
+
+			assert.Nil(t, observed)
+			assert.Equal(t, tc.expectedErr, err)
+		})
+	}
+
+	t.Run("when the service fails", func(t *testing.T) {
+		svc := &serviceMock{
+			DeleteUsersByFilterFunc: func(ctx context.Context, filter service.BulkDeleteFilter, dryRun bool) (*service.BulkDeleteReport, error) {
+				return nil, errors.New("some error")
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.DeleteUsersByFilter(context.TODO(), &apiv1.DeleteUsersByFilterRequest{Country: "BR"})
+
+		assert.Nil(t, observed)
+		assert.Equal(t, ErrInternal, err)
+	})
+}
+
+func TestMergeUsers(t *testing.T) {
+	t.Parallel()
+
+	survivorID, duplicateID := uuid.New().String(), uuid.New().String()
+
+	t.Run("happy path", func(t *testing.T) {
+		svc := &serviceMock{
+			MergeFunc: func(ctx context.Context, params service.MergeParams) (*service.User, error) {
+				assert.Equal(t, service.MergeParams{
+					SurvivorID:         survivorID,
+					DuplicateID:        duplicateID,
+					KeepDuplicateEmail: true,
+				}, params)
+
+				return &service.User{ID: survivorID, Email: "joe.doe@foo.bar"}, nil
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.MergeUsers(context.TODO(), &apiv1.MergeUsersRequest{
+			SurvivorId:         survivorID,
+			DuplicateId:        duplicateID,
+			KeepDuplicateEmail: true,
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, survivorID, observed.User.Id)
+		assert.Equal(t, "joe.doe@foo.bar", observed.User.Email)
+	})
+
+	t.Run("invalid duplicate id", func(t *testing.T) {
+		server := NewGRPCServer(zap.NewNop(), &serviceMock{})
+
+		observed, err := server.MergeUsers(context.TODO(), &apiv1.MergeUsersRequest{
+			SurvivorId:  survivorID,
+			DuplicateId: "foo",
+		})
+
+		assert.Nil(t, observed)
+		assertStatusHelper(t, ErrIDFormat, err)
+	})
+
+	t.Run("same user", func(t *testing.T) {
+		svc := &serviceMock{
+			MergeFunc: func(ctx context.Context, params service.MergeParams) (*service.User, error) {
+				return nil, service.ErrMergeSameUser
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.MergeUsers(context.TODO(), &apiv1.MergeUsersRequest{
+			SurvivorId:  survivorID,
+			DuplicateId: survivorID,
+		})
+
+		assert.Nil(t, observed)
+		assert.Equal(t, ErrMergeSameUser, err)
+	})
+}
+
+func TestBootstrap(t *testing.T) {
+	t.Parallel()
+
+	givenAdmin := &apiv1.CreateUserRequest{
+		FirstName: "Ada",
+		LastName:  "Admin",
+		Nickname:  "admin",
+		Email:     "admin@foo.bar",
+		Password:  "some-passw0rd!",
+		Country:   "BR",
+	}
+
+	t.Run("happy path", func(t *testing.T) {
+		id := uuid.New().String()
+
+		svc := &serviceMock{
+			BootstrapFunc: func(ctx context.Context, token string, admin *service.User, keyName string) (*service.User, string, error) {
+				assert.Equal(t, "one-time-token", token)
+				assert.Equal(t, "admin@foo.bar", admin.Email)
+				assert.Equal(t, defaultBootstrapKeyName, keyName)
+
+				admin.ID = id
+				return admin, "usrsvc_key", nil
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.Bootstrap(context.TODO(), &apiv1.BootstrapRequest{
+			Token: "one-time-token",
+			Admin: givenAdmin,
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, id, observed.Admin.Id)
+		assert.Equal(t, "usrsvc_key", observed.ApiKey)
+	})
+
+	t.Run("missing token", func(t *testing.T) {
+		server := NewGRPCServer(zap.NewNop(), &serviceMock{})
+
+		observed, err := server.Bootstrap(context.TODO(), &apiv1.BootstrapRequest{Admin: givenAdmin})
+
+		assert.Nil(t, observed)
+		assertStatusHelper(t, ErrBootstrapToken, err)
+	})
+
+	t.Run("already bootstrapped", func(t *testing.T) {
+		svc := &serviceMock{
+			BootstrapFunc: func(ctx context.Context, token string, admin *service.User, keyName string) (*service.User, string, error) {
+				return nil, "", service.ErrAlreadyBootstrapped
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.Bootstrap(context.TODO(), &apiv1.BootstrapRequest{
+			Token: "one-time-token",
+			Admin: givenAdmin,
+		})
+
+		assert.Nil(t, observed)
+		assert.Equal(t, ErrAlreadyBootstrapped, err)
+	})
+}
+
+func TestCreateAPIKey(t *testing.T) {
+	t.Parallel()
+
+	userID := uuid.New().String()
+
+	t.Run("happy path", func(t *testing.T) {
+		svc := &serviceMock{
+			CreateAPIKeyFunc: func(ctx context.Context, id, name string, scopes []string) (*service.APIKey, string, error) {
+				assert.Equal(t, userID, id)
+				assert.Equal(t, "billing", name)
+				assert.Equal(t, []string{service.ScopeUsersRead}, scopes)
+				return &service.APIKey{ID: "key-id", UserID: id, Name: name, Scopes: scopes}, "usrsvc_key-id.secret", nil
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.CreateAPIKey(context.TODO(), &apiv1.CreateAPIKeyRequest{
+			UserId: userID,
+			Name:   "billing",
+			Scopes: []string{service.ScopeUsersRead},
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, "usrsvc_key-id.secret", observed.Key)
+		assert.Equal(t, "key-id", observed.ApiKey.Id)
+		assert.Equal(t, []string{service.ScopeUsersRead}, observed.ApiKey.Scopes)
+	})
+
+	t.Run("unknown scopes", func(t *testing.T) {
+		svc := &serviceMock{
+			CreateAPIKeyFunc: func(ctx context.Context, id, name string, scopes []string) (*service.APIKey, string, error) {
+				return nil, "", service.ErrAPIKeyScopesInvalid
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.CreateAPIKey(context.TODO(), &apiv1.CreateAPIKeyRequest{
+			UserId: userID,
+			Name:   "billing",
+			Scopes: []string{"users:admin"},
+		})
+
+		assert.Nil(t, observed)
+		assertStatusHelper(t, ErrAPIKeyScopesInvalid, err)
+	})
+
+	t.Run("missing scopes", func(t *testing.T) {
+		server := NewGRPCServer(zap.NewNop(), &serviceMock{})
+
+		observed, err := server.CreateAPIKey(context.TODO(), &apiv1.CreateAPIKeyRequest{
+			UserId: userID,
+			Name:   "billing",
+		})
+
+		assert.Nil(t, observed)
+		assertStatusHelper(t, ErrAPIKeyScopesRequired, err)
+	})
+
+	t.Run("missing name", func(t *testing.T) {
+		server := NewGRPCServer(zap.NewNop(), &serviceMock{})
+
+		observed, err := server.CreateAPIKey(context.TODO(), &apiv1.CreateAPIKeyRequest{
+			UserId: userID,
+			Scopes: []string{service.ScopeUsersRead},
+		})
+
+		assert.Nil(t, observed)
+		assertStatusHelper(t, ErrAPIKeyNameRequired, err)
+	})
+}
+
+func TestRevokeAPIKey(t *testing.T) {
+	t.Parallel()
+
+	keyID := uuid.New().String()
+
+	t.Run("happy path", func(t *testing.T) {
+		svc := &serviceMock{
+			RevokeAPIKeyFunc: func(ctx context.Context, id string) error {
+				assert.Equal(t, keyID, id)
+				return nil
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.RevokeAPIKey(context.TODO(), &apiv1.RevokeAPIKeyRequest{Id: keyID})
+		require.NoError(t, err)
+
+		assert.NotNil(t, observed)
+	})
+
+	t.Run("key not found", func(t *testing.T) {
+		svc := &serviceMock{
+			RevokeAPIKeyFunc: func(ctx context.Context, id string) error {
+				return service.ErrAPIKeyNotFound
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.RevokeAPIKey(context.TODO(), &apiv1.RevokeAPIKeyRequest{Id: keyID})
+
+		assert.Nil(t, observed)
+		assertStatusHelper(t, ErrAPIKeyNotFound, err)
+	})
+}
+
+func TestListAPIKeys(t *testing.T) {
+	t.Parallel()
+
+	t.Run("happy path", func(t *testing.T) {
+		userID := uuid.New().String()
+
+		svc := &serviceMock{
+			APIKeysFunc: func(ctx context.Context, id string) ([]*service.APIKey, error) {
+				assert.Equal(t, userID, id)
+				return []*service.APIKey{
+					{ID: "older", UserID: id, Name: "billing", Scopes: []string{service.ScopeUsersRead}},
+					{ID: "newer", UserID: id, Name: "legacy"},
+				}, nil
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.ListAPIKeys(context.TODO(), &apiv1.ListAPIKeysRequest{UserId: userID})
+		require.NoError(t, err)
+
+		require.Len(t, observed.ApiKeys, 2)
+		assert.Equal(t, "older", observed.ApiKeys[0].Id)
+		assert.Equal(t, []string{service.ScopeUsersRead}, observed.ApiKeys[0].Scopes)
+		assert.Equal(t, "newer", observed.ApiKeys[1].Id)
+	})
+
+	t.Run("when the request is invalid", func(t *testing.T) {
+		server := NewGRPCServer(zap.NewNop(), &serviceMock{})
+
+		observed, err := server.ListAPIKeys(context.TODO(), &apiv1.ListAPIKeysRequest{UserId: "not-a-uuid"})
+
+		assert.Nil(t, observed)
+		assertStatusHelper(t, ErrIDFormat, err)
+	})
+}
+
+func TestCheckHeath(t *testing.T) {
+	t.Parallel()
+
+	healthy := &serviceMock{
+		CheckServiceHealthFunc: func(ctx context.Context) error {
+			return nil
+		},
+	}
+
+	t.Run("serving", func(t *testing.T) {
+		server := NewGRPCServer(zap.NewNop(), healthy, WithDrain(NewDrain(time.Second)))
+
+		observed, err := server.CheckHeath(context.TODO(), &apiv1.HealthCheckRequest{})
+		require.NoError(t, err)
+
+		assert.Equal(t, apiv1.HealthCheckResponse_SERVING, observed.Status)
+	})
+
+	t.Run("unhealthy", func(t *testing.T) {
+		svc := &serviceMock{
+			CheckServiceHealthFunc: func(ctx context.Context) error {
+				return errors.New("some error")
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		observed, err := server.CheckHeath(context.TODO(), &apiv1.HealthCheckRequest{})
+		require.NoError(t, err)
+
+		assert.Equal(t, apiv1.HealthCheckResponse_NOT_SERVING, observed.Status)
+	})
+
+	t.Run("draining", func(t *testing.T) {
+		drain := NewDrain(time.Second)
+		drain.Start()
+
+		server := NewGRPCServer(zap.NewNop(), healthy, WithDrain(drain))
+
+		observed, err := server.CheckHeath(context.TODO(), &apiv1.HealthCheckRequest{})
+		require.NoError(t, err)
+
+		assert.Equal(t, apiv1.HealthCheckResponse_NOT_SERVING, observed.Status)
+	})
 }
@@ -0,0 +1,354 @@
+package app
+
+import (
+	"context"
+
+	"github.com/alesr/usrsvc/internal/users/service"
+	apiv1 "github.com/alesr/usrsvc/proto/users/v1"
+	apiv2 "github.com/alesr/usrsvc/proto/users/v2"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+var (
+	rolesV2 = map[string]apiv2.Role{
+		service.RoleUser:  apiv2.Role_ROLE_USER,
+		service.RoleAdmin: apiv2.Role_ROLE_ADMIN,
+	}
+
+	statusesV2 = map[string]apiv2.UserStatus{
+		service.StatusActive:      apiv2.UserStatus_USER_STATUS_ACTIVE,
+		service.StatusSuspended:   apiv2.UserStatus_USER_STATUS_SUSPENDED,
+		service.StatusDeactivated: apiv2.UserStatus_USER_STATUS_DEACTIVATED,
+	}
+)
+
+// GRPCServerV2 serves the v2 API. It adapts the v2 requests to the handlers of the v1
+// server and their responses back, so both versions share the validation, the warnings
+// and the errors.
+type GRPCServerV2 struct {
+	apiv2.UnimplementedUserServiceServer
+	v1 *GRPCServer
+}
+
+// NewGRPCServerV2 creates the v2 server on top of the v1 one.
+func NewGRPCServerV2(v1 *GRPCServer) *GRPCServerV2 {
+	return &GRPCServerV2{v1: v1}
+}
+
+// GetUser returns a user by ID.
+func (s *GRPCServerV2) GetUser(ctx context.Context, req *apiv2.GetUserRequest) (*apiv2.GetUserResponse, error) {
+	if req == nil {
+		return nil, ErrRequestRequired
+	}
+
+	resp, err := s.v1.GetUser(ctx, &apiv1.GetUserRequest{Id: req.Id})
+	if err != nil {
+		return nil, err
+	}
+	return &apiv2.GetUserResponse{User: newUserV2FromV1(resp.User)}, nil
+}
+
+// CreateUser creates a user, ignoring the output only fields.
+func (s *GRPCServerV2) CreateUser(ctx context.Context, req *apiv2.CreateUserRequest) (*apiv2.CreateUserResponse, error) {
+	if req == nil {
+		return nil, ErrRequestRequired
+	}
+
+	if req.User == nil {
+		req.User = &apiv2.User{}
+	}
+
+	// Validated here for the field violations to name the fields of the v2 request.
+	if err := validateRequest(ctx, req); err != nil {
+		s.v1.logger.Error("failed to validate request", zap.Error(err))
+		return nil, err
+	}
+
+	user := req.User
+	resp, err := s.v1.CreateUser(ctx, &apiv1.CreateUserRequest{
+		FirstName: user.FirstName,
+		LastName:  user.LastName,
+		Nickname:  user.Nickname,
+		Email:     user.Email,
+		Password:  req.Password,
+		Country:   user.Country,
+		Metadata:  user.Metadata,
+		Phone:     user.GetPhone(),
+		Locale:    user.GetLocale(),
+		Timezone:  user.GetTimezone(),
+		Birthdate: user.GetBirthdate(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &apiv2.CreateUserResponse{User: newUserV2FromV1(resp.User)}, nil
+}
+
+// UpdateUser sets the fields of the update mask. The v1 update replaces every field, so
+// the fields out of the mask are taken from the current user.
+func (s *GRPCServerV2) UpdateUser(ctx context.Context, req *apiv2.UpdateUserRequest) (*apiv2.UpdateUserResponse, error) {
+	if req == nil {
+		return nil, ErrRequestRequired
+	}
+
+	if err := validateID(req.GetUser().GetId()); err != nil {
+		return nil, badRequestError("user.id", err)
+	}
+
+	if len(req.GetUpdateMask().GetPaths()) == 0 {
+		return nil, badRequestError("update_mask", ErrUpdateMaskRequired)
+	}
+
+	if err := validateUpdateMask(ctx, req); err != nil {
+		s.v1.logger.Error("failed to validate request", zap.Error(err))
+		return nil, err
+	}
+
+	current, err := s.v1.GetUser(ctx, &apiv1.GetUserRequest{Id: req.User.Id})
+	if err != nil {
+		return nil, err
+	}
+
+	update, err := newUpdateUserRequestV1(current.User, req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.v1.UpdateUser(ctx, update)
+	if err != nil {
+		return nil, err
+	}
+	return &apiv2.UpdateUserResponse{User: newUserV2FromV1(resp.User)}, nil
+}
+
+// DeleteUser deletes a user by ID.
+func (s *GRPCServerV2) DeleteUser(ctx context.Context, req *apiv2.DeleteUserRequest) (*apiv2.DeleteUserResponse, error) {
+	if req == nil {
+		return nil, ErrRequestRequired
+	}
+
+	if _, err := s.v1.DeleteUser(ctx, &apiv1.DeleteUserRequest{Id: req.Id}); err != nil {
+		return nil, err
+	}
+	return &apiv2.DeleteUserResponse{}, nil
+}
+
+// SuspendUser suspends a user by ID until it is reactivated.
+func (s *GRPCServerV2) SuspendUser(ctx context.Context, req *apiv2.SuspendUserRequest) (*apiv2.SuspendUserResponse, error) {
+	if req == nil {
+		return nil, ErrRequestRequired
+	}
+
+	resp, err := s.v1.SuspendUser(ctx, &apiv1.SuspendUserRequest{Id: req.Id})
+	if err != nil {
+		return nil, err
+	}
+	return &apiv2.SuspendUserResponse{User: newUserV2FromV1(resp.User)}, nil
+}
+
+// DeactivateUser deactivates a user by ID until it is reactivated.
+func (s *GRPCServerV2) DeactivateUser(ctx context.Context, req *apiv2.DeactivateUserRequest) (*apiv2.DeactivateUserResponse, error) {
+	if req == nil {
+		return nil, ErrRequestRequired
+	}
+
+	resp, err := s.v1.DeactivateUser(ctx, &apiv1.DeactivateUserRequest{Id: req.Id})
+	if err != nil {
+		return nil, err
+	}
+	return &apiv2.DeactivateUserResponse{User: newUserV2FromV1(resp.User)}, nil
+}
+
+// ReactivateUser makes a suspended or deactivated user active again.
+func (s *GRPCServerV2) ReactivateUser(ctx context.Context, req *apiv2.ReactivateUserRequest) (*apiv2.ReactivateUserResponse, error) {
+	if req == nil {
+		return nil, ErrRequestRequired
+	}
+
+	resp, err := s.v1.ReactivateUser(ctx, &apiv1.ReactivateUserRequest{Id: req.Id})
+	if err != nil {
+		return nil, err
+	}
+	return &apiv2.ReactivateUserResponse{User: newUserV2FromV1(resp.User)}, nil
+}
+
+// ListUsers returns a page of the users matching the filters, like the v1 ListUsers.
+func (s *GRPCServerV2) ListUsers(ctx context.Context, req *apiv2.ListUsersRequest) (*apiv2.ListUsersResponse, error) {
+	if req == nil {
+		return nil, ErrRequestRequired
+	}
+
+	resp, err := s.v1.ListUsers(ctx, &apiv1.ListUsersRequest{
+		Country:          req.GetCountry(),
+		PageSize:         req.PageSize,
+		PageToken:        req.PageToken,
+		NicknamePrefix:   req.GetNicknamePrefix(),
+		Email:            req.GetEmail(),
+		FirstName:        req.GetFirstName(),
+		LastName:         req.GetLastName(),
+		CreatedAfter:     req.CreatedAfter,
+		CreatedBefore:    req.CreatedBefore,
+		IncludeTotalSize: req.IncludeTotalSize,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	users := make([]*apiv2.User, 0, len(resp.Users))
+	for _, user := range resp.Users {
+		users = append(users, newUserV2FromV1(user))
+	}
+
+	return &apiv2.ListUsersResponse{
+		Users:         users,
+		NextPageToken: resp.NextPageToken,
+		TotalSize:     resp.TotalSize,
+	}, nil
+}
+
+// CheckHealth checks the health of the application going all the way down to the database.
+// A draining instance is reported as not serving.
+func (s *GRPCServerV2) CheckHealth(ctx context.Context, req *apiv2.CheckHealthRequest) (*apiv2.CheckHealthResponse, error) {
+	resp, err := s.v1.CheckHeath(ctx, &apiv1.HealthCheckRequest{})
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Status != apiv1.HealthCheckResponse_SERVING {
+		return &apiv2.CheckHealthResponse{
+			Status: apiv2.CheckHealthResponse_SERVING_STATUS_NOT_SERVING,
+		}, nil
+	}
+	return &apiv2.CheckHealthResponse{
+		Status: apiv2.CheckHealthResponse_SERVING_STATUS_SERVING,
+	}, nil
+}
+
+// validateUpdateMask validates the fields of the user listed in the update mask, with the
+// rules of the v1 fields of the same name.
+func validateUpdateMask(ctx context.Context, req *apiv2.UpdateUserRequest) error {
+	policy := policyFromContext(ctx)
+	msg := req.User.ProtoReflect()
+	desc := msg.Descriptor()
+
+	for _, path := range req.UpdateMask.Paths {
+		field := desc.Fields().ByName(protoreflect.Name(path))
+		if field == nil || field.Kind() != protoreflect.StringKind {
+			continue
+		}
+
+		rule := ruleOf(desc.FullName(), field.Name())
+		if rule == nil {
+			continue
+		}
+
+		if err := rule(policy, msg.Get(field).String()); err != nil {
+			return badRequestError("user."+path, err)
+		}
+	}
+	return nil
+}
+
+// newUpdateUserRequestV1 returns the v1 update of the current user setting the fields of
+// the update mask of the v2 request.
+func newUpdateUserRequestV1(current *apiv1.User, req *apiv2.UpdateUserRequest) (*apiv1.UpdateUserRequest, error) {
+	user := req.User
+
+	update := &apiv1.UpdateUserRequest{
+		Id:        current.Id,
+		FirstName: current.FirstName,
+		LastName:  current.LastName,
+		Nickname:  current.Nickname,
+		Email:     current.Email,
+		Password:  req.GetPassword(),
+		Country:   current.Country,
+	}
+
+	// The v1 update keeps the phone, locale, timezone and birthdate when they are empty.
+	notClearable := func(path, value string, target *string) error {
+		if value == "" {
+			return badRequestError("user."+path, ErrFieldNotClearable)
+		}
+		*target = value
+		return nil
+	}
+
+	for _, path := range req.UpdateMask.Paths {
+		var err error
+
+		switch path {
+		case "first_name":
+			update.FirstName = user.FirstName
+		case "last_name":
+			update.LastName = user.LastName
+		case "nickname":
+			update.Nickname = user.Nickname
+		case "email":
+			update.Email = user.Email
+		case "country":
+			update.Country = user.Country
+		case "metadata":
+			// The v1 metadata is merged into the current one, the attributes to remove
+			// are sent with an empty value.
+			update.Metadata = make(map[string]string, len(current.Metadata)+len(user.Metadata))
+			for key := range current.Metadata {
+				update.Metadata[key] = ""
+			}
+			for key, value := range user.Metadata {
+				update.Metadata[key] = value
+			}
+		case "phone":
+			err = notClearable(path, user.GetPhone(), &update.Phone)
+		case "locale":
+			err = notClearable(path, user.GetLocale(), &update.Locale)
+		case "timezone":
+			err = notClearable(path, user.GetTimezone(), &update.Timezone)
+		case "birthdate":
+			err = notClearable(path, user.GetBirthdate(), &update.Birthdate)
+		default:
+			err = badRequestError("update_mask", ErrUpdateMaskInvalid)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+	return update, nil
+}
+
+func newUserV2FromV1(user *apiv1.User) *apiv2.User {
+	if user == nil {
+		return nil
+	}
+
+	return &apiv2.User{
+		Id:         user.Id,
+		FirstName:  user.FirstName,
+		LastName:   user.LastName,
+		Nickname:   user.Nickname,
+		Email:      user.Email,
+		Country:    user.Country,
+		CreatedAt:  user.CreatedAt,
+		UpdatedAt:  user.UpdatedAt,
+		Role:       rolesV2[user.Role],
+		Status:     statusesV2[user.Status],
+		Anonymized: user.Anonymized,
+		Metadata:   user.Metadata,
+
+		AvatarHash:    optionalString(user.AvatarHash),
+		Phone:         optionalString(user.Phone),
+		PhoneVerified: user.PhoneVerified,
+		Locale:        optionalString(user.Locale),
+		Timezone:      optionalString(user.Timezone),
+		Birthdate:     optionalString(user.Birthdate),
+	}
+}
+
+// optionalString returns nil for the empty values, unset in the v2 messages.
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
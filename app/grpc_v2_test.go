@@ -0,0 +1,284 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alesr/usrsvc/internal/users/service"
+	apiv2 "github.com/alesr/usrsvc/proto/users/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+func TestCreateUserV2(t *testing.T) {
+	t.Parallel()
+
+	t.Run("happy path", func(t *testing.T) {
+		svc := &serviceMock{
+			CreateFunc: func(ctx context.Context, user *service.User) (*service.User, error) {
+				assert.Equal(t, "password1!", user.Password)
+				assert.Equal(t, "pt-BR", user.Locale)
+				assert.Empty(t, user.Phone)
+
+				created := *user
+				created.ID = uuid.New().String()
+				created.Role = service.RoleUser
+				created.Status = service.StatusActive
+				return &created, nil
+			},
+		}
+
+		server := NewGRPCServerV2(NewGRPCServer(zap.NewNop(), svc))
+
+		observed, err := server.CreateUser(context.TODO(), &apiv2.CreateUserRequest{
+			User: &apiv2.User{
+				FirstName: "John",
+				LastName:  "Doe",
+				Nickname:  "jdoe",
+				Email:     "john@foo.bar",
+				Country:   "BR",
+				Locale:    proto.String("pt-BR"),
+			},
+			Password: "password1!",
+		})
+		require.NoError(t, err)
+
+		assert.NotEmpty(t, observed.User.Id)
+		assert.Equal(t, apiv2.Role_ROLE_USER, observed.User.Role)
+		assert.Equal(t, apiv2.UserStatus_USER_STATUS_ACTIVE, observed.User.Status)
+		assert.Equal(t, "pt-BR", observed.User.GetLocale())
+		assert.Nil(t, observed.User.Phone)
+		assert.Nil(t, observed.User.AvatarHash)
+	})
+
+	t.Run("field violations name the v2 fields", func(t *testing.T) {
+		server := NewGRPCServerV2(NewGRPCServer(zap.NewNop(), &serviceMock{}))
+
+		_, err := server.CreateUser(context.TODO(), &apiv2.CreateUserRequest{
+			User: &apiv2.User{
+				FirstName: "John",
+				LastName:  "Doe",
+				Nickname:  "jdoe",
+				Email:     "john",
+				Country:   "BR",
+			},
+			Password: "password1!",
+		})
+
+		assertStatusHelper(t, ErrEmailFormat, err)
+		assert.Equal(t, []string{"user.email"}, fieldViolationsHelper(t, err))
+	})
+
+	t.Run("without user", func(t *testing.T) {
+		server := NewGRPCServerV2(NewGRPCServer(zap.NewNop(), &serviceMock{}))
+
+		_, err := server.CreateUser(context.TODO(), &apiv2.CreateUserRequest{Password: "password1!"})
+
+		assertStatusHelper(t, ErrNameRequired, err)
+		assert.Equal(t, []string{"user.first_name"}, fieldViolationsHelper(t, err))
+	})
+}
+
+func TestUpdateUserV2(t *testing.T) {
+	t.Parallel()
+
+	id := uuid.New().String()
+
+	current := func() *service.User {
+		return &service.User{
+			ID:        id,
+			FirstName: "John",
+			LastName:  "Doe",
+			Nickname:  "jdoe",
+			Email:     "john@foo.bar",
+			Country:   "US",
+			Metadata:  map[string]string{"theme": "dark", "plan": "pro"},
+			Phone:     "+14155550123",
+			Role:      service.RoleAdmin,
+			Status:    service.StatusSuspended,
+		}
+	}
+
+	newServerHelper := func(update func(user *service.User)) *GRPCServerV2 {
+		svc := &serviceMock{
+			FetchFunc: func(ctx context.Context, id string) (*service.User, error) {
+				return current(), nil
+			},
+			UpdateFunc: func(ctx context.Context, user *service.User) (*service.User, error) {
+				update(user)
+				return user, nil
+			},
+		}
+		return NewGRPCServerV2(NewGRPCServer(zap.NewNop(), svc))
+	}
+
+	t.Run("only the fields of the mask change", func(t *testing.T) {
+		server := newServerHelper(func(user *service.User) {
+			assert.Equal(t, "Johnny", user.FirstName)
+			assert.Equal(t, "jdoe", user.Nickname)
+			assert.Equal(t, "john@foo.bar", user.Email)
+			assert.Equal(t, "US", user.Country)
+			assert.Equal(t, "Europe/Lisbon", user.Timezone)
+			assert.Empty(t, user.Phone)
+			assert.Empty(t, user.Password)
+		})
+
+		observed, err := server.UpdateUser(context.TODO(), &apiv2.UpdateUserRequest{
+			User: &apiv2.User{
+				Id:        id,
+				FirstName: "Johnny",
+				Nickname:  "ignored",
+				Timezone:  proto.String("Europe/Lisbon"),
+			},
+			UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"first_name", "timezone"}},
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, "Johnny", observed.User.FirstName)
+	})
+
+	t.Run("the metadata of the mask replaces the current one", func(t *testing.T) {
+		server := newServerHelper(func(user *service.User) {
+			assert.Equal(t, map[string]string{"theme": "light", "plan": ""}, user.Metadata)
+		})
+
+		_, err := server.UpdateUser(context.TODO(), &apiv2.UpdateUserRequest{
+			User:       &apiv2.User{Id: id, Metadata: map[string]string{"theme": "light"}},
+			UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"metadata"}},
+		})
+		require.NoError(t, err)
+	})
+
+	testCases := []struct {
+		name              string
+		given             *apiv2.UpdateUserRequest
+		expectedErr       error
+		expectedViolation string
+	}{
+		{
+			name:              "invalid id",
+			given:             &apiv2.UpdateUserRequest{User: &apiv2.User{Id: "42"}, UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"first_name"}}},
+			expectedErr:       ErrIDFormat,
+			expectedViolation: "user.id",
+		},
+		{
+			name:              "without update mask",
+			given:             &apiv2.UpdateUserRequest{User: &apiv2.User{Id: id}},
+			expectedErr:       ErrUpdateMaskRequired,
+			expectedViolation: "update_mask",
+		},
+		{
+			name:              "output only field",
+			given:             &apiv2.UpdateUserRequest{User: &apiv2.User{Id: id}, UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"role"}}},
+			expectedErr:       ErrUpdateMaskInvalid,
+			expectedViolation: "update_mask",
+		},
+		{
+			name:              "invalid field of the mask",
+			given:             &apiv2.UpdateUserRequest{User: &apiv2.User{Id: id}, UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"email"}}},
+			expectedErr:       ErrEmailRequired,
+			expectedViolation: "user.email",
+		},
+		{
+			name:              "clearing the phone",
+			given:             &apiv2.UpdateUserRequest{User: &apiv2.User{Id: id}, UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"phone"}}},
+			expectedErr:       ErrFieldNotClearable,
+			expectedViolation: "user.phone",
+		},
+		{
+			name:              "password",
+			given:             &apiv2.UpdateUserRequest{User: &apiv2.User{Id: id, FirstName: "Johnny"}, UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"first_name"}}, Password: proto.String("password2!")},
+			expectedErr:       ErrPasswordNotUpdatable,
+			expectedViolation: "password",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			server := newServerHelper(func(user *service.User) {
+				t.Fatal("unexpected update")
+			})
+
+			_, err := server.UpdateUser(context.TODO(), tc.given)
+
+			assertStatusHelper(t, tc.expectedErr, err)
+			assert.Equal(t, []string{tc.expectedViolation}, fieldViolationsHelper(t, err))
+		})
+	}
+}
+
+func TestListUsersV2(t *testing.T) {
+	t.Parallel()
+
+	svc := &serviceMock{
+		FetchAllFunc: func(ctx context.Context, filter service.FilterParams, pag service.PaginationParams) ([]*service.User, error) {
+			require.NotNil(t, filter.Country)
+			assert.Equal(t, "BR", *filter.Country)
+			assert.Nil(t, filter.Email)
+
+			return []*service.User{{ID: uuid.New().String(), Country: "BR", Role: service.RoleUser, Status: service.StatusDeactivated}}, nil
+		},
+		CountFunc: func(ctx context.Context, filter service.FilterParams) (int64, error) {
+			return 42, nil
+		},
+	}
+
+	server := NewGRPCServerV2(NewGRPCServer(zap.NewNop(), svc))
+
+	observed, err := server.ListUsers(context.TODO(), &apiv2.ListUsersRequest{
+		Country:          proto.String("BR"),
+		PageSize:         1,
+		IncludeTotalSize: true,
+	})
+	require.NoError(t, err)
+
+	require.Len(t, observed.Users, 1)
+	assert.Equal(t, apiv2.UserStatus_USER_STATUS_DEACTIVATED, observed.Users[0].Status)
+	assert.Equal(t, observed.Users[0].Id, observed.NextPageToken)
+	require.NotNil(t, observed.TotalSize)
+	assert.Equal(t, int64(42), *observed.TotalSize)
+}
+
+func TestCheckHealthV2(t *testing.T) {
+	t.Parallel()
+
+	t.Run("serving", func(t *testing.T) {
+		svc := &serviceMock{
+			CheckServiceHealthFunc: func(ctx context.Context) error {
+				return nil
+			},
+		}
+
+		server := NewGRPCServerV2(NewGRPCServer(zap.NewNop(), svc))
+
+		observed, err := server.CheckHealth(context.TODO(), &apiv2.CheckHealthRequest{})
+		require.NoError(t, err)
+
+		assert.Equal(t, apiv2.CheckHealthResponse_SERVING_STATUS_SERVING, observed.Status)
+	})
+
+	t.Run("draining", func(t *testing.T) {
+		drain := NewDrain(time.Second)
+		drain.Start()
+
+		svc := &serviceMock{
+			CheckServiceHealthFunc: func(ctx context.Context) error {
+				return errors.New("unexpected health check")
+			},
+		}
+
+		server := NewGRPCServerV2(NewGRPCServer(zap.NewNop(), svc, WithDrain(drain)))
+
+		observed, err := server.CheckHealth(context.TODO(), &apiv2.CheckHealthRequest{})
+		require.NoError(t, err)
+
+		assert.Equal(t, apiv2.CheckHealthResponse_SERVING_STATUS_NOT_SERVING, observed.Status)
+	})
+}
@@ -0,0 +1,66 @@
+package app
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// incomingContext returns the context of an HTTP request calling the gRPC handlers as
+// the interceptors expect it from a gRPC server: the given headers, or all of them when
+// none is given, as incoming metadata and the client address as peer.
+func incomingContext(r *http.Request, headers ...string) context.Context {
+	md := metadata.MD{}
+	if len(headers) == 0 {
+		for header, values := range r.Header {
+			md.Append(strings.ToLower(header), values...)
+		}
+	}
+
+	for _, header := range headers {
+		if values := r.Header.Values(header); len(values) > 0 {
+			md.Set(header, values...)
+		}
+	}
+
+	ctx := metadata.NewIncomingContext(r.Context(), md)
+	if addr, err := net.ResolveTCPAddr("tcp", r.RemoteAddr); err == nil {
+		ctx = peer.NewContext(ctx, &peer.Peer{Addr: addr})
+	}
+	return ctx
+}
+
+// chainUnaryInterceptors returns a unary interceptor calling the given ones in order,
+// like grpc.ChainUnaryInterceptor does for a gRPC server.
+func chainUnaryInterceptors(interceptors []grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		next := handler
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			interceptor, handler := interceptors[i], next
+			next = func(ctx context.Context, req any) (any, error) {
+				return interceptor(ctx, req, info, handler)
+			}
+		}
+		return next(ctx, req)
+	}
+}
+
+// chainStreamInterceptors returns a stream interceptor calling the given ones in order,
+// like grpc.ChainStreamInterceptor does for a gRPC server.
+func chainStreamInterceptors(interceptors []grpc.StreamServerInterceptor) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		next := handler
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			interceptor, handler := interceptors[i], next
+			next = func(srv any, ss grpc.ServerStream) error {
+				return interceptor(srv, ss, info, handler)
+			}
+		}
+		return next(srv, ss)
+	}
+}
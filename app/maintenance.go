@@ -0,0 +1,77 @@
+package app
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+
+	"google.golang.org/grpc"
+)
+
+// readOnlyMethods are the RPCs that keep being served in maintenance mode. They also make the
+// users:read scope of the API keys, so they must not write anything: Authenticate, which
+// records the sessions, the logins and the failed attempts, is not one of them.
+var readOnlyMethods = map[string]bool{
+	"GetUser":                 true,
+	"GetAvatar":               true,
+	"CheckNicknameAvailable":  true,
+	"ListUsers":               true,
+	"ExportUsers":             true,
+	"SearchUsers":             true,
+	"GetUserStats":            true,
+	"FindDuplicateUsers":      true,
+	"StartFindDuplicateUsers": true,
+	"GetOperation":            true,
+	"ListOperations":          true,
+	"ListLinkedIdentities":    true,
+	"GetUserByIdentity":       true,
+	"ListFieldLocks":          true,
+	"ListSessions":            true,
+	"ListAPIKeys":             true,
+	"ListAuditEvents":         true,
+	"ListUserChanges":         true,
+	"GetTenantPolicy":         true,
+	"CheckHeath":              true,
+	"CheckHealth":             true,
+}
+
+// Maintenance is the maintenance mode switch. While enabled, the RPCs that
+// change data are rejected so operators can work on the storage safely.
+type Maintenance struct {
+	enabled atomic.Bool
+}
+
+// Enabled reports whether maintenance mode is on.
+func (m *Maintenance) Enabled() bool {
+	return m.enabled.Load()
+}
+
+// SetEnabled turns maintenance mode on or off.
+func (m *Maintenance) SetEnabled(enabled bool) {
+	m.enabled.Store(enabled)
+}
+
+// UnaryServerInterceptor rejects the RPCs that change data while maintenance mode is on.
+func (m *Maintenance) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if m.Enabled() && !isReadOnlyMethod(info.FullMethod) {
+			return nil, ErrMaintenance
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor rejects the streaming RPCs that change data while maintenance mode is on.
+func (m *Maintenance) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if m.Enabled() && !isReadOnlyMethod(info.FullMethod) {
+			return ErrMaintenance
+		}
+		return handler(srv, ss)
+	}
+}
+
+// isReadOnlyMethod reports whether the full gRPC method name ("/Service/Method") is read-only.
+func isReadOnlyMethod(fullMethod string) bool {
+	return readOnlyMethods[fullMethod[strings.LastIndex(fullMethod, "/")+1:]]
+}
@@ -0,0 +1,94 @@
+package app
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+func TestMaintenanceUnaryServerInterceptor(t *testing.T) {
+	t.Parallel()
+
+	handler := func(ctx context.Context, req any) (any, error) {
+		return "ok", nil
+	}
+
+	testCases := []struct {
+		name        string
+		enabled     bool
+		method      string
+		expectedErr error
+	}{
+		{
+			name:        "writes are served when disabled",
+			enabled:     false,
+			method:      "/UserService/CreateUser",
+			expectedErr: nil,
+		},
+		{
+			name:        "writes are rejected when enabled",
+			enabled:     true,
+			method:      "/UserService/CreateUser",
+			expectedErr: ErrMaintenance,
+		},
+		{
+			name:        "logins are rejected when enabled",
+			enabled:     true,
+			method:      "/UserService/Authenticate",
+			expectedErr: ErrMaintenance,
+		},
+		{
+			name:        "reads are served when enabled",
+			enabled:     true,
+			method:      "/UserService/GetUser",
+			expectedErr: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var maintenance Maintenance
+			maintenance.SetEnabled(tc.enabled)
+
+			resp, err := maintenance.UnaryServerInterceptor()(
+				context.TODO(),
+				nil,
+				&grpc.UnaryServerInfo{FullMethod: tc.method},
+				handler,
+			)
+
+			assert.Equal(t, tc.expectedErr, err)
+			if tc.expectedErr == nil {
+				assert.Equal(t, "ok", resp)
+			}
+		})
+	}
+}
+
+func TestMaintenanceStreamServerInterceptor(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	var maintenance Maintenance
+	maintenance.SetEnabled(true)
+
+	handler := func(srv any, ss grpc.ServerStream) error {
+		return nil
+	}
+
+	// Act
+	err := maintenance.StreamServerInterceptor()(
+		nil,
+		nil,
+		&grpc.StreamServerInfo{FullMethod: "/UserService/ImportUsers", IsClientStream: true},
+		handler,
+	)
+
+	// Assert
+	assert.Equal(t, ErrMaintenance, err)
+}
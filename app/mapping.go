@@ -0,0 +1,318 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/alesr/usrsvc/internal/audit"
+	"github.com/alesr/usrsvc/internal/policies"
+	"github.com/alesr/usrsvc/internal/users/service"
+	"github.com/alesr/usrsvc/pkg/uservalidation"
+	apiv1 "github.com/alesr/usrsvc/proto/users/v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var errZeroTimestamp = errors.New("zero timestamp")
+
+// newTimestamp converts the time to a proto timestamp. The zero time is left unset,
+// rather than sent as a valid timestamp clients would display.
+func newTimestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+// timeFromTimestamp converts an optional proto timestamp to a time. It returns false when
+// the timestamp is unset, and an error when it is invalid or zero (1970-01-01T00:00:00Z),
+// which is what clients usually send when they forget to set it.
+func timeFromTimestamp(ts *timestamppb.Timestamp) (time.Time, bool, error) {
+	if ts == nil {
+		return time.Time{}, false, nil
+	}
+
+	if err := ts.CheckValid(); err != nil {
+		return time.Time{}, false, err
+	}
+
+	if ts.GetSeconds() == 0 && ts.GetNanos() == 0 {
+		return time.Time{}, false, errZeroTimestamp
+	}
+	return ts.AsTime(), true, nil
+}
+
+// parseBirthdate parses a validated birthdate. It returns the zero time for empty ones.
+func parseBirthdate(birthdate string) time.Time {
+	date, _ := uservalidation.ParseBirthdate(birthdate)
+	return date
+}
+
+// formatBirthdate formats the birthdate in YYYY-MM-DD format, empty if it is unknown.
+func formatBirthdate(birthdate time.Time) string {
+	if birthdate.IsZero() {
+		return ""
+	}
+	return birthdate.Format(uservalidation.BirthdateLayout)
+}
+
+// newFilterParamsFromListRequest returns the filters of the list request. Empty fields are not filtered on.
+func newFilterParamsFromListRequest(req *apiv1.ListUsersRequest) (service.FilterParams, error) {
+	var filters service.FilterParams
+
+	for _, f := range []struct {
+		value  string
+		target **string
+	}{
+		{req.GetCountry(), &filters.Country},
+		{req.GetNicknamePrefix(), &filters.NicknamePrefix},
+		{req.GetEmail(), &filters.Email},
+		{req.GetFirstName(), &filters.FirstName},
+		{req.GetLastName(), &filters.LastName},
+	} {
+		if f.value != "" {
+			value := f.value
+			*f.target = &value
+		}
+	}
+
+	for _, f := range []struct {
+		value  *timestamppb.Timestamp
+		target **time.Time
+	}{
+		{req.GetCreatedAfter(), &filters.CreatedAfter},
+		{req.GetCreatedBefore(), &filters.CreatedBefore},
+	} {
+		value, ok, err := timeFromTimestamp(f.value)
+		if err != nil {
+			return filters, ErrCreatedRangeInvalid
+		}
+		if ok {
+			*f.target = &value
+		}
+	}
+	return filters, nil
+}
+
+// newCreateUserFromRequest converts a create request, e.g. the admin of a bootstrap request, to a domain user.
+func newCreateUserFromRequest(req *apiv1.CreateUserRequest) *service.User {
+	return &service.User{
+		FirstName: req.GetFirstName(),
+		LastName:  req.GetLastName(),
+		Nickname:  req.GetNickname(),
+		Email:     req.GetEmail(),
+		Password:  req.GetPassword(),
+		Country:   req.GetCountry(),
+		Metadata:  req.GetMetadata(),
+		Phone:     req.GetPhone(),
+		Locale:    req.GetLocale(),
+		Timezone:  req.GetTimezone(),
+		Birthdate: parseBirthdate(req.GetBirthdate()),
+	}
+}
+
+// newImportRecordFromRequest validates an import request and converts it to an import record.
+func newImportRecordFromRequest(ctx context.Context, req *apiv1.ImportUsersRequest) *service.ImportRecord {
+	user := &service.User{
+		FirstName: req.GetFirstName(),
+		LastName:  req.GetLastName(),
+		Nickname:  req.GetNickname(),
+		Email:     req.GetEmail(),
+		Password:  req.GetPassword(),
+		Country:   req.GetCountry(),
+		Metadata:  req.GetMetadata(),
+		Phone:     req.GetPhone(),
+		Locale:    req.GetLocale(),
+		Timezone:  req.GetTimezone(),
+	}
+
+	if err := validateRequest(ctx, req); err != nil {
+		return &service.ImportRecord{User: user, Err: err}
+	}
+	user.Birthdate = parseBirthdate(req.GetBirthdate())
+
+	createdAt, ok, err := timeFromTimestamp(req.GetCreatedAt())
+	if err != nil || createdAt.After(time.Now()) {
+		return &service.ImportRecord{User: user, Err: badRequestError("created_at", ErrCreatedAtInvalid)}
+	}
+
+	if ok {
+		user.CreatedAt = createdAt
+	}
+	return &service.ImportRecord{User: user}
+}
+
+// newImportUsersResponse converts an import report to a response. The record errors are
+// converted like the errors of CreateUser, but for the validation errors, already converted.
+func newImportUsersResponse(report *service.ImportReport) *apiv1.ImportUsersResponse {
+	resp := apiv1.ImportUsersResponse{
+		Imported: report.Imported,
+		Failed:   report.Failed,
+		Errors:   make([]*apiv1.ImportUserError, 0, len(report.Errors)),
+	}
+
+	for _, importErr := range report.Errors {
+		st, ok := status.FromError(importErr.Err)
+		if !ok {
+			st = status.Convert(convertServiceError(importErr.Err))
+		}
+
+		resp.Errors = append(resp.Errors, &apiv1.ImportUserError{
+			Index:  importErr.Index,
+			Email:  importErr.Email,
+			Status: st.Proto(),
+		})
+	}
+	return &resp
+}
+
+func newAuditEventResponseFromDomain(event *audit.Event) *apiv1.AuditEvent {
+	if event == nil {
+		return nil
+	}
+
+	changes := make(map[string]*apiv1.AuditChange, len(event.Changes))
+	for field, change := range event.Changes {
+		changes[field] = &apiv1.AuditChange{Before: change.Before, After: change.After}
+	}
+
+	return &apiv1.AuditEvent{
+		Id:        event.ID,
+		Actor:     event.Actor,
+		Action:    string(event.Action),
+		UserId:    event.UserID,
+		Changes:   changes,
+		CreatedAt: newTimestamp(event.CreatedAt),
+	}
+}
+
+func newTenantPolicyResponseFromDomain(policy *policies.Policy) *apiv1.TenantPolicy {
+	return &apiv1.TenantPolicy{
+		Tenant:            policy.Tenant,
+		MinPasswordLength: int32(policy.MinPasswordLength),
+		NameSeparators:    policy.NameSeparators,
+		MinAge:            int32(policy.MinAge),
+		UpdatedAt:         newTimestamp(policy.UpdatedAt),
+	}
+}
+
+func newUserChangeResponseFromDomain(change *service.UserChange) *apiv1.UserChange {
+	if change == nil {
+		return nil
+	}
+
+	return &apiv1.UserChange{
+		Id:        change.ID,
+		Type:      string(change.Type),
+		UserId:    change.UserID,
+		User:      newUserResponseFromDomain(change.User),
+		CreatedAt: newTimestamp(change.CreatedAt),
+	}
+}
+
+func newUserResponseFromDomain(user *service.User) *apiv1.User {
+	// Better safe than sorry.
+	if user == nil {
+		return nil
+	}
+
+	return &apiv1.User{
+		Id:        user.ID,
+		FirstName: user.FirstName,
+		LastName:  user.LastName,
+		Nickname:  user.Nickname,
+		Email:     user.Email,
+		Country:   user.Country,
+		CreatedAt: newTimestamp(user.CreatedAt),
+		UpdatedAt: newTimestamp(user.UpdatedAt),
+		Role:      user.Role,
+		Status:    user.Status,
+		Metadata:  user.Metadata,
+
+		Anonymized: user.Anonymized,
+		AvatarHash: user.AvatarHash,
+
+		Phone:         user.Phone,
+		PhoneVerified: user.PhoneVerified,
+		Locale:        user.Locale,
+		Timezone:      user.Timezone,
+		Birthdate:     formatBirthdate(user.Birthdate),
+	}
+}
+
+func newSessionResponseFromDomain(session *service.Session) *apiv1.Session {
+	if session == nil {
+		return nil
+	}
+
+	return &apiv1.Session{
+		Id:                   session.ID,
+		UserId:               session.UserID,
+		Ip:                   session.IP,
+		CreatedAt:            newTimestamp(session.CreatedAt),
+		RefreshedAt:          newTimestamp(session.RefreshedAt),
+		AccessTokenExpiresAt: newTimestamp(session.AccessTokenExpiresAt),
+		ExpiresAt:            newTimestamp(session.ExpiresAt),
+	}
+}
+
+func newSessionTokensResponseFromDomain(tokens *service.SessionTokens) *apiv1.SessionTokens {
+	if tokens == nil {
+		return nil
+	}
+
+	return &apiv1.SessionTokens{
+		Session:      newSessionResponseFromDomain(tokens.Session),
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+	}
+}
+
+func newAPIKeyResponseFromDomain(key *service.APIKey) *apiv1.APIKey {
+	if key == nil {
+		return nil
+	}
+
+	return &apiv1.APIKey{
+		Id:        key.ID,
+		UserId:    key.UserID,
+		Name:      key.Name,
+		Scopes:    key.Scopes,
+		CreatedAt: newTimestamp(key.CreatedAt),
+	}
+}
+
+func newLinkedIdentityResponseFromDomain(identity *service.LinkedIdentity) *apiv1.LinkedIdentity {
+	if identity == nil {
+		return nil
+	}
+
+	return &apiv1.LinkedIdentity{
+		Provider: identity.Provider,
+		Subject:  identity.Subject,
+		Email:    identity.Email,
+		LinkedAt: newTimestamp(identity.LinkedAt),
+	}
+}
+
+func newFieldLockResponseFromDomain(lock *service.FieldLock) *apiv1.FieldLock {
+	if lock == nil {
+		return nil
+	}
+
+	return &apiv1.FieldLock{
+		Field:    lock.Field,
+		Reason:   lock.Reason,
+		LockedBy: lock.LockedBy,
+		LockedAt: newTimestamp(lock.LockedAt),
+	}
+}
+
+func newFieldLocksResponseFromDomain(locks []*service.FieldLock) []*apiv1.FieldLock {
+	resp := make([]*apiv1.FieldLock, 0, len(locks))
+	for _, lock := range locks {
+		resp = append(resp, newFieldLockResponseFromDomain(lock))
+	}
+	return resp
+}
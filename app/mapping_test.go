@@ -0,0 +1,220 @@
+package app
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alesr/usrsvc/internal/audit"
+	"github.com/alesr/usrsvc/internal/users/service"
+	apiv1 "github.com/alesr/usrsvc/proto/users/v1"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestNewUserResponseFromDomain(t *testing.T) {
+	t.Parallel()
+
+	id := uuid.New().String()
+
+	testCases := []struct {
+		name     string
+		given    *service.User
+		expected *apiv1.User
+	}{
+		{
+			name: "happy path",
+			given: &service.User{
+				ID:        id,
+				FirstName: "Michael",
+				LastName:  "Jackson",
+				Nickname:  "mj",
+				Email:     "mj@foo.bar",
+				Country:   "US",
+				Status:    "suspended",
+				Metadata:  map[string]string{"theme": "dark"},
+				Locale:    "en-US",
+				Timezone:  "America/New_York",
+				Birthdate: time.Date(1958, time.August, 29, 0, 0, 0, 0, time.UTC),
+				CreatedAt: time.Time{}.Add(1 * time.Second),
+				UpdatedAt: time.Time{}.Add(2 * time.Second),
+			},
+			expected: &apiv1.User{
+				Id:        id,
+				FirstName: "Michael",
+				LastName:  "Jackson",
+				Nickname:  "mj",
+				Email:     "mj@foo.bar",
+				Country:   "US",
+				Status:    "suspended",
+				Metadata:  map[string]string{"theme": "dark"},
+				Locale:    "en-US",
+				Timezone:  "America/New_York",
+				Birthdate: "1958-08-29",
+				CreatedAt: timestamppb.New(time.Time{}.Add(1 * time.Second)),
+				UpdatedAt: timestamppb.New(time.Time{}.Add(2 * time.Second)),
+			},
+		},
+		{
+			name:     "zero timestamps are left unset",
+			given:    &service.User{ID: id},
+			expected: &apiv1.User{Id: id},
+		},
+		{
+			name:     "nil user",
+			given:    nil,
+			expected: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			observed := newUserResponseFromDomain(tc.given)
+			assert.Equal(t, tc.expected, observed)
+		})
+	}
+}
+
+func TestNewResponsesFromDomainNil(t *testing.T) {
+	t.Parallel()
+
+	assert.Nil(t, newAuditEventResponseFromDomain(nil))
+	assert.Nil(t, newLinkedIdentityResponseFromDomain(nil))
+
+	observed := newAuditEventResponseFromDomain(&audit.Event{ID: 1, Action: audit.ActionCreate})
+	require.NotNil(t, observed)
+	assert.Nil(t, observed.CreatedAt)
+}
+
+func TestTimeFromTimestamp(t *testing.T) {
+	t.Parallel()
+
+	someTime := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name        string
+		given       *timestamppb.Timestamp
+		expected    time.Time
+		expectedSet bool
+		expectedErr bool
+	}{
+		{
+			name:        "unset",
+			given:       nil,
+			expectedSet: false,
+		},
+		{
+			name:        "valid",
+			given:       timestamppb.New(someTime),
+			expected:    someTime,
+			expectedSet: true,
+		},
+		{
+			name:        "zero",
+			given:       &timestamppb.Timestamp{},
+			expectedErr: true,
+		},
+		{
+			name:        "out of range",
+			given:       &timestamppb.Timestamp{Seconds: 1, Nanos: -1},
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			observed, set, err := timeFromTimestamp(tc.given)
+
+			if tc.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedSet, set)
+			assert.Equal(t, tc.expected, observed)
+		})
+	}
+}
+
+func TestNewFilterParamsFromListRequest(t *testing.T) {
+	t.Parallel()
+
+	t.Run("zero timestamp", func(t *testing.T) {
+		_, err := newFilterParamsFromListRequest(&apiv1.ListUsersRequest{CreatedAfter: &timestamppb.Timestamp{}})
+		assert.Equal(t, ErrCreatedRangeInvalid, err)
+	})
+
+	t.Run("nil request", func(t *testing.T) {
+		filters, err := newFilterParamsFromListRequest(nil)
+		require.NoError(t, err)
+		assert.Equal(t, service.FilterParams{}, filters)
+	})
+}
+
+func TestNilRequests(t *testing.T) {
+	t.Parallel()
+
+	server := NewGRPCServer(zap.NewNop(), &serviceMock{})
+	ctx := context.TODO()
+
+	testCases := []struct {
+		name string
+		call func() error
+	}{
+		{"CreateUser", func() error { _, err := server.CreateUser(ctx, nil); return err }},
+		{"CheckNicknameAvailable", func() error { _, err := server.CheckNicknameAvailable(ctx, nil); return err }},
+		{"UpdateUser", func() error { _, err := server.UpdateUser(ctx, nil); return err }},
+		{"SearchUsers", func() error { _, err := server.SearchUsers(ctx, nil); return err }},
+		{"GetUser", func() error { _, err := server.GetUser(ctx, nil); return err }},
+		{"ListUsers", func() error { _, err := server.ListUsers(ctx, nil); return err }},
+		{"DeleteUser", func() error { _, err := server.DeleteUser(ctx, nil); return err }},
+		{"AnonymizeUser", func() error { _, err := server.AnonymizeUser(ctx, nil); return err }},
+		{"SuspendUser", func() error { _, err := server.SuspendUser(ctx, nil); return err }},
+		{"DeactivateUser", func() error { _, err := server.DeactivateUser(ctx, nil); return err }},
+		{"ReactivateUser", func() error { _, err := server.ReactivateUser(ctx, nil); return err }},
+		{"SetAvatar", func() error { _, err := server.SetAvatar(ctx, nil); return err }},
+		{"GetAvatar", func() error { _, err := server.GetAvatar(ctx, nil); return err }},
+		{"RequestPhoneVerification", func() error { _, err := server.RequestPhoneVerification(ctx, nil); return err }},
+		{"VerifyPhone", func() error { _, err := server.VerifyPhone(ctx, nil); return err }},
+		{"Authenticate", func() error { _, err := server.Authenticate(ctx, nil); return err }},
+		{"FindDuplicateUsers", func() error { _, err := server.FindDuplicateUsers(ctx, nil); return err }},
+		{"MergeUsers", func() error { _, err := server.MergeUsers(ctx, nil); return err }},
+		{"DeleteUsersByFilter", func() error { _, err := server.DeleteUsersByFilter(ctx, nil); return err }},
+		{"Bootstrap", func() error { _, err := server.Bootstrap(ctx, nil); return err }},
+		{"LinkExternalIdentity", func() error { _, err := server.LinkExternalIdentity(ctx, nil); return err }},
+		{"ListLinkedIdentities", func() error { _, err := server.ListLinkedIdentities(ctx, nil); return err }},
+		{"ChangePassword", func() error { _, err := server.ChangePassword(ctx, nil); return err }},
+		{"RequestPasswordReset", func() error { _, err := server.RequestPasswordReset(ctx, nil); return err }},
+		{"ConfirmPasswordReset", func() error { _, err := server.ConfirmPasswordReset(ctx, nil); return err }},
+		{"ListAuditEvents", func() error { _, err := server.ListAuditEvents(ctx, nil); return err }},
+		{"ListUserChanges", func() error { _, err := server.ListUserChanges(ctx, nil); return err }},
+		{"GetTenantPolicy", func() error { _, err := server.GetTenantPolicy(ctx, nil); return err }},
+		{"SetTenantPolicy", func() error { _, err := server.SetTenantPolicy(ctx, nil); return err }},
+		{"LockUserFields", func() error { _, err := server.LockUserFields(ctx, nil); return err }},
+		{"UnlockUserFields", func() error { _, err := server.UnlockUserFields(ctx, nil); return err }},
+		{"ListFieldLocks", func() error { _, err := server.ListFieldLocks(ctx, nil); return err }},
+		{
+			"Bootstrap without admin",
+			func() error {
+				_, err := server.Bootstrap(ctx, &apiv1.BootstrapRequest{Token: "some-token"})
+				return err
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			var err error
+			require.NotPanics(t, func() { err = tc.call() })
+			assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/alesr/usrsvc/internal/operations"
+	"github.com/alesr/usrsvc/internal/tenant"
+	apiv1 "github.com/alesr/usrsvc/proto/users/v1"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+const (
+	// operationNamePrefix prefixes the ids of the operations in their names.
+	operationNamePrefix string = "operations/"
+
+	// defaultOperationRetention is how long the done operations are kept by default.
+	defaultOperationRetention time.Duration = 24 * time.Hour
+
+	// Enumerate the types of operations.
+
+	operationFindDuplicateUsers  string = "find_duplicate_users"
+	operationMergeUsers          string = "merge_users"
+	operationDeleteUsersByFilter string = "delete_users_by_filter"
+)
+
+// WithOperations configures the manager running the operations started by the Start RPCs.
+func WithOperations(manager *operations.Manager) ServerOption {
+	return func(s *GRPCServer) {
+		s.operations = manager
+	}
+}
+
+// StartFindDuplicateUsers starts FindDuplicateUsers as an operation, whose response
+// is a FindDuplicateUsersResponse and whose progress is the number of users scanned.
+func (s *GRPCServer) StartFindDuplicateUsers(ctx context.Context, req *apiv1.FindDuplicateUsersRequest) (*apiv1.Operation, error) {
+	filters, err := findDuplicateUsersFilter(req)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.startOperation(ctx, operationFindDuplicateUsers, func(ctx context.Context) (proto.Message, error) {
+		return s.findDuplicateUsers(ctx, filters)
+	})
+}
+
+// StartMergeUsers starts MergeUsers as an operation, whose response is a MergeUsersResponse.
+func (s *GRPCServer) StartMergeUsers(ctx context.Context, req *apiv1.MergeUsersRequest) (*apiv1.Operation, error) {
+	params, err := s.mergeUsersParams(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.startOperation(ctx, operationMergeUsers, func(ctx context.Context) (proto.Message, error) {
+		return s.mergeUsers(ctx, params)
+	})
+}
+
+// StartDeleteUsersByFilter starts DeleteUsersByFilter as an operation, whose response is
+// a DeleteUsersByFilterResponse and whose progress is the number of users scanned.
+func (s *GRPCServer) StartDeleteUsersByFilter(ctx context.Context, req *apiv1.DeleteUsersByFilterRequest) (*apiv1.Operation, error) {
+	filter, err := deleteUsersByFilterFilter(req)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.startOperation(ctx, operationDeleteUsersByFilter, func(ctx context.Context) (proto.Message, error) {
+		return s.deleteUsersByFilter(ctx, filter, req.DryRun)
+	})
+}
+
+// GetOperation returns the operation with the given name. Operations are only known
+// by the instance that started them.
+func (s *GRPCServer) GetOperation(ctx context.Context, req *apiv1.GetOperationRequest) (*apiv1.Operation, error) {
+	if req == nil {
+		return nil, ErrRequestRequired
+	}
+
+	id, ok := strings.CutPrefix(req.Name, operationNamePrefix)
+	if !ok || id == "" {
+		return nil, ErrOperationNameFormat
+	}
+
+	op, err := s.operations.Get(id)
+	if err != nil {
+		if errors.Is(err, operations.ErrNotFound) {
+			return nil, ErrOperationNotFound
+		}
+		s.logger.Error("failed to get operation", zap.Error(err))
+		return nil, ErrInternal
+	}
+
+	// The operations of the other tenants are not found.
+	if !tenant.Visible(ctx, op.Tenant) {
+		return nil, ErrOperationNotFound
+	}
+	return s.newOperationResponse(op), nil
+}
+
+// ListOperations lists the operations of the instance, newest first, optionally of a single type.
+// The page token is the name of the last operation of the previous page.
+func (s *GRPCServer) ListOperations(ctx context.Context, req *apiv1.ListOperationsRequest) (*apiv1.ListOperationsResponse, error) {
+	if req == nil {
+		return nil, ErrRequestRequired
+	}
+
+	if req.PageSize <= 0 || req.PageSize > defaultPageSize {
+		req.PageSize = defaultPageSize
+	}
+
+	filter := operations.Filter{
+		Kind:  req.Type,
+		Limit: int(req.PageSize),
+	}
+	filter.Tenant, _ = tenant.FromContext(ctx)
+
+	if req.PageToken != "" {
+		cursor, ok := strings.CutPrefix(req.PageToken, operationNamePrefix)
+		if !ok || cursor == "" {
+			s.logger.Error("failed to validate cursor", zap.String("page_token", req.PageToken))
+			return nil, ErrPageTokenInvalid
+		}
+		filter.Cursor = cursor
+	}
+
+	ops := s.operations.List(filter)
+
+	resp := apiv1.ListOperationsResponse{
+		Operations: make([]*apiv1.Operation, 0, len(ops)),
+	}
+	for _, op := range ops {
+		resp.Operations = append(resp.Operations, s.newOperationResponse(op))
+	}
+
+	if len(ops) == int(req.PageSize) {
+		resp.NextPageToken = operationNamePrefix + ops[len(ops)-1].ID
+	}
+	return &resp, nil
+}
+
+// startOperation starts the job as an operation. The job returns the response of the
+// operation, or a transport error.
+func (s *GRPCServer) startOperation(ctx context.Context, kind string, job func(ctx context.Context) (proto.Message, error)) (*apiv1.Operation, error) {
+	op, err := s.operations.Start(ctx, kind, func(ctx context.Context) (any, error) {
+		return job(ctx)
+	})
+	if err != nil {
+		if errors.Is(err, operations.ErrClosed) {
+			return nil, ErrOperationsClosed
+		}
+		s.logger.Error("failed to start operation", zap.String("type", kind), zap.Error(err))
+		return nil, ErrInternal
+	}
+
+	s.logger.Info("operation started", zap.String("type", kind), zap.String("operation_id", op.ID))
+	return s.newOperationResponse(op), nil
+}
+
+// newOperationResponse converts an operation to its transport representation.
+func (s *GRPCServer) newOperationResponse(op *operations.Operation) *apiv1.Operation {
+	resp := apiv1.Operation{
+		Name:      operationNamePrefix + op.ID,
+		Type:      op.Kind,
+		Done:      op.Done,
+		Processed: op.Processed,
+		Total:     op.Total,
+		CreatedAt: newTimestamp(op.CreatedAt),
+		UpdatedAt: newTimestamp(op.UpdatedAt),
+	}
+
+	if !op.Done {
+		return &resp
+	}
+
+	if op.Err != nil {
+		// The jobs return transport errors, anything else is unexpected.
+		st, ok := status.FromError(op.Err)
+		if !ok {
+			s.logger.Error("operation failed", zap.String("operation_id", op.ID), zap.Error(op.Err))
+			st = status.Convert(ErrInternal)
+		}
+		resp.Result = &apiv1.Operation_Error{Error: st.Proto()}
+		return &resp
+	}
+
+	msg, ok := op.Result.(proto.Message)
+	if !ok {
+		resp.Result = &apiv1.Operation_Error{Error: status.Convert(ErrInternal).Proto()}
+		return &resp
+	}
+
+	response, err := anypb.New(msg)
+	if err != nil {
+		s.logger.Error("failed to marshal operation response", zap.String("operation_id", op.ID), zap.Error(err))
+		resp.Result = &apiv1.Operation_Error{Error: status.Convert(ErrInternal).Proto()}
+		return &resp
+	}
+	resp.Result = &apiv1.Operation_Response{Response: response}
+	return &resp
+}
@@ -0,0 +1,243 @@
+package app
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alesr/usrsvc/internal/operations"
+	"github.com/alesr/usrsvc/internal/users/service"
+	apiv1 "github.com/alesr/usrsvc/proto/users/v1"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+)
+
+// waitOperationHelper polls the operation until it is done and returns it.
+func waitOperationHelper(t *testing.T, server *GRPCServer, name string) *apiv1.Operation {
+	t.Helper()
+
+	var op *apiv1.Operation
+	require.Eventually(t, func() bool {
+		var err error
+		op, err = server.GetOperation(context.TODO(), &apiv1.GetOperationRequest{Name: name})
+		require.NoError(t, err)
+		return op.Done
+	}, time.Second, time.Millisecond)
+	return op
+}
+
+func TestStartMergeUsers(t *testing.T) {
+	t.Parallel()
+
+	survivorID, duplicateID := uuid.New().String(), uuid.New().String()
+
+	t.Run("returns the merged user once done", func(t *testing.T) {
+		svc := &serviceMock{
+			MergeFunc: func(ctx context.Context, params service.MergeParams) (*service.User, error) {
+				return &service.User{ID: params.SurvivorID, Email: "joe.doe@foo.bar"}, nil
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		started, err := server.StartMergeUsers(context.TODO(), &apiv1.MergeUsersRequest{
+			SurvivorId:  survivorID,
+			DuplicateId: duplicateID,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, operationMergeUsers, started.Type)
+
+		op := waitOperationHelper(t, server, started.Name)
+
+		var observed apiv1.MergeUsersResponse
+		require.NoError(t, op.GetResponse().UnmarshalTo(&observed))
+		assert.Equal(t, survivorID, observed.User.Id)
+	})
+
+	t.Run("validates the request before starting", func(t *testing.T) {
+		server := NewGRPCServer(zap.NewNop(), &serviceMock{})
+
+		observed, err := server.StartMergeUsers(context.TODO(), &apiv1.MergeUsersRequest{
+			SurvivorId:  survivorID,
+			DuplicateId: "foo",
+		})
+
+		assert.Nil(t, observed)
+		assertStatusHelper(t, ErrIDFormat, err)
+	})
+
+	t.Run("returns the error of the merge once done", func(t *testing.T) {
+		svc := &serviceMock{
+			MergeFunc: func(ctx context.Context, params service.MergeParams) (*service.User, error) {
+				return nil, service.ErrMergeSameUser
+			},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), svc)
+
+		started, err := server.StartMergeUsers(context.TODO(), &apiv1.MergeUsersRequest{
+			SurvivorId:  survivorID,
+			DuplicateId: survivorID,
+		})
+		require.NoError(t, err)
+
+		op := waitOperationHelper(t, server, started.Name)
+
+		require.NotNil(t, op.GetError())
+		assert.Equal(t, int32(codes.InvalidArgument), op.GetError().Code)
+		assert.Nil(t, op.GetResponse())
+	})
+
+	t.Run("rejected once the operations are closed", func(t *testing.T) {
+		manager := operations.NewManager(time.Hour)
+		require.NoError(t, manager.Close(context.TODO()))
+
+		server := NewGRPCServer(zap.NewNop(), &serviceMock{}, WithOperations(manager))
+
+		observed, err := server.StartMergeUsers(context.TODO(), &apiv1.MergeUsersRequest{
+			SurvivorId:  survivorID,
+			DuplicateId: duplicateID,
+		})
+
+		assert.Nil(t, observed)
+		assert.Equal(t, ErrOperationsClosed, err)
+	})
+}
+
+func TestStartFindDuplicateUsers(t *testing.T) {
+	t.Parallel()
+
+	svc := &serviceMock{
+		FindDuplicatesFunc: func(ctx context.Context, filter service.FilterParams) (*service.DuplicateReport, error) {
+			assert.Equal(t, "BR", *filter.Country)
+			return &service.DuplicateReport{ScannedUsers: 42, GeneratedAt: time.Now()}, nil
+		},
+	}
+
+	server := NewGRPCServer(zap.NewNop(), svc)
+
+	started, err := server.StartFindDuplicateUsers(context.TODO(), &apiv1.FindDuplicateUsersRequest{Country: "BR"})
+	require.NoError(t, err)
+
+	op := waitOperationHelper(t, server, started.Name)
+
+	var observed apiv1.FindDuplicateUsersResponse
+	require.NoError(t, op.GetResponse().UnmarshalTo(&observed))
+	assert.Equal(t, int64(42), observed.ScannedUsers)
+}
+
+func TestStartDeleteUsersByFilter(t *testing.T) {
+	t.Parallel()
+
+	svc := &serviceMock{
+		DeleteUsersByFilterFunc: func(ctx context.Context, filter service.BulkDeleteFilter, dryRun bool) (*service.BulkDeleteReport, error) {
+			assert.Equal(t, "BR", *filter.Country)
+			assert.False(t, dryRun)
+			return &service.BulkDeleteReport{Scanned: 42, Matched: 40, Deleted: 40}, nil
+		},
+	}
+
+	server := NewGRPCServer(zap.NewNop(), svc)
+
+	started, err := server.StartDeleteUsersByFilter(context.TODO(), &apiv1.DeleteUsersByFilterRequest{Country: "BR"})
+	require.NoError(t, err)
+	assert.Equal(t, operationDeleteUsersByFilter, started.Type)
+
+	op := waitOperationHelper(t, server, started.Name)
+
+	var observed apiv1.DeleteUsersByFilterResponse
+	require.NoError(t, op.GetResponse().UnmarshalTo(&observed))
+	assert.Equal(t, int64(40), observed.DeletedUsers)
+}
+
+func TestGetOperation(t *testing.T) {
+	t.Parallel()
+
+	server := NewGRPCServer(zap.NewNop(), &serviceMock{})
+
+	testCases := []struct {
+		name        string
+		given       string
+		expectedErr error
+	}{
+		{
+			name:        "missing prefix",
+			given:       uuid.New().String(),
+			expectedErr: ErrOperationNameFormat,
+		},
+		{
+			name:        "missing id",
+			given:       operationNamePrefix,
+			expectedErr: ErrOperationNameFormat,
+		},
+		{
+			name:        "unknown operation",
+			given:       operationNamePrefix + uuid.New().String(),
+			expectedErr: ErrOperationNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			observed, err := server.GetOperation(context.TODO(), &apiv1.GetOperationRequest{Name: tc.given})
+
+			assert.Nil(t, observed)
+			assert.Equal(t, tc.expectedErr, err)
+		})
+	}
+}
+
+func TestListOperations(t *testing.T) {
+	t.Parallel()
+
+	svc := &serviceMock{
+		MergeFunc: func(ctx context.Context, params service.MergeParams) (*service.User, error) {
+			return &service.User{ID: params.SurvivorID}, nil
+		},
+		FindDuplicatesFunc: func(ctx context.Context, filter service.FilterParams) (*service.DuplicateReport, error) {
+			return &service.DuplicateReport{}, nil
+		},
+	}
+
+	server := NewGRPCServer(zap.NewNop(), svc)
+
+	var names []string
+	for i := 0; i < 3; i++ {
+		op, err := server.StartMergeUsers(context.TODO(), &apiv1.MergeUsersRequest{
+			SurvivorId:  uuid.New().String(),
+			DuplicateId: uuid.New().String(),
+		})
+		require.NoError(t, err)
+		names = append(names, op.Name)
+	}
+
+	_, err := server.StartFindDuplicateUsers(context.TODO(), &apiv1.FindDuplicateUsersRequest{})
+	require.NoError(t, err)
+
+	first, err := server.ListOperations(context.TODO(), &apiv1.ListOperationsRequest{Type: operationMergeUsers, PageSize: 2})
+	require.NoError(t, err)
+
+	second, err := server.ListOperations(context.TODO(), &apiv1.ListOperationsRequest{
+		Type:      operationMergeUsers,
+		PageSize:  2,
+		PageToken: first.NextPageToken,
+	})
+	require.NoError(t, err)
+
+	require.Len(t, first.Operations, 2)
+	assert.Equal(t, names[2], first.Operations[0].Name)
+	assert.Equal(t, names[1], first.Operations[1].Name)
+
+	require.Len(t, second.Operations, 1)
+	assert.Equal(t, names[0], second.Operations[0].Name)
+	assert.Empty(t, second.NextPageToken)
+
+	_, err = server.ListOperations(context.TODO(), &apiv1.ListOperationsRequest{PageToken: "foo"})
+	assert.Equal(t, ErrPageTokenInvalid, err)
+}
@@ -0,0 +1,194 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/alesr/usrsvc/internal/policies"
+	"github.com/alesr/usrsvc/internal/tenant"
+	"github.com/alesr/usrsvc/pkg/uservalidation"
+	apiv1 "github.com/alesr/usrsvc/proto/users/v1"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+)
+
+// PolicyStore stores the validation policies of the tenants.
+type PolicyStore interface {
+	Get(ctx context.Context, tenant string) (*policies.Policy, error)
+	Put(ctx context.Context, policy *policies.Policy) error
+}
+
+// cachedPolicy is a policy of TenantPolicies cached until expiresAt.
+type cachedPolicy struct {
+	policy    *policies.Policy
+	expiresAt time.Time
+}
+
+// TenantPolicies resolves the validation policy of the tenant of every RPC, so its
+// requests are validated with the overrides of the tenant. The policies are cached
+// for the TTL: a policy set on an instance takes up to the TTL to apply on the others.
+type TenantPolicies struct {
+	logger *zap.Logger
+	store  PolicyStore
+	ttl    time.Duration
+
+	mu     sync.Mutex
+	cached map[string]cachedPolicy
+}
+
+// NewTenantPolicies creates a new tenant policies interceptor caching the policies for the TTL.
+func NewTenantPolicies(logger *zap.Logger, store PolicyStore, ttl time.Duration) *TenantPolicies {
+	return &TenantPolicies{
+		logger: logger,
+		store:  store,
+		ttl:    ttl,
+		cached: make(map[string]cachedPolicy),
+	}
+}
+
+// WithTenantPolicies configures the policies managed by the tenant policy RPCs. It must be
+// the instance intercepting the RPCs, to apply the policies set on the instance right away.
+func WithTenantPolicies(tenantPolicies *TenantPolicies) ServerOption {
+	return func(s *GRPCServer) {
+		s.policies = tenantPolicies
+	}
+}
+
+// UnaryServerInterceptor sets the validation policy of the tenant of the RPC. It must
+// come after the tenancy, which scopes the RPC to its tenant.
+func (p *TenantPolicies) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := p.apply(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor sets the validation policy of the streaming RPCs like UnaryServerInterceptor.
+func (p *TenantPolicies) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := p.apply(ss.Context())
+		if err != nil {
+			return err
+		}
+		return handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// apply returns the context with the validation policy of its tenant.
+func (p *TenantPolicies) apply(ctx context.Context) (context.Context, error) {
+	policy, err := p.get(ctx, tenant.OrDefault(ctx))
+	if err != nil {
+		p.logger.Error("failed to get tenant policy", zap.Error(err))
+		return nil, ErrInternal
+	}
+	return contextWithPolicy(ctx, policy.Apply(userValidation)), nil
+}
+
+// get returns the policy of the tenant, from the cache while fresh. The tenants without
+// a policy get an empty one, keeping the defaults.
+func (p *TenantPolicies) get(ctx context.Context, id string) (*policies.Policy, error) {
+	p.mu.Lock()
+	cached, ok := p.cached[id]
+	p.mu.Unlock()
+
+	if ok && time.Now().Before(cached.expiresAt) {
+		return cached.policy, nil
+	}
+
+	policy, err := p.store.Get(ctx, id)
+	if err != nil {
+		if !errors.Is(err, policies.ErrNotFound) {
+			return nil, err
+		}
+		policy = &policies.Policy{Tenant: id}
+	}
+
+	p.cache(policy)
+	return policy, nil
+}
+
+// put stores the policy and caches it.
+func (p *TenantPolicies) put(ctx context.Context, policy *policies.Policy) error {
+	if err := p.store.Put(ctx, policy); err != nil {
+		return err
+	}
+
+	p.cache(policy)
+	return nil
+}
+
+func (p *TenantPolicies) cache(policy *policies.Policy) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.cached[policy.Tenant] = cachedPolicy{policy: policy, expiresAt: time.Now().Add(p.ttl)}
+}
+
+type policyKey struct{}
+
+// contextWithPolicy returns a copy of ctx with the validation policy of its tenant.
+func contextWithPolicy(ctx context.Context, policy uservalidation.Policy) context.Context {
+	return context.WithValue(ctx, policyKey{}, policy)
+}
+
+// policyFromContext returns the validation policy of the tenant of ctx, or the default
+// one without tenant policies.
+func policyFromContext(ctx context.Context) uservalidation.Policy {
+	if policy, ok := ctx.Value(policyKey{}).(uservalidation.Policy); ok {
+		return policy
+	}
+	return userValidation
+}
+
+// GetTenantPolicy returns the validation policy of the tenant of the caller. Its zero
+// values are the server defaults.
+func (s *GRPCServer) GetTenantPolicy(ctx context.Context, req *apiv1.GetTenantPolicyRequest) (*apiv1.GetTenantPolicyResponse, error) {
+	if req == nil {
+		return nil, ErrRequestRequired
+	}
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	policy, err := s.policies.get(ctx, tenant.OrDefault(ctx))
+	if err != nil {
+		s.logger.Error("failed to get tenant policy", zap.Error(err))
+		return nil, ErrInternal
+	}
+	return &apiv1.GetTenantPolicyResponse{Policy: newTenantPolicyResponseFromDomain(policy)}, nil
+}
+
+// SetTenantPolicy replaces the validation policy of the tenant of the caller. It applies
+// to the following requests, while the stored users are left as they are.
+func (s *GRPCServer) SetTenantPolicy(ctx context.Context, req *apiv1.SetTenantPolicyRequest) (*apiv1.SetTenantPolicyResponse, error) {
+	if req == nil {
+		return nil, ErrRequestRequired
+	}
+
+	if err := validateSetTenantPolicyRequest(req); err != nil {
+		s.logger.Error("failed to validate request", zap.Error(err))
+		return nil, err
+	}
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	policy := &policies.Policy{
+		Tenant:            tenant.OrDefault(ctx),
+		MinPasswordLength: int(req.Policy.MinPasswordLength),
+		NameSeparators:    req.Policy.NameSeparators,
+		MinAge:            int(req.Policy.MinAge),
+		UpdatedAt:         time.Now().UTC(),
+	}
+
+	if err := s.policies.put(ctx, policy); err != nil {
+		s.logger.Error("failed to set tenant policy", zap.Error(err))
+		return nil, ErrInternal
+	}
+	return &apiv1.SetTenantPolicyResponse{Policy: newTenantPolicyResponseFromDomain(policy)}, nil
+}
@@ -0,0 +1,238 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alesr/usrsvc/internal/policies"
+	"github.com/alesr/usrsvc/internal/tenant"
+	apiv1 "github.com/alesr/usrsvc/proto/users/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// policyStoreMock counts the policies read from the store.
+type policyStoreMock struct {
+	*policies.Memory
+	gets int
+	err  error
+}
+
+func (m *policyStoreMock) Get(ctx context.Context, tenant string) (*policies.Policy, error) {
+	m.gets++
+	if m.err != nil {
+		return nil, m.err
+	}
+	return m.Memory.Get(ctx, tenant)
+}
+
+func TestTenantPoliciesUnaryServerInterceptor(t *testing.T) {
+	t.Parallel()
+
+	createUser := func(password string) *apiv1.CreateUserRequest {
+		return &apiv1.CreateUserRequest{
+			FirstName: "John",
+			LastName:  "Doe",
+			Nickname:  "johndoe",
+			Email:     "joedoe@foo.bar",
+			Password:  password,
+			Country:   "BR",
+		}
+	}
+
+	store := &policyStoreMock{Memory: policies.NewMemory()}
+	require.NoError(t, store.Put(context.TODO(), &policies.Policy{Tenant: "acme", MinPasswordLength: 12}))
+
+	interceptor := NewTenantPolicies(zap.NewNop(), store, time.Minute).UnaryServerInterceptor()
+
+	validate := func(ctx context.Context, req any) (any, error) {
+		return nil, validateRequest(ctx, req.(*apiv1.CreateUserRequest))
+	}
+
+	testCases := []struct {
+		name        string
+		tenant      string
+		given       *apiv1.CreateUserRequest
+		expectedErr codes.Code
+	}{
+		{
+			name:   "default policy",
+			tenant: tenant.Default,
+			given:  createUser("passw0rd!"),
+		},
+		{
+			name:        "password too short for the tenant",
+			tenant:      "acme",
+			given:       createUser("passw0rd!"),
+			expectedErr: codes.InvalidArgument,
+		},
+		{
+			name:   "password long enough for the tenant",
+			tenant: "acme",
+			given:  createUser("long-passw0rd!"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			_, err := interceptor(
+				tenant.ContextWithTenant(context.TODO(), tc.tenant),
+				tc.given,
+				&grpc.UnaryServerInfo{FullMethod: "/UserService/CreateUser"},
+				validate,
+			)
+
+			// Assert
+			assert.Equal(t, tc.expectedErr, status.Code(err))
+		})
+	}
+
+	// The policies are read once per tenant, then cached.
+	assert.Equal(t, 2, store.gets)
+}
+
+func TestTenantPoliciesStoreFailure(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	store := &policyStoreMock{Memory: policies.NewMemory(), err: errors.New("connection refused")}
+	interceptor := NewTenantPolicies(zap.NewNop(), store, time.Minute).UnaryServerInterceptor()
+
+	// Act
+	_, err := interceptor(
+		context.TODO(),
+		nil,
+		&grpc.UnaryServerInfo{FullMethod: "/UserService/GetUser"},
+		func(ctx context.Context, req any) (any, error) { return "ok", nil },
+	)
+
+	// Assert
+	assert.Equal(t, ErrInternal, err)
+}
+
+func TestSetTenantPolicy(t *testing.T) {
+	t.Parallel()
+
+	t.Run("policy of the caller's tenant", func(t *testing.T) {
+		// Arrange
+		tenantPolicies := NewTenantPolicies(zap.NewNop(), policies.NewMemory(), time.Minute)
+		server := NewGRPCServer(zap.NewNop(), &serviceMock{}, WithTenantPolicies(tenantPolicies))
+		ctx := tenant.ContextWithTenant(context.TODO(), "acme")
+
+		// Act
+		set, err := server.SetTenantPolicy(ctx, &apiv1.SetTenantPolicyRequest{
+			Policy: &apiv1.TenantPolicy{Tenant: "globex", MinPasswordLength: 12, NameSeparators: "-.", MinAge: 16},
+		})
+		require.NoError(t, err)
+
+		observed, err := server.GetTenantPolicy(ctx, &apiv1.GetTenantPolicyRequest{})
+		require.NoError(t, err)
+
+		other, err := server.GetTenantPolicy(context.TODO(), &apiv1.GetTenantPolicyRequest{})
+		require.NoError(t, err)
+
+		// Assert
+		assert.Equal(t, "acme", set.Policy.Tenant)
+		assert.NotNil(t, set.Policy.UpdatedAt)
+
+		assert.Equal(t, "acme", observed.Policy.Tenant)
+		assert.Equal(t, int32(12), observed.Policy.MinPasswordLength)
+		assert.Equal(t, "-.", observed.Policy.NameSeparators)
+		assert.Equal(t, int32(16), observed.Policy.MinAge)
+
+		assert.Equal(t, tenant.Default, other.Policy.Tenant)
+		assert.Zero(t, other.Policy.MinPasswordLength)
+		assert.Nil(t, other.Policy.UpdatedAt)
+
+		// The policy applies right away on the instance it was set on.
+		ctx, err = tenantPolicies.apply(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 12, policyFromContext(ctx).MinPasswordLength)
+	})
+
+	t.Run("invalid policies", func(t *testing.T) {
+		testCases := []struct {
+			name     string
+			given    *apiv1.TenantPolicy
+			expected error
+		}{
+			{name: "missing policy", expected: ErrTenantPolicyRequired},
+			{name: "negative min password length", given: &apiv1.TenantPolicy{MinPasswordLength: -1}, expected: ErrMinPasswordLengthInvalid},
+			{name: "min password length over the max", given: &apiv1.TenantPolicy{MinPasswordLength: 129}, expected: ErrMinPasswordLengthInvalid},
+			{name: "min age too high", given: &apiv1.TenantPolicy{MinAge: 121}, expected: ErrMinAgeInvalid},
+			{name: "letter separator", given: &apiv1.TenantPolicy{NameSeparators: "-a"}, expected: ErrNameSeparatorsInvalid},
+			{name: "space separator", given: &apiv1.TenantPolicy{NameSeparators: " "}, expected: ErrNameSeparatorsInvalid},
+			{name: "too many separators", given: &apiv1.TenantPolicy{NameSeparators: "-'.,:;!?&/()[]{}*"}, expected: ErrNameSeparatorsInvalid},
+		}
+
+		server := NewGRPCServer(zap.NewNop(), &serviceMock{})
+
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				_, err := server.SetTenantPolicy(context.TODO(), &apiv1.SetTenantPolicyRequest{Policy: tc.given})
+				assertStatusHelper(t, tc.expected, err)
+			})
+		}
+	})
+}
+
+func TestValidateWithTenantPolicy(t *testing.T) {
+	t.Parallel()
+
+	policy := userValidation
+	policy.MinPasswordLength = 12
+	policy.NameSeparators = "."
+	policy.MinAge = 16
+
+	ctx := contextWithPolicy(context.TODO(), policy)
+
+	testCases := []struct {
+		name     string
+		given    *apiv1.CreateUserRequest
+		expected string
+	}{
+		{
+			name: "password too short",
+			given: &apiv1.CreateUserRequest{
+				FirstName: "John", LastName: "Doe", Nickname: "johndoe", Email: "joedoe@foo.bar", Country: "BR",
+				Password: "passw0rd!",
+			},
+			expected: "password must be between 12 and 128 characters",
+		},
+		{
+			name: "separator not allowed",
+			given: &apiv1.CreateUserRequest{
+				FirstName: "Jean-Luc", LastName: "Doe", Nickname: "johndoe", Email: "joedoe@foo.bar", Country: "BR",
+				Password: "long-passw0rd!",
+			},
+			expected: `name must only contain letters, spaces and "."`,
+		},
+		{
+			name: "under the minimum age",
+			given: &apiv1.CreateUserRequest{
+				FirstName: "J. R.", LastName: "Doe", Nickname: "johndoe", Email: "joedoe@foo.bar", Country: "BR",
+				Password: "long-passw0rd!", Birthdate: time.Now().AddDate(-15, 0, 0).Format("2006-01-02"),
+			},
+			expected: status.Convert(ErrUnderMinimumAge).Message(),
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := validateRequest(ctx, tc.given)
+
+			assert.Equal(t, codes.InvalidArgument, status.Code(err))
+			assert.Equal(t, tc.expected, status.Convert(err).Message())
+		})
+	}
+}
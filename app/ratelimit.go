@@ -0,0 +1,208 @@
+package app
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+const (
+	// retryAfterHeader carries the number of seconds to wait before retrying a rate limited RPC.
+	retryAfterHeader string = "retry-after"
+
+	// bucketSweepInterval is how often the buckets of the idle clients are dropped.
+	bucketSweepInterval time.Duration = time.Minute
+)
+
+// RateLimit is a token bucket allowing Rate requests per second on average, with bursts
+// of up to Burst requests. A zero Rate means no limit.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// RateLimiter limits the rate of the RPCs per client. The clients are identified by
+// their API key, when authenticated, and by their IP otherwise. The RPCs with a limit of
+// their own, e.g. CreateUser, get a separate bucket per client instead of the default one.
+type RateLimiter struct {
+	logger            *zap.Logger
+	limit             RateLimit
+	methodLimits      map[string]RateLimit
+	trustForwardedFor bool
+	now               func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[bucketKey]*tokenBucket
+	lastSweep time.Time
+}
+
+type bucketKey struct {
+	client string
+	method string
+}
+
+type tokenBucket struct {
+	limit     RateLimit
+	tokens    float64
+	updatedAt time.Time
+}
+
+// NewRateLimiter creates a rate limiter with the default limit and the limits of the
+// RPCs keyed by method name. With trustForwardedFor, the client IP is taken from the
+// x-forwarded-for metadata, see WithTrustForwardedFor.
+func NewRateLimiter(logger *zap.Logger, limit RateLimit, methodLimits map[string]RateLimit, trustForwardedFor bool) *RateLimiter {
+	return &RateLimiter{
+		logger:            logger,
+		limit:             limit,
+		methodLimits:      methodLimits,
+		trustForwardedFor: trustForwardedFor,
+		now:               time.Now,
+		buckets:           make(map[bucketKey]*tokenBucket),
+	}
+}
+
+// UnaryServerInterceptor rejects the unary RPCs over the rate limit with ResourceExhausted.
+// It must come after the authorization to identify the clients by API key.
+func (r *RateLimiter) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if retryAfter := r.reserve(ctx, info.FullMethod); retryAfter > 0 {
+			if err := grpc.SetHeader(ctx, retryAfterMetadata(retryAfter)); err != nil {
+				r.logger.Debug("failed to set retry-after header", zap.Error(err))
+			}
+			return nil, rateLimitedError(retryAfter)
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor rejects the streaming RPCs over the rate limit with ResourceExhausted.
+func (r *RateLimiter) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if retryAfter := r.reserve(ss.Context(), info.FullMethod); retryAfter > 0 {
+			if err := ss.SetHeader(retryAfterMetadata(retryAfter)); err != nil {
+				r.logger.Debug("failed to set retry-after header", zap.Error(err))
+			}
+			return rateLimitedError(retryAfter)
+		}
+		return handler(srv, ss)
+	}
+}
+
+// reserve takes a token from the bucket of the client for the method. It returns how long
+// to wait for the next token if there is none left, or zero if the RPC is allowed.
+func (r *RateLimiter) reserve(ctx context.Context, fullMethod string) time.Duration {
+	method := fullMethod[strings.LastIndex(fullMethod, "/")+1:]
+
+	key := bucketKey{client: r.client(ctx)}
+	if key.client == "" {
+		// Local callers, e.g. over the Unix socket, aren't limited.
+		return 0
+	}
+
+	limit, ok := r.methodLimits[method]
+	if ok {
+		key.method = method
+	} else {
+		limit = r.limit
+	}
+
+	if limit.Rate <= 0 {
+		return 0
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	r.sweep(now)
+
+	bucket, ok := r.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{limit: limit, tokens: float64(limit.Burst), updatedAt: now}
+		r.buckets[key] = bucket
+	}
+
+	retryAfter := bucket.take(now)
+	if retryAfter > 0 {
+		r.logger.Warn("rate limit exceeded", zap.String("method", method), zap.Duration("retry_after", retryAfter))
+	}
+	return retryAfter
+}
+
+// client identifies the client of the RPC, or returns an empty string if it is unknown.
+func (r *RateLimiter) client(ctx context.Context) string {
+	if _, ok := CallerFromContext(ctx); ok {
+		md, _ := metadata.FromIncomingContext(ctx)
+		if values := md.Get(authorizationHeader); len(values) > 0 {
+			return "key:" + strings.TrimPrefix(values[0], bearerPrefix)
+		}
+
+		if values := md.Get(apiKeyHeader); len(values) > 0 {
+			return "key:" + values[0]
+		}
+	}
+
+	if ip := clientIP(ctx, r.trustForwardedFor); ip != nil {
+		return "ip:" + ip.String()
+	}
+	return ""
+}
+
+// sweep drops the buckets refilled since the last sweep, as they are no different
+// from new ones. The caller must hold the lock.
+func (r *RateLimiter) sweep(now time.Time) {
+	if now.Sub(r.lastSweep) < bucketSweepInterval {
+		return
+	}
+	r.lastSweep = now
+
+	for key, bucket := range r.buckets {
+		if bucket.refill(now) >= float64(bucket.limit.Burst) {
+			delete(r.buckets, key)
+		}
+	}
+}
+
+// refill adds the tokens earned since the last update and returns the tokens available.
+func (b *tokenBucket) refill(now time.Time) float64 {
+	b.tokens = math.Min(float64(b.limit.Burst), b.tokens+now.Sub(b.updatedAt).Seconds()*b.limit.Rate)
+	b.updatedAt = now
+	return b.tokens
+}
+
+// take takes a token from the bucket. It returns how long to wait for the next token
+// if there is none left, or zero if it took one.
+func (b *tokenBucket) take(now time.Time) time.Duration {
+	if b.refill(now) >= 1 {
+		b.tokens--
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.limit.Rate * float64(time.Second))
+}
+
+// retryAfterMetadata returns the retry-after header in whole seconds, rounded up.
+func retryAfterMetadata(retryAfter time.Duration) metadata.MD {
+	seconds := int64(math.Ceil(retryAfter.Seconds()))
+	return metadata.Pairs(retryAfterHeader, strconv.FormatInt(seconds, 10))
+}
+
+// rateLimitedError returns ErrRateLimited with a RetryInfo detail.
+func rateLimitedError(retryAfter time.Duration) error {
+	st, err := status.Convert(ErrRateLimited).WithDetails(&errdetails.RetryInfo{
+		RetryDelay: durationpb.New(retryAfter),
+	})
+	if err != nil {
+		return ErrRateLimited
+	}
+	return st.Err()
+}
@@ -0,0 +1,153 @@
+package app
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/alesr/usrsvc/internal/users/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func peerContextHelper(ip string) context.Context {
+	return peer.NewContext(context.TODO(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(ip), Port: 4242}})
+}
+
+func TestRateLimiterUnaryServerInterceptor(t *testing.T) {
+	t.Parallel()
+
+	handler := func(ctx context.Context, req any) (any, error) {
+		return "ok", nil
+	}
+
+	call := func(r *RateLimiter, ctx context.Context, method string) error {
+		_, err := r.UnaryServerInterceptor()(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/UserService/" + method}, handler)
+		return err
+	}
+
+	t.Run("rejects the requests over the burst until the bucket refills", func(t *testing.T) {
+		// Arrange
+		r := NewRateLimiter(zap.NewNop(), RateLimit{Rate: 2, Burst: 2}, nil, false)
+		now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+		r.now = func() time.Time { return now }
+
+		ctx := peerContextHelper("10.0.0.1")
+
+		// Act
+		first, second, third := call(r, ctx, "GetUser"), call(r, ctx, "GetUser"), call(r, ctx, "GetUser")
+
+		now = now.Add(500 * time.Millisecond)
+		refilled := call(r, ctx, "GetUser")
+
+		// Assert
+		require.NoError(t, first)
+		require.NoError(t, second)
+		require.NoError(t, refilled)
+
+		st := status.Convert(third)
+		assert.Equal(t, codes.ResourceExhausted, st.Code())
+
+		require.Len(t, st.Details(), 1)
+		retryInfo, ok := st.Details()[0].(*errdetails.RetryInfo)
+		require.True(t, ok)
+		assert.Equal(t, 500*time.Millisecond, retryInfo.RetryDelay.AsDuration())
+	})
+
+	t.Run("limits each client separately", func(t *testing.T) {
+		// Arrange
+		r := NewRateLimiter(zap.NewNop(), RateLimit{Rate: 1, Burst: 1}, nil, false)
+
+		// Act
+		first := call(r, peerContextHelper("10.0.0.1"), "GetUser")
+		other := call(r, peerContextHelper("10.0.0.2"), "GetUser")
+		again := call(r, peerContextHelper("10.0.0.1"), "GetUser")
+
+		// Assert
+		assert.NoError(t, first)
+		assert.NoError(t, other)
+		assert.Equal(t, codes.ResourceExhausted, status.Code(again))
+	})
+
+	t.Run("identifies the authenticated clients by api key", func(t *testing.T) {
+		// Arrange
+		r := NewRateLimiter(zap.NewNop(), RateLimit{Rate: 1, Burst: 1}, nil, false)
+
+		withKey := func(apiKey string) context.Context {
+			ctx := metadata.NewIncomingContext(peerContextHelper("10.0.0.1"), metadata.Pairs(authorizationHeader, bearerPrefix+apiKey))
+			return context.WithValue(ctx, callerKey{}, &service.User{ID: "user-id"})
+		}
+
+		// Act
+		first := call(r, withKey("first-key"), "GetUser")
+		second := call(r, withKey("second-key"), "GetUser")
+		again := call(r, withKey("first-key"), "GetUser")
+
+		// Assert
+		assert.NoError(t, first)
+		assert.NoError(t, second)
+		assert.Equal(t, codes.ResourceExhausted, status.Code(again))
+	})
+
+	t.Run("applies the limits of the methods", func(t *testing.T) {
+		// Arrange
+		r := NewRateLimiter(zap.NewNop(), RateLimit{}, map[string]RateLimit{"CreateUser": {Rate: 1, Burst: 1}}, false)
+		ctx := peerContextHelper("10.0.0.1")
+
+		// Act
+		created := call(r, ctx, "CreateUser")
+		limited := call(r, ctx, "CreateUser")
+		unlimited := call(r, ctx, "GetUser")
+
+		// Assert
+		assert.NoError(t, created)
+		assert.Equal(t, codes.ResourceExhausted, status.Code(limited))
+		assert.NoError(t, unlimited)
+	})
+
+	t.Run("doesn't limit the local clients", func(t *testing.T) {
+		// Arrange
+		r := NewRateLimiter(zap.NewNop(), RateLimit{Rate: 1, Burst: 1}, nil, false)
+
+		// Act
+		first, second := call(r, context.TODO(), "GetUser"), call(r, context.TODO(), "GetUser")
+
+		// Assert
+		assert.NoError(t, first)
+		assert.NoError(t, second)
+	})
+}
+
+func TestRateLimiterSweep(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	r := NewRateLimiter(zap.NewNop(), RateLimit{Rate: 1, Burst: 2}, nil, false)
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { return now }
+
+	r.reserve(peerContextHelper("10.0.0.1"), "/UserService/GetUser")
+
+	// Act
+	now = now.Add(bucketSweepInterval)
+	r.reserve(peerContextHelper("10.0.0.2"), "/UserService/GetUser")
+
+	// Assert
+	assert.Len(t, r.buckets, 1)
+	assert.Contains(t, r.buckets, bucketKey{client: "ip:10.0.0.2"})
+}
+
+func TestRetryAfterMetadata(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, []string{"1"}, retryAfterMetadata(200*time.Millisecond).Get(retryAfterHeader))
+	assert.Equal(t, []string{"2"}, retryAfterMetadata(1500*time.Millisecond).Get(retryAfterHeader))
+}
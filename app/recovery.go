@@ -0,0 +1,68 @@
+package app
+
+import (
+	"context"
+	"runtime/debug"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+)
+
+// PanicObserver is notified of every panic recovered from an RPC.
+type PanicObserver interface {
+	ObservePanic(method string)
+}
+
+// Recovery recovers the panics of the RPCs, which would otherwise take down the whole
+// process, and fails the RPC with Internal instead.
+type Recovery struct {
+	logger   *zap.Logger
+	observer PanicObserver
+}
+
+// NewRecovery creates a recovery interceptor logging the panics and reporting them
+// to the observer, if not nil.
+func NewRecovery(logger *zap.Logger, observer PanicObserver) *Recovery {
+	return &Recovery{
+		logger:   logger,
+		observer: observer,
+	}
+}
+
+// UnaryServerInterceptor recovers the panics of the unary RPCs.
+func (r *Recovery) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				r.recovered(info.FullMethod, p)
+				resp, err = nil, ErrInternal
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor recovers the panics of the streaming RPCs.
+func (r *Recovery) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				r.recovered(info.FullMethod, p)
+				err = ErrInternal
+			}
+		}()
+		return handler(srv, ss)
+	}
+}
+
+func (r *Recovery) recovered(method string, p any) {
+	r.logger.Error("recovered from panic",
+		zap.String("method", method),
+		zap.Any("panic", p),
+		zap.ByteString("stack", debug.Stack()),
+	)
+
+	if r.observer != nil {
+		r.observer.ObservePanic(method)
+	}
+}
@@ -0,0 +1,100 @@
+package app
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc"
+)
+
+type panicObserverMock struct {
+	methods []string
+}
+
+func (o *panicObserverMock) ObservePanic(method string) {
+	o.methods = append(o.methods, method)
+}
+
+func TestRecoveryUnaryServerInterceptor(t *testing.T) {
+	t.Parallel()
+
+	t.Run("converts the panic to an internal error", func(t *testing.T) {
+		// Arrange
+		core, logs := observer.New(zap.ErrorLevel)
+		panics := &panicObserverMock{}
+		recovery := NewRecovery(zap.New(core), panics)
+
+		handler := func(ctx context.Context, req any) (any, error) {
+			panic("boom")
+		}
+
+		// Act
+		resp, err := recovery.UnaryServerInterceptor()(
+			context.TODO(),
+			nil,
+			&grpc.UnaryServerInfo{FullMethod: "/UserService/GetUser"},
+			handler,
+		)
+
+		// Assert
+		assert.Nil(t, resp)
+		assert.Equal(t, ErrInternal, err)
+		assert.Equal(t, []string{"/UserService/GetUser"}, panics.methods)
+
+		require.Equal(t, 1, logs.Len())
+		entry := logs.All()[0]
+		assert.Equal(t, "boom", entry.ContextMap()["panic"])
+		assert.Contains(t, entry.ContextMap()["stack"], "recovery.go")
+	})
+
+	t.Run("passes the response through", func(t *testing.T) {
+		// Arrange
+		panics := &panicObserverMock{}
+		recovery := NewRecovery(zap.NewNop(), panics)
+
+		handler := func(ctx context.Context, req any) (any, error) {
+			return "ok", nil
+		}
+
+		// Act
+		resp, err := recovery.UnaryServerInterceptor()(
+			context.TODO(),
+			nil,
+			&grpc.UnaryServerInfo{FullMethod: "/UserService/GetUser"},
+			handler,
+		)
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, "ok", resp)
+		assert.Empty(t, panics.methods)
+	})
+}
+
+func TestRecoveryStreamServerInterceptor(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	panics := &panicObserverMock{}
+	recovery := NewRecovery(zap.NewNop(), panics)
+
+	handler := func(srv any, ss grpc.ServerStream) error {
+		panic("boom")
+	}
+
+	// Act
+	err := recovery.StreamServerInterceptor()(
+		nil,
+		nil,
+		&grpc.StreamServerInfo{FullMethod: "/UserService/WatchUsers"},
+		handler,
+	)
+
+	// Assert
+	assert.Equal(t, ErrInternal, err)
+	assert.Equal(t, []string{"/UserService/WatchUsers"}, panics.methods)
+}
@@ -3,18 +3,64 @@ package app
 import (
 	"context"
 
+	"github.com/alesr/usrsvc/internal/audit"
 	"github.com/alesr/usrsvc/internal/users/service"
 )
 
 var _ userService = (*serviceMock)(nil)
 
 type serviceMock struct {
-	FetchFunc              func(ctx context.Context, id string) (*service.User, error)
-	FetchAllFunc           func(ctx context.Context, filter service.FilterParams, pag service.PaginationParams) ([]*service.User, error)
-	CreateFunc             func(ctx context.Context, user *service.User) (*service.User, error)
-	UpdateFunc             func(ctx context.Context, user *service.User) (*service.User, error)
-	DeleteFunc             func(ctx context.Context, id string) error
-	CheckServiceHealthFunc func(ctx context.Context) error
+	FetchFunc                    func(ctx context.Context, id string) (*service.User, error)
+	FetchAllFunc                 func(ctx context.Context, filter service.FilterParams, pag service.PaginationParams) ([]*service.User, error)
+	CountFunc                    func(ctx context.Context, filter service.FilterParams) (int64, error)
+	SearchFunc                   func(ctx context.Context, query string, offset, limit int) ([]*service.User, error)
+	CreateFunc                   func(ctx context.Context, user *service.User) (*service.User, error)
+	ImportUsersFunc              func(ctx context.Context, next func() (*service.ImportRecord, error)) (*service.ImportReport, error)
+	CheckNicknameAvailableFunc   func(ctx context.Context, nickname, country string) (bool, error)
+	UpdateFunc                   func(ctx context.Context, user *service.User) (*service.User, error)
+	DeleteFunc                   func(ctx context.Context, id string) error
+	SuspendUserFunc              func(ctx context.Context, id string) (*service.User, error)
+	DeactivateUserFunc           func(ctx context.Context, id string) (*service.User, error)
+	ReactivateUserFunc           func(ctx context.Context, id string) (*service.User, error)
+	SetAvatarFunc                func(ctx context.Context, id string, image []byte) (*service.User, error)
+	GetAvatarFunc                func(ctx context.Context, id string) (*service.Avatar, error)
+	RequestPhoneVerificationFunc func(ctx context.Context, id string) error
+	VerifyPhoneFunc              func(ctx context.Context, id, code string) (*service.User, error)
+	AnonymizeUserFunc            func(ctx context.Context, id string) (*service.User, error)
+	AuthenticateFunc             func(ctx context.Context, email, password, totpCode string) (*service.User, error)
+	StatsFunc                    func(ctx context.Context) (*service.UserStats, error)
+	DeleteUsersByFilterFunc      func(ctx context.Context, filter service.BulkDeleteFilter, dryRun bool) (*service.BulkDeleteReport, error)
+	ExportUsersFunc              func(ctx context.Context, filter service.FilterParams, fn func(user *service.User) error) error
+	FindDuplicatesFunc           func(ctx context.Context, filter service.FilterParams) (*service.DuplicateReport, error)
+	MergeFunc                    func(ctx context.Context, params service.MergeParams) (*service.User, error)
+	BootstrapFunc                func(ctx context.Context, token string, admin *service.User, keyName string) (*service.User, string, error)
+	CreateAPIKeyFunc             func(ctx context.Context, userID, name string, scopes []string) (*service.APIKey, string, error)
+	RevokeAPIKeyFunc             func(ctx context.Context, id string) error
+	APIKeysFunc                  func(ctx context.Context, userID string) ([]*service.APIKey, error)
+	AuthenticateAPIKeyFunc       func(ctx context.Context, apiKey string) (*service.User, *service.APIKey, error)
+	AuthenticateAccessTokenFunc  func(ctx context.Context, accessToken string) (*service.User, error)
+	CreateSessionFunc            func(ctx context.Context, userID string) (*service.SessionTokens, error)
+	RefreshSessionFunc           func(ctx context.Context, refreshToken string) (*service.SessionTokens, error)
+	RevokeSessionFunc            func(ctx context.Context, id string) error
+	RevokeRefreshTokenFunc       func(ctx context.Context, refreshToken string) error
+	SessionsFunc                 func(ctx context.Context, userID string) ([]*service.Session, error)
+	LinkIdentityFunc             func(ctx context.Context, userID, provider, idToken string) (*service.LinkedIdentity, error)
+	LinkedIdentitiesFunc         func(ctx context.Context, userID string) ([]*service.LinkedIdentity, error)
+	UnlinkIdentityFunc           func(ctx context.Context, userID, provider, subject string) error
+	FetchByIdentityFunc          func(ctx context.Context, provider, subject string) (*service.User, error)
+	AuthenticateWithIDTokenFunc  func(ctx context.Context, provider, idToken, totpCode string) (*service.User, error)
+	LockFieldsFunc               func(ctx context.Context, userID string, fields []string, reason string) ([]*service.FieldLock, error)
+	UnlockFieldsFunc             func(ctx context.Context, userID string, fields []string) ([]*service.FieldLock, error)
+	FieldLocksFunc               func(ctx context.Context, userID string) ([]*service.FieldLock, error)
+	UnlockUserFunc               func(ctx context.Context, id string) error
+	ChangePasswordFunc           func(ctx context.Context, id, oldPassword, newPassword string) error
+	EnrollTOTPFunc               func(ctx context.Context, id, password string) (*service.TOTPEnrollment, error)
+	VerifyTOTPFunc               func(ctx context.Context, id, code string) error
+	RequestPasswordResetFunc     func(ctx context.Context, email string) error
+	ConfirmPasswordResetFunc     func(ctx context.Context, token, password string) error
+	AuditEventsFunc              func(ctx context.Context, filter audit.Filter) ([]*audit.Event, error)
+	ListUserChangesFunc          func(ctx context.Context, since int64, limit int) ([]*service.UserChange, error)
+	CheckServiceHealthFunc       func(ctx context.Context) error
 }
 
 func (s *serviceMock) Fetch(ctx context.Context, id string) (*service.User, error) {
@@ -25,10 +71,26 @@ func (s *serviceMock) FetchAll(ctx context.Context, filter service.FilterParams,
 	return s.FetchAllFunc(ctx, filter, pag)
 }
 
+func (s *serviceMock) Count(ctx context.Context, filter service.FilterParams) (int64, error) {
+	return s.CountFunc(ctx, filter)
+}
+
+func (s *serviceMock) Search(ctx context.Context, query string, offset, limit int) ([]*service.User, error) {
+	return s.SearchFunc(ctx, query, offset, limit)
+}
+
 func (s *serviceMock) Create(ctx context.Context, user *service.User) (*service.User, error) {
 	return s.CreateFunc(ctx, user)
 }
 
+func (s *serviceMock) ImportUsers(ctx context.Context, next func() (*service.ImportRecord, error)) (*service.ImportReport, error) {
+	return s.ImportUsersFunc(ctx, next)
+}
+
+func (s *serviceMock) CheckNicknameAvailable(ctx context.Context, nickname, country string) (bool, error) {
+	return s.CheckNicknameAvailableFunc(ctx, nickname, country)
+}
+
 func (s *serviceMock) Update(ctx context.Context, user *service.User) (*service.User, error) {
 	return s.UpdateFunc(ctx, user)
 }
@@ -37,6 +99,170 @@ func (s *serviceMock) Delete(ctx context.Context, id string) error {
 	return s.DeleteFunc(ctx, id)
 }
 
+func (s *serviceMock) AnonymizeUser(ctx context.Context, id string) (*service.User, error) {
+	return s.AnonymizeUserFunc(ctx, id)
+}
+
+func (s *serviceMock) SuspendUser(ctx context.Context, id string) (*service.User, error) {
+	return s.SuspendUserFunc(ctx, id)
+}
+
+func (s *serviceMock) DeactivateUser(ctx context.Context, id string) (*service.User, error) {
+	return s.DeactivateUserFunc(ctx, id)
+}
+
+func (s *serviceMock) ReactivateUser(ctx context.Context, id string) (*service.User, error) {
+	return s.ReactivateUserFunc(ctx, id)
+}
+
+func (s *serviceMock) SetAvatar(ctx context.Context, id string, image []byte) (*service.User, error) {
+	return s.SetAvatarFunc(ctx, id, image)
+}
+
+func (s *serviceMock) GetAvatar(ctx context.Context, id string) (*service.Avatar, error) {
+	return s.GetAvatarFunc(ctx, id)
+}
+
+func (s *serviceMock) RequestPhoneVerification(ctx context.Context, id string) error {
+	return s.RequestPhoneVerificationFunc(ctx, id)
+}
+
+func (s *serviceMock) VerifyPhone(ctx context.Context, id, code string) (*service.User, error) {
+	return s.VerifyPhoneFunc(ctx, id, code)
+}
+
+func (s *serviceMock) Authenticate(ctx context.Context, email, password, totpCode string) (*service.User, error) {
+	return s.AuthenticateFunc(ctx, email, password, totpCode)
+}
+
+func (s *serviceMock) Stats(ctx context.Context) (*service.UserStats, error) {
+	return s.StatsFunc(ctx)
+}
+
+func (s *serviceMock) ExportUsers(ctx context.Context, filter service.FilterParams, fn func(user *service.User) error) error {
+	return s.ExportUsersFunc(ctx, filter, fn)
+}
+
+func (s *serviceMock) FindDuplicates(ctx context.Context, filter service.FilterParams) (*service.DuplicateReport, error) {
+	return s.FindDuplicatesFunc(ctx, filter)
+}
+
+func (s *serviceMock) DeleteUsersByFilter(ctx context.Context, filter service.BulkDeleteFilter, dryRun bool) (*service.BulkDeleteReport, error) {
+	return s.DeleteUsersByFilterFunc(ctx, filter, dryRun)
+}
+
+func (s *serviceMock) Merge(ctx context.Context, params service.MergeParams) (*service.User, error) {
+	return s.MergeFunc(ctx, params)
+}
+
+func (s *serviceMock) Bootstrap(ctx context.Context, token string, admin *service.User, keyName string) (*service.User, string, error) {
+	return s.BootstrapFunc(ctx, token, admin, keyName)
+}
+
+func (s *serviceMock) CreateAPIKey(ctx context.Context, userID, name string, scopes []string) (*service.APIKey, string, error) {
+	return s.CreateAPIKeyFunc(ctx, userID, name, scopes)
+}
+
+func (s *serviceMock) RevokeAPIKey(ctx context.Context, id string) error {
+	return s.RevokeAPIKeyFunc(ctx, id)
+}
+
+func (s *serviceMock) APIKeys(ctx context.Context, userID string) ([]*service.APIKey, error) {
+	return s.APIKeysFunc(ctx, userID)
+}
+
+func (s *serviceMock) AuthenticateAPIKey(ctx context.Context, apiKey string) (*service.User, *service.APIKey, error) {
+	return s.AuthenticateAPIKeyFunc(ctx, apiKey)
+}
+
+func (s *serviceMock) LinkIdentity(ctx context.Context, userID, provider, idToken string) (*service.LinkedIdentity, error) {
+	return s.LinkIdentityFunc(ctx, userID, provider, idToken)
+}
+
+func (s *serviceMock) LinkedIdentities(ctx context.Context, userID string) ([]*service.LinkedIdentity, error) {
+	return s.LinkedIdentitiesFunc(ctx, userID)
+}
+
+func (s *serviceMock) UnlinkIdentity(ctx context.Context, userID, provider, subject string) error {
+	return s.UnlinkIdentityFunc(ctx, userID, provider, subject)
+}
+
+func (s *serviceMock) FetchByIdentity(ctx context.Context, provider, subject string) (*service.User, error) {
+	return s.FetchByIdentityFunc(ctx, provider, subject)
+}
+
+func (s *serviceMock) AuthenticateWithIDToken(ctx context.Context, provider, idToken, totpCode string) (*service.User, error) {
+	return s.AuthenticateWithIDTokenFunc(ctx, provider, idToken, totpCode)
+}
+
+func (s *serviceMock) LockFields(ctx context.Context, userID string, fields []string, reason string) ([]*service.FieldLock, error) {
+	return s.LockFieldsFunc(ctx, userID, fields, reason)
+}
+
+func (s *serviceMock) UnlockFields(ctx context.Context, userID string, fields []string) ([]*service.FieldLock, error) {
+	return s.UnlockFieldsFunc(ctx, userID, fields)
+}
+
+func (s *serviceMock) FieldLocks(ctx context.Context, userID string) ([]*service.FieldLock, error) {
+	return s.FieldLocksFunc(ctx, userID)
+}
+
+func (s *serviceMock) UnlockUser(ctx context.Context, id string) error {
+	return s.UnlockUserFunc(ctx, id)
+}
+
+func (s *serviceMock) AuthenticateAccessToken(ctx context.Context, accessToken string) (*service.User, error) {
+	return s.AuthenticateAccessTokenFunc(ctx, accessToken)
+}
+
+func (s *serviceMock) CreateSession(ctx context.Context, userID string) (*service.SessionTokens, error) {
+	return s.CreateSessionFunc(ctx, userID)
+}
+
+func (s *serviceMock) RefreshSession(ctx context.Context, refreshToken string) (*service.SessionTokens, error) {
+	return s.RefreshSessionFunc(ctx, refreshToken)
+}
+
+func (s *serviceMock) RevokeSession(ctx context.Context, id string) error {
+	return s.RevokeSessionFunc(ctx, id)
+}
+
+func (s *serviceMock) RevokeRefreshToken(ctx context.Context, refreshToken string) error {
+	return s.RevokeRefreshTokenFunc(ctx, refreshToken)
+}
+
+func (s *serviceMock) Sessions(ctx context.Context, userID string) ([]*service.Session, error) {
+	return s.SessionsFunc(ctx, userID)
+}
+
+func (s *serviceMock) ChangePassword(ctx context.Context, id, oldPassword, newPassword string) error {
+	return s.ChangePasswordFunc(ctx, id, oldPassword, newPassword)
+}
+
+func (s *serviceMock) EnrollTOTP(ctx context.Context, id, password string) (*service.TOTPEnrollment, error) {
+	return s.EnrollTOTPFunc(ctx, id, password)
+}
+
+func (s *serviceMock) VerifyTOTP(ctx context.Context, id, code string) error {
+	return s.VerifyTOTPFunc(ctx, id, code)
+}
+
+func (s *serviceMock) RequestPasswordReset(ctx context.Context, email string) error {
+	return s.RequestPasswordResetFunc(ctx, email)
+}
+
+func (s *serviceMock) ConfirmPasswordReset(ctx context.Context, token, password string) error {
+	return s.ConfirmPasswordResetFunc(ctx, token, password)
+}
+
+func (s *serviceMock) AuditEvents(ctx context.Context, filter audit.Filter) ([]*audit.Event, error) {
+	return s.AuditEventsFunc(ctx, filter)
+}
+
+func (s *serviceMock) ListUserChanges(ctx context.Context, since int64, limit int) ([]*service.UserChange, error) {
+	return s.ListUserChangesFunc(ctx, since, limit)
+}
+
 func (s *serviceMock) CheckServiceHealth(ctx context.Context) error {
 	return s.CheckServiceHealthFunc(ctx)
 }
@@ -0,0 +1,76 @@
+package app
+
+import (
+	"context"
+	"strings"
+
+	"github.com/alesr/usrsvc/internal/tenant"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const tenantHeader string = "x-tenant-id"
+
+// defaultTenantOnly are the RPCs reading the records of every tenant, the audit events and
+// the user changes, which are reserved to the default tenant.
+var defaultTenantOnly = map[string]bool{
+	"ListAuditEvents": true,
+	"ListUserChanges": true,
+}
+
+// Tenancy scopes the RPCs to a tenant, so the users of the other tenants are not found.
+type Tenancy struct {
+	logger *zap.Logger
+}
+
+// NewTenancy creates a new tenancy interceptor.
+func NewTenancy(logger *zap.Logger) *Tenancy {
+	return &Tenancy{logger: logger}
+}
+
+// UnaryServerInterceptor scopes the context to the tenant of the "x-tenant-id: <tenant>"
+// metadata, or to the default tenant without it. It must come before the authorization:
+// the callers are then authenticated within the tenant, so their API keys and access
+// tokens are only valid for the tenant of their user.
+func (t *Tenancy) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := t.scope(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor scopes the streaming RPCs like UnaryServerInterceptor.
+func (t *Tenancy) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := t.scope(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// scope returns the context scoped to the tenant of the RPC.
+func (t *Tenancy) scope(ctx context.Context, fullMethod string) (context.Context, error) {
+	id := tenant.Default
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(tenantHeader); len(values) > 0 {
+		id = values[0]
+	}
+
+	if err := tenant.Validate(id); err != nil {
+		t.logger.Warn("failed to validate tenant", zap.Error(err))
+		return nil, ErrTenantInvalid
+	}
+
+	method := fullMethod[strings.LastIndex(fullMethod, "/")+1:]
+	if id != tenant.Default && defaultTenantOnly[method] {
+		return nil, ErrDefaultTenantRequired
+	}
+	return tenant.ContextWithTenant(ctx, id), nil
+}
@@ -0,0 +1,91 @@
+package app
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alesr/usrsvc/internal/tenant"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestTenancyUnaryServerInterceptor(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name           string
+		tenant         []string
+		method         string
+		expectedTenant string
+		expectedErr    error
+	}{
+		{
+			name:           "default tenant without metadata",
+			method:         "/UserService/GetUser",
+			expectedTenant: tenant.Default,
+		},
+		{
+			name:           "tenant of the metadata",
+			tenant:         []string{"acme"},
+			method:         "/UserService/GetUser",
+			expectedTenant: "acme",
+		},
+		{
+			name:        "invalid tenant",
+			tenant:      []string{"Acme/EU"},
+			method:      "/UserService/GetUser",
+			expectedErr: ErrTenantInvalid,
+		},
+		{
+			name:        "empty tenant",
+			tenant:      []string{""},
+			method:      "/UserService/GetUser",
+			expectedErr: ErrTenantInvalid,
+		},
+		{
+			name:        "audit events of another tenant",
+			tenant:      []string{"acme"},
+			method:      "/UserService/ListAuditEvents",
+			expectedErr: ErrDefaultTenantRequired,
+		},
+		{
+			name:           "audit events of the default tenant",
+			tenant:         []string{tenant.Default},
+			method:         "/UserService/ListAuditEvents",
+			expectedTenant: tenant.Default,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Arrange
+			ctx := context.TODO()
+			if tc.tenant != nil {
+				ctx = metadata.NewIncomingContext(ctx, metadata.MD{tenantHeader: tc.tenant})
+			}
+
+			var observedTenant string
+			handler := func(ctx context.Context, req any) (any, error) {
+				observedTenant, _ = tenant.FromContext(ctx)
+				return "ok", nil
+			}
+
+			// Act
+			_, err := NewTenancy(zap.NewNop()).UnaryServerInterceptor()(
+				ctx,
+				nil,
+				&grpc.UnaryServerInfo{FullMethod: tc.method},
+				handler,
+			)
+
+			// Assert
+			assert.Equal(t, tc.expectedErr, err)
+			assert.Equal(t, tc.expectedTenant, observedTenant)
+		})
+	}
+}
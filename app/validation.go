@@ -1,145 +1,360 @@
-/*
-I could have done a more precise validation, especially
-for the first, last and nickname, but I think this is enough.
-
-Ideally, I would have used a library like https://pkg.go.dev/github.com/grpc-ecosystem/go-grpc-middleware/validator
-for validating the probuf messages, but I wanted to keep this project as simple as possible.
-*/
 package app
 
 import (
-	"net/mail"
+	"context"
+	"errors"
+	"strings"
 	"unicode"
+	"unicode/utf8"
 
+	"github.com/alesr/usrsvc/pkg/uservalidation"
 	apiv1 "github.com/alesr/usrsvc/proto/users/v1"
+	apiv2 "github.com/alesr/usrsvc/proto/users/v2"
 	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
 )
 
+// userValidation is the policy of the user fields, shared with the clients through
+// the uservalidation package so they validate users like the server. The tenants may
+// override it (see TenantPolicies).
+var userValidation = uservalidation.DefaultPolicy
+
 const (
-	minNameLength     int = 2
-	maxNameLength     int = 50
-	minPasswordLength int = 8
-	maxPasswordLength int = 128
+	maxSearchQueryLength int = 256
+	maxLockReasonLength  int = 512
+
+	// maxPolicyMinAge and maxPolicyNameSeparators bound the tenant policies.
+	maxPolicyMinAge         int = 120
+	maxPolicyNameSeparators int = 16
 )
 
-func validateCreateUserRequest(req *apiv1.CreateUserRequest) error {
-	if err := validateName(req.FirstName); err != nil {
-		return err
+// fieldRule validates the value of a string field with the validation policy of the
+// tenant and returns a transport error.
+type fieldRule func(policy uservalidation.Policy, value string) error
+
+// fieldRules are the rules of the request fields, by field name. validateRequest applies
+// them to every field with that name, so a new email or id field of any request gets the
+// same validation and errors as the existing ones.
+var fieldRules = map[protoreflect.Name]fieldRule{
+	"id":           plain(validateID),
+	"user_id":      plain(validateID),
+	"survivor_id":  plain(validateID),
+	"duplicate_id": plain(validateID),
+	"first_name":   validateName,
+	"last_name":    validateName,
+	"nickname":     validateName,
+	"email":        validateEmail,
+	"password":     validatePassword,
+	"new_password": validatePassword,
+	"old_password": required(ErrPasswordRequired),
+	"country":      validateCountryCode,
+	"phone":        optional(validatePhone),
+	"locale":       optional(validateLocale),
+	"timezone":     optional(validateTimezone),
+	"birthdate":    optional(validateBirthdate),
+}
+
+// messageFieldRules override the rules of some fields of a request.
+// A nil rule leaves the field unvalidated.
+var messageFieldRules = map[protoreflect.FullName]map[protoreflect.Name]fieldRule{
+	(&apiv1.UpdateUserRequest{}).ProtoReflect().Descriptor().FullName(): {
+		// The password is changed with ChangePassword, which confirms the current one.
+		"password": rejected(ErrPasswordNotUpdatable),
+	},
+	(&apiv1.ImportUsersRequest{}).ProtoReflect().Descriptor().FullName(): {
+		// The legacy passwords are imported as is.
+		"password": nil,
+	},
+	(&apiv1.CheckNicknameAvailableRequest{}).ProtoReflect().Descriptor().FullName(): {
+		// Required by the service when the nicknames are unique per country only.
+		"country": optional(validateCountryCode),
+	},
+	(&apiv1.BootstrapRequest{}).ProtoReflect().Descriptor().FullName(): {
+		"token": required(ErrBootstrapToken),
+	},
+	(&apiv1.CreateAPIKeyRequest{}).ProtoReflect().Descriptor().FullName(): {
+		"name": required(ErrAPIKeyNameRequired),
+	},
+	(&apiv1.ConfirmPasswordResetRequest{}).ProtoReflect().Descriptor().FullName(): {
+		"token": required(ErrResetTokenRequired),
+	},
+	(&apiv1.LinkExternalIdentityRequest{}).ProtoReflect().Descriptor().FullName(): {
+		"provider": required(ErrIdentityProvider),
+		"id_token": required(ErrIDTokenRequired),
+	},
+	(&apiv1.UnlinkExternalIdentityRequest{}).ProtoReflect().Descriptor().FullName(): {
+		"provider": required(ErrIdentityProvider),
+		"subject":  required(ErrSubjectRequired),
+	},
+	(&apiv1.GetUserByIdentityRequest{}).ProtoReflect().Descriptor().FullName(): {
+		"provider": required(ErrIdentityProvider),
+		"subject":  required(ErrSubjectRequired),
+	},
+	(&apiv1.SearchUsersRequest{}).ProtoReflect().Descriptor().FullName(): {
+		"query": plain(validateSearchQuery),
+	},
+	(&apiv1.EnrollTOTPRequest{}).ProtoReflect().Descriptor().FullName(): {
+		// The current password is confirmed, the password policy doesn't apply.
+		"password": required(ErrPasswordRequired),
+	},
+	(&apiv1.VerifyTOTPRequest{}).ProtoReflect().Descriptor().FullName(): {
+		"code": required(ErrTOTPCodeRequired),
+	},
+	(&apiv1.VerifyPhoneRequest{}).ProtoReflect().Descriptor().FullName(): {
+		"code": required(ErrPhoneCodeRequired),
+	},
+	(&apiv1.RefreshTokenRequest{}).ProtoReflect().Descriptor().FullName(): {
+		"refresh_token": required(ErrRefreshTokenRequired),
+	},
+	(&apiv1.RevokeSessionRequest{}).ProtoReflect().Descriptor().FullName(): {
+		// Either the id or the refresh token, see validateRevokeSessionRequest.
+		"id": optional(plain(validateID)),
+	},
+	(&apiv1.ListAuditEventsRequest{}).ProtoReflect().Descriptor().FullName(): {
+		// Leave empty to list the events of all users.
+		"user_id": optional(plain(validateID)),
+	},
+	(&apiv2.User{}).ProtoReflect().Descriptor().FullName(): {
+		// Output only on create, and validated apart on update.
+		"id": nil,
+	},
+}
+
+// validateRequest validates the fields of the request, and of its messages, in declaration
+// order with the validation policy of the tenant of ctx and returns the error of the first
+// invalid one, with a BadRequest detail naming it.
+func validateRequest(ctx context.Context, req proto.Message) error {
+	return validateMessage(policyFromContext(ctx), req.ProtoReflect(), "")
+}
+
+// validateMessage validates the fields of the message, whose path in the request is prefixed with prefix.
+func validateMessage(policy uservalidation.Policy, msg protoreflect.Message, prefix string) error {
+	desc := msg.Descriptor()
+	fields := desc.Fields()
+
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		if field.IsList() || field.IsMap() {
+			continue
+		}
+
+		switch field.Kind() {
+		case protoreflect.MessageKind:
+			if !msg.Has(field) {
+				continue
+			}
+
+			if err := validateMessage(policy, msg.Get(field).Message(), prefix+string(field.Name())+"."); err != nil {
+				return err
+			}
+		case protoreflect.StringKind:
+			rule := ruleOf(desc.FullName(), field.Name())
+			if rule == nil {
+				continue
+			}
+
+			if err := rule(policy, msg.Get(field).String()); err != nil {
+				return badRequestError(prefix+string(field.Name()), err)
+			}
+		}
 	}
+	return nil
+}
 
-	if err := validateName(req.LastName); err != nil {
-		return err
+// ruleOf returns the rule of the field of the message, or nil if it isn't validated.
+func ruleOf(message protoreflect.FullName, field protoreflect.Name) fieldRule {
+	if rule, ok := messageFieldRules[message][field]; ok {
+		return rule
 	}
+	return fieldRules[field]
+}
 
-	if err := validateName(req.Nickname); err != nil {
-		return err
+// required returns a rule rejecting empty values with the given error.
+func required(err error) fieldRule {
+	return func(_ uservalidation.Policy, value string) error {
+		if value == "" {
+			return err
+		}
+		return nil
 	}
+}
 
-	if err := validateEmail(req.Email); err != nil {
-		return err
+// optional returns a rule accepting empty values and validating the others with the rule.
+func optional(rule fieldRule) fieldRule {
+	return func(policy uservalidation.Policy, value string) error {
+		if value == "" {
+			return nil
+		}
+		return rule(policy, value)
 	}
+}
 
-	if err := validatePassword(req.Password); err != nil {
-		return err
+// rejected returns a rule rejecting non-empty values with the given error, for the
+// deprecated fields.
+func rejected(err error) fieldRule {
+	return func(_ uservalidation.Policy, value string) error {
+		if value != "" {
+			return err
+		}
+		return nil
 	}
+}
 
-	if err := validateCountryCode(req.Country); err != nil {
-		return err
+// plain returns a rule validating the values with the validation function, which doesn't
+// depend on the policy.
+func plain(validate func(value string) error) fieldRule {
+	return func(_ uservalidation.Policy, value string) error {
+		return validate(value)
 	}
-	return nil
 }
 
-func validateUpdateUserRequest(req *apiv1.UpdateUserRequest) error {
-	if err := validateID(req.Id); err != nil {
+func validateBootstrapRequest(ctx context.Context, req *apiv1.BootstrapRequest) error {
+	if err := validateRequest(ctx, req); err != nil {
 		return err
 	}
 
-	if err := validateName(req.FirstName); err != nil {
-		return err
+	if req.Admin == nil {
+		return badRequestError("admin", ErrAdminUserRequired)
 	}
+	return nil
+}
 
-	if err := validateName(req.LastName); err != nil {
+func validateCreateAPIKeyRequest(ctx context.Context, req *apiv1.CreateAPIKeyRequest) error {
+	if err := validateRequest(ctx, req); err != nil {
 		return err
 	}
 
-	if err := validateName(req.Nickname); err != nil {
-		return err
+	if len(req.Scopes) == 0 {
+		return badRequestError("scopes", ErrAPIKeyScopesRequired)
 	}
+	return nil
+}
 
-	if err := validateEmail(req.Email); err != nil {
-		return err
+func validateAuthenticateRequest(ctx context.Context, req *apiv1.AuthenticateRequest) error {
+	if req.IdToken != "" || req.Provider != "" {
+		if req.Provider == "" {
+			return badRequestError("provider", ErrIdentityProvider)
+		}
+
+		if req.IdToken == "" {
+			return badRequestError("id_token", ErrIDTokenRequired)
+		}
+		return nil
 	}
 
-	if err := validatePassword(req.Password); err != nil {
-		return err
+	if err := validateEmail(policyFromContext(ctx), req.Email); err != nil {
+		return badRequestError("email", err)
 	}
 
-	if err := validateCountryCode(req.Country); err != nil {
-		return err
+	// The password policy is enforced on creation, here we only care it's present.
+	if req.Password == "" {
+		return badRequestError("password", ErrPasswordRequired)
 	}
 	return nil
 }
 
-func validateName(name string) error {
-	if name == "" {
-		return ErrNameRequired
+func validateLockUserFieldsRequest(ctx context.Context, req *apiv1.LockUserFieldsRequest) error {
+	if err := validateRequest(ctx, req); err != nil {
+		return err
 	}
 
-	// I don't think this is the best way to validate a name, but it's good enough for this project.
-	// I think go languages package have a better way to do this, or some library would do it for me.
-	for _, char := range name {
-		if !unicode.IsLetter(char) && !unicode.IsSpace(char) {
-			return ErrNameFormat
-		}
+	if len(req.Fields) == 0 {
+		return badRequestError("fields", ErrLockFieldsRequired)
 	}
 
-	if len(name) < minNameLength || len(name) > maxNameLength {
-		return ErrNameLength
+	if strings.TrimSpace(req.Reason) == "" {
+		return badRequestError("reason", ErrLockReasonRequired)
+	}
+
+	if utf8.RuneCountInString(req.Reason) > maxLockReasonLength {
+		return badRequestError("reason", ErrLockReasonLength)
 	}
 	return nil
 }
 
-func validateEmail(email string) error {
-	if email == "" {
-		return ErrEmailRequired
+func validateUnlockUserFieldsRequest(ctx context.Context, req *apiv1.UnlockUserFieldsRequest) error {
+	if err := validateRequest(ctx, req); err != nil {
+		return err
 	}
 
-	// I don't think this is the best way to validate an email, but it's good enough for this project.
-	if _, err := mail.ParseAddress(email); err != nil {
-		return ErrEmailFormat
+	if len(req.Fields) == 0 {
+		return badRequestError("fields", ErrLockFieldsRequired)
 	}
 	return nil
 }
 
-// I hope this is not too cumbersome. I wanted to make sure that the password is somewhat secure.
-func validatePassword(password string) error {
-	if password == "" {
-		return ErrPasswordRequired
+func validateRevokeSessionRequest(ctx context.Context, req *apiv1.RevokeSessionRequest) error {
+	if err := validateRequest(ctx, req); err != nil {
+		return err
 	}
 
-	if len(password) < minPasswordLength || len(password) > maxPasswordLength {
-		return ErrPasswordLength
+	if req.Id == "" && req.RefreshToken == "" {
+		return badRequestError("id", ErrSessionRequired)
 	}
+	return nil
+}
 
-	var hasNumber, hasLetter, hasSpecial bool
-	for _, char := range password {
-		if unicode.IsNumber(char) {
-			hasNumber = true
-		}
-		if unicode.IsLetter(char) {
-			hasLetter = true
-		}
-		if !unicode.IsLetter(char) && !unicode.IsNumber(char) {
-			hasSpecial = true
+func validateSetTenantPolicyRequest(req *apiv1.SetTenantPolicyRequest) error {
+	if req.Policy == nil {
+		return badRequestError("policy", ErrTenantPolicyRequired)
+	}
+
+	if n := int(req.Policy.MinPasswordLength); n < 0 || n > userValidation.MaxPasswordLength {
+		return badRequestError("policy.min_password_length", ErrMinPasswordLengthInvalid)
+	}
+
+	if n := int(req.Policy.MinAge); n < 0 || n > maxPolicyMinAge {
+		return badRequestError("policy.min_age", ErrMinAgeInvalid)
+	}
+
+	if utf8.RuneCountInString(req.Policy.NameSeparators) > maxPolicyNameSeparators {
+		return badRequestError("policy.name_separators", ErrNameSeparatorsInvalid)
+	}
+
+	for _, char := range req.Policy.NameSeparators {
+		if !unicode.IsPunct(char) && !unicode.IsSymbol(char) {
+			return badRequestError("policy.name_separators", ErrNameSeparatorsInvalid)
 		}
 	}
+	return nil
+}
 
-	if !hasNumber || !hasLetter || !hasSpecial {
-		return ErrPasswordFormat
+func validateSearchQuery(query string) error {
+	if strings.TrimSpace(query) == "" {
+		return ErrSearchQueryRequired
+	}
+
+	if utf8.RuneCountInString(query) > maxSearchQueryLength {
+		return ErrSearchQueryInvalid
 	}
 	return nil
 }
 
+func validateName(policy uservalidation.Policy, name string) error {
+	err := policy.ValidateName(name)
+	if errors.Is(err, uservalidation.ErrNameFormat) && policy.NameSeparators != userValidation.NameSeparators {
+		// ErrNameFormat lists the default separators.
+		return status.Errorf(codes.InvalidArgument, "name must only contain letters, spaces and %q", policy.NameSeparators)
+	}
+	return newValidationError(err)
+}
+
+func validateEmail(policy uservalidation.Policy, email string) error {
+	return newValidationError(policy.ValidateEmail(email))
+}
+
+func validatePassword(policy uservalidation.Policy, password string) error {
+	err := policy.ValidatePassword(password)
+	if errors.Is(err, uservalidation.ErrPasswordLength) && policy.MinPasswordLength != userValidation.MinPasswordLength {
+		// ErrPasswordLength has the default bounds.
+		return status.Errorf(codes.InvalidArgument, "password must be between %d and %d characters", policy.MinPasswordLength, policy.MaxPasswordLength)
+	}
+	return newValidationError(err)
+}
+
 func validateID(id string) error {
 	if id == "" {
 		return ErrIDRequired
@@ -151,13 +366,64 @@ func validateID(id string) error {
 	return nil
 }
 
-func validateCountryCode(country string) error {
-	if country == "" {
-		return ErrCountryCodeRequired
-	}
+func validateCountryCode(policy uservalidation.Policy, country string) error {
+	return newValidationError(policy.ValidateCountryCode(country))
+}
+
+func validatePhone(policy uservalidation.Policy, phone string) error {
+	return newValidationError(policy.ValidatePhone(phone))
+}
+
+func validateLocale(policy uservalidation.Policy, locale string) error {
+	return newValidationError(policy.ValidateLocale(locale))
+}
+
+func validateTimezone(policy uservalidation.Policy, timezone string) error {
+	return newValidationError(policy.ValidateTimezone(timezone))
+}
 
-	if len(country) != 2 {
+func validateBirthdate(policy uservalidation.Policy, birthdate string) error {
+	return newValidationError(policy.ValidateBirthdate(birthdate))
+}
+
+// newValidationError converts the errors of the uservalidation package to transport errors.
+func newValidationError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, uservalidation.ErrBirthdateFormat):
+		return ErrBirthdateFormat
+	case errors.Is(err, uservalidation.ErrBirthdateInvalid):
+		return ErrBirthdateInvalid
+	case errors.Is(err, uservalidation.ErrCountryCodeInvalid):
 		return ErrCountryCodeInvalid
+	case errors.Is(err, uservalidation.ErrCountryCodeRequired):
+		return ErrCountryCodeRequired
+	case errors.Is(err, uservalidation.ErrEmailFormat):
+		return ErrEmailFormat
+	case errors.Is(err, uservalidation.ErrEmailRequired):
+		return ErrEmailRequired
+	case errors.Is(err, uservalidation.ErrLocaleFormat):
+		return ErrLocaleFormat
+	case errors.Is(err, uservalidation.ErrNameFormat):
+		return ErrNameFormat
+	case errors.Is(err, uservalidation.ErrNameLength):
+		return ErrNameLength
+	case errors.Is(err, uservalidation.ErrNameRequired):
+		return ErrNameRequired
+	case errors.Is(err, uservalidation.ErrPasswordFormat):
+		return ErrPasswordFormat
+	case errors.Is(err, uservalidation.ErrPasswordLength):
+		return ErrPasswordLength
+	case errors.Is(err, uservalidation.ErrPasswordRequired):
+		return ErrPasswordRequired
+	case errors.Is(err, uservalidation.ErrPhoneFormat):
+		return ErrPhoneFormat
+	case errors.Is(err, uservalidation.ErrTimezoneFormat):
+		return ErrTimezoneFormat
+	case errors.Is(err, uservalidation.ErrUnderMinimumAge):
+		return ErrUnderMinimumAge
+	default:
+		return err
 	}
-	return nil
 }
@@ -1,12 +1,14 @@
 package app
 
 import (
-	"errors"
+	"context"
 	"testing"
 
 	apiv1 "github.com/alesr/usrsvc/proto/users/v1"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
 )
 
 func TestValidateCreateUserRequest(t *testing.T) {
@@ -185,12 +187,65 @@ func TestValidateCreateUserRequest(t *testing.T) {
 			},
 			expected: ErrCountryCodeRequired,
 		},
+		{
+			name: "valid locale and timezone",
+			given: &apiv1.CreateUserRequest{
+				FirstName: "John",
+				LastName:  "Doe",
+				Nickname:  "johndoe",
+				Email:     "joedoe@foo.bar",
+				Password:  "some_passw0rd",
+				Country:   "BR",
+				Locale:    "pt-BR",
+				Timezone:  "America/Sao_Paulo",
+			},
+			expected: nil,
+		},
+		{
+			name: "invalid locale",
+			given: &apiv1.CreateUserRequest{
+				FirstName: "John",
+				LastName:  "Doe",
+				Nickname:  "johndoe",
+				Email:     "joedoe@foo.bar",
+				Password:  "some_passw0rd",
+				Country:   "BR",
+				Locale:    "portuguese",
+			},
+			expected: ErrLocaleFormat,
+		},
+		{
+			name: "invalid timezone",
+			given: &apiv1.CreateUserRequest{
+				FirstName: "John",
+				LastName:  "Doe",
+				Nickname:  "johndoe",
+				Email:     "joedoe@foo.bar",
+				Password:  "some_passw0rd",
+				Country:   "BR",
+				Timezone:  "GMT+3",
+			},
+			expected: ErrTimezoneFormat,
+		},
+		{
+			name: "invalid birthdate",
+			given: &apiv1.CreateUserRequest{
+				FirstName: "John",
+				LastName:  "Doe",
+				Nickname:  "johndoe",
+				Email:     "joedoe@foo.bar",
+				Password:  "some_passw0rd",
+				Country:   "BR",
+				Birthdate: "1990-02-30",
+			},
+			expected: ErrBirthdateFormat,
+		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			observedErr := validateCreateUserRequest(tc.given)
-			assert.True(t, errors.Is(observedErr, tc.expected))
+			observedErr := validateRequest(context.TODO(), tc.given)
+			assertStatusHelper(t, tc.expected, observedErr)
 		})
 	}
 }
@@ -211,7 +266,6 @@ func TestValidateUpdateUserRequest(t *testing.T) {
 				LastName:  "Doe",
 				Nickname:  "johndoe",
 				Email:     "joedoe@foo.bar",
-				Password:  "some_passw0rd",
 				Country:   "BR",
 			},
 			expected: nil,
@@ -224,7 +278,6 @@ func TestValidateUpdateUserRequest(t *testing.T) {
 				LastName:  "Doe",
 				Nickname:  "johndoe",
 				Email:     "joedoe@foo.bar",
-				Password:  "some_passw0rd",
 				Country:   "BR",
 			},
 			expected: ErrIDFormat,
@@ -237,7 +290,6 @@ func TestValidateUpdateUserRequest(t *testing.T) {
 				LastName:  "Doe",
 				Nickname:  "johndoe",
 				Email:     "joedoe@foo.bar",
-				Password:  "some_passw0rd",
 				Country:   "BR",
 			},
 			expected: ErrIDRequired,
@@ -250,7 +302,6 @@ func TestValidateUpdateUserRequest(t *testing.T) {
 				LastName:  "Doe",
 				Nickname:  "johndoe",
 				Email:     "joedoe@foo.bar",
-				Password:  "some_passw0rd",
 				Country:   "BR",
 			},
 			expected: ErrNameLength,
@@ -263,7 +314,6 @@ func TestValidateUpdateUserRequest(t *testing.T) {
 				LastName:  "Doe",
 				Nickname:  "johndoe",
 				Email:     "joedoe@foo.bar",
-				Password:  "some_passw0rd",
 				Country:   "BR",
 			},
 			expected: ErrNameRequired,
@@ -276,7 +326,6 @@ func TestValidateUpdateUserRequest(t *testing.T) {
 				LastName:  "D",
 				Nickname:  "johndoe",
 				Email:     "joedoe@foo.bar",
-				Password:  "some_passw0rd",
 				Country:   "BR",
 			},
 			expected: ErrNameLength,
@@ -289,7 +338,6 @@ func TestValidateUpdateUserRequest(t *testing.T) {
 				LastName:  "",
 				Nickname:  "johndoe",
 				Email:     "joedoe@foo.bar",
-				Password:  "some_passw0rd",
 				Country:   "BR",
 			},
 			expected: ErrNameRequired,
@@ -302,7 +350,6 @@ func TestValidateUpdateUserRequest(t *testing.T) {
 				LastName:  "Doe",
 				Nickname:  "j",
 				Email:     "joedoe@foo.bar",
-				Password:  "some_passw0rd",
 				Country:   "BR",
 			},
 			expected: ErrNameLength,
@@ -315,7 +362,6 @@ func TestValidateUpdateUserRequest(t *testing.T) {
 				LastName:  "Doe",
 				Nickname:  "",
 				Email:     "joedoe@foo.bar",
-				Password:  "some_passw0rd",
 				Country:   "BR",
 			},
 			expected: ErrNameRequired,
@@ -328,7 +374,6 @@ func TestValidateUpdateUserRequest(t *testing.T) {
 				LastName:  "Doe",
 				Nickname:  "johndoe",
 				Email:     "foo.bar",
-				Password:  "some_passw0rd",
 				Country:   "BR",
 			},
 			expected: ErrEmailFormat,
@@ -341,82 +386,175 @@ func TestValidateUpdateUserRequest(t *testing.T) {
 				LastName:  "Doe",
 				Nickname:  "johndoe",
 				Email:     "",
-				Password:  "some_passw0rd",
 				Country:   "BR",
 			},
 			expected: ErrEmailRequired,
 		},
 		{
-			name: "invalid password",
+			name: "password",
 			given: &apiv1.UpdateUserRequest{
 				Id:        uuid.New().String(),
 				FirstName: "John",
 				LastName:  "Doe",
 				Nickname:  "johndoe",
 				Email:     "joedoe@foo.bar",
-				Password:  "invalidpassword",
+				Password:  "some_passw0rd",
 				Country:   "BR",
 			},
-			expected: ErrPasswordFormat,
+			expected: ErrPasswordNotUpdatable,
 		},
 		{
-			name: "missing password",
+			name: "invalid password",
 			given: &apiv1.UpdateUserRequest{
 				Id:        uuid.New().String(),
 				FirstName: "John",
 				LastName:  "Doe",
 				Nickname:  "johndoe",
 				Email:     "joedoe@foo.bar",
-				Password:  "",
+				Password:  "xxx",
 				Country:   "BR",
 			},
-			expected: ErrPasswordRequired,
+			expected: ErrPasswordNotUpdatable,
 		},
 		{
-			name: "password length",
+			name: "invalid country code",
 			given: &apiv1.UpdateUserRequest{
 				Id:        uuid.New().String(),
 				FirstName: "John",
 				LastName:  "Doe",
 				Nickname:  "johndoe",
 				Email:     "joedoe@foo.bar",
-				Password:  "xxx",
-				Country:   "BR",
+				Country:   "BRR",
 			},
-			expected: ErrPasswordLength,
+			expected: ErrCountryCodeInvalid,
 		},
 		{
-			name: "invalid country code",
+			name: "missing country code",
 			given: &apiv1.UpdateUserRequest{
 				Id:        uuid.New().String(),
 				FirstName: "John",
 				LastName:  "Doe",
 				Nickname:  "johndoe",
 				Email:     "joedoe@foo.bar",
-				Password:  "some_passw0rd",
-				Country:   "BRR",
+				Country:   "",
 			},
-			expected: ErrCountryCodeInvalid,
+			expected: ErrCountryCodeRequired,
 		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			observedErr := validateRequest(context.TODO(), tc.given)
+			assertStatusHelper(t, tc.expected, observedErr)
+		})
+	}
+}
+
+func TestValidateRequest(t *testing.T) {
+	t.Parallel()
+
+	admin := &apiv1.CreateUserRequest{
+		FirstName: "John",
+		LastName:  "Doe",
+		Nickname:  "johndoe",
+		Email:     "joedoe@foo.bar",
+		Password:  "some_passw0rd",
+		Country:   "BR",
+	}
+
+	testCases := []struct {
+		name     string
+		given    proto.Message
+		expected error
+	}{
 		{
-			name: "missing country code",
+			name: "update keeps the password when empty",
 			given: &apiv1.UpdateUserRequest{
 				Id:        uuid.New().String(),
 				FirstName: "John",
 				LastName:  "Doe",
 				Nickname:  "johndoe",
 				Email:     "joedoe@foo.bar",
-				Password:  "some_passw0rd",
-				Country:   "",
+				Country:   "BR",
 			},
-			expected: ErrCountryCodeRequired,
+			expected: nil,
+		},
+		{
+			name:     "validates the nested messages",
+			given:    &apiv1.BootstrapRequest{Token: "token", Admin: &apiv1.CreateUserRequest{FirstName: "John"}},
+			expected: ErrNameRequired,
+		},
+		{
+			name:     "validates the fields before the nested messages",
+			given:    &apiv1.BootstrapRequest{Admin: admin},
+			expected: ErrBootstrapToken,
+		},
+		{
+			name:     "merge ids",
+			given:    &apiv1.MergeUsersRequest{SurvivorId: uuid.New().String(), DuplicateId: "foo"},
+			expected: ErrIDFormat,
+		},
+		{
+			name:     "optional user id",
+			given:    &apiv1.ListAuditEventsRequest{},
+			expected: nil,
+		},
+		{
+			name:     "search query",
+			given:    &apiv1.SearchUsersRequest{Query: " "},
+			expected: ErrSearchQueryRequired,
+		},
+		{
+			name:     "identity provider",
+			given:    &apiv1.LinkExternalIdentityRequest{UserId: uuid.New().String(), IdToken: "token"},
+			expected: ErrIdentityProvider,
+		},
+		{
+			name:     "old password",
+			given:    &apiv1.ChangePasswordRequest{Id: uuid.New().String(), NewPassword: "some_passw0rd"},
+			expected: ErrPasswordRequired,
 		},
 	}
 
 	for _, tc := range testCases {
+		tc := tc
 		t.Run(tc.name, func(t *testing.T) {
-			observedErr := validateUpdateUserRequest(tc.given)
-			assert.True(t, errors.Is(observedErr, tc.expected))
+			t.Parallel()
+
+			observedErr := validateRequest(context.TODO(), tc.given)
+			assertStatusHelper(t, tc.expected, observedErr)
 		})
 	}
 }
+
+func TestValidateRequestFieldViolations(t *testing.T) {
+	t.Parallel()
+
+	// Act
+	err := validateRequest(context.TODO(), &apiv1.BootstrapRequest{
+		Token: "token",
+		Admin: &apiv1.CreateUserRequest{FirstName: "John", LastName: "Doe", Nickname: "johndoe"},
+	})
+
+	// Assert
+	assertStatusHelper(t, ErrEmailRequired, err)
+	assert.Equal(t, []string{"admin.email"}, fieldViolationsHelper(t, err))
+}
+
+func TestUserFieldsHaveRules(t *testing.T) {
+	t.Parallel()
+
+	// A new field of the user requests must get a rule in fieldRules or messageFieldRules.
+	for _, req := range []proto.Message{&apiv1.CreateUserRequest{}, &apiv1.UpdateUserRequest{}} {
+		desc := req.ProtoReflect().Descriptor()
+
+		fields := desc.Fields()
+		for i := 0; i < fields.Len(); i++ {
+			field := fields.Get(i)
+			if field.Kind() != protoreflect.StringKind {
+				continue
+			}
+			assert.NotNil(t, ruleOf(desc.FullName(), field.Name()), "%s has no rule", field.FullName())
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package app
+
+import (
+	"context"
+
+	"github.com/alesr/usrsvc/pkg/uservalidation"
+	apiv1 "github.com/alesr/usrsvc/proto/users/v1"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// warningHeader carries the validation warnings of the request as "field:code" values,
+// e.g. "password:weak_password".
+const warningHeader string = "usrsvc-validation-warning"
+
+// WarningObserver is notified of every validation warning returned to the clients.
+type WarningObserver interface {
+	ObserveValidationWarning(field, code string)
+}
+
+// WithWarningObserver configures the server to report the validation warnings.
+func WithWarningObserver(observer WarningObserver) ServerOption {
+	return func(s *GRPCServer) {
+		s.warningObserver = observer
+	}
+}
+
+// warn returns the warnings to the client in the response header and reports them.
+func (s *GRPCServer) warn(ctx context.Context, warnings []uservalidation.Warning) {
+	if len(warnings) == 0 {
+		return
+	}
+
+	md := metadata.MD{}
+	for _, warning := range warnings {
+		md.Append(warningHeader, warning.String())
+
+		if s.warningObserver != nil {
+			s.warningObserver.ObserveValidationWarning(warning.Field, warning.Code)
+		}
+	}
+
+	if err := grpc.SetHeader(ctx, md); err != nil {
+		s.logger.Debug("failed to set validation warnings header", zap.Error(err))
+	}
+}
+
+func createUserWarnings(req *apiv1.CreateUserRequest) []uservalidation.Warning {
+	return userValidation.Warnings(uservalidation.User{Password: req.Password, Country: req.Country})
+}
+
+func updateUserWarnings(req *apiv1.UpdateUserRequest) []uservalidation.Warning {
+	return userValidation.CountryCodeWarnings(req.Country)
+}
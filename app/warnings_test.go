@@ -0,0 +1,91 @@
+package app
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alesr/usrsvc/internal/users/service"
+	apiv1 "github.com/alesr/usrsvc/proto/users/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// headerStream records the header set by the handlers.
+type headerStream struct {
+	grpc.ServerTransportStream
+	header metadata.MD
+}
+
+func (s *headerStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+type warningObserverStub struct {
+	observed []string
+}
+
+func (o *warningObserverStub) ObserveValidationWarning(field, code string) {
+	o.observed = append(o.observed, field+":"+code)
+}
+
+func TestValidationWarnings(t *testing.T) {
+	t.Parallel()
+
+	svc := &serviceMock{
+		CreateFunc: func(ctx context.Context, user *service.User) (*service.User, error) {
+			return user, nil
+		},
+	}
+
+	testCases := []struct {
+		name     string
+		password string
+		country  string
+		expected []string
+	}{
+		{
+			name:     "no warnings",
+			password: "long-passw0rd!",
+			country:  "GB",
+		},
+		{
+			name:     "weak password and deprecated country code",
+			password: "passw0rd!",
+			country:  "UK",
+			expected: []string{"password:weak_password", "country:deprecated_country_code"},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Arrange
+			observer := &warningObserverStub{}
+			server := NewGRPCServer(zap.NewNop(), svc, WithWarningObserver(observer))
+
+			stream := &headerStream{}
+			ctx := grpc.NewContextWithServerTransportStream(context.TODO(), stream)
+
+			// Act
+			_, err := server.CreateUser(ctx, &apiv1.CreateUserRequest{
+				FirstName: "John",
+				LastName:  "Doe",
+				Nickname:  "jdoe",
+				Email:     "joedoe@foo.bar",
+				Password:  tc.password,
+				Country:   tc.country,
+			})
+			require.NoError(t, err)
+
+			// Assert
+			assert.Equal(t, tc.expected, stream.header.Get(warningHeader))
+			assert.Equal(t, tc.expected, observer.observed)
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alesr/usrsvc/internal/cache"
+	userrepo "github.com/alesr/usrsvc/internal/users/repository"
+	userservice "github.com/alesr/usrsvc/internal/users/service"
+	"github.com/jmoiron/sqlx"
+	"github.com/pressly/goose/v3"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+const checkTimeout time.Duration = 30 * time.Second
+
+// errCheckSkipped marks a check that does not apply to the current setup.
+var errCheckSkipped = errors.New("skipped")
+
+// brokerPinger is implemented by publishers that hold a connection to a broker.
+type brokerPinger interface {
+	Ping(ctx context.Context) error
+}
+
+// runCheck is the deployment pre-flight gate behind `usrsvc check`. It validates the
+// config, connects to the database and the broker, verifies that every migration
+// has been applied and runs the service health check without serving any traffic.
+// All checks run so a single invocation reports every problem.
+func runCheck(ctx context.Context, logger *zap.Logger) error {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	var (
+		cfg         *config
+		db          *sqlx.DB
+		mongoClient *mongo.Client
+	)
+
+	defer func() {
+		if db != nil {
+			db.Close()
+		}
+		if mongoClient != nil {
+			mongoClient.Disconnect(context.Background())
+		}
+	}()
+
+	steps := []struct {
+		name string
+		run  func() error
+	}{
+		{
+			name: "config",
+			run: func() (err error) {
+				cfg, err = loadConfig()
+				return err
+			},
+		},
+		{
+			name: "database",
+			run: func() error {
+				if cfg == nil || cfg.DBDriver == memoryDriverName {
+					return errCheckSkipped
+				}
+
+				var err error
+				if cfg.DBDriver == mongoDriverName {
+					if mongoClient, err = connectMongo(ctx, cfg); err != nil {
+						return fmt.Errorf("could not connect to database: %w", err)
+					}
+
+					if err := mongoClient.Ping(ctx, nil); err != nil {
+						return fmt.Errorf("could not ping database: %w", err)
+					}
+					return nil
+				}
+
+				if db, err = openDB(cfg); err != nil {
+					return fmt.Errorf("could not open database: %w", err)
+				}
+
+				if err := db.PingContext(ctx); err != nil {
+					db.Close()
+					db = nil
+					return fmt.Errorf("could not ping database: %w", err)
+				}
+				return nil
+			},
+		},
+		{
+			name: "migrations",
+			run: func() error {
+				if db == nil {
+					return errCheckSkipped
+				}
+				return checkMigrations(db, cfg.DBDriver)
+			},
+		},
+		{
+			name: "broker",
+			run: func() error {
+				if cfg == nil {
+					return errCheckSkipped
+				}
+
+				publisher, err := newPublisher(cfg)
+				if err != nil {
+					return fmt.Errorf("could not connect to broker: %w", err)
+				}
+
+				if closer, ok := publisher.(interface{ Close() error }); ok {
+					defer closer.Close()
+				}
+
+				pinger, ok := publisher.(brokerPinger)
+				if !ok {
+					return errCheckSkipped
+				}
+
+				if err := pinger.Ping(ctx); err != nil {
+					return fmt.Errorf("could not ping broker: %w", err)
+				}
+				return nil
+			},
+		},
+		{
+			name: "cache",
+			run: func() error {
+				if cfg == nil || cfg.RedisAddr == "" {
+					return errCheckSkipped
+				}
+
+				client := newRedisClient(cfg)
+				defer client.Close()
+
+				return cache.NewRedis(client, serviceName+":").Ping(ctx)
+			},
+		},
+		{
+			name: "health",
+			run: func() error {
+				var repo userrepo.Store
+				switch {
+				case cfg != nil && cfg.DBDriver == memoryDriverName:
+					repo = userrepo.NewMemory()
+				case mongoClient != nil:
+					repo = userrepo.NewMongo(mongoClient, cfg.MongoDatabase)
+				case db != nil && cfg.DBDriver == sqliteDriverName:
+					repo = userrepo.NewSQLite(db)
+				case db != nil:
+					repo = userrepo.NewPostgres(db)
+				default:
+					return errCheckSkipped
+				}
+
+				svc := userservice.NewServiceDefault(logger, repo)
+				return svc.CheckServiceHealth(ctx)
+			},
+		},
+	}
+
+	var failed []string
+	for _, step := range steps {
+		err := step.run()
+		switch {
+		case err == nil:
+			logger.Info("check passed", zap.String("check", step.name))
+		case errors.Is(err, errCheckSkipped):
+			logger.Warn("check skipped", zap.String("check", step.name))
+		default:
+			logger.Error("check failed", zap.String("check", step.name), zap.Error(err))
+			failed = append(failed, step.name)
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed checks: %v", failed)
+	}
+	return nil
+}
+
+// checkMigrations returns an error if the database is behind the embedded migrations.
+func checkMigrations(db *sqlx.DB, driver string) error {
+	goose.SetBaseFS(embedMigrations)
+
+	dialect, dir := migrationSource(driver)
+
+	if err := goose.SetDialect(dialect); err != nil {
+		return fmt.Errorf("could not set goose dialect: %w", err)
+	}
+
+	migrations, err := goose.CollectMigrations(dir, 0, goose.MaxVersion)
+	if err != nil {
+		return fmt.Errorf("could not collect migrations: %w", err)
+	}
+
+	latest, err := migrations.Last()
+	if err != nil {
+		return fmt.Errorf("could not find latest migration: %w", err)
+	}
+
+	current, err := goose.GetDBVersion(db.DB)
+	if err != nil {
+		return fmt.Errorf("could not get database version: %w", err)
+	}
+
+	if current < latest.Version {
+		return fmt.Errorf("database is at version %d, expected %d", current, latest.Version)
+	}
+	return nil
+}
@@ -0,0 +1,468 @@
+// Command usrsvcctl is the admin CLI of the users service: it gets, lists, creates, deletes,
+// suspends and reactivates users through the gRPC API, so ops don't craft grpcurl payloads
+// by hand. Most commands require an admin API key or access token.
+//
+//	usrsvcctl [flags] <command> [command flags] [args]
+//
+// The server address and the credentials are read from the flags, or else from the
+// USRSVC_ADDR, USRSVC_API_KEY and USRSVC_TOKEN environment variables.
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	apiv1 "github.com/alesr/usrsvc/proto/users/v1"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	defaultAddr    string        = "localhost:50051"
+	defaultTimeout time.Duration = 10 * time.Second
+
+	// Enumerate the output formats.
+
+	outputTable string = "table"
+	outputJSON  string = "json"
+
+	// The metadata the server authenticates the callers with.
+
+	authorizationHeader string = "authorization"
+	apiKeyHeader        string = "x-api-key"
+)
+
+const usage = `usrsvcctl is the admin CLI of the users service.
+
+Usage:
+
+	usrsvcctl [flags] <command> [command flags] [args]
+
+Commands:
+
+	get <id>          Get a user.
+	list              List users, optionally filtered.
+	create            Create a user.
+	delete <id>       Delete a user.
+	suspend <id>      Suspend a user.
+	reactivate <id>   Reactivate a suspended or deactivated user.
+
+Run usrsvcctl <command> -h for the flags of a command.
+
+Flags:
+`
+
+// errUsage is returned after the usage of a command was printed.
+var errUsage = errors.New("invalid usage")
+
+func main() {
+	err := run(context.Background(), os.Args[1:], os.Getenv, os.Stdin, os.Stdout, os.Stderr)
+	if err == nil {
+		return
+	}
+
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+
+	if !errors.Is(err, errUsage) {
+		fmt.Fprintln(os.Stderr, "usrsvcctl:", err)
+	}
+	os.Exit(1)
+}
+
+// cli is the state of an invocation.
+type cli struct {
+	client  apiv1.UserServiceClient
+	output  string
+	timeout time.Duration
+
+	stdin          io.Reader
+	stdout, stderr io.Writer
+}
+
+// command runs a command with its arguments, the global flags removed.
+type command func(ctx context.Context, c *cli, args []string) error
+
+var commands = map[string]command{
+	"get":        runGet,
+	"list":       runList,
+	"create":     runCreate,
+	"delete":     runDelete,
+	"suspend":    runSuspend,
+	"reactivate": runReactivate,
+}
+
+// run parses the global flags, connects to the server and runs the command.
+func run(ctx context.Context, args []string, getenv func(string) string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("usrsvcctl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		fs.PrintDefaults()
+	}
+
+	var (
+		addr    = fs.String("addr", envOr(getenv, "USRSVC_ADDR", defaultAddr), "server address, or $USRSVC_ADDR")
+		apiKey  = fs.String("api-key", getenv("USRSVC_API_KEY"), "API key, or $USRSVC_API_KEY")
+		token   = fs.String("token", getenv("USRSVC_TOKEN"), "access token, or $USRSVC_TOKEN, used when no API key is set")
+		output  = fs.String("o", envOr(getenv, "USRSVC_OUTPUT", outputTable), "output format, table or json, or $USRSVC_OUTPUT")
+		timeout = fs.Duration("timeout", defaultTimeout, "timeout of each call")
+		useTLS  = fs.Bool("tls", false, "connect with TLS, e.g. through an ingress")
+	)
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return errUsage
+	}
+
+	if *output != outputTable && *output != outputJSON {
+		return fmt.Errorf("invalid output format '%s', must be %s or %s", *output, outputTable, outputJSON)
+	}
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errUsage
+	}
+
+	cmd, ok := commands[fs.Arg(0)]
+	if !ok {
+		return fmt.Errorf("unknown command '%s', run usrsvcctl -h for the commands", fs.Arg(0))
+	}
+
+	creds := insecure.NewCredentials()
+	if *useTLS {
+		creds = credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12})
+	}
+
+	conn, err := grpc.DialContext(ctx, *addr, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return fmt.Errorf("could not connect to '%s': %w", *addr, err)
+	}
+	defer conn.Close()
+
+	switch {
+	case *apiKey != "":
+		ctx = metadata.AppendToOutgoingContext(ctx, apiKeyHeader, *apiKey)
+	case *token != "":
+		ctx = metadata.AppendToOutgoingContext(ctx, authorizationHeader, "Bearer "+*token)
+	}
+
+	c := cli{
+		client:  apiv1.NewUserServiceClient(conn),
+		output:  *output,
+		timeout: *timeout,
+		stdin:   stdin,
+		stdout:  stdout,
+		stderr:  stderr,
+	}
+	return cmd(ctx, &c, fs.Args()[1:])
+}
+
+// envOr returns the environment variable, or def when it is unset or empty.
+func envOr(getenv func(string) string, key, def string) string {
+	if value := getenv(key); value != "" {
+		return value
+	}
+	return def
+}
+
+// flags returns the flag set of a command, printing its usage on the CLI stderr.
+func (c *cli) flags(name, synopsis string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(c.stderr, "Usage: usrsvcctl %s %s\n\nFlags:\n", name, synopsis)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseID parses the flags of a command that takes the id of a user as its single argument.
+func (c *cli) parseID(fs *flag.FlagSet, args []string) (string, error) {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return "", err
+		}
+		return "", errUsage
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return "", errUsage
+	}
+	return fs.Arg(0), nil
+}
+
+func runGet(ctx context.Context, c *cli, args []string) error {
+	id, err := c.parseID(c.flags("get", "<id>"), args)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	resp, err := c.client.GetUser(ctx, &apiv1.GetUserRequest{Id: id})
+	if err != nil {
+		return callError(err)
+	}
+	return c.printUsers(resp, resp.User)
+}
+
+func runList(ctx context.Context, c *cli, args []string) error {
+	fs := c.flags("list", "[flags]")
+
+	var (
+		req apiv1.ListUsersRequest
+		all bool
+	)
+	fs.StringVar(&req.Country, "country", "", "ISO 3166-1 alpha-2 country code")
+	fs.StringVar(&req.NicknamePrefix, "nickname-prefix", "", "nickname prefix")
+	fs.StringVar(&req.Email, "email", "", "email, ignoring case")
+	fs.StringVar(&req.FirstName, "first-name", "", "first name, ignoring case")
+	fs.StringVar(&req.LastName, "last-name", "", "last name, ignoring case")
+	pageSize := fs.Int("page-size", 0, "page size, 100 at most")
+	fs.StringVar(&req.PageToken, "page-token", "", "token of the page to list, from a previous list")
+	fs.BoolVar(&all, "all", false, "list every page")
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return errUsage
+	}
+
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return errUsage
+	}
+	req.PageSize = int32(*pageSize)
+
+	var resp apiv1.ListUsersResponse
+	for {
+		page, err := c.listPage(ctx, &req)
+		if err != nil {
+			return err
+		}
+
+		resp.Users = append(resp.Users, page.Users...)
+		resp.NextPageToken = page.NextPageToken
+
+		if !all || page.NextPageToken == "" {
+			break
+		}
+		req.PageToken = page.NextPageToken
+	}
+
+	if err := c.printUsers(&resp, resp.Users...); err != nil {
+		return err
+	}
+
+	if c.output == outputTable && resp.NextPageToken != "" {
+		fmt.Fprintf(c.stderr, "next page token: %s\n", resp.NextPageToken)
+	}
+	return nil
+}
+
+func (c *cli) listPage(ctx context.Context, req *apiv1.ListUsersRequest) (*apiv1.ListUsersResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	resp, err := c.client.ListUsers(ctx, req)
+	if err != nil {
+		return nil, callError(err)
+	}
+	return resp, nil
+}
+
+func runCreate(ctx context.Context, c *cli, args []string) error {
+	fs := c.flags("create", "[flags]")
+
+	var (
+		req           apiv1.CreateUserRequest
+		passwordStdin bool
+		metadataPairs string
+	)
+	fs.StringVar(&req.FirstName, "first-name", "", "first name (required)")
+	fs.StringVar(&req.LastName, "last-name", "", "last name (required)")
+	fs.StringVar(&req.Nickname, "nickname", "", "nickname (required)")
+	fs.StringVar(&req.Email, "email", "", "email (required)")
+	fs.StringVar(&req.Country, "country", "", "ISO 3166-1 alpha-2 country code (required)")
+	fs.StringVar(&req.Password, "password", "", "password, prefer -password-stdin to keep it out of the shell history")
+	fs.BoolVar(&passwordStdin, "password-stdin", false, "read the password from the first line of stdin")
+	fs.StringVar(&req.Phone, "phone", "", "E.164 phone number")
+	fs.StringVar(&req.Locale, "locale", "", "BCP 47 locale, e.g. pt-BR")
+	fs.StringVar(&req.Timezone, "timezone", "", "IANA time zone, e.g. America/Sao_Paulo")
+	fs.StringVar(&req.Birthdate, "birthdate", "", "date of birth, YYYY-MM-DD")
+	fs.StringVar(&metadataPairs, "metadata", "", "comma separated key=value metadata")
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return errUsage
+	}
+
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return errUsage
+	}
+
+	if passwordStdin {
+		line, err := bufio.NewReader(c.stdin).ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("could not read password: %w", err)
+		}
+		req.Password = strings.TrimRight(line, "\r\n")
+	}
+
+	if metadataPairs != "" {
+		req.Metadata = make(map[string]string)
+		for _, pair := range strings.Split(metadataPairs, ",") {
+			key, value, ok := strings.Cut(pair, "=")
+			if !ok || key == "" {
+				return fmt.Errorf("invalid metadata '%s', must be key=value", pair)
+			}
+			req.Metadata[key] = value
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	resp, err := c.client.CreateUser(ctx, &req)
+	if err != nil {
+		return callError(err)
+	}
+	return c.printUsers(resp, resp.User)
+}
+
+func runDelete(ctx context.Context, c *cli, args []string) error {
+	id, err := c.parseID(c.flags("delete", "<id>"), args)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	resp, err := c.client.DeleteUser(ctx, &apiv1.DeleteUserRequest{Id: id})
+	if err != nil {
+		return callError(err)
+	}
+
+	if c.output == outputJSON {
+		return c.printJSON(resp)
+	}
+
+	fmt.Fprintf(c.stdout, "deleted user %s\n", id)
+	return nil
+}
+
+func runSuspend(ctx context.Context, c *cli, args []string) error {
+	id, err := c.parseID(c.flags("suspend", "<id>"), args)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	resp, err := c.client.SuspendUser(ctx, &apiv1.SuspendUserRequest{Id: id})
+	if err != nil {
+		return callError(err)
+	}
+	return c.printUsers(resp, resp.User)
+}
+
+func runReactivate(ctx context.Context, c *cli, args []string) error {
+	id, err := c.parseID(c.flags("reactivate", "<id>"), args)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	resp, err := c.client.ReactivateUser(ctx, &apiv1.ReactivateUserRequest{Id: id})
+	if err != nil {
+		return callError(err)
+	}
+	return c.printUsers(resp, resp.User)
+}
+
+// printUsers prints the response as JSON, or its users as a table.
+func (c *cli) printUsers(resp proto.Message, users ...*apiv1.User) error {
+	if c.output == outputJSON {
+		return c.printJSON(resp)
+	}
+
+	w := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNICKNAME\tEMAIL\tNAME\tCOUNTRY\tROLE\tSTATUS\tCREATED AT")
+
+	for _, user := range users {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			user.Id,
+			user.Nickname,
+			user.Email,
+			strings.TrimSpace(user.FirstName+" "+user.LastName),
+			user.Country,
+			user.Role,
+			user.Status,
+			user.CreatedAt.AsTime().Format(time.RFC3339),
+		)
+	}
+	return w.Flush()
+}
+
+func (c *cli) printJSON(resp proto.Message) error {
+	b, err := protojson.MarshalOptions{Multiline: true, Indent: "  ", UseProtoNames: true}.Marshal(resp)
+	if err != nil {
+		return fmt.Errorf("could not encode response: %w", err)
+	}
+
+	_, err = fmt.Fprintln(c.stdout, string(b))
+	return err
+}
+
+// callError formats the error of a call with its status code and the field violations
+// of its details, if any.
+func callError(err error) error {
+	st := status.Convert(err)
+
+	msg := fmt.Sprintf("%s: %s", st.Code(), st.Message())
+
+	var violations []string
+	for _, detail := range st.Details() {
+		if br, ok := detail.(*errdetails.BadRequest); ok {
+			for _, v := range br.GetFieldViolations() {
+				violations = append(violations, fmt.Sprintf("%s: %s", v.GetField(), v.GetDescription()))
+			}
+		}
+	}
+
+	if len(violations) > 0 {
+		sort.Strings(violations)
+		msg += " (" + strings.Join(violations, "; ") + ")"
+	}
+	return errors.New(msg)
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	apiv1 "github.com/alesr/usrsvc/proto/users/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// userServiceFake serves the RPCs of the CLI from canned users.
+type userServiceFake struct {
+	apiv1.UnimplementedUserServiceServer
+
+	users   []*apiv1.User
+	created *apiv1.CreateUserRequest
+	apiKeys []string
+}
+
+func (f *userServiceFake) GetUser(ctx context.Context, req *apiv1.GetUserRequest) (*apiv1.GetUserResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	f.apiKeys = append(f.apiKeys, md.Get(apiKeyHeader)...)
+
+	for _, user := range f.users {
+		if user.Id == req.Id {
+			return &apiv1.GetUserResponse{User: user}, nil
+		}
+	}
+	return nil, status.Error(codes.NotFound, "user not found")
+}
+
+// ListUsers returns a user per page.
+func (f *userServiceFake) ListUsers(ctx context.Context, req *apiv1.ListUsersRequest) (*apiv1.ListUsersResponse, error) {
+	start := 0
+	for i, user := range f.users {
+		if user.Id == req.PageToken {
+			start = i + 1
+		}
+	}
+
+	if start == len(f.users) {
+		return &apiv1.ListUsersResponse{}, nil
+	}
+
+	user := f.users[start]
+	return &apiv1.ListUsersResponse{Users: []*apiv1.User{user}, NextPageToken: user.Id}, nil
+}
+
+func (f *userServiceFake) CreateUser(ctx context.Context, req *apiv1.CreateUserRequest) (*apiv1.CreateUserResponse, error) {
+	if req.Email == "" {
+		st, err := status.New(codes.InvalidArgument, "invalid request").WithDetails(&errdetails.BadRequest{
+			FieldViolations: []*errdetails.BadRequest_FieldViolation{{Field: "email", Description: "email is required"}},
+		})
+		if err != nil {
+			return nil, err
+		}
+		return nil, st.Err()
+	}
+
+	f.created = req
+	return &apiv1.CreateUserResponse{User: &apiv1.User{Id: "new", Nickname: req.Nickname, Email: req.Email}}, nil
+}
+
+func (f *userServiceFake) SuspendUser(ctx context.Context, req *apiv1.SuspendUserRequest) (*apiv1.SuspendUserResponse, error) {
+	return &apiv1.SuspendUserResponse{User: &apiv1.User{Id: req.Id, Status: "suspended"}}, nil
+}
+
+func (f *userServiceFake) DeleteUser(ctx context.Context, req *apiv1.DeleteUserRequest) (*apiv1.DeleteUserResponse, error) {
+	return &apiv1.DeleteUserResponse{}, nil
+}
+
+func startServerHelper(t *testing.T, fake *userServiceFake) string {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	server := grpc.NewServer()
+	apiv1.RegisterUserServiceServer(server, fake)
+
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	return lis.Addr().String()
+}
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	createdAt := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	users := []*apiv1.User{
+		{Id: "1", FirstName: "John", LastName: "Doe", Nickname: "jdoe", Email: "joedoe@foo.bar", Country: "US", Role: "user", Status: "active", CreatedAt: timestamppb.New(createdAt)},
+		{Id: "2", FirstName: "Jane", LastName: "Doe", Nickname: "janedoe", Email: "janedoe@foo.bar", Country: "BR", Role: "admin", Status: "active", CreatedAt: timestamppb.New(createdAt)},
+	}
+
+	runHelper := func(t *testing.T, fake *userServiceFake, env map[string]string, stdin string, args ...string) (string, string, error) {
+		t.Helper()
+
+		env["USRSVC_ADDR"] = startServerHelper(t, fake)
+
+		var stdout, stderr bytes.Buffer
+		err := run(context.TODO(), args, func(key string) string { return env[key] }, strings.NewReader(stdin), &stdout, &stderr)
+		return stdout.String(), stderr.String(), err
+	}
+
+	t.Run("get prints a table and sends the api key", func(t *testing.T) {
+		fake := &userServiceFake{users: users}
+
+		stdout, _, err := runHelper(t, fake, map[string]string{"USRSVC_API_KEY": "secret"}, "", "get", "1")
+		require.NoError(t, err)
+
+		lines := strings.Split(strings.TrimSpace(stdout), "\n")
+		require.Len(t, lines, 2)
+		assert.Equal(t, []string{"ID", "NICKNAME", "EMAIL", "NAME", "COUNTRY", "ROLE", "STATUS", "CREATED", "AT"}, strings.Fields(lines[0]))
+		assert.Equal(t, []string{"1", "jdoe", "joedoe@foo.bar", "John", "Doe", "US", "user", "active", "2020-01-02T03:04:05Z"}, strings.Fields(lines[1]))
+
+		assert.Equal(t, []string{"secret"}, fake.apiKeys)
+	})
+
+	t.Run("list every page as json", func(t *testing.T) {
+		fake := &userServiceFake{users: users}
+
+		stdout, _, err := runHelper(t, fake, map[string]string{"USRSVC_OUTPUT": "json"}, "", "list", "-all")
+		require.NoError(t, err)
+
+		var observed struct {
+			Users []struct {
+				ID string `json:"id"`
+			} `json:"users"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(stdout), &observed))
+
+		require.Len(t, observed.Users, 2)
+		assert.Equal(t, "1", observed.Users[0].ID)
+		assert.Equal(t, "2", observed.Users[1].ID)
+	})
+
+	t.Run("list a page prints the next page token", func(t *testing.T) {
+		fake := &userServiceFake{users: users}
+
+		stdout, stderr, err := runHelper(t, fake, map[string]string{}, "", "list", "-page-token", "1")
+		require.NoError(t, err)
+
+		assert.Contains(t, stdout, "janedoe")
+		assert.NotContains(t, stdout, "jdoe ")
+		assert.Equal(t, "next page token: 2\n", stderr)
+	})
+
+	t.Run("create reads the password from stdin", func(t *testing.T) {
+		fake := &userServiceFake{}
+
+		_, _, err := runHelper(t, fake, map[string]string{}, "password1!\n",
+			"create",
+			"-first-name", "John",
+			"-last-name", "Doe",
+			"-nickname", "jdoe",
+			"-email", "joedoe@foo.bar",
+			"-country", "US",
+			"-password-stdin",
+			"-metadata", "theme=dark,plan=pro",
+		)
+		require.NoError(t, err)
+
+		require.NotNil(t, fake.created)
+		assert.Equal(t, "password1!", fake.created.Password)
+		assert.Equal(t, map[string]string{"theme": "dark", "plan": "pro"}, fake.created.Metadata)
+	})
+
+	t.Run("suspend", func(t *testing.T) {
+		stdout, _, err := runHelper(t, &userServiceFake{}, map[string]string{}, "", "suspend", "1")
+		require.NoError(t, err)
+
+		assert.Contains(t, stdout, "suspended")
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		stdout, _, err := runHelper(t, &userServiceFake{}, map[string]string{}, "", "delete", "1")
+		require.NoError(t, err)
+
+		assert.Equal(t, "deleted user 1\n", stdout)
+	})
+
+	t.Run("errors show the status code and the field violations", func(t *testing.T) {
+		_, _, err := runHelper(t, &userServiceFake{}, map[string]string{}, "", "create", "-nickname", "jdoe")
+
+		require.Error(t, err)
+		assert.Equal(t, "InvalidArgument: invalid request (email: email is required)", err.Error())
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		_, _, err := runHelper(t, &userServiceFake{}, map[string]string{}, "", "get", "42")
+
+		require.Error(t, err)
+		assert.Equal(t, "NotFound: user not found", err.Error())
+	})
+
+	testCases := []struct {
+		name        string
+		args        []string
+		expectedErr string
+	}{
+		{
+			name:        "unknown command",
+			args:        []string{"frobnicate"},
+			expectedErr: "unknown command 'frobnicate', run usrsvcctl -h for the commands",
+		},
+		{
+			name:        "invalid output format",
+			args:        []string{"-o", "yaml", "get", "1"},
+			expectedErr: "invalid output format 'yaml', must be table or json",
+		},
+		{
+			name:        "missing id",
+			args:        []string{"get"},
+			expectedErr: errUsage.Error(),
+		},
+		{
+			name:        "missing command",
+			args:        nil,
+			expectedErr: errUsage.Error(),
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, err := runHelper(t, &userServiceFake{}, map[string]string{}, "", tc.args...)
+
+			require.Error(t, err)
+			assert.Equal(t, tc.expectedErr, err.Error())
+		})
+	}
+}
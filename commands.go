@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alesr/usrsvc/internal/audit"
+	userservice "github.com/alesr/usrsvc/internal/users/service"
+	"github.com/alesr/usrsvc/pkg/events"
+	"go.uber.org/zap"
+)
+
+const (
+	// commandsAuditActor is the actor of the changes made by the commands that don't tell
+	// who requested them.
+	commandsAuditActor string = "event-consumer"
+
+	// consumerRestartDelay is how long the command worker waits before consuming again
+	// once the consumer failed, e.g. when the connection to the broker was lost.
+	consumerRestartDelay time.Duration = 5 * time.Second
+)
+
+// commandEvents are the inbound commands handled by the command worker.
+var commandEvents = []events.Event{events.UserDeleteRequested, events.UserAnonymizeRequested}
+
+// commandService is the part of the user service invoked by the commands.
+type commandService interface {
+	Delete(ctx context.Context, id string) error
+	AnonymizeUser(ctx context.Context, id string) (*userservice.User, error)
+}
+
+// commandWorker consumes the commands other services publish to usrsvc, e.g. the deletion
+// requests of the GDPR service, and invokes the service with them. The commands are
+// delivered at least once, so they are handled idempotently: a user already deleted or
+// anonymized is a success.
+type commandWorker struct {
+	logger       *zap.Logger
+	consumer     events.Consumer
+	service      commandService
+	restartDelay time.Duration
+}
+
+func newCommandWorker(logger *zap.Logger, consumer events.Consumer, service commandService) *commandWorker {
+	return &commandWorker{
+		logger:       logger,
+		consumer:     consumer,
+		service:      service,
+		restartDelay: consumerRestartDelay,
+	}
+}
+
+// Run consumes the commands until ctx is done, consuming again after a delay when the consumer fails.
+func (w *commandWorker) Run(ctx context.Context) {
+	for {
+		err := w.consumer.Consume(ctx, w.handle, commandEvents...)
+		if ctx.Err() != nil {
+			return
+		}
+
+		w.logger.Error("event consumer failed, restarting", zap.Duration("delay", w.restartDelay), zap.Error(err))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(w.restartDelay):
+		}
+	}
+}
+
+// handle invokes the service with the command. Malformed commands, commands of a newer
+// schema version and invalid ids are permanent failures, the other failures are retried
+// by the consumer.
+func (w *commandWorker) handle(ctx context.Context, env *events.Envelope) error {
+	logger := w.logger.With(zap.String("event_id", env.ID), zap.String("event", string(env.Event)))
+
+	if env.Version() > env.Event.SchemaVersion() {
+		err := fmt.Errorf("unsupported schema version %d of command '%s'", env.Version(), env.Event)
+		logger.Error("discarding command", zap.Error(err))
+		return events.Permanent(err)
+	}
+
+	var data events.UserCommandData
+	if err := env.Decode(&data); err != nil {
+		logger.Error("discarding malformed command", zap.Error(err))
+		return events.Permanent(err)
+	}
+
+	actor := data.RequestedBy
+	if actor == "" {
+		actor = commandsAuditActor
+	}
+	ctx = audit.ContextWithActor(ctx, actor)
+
+	var err error
+	switch env.Event {
+	case events.UserDeleteRequested:
+		err = w.service.Delete(ctx, data.UserID)
+	case events.UserAnonymizeRequested:
+		_, err = w.service.AnonymizeUser(ctx, data.UserID)
+	default:
+		err = events.Permanent(fmt.Errorf("unexpected command '%s'", env.Event))
+	}
+
+	switch {
+	case err == nil:
+		logger.Info("command handled", zap.String("user_id", data.UserID), zap.String("requested_by", actor))
+		return nil
+	case errors.Is(err, userservice.ErrUserNotFound), errors.Is(err, userservice.ErrUserAnonymized):
+		logger.Info("command already handled", zap.String("user_id", data.UserID), zap.Error(err))
+		return nil
+	case errors.Is(err, userservice.ErrInvalidID):
+		err = events.Permanent(err)
+	}
+
+	logger.Error("failed to handle command", zap.String("user_id", data.UserID), zap.Bool("permanent", events.IsPermanent(err)), zap.Error(err))
+	return err
+}
@@ -0,0 +1,427 @@
+// Package admin serves a minimal web UI for on-call operators to look up users, read
+// their audit history and toggle maintenance mode when the main console is not available.
+// It also serves the pre-stop drain endpoint used ahead of a deploy.
+package admin
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alesr/usrsvc/internal/audit"
+	"github.com/alesr/usrsvc/internal/tenant"
+	"github.com/alesr/usrsvc/internal/users/service"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	requestTimeout time.Duration = 5 * time.Second
+	searchLimit    int           = 50
+	auditLimit     int           = 50
+	realm          string        = "usrsvc admin"
+	apiPrefix      string        = "/admin/api/"
+	bearerPrefix   string        = "Bearer "
+	apiKeyHeader   string        = "X-Api-Key"
+)
+
+// errCredentialsRequired is returned when the request carries no credentials.
+var errCredentialsRequired = errors.New("credentials required")
+
+//go:embed static
+var staticFiles embed.FS
+
+// userService is the part of the user service used by the admin UI.
+type userService interface {
+	Fetch(ctx context.Context, id string) (*service.User, error)
+	FetchByEmail(ctx context.Context, email string) (*service.User, error)
+	FetchAll(ctx context.Context, filter service.FilterParams, pag service.PaginationParams) ([]*service.User, error)
+	AuditEvents(ctx context.Context, filter audit.Filter) ([]*audit.Event, error)
+	AuthenticateAPIKey(ctx context.Context, apiKey string) (*service.User, *service.APIKey, error)
+	AuthenticateAccessToken(ctx context.Context, accessToken string) (*service.User, error)
+}
+
+// MaintenanceSwitch turns maintenance mode on and off.
+type MaintenanceSwitch interface {
+	Enabled() bool
+	SetEnabled(enabled bool)
+}
+
+// DrainSwitch marks the instance as draining before it is stopped.
+type DrainSwitch interface {
+	Draining() bool
+	Start() time.Duration
+	Cancel()
+}
+
+// user is the user representation returned by the admin API. It never includes the password.
+type user struct {
+	ID        string    `json:"id"`
+	FirstName string    `json:"first_name"`
+	LastName  string    `json:"last_name"`
+	Nickname  string    `json:"nickname"`
+	Email     string    `json:"email"`
+	Country   string    `json:"country"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// auditEvent is the audit event representation returned by the admin API.
+// The values of the changes are redacted by the service.
+type auditEvent struct {
+	ID        int64                   `json:"id"`
+	Actor     string                  `json:"actor"`
+	Action    string                  `json:"action"`
+	UserID    string                  `json:"user_id"`
+	Changes   map[string]audit.Change `json:"changes"`
+	CreatedAt time.Time               `json:"created_at"`
+}
+
+type maintenanceState struct {
+	Enabled bool `json:"enabled"`
+}
+
+type drainState struct {
+	Draining bool `json:"draining"`
+}
+
+// Handler serves the admin UI under /admin/ and its API under /admin/api/.
+// The API requests must authenticate an admin user with a session access token or an API key,
+// in the Authorization header as a bearer token or in the X-Api-Key header. Neither is sent
+// by the browsers on their own, unlike cookies and basic auth, so other sites can't forge
+// the requests of a signed in operator.
+type Handler struct {
+	logger      *zap.Logger
+	service     userService
+	maintenance MaintenanceSwitch
+	drain       DrainSwitch
+	mux         *http.ServeMux
+}
+
+// NewHandler creates the admin handler.
+func NewHandler(logger *zap.Logger, svc userService, maintenance MaintenanceSwitch, drain DrainSwitch) *Handler {
+	h := &Handler{
+		logger:      logger,
+		service:     svc,
+		maintenance: maintenance,
+		drain:       drain,
+		mux:         http.NewServeMux(),
+	}
+
+	assets, _ := fs.Sub(staticFiles, "static")
+	h.mux.Handle("/admin/", http.StripPrefix("/admin/", http.FileServer(http.FS(assets))))
+	h.mux.HandleFunc("/admin/api/users", h.searchUsers)
+	h.mux.HandleFunc("/admin/api/audit", h.listAuditEvents)
+	h.mux.HandleFunc("/admin/api/maintenance", h.handleMaintenance)
+	h.mux.HandleFunc("/admin/api/drain", h.handleDrain)
+	return h
+}
+
+// ServeHTTP authenticates the API requests and routes them. The static files of the UI
+// hold no data and are served to anyone, for the operators to sign in.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.URL.Path, apiPrefix) {
+		h.mux.ServeHTTP(w, r)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	caller, key, err := h.authenticate(ctx, r)
+	if err != nil {
+		if !errors.Is(err, errCredentialsRequired) && !errors.Is(err, service.ErrInvalidCredentials) {
+			h.logger.Error("failed to authenticate admin", zap.Error(err))
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("WWW-Authenticate", `Bearer realm="`+realm+`"`)
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	if !caller.IsAdmin() {
+		h.logger.Warn("non-admin user denied the admin API", zap.String("caller_id", caller.ID))
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	readOnly := r.Method == http.MethodGet || r.Method == http.MethodHead
+
+	if key != nil && !key.HasScope(requiredScope(readOnly)) {
+		h.logger.Warn("api key lacks the scope", zap.String("api_key_id", key.ID), zap.String("path", r.URL.Path))
+		http.Error(w, "api key lacks the scope", http.StatusForbidden)
+		return
+	}
+
+	// Other sites can't send a JSON body without a CORS preflight, which only the allowed
+	// origins pass, so they can't forge the state-changing requests.
+	if !readOnly && !isJSON(r) {
+		http.Error(w, "content type must be application/json", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	// The admins only see the users of their tenant.
+	ctx = tenant.ContextWithTenant(r.Context(), caller.TenantID)
+	ctx = audit.ContextWithActor(ctx, caller.ID)
+	h.mux.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// authenticate returns the user the credentials of the request belong to, and the API key
+// when they are one.
+func (h *Handler) authenticate(ctx context.Context, r *http.Request) (*service.User, *service.APIKey, error) {
+	if apiKey := r.Header.Get(apiKeyHeader); apiKey != "" {
+		return h.service.AuthenticateAPIKey(ctx, apiKey)
+	}
+
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), bearerPrefix)
+	if token == "" || token == r.Header.Get("Authorization") {
+		return nil, nil, errCredentialsRequired
+	}
+
+	if !service.IsAccessToken(token) {
+		return h.service.AuthenticateAPIKey(ctx, token)
+	}
+
+	caller, err := h.service.AuthenticateAccessToken(ctx, token)
+	return caller, nil, err
+}
+
+// searchUsers looks up users by id, email or country code, depending on the query.
+func (h *Handler) searchUsers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+
+	var (
+		users []*service.User
+		err   error
+	)
+
+	switch {
+	case query == "":
+		http.Error(w, "query is required", http.StatusBadRequest)
+		return
+	case isUUID(query):
+		var u *service.User
+		if u, err = h.service.Fetch(ctx, query); err == nil {
+			users = append(users, u)
+		}
+	case strings.Contains(query, "@"):
+		var u *service.User
+		if u, err = h.service.FetchByEmail(ctx, query); err == nil {
+			users = append(users, u)
+		}
+
+		// The uniqueness scope lets the users of different countries share an email.
+		if errors.Is(err, service.ErrEmailAmbiguous) {
+			users, err = h.service.FetchAll(ctx,
+				service.FilterParams{Email: &query},
+				service.PaginationParams{Limit: searchLimit},
+			)
+		}
+	case len(query) == 2:
+		users, err = h.service.FetchAll(ctx,
+			service.FilterParams{Country: &query},
+			service.PaginationParams{Cursor: r.URL.Query().Get("cursor"), Limit: searchLimit},
+		)
+	default:
+		http.Error(w, "query must be a user id, an email or a country code", http.StatusBadRequest)
+		return
+	}
+
+	if err != nil && !errors.Is(err, service.ErrUserNotFound) {
+		h.logger.Error("failed to search users", zap.String("query", query), zap.Error(err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	result := make([]user, 0, len(users))
+	for _, u := range users {
+		result = append(result, newUser(u))
+	}
+	h.writeJSON(w, result)
+}
+
+// listAuditEvents lists the audit history of a user, newest first. The cursor is the id
+// of the last event of the previous page.
+func (h *Handler) listAuditEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	userID := strings.TrimSpace(r.URL.Query().Get("user_id"))
+	if !isUUID(userID) {
+		http.Error(w, "user_id must be a user id", http.StatusBadRequest)
+		return
+	}
+
+	filter := audit.Filter{UserID: userID, Limit: auditLimit}
+
+	if c := r.URL.Query().Get("cursor"); c != "" {
+		cursor, err := strconv.ParseInt(c, 10, 64)
+		if err != nil || cursor <= 0 {
+			http.Error(w, "invalid cursor", http.StatusBadRequest)
+			return
+		}
+		filter.Cursor = cursor
+	}
+
+	// The audit log isn't scoped to the tenants, the user is.
+	if _, err := h.service.Fetch(ctx, userID); err != nil {
+		if errors.Is(err, service.ErrUserNotFound) {
+			http.Error(w, "user not found", http.StatusNotFound)
+			return
+		}
+
+		h.logger.Error("failed to fetch user", zap.String("user_id", userID), zap.Error(err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	events, err := h.service.AuditEvents(ctx, filter)
+	if err != nil {
+		if errors.Is(err, service.ErrAuditDisabled) {
+			http.Error(w, "audit log is disabled", http.StatusNotImplemented)
+			return
+		}
+
+		h.logger.Error("failed to list audit events", zap.String("user_id", userID), zap.Error(err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	result := make([]auditEvent, 0, len(events))
+	for _, e := range events {
+		result = append(result, newAuditEvent(e))
+	}
+	h.writeJSON(w, result)
+}
+
+// handleMaintenance returns the maintenance mode state on GET and sets it on POST.
+func (h *Handler) handleMaintenance(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var state maintenanceState
+		if err := json.NewDecoder(r.Body).Decode(&state); err != nil {
+			http.Error(w, "invalid body", http.StatusBadRequest)
+			return
+		}
+
+		h.maintenance.SetEnabled(state.Enabled)
+		h.logger.Warn("maintenance mode changed from the admin UI",
+			zap.Bool("enabled", state.Enabled),
+			zap.String("admin_id", audit.ActorFromContext(r.Context())),
+			zap.String("remote_addr", r.RemoteAddr),
+		)
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	h.writeJSON(w, maintenanceState{Enabled: h.maintenance.Enabled()})
+}
+
+// handleDrain returns the drain state on GET and sets it on POST. It is meant for the
+// pre-stop hook of the deploys, so the load balancers stop sending traffic to the
+// instance before it receives SIGTERM.
+func (h *Handler) handleDrain(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var state drainState
+		if err := json.NewDecoder(r.Body).Decode(&state); err != nil {
+			http.Error(w, "invalid body", http.StatusBadRequest)
+			return
+		}
+
+		if state.Draining {
+			remaining := h.drain.Start()
+			h.logger.Warn("drain started from the admin API",
+				zap.Duration("grace_period_left", remaining),
+				zap.String("admin_id", audit.ActorFromContext(r.Context())),
+				zap.String("remote_addr", r.RemoteAddr),
+			)
+		} else {
+			h.drain.Cancel()
+			h.logger.Warn("drain cancelled from the admin API",
+				zap.String("admin_id", audit.ActorFromContext(r.Context())),
+				zap.String("remote_addr", r.RemoteAddr),
+			)
+		}
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	h.writeJSON(w, drainState{Draining: h.drain.Draining()})
+}
+
+func (h *Handler) writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		h.logger.Error("failed to write admin response", zap.Error(err))
+	}
+}
+
+func newUser(u *service.User) user {
+	return user{
+		ID:        u.ID,
+		FirstName: u.FirstName,
+		LastName:  u.LastName,
+		Nickname:  u.Nickname,
+		Email:     u.Email,
+		Country:   u.Country,
+		CreatedAt: u.CreatedAt,
+		UpdatedAt: u.UpdatedAt,
+	}
+}
+
+func newAuditEvent(e *audit.Event) auditEvent {
+	return auditEvent{
+		ID:        e.ID,
+		Actor:     e.Actor,
+		Action:    string(e.Action),
+		UserID:    e.UserID,
+		Changes:   e.Changes,
+		CreatedAt: e.CreatedAt,
+	}
+}
+
+// requiredScope returns the API key scope required by the request:
+// ScopeUsersRead for the read-only ones and ScopeUsersWrite for the other ones.
+func requiredScope(readOnly bool) string {
+	if readOnly {
+		return service.ScopeUsersRead
+	}
+	return service.ScopeUsersWrite
+}
+
+func isJSON(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == "application/json"
+}
+
+func isUUID(s string) bool {
+	_, err := uuid.Parse(s)
+	return err == nil
+}
@@ -0,0 +1,442 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alesr/usrsvc/internal/audit"
+	"github.com/alesr/usrsvc/internal/tenant"
+	"github.com/alesr/usrsvc/internal/users/service"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const (
+	testToken  string = "usrsvc_at_s3cret"
+	testAPIKey string = "usrsvc_k3y"
+)
+
+type maintenanceMock struct {
+	enabled bool
+}
+
+func (m *maintenanceMock) Enabled() bool           { return m.enabled }
+func (m *maintenanceMock) SetEnabled(enabled bool) { m.enabled = enabled }
+
+type drainMock struct {
+	draining bool
+}
+
+func (m *drainMock) Draining() bool       { return m.draining }
+func (m *drainMock) Start() time.Duration { m.draining = true; return time.Second }
+func (m *drainMock) Cancel()              { m.draining = false }
+
+func newRequestHelper(t *testing.T, method, target, body string) *http.Request {
+	t.Helper()
+
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+// authenticatedHelper makes the service authenticate testToken and testAPIKey as an admin,
+// and any other credentials as a regular user.
+func authenticatedHelper(svc *serviceMock) *serviceMock {
+	svc.AuthenticateAccessTokenFunc = func(ctx context.Context, accessToken string) (*service.User, error) {
+		switch accessToken {
+		case testToken:
+			return &service.User{ID: "admin-id", Role: service.RoleAdmin}, nil
+		case "usrsvc_at_user":
+			return &service.User{ID: "user-id"}, nil
+		default:
+			return nil, service.ErrInvalidCredentials
+		}
+	}
+	svc.AuthenticateAPIKeyFunc = func(ctx context.Context, apiKey string) (*service.User, *service.APIKey, error) {
+		if apiKey != testAPIKey {
+			return nil, nil, service.ErrInvalidCredentials
+		}
+		return &service.User{ID: "admin-id", Role: service.RoleAdmin},
+			&service.APIKey{ID: "key-id", Scopes: []string{service.ScopeUsersRead}}, nil
+	}
+	return svc
+}
+
+func TestAuthentication(t *testing.T) {
+	t.Parallel()
+
+	h := NewHandler(zap.NewNop(), authenticatedHelper(&serviceMock{}), &maintenanceMock{}, &drainMock{})
+
+	testCases := []struct {
+		name         string
+		method       string
+		header       http.Header
+		expectedCode int
+	}{
+		{
+			name:         "missing credentials",
+			method:       http.MethodGet,
+			header:       http.Header{},
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "basic auth",
+			method:       http.MethodGet,
+			header:       http.Header{"Authorization": {"Basic YWRtaW46czNjcmV0"}},
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "wrong access token",
+			method:       http.MethodGet,
+			header:       http.Header{"Authorization": {"Bearer usrsvc_at_wrong"}},
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "non-admin user",
+			method:       http.MethodGet,
+			header:       http.Header{"Authorization": {"Bearer usrsvc_at_user"}},
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name:         "admin access token",
+			method:       http.MethodGet,
+			header:       http.Header{"Authorization": {"Bearer " + testToken}},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "admin api key as bearer token",
+			method:       http.MethodGet,
+			header:       http.Header{"Authorization": {"Bearer " + testAPIKey}},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "admin api key header",
+			method:       http.MethodGet,
+			header:       http.Header{"X-Api-Key": {testAPIKey}},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:   "api key without the write scope",
+			method: http.MethodPost,
+			header: http.Header{
+				"X-Api-Key":    {testAPIKey},
+				"Content-Type": {"application/json"},
+			},
+			expectedCode: http.StatusForbidden,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(tc.method, "/admin/api/maintenance", strings.NewReader(`{"enabled":true}`))
+			req.Header = tc.header
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.expectedCode, rec.Code)
+			if tc.expectedCode == http.StatusUnauthorized {
+				assert.Contains(t, rec.Header().Get("WWW-Authenticate"), "Bearer")
+			}
+		})
+	}
+
+	t.Run("serves the UI without credentials", func(t *testing.T) {
+		t.Parallel()
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/", nil))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "usrsvc admin")
+	})
+}
+
+func TestCrossSiteRequests(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	maintenance := &maintenanceMock{}
+	h := NewHandler(zap.NewNop(), authenticatedHelper(&serviceMock{}), maintenance, &drainMock{})
+
+	for _, contentType := range []string{"", "text/plain", "application/x-www-form-urlencoded", "multipart/form-data"} {
+		req := newRequestHelper(t, http.MethodPost, "/admin/api/maintenance", `{"enabled":true}`)
+		req.Header.Set("Content-Type", contentType)
+
+		// Act
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		// Assert
+		assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code, contentType)
+		assert.False(t, maintenance.enabled, contentType)
+	}
+
+	req := newRequestHelper(t, http.MethodPost, "/admin/api/maintenance", `{"enabled":true}`)
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.True(t, maintenance.enabled)
+}
+
+func TestSearchUsers(t *testing.T) {
+	t.Parallel()
+
+	id := uuid.New().String()
+	found := &service.User{ID: id, Email: "joedoe@foo.bar", Password: "hash", Country: "BR"}
+
+	svc := authenticatedHelper(&serviceMock{
+		FetchFunc: func(ctx context.Context, given string) (*service.User, error) {
+			if given == id {
+				return found, nil
+			}
+			return nil, service.ErrUserNotFound
+		},
+		FetchByEmailFunc: func(ctx context.Context, email string) (*service.User, error) {
+			switch email {
+			case found.Email:
+				return found, nil
+			case "shared@foo.bar":
+				return nil, service.ErrEmailAmbiguous
+			default:
+				return nil, errors.New("some error")
+			}
+		},
+		FetchAllFunc: func(ctx context.Context, filter service.FilterParams, pag service.PaginationParams) ([]*service.User, error) {
+			assert.Equal(t, searchLimit, pag.Limit)
+			if filter.Email != nil {
+				return []*service.User{found, found}, nil
+			}
+
+			assert.Equal(t, "BR", *filter.Country)
+			return []*service.User{found}, nil
+		},
+	})
+
+	h := NewHandler(zap.NewNop(), svc, &maintenanceMock{}, &drainMock{})
+
+	testCases := []struct {
+		name          string
+		query         string
+		expectedCode  int
+		expectedCount int
+	}{
+		{name: "by id", query: id, expectedCode: http.StatusOK, expectedCount: 1},
+		{name: "by unknown id", query: uuid.New().String(), expectedCode: http.StatusOK, expectedCount: 0},
+		{name: "by email", query: "joedoe@foo.bar", expectedCode: http.StatusOK, expectedCount: 1},
+		{name: "by shared email", query: "shared@foo.bar", expectedCode: http.StatusOK, expectedCount: 2},
+		{name: "by country", query: "BR", expectedCode: http.StatusOK, expectedCount: 1},
+		{name: "service failure", query: "other@foo.bar", expectedCode: http.StatusInternalServerError},
+		{name: "unsupported query", query: "john", expectedCode: http.StatusBadRequest},
+		{name: "empty query", query: "", expectedCode: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, newRequestHelper(t, http.MethodGet, "/admin/api/users?q="+tc.query, ""))
+
+			require.Equal(t, tc.expectedCode, rec.Code)
+			if tc.expectedCode != http.StatusOK {
+				return
+			}
+
+			var users []map[string]any
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &users))
+			require.Len(t, users, tc.expectedCount)
+
+			for _, u := range users {
+				assert.Equal(t, id, u["id"])
+				assert.NotContains(t, u, "password")
+			}
+		})
+	}
+}
+
+func TestListAuditEvents(t *testing.T) {
+	t.Parallel()
+
+	id := uuid.New().String()
+	otherTenantID := uuid.New().String()
+
+	svc := authenticatedHelper(&serviceMock{
+		FetchFunc: func(ctx context.Context, given string) (*service.User, error) {
+			if given == otherTenantID {
+				return nil, service.ErrUserNotFound
+			}
+			return &service.User{ID: given}, nil
+		},
+		AuditEventsFunc: func(ctx context.Context, filter audit.Filter) ([]*audit.Event, error) {
+			assert.Equal(t, id, filter.UserID)
+			assert.Equal(t, auditLimit, filter.Limit)
+			assert.Equal(t, int64(7), filter.Cursor)
+			return []*audit.Event{
+				{
+					ID:        6,
+					Actor:     "admin-id",
+					Action:    audit.ActionUpdate,
+					UserID:    id,
+					Changes:   map[string]audit.Change{"nickname": {Before: "joe", After: "joedoe"}},
+					CreatedAt: time.Now(),
+				},
+			}, nil
+		},
+	})
+
+	h := NewHandler(zap.NewNop(), svc, &maintenanceMock{}, &drainMock{})
+
+	t.Run("lists the events of the user", func(t *testing.T) {
+		t.Parallel()
+
+		// Act
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, newRequestHelper(t, http.MethodGet, "/admin/api/audit?user_id="+id+"&cursor=7", ""))
+
+		// Assert
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var events []map[string]any
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &events))
+		require.Len(t, events, 1)
+
+		assert.Equal(t, float64(6), events[0]["id"])
+		assert.Equal(t, "update", events[0]["action"])
+		assert.Equal(t, map[string]any{"nickname": map[string]any{"before": "joe", "after": "joedoe"}}, events[0]["changes"])
+	})
+
+	testCases := []struct {
+		name         string
+		query        string
+		expectedCode int
+	}{
+		{name: "missing user id", query: "", expectedCode: http.StatusBadRequest},
+		{name: "invalid user id", query: "user_id=john", expectedCode: http.StatusBadRequest},
+		{name: "invalid cursor", query: "user_id=" + id + "&cursor=abc", expectedCode: http.StatusBadRequest},
+		{name: "user of another tenant", query: "user_id=" + otherTenantID, expectedCode: http.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, newRequestHelper(t, http.MethodGet, "/admin/api/audit?"+tc.query, ""))
+
+			assert.Equal(t, tc.expectedCode, rec.Code)
+		})
+	}
+
+	t.Run("audit log disabled", func(t *testing.T) {
+		t.Parallel()
+
+		// Arrange
+		svc := authenticatedHelper(&serviceMock{
+			FetchFunc: func(ctx context.Context, given string) (*service.User, error) {
+				return &service.User{ID: given}, nil
+			},
+			AuditEventsFunc: func(ctx context.Context, filter audit.Filter) ([]*audit.Event, error) {
+				return nil, service.ErrAuditDisabled
+			},
+		})
+		h := NewHandler(zap.NewNop(), svc, &maintenanceMock{}, &drainMock{})
+
+		// Act
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, newRequestHelper(t, http.MethodGet, "/admin/api/audit?user_id="+id, ""))
+
+		// Assert
+		assert.Equal(t, http.StatusNotImplemented, rec.Code)
+	})
+}
+
+func TestScopesRequestsToTheAdminTenant(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	svc := &serviceMock{
+		AuthenticateAccessTokenFunc: func(ctx context.Context, accessToken string) (*service.User, error) {
+			return &service.User{ID: "admin-id", Role: service.RoleAdmin, TenantID: "acme"}, nil
+		},
+		FetchFunc: func(ctx context.Context, id string) (*service.User, error) {
+			scoped, ok := tenant.FromContext(ctx)
+			assert.True(t, ok)
+			assert.Equal(t, "acme", scoped)
+			return nil, service.ErrUserNotFound
+		},
+	}
+	h := NewHandler(zap.NewNop(), svc, &maintenanceMock{}, &drainMock{})
+
+	// Act
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, newRequestHelper(t, http.MethodGet, "/admin/api/users?q="+uuid.New().String(), ""))
+
+	// Assert
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestMaintenance(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	maintenance := &maintenanceMock{}
+	h := NewHandler(zap.NewNop(), authenticatedHelper(&serviceMock{}), maintenance, &drainMock{})
+
+	// Act
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, newRequestHelper(t, http.MethodPost, "/admin/api/maintenance", `{"enabled":true}`))
+
+	// Assert
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"enabled":true}`, rec.Body.String())
+	assert.True(t, maintenance.enabled)
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, newRequestHelper(t, http.MethodPost, "/admin/api/maintenance", `not json`))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.True(t, maintenance.enabled)
+}
+
+func TestDrain(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	drain := &drainMock{}
+	h := NewHandler(zap.NewNop(), authenticatedHelper(&serviceMock{}), &maintenanceMock{}, drain)
+
+	// Act
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, newRequestHelper(t, http.MethodPost, "/admin/api/drain", `{"draining":true}`))
+
+	// Assert
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"draining":true}`, rec.Body.String())
+	assert.True(t, drain.draining)
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, newRequestHelper(t, http.MethodPost, "/admin/api/drain", `{"draining":false}`))
+	assert.JSONEq(t, `{"draining":false}`, rec.Body.String())
+	assert.False(t, drain.draining)
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, newRequestHelper(t, http.MethodPut, "/admin/api/drain", `{"draining":true}`))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
@@ -0,0 +1,43 @@
+package admin
+
+import (
+	"context"
+
+	"github.com/alesr/usrsvc/internal/audit"
+	"github.com/alesr/usrsvc/internal/users/service"
+)
+
+var _ userService = (*serviceMock)(nil)
+
+type serviceMock struct {
+	FetchFunc                   func(ctx context.Context, id string) (*service.User, error)
+	FetchByEmailFunc            func(ctx context.Context, email string) (*service.User, error)
+	FetchAllFunc                func(ctx context.Context, filter service.FilterParams, pag service.PaginationParams) ([]*service.User, error)
+	AuditEventsFunc             func(ctx context.Context, filter audit.Filter) ([]*audit.Event, error)
+	AuthenticateAPIKeyFunc      func(ctx context.Context, apiKey string) (*service.User, *service.APIKey, error)
+	AuthenticateAccessTokenFunc func(ctx context.Context, accessToken string) (*service.User, error)
+}
+
+func (s *serviceMock) Fetch(ctx context.Context, id string) (*service.User, error) {
+	return s.FetchFunc(ctx, id)
+}
+
+func (s *serviceMock) FetchByEmail(ctx context.Context, email string) (*service.User, error) {
+	return s.FetchByEmailFunc(ctx, email)
+}
+
+func (s *serviceMock) FetchAll(ctx context.Context, filter service.FilterParams, pag service.PaginationParams) ([]*service.User, error) {
+	return s.FetchAllFunc(ctx, filter, pag)
+}
+
+func (s *serviceMock) AuditEvents(ctx context.Context, filter audit.Filter) ([]*audit.Event, error) {
+	return s.AuditEventsFunc(ctx, filter)
+}
+
+func (s *serviceMock) AuthenticateAPIKey(ctx context.Context, apiKey string) (*service.User, *service.APIKey, error) {
+	return s.AuthenticateAPIKeyFunc(ctx, apiKey)
+}
+
+func (s *serviceMock) AuthenticateAccessToken(ctx context.Context, accessToken string) (*service.User, error) {
+	return s.AuthenticateAccessTokenFunc(ctx, accessToken)
+}
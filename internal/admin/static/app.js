@@ -0,0 +1,202 @@
+(function () {
+  'use strict';
+
+  // The token is sent in the Authorization header rather than kept in a cookie, so the
+  // browser never attaches it to the requests of other sites.
+  const tokenKey = 'usrsvc-admin-token';
+  const auditLimit = 50;
+
+  const status = document.getElementById('status');
+  const results = document.getElementById('results');
+  const maintenance = document.getElementById('maintenance');
+  const signin = document.getElementById('signin');
+  const consoleView = document.getElementById('console');
+  const audit = document.getElementById('audit');
+  const auditMore = document.getElementById('audit-more');
+
+  let auditUserID = '';
+  let auditCursor = '';
+
+  async function request(path, options) {
+    options = options || {};
+    options.headers = Object.assign({}, options.headers, {
+      'Authorization': 'Bearer ' + sessionStorage.getItem(tokenKey),
+    });
+
+    const resp = await fetch('api/' + path, options);
+    if (resp.status === 401) {
+      signOut();
+      throw new Error('Sign in again, the token is invalid or expired.');
+    }
+    if (!resp.ok) {
+      throw new Error((await resp.text()).trim() || resp.statusText);
+    }
+    return resp.json();
+  }
+
+  function signOut() {
+    sessionStorage.removeItem(tokenKey);
+    consoleView.hidden = true;
+    maintenance.parentElement.hidden = true;
+    signin.hidden = false;
+  }
+
+  async function signIn() {
+    status.textContent = '';
+    showMaintenance(await request('maintenance'));
+    signin.hidden = true;
+    consoleView.hidden = false;
+    maintenance.parentElement.hidden = false;
+  }
+
+  function showMaintenance(state) {
+    maintenance.checked = state.enabled;
+    maintenance.parentElement.classList.toggle('on', state.enabled);
+  }
+
+  function cell(text) {
+    const td = document.createElement('td');
+    td.textContent = text;
+    return td;
+  }
+
+  function showUsers(users) {
+    const body = results.querySelector('tbody');
+    body.replaceChildren();
+
+    for (const u of users) {
+      const history = document.createElement('button');
+      history.type = 'button';
+      history.textContent = 'History';
+      history.addEventListener('click', function () {
+        auditUserID = u.id;
+        auditCursor = '';
+        audit.querySelector('tbody').replaceChildren();
+        document.getElementById('audit-user').textContent = u.email;
+        loadAudit();
+      });
+
+      const actions = document.createElement('td');
+      actions.append(history);
+
+      const tr = document.createElement('tr');
+      tr.append(
+        cell(u.id),
+        cell(u.first_name + ' ' + u.last_name),
+        cell(u.nickname),
+        cell(u.email),
+        cell(u.country),
+        cell(new Date(u.created_at).toLocaleString()),
+        cell(new Date(u.updated_at).toLocaleString()),
+        actions,
+      );
+      body.append(tr);
+    }
+
+    results.hidden = users.length === 0;
+    audit.hidden = true;
+    status.textContent = users.length === 0 ? 'No users found.' : users.length + ' user(s) found.';
+  }
+
+  function changes(event) {
+    return Object.keys(event.changes || {}).sort().map(function (field) {
+      const change = event.changes[field];
+      return field + ': ' + (change.before || '∅') + ' → ' + (change.after || '∅');
+    }).join('\n');
+  }
+
+  async function loadAudit() {
+    let path = 'audit?user_id=' + encodeURIComponent(auditUserID);
+    if (auditCursor) {
+      path += '&cursor=' + encodeURIComponent(auditCursor);
+    }
+
+    try {
+      const events = await request(path);
+      const body = audit.querySelector('tbody');
+      for (const e of events) {
+        const tr = document.createElement('tr');
+        tr.append(
+          cell(new Date(e.created_at).toLocaleString()),
+          cell(e.action),
+          cell(e.actor),
+          cell(changes(e)),
+        );
+        body.append(tr);
+      }
+
+      audit.hidden = false;
+      auditMore.hidden = events.length < auditLimit;
+      if (events.length > 0) {
+        auditCursor = String(events[events.length - 1].id);
+      }
+      if (body.children.length === 0) {
+        status.textContent = 'No audit events found.';
+      }
+    } catch (err) {
+      status.textContent = err.message;
+    }
+  }
+
+  signin.addEventListener('submit', async function (e) {
+    e.preventDefault();
+    const token = document.getElementById('token').value.trim();
+    if (!token) {
+      return;
+    }
+
+    sessionStorage.setItem(tokenKey, token);
+    document.getElementById('token').value = '';
+    try {
+      await signIn();
+    } catch (err) {
+      status.textContent = err.message;
+    }
+  });
+
+  document.getElementById('signout').addEventListener('click', signOut);
+
+  auditMore.addEventListener('click', loadAudit);
+
+  document.getElementById('search').addEventListener('submit', async function (e) {
+    e.preventDefault();
+    const query = document.getElementById('query').value.trim();
+    if (!query) {
+      return;
+    }
+
+    status.textContent = 'Searching...';
+    try {
+      showUsers(await request('users?q=' + encodeURIComponent(query)));
+    } catch (err) {
+      results.hidden = true;
+      status.textContent = err.message;
+    }
+  });
+
+  maintenance.addEventListener('change', async function () {
+    const enabled = maintenance.checked;
+    const verb = enabled ? 'Enable' : 'Disable';
+    if (!confirm(verb + ' maintenance mode? Writes are rejected while it is on.')) {
+      maintenance.checked = !enabled;
+      return;
+    }
+
+    try {
+      showMaintenance(await request('maintenance', {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ enabled: enabled }),
+      }));
+    } catch (err) {
+      maintenance.checked = !enabled;
+      status.textContent = err.message;
+    }
+  });
+
+  if (sessionStorage.getItem(tokenKey)) {
+    signIn().catch(function (err) {
+      status.textContent = err.message;
+    });
+  }
+})();
@@ -0,0 +1,133 @@
+// Package apidocs serves the OpenAPI documents generated from the protos and a Swagger UI
+// to explore them, so the teams integrating with the service can browse the API.
+package apidocs
+
+import (
+	"embed"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+var (
+	//go:embed static/index.html
+	indexFile embed.FS
+
+	// initializer starts Swagger UI. It is not inlined in the page, which the content
+	// security policy would block.
+	//go:embed static/swagger-initializer.js
+	initializer []byte
+
+	indexTemplate = template.Must(template.ParseFS(indexFile, "static/index.html"))
+)
+
+// Spec is an OpenAPI document, served under /docs/openapi/<name>.json.
+type Spec struct {
+	Name     string
+	Document []byte
+}
+
+// specURL is a document listed by Swagger UI.
+type specURL struct {
+	URL  string `json:"url"`
+	Name string `json:"name"`
+}
+
+// Handler serves Swagger UI under /docs/ and the OpenAPI documents under /docs/openapi/.
+// The Swagger UI assets are loaded from assetsURL, e.g. a CDN or an internal mirror of
+// the swagger-ui-dist package.
+type Handler struct {
+	specs     map[string][]byte
+	specURLs  string
+	assetsURL string
+	csp       string
+	mux       *http.ServeMux
+}
+
+// NewHandler creates the API docs handler. The first spec is the one shown by default.
+func NewHandler(specs []Spec, assetsURL string) *Handler {
+	assetsURL = strings.TrimSuffix(assetsURL, "/")
+
+	h := &Handler{
+		specs:     make(map[string][]byte, len(specs)),
+		assetsURL: assetsURL,
+		mux:       http.NewServeMux(),
+	}
+
+	urls := make([]specURL, 0, len(specs))
+	for _, spec := range specs {
+		h.specs[spec.Name] = spec.Document
+		urls = append(urls, specURL{URL: "openapi/" + spec.Name + ".json", Name: spec.Name})
+	}
+
+	encoded, _ := json.Marshal(urls)
+	h.specURLs = string(encoded)
+
+	// The page loads the Swagger UI assets from their origin. Swagger UI sets inline styles
+	// and embeds its icons as data URLs.
+	origin := assetsURL
+	if u, err := url.Parse(assetsURL); err == nil && u.Scheme != "" && u.Host != "" {
+		origin = u.Scheme + "://" + u.Host
+	}
+	h.csp = "default-src 'self'; script-src 'self' " + origin + "; style-src 'self' 'unsafe-inline' " + origin +
+		"; img-src 'self' data:; frame-ancestors 'none'"
+
+	h.mux.HandleFunc("/docs/", h.serveIndex)
+	h.mux.HandleFunc("/docs/openapi/", h.serveSpec)
+	h.mux.HandleFunc("/docs/swagger-initializer.js", h.serveInitializer)
+	return h
+}
+
+// ServeHTTP routes the request.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	h.mux.ServeHTTP(w, r)
+}
+
+// serveIndex renders the Swagger UI page.
+func (h *Handler) serveIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/docs/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Security-Policy", h.csp)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	data := struct {
+		AssetsURL string
+		SpecURLs  string
+	}{
+		AssetsURL: h.assetsURL,
+		SpecURLs:  h.specURLs,
+	}
+
+	if err := indexTemplate.Execute(w, data); err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+// serveInitializer serves the script starting Swagger UI.
+func (h *Handler) serveInitializer(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+	w.Write(initializer)
+}
+
+// serveSpec serves an OpenAPI document by name.
+func (h *Handler) serveSpec(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/docs/openapi/"), ".json")
+
+	doc, ok := h.specs[name]
+	if !ok || !strings.HasSuffix(r.URL.Path, ".json") {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(doc)
+}
@@ -0,0 +1,90 @@
+package apidocs
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testAssetsURL string = "https://cdn.example.com/swagger-ui-dist@5/"
+
+func newHandlerHelper() *Handler {
+	return NewHandler([]Spec{
+		{Name: "v2", Document: []byte(`{"swagger":"2.0","info":{"version":"v2"}}`)},
+		{Name: "v1", Document: []byte(`{"swagger":"2.0","info":{"version":"v1"}}`)},
+	}, testAssetsURL)
+}
+
+func TestIndex(t *testing.T) {
+	t.Parallel()
+
+	h := newHandlerHelper()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/docs/", nil))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Header().Get("Content-Type"), "text/html")
+
+	csp := rec.Header().Get("Content-Security-Policy")
+	assert.Contains(t, csp, "script-src 'self' https://cdn.example.com;")
+	assert.Contains(t, csp, "frame-ancestors 'none'")
+
+	body := rec.Body.String()
+	assert.Contains(t, body, `src="https://cdn.example.com/swagger-ui-dist@5/swagger-ui-bundle.js"`)
+	assert.Contains(t, body, `src="swagger-initializer.js"`)
+	assert.Contains(t, body, `data-specs="[{&#34;url&#34;:&#34;openapi/v2.json&#34;,&#34;name&#34;:&#34;v2&#34;},`)
+}
+
+func TestSpec(t *testing.T) {
+	t.Parallel()
+
+	h := newHandlerHelper()
+
+	t.Run("known spec", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/docs/openapi/v1.json", nil))
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+		assert.JSONEq(t, `{"swagger":"2.0","info":{"version":"v1"}}`, rec.Body.String())
+	})
+
+	testCases := []struct {
+		name   string
+		method string
+		target string
+		code   int
+	}{
+		{name: "unknown spec", method: http.MethodGet, target: "/docs/openapi/v3.json", code: http.StatusNotFound},
+		{name: "without extension", method: http.MethodGet, target: "/docs/openapi/v1", code: http.StatusNotFound},
+		{name: "unknown page", method: http.MethodGet, target: "/docs/index.html", code: http.StatusNotFound},
+		{name: "not a GET", method: http.MethodPost, target: "/docs/openapi/v1.json", code: http.StatusMethodNotAllowed},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(tc.method, tc.target, nil))
+
+			assert.Equal(t, tc.code, rec.Code)
+		})
+	}
+}
+
+func TestInitializer(t *testing.T) {
+	t.Parallel()
+
+	h := newHandlerHelper()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/docs/swagger-initializer.js", nil))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Header().Get("Content-Type"), "text/javascript")
+	assert.Contains(t, rec.Body.String(), "supportedSubmitMethods: []")
+}
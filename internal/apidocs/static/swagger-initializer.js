@@ -0,0 +1,16 @@
+(function () {
+  'use strict';
+
+  const root = document.getElementById('swagger-ui');
+
+  window.ui = SwaggerUIBundle({
+    urls: JSON.parse(root.dataset.specs),
+    domNode: root,
+    deepLinking: true,
+    presets: [SwaggerUIBundle.presets.apis, SwaggerUIStandalonePreset],
+    layout: 'StandaloneLayout',
+    // The API is served over gRPC only, the documents describe it but cannot be called
+    // from the browser.
+    supportedSubmitMethods: [],
+  });
+})();
@@ -0,0 +1,119 @@
+// Package audit records who changed which user, and how, for compliance reviews.
+package audit
+
+import (
+	"context"
+	"time"
+)
+
+// Action is the kind of change recorded.
+type Action string
+
+const (
+	// Enumerate the audited actions.
+
+	ActionCreate         Action = "create"
+	ActionUpdate         Action = "update"
+	ActionDelete         Action = "delete"
+	ActionMerge          Action = "merge"
+	ActionPasswordChange Action = "password_change"
+	ActionPasswordReset  Action = "password_reset"
+
+	// Field locks record the reason of the locked fields as their value, and rejected
+	// changes record the values the locked fields would have been changed to.
+	ActionFieldLock           Action = "field_lock"
+	ActionFieldUnlock         Action = "field_unlock"
+	ActionLockedFieldRejected Action = "locked_field_rejected"
+
+	// Unlocks record when the user was locked out until, after too many failed logins.
+	ActionUnlock Action = "unlock"
+
+	// Status changes record the status before and after.
+	ActionStatusChange Action = "status_change"
+
+	// Anonymizations record the values the personal data was overwritten with,
+	// the values before are always redacted.
+	ActionAnonymize Action = "anonymize"
+)
+
+const (
+	// Anonymous is the actor of the changes made by unauthenticated callers.
+	Anonymous string = "anonymous"
+
+	// Redacted replaces the values of secret fields, e.g. password hashes.
+	Redacted string = "[redacted]"
+)
+
+// Event is an entry of the audit log.
+type Event struct {
+	ID        int64
+	Actor     string
+	Action    Action
+	UserID    string
+	Changes   map[string]Change
+	CreatedAt time.Time
+}
+
+// Change is the value of a field before and after the change.
+// Before is empty for created fields and After for deleted ones.
+type Change struct {
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+}
+
+// Filter selects the events to list, newest first.
+type Filter struct {
+	UserID string // Empty for all users.
+
+	// Cursor is the id of the last event of the previous page, 0 for the first page.
+	Cursor int64
+	Limit  int
+}
+
+// Diff returns the fields whose values differ between before and after.
+func Diff(before, after map[string]string) map[string]Change {
+	changes := make(map[string]Change)
+	for field, value := range after {
+		if before[field] != value {
+			changes[field] = Change{Before: before[field], After: value}
+		}
+	}
+
+	for field, value := range before {
+		if _, ok := after[field]; !ok {
+			changes[field] = Change{Before: value}
+		}
+	}
+	return changes
+}
+
+// redactChanges replaces the values of the changes with Redacted, keeping the fields that
+// changed but not their personal data.
+func redactChanges(changes map[string]Change) {
+	for field, change := range changes {
+		if change.Before != "" {
+			change.Before = Redacted
+		}
+
+		if change.After != "" {
+			change.After = Redacted
+		}
+		changes[field] = change
+	}
+}
+
+type actorKey struct{}
+
+// ContextWithActor returns a copy of ctx carrying the actor of the changes,
+// e.g. the id of the authenticated caller.
+func ContextWithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the actor of the changes, or Anonymous.
+func ActorFromContext(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey{}).(string); ok && actor != "" {
+		return actor
+	}
+	return Anonymous
+}
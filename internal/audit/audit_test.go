@@ -0,0 +1,60 @@
+package audit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		before   map[string]string
+		after    map[string]string
+		expected map[string]Change
+	}{
+		{
+			name:     "created",
+			before:   nil,
+			after:    map[string]string{"email": "joedoe@foo.bar"},
+			expected: map[string]Change{"email": {After: "joedoe@foo.bar"}},
+		},
+		{
+			name:     "deleted",
+			before:   map[string]string{"email": "joedoe@foo.bar"},
+			after:    nil,
+			expected: map[string]Change{"email": {Before: "joedoe@foo.bar"}},
+		},
+		{
+			name:     "updated",
+			before:   map[string]string{"email": "joedoe@foo.bar", "country": "BR"},
+			after:    map[string]string{"email": "joedoe@foo.bar", "country": "PT"},
+			expected: map[string]Change{"country": {Before: "BR", After: "PT"}},
+		},
+		{
+			name:     "unchanged",
+			before:   map[string]string{"country": "BR"},
+			after:    map[string]string{"country": "BR"},
+			expected: map[string]Change{},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expected, Diff(tc.before, tc.after))
+		})
+	}
+}
+
+func TestActorFromContext(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, Anonymous, ActorFromContext(context.TODO()))
+	assert.Equal(t, "some-id", ActorFromContext(ContextWithActor(context.TODO(), "some-id")))
+}
@@ -0,0 +1,68 @@
+package audit
+
+import (
+	"context"
+	"sync"
+)
+
+// Memory keeps the audit log in memory, for local development and tests.
+type Memory struct {
+	mu     sync.RWMutex
+	events []Event
+}
+
+// NewMemory creates a new in-memory audit log.
+func NewMemory() *Memory {
+	return &Memory{}
+}
+
+// Record appends the event to the audit log and sets its id.
+func (m *Memory) Record(ctx context.Context, event *Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	event.ID = int64(len(m.events) + 1)
+	m.events = append(m.events, *event)
+	return nil
+}
+
+// Redact redacts the values of the changes recorded so far for the user.
+func (m *Memory) Redact(ctx context.Context, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.events {
+		if m.events[i].UserID != userID {
+			continue
+		}
+
+		// The events returned by List share their changes, so they are copied.
+		changes := make(map[string]Change, len(m.events[i].Changes))
+		for field, change := range m.events[i].Changes {
+			changes[field] = change
+		}
+		redactChanges(changes)
+		m.events[i].Changes = changes
+	}
+	return nil
+}
+
+// List returns the events matching the filter, newest first.
+func (m *Memory) List(ctx context.Context, filter Filter) ([]*Event, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var events []*Event
+	for i := len(m.events) - 1; i >= 0 && len(events) < filter.Limit; i-- {
+		event := m.events[i]
+		if filter.UserID != "" && event.UserID != filter.UserID {
+			continue
+		}
+
+		if filter.Cursor > 0 && event.ID >= filter.Cursor {
+			continue
+		}
+		events = append(events, &event)
+	}
+	return events, nil
+}
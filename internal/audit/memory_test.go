@@ -0,0 +1,83 @@
+package audit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemory(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	log := NewMemory()
+
+	for _, userID := range []string{"user-1", "user-2", "user-1", "user-1"} {
+		require.NoError(t, log.Record(context.TODO(), &Event{
+			Actor:     "admin",
+			Action:    ActionUpdate,
+			UserID:    userID,
+			Changes:   map[string]Change{"country": {Before: "BR", After: "PT"}},
+			CreatedAt: time.Now(),
+		}))
+	}
+
+	// Act
+	firstPage, err := log.List(context.TODO(), Filter{UserID: "user-1", Limit: 2})
+	require.NoError(t, err)
+
+	secondPage, err := log.List(context.TODO(), Filter{UserID: "user-1", Cursor: firstPage[1].ID, Limit: 2})
+	require.NoError(t, err)
+
+	all, err := log.List(context.TODO(), Filter{Limit: 10})
+	require.NoError(t, err)
+
+	// Assert
+	require.Len(t, firstPage, 2)
+	assert.Equal(t, int64(4), firstPage[0].ID)
+	assert.Equal(t, int64(3), firstPage[1].ID)
+
+	require.Len(t, secondPage, 1)
+	assert.Equal(t, int64(1), secondPage[0].ID)
+
+	assert.Len(t, all, 4)
+}
+
+func TestMemoryRedact(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	log := NewMemory()
+
+	for _, userID := range []string{"user-1", "user-2"} {
+		require.NoError(t, log.Record(context.TODO(), &Event{
+			Actor:     "admin",
+			Action:    ActionUpdate,
+			UserID:    userID,
+			Changes:   map[string]Change{"email": {Before: "joedoe@foo.bar", After: "jdoe@foo.bar"}, "phone": {After: "+5511999999999"}},
+			CreatedAt: time.Now(),
+		}))
+	}
+
+	listed, err := log.List(context.TODO(), Filter{UserID: "user-1", Limit: 1})
+	require.NoError(t, err)
+
+	// Act
+	err = log.Redact(context.TODO(), "user-1")
+
+	// Assert
+	require.NoError(t, err)
+
+	redacted, err := log.List(context.TODO(), Filter{UserID: "user-1", Limit: 1})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]Change{"email": {Before: Redacted, After: Redacted}, "phone": {After: Redacted}}, redacted[0].Changes)
+
+	// The other users and the events listed before are left as they are.
+	other, err := log.List(context.TODO(), Filter{UserID: "user-2", Limit: 1})
+	require.NoError(t, err)
+	assert.Equal(t, "joedoe@foo.bar", other[0].Changes["email"].Before)
+	assert.Equal(t, "joedoe@foo.bar", listed[0].Changes["email"].Before)
+}
@@ -0,0 +1,142 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	mongoAuditLog   string = "audit_log"
+	mongoCounters   string = "counters"
+	mongoAuditIDKey string = "audit_log"
+)
+
+// document is the audit_log collection document.
+type document struct {
+	ID        int64             `bson:"_id"`
+	Actor     string            `bson:"actor"`
+	Action    string            `bson:"action"`
+	UserID    string            `bson:"user_id"`
+	Changes   map[string]Change `bson:"changes"`
+	CreatedAt time.Time         `bson:"created_at"`
+}
+
+// Mongo stores the audit log in the audit_log collection. The ids are taken from
+// a counter document, so the events are paged by id like in Postgres.
+type Mongo struct {
+	db *mongo.Database
+}
+
+// NewMongo creates a new Mongo audit log.
+func NewMongo(db *mongo.Database) *Mongo {
+	return &Mongo{db: db}
+}
+
+// EnsureIndexes creates the index listing the events of a user.
+func (m *Mongo) EnsureIndexes(ctx context.Context) error {
+	if _, err := m.db.Collection(mongoAuditLog).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "_id", Value: -1}},
+	}); err != nil {
+		return fmt.Errorf("could not create audit log indexes: %w", err)
+	}
+	return nil
+}
+
+// Record appends the event to the audit log and sets its id.
+func (m *Mongo) Record(ctx context.Context, event *Event) error {
+	var counter struct {
+		Seq int64 `bson:"seq"`
+	}
+	if err := m.db.Collection(mongoCounters).FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": mongoAuditIDKey},
+		bson.M{"$inc": bson.M{"seq": 1}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&counter); err != nil {
+		return fmt.Errorf("could not get audit event id: %w", err)
+	}
+
+	if _, err := m.db.Collection(mongoAuditLog).InsertOne(ctx, document{
+		ID:        counter.Seq,
+		Actor:     event.Actor,
+		Action:    string(event.Action),
+		UserID:    event.UserID,
+		Changes:   event.Changes,
+		CreatedAt: event.CreatedAt,
+	}); err != nil {
+		return fmt.Errorf("could not insert audit event: %w", err)
+	}
+
+	event.ID = counter.Seq
+	return nil
+}
+
+// Redact redacts the values of the changes recorded so far for the user.
+func (m *Mongo) Redact(ctx context.Context, userID string) error {
+	cur, err := m.db.Collection(mongoAuditLog).Find(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return fmt.Errorf("could not list audit events: %w", err)
+	}
+
+	var docs []document
+	if err := cur.All(ctx, &docs); err != nil {
+		return fmt.Errorf("could not list audit events: %w", err)
+	}
+
+	for _, d := range docs {
+		redactChanges(d.Changes)
+
+		if _, err := m.db.Collection(mongoAuditLog).UpdateOne(
+			ctx,
+			bson.M{"_id": d.ID},
+			bson.M{"$set": bson.M{"changes": d.Changes}},
+		); err != nil {
+			return fmt.Errorf("could not redact audit event %d: %w", d.ID, err)
+		}
+	}
+	return nil
+}
+
+// List returns the events matching the filter, newest first.
+func (m *Mongo) List(ctx context.Context, filter Filter) ([]*Event, error) {
+	query := bson.M{}
+	if filter.UserID != "" {
+		query["user_id"] = filter.UserID
+	}
+
+	if filter.Cursor > 0 {
+		query["_id"] = bson.M{"$lt": filter.Cursor}
+	}
+
+	cur, err := m.db.Collection(mongoAuditLog).Find(
+		ctx,
+		query,
+		options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetLimit(int64(filter.Limit)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not list audit events: %w", err)
+	}
+
+	var docs []document
+	if err := cur.All(ctx, &docs); err != nil {
+		return nil, fmt.Errorf("could not list audit events: %w", err)
+	}
+
+	events := make([]*Event, 0, len(docs))
+	for _, d := range docs {
+		events = append(events, &Event{
+			ID:        d.ID,
+			Actor:     d.Actor,
+			Action:    Action(d.Action),
+			UserID:    d.UserID,
+			Changes:   d.Changes,
+			CreatedAt: d.CreatedAt,
+		})
+	}
+	return events, nil
+}
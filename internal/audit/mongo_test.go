@@ -0,0 +1,88 @@
+//go:build integration
+// +build integration
+
+package audit
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const defaultMongoURI string = "mongodb://localhost:27017/?replicaSet=rs0&directConnection=true"
+
+func TestMongo(t *testing.T) {
+	// Arrange
+	log := NewMongo(setupMongoHelper(t))
+	require.NoError(t, log.EnsureIndexes(context.TODO()))
+
+	userID := uuid.New().String()
+	createdAt := time.Now().UTC().Truncate(time.Millisecond)
+
+	for _, id := range []string{userID, uuid.New().String(), userID} {
+		require.NoError(t, log.Record(context.TODO(), &Event{
+			Actor:     "admin",
+			Action:    ActionUpdate,
+			UserID:    id,
+			Changes:   map[string]Change{"country": {Before: "BR", After: "PT"}},
+			CreatedAt: createdAt,
+		}))
+	}
+
+	// Act
+	firstPage, err := log.List(context.TODO(), Filter{UserID: userID, Limit: 1})
+	require.NoError(t, err)
+
+	secondPage, err := log.List(context.TODO(), Filter{UserID: userID, Cursor: firstPage[0].ID, Limit: 1})
+	require.NoError(t, err)
+
+	// Assert
+	require.Len(t, firstPage, 1)
+	require.Len(t, secondPage, 1)
+	assert.Greater(t, firstPage[0].ID, secondPage[0].ID)
+
+	event := firstPage[0]
+	assert.Equal(t, "admin", event.Actor)
+	assert.Equal(t, ActionUpdate, event.Action)
+	assert.Equal(t, userID, event.UserID)
+	assert.Equal(t, map[string]Change{"country": {Before: "BR", After: "PT"}}, event.Changes)
+	assert.True(t, createdAt.Equal(event.CreatedAt))
+
+	// The changes of the user are redacted, for the right to be forgotten.
+	require.NoError(t, log.Redact(context.TODO(), userID))
+
+	redacted, err := log.List(context.TODO(), Filter{UserID: userID, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, redacted, 2)
+	for _, event := range redacted {
+		assert.Equal(t, map[string]Change{"country": {Before: Redacted, After: Redacted}}, event.Changes)
+	}
+}
+
+// setupMongoHelper returns a new database, dropped when the test ends.
+func setupMongoHelper(t *testing.T) *mongo.Database {
+	t.Helper()
+
+	uri := os.Getenv("MONGO_URI")
+	if uri == "" {
+		uri = defaultMongoURI
+	}
+
+	client, err := mongo.Connect(context.TODO(), options.Client().ApplyURI(uri))
+	require.NoError(t, err)
+
+	db := client.Database("usrsvc_test_" + strings.ReplaceAll(uuid.New().String(), "-", ""))
+	t.Cleanup(func() {
+		db.Drop(context.TODO())
+		client.Disconnect(context.TODO())
+	})
+	return db
+}
@@ -0,0 +1,130 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// row is the audit_log table row.
+type row struct {
+	ID        int64     `db:"id"`
+	Actor     string    `db:"actor"`
+	Action    string    `db:"action"`
+	UserID    string    `db:"user_id"`
+	Changes   []byte    `db:"changes"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// Postgres stores the audit log in the audit_log table. The queries are portable,
+// so it also stores the audit log on the SQLite schema.
+type Postgres struct {
+	db *sqlx.DB
+}
+
+// NewPostgres creates a new Postgres audit log.
+func NewPostgres(db *sqlx.DB) *Postgres {
+	return &Postgres{db: db}
+}
+
+// Record appends the event to the audit log and sets its id.
+func (p *Postgres) Record(ctx context.Context, event *Event) error {
+	changes, err := json.Marshal(event.Changes)
+	if err != nil {
+		return fmt.Errorf("could not marshal audit changes: %w", err)
+	}
+
+	if err := p.db.QueryRowxContext(
+		ctx,
+		`INSERT INTO audit_log (actor, action, user_id, changes, created_at)
+		VALUES ($1, $2, $3, $4, $5) RETURNING id`,
+		event.Actor,
+		event.Action,
+		event.UserID,
+		changes,
+		event.CreatedAt,
+	).Scan(&event.ID); err != nil {
+		return fmt.Errorf("could not insert audit event: %w", err)
+	}
+	return nil
+}
+
+// Redact redacts the values of the changes recorded so far for the user.
+func (p *Postgres) Redact(ctx context.Context, userID string) error {
+	tx, err := p.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var rows []row
+	if err := tx.SelectContext(ctx, &rows, "SELECT id, changes FROM audit_log WHERE user_id = $1", userID); err != nil {
+		return fmt.Errorf("could not list audit events: %w", err)
+	}
+
+	for _, r := range rows {
+		var changes map[string]Change
+		if err := json.Unmarshal(r.Changes, &changes); err != nil {
+			return fmt.Errorf("could not unmarshal changes of audit event %d: %w", r.ID, err)
+		}
+		redactChanges(changes)
+
+		redacted, err := json.Marshal(changes)
+		if err != nil {
+			return fmt.Errorf("could not marshal audit changes: %w", err)
+		}
+
+		if _, err := tx.ExecContext(ctx, "UPDATE audit_log SET changes = $1 WHERE id = $2", redacted, r.ID); err != nil {
+			return fmt.Errorf("could not redact audit event %d: %w", r.ID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("could not commit transaction: %w", err)
+	}
+	return nil
+}
+
+// List returns the events matching the filter, newest first.
+func (p *Postgres) List(ctx context.Context, filter Filter) ([]*Event, error) {
+	query := "SELECT id, actor, action, user_id, changes, created_at FROM audit_log WHERE TRUE"
+
+	var args []any
+	if filter.UserID != "" {
+		args = append(args, filter.UserID)
+		query += fmt.Sprintf(" AND user_id = $%d", len(args))
+	}
+
+	if filter.Cursor > 0 {
+		args = append(args, filter.Cursor)
+		query += fmt.Sprintf(" AND id < $%d", len(args))
+	}
+
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d", len(args))
+
+	var rows []row
+	if err := p.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		return nil, fmt.Errorf("could not list audit events: %w", err)
+	}
+
+	events := make([]*Event, 0, len(rows))
+	for _, r := range rows {
+		event := Event{
+			ID:        r.ID,
+			Actor:     r.Actor,
+			Action:    Action(r.Action),
+			UserID:    r.UserID,
+			CreatedAt: r.CreatedAt,
+		}
+
+		if err := json.Unmarshal(r.Changes, &event.Changes); err != nil {
+			return nil, fmt.Errorf("could not unmarshal changes of audit event %d: %w", r.ID, err)
+		}
+		events = append(events, &event)
+	}
+	return events, nil
+}
@@ -0,0 +1,100 @@
+//go:build integration
+// +build integration
+
+package audit
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+	"github.com/pressly/goose/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	migrationsDir      string = "../../migrations"
+	postgresDriverName string = "postgres"
+	dbHost             string = "localhost"
+	dbPort             string = "5432"
+	dbUser             string = "user"
+	dbPass             string = "password"
+	dbName             string = "usrsvc"
+)
+
+func TestPostgres(t *testing.T) {
+	db := setupDBHelper(t)
+	defer teardownDBHelper(t, db)
+
+	// Arrange
+	log := NewPostgres(db)
+
+	userID := uuid.New().String()
+	createdAt := time.Now().UTC().Truncate(time.Millisecond)
+
+	for _, id := range []string{userID, uuid.New().String(), userID} {
+		require.NoError(t, log.Record(context.TODO(), &Event{
+			Actor:     "admin",
+			Action:    ActionUpdate,
+			UserID:    id,
+			Changes:   map[string]Change{"country": {Before: "BR", After: "PT"}},
+			CreatedAt: createdAt,
+		}))
+	}
+
+	// Act
+	firstPage, err := log.List(context.TODO(), Filter{UserID: userID, Limit: 1})
+	require.NoError(t, err)
+
+	secondPage, err := log.List(context.TODO(), Filter{UserID: userID, Cursor: firstPage[0].ID, Limit: 1})
+	require.NoError(t, err)
+
+	// Assert
+	require.Len(t, firstPage, 1)
+	require.Len(t, secondPage, 1)
+	assert.Greater(t, firstPage[0].ID, secondPage[0].ID)
+
+	event := firstPage[0]
+	assert.Equal(t, "admin", event.Actor)
+	assert.Equal(t, ActionUpdate, event.Action)
+	assert.Equal(t, userID, event.UserID)
+	assert.Equal(t, map[string]Change{"country": {Before: "BR", After: "PT"}}, event.Changes)
+	assert.True(t, createdAt.Equal(event.CreatedAt))
+
+	// The changes of the user are redacted, for the right to be forgotten.
+	require.NoError(t, log.Redact(context.TODO(), userID))
+
+	redacted, err := log.List(context.TODO(), Filter{UserID: userID, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, redacted, 2)
+	for _, event := range redacted {
+		assert.Equal(t, map[string]Change{"country": {Before: Redacted, After: Redacted}}, event.Changes)
+	}
+}
+
+func setupDBHelper(t *testing.T) *sqlx.DB {
+	t.Helper()
+
+	db, err := sqlx.Open(postgresDriverName, fmt.Sprintf(
+		"host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+		dbHost, dbPort, dbUser, dbPass, dbName),
+	)
+	require.NoError(t, err)
+
+	require.NoError(t, goose.Up(db.DB, migrationsDir))
+	return db
+}
+
+func teardownDBHelper(t *testing.T, db *sqlx.DB) {
+	t.Helper()
+
+	_, err := db.Exec("TRUNCATE TABLE audit_log")
+	require.NoError(t, err)
+
+	require.NoError(t, db.Close())
+}
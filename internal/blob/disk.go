@@ -0,0 +1,106 @@
+// Package blob provides the blob stores keeping the files of the users, e.g. their avatars,
+// out of the database. Other stores (e.g. S3 or GCS buckets) implement the same methods.
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	// ErrNotFound is returned when no object is stored under the key.
+	ErrNotFound error = errors.New("blob not found")
+
+	// ErrInvalidKey is returned for the keys escaping the store, e.g. with "..".
+	ErrInvalidKey error = errors.New("invalid blob key")
+)
+
+// Disk is a blob store keeping the objects as files under a directory, for local
+// development and single instance deployments. The keys are slash-separated paths.
+type Disk struct {
+	dir string
+}
+
+// NewDisk creates a new disk blob store under the directory, creating it if needed.
+func NewDisk(dir string) (*Disk, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("could not create blob directory '%s': %w", dir, err)
+	}
+	return &Disk{dir: dir}, nil
+}
+
+// Put stores the data under the key, replacing the previous object. The data is written
+// to a temporary file first, so readers never see a partial object.
+func (d *Disk) Put(ctx context.Context, key string, data []byte) error {
+	path, err := d.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return fmt.Errorf("could not create blob directory for key '%s': %w", key, err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("could not create blob file for key '%s': %w", key, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("could not write blob for key '%s': %w", key, err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("could not write blob for key '%s': %w", key, err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("could not store blob for key '%s': %w", key, err)
+	}
+	return nil
+}
+
+// Get returns the data stored under the key or ErrNotFound.
+func (d *Disk) Get(ctx context.Context, key string) ([]byte, error) {
+	path, err := d.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("could not read blob for key '%s': %w", key, ErrNotFound)
+		}
+		return nil, fmt.Errorf("could not read blob for key '%s': %w", key, err)
+	}
+	return data, nil
+}
+
+// Delete deletes the object stored under the key. Deleting a missing object is a no-op.
+func (d *Disk) Delete(ctx context.Context, key string) error {
+	path, err := d.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("could not delete blob for key '%s': %w", key, err)
+	}
+	return nil
+}
+
+// path returns the file of the key, making sure it is inside the directory.
+func (d *Disk) path(key string) (string, error) {
+	cleaned := filepath.Clean(filepath.FromSlash(key))
+	if key == "" || filepath.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("could not use key '%s': %w", key, ErrInvalidKey)
+	}
+	return filepath.Join(d.dir, cleaned), nil
+}
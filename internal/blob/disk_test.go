@@ -0,0 +1,51 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDisk(t *testing.T) {
+	t.Parallel()
+
+	t.Run("objects can be stored, replaced and deleted", func(t *testing.T) {
+		// Arrange
+		store, err := NewDisk(t.TempDir())
+		require.NoError(t, err)
+
+		// Act
+		require.NoError(t, store.Put(context.TODO(), "avatars/123/abc", []byte("first")))
+		require.NoError(t, store.Put(context.TODO(), "avatars/123/abc", []byte("second")))
+
+		data, err := store.Get(context.TODO(), "avatars/123/abc")
+		require.NoError(t, err)
+
+		require.NoError(t, store.Delete(context.TODO(), "avatars/123/abc"))
+		_, getErr := store.Get(context.TODO(), "avatars/123/abc")
+
+		// Assert
+		assert.Equal(t, []byte("second"), data)
+		assert.True(t, errors.Is(getErr, ErrNotFound))
+
+		// Deleting a missing object is a no-op.
+		assert.NoError(t, store.Delete(context.TODO(), "avatars/123/abc"))
+	})
+
+	t.Run("keys can't escape the directory", func(t *testing.T) {
+		// Arrange
+		store, err := NewDisk(t.TempDir())
+		require.NoError(t, err)
+
+		for _, key := range []string{"", "..", "../secret", "avatars/../../secret", "/etc/passwd"} {
+			// Act
+			err := store.Put(context.TODO(), key, []byte("data"))
+
+			// Assert
+			assert.True(t, errors.Is(err, ErrInvalidKey), key)
+		}
+	})
+}
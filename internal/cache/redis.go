@@ -0,0 +1,62 @@
+// Package cache provides the key-value cache used by the service.
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrMiss is returned when the key is not in the cache.
+var ErrMiss error = errors.New("cache miss")
+
+// Redis is a cache backed by Redis.
+type Redis struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewRedis creates a new Redis cache. Every key is prefixed so
+// the same Redis instance can be shared with other services.
+func NewRedis(client redis.UniversalClient, prefix string) *Redis {
+	return &Redis{client: client, prefix: prefix}
+}
+
+// Get returns the value stored under the key or ErrMiss.
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, error) {
+	value, err := r.client.Get(ctx, r.prefix+key).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrMiss
+		}
+		return nil, fmt.Errorf("could not get key '%s': %w", key, err)
+	}
+	return value, nil
+}
+
+// Set stores the value under the key for the given ttl.
+func (r *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if err := r.client.Set(ctx, r.prefix+key, value, ttl).Err(); err != nil {
+		return fmt.Errorf("could not set key '%s': %w", key, err)
+	}
+	return nil
+}
+
+// Delete removes the key. Deleting a missing key is not an error.
+func (r *Redis) Delete(ctx context.Context, key string) error {
+	if err := r.client.Del(ctx, r.prefix+key).Err(); err != nil {
+		return fmt.Errorf("could not delete key '%s': %w", key, err)
+	}
+	return nil
+}
+
+// Ping checks the connection to Redis.
+func (r *Redis) Ping(ctx context.Context) error {
+	if err := r.client.Ping(ctx).Err(); err != nil {
+		return fmt.Errorf("could not ping redis: %w", err)
+	}
+	return nil
+}
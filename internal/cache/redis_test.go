@@ -0,0 +1,74 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupRedisHelper(t *testing.T) (*Redis, *miniredis.Miniredis) {
+	t.Helper()
+
+	srv := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: srv.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	return NewRedis(client, "usrsvc:"), srv
+}
+
+func TestRedis(t *testing.T) {
+	t.Parallel()
+
+	t.Run("set, get and delete", func(t *testing.T) {
+		// Arrange
+		c, srv := setupRedisHelper(t)
+
+		// Act
+		require.NoError(t, c.Set(context.TODO(), "user:1", []byte("foo"), time.Minute))
+		value, err := c.Get(context.TODO(), "user:1")
+		require.NoError(t, err)
+
+		stored := srv.Exists("usrsvc:user:1")
+
+		require.NoError(t, c.Delete(context.TODO(), "user:1"))
+		_, missErr := c.Get(context.TODO(), "user:1")
+
+		// Assert
+		assert.Equal(t, []byte("foo"), value)
+		assert.True(t, errors.Is(missErr, ErrMiss))
+		assert.True(t, stored, "keys must be prefixed")
+	})
+
+	t.Run("expires after the ttl", func(t *testing.T) {
+		// Arrange
+		c, srv := setupRedisHelper(t)
+		require.NoError(t, c.Set(context.TODO(), "user:1", []byte("foo"), time.Minute))
+
+		// Act
+		srv.FastForward(2 * time.Minute)
+		_, err := c.Get(context.TODO(), "user:1")
+
+		// Assert
+		assert.True(t, errors.Is(err, ErrMiss))
+	})
+
+	t.Run("connection error", func(t *testing.T) {
+		// Arrange
+		c, srv := setupRedisHelper(t)
+		srv.Close()
+
+		// Act
+		_, err := c.Get(context.TODO(), "user:1")
+
+		// Assert
+		assert.Error(t, err)
+		assert.False(t, errors.Is(err, ErrMiss))
+		assert.Error(t, c.Ping(context.TODO()))
+	})
+}
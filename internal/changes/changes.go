@@ -0,0 +1,37 @@
+// Package changes records every change made to the users with a snapshot of the user,
+// so downstream systems can sync the users incrementally without a message broker.
+package changes
+
+import "time"
+
+// Type is the kind of change recorded.
+type Type string
+
+const (
+	// Enumerate the change types. Every other change of a user, e.g. of its status,
+	// is an update.
+
+	TypeCreated Type = "created"
+	TypeUpdated Type = "updated"
+	TypeDeleted Type = "deleted"
+)
+
+// Change is an entry of the change log. The ids increase with the changes, so the id of
+// the last change synced is the position to resume from.
+type Change struct {
+	ID     int64
+	Type   Type
+	UserID string
+
+	// Snapshot is the user after the change, encoded by the recorder, and empty for deletions.
+	Snapshot []byte
+
+	CreatedAt time.Time
+}
+
+// Filter selects the changes to list, oldest first.
+type Filter struct {
+	// Since is the id of the last change synced, 0 to list from the first change.
+	Since int64
+	Limit int
+}
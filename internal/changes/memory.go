@@ -0,0 +1,53 @@
+package changes
+
+import (
+	"context"
+	"sync"
+)
+
+// Memory keeps the change log in memory, for local development and tests.
+type Memory struct {
+	mu      sync.RWMutex
+	changes []Change
+}
+
+// NewMemory creates a new in-memory change log.
+func NewMemory() *Memory {
+	return &Memory{}
+}
+
+// Record appends the change to the change log and sets its id.
+func (m *Memory) Record(ctx context.Context, change *Change) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	change.ID = int64(len(m.changes) + 1)
+	m.changes = append(m.changes, *change)
+	return nil
+}
+
+// List returns the changes after the Since of the filter, oldest first.
+func (m *Memory) List(ctx context.Context, filter Filter) ([]*Change, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var changes []*Change
+	for i := int(filter.Since); i >= 0 && i < len(m.changes) && len(changes) < filter.Limit; i++ {
+		change := m.changes[i]
+		changes = append(changes, &change)
+	}
+	return changes, nil
+}
+
+// EraseSnapshots erases the snapshots of the changes of the user recorded so far.
+func (m *Memory) EraseSnapshots(ctx context.Context, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.changes {
+		if m.changes[i].UserID == userID {
+			m.changes[i].Snapshot = nil
+		}
+	}
+	return nil
+}
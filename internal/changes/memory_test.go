@@ -0,0 +1,77 @@
+package changes
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemory(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	log := NewMemory()
+
+	for _, userID := range []string{"user-1", "user-2", "user-1"} {
+		require.NoError(t, log.Record(context.TODO(), &Change{
+			Type:      TypeUpdated,
+			UserID:    userID,
+			Snapshot:  []byte(`{"id":"` + userID + `"}`),
+			CreatedAt: time.Now(),
+		}))
+	}
+
+	// Act
+	firstPage, err := log.List(context.TODO(), Filter{Limit: 2})
+	require.NoError(t, err)
+
+	secondPage, err := log.List(context.TODO(), Filter{Since: firstPage[1].ID, Limit: 2})
+	require.NoError(t, err)
+
+	lastPage, err := log.List(context.TODO(), Filter{Since: secondPage[0].ID, Limit: 2})
+	require.NoError(t, err)
+
+	// Assert
+	require.Len(t, firstPage, 2)
+	assert.Equal(t, int64(1), firstPage[0].ID)
+	assert.Equal(t, int64(2), firstPage[1].ID)
+	assert.Equal(t, "user-2", firstPage[1].UserID)
+
+	require.Len(t, secondPage, 1)
+	assert.Equal(t, int64(3), secondPage[0].ID)
+	assert.JSONEq(t, `{"id":"user-1"}`, string(secondPage[0].Snapshot))
+
+	assert.Empty(t, lastPage)
+}
+
+func TestMemoryEraseSnapshots(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	log := NewMemory()
+
+	for _, userID := range []string{"user-1", "user-2"} {
+		require.NoError(t, log.Record(context.TODO(), &Change{
+			Type:      TypeCreated,
+			UserID:    userID,
+			Snapshot:  []byte(`{"id":"` + userID + `"}`),
+			CreatedAt: time.Now(),
+		}))
+	}
+
+	// Act
+	err := log.EraseSnapshots(context.TODO(), "user-1")
+	require.NoError(t, err)
+
+	// Assert
+	all, err := log.List(context.TODO(), Filter{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, all, 2)
+
+	assert.Empty(t, all[0].Snapshot)
+	assert.Equal(t, TypeCreated, all[0].Type)
+	assert.NotEmpty(t, all[1].Snapshot)
+}
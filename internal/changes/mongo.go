@@ -0,0 +1,116 @@
+package changes
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	mongoUserChanges string = "user_changes"
+	mongoCounters    string = "counters"
+	mongoChangeIDKey string = "user_changes"
+)
+
+// document is the user_changes collection document.
+type document struct {
+	ID        int64     `bson:"_id"`
+	Type      string    `bson:"type"`
+	UserID    string    `bson:"user_id"`
+	Snapshot  []byte    `bson:"snapshot,omitempty"`
+	CreatedAt time.Time `bson:"created_at"`
+}
+
+// Mongo stores the change log in the user_changes collection. The ids are taken from
+// a counter document, so the changes are listed by id like in Postgres.
+type Mongo struct {
+	db *mongo.Database
+}
+
+// NewMongo creates a new Mongo change log.
+func NewMongo(db *mongo.Database) *Mongo {
+	return &Mongo{db: db}
+}
+
+// Record appends the change to the change log and sets its id.
+func (m *Mongo) Record(ctx context.Context, change *Change) error {
+	var counter struct {
+		Seq int64 `bson:"seq"`
+	}
+	if err := m.db.Collection(mongoCounters).FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": mongoChangeIDKey},
+		bson.M{"$inc": bson.M{"seq": 1}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&counter); err != nil {
+		return fmt.Errorf("could not get user change id: %w", err)
+	}
+
+	if _, err := m.db.Collection(mongoUserChanges).InsertOne(ctx, document{
+		ID:        counter.Seq,
+		Type:      string(change.Type),
+		UserID:    change.UserID,
+		Snapshot:  change.Snapshot,
+		CreatedAt: change.CreatedAt,
+	}); err != nil {
+		return fmt.Errorf("could not insert user change: %w", err)
+	}
+
+	change.ID = counter.Seq
+	return nil
+}
+
+// List returns the changes after the Since of the filter, oldest first.
+func (m *Mongo) List(ctx context.Context, filter Filter) ([]*Change, error) {
+	cur, err := m.db.Collection(mongoUserChanges).Find(
+		ctx,
+		bson.M{"_id": bson.M{"$gt": filter.Since}},
+		options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(int64(filter.Limit)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not list user changes: %w", err)
+	}
+
+	var docs []document
+	if err := cur.All(ctx, &docs); err != nil {
+		return nil, fmt.Errorf("could not list user changes: %w", err)
+	}
+
+	changes := make([]*Change, 0, len(docs))
+	for _, d := range docs {
+		changes = append(changes, &Change{
+			ID:        d.ID,
+			Type:      Type(d.Type),
+			UserID:    d.UserID,
+			Snapshot:  d.Snapshot,
+			CreatedAt: d.CreatedAt,
+		})
+	}
+	return changes, nil
+}
+
+// EraseSnapshots erases the snapshots of the changes of the user recorded so far.
+func (m *Mongo) EraseSnapshots(ctx context.Context, userID string) error {
+	if _, err := m.db.Collection(mongoUserChanges).UpdateMany(
+		ctx,
+		bson.M{"user_id": userID},
+		bson.M{"$unset": bson.M{"snapshot": ""}},
+	); err != nil {
+		return fmt.Errorf("could not erase user change snapshots: %w", err)
+	}
+	return nil
+}
+
+// EnsureIndexes creates the index erasing the snapshots of a user.
+func (m *Mongo) EnsureIndexes(ctx context.Context) error {
+	if _, err := m.db.Collection(mongoUserChanges).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "user_id", Value: 1}},
+	}); err != nil {
+		return fmt.Errorf("could not create user changes indexes: %w", err)
+	}
+	return nil
+}
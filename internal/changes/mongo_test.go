@@ -0,0 +1,89 @@
+//go:build integration
+// +build integration
+
+package changes
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const defaultMongoURI string = "mongodb://localhost:27017/?replicaSet=rs0&directConnection=true"
+
+func TestMongo(t *testing.T) {
+	// Arrange
+	log := NewMongo(setupMongoHelper(t))
+	require.NoError(t, log.EnsureIndexes(context.TODO()))
+
+	userID := uuid.New().String()
+	createdAt := time.Now().UTC().Truncate(time.Millisecond)
+
+	require.NoError(t, log.Record(context.TODO(), &Change{
+		Type:      TypeCreated,
+		UserID:    userID,
+		Snapshot:  []byte(`{"id":"` + userID + `"}`),
+		CreatedAt: createdAt,
+	}))
+	require.NoError(t, log.Record(context.TODO(), &Change{
+		Type:      TypeDeleted,
+		UserID:    userID,
+		CreatedAt: createdAt,
+	}))
+
+	// Act
+	firstPage, err := log.List(context.TODO(), Filter{Limit: 1})
+	require.NoError(t, err)
+
+	secondPage, err := log.List(context.TODO(), Filter{Since: firstPage[0].ID, Limit: 1})
+	require.NoError(t, err)
+
+	// Assert
+	require.Len(t, firstPage, 1)
+	require.Len(t, secondPage, 1)
+	assert.Greater(t, secondPage[0].ID, firstPage[0].ID)
+
+	created := firstPage[0]
+	assert.Equal(t, TypeCreated, created.Type)
+	assert.Equal(t, userID, created.UserID)
+	assert.JSONEq(t, `{"id":"`+userID+`"}`, string(created.Snapshot))
+	assert.True(t, createdAt.Equal(created.CreatedAt))
+
+	assert.Equal(t, TypeDeleted, secondPage[0].Type)
+	assert.Empty(t, secondPage[0].Snapshot)
+
+	require.NoError(t, log.EraseSnapshots(context.TODO(), userID))
+
+	erased, err := log.List(context.TODO(), Filter{Since: created.ID - 1, Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, erased, 1)
+	assert.Empty(t, erased[0].Snapshot)
+}
+
+// setupMongoHelper returns a new database, dropped when the test ends.
+func setupMongoHelper(t *testing.T) *mongo.Database {
+	t.Helper()
+
+	uri := os.Getenv("MONGO_URI")
+	if uri == "" {
+		uri = defaultMongoURI
+	}
+
+	client, err := mongo.Connect(context.TODO(), options.Client().ApplyURI(uri))
+	require.NoError(t, err)
+
+	db := client.Database("usrsvc_test_" + strings.ReplaceAll(uuid.New().String(), "-", ""))
+	t.Cleanup(func() {
+		db.Drop(context.TODO())
+		client.Disconnect(context.TODO())
+	})
+	return db
+}
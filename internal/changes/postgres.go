@@ -0,0 +1,84 @@
+package changes
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// row is the user_changes table row.
+type row struct {
+	ID        int64     `db:"id"`
+	Type      string    `db:"type"`
+	UserID    string    `db:"user_id"`
+	Snapshot  []byte    `db:"snapshot"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// Postgres stores the change log in the user_changes table. The queries are portable,
+// so it also stores the change log on the SQLite schema.
+type Postgres struct {
+	db *sqlx.DB
+}
+
+// NewPostgres creates a new Postgres change log.
+func NewPostgres(db *sqlx.DB) *Postgres {
+	return &Postgres{db: db}
+}
+
+// Record appends the change to the change log and sets its id.
+func (p *Postgres) Record(ctx context.Context, change *Change) error {
+	if err := p.db.QueryRowxContext(
+		ctx,
+		`INSERT INTO user_changes (type, user_id, snapshot, created_at)
+		VALUES ($1, $2, $3, $4) RETURNING id`,
+		change.Type,
+		change.UserID,
+		change.Snapshot,
+		change.CreatedAt,
+	).Scan(&change.ID); err != nil {
+		return fmt.Errorf("could not insert user change: %w", err)
+	}
+	return nil
+}
+
+// List returns the changes after the Since of the filter, oldest first.
+func (p *Postgres) List(ctx context.Context, filter Filter) ([]*Change, error) {
+	var rows []row
+	if err := p.db.SelectContext(
+		ctx,
+		&rows,
+		`SELECT id, type, user_id, snapshot, created_at FROM user_changes
+		WHERE id > $1 ORDER BY id LIMIT $2`,
+		filter.Since,
+		filter.Limit,
+	); err != nil {
+		return nil, fmt.Errorf("could not list user changes: %w", err)
+	}
+
+	changes := make([]*Change, 0, len(rows))
+	for _, r := range rows {
+		changes = append(changes, &Change{
+			ID:        r.ID,
+			Type:      Type(r.Type),
+			UserID:    r.UserID,
+			Snapshot:  r.Snapshot,
+			CreatedAt: r.CreatedAt,
+		})
+	}
+	return changes, nil
+}
+
+// EraseSnapshots erases the snapshots of the changes of the user recorded so far.
+func (p *Postgres) EraseSnapshots(ctx context.Context, userID string) error {
+	if _, err := p.db.ExecContext(
+		ctx,
+		"UPDATE user_changes SET snapshot = NULL WHERE user_id = $1 AND snapshot IS NOT NULL",
+		userID,
+	); err != nil {
+		return fmt.Errorf("could not erase user change snapshots: %w", err)
+	}
+	return nil
+}
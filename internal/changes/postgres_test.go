@@ -0,0 +1,101 @@
+//go:build integration
+// +build integration
+
+package changes
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+	"github.com/pressly/goose/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	migrationsDir      string = "../../migrations"
+	postgresDriverName string = "postgres"
+	dbHost             string = "localhost"
+	dbPort             string = "5432"
+	dbUser             string = "user"
+	dbPass             string = "password"
+	dbName             string = "usrsvc"
+)
+
+func TestPostgres(t *testing.T) {
+	db := setupDBHelper(t)
+	defer teardownDBHelper(t, db)
+
+	// Arrange
+	log := NewPostgres(db)
+
+	userID := uuid.New().String()
+	createdAt := time.Now().UTC().Truncate(time.Millisecond)
+
+	require.NoError(t, log.Record(context.TODO(), &Change{
+		Type:      TypeCreated,
+		UserID:    userID,
+		Snapshot:  []byte(`{"id": "` + userID + `", "country": "BR"}`),
+		CreatedAt: createdAt,
+	}))
+	require.NoError(t, log.Record(context.TODO(), &Change{
+		Type:      TypeDeleted,
+		UserID:    userID,
+		CreatedAt: createdAt,
+	}))
+
+	// Act
+	firstPage, err := log.List(context.TODO(), Filter{Limit: 1})
+	require.NoError(t, err)
+
+	secondPage, err := log.List(context.TODO(), Filter{Since: firstPage[0].ID, Limit: 1})
+	require.NoError(t, err)
+
+	// Assert
+	require.Len(t, firstPage, 1)
+	require.Len(t, secondPage, 1)
+	assert.Greater(t, secondPage[0].ID, firstPage[0].ID)
+
+	created := firstPage[0]
+	assert.Equal(t, TypeCreated, created.Type)
+	assert.Equal(t, userID, created.UserID)
+	assert.JSONEq(t, `{"id": "`+userID+`", "country": "BR"}`, string(created.Snapshot))
+	assert.True(t, createdAt.Equal(created.CreatedAt))
+
+	assert.Equal(t, TypeDeleted, secondPage[0].Type)
+	assert.Empty(t, secondPage[0].Snapshot)
+
+	require.NoError(t, log.EraseSnapshots(context.TODO(), userID))
+
+	erased, err := log.List(context.TODO(), Filter{Since: created.ID - 1, Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, erased, 1)
+	assert.Empty(t, erased[0].Snapshot)
+}
+
+func setupDBHelper(t *testing.T) *sqlx.DB {
+	t.Helper()
+
+	db, err := sqlx.Open(postgresDriverName, fmt.Sprintf(
+		"host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+		dbHost, dbPort, dbUser, dbPass, dbName),
+	)
+	require.NoError(t, err)
+
+	require.NoError(t, goose.Up(db.DB, migrationsDir))
+	return db
+}
+
+func teardownDBHelper(t *testing.T, db *sqlx.DB) {
+	t.Helper()
+
+	_, err := db.Exec("TRUNCATE TABLE user_changes")
+	require.NoError(t, err)
+
+	require.NoError(t, db.Close())
+}
@@ -0,0 +1,83 @@
+// Package diagnostics serves the runtime diagnostics of the instance: the pprof profiles,
+// the expvar variables and the build info, so the instances can be profiled in place.
+package diagnostics
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	"runtime/debug"
+)
+
+// BuildInfo is the build info of the binary, served under /debug/buildinfo.
+type BuildInfo struct {
+	GoVersion string `json:"go_version"`
+	Path      string `json:"path"`
+	Version   string `json:"version"`
+
+	// Settings are the build settings, e.g. the vcs.revision and vcs.time of the commit
+	// the binary was built from.
+	Settings map[string]string `json:"settings,omitempty"`
+
+	// Deps are the versions of the modules the binary was built with, keyed by path.
+	Deps map[string]string `json:"deps,omitempty"`
+}
+
+// NewHandler returns the handler serving the pprof profiles under /debug/pprof/, the
+// expvar variables under /debug/vars and the build info under /debug/buildinfo. It has
+// no authentication: serve it on a port that is not exposed, e.g. on localhost.
+func NewHandler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	mux.Handle("/debug/vars", expvar.Handler())
+	mux.HandleFunc("/debug/buildinfo", serveBuildInfo)
+	return mux
+}
+
+func serveBuildInfo(w http.ResponseWriter, r *http.Request) {
+	info, ok := ReadBuildInfo()
+	if !ok {
+		http.Error(w, "build info not available", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(info)
+}
+
+// ReadBuildInfo returns the build info embedded in the binary, and false when it was
+// built without module support.
+func ReadBuildInfo() (*BuildInfo, bool) {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return nil, false
+	}
+
+	buildInfo := BuildInfo{
+		GoVersion: info.GoVersion,
+		Path:      info.Path,
+		Version:   info.Main.Version,
+		Settings:  make(map[string]string, len(info.Settings)),
+		Deps:      make(map[string]string, len(info.Deps)),
+	}
+
+	for _, setting := range info.Settings {
+		buildInfo.Settings[setting.Key] = setting.Value
+	}
+
+	for _, dep := range info.Deps {
+		version := dep.Version
+		if dep.Replace != nil {
+			version = dep.Replace.Path + "@" + dep.Replace.Version
+		}
+		buildInfo.Deps[dep.Path] = version
+	}
+	return &buildInfo, true
+}
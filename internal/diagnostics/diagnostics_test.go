@@ -0,0 +1,57 @@
+package diagnostics
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler(t *testing.T) {
+	t.Parallel()
+
+	h := NewHandler()
+
+	testCases := []struct {
+		name                string
+		path                string
+		expectedContentType string
+	}{
+		{name: "pprof index", path: "/debug/pprof/", expectedContentType: "text/html"},
+		{name: "heap profile", path: "/debug/pprof/heap?debug=1", expectedContentType: "text/plain"},
+		{name: "goroutines", path: "/debug/pprof/goroutine?debug=1", expectedContentType: "text/plain"},
+		{name: "expvar", path: "/debug/vars", expectedContentType: "application/json"},
+		{name: "build info", path: "/debug/buildinfo", expectedContentType: "application/json"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+
+			require.Equal(t, http.StatusOK, rec.Code)
+			assert.Contains(t, rec.Header().Get("Content-Type"), tc.expectedContentType)
+		})
+	}
+}
+
+func TestBuildInfo(t *testing.T) {
+	t.Parallel()
+
+	rec := httptest.NewRecorder()
+	NewHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/buildinfo", nil))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var info BuildInfo
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &info))
+	assert.Equal(t, runtime.Version(), info.GoVersion)
+	assert.Contains(t, info.Deps, "github.com/stretchr/testify")
+}
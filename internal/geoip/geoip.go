@@ -0,0 +1,73 @@
+// Package geoip resolves the location of IP addresses with MaxMind GeoIP2 or GeoLite2 databases.
+package geoip
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/alesr/usrsvc/internal/users/service"
+	"github.com/oschwald/geoip2-golang"
+)
+
+var _ service.GeoResolver = (*Resolver)(nil)
+
+// Resolver looks up the country and the autonomous system of IP addresses
+// in local database files, so no request leaves the service.
+type Resolver struct {
+	country *geoip2.Reader
+	asn     *geoip2.Reader
+}
+
+// Open opens the country database and, if asnPath is not empty, the ASN database.
+// The databases are memory-mapped, so Close must be called when the resolver is no longer used.
+func Open(countryPath, asnPath string) (*Resolver, error) {
+	country, err := geoip2.Open(countryPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not open country database: %w", err)
+	}
+
+	r := Resolver{country: country}
+
+	if asnPath != "" {
+		asn, err := geoip2.Open(asnPath)
+		if err != nil {
+			country.Close()
+			return nil, fmt.Errorf("could not open asn database: %w", err)
+		}
+		r.asn = asn
+	}
+	return &r, nil
+}
+
+// Resolve returns the location of the IP address. Fields are left empty
+// when the address is not in the databases (e.g. private addresses).
+func (r *Resolver) Resolve(ctx context.Context, ip net.IP) (*service.GeoLocation, error) {
+	country, err := r.country.Country(ip)
+	if err != nil {
+		return nil, fmt.Errorf("could not look up country of '%s': %w", ip, err)
+	}
+
+	location := service.GeoLocation{Country: country.Country.IsoCode}
+
+	if r.asn != nil {
+		asn, err := r.asn.ASN(ip)
+		if err != nil {
+			return nil, fmt.Errorf("could not look up asn of '%s': %w", ip, err)
+		}
+
+		location.ASN = int64(asn.AutonomousSystemNumber)
+		location.Organization = asn.AutonomousSystemOrganization
+	}
+	return &location, nil
+}
+
+// Close closes the databases.
+func (r *Resolver) Close() error {
+	err := r.country.Close()
+	if r.asn != nil {
+		err = errors.Join(err, r.asn.Close())
+	}
+	return err
+}
@@ -0,0 +1,156 @@
+package hashing
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrMismatch is returned when the password doesn't match the hash.
+var ErrMismatch error = errors.New("password does not match the hash")
+
+// ErrUnknownHash is returned when no algorithm produced the hash.
+var ErrUnknownHash error = errors.New("unknown hash format")
+
+var (
+	_ Algorithm = Bcrypt{}
+	_ Algorithm = Argon2id{}
+)
+
+// Algorithm hashes passwords with its parameters and verifies its own hashes,
+// whatever the parameters they were produced with.
+type Algorithm interface {
+	Hash(password []byte) ([]byte, error)
+
+	// Compare returns nil if the password matches the hash and ErrMismatch otherwise.
+	Compare(hash, password []byte) error
+
+	// Owns reports whether the hash was produced by the algorithm.
+	Owns(hash []byte) bool
+
+	// Current reports whether the hash was produced by the algorithm with its parameters.
+	Current(hash []byte) bool
+}
+
+// Bcrypt hashes the passwords with bcrypt.
+type Bcrypt struct {
+	Cost int
+}
+
+func (b Bcrypt) Hash(password []byte) ([]byte, error) {
+	return bcrypt.GenerateFromPassword(password, b.Cost)
+}
+
+func (b Bcrypt) Compare(hash, password []byte) error {
+	err := bcrypt.CompareHashAndPassword(hash, password)
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return fmt.Errorf("%w: %w", ErrMismatch, err)
+	}
+	return err
+}
+
+func (b Bcrypt) Owns(hash []byte) bool {
+	for _, prefix := range []string{"$2a$", "$2b$", "$2y$"} {
+		if bytes.HasPrefix(hash, []byte(prefix)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (b Bcrypt) Current(hash []byte) bool {
+	cost, err := bcrypt.Cost(hash)
+	return err == nil && cost == b.Cost
+}
+
+const argon2idPrefix string = "$argon2id$"
+
+// Argon2id hashes the passwords with Argon2id, in the PHC string format
+// "$argon2id$v=19$m=<memory>,t=<time>,p=<threads>$<salt>$<key>".
+type Argon2id struct {
+	Time    uint32
+	Memory  uint32 // In KiB.
+	Threads uint8
+}
+
+// Lengths of the salt and of the derived key, in bytes, as recommended by RFC 9106.
+const (
+	argon2idSaltLength uint32 = 16
+	argon2idKeyLength  uint32 = 32
+)
+
+func (a Argon2id) Hash(password []byte) ([]byte, error) {
+	salt := make([]byte, argon2idSaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("could not generate salt: %w", err)
+	}
+
+	key := argon2.IDKey(password, salt, a.Time, a.Memory, a.Threads, argon2idKeyLength)
+	return []byte(fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2idPrefix,
+		argon2.Version,
+		a.Memory, a.Time, a.Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	)), nil
+}
+
+func (a Argon2id) Compare(hash, password []byte) error {
+	params, salt, key, err := parseArgon2id(hash)
+	if err != nil {
+		return err
+	}
+
+	derived := argon2.IDKey(password, salt, params.Time, params.Memory, params.Threads, uint32(len(key)))
+	if subtle.ConstantTimeCompare(derived, key) != 1 {
+		return ErrMismatch
+	}
+	return nil
+}
+
+func (a Argon2id) Owns(hash []byte) bool {
+	return bytes.HasPrefix(hash, []byte(argon2idPrefix))
+}
+
+func (a Argon2id) Current(hash []byte) bool {
+	params, _, key, err := parseArgon2id(hash)
+	return err == nil && params == a && uint32(len(key)) == argon2idKeyLength
+}
+
+// parseArgon2id returns the parameters, the salt and the key of an Argon2id hash.
+func parseArgon2id(hash []byte) (Argon2id, []byte, []byte, error) {
+	var params Argon2id
+
+	// "", "argon2id", "v=19", "m=65536,t=1,p=4", salt, key
+	parts := strings.Split(string(hash), "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return params, nil, nil, fmt.Errorf("could not parse argon2id hash: %w", ErrUnknownHash)
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("could not parse argon2id version '%s': %w", parts[2], ErrUnknownHash)
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Threads); err != nil || params.Time == 0 || params.Threads == 0 {
+		return params, nil, nil, fmt.Errorf("could not parse argon2id parameters '%s': %w", parts[3], ErrUnknownHash)
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("could not decode argon2id salt: %w", ErrUnknownHash)
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, fmt.Errorf("could not decode argon2id key: %w", ErrUnknownHash)
+	}
+	return params, salt, key, nil
+}
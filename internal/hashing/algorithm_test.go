@@ -0,0 +1,77 @@
+package hashing
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testArgon2id keeps the tests fast, it is far too weak for real passwords.
+var testArgon2id = Argon2id{Time: 1, Memory: 64, Threads: 1}
+
+func TestAlgorithms(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name      string
+		algorithm Algorithm
+		other     Algorithm
+	}{
+		{
+			name:      "bcrypt",
+			algorithm: Bcrypt{Cost: bcrypt.MinCost},
+			other:     Bcrypt{Cost: bcrypt.MinCost + 1},
+		},
+		{
+			name:      "argon2id",
+			algorithm: testArgon2id,
+			other:     Argon2id{Time: 2, Memory: 64, Threads: 1},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Act
+			hash, err := tc.algorithm.Hash([]byte("some-passw0rd"))
+			require.NoError(t, err)
+
+			// Assert
+			assert.NoError(t, tc.algorithm.Compare(hash, []byte("some-passw0rd")))
+			assert.True(t, errors.Is(tc.algorithm.Compare(hash, []byte("wrong")), ErrMismatch))
+
+			assert.True(t, tc.algorithm.Owns(hash))
+			assert.True(t, tc.algorithm.Current(hash))
+
+			// The parameters of the hash are used to compare, whatever the current ones.
+			assert.NoError(t, tc.other.Compare(hash, []byte("some-passw0rd")))
+			assert.False(t, tc.other.Current(hash))
+		})
+	}
+}
+
+func TestArgon2idHashFormat(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	hash, err := testArgon2id.Hash([]byte("some-passw0rd"))
+	require.NoError(t, err)
+
+	// Assert
+	assert.Regexp(t, `^\$argon2id\$v=19\$m=64,t=1,p=1\$[A-Za-z0-9+/]{22}\$[A-Za-z0-9+/]{43}$`, string(hash))
+	assert.False(t, Bcrypt{}.Owns(hash))
+
+	for _, invalid := range []string{
+		"$argon2id$v=19$m=64,t=1,p=1$c2FsdA",
+		"$argon2id$v=16$m=64,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=64,t=0,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=64,t=1,p=1$c2FsdA$!",
+	} {
+		assert.True(t, errors.Is(testArgon2id.Compare([]byte(invalid), []byte("some-passw0rd")), ErrUnknownHash), invalid)
+	}
+}
@@ -0,0 +1,210 @@
+// Package hashing runs the password hashing on a bounded pool of workers,
+// so bursts of writes queue up instead of saturating every CPU and
+// inflating the latency of the other requests.
+package hashing
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrSaturated is returned when the queue is full. Callers should retry later.
+var ErrSaturated error = errors.New("hashing pool is saturated")
+
+// ErrClosed is returned when the pool has been closed.
+var ErrClosed error = errors.New("hashing pool is closed")
+
+type job struct {
+	ctx  context.Context
+	run  func() error
+	done chan error
+}
+
+// Pool hashes and compares passwords on a fixed number of workers. New passwords are
+// hashed with the configured algorithm, bcrypt by default, and the hashes of all the
+// supported algorithms can be compared, so the algorithm can be changed over time.
+type Pool struct {
+	algorithm Algorithm
+	dummyHash []byte
+	jobs      chan job
+	queued    int64
+	rejected  uint64
+	closeOnce sync.Once
+	closed    chan struct{}
+	wg        sync.WaitGroup
+}
+
+// Option is a function that configures the pool.
+type Option func(*Pool)
+
+// WithCost hashes the passwords with bcrypt and the given cost.
+func WithCost(cost int) Option {
+	return WithAlgorithm(Bcrypt{Cost: cost})
+}
+
+// WithAlgorithm sets the algorithm used to hash passwords.
+func WithAlgorithm(algorithm Algorithm) Option {
+	return func(p *Pool) {
+		p.algorithm = algorithm
+	}
+}
+
+// NewPool starts a pool with the given number of workers and queue size.
+// A non-positive number of workers defaults to the number of CPUs.
+func NewPool(workers, queueSize int, opts ...Option) *Pool {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	if queueSize < 0 {
+		queueSize = 0
+	}
+
+	p := &Pool{
+		algorithm: Bcrypt{Cost: bcrypt.DefaultCost},
+		jobs:      make(chan job, queueSize),
+		closed:    make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	// Hashed once up front, it costs as much to compare against as the real hashes.
+	p.dummyHash, _ = p.algorithm.Hash([]byte("dummy-passw0rd!"))
+
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+	return p
+}
+
+// Hash returns the hash of the password.
+func (p *Pool) Hash(ctx context.Context, password []byte) ([]byte, error) {
+	var hash []byte
+	err := p.submit(ctx, func() error {
+		var err error
+		hash, err = p.algorithm.Hash(password)
+		return err
+	})
+	return hash, err
+}
+
+// Compare returns nil if the password matches the hash, whatever the algorithm of the hash.
+func (p *Pool) Compare(ctx context.Context, hash, password []byte) error {
+	algorithm, err := p.algorithmOf(hash)
+	if err != nil {
+		return err
+	}
+
+	return p.submit(ctx, func() error {
+		return algorithm.Compare(hash, password)
+	})
+}
+
+// NeedsRehash reports whether the hash was produced with another algorithm or other
+// parameters than the configured ones, so it should be replaced once the password is known.
+func (p *Pool) NeedsRehash(hash []byte) bool {
+	return !p.algorithm.Current(hash)
+}
+
+// DummyHash returns the hash of a dummy password with the configured algorithm, to compare
+// against when the real hash is unknown, e.g. for the logins of unknown users.
+func (p *Pool) DummyHash() []byte {
+	return p.dummyHash
+}
+
+// algorithmOf returns the algorithm that produced the hash.
+func (p *Pool) algorithmOf(hash []byte) (Algorithm, error) {
+	// The configured algorithm comes first, as the other ones of its kind only differ by parameters.
+	for _, algorithm := range []Algorithm{p.algorithm, Bcrypt{}, Argon2id{}} {
+		if algorithm.Owns(hash) {
+			return algorithm, nil
+		}
+	}
+	return nil, ErrUnknownHash
+}
+
+// QueueDepth returns the number of jobs waiting for a worker.
+func (p *Pool) QueueDepth() int {
+	return int(atomic.LoadInt64(&p.queued))
+}
+
+// Rejected returns the number of jobs rejected because the queue was full.
+func (p *Pool) Rejected() uint64 {
+	return atomic.LoadUint64(&p.rejected)
+}
+
+// Close stops the workers once the queued jobs are done.
+func (p *Pool) Close() {
+	p.closeOnce.Do(func() {
+		close(p.closed)
+		p.wg.Wait()
+	})
+}
+
+// submit enqueues the job without blocking and waits for its result.
+// A full queue is reported right away so the caller can shed load.
+func (p *Pool) submit(ctx context.Context, run func() error) error {
+	select {
+	case <-p.closed:
+		return ErrClosed
+	default:
+	}
+
+	j := job{ctx: ctx, run: run, done: make(chan error, 1)}
+
+	atomic.AddInt64(&p.queued, 1)
+	select {
+	case p.jobs <- j:
+	default:
+		atomic.AddInt64(&p.queued, -1)
+		atomic.AddUint64(&p.rejected, 1)
+		return ErrSaturated
+	}
+
+	select {
+	case err := <-j.done:
+		return err
+	case <-ctx.Done():
+		// The worker skips the job if it didn't start yet.
+		return ctx.Err()
+	}
+}
+
+func (p *Pool) work() {
+	defer p.wg.Done()
+
+	for {
+		select {
+		case j := <-p.jobs:
+			p.run(j)
+		case <-p.closed:
+			// Drain what was already accepted before stopping.
+			for {
+				select {
+				case j := <-p.jobs:
+					p.run(j)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (p *Pool) run(j job) {
+	atomic.AddInt64(&p.queued, -1)
+
+	if err := j.ctx.Err(); err != nil {
+		j.done <- err
+		return
+	}
+	j.done <- j.run()
+}
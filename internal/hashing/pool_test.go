@@ -0,0 +1,119 @@
+package hashing
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestPool(t *testing.T) {
+	t.Parallel()
+
+	t.Run("hashes and compares", func(t *testing.T) {
+		// Arrange
+		pool := NewPool(2, 4, WithCost(bcrypt.MinCost))
+		defer pool.Close()
+
+		// Act
+		hash, err := pool.Hash(context.TODO(), []byte("some-passw0rd"))
+		require.NoError(t, err)
+
+		// Assert
+		assert.NoError(t, pool.Compare(context.TODO(), hash, []byte("some-passw0rd")))
+		assert.True(t, errors.Is(pool.Compare(context.TODO(), hash, []byte("wrong")), bcrypt.ErrMismatchedHashAndPassword))
+	})
+
+	t.Run("compares the hashes of the other algorithms", func(t *testing.T) {
+		// Arrange
+		pool := NewPool(1, 1, WithAlgorithm(testArgon2id))
+		defer pool.Close()
+
+		legacy, err := bcrypt.GenerateFromPassword([]byte("some-passw0rd"), bcrypt.MinCost)
+		require.NoError(t, err)
+
+		current, err := pool.Hash(context.TODO(), []byte("some-passw0rd"))
+		require.NoError(t, err)
+
+		// Act
+		compareErr := pool.Compare(context.TODO(), legacy, []byte("some-passw0rd"))
+
+		// Assert
+		assert.NoError(t, compareErr)
+		assert.True(t, pool.NeedsRehash(legacy))
+		assert.False(t, pool.NeedsRehash(current))
+		assert.False(t, pool.NeedsRehash(pool.DummyHash()))
+		assert.True(t, errors.Is(pool.Compare(context.TODO(), []byte("plain"), []byte("plain")), ErrUnknownHash))
+	})
+
+	t.Run("rejects jobs when the queue is full", func(t *testing.T) {
+		// Arrange
+		pool := NewPool(1, 1)
+		defer pool.Close()
+
+		// Block the only worker and fill the queue.
+		release := make(chan struct{})
+		started := make(chan struct{})
+
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_ = pool.submit(context.TODO(), func() error {
+				close(started)
+				<-release
+				return nil
+			})
+		}()
+		<-started
+
+		go func() {
+			defer wg.Done()
+			_ = pool.submit(context.TODO(), func() error { return nil })
+		}()
+		require.Eventually(t, func() bool { return pool.QueueDepth() == 1 }, time.Second, time.Millisecond)
+
+		// Act
+		_, err := pool.Hash(context.TODO(), []byte("some-passw0rd"))
+
+		// Assert
+		assert.True(t, errors.Is(err, ErrSaturated))
+		assert.Equal(t, uint64(1), pool.Rejected())
+
+		close(release)
+		wg.Wait()
+		assert.Equal(t, 0, pool.QueueDepth())
+	})
+
+	t.Run("skips jobs whose context is done", func(t *testing.T) {
+		// Arrange
+		pool := NewPool(1, 1)
+		defer pool.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		// Act
+		_, err := pool.Hash(ctx, []byte("some-passw0rd"))
+
+		// Assert
+		assert.True(t, errors.Is(err, context.Canceled))
+	})
+
+	t.Run("fails after close", func(t *testing.T) {
+		// Arrange
+		pool := NewPool(1, 1)
+		pool.Close()
+
+		// Act
+		_, err := pool.Hash(context.TODO(), []byte("some-passw0rd"))
+
+		// Assert
+		assert.True(t, errors.Is(err, ErrClosed))
+	})
+}
@@ -0,0 +1,91 @@
+// Package httpsec provides the CORS and security headers middleware of the HTTP listeners,
+// so they can be exposed to browsers safely.
+package httpsec
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	allowedMethods string = "GET, POST, PUT, DELETE, OPTIONS"
+
+	// allowedHeaders include the request headers of the Connect and gRPC-Web protocols,
+	// exposedHeaders the response headers their browser clients read.
+	allowedHeaders string = "Authorization, Content-Type, X-Api-Key, Connect-Protocol-Version, Connect-Timeout-Ms, Grpc-Timeout, X-Grpc-Web, X-User-Agent"
+	exposedHeaders string = "Grpc-Status, Grpc-Message, Grpc-Status-Details-Bin, Retry-After, Usrsvc-Validation-Warning"
+
+	// preflightMaxAge is how long browsers may cache the preflight responses.
+	preflightMaxAge time.Duration = 10 * time.Minute
+)
+
+// Config configures the middleware.
+type Config struct {
+	// AllowedOrigins are the origins allowed to call the listener from a browser,
+	// e.g. "https://console.example.com". "*" allows any origin. Leave empty to
+	// disable CORS, in which case browsers only allow same-origin calls.
+	AllowedOrigins []string
+
+	// HSTSMaxAge enables HTTP Strict Transport Security when positive. Only enable
+	// it when the listener is served over TLS, e.g. behind a TLS-terminating proxy.
+	HSTSMaxAge time.Duration
+}
+
+// ParseOrigins parses a comma separated list of origins.
+func ParseOrigins(origins string) []string {
+	var parsed []string
+	for _, origin := range strings.Split(origins, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			parsed = append(parsed, strings.TrimSuffix(origin, "/"))
+		}
+	}
+	return parsed
+}
+
+// Middleware sets the security headers on every response and answers the CORS
+// preflight requests of the allowed origins.
+func Middleware(cfg Config, next http.Handler) http.Handler {
+	allowed := make(map[string]bool, len(cfg.AllowedOrigins))
+	for _, origin := range cfg.AllowedOrigins {
+		allowed[origin] = true
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		h.Set("X-Content-Type-Options", "nosniff")
+		h.Set("X-Frame-Options", "DENY")
+		h.Set("Referrer-Policy", "no-referrer")
+		h.Set("Content-Security-Policy", "default-src 'self'; frame-ancestors 'none'")
+
+		if cfg.HSTSMaxAge > 0 {
+			h.Set("Strict-Transport-Security", "max-age="+strconv.Itoa(int(cfg.HSTSMaxAge.Seconds()))+"; includeSubDomains")
+		}
+
+		origin := r.Header.Get("Origin")
+		if origin == "" || !(allowed[origin] || allowed["*"]) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// The response depends on the origin, so it must not be cached for other origins.
+		h.Add("Vary", "Origin")
+		h.Set("Access-Control-Allow-Origin", origin)
+
+		// Credentials (cookies and basic auth) are only sent to the origins listed explicitly.
+		if allowed[origin] {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+		h.Set("Access-Control-Expose-Headers", exposedHeaders)
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			h.Set("Access-Control-Allow-Methods", allowedMethods)
+			h.Set("Access-Control-Allow-Headers", allowedHeaders)
+			h.Set("Access-Control-Max-Age", strconv.Itoa(int(preflightMaxAge.Seconds())))
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
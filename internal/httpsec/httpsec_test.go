@@ -0,0 +1,118 @@
+package httpsec
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware(t *testing.T) {
+	t.Parallel()
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	cfg := Config{
+		AllowedOrigins: []string{"https://console.foo.bar"},
+		HSTSMaxAge:     365 * 24 * time.Hour,
+	}
+
+	t.Run("security headers", func(t *testing.T) {
+		// Arrange
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		rec := httptest.NewRecorder()
+
+		// Act
+		Middleware(cfg, next).ServeHTTP(rec, req)
+
+		// Assert
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "nosniff", rec.Header().Get("X-Content-Type-Options"))
+		assert.Equal(t, "DENY", rec.Header().Get("X-Frame-Options"))
+		assert.Equal(t, "max-age=31536000; includeSubDomains", rec.Header().Get("Strict-Transport-Security"))
+		assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("hsts disabled", func(t *testing.T) {
+		// Arrange
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		rec := httptest.NewRecorder()
+
+		// Act
+		Middleware(Config{}, next).ServeHTTP(rec, req)
+
+		// Assert
+		assert.Empty(t, rec.Header().Get("Strict-Transport-Security"))
+	})
+
+	t.Run("allowed origin", func(t *testing.T) {
+		// Arrange
+		req := httptest.NewRequest(http.MethodGet, "/admin/api/users", nil)
+		req.Header.Set("Origin", "https://console.foo.bar")
+		rec := httptest.NewRecorder()
+
+		// Act
+		Middleware(cfg, next).ServeHTTP(rec, req)
+
+		// Assert
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "https://console.foo.bar", rec.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "true", rec.Header().Get("Access-Control-Allow-Credentials"))
+		assert.Equal(t, "Origin", rec.Header().Get("Vary"))
+		assert.Equal(t, exposedHeaders, rec.Header().Get("Access-Control-Expose-Headers"))
+	})
+
+	t.Run("disallowed origin", func(t *testing.T) {
+		// Arrange
+		req := httptest.NewRequest(http.MethodGet, "/admin/api/users", nil)
+		req.Header.Set("Origin", "https://evil.foo.bar")
+		rec := httptest.NewRecorder()
+
+		// Act
+		Middleware(cfg, next).ServeHTTP(rec, req)
+
+		// Assert
+		assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("preflight", func(t *testing.T) {
+		// Arrange
+		req := httptest.NewRequest(http.MethodOptions, "/admin/api/maintenance", nil)
+		req.Header.Set("Origin", "https://console.foo.bar")
+		req.Header.Set("Access-Control-Request-Method", http.MethodPut)
+		rec := httptest.NewRecorder()
+
+		// Act
+		Middleware(cfg, http.NotFoundHandler()).ServeHTTP(rec, req)
+
+		// Assert
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.Equal(t, allowedMethods, rec.Header().Get("Access-Control-Allow-Methods"))
+		assert.Equal(t, allowedHeaders, rec.Header().Get("Access-Control-Allow-Headers"))
+	})
+
+	t.Run("any origin", func(t *testing.T) {
+		// Arrange
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		req.Header.Set("Origin", "https://grafana.foo.bar")
+		rec := httptest.NewRecorder()
+
+		// Act
+		Middleware(Config{AllowedOrigins: []string{"*"}}, next).ServeHTTP(rec, req)
+
+		// Assert
+		assert.Equal(t, "https://grafana.foo.bar", rec.Header().Get("Access-Control-Allow-Origin"))
+		assert.Empty(t, rec.Header().Get("Access-Control-Allow-Credentials"))
+	})
+}
+
+func TestParseOrigins(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, []string{"https://a.foo.bar", "https://b.foo.bar"}, ParseOrigins(" https://a.foo.bar/, https://b.foo.bar,,"))
+	assert.Nil(t, ParseOrigins(""))
+}
@@ -0,0 +1,197 @@
+// Package importer maps the users exported by legacy systems to the fields of the service.
+// Every source is described by a mapping profile written in YAML, so a migration from a
+// new system only needs a new profile:
+//
+//	name: legacy-crm
+//	columns:
+//	  fname: first_name
+//	  lname: last_name
+//	  login: nickname
+//	  mail: email
+//	  cc: country
+//	  signup_date: created_at
+//	date_format: "02/01/2006"
+//	default_country: PT
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Fields of the service that source columns can be mapped to.
+const (
+	FieldFirstName string = "first_name"
+	FieldLastName  string = "last_name"
+	FieldNickname  string = "nickname"
+	FieldEmail     string = "email"
+	FieldPassword  string = "password"
+	FieldCountry   string = "country"
+	FieldCreatedAt string = "created_at"
+)
+
+// Named date formats, besides the Go reference time layouts.
+const (
+	DateFormatRFC3339 string = "rfc3339"
+	DateFormatUnix    string = "unix"
+)
+
+var (
+	ErrInvalidProfile error = errors.New("invalid import profile")
+	ErrInvalidDate    error = errors.New("invalid date")
+)
+
+var fields = map[string]bool{
+	FieldFirstName: true,
+	FieldLastName:  true,
+	FieldNickname:  true,
+	FieldEmail:     true,
+	FieldPassword:  true,
+	FieldCountry:   true,
+	FieldCreatedAt: true,
+}
+
+// Profile maps the records of a source to users.
+type Profile struct {
+	Name string `yaml:"name"`
+
+	// Columns maps the source columns to the fields of the service. Other columns are ignored,
+	// and the columns named after a field are mapped to it unless renamed.
+	Columns map[string]string `yaml:"columns"`
+
+	// DateFormat is the format of the dates: rfc3339 (the default), unix (seconds since
+	// the epoch) or a Go layout, e.g. "2006-01-02 15:04:05".
+	DateFormat string `yaml:"date_format"`
+
+	// DefaultCountry is the country of the records without one.
+	DefaultCountry string `yaml:"default_country"`
+}
+
+// Record is a source record mapped to the fields of the service.
+type Record struct {
+	FirstName string
+	LastName  string
+	Nickname  string
+	Email     string
+	Password  string
+	Country   string
+
+	// CreatedAt is zero when the source has no creation date.
+	CreatedAt time.Time
+}
+
+// LoadProfile reads and validates a YAML profile.
+func LoadProfile(r io.Reader) (*Profile, error) {
+	var profile Profile
+
+	decoder := yaml.NewDecoder(r)
+	decoder.KnownFields(true)
+
+	if err := decoder.Decode(&profile); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidProfile, err)
+	}
+
+	if err := profile.Validate(); err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+// Validate checks that the columns are mapped to known fields, once each.
+func (p *Profile) Validate() error {
+	if p.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidProfile)
+	}
+
+	mapped := make(map[string]string, len(p.Columns))
+	for column, field := range p.Columns {
+		if !fields[field] {
+			return fmt.Errorf("%w: column '%s' is mapped to unknown field '%s'", ErrInvalidProfile, column, field)
+		}
+
+		if other, ok := mapped[field]; ok {
+			return fmt.Errorf("%w: columns '%s' and '%s' are both mapped to '%s'", ErrInvalidProfile, other, column, field)
+		}
+		mapped[field] = column
+	}
+	return nil
+}
+
+// Map maps a source record, keyed by column, to the fields of the service.
+// Values are trimmed, and the record is not validated.
+func (p *Profile) Map(source map[string]string) (*Record, error) {
+	values := make(map[string]string, len(fields))
+	for column, value := range source {
+		field, ok := p.Columns[column]
+		if !ok {
+			// A column named after a field is ignored when another column is mapped to it.
+			if !fields[column] || p.isMapped(column) {
+				continue
+			}
+			field = column
+		}
+		values[field] = strings.TrimSpace(value)
+	}
+
+	record := Record{
+		FirstName: values[FieldFirstName],
+		LastName:  values[FieldLastName],
+		Nickname:  values[FieldNickname],
+		Email:     values[FieldEmail],
+		Password:  values[FieldPassword],
+		Country:   strings.ToUpper(values[FieldCountry]),
+	}
+
+	if record.Country == "" {
+		record.Country = p.DefaultCountry
+	}
+
+	if value := values[FieldCreatedAt]; value != "" {
+		createdAt, err := p.parseDate(value)
+		if err != nil {
+			return nil, err
+		}
+		record.CreatedAt = createdAt
+	}
+	return &record, nil
+}
+
+// isMapped reports whether a column of the source is mapped to the field.
+func (p *Profile) isMapped(field string) bool {
+	for _, mapped := range p.Columns {
+		if mapped == field {
+			return true
+		}
+	}
+	return false
+}
+
+// parseDate parses a date in the format of the profile. Dates without a time zone are UTC.
+func (p *Profile) parseDate(value string) (time.Time, error) {
+	switch p.DateFormat {
+	case "", DateFormatRFC3339:
+		date, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%w: '%s' is not in the rfc3339 format", ErrInvalidDate, value)
+		}
+		return date.UTC(), nil
+	case DateFormatUnix:
+		seconds, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%w: '%s' is not a unix time", ErrInvalidDate, value)
+		}
+		return time.Unix(seconds, 0).UTC(), nil
+	default:
+		date, err := time.Parse(p.DateFormat, value)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%w: '%s' is not in the format '%s'", ErrInvalidDate, value, p.DateFormat)
+		}
+		return date.UTC(), nil
+	}
+}
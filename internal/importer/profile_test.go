@@ -0,0 +1,160 @@
+package importer
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const legacyCRMProfile string = `
+name: legacy-crm
+columns:
+  fname: first_name
+  lname: last_name
+  mail: email
+  cc: country
+  signup_date: created_at
+date_format: "02/01/2006"
+default_country: PT
+`
+
+func TestLoadProfile(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name        string
+		given       string
+		expectedErr error
+	}{
+		{
+			name:  "valid profile",
+			given: legacyCRMProfile,
+		},
+		{
+			name:        "missing name",
+			given:       "columns:\n  mail: email\n",
+			expectedErr: ErrInvalidProfile,
+		},
+		{
+			name:        "unknown field",
+			given:       "name: crm\ncolumns:\n  mail: e_mail\n",
+			expectedErr: ErrInvalidProfile,
+		},
+		{
+			name:        "field mapped twice",
+			given:       "name: crm\ncolumns:\n  mail: email\n  email2: email\n",
+			expectedErr: ErrInvalidProfile,
+		},
+		{
+			name:        "unknown setting",
+			given:       "name: crm\ndefault_locale: pt\n",
+			expectedErr: ErrInvalidProfile,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Act
+			profile, err := LoadProfile(strings.NewReader(tc.given))
+
+			// Assert
+			if tc.expectedErr != nil {
+				assert.True(t, errors.Is(err, tc.expectedErr), "expected %v, got %v", tc.expectedErr, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, "legacy-crm", profile.Name)
+		})
+	}
+}
+
+func TestProfileMap(t *testing.T) {
+	t.Parallel()
+
+	profile, err := LoadProfile(strings.NewReader(legacyCRMProfile))
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name        string
+		profile     *Profile
+		given       map[string]string
+		expected    *Record
+		expectedErr error
+	}{
+		{
+			name:    "renames the columns and parses the date",
+			profile: profile,
+			given: map[string]string{
+				"fname":       " John ",
+				"lname":       "Doe",
+				"nickname":    "jdoe",
+				"mail":        "joedoe@foo.bar",
+				"cc":          "br",
+				"signup_date": "31/12/2010",
+				"legacy_id":   "42",
+			},
+			expected: &Record{
+				FirstName: "John",
+				LastName:  "Doe",
+				Nickname:  "jdoe",
+				Email:     "joedoe@foo.bar",
+				Country:   "BR",
+				CreatedAt: time.Date(2010, 12, 31, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name:    "defaults the country",
+			profile: profile,
+			given:   map[string]string{"mail": "joedoe@foo.bar", "cc": ""},
+			expected: &Record{
+				Email:   "joedoe@foo.bar",
+				Country: "PT",
+			},
+		},
+		{
+			name:     "ignores the columns named after a renamed field",
+			profile:  profile,
+			given:    map[string]string{"mail": "joedoe@foo.bar", "email": "old@foo.bar"},
+			expected: &Record{Email: "joedoe@foo.bar", Country: "PT"},
+		},
+		{
+			name:     "parses unix dates",
+			profile:  &Profile{Name: "unix", DateFormat: DateFormatUnix},
+			given:    map[string]string{"created_at": "1293753600"},
+			expected: &Record{CreatedAt: time.Date(2010, 12, 31, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			name:        "invalid date",
+			profile:     profile,
+			given:       map[string]string{"signup_date": "2010-12-31"},
+			expectedErr: ErrInvalidDate,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Act
+			observed, err := tc.profile.Map(tc.given)
+
+			// Assert
+			if tc.expectedErr != nil {
+				assert.True(t, errors.Is(err, tc.expectedErr), "expected %v, got %v", tc.expectedErr, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, observed)
+		})
+	}
+}
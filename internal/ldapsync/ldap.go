@@ -0,0 +1,106 @@
+package ldapsync
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+const (
+	dialTimeout time.Duration = 10 * time.Second
+	searchPage  uint32        = 500
+)
+
+// AttributeMap maps our user fields to the directory attributes they are read from.
+type AttributeMap struct {
+	FirstName string
+	LastName  string
+	Nickname  string
+	Email     string
+	Country   string
+}
+
+// DefaultAttributes are the standard inetOrgPerson attributes. For Active Directory,
+// the nickname is usually read from sAMAccountName instead of uid.
+var DefaultAttributes = AttributeMap{
+	FirstName: "givenName",
+	LastName:  "sn",
+	Nickname:  "uid",
+	Email:     "mail",
+	Country:   "c",
+}
+
+// LDAPConfig configures the connection to the directory and the users search.
+type LDAPConfig struct {
+	URL          string
+	BindDN       string
+	BindPassword string
+	BaseDN       string
+	Filter       string
+	Attributes   AttributeMap
+}
+
+// LDAPSource reads the users from an LDAP or Active Directory server.
+type LDAPSource struct {
+	cfg LDAPConfig
+}
+
+// NewLDAPSource creates a new LDAP source. No connection is made until Entries is called.
+func NewLDAPSource(cfg LDAPConfig) *LDAPSource {
+	return &LDAPSource{cfg: cfg}
+}
+
+// Entries binds to the directory and returns all the users matching the filter.
+func (s *LDAPSource) Entries(ctx context.Context) ([]Entry, error) {
+	conn, err := ldap.DialURL(s.cfg.URL, ldap.DialWithDialer(&net.Dialer{Timeout: dialTimeout}))
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to ldap server: %w", err)
+	}
+	defer conn.Close()
+
+	// The client doesn't take a context, closing the connection aborts the pending requests.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	if err := conn.Bind(s.cfg.BindDN, s.cfg.BindPassword); err != nil {
+		return nil, fmt.Errorf("could not bind to ldap server: %w", err)
+	}
+
+	attrs := s.cfg.Attributes
+	result, err := conn.SearchWithPaging(ldap.NewSearchRequest(
+		s.cfg.BaseDN,
+		ldap.ScopeWholeSubtree,
+		ldap.NeverDerefAliases,
+		0, 0, false,
+		s.cfg.Filter,
+		[]string{attrs.FirstName, attrs.LastName, attrs.Nickname, attrs.Email, attrs.Country},
+		nil,
+	), searchPage)
+	if err != nil {
+		return nil, fmt.Errorf("could not search ldap users: %w", err)
+	}
+
+	entries := make([]Entry, 0, len(result.Entries))
+	for _, e := range result.Entries {
+		entries = append(entries, Entry{
+			DN:        e.DN,
+			FirstName: strings.TrimSpace(e.GetAttributeValue(attrs.FirstName)),
+			LastName:  strings.TrimSpace(e.GetAttributeValue(attrs.LastName)),
+			Nickname:  strings.TrimSpace(e.GetAttributeValue(attrs.Nickname)),
+			Email:     strings.ToLower(strings.TrimSpace(e.GetAttributeValue(attrs.Email))),
+			Country:   strings.ToUpper(strings.TrimSpace(e.GetAttributeValue(attrs.Country))),
+		})
+	}
+	return entries, nil
+}
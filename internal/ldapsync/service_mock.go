@@ -0,0 +1,28 @@
+package ldapsync
+
+import (
+	"context"
+
+	"github.com/alesr/usrsvc/internal/users/service"
+)
+
+var _ userService = (*serviceMock)(nil)
+
+// serviceMock is a mock implementation of the user service interface.
+type serviceMock struct {
+	FetchByEmailFunc  func(ctx context.Context, email string) (*service.User, error)
+	CreateFunc        func(ctx context.Context, user *service.User) (*service.User, error)
+	UpdateProfileFunc func(ctx context.Context, user *service.User) (*service.User, error)
+}
+
+func (s *serviceMock) FetchByEmail(ctx context.Context, email string) (*service.User, error) {
+	return s.FetchByEmailFunc(ctx, email)
+}
+
+func (s *serviceMock) Create(ctx context.Context, user *service.User) (*service.User, error) {
+	return s.CreateFunc(ctx, user)
+}
+
+func (s *serviceMock) UpdateProfile(ctx context.Context, user *service.User) (*service.User, error) {
+	return s.UpdateProfileFunc(ctx, user)
+}
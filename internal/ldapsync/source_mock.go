@@ -0,0 +1,14 @@
+package ldapsync
+
+import "context"
+
+var _ Source = (*sourceMock)(nil)
+
+// sourceMock is a mock implementation of the source interface.
+type sourceMock struct {
+	EntriesFunc func(ctx context.Context) ([]Entry, error)
+}
+
+func (s *sourceMock) Entries(ctx context.Context) ([]Entry, error) {
+	return s.EntriesFunc(ctx)
+}
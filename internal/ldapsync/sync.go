@@ -0,0 +1,284 @@
+// Package ldapsync imports and keeps in sync the users of an LDAP or Active Directory source.
+package ldapsync
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alesr/usrsvc/internal/audit"
+	"github.com/alesr/usrsvc/internal/users/service"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultInterval time.Duration = time.Hour
+	countryLength   int           = 2
+
+	// auditActor is the actor of the changes made by the sync in the audit log.
+	auditActor string = "ldap-sync"
+)
+
+// Entry is a user read from the directory, mapped to our model.
+type Entry struct {
+	DN        string
+	FirstName string
+	LastName  string
+	Nickname  string
+	Email     string
+	Country   string
+}
+
+// Source is the directory the users are synced from.
+type Source interface {
+	Entries(ctx context.Context) ([]Entry, error)
+}
+
+// userService is the subset of the service used to upsert the users.
+type userService interface {
+	FetchByEmail(ctx context.Context, email string) (*service.User, error)
+	Create(ctx context.Context, user *service.User) (*service.User, error)
+	UpdateProfile(ctx context.Context, user *service.User) (*service.User, error)
+}
+
+// Conflict is a directory entry that could not be synced.
+type Conflict struct {
+	DN     string
+	Email  string
+	Reason string
+}
+
+// Report summarizes a sync. In dry-run mode, it reports what the sync would have done.
+type Report struct {
+	DryRun    bool
+	Created   int
+	Updated   int
+	Unchanged int
+	Conflicts []Conflict
+}
+
+// Syncer upserts the directory users through the service, so the usual events are published.
+// Users are matched by email. Existing users get their names, nickname and country updated,
+// new users are created with a random password: they can't log in with a password until they reset it.
+type Syncer struct {
+	logger   *zap.Logger
+	source   Source
+	svc      userService
+	interval time.Duration
+	dryRun   bool
+}
+
+// Option is a function that configures the syncer.
+type Option func(*Syncer)
+
+// WithInterval sets how often the directory is synced.
+func WithInterval(d time.Duration) Option {
+	return func(s *Syncer) {
+		s.interval = d
+	}
+}
+
+// WithDryRun makes the syncer only report the changes it would make.
+func WithDryRun(dryRun bool) Option {
+	return func(s *Syncer) {
+		s.dryRun = dryRun
+	}
+}
+
+// NewSyncer creates a new syncer. Call Run to start syncing.
+func NewSyncer(logger *zap.Logger, source Source, svc userService, opts ...Option) *Syncer {
+	s := &Syncer{
+		logger:   logger,
+		source:   source,
+		svc:      svc,
+		interval: defaultInterval,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Run syncs the directory right away and then periodically, until the context is done.
+func (s *Syncer) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		report, err := s.Sync(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			s.logger.Error("ldap sync failed", zap.Error(err))
+		} else {
+			s.logReport(report)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sync upserts every directory entry once. Entries that can't be synced (missing attributes,
+// nickname taken by another user, etc.) are reported as conflicts and don't stop the sync.
+func (s *Syncer) Sync(ctx context.Context) (*Report, error) {
+	ctx = audit.ContextWithActor(ctx, auditActor)
+
+	entries, err := s.source.Entries(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not read directory entries: %w", err)
+	}
+
+	report := Report{DryRun: s.dryRun}
+	seen := make(map[string]string, len(entries))
+
+	for _, entry := range entries {
+		if reason := validateEntry(entry); reason != "" {
+			report.conflict(entry, reason)
+			continue
+		}
+
+		if dn, ok := seen[entry.Email]; ok {
+			report.conflict(entry, fmt.Sprintf("email also used by '%s'", dn))
+			continue
+		}
+		seen[entry.Email] = entry.DN
+
+		if err := s.upsert(ctx, entry, &report); err != nil {
+			return nil, fmt.Errorf("could not sync '%s': %w", entry.DN, err)
+		}
+	}
+	return &report, nil
+}
+
+// upsert creates or updates the user of the entry. Only unexpected errors are returned.
+func (s *Syncer) upsert(ctx context.Context, entry Entry, report *Report) error {
+	existing, err := s.svc.FetchByEmail(ctx, entry.Email)
+	if err != nil && !errors.Is(err, service.ErrUserNotFound) {
+		return report.conflictOrError(entry, err)
+	}
+
+	if existing == nil {
+		if !s.dryRun {
+			password, err := randomPassword()
+			if err != nil {
+				return err
+			}
+
+			if _, err := s.svc.Create(ctx, &service.User{
+				FirstName: entry.FirstName,
+				LastName:  entry.LastName,
+				Nickname:  entry.Nickname,
+				Email:     entry.Email,
+				Password:  password,
+				Country:   entry.Country,
+			}); err != nil {
+				return report.conflictOrError(entry, err)
+			}
+		}
+		report.Created++
+		return nil
+	}
+
+	if existing.FirstName == entry.FirstName && existing.LastName == entry.LastName &&
+		existing.Nickname == entry.Nickname && existing.Country == entry.Country {
+		report.Unchanged++
+		return nil
+	}
+
+	if !s.dryRun {
+		if _, err := s.svc.UpdateProfile(ctx, &service.User{
+			ID:        existing.ID,
+			FirstName: entry.FirstName,
+			LastName:  entry.LastName,
+			Nickname:  entry.Nickname,
+			Country:   entry.Country,
+		}); err != nil {
+			return report.conflictOrError(entry, err)
+		}
+	}
+	report.Updated++
+	return nil
+}
+
+func (s *Syncer) logReport(report *Report) {
+	for _, c := range report.Conflicts {
+		s.logger.Warn("ldap sync conflict", zap.String("dn", c.DN), zap.String("email", c.Email), zap.String("reason", c.Reason))
+	}
+
+	s.logger.Info("ldap sync done",
+		zap.Bool("dry_run", report.DryRun),
+		zap.Int("created", report.Created),
+		zap.Int("updated", report.Updated),
+		zap.Int("unchanged", report.Unchanged),
+		zap.Int("conflicts", len(report.Conflicts)),
+	)
+}
+
+func (r *Report) conflict(entry Entry, reason string) {
+	r.Conflicts = append(r.Conflicts, Conflict{DN: entry.DN, Email: entry.Email, Reason: reason})
+}
+
+// conflictOrError reports the errors caused by the entry data as conflicts and returns the others.
+func (r *Report) conflictOrError(entry Entry, err error) error {
+	switch {
+	case errors.Is(err, service.ErrNicknameTaken):
+		r.conflict(entry, "nickname already taken")
+	case errors.Is(err, service.ErrUserAlreadyExists):
+		r.conflict(entry, "email already taken")
+	case errors.Is(err, service.ErrUserNotFound):
+		r.conflict(entry, "user deleted during the sync")
+	case errors.Is(err, service.ErrEmailAmbiguous):
+		r.conflict(entry, "email used by several users")
+	default:
+		return err
+	}
+	return nil
+}
+
+// validateEntry returns why the entry can't be synced, or an empty string.
+func validateEntry(entry Entry) string {
+	var missing []string
+	for _, attr := range []struct{ name, value string }{
+		{"first name", entry.FirstName},
+		{"last name", entry.LastName},
+		{"nickname", entry.Nickname},
+		{"email", entry.Email},
+		{"country", entry.Country},
+	} {
+		if attr.value == "" {
+			missing = append(missing, attr.name)
+		}
+	}
+
+	if len(missing) > 0 {
+		return "missing " + strings.Join(missing, ", ")
+	}
+
+	if !strings.Contains(entry.Email, "@") {
+		return "invalid email"
+	}
+
+	if len(entry.Country) != countryLength {
+		return fmt.Sprintf("invalid country '%s'", entry.Country)
+	}
+	return ""
+}
+
+// randomPassword returns a password nobody knows, for the users created from the directory.
+func randomPassword() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("could not generate password: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
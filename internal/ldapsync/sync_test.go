@@ -0,0 +1,147 @@
+package ldapsync
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alesr/usrsvc/internal/users/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func newEntryHelper(t *testing.T, nickname, country string) Entry {
+	t.Helper()
+
+	return Entry{
+		DN:        "uid=" + nickname + ",ou=people,dc=foo,dc=bar",
+		FirstName: "John",
+		LastName:  "Doe",
+		Nickname:  nickname,
+		Email:     nickname + "@foo.bar",
+		Country:   country,
+	}
+}
+
+func TestSync(t *testing.T) {
+	t.Parallel()
+
+	newSource := func(t *testing.T) *sourceMock {
+		return &sourceMock{
+			EntriesFunc: func(ctx context.Context) ([]Entry, error) {
+				duplicate := newEntryHelper(t, "joedoe", "BR")
+				duplicate.DN = "uid=joedoe2,ou=people,dc=foo,dc=bar"
+
+				missing := newEntryHelper(t, "nocountry", "")
+				missing.LastName = ""
+
+				return []Entry{
+					newEntryHelper(t, "newuser", "BR"),     // created
+					newEntryHelper(t, "joedoe", "US"),      // updated
+					newEntryHelper(t, "janedoe", "BR"),     // unchanged
+					newEntryHelper(t, "taken", "BR"),       // nickname conflict
+					newEntryHelper(t, "badcountry", "BRA"), // invalid country
+					duplicate,
+					missing,
+				}, nil
+			},
+		}
+	}
+
+	newService := func(created, updated *[]*service.User) *serviceMock {
+		existing := map[string]*service.User{
+			"joedoe@foo.bar":  {ID: "1", FirstName: "John", LastName: "Doe", Nickname: "joedoe", Country: "BR"},
+			"janedoe@foo.bar": {ID: "2", FirstName: "John", LastName: "Doe", Nickname: "janedoe", Country: "BR"},
+		}
+
+		return &serviceMock{
+			FetchByEmailFunc: func(ctx context.Context, email string) (*service.User, error) {
+				if user, ok := existing[email]; ok {
+					return user, nil
+				}
+				return nil, service.ErrUserNotFound
+			},
+			CreateFunc: func(ctx context.Context, user *service.User) (*service.User, error) {
+				if user.Nickname == "taken" {
+					return nil, service.ErrNicknameTaken
+				}
+				*created = append(*created, user)
+				return user, nil
+			},
+			UpdateProfileFunc: func(ctx context.Context, user *service.User) (*service.User, error) {
+				*updated = append(*updated, user)
+				return user, nil
+			},
+		}
+	}
+
+	t.Run("upserts the entries and reports conflicts", func(t *testing.T) {
+		// Arrange
+		var created, updated []*service.User
+		syncer := NewSyncer(zap.NewNop(), newSource(t), newService(&created, &updated))
+
+		// Act
+		report, err := syncer.Sync(context.TODO())
+		require.NoError(t, err)
+
+		// Assert
+		assert.False(t, report.DryRun)
+		assert.Equal(t, 1, report.Created)
+		assert.Equal(t, 1, report.Updated)
+		assert.Equal(t, 1, report.Unchanged)
+
+		require.Len(t, created, 1)
+		assert.Equal(t, "newuser@foo.bar", created[0].Email)
+		assert.NotEmpty(t, created[0].Password)
+
+		require.Len(t, updated, 1)
+		assert.Equal(t, "1", updated[0].ID)
+		assert.Equal(t, "US", updated[0].Country)
+
+		assert.Equal(t, []Conflict{
+			{DN: "uid=taken,ou=people,dc=foo,dc=bar", Email: "taken@foo.bar", Reason: "nickname already taken"},
+			{DN: "uid=badcountry,ou=people,dc=foo,dc=bar", Email: "badcountry@foo.bar", Reason: "invalid country 'BRA'"},
+			{DN: "uid=joedoe2,ou=people,dc=foo,dc=bar", Email: "joedoe@foo.bar", Reason: "email also used by 'uid=joedoe,ou=people,dc=foo,dc=bar'"},
+			{DN: "uid=nocountry,ou=people,dc=foo,dc=bar", Email: "nocountry@foo.bar", Reason: "missing last name, country"},
+		}, report.Conflicts)
+	})
+
+	t.Run("dry run doesn't write", func(t *testing.T) {
+		// Arrange
+		var created, updated []*service.User
+		syncer := NewSyncer(zap.NewNop(), newSource(t), newService(&created, &updated), WithDryRun(true))
+
+		// Act
+		report, err := syncer.Sync(context.TODO())
+		require.NoError(t, err)
+
+		// Assert
+		assert.True(t, report.DryRun)
+		assert.Empty(t, created)
+		assert.Empty(t, updated)
+
+		// The nickname conflict can only be found by writing.
+		assert.Equal(t, 2, report.Created)
+		assert.Equal(t, 1, report.Updated)
+		assert.Len(t, report.Conflicts, 3)
+	})
+
+	t.Run("unexpected errors stop the sync", func(t *testing.T) {
+		// Arrange
+		svc := &serviceMock{
+			FetchByEmailFunc: func(ctx context.Context, email string) (*service.User, error) {
+				return nil, errors.New("connection refused")
+			},
+		}
+
+		syncer := NewSyncer(zap.NewNop(), newSource(t), svc)
+
+		// Act
+		report, err := syncer.Sync(context.TODO())
+
+		// Assert
+		assert.Nil(t, report)
+		assert.Error(t, err)
+	})
+}
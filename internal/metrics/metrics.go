@@ -0,0 +1,172 @@
+// Package metrics exposes the Prometheus collectors for the gRPC server
+// and the repository, plus the HTTP handler that serves them.
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+const namespace string = "usrsvc"
+
+// Metrics holds the collectors registered by the service.
+type Metrics struct {
+	registerer      prometheus.Registerer
+	gatherer        prometheus.Gatherer
+	requestsTotal   *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	queryDuration   *prometheus.HistogramVec
+	warningsTotal   *prometheus.CounterVec
+	panicsTotal     *prometheus.CounterVec
+}
+
+// New creates the collectors and registers them with the given registry.
+func New(reg *prometheus.Registry) *Metrics {
+	m := &Metrics{
+		registerer: reg,
+		gatherer:   reg,
+		requestsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "grpc",
+			Name:      "requests_total",
+			Help:      "Total number of gRPC requests by method and status code.",
+		}, []string{"method", "code"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "grpc",
+			Name:      "request_duration_seconds",
+			Help:      "gRPC request latency by method and status code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "code"}),
+		queryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "repository",
+			Name:      "query_duration_seconds",
+			Help:      "Repository query latency by operation.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"operation"}),
+		warningsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "validation",
+			Name:      "warnings_total",
+			Help:      "Total number of validation warnings returned to the clients by field and code.",
+		}, []string{"field", "code"}),
+		panicsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "grpc",
+			Name:      "panics_total",
+			Help:      "Total number of panics recovered from gRPC requests by method.",
+		}, []string{"method"}),
+	}
+
+	reg.MustRegister(
+		m.requestsTotal,
+		m.requestDuration,
+		m.queryDuration,
+		m.warningsTotal,
+		m.panicsTotal,
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// Handler returns the HTTP handler serving the registered metrics.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.gatherer, promhttp.HandlerOpts{})
+}
+
+// UnaryServerInterceptor records the count and latency of every unary RPC.
+func (m *Metrics) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		m.observeRequest(info.FullMethod, err, time.Since(start))
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor records the count and latency of every streaming RPC.
+func (m *Metrics) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		m.observeRequest(info.FullMethod, err, time.Since(start))
+		return err
+	}
+}
+
+// ObserveQuery records how long a repository operation took.
+func (m *Metrics) ObserveQuery(operation string, d time.Duration) {
+	m.queryDuration.WithLabelValues(operation).Observe(d.Seconds())
+}
+
+// ObserveValidationWarning counts a validation warning returned to a client.
+func (m *Metrics) ObserveValidationWarning(field, code string) {
+	m.warningsTotal.WithLabelValues(field, code).Inc()
+}
+
+// ObservePanic counts a panic recovered from an RPC.
+func (m *Metrics) ObservePanic(method string) {
+	m.panicsTotal.WithLabelValues(method).Inc()
+}
+
+// HashPool is the hashing pool state exposed as metrics.
+type HashPool interface {
+	QueueDepth() int
+	Rejected() uint64
+}
+
+// ObserveHashPool exports the queue depth and the rejections of the hashing pool.
+func (m *Metrics) ObserveHashPool(pool HashPool) {
+	m.registerer.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "hashing",
+			Name:      "queue_depth",
+			Help:      "Number of password hashing jobs waiting for a worker.",
+		}, func() float64 { return float64(pool.QueueDepth()) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "hashing",
+			Name:      "rejected_total",
+			Help:      "Total number of password hashing jobs rejected because the queue was full.",
+		}, func() float64 { return float64(pool.Rejected()) }),
+	)
+}
+
+// EventQueue is the asynchronous event publisher state exposed as metrics.
+type EventQueue interface {
+	Pending() int
+	DeadLettered() uint64
+}
+
+// ObserveEventQueue exports the pending and dead-lettered events of the publisher.
+func (m *Metrics) ObserveEventQueue(queue EventQueue) {
+	m.registerer.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "events",
+			Name:      "pending",
+			Help:      "Number of events waiting to be published.",
+		}, func() float64 { return float64(queue.Pending()) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "events",
+			Name:      "dead_lettered_total",
+			Help:      "Total number of events that could not be published.",
+		}, func() float64 { return float64(queue.DeadLettered()) }),
+	)
+}
+
+func (m *Metrics) observeRequest(method string, err error, d time.Duration) {
+	code := status.Code(err).String()
+	m.requestsTotal.WithLabelValues(method, code).Inc()
+	m.requestDuration.WithLabelValues(method, code).Observe(d.Seconds())
+}
@@ -0,0 +1,134 @@
+package metrics
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	t.Run("records the request by method and status code", func(t *testing.T) {
+		// Arrange
+		m := New(prometheus.NewRegistry())
+		interceptor := m.UnaryServerInterceptor()
+		info := &grpc.UnaryServerInfo{FullMethod: "/UserService/GetUser"}
+
+		okHandler := func(ctx context.Context, req any) (any, error) { return "ok", nil }
+		notFoundHandler := func(ctx context.Context, req any) (any, error) {
+			return nil, status.Error(codes.NotFound, "not found")
+		}
+
+		// Act
+		resp, err := interceptor(context.TODO(), nil, info, okHandler)
+		require.NoError(t, err)
+
+		_, err = interceptor(context.TODO(), nil, info, notFoundHandler)
+		require.Error(t, err)
+
+		// Assert
+		assert.Equal(t, "ok", resp)
+		assert.Equal(t, float64(1), testutil.ToFloat64(m.requestsTotal.WithLabelValues("/UserService/GetUser", "OK")))
+		assert.Equal(t, float64(1), testutil.ToFloat64(m.requestsTotal.WithLabelValues("/UserService/GetUser", "NotFound")))
+		assert.Equal(t, 2, testutil.CollectAndCount(m.requestDuration))
+	})
+}
+
+func TestHandler(t *testing.T) {
+	t.Run("serves the registered metrics", func(t *testing.T) {
+		// Arrange
+		m := New(prometheus.NewRegistry())
+		m.ObserveQuery("get", 10*time.Millisecond)
+
+		rec := httptest.NewRecorder()
+
+		// Act
+		m.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+		// Assert
+		assert.Equal(t, 200, rec.Code)
+		assert.True(t, strings.Contains(rec.Body.String(), `usrsvc_repository_query_duration_seconds_count{operation="get"} 1`))
+	})
+}
+
+type hashPoolStub struct{}
+
+func (hashPoolStub) QueueDepth() int  { return 3 }
+func (hashPoolStub) Rejected() uint64 { return 7 }
+
+func TestObserveHashPool(t *testing.T) {
+	t.Run("exports the pool state", func(t *testing.T) {
+		// Arrange
+		m := New(prometheus.NewRegistry())
+		m.ObserveHashPool(hashPoolStub{})
+
+		rec := httptest.NewRecorder()
+
+		// Act
+		m.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+		// Assert
+		assert.Contains(t, rec.Body.String(), "usrsvc_hashing_queue_depth 3")
+		assert.Contains(t, rec.Body.String(), "usrsvc_hashing_rejected_total 7")
+	})
+}
+
+type eventQueueStub struct{}
+
+func (eventQueueStub) Pending() int         { return 4 }
+func (eventQueueStub) DeadLettered() uint64 { return 2 }
+
+func TestObserveEventQueue(t *testing.T) {
+	t.Run("exports the queue state", func(t *testing.T) {
+		// Arrange
+		m := New(prometheus.NewRegistry())
+		m.ObserveEventQueue(eventQueueStub{})
+
+		rec := httptest.NewRecorder()
+
+		// Act
+		m.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+		// Assert
+		assert.Contains(t, rec.Body.String(), "usrsvc_events_pending 4")
+		assert.Contains(t, rec.Body.String(), "usrsvc_events_dead_lettered_total 2")
+	})
+}
+
+func TestObserveValidationWarning(t *testing.T) {
+	t.Run("counts the warnings by field and code", func(t *testing.T) {
+		// Arrange
+		m := New(prometheus.NewRegistry())
+
+		// Act
+		m.ObserveValidationWarning("password", "weak_password")
+		m.ObserveValidationWarning("password", "weak_password")
+		m.ObserveValidationWarning("country", "deprecated_country_code")
+
+		// Assert
+		assert.Equal(t, float64(2), testutil.ToFloat64(m.warningsTotal.WithLabelValues("password", "weak_password")))
+		assert.Equal(t, float64(1), testutil.ToFloat64(m.warningsTotal.WithLabelValues("country", "deprecated_country_code")))
+	})
+}
+
+func TestObservePanic(t *testing.T) {
+	t.Run("counts the panics by method", func(t *testing.T) {
+		// Arrange
+		m := New(prometheus.NewRegistry())
+
+		// Act
+		m.ObservePanic("/UserService/GetUser")
+
+		// Assert
+		assert.Equal(t, float64(1), testutil.ToFloat64(m.panicsTotal.WithLabelValues("/UserService/GetUser")))
+	})
+}
@@ -0,0 +1,69 @@
+// Package oidc verifies the ID tokens issued by the supported OpenID Connect providers.
+package oidc
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/alesr/usrsvc/internal/users/service"
+	"github.com/coreos/go-oidc/v3/oidc"
+)
+
+const (
+	googleIssuer  string = "https://accounts.google.com"
+	googleKeysURL string = "https://www.googleapis.com/oauth2/v3/certs"
+
+	azureIssuer  string = "https://login.microsoftonline.com/%s/v2.0"
+	azureKeysURL string = "https://login.microsoftonline.com/%s/discovery/v2.0/keys"
+)
+
+var _ service.IdentityVerifier = (*Verifier)(nil)
+
+// Verifier verifies the ID tokens issued by a provider to our client.
+// The provider signing keys are fetched on first use and cached.
+type Verifier struct {
+	verifier *oidc.IDTokenVerifier
+}
+
+// NewGoogle creates a verifier for the ID tokens issued by Google to the given client.
+func NewGoogle(clientID string) *Verifier {
+	return newVerifier(googleIssuer, oidc.NewRemoteKeySet(context.Background(), googleKeysURL), clientID)
+}
+
+// NewAzure creates a verifier for the ID tokens issued by the given Azure AD tenant to the given client.
+func NewAzure(tenantID, clientID string) *Verifier {
+	keySet := oidc.NewRemoteKeySet(context.Background(), fmt.Sprintf(azureKeysURL, tenantID))
+	return newVerifier(fmt.Sprintf(azureIssuer, tenantID), keySet, clientID)
+}
+
+func newVerifier(issuer string, keySet oidc.KeySet, clientID string) *Verifier {
+	return &Verifier{
+		verifier: oidc.NewVerifier(issuer, keySet, &oidc.Config{ClientID: clientID}),
+	}
+}
+
+// Verify checks the ID token signature, issuer, audience and expiry,
+// and returns the identity it asserts.
+func (v *Verifier) Verify(ctx context.Context, rawIDToken string) (*service.ExternalIdentity, error) {
+	token, err := v.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("could not verify id token: %w", err)
+	}
+
+	// Google tells whether the email is verified with email_verified, and Azure AD with the
+	// xms_edov optional claim, true when the domain of the email is owned by the tenant.
+	var claims struct {
+		Email               string `json:"email"`
+		EmailVerified       bool   `json:"email_verified"`
+		EmailDomainVerified bool   `json:"xms_edov"`
+	}
+	if err := token.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("could not parse id token claims: %w", err)
+	}
+
+	return &service.ExternalIdentity{
+		Subject:       token.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified || claims.EmailDomainVerified,
+	}, nil
+}
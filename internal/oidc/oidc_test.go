@@ -0,0 +1,147 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testIssuer   string = "https://issuer.example.com"
+	testClientID string = "usrsvc"
+)
+
+func signIDTokenHelper(t *testing.T, key *rsa.PrivateKey, claims map[string]any) string {
+	t.Helper()
+
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	require.NoError(t, err)
+
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	require.NoError(t, err)
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestVerify(t *testing.T) {
+	t.Parallel()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	verifier := newVerifier(testIssuer, &oidc.StaticKeySet{PublicKeys: []crypto.PublicKey{&key.PublicKey}}, testClientID)
+
+	claims := func(audience string, expiresAt time.Time) map[string]any {
+		return map[string]any{
+			"iss":            testIssuer,
+			"sub":            "1234567890",
+			"aud":            audience,
+			"email":          "joedoe@foo.bar",
+			"email_verified": true,
+			"iat":            time.Now().Add(-time.Minute).Unix(),
+			"exp":            expiresAt.Unix(),
+		}
+	}
+
+	t.Run("valid token", func(t *testing.T) {
+		// Arrange
+		token := signIDTokenHelper(t, key, claims(testClientID, time.Now().Add(time.Hour)))
+
+		// Act
+		identity, err := verifier.Verify(context.TODO(), token)
+		require.NoError(t, err)
+
+		// Assert
+		assert.Equal(t, "1234567890", identity.Subject)
+		assert.Equal(t, "joedoe@foo.bar", identity.Email)
+		assert.True(t, identity.EmailVerified)
+	})
+
+	t.Run("email verification", func(t *testing.T) {
+		testCases := []struct {
+			name     string
+			claims   map[string]any
+			expected bool
+		}{
+			{name: "unverified email", claims: map[string]any{"email_verified": false}, expected: false},
+			{name: "without the claims", claims: map[string]any{"email_verified": nil}, expected: false},
+			{name: "azure verified domain", claims: map[string]any{"email_verified": nil, "xms_edov": true}, expected: true},
+		}
+
+		for _, tc := range testCases {
+			tc := tc
+			t.Run(tc.name, func(t *testing.T) {
+				// Arrange
+				given := claims(testClientID, time.Now().Add(time.Hour))
+				for name, value := range tc.claims {
+					if value == nil {
+						delete(given, name)
+						continue
+					}
+					given[name] = value
+				}
+
+				token := signIDTokenHelper(t, key, given)
+
+				// Act
+				identity, err := verifier.Verify(context.TODO(), token)
+				require.NoError(t, err)
+
+				// Assert
+				assert.Equal(t, tc.expected, identity.EmailVerified)
+			})
+		}
+	})
+
+	t.Run("other audience", func(t *testing.T) {
+		// Arrange
+		token := signIDTokenHelper(t, key, claims("someone-else", time.Now().Add(time.Hour)))
+
+		// Act
+		_, err := verifier.Verify(context.TODO(), token)
+
+		// Assert
+		assert.Error(t, err)
+	})
+
+	t.Run("expired token", func(t *testing.T) {
+		// Arrange
+		token := signIDTokenHelper(t, key, claims(testClientID, time.Now().Add(-time.Second)))
+
+		// Act
+		_, err := verifier.Verify(context.TODO(), token)
+
+		// Assert
+		assert.Error(t, err)
+	})
+
+	t.Run("signed by another key", func(t *testing.T) {
+		// Arrange
+		otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+
+		token := signIDTokenHelper(t, otherKey, claims(testClientID, time.Now().Add(time.Hour)))
+
+		// Act
+		_, err = verifier.Verify(context.TODO(), token)
+
+		// Assert
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,272 @@
+// Package operations runs the long admin jobs in the background, so the RPCs starting them
+// return an operation id at once and the clients poll its progress and result instead of
+// holding the connection open for minutes.
+//
+// The operations are kept in memory by the instance running them, until they have been
+// done for the retention period, so they are lost when the instance restarts.
+package operations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/alesr/usrsvc/internal/tenant"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrNotFound error = errors.New("operation not found")
+	ErrClosed   error = errors.New("operations are closed")
+)
+
+// Func is the job of an operation. It reports its progress with Report,
+// and its result is the result of the operation.
+type Func func(ctx context.Context) (any, error)
+
+// Operation is a snapshot of a job started by the Manager.
+type Operation struct {
+	ID   string
+	Kind string // The job, e.g. find_duplicate_users.
+
+	// Tenant is the tenant of the context the operation was started in, empty if unscoped.
+	Tenant string
+
+	Done   bool
+	Result any   // Set once done, unless Err is.
+	Err    error // Set once done when the job failed.
+
+	// Processed and Total are the progress last reported by the job. Total is 0 when unknown.
+	Processed int64
+	Total     int64
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Filter selects the operations to list, newest first.
+type Filter struct {
+	Kind   string // Empty for all kinds.
+	Tenant string // Empty for all tenants.
+
+	// Cursor is the id of the last operation of the previous page, empty for the first page.
+	Cursor string
+	Limit  int
+}
+
+// Manager runs the operations and keeps track of them.
+type Manager struct {
+	retention time.Duration
+	now       func() time.Time
+
+	// ctx is the parent of the contexts of the jobs, cancelled by Close.
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu     sync.RWMutex
+	closed bool
+	ops    map[string]*Operation
+	order  []string // The ids of the operations, oldest first.
+}
+
+// NewManager creates a manager keeping the done operations for the retention period.
+func NewManager(retention time.Duration) *Manager {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Manager{
+		retention: retention,
+		now:       time.Now,
+		ctx:       ctx,
+		cancel:    cancel,
+		ops:       make(map[string]*Operation),
+	}
+}
+
+type operationKey struct{}
+
+// Start runs the job in the background and returns the operation tracking it. The job
+// gets the values of the context, e.g. the audit actor, but it isn't cancelled with it.
+func (m *Manager) Start(ctx context.Context, kind string, fn Func) (*Operation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil, ErrClosed
+	}
+	m.evict()
+
+	tenantID, _ := tenant.FromContext(ctx)
+
+	now := m.now()
+	op := &Operation{
+		ID:        uuid.New().String(),
+		Kind:      kind,
+		Tenant:    tenantID,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	m.ops[op.ID] = op
+	m.order = append(m.order, op.ID)
+
+	jobCtx := context.WithValue(detached{parent: ctx, done: m.ctx}, operationKey{}, &tracker{manager: m, id: op.ID})
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+
+		result, err := run(jobCtx, fn)
+		m.finish(op.ID, result, err)
+	}()
+
+	snapshot := *op
+	return &snapshot, nil
+}
+
+// run runs the job, turning a panic into an error so it doesn't take down the instance.
+func run(ctx context.Context, fn Func) (result any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("operation panicked: %v", r)
+		}
+	}()
+	return fn(ctx)
+}
+
+func (m *Manager) finish(id string, result any, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	op := m.ops[id]
+	op.Done = true
+	op.Result = result
+	op.Err = err
+	op.UpdatedAt = m.now()
+}
+
+func (m *Manager) report(id string, processed, total int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if op := m.ops[id]; op != nil && !op.Done {
+		op.Processed = processed
+		op.Total = total
+		op.UpdatedAt = m.now()
+	}
+}
+
+// Get returns the operation with the given id or ErrNotFound.
+func (m *Manager) Get(id string) (*Operation, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	op, ok := m.ops[id]
+	if !ok || m.expired(op) {
+		return nil, ErrNotFound
+	}
+
+	snapshot := *op
+	return &snapshot, nil
+}
+
+// List returns a page of the operations selected by the filter, newest first.
+func (m *Manager) List(filter Filter) []*Operation {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	start := len(m.order) - 1
+	if filter.Cursor != "" {
+		for i, id := range m.order {
+			if id == filter.Cursor {
+				start = i - 1
+				break
+			}
+		}
+	}
+
+	var ops []*Operation
+	for i := start; i >= 0 && len(ops) < filter.Limit; i-- {
+		op := m.ops[m.order[i]]
+		if m.expired(op) || (filter.Kind != "" && op.Kind != filter.Kind) || (filter.Tenant != "" && op.Tenant != filter.Tenant) {
+			continue
+		}
+
+		snapshot := *op
+		ops = append(ops, &snapshot)
+	}
+	return ops
+}
+
+// Close cancels the running jobs and waits for them to return, or for the context to be done.
+// No operation can be started once closed.
+func (m *Manager) Close(ctx context.Context) error {
+	m.mu.Lock()
+	m.closed = true
+	m.mu.Unlock()
+
+	m.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// expired reports whether the operation has been done for longer than the retention period.
+func (m *Manager) expired(op *Operation) bool {
+	return op.Done && m.now().Sub(op.UpdatedAt) > m.retention
+}
+
+// evict forgets the expired operations. It must be called with the lock held.
+func (m *Manager) evict() {
+	order := m.order[:0]
+	for _, id := range m.order {
+		if m.expired(m.ops[id]) {
+			delete(m.ops, id)
+			continue
+		}
+		order = append(order, id)
+	}
+	m.order = order
+}
+
+// tracker reports the progress of an operation.
+type tracker struct {
+	manager *Manager
+	id      string
+}
+
+// Report reports the progress of the operation running the job, if the context is the
+// context of one. Total is 0 when unknown.
+func Report(ctx context.Context, processed, total int64) {
+	if t, ok := ctx.Value(operationKey{}).(*tracker); ok {
+		t.manager.report(t.id, processed, total)
+	}
+}
+
+// Tracked reports whether the context is the context of an operation, so jobs can skip
+// the work only needed to report their progress otherwise.
+func Tracked(ctx context.Context) bool {
+	_, ok := ctx.Value(operationKey{}).(*tracker)
+	return ok
+}
+
+// detached is a context with the values of the parent, but cancelled with done instead.
+type detached struct {
+	parent context.Context
+	done   context.Context
+}
+
+func (d detached) Deadline() (time.Time, bool) { return d.done.Deadline() }
+func (d detached) Done() <-chan struct{}       { return d.done.Done() }
+func (d detached) Err() error                  { return d.done.Err() }
+func (d detached) Value(key any) any           { return d.parent.Value(key) }
@@ -0,0 +1,177 @@
+package operations
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alesr/usrsvc/internal/tenant"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type actorKey struct{}
+
+// waitDone waits for the operation to be done and returns it.
+func waitDone(t *testing.T, m *Manager, id string) *Operation {
+	t.Helper()
+
+	var op *Operation
+	require.Eventually(t, func() bool {
+		var err error
+		op, err = m.Get(id)
+		require.NoError(t, err)
+		return op.Done
+	}, time.Second, time.Millisecond)
+	return op
+}
+
+func TestManager(t *testing.T) {
+	t.Parallel()
+
+	t.Run("runs the job in the background", func(t *testing.T) {
+		// Arrange
+		m := NewManager(time.Hour)
+		release := make(chan struct{})
+
+		ctx, cancel := context.WithCancel(context.WithValue(context.TODO(), actorKey{}, "admin-id"))
+
+		// Act
+		started, err := m.Start(ctx, "scan", func(ctx context.Context) (any, error) {
+			Report(ctx, 1, 2)
+			<-release
+
+			// The job outlives the context it was started with, but keeps its values.
+			return ctx.Value(actorKey{}), ctx.Err()
+		})
+		require.NoError(t, err)
+		cancel()
+
+		// Assert
+		assert.False(t, started.Done)
+		assert.Equal(t, "scan", started.Kind)
+
+		require.Eventually(t, func() bool {
+			op, err := m.Get(started.ID)
+			require.NoError(t, err)
+			return op.Processed == 1 && op.Total == 2
+		}, time.Second, time.Millisecond)
+
+		close(release)
+
+		op := waitDone(t, m, started.ID)
+		assert.NoError(t, op.Err)
+		assert.Equal(t, "admin-id", op.Result)
+	})
+
+	t.Run("records the error of the job", func(t *testing.T) {
+		// Arrange
+		m := NewManager(time.Hour)
+		jobErr := errors.New("job error")
+
+		// Act
+		started, err := m.Start(context.TODO(), "scan", func(ctx context.Context) (any, error) {
+			return nil, jobErr
+		})
+		require.NoError(t, err)
+
+		// Assert
+		op := waitDone(t, m, started.ID)
+		assert.True(t, errors.Is(op.Err, jobErr))
+		assert.Nil(t, op.Result)
+	})
+
+	t.Run("recovers from a panicking job", func(t *testing.T) {
+		// Arrange
+		m := NewManager(time.Hour)
+
+		// Act
+		started, err := m.Start(context.TODO(), "scan", func(ctx context.Context) (any, error) {
+			panic("boom")
+		})
+		require.NoError(t, err)
+
+		// Assert
+		op := waitDone(t, m, started.ID)
+		assert.EqualError(t, op.Err, "operation panicked: boom")
+	})
+
+	t.Run("forgets the operations done for longer than the retention", func(t *testing.T) {
+		// Arrange
+		m := NewManager(time.Hour)
+		now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+		m.now = func() time.Time { return now }
+
+		started, err := m.Start(context.TODO(), "scan", func(ctx context.Context) (any, error) { return nil, nil })
+		require.NoError(t, err)
+		waitDone(t, m, started.ID)
+
+		// Act
+		now = now.Add(2 * time.Hour)
+		_, err = m.Get(started.ID)
+
+		// Assert
+		assert.True(t, errors.Is(err, ErrNotFound))
+	})
+
+	t.Run("cancels the running jobs when closed", func(t *testing.T) {
+		// Arrange
+		m := NewManager(time.Hour)
+
+		started, err := m.Start(context.TODO(), "scan", func(ctx context.Context) (any, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+		require.NoError(t, err)
+
+		// Act
+		require.NoError(t, m.Close(context.TODO()))
+		_, startErr := m.Start(context.TODO(), "scan", func(ctx context.Context) (any, error) { return nil, nil })
+
+		// Assert
+		op, err := m.Get(started.ID)
+		require.NoError(t, err)
+		assert.True(t, op.Done)
+		assert.True(t, errors.Is(op.Err, context.Canceled))
+		assert.True(t, errors.Is(startErr, ErrClosed))
+	})
+}
+
+func TestManagerList(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	m := NewManager(time.Hour)
+
+	var ids []string
+	for _, kind := range []string{"scan", "merge", "scan", "scan"} {
+		op, err := m.Start(context.TODO(), kind, func(ctx context.Context) (any, error) { return nil, nil })
+		require.NoError(t, err)
+		ids = append(ids, op.ID)
+	}
+
+	acme, err := m.Start(tenant.ContextWithTenant(context.TODO(), "acme"), "scan", func(ctx context.Context) (any, error) { return nil, nil })
+	require.NoError(t, err)
+
+	// Act
+	first := m.List(Filter{Kind: "scan", Limit: 2})
+	second := m.List(Filter{Kind: "scan", Cursor: first[len(first)-1].ID, Limit: 2})
+	all := m.List(Filter{Limit: 10})
+	ofTenant := m.List(Filter{Tenant: "acme", Limit: 10})
+
+	// Assert
+	require.Len(t, first, 2)
+	assert.Equal(t, acme.ID, first[0].ID)
+	assert.Equal(t, ids[3], first[1].ID)
+
+	require.Len(t, second, 2)
+	assert.Equal(t, ids[2], second[0].ID)
+	assert.Equal(t, ids[0], second[1].ID)
+
+	assert.Len(t, all, 5)
+
+	require.Len(t, ofTenant, 1)
+	assert.Equal(t, acme.ID, ofTenant[0].ID)
+	assert.Equal(t, "acme", ofTenant[0].Tenant)
+}
@@ -0,0 +1,38 @@
+package policies
+
+import (
+	"context"
+	"sync"
+)
+
+// Memory keeps the policies in memory, for local development and tests.
+type Memory struct {
+	mu       sync.RWMutex
+	policies map[string]Policy
+}
+
+// NewMemory creates a new in-memory policy store.
+func NewMemory() *Memory {
+	return &Memory{policies: make(map[string]Policy)}
+}
+
+// Get returns the policy of the tenant.
+func (m *Memory) Get(ctx context.Context, tenant string) (*Policy, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	policy, ok := m.policies[tenant]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &policy, nil
+}
+
+// Put creates or replaces the policy of its tenant.
+func (m *Memory) Put(ctx context.Context, policy *Policy) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.policies[policy.Tenant] = *policy
+	return nil
+}
@@ -0,0 +1,33 @@
+package policies
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemory(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	store := NewMemory()
+
+	require.NoError(t, store.Put(context.TODO(), &Policy{Tenant: "acme", MinPasswordLength: 10, UpdatedAt: time.Now()}))
+
+	given := &Policy{Tenant: "acme", MinPasswordLength: 12, NameSeparators: "-.", MinAge: 16, UpdatedAt: time.Now()}
+	require.NoError(t, store.Put(context.TODO(), given))
+
+	// Act
+	observed, err := store.Get(context.TODO(), "acme")
+	require.NoError(t, err)
+
+	_, notFoundErr := store.Get(context.TODO(), "globex")
+
+	// Assert
+	assert.Equal(t, given, observed)
+	assert.True(t, errors.Is(notFoundErr, ErrNotFound))
+}
@@ -0,0 +1,71 @@
+package policies
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const mongoTenantPolicies string = "tenant_policies"
+
+// document is the tenant_policies collection document, keyed by tenant.
+type document struct {
+	TenantID          string    `bson:"_id"`
+	MinPasswordLength int       `bson:"min_password_length"`
+	NameSeparators    string    `bson:"name_separators"`
+	MinAge            int       `bson:"min_age"`
+	UpdatedAt         time.Time `bson:"updated_at"`
+}
+
+// Mongo stores the policies in the tenant_policies collection.
+type Mongo struct {
+	db *mongo.Database
+}
+
+// NewMongo creates a new Mongo policy store.
+func NewMongo(db *mongo.Database) *Mongo {
+	return &Mongo{db: db}
+}
+
+// Get returns the policy of the tenant.
+func (m *Mongo) Get(ctx context.Context, tenant string) (*Policy, error) {
+	var doc document
+	if err := m.db.Collection(mongoTenantPolicies).FindOne(ctx, bson.M{"_id": tenant}).Decode(&doc); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("could not get tenant policy: %w", err)
+	}
+
+	return &Policy{
+		Tenant:            doc.TenantID,
+		MinPasswordLength: doc.MinPasswordLength,
+		NameSeparators:    doc.NameSeparators,
+		MinAge:            doc.MinAge,
+		UpdatedAt:         doc.UpdatedAt,
+	}, nil
+}
+
+// Put creates or replaces the policy of its tenant.
+func (m *Mongo) Put(ctx context.Context, policy *Policy) error {
+	if _, err := m.db.Collection(mongoTenantPolicies).ReplaceOne(
+		ctx,
+		bson.M{"_id": policy.Tenant},
+		document{
+			TenantID:          policy.Tenant,
+			MinPasswordLength: policy.MinPasswordLength,
+			NameSeparators:    policy.NameSeparators,
+			MinAge:            policy.MinAge,
+			UpdatedAt:         policy.UpdatedAt,
+		},
+		options.Replace().SetUpsert(true),
+	); err != nil {
+		return fmt.Errorf("could not put tenant policy: %w", err)
+	}
+	return nil
+}
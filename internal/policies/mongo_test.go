@@ -0,0 +1,69 @@
+//go:build integration
+// +build integration
+
+package policies
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const defaultMongoURI string = "mongodb://localhost:27017/?replicaSet=rs0&directConnection=true"
+
+func TestMongo(t *testing.T) {
+	// Arrange
+	store := NewMongo(setupMongoHelper(t))
+	updatedAt := time.Now().UTC().Truncate(time.Millisecond)
+
+	require.NoError(t, store.Put(context.TODO(), &Policy{Tenant: "acme", MinPasswordLength: 10, UpdatedAt: updatedAt}))
+	require.NoError(t, store.Put(context.TODO(), &Policy{
+		Tenant:            "acme",
+		MinPasswordLength: 12,
+		NameSeparators:    "-.",
+		MinAge:            16,
+		UpdatedAt:         updatedAt,
+	}))
+
+	// Act
+	observed, err := store.Get(context.TODO(), "acme")
+	require.NoError(t, err)
+
+	_, notFoundErr := store.Get(context.TODO(), "globex")
+
+	// Assert
+	assert.Equal(t, 12, observed.MinPasswordLength)
+	assert.Equal(t, "-.", observed.NameSeparators)
+	assert.Equal(t, 16, observed.MinAge)
+	assert.True(t, updatedAt.Equal(observed.UpdatedAt))
+	assert.True(t, errors.Is(notFoundErr, ErrNotFound))
+}
+
+// setupMongoHelper returns a new database, dropped when the test ends.
+func setupMongoHelper(t *testing.T) *mongo.Database {
+	t.Helper()
+
+	uri := os.Getenv("MONGO_URI")
+	if uri == "" {
+		uri = defaultMongoURI
+	}
+
+	client, err := mongo.Connect(context.TODO(), options.Client().ApplyURI(uri))
+	require.NoError(t, err)
+
+	db := client.Database("usrsvc_test_" + strings.ReplaceAll(uuid.New().String(), "-", ""))
+	t.Cleanup(func() {
+		db.Drop(context.TODO())
+		client.Disconnect(context.TODO())
+	})
+	return db
+}
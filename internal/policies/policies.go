@@ -0,0 +1,45 @@
+// Package policies stores the validation policies of the tenants, so every tenant can
+// tune the password, name and age rules of its users within the server limits.
+package policies
+
+import (
+	"errors"
+	"time"
+
+	"github.com/alesr/usrsvc/pkg/uservalidation"
+)
+
+// ErrNotFound is returned for the tenants without a policy, which use the server defaults.
+var ErrNotFound = errors.New("tenant policy not found")
+
+// Policy overrides the validation policy of the users of a tenant. The zero values keep
+// the server defaults.
+type Policy struct {
+	Tenant string
+
+	MinPasswordLength int
+
+	// NameSeparators are the characters allowed in names besides letters and spaces.
+	NameSeparators string
+
+	// MinAge is the age in years below which the users are rejected, given their birthdate.
+	MinAge int
+
+	UpdatedAt time.Time
+}
+
+// Apply returns the validation policy with the overrides of the tenant.
+func (p *Policy) Apply(base uservalidation.Policy) uservalidation.Policy {
+	if p.MinPasswordLength > 0 {
+		base.MinPasswordLength = p.MinPasswordLength
+	}
+
+	if p.NameSeparators != "" {
+		base.NameSeparators = p.NameSeparators
+	}
+
+	if p.MinAge > 0 {
+		base.MinAge = p.MinAge
+	}
+	return base
+}
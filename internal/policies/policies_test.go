@@ -0,0 +1,50 @@
+package policies
+
+import (
+	"testing"
+
+	"github.com/alesr/usrsvc/pkg/uservalidation"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicyApply(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		given    Policy
+		expected func(p *uservalidation.Policy)
+	}{
+		{
+			name:     "zero values keep the defaults",
+			given:    Policy{Tenant: "acme"},
+			expected: func(p *uservalidation.Policy) {},
+		},
+		{
+			name:  "every override",
+			given: Policy{Tenant: "acme", MinPasswordLength: 12, NameSeparators: "-.", MinAge: 16},
+			expected: func(p *uservalidation.Policy) {
+				p.MinPasswordLength = 12
+				p.NameSeparators = "-."
+				p.MinAge = 16
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Arrange
+			expected := uservalidation.DefaultPolicy
+			tc.expected(&expected)
+
+			// Act
+			observed := tc.given.Apply(uservalidation.DefaultPolicy)
+
+			// Assert
+			assert.Equal(t, expected, observed)
+		})
+	}
+}
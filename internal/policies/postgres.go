@@ -0,0 +1,78 @@
+package policies
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// row is the tenant_policies table row.
+type row struct {
+	TenantID          string    `db:"tenant_id"`
+	MinPasswordLength int       `db:"min_password_length"`
+	NameSeparators    string    `db:"name_separators"`
+	MinAge            int       `db:"min_age"`
+	UpdatedAt         time.Time `db:"updated_at"`
+}
+
+// Postgres stores the policies in the tenant_policies table. The queries are portable,
+// so it also stores the policies on the SQLite schema.
+type Postgres struct {
+	db *sqlx.DB
+}
+
+// NewPostgres creates a new Postgres policy store.
+func NewPostgres(db *sqlx.DB) *Postgres {
+	return &Postgres{db: db}
+}
+
+// Get returns the policy of the tenant.
+func (p *Postgres) Get(ctx context.Context, tenant string) (*Policy, error) {
+	var r row
+	if err := p.db.GetContext(
+		ctx,
+		&r,
+		`SELECT tenant_id, min_password_length, name_separators, min_age, updated_at
+		FROM tenant_policies WHERE tenant_id = $1`,
+		tenant,
+	); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("could not get tenant policy: %w", err)
+	}
+
+	return &Policy{
+		Tenant:            r.TenantID,
+		MinPasswordLength: r.MinPasswordLength,
+		NameSeparators:    r.NameSeparators,
+		MinAge:            r.MinAge,
+		UpdatedAt:         r.UpdatedAt,
+	}, nil
+}
+
+// Put creates or replaces the policy of its tenant.
+func (p *Postgres) Put(ctx context.Context, policy *Policy) error {
+	if _, err := p.db.ExecContext(
+		ctx,
+		`INSERT INTO tenant_policies (tenant_id, min_password_length, name_separators, min_age, updated_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (tenant_id) DO UPDATE SET
+			min_password_length = excluded.min_password_length,
+			name_separators = excluded.name_separators,
+			min_age = excluded.min_age,
+			updated_at = excluded.updated_at`,
+		policy.Tenant,
+		policy.MinPasswordLength,
+		policy.NameSeparators,
+		policy.MinAge,
+		policy.UpdatedAt,
+	); err != nil {
+		return fmt.Errorf("could not put tenant policy: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+//go:build integration
+// +build integration
+
+package policies
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+	"github.com/pressly/goose/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	migrationsDir      string = "../../migrations"
+	postgresDriverName string = "postgres"
+	dbHost             string = "localhost"
+	dbPort             string = "5432"
+	dbUser             string = "user"
+	dbPass             string = "password"
+	dbName             string = "usrsvc"
+)
+
+func TestPostgres(t *testing.T) {
+	db := setupDBHelper(t)
+	defer teardownDBHelper(t, db)
+
+	// Arrange
+	store := NewPostgres(db)
+	updatedAt := time.Now().UTC().Truncate(time.Millisecond)
+
+	require.NoError(t, store.Put(context.TODO(), &Policy{Tenant: "acme", MinPasswordLength: 10, UpdatedAt: updatedAt}))
+	require.NoError(t, store.Put(context.TODO(), &Policy{
+		Tenant:            "acme",
+		MinPasswordLength: 12,
+		NameSeparators:    "-.",
+		MinAge:            16,
+		UpdatedAt:         updatedAt,
+	}))
+
+	// Act
+	observed, err := store.Get(context.TODO(), "acme")
+	require.NoError(t, err)
+
+	_, notFoundErr := store.Get(context.TODO(), "globex")
+
+	// Assert
+	assert.Equal(t, 12, observed.MinPasswordLength)
+	assert.Equal(t, "-.", observed.NameSeparators)
+	assert.Equal(t, 16, observed.MinAge)
+	assert.True(t, updatedAt.Equal(observed.UpdatedAt))
+	assert.True(t, errors.Is(notFoundErr, ErrNotFound))
+}
+
+func setupDBHelper(t *testing.T) *sqlx.DB {
+	t.Helper()
+
+	db, err := sqlx.Open(postgresDriverName, fmt.Sprintf(
+		"host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+		dbHost, dbPort, dbUser, dbPass, dbName),
+	)
+	require.NoError(t, err)
+
+	require.NoError(t, goose.Up(db.DB, migrationsDir))
+	return db
+}
+
+func teardownDBHelper(t *testing.T, db *sqlx.DB) {
+	t.Helper()
+
+	_, err := db.Exec("TRUNCATE TABLE tenant_policies")
+	require.NoError(t, err)
+
+	require.NoError(t, db.Close())
+}
@@ -0,0 +1,237 @@
+// Package pwned checks passwords against the Pwned Passwords range API of HaveIBeenPwned.
+// Only the first 5 hex characters of the password SHA-1 are sent (k-anonymity).
+package pwned
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultBaseURL   string        = "https://api.pwnedpasswords.com"
+	defaultTimeout   time.Duration = 2 * time.Second
+	defaultCacheTTL  time.Duration = 24 * time.Hour
+	defaultCacheSize int           = 10000
+
+	defaultBreakerFailures int           = 5
+	defaultBreakerCooldown time.Duration = 30 * time.Second
+
+	prefixLength int = 5
+)
+
+// ErrCircuitOpen is returned while the API is considered down, without calling it.
+var ErrCircuitOpen error = errors.New("pwned passwords circuit breaker is open")
+
+// Client queries the range API. Responses are cached per hash prefix, and after
+// too many consecutive failures the client stops calling the API for a while.
+type Client struct {
+	httpClient *http.Client
+	baseURL    string
+	cacheTTL   time.Duration
+	cacheSize  int
+
+	mu    sync.Mutex
+	cache map[string]cachedRange
+
+	breaker breaker
+}
+
+type cachedRange struct {
+	suffixes  map[string]struct{}
+	expiresAt time.Time
+}
+
+// Option is a function that configures the client.
+type Option func(*Client)
+
+// WithBaseURL overrides the API base URL, e.g. to use a self-hosted mirror.
+func WithBaseURL(url string) Option {
+	return func(c *Client) {
+		c.baseURL = strings.TrimSuffix(url, "/")
+	}
+}
+
+// WithTimeout bounds the duration of a request to the API.
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		c.httpClient.Timeout = d
+	}
+}
+
+// WithCache sets for how long and how many hash prefixes are cached.
+func WithCache(ttl time.Duration, size int) Option {
+	return func(c *Client) {
+		c.cacheTTL = ttl
+		c.cacheSize = size
+	}
+}
+
+// WithBreaker sets after how many consecutive failures the API stops being called,
+// and for how long.
+func WithBreaker(failures int, cooldown time.Duration) Option {
+	return func(c *Client) {
+		c.breaker.maxFailures = failures
+		c.breaker.cooldown = cooldown
+	}
+}
+
+// New creates a new client.
+func New(opts ...Option) *Client {
+	c := &Client{
+		httpClient: &http.Client{Timeout: defaultTimeout},
+		baseURL:    defaultBaseURL,
+		cacheTTL:   defaultCacheTTL,
+		cacheSize:  defaultCacheSize,
+		cache:      make(map[string]cachedRange),
+		breaker: breaker{
+			maxFailures: defaultBreakerFailures,
+			cooldown:    defaultBreakerCooldown,
+		},
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Breached reports whether the password appears in a known data breach.
+func (c *Client) Breached(ctx context.Context, password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:prefixLength], hash[prefixLength:]
+
+	suffixes, ok := c.cached(prefix)
+	if !ok {
+		if !c.breaker.allow() {
+			return false, ErrCircuitOpen
+		}
+
+		var err error
+		suffixes, err = c.fetchRange(ctx, prefix)
+		c.breaker.record(err)
+		if err != nil {
+			return false, err
+		}
+		c.store(prefix, suffixes)
+	}
+
+	_, breached := suffixes[suffix]
+	return breached, nil
+}
+
+// fetchRange returns the suffixes of the breached hashes starting with the prefix.
+func (c *Client) fetchRange(ctx context.Context, prefix string) (map[string]struct{}, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/range/"+prefix, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not create range request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("could not query pwned passwords: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("could not query pwned passwords: unexpected status %d", resp.StatusCode)
+	}
+
+	// Each line is "<hash suffix>:<count>".
+	suffixes := make(map[string]struct{})
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		suffix, _, ok := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if ok {
+			suffixes[strings.ToUpper(suffix)] = struct{}{}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read pwned passwords response: %w", err)
+	}
+	return suffixes, nil
+}
+
+func (c *Client) cached(prefix string) (map[string]struct{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.cache[prefix]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return nil, false
+	}
+	return entry.suffixes, true
+}
+
+func (c *Client) store(prefix string, suffixes map[string]struct{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.cache) >= c.cacheSize {
+		now := time.Now()
+		for p, entry := range c.cache {
+			if now.After(entry.expiresAt) {
+				delete(c.cache, p)
+			}
+		}
+
+		// Still full, start over rather than tracking the least recently used prefixes.
+		if len(c.cache) >= c.cacheSize {
+			c.cache = make(map[string]cachedRange)
+		}
+	}
+
+	c.cache[prefix] = cachedRange{suffixes: suffixes, expiresAt: time.Now().Add(c.cacheTTL)}
+}
+
+// breaker is a consecutive failures circuit breaker. Once open, a single
+// request is let through after the cooldown to probe whether the API is back.
+type breaker struct {
+	maxFailures int
+	cooldown    time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+}
+
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.maxFailures {
+		return true
+	}
+
+	if time.Now().Before(b.openUntil) {
+		return false
+	}
+
+	// Half-open: let this request probe the API and keep the others out until it is done.
+	b.openUntil = time.Now().Add(b.cooldown)
+	return true
+}
+
+func (b *breaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err == nil {
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.failures >= b.maxFailures {
+		b.openUntil = time.Now().Add(b.cooldown)
+	}
+}
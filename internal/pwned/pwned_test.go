@@ -0,0 +1,119 @@
+package pwned
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func hashHelper(t *testing.T, password string) (string, string) {
+	t.Helper()
+
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	return hash[:prefixLength], hash[prefixLength:]
+}
+
+func TestBreached(t *testing.T) {
+	t.Parallel()
+
+	prefix, suffix := hashHelper(t, "password1")
+
+	var calls int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&calls, 1)
+
+		// Only the prefix is ever sent.
+		assert.Equal(t, "/range/"+prefix, r.URL.Path)
+		fmt.Fprintf(w, "0018A45C4D1DEF81644B54AB7F969B88D65:1\r\n%s:2427\r\n", suffix)
+	}))
+	defer server.Close()
+
+	client := New(WithBaseURL(server.URL))
+
+	// Act
+	breached, err := client.Breached(context.TODO(), "password1")
+	require.NoError(t, err)
+
+	cachedBreached, err := client.Breached(context.TODO(), "password1")
+	require.NoError(t, err)
+
+	// Assert
+	assert.True(t, breached)
+	assert.True(t, cachedBreached)
+	assert.Equal(t, int64(1), atomic.LoadInt64(&calls))
+}
+
+func TestBreachedNotFound(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "0018A45C4D1DEF81644B54AB7F969B88D65:1\r\n")
+	}))
+	defer server.Close()
+
+	client := New(WithBaseURL(server.URL))
+
+	// Act
+	breached, err := client.Breached(context.TODO(), "c0rrect-h0rse-battery-staple!")
+	require.NoError(t, err)
+
+	// Assert
+	assert.False(t, breached)
+}
+
+func TestBreachedCircuitBreaker(t *testing.T) {
+	t.Parallel()
+
+	var (
+		calls int64
+		down  atomic.Bool
+	)
+	down.Store(true)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&calls, 1)
+		if down.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, "0018A45C4D1DEF81644B54AB7F969B88D65:1\r\n")
+	}))
+	defer server.Close()
+
+	client := New(WithBaseURL(server.URL), WithBreaker(2, 50*time.Millisecond))
+
+	// Two failures open the circuit.
+	for i := 0; i < 2; i++ {
+		_, err := client.Breached(context.TODO(), fmt.Sprintf("password%d", i))
+		require.Error(t, err)
+		assert.False(t, errors.Is(err, ErrCircuitOpen))
+	}
+
+	// While open, the API is not called.
+	_, err := client.Breached(context.TODO(), "password2")
+	assert.True(t, errors.Is(err, ErrCircuitOpen))
+	assert.Equal(t, int64(2), atomic.LoadInt64(&calls))
+
+	// After the cooldown, a successful probe closes it.
+	down.Store(false)
+	time.Sleep(60 * time.Millisecond)
+
+	_, err = client.Breached(context.TODO(), "password3")
+	require.NoError(t, err)
+
+	_, err = client.Breached(context.TODO(), "password4")
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), atomic.LoadInt64(&calls))
+}
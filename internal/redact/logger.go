@@ -0,0 +1,59 @@
+package redact
+
+import "go.uber.org/zap/zapcore"
+
+// WrapCore returns a core redacting the string fields of the logs, for zap.WrapCore.
+func (p *Policy) WrapCore(core zapcore.Core) zapcore.Core {
+	if p == nil || len(p.fields) == 0 {
+		return core
+	}
+	return &redactingCore{Core: core, policy: p}
+}
+
+type redactingCore struct {
+	zapcore.Core
+	policy *Policy
+}
+
+func (c *redactingCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactingCore{Core: c.Core.With(c.policy.logFields(fields)), policy: c.policy}
+}
+
+func (c *redactingCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+func (c *redactingCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	return c.Core.Write(entry, c.policy.logFields(fields))
+}
+
+// logFields returns the fields with the redacted ones replaced or dropped.
+// The fields are copied only if one of them is redacted.
+func (p *Policy) logFields(fields []zapcore.Field) []zapcore.Field {
+	var redacted []zapcore.Field
+	for i, field := range fields {
+		if field.Type != zapcore.StringType || !p.Redacts(field.Key) {
+			if redacted != nil {
+				redacted = append(redacted, field)
+			}
+			continue
+		}
+
+		if redacted == nil {
+			redacted = append(make([]zapcore.Field, 0, len(fields)), fields[:i]...)
+		}
+
+		if value, keep := p.Value(field.Key, field.String); keep {
+			field.String = value
+			redacted = append(redacted, field)
+		}
+	}
+
+	if redacted == nil {
+		return fields
+	}
+	return redacted
+}
@@ -0,0 +1,96 @@
+package redact
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/alesr/usrsvc/pkg/events"
+)
+
+// Publisher returns a publisher redacting the event data before publishing it to next.
+// The data is marshaled to JSON and the fields are matched by their JSON name at every
+// level. Ordered data keeps its key and sequence.
+func (p *Policy) Publisher(next events.Publisher) events.Publisher {
+	if p == nil || len(p.fields) == 0 {
+		return next
+	}
+	return &redactingPublisher{next: next, policy: p}
+}
+
+type redactingPublisher struct {
+	next   events.Publisher
+	policy *Policy
+}
+
+func (r *redactingPublisher) Publish(ctx context.Context, event events.Event, data any) error {
+	if ordered, ok := data.(events.Ordered); ok {
+		redacted, err := r.policy.data(ordered.Data)
+		if err != nil {
+			return err
+		}
+
+		ordered.Data = redacted
+		return r.next.Publish(ctx, event, ordered)
+	}
+
+	redacted, err := r.policy.data(data)
+	if err != nil {
+		return err
+	}
+	return r.next.Publish(ctx, event, redacted)
+}
+
+// data returns the JSON of the data with the fields redacted.
+func (p *Policy) data(data any) (json.RawMessage, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal event data: %w", err)
+	}
+
+	var decoded any
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		return nil, fmt.Errorf("could not unmarshal event data: %w", err)
+	}
+
+	redacted, err := json.Marshal(p.walk(decoded))
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal redacted event data: %w", err)
+	}
+	return redacted, nil
+}
+
+// walk redacts the fields of the decoded JSON objects. Values that are not strings,
+// e.g. numbers, are redacted from their JSON text.
+func (p *Policy) walk(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, field := range v {
+			if !p.Redacts(key) {
+				v[key] = p.walk(field)
+				continue
+			}
+
+			if field == nil {
+				continue
+			}
+
+			text, ok := field.(string)
+			if !ok {
+				encoded, _ := json.Marshal(field)
+				text = string(encoded)
+			}
+
+			if redacted, keep := p.Value(key, text); keep {
+				v[key] = redacted
+			} else {
+				delete(v, key)
+			}
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = p.walk(item)
+		}
+	}
+	return value
+}
@@ -0,0 +1,137 @@
+// Package redact applies the redaction policy of the deployment to the personal data
+// written to the logs, the audit log and the events, so it is defined in one place.
+package redact
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Strategy is how the values of a field are redacted.
+type Strategy string
+
+const (
+	// Drop removes the field.
+	Drop Strategy = "drop"
+
+	// Hash replaces the value with a keyed hash, so equal values can still be correlated.
+	Hash Strategy = "hash"
+
+	// Mask keeps the first character of the value, and the domain of emails.
+	Mask Strategy = "mask"
+)
+
+const (
+	hashPrefix string = "hash:"
+	hashLength int    = 16
+	maskSuffix string = "***"
+)
+
+// ErrInvalidPolicy is returned when parsing an invalid policy.
+var ErrInvalidPolicy = errors.New("invalid redaction policy")
+
+// Policy maps field names to their redaction strategy. Fields are matched by name in the
+// log fields, the audited changes and the event data, e.g. "email" or "ip". The zero
+// value and nil redact nothing.
+type Policy struct {
+	fields  map[string]Strategy
+	hashKey []byte
+}
+
+// New returns a policy redacting the fields with their strategy. The hash key keys the
+// hashes, so they can't be reversed by hashing guesses without it.
+func New(fields map[string]Strategy, hashKey []byte) *Policy {
+	return &Policy{fields: fields, hashKey: hashKey}
+}
+
+// Parse parses a policy of comma separated field=strategy pairs, e.g. "email=mask,ip=hash".
+func Parse(spec string, hashKey []byte) (*Policy, error) {
+	fields := make(map[string]Strategy)
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		field, strategy, ok := strings.Cut(pair, "=")
+		if !ok || field == "" {
+			return nil, fmt.Errorf("%w: '%s' is not a field=strategy pair", ErrInvalidPolicy, pair)
+		}
+
+		switch s := Strategy(strategy); s {
+		case Drop, Hash, Mask:
+			fields[field] = s
+		default:
+			return nil, fmt.Errorf("%w: unknown strategy '%s' for field '%s'", ErrInvalidPolicy, strategy, field)
+		}
+	}
+	return New(fields, hashKey), nil
+}
+
+// Redacts reports whether the policy redacts the field.
+func (p *Policy) Redacts(field string) bool {
+	if p == nil {
+		return false
+	}
+
+	_, ok := p.fields[field]
+	return ok
+}
+
+// Hashes reports whether the policy hashes any field, and so needs a hash key.
+func (p *Policy) Hashes() bool {
+	if p == nil {
+		return false
+	}
+
+	for _, strategy := range p.fields {
+		if strategy == Hash {
+			return true
+		}
+	}
+	return false
+}
+
+// Value redacts the value of the field. It returns false when the field must be dropped.
+// Empty values are kept as is.
+func (p *Policy) Value(field, value string) (string, bool) {
+	if p == nil || value == "" {
+		return value, true
+	}
+
+	strategy, ok := p.fields[field]
+	if !ok {
+		return value, true
+	}
+
+	switch strategy {
+	case Drop:
+		return "", false
+	case Hash:
+		return p.hash(value), true
+	default:
+		return mask(value), true
+	}
+}
+
+func (p *Policy) hash(value string) string {
+	mac := hmac.New(sha256.New, p.hashKey)
+	mac.Write([]byte(value))
+	return hashPrefix + hex.EncodeToString(mac.Sum(nil))[:hashLength]
+}
+
+// mask keeps the first character of the value and the domain of emails. The length
+// of the masked part is not kept.
+func mask(value string) string {
+	first, _ := utf8.DecodeRuneInString(value)
+
+	if at := strings.LastIndex(value, "@"); at > 0 {
+		return string(first) + maskSuffix + value[at:]
+	}
+	return string(first) + maskSuffix
+}
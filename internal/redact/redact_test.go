@@ -0,0 +1,222 @@
+package redact
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/alesr/usrsvc/pkg/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name        string
+		given       string
+		expected    map[string]Strategy
+		expectedErr error
+	}{
+		{
+			name:     "empty policy",
+			given:    "",
+			expected: map[string]Strategy{},
+		},
+		{
+			name:     "every strategy",
+			given:    "email=mask, ip=hash,last_name=drop",
+			expected: map[string]Strategy{"email": Mask, "ip": Hash, "last_name": Drop},
+		},
+		{
+			name:        "unknown strategy",
+			given:       "email=encrypt",
+			expectedErr: ErrInvalidPolicy,
+		},
+		{
+			name:        "missing strategy",
+			given:       "email",
+			expectedErr: ErrInvalidPolicy,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Act
+			policy, err := Parse(tc.given, nil)
+
+			// Assert
+			if tc.expectedErr != nil {
+				assert.True(t, errors.Is(err, tc.expectedErr))
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, policy.fields)
+		})
+	}
+}
+
+func TestPolicyValue(t *testing.T) {
+	t.Parallel()
+
+	policy := New(map[string]Strategy{"email": Mask, "nickname": Mask, "ip": Hash, "last_name": Drop}, []byte("some-key"))
+
+	testCases := []struct {
+		name         string
+		field        string
+		value        string
+		expected     string
+		expectedKeep bool
+	}{
+		{
+			name:         "masks emails keeping the domain",
+			field:        "email",
+			value:        "joedoe@foo.bar",
+			expected:     "j***@foo.bar",
+			expectedKeep: true,
+		},
+		{
+			name:         "masks values",
+			field:        "nickname",
+			value:        "jdoe",
+			expected:     "j***",
+			expectedKeep: true,
+		},
+		{
+			name:         "hashes values",
+			field:        "ip",
+			value:        "192.0.2.1",
+			expected:     policy.hash("192.0.2.1"),
+			expectedKeep: true,
+		},
+		{
+			name:  "drops values",
+			field: "last_name",
+			value: "Doe",
+		},
+		{
+			name:         "keeps other fields",
+			field:        "country",
+			value:        "PT",
+			expected:     "PT",
+			expectedKeep: true,
+		},
+		{
+			name:         "keeps empty values",
+			field:        "email",
+			expectedKeep: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Act
+			observed, keep := policy.Value(tc.field, tc.value)
+
+			// Assert
+			assert.Equal(t, tc.expected, observed)
+			assert.Equal(t, tc.expectedKeep, keep)
+		})
+	}
+
+	t.Run("hashes depend on the key", func(t *testing.T) {
+		t.Parallel()
+
+		other := New(map[string]Strategy{"ip": Hash}, []byte("other-key"))
+
+		// Act
+		first, _ := policy.Value("ip", "192.0.2.1")
+		again, _ := policy.Value("ip", "192.0.2.1")
+		observed, _ := other.Value("ip", "192.0.2.1")
+
+		// Assert
+		assert.Equal(t, first, again)
+		assert.NotEqual(t, first, observed)
+		assert.NotContains(t, first, "192.0.2.1")
+	})
+}
+
+func TestPolicyHashes(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, New(map[string]Strategy{"email": Mask, "ip": Hash}, nil).Hashes())
+	assert.False(t, New(map[string]Strategy{"email": Mask, "last_name": Drop}, nil).Hashes())
+	assert.False(t, (*Policy)(nil).Hashes())
+}
+
+func TestWrapCore(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	policy := New(map[string]Strategy{"email": Mask, "ip": Drop}, nil)
+
+	core, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(core, zap.WrapCore(policy.WrapCore))
+
+	// Act
+	logger.With(zap.String("email", "joedoe@foo.bar")).Info("some message",
+		zap.String("ip", "192.0.2.1"),
+		zap.String("country", "PT"),
+	)
+
+	// Assert
+	require.Equal(t, 1, logs.Len())
+	assert.Equal(t, map[string]any{"email": "j***@foo.bar", "country": "PT"}, logs.All()[0].ContextMap())
+}
+
+func TestPublisher(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	policy := New(map[string]Strategy{"email": Mask, "ip": Drop, "asn": Mask}, nil)
+
+	var published []*events.Envelope
+	next := publisherFunc(func(ctx context.Context, event events.Event, data any) error {
+		env, err := events.NewEnvelope(ctx, event, data)
+		require.NoError(t, err)
+
+		published = append(published, env)
+		return nil
+	})
+
+	// Act
+	err := policy.Publisher(next).Publish(context.TODO(), events.UserAuthenticated, events.Ordered{
+		Key:      "some-id",
+		Sequence: 2,
+		Data: events.SuspiciousLoginData{
+			LoginData: events.LoginData{UserID: "some-id", IP: "192.0.2.1", ASN: 64496},
+			Reason:    "new country",
+		},
+	})
+
+	// Assert
+	require.NoError(t, err)
+	require.Len(t, published, 1)
+	assert.Equal(t, "some-id", published[0].Key)
+	assert.Equal(t, int64(2), published[0].Sequence)
+
+	var data map[string]any
+	require.NoError(t, json.Unmarshal(published[0].Data, &data))
+	assert.Equal(t, "some-id", data["user_id"])
+	assert.Equal(t, "6***", data["asn"])
+	assert.NotContains(t, data, "ip")
+	assert.Equal(t, "new country", data["reason"])
+}
+
+type publisherFunc func(ctx context.Context, event events.Event, data any) error
+
+func (f publisherFunc) Publish(ctx context.Context, event events.Event, data any) error {
+	return f(ctx, event, data)
+}
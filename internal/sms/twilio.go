@@ -0,0 +1,108 @@
+// Package sms sends text messages through the Twilio Programmable Messaging API.
+package sms
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	defaultBaseURL string        = "https://api.twilio.com"
+	defaultTimeout time.Duration = 5 * time.Second
+
+	// maxErrorBodySize bounds the error responses read for their message.
+	maxErrorBodySize int64 = 4 << 10
+)
+
+// Twilio sends text messages from a phone number, or a messaging service, of a Twilio account.
+type Twilio struct {
+	httpClient *http.Client
+	baseURL    string
+	accountSID string
+	authToken  string
+	from       string
+}
+
+// Option is a function that configures the sender.
+type Option func(*Twilio)
+
+// WithBaseURL overrides the API base URL, e.g. to use a test server.
+func WithBaseURL(url string) Option {
+	return func(t *Twilio) {
+		t.baseURL = strings.TrimSuffix(url, "/")
+	}
+}
+
+// WithTimeout bounds the duration of a request to the API.
+func WithTimeout(d time.Duration) Option {
+	return func(t *Twilio) {
+		t.httpClient.Timeout = d
+	}
+}
+
+// NewTwilio creates a new sender authenticated with the account SID and auth token.
+// The messages are sent from the E.164 phone number, or messaging service SID, from.
+func NewTwilio(accountSID, authToken, from string, opts ...Option) *Twilio {
+	t := &Twilio{
+		httpClient: &http.Client{Timeout: defaultTimeout},
+		baseURL:    defaultBaseURL,
+		accountSID: accountSID,
+		authToken:  authToken,
+		from:       from,
+	}
+
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// Send sends the message to the E.164 phone number. The message is accepted by Twilio
+// for delivery: its delivery to the phone is not awaited.
+func (t *Twilio) Send(ctx context.Context, phone, message string) error {
+	form := url.Values{
+		"To":   {phone},
+		"Body": {message},
+	}
+
+	// Messaging service SIDs start with MG, the senders are phone numbers otherwise.
+	if strings.HasPrefix(t.from, "MG") {
+		form.Set("MessagingServiceSid", t.from)
+	} else {
+		form.Set("From", t.from)
+	}
+
+	endpoint := t.baseURL + "/2010-04-01/Accounts/" + url.PathEscape(t.accountSID) + "/Messages.json"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("could not create message request: %w", err)
+	}
+	req.SetBasicAuth(t.accountSID, t.authToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := t.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("could not send message: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		// The errors are described by a JSON body, e.g. {"code": 21211, "message": "Invalid 'To' Phone Number"}.
+		var apiErr struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		}
+
+		if err := json.NewDecoder(io.LimitReader(resp.Body, maxErrorBodySize)).Decode(&apiErr); err != nil || apiErr.Message == "" {
+			return fmt.Errorf("could not send message: unexpected status %d", resp.StatusCode)
+		}
+		return fmt.Errorf("could not send message: unexpected status %d: %s (code %d)", resp.StatusCode, apiErr.Message, apiErr.Code)
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package sms
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTwilioSend(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name         string
+		from         string
+		expectedForm map[string]string
+	}{
+		{
+			name: "from a phone number",
+			from: "+14155550100",
+			expectedForm: map[string]string{
+				"To":   "+14155550123",
+				"Body": "Your code is 123456",
+				"From": "+14155550100",
+			},
+		},
+		{
+			name: "from a messaging service",
+			from: "MG0123456789abcdef0123456789abcdef",
+			expectedForm: map[string]string{
+				"To":                  "+14155550123",
+				"Body":                "Your code is 123456",
+				"MessagingServiceSid": "MG0123456789abcdef0123456789abcdef",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Arrange
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, http.MethodPost, r.Method)
+				assert.Equal(t, "/2010-04-01/Accounts/AC123/Messages.json", r.URL.Path)
+
+				user, password, ok := r.BasicAuth()
+				assert.True(t, ok)
+				assert.Equal(t, "AC123", user)
+				assert.Equal(t, "secret", password)
+
+				require.NoError(t, r.ParseForm())
+				assert.Len(t, r.PostForm, len(tc.expectedForm))
+				for key, value := range tc.expectedForm {
+					assert.Equal(t, value, r.PostForm.Get(key), key)
+				}
+
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte(`{"sid": "SM123", "status": "queued"}`))
+			}))
+			defer server.Close()
+
+			sender := NewTwilio("AC123", "secret", tc.from, WithBaseURL(server.URL))
+
+			// Act
+			err := sender.Send(context.TODO(), "+14155550123", "Your code is 123456")
+
+			// Assert
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestTwilioSendError(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name        string
+		body        string
+		expectedErr string
+	}{
+		{
+			name:        "api error",
+			body:        `{"code": 21211, "message": "Invalid 'To' Phone Number", "status": 400}`,
+			expectedErr: "could not send message: unexpected status 400: Invalid 'To' Phone Number (code 21211)",
+		},
+		{
+			name:        "unexpected body",
+			body:        "Bad Request",
+			expectedErr: "could not send message: unexpected status 400",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Arrange
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(tc.body))
+			}))
+			defer server.Close()
+
+			sender := NewTwilio("AC123", "secret", "+14155550100", WithBaseURL(server.URL))
+
+			// Act
+			err := sender.Send(context.TODO(), "+14155550123", "Your code is 123456")
+
+			// Assert
+			assert.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
@@ -0,0 +1,59 @@
+// Package tenant carries the tenant of the requests, which scopes the users they read and
+// write, so several products can share a deployment without seeing each other's users.
+package tenant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// Default is the tenant of the requests that don't name one, and of the users stored
+// before the tenants were introduced.
+const Default string = "default"
+
+// ErrInvalid is returned for the tenant ids that don't match the expected format.
+var ErrInvalid = errors.New("invalid tenant")
+
+// pattern is the format of the tenant ids: lowercase letters, digits and dashes, so they
+// are safe in the uniqueness keys, the logs and the metric labels.
+var pattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+// Validate checks the format of the tenant id.
+func Validate(id string) error {
+	if !pattern.MatchString(id) {
+		return fmt.Errorf("%w: '%s', use up to 63 lowercase letters, digits and dashes", ErrInvalid, id)
+	}
+	return nil
+}
+
+type tenantKey struct{}
+
+// ContextWithTenant returns a copy of ctx scoped to the tenant.
+func ContextWithTenant(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, id)
+}
+
+// FromContext returns the tenant ctx is scoped to, and false when it isn't scoped to
+// any, e.g. in the background jobs working across the tenants.
+func FromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(tenantKey{}).(string)
+	return id, ok && id != ""
+}
+
+// OrDefault returns the tenant ctx is scoped to, or Default, e.g. for the users created
+// by the background jobs.
+func OrDefault(ctx context.Context) string {
+	if id, ok := FromContext(ctx); ok {
+		return id
+	}
+	return Default
+}
+
+// Visible reports whether a record of the tenant is visible to the operation scoped by ctx,
+// i.e. it belongs to the tenant of ctx or ctx isn't scoped to any.
+func Visible(ctx context.Context, id string) bool {
+	scoped, ok := FromContext(ctx)
+	return !ok || scoped == id
+}
@@ -0,0 +1,68 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name  string
+		given string
+		valid bool
+	}{
+		{name: "default", given: Default, valid: true},
+		{name: "with digits and dashes", given: "acme-2", valid: true},
+		{name: "longest", given: strings.Repeat("a", 63), valid: true},
+		{name: "empty", given: ""},
+		{name: "too long", given: strings.Repeat("a", 64)},
+		{name: "uppercase", given: "Acme"},
+		{name: "leading dash", given: "-acme"},
+		{name: "separator", given: "acme/eu"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := Validate(tc.given)
+
+			if tc.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.True(t, errors.Is(err, ErrInvalid))
+			}
+		})
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	t.Parallel()
+
+	t.Run("scoped", func(t *testing.T) {
+		ctx := ContextWithTenant(context.TODO(), "acme")
+
+		id, ok := FromContext(ctx)
+
+		assert.True(t, ok)
+		assert.Equal(t, "acme", id)
+		assert.Equal(t, "acme", OrDefault(ctx))
+		assert.True(t, Visible(ctx, "acme"))
+		assert.False(t, Visible(ctx, Default))
+	})
+
+	t.Run("not scoped", func(t *testing.T) {
+		_, ok := FromContext(context.TODO())
+
+		assert.False(t, ok)
+		assert.Equal(t, Default, OrDefault(context.TODO()))
+		assert.True(t, Visible(context.TODO(), "acme"))
+	})
+}
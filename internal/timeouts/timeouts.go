@@ -0,0 +1,33 @@
+// Package timeouts bounds the time the requests and the background jobs spend on an
+// operation, so each layer derives its deadline from the one it was given instead of
+// stacking its own.
+package timeouts
+
+import (
+	"context"
+	"time"
+)
+
+// Policy sets the deadline of the operations.
+type Policy struct {
+	// Default is the timeout of the operations whose context has no deadline, e.g. the
+	// RPCs sent without a deadline or the background jobs. Zero leaves them unbounded.
+	Default time.Duration
+
+	// Max caps the deadlines set by the callers. Zero honors them as they are.
+	Max time.Duration
+}
+
+// WithTimeout returns a copy of ctx with the deadline of the policy: the deadline of ctx,
+// capped by Max, or Default when ctx has none. It never extends the deadline of ctx, and
+// an operation called within another one keeps the deadline of the outer operation.
+func (p Policy) WithTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	deadline, ok := ctx.Deadline()
+	switch {
+	case !ok && p.Default > 0:
+		return context.WithTimeout(ctx, p.Default)
+	case ok && p.Max > 0 && time.Until(deadline) > p.Max:
+		return context.WithTimeout(ctx, p.Max)
+	}
+	return context.WithCancel(ctx)
+}
@@ -0,0 +1,107 @@
+package timeouts
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicyWithTimeout(t *testing.T) {
+	t.Parallel()
+
+	policy := Policy{Default: 5 * time.Second, Max: time.Minute}
+
+	testCases := []struct {
+		name             string
+		policy           Policy
+		callerTimeout    time.Duration
+		expectedDeadline bool
+		expectedTimeout  time.Duration
+	}{
+		{
+			name:             "default without deadline",
+			policy:           policy,
+			expectedDeadline: true,
+			expectedTimeout:  5 * time.Second,
+		},
+		{
+			name:             "unbounded without default",
+			policy:           Policy{Max: time.Minute},
+			expectedDeadline: false,
+		},
+		{
+			name:             "caller deadline honored",
+			policy:           policy,
+			callerTimeout:    30 * time.Second,
+			expectedDeadline: true,
+			expectedTimeout:  30 * time.Second,
+		},
+		{
+			name:             "caller deadline shorter than default",
+			policy:           policy,
+			callerTimeout:    time.Second,
+			expectedDeadline: true,
+			expectedTimeout:  time.Second,
+		},
+		{
+			name:             "caller deadline capped",
+			policy:           policy,
+			callerTimeout:    time.Hour,
+			expectedDeadline: true,
+			expectedTimeout:  time.Minute,
+		},
+		{
+			name:             "caller deadline uncapped",
+			policy:           Policy{Default: 5 * time.Second},
+			callerTimeout:    time.Hour,
+			expectedDeadline: true,
+			expectedTimeout:  time.Hour,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Arrange
+			ctx := context.Background()
+			if tc.callerTimeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tc.callerTimeout)
+				defer cancel()
+			}
+
+			// Act
+			ctx, cancel := tc.policy.WithTimeout(ctx)
+			defer cancel()
+
+			// Assert
+			deadline, ok := ctx.Deadline()
+			assert.Equal(t, tc.expectedDeadline, ok)
+			if tc.expectedDeadline {
+				assert.WithinDuration(t, time.Now().Add(tc.expectedTimeout), deadline, time.Second)
+			}
+		})
+	}
+
+	t.Run("nested operations keep the outer deadline", func(t *testing.T) {
+		t.Parallel()
+
+		// Arrange
+		outer, cancelOuter := Policy{Default: time.Second}.WithTimeout(context.Background())
+		defer cancelOuter()
+		outerDeadline, _ := outer.Deadline()
+
+		// Act
+		inner, cancelInner := Policy{Default: time.Minute}.WithTimeout(outer)
+		defer cancelInner()
+
+		// Assert
+		innerDeadline, ok := inner.Deadline()
+		assert.True(t, ok)
+		assert.Equal(t, outerDeadline, innerDeadline)
+	})
+}
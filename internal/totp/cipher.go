@@ -0,0 +1,62 @@
+package totp
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// KeyLength is the length of the encryption keys, in bytes (AES-256).
+const KeyLength int = 32
+
+// ErrDecrypt is returned when a secret can't be decrypted, e.g. with another key.
+var ErrDecrypt error = errors.New("could not decrypt totp secret")
+
+// Cipher encrypts the secrets at rest with AES-GCM. Each secret is bound to the id of
+// its user, so a secret copied to another user fails to decrypt.
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// NewCipher returns a cipher using the given key of KeyLength bytes.
+func NewCipher(key []byte) (*Cipher, error) {
+	if len(key) != KeyLength {
+		return nil, fmt.Errorf("totp encryption key must be %d bytes, got %d", KeyLength, len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("could not create totp cipher: %w", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("could not create totp cipher: %w", err)
+	}
+	return &Cipher{aead: aead}, nil
+}
+
+// Encrypt returns the secret of the user encrypted, prefixed with a random nonce.
+func (c *Cipher) Encrypt(userID string, secret []byte) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("could not generate nonce: %w", err)
+	}
+	return c.aead.Seal(nonce, nonce, secret, []byte(userID)), nil
+}
+
+// Decrypt returns the secret of the user encrypted by Encrypt.
+func (c *Cipher) Decrypt(userID string, encrypted []byte) ([]byte, error) {
+	if len(encrypted) < c.aead.NonceSize() {
+		return nil, ErrDecrypt
+	}
+
+	nonce, sealed := encrypted[:c.aead.NonceSize()], encrypted[c.aead.NonceSize():]
+	secret, err := c.aead.Open(nil, nonce, sealed, []byte(userID))
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return secret, nil
+}
@@ -0,0 +1,145 @@
+package totp
+
+import (
+	"bytes"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rfcSecret is the SHA-1 secret of the test vectors of RFC 6238 appendix B.
+var rfcSecret = []byte("12345678901234567890")
+
+func TestCode(t *testing.T) {
+	t.Parallel()
+
+	// The RFC vectors have 8 digits, the codes are their last 6.
+	testCases := []struct {
+		unix     int64
+		expected string
+	}{
+		{unix: 59, expected: "287082"},
+		{unix: 1111111109, expected: "081804"},
+		{unix: 1111111111, expected: "050471"},
+		{unix: 1234567890, expected: "005924"},
+		{unix: 2000000000, expected: "279037"},
+		{unix: 20000000000, expected: "353130"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.expected, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expected, Code(rfcSecret, Step(time.Unix(tc.unix, 0))))
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1111111111, 0)
+	current := Step(now)
+
+	testCases := []struct {
+		name         string
+		code         string
+		expectedStep int64
+		expectedOK   bool
+	}{
+		{name: "current step", code: Code(rfcSecret, current), expectedStep: current, expectedOK: true},
+		{name: "previous step", code: Code(rfcSecret, current-1), expectedStep: current - 1, expectedOK: true},
+		{name: "next step", code: Code(rfcSecret, current+1), expectedStep: current + 1, expectedOK: true},
+		{name: "older step", code: Code(rfcSecret, current-2), expectedOK: false},
+		{name: "wrong length", code: "12345", expectedOK: false},
+		{name: "empty", code: "", expectedOK: false},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			step, ok := Validate(rfcSecret, tc.code, now)
+
+			assert.Equal(t, tc.expectedOK, ok)
+			assert.Equal(t, tc.expectedStep, step)
+		})
+	}
+}
+
+func TestURI(t *testing.T) {
+	t.Parallel()
+
+	// Act
+	uri, err := url.Parse(URI("usrsvc", "joedoe@foo.bar", rfcSecret))
+	require.NoError(t, err)
+
+	// Assert
+	assert.Equal(t, "otpauth", uri.Scheme)
+	assert.Equal(t, "totp", uri.Host)
+	assert.Equal(t, "/usrsvc:joedoe@foo.bar", uri.Path)
+	assert.Equal(t, "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", uri.Query().Get("secret"))
+	assert.Equal(t, "usrsvc", uri.Query().Get("issuer"))
+	assert.Equal(t, "30", uri.Query().Get("period"))
+}
+
+func TestCipher(t *testing.T) {
+	t.Parallel()
+
+	key := bytes.Repeat([]byte{1}, KeyLength)
+
+	c, err := NewCipher(key)
+	require.NoError(t, err)
+
+	t.Run("round trips the secret", func(t *testing.T) {
+		t.Parallel()
+
+		encrypted, err := c.Encrypt("user-id", rfcSecret)
+		require.NoError(t, err)
+
+		decrypted, err := c.Decrypt("user-id", encrypted)
+		require.NoError(t, err)
+
+		assert.NotContains(t, string(encrypted), string(rfcSecret))
+		assert.Equal(t, rfcSecret, decrypted)
+	})
+
+	t.Run("binds the secret to the user", func(t *testing.T) {
+		t.Parallel()
+
+		encrypted, err := c.Encrypt("user-id", rfcSecret)
+		require.NoError(t, err)
+
+		_, err = c.Decrypt("other-user-id", encrypted)
+
+		assert.True(t, errors.Is(err, ErrDecrypt))
+	})
+
+	t.Run("rejects other keys", func(t *testing.T) {
+		t.Parallel()
+
+		encrypted, err := c.Encrypt("user-id", rfcSecret)
+		require.NoError(t, err)
+
+		other, err := NewCipher(bytes.Repeat([]byte{2}, KeyLength))
+		require.NoError(t, err)
+
+		_, err = other.Decrypt("user-id", encrypted)
+
+		assert.True(t, errors.Is(err, ErrDecrypt))
+	})
+
+	t.Run("rejects short keys", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := NewCipher([]byte("short"))
+
+		assert.Error(t, err)
+	})
+}
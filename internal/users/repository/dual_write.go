@@ -0,0 +1,725 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var _ Store = (*DualWrite)(nil)
+
+// DualWrite is a Store used while migrating from one storage backend or schema
+// to another without downtime. The old store remains the source of truth: every
+// write goes to the old store first and is then mirrored to the new one, and reads
+// are served from the old store. Failures on the new store never fail the request,
+// they are logged as mismatches to be fixed by a later write or by Backfill.
+//
+// With read verification enabled, every read is repeated against the new store and
+// the results compared, so we know when the new store is safe to switch to.
+type DualWrite struct {
+	logger      *zap.Logger
+	old         Store
+	new         Store
+	verifyReads bool
+	mismatches  uint64
+}
+
+// DualWriteOption is a function that configures the dual-write store.
+type DualWriteOption func(*DualWrite)
+
+// WithReadVerification enables comparing every read with the new store.
+func WithReadVerification(enabled bool) DualWriteOption {
+	return func(d *DualWrite) {
+		d.verifyReads = enabled
+	}
+}
+
+// NewDualWrite creates a store that writes to both old and new and reads from old.
+func NewDualWrite(logger *zap.Logger, old, new Store, opts ...DualWriteOption) *DualWrite {
+	d := &DualWrite{
+		logger: logger,
+		old:    old,
+		new:    new,
+	}
+
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// Mismatches returns the number of failed mirrored writes and divergent reads so far.
+func (d *DualWrite) Mismatches() uint64 {
+	return atomic.LoadUint64(&d.mismatches)
+}
+
+// Get returns a user by id from the old store.
+func (d *DualWrite) Get(ctx context.Context, id string) (*User, error) {
+	user, err := d.old.Get(ctx, id)
+	if d.verifyReads {
+		newUser, newErr := d.new.Get(ctx, id)
+		d.verifyUser("get", user, err, newUser, newErr)
+	}
+	return user, err
+}
+
+// GetByEmail returns a user by email from the old store.
+func (d *DualWrite) GetByEmail(ctx context.Context, email string) (*User, error) {
+	user, err := d.old.GetByEmail(ctx, email)
+	if d.verifyReads {
+		newUser, newErr := d.new.GetByEmail(ctx, email)
+		d.verifyUser("get_by_email", user, err, newUser, newErr)
+	}
+	return user, err
+}
+
+// List returns a page of the users selected by the filter from the old store.
+func (d *DualWrite) List(ctx context.Context, filter Filter, page Page) ([]*User, error) {
+	users, err := d.old.List(ctx, filter, page)
+	if d.verifyReads && err == nil {
+		newUsers, newErr := d.new.List(ctx, filter, page)
+		d.verifyList("list", users, newUsers, newErr)
+	}
+	return users, err
+}
+
+// Count returns the number of users selected by the filter in the old store.
+func (d *DualWrite) Count(ctx context.Context, filter Filter) (int64, error) {
+	return d.old.Count(ctx, filter)
+}
+
+// NicknameExists reports whether a user has the nickname in the old store.
+func (d *DualWrite) NicknameExists(ctx context.Context, nickname, country string) (bool, error) {
+	return d.old.NicknameExists(ctx, nickname, country)
+}
+
+// Search returns the users matching the query from the old store.
+func (d *DualWrite) Search(ctx context.Context, query string, offset, limit int) ([]*User, error) {
+	users, err := d.old.Search(ctx, query, offset, limit)
+	if d.verifyReads && err == nil {
+		newUsers, newErr := d.new.Search(ctx, query, offset, limit)
+		d.verifyList("search", users, newUsers, newErr)
+	}
+	return users, err
+}
+
+// Insert inserts the user in the old store and mirrors it to the new one.
+func (d *DualWrite) Insert(ctx context.Context, user *User) error {
+	if err := d.old.Insert(ctx, user); err != nil {
+		return err
+	}
+
+	if err := d.new.Insert(ctx, user); err != nil {
+		d.mismatch("insert", user.ID, err)
+	}
+	return nil
+}
+
+// InsertBatch inserts the users in the old store and mirrors them to the new one.
+func (d *DualWrite) InsertBatch(ctx context.Context, users []*User) error {
+	if err := d.old.InsertBatch(ctx, users); err != nil {
+		return err
+	}
+
+	if err := d.new.InsertBatch(ctx, users); err != nil {
+		for _, user := range users {
+			d.mismatch("insert_batch", user.ID, err)
+		}
+	}
+	return nil
+}
+
+// Update updates the user in the old store and mirrors it to the new one.
+// Users missing from the new store are copied over from the old store.
+func (d *DualWrite) Update(ctx context.Context, user *User) error {
+	if err := d.old.Update(ctx, user); err != nil {
+		return err
+	}
+
+	// The event sequence set by the old store is the one callers must see.
+	sequence := user.EventSequence
+	defer func() { user.EventSequence = sequence }()
+
+	err := d.new.Update(ctx, user)
+	if errors.Is(err, ErrUserNotFound) {
+		err = d.copyToNew(ctx, user.ID)
+	}
+
+	if err != nil {
+		d.mismatch("update", user.ID, err)
+	}
+	return nil
+}
+
+// Delete deletes the user from both stores and returns the event sequence from the old store.
+func (d *DualWrite) Delete(ctx context.Context, id string) (int64, error) {
+	sequence, err := d.old.Delete(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := d.new.Delete(ctx, id); err != nil && !errors.Is(err, ErrUserNotFound) {
+		d.mismatch("delete", id, err)
+	}
+	return sequence, nil
+}
+
+// Merge merges the users in the old store and mirrors the merge to the new one.
+func (d *DualWrite) Merge(ctx context.Context, survivor *User, duplicateID string) error {
+	if err := d.old.Merge(ctx, survivor, duplicateID); err != nil {
+		return err
+	}
+
+	sequence := survivor.EventSequence
+	defer func() { survivor.EventSequence = sequence }()
+
+	if err := d.new.Merge(ctx, survivor, duplicateID); err != nil {
+		d.mismatch("merge", survivor.ID, err)
+	}
+	return nil
+}
+
+// Anonymize anonymizes the user in the old store, then in the new one.
+func (d *DualWrite) Anonymize(ctx context.Context, user *User) error {
+	if err := d.old.Anonymize(ctx, user); err != nil {
+		return err
+	}
+
+	sequence := user.EventSequence
+	defer func() { user.EventSequence = sequence }()
+
+	// A user missing from the new store is copied already anonymized.
+	err := d.new.Anonymize(ctx, user)
+	if errors.Is(err, ErrUserNotFound) {
+		err = d.copyToNew(ctx, user.ID)
+	}
+
+	if err != nil {
+		d.mismatch("anonymize", user.ID, err)
+	}
+	return nil
+}
+
+// SetStatus sets the status of the user in the old store, then in the new one.
+func (d *DualWrite) SetStatus(ctx context.Context, user *User) error {
+	if err := d.old.SetStatus(ctx, user); err != nil {
+		return err
+	}
+
+	sequence := user.EventSequence
+	defer func() { user.EventSequence = sequence }()
+
+	err := d.new.SetStatus(ctx, user)
+	if errors.Is(err, ErrUserNotFound) {
+		err = d.copyToNew(ctx, user.ID)
+	}
+
+	if err != nil {
+		d.mismatch("set_status", user.ID, err)
+	}
+	return nil
+}
+
+// SetAvatar sets the avatar key and hash of the user in the old store, then in the new one.
+func (d *DualWrite) SetAvatar(ctx context.Context, user *User) error {
+	if err := d.old.SetAvatar(ctx, user); err != nil {
+		return err
+	}
+
+	sequence := user.EventSequence
+	defer func() { user.EventSequence = sequence }()
+
+	err := d.new.SetAvatar(ctx, user)
+	if errors.Is(err, ErrUserNotFound) {
+		err = d.copyToNew(ctx, user.ID)
+	}
+
+	if err != nil {
+		d.mismatch("set_avatar", user.ID, err)
+	}
+	return nil
+}
+
+// Bootstrap bootstraps the old store and mirrors the admin user and API key to the new one.
+func (d *DualWrite) Bootstrap(ctx context.Context, admin *User, key *APIKey) error {
+	if err := d.old.Bootstrap(ctx, admin, key); err != nil {
+		return err
+	}
+
+	if err := d.new.Bootstrap(ctx, admin, key); err != nil {
+		d.mismatch("bootstrap", admin.ID, err)
+	}
+	return nil
+}
+
+// InsertAPIKey inserts the API key in the old store and mirrors it to the new one.
+func (d *DualWrite) InsertAPIKey(ctx context.Context, key *APIKey) error {
+	if err := d.old.InsertAPIKey(ctx, key); err != nil {
+		return err
+	}
+
+	if err := d.new.InsertAPIKey(ctx, key); err != nil {
+		d.mismatch("insert_api_key", key.UserID, err)
+	}
+	return nil
+}
+
+// GetAPIKey reads from the old store.
+func (d *DualWrite) GetAPIKey(ctx context.Context, id string) (*APIKey, error) {
+	return d.old.GetAPIKey(ctx, id)
+}
+
+// GetAPIKeys reads from the old store.
+func (d *DualWrite) GetAPIKeys(ctx context.Context, userID string) ([]*APIKey, error) {
+	return d.old.GetAPIKeys(ctx, userID)
+}
+
+// DeleteAPIKey deletes the API key from the old store and mirrors it to the new one.
+// Keys created before the migration started are only in the old store.
+func (d *DualWrite) DeleteAPIKey(ctx context.Context, id string) error {
+	if err := d.old.DeleteAPIKey(ctx, id); err != nil {
+		return err
+	}
+
+	if err := d.new.DeleteAPIKey(ctx, id); err != nil && !errors.Is(err, ErrAPIKeyNotFound) {
+		d.mismatch("delete_api_key", id, err)
+	}
+	return nil
+}
+
+// LinkIdentity links the identity in the old store and mirrors it to the new one.
+func (d *DualWrite) LinkIdentity(ctx context.Context, identity *LinkedIdentity) error {
+	if err := d.old.LinkIdentity(ctx, identity); err != nil {
+		return err
+	}
+
+	if err := d.new.LinkIdentity(ctx, identity); err != nil {
+		d.mismatch("link_identity", identity.UserID, err)
+	}
+	return nil
+}
+
+// GetLinkedIdentities reads from the old store.
+func (d *DualWrite) GetLinkedIdentities(ctx context.Context, userID string) ([]*LinkedIdentity, error) {
+	return d.old.GetLinkedIdentities(ctx, userID)
+}
+
+// GetByLinkedIdentity reads from the old store, verifying against the new one if enabled.
+func (d *DualWrite) GetByLinkedIdentity(ctx context.Context, provider, subject string) (*User, error) {
+	user, err := d.old.GetByLinkedIdentity(ctx, provider, subject)
+	if d.verifyReads {
+		newUser, newErr := d.new.GetByLinkedIdentity(ctx, provider, subject)
+		d.verifyUser("get_by_linked_identity", user, err, newUser, newErr)
+	}
+	return user, err
+}
+
+// UnlinkIdentity unlinks the identity in the old store and mirrors it to the new one.
+func (d *DualWrite) UnlinkIdentity(ctx context.Context, userID, provider, subject string) error {
+	if err := d.old.UnlinkIdentity(ctx, userID, provider, subject); err != nil {
+		return err
+	}
+
+	if err := d.new.UnlinkIdentity(ctx, userID, provider, subject); err != nil {
+		d.mismatch("unlink_identity", userID, err)
+	}
+	return nil
+}
+
+// LockField locks the field in the old store and mirrors it to the new one.
+func (d *DualWrite) LockField(ctx context.Context, lock *FieldLock) error {
+	if err := d.old.LockField(ctx, lock); err != nil {
+		return err
+	}
+
+	if err := d.new.LockField(ctx, lock); err != nil {
+		d.mismatch("lock_field", lock.UserID, err)
+	}
+	return nil
+}
+
+// UnlockField unlocks the field in the old store and mirrors it to the new one.
+func (d *DualWrite) UnlockField(ctx context.Context, userID, field string) error {
+	if err := d.old.UnlockField(ctx, userID, field); err != nil {
+		return err
+	}
+
+	if err := d.new.UnlockField(ctx, userID, field); err != nil {
+		d.mismatch("unlock_field", userID, err)
+	}
+	return nil
+}
+
+// GetFieldLocks reads from the old store.
+func (d *DualWrite) GetFieldLocks(ctx context.Context, userID string) ([]*FieldLock, error) {
+	return d.old.GetFieldLocks(ctx, userID)
+}
+
+// RecordLogin records the login in the old store and mirrors it to the new one.
+func (d *DualWrite) RecordLogin(ctx context.Context, login *Login) error {
+	if err := d.old.RecordLogin(ctx, login); err != nil {
+		return err
+	}
+
+	if err := d.new.RecordLogin(ctx, login); err != nil {
+		d.mismatch("record_login", login.UserID, err)
+	}
+	return nil
+}
+
+// GetRecentLogins reads from the old store.
+func (d *DualWrite) GetRecentLogins(ctx context.Context, userID string, limit int) ([]*Login, error) {
+	return d.old.GetRecentLogins(ctx, userID, limit)
+}
+
+// GetLockout reads from the old store.
+func (d *DualWrite) GetLockout(ctx context.Context, userID string) (*Lockout, error) {
+	return d.old.GetLockout(ctx, userID)
+}
+
+// RecordFailedLogin records the failed login in the old store and mirrors it to the new one.
+func (d *DualWrite) RecordFailedLogin(ctx context.Context, userID string) (*Lockout, error) {
+	lockout, err := d.old.RecordFailedLogin(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := d.new.RecordFailedLogin(ctx, userID); err != nil {
+		d.mismatch("record_failed_login", userID, err)
+	}
+	return lockout, nil
+}
+
+// LockUser locks the user out in the old store and mirrors it to the new one.
+func (d *DualWrite) LockUser(ctx context.Context, userID string, until time.Time) error {
+	if err := d.old.LockUser(ctx, userID, until); err != nil {
+		return err
+	}
+
+	if err := d.new.LockUser(ctx, userID, until); err != nil {
+		d.mismatch("lock_user", userID, err)
+	}
+	return nil
+}
+
+// ResetLockout resets the lockout in the old store and mirrors it to the new one.
+func (d *DualWrite) ResetLockout(ctx context.Context, userID string) error {
+	if err := d.old.ResetLockout(ctx, userID); err != nil {
+		return err
+	}
+
+	if err := d.new.ResetLockout(ctx, userID); err != nil {
+		d.mismatch("reset_lockout", userID, err)
+	}
+	return nil
+}
+
+// InsertSession inserts the session in the old store and mirrors it to the new one.
+func (d *DualWrite) InsertSession(ctx context.Context, session *Session) error {
+	if err := d.old.InsertSession(ctx, session); err != nil {
+		return err
+	}
+
+	if err := d.new.InsertSession(ctx, session); err != nil {
+		d.mismatch("insert_session", session.UserID, err)
+	}
+	return nil
+}
+
+// GetSession reads from the old store.
+func (d *DualWrite) GetSession(ctx context.Context, id string) (*Session, error) {
+	return d.old.GetSession(ctx, id)
+}
+
+// GetSessions reads from the old store.
+func (d *DualWrite) GetSessions(ctx context.Context, userID string) ([]*Session, error) {
+	return d.old.GetSessions(ctx, userID)
+}
+
+// RotateSession rotates the session in the old store and mirrors it to the new one.
+// Sessions created before the migration started are only in the old store.
+func (d *DualWrite) RotateSession(ctx context.Context, session *Session, refreshTokenHash []byte) error {
+	if err := d.old.RotateSession(ctx, session, refreshTokenHash); err != nil {
+		return err
+	}
+
+	if err := d.new.RotateSession(ctx, session, refreshTokenHash); err != nil && !errors.Is(err, ErrSessionNotFound) {
+		d.mismatch("rotate_session", session.UserID, err)
+	}
+	return nil
+}
+
+// DeleteSession deletes the session from the old store and mirrors it to the new one.
+func (d *DualWrite) DeleteSession(ctx context.Context, id string) error {
+	if err := d.old.DeleteSession(ctx, id); err != nil {
+		return err
+	}
+
+	if err := d.new.DeleteSession(ctx, id); err != nil && !errors.Is(err, ErrSessionNotFound) {
+		d.mismatch("delete_session", id, err)
+	}
+	return nil
+}
+
+// InsertTOTP inserts the TOTP enrollment in the old store and mirrors it to the new one.
+func (d *DualWrite) InsertTOTP(ctx context.Context, totp *TOTP) error {
+	if err := d.old.InsertTOTP(ctx, totp); err != nil {
+		return err
+	}
+
+	if err := d.new.InsertTOTP(ctx, totp); err != nil {
+		d.mismatch("insert_totp", totp.UserID, err)
+	}
+	return nil
+}
+
+// GetTOTP reads from the old store.
+func (d *DualWrite) GetTOTP(ctx context.Context, userID string) (*TOTP, error) {
+	return d.old.GetTOTP(ctx, userID)
+}
+
+// UseTOTP records the use of the code in the old store and mirrors it to the new one.
+func (d *DualWrite) UseTOTP(ctx context.Context, userID string, step int64) error {
+	if err := d.old.UseTOTP(ctx, userID, step); err != nil {
+		return err
+	}
+
+	if err := d.new.UseTOTP(ctx, userID, step); err != nil {
+		d.mismatch("use_totp", userID, err)
+	}
+	return nil
+}
+
+// InsertPhoneVerification inserts the phone verification in the old store and mirrors it to the new one.
+func (d *DualWrite) InsertPhoneVerification(ctx context.Context, verification *PhoneVerification) error {
+	if err := d.old.InsertPhoneVerification(ctx, verification); err != nil {
+		return err
+	}
+
+	if err := d.new.InsertPhoneVerification(ctx, verification); err != nil {
+		d.mismatch("insert_phone_verification", verification.UserID, err)
+	}
+	return nil
+}
+
+// AttemptPhoneVerification counts the attempt in the old store and mirrors it to the new one.
+func (d *DualWrite) AttemptPhoneVerification(ctx context.Context, userID string, now time.Time) (*PhoneVerification, error) {
+	verification, err := d.old.AttemptPhoneVerification(ctx, userID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := d.new.AttemptPhoneVerification(ctx, userID, now); err != nil {
+		d.mismatch("attempt_phone_verification", userID, err)
+	}
+	return verification, nil
+}
+
+// DeletePhoneVerification deletes the phone verification in the old store and mirrors it to the new one.
+func (d *DualWrite) DeletePhoneVerification(ctx context.Context, userID string) error {
+	if err := d.old.DeletePhoneVerification(ctx, userID); err != nil {
+		return err
+	}
+
+	if err := d.new.DeletePhoneVerification(ctx, userID); err != nil {
+		d.mismatch("delete_phone_verification", userID, err)
+	}
+	return nil
+}
+
+// InsertPasswordResetToken inserts the token in the old store and mirrors it to the new one.
+func (d *DualWrite) InsertPasswordResetToken(ctx context.Context, token *PasswordResetToken) error {
+	if err := d.old.InsertPasswordResetToken(ctx, token); err != nil {
+		return err
+	}
+
+	if err := d.new.InsertPasswordResetToken(ctx, token); err != nil {
+		d.mismatch("insert_password_reset_token", token.UserID, err)
+	}
+	return nil
+}
+
+// ResetPassword resets the password in the old store and mirrors it to the new one.
+// Tokens issued before the migration started are only in the old store, so the user
+// is then mirrored as a regular update.
+func (d *DualWrite) ResetPassword(ctx context.Context, tokenHash []byte, password string, now time.Time) (*User, error) {
+	user, err := d.old.ResetPassword(ctx, tokenHash, password, now)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = d.new.ResetPassword(ctx, tokenHash, password, now)
+	if errors.Is(err, ErrResetTokenNotFound) {
+		mirrored := *user
+		err = d.new.Update(ctx, &mirrored)
+		if errors.Is(err, ErrUserNotFound) {
+			err = d.copyToNew(ctx, user.ID)
+		}
+	}
+
+	if err != nil {
+		d.mismatch("reset_password", user.ID, err)
+	}
+	return user, nil
+}
+
+// CountByCountry returns the number of users per country from the old store.
+func (d *DualWrite) CountByCountry(ctx context.Context) (map[string]int64, error) {
+	return d.old.CountByCountry(ctx)
+}
+
+// CheckDatabaseHealth checks both stores.
+func (d *DualWrite) CheckDatabaseHealth(ctx context.Context) error {
+	if err := d.old.CheckDatabaseHealth(ctx); err != nil {
+		return fmt.Errorf("could not check old store health: %w", err)
+	}
+
+	if err := d.new.CheckDatabaseHealth(ctx); err != nil {
+		return fmt.Errorf("could not check new store health: %w", err)
+	}
+	return nil
+}
+
+// Backfill copies the users missing from the new store in batches, starting after
+// the cursor (empty to start from the beginning). It returns the cursor to resume from
+// and the number of users copied; the cursor is empty once every user has been visited.
+func (d *DualWrite) Backfill(ctx context.Context, cursor string, batchSize int) (string, int, error) {
+	users, err := d.old.List(ctx, Filter{}, Page{Cursor: cursor, Limit: batchSize})
+	if err != nil {
+		return cursor, 0, fmt.Errorf("could not backfill users: %w", err)
+	}
+
+	var copied int
+	for _, user := range users {
+		_, err := d.new.Get(ctx, user.ID)
+		switch {
+		case err == nil:
+		case errors.Is(err, ErrUserNotFound):
+			if err := d.new.Insert(ctx, user); err != nil {
+				return cursor, copied, fmt.Errorf("could not backfill user '%s': %w", user.ID, err)
+			}
+			copied++
+		default:
+			return cursor, copied, fmt.Errorf("could not backfill user '%s': %w", user.ID, err)
+		}
+		cursor = user.ID
+	}
+
+	if len(users) < batchSize {
+		return "", copied, nil
+	}
+	return cursor, copied, nil
+}
+
+// copyToNew inserts the current version of the user in the old store into the new one.
+func (d *DualWrite) copyToNew(ctx context.Context, id string) error {
+	user, err := d.old.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	return d.new.Insert(ctx, user)
+}
+
+func (d *DualWrite) verifyUser(operation string, old *User, oldErr error, new *User, newErr error) {
+	switch {
+	case oldErr != nil:
+		// Nothing to compare against.
+		return
+	case newErr != nil:
+		d.mismatch(operation, old.ID, newErr)
+		return
+	}
+
+	if fields := diffUsers(old, new); len(fields) > 0 {
+		atomic.AddUint64(&d.mismatches, 1)
+		d.logger.Warn("dual-write read mismatch",
+			zap.String("operation", operation),
+			zap.String("id", old.ID),
+			zap.Strings("fields", fields),
+		)
+	}
+}
+
+func (d *DualWrite) verifyList(operation string, old, new []*User, newErr error) {
+	if newErr != nil {
+		d.mismatch(operation, "", newErr)
+		return
+	}
+
+	if len(old) != len(new) {
+		atomic.AddUint64(&d.mismatches, 1)
+		d.logger.Warn("dual-write read mismatch",
+			zap.String("operation", operation),
+			zap.Int("old_count", len(old)),
+			zap.Int("new_count", len(new)),
+		)
+		return
+	}
+
+	for i := range old {
+		if fields := diffUsers(old[i], new[i]); len(fields) > 0 {
+			atomic.AddUint64(&d.mismatches, 1)
+			d.logger.Warn("dual-write read mismatch",
+				zap.String("operation", operation),
+				zap.String("id", old[i].ID),
+				zap.Strings("fields", fields),
+			)
+		}
+	}
+}
+
+func (d *DualWrite) mismatch(operation, id string, err error) {
+	atomic.AddUint64(&d.mismatches, 1)
+	d.logger.Warn("dual-write mismatch on new store",
+		zap.String("operation", operation),
+		zap.String("id", id),
+		zap.Error(err),
+	)
+}
+
+// diffUsers returns the names of the fields that differ between a and b.
+// Password hashes are compared but never logged.
+func diffUsers(a, b *User) []string {
+	var fields []string
+	check := func(name string, equal bool) {
+		if !equal {
+			fields = append(fields, name)
+		}
+	}
+
+	check("id", a.ID == b.ID)
+	check("first_name", a.FirstName == b.FirstName)
+	check("last_name", a.LastName == b.LastName)
+	check("nickname", a.Nickname == b.Nickname)
+	check("password", a.Password == b.Password)
+	check("email", a.Email == b.Email)
+	check("country", a.Country == b.Country)
+	check("role", a.Role == b.Role)
+	check("created_at", a.CreatedAt.Equal(b.CreatedAt))
+	check("updated_at", a.UpdatedAt.Equal(b.UpdatedAt))
+	check("event_sequence", a.EventSequence == b.EventSequence)
+	check("anonymized", a.Anonymized == b.Anonymized)
+	check("status", a.Status == b.Status)
+	check("metadata", a.Metadata.equal(b.Metadata))
+	check("avatar_key", a.AvatarKey == b.AvatarKey)
+	check("avatar_hash", a.AvatarHash == b.AvatarHash)
+	check("phone", a.Phone == b.Phone)
+	check("phone_verified", a.PhoneVerified == b.PhoneVerified)
+	check("locale", a.Locale == b.Locale)
+	check("timezone", a.Timezone == b.Timezone)
+	check("birthdate", equalDates(a.Birthdate, b.Birthdate))
+	check("tenant_id", a.TenantID == b.TenantID)
+	return fields
+}
+
+// equalDates reports whether both dates are unknown or the same.
+func equalDates(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
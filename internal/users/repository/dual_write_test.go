@@ -0,0 +1,214 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestDualWriteInsert(t *testing.T) {
+	t.Parallel()
+
+	t.Run("writes to both stores", func(t *testing.T) {
+		// Arrange
+		oldStore, newStore := NewMemory(), NewMemory()
+		store := NewDualWrite(zap.NewNop(), oldStore, newStore)
+		givenUser := newMemoryUserHelper(t, "joedoe@foo.bar", "BR")
+
+		// Act
+		err := store.Insert(context.TODO(), givenUser)
+		require.NoError(t, err)
+
+		// Assert
+		oldUser, err := oldStore.Get(context.TODO(), givenUser.ID)
+		require.NoError(t, err)
+		newUser, err := newStore.Get(context.TODO(), givenUser.ID)
+		require.NoError(t, err)
+
+		assert.Equal(t, oldUser, newUser)
+		assert.Zero(t, store.Mismatches())
+	})
+
+	t.Run("old store error fails the insert", func(t *testing.T) {
+		// Arrange
+		oldStore, newStore := NewMemory(), NewMemory()
+		store := NewDualWrite(zap.NewNop(), oldStore, newStore)
+		require.NoError(t, oldStore.Insert(context.TODO(), newMemoryUserHelper(t, "joedoe@foo.bar", "BR")))
+
+		// Act
+		err := store.Insert(context.TODO(), newMemoryUserHelper(t, "joedoe@foo.bar", "US"))
+
+		// Assert
+		assert.True(t, errors.Is(err, ErrDuplicateEmail))
+		assert.Zero(t, store.Mismatches())
+	})
+
+	t.Run("new store error is only recorded", func(t *testing.T) {
+		// Arrange
+		oldStore, newStore := NewMemory(), NewMemory()
+		store := NewDualWrite(zap.NewNop(), oldStore, newStore)
+		require.NoError(t, newStore.Insert(context.TODO(), newMemoryUserHelper(t, "joedoe@foo.bar", "BR")))
+
+		// Act
+		err := store.Insert(context.TODO(), newMemoryUserHelper(t, "joedoe@foo.bar", "US"))
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, uint64(1), store.Mismatches())
+	})
+}
+
+func TestDualWriteUpdate(t *testing.T) {
+	t.Parallel()
+
+	t.Run("copies users missing from the new store", func(t *testing.T) {
+		// Arrange
+		oldStore, newStore := NewMemory(), NewMemory()
+		store := NewDualWrite(zap.NewNop(), oldStore, newStore)
+
+		givenUser := newMemoryUserHelper(t, "joedoe@foo.bar", "BR")
+		require.NoError(t, oldStore.Insert(context.TODO(), givenUser))
+
+		updatedUser := *givenUser
+		updatedUser.Country = "US"
+
+		// Act
+		err := store.Update(context.TODO(), &updatedUser)
+		require.NoError(t, err)
+
+		// Assert
+		newUser, err := newStore.Get(context.TODO(), givenUser.ID)
+		require.NoError(t, err)
+
+		assert.Equal(t, "US", newUser.Country)
+		assert.Equal(t, int64(2), updatedUser.EventSequence)
+		assert.Equal(t, int64(2), newUser.EventSequence)
+		assert.Zero(t, store.Mismatches())
+	})
+}
+
+func TestDualWriteDelete(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	oldStore, newStore := NewMemory(), NewMemory()
+	store := NewDualWrite(zap.NewNop(), oldStore, newStore)
+
+	givenUser := newMemoryUserHelper(t, "joedoe@foo.bar", "BR")
+	require.NoError(t, store.Insert(context.TODO(), givenUser))
+
+	// Act
+	sequence, err := store.Delete(context.TODO(), givenUser.ID)
+	require.NoError(t, err)
+
+	// Assert
+	assert.Equal(t, int64(2), sequence)
+
+	_, err = newStore.Get(context.TODO(), givenUser.ID)
+	assert.True(t, errors.Is(err, ErrUserNotFound))
+}
+
+func TestDualWriteReadVerification(t *testing.T) {
+	t.Parallel()
+
+	t.Run("matching reads", func(t *testing.T) {
+		// Arrange
+		store := NewDualWrite(zap.NewNop(), NewMemory(), NewMemory(), WithReadVerification(true))
+
+		givenUser := newMemoryUserHelper(t, "joedoe@foo.bar", "BR")
+		require.NoError(t, store.Insert(context.TODO(), givenUser))
+
+		// Act
+		_, err := store.Get(context.TODO(), givenUser.ID)
+		require.NoError(t, err)
+		_, err = store.List(context.TODO(), Filter{}, Page{Limit: 10})
+		require.NoError(t, err)
+
+		// Assert
+		assert.Zero(t, store.Mismatches())
+	})
+
+	t.Run("diverging reads are served from the old store", func(t *testing.T) {
+		// Arrange
+		oldStore, newStore := NewMemory(), NewMemory()
+		store := NewDualWrite(zap.NewNop(), oldStore, newStore, WithReadVerification(true))
+
+		givenUser := newMemoryUserHelper(t, "joedoe@foo.bar", "BR")
+		require.NoError(t, store.Insert(context.TODO(), givenUser))
+
+		divergedUser := *givenUser
+		divergedUser.Country = "US"
+		require.NoError(t, newStore.Update(context.TODO(), &divergedUser))
+
+		// Act
+		actualUser, err := store.Get(context.TODO(), givenUser.ID)
+		require.NoError(t, err)
+
+		// Assert
+		assert.Equal(t, "BR", actualUser.Country)
+		assert.Equal(t, uint64(1), store.Mismatches())
+	})
+
+	t.Run("disabled by default", func(t *testing.T) {
+		// Arrange
+		oldStore := NewMemory()
+		store := NewDualWrite(zap.NewNop(), oldStore, NewMemory())
+
+		givenUser := newMemoryUserHelper(t, "joedoe@foo.bar", "BR")
+		require.NoError(t, oldStore.Insert(context.TODO(), givenUser))
+
+		// Act
+		_, err := store.Get(context.TODO(), givenUser.ID)
+		require.NoError(t, err)
+
+		// Assert
+		assert.Zero(t, store.Mismatches())
+	})
+}
+
+func TestDualWriteBackfill(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	oldStore, newStore := NewMemory(), NewMemory()
+	store := NewDualWrite(zap.NewNop(), oldStore, newStore)
+
+	for _, email := range []string{"a@foo.bar", "b@foo.bar", "c@foo.bar"} {
+		require.NoError(t, oldStore.Insert(context.TODO(), newMemoryUserHelper(t, email, "BR")))
+	}
+
+	// One user was already mirrored.
+	users, err := oldStore.List(context.TODO(), Filter{}, Page{Limit: 1})
+	require.NoError(t, err)
+	require.NoError(t, newStore.Insert(context.TODO(), users[0]))
+
+	// Act
+	var (
+		cursor  string
+		copied  int
+		batches int
+	)
+	for {
+		next, n, err := store.Backfill(context.TODO(), cursor, 2)
+		require.NoError(t, err)
+
+		copied += n
+		batches++
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+
+	// Assert
+	assert.Equal(t, 2, copied)
+	assert.Equal(t, 2, batches)
+
+	newUsers, err := newStore.List(context.TODO(), Filter{}, Page{Limit: 10})
+	require.NoError(t, err)
+	assert.Len(t, newUsers, 3)
+}
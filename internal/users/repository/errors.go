@@ -5,6 +5,19 @@ import "errors"
 var (
 	// Enumerate all the errors that can be returned by the repository.
 
-	ErrDuplicateEmail error = errors.New("user already exists with given email")
-	ErrUserNotFound   error = errors.New("user not found")
+	ErrAlreadyBootstrapped       error = errors.New("an admin user already exists")
+	ErrAmbiguousEmail            error = errors.New("several users have the given email")
+	ErrAPIKeyNotFound            error = errors.New("api key not found")
+	ErrDuplicateEmail            error = errors.New("user already exists with given email")
+	ErrDuplicateNickname         error = errors.New("user already exists with given nickname")
+	ErrIdentityLinked            error = errors.New("identity already linked to a user")
+	ErrIdentityNotFound          error = errors.New("identity not linked to the user")
+	ErrPhoneVerificationNotFound error = errors.New("phone verification not found or expired")
+	ErrResetTokenNotFound        error = errors.New("password reset token not found or expired")
+	ErrSessionNotFound           error = errors.New("session not found")
+	ErrTOTPCodeUsed              error = errors.New("totp code already used")
+	ErrTOTPEnabled               error = errors.New("totp already enabled")
+	ErrTOTPNotFound              error = errors.New("totp not enrolled")
+	ErrUniquenessScope           error = errors.New("invalid uniqueness scope")
+	ErrUserNotFound              error = errors.New("user not found")
 )
@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Filter selects the users returned by List. Empty fields are ignored
+// and the other ones are combined with AND, so the zero Filter selects every user.
+type Filter struct {
+	Country string
+
+	// NicknamePrefix matches the nicknames starting with it (case sensitive).
+	NicknamePrefix string
+
+	// Email, FirstName and LastName match the whole value, ignoring case.
+	Email     string
+	FirstName string
+	LastName  string
+
+	// CreatedAfter and CreatedBefore bound the creation time, inclusive and exclusive.
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+}
+
+// Page selects a page of the users ordered by id: at most Limit users, after the
+// Cursor, the id of the last user of the previous page. The first page has no cursor.
+type Page struct {
+	Cursor string
+	Limit  int
+}
+
+// match reports whether the user is selected by the filter.
+func (f Filter) match(u *User) bool {
+	switch {
+	case f.Country != "" && u.Country != f.Country:
+		return false
+	case f.NicknamePrefix != "" && !strings.HasPrefix(u.Nickname, f.NicknamePrefix):
+		return false
+	case f.Email != "" && !strings.EqualFold(u.Email, f.Email):
+		return false
+	case f.FirstName != "" && !strings.EqualFold(u.FirstName, f.FirstName):
+		return false
+	case f.LastName != "" && !strings.EqualFold(u.LastName, f.LastName):
+		return false
+	case !f.CreatedAfter.IsZero() && u.CreatedAt.Before(f.CreatedAfter):
+		return false
+	case !f.CreatedBefore.IsZero() && !u.CreatedAt.Before(f.CreatedBefore):
+		return false
+	}
+	return true
+}
+
+// where appends the filter predicates to the query.
+func (f Filter) where(q *queryBuilder) {
+	if f.Country != "" {
+		q.where("country = ?", f.Country)
+	}
+	if f.NicknamePrefix != "" {
+		q.where(`nickname LIKE ? ESCAPE '\'`, escapeLike(f.NicknamePrefix)+"%")
+	}
+	if f.Email != "" {
+		q.where("lower(email) = lower(?)", f.Email)
+	}
+	if f.FirstName != "" {
+		q.where("lower(first_name) = lower(?)", f.FirstName)
+	}
+	if f.LastName != "" {
+		q.where("lower(last_name) = lower(?)", f.LastName)
+	}
+	if !f.CreatedAfter.IsZero() {
+		q.where("created_at >= ?", f.CreatedAfter)
+	}
+	if !f.CreatedBefore.IsZero() {
+		q.where("created_at < ?", f.CreatedBefore)
+	}
+}
+
+// queryBuilder composes a SELECT query from optional predicates.
+// Predicates use ? placeholders, numbered in order when the query is built.
+type queryBuilder struct {
+	sel        string
+	predicates []string
+	args       []any
+	suffix     string
+}
+
+func newQueryBuilder(sel string) *queryBuilder {
+	return &queryBuilder{sel: sel}
+}
+
+// where adds a predicate, combined with the previous ones with AND.
+func (q *queryBuilder) where(predicate string, args ...any) {
+	q.predicates = append(q.predicates, predicate)
+	q.args = append(q.args, args...)
+}
+
+// orderBy sets what follows the WHERE clause, e.g. ORDER BY and LIMIT.
+func (q *queryBuilder) orderBy(suffix string, args ...any) {
+	q.suffix = suffix
+	q.args = append(q.args, args...)
+}
+
+// build returns the query with numbered placeholders and its arguments.
+func (q *queryBuilder) build() (string, []any) {
+	var b strings.Builder
+	b.WriteString(q.sel)
+
+	if len(q.predicates) > 0 {
+		b.WriteString(" WHERE ")
+		b.WriteString(strings.Join(q.predicates, " AND "))
+	}
+
+	if q.suffix != "" {
+		b.WriteString(" ")
+		b.WriteString(q.suffix)
+	}
+
+	var (
+		query strings.Builder
+		n     int
+	)
+	for _, r := range b.String() {
+		if r == '?' {
+			n++
+			fmt.Fprintf(&query, "$%d", n)
+			continue
+		}
+		query.WriteRune(r)
+	}
+	return query.String(), q.args
+}
+
+// escapeLike escapes the LIKE wildcards in s, so it is matched literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestFilterWhere(t *testing.T) {
+	t.Parallel()
+
+	createdAfter := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name          string
+		givenFilter   Filter
+		expectedQuery string
+		expectedArgs  []any
+	}{
+		{
+			name:          "no filter",
+			givenFilter:   Filter{},
+			expectedQuery: "SELECT id FROM users ORDER BY id ASC LIMIT $1",
+			expectedArgs:  []any{10},
+		},
+		{
+			name:          "single filter",
+			givenFilter:   Filter{Country: "BR"},
+			expectedQuery: "SELECT id FROM users WHERE country = $1 ORDER BY id ASC LIMIT $2",
+			expectedArgs:  []any{"BR", 10},
+		},
+		{
+			name:        "combined filters",
+			givenFilter: Filter{NicknamePrefix: "jo_", Email: "joedoe@foo.bar", CreatedAfter: createdAfter},
+			expectedQuery: `SELECT id FROM users WHERE nickname LIKE $1 ESCAPE '\' AND lower(email) = lower($2) ` +
+				`AND created_at >= $3 ORDER BY id ASC LIMIT $4`,
+			expectedArgs: []any{`jo\_%`, "joedoe@foo.bar", createdAfter, 10},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Arrange
+			q := newQueryBuilder("SELECT id FROM users")
+
+			// Act
+			tc.givenFilter.where(q)
+			q.orderBy("ORDER BY id ASC LIMIT ?", 10)
+			query, args := q.build()
+
+			// Assert
+			assert.Equal(t, tc.expectedQuery, query)
+			assert.Equal(t, tc.expectedArgs, args)
+		})
+	}
+}
+
+func TestMongoFilter(t *testing.T) {
+	t.Parallel()
+
+	createdAfter := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name          string
+		givenFilter   Filter
+		expectedQuery bson.M
+	}{
+		{
+			name:          "no filter",
+			givenFilter:   Filter{},
+			expectedQuery: bson.M{},
+		},
+		{
+			name:          "single filter",
+			givenFilter:   Filter{Country: "BR"},
+			expectedQuery: bson.M{"country": "BR"},
+		},
+		{
+			name:        "combined filters",
+			givenFilter: Filter{NicknamePrefix: "jo.", Email: "joedoe@foo.bar", CreatedAfter: createdAfter},
+			expectedQuery: bson.M{
+				"nickname":   primitive.Regex{Pattern: `^jo\.`},
+				"email":      primitive.Regex{Pattern: `^joedoe@foo\.bar$`, Options: "i"},
+				"created_at": bson.M{"$gte": createdAfter},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expectedQuery, mongoFilter(tc.givenFilter))
+		})
+	}
+}
+
+func TestFilterMatch(t *testing.T) {
+	t.Parallel()
+
+	user := &User{
+		FirstName: "John",
+		LastName:  "Doe",
+		Nickname:  "johndoe",
+		Email:     "joedoe@foo.bar",
+		Country:   "BR",
+		CreatedAt: time.Date(2023, 1, 15, 0, 0, 0, 0, time.UTC),
+	}
+
+	testCases := []struct {
+		name        string
+		givenFilter Filter
+		expected    bool
+	}{
+		{name: "no filter", givenFilter: Filter{}, expected: true},
+		{name: "nickname prefix", givenFilter: Filter{NicknamePrefix: "john"}, expected: true},
+		{name: "other nickname prefix", givenFilter: Filter{NicknamePrefix: "jane"}, expected: false},
+		{name: "email ignoring case", givenFilter: Filter{Email: "JoeDoe@foo.bar"}, expected: true},
+		{name: "names ignoring case", givenFilter: Filter{FirstName: "john", LastName: "DOE"}, expected: true},
+		{
+			name: "within creation range",
+			givenFilter: Filter{
+				CreatedAfter:  time.Date(2023, 1, 15, 0, 0, 0, 0, time.UTC),
+				CreatedBefore: time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC),
+			},
+			expected: true,
+		},
+		{
+			name:        "created before the range end is exclusive",
+			givenFilter: Filter{CreatedBefore: time.Date(2023, 1, 15, 0, 0, 0, 0, time.UTC)},
+			expected:    false,
+		},
+		{name: "one filter not matching", givenFilter: Filter{Country: "BR", LastName: "Smith"}, expected: false},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expected, tc.givenFilter.match(user))
+		})
+	}
+}
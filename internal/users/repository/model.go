@@ -1,6 +1,24 @@
 package repository
 
-import "time"
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const (
+	// Enumerate the user roles.
+
+	RoleUser  string = "user"
+	RoleAdmin string = "admin"
+
+	// Enumerate the user statuses.
+
+	StatusActive      string = "active"
+	StatusSuspended   string = "suspended"
+	StatusDeactivated string = "deactivated"
+)
 
 // User defines storage model for a user.
 type User struct {
@@ -13,4 +31,229 @@ type User struct {
 	Country   string    `db:"country"`
 	CreatedAt time.Time `db:"created_at"`
 	UpdatedAt time.Time `db:"updated_at"`
+
+	// Role is set on insert (RoleUser if empty) and kept by updates.
+	Role string `db:"role"`
+
+	// Status is set on insert (StatusActive if empty) and only changed by SetStatus.
+	Status string `db:"status"`
+
+	// EventSequence is incremented by every write to the user, so the events published
+	// for the same user can be ordered by consumers. It is set by the repository.
+	EventSequence int64 `db:"event_sequence"`
+
+	// Anonymized is set by Anonymize and never cleared: the personal data of the user is
+	// gone and only the row remains, for the references to its id.
+	Anonymized bool `db:"anonymized"`
+
+	// Metadata are the key/value attributes of the user, written by Insert and Update.
+	Metadata Metadata `db:"metadata"`
+
+	// AvatarKey is the blob store key of the avatar image of the user and AvatarHash the
+	// hex SHA-256 of its content. Both are empty without avatar and only changed by
+	// SetAvatar and cleared by Anonymize.
+	AvatarKey  string `db:"avatar_key"`
+	AvatarHash string `db:"avatar_hash"`
+
+	// Phone is the E.164 phone number of the user, empty if unknown. PhoneVerified is
+	// set once the user proves they own it and is cleared when the phone changes.
+	Phone         string `db:"phone"`
+	PhoneVerified bool   `db:"phone_verified"`
+
+	// Locale is the preferred BCP 47 language tag of the user, e.g. "pt-BR", and Timezone
+	// its IANA time zone, e.g. "America/Sao_Paulo". Both are empty if unknown.
+	Locale   string `db:"locale"`
+	Timezone string `db:"timezone"`
+
+	// Birthdate is the date of birth of the user, at midnight UTC, nil if unknown.
+	Birthdate *time.Time `db:"birthdate"`
+
+	// TenantID is the tenant of the user, set on insert from the context (see tenantOf)
+	// and never changed.
+	TenantID string `db:"tenant_id"`
+}
+
+// setInsertDefaults sets the event sequence, role and status of a new user, when unset.
+func (u *User) setInsertDefaults() {
+	if u.EventSequence == 0 {
+		u.EventSequence = 1
+	}
+
+	if u.Role == "" {
+		u.Role = RoleUser
+	}
+
+	if u.Status == "" {
+		u.Status = StatusActive
+	}
+}
+
+// Metadata defines storage model for the key/value attributes of a user. It is stored
+// as a JSON object by the SQL backends and as an embedded document by Mongo.
+type Metadata map[string]string
+
+// Value implements driver.Valuer. A nil map is stored as an empty object.
+func (m Metadata) Value() (driver.Value, error) {
+	if m == nil {
+		return "{}", nil
+	}
+
+	encoded, err := json.Marshal(map[string]string(m))
+	if err != nil {
+		return nil, fmt.Errorf("could not encode metadata: %w", err)
+	}
+	return string(encoded), nil
+}
+
+// Scan implements sql.Scanner.
+func (m *Metadata) Scan(src any) error {
+	var encoded []byte
+	switch src := src.(type) {
+	case []byte:
+		encoded = src
+	case string:
+		encoded = []byte(src)
+	case nil:
+		*m = nil
+		return nil
+	default:
+		return fmt.Errorf("could not decode metadata of type %T", src)
+	}
+
+	var decoded map[string]string
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		return fmt.Errorf("could not decode metadata: %w", err)
+	}
+
+	if len(decoded) == 0 {
+		decoded = nil
+	}
+	*m = decoded
+	return nil
+}
+
+// clone returns a copy of the metadata, so the memory repository doesn't share its maps.
+func (m Metadata) clone() Metadata {
+	if m == nil {
+		return nil
+	}
+
+	cloned := make(Metadata, len(m))
+	for key, value := range m {
+		cloned[key] = value
+	}
+	return cloned
+}
+
+// equal reports whether both metadata have the same attributes. Nil and empty are equal.
+func (m Metadata) equal(other Metadata) bool {
+	if len(m) != len(other) {
+		return false
+	}
+
+	for key, value := range m {
+		if otherValue, ok := other[key]; !ok || otherValue != value {
+			return false
+		}
+	}
+	return true
+}
+
+// APIKey defines storage model for an API key. Only a hash of the key secret is stored.
+type APIKey struct {
+	ID         string `db:"id"`
+	UserID     string `db:"user_id"`
+	Name       string `db:"name"`
+	SecretHash []byte `db:"secret_hash"`
+
+	// Scopes are the scopes granted to the key, separated by spaces.
+	// The keys created before scopes existed have none.
+	Scopes    string    `db:"scopes"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// LinkedIdentity defines storage model for an external identity (e.g. a Google account)
+// linked to a user. The provider and subject pair is unique.
+type LinkedIdentity struct {
+	Provider  string    `db:"provider"`
+	Subject   string    `db:"subject"`
+	UserID    string    `db:"user_id"`
+	Email     string    `db:"email"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// FieldLock defines storage model for a field of a user locked by an admin,
+// e.g. the email frozen pending an investigation. The user and field pair is unique.
+type FieldLock struct {
+	UserID    string    `db:"user_id"`
+	Field     string    `db:"field"`
+	Reason    string    `db:"reason"`
+	LockedBy  string    `db:"locked_by"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// Login defines storage model for a successful login, kept to detect unusual logins.
+type Login struct {
+	UserID    string    `db:"user_id"`
+	IP        string    `db:"ip"`
+	Country   string    `db:"country"`
+	ASN       int64     `db:"asn"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// Lockout defines storage model for the failed logins of a user since their last
+// successful login, kept to lock the account after too many of them.
+type Lockout struct {
+	UserID string `db:"user_id"`
+
+	// FailedAttempts are the failed logins since the last login or lockout.
+	FailedAttempts int `db:"failed_attempts"`
+
+	// Lockouts are the lockouts since the last login.
+	Lockouts    int       `db:"lockouts"`
+	LockedUntil time.Time `db:"locked_until"`
+}
+
+// Session defines storage model for a login session of a user. Only hashes of its
+// tokens are stored. The session expires with its refresh token.
+type Session struct {
+	ID                   string    `db:"id"`
+	UserID               string    `db:"user_id"`
+	AccessTokenHash      []byte    `db:"access_token_hash"`
+	RefreshTokenHash     []byte    `db:"refresh_token_hash"`
+	IP                   string    `db:"ip"`
+	CreatedAt            time.Time `db:"created_at"`
+	RefreshedAt          time.Time `db:"refreshed_at"`
+	AccessTokenExpiresAt time.Time `db:"access_token_expires_at"`
+	ExpiresAt            time.Time `db:"expires_at"`
+}
+
+// TOTP defines storage model for the TOTP enrollment of a user. The secret is encrypted
+// by the service. The enrollment is pending until a first code is used.
+type TOTP struct {
+	UserID       string    `db:"user_id"`
+	Secret       []byte    `db:"secret"`
+	Enabled      bool      `db:"enabled"`
+	LastUsedStep int64     `db:"last_used_step"`
+	CreatedAt    time.Time `db:"created_at"`
+}
+
+// PhoneVerification defines storage model for the pending verification of the phone of
+// a user. Only a hash of the code sent to the phone is stored.
+type PhoneVerification struct {
+	UserID    string    `db:"user_id"`
+	Phone     string    `db:"phone"`
+	CodeHash  []byte    `db:"code_hash"`
+	Attempts  int       `db:"attempts"`
+	CreatedAt time.Time `db:"created_at"`
+	ExpiresAt time.Time `db:"expires_at"`
+}
+
+// PasswordResetToken defines storage model for a password reset token.
+// Only a hash of the token is stored.
+type PasswordResetToken struct {
+	TokenHash []byte    `db:"token_hash"`
+	UserID    string    `db:"user_id"`
+	CreatedAt time.Time `db:"created_at"`
+	ExpiresAt time.Time `db:"expires_at"`
 }
@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+)
+
+var _ Store = (*Replicated)(nil)
+
+type primaryKey struct{}
+
+// ContextWithPrimary returns a copy of ctx whose reads are served by the primary,
+// e.g. to read a user that is about to be updated, which must not be stale.
+func ContextWithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+func primaryFromContext(ctx context.Context) bool {
+	primary, _ := ctx.Value(primaryKey{}).(bool)
+	return primary
+}
+
+// Replicated is a Store serving the user reads from a read replica and everything else,
+// writes included, from the primary. Replicas lag behind the primary, so the reads
+// fall back to the primary when the replica fails, or when it does not find a user
+// that may have just been created.
+type Replicated struct {
+	Store
+	logger    *zap.Logger
+	replica   Store
+	fallbacks uint64
+}
+
+// NewReplicated creates a store writing to primary and reading users from replica.
+func NewReplicated(logger *zap.Logger, primary, replica Store) *Replicated {
+	return &Replicated{
+		Store:   primary,
+		logger:  logger,
+		replica: replica,
+	}
+}
+
+// Fallbacks returns the number of replica reads retried on the primary so far.
+func (r *Replicated) Fallbacks() uint64 {
+	return atomic.LoadUint64(&r.fallbacks)
+}
+
+// Get returns a user by id from the replica.
+func (r *Replicated) Get(ctx context.Context, id string) (*User, error) {
+	return readReplica(ctx, r, "get", true, func(store Store) (*User, error) {
+		return store.Get(ctx, id)
+	})
+}
+
+// List returns a page of the users selected by the filter from the replica.
+func (r *Replicated) List(ctx context.Context, filter Filter, page Page) ([]*User, error) {
+	return readReplica(ctx, r, "list", false, func(store Store) ([]*User, error) {
+		return store.List(ctx, filter, page)
+	})
+}
+
+// Count returns the number of users selected by the filter from the replica.
+func (r *Replicated) Count(ctx context.Context, filter Filter) (int64, error) {
+	return readReplica(ctx, r, "count", false, func(store Store) (int64, error) {
+		return store.Count(ctx, filter)
+	})
+}
+
+// NicknameExists reports whether a user has the nickname on the replica. A nickname just
+// taken may be reported available: the unique constraint of the primary still rejects it.
+func (r *Replicated) NicknameExists(ctx context.Context, nickname, country string) (bool, error) {
+	return readReplica(ctx, r, "nickname_exists", false, func(store Store) (bool, error) {
+		return store.NicknameExists(ctx, nickname, country)
+	})
+}
+
+// Search returns the users matching the query from the replica.
+func (r *Replicated) Search(ctx context.Context, query string, offset, limit int) ([]*User, error) {
+	return readReplica(ctx, r, "search", false, func(store Store) ([]*User, error) {
+		return store.Search(ctx, query, offset, limit)
+	})
+}
+
+// CountByCountry returns the number of users per country from the replica.
+func (r *Replicated) CountByCountry(ctx context.Context) (map[string]int64, error) {
+	return readReplica(ctx, r, "count_by_country", false, func(store Store) (map[string]int64, error) {
+		return store.CountByCountry(ctx)
+	})
+}
+
+// readReplica runs the read on the replica, unless the context asks for the primary,
+// and runs it again on the primary if the replica fails. With retryNotFound, a user
+// not found on the replica is looked up on the primary too.
+func readReplica[T any](ctx context.Context, r *Replicated, op string, retryNotFound bool, read func(Store) (T, error)) (T, error) {
+	if primaryFromContext(ctx) {
+		return read(r.Store)
+	}
+
+	result, err := read(r.replica)
+	if err == nil || ctx.Err() != nil {
+		return result, err
+	}
+
+	notFound := errors.Is(err, ErrUserNotFound)
+	if notFound && !retryNotFound {
+		return result, err
+	}
+
+	if !notFound {
+		r.logger.Warn("replica read failed, falling back to the primary", zap.String("op", op), zap.Error(err))
+	}
+
+	atomic.AddUint64(&r.fallbacks, 1)
+	return read(r.Store)
+}
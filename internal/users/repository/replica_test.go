@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// unavailableStore is a replica failing every page read.
+type unavailableStore struct {
+	Store
+}
+
+func (s unavailableStore) List(ctx context.Context, filter Filter, page Page) ([]*User, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestReplicatedReads(t *testing.T) {
+	t.Parallel()
+
+	t.Run("reads from the replica", func(t *testing.T) {
+		// Arrange
+		primary, replica := NewMemory(), NewMemory()
+		store := NewReplicated(zap.NewNop(), primary, replica)
+
+		onlyOnReplica := newMemoryUserHelper(t, "joedoe@foo.bar", "BR")
+		require.NoError(t, replica.Insert(context.TODO(), onlyOnReplica))
+
+		// Act
+		users, err := store.List(context.TODO(), Filter{Country: "BR"}, Page{Limit: 10})
+		require.NoError(t, err)
+
+		// Assert
+		require.Len(t, users, 1)
+		assert.Equal(t, onlyOnReplica.ID, users[0].ID)
+		assert.Zero(t, store.Fallbacks())
+	})
+
+	t.Run("writes to the primary", func(t *testing.T) {
+		// Arrange
+		primary, replica := NewMemory(), NewMemory()
+		store := NewReplicated(zap.NewNop(), primary, replica)
+		givenUser := newMemoryUserHelper(t, "joedoe@foo.bar", "BR")
+
+		// Act
+		err := store.Insert(context.TODO(), givenUser)
+		require.NoError(t, err)
+
+		// Assert
+		_, err = primary.Get(context.TODO(), givenUser.ID)
+		require.NoError(t, err)
+
+		_, err = replica.Get(context.TODO(), givenUser.ID)
+		assert.True(t, errors.Is(err, ErrUserNotFound))
+	})
+
+	t.Run("lagging replica falls back to the primary", func(t *testing.T) {
+		// Arrange
+		primary, replica := NewMemory(), NewMemory()
+		store := NewReplicated(zap.NewNop(), primary, replica)
+		givenUser := newMemoryUserHelper(t, "joedoe@foo.bar", "BR")
+		require.NoError(t, store.Insert(context.TODO(), givenUser))
+
+		// Act
+		observed, err := store.Get(context.TODO(), givenUser.ID)
+		require.NoError(t, err)
+
+		// Assert
+		assert.Equal(t, givenUser.ID, observed.ID)
+		assert.Equal(t, uint64(1), store.Fallbacks())
+	})
+
+	t.Run("failing replica falls back to the primary", func(t *testing.T) {
+		// Arrange
+		primary := NewMemory()
+		store := NewReplicated(zap.NewNop(), primary, unavailableStore{Store: NewMemory()})
+		require.NoError(t, primary.Insert(context.TODO(), newMemoryUserHelper(t, "joedoe@foo.bar", "BR")))
+
+		// Act
+		users, err := store.List(context.TODO(), Filter{}, Page{Limit: 10})
+		require.NoError(t, err)
+
+		// Assert
+		assert.Len(t, users, 1)
+		assert.Equal(t, uint64(1), store.Fallbacks())
+	})
+
+	t.Run("context asks for the primary", func(t *testing.T) {
+		// Arrange
+		primary, replica := NewMemory(), NewMemory()
+		store := NewReplicated(zap.NewNop(), primary, replica)
+
+		stale := newMemoryUserHelper(t, "joedoe@foo.bar", "BR")
+		require.NoError(t, replica.Insert(context.TODO(), stale))
+
+		fresh := *stale
+		fresh.FirstName = "Johnny"
+		require.NoError(t, primary.Insert(context.TODO(), &fresh))
+
+		// Act
+		observed, err := store.Get(ContextWithPrimary(context.TODO()), stale.ID)
+		require.NoError(t, err)
+
+		// Assert
+		assert.Equal(t, "Johnny", observed.FirstName)
+		assert.Zero(t, store.Fallbacks())
+	})
+}
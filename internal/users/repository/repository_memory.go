@@ -0,0 +1,1035 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/alesr/usrsvc/internal/tenant"
+)
+
+// Memory is an in-memory repository implementation for local development and tests.
+// It mirrors the behavior of the Postgres repository, including the ordering by id
+// used by the cursor pagination and the unique email and nickname constraints.
+type Memory struct {
+	mu    sync.RWMutex
+	users map[string]User
+
+	// mergedInto maps the id of every merged duplicate to the id of its survivor.
+	mergedInto map[string]string
+
+	apiKeys map[string]APIKey
+
+	// identities are keyed by provider and subject.
+	identities map[[2]string]LinkedIdentity
+
+	// fieldLocks are keyed by user id and field.
+	fieldLocks map[[2]string]FieldLock
+
+	logins []Login
+
+	// lockouts are keyed by user id.
+	lockouts map[string]Lockout
+
+	// resetTokens are keyed by token hash.
+	resetTokens map[string]PasswordResetToken
+
+	// sessions are keyed by id.
+	sessions map[string]Session
+
+	// totps are keyed by user id.
+	totps map[string]TOTP
+
+	// phoneVerifications are keyed by user id.
+	phoneVerifications map[string]PhoneVerification
+
+	scope UniquenessScope
+}
+
+// MemoryOption is a function that configures the in-memory repository.
+type MemoryOption func(*Memory)
+
+// WithMemoryUniquenessScope configures the scope of the email and nickname uniqueness.
+// It defaults to ScopeGlobal.
+func WithMemoryUniquenessScope(scope UniquenessScope) MemoryOption {
+	return func(m *Memory) {
+		m.scope = scope
+	}
+}
+
+// NewMemory creates a new empty in-memory repository.
+func NewMemory(opts ...MemoryOption) *Memory {
+	m := &Memory{
+		users:      make(map[string]User),
+		mergedInto: make(map[string]string),
+		apiKeys:    make(map[string]APIKey),
+		identities: make(map[[2]string]LinkedIdentity),
+		fieldLocks: make(map[[2]string]FieldLock),
+		lockouts:   make(map[string]Lockout),
+
+		resetTokens: make(map[string]PasswordResetToken),
+		sessions:    make(map[string]Session),
+		totps:       make(map[string]TOTP),
+
+		phoneVerifications: make(map[string]PhoneVerification),
+
+		scope: ScopeGlobal,
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Get returns a user by id.
+func (m *Memory) Get(ctx context.Context, id string) (*User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	user, ok := m.get(ctx, id)
+	if !ok {
+		return nil, fmt.Errorf("could not get user: %w", ErrUserNotFound)
+	}
+	return &user, nil
+}
+
+// GetByEmail returns a user by email, like the Postgres repository.
+func (m *Memory) GetByEmail(ctx context.Context, email string) (*User, error) {
+	user, err := oneByEmail(m.list(ctx, "", 2, func(u *User) bool { return u.Email == email }))
+	if err != nil {
+		return nil, fmt.Errorf("could not get user by email: %w", err)
+	}
+	return user, nil
+}
+
+// List returns a page of the users selected by the filter.
+func (m *Memory) List(ctx context.Context, filter Filter, page Page) ([]*User, error) {
+	return m.list(ctx, page.Cursor, page.Limit, filter.match), nil
+}
+
+// Count returns the number of users selected by the filter.
+func (m *Memory) Count(ctx context.Context, filter Filter) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var count int64
+	for _, user := range m.users {
+		if inTenant(ctx, &user) && filter.match(&user) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// NicknameExists reports whether a user has the nickname within the uniqueness scope of the country.
+func (m *Memory) NicknameExists(ctx context.Context, nickname, country string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	key := m.scope.key(&User{Country: country, TenantID: tenant.OrDefault(ctx)})
+	for _, user := range m.users {
+		if user.Nickname == nickname && m.scope.key(&user) == key {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Search returns the users with names or nickname containing words starting with every
+// word of the query, ordered by id. The page starts at offset.
+func (m *Memory) Search(ctx context.Context, query string, offset, limit int) ([]*User, error) {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	users := m.list(ctx, "", offset+limit, func(u *User) bool { return matchSearch(u, terms) })
+	if offset >= len(users) {
+		return nil, nil
+	}
+	return users[offset:], nil
+}
+
+// Insert inserts a new user.
+func (m *Memory) Insert(ctx context.Context, user *User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[user.ID]; ok {
+		return fmt.Errorf("could not insert user: %w", ErrDuplicateEmail)
+	}
+
+	if err := m.insert(ctx, user); err != nil {
+		return fmt.Errorf("could not insert user: %w", err)
+	}
+	return nil
+}
+
+// InsertBatch inserts the users: either all of them are inserted or none is.
+func (m *Memory) InsertBatch(ctx context.Context, users []*User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, user := range users {
+		err := ErrDuplicateEmail
+		if _, ok := m.users[user.ID]; !ok {
+			err = m.insert(ctx, user)
+		}
+
+		if err != nil {
+			for _, inserted := range users[:i] {
+				delete(m.users, inserted.ID)
+			}
+			return fmt.Errorf("could not insert users: %w", err)
+		}
+	}
+	return nil
+}
+
+// Update updates a user by id.
+func (m *Memory) Update(ctx context.Context, user *User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.get(ctx, user.ID)
+	if !ok {
+		return fmt.Errorf("could not update user: %w", ErrUserNotFound)
+	}
+
+	user.TenantID = stored.TenantID
+
+	if err := m.checkUnique(user); err != nil {
+		return fmt.Errorf("could not update user: %w", err)
+	}
+
+	m.users[user.ID] = m.updated(stored, user)
+	return nil
+}
+
+// Delete deletes a user by id and returns the event sequence of the deletion.
+func (m *Memory) Delete(ctx context.Context, id string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.get(ctx, id)
+	if !ok {
+		return 0, fmt.Errorf("could not delete user: %w", ErrUserNotFound)
+	}
+
+	delete(m.users, id)
+
+	// Like the Postgres foreign keys, deleting a user deletes the records referencing it.
+	m.deleteReferences(id)
+	return stored.EventSequence + 1, nil
+}
+
+// Merge merges the duplicate user into the survivor, leaving a tombstone for the duplicate.
+func (m *Memory) Merge(ctx context.Context, survivor *User, duplicateID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.get(ctx, duplicateID); !ok {
+		return fmt.Errorf("could not tombstone duplicate user: %w", ErrUserNotFound)
+	}
+
+	stored, ok := m.get(ctx, survivor.ID)
+	if !ok {
+		return fmt.Errorf("could not update surviving user: %w", ErrUserNotFound)
+	}
+
+	survivor.TenantID = stored.TenantID
+
+	// Check uniqueness as if the duplicate was already gone, like the Postgres transaction does.
+	duplicate := m.users[duplicateID]
+	delete(m.users, duplicateID)
+
+	if err := m.checkUnique(survivor); err != nil {
+		m.users[duplicateID] = duplicate
+		return fmt.Errorf("could not update surviving user: %w", err)
+	}
+
+	m.users[survivor.ID] = m.updated(stored, survivor)
+	m.mergedInto[duplicateID] = survivor.ID
+
+	// The failed logins, the TOTP, the phone verification and the sessions of the
+	// duplicate are deleted with it.
+	delete(m.lockouts, duplicateID)
+	delete(m.totps, duplicateID)
+	delete(m.phoneVerifications, duplicateID)
+
+	for k, session := range m.sessions {
+		if session.UserID == duplicateID {
+			delete(m.sessions, k)
+		}
+	}
+
+	for id, key := range m.apiKeys {
+		if key.UserID == duplicateID {
+			key.UserID = survivor.ID
+			m.apiKeys[id] = key
+		}
+	}
+
+	for k, identity := range m.identities {
+		if identity.UserID == duplicateID {
+			identity.UserID = survivor.ID
+			m.identities[k] = identity
+		}
+	}
+
+	for i := range m.logins {
+		if m.logins[i].UserID == duplicateID {
+			m.logins[i].UserID = survivor.ID
+		}
+	}
+
+	for k, lock := range m.fieldLocks {
+		if lock.UserID != duplicateID {
+			continue
+		}
+
+		delete(m.fieldLocks, k)
+
+		survivorKey := [2]string{survivor.ID, lock.Field}
+		if _, ok := m.fieldLocks[survivorKey]; !ok {
+			lock.UserID = survivor.ID
+			m.fieldLocks[survivorKey] = lock
+		}
+	}
+	return nil
+}
+
+// Anonymize overwrites the user with the given values, marks it anonymized and deletes the
+// records referencing it, like Postgres.Anonymize.
+func (m *Memory) Anonymize(ctx context.Context, user *User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.get(ctx, user.ID)
+	if !ok {
+		return fmt.Errorf("could not anonymize user: %w", ErrUserNotFound)
+	}
+
+	user.TenantID = stored.TenantID
+
+	if err := m.checkUnique(user); err != nil {
+		return fmt.Errorf("could not anonymize user: %w", err)
+	}
+
+	anonymized := m.updated(stored, user)
+	anonymized.Anonymized = true
+	anonymized.AvatarKey = ""
+	anonymized.AvatarHash = ""
+	m.users[user.ID] = anonymized
+
+	m.deleteReferences(user.ID)
+	user.Anonymized = true
+	user.AvatarKey = ""
+	user.AvatarHash = ""
+	return nil
+}
+
+// SetStatus sets the status of the user, increments its event sequence and sets it on the user.
+func (m *Memory) SetStatus(ctx context.Context, user *User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.get(ctx, user.ID)
+	if !ok {
+		return fmt.Errorf("could not set user status: %w", ErrUserNotFound)
+	}
+
+	stored.Status = user.Status
+	stored.UpdatedAt = user.UpdatedAt
+	stored.EventSequence++
+	m.users[user.ID] = stored
+
+	user.EventSequence = stored.EventSequence
+	return nil
+}
+
+// SetAvatar sets the avatar key and hash of the user, increments its event sequence and sets it on the user.
+func (m *Memory) SetAvatar(ctx context.Context, user *User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.get(ctx, user.ID)
+	if !ok {
+		return fmt.Errorf("could not set user avatar: %w", ErrUserNotFound)
+	}
+
+	stored.AvatarKey = user.AvatarKey
+	stored.AvatarHash = user.AvatarHash
+	stored.UpdatedAt = user.UpdatedAt
+	stored.EventSequence++
+	m.users[user.ID] = stored
+
+	user.EventSequence = stored.EventSequence
+	return nil
+}
+
+// Bootstrap inserts the first admin user and its API key, unless an admin user already exists.
+func (m *Memory) Bootstrap(ctx context.Context, admin *User, key *APIKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Every tenant is bootstrapped on its own.
+	for _, user := range m.users {
+		if user.Role == RoleAdmin && user.TenantID == tenant.OrDefault(ctx) {
+			return fmt.Errorf("could not bootstrap: %w", ErrAlreadyBootstrapped)
+		}
+	}
+
+	if _, ok := m.users[admin.ID]; ok {
+		return fmt.Errorf("could not insert admin user: %w", ErrDuplicateEmail)
+	}
+
+	admin.Role = RoleAdmin
+	if err := m.insert(ctx, admin); err != nil {
+		return fmt.Errorf("could not insert admin user: %w", err)
+	}
+
+	m.apiKeys[key.ID] = *key
+	return nil
+}
+
+// InsertAPIKey inserts an API key.
+func (m *Memory) InsertAPIKey(ctx context.Context, key *APIKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[key.UserID]; !ok {
+		return fmt.Errorf("could not insert api key: %w", ErrUserNotFound)
+	}
+
+	m.apiKeys[key.ID] = *key
+	return nil
+}
+
+// GetAPIKey returns an API key by id.
+func (m *Memory) GetAPIKey(ctx context.Context, id string) (*APIKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	key, ok := m.apiKeys[id]
+	if !ok {
+		return nil, fmt.Errorf("could not get api key: %w", ErrAPIKeyNotFound)
+	}
+	return &key, nil
+}
+
+// GetAPIKeys returns the API keys of a user, oldest first.
+func (m *Memory) GetAPIKeys(ctx context.Context, userID string) ([]*APIKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var keys []*APIKey
+	for _, key := range m.apiKeys {
+		if key.UserID == userID {
+			key := key
+			keys = append(keys, &key)
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].CreatedAt.Equal(keys[j].CreatedAt) {
+			return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+		}
+		return keys[i].ID < keys[j].ID
+	})
+	return keys, nil
+}
+
+// DeleteAPIKey deletes an API key by id.
+func (m *Memory) DeleteAPIKey(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.apiKeys[id]; !ok {
+		return fmt.Errorf("could not delete api key: %w", ErrAPIKeyNotFound)
+	}
+
+	delete(m.apiKeys, id)
+	return nil
+}
+
+// LinkIdentity links an external identity to a user.
+func (m *Memory) LinkIdentity(ctx context.Context, identity *LinkedIdentity) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[identity.UserID]; !ok {
+		return fmt.Errorf("could not link identity: %w", ErrUserNotFound)
+	}
+
+	k := [2]string{identity.Provider, identity.Subject}
+	if _, ok := m.identities[k]; ok {
+		return fmt.Errorf("could not link identity: %w", ErrIdentityLinked)
+	}
+
+	m.identities[k] = *identity
+	return nil
+}
+
+// GetLinkedIdentities returns the external identities linked to a user, oldest first.
+func (m *Memory) GetLinkedIdentities(ctx context.Context, userID string) ([]*LinkedIdentity, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var identities []*LinkedIdentity
+	for _, identity := range m.identities {
+		identity := identity
+		if identity.UserID == userID {
+			identities = append(identities, &identity)
+		}
+	}
+
+	sort.Slice(identities, func(i, j int) bool {
+		if !identities[i].CreatedAt.Equal(identities[j].CreatedAt) {
+			return identities[i].CreatedAt.Before(identities[j].CreatedAt)
+		}
+		return identities[i].Provider < identities[j].Provider
+	})
+	return identities, nil
+}
+
+// UnlinkIdentity unlinks an external identity from a user.
+func (m *Memory) UnlinkIdentity(ctx context.Context, userID, provider, subject string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	k := [2]string{provider, subject}
+	if identity, ok := m.identities[k]; !ok || identity.UserID != userID {
+		return fmt.Errorf("could not unlink identity: %w", ErrIdentityNotFound)
+	}
+
+	delete(m.identities, k)
+	return nil
+}
+
+// LockField locks a field of a user, replacing the reason of an existing lock.
+func (m *Memory) LockField(ctx context.Context, lock *FieldLock) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[lock.UserID]; !ok {
+		return fmt.Errorf("could not lock field: %w", ErrUserNotFound)
+	}
+
+	m.fieldLocks[[2]string{lock.UserID, lock.Field}] = *lock
+	return nil
+}
+
+// UnlockField unlocks a field of a user. Unlocking a field that is not locked is a no-op.
+func (m *Memory) UnlockField(ctx context.Context, userID, field string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.fieldLocks, [2]string{userID, field})
+	return nil
+}
+
+// GetFieldLocks returns the locked fields of a user, ordered by field.
+func (m *Memory) GetFieldLocks(ctx context.Context, userID string) ([]*FieldLock, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var locks []*FieldLock
+	for _, lock := range m.fieldLocks {
+		if lock.UserID == userID {
+			lock := lock
+			locks = append(locks, &lock)
+		}
+	}
+
+	sort.Slice(locks, func(i, j int) bool {
+		return locks[i].Field < locks[j].Field
+	})
+	return locks, nil
+}
+
+// GetByLinkedIdentity returns the user linked to the given external identity.
+func (m *Memory) GetByLinkedIdentity(ctx context.Context, provider, subject string) (*User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	identity, ok := m.identities[[2]string{provider, subject}]
+	if !ok {
+		return nil, fmt.Errorf("could not get user by linked identity: %w", ErrUserNotFound)
+	}
+
+	user, ok := m.get(ctx, identity.UserID)
+	if !ok {
+		return nil, fmt.Errorf("could not get user by linked identity: %w", ErrUserNotFound)
+	}
+	return &user, nil
+}
+
+// RecordLogin records a successful login.
+func (m *Memory) RecordLogin(ctx context.Context, login *Login) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[login.UserID]; !ok {
+		return fmt.Errorf("could not record login: %w", ErrUserNotFound)
+	}
+
+	m.logins = append(m.logins, *login)
+	return nil
+}
+
+// GetRecentLogins returns the last logins of a user, most recent first.
+func (m *Memory) GetRecentLogins(ctx context.Context, userID string, limit int) ([]*Login, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var logins []*Login
+	for _, login := range m.logins {
+		login := login
+		if login.UserID == userID {
+			logins = append(logins, &login)
+		}
+	}
+
+	sort.SliceStable(logins, func(i, j int) bool {
+		return logins[i].CreatedAt.After(logins[j].CreatedAt)
+	})
+
+	if len(logins) > limit {
+		logins = logins[:limit]
+	}
+	return logins, nil
+}
+
+// GetLockout returns the failed logins of a user. A user without failed logins
+// gets a lockout with no attempts.
+func (m *Memory) GetLockout(ctx context.Context, userID string) (*Lockout, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	lockout, ok := m.lockouts[userID]
+	if !ok {
+		return &Lockout{UserID: userID}, nil
+	}
+	return &lockout, nil
+}
+
+// RecordFailedLogin counts a failed login of a user and returns the updated lockout.
+func (m *Memory) RecordFailedLogin(ctx context.Context, userID string) (*Lockout, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[userID]; !ok {
+		return nil, fmt.Errorf("could not record failed login: %w", ErrUserNotFound)
+	}
+
+	lockout := m.lockouts[userID]
+	lockout.UserID = userID
+	lockout.FailedAttempts++
+	m.lockouts[userID] = lockout
+	return &lockout, nil
+}
+
+// LockUser locks a user out until the given time, starting over the count of failed logins.
+func (m *Memory) LockUser(ctx context.Context, userID string, until time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	lockout, ok := m.lockouts[userID]
+	if !ok {
+		return nil
+	}
+
+	lockout.FailedAttempts = 0
+	lockout.Lockouts++
+	lockout.LockedUntil = until
+	m.lockouts[userID] = lockout
+	return nil
+}
+
+// ResetLockout forgets the failed logins and the lockouts of a user, unlocking it.
+func (m *Memory) ResetLockout(ctx context.Context, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.lockouts, userID)
+	return nil
+}
+
+// InsertSession inserts a session. The expired sessions of the user are deleted on the way.
+func (m *Memory) InsertSession(ctx context.Context, session *Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[session.UserID]; !ok {
+		return fmt.Errorf("could not insert session: %w", ErrUserNotFound)
+	}
+
+	for k, s := range m.sessions {
+		if s.UserID == session.UserID && !s.ExpiresAt.After(session.CreatedAt) {
+			delete(m.sessions, k)
+		}
+	}
+
+	m.sessions[session.ID] = *session
+	return nil
+}
+
+// GetSession returns a session by id.
+func (m *Memory) GetSession(ctx context.Context, id string) (*Session, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	session, ok := m.sessions[id]
+	if !ok {
+		return nil, fmt.Errorf("could not get session: %w", ErrSessionNotFound)
+	}
+	return &session, nil
+}
+
+// GetSessions returns the sessions of a user, most recent first.
+func (m *Memory) GetSessions(ctx context.Context, userID string) ([]*Session, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var sessions []*Session
+	for _, session := range m.sessions {
+		if session.UserID == userID {
+			session := session
+			sessions = append(sessions, &session)
+		}
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].CreatedAt.After(sessions[j].CreatedAt)
+	})
+	return sessions, nil
+}
+
+// RotateSession replaces the tokens and the expiry of the session, given the hash of its
+// current refresh token. It fails with ErrSessionNotFound if the refresh token was rotated
+// meanwhile, so a refresh token can only be used once.
+func (m *Memory) RotateSession(ctx context.Context, session *Session, refreshTokenHash []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.sessions[session.ID]
+	if !ok || string(stored.RefreshTokenHash) != string(refreshTokenHash) {
+		return fmt.Errorf("could not rotate session: %w", ErrSessionNotFound)
+	}
+
+	stored.AccessTokenHash = session.AccessTokenHash
+	stored.RefreshTokenHash = session.RefreshTokenHash
+	stored.RefreshedAt = session.RefreshedAt
+	stored.AccessTokenExpiresAt = session.AccessTokenExpiresAt
+	stored.ExpiresAt = session.ExpiresAt
+	m.sessions[session.ID] = stored
+	return nil
+}
+
+// DeleteSession deletes a session by id.
+func (m *Memory) DeleteSession(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.sessions[id]; !ok {
+		return fmt.Errorf("could not delete session: %w", ErrSessionNotFound)
+	}
+
+	delete(m.sessions, id)
+	return nil
+}
+
+// InsertTOTP inserts the pending TOTP enrollment of a user, replacing a pending one.
+// It fails with ErrTOTPEnabled if the user already enabled TOTP.
+func (m *Memory) InsertTOTP(ctx context.Context, totp *TOTP) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[totp.UserID]; !ok {
+		return fmt.Errorf("could not insert totp: %w", ErrUserNotFound)
+	}
+
+	if stored, ok := m.totps[totp.UserID]; ok && stored.Enabled {
+		return fmt.Errorf("could not insert totp: %w", ErrTOTPEnabled)
+	}
+
+	m.totps[totp.UserID] = *totp
+	return nil
+}
+
+// GetTOTP returns the TOTP enrollment of a user.
+func (m *Memory) GetTOTP(ctx context.Context, userID string) (*TOTP, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	totp, ok := m.totps[userID]
+	if !ok {
+		return nil, fmt.Errorf("could not get totp: %w", ErrTOTPNotFound)
+	}
+	return &totp, nil
+}
+
+// UseTOTP records the use of a code of the time step, enabling TOTP on first use. It fails
+// with ErrTOTPCodeUsed if a code of that step or a later one was used, so codes can't be replayed.
+func (m *Memory) UseTOTP(ctx context.Context, userID string, step int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	totp, ok := m.totps[userID]
+	if !ok || totp.LastUsedStep >= step {
+		return fmt.Errorf("could not use totp: %w", ErrTOTPCodeUsed)
+	}
+
+	totp.Enabled = true
+	totp.LastUsedStep = step
+	m.totps[userID] = totp
+	return nil
+}
+
+// InsertPhoneVerification inserts the phone verification of a user, replacing a pending one.
+func (m *Memory) InsertPhoneVerification(ctx context.Context, verification *PhoneVerification) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[verification.UserID]; !ok {
+		return fmt.Errorf("could not insert phone verification: %w", ErrUserNotFound)
+	}
+
+	stored := *verification
+	stored.CodeHash = append([]byte(nil), verification.CodeHash...)
+	m.phoneVerifications[verification.UserID] = stored
+	return nil
+}
+
+// AttemptPhoneVerification counts an attempt to verify the phone of a user and returns the
+// phone verification, attempt included, if it has not expired by now.
+func (m *Memory) AttemptPhoneVerification(ctx context.Context, userID string, now time.Time) (*PhoneVerification, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	verification, ok := m.phoneVerifications[userID]
+	if !ok || !verification.ExpiresAt.After(now) {
+		return nil, fmt.Errorf("could not attempt phone verification: %w", ErrPhoneVerificationNotFound)
+	}
+
+	verification.Attempts++
+	m.phoneVerifications[userID] = verification
+	return &verification, nil
+}
+
+// DeletePhoneVerification deletes the phone verification of a user, if any.
+func (m *Memory) DeletePhoneVerification(ctx context.Context, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.phoneVerifications, userID)
+	return nil
+}
+
+// InsertPasswordResetToken inserts a password reset token.
+func (m *Memory) InsertPasswordResetToken(ctx context.Context, token *PasswordResetToken) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[token.UserID]; !ok {
+		return fmt.Errorf("could not insert password reset token: %w", ErrUserNotFound)
+	}
+
+	for k, t := range m.resetTokens {
+		if t.UserID == token.UserID && !t.ExpiresAt.After(token.CreatedAt) {
+			delete(m.resetTokens, k)
+		}
+	}
+
+	m.resetTokens[string(token.TokenHash)] = *token
+	return nil
+}
+
+// ResetPassword consumes the password reset token, if it has not expired by now,
+// sets the password of its user and deletes the other reset tokens of the user.
+func (m *Memory) ResetPassword(ctx context.Context, tokenHash []byte, password string, now time.Time) (*User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	token, ok := m.resetTokens[string(tokenHash)]
+	if !ok || !token.ExpiresAt.After(now) {
+		return nil, fmt.Errorf("could not reset password: %w", ErrResetTokenNotFound)
+	}
+
+	// The token of a user of another tenant is unknown to this one.
+	stored, ok := m.get(ctx, token.UserID)
+	if !ok {
+		return nil, fmt.Errorf("could not reset password: %w", ErrResetTokenNotFound)
+	}
+
+	stored.Password = password
+	stored.UpdatedAt = now
+	stored.EventSequence++
+	m.users[stored.ID] = stored
+
+	for k, t := range m.resetTokens {
+		if t.UserID == stored.ID {
+			delete(m.resetTokens, k)
+		}
+	}
+	return &stored, nil
+}
+
+// CountByCountry returns the number of users per country.
+func (m *Memory) CountByCountry(ctx context.Context) (map[string]int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	counts := make(map[string]int64)
+	for _, user := range m.users {
+		if inTenant(ctx, &user) {
+			counts[user.Country]++
+		}
+	}
+	return counts, nil
+}
+
+// CheckDatabaseHealth always succeeds.
+func (m *Memory) CheckDatabaseHealth(ctx context.Context) error {
+	return nil
+}
+
+// insert inserts the user with the same defaults as the Postgres repository, in the
+// tenant of ctx unless it has one. Must be called with the lock held.
+func (m *Memory) insert(ctx context.Context, user *User) error {
+	if user.TenantID == "" {
+		user.TenantID = tenant.OrDefault(ctx)
+	}
+
+	if err := m.checkUnique(user); err != nil {
+		return err
+	}
+
+	user.setInsertDefaults()
+
+	stored := *user
+	stored.Metadata = user.Metadata.clone()
+	m.users[user.ID] = stored
+	return nil
+}
+
+// get returns the user with the id, unless it isn't visible to the operation scoped by ctx.
+// Must be called with the lock held.
+func (m *Memory) get(ctx context.Context, id string) (User, bool) {
+	user, ok := m.users[id]
+	if !ok || !inTenant(ctx, &user) {
+		return User{}, false
+	}
+	return user, true
+}
+
+// deleteReferences deletes the records referencing the user, but not the user itself.
+// Must be called with the lock held.
+func (m *Memory) deleteReferences(userID string) {
+	for keyID, key := range m.apiKeys {
+		if key.UserID == userID {
+			delete(m.apiKeys, keyID)
+		}
+	}
+
+	for k, identity := range m.identities {
+		if identity.UserID == userID {
+			delete(m.identities, k)
+		}
+	}
+
+	for k, lock := range m.fieldLocks {
+		if lock.UserID == userID {
+			delete(m.fieldLocks, k)
+		}
+	}
+
+	for k, token := range m.resetTokens {
+		if token.UserID == userID {
+			delete(m.resetTokens, k)
+		}
+	}
+
+	for k, session := range m.sessions {
+		if session.UserID == userID {
+			delete(m.sessions, k)
+		}
+	}
+
+	delete(m.lockouts, userID)
+	delete(m.totps, userID)
+	delete(m.phoneVerifications, userID)
+
+	logins := m.logins[:0]
+	for _, login := range m.logins {
+		if login.UserID != userID {
+			logins = append(logins, login)
+		}
+	}
+	m.logins = logins
+}
+
+// updated returns the stored user updated with the given one, and sets the incremented
+// event sequence on the given user. Like the Postgres UPDATE, the creation time, the
+// role, the status, the avatar and the anonymization are never overwritten. Must be called with the lock held.
+func (m *Memory) updated(stored User, user *User) User {
+	user.EventSequence = stored.EventSequence + 1
+
+	updated := *user
+	updated.Metadata = user.Metadata.clone()
+	updated.CreatedAt = stored.CreatedAt
+	updated.Role = stored.Role
+	updated.Status = stored.Status
+	updated.AvatarKey = stored.AvatarKey
+	updated.AvatarHash = stored.AvatarHash
+	updated.Anonymized = stored.Anonymized
+	return updated
+}
+
+// list returns up to limit users of the tenant of ctx matching the filter, ordered by id
+// and after the cursor.
+func (m *Memory) list(ctx context.Context, cursor string, limit int, match func(*User) bool) []*User {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var users []*User
+	for _, user := range m.users {
+		user := user
+		if user.ID > cursor && inTenant(ctx, &user) && match(&user) {
+			users = append(users, &user)
+		}
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].ID < users[j].ID
+	})
+
+	if len(users) > limit {
+		users = users[:limit]
+	}
+	return users
+}
+
+// checkUnique returns ErrDuplicateEmail or ErrDuplicateNickname if another user with the
+// same uniqueness key already uses the user's email or nickname. Must be called with the
+// lock held.
+func (m *Memory) checkUnique(user *User) error {
+	key := m.scope.key(user)
+	for _, other := range m.users {
+		other := other
+		if other.ID == user.ID || m.scope.key(&other) != key {
+			continue
+		}
+		if other.Email == user.Email {
+			return ErrDuplicateEmail
+		}
+		if other.Nickname == user.Nickname {
+			return ErrDuplicateNickname
+		}
+	}
+	return nil
+}
@@ -0,0 +1,1238 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alesr/usrsvc/internal/tenant"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newMemoryUserHelper(t *testing.T, email, country string) *User {
+	t.Helper()
+
+	return &User{
+		ID:        uuid.New().String(),
+		FirstName: "John",
+		LastName:  "Doe",
+		Nickname:  strings.Split(email, "@")[0],
+		Password:  "password",
+		Email:     email,
+		Country:   country,
+		CreatedAt: time.Time{}.Add(1 * time.Second),
+		UpdatedAt: time.Time{}.Add(2 * time.Second),
+	}
+}
+
+func TestMemoryGet(t *testing.T) {
+	t.Parallel()
+
+	t.Run("happy case", func(t *testing.T) {
+		// Arrange
+		repo := NewMemory()
+		givenUser := newMemoryUserHelper(t, "joedoe@foo.bar", "BR")
+		require.NoError(t, repo.Insert(context.TODO(), givenUser))
+
+		// Act
+		actualUser, err := repo.Get(context.TODO(), givenUser.ID)
+		require.NoError(t, err)
+
+		// Assert
+		assert.Equal(t, givenUser, actualUser)
+
+		// Changing the returned user must not change the stored one.
+		actualUser.FirstName = "Jane"
+		storedUser, err := repo.Get(context.TODO(), givenUser.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "John", storedUser.FirstName)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		// Arrange
+		repo := NewMemory()
+
+		// Act
+		actualUser, err := repo.Get(context.TODO(), uuid.New().String())
+
+		// Assert
+		assert.True(t, errors.Is(err, ErrUserNotFound))
+		assert.Nil(t, actualUser)
+	})
+}
+
+func TestMemoryGetByEmail(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	repo := NewMemory()
+	givenUser := newMemoryUserHelper(t, "joedoe@foo.bar", "BR")
+	require.NoError(t, repo.Insert(context.TODO(), givenUser))
+
+	// Act
+	actualUser, err := repo.GetByEmail(context.TODO(), "joedoe@foo.bar")
+	require.NoError(t, err)
+
+	_, notFoundErr := repo.GetByEmail(context.TODO(), "unknown@foo.bar")
+
+	// Assert
+	assert.Equal(t, givenUser, actualUser)
+	assert.True(t, errors.Is(notFoundErr, ErrUserNotFound))
+}
+
+func TestMemoryUniquenessScope(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	repo := NewMemory(WithMemoryUniquenessScope(ScopeCountry))
+
+	john := newMemoryUserHelper(t, "johndoe@foo.bar", "US")
+	require.NoError(t, repo.Insert(context.TODO(), john))
+
+	otherCountry := newMemoryUserHelper(t, "johndoe@foo.bar", "BR")
+	otherCountry.Nickname = "johndoe_br"
+
+	sameCountry := newMemoryUserHelper(t, "johndoe@foo.bar", "US")
+	sameCountry.Nickname = "johndoe_us"
+
+	// Act
+	otherCountryErr := repo.Insert(context.TODO(), otherCountry)
+	sameCountryErr := repo.Insert(context.TODO(), sameCountry)
+
+	_, ambiguousErr := repo.GetByEmail(context.TODO(), "johndoe@foo.bar")
+
+	moved := *john
+	moved.Country = "BR"
+	movedErr := repo.Update(context.TODO(), &moved)
+
+	// Assert
+	require.NoError(t, otherCountryErr)
+	assert.True(t, errors.Is(sameCountryErr, ErrDuplicateEmail))
+	assert.True(t, errors.Is(ambiguousErr, ErrAmbiguousEmail))
+	assert.True(t, errors.Is(movedErr, ErrDuplicateEmail))
+}
+
+func TestMemoryTenants(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	repo := NewMemory()
+	acme := tenant.ContextWithTenant(context.TODO(), "acme")
+	globex := tenant.ContextWithTenant(context.TODO(), "globex")
+
+	john := newMemoryUserHelper(t, "johndoe@foo.bar", "US")
+	require.NoError(t, repo.Insert(acme, john))
+
+	// The emails and nicknames are unique per tenant.
+	otherTenant := newMemoryUserHelper(t, "johndoe@foo.bar", "US")
+	require.NoError(t, repo.Insert(globex, otherTenant))
+
+	sameTenant := newMemoryUserHelper(t, "johndoe@foo.bar", "US")
+	sameTenant.Nickname = "johndoe_us"
+
+	// Act
+	sameTenantErr := repo.Insert(acme, sameTenant)
+
+	visible, err := repo.Get(acme, john.ID)
+	require.NoError(t, err)
+
+	_, hiddenErr := repo.Get(globex, john.ID)
+
+	byEmail, err := repo.GetByEmail(globex, "johndoe@foo.bar")
+	require.NoError(t, err)
+
+	listed, err := repo.List(acme, Filter{}, Page{Limit: 10})
+	require.NoError(t, err)
+
+	// The operations that aren't scoped, e.g. the background jobs, see every tenant.
+	count, err := repo.Count(context.TODO(), Filter{})
+	require.NoError(t, err)
+
+	taken, err := repo.NicknameExists(globex, "johndoe", "US")
+	require.NoError(t, err)
+
+	free, err := repo.NicknameExists(context.TODO(), "johndoe", "US")
+	require.NoError(t, err)
+
+	_, deleteErr := repo.Delete(globex, john.ID)
+
+	// Assert
+	assert.True(t, errors.Is(sameTenantErr, ErrDuplicateEmail))
+	assert.Equal(t, "acme", visible.TenantID)
+	assert.True(t, errors.Is(hiddenErr, ErrUserNotFound))
+	assert.Equal(t, otherTenant.ID, byEmail.ID)
+	require.Len(t, listed, 1)
+	assert.Equal(t, john.ID, listed[0].ID)
+	assert.Equal(t, int64(2), count)
+	assert.True(t, taken)
+	assert.False(t, free)
+	assert.True(t, errors.Is(deleteErr, ErrUserNotFound))
+}
+
+func TestMemoryNicknameExists(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	repo := NewMemory(WithMemoryUniquenessScope(ScopeCountry))
+	require.NoError(t, repo.Insert(context.TODO(), newMemoryUserHelper(t, "johndoe@foo.bar", "US")))
+
+	// Act
+	taken, err := repo.NicknameExists(context.TODO(), "johndoe", "US")
+	require.NoError(t, err)
+
+	otherCountry, err := repo.NicknameExists(context.TODO(), "johndoe", "BR")
+	require.NoError(t, err)
+
+	free, err := repo.NicknameExists(context.TODO(), "janedoe", "US")
+	require.NoError(t, err)
+
+	// Assert
+	assert.True(t, taken)
+	assert.False(t, otherCountry)
+	assert.False(t, free)
+}
+
+func TestMemoryPagination(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	repo := NewMemory()
+	for i := 0; i < 5; i++ {
+		country := "BR"
+		if i%2 == 0 {
+			country = "US"
+		}
+		require.NoError(t, repo.Insert(context.TODO(), newMemoryUserHelper(t, fmt.Sprintf("joedoe%d@foo.bar", i), country)))
+	}
+
+	// Act
+	var all []*User
+	cursor := ""
+	for {
+		page, err := repo.List(context.TODO(), Filter{}, Page{Cursor: cursor, Limit: 2})
+		require.NoError(t, err)
+		all = append(all, page...)
+		if len(page) < 2 {
+			break
+		}
+		cursor = page[len(page)-1].ID
+	}
+
+	byCountry, err := repo.List(context.TODO(), Filter{Country: "US"}, Page{Limit: 10})
+	require.NoError(t, err)
+
+	// Assert
+	require.Len(t, all, 5)
+	for i := 1; i < len(all); i++ {
+		assert.Less(t, all[i-1].ID, all[i].ID)
+	}
+
+	require.Len(t, byCountry, 3)
+	for _, user := range byCountry {
+		assert.Equal(t, "US", user.Country)
+	}
+}
+
+func TestMemoryListByFilter(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	repo := NewMemory()
+
+	john := newMemoryUserHelper(t, "johndoe@foo.bar", "US")
+	jane := newMemoryUserHelper(t, "janedoe@foo.bar", "US")
+	jane.FirstName = "Jane"
+	jane.CreatedAt = john.CreatedAt.Add(time.Hour)
+
+	for _, user := range []*User{john, jane, newMemoryUserHelper(t, "johnsmith@foo.bar", "BR")} {
+		require.NoError(t, repo.Insert(context.TODO(), user))
+	}
+
+	// Act
+	byNickname, err := repo.List(context.TODO(), Filter{Country: "US", NicknamePrefix: "john"}, Page{Limit: 10})
+	require.NoError(t, err)
+
+	byCreation, err := repo.List(context.TODO(), Filter{LastName: "doe", CreatedAfter: jane.CreatedAt}, Page{Limit: 10})
+	require.NoError(t, err)
+
+	count, err := repo.Count(context.TODO(), Filter{Country: "US"})
+	require.NoError(t, err)
+
+	// Assert
+	require.Len(t, byNickname, 1)
+	assert.Equal(t, john.ID, byNickname[0].ID)
+
+	require.Len(t, byCreation, 1)
+	assert.Equal(t, jane.ID, byCreation[0].ID)
+
+	assert.Equal(t, int64(2), count)
+}
+
+func TestMemorySearch(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	repo := NewMemory()
+
+	john := newMemoryUserHelper(t, "johndoe@foo.bar", "US")
+	jane := newMemoryUserHelper(t, "janedoe@foo.bar", "US")
+	jane.FirstName = "Jane"
+	smith := newMemoryUserHelper(t, "jsmith@foo.bar", "BR")
+	smith.LastName = "Smith"
+
+	for _, user := range []*User{john, jane, smith} {
+		require.NoError(t, repo.Insert(context.TODO(), user))
+	}
+
+	// Act
+	byPrefixes, err := repo.Search(context.TODO(), "JO d", 0, 10)
+	require.NoError(t, err)
+
+	byNickname, err := repo.Search(context.TODO(), "jsmi", 0, 10)
+	require.NoError(t, err)
+
+	pastTheEnd, err := repo.Search(context.TODO(), "doe", 2, 10)
+	require.NoError(t, err)
+
+	withoutWords, err := repo.Search(context.TODO(), "!!", 0, 10)
+	require.NoError(t, err)
+
+	// Assert
+	require.Len(t, byPrefixes, 1)
+	assert.Equal(t, john.ID, byPrefixes[0].ID)
+
+	require.Len(t, byNickname, 1)
+	assert.Equal(t, smith.ID, byNickname[0].ID)
+
+	assert.Empty(t, pastTheEnd)
+	assert.Empty(t, withoutWords)
+}
+
+func TestMemoryInsert(t *testing.T) {
+	t.Parallel()
+
+	t.Run("duplicate email", func(t *testing.T) {
+		// Arrange
+		repo := NewMemory()
+		require.NoError(t, repo.Insert(context.TODO(), newMemoryUserHelper(t, "joedoe@foo.bar", "BR")))
+
+		givenUser := newMemoryUserHelper(t, "joedoe@foo.bar", "US")
+		givenUser.Nickname = "someoneelse"
+
+		// Act
+		err := repo.Insert(context.TODO(), givenUser)
+
+		// Assert
+		assert.True(t, errors.Is(err, ErrDuplicateEmail))
+	})
+
+	t.Run("duplicate nickname", func(t *testing.T) {
+		// Arrange
+		repo := NewMemory()
+		require.NoError(t, repo.Insert(context.TODO(), newMemoryUserHelper(t, "joedoe@foo.bar", "BR")))
+
+		givenUser := newMemoryUserHelper(t, "janedoe@foo.bar", "US")
+		givenUser.Nickname = "joedoe"
+
+		// Act
+		err := repo.Insert(context.TODO(), givenUser)
+
+		// Assert
+		assert.True(t, errors.Is(err, ErrDuplicateNickname))
+	})
+}
+
+func TestMemoryInsertBatch(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	repo := NewMemory()
+	require.NoError(t, repo.Insert(context.TODO(), newMemoryUserHelper(t, "joedoe@foo.bar", "BR")))
+
+	batch := []*User{
+		newMemoryUserHelper(t, "janedoe@foo.bar", "US"),
+		newMemoryUserHelper(t, "jsmith@foo.bar", "PT"),
+	}
+
+	// The second user of the failed batch shares the email of the stored user.
+	failed := []*User{
+		newMemoryUserHelper(t, "alice@foo.bar", "US"),
+		newMemoryUserHelper(t, "joedoe@foo.bar", "PT"),
+	}
+	failed[1].Nickname = "someoneelse"
+
+	// Act
+	err := repo.InsertBatch(context.TODO(), batch)
+	require.NoError(t, err)
+
+	failedErr := repo.InsertBatch(context.TODO(), failed)
+
+	count, err := repo.Count(context.TODO(), Filter{})
+	require.NoError(t, err)
+
+	inserted, err := repo.Get(context.TODO(), batch[1].ID)
+	require.NoError(t, err)
+
+	_, notInsertedErr := repo.Get(context.TODO(), failed[0].ID)
+
+	// Assert
+	assert.True(t, errors.Is(failedErr, ErrDuplicateEmail))
+	assert.Equal(t, int64(3), count)
+	assert.Equal(t, StatusActive, inserted.Status)
+	assert.Equal(t, int64(1), inserted.EventSequence)
+	assert.True(t, errors.Is(notInsertedErr, ErrUserNotFound))
+}
+
+func TestMemoryUpdate(t *testing.T) {
+	t.Parallel()
+
+	t.Run("happy case", func(t *testing.T) {
+		// Arrange
+		repo := NewMemory()
+		givenUser := newMemoryUserHelper(t, "joedoe@foo.bar", "BR")
+		require.NoError(t, repo.Insert(context.TODO(), givenUser))
+
+		updatedUser := *givenUser
+		updatedUser.Country = "US"
+		updatedUser.CreatedAt = time.Time{}.Add(10 * time.Second)
+
+		// Act
+		require.NoError(t, repo.Update(context.TODO(), &updatedUser))
+
+		// Assert
+		actualUser, err := repo.Get(context.TODO(), givenUser.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "US", actualUser.Country)
+		assert.Equal(t, givenUser.CreatedAt, actualUser.CreatedAt)
+		assert.Equal(t, int64(2), updatedUser.EventSequence)
+		assert.Equal(t, int64(2), actualUser.EventSequence)
+	})
+
+	t.Run("duplicate email", func(t *testing.T) {
+		// Arrange
+		repo := NewMemory()
+		first := newMemoryUserHelper(t, "joedoe@foo.bar", "BR")
+		second := newMemoryUserHelper(t, "janedoe@foo.bar", "BR")
+		require.NoError(t, repo.Insert(context.TODO(), first))
+		require.NoError(t, repo.Insert(context.TODO(), second))
+
+		second.Email = first.Email
+
+		// Act
+		err := repo.Update(context.TODO(), second)
+
+		// Assert
+		assert.True(t, errors.Is(err, ErrDuplicateEmail))
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		// Arrange
+		repo := NewMemory()
+
+		// Act
+		err := repo.Update(context.TODO(), newMemoryUserHelper(t, "joedoe@foo.bar", "BR"))
+
+		// Assert
+		assert.True(t, errors.Is(err, ErrUserNotFound))
+	})
+}
+
+func TestMemoryDeleteAndCount(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	repo := NewMemory()
+	givenUser := newMemoryUserHelper(t, "joedoe@foo.bar", "BR")
+	require.NoError(t, repo.Insert(context.TODO(), givenUser))
+	require.NoError(t, repo.Insert(context.TODO(), newMemoryUserHelper(t, "janedoe@foo.bar", "US")))
+
+	// Act
+	sequence, err := repo.Delete(context.TODO(), givenUser.ID)
+	require.NoError(t, err)
+
+	_, secondErr := repo.Delete(context.TODO(), givenUser.ID)
+
+	counts, err := repo.CountByCountry(context.TODO())
+	require.NoError(t, err)
+
+	// Assert
+	assert.Equal(t, int64(2), sequence)
+	assert.True(t, errors.Is(secondErr, ErrUserNotFound))
+	assert.Equal(t, map[string]int64{"US": 1}, counts)
+}
+
+func TestMemoryMerge(t *testing.T) {
+	t.Parallel()
+
+	t.Run("survivor takes over the duplicate email", func(t *testing.T) {
+		// Arrange
+		repo := NewMemory()
+		survivor := newMemoryUserHelper(t, "joedoe@foo.bar", "BR")
+		duplicate := newMemoryUserHelper(t, "joe.doe@foo.bar", "BR")
+		require.NoError(t, repo.Insert(context.TODO(), survivor))
+		require.NoError(t, repo.Insert(context.TODO(), duplicate))
+
+		merged := *survivor
+		merged.Email = duplicate.Email
+
+		// Act
+		err := repo.Merge(context.TODO(), &merged, duplicate.ID)
+		require.NoError(t, err)
+
+		// Assert
+		stored, err := repo.GetByEmail(context.TODO(), duplicate.Email)
+		require.NoError(t, err)
+		assert.Equal(t, survivor.ID, stored.ID)
+
+		_, err = repo.Get(context.TODO(), duplicate.ID)
+		assert.True(t, errors.Is(err, ErrUserNotFound))
+	})
+
+	t.Run("conflict keeps the duplicate", func(t *testing.T) {
+		// Arrange
+		repo := NewMemory()
+		survivor := newMemoryUserHelper(t, "joedoe@foo.bar", "BR")
+		duplicate := newMemoryUserHelper(t, "joe.doe@foo.bar", "BR")
+		other := newMemoryUserHelper(t, "other@foo.bar", "BR")
+		for _, user := range []*User{survivor, duplicate, other} {
+			require.NoError(t, repo.Insert(context.TODO(), user))
+		}
+
+		merged := *survivor
+		merged.Email = other.Email
+
+		// Act
+		err := repo.Merge(context.TODO(), &merged, duplicate.ID)
+
+		// Assert
+		assert.True(t, errors.Is(err, ErrDuplicateEmail))
+
+		_, err = repo.Get(context.TODO(), duplicate.ID)
+		assert.NoError(t, err)
+	})
+
+	t.Run("duplicate not found", func(t *testing.T) {
+		// Arrange
+		repo := NewMemory()
+		survivor := newMemoryUserHelper(t, "joedoe@foo.bar", "BR")
+		require.NoError(t, repo.Insert(context.TODO(), survivor))
+
+		// Act
+		err := repo.Merge(context.TODO(), survivor, uuid.New().String())
+
+		// Assert
+		assert.True(t, errors.Is(err, ErrUserNotFound))
+	})
+}
+
+func TestMemoryAnonymize(t *testing.T) {
+	t.Parallel()
+
+	t.Run("overwrites the user and deletes its records", func(t *testing.T) {
+		// Arrange
+		repo := NewMemory()
+		user := newMemoryUserHelper(t, "joedoe@foo.bar", "BR")
+		require.NoError(t, repo.Insert(context.TODO(), user))
+
+		require.NoError(t, repo.LinkIdentity(context.TODO(), &LinkedIdentity{
+			Provider:  "google",
+			Subject:   "1234567890",
+			UserID:    user.ID,
+			Email:     "joedoe@gmail.com",
+			CreatedAt: time.Time{}.Add(1 * time.Second),
+		}))
+
+		anonymized := *user
+		anonymized.FirstName = "Anonymized"
+		anonymized.Email = "anonymized@anonymized.invalid"
+
+		// Act
+		err := repo.Anonymize(context.TODO(), &anonymized)
+		require.NoError(t, err)
+
+		// Updates keep the user anonymized.
+		require.NoError(t, repo.Update(context.TODO(), &anonymized))
+
+		// Assert
+		stored, err := repo.Get(context.TODO(), user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Anonymized", stored.FirstName)
+		assert.Equal(t, "anonymized@anonymized.invalid", stored.Email)
+		assert.True(t, stored.Anonymized)
+		assert.Equal(t, int64(3), stored.EventSequence)
+
+		identities, err := repo.GetLinkedIdentities(context.TODO(), user.ID)
+		require.NoError(t, err)
+		assert.Empty(t, identities)
+	})
+
+	t.Run("user not found", func(t *testing.T) {
+		// Arrange
+		repo := NewMemory()
+
+		// Act
+		err := repo.Anonymize(context.TODO(), newMemoryUserHelper(t, "joedoe@foo.bar", "BR"))
+
+		// Assert
+		assert.True(t, errors.Is(err, ErrUserNotFound))
+	})
+}
+
+func TestMemorySetStatus(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	repo := NewMemory()
+	user := newMemoryUserHelper(t, "joedoe@foo.bar", "BR")
+	require.NoError(t, repo.Insert(context.TODO(), user))
+
+	suspended := *user
+	suspended.Status = StatusSuspended
+	suspended.UpdatedAt = time.Time{}.Add(1 * time.Hour)
+
+	// Act
+	err := repo.SetStatus(context.TODO(), &suspended)
+	require.NoError(t, err)
+
+	// Updates keep the status.
+	require.NoError(t, repo.Update(context.TODO(), user))
+
+	notFoundErr := repo.SetStatus(context.TODO(), &User{ID: uuid.New().String(), Status: StatusActive})
+
+	// Assert
+	assert.Equal(t, StatusActive, user.Status, "insert defaults to active")
+	assert.Equal(t, int64(2), suspended.EventSequence)
+	assert.True(t, errors.Is(notFoundErr, ErrUserNotFound))
+
+	stored, err := repo.Get(context.TODO(), user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusSuspended, stored.Status)
+}
+
+func TestMemorySetAvatar(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	repo := NewMemory()
+	user := newMemoryUserHelper(t, "joedoe@foo.bar", "BR")
+	require.NoError(t, repo.Insert(context.TODO(), user))
+
+	withAvatar := *user
+	withAvatar.AvatarKey = "avatars/" + user.ID + "/abc"
+	withAvatar.AvatarHash = "abc"
+
+	// Act
+	err := repo.SetAvatar(context.TODO(), &withAvatar)
+	require.NoError(t, err)
+
+	// Updates keep the avatar.
+	require.NoError(t, repo.Update(context.TODO(), user))
+
+	notFoundErr := repo.SetAvatar(context.TODO(), &User{ID: uuid.New().String()})
+
+	// Assert
+	assert.Equal(t, int64(2), withAvatar.EventSequence)
+	assert.True(t, errors.Is(notFoundErr, ErrUserNotFound))
+
+	stored, err := repo.Get(context.TODO(), user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "avatars/"+user.ID+"/abc", stored.AvatarKey)
+	assert.Equal(t, "abc", stored.AvatarHash)
+
+	// Anonymize clears the avatar.
+	require.NoError(t, repo.Anonymize(context.TODO(), stored))
+	assert.Empty(t, stored.AvatarKey)
+
+	stored, err = repo.Get(context.TODO(), user.ID)
+	require.NoError(t, err)
+	assert.Empty(t, stored.AvatarKey)
+	assert.Empty(t, stored.AvatarHash)
+}
+
+func TestMemoryPhoneVerification(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	repo := NewMemory()
+	user := newMemoryUserHelper(t, "joedoe@foo.bar", "BR")
+	require.NoError(t, repo.Insert(context.TODO(), user))
+
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	verification := func(codeHash string) *PhoneVerification {
+		return &PhoneVerification{
+			UserID:    user.ID,
+			Phone:     "+5511999999999",
+			CodeHash:  []byte(codeHash),
+			CreatedAt: now,
+			ExpiresAt: now.Add(10 * time.Minute),
+		}
+	}
+
+	// Act
+	unknownErr := repo.InsertPhoneVerification(context.TODO(), &PhoneVerification{UserID: uuid.New().String(), CodeHash: []byte("unknown"), CreatedAt: now, ExpiresAt: now})
+	_, notPendingErr := repo.AttemptPhoneVerification(context.TODO(), user.ID, now)
+
+	require.NoError(t, repo.InsertPhoneVerification(context.TODO(), verification("first")))
+	_, err := repo.AttemptPhoneVerification(context.TODO(), user.ID, now)
+	require.NoError(t, err)
+
+	require.NoError(t, repo.InsertPhoneVerification(context.TODO(), verification("second")))
+	_, err = repo.AttemptPhoneVerification(context.TODO(), user.ID, now)
+	require.NoError(t, err)
+
+	attempted, err := repo.AttemptPhoneVerification(context.TODO(), user.ID, now)
+	require.NoError(t, err)
+
+	_, expiredErr := repo.AttemptPhoneVerification(context.TODO(), user.ID, now.Add(10*time.Minute))
+
+	require.NoError(t, repo.DeletePhoneVerification(context.TODO(), user.ID))
+	_, deletedErr := repo.AttemptPhoneVerification(context.TODO(), user.ID, now)
+
+	user.Phone = "+5511999999999"
+	user.PhoneVerified = true
+	require.NoError(t, repo.Update(context.TODO(), user))
+
+	stored, err := repo.Get(context.TODO(), user.ID)
+	require.NoError(t, err)
+
+	// Assert
+	assert.True(t, errors.Is(unknownErr, ErrUserNotFound))
+	assert.True(t, errors.Is(notPendingErr, ErrPhoneVerificationNotFound))
+
+	// A pending verification is replaced, attempts included.
+	assert.Equal(t, []byte("second"), attempted.CodeHash)
+	assert.Equal(t, "+5511999999999", attempted.Phone)
+	assert.Equal(t, 2, attempted.Attempts)
+
+	assert.True(t, errors.Is(expiredErr, ErrPhoneVerificationNotFound))
+	assert.True(t, errors.Is(deletedErr, ErrPhoneVerificationNotFound))
+
+	assert.Equal(t, "+5511999999999", stored.Phone)
+	assert.True(t, stored.PhoneVerified)
+}
+
+func TestMemoryMetadata(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	repo := NewMemory()
+	user := newMemoryUserHelper(t, "joedoe@foo.bar", "BR")
+	user.Metadata = Metadata{"theme": "dark"}
+	require.NoError(t, repo.Insert(context.TODO(), user))
+
+	// The repository doesn't share the maps of the callers.
+	user.Metadata["theme"] = "light"
+
+	inserted, err := repo.Get(context.TODO(), user.ID)
+	require.NoError(t, err)
+
+	updated := *user
+	updated.Metadata = Metadata{"locale": "pt-BR"}
+
+	// Act
+	err = repo.Update(context.TODO(), &updated)
+	require.NoError(t, err)
+
+	// Assert
+	assert.Equal(t, Metadata{"theme": "dark"}, inserted.Metadata)
+
+	stored, err := repo.Get(context.TODO(), user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, Metadata{"locale": "pt-BR"}, stored.Metadata)
+}
+
+func TestMemoryBootstrap(t *testing.T) {
+	t.Parallel()
+
+	newKey := func(userID string) *APIKey {
+		return &APIKey{
+			ID:         uuid.New().String(),
+			UserID:     userID,
+			Name:       "terraform",
+			SecretHash: []byte("hash"),
+			CreatedAt:  time.Time{}.Add(1 * time.Second),
+		}
+	}
+
+	// Arrange
+	repo := NewMemory()
+	regular := newMemoryUserHelper(t, "janedoe@foo.bar", "BR")
+	require.NoError(t, repo.Insert(context.TODO(), regular))
+
+	admin := newMemoryUserHelper(t, "admin@foo.bar", "BR")
+
+	adminKey := newKey(admin.ID)
+
+	// Act
+	err := repo.Bootstrap(context.TODO(), admin, adminKey)
+	require.NoError(t, err)
+
+	second := newMemoryUserHelper(t, "admin2@foo.bar", "BR")
+	secondErr := repo.Bootstrap(context.TODO(), second, newKey(second.ID))
+
+	// Assert
+	assert.True(t, errors.Is(secondErr, ErrAlreadyBootstrapped))
+
+	storedKey, err := repo.GetAPIKey(context.TODO(), adminKey.ID)
+	require.NoError(t, err)
+	assert.Equal(t, adminKey, storedKey)
+
+	_, err = repo.GetAPIKey(context.TODO(), uuid.New().String())
+	assert.True(t, errors.Is(err, ErrAPIKeyNotFound))
+
+	stored, err := repo.Get(context.TODO(), admin.ID)
+	require.NoError(t, err)
+	assert.Equal(t, RoleAdmin, stored.Role)
+
+	stored, err = repo.Get(context.TODO(), regular.ID)
+	require.NoError(t, err)
+	assert.Equal(t, RoleUser, stored.Role)
+
+	// Updates keep the role.
+	updated := *admin
+	updated.Role = ""
+	require.NoError(t, repo.Update(context.TODO(), &updated))
+
+	stored, err = repo.Get(context.TODO(), admin.ID)
+	require.NoError(t, err)
+	assert.Equal(t, RoleAdmin, stored.Role)
+}
+
+func TestMemoryLinkedIdentities(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	repo := NewMemory()
+	survivor := newMemoryUserHelper(t, "joedoe@foo.bar", "BR")
+	duplicate := newMemoryUserHelper(t, "joe.doe@foo.bar", "BR")
+	require.NoError(t, repo.Insert(context.TODO(), survivor))
+	require.NoError(t, repo.Insert(context.TODO(), duplicate))
+
+	identity := &LinkedIdentity{
+		Provider:  "google",
+		Subject:   "1234567890",
+		UserID:    duplicate.ID,
+		Email:     "joedoe@gmail.com",
+		CreatedAt: time.Time{}.Add(1 * time.Second),
+	}
+
+	// Act
+	require.NoError(t, repo.LinkIdentity(context.TODO(), identity))
+
+	linkedErr := repo.LinkIdentity(context.TODO(), &LinkedIdentity{Provider: "google", Subject: "1234567890", UserID: survivor.ID})
+	unknownErr := repo.LinkIdentity(context.TODO(), &LinkedIdentity{Provider: "azure", Subject: "1234567890", UserID: uuid.New().String()})
+
+	require.NoError(t, repo.Merge(context.TODO(), survivor, duplicate.ID))
+
+	// Assert
+	assert.True(t, errors.Is(linkedErr, ErrIdentityLinked))
+	assert.True(t, errors.Is(unknownErr, ErrUserNotFound))
+
+	// Merging moves the identity to the survivor.
+	user, err := repo.GetByLinkedIdentity(context.TODO(), "google", "1234567890")
+	require.NoError(t, err)
+	assert.Equal(t, survivor.ID, user.ID)
+
+	identities, err := repo.GetLinkedIdentities(context.TODO(), survivor.ID)
+	require.NoError(t, err)
+	require.Len(t, identities, 1)
+	assert.Equal(t, "joedoe@gmail.com", identities[0].Email)
+
+	// Deleting the user deletes its identities.
+	_, err = repo.Delete(context.TODO(), survivor.ID)
+	require.NoError(t, err)
+
+	_, err = repo.GetByLinkedIdentity(context.TODO(), "google", "1234567890")
+	assert.True(t, errors.Is(err, ErrUserNotFound))
+}
+
+func TestMemoryUnlinkIdentity(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	repo := NewMemory()
+	user := newMemoryUserHelper(t, "joedoe@foo.bar", "BR")
+	require.NoError(t, repo.Insert(context.TODO(), user))
+
+	identity := &LinkedIdentity{
+		Provider:  "google",
+		Subject:   "1234567890",
+		UserID:    user.ID,
+		Email:     "joedoe@gmail.com",
+		CreatedAt: time.Now().UTC().Truncate(time.Millisecond),
+	}
+	require.NoError(t, repo.LinkIdentity(context.TODO(), identity))
+
+	// Act
+	otherUserErr := repo.UnlinkIdentity(context.TODO(), uuid.New().String(), "google", "1234567890")
+	require.NoError(t, repo.UnlinkIdentity(context.TODO(), user.ID, "google", "1234567890"))
+	unlinkAgainErr := repo.UnlinkIdentity(context.TODO(), user.ID, "google", "1234567890")
+
+	_, getErr := repo.GetByLinkedIdentity(context.TODO(), "google", "1234567890")
+
+	// Assert
+	assert.True(t, errors.Is(otherUserErr, ErrIdentityNotFound))
+	assert.True(t, errors.Is(unlinkAgainErr, ErrIdentityNotFound))
+	assert.True(t, errors.Is(getErr, ErrUserNotFound))
+
+	// The identity can be linked again once unlinked.
+	assert.NoError(t, repo.LinkIdentity(context.TODO(), identity))
+}
+
+func TestMemoryFieldLocks(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	repo := NewMemory()
+	survivor := newMemoryUserHelper(t, "joedoe@foo.bar", "BR")
+	duplicate := newMemoryUserHelper(t, "joe.doe@foo.bar", "BR")
+	require.NoError(t, repo.Insert(context.TODO(), survivor))
+	require.NoError(t, repo.Insert(context.TODO(), duplicate))
+
+	lock := func(userID, field, reason string) *FieldLock {
+		return &FieldLock{UserID: userID, Field: field, Reason: reason, LockedBy: "admin-id", CreatedAt: time.Time{}.Add(1 * time.Second)}
+	}
+
+	// Act
+	require.NoError(t, repo.LockField(context.TODO(), lock(survivor.ID, "email", "investigation")))
+	require.NoError(t, repo.LockField(context.TODO(), lock(duplicate.ID, "email", "other investigation")))
+	require.NoError(t, repo.LockField(context.TODO(), lock(duplicate.ID, "nickname", "reserved")))
+	require.NoError(t, repo.LockField(context.TODO(), lock(duplicate.ID, "country", "legal hold")))
+	require.NoError(t, repo.UnlockField(context.TODO(), duplicate.ID, "country"))
+
+	unknownErr := repo.LockField(context.TODO(), lock(uuid.New().String(), "email", "investigation"))
+
+	require.NoError(t, repo.Merge(context.TODO(), survivor, duplicate.ID))
+
+	// Assert
+	assert.True(t, errors.Is(unknownErr, ErrUserNotFound))
+
+	// Merging moves the locks to the survivor, which keeps its own.
+	locks, err := repo.GetFieldLocks(context.TODO(), survivor.ID)
+	require.NoError(t, err)
+	require.Len(t, locks, 2)
+	assert.Equal(t, "email", locks[0].Field)
+	assert.Equal(t, "investigation", locks[0].Reason)
+	assert.Equal(t, "nickname", locks[1].Field)
+
+	// Deleting the user deletes its locks.
+	_, err = repo.Delete(context.TODO(), survivor.ID)
+	require.NoError(t, err)
+
+	locks, err = repo.GetFieldLocks(context.TODO(), survivor.ID)
+	require.NoError(t, err)
+	assert.Empty(t, locks)
+}
+
+func TestMemoryLogins(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	repo := NewMemory()
+	survivor := newMemoryUserHelper(t, "joedoe@foo.bar", "BR")
+	duplicate := newMemoryUserHelper(t, "joe.doe@foo.bar", "BR")
+	require.NoError(t, repo.Insert(context.TODO(), survivor))
+	require.NoError(t, repo.Insert(context.TODO(), duplicate))
+
+	for i, country := range []string{"BR", "PT", "DE"} {
+		require.NoError(t, repo.RecordLogin(context.TODO(), &Login{
+			UserID:    survivor.ID,
+			Country:   country,
+			CreatedAt: time.Time{}.Add(time.Duration(i) * time.Hour),
+		}))
+	}
+	require.NoError(t, repo.RecordLogin(context.TODO(), &Login{
+		UserID:    duplicate.ID,
+		Country:   "FR",
+		CreatedAt: time.Time{}.Add(10 * time.Hour),
+	}))
+
+	// Act
+	unknownErr := repo.RecordLogin(context.TODO(), &Login{UserID: uuid.New().String()})
+
+	recent, err := repo.GetRecentLogins(context.TODO(), survivor.ID, 2)
+	require.NoError(t, err)
+
+	require.NoError(t, repo.Merge(context.TODO(), survivor, duplicate.ID))
+
+	merged, err := repo.GetRecentLogins(context.TODO(), survivor.ID, 10)
+	require.NoError(t, err)
+
+	// Assert
+	assert.True(t, errors.Is(unknownErr, ErrUserNotFound))
+
+	// The most recent logins come first.
+	require.Len(t, recent, 2)
+	assert.Equal(t, "DE", recent[0].Country)
+	assert.Equal(t, "PT", recent[1].Country)
+
+	// Merging moves the logins to the survivor.
+	require.Len(t, merged, 4)
+	assert.Equal(t, "FR", merged[0].Country)
+
+	// Deleting the user deletes its logins.
+	_, err = repo.Delete(context.TODO(), survivor.ID)
+	require.NoError(t, err)
+
+	deleted, err := repo.GetRecentLogins(context.TODO(), survivor.ID, 10)
+	require.NoError(t, err)
+	assert.Empty(t, deleted)
+}
+
+func TestMemoryLockouts(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	repo := NewMemory()
+	user := newMemoryUserHelper(t, "joedoe@foo.bar", "BR")
+	require.NoError(t, repo.Insert(context.TODO(), user))
+
+	until := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Act
+	_, unknownErr := repo.RecordFailedLogin(context.TODO(), uuid.New().String())
+
+	none, err := repo.GetLockout(context.TODO(), user.ID)
+	require.NoError(t, err)
+
+	_, err = repo.RecordFailedLogin(context.TODO(), user.ID)
+	require.NoError(t, err)
+
+	failed, err := repo.RecordFailedLogin(context.TODO(), user.ID)
+	require.NoError(t, err)
+
+	require.NoError(t, repo.LockUser(context.TODO(), user.ID, until))
+
+	locked, err := repo.GetLockout(context.TODO(), user.ID)
+	require.NoError(t, err)
+
+	require.NoError(t, repo.ResetLockout(context.TODO(), user.ID))
+
+	reset, err := repo.GetLockout(context.TODO(), user.ID)
+	require.NoError(t, err)
+
+	// Assert
+	assert.True(t, errors.Is(unknownErr, ErrUserNotFound))
+	assert.Equal(t, &Lockout{UserID: user.ID}, none)
+	assert.Equal(t, &Lockout{UserID: user.ID, FailedAttempts: 2}, failed)
+
+	// Locking starts over the count of failed logins.
+	assert.Equal(t, &Lockout{UserID: user.ID, Lockouts: 1, LockedUntil: until}, locked)
+	assert.Equal(t, &Lockout{UserID: user.ID}, reset)
+}
+
+func TestMemoryResetPassword(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	repo := NewMemory()
+	user := newMemoryUserHelper(t, "joedoe@foo.bar", "BR")
+	require.NoError(t, repo.Insert(context.TODO(), user))
+
+	now := time.Now()
+	for _, token := range []*PasswordResetToken{
+		{TokenHash: []byte("valid"), UserID: user.ID, CreatedAt: now, ExpiresAt: now.Add(time.Hour)},
+		{TokenHash: []byte("other"), UserID: user.ID, CreatedAt: now, ExpiresAt: now.Add(time.Hour)},
+		{TokenHash: []byte("expired"), UserID: user.ID, CreatedAt: now, ExpiresAt: now.Add(time.Minute)},
+	} {
+		require.NoError(t, repo.InsertPasswordResetToken(context.TODO(), token))
+	}
+
+	// Act
+	unknownErr := repo.InsertPasswordResetToken(context.TODO(), &PasswordResetToken{TokenHash: []byte("unknown"), UserID: uuid.New().String()})
+	_, expiredErr := repo.ResetPassword(context.TODO(), []byte("expired"), "new-password", now.Add(2*time.Minute))
+
+	reset, err := repo.ResetPassword(context.TODO(), []byte("valid"), "new-password", now.Add(2*time.Minute))
+	require.NoError(t, err)
+
+	_, reusedErr := repo.ResetPassword(context.TODO(), []byte("valid"), "new-password", now.Add(2*time.Minute))
+	_, otherErr := repo.ResetPassword(context.TODO(), []byte("other"), "new-password", now.Add(2*time.Minute))
+
+	// Assert
+	assert.True(t, errors.Is(unknownErr, ErrUserNotFound))
+	assert.True(t, errors.Is(expiredErr, ErrResetTokenNotFound))
+
+	assert.Equal(t, "new-password", reset.Password)
+	assert.Equal(t, int64(2), reset.EventSequence)
+
+	stored, err := repo.Get(context.TODO(), user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "new-password", stored.Password)
+
+	// Tokens are single use and a reset revokes the other tokens of the user.
+	assert.True(t, errors.Is(reusedErr, ErrResetTokenNotFound))
+	assert.True(t, errors.Is(otherErr, ErrResetTokenNotFound))
+}
+
+func TestMemoryAPIKeys(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	repo := NewMemory()
+	user := newMemoryUserHelper(t, "joedoe@foo.bar", "BR")
+	require.NoError(t, repo.Insert(context.TODO(), user))
+
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	older := &APIKey{ID: uuid.New().String(), UserID: user.ID, Name: "older", SecretHash: []byte("older"), Scopes: "users:read", CreatedAt: now}
+	newer := &APIKey{ID: uuid.New().String(), UserID: user.ID, Name: "newer", SecretHash: []byte("newer"), Scopes: "users:read users:write", CreatedAt: now.Add(time.Hour)}
+
+	// Act
+	unknownErr := repo.InsertAPIKey(context.TODO(), &APIKey{ID: uuid.New().String(), UserID: uuid.New().String(), Name: "unknown", SecretHash: []byte("unknown"), CreatedAt: now})
+
+	for _, key := range []*APIKey{newer, older} {
+		require.NoError(t, repo.InsertAPIKey(context.TODO(), key))
+	}
+
+	keys, err := repo.GetAPIKeys(context.TODO(), user.ID)
+	require.NoError(t, err)
+
+	stored, err := repo.GetAPIKey(context.TODO(), newer.ID)
+	require.NoError(t, err)
+
+	require.NoError(t, repo.DeleteAPIKey(context.TODO(), newer.ID))
+	_, deletedErr := repo.GetAPIKey(context.TODO(), newer.ID)
+	deleteAgainErr := repo.DeleteAPIKey(context.TODO(), newer.ID)
+
+	_, err = repo.Delete(context.TODO(), user.ID)
+	require.NoError(t, err)
+	_, userDeletedErr := repo.GetAPIKey(context.TODO(), older.ID)
+
+	// Assert
+	assert.True(t, errors.Is(unknownErr, ErrUserNotFound))
+
+	// The oldest keys come first.
+	require.Len(t, keys, 2)
+	assert.Equal(t, older.ID, keys[0].ID)
+	assert.Equal(t, newer.ID, keys[1].ID)
+
+	assert.Equal(t, "users:read users:write", stored.Scopes)
+	assert.Equal(t, []byte("newer"), stored.SecretHash)
+
+	assert.True(t, errors.Is(deletedErr, ErrAPIKeyNotFound))
+	assert.True(t, errors.Is(deleteAgainErr, ErrAPIKeyNotFound))
+
+	// Deleting the user deletes its keys.
+	assert.True(t, errors.Is(userDeletedErr, ErrAPIKeyNotFound))
+}
+
+func TestMemorySessions(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	repo := NewMemory()
+	user := newMemoryUserHelper(t, "joedoe@foo.bar", "BR")
+	require.NoError(t, repo.Insert(context.TODO(), user))
+
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	expired := &Session{ID: uuid.New().String(), UserID: user.ID, AccessTokenHash: []byte("access"), RefreshTokenHash: []byte("expired"), CreatedAt: now, ExpiresAt: now.Add(time.Hour)}
+	older := &Session{ID: uuid.New().String(), UserID: user.ID, AccessTokenHash: []byte("access"), RefreshTokenHash: []byte("older"), CreatedAt: now.Add(time.Hour), ExpiresAt: now.Add(48 * time.Hour)}
+	newer := &Session{ID: uuid.New().String(), UserID: user.ID, AccessTokenHash: []byte("access"), RefreshTokenHash: []byte("newer"), CreatedAt: now.Add(2 * time.Hour), ExpiresAt: now.Add(48 * time.Hour)}
+
+	// Act
+	unknownErr := repo.InsertSession(context.TODO(), &Session{ID: uuid.New().String(), UserID: uuid.New().String(), AccessTokenHash: []byte("unknown"), RefreshTokenHash: []byte("unknown")})
+
+	for _, session := range []*Session{expired, older, newer} {
+		require.NoError(t, repo.InsertSession(context.TODO(), session))
+	}
+
+	sessions, err := repo.GetSessions(context.TODO(), user.ID)
+	require.NoError(t, err)
+
+	rotated := *older
+	rotated.AccessTokenHash = []byte("rotated-access")
+	rotated.RefreshTokenHash = []byte("rotated")
+	rotated.ExpiresAt = now.Add(72 * time.Hour)
+	require.NoError(t, repo.RotateSession(context.TODO(), &rotated, []byte("older")))
+
+	reusedErr := repo.RotateSession(context.TODO(), &rotated, []byte("older"))
+
+	stored, err := repo.GetSession(context.TODO(), older.ID)
+	require.NoError(t, err)
+
+	require.NoError(t, repo.DeleteSession(context.TODO(), newer.ID))
+	_, deletedErr := repo.GetSession(context.TODO(), newer.ID)
+	deleteAgainErr := repo.DeleteSession(context.TODO(), newer.ID)
+
+	_, err = repo.Delete(context.TODO(), user.ID)
+	require.NoError(t, err)
+	_, userDeletedErr := repo.GetSession(context.TODO(), older.ID)
+
+	// Assert
+	assert.True(t, errors.Is(unknownErr, ErrUserNotFound))
+
+	// Inserting a session deletes the expired ones of the user, and the most recent come first.
+	require.Len(t, sessions, 2)
+	assert.Equal(t, newer.ID, sessions[0].ID)
+	assert.Equal(t, older.ID, sessions[1].ID)
+
+	// A refresh token can only be rotated once.
+	assert.True(t, errors.Is(reusedErr, ErrSessionNotFound))
+	assert.Equal(t, []byte("rotated"), stored.RefreshTokenHash)
+	assert.Equal(t, []byte("rotated-access"), stored.AccessTokenHash)
+	assert.True(t, rotated.ExpiresAt.Equal(stored.ExpiresAt))
+
+	assert.True(t, errors.Is(deletedErr, ErrSessionNotFound))
+	assert.True(t, errors.Is(deleteAgainErr, ErrSessionNotFound))
+
+	// Deleting the user deletes its sessions.
+	assert.True(t, errors.Is(userDeletedErr, ErrSessionNotFound))
+}
+
+func TestMemoryTOTP(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	repo := NewMemory()
+	user := newMemoryUserHelper(t, "joedoe@foo.bar", "BR")
+	require.NoError(t, repo.Insert(context.TODO(), user))
+
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Act
+	unknownErr := repo.InsertTOTP(context.TODO(), &TOTP{UserID: uuid.New().String(), Secret: []byte("unknown"), CreatedAt: now})
+	_, notEnrolledErr := repo.GetTOTP(context.TODO(), user.ID)
+
+	require.NoError(t, repo.InsertTOTP(context.TODO(), &TOTP{UserID: user.ID, Secret: []byte("first"), CreatedAt: now}))
+	require.NoError(t, repo.InsertTOTP(context.TODO(), &TOTP{UserID: user.ID, Secret: []byte("second"), CreatedAt: now}))
+
+	pending, err := repo.GetTOTP(context.TODO(), user.ID)
+	require.NoError(t, err)
+
+	require.NoError(t, repo.UseTOTP(context.TODO(), user.ID, 100))
+	replayedErr := repo.UseTOTP(context.TODO(), user.ID, 100)
+	olderErr := repo.UseTOTP(context.TODO(), user.ID, 99)
+	require.NoError(t, repo.UseTOTP(context.TODO(), user.ID, 101))
+
+	enabledErr := repo.InsertTOTP(context.TODO(), &TOTP{UserID: user.ID, Secret: []byte("third"), CreatedAt: now})
+
+	enabled, err := repo.GetTOTP(context.TODO(), user.ID)
+	require.NoError(t, err)
+
+	_, err = repo.Delete(context.TODO(), user.ID)
+	require.NoError(t, err)
+	_, userDeletedErr := repo.GetTOTP(context.TODO(), user.ID)
+
+	// Assert
+	assert.True(t, errors.Is(unknownErr, ErrUserNotFound))
+	assert.True(t, errors.Is(notEnrolledErr, ErrTOTPNotFound))
+
+	// A pending enrollment is replaced.
+	assert.Equal(t, []byte("second"), pending.Secret)
+	assert.False(t, pending.Enabled)
+
+	// Codes can't be replayed, and using one enables TOTP for good.
+	assert.True(t, errors.Is(replayedErr, ErrTOTPCodeUsed))
+	assert.True(t, errors.Is(olderErr, ErrTOTPCodeUsed))
+	assert.True(t, errors.Is(enabledErr, ErrTOTPEnabled))
+	assert.Equal(t, []byte("second"), enabled.Secret)
+	assert.True(t, enabled.Enabled)
+	assert.Equal(t, int64(101), enabled.LastUsedStep)
+
+	// Deleting the user deletes its TOTP.
+	assert.True(t, errors.Is(userDeletedErr, ErrTOTPNotFound))
+}
@@ -0,0 +1,1442 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/alesr/usrsvc/internal/tenant"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsoncodec"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	mongoUsers          string = "users"
+	mongoTombstones     string = "user_tombstones"
+	mongoAPIKeys        string = "api_keys"
+	mongoIdentities     string = "linked_identities"
+	mongoFieldLocks     string = "user_field_locks"
+	mongoLogins         string = "user_logins"
+	mongoLockouts       string = "user_lockouts"
+	mongoResetTokens    string = "password_reset_tokens"
+	mongoSessions       string = "sessions"
+	mongoTOTP           string = "user_totp"
+	mongoVerifications  string = "phone_verifications"
+	mongoLocks          string = "locks"
+	mongoBootstrapLock  string = "bootstrap"
+	emailUniqueIndex    string = "users_scoped_email_key"
+	nicknameUniqueIndex string = "users_scoped_nickname_key"
+	identityUniqueIndex string = "linked_identities_pkey"
+)
+
+// globalUniqueIndexes are the unique indexes of the users before the uniqueness scopes,
+// dropped by EnsureIndexes.
+var globalUniqueIndexes = []string{"users_email_key", nicknameUniqueConstraint}
+
+// mongoRegistry encodes the storage models with their db tags, so the documents have
+// the column names of the SQL repositories, and stores the ids in _id. Embedded
+// structs are inlined, like sqlx does, and the fields tagged bson:"-" are skipped.
+var mongoRegistry = func() *bsoncodec.Registry {
+	codec, err := bsoncodec.NewStructCodec(bsoncodec.StructTagParserFunc(
+		func(sf reflect.StructField) (bsoncodec.StructTags, error) {
+			if sf.Anonymous {
+				return bsoncodec.StructTags{Name: strings.ToLower(sf.Name), Inline: true}, nil
+			}
+
+			if sf.Tag.Get("bson") == "-" {
+				return bsoncodec.StructTags{Skip: true}, nil
+			}
+
+			name := sf.Tag.Get("db")
+			switch name {
+			case "":
+				name = strings.ToLower(sf.Name)
+			case "id":
+				name = "_id"
+			}
+			return bsoncodec.StructTags{Name: name}, nil
+		},
+	))
+	if err != nil {
+		panic(fmt.Sprintf("could not create mongo struct codec: %s", err))
+	}
+
+	rb := bson.NewRegistryBuilder()
+	rb.RegisterDefaultEncoder(reflect.Struct, codec)
+	rb.RegisterDefaultDecoder(reflect.Struct, codec)
+	return rb.Build()
+}()
+
+// tombstone is the document left by a user merged into another one.
+type tombstone struct {
+	ID         string    `db:"id"`
+	MergedInto string    `db:"merged_into"`
+	FirstName  string    `db:"first_name"`
+	LastName   string    `db:"last_name"`
+	Nickname   string    `db:"nickname"`
+	Email      string    `db:"email"`
+	Country    string    `db:"country"`
+	CreatedAt  time.Time `db:"created_at"`
+	MergedAt   time.Time `db:"merged_at"`
+}
+
+// Mongo is a repository implementation for MongoDB. The users are stored in the users
+// collection with their id in _id, so the pages are ordered and resumed by _id.
+//
+// The writes spanning several documents use transactions, which require a replica set.
+// There are no foreign keys: the writes referencing a user check that it exists, and
+// deleting a user deletes the documents referencing it. Times are stored with
+// millisecond precision.
+type Mongo struct {
+	db    *mongo.Database
+	scope UniquenessScope
+}
+
+// MongoOption is a function that configures the Mongo repository.
+type MongoOption func(*Mongo)
+
+// WithMongoUniquenessScope configures the scope of the email and nickname uniqueness.
+// It defaults to ScopeGlobal.
+func WithMongoUniquenessScope(scope UniquenessScope) MongoOption {
+	return func(m *Mongo) {
+		m.scope = scope
+	}
+}
+
+// NewMongo creates a new Mongo repository on the database. EnsureIndexes must be
+// called once before using it, like the migrations of the SQL repositories.
+func NewMongo(client *mongo.Client, database string, opts ...MongoOption) *Mongo {
+	m := &Mongo{
+		db:    client.Database(database, options.Database().SetRegistry(mongoRegistry)),
+		scope: ScopeGlobal,
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// EnsureIndexes creates the indexes of the collections, including the unique ones
+// the repository relies on to reject duplicates. Existing indexes are left as is.
+func (m *Mongo) EnsureIndexes(ctx context.Context) error {
+	indexes := map[string][]mongo.IndexModel{
+		mongoUsers: {
+			{Keys: bson.D{{Key: "uniqueness_key", Value: 1}, {Key: "email", Value: 1}}, Options: options.Index().SetName(emailUniqueIndex).SetUnique(true)},
+			{Keys: bson.D{{Key: "uniqueness_key", Value: 1}, {Key: "nickname", Value: 1}}, Options: options.Index().SetName(nicknameUniqueIndex).SetUnique(true)},
+			{Keys: bson.D{{Key: "email", Value: 1}}},
+			{Keys: bson.D{{Key: "country", Value: 1}, {Key: "_id", Value: 1}}},
+			{Keys: bson.D{{Key: "created_at", Value: 1}}},
+			{Keys: bson.D{{Key: "role", Value: 1}}},
+			{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "_id", Value: 1}}},
+		},
+		mongoTombstones: {
+			{Keys: bson.D{{Key: "merged_into", Value: 1}}},
+		},
+		mongoAPIKeys: {
+			{Keys: bson.D{{Key: "user_id", Value: 1}}},
+		},
+		mongoIdentities: {
+			{Keys: bson.D{{Key: "provider", Value: 1}, {Key: "subject", Value: 1}}, Options: options.Index().SetName(identityUniqueIndex).SetUnique(true)},
+			{Keys: bson.D{{Key: "user_id", Value: 1}}},
+		},
+		mongoFieldLocks: {
+			{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "field", Value: 1}}, Options: options.Index().SetUnique(true)},
+		},
+		mongoLogins: {
+			{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		},
+		mongoLockouts: {
+			{Keys: bson.D{{Key: "user_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		},
+		mongoResetTokens: {
+			{Keys: bson.D{{Key: "token_hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "user_id", Value: 1}}},
+		},
+		mongoSessions: {
+			{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		},
+		mongoTOTP: {
+			{Keys: bson.D{{Key: "user_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		},
+		mongoVerifications: {
+			{Keys: bson.D{{Key: "user_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		},
+	}
+
+	for collection, models := range indexes {
+		if _, err := m.db.Collection(collection).Indexes().CreateMany(ctx, models); err != nil {
+			return fmt.Errorf("could not create indexes of %s: %w", collection, err)
+		}
+	}
+
+	for _, name := range globalUniqueIndexes {
+		if _, err := m.db.Collection(mongoUsers).Indexes().DropOne(ctx, name); err != nil && !isIndexNotFound(err) {
+			return fmt.Errorf("could not drop index %s: %w", name, err)
+		}
+	}
+
+	// The users stored before the tenants belong to the default one, like the default of
+	// the tenant_id column of the SQL repositories.
+	if _, err := m.db.Collection(mongoUsers).UpdateMany(
+		ctx,
+		bson.M{"tenant_id": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"tenant_id": tenant.Default}},
+	); err != nil {
+		return fmt.Errorf("could not set the tenant of the users: %w", err)
+	}
+	return nil
+}
+
+// isIndexNotFound reports whether the error is the IndexNotFound error of the server.
+func isIndexNotFound(err error) bool {
+	var cmdErr mongo.CommandError
+	return errors.As(err, &cmdErr) && cmdErr.Name == "IndexNotFound"
+}
+
+// Get returns a user by id.
+func (m *Mongo) Get(ctx context.Context, id string) (*User, error) {
+	ctx, end := m.startQuery(ctx, "get")
+	defer end()
+
+	user, err := m.findUser(ctx, inTenantFilter(ctx, bson.M{"_id": id}))
+	if err != nil {
+		return nil, fmt.Errorf("could not get user: %w", err)
+	}
+	return user, nil
+}
+
+// GetByEmail returns a user by email, like the Postgres repository.
+func (m *Mongo) GetByEmail(ctx context.Context, email string) (*User, error) {
+	ctx, end := m.startQuery(ctx, "get_by_email")
+	defer end()
+
+	var users []*User
+	if err := m.findAll(
+		ctx,
+		mongoUsers,
+		inTenantFilter(ctx, bson.M{"email": email}),
+		options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(2),
+		&users,
+	); err != nil {
+		return nil, fmt.Errorf("could not get user by email: %w", err)
+	}
+
+	user, err := oneByEmail(users)
+	if err != nil {
+		return nil, fmt.Errorf("could not get user by email: %w", err)
+	}
+	return user, nil
+}
+
+// List returns a page of the users selected by the filter.
+func (m *Mongo) List(ctx context.Context, filter Filter, page Page) ([]*User, error) {
+	ctx, end := m.startQuery(ctx, "list")
+	defer end()
+
+	query := inTenantFilter(ctx, mongoFilter(filter))
+	if page.Cursor != "" {
+		query["_id"] = bson.M{"$gt": page.Cursor}
+	}
+
+	var users []*User
+	if err := m.findAll(
+		ctx,
+		mongoUsers,
+		query,
+		options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(int64(page.Limit)),
+		&users,
+	); err != nil {
+		return nil, fmt.Errorf("could not get users: %w", err)
+	}
+	return users, nil
+}
+
+// Count returns the number of users selected by the filter.
+func (m *Mongo) Count(ctx context.Context, filter Filter) (int64, error) {
+	ctx, end := m.startQuery(ctx, "count")
+	defer end()
+
+	count, err := m.db.Collection(mongoUsers).CountDocuments(ctx, inTenantFilter(ctx, mongoFilter(filter)))
+	if err != nil {
+		return 0, fmt.Errorf("could not count users: %w", err)
+	}
+	return count, nil
+}
+
+// NicknameExists reports whether a user has the nickname within the uniqueness scope of
+// the country. It only reads the unique index of the nicknames.
+func (m *Mongo) NicknameExists(ctx context.Context, nickname, country string) (bool, error) {
+	ctx, end := m.startQuery(ctx, "nickname_exists")
+	defer end()
+
+	count, err := m.db.Collection(mongoUsers).CountDocuments(
+		ctx,
+		bson.M{"uniqueness_key": m.scope.key(&User{Country: country, TenantID: tenant.OrDefault(ctx)}), "nickname": nickname},
+		options.Count().SetLimit(1),
+	)
+	if err != nil {
+		return false, fmt.Errorf("could not check nickname: %w", err)
+	}
+	return count > 0, nil
+}
+
+// Search returns the users with names or nickname containing words starting with every
+// word of the query, ordered by id like the in-memory repository. The page starts at offset.
+func (m *Mongo) Search(ctx context.Context, query string, offset, limit int) ([]*User, error) {
+	ctx, end := m.startQuery(ctx, "search")
+	defer end()
+
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	// The candidates have a word starting with every term in one of the fields, and
+	// are matched like the Postgres search vector here.
+	and := make(bson.A, 0, len(terms))
+	for _, term := range terms {
+		word := primitive.Regex{Pattern: `(^|[^\p{L}\p{N}])` + regexp.QuoteMeta(term), Options: "i"}
+		and = append(and, bson.M{"$or": bson.A{
+			bson.M{"first_name": word},
+			bson.M{"last_name": word},
+			bson.M{"nickname": word},
+		}})
+	}
+
+	cur, err := m.db.Collection(mongoUsers).Find(ctx, inTenantFilter(ctx, bson.M{"$and": and}), options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("could not search users: %w", err)
+	}
+	defer cur.Close(ctx)
+
+	var users []*User
+	for len(users) < limit && cur.Next(ctx) {
+		var user User
+		if err := cur.Decode(&user); err != nil {
+			return nil, fmt.Errorf("could not decode user: %w", err)
+		}
+
+		if !matchSearch(&user, terms) {
+			continue
+		}
+
+		if offset > 0 {
+			offset--
+			continue
+		}
+		users = append(users, &user)
+	}
+
+	if err := cur.Err(); err != nil {
+		return nil, fmt.Errorf("could not search users: %w", err)
+	}
+	return users, nil
+}
+
+// Insert inserts a new user.
+func (m *Mongo) Insert(ctx context.Context, user *User) error {
+	ctx, end := m.startQuery(ctx, "insert")
+	defer end()
+
+	if err := m.insertUser(ctx, user); err != nil {
+		return fmt.Errorf("could not insert user: %w", err)
+	}
+	return nil
+}
+
+// InsertBatch inserts the users in a transaction: either all of them are inserted or none is.
+func (m *Mongo) InsertBatch(ctx context.Context, users []*User) error {
+	ctx, end := m.startQuery(ctx, "insert_batch")
+	defer end()
+
+	if len(users) == 0 {
+		return nil
+	}
+
+	documents := make([]any, 0, len(users))
+	for _, user := range users {
+		user.setInsertDefaults()
+		documents = append(documents, m.scope.scoped(ctx, user))
+	}
+
+	if err := m.withTransaction(ctx, func(ctx mongo.SessionContext) error {
+		_, err := m.db.Collection(mongoUsers).InsertMany(ctx, documents)
+		return err
+	}); err != nil {
+		if dupErr := mongoDuplicateKeyError(err); dupErr != nil {
+			return fmt.Errorf("could not insert users: %w", dupErr)
+		}
+		return fmt.Errorf("could not insert users: %w", err)
+	}
+	return nil
+}
+
+// Update updates a user by id and sets the user event sequence to the incremented one.
+func (m *Mongo) Update(ctx context.Context, user *User) error {
+	ctx, end := m.startQuery(ctx, "update")
+	defer end()
+
+	if err := m.updateUser(ctx, user); err != nil {
+		return fmt.Errorf("could not update user: %w", err)
+	}
+	return nil
+}
+
+// Delete deletes a user, and the documents referencing it, by id and returns the event
+// sequence of the deletion.
+func (m *Mongo) Delete(ctx context.Context, id string) (int64, error) {
+	ctx, end := m.startQuery(ctx, "delete")
+	defer end()
+
+	var sequence int64
+	if err := m.withTransaction(ctx, func(ctx mongo.SessionContext) error {
+		var deleted User
+		if err := m.db.Collection(mongoUsers).FindOneAndDelete(ctx, inTenantFilter(ctx, bson.M{"_id": id})).Decode(&deleted); err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				return ErrUserNotFound
+			}
+			return err
+		}
+
+		sequence = deleted.EventSequence + 1
+		return m.deleteReferences(ctx, id)
+	}); err != nil {
+		return 0, fmt.Errorf("could not delete user: %w", err)
+	}
+	return sequence, nil
+}
+
+// Merge merges the duplicate user into the survivor in a single transaction, like Postgres.Merge.
+func (m *Mongo) Merge(ctx context.Context, survivor *User, duplicateID string) error {
+	ctx, end := m.startQuery(ctx, "merge")
+	defer end()
+
+	return m.withTransaction(ctx, func(ctx mongo.SessionContext) error {
+		var duplicate User
+		if err := m.db.Collection(mongoUsers).FindOneAndDelete(ctx, inTenantFilter(ctx, bson.M{"_id": duplicateID})).Decode(&duplicate); err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				return fmt.Errorf("could not tombstone duplicate user: %w", ErrUserNotFound)
+			}
+			return fmt.Errorf("could not delete duplicate user: %w", err)
+		}
+
+		if _, err := m.db.Collection(mongoTombstones).InsertOne(ctx, tombstone{
+			ID:         duplicate.ID,
+			MergedInto: survivor.ID,
+			FirstName:  duplicate.FirstName,
+			LastName:   duplicate.LastName,
+			Nickname:   duplicate.Nickname,
+			Email:      duplicate.Email,
+			Country:    duplicate.Country,
+			CreatedAt:  duplicate.CreatedAt,
+			MergedAt:   survivor.UpdatedAt,
+		}); err != nil {
+			return fmt.Errorf("could not tombstone duplicate user: %w", err)
+		}
+
+		// Documents referencing the duplicate in other collections must be moved to the survivor here.
+
+		moved := bson.M{"$set": bson.M{"user_id": survivor.ID}}
+		for _, collection := range []string{mongoAPIKeys, mongoIdentities, mongoLogins} {
+			if _, err := m.db.Collection(collection).UpdateMany(ctx, bson.M{"user_id": duplicateID}, moved); err != nil {
+				return fmt.Errorf("could not move %s: %w", strings.ReplaceAll(collection, "_", " "), err)
+			}
+		}
+
+		// The survivor's own locks win over the duplicate's, and the rest are deleted with the duplicate.
+		var locks []*FieldLock
+		if err := m.findAll(ctx, mongoFieldLocks, bson.M{"user_id": duplicateID}, nil, &locks); err != nil {
+			return fmt.Errorf("could not move field locks: %w", err)
+		}
+
+		for _, lock := range locks {
+			lock.UserID = survivor.ID
+			if _, err := m.db.Collection(mongoFieldLocks).UpdateOne(
+				ctx,
+				bson.M{"user_id": survivor.ID, "field": lock.Field},
+				bson.M{"$setOnInsert": lock},
+				options.Update().SetUpsert(true),
+			); err != nil {
+				return fmt.Errorf("could not move field locks: %w", err)
+			}
+		}
+
+		if err := m.deleteReferences(ctx, duplicateID); err != nil {
+			return fmt.Errorf("could not delete duplicate user: %w", err)
+		}
+
+		if err := m.updateUser(ctx, survivor); err != nil {
+			return fmt.Errorf("could not update surviving user: %w", err)
+		}
+		return nil
+	})
+}
+
+// Anonymize anonymizes the user in a single transaction, like Postgres.Anonymize.
+func (m *Mongo) Anonymize(ctx context.Context, user *User) error {
+	ctx, end := m.startQuery(ctx, "anonymize")
+	defer end()
+
+	if err := m.withTransaction(ctx, func(ctx mongo.SessionContext) error {
+		if err := m.updateUser(ctx, user); err != nil {
+			return fmt.Errorf("could not anonymize user: %w", err)
+		}
+
+		if _, err := m.db.Collection(mongoUsers).UpdateOne(
+			ctx,
+			bson.M{"_id": user.ID},
+			bson.M{"$set": bson.M{"anonymized": true, "avatar_key": "", "avatar_hash": ""}},
+		); err != nil {
+			return fmt.Errorf("could not anonymize user: %w", err)
+		}
+
+		if _, err := m.db.Collection(mongoTombstones).UpdateMany(
+			ctx,
+			bson.M{"merged_into": user.ID},
+			bson.M{"$set": bson.M{
+				"first_name": user.FirstName,
+				"last_name":  user.LastName,
+				"nickname":   user.Nickname,
+				"email":      user.Email,
+			}},
+		); err != nil {
+			return fmt.Errorf("could not anonymize tombstones: %w", err)
+		}
+		return m.deleteReferences(ctx, user.ID)
+	}); err != nil {
+		return err
+	}
+
+	user.Anonymized = true
+	user.AvatarKey = ""
+	user.AvatarHash = ""
+	return nil
+}
+
+// SetStatus sets the status of the user, like Postgres.SetStatus.
+func (m *Mongo) SetStatus(ctx context.Context, user *User) error {
+	ctx, end := m.startQuery(ctx, "set_status")
+	defer end()
+
+	var updated User
+	if err := m.db.Collection(mongoUsers).FindOneAndUpdate(
+		ctx,
+		inTenantFilter(ctx, bson.M{"_id": user.ID}),
+		bson.M{
+			"$set": bson.M{"status": user.Status, "updated_at": user.UpdatedAt},
+			"$inc": bson.M{"event_sequence": 1},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return fmt.Errorf("could not set user status: %w", ErrUserNotFound)
+		}
+		return fmt.Errorf("could not set user status: %w", err)
+	}
+
+	user.EventSequence = updated.EventSequence
+	return nil
+}
+
+// SetAvatar sets the avatar key and hash of the user, like Postgres.SetAvatar.
+func (m *Mongo) SetAvatar(ctx context.Context, user *User) error {
+	ctx, end := m.startQuery(ctx, "set_avatar")
+	defer end()
+
+	var updated User
+	if err := m.db.Collection(mongoUsers).FindOneAndUpdate(
+		ctx,
+		inTenantFilter(ctx, bson.M{"_id": user.ID}),
+		bson.M{
+			"$set": bson.M{"avatar_key": user.AvatarKey, "avatar_hash": user.AvatarHash, "updated_at": user.UpdatedAt},
+			"$inc": bson.M{"event_sequence": 1},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return fmt.Errorf("could not set user avatar: %w", ErrUserNotFound)
+		}
+		return fmt.Errorf("could not set user avatar: %w", err)
+	}
+
+	user.EventSequence = updated.EventSequence
+	return nil
+}
+
+// Bootstrap inserts the first admin user and its API key, unless an admin user already exists.
+// Concurrent bootstraps write the same lock document, so only one of their transactions
+// commits and the other ones are retried and see the admin user.
+func (m *Mongo) Bootstrap(ctx context.Context, admin *User, key *APIKey) error {
+	ctx, end := m.startQuery(ctx, "bootstrap")
+	defer end()
+
+	return m.withTransaction(ctx, func(ctx mongo.SessionContext) error {
+		if _, err := m.db.Collection(mongoLocks).UpdateOne(
+			ctx,
+			bson.M{"_id": mongoBootstrapLock},
+			bson.M{"$inc": bson.M{"count": 1}},
+			options.Update().SetUpsert(true),
+		); err != nil {
+			return fmt.Errorf("could not lock bootstrap: %w", err)
+		}
+
+		// Every tenant is bootstrapped on its own.
+		admins, err := m.db.Collection(mongoUsers).CountDocuments(
+			ctx,
+			bson.M{"role": RoleAdmin, "tenant_id": tenant.OrDefault(ctx)},
+			options.Count().SetLimit(1),
+		)
+		if err != nil {
+			return fmt.Errorf("could not check for admin users: %w", err)
+		}
+
+		if admins > 0 {
+			return fmt.Errorf("could not bootstrap: %w", ErrAlreadyBootstrapped)
+		}
+
+		admin.Role = RoleAdmin
+		if err := m.insertUser(ctx, admin); err != nil {
+			return fmt.Errorf("could not insert admin user: %w", err)
+		}
+
+		if _, err := m.db.Collection(mongoAPIKeys).InsertOne(ctx, key); err != nil {
+			return fmt.Errorf("could not insert api key: %w", err)
+		}
+		return nil
+	})
+}
+
+// InsertAPIKey inserts an API key.
+func (m *Mongo) InsertAPIKey(ctx context.Context, key *APIKey) error {
+	ctx, end := m.startQuery(ctx, "insert_api_key")
+	defer end()
+
+	if err := m.checkUserExists(ctx, key.UserID); err != nil {
+		return fmt.Errorf("could not insert api key: %w", err)
+	}
+
+	if _, err := m.db.Collection(mongoAPIKeys).InsertOne(ctx, key); err != nil {
+		return fmt.Errorf("could not insert api key: %w", err)
+	}
+	return nil
+}
+
+// GetAPIKey returns an API key by id.
+func (m *Mongo) GetAPIKey(ctx context.Context, id string) (*APIKey, error) {
+	ctx, end := m.startQuery(ctx, "get_api_key")
+	defer end()
+
+	var key APIKey
+	if err := m.db.Collection(mongoAPIKeys).FindOne(ctx, bson.M{"_id": id}).Decode(&key); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, fmt.Errorf("could not get api key: %w", ErrAPIKeyNotFound)
+		}
+		return nil, fmt.Errorf("could not get api key: %w", err)
+	}
+	return &key, nil
+}
+
+// GetAPIKeys returns the API keys of a user, oldest first.
+func (m *Mongo) GetAPIKeys(ctx context.Context, userID string) ([]*APIKey, error) {
+	ctx, end := m.startQuery(ctx, "get_api_keys")
+	defer end()
+
+	var keys []*APIKey
+	if err := m.findAll(
+		ctx,
+		mongoAPIKeys,
+		bson.M{"user_id": userID},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}),
+		&keys,
+	); err != nil {
+		return nil, fmt.Errorf("could not get api keys: %w", err)
+	}
+	return keys, nil
+}
+
+// DeleteAPIKey deletes an API key by id.
+func (m *Mongo) DeleteAPIKey(ctx context.Context, id string) error {
+	ctx, end := m.startQuery(ctx, "delete_api_key")
+	defer end()
+
+	res, err := m.db.Collection(mongoAPIKeys).DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return fmt.Errorf("could not delete api key: %w", err)
+	}
+
+	if res.DeletedCount == 0 {
+		return fmt.Errorf("could not delete api key: %w", ErrAPIKeyNotFound)
+	}
+	return nil
+}
+
+// LinkIdentity links an external identity to a user.
+func (m *Mongo) LinkIdentity(ctx context.Context, identity *LinkedIdentity) error {
+	ctx, end := m.startQuery(ctx, "link_identity")
+	defer end()
+
+	if err := m.checkUserExists(ctx, identity.UserID); err != nil {
+		return fmt.Errorf("could not link identity: %w", err)
+	}
+
+	if _, err := m.db.Collection(mongoIdentities).InsertOne(ctx, identity); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return fmt.Errorf("could not link identity: %w", ErrIdentityLinked)
+		}
+		return fmt.Errorf("could not link identity: %w", err)
+	}
+	return nil
+}
+
+// GetLinkedIdentities returns the external identities linked to a user, oldest first.
+func (m *Mongo) GetLinkedIdentities(ctx context.Context, userID string) ([]*LinkedIdentity, error) {
+	ctx, end := m.startQuery(ctx, "get_linked_identities")
+	defer end()
+
+	var identities []*LinkedIdentity
+	if err := m.findAll(
+		ctx,
+		mongoIdentities,
+		bson.M{"user_id": userID},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "provider", Value: 1}}),
+		&identities,
+	); err != nil {
+		return nil, fmt.Errorf("could not get linked identities: %w", err)
+	}
+	return identities, nil
+}
+
+// GetByLinkedIdentity returns the user linked to the given external identity.
+func (m *Mongo) GetByLinkedIdentity(ctx context.Context, provider, subject string) (*User, error) {
+	ctx, end := m.startQuery(ctx, "get_by_linked_identity")
+	defer end()
+
+	var identity LinkedIdentity
+	if err := m.db.Collection(mongoIdentities).FindOne(ctx, bson.M{"provider": provider, "subject": subject}).Decode(&identity); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, fmt.Errorf("could not get user by linked identity: %w", ErrUserNotFound)
+		}
+		return nil, fmt.Errorf("could not get user by linked identity: %w", err)
+	}
+
+	user, err := m.findUser(ctx, inTenantFilter(ctx, bson.M{"_id": identity.UserID}))
+	if err != nil {
+		return nil, fmt.Errorf("could not get user by linked identity: %w", err)
+	}
+	return user, nil
+}
+
+// UnlinkIdentity unlinks an external identity from a user.
+func (m *Mongo) UnlinkIdentity(ctx context.Context, userID, provider, subject string) error {
+	ctx, end := m.startQuery(ctx, "unlink_identity")
+	defer end()
+
+	res, err := m.db.Collection(mongoIdentities).DeleteOne(ctx, bson.M{"user_id": userID, "provider": provider, "subject": subject})
+	if err != nil {
+		return fmt.Errorf("could not unlink identity: %w", err)
+	}
+
+	if res.DeletedCount == 0 {
+		return fmt.Errorf("could not unlink identity: %w", ErrIdentityNotFound)
+	}
+	return nil
+}
+
+// LockField locks a field of a user, replacing the reason of an existing lock.
+func (m *Mongo) LockField(ctx context.Context, lock *FieldLock) error {
+	ctx, end := m.startQuery(ctx, "lock_field")
+	defer end()
+
+	if err := m.checkUserExists(ctx, lock.UserID); err != nil {
+		return fmt.Errorf("could not lock field: %w", err)
+	}
+
+	if _, err := m.db.Collection(mongoFieldLocks).ReplaceOne(
+		ctx,
+		bson.M{"user_id": lock.UserID, "field": lock.Field},
+		lock,
+		options.Replace().SetUpsert(true),
+	); err != nil {
+		return fmt.Errorf("could not lock field: %w", err)
+	}
+	return nil
+}
+
+// UnlockField unlocks a field of a user. Unlocking a field that is not locked is a no-op.
+func (m *Mongo) UnlockField(ctx context.Context, userID, field string) error {
+	ctx, end := m.startQuery(ctx, "unlock_field")
+	defer end()
+
+	if _, err := m.db.Collection(mongoFieldLocks).DeleteOne(ctx, bson.M{"user_id": userID, "field": field}); err != nil {
+		return fmt.Errorf("could not unlock field: %w", err)
+	}
+	return nil
+}
+
+// GetFieldLocks returns the locked fields of a user, ordered by field.
+func (m *Mongo) GetFieldLocks(ctx context.Context, userID string) ([]*FieldLock, error) {
+	ctx, end := m.startQuery(ctx, "get_field_locks")
+	defer end()
+
+	var locks []*FieldLock
+	if err := m.findAll(
+		ctx,
+		mongoFieldLocks,
+		bson.M{"user_id": userID},
+		options.Find().SetSort(bson.D{{Key: "field", Value: 1}}),
+		&locks,
+	); err != nil {
+		return nil, fmt.Errorf("could not get field locks: %w", err)
+	}
+	return locks, nil
+}
+
+// RecordLogin records a successful login.
+func (m *Mongo) RecordLogin(ctx context.Context, login *Login) error {
+	ctx, end := m.startQuery(ctx, "record_login")
+	defer end()
+
+	if err := m.checkUserExists(ctx, login.UserID); err != nil {
+		return fmt.Errorf("could not record login: %w", err)
+	}
+
+	if _, err := m.db.Collection(mongoLogins).InsertOne(ctx, login); err != nil {
+		return fmt.Errorf("could not record login: %w", err)
+	}
+	return nil
+}
+
+// GetRecentLogins returns the last logins of a user, most recent first.
+func (m *Mongo) GetRecentLogins(ctx context.Context, userID string, limit int) ([]*Login, error) {
+	ctx, end := m.startQuery(ctx, "get_recent_logins")
+	defer end()
+
+	var logins []*Login
+	if err := m.findAll(
+		ctx,
+		mongoLogins,
+		bson.M{"user_id": userID},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(int64(limit)),
+		&logins,
+	); err != nil {
+		return nil, fmt.Errorf("could not get recent logins: %w", err)
+	}
+	return logins, nil
+}
+
+// GetLockout returns the failed logins of a user. A user without failed logins
+// gets a lockout with no attempts.
+func (m *Mongo) GetLockout(ctx context.Context, userID string) (*Lockout, error) {
+	ctx, end := m.startQuery(ctx, "get_lockout")
+	defer end()
+
+	var lockout Lockout
+	if err := m.db.Collection(mongoLockouts).FindOne(ctx, bson.M{"user_id": userID}).Decode(&lockout); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return &Lockout{UserID: userID}, nil
+		}
+		return nil, fmt.Errorf("could not get lockout: %w", err)
+	}
+	return &lockout, nil
+}
+
+// RecordFailedLogin counts a failed login of a user and returns the updated lockout.
+func (m *Mongo) RecordFailedLogin(ctx context.Context, userID string) (*Lockout, error) {
+	ctx, end := m.startQuery(ctx, "record_failed_login")
+	defer end()
+
+	if err := m.checkUserExists(ctx, userID); err != nil {
+		return nil, fmt.Errorf("could not record failed login: %w", err)
+	}
+
+	var lockout Lockout
+	if err := m.db.Collection(mongoLockouts).FindOneAndUpdate(
+		ctx,
+		bson.M{"user_id": userID},
+		bson.M{
+			"$inc":         bson.M{"failed_attempts": 1},
+			"$setOnInsert": bson.M{"lockouts": 0, "locked_until": time.Time{}},
+		},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&lockout); err != nil {
+		return nil, fmt.Errorf("could not record failed login: %w", err)
+	}
+	return &lockout, nil
+}
+
+// LockUser locks a user out until the given time, starting over the count of failed logins.
+func (m *Mongo) LockUser(ctx context.Context, userID string, until time.Time) error {
+	ctx, end := m.startQuery(ctx, "lock_user")
+	defer end()
+
+	if _, err := m.db.Collection(mongoLockouts).UpdateOne(
+		ctx,
+		bson.M{"user_id": userID},
+		bson.M{
+			"$set": bson.M{"failed_attempts": 0, "locked_until": until},
+			"$inc": bson.M{"lockouts": 1},
+		},
+	); err != nil {
+		return fmt.Errorf("could not lock user: %w", err)
+	}
+	return nil
+}
+
+// ResetLockout forgets the failed logins and the lockouts of a user, unlocking it.
+func (m *Mongo) ResetLockout(ctx context.Context, userID string) error {
+	ctx, end := m.startQuery(ctx, "reset_lockout")
+	defer end()
+
+	if _, err := m.db.Collection(mongoLockouts).DeleteOne(ctx, bson.M{"user_id": userID}); err != nil {
+		return fmt.Errorf("could not reset lockout: %w", err)
+	}
+	return nil
+}
+
+// InsertSession inserts a session. The expired sessions of the user are deleted on the way.
+func (m *Mongo) InsertSession(ctx context.Context, session *Session) error {
+	ctx, end := m.startQuery(ctx, "insert_session")
+	defer end()
+
+	if err := m.checkUserExists(ctx, session.UserID); err != nil {
+		return fmt.Errorf("could not insert session: %w", err)
+	}
+
+	sessions := m.db.Collection(mongoSessions)
+	if _, err := sessions.DeleteMany(ctx, bson.M{"user_id": session.UserID, "expires_at": bson.M{"$lte": session.CreatedAt}}); err != nil {
+		return fmt.Errorf("could not delete expired sessions: %w", err)
+	}
+
+	if _, err := sessions.InsertOne(ctx, session); err != nil {
+		return fmt.Errorf("could not insert session: %w", err)
+	}
+	return nil
+}
+
+// GetSession returns a session by id.
+func (m *Mongo) GetSession(ctx context.Context, id string) (*Session, error) {
+	ctx, end := m.startQuery(ctx, "get_session")
+	defer end()
+
+	var session Session
+	if err := m.db.Collection(mongoSessions).FindOne(ctx, bson.M{"_id": id}).Decode(&session); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, fmt.Errorf("could not get session: %w", ErrSessionNotFound)
+		}
+		return nil, fmt.Errorf("could not get session: %w", err)
+	}
+	return &session, nil
+}
+
+// GetSessions returns the sessions of a user, most recent first.
+func (m *Mongo) GetSessions(ctx context.Context, userID string) ([]*Session, error) {
+	ctx, end := m.startQuery(ctx, "get_sessions")
+	defer end()
+
+	var sessions []*Session
+	if err := m.findAll(
+		ctx,
+		mongoSessions,
+		bson.M{"user_id": userID},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}),
+		&sessions,
+	); err != nil {
+		return nil, fmt.Errorf("could not get sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+// RotateSession replaces the tokens and the expiry of the session, given the hash of its
+// current refresh token. It fails with ErrSessionNotFound if the refresh token was rotated
+// meanwhile, so a refresh token can only be used once.
+func (m *Mongo) RotateSession(ctx context.Context, session *Session, refreshTokenHash []byte) error {
+	ctx, end := m.startQuery(ctx, "rotate_session")
+	defer end()
+
+	res, err := m.db.Collection(mongoSessions).UpdateOne(
+		ctx,
+		bson.M{"_id": session.ID, "refresh_token_hash": refreshTokenHash},
+		bson.M{"$set": bson.M{
+			"access_token_hash":       session.AccessTokenHash,
+			"refresh_token_hash":      session.RefreshTokenHash,
+			"refreshed_at":            session.RefreshedAt,
+			"access_token_expires_at": session.AccessTokenExpiresAt,
+			"expires_at":              session.ExpiresAt,
+		}},
+	)
+	if err != nil {
+		return fmt.Errorf("could not rotate session: %w", err)
+	}
+
+	if res.MatchedCount == 0 {
+		return fmt.Errorf("could not rotate session: %w", ErrSessionNotFound)
+	}
+	return nil
+}
+
+// DeleteSession deletes a session by id.
+func (m *Mongo) DeleteSession(ctx context.Context, id string) error {
+	ctx, end := m.startQuery(ctx, "delete_session")
+	defer end()
+
+	res, err := m.db.Collection(mongoSessions).DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return fmt.Errorf("could not delete session: %w", err)
+	}
+
+	if res.DeletedCount == 0 {
+		return fmt.Errorf("could not delete session: %w", ErrSessionNotFound)
+	}
+	return nil
+}
+
+// InsertTOTP inserts the pending TOTP enrollment of a user, replacing a pending one.
+// It fails with ErrTOTPEnabled if the user already enabled TOTP: the upsert then
+// conflicts with the enabled enrollment on the unique user_id index.
+func (m *Mongo) InsertTOTP(ctx context.Context, totp *TOTP) error {
+	ctx, end := m.startQuery(ctx, "insert_totp")
+	defer end()
+
+	if err := m.checkUserExists(ctx, totp.UserID); err != nil {
+		return fmt.Errorf("could not insert totp: %w", err)
+	}
+
+	if _, err := m.db.Collection(mongoTOTP).UpdateOne(
+		ctx,
+		bson.M{"user_id": totp.UserID, "enabled": false},
+		bson.M{"$set": totp},
+		options.Update().SetUpsert(true),
+	); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return fmt.Errorf("could not insert totp: %w", ErrTOTPEnabled)
+		}
+		return fmt.Errorf("could not insert totp: %w", err)
+	}
+	return nil
+}
+
+// GetTOTP returns the TOTP enrollment of a user.
+func (m *Mongo) GetTOTP(ctx context.Context, userID string) (*TOTP, error) {
+	ctx, end := m.startQuery(ctx, "get_totp")
+	defer end()
+
+	var totp TOTP
+	if err := m.db.Collection(mongoTOTP).FindOne(ctx, bson.M{"user_id": userID}).Decode(&totp); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, fmt.Errorf("could not get totp: %w", ErrTOTPNotFound)
+		}
+		return nil, fmt.Errorf("could not get totp: %w", err)
+	}
+	return &totp, nil
+}
+
+// UseTOTP records the use of a code of the time step, enabling TOTP on first use. It fails
+// with ErrTOTPCodeUsed if a code of that step or a later one was used, so codes can't be replayed.
+func (m *Mongo) UseTOTP(ctx context.Context, userID string, step int64) error {
+	ctx, end := m.startQuery(ctx, "use_totp")
+	defer end()
+
+	res, err := m.db.Collection(mongoTOTP).UpdateOne(
+		ctx,
+		bson.M{"user_id": userID, "last_used_step": bson.M{"$lt": step}},
+		bson.M{"$set": bson.M{"enabled": true, "last_used_step": step}},
+	)
+	if err != nil {
+		return fmt.Errorf("could not use totp: %w", err)
+	}
+
+	if res.MatchedCount == 0 {
+		return fmt.Errorf("could not use totp: %w", ErrTOTPCodeUsed)
+	}
+	return nil
+}
+
+// InsertPhoneVerification inserts the phone verification of a user, replacing a pending one.
+func (m *Mongo) InsertPhoneVerification(ctx context.Context, verification *PhoneVerification) error {
+	ctx, end := m.startQuery(ctx, "insert_phone_verification")
+	defer end()
+
+	if err := m.checkUserExists(ctx, verification.UserID); err != nil {
+		return fmt.Errorf("could not insert phone verification: %w", err)
+	}
+
+	if _, err := m.db.Collection(mongoVerifications).ReplaceOne(
+		ctx,
+		bson.M{"user_id": verification.UserID},
+		verification,
+		options.Replace().SetUpsert(true),
+	); err != nil {
+		return fmt.Errorf("could not insert phone verification: %w", err)
+	}
+	return nil
+}
+
+// AttemptPhoneVerification counts an attempt to verify the phone of a user and returns the
+// phone verification, attempt included, if it has not expired by now.
+func (m *Mongo) AttemptPhoneVerification(ctx context.Context, userID string, now time.Time) (*PhoneVerification, error) {
+	ctx, end := m.startQuery(ctx, "attempt_phone_verification")
+	defer end()
+
+	var verification PhoneVerification
+	if err := m.db.Collection(mongoVerifications).FindOneAndUpdate(
+		ctx,
+		bson.M{"user_id": userID, "expires_at": bson.M{"$gt": now}},
+		bson.M{"$inc": bson.M{"attempts": 1}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&verification); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, fmt.Errorf("could not attempt phone verification: %w", ErrPhoneVerificationNotFound)
+		}
+		return nil, fmt.Errorf("could not attempt phone verification: %w", err)
+	}
+	return &verification, nil
+}
+
+// DeletePhoneVerification deletes the phone verification of a user, if any.
+func (m *Mongo) DeletePhoneVerification(ctx context.Context, userID string) error {
+	ctx, end := m.startQuery(ctx, "delete_phone_verification")
+	defer end()
+
+	if _, err := m.db.Collection(mongoVerifications).DeleteOne(ctx, bson.M{"user_id": userID}); err != nil {
+		return fmt.Errorf("could not delete phone verification: %w", err)
+	}
+	return nil
+}
+
+// InsertPasswordResetToken inserts a password reset token.
+// The expired tokens of the user are deleted on the way.
+func (m *Mongo) InsertPasswordResetToken(ctx context.Context, token *PasswordResetToken) error {
+	ctx, end := m.startQuery(ctx, "insert_password_reset_token")
+	defer end()
+
+	if err := m.checkUserExists(ctx, token.UserID); err != nil {
+		return fmt.Errorf("could not insert password reset token: %w", err)
+	}
+
+	tokens := m.db.Collection(mongoResetTokens)
+	if _, err := tokens.DeleteMany(ctx, bson.M{"user_id": token.UserID, "expires_at": bson.M{"$lte": token.CreatedAt}}); err != nil {
+		return fmt.Errorf("could not delete expired password reset tokens: %w", err)
+	}
+
+	if _, err := tokens.InsertOne(ctx, token); err != nil {
+		return fmt.Errorf("could not insert password reset token: %w", err)
+	}
+	return nil
+}
+
+// ResetPassword consumes the password reset token, if it has not expired by now, and sets
+// the password of its user in a single transaction, like Postgres.ResetPassword.
+func (m *Mongo) ResetPassword(ctx context.Context, tokenHash []byte, password string, now time.Time) (*User, error) {
+	ctx, end := m.startQuery(ctx, "reset_password")
+	defer end()
+
+	var user User
+	if err := m.withTransaction(ctx, func(ctx mongo.SessionContext) error {
+		tokens := m.db.Collection(mongoResetTokens)
+
+		var token PasswordResetToken
+		if err := tokens.FindOneAndDelete(ctx, bson.M{"token_hash": tokenHash, "expires_at": bson.M{"$gt": now}}).Decode(&token); err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				return fmt.Errorf("could not reset password: %w", ErrResetTokenNotFound)
+			}
+			return fmt.Errorf("could not consume password reset token: %w", err)
+		}
+
+		if err := m.db.Collection(mongoUsers).FindOneAndUpdate(
+			ctx,
+			inTenantFilter(ctx, bson.M{"_id": token.UserID}),
+			bson.M{
+				"$set": bson.M{"password": password, "updated_at": now},
+				"$inc": bson.M{"event_sequence": 1},
+			},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&user); err != nil {
+			// The token of a user of another tenant is unknown to this one.
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				return fmt.Errorf("could not reset password: %w", ErrResetTokenNotFound)
+			}
+			return fmt.Errorf("could not update password: %w", err)
+		}
+
+		if _, err := tokens.DeleteMany(ctx, bson.M{"user_id": token.UserID}); err != nil {
+			return fmt.Errorf("could not delete password reset tokens: %w", err)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// CountByCountry returns the number of users per country.
+func (m *Mongo) CountByCountry(ctx context.Context) (map[string]int64, error) {
+	ctx, end := m.startQuery(ctx, "count_by_country")
+	defer end()
+
+	cur, err := m.db.Collection(mongoUsers).Aggregate(ctx, bson.A{
+		bson.M{"$match": inTenantFilter(ctx, bson.M{})},
+		bson.M{"$group": bson.M{"_id": "$country", "count": bson.M{"$sum": 1}}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not count users by country: %w", err)
+	}
+
+	// The groups are keyed by country in _id.
+	var rows []struct {
+		Country string `db:"id"`
+		Count   int64  `db:"count"`
+	}
+	if err := cur.All(ctx, &rows); err != nil {
+		return nil, fmt.Errorf("could not count users by country: %w", err)
+	}
+
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Country] = row.Count
+	}
+	return counts, nil
+}
+
+// ApplyUniquenessScope recomputes the uniqueness key of the users like the Postgres
+// repository, in a transaction.
+func (m *Mongo) ApplyUniquenessScope(ctx context.Context) (int64, error) {
+	ctx, end := m.startQuery(ctx, "apply_uniqueness_scope")
+	defer end()
+
+	// The key expression mirrors UniquenessScope.key.
+	key := any("")
+	if m.scope == ScopeCountry {
+		key = "$country"
+	}
+	key = bson.M{"$cond": bson.A{
+		bson.M{"$eq": bson.A{"$tenant_id", tenant.Default}},
+		key,
+		bson.M{"$concat": bson.A{"$tenant_id", "/", key}},
+	}}
+
+	filter := bson.M{"$expr": bson.M{"$ne": bson.A{"$uniqueness_key", key}}}
+	update := bson.A{bson.M{"$set": bson.M{"uniqueness_key": key}}}
+
+	var updated int64
+	if err := m.withTransaction(ctx, func(ctx mongo.SessionContext) error {
+		res, err := m.db.Collection(mongoUsers).UpdateMany(ctx, filter, update)
+		if err != nil {
+			if dupErr := mongoDuplicateKeyError(err); dupErr != nil {
+				return dupErr
+			}
+			return err
+		}
+
+		updated = res.ModifiedCount
+		return nil
+	}); err != nil {
+		return 0, fmt.Errorf("could not apply uniqueness scope: %w", err)
+	}
+	return updated, nil
+}
+
+// CheckDatabaseHealth checks if the database is healthy by pinging it.
+func (m *Mongo) CheckDatabaseHealth(ctx context.Context) error {
+	if err := m.db.Client().Ping(ctx, nil); err != nil {
+		return fmt.Errorf("could not ping database: %w", err)
+	}
+	return nil
+}
+
+func (m *Mongo) findUser(ctx context.Context, filter bson.M) (*User, error) {
+	var user User
+	if err := m.db.Collection(mongoUsers).FindOne(ctx, filter).Decode(&user); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// findAll decodes the documents of the collection selected by the filter into results,
+// a pointer to a slice.
+func (m *Mongo) findAll(ctx context.Context, collection string, filter bson.M, opts *options.FindOptions, results any) error {
+	cur, err := m.db.Collection(collection).Find(ctx, filter, opts)
+	if err != nil {
+		return err
+	}
+	return cur.All(ctx, results)
+}
+
+// checkUserExists returns ErrUserNotFound if there is no user with the id.
+func (m *Mongo) checkUserExists(ctx context.Context, id string) error {
+	count, err := m.db.Collection(mongoUsers).CountDocuments(ctx, bson.M{"_id": id}, options.Count().SetLimit(1))
+	if err != nil {
+		return fmt.Errorf("could not check user: %w", err)
+	}
+
+	if count == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// deleteReferences deletes the documents referencing the user, like the cascading
+// foreign keys of the SQL repositories.
+func (m *Mongo) deleteReferences(ctx context.Context, userID string) error {
+	for _, collection := range []string{mongoAPIKeys, mongoIdentities, mongoFieldLocks, mongoLogins, mongoLockouts, mongoResetTokens, mongoSessions, mongoTOTP, mongoVerifications} {
+		if _, err := m.db.Collection(collection).DeleteMany(ctx, bson.M{"user_id": userID}); err != nil {
+			return fmt.Errorf("could not delete %s: %w", strings.ReplaceAll(collection, "_", " "), err)
+		}
+	}
+	return nil
+}
+
+// insertUser inserts the user like insertUser.
+func (m *Mongo) insertUser(ctx context.Context, user *User) error {
+	user.setInsertDefaults()
+
+	if _, err := m.db.Collection(mongoUsers).InsertOne(ctx, m.scope.scoped(ctx, user)); err != nil {
+		if dupErr := mongoDuplicateKeyError(err); dupErr != nil {
+			return dupErr
+		}
+		return err
+	}
+	return nil
+}
+
+// updateUser updates the user, increments its event sequence and sets it on the user.
+func (m *Mongo) updateUser(ctx context.Context, user *User) error {
+	scoped := m.scope.scoped(ctx, user)
+
+	var updated User
+	if err := m.db.Collection(mongoUsers).FindOneAndUpdate(
+		ctx,
+		inTenantFilter(ctx, bson.M{"_id": user.ID}),
+		bson.M{
+			"$set": bson.M{
+				"first_name": user.FirstName,
+				"last_name":  user.LastName,
+				"nickname":   user.Nickname,
+				"password":   user.Password,
+				"email":      user.Email,
+				"country":    user.Country,
+				"metadata":   user.Metadata,
+				"updated_at": user.UpdatedAt,
+
+				"phone":          user.Phone,
+				"phone_verified": user.PhoneVerified,
+				"locale":         user.Locale,
+				"timezone":       user.Timezone,
+				"birthdate":      user.Birthdate,
+
+				"uniqueness_key": scoped.UniquenessKey,
+			},
+			"$inc": bson.M{"event_sequence": 1},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return ErrUserNotFound
+		}
+
+		if dupErr := mongoDuplicateKeyError(err); dupErr != nil {
+			return dupErr
+		}
+		return err
+	}
+
+	user.EventSequence = updated.EventSequence
+	return nil
+}
+
+// withTransaction runs fn in a transaction, retried by the driver on transient errors.
+func (m *Mongo) withTransaction(ctx context.Context, fn func(ctx mongo.SessionContext) error) error {
+	session, err := m.db.Client().StartSession()
+	if err != nil {
+		return fmt.Errorf("could not start session: %w", err)
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(ctx mongo.SessionContext) (any, error) {
+		return nil, fn(ctx)
+	})
+	return err
+}
+
+// inTenantFilter adds the tenant ctx is scoped to, if any, to the filter of the users.
+func inTenantFilter(ctx context.Context, filter bson.M) bson.M {
+	if id := tenantOf(ctx); id != "" {
+		filter["tenant_id"] = id
+	}
+	return filter
+}
+
+// mongoFilter returns the query selecting the users of the filter. The case-insensitive
+// matches use anchored regular expressions.
+func mongoFilter(f Filter) bson.M {
+	query := bson.M{}
+	if f.Country != "" {
+		query["country"] = f.Country
+	}
+	if f.NicknamePrefix != "" {
+		query["nickname"] = primitive.Regex{Pattern: "^" + regexp.QuoteMeta(f.NicknamePrefix)}
+	}
+	if f.Email != "" {
+		query["email"] = equalFold(f.Email)
+	}
+	if f.FirstName != "" {
+		query["first_name"] = equalFold(f.FirstName)
+	}
+	if f.LastName != "" {
+		query["last_name"] = equalFold(f.LastName)
+	}
+
+	created := bson.M{}
+	if !f.CreatedAfter.IsZero() {
+		created["$gte"] = f.CreatedAfter
+	}
+	if !f.CreatedBefore.IsZero() {
+		created["$lt"] = f.CreatedBefore
+	}
+	if len(created) > 0 {
+		query["created_at"] = created
+	}
+	return query
+}
+
+func equalFold(s string) primitive.Regex {
+	return primitive.Regex{Pattern: "^" + regexp.QuoteMeta(s) + "$", Options: "i"}
+}
+
+// mongoDuplicateKeyError maps a duplicate key error to ErrDuplicateNickname or
+// ErrDuplicateEmail based on the violated index. It returns nil for any other error.
+func mongoDuplicateKeyError(err error) error {
+	if !mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
+
+	if strings.Contains(err.Error(), nicknameUniqueIndex) {
+		return ErrDuplicateNickname
+	}
+	return ErrDuplicateEmail
+}
+
+// startQuery starts a client span for the operation. The returned function ends the span.
+func (m *Mongo) startQuery(ctx context.Context, operation string) (context.Context, func()) {
+	ctx, span := tracer.Start(ctx, "mongo."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemMongoDB,
+			semconv.DBOperation(operation),
+			semconv.DBMongoDBCollection(mongoUsers),
+		),
+	)
+	return ctx, func() { span.End() }
+}
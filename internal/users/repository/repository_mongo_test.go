@@ -0,0 +1,345 @@
+//go:build integration
+// +build integration
+
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const defaultMongoURI string = "mongodb://localhost:27017/?replicaSet=rs0&directConnection=true"
+
+func TestMongoGetAndInsert(t *testing.T) {
+	repo := setupMongoHelper(t)
+
+	// Arrange
+	givenUser := newMongoUserHelper(t, "joedoe@foo.bar", "BR")
+	require.NoError(t, repo.Insert(context.TODO(), givenUser))
+
+	sameEmail := newMongoUserHelper(t, "joedoe@foo.bar", "US")
+	sameEmail.Nickname = "someoneelse"
+
+	sameNickname := newMongoUserHelper(t, "jdoe@foo.bar", "US")
+	sameNickname.Nickname = "joedoe"
+
+	// Act
+	actualUser, err := repo.Get(context.TODO(), givenUser.ID)
+	require.NoError(t, err)
+
+	byEmail, err := repo.GetByEmail(context.TODO(), "joedoe@foo.bar")
+	require.NoError(t, err)
+
+	_, notFoundErr := repo.Get(context.TODO(), uuid.New().String())
+	duplicateEmailErr := repo.Insert(context.TODO(), sameEmail)
+	duplicateNicknameErr := repo.Insert(context.TODO(), sameNickname)
+
+	// Assert
+	assert.Equal(t, givenUser, actualUser)
+	assert.Equal(t, givenUser.ID, byEmail.ID)
+	assert.True(t, errors.Is(notFoundErr, ErrUserNotFound))
+	assert.True(t, errors.Is(duplicateEmailErr, ErrDuplicateEmail))
+	assert.True(t, errors.Is(duplicateNicknameErr, ErrDuplicateNickname))
+}
+
+func TestMongoPaginationAndFilter(t *testing.T) {
+	repo := setupMongoHelper(t)
+
+	// Arrange
+	for i := 0; i < 5; i++ {
+		country := "BR"
+		if i%2 == 0 {
+			country = "US"
+		}
+		user := newMongoUserHelper(t, fmt.Sprintf("joedoe%d@foo.bar", i), country)
+		user.CreatedAt = user.CreatedAt.Add(time.Duration(i) * time.Hour)
+		require.NoError(t, repo.Insert(context.TODO(), user))
+	}
+
+	// Act
+	var all []*User
+	cursor := ""
+	for {
+		page, err := repo.List(context.TODO(), Filter{}, Page{Cursor: cursor, Limit: 2})
+		require.NoError(t, err)
+		all = append(all, page...)
+		if len(page) < 2 {
+			break
+		}
+		cursor = page[len(page)-1].ID
+	}
+
+	byCountry, err := repo.List(context.TODO(), Filter{Country: "US"}, Page{Limit: 10})
+	require.NoError(t, err)
+
+	filtered, err := repo.List(context.TODO(), Filter{
+		Email:        "JOEDOE3@foo.bar",
+		CreatedAfter: time.Time{}.Add(time.Hour),
+	}, Page{Limit: 10})
+	require.NoError(t, err)
+
+	count, err := repo.Count(context.TODO(), Filter{NicknamePrefix: "joedoe"})
+	require.NoError(t, err)
+
+	counts, err := repo.CountByCountry(context.TODO())
+	require.NoError(t, err)
+
+	// Assert
+	require.Len(t, all, 5)
+	for i := 1; i < len(all); i++ {
+		assert.Less(t, all[i-1].ID, all[i].ID)
+	}
+
+	assert.Len(t, byCountry, 3)
+
+	require.Len(t, filtered, 1)
+	assert.Equal(t, "joedoe3@foo.bar", filtered[0].Email)
+
+	assert.Equal(t, int64(5), count)
+	assert.Equal(t, map[string]int64{"BR": 2, "US": 3}, counts)
+}
+
+func TestMongoSearch(t *testing.T) {
+	repo := setupMongoHelper(t)
+
+	// Arrange
+	john := newMongoUserHelper(t, "johndoe@foo.bar", "US")
+	smith := newMongoUserHelper(t, "jsmith@foo.bar", "BR")
+	smith.LastName = "Smith"
+
+	for _, user := range []*User{john, smith} {
+		require.NoError(t, repo.Insert(context.TODO(), user))
+	}
+
+	// Act
+	byPrefixes, err := repo.Search(context.TODO(), "JO d", 0, 10)
+	require.NoError(t, err)
+
+	inTheMiddle, err := repo.Search(context.TODO(), "mith", 0, 10)
+	require.NoError(t, err)
+
+	// Assert
+	require.Len(t, byPrefixes, 1)
+	assert.Equal(t, john.ID, byPrefixes[0].ID)
+	assert.Empty(t, inTheMiddle)
+}
+
+func TestMongoUpdateAndDelete(t *testing.T) {
+	repo := setupMongoHelper(t)
+
+	// Arrange
+	user := newMongoUserHelper(t, "joedoe@foo.bar", "BR")
+	require.NoError(t, repo.Insert(context.TODO(), user))
+	require.NoError(t, repo.RecordLogin(context.TODO(), &Login{UserID: user.ID, Country: "BR", CreatedAt: user.CreatedAt}))
+
+	updated := *user
+	updated.FirstName = "Johnny"
+
+	// Act
+	updateErr := repo.Update(context.TODO(), &updated)
+	notFoundErr := repo.Update(context.TODO(), newMongoUserHelper(t, "unknown@foo.bar", "BR"))
+
+	sequence, deleteErr := repo.Delete(context.TODO(), user.ID)
+
+	// Assert
+	require.NoError(t, updateErr)
+	assert.Equal(t, int64(2), updated.EventSequence)
+	assert.True(t, errors.Is(notFoundErr, ErrUserNotFound))
+
+	require.NoError(t, deleteErr)
+	assert.Equal(t, int64(3), sequence)
+
+	// Deleting the user deletes its logins.
+	logins, err := repo.GetRecentLogins(context.TODO(), user.ID, 10)
+	require.NoError(t, err)
+	assert.Empty(t, logins)
+}
+
+func TestMongoMerge(t *testing.T) {
+	repo := setupMongoHelper(t)
+
+	// Arrange
+	survivor := newMongoUserHelper(t, "joedoe@foo.bar", "BR")
+	duplicate := newMongoUserHelper(t, "joe.doe@foo.bar", "BR")
+	require.NoError(t, repo.Insert(context.TODO(), survivor))
+	require.NoError(t, repo.Insert(context.TODO(), duplicate))
+
+	require.NoError(t, repo.LinkIdentity(context.TODO(), &LinkedIdentity{
+		Provider:  "google",
+		Subject:   "1234567890",
+		UserID:    duplicate.ID,
+		CreatedAt: survivor.CreatedAt,
+	}))
+
+	lock := func(userID, field, reason string) *FieldLock {
+		return &FieldLock{UserID: userID, Field: field, Reason: reason, LockedBy: "admin-id", CreatedAt: survivor.CreatedAt}
+	}
+	require.NoError(t, repo.LockField(context.TODO(), lock(survivor.ID, "email", "investigation")))
+	require.NoError(t, repo.LockField(context.TODO(), lock(duplicate.ID, "email", "other investigation")))
+	require.NoError(t, repo.LockField(context.TODO(), lock(duplicate.ID, "nickname", "reserved")))
+
+	merged := *survivor
+	merged.Email = duplicate.Email
+
+	// Act
+	err := repo.Merge(context.TODO(), &merged, duplicate.ID)
+	notFoundErr := repo.Merge(context.TODO(), &merged, uuid.New().String())
+
+	// Assert
+	require.NoError(t, err)
+	assert.True(t, errors.Is(notFoundErr, ErrUserNotFound))
+
+	stored, err := repo.Get(context.TODO(), survivor.ID)
+	require.NoError(t, err)
+	assert.Equal(t, duplicate.Email, stored.Email)
+
+	linked, err := repo.GetByLinkedIdentity(context.TODO(), "google", "1234567890")
+	require.NoError(t, err)
+	assert.Equal(t, survivor.ID, linked.ID)
+
+	locks, err := repo.GetFieldLocks(context.TODO(), survivor.ID)
+	require.NoError(t, err)
+	require.Len(t, locks, 2)
+	assert.Equal(t, "investigation", locks[0].Reason)
+	assert.Equal(t, "nickname", locks[1].Field)
+}
+
+func TestMongoBootstrap(t *testing.T) {
+	repo := setupMongoHelper(t)
+
+	newKey := func(userID string) *APIKey {
+		return &APIKey{ID: uuid.New().String(), UserID: userID, Name: "terraform", SecretHash: []byte("hash")}
+	}
+
+	// Arrange
+	admin := newMongoUserHelper(t, "admin@foo.bar", "BR")
+	adminKey := newKey(admin.ID)
+
+	// Act
+	err := repo.Bootstrap(context.TODO(), admin, adminKey)
+	require.NoError(t, err)
+
+	second := newMongoUserHelper(t, "admin2@foo.bar", "BR")
+	secondErr := repo.Bootstrap(context.TODO(), second, newKey(second.ID))
+
+	// Assert
+	assert.True(t, errors.Is(secondErr, ErrAlreadyBootstrapped))
+
+	storedKey, err := repo.GetAPIKey(context.TODO(), adminKey.ID)
+	require.NoError(t, err)
+	assert.Equal(t, adminKey, storedKey)
+}
+
+func TestMongoResetPassword(t *testing.T) {
+	repo := setupMongoHelper(t)
+
+	// Arrange
+	user := newMongoUserHelper(t, "joedoe@foo.bar", "BR")
+	require.NoError(t, repo.Insert(context.TODO(), user))
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	for _, token := range []*PasswordResetToken{
+		{TokenHash: []byte("valid"), UserID: user.ID, CreatedAt: now, ExpiresAt: now.Add(time.Hour)},
+		{TokenHash: []byte("expired"), UserID: user.ID, CreatedAt: now, ExpiresAt: now.Add(time.Minute)},
+	} {
+		require.NoError(t, repo.InsertPasswordResetToken(context.TODO(), token))
+	}
+
+	// Act
+	unknownErr := repo.InsertPasswordResetToken(context.TODO(), &PasswordResetToken{TokenHash: []byte("unknown"), UserID: uuid.New().String()})
+	_, expiredErr := repo.ResetPassword(context.TODO(), []byte("expired"), "new-password", now.Add(2*time.Minute))
+
+	reset, err := repo.ResetPassword(context.TODO(), []byte("valid"), "new-password", now.Add(2*time.Minute))
+	require.NoError(t, err)
+
+	_, reusedErr := repo.ResetPassword(context.TODO(), []byte("valid"), "new-password", now.Add(2*time.Minute))
+
+	// Assert
+	assert.True(t, errors.Is(unknownErr, ErrUserNotFound))
+	assert.True(t, errors.Is(expiredErr, ErrResetTokenNotFound))
+	assert.Equal(t, "new-password", reset.Password)
+	assert.Equal(t, int64(2), reset.EventSequence)
+	assert.True(t, errors.Is(reusedErr, ErrResetTokenNotFound))
+}
+
+func TestMongoUniquenessScope(t *testing.T) {
+	repo := setupMongoHelper(t, WithMongoUniquenessScope(ScopeCountry))
+
+	// Arrange
+	john := newMongoUserHelper(t, "johndoe@foo.bar", "US")
+	require.NoError(t, repo.Insert(context.TODO(), john))
+
+	otherCountry := newMongoUserHelper(t, "johndoe@foo.bar", "BR")
+	otherCountry.Nickname = "johndoe_br"
+
+	sameCountry := newMongoUserHelper(t, "johndoe@foo.bar", "US")
+	sameCountry.Nickname = "johndoe_us"
+
+	// Act
+	otherCountryErr := repo.Insert(context.TODO(), otherCountry)
+	sameCountryErr := repo.Insert(context.TODO(), sameCountry)
+
+	_, ambiguousErr := repo.GetByEmail(context.TODO(), "johndoe@foo.bar")
+
+	rekeyed, applyErr := repo.ApplyUniquenessScope(context.TODO())
+
+	// Assert
+	require.NoError(t, otherCountryErr)
+	assert.True(t, errors.Is(sameCountryErr, ErrDuplicateEmail))
+	assert.True(t, errors.Is(ambiguousErr, ErrAmbiguousEmail))
+
+	require.NoError(t, applyErr)
+	assert.Zero(t, rekeyed)
+}
+
+func newMongoUserHelper(t *testing.T, email, country string) *User {
+	t.Helper()
+
+	// Mongo stores the times with millisecond precision and returns them in UTC.
+	return &User{
+		ID:        uuid.New().String(),
+		FirstName: "John",
+		LastName:  "Doe",
+		Nickname:  strings.Split(email, "@")[0],
+		Password:  "password",
+		Email:     email,
+		Country:   country,
+		CreatedAt: time.Time{}.Add(1 * time.Second),
+		UpdatedAt: time.Time{}.Add(2 * time.Second),
+	}
+}
+
+// setupMongoHelper returns a repository on a new database, dropped when the test ends.
+// MONGO_URI overrides the replica set started by docker compose.
+func setupMongoHelper(t *testing.T, opts ...MongoOption) *Mongo {
+	t.Helper()
+
+	uri := os.Getenv("MONGO_URI")
+	if uri == "" {
+		uri = defaultMongoURI
+	}
+
+	client, err := mongo.Connect(context.TODO(), options.Client().ApplyURI(uri))
+	require.NoError(t, err)
+
+	database := "usrsvc_test_" + strings.ReplaceAll(uuid.New().String(), "-", "")
+	t.Cleanup(func() {
+		client.Database(database).Drop(context.TODO())
+		client.Disconnect(context.TODO())
+	})
+
+	repo := NewMongo(client, database, opts...)
+	require.NoError(t, repo.EnsureIndexes(context.TODO()))
+	return repo
+}
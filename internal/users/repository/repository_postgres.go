@@ -5,30 +5,226 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
+	"github.com/alesr/usrsvc/internal/tenant"
 	"github.com/jmoiron/sqlx"
 	"github.com/lib/pq"
+	"go.opentelemetry.io/otel"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
+const (
+	// uniqueViolationCode is the Postgres unique_violation error code:
+	// https://www.postgresql.org/docs/current/errcodes-appendix.html
+	uniqueViolationCode pq.ErrorCode = "23505"
+
+	// foreignKeyViolationCode is the Postgres foreign_key_violation error code.
+	foreignKeyViolationCode pq.ErrorCode = "23503"
+
+	nicknameUniqueConstraint string = "users_nickname_key"
+
+	// bootstrapLockID is the advisory lock key serializing bootstraps.
+	bootstrapLockID int64 = 0x75737273766362 // "usrsvcb"
+)
+
+// anonymizedTables are the tables referencing the users whose records are deleted by
+// Anonymize, as they hold either personal data or credentials. Both SQL backends share them.
+var anonymizedTables = []string{
+	"api_keys",
+	"linked_identities",
+	"password_reset_tokens",
+	"phone_verifications",
+	"sessions",
+	"user_field_locks",
+	"user_lockouts",
+	"user_logins",
+	"user_totp",
+}
+
+// The queries of the repository. The fixed ones are prepared by Prepare.
+const (
+	getUserQuery string = `SELECT id, first_name, last_name, nickname, password, email,
+	country, created_at, updated_at, event_sequence, role, anonymized, status, metadata, avatar_key, avatar_hash, phone, phone_verified, locale, timezone, birthdate, tenant_id FROM users WHERE id = $1 AND (tenant_id = $2 OR $2 = '')`
+
+	getUserByEmailQuery string = `SELECT id, first_name, last_name, nickname, password, email,
+	country, created_at, updated_at, event_sequence, role, anonymized, status, metadata, avatar_key, avatar_hash, phone, phone_verified, locale, timezone, birthdate, tenant_id FROM users WHERE email = $1 AND (tenant_id = $2 OR $2 = '') ORDER BY id LIMIT 2`
+
+	deleteUserQuery string = "DELETE FROM users WHERE id = $1 AND (tenant_id = $2 OR $2 = '') RETURNING event_sequence + 1"
+
+	setUserStatusQuery string = `UPDATE users SET status = $1, updated_at = $2, event_sequence = event_sequence + 1
+	WHERE id = $3 AND (tenant_id = $4 OR $4 = '') RETURNING event_sequence`
+
+	setUserAvatarQuery string = `UPDATE users SET avatar_key = $1, avatar_hash = $2, updated_at = $3,
+	event_sequence = event_sequence + 1 WHERE id = $4 AND (tenant_id = $5 OR $5 = '') RETURNING event_sequence`
+
+	insertAPIKeyQuery string = `INSERT INTO api_keys (id, user_id, name, secret_hash, scopes, created_at)
+	VALUES (:id, :user_id, :name, :secret_hash, :scopes, :created_at)`
+
+	getAPIKeyQuery string = "SELECT id, user_id, name, secret_hash, scopes, created_at FROM api_keys WHERE id = $1"
+
+	getAPIKeysQuery string = `SELECT id, user_id, name, secret_hash, scopes, created_at FROM api_keys
+	WHERE user_id = $1 ORDER BY created_at, id`
+
+	deleteAPIKeyQuery string = "DELETE FROM api_keys WHERE id = $1"
+
+	linkIdentityQuery string = `INSERT INTO linked_identities (provider, subject, user_id, email, created_at)
+	VALUES (:provider, :subject, :user_id, :email, :created_at)`
+
+	getLinkedIdentitiesQuery string = `SELECT provider, subject, user_id, email, created_at FROM linked_identities
+	WHERE user_id = $1 ORDER BY created_at, provider`
+
+	unlinkIdentityQuery string = "DELETE FROM linked_identities WHERE user_id = $1 AND provider = $2 AND subject = $3"
+
+	lockFieldQuery string = `INSERT INTO user_field_locks (user_id, field, reason, locked_by, created_at)
+	VALUES (:user_id, :field, :reason, :locked_by, :created_at)
+	ON CONFLICT (user_id, field) DO UPDATE
+	SET reason = EXCLUDED.reason, locked_by = EXCLUDED.locked_by, created_at = EXCLUDED.created_at`
+
+	unlockFieldQuery string = "DELETE FROM user_field_locks WHERE user_id = $1 AND field = $2"
+
+	getFieldLocksQuery string = `SELECT user_id, field, reason, locked_by, created_at FROM user_field_locks
+	WHERE user_id = $1 ORDER BY field`
+
+	getByLinkedIdentityQuery string = `SELECT u.id, u.first_name, u.last_name, u.nickname, u.password, u.email,
+	u.country, u.created_at, u.updated_at, u.event_sequence, u.role, u.anonymized, u.status, u.metadata, u.avatar_key, u.avatar_hash, u.phone, u.phone_verified, u.locale, u.timezone, u.birthdate, u.tenant_id
+	FROM users u JOIN linked_identities li ON li.user_id = u.id
+	WHERE li.provider = $1 AND li.subject = $2 AND (u.tenant_id = $3 OR $3 = '')`
+
+	recordLoginQuery string = `INSERT INTO user_logins (user_id, ip, country, asn, created_at)
+	VALUES (:user_id, :ip, :country, :asn, :created_at)`
+
+	getRecentLoginsQuery string = `SELECT user_id, ip, country, asn, created_at FROM user_logins
+	WHERE user_id = $1 ORDER BY created_at DESC LIMIT $2`
+
+	getLockoutQuery string = `SELECT user_id, failed_attempts, lockouts, locked_until FROM user_lockouts
+	WHERE user_id = $1`
+
+	recordFailedLoginQuery string = `INSERT INTO user_lockouts (user_id, failed_attempts, lockouts, locked_until)
+	VALUES ($1, 1, 0, $2)
+	ON CONFLICT (user_id) DO UPDATE SET failed_attempts = user_lockouts.failed_attempts + 1
+	RETURNING user_id, failed_attempts, lockouts, locked_until`
+
+	lockUserQuery string = `UPDATE user_lockouts SET failed_attempts = 0, lockouts = lockouts + 1, locked_until = $2
+	WHERE user_id = $1`
+
+	resetLockoutQuery string = "DELETE FROM user_lockouts WHERE user_id = $1"
+
+	getSessionQuery string = `SELECT id, user_id, access_token_hash, refresh_token_hash, ip, created_at, refreshed_at,
+	access_token_expires_at, expires_at FROM sessions WHERE id = $1`
+
+	getSessionsQuery string = `SELECT id, user_id, access_token_hash, refresh_token_hash, ip, created_at, refreshed_at,
+	access_token_expires_at, expires_at FROM sessions WHERE user_id = $1 ORDER BY created_at DESC`
+
+	rotateSessionQuery string = `UPDATE sessions SET access_token_hash = $3, refresh_token_hash = $4, refreshed_at = $5,
+	access_token_expires_at = $6, expires_at = $7 WHERE id = $1 AND refresh_token_hash = $2`
+
+	deleteSessionQuery string = "DELETE FROM sessions WHERE id = $1"
+
+	insertTOTPQuery string = `INSERT INTO user_totp (user_id, secret, enabled, last_used_step, created_at)
+	VALUES (:user_id, :secret, :enabled, :last_used_step, :created_at)
+	ON CONFLICT (user_id) DO UPDATE SET secret = EXCLUDED.secret, last_used_step = EXCLUDED.last_used_step,
+	created_at = EXCLUDED.created_at WHERE user_totp.enabled = FALSE`
+
+	getTOTPQuery string = "SELECT user_id, secret, enabled, last_used_step, created_at FROM user_totp WHERE user_id = $1"
+
+	useTOTPQuery string = "UPDATE user_totp SET enabled = TRUE, last_used_step = $2 WHERE user_id = $1 AND last_used_step < $2"
+
+	insertPhoneVerificationQuery string = `INSERT INTO phone_verifications (user_id, phone, code_hash, attempts, created_at, expires_at)
+	VALUES (:user_id, :phone, :code_hash, :attempts, :created_at, :expires_at)
+	ON CONFLICT (user_id) DO UPDATE SET phone = EXCLUDED.phone, code_hash = EXCLUDED.code_hash,
+	attempts = EXCLUDED.attempts, created_at = EXCLUDED.created_at, expires_at = EXCLUDED.expires_at`
+
+	attemptPhoneVerificationQuery string = `UPDATE phone_verifications SET attempts = attempts + 1
+	WHERE user_id = $1 AND expires_at > $2 RETURNING user_id, phone, code_hash, attempts, created_at, expires_at`
+
+	deletePhoneVerificationQuery string = "DELETE FROM phone_verifications WHERE user_id = $1"
+
+	countByCountryQuery string = `SELECT country, COUNT(*) AS count FROM users WHERE tenant_id = $1 OR $1 = '' GROUP BY country`
+
+	nicknameExistsQuery string = `SELECT EXISTS (SELECT 1 FROM users WHERE uniqueness_key = $1 AND nickname = $2)`
+
+	insertUserQuery string = `INSERT INTO users (id, first_name, last_name, nickname, password, email, country, created_at, updated_at, event_sequence, role, anonymized, status, metadata, avatar_key, avatar_hash, phone, phone_verified, locale, timezone, birthdate, tenant_id, uniqueness_key)
+	VALUES (:id, :first_name, :last_name, :nickname, :password, :email, :country, :created_at, :updated_at, :event_sequence, :role, :anonymized, :status, :metadata, :avatar_key, :avatar_hash, :phone, :phone_verified, :locale, :timezone, :birthdate, :tenant_id, :uniqueness_key)`
+
+	updateUserQuery string = `UPDATE users SET first_name = :first_name, last_name = :last_name, nickname = :nickname,
+	password = :password, email = :email, country = :country, metadata = :metadata, phone = :phone,
+	phone_verified = :phone_verified, locale = :locale, timezone = :timezone, birthdate = :birthdate,
+	updated_at = :updated_at, uniqueness_key = :uniqueness_key, event_sequence = event_sequence + 1
+	WHERE id = :id AND (tenant_id = :scope_tenant OR :scope_tenant = '') RETURNING event_sequence`
+)
+
+var tracer = otel.Tracer("github.com/alesr/usrsvc/internal/users/repository")
+
+// QueryObserver is notified with the duration of every repository operation.
+type QueryObserver interface {
+	ObserveQuery(operation string, d time.Duration)
+}
+
 // Postgres is a repository implementation for Postgres.
 type Postgres struct {
-	db *sqlx.DB
+	db       *statementDB
+	observer QueryObserver
+	scope    UniquenessScope
+}
+
+// Option is a function that configures the repository.
+type Option func(*Postgres)
+
+// WithQueryObserver configures the repository to report query durations.
+func WithQueryObserver(observer QueryObserver) Option {
+	return func(p *Postgres) {
+		p.observer = observer
+	}
+}
+
+// WithUniquenessScope configures the scope of the email and nickname uniqueness.
+// It defaults to ScopeGlobal.
+func WithUniquenessScope(scope UniquenessScope) Option {
+	return func(p *Postgres) {
+		p.scope = scope
+	}
+}
+
+// WithRowLevelSecurity runs every query in a transaction scoped to the tenant of its context,
+// for the row-level security policies of the users table to hide the users of the other
+// tenants on top of the filters of the queries. It costs a few round trips per query, and
+// has no effect for the superusers, which bypass the policies.
+//
+// The queries of the contexts scoped to no tenant, e.g. of the background jobs, run on
+// bypass instead, which must connect as a role bypassing the policies: the owner of the
+// users table or a role with BYPASSRLS. Without it, they don't see any user.
+func WithRowLevelSecurity(bypass *sqlx.DB) Option {
+	return func(p *Postgres) {
+		p.db.rowLevelSecurity = true
+		p.db.bypass = bypass
+	}
 }
 
 // NewPostgres creates a new Postgres repository.
-func NewPostgres(db *sqlx.DB) *Postgres {
-	return &Postgres{db: db}
+func NewPostgres(db *sqlx.DB, opts ...Option) *Postgres {
+	p := &Postgres{db: &statementDB{DB: db}, scope: ScopeGlobal}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
 }
 
 // Get returns a user by id.
 func (p *Postgres) Get(ctx context.Context, id string) (*User, error) {
+	ctx, end := p.startQuery(ctx, "get")
+	defer end()
+
 	var user User
 	if err := p.db.GetContext(
 		ctx,
 		&user,
-		`SELECT id, first_name, last_name, nickname, password, email,
-		country, created_at, updated_at FROM users WHERE id =$1`,
+		getUserQuery,
 		id,
+		tenantOf(ctx),
 	); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, fmt.Errorf("could not get user: %w", ErrUserNotFound)
@@ -38,128 +234,1192 @@ func (p *Postgres) Get(ctx context.Context, id string) (*User, error) {
 	return &user, nil
 }
 
-func (p *Postgres) GetAll(ctx context.Context, cursor string, limit int) ([]*User, error) {
-	var users []*User
-	if cursor == "" {
-		if err := p.db.SelectContext(
-			ctx,
-			&users,
-			`SELECT id, first_name, last_name, nickname, password, email, country, 
-			created_at, updated_at FROM users ORDER BY id ASC LIMIT $1`,
-			limit,
-		); err != nil {
-			return nil, fmt.Errorf("could not get users: %w", err)
-		}
-		return users, nil
-	}
+// GetByEmail returns a user by email. It fails with ErrAmbiguousEmail when the
+// uniqueness scope lets several users share the email.
+func (p *Postgres) GetByEmail(ctx context.Context, email string) (*User, error) {
+	ctx, end := p.startQuery(ctx, "get_by_email")
+	defer end()
 
+	var users []*User
 	if err := p.db.SelectContext(
 		ctx,
 		&users,
-		`SELECT id, first_name, last_name, nickname, password, email, country,  
-		created_at, updated_at FROM users WHERE id > $1 ORDER BY id ASC LIMIT $2`,
-		cursor,
-		limit,
+		getUserByEmailQuery,
+		email,
+		tenantOf(ctx),
 	); err != nil {
+		return nil, fmt.Errorf("could not get user by email: %w", err)
+	}
+
+	user, err := oneByEmail(users)
+	if err != nil {
+		return nil, fmt.Errorf("could not get user by email: %w", err)
+	}
+	return user, nil
+}
+
+// List returns a page of the users selected by the filter.
+func (p *Postgres) List(ctx context.Context, filter Filter, page Page) ([]*User, error) {
+	ctx, end := p.startQuery(ctx, "list")
+	defer end()
+
+	query, args := listQuery(tenantOf(ctx), filter, page)
+
+	var users []*User
+	if err := p.db.SelectContext(ctx, &users, query, args...); err != nil {
 		return nil, fmt.Errorf("could not get users: %w", err)
 	}
 	return users, nil
 }
 
-// GetByCountry returns a list of users by country.
-func (p *Postgres) GetByCountry(ctx context.Context, country string, cursor string, limit int) ([]*User, error) {
-	var users []*User
-	if cursor == "" {
-		if err := p.db.SelectContext(
-			ctx,
-			&users,
-			`SELECT id, first_name, last_name, nickname, password, email, country,
-			created_at, updated_at FROM users WHERE country = $1 ORDER BY id ASC LIMIT $2`,
-			country,
-			limit,
-		); err != nil {
-			return nil, fmt.Errorf("could not get users: %w", err)
-		}
-		return users, nil
+// Count returns the number of users selected by the filter.
+func (p *Postgres) Count(ctx context.Context, filter Filter) (int64, error) {
+	ctx, end := p.startQuery(ctx, "count")
+	defer end()
+
+	query, args := countQuery(tenantOf(ctx), filter)
+
+	var count int64
+	if err := p.db.GetContext(ctx, &count, query, args...); err != nil {
+		return 0, fmt.Errorf("could not count users: %w", err)
 	}
+	return count, nil
+}
 
-	if err := p.db.SelectContext(
-		ctx,
-		&users,
-		`SELECT id, first_name, last_name, nickname, password, email, country, created_at, 
-		updated_at FROM users WHERE country= $1 AND id > $2 ORDER BY id ASC LIMIT $3`,
-		country,
-		cursor,
-		limit,
-	); err != nil {
-		return nil, fmt.Errorf("could not get users: %w", err)
+// NicknameExists reports whether a user has the nickname within the uniqueness scope of
+// the country. It only reads the unique index of the nicknames.
+func (p *Postgres) NicknameExists(ctx context.Context, nickname, country string) (bool, error) {
+	ctx, end := p.startQuery(ctx, "nickname_exists")
+	defer end()
+
+	var exists bool
+	if err := p.db.GetContext(ctx, &exists, nicknameExistsQuery, p.scope.key(&User{Country: country, TenantID: tenant.OrDefault(ctx)}), nickname); err != nil {
+		return false, fmt.Errorf("could not check nickname: %w", err)
+	}
+	return exists, nil
+}
+
+// Search returns the users with names or nickname containing words starting with every
+// word of the query, best matches first. The page starts at offset.
+func (p *Postgres) Search(ctx context.Context, query string, offset, limit int) ([]*User, error) {
+	ctx, end := p.startQuery(ctx, "search")
+	defer end()
+
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	var users []*User
+	if err := p.db.SelectContext(ctx, &users, searchQuery, prefixTSQuery(terms), limit, offset, tenantOf(ctx)); err != nil {
+		return nil, fmt.Errorf("could not search users: %w", err)
 	}
 	return users, nil
 }
 
+// listQuery returns the List query of the users of the tenant, or of every user when it is
+// empty, and its arguments. The query only depends on the fields set in the filter and on
+// the presence of the cursor, not on their values.
+func listQuery(tenantID string, filter Filter, page Page) (string, []any) {
+	q := newQueryBuilder(`SELECT id, first_name, last_name, nickname, password, email, country,
+		created_at, updated_at, event_sequence, role, anonymized, status, metadata, avatar_key, avatar_hash, phone, phone_verified, locale, timezone, birthdate, tenant_id FROM users`)
+
+	q.where("(tenant_id = ? OR ? = '')", tenantID, tenantID)
+	filter.where(q)
+	if page.Cursor != "" {
+		q.where("id > ?", page.Cursor)
+	}
+	q.orderBy("ORDER BY id ASC LIMIT ?", page.Limit)
+	return q.build()
+}
+
+// countQuery returns the Count query of the users of the tenant, or of every user when it
+// is empty, and its arguments.
+func countQuery(tenantID string, filter Filter) (string, []any) {
+	q := newQueryBuilder("SELECT count(*) FROM users")
+	q.where("(tenant_id = ? OR ? = '')", tenantID, tenantID)
+	filter.where(q)
+	return q.build()
+}
+
+// searchQuery is the Search query, taking the tsquery, the limit and the offset.
+const searchQuery string = `SELECT id, first_name, last_name, nickname, password, email, country,
+	created_at, updated_at, event_sequence, role, anonymized, status, metadata, avatar_key, avatar_hash, phone, phone_verified, locale, timezone, birthdate, tenant_id
+	FROM users, to_tsquery('simple', $1) query
+	WHERE search_vector @@ query AND (tenant_id = $4 OR $4 = '')
+	ORDER BY ts_rank(search_vector, query) DESC, id ASC LIMIT $2 OFFSET $3`
+
 // Insert inserts a new user.
 func (p *Postgres) Insert(ctx context.Context, user *User) error {
-	if _, err := p.db.NamedExecContext(
-		ctx,
-		`INSERT INTO users (id, first_name, last_name, nickname, password, email, country, created_at, updated_at) 
-		VALUES (:id, :first_name, :last_name, :nickname, :password, :email, :country, :created_at, :updated_at)`,
-		user,
-	); err != nil {
-		pgErr, ok := err.(*pq.Error)
-		if ok {
-			if pgErr.Code == "23505" { // unique_violation: https://www.postgresql.org/docs/8.2/errcodes-appendix.html
-				return fmt.Errorf("could not insert user: %w", ErrDuplicateEmail)
-			}
-		}
+	ctx, end := p.startQuery(ctx, "insert")
+	defer end()
+
+	if err := insertUser(ctx, p.db, p.scope, user); err != nil {
 		return fmt.Errorf("could not insert user: %w", err)
 	}
 	return nil
 }
 
-// Update updates a user by id.
+// InsertBatch inserts the users with a single multi-row INSERT: either all of them are
+// inserted or none is.
+func (p *Postgres) InsertBatch(ctx context.Context, users []*User) error {
+	ctx, end := p.startQuery(ctx, "insert_batch")
+	defer end()
+
+	if len(users) == 0 {
+		return nil
+	}
+
+	scoped := make([]*scopedUser, 0, len(users))
+	for _, user := range users {
+		user.setInsertDefaults()
+		scoped = append(scoped, p.scope.scoped(ctx, user))
+	}
+
+	if _, err := sqlx.NamedExecContext(ctx, p.db, insertUserQuery, scoped); err != nil {
+		if dupErr := uniqueViolationError(err); dupErr != nil {
+			return fmt.Errorf("could not insert users: %w", dupErr)
+		}
+		return fmt.Errorf("could not insert users: %w", err)
+	}
+	return nil
+}
+
+// Update updates a user by id and sets the user event sequence to the incremented one.
 func (p *Postgres) Update(ctx context.Context, user *User) error {
-	result, err := p.db.NamedExecContext(
+	ctx, end := p.startQuery(ctx, "update")
+	defer end()
+
+	if err := updateUser(ctx, p.db, p.scope, user); err != nil {
+		return fmt.Errorf("could not update user: %w", err)
+	}
+	return nil
+}
+
+// Delete deletes a user by id and returns the event sequence of the deletion.
+func (p *Postgres) Delete(ctx context.Context, id string) (int64, error) {
+	ctx, end := p.startQuery(ctx, "delete")
+	defer end()
+
+	var sequence int64
+	if err := p.db.GetContext(
+		ctx,
+		&sequence,
+		deleteUserQuery,
+		id,
+		tenantOf(ctx),
+	); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, fmt.Errorf("could not delete user: %w", ErrUserNotFound)
+		}
+		return 0, fmt.Errorf("could not delete user: %w", err)
+	}
+	return sequence, nil
+}
+
+// Merge merges the duplicate user into the survivor in a single transaction: the survivor
+// is updated with the given values and the duplicate is replaced by a tombstone pointing
+// to the survivor, which frees its email and nickname for the survivor to take over.
+func (p *Postgres) Merge(ctx context.Context, survivor *User, duplicateID string) error {
+	ctx, end := p.startQuery(ctx, "merge")
+	defer end()
+
+	tx, err := p.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not begin merge transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(
 		ctx,
-		`UPDATE users SET first_name = :first_name, last_name = :last_name, nickname = :nickname, 
-		password = :password, email = :email, country = :country, updated_at = :updated_at WHERE id = :id`,
-		user,
+		`INSERT INTO user_tombstones (id, merged_into, first_name, last_name, nickname, email, country, created_at, merged_at)
+		SELECT id, $2, first_name, last_name, nickname, email, country, created_at, $3 FROM users
+		WHERE id = $1 AND (tenant_id = $4 OR $4 = '')`,
+		duplicateID,
+		survivor.ID,
+		survivor.UpdatedAt,
+		tenantOf(ctx),
 	)
 	if err != nil {
-		pgErr, ok := err.(*pq.Error)
-		if ok {
-			if pgErr.Code == "23505" {
-				return fmt.Errorf("could not insert user: %w", ErrDuplicateEmail)
-			}
+		return fmt.Errorf("could not tombstone duplicate user: %w", err)
+	}
+
+	if rows, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("could not tombstone duplicate user: %w", err)
+	} else if rows == 0 {
+		return fmt.Errorf("could not tombstone duplicate user: %w", ErrUserNotFound)
+	}
+
+	// Records referencing the duplicate in other tables must be moved to the survivor here.
+
+	if _, err := tx.ExecContext(ctx, "UPDATE api_keys SET user_id = $1 WHERE user_id = $2", survivor.ID, duplicateID); err != nil {
+		return fmt.Errorf("could not move api keys: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE linked_identities SET user_id = $1 WHERE user_id = $2", survivor.ID, duplicateID); err != nil {
+		return fmt.Errorf("could not move linked identities: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE user_logins SET user_id = $1 WHERE user_id = $2", survivor.ID, duplicateID); err != nil {
+		return fmt.Errorf("could not move logins: %w", err)
+	}
+
+	// The survivor's own locks win over the duplicate's, and the rest are deleted with the duplicate.
+	if _, err := tx.ExecContext(
+		ctx,
+		`INSERT INTO user_field_locks (user_id, field, reason, locked_by, created_at)
+		SELECT $1, field, reason, locked_by, created_at FROM user_field_locks WHERE user_id = $2
+		ON CONFLICT (user_id, field) DO NOTHING`,
+		survivor.ID,
+		duplicateID,
+	); err != nil {
+		return fmt.Errorf("could not move field locks: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM users WHERE id = $1", duplicateID); err != nil {
+		return fmt.Errorf("could not delete duplicate user: %w", err)
+	}
+
+	if err := updateUser(ctx, tx, p.scope, survivor); err != nil {
+		return fmt.Errorf("could not update surviving user: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("could not commit merge transaction: %w", err)
+	}
+	return nil
+}
+
+// Anonymize overwrites the user with the given values, marks it anonymized, clears its avatar
+// (the blob is deleted by the caller) and deletes the records holding its personal data,
+// in a single transaction. The row is kept, so the references to its id remain valid, and
+// so are the tombstones of the users merged into it, overwritten with the same values.
+func (p *Postgres) Anonymize(ctx context.Context, user *User) error {
+	ctx, end := p.startQuery(ctx, "anonymize")
+	defer end()
+
+	tx, err := p.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not begin anonymize transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := updateUser(ctx, tx, p.scope, user); err != nil {
+		return fmt.Errorf("could not anonymize user: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE users SET anonymized = TRUE, avatar_key = '', avatar_hash = '' WHERE id = $1`, user.ID); err != nil {
+		return fmt.Errorf("could not anonymize user: %w", err)
+	}
+
+	if _, err := tx.ExecContext(
+		ctx,
+		"UPDATE user_tombstones SET first_name = $1, last_name = $2, nickname = $3, email = $4 WHERE merged_into = $5",
+		user.FirstName,
+		user.LastName,
+		user.Nickname,
+		user.Email,
+		user.ID,
+	); err != nil {
+		return fmt.Errorf("could not anonymize tombstones: %w", err)
+	}
+
+	for _, table := range anonymizedTables {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE user_id = $1", user.ID); err != nil {
+			return fmt.Errorf("could not delete %s: %w", strings.ReplaceAll(table, "_", " "), err)
 		}
-		return fmt.Errorf("could not update user: %w", err)
 	}
 
-	rows, err := result.RowsAffected()
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("could not commit anonymize transaction: %w", err)
+	}
+
+	user.Anonymized = true
+	user.AvatarKey = ""
+	user.AvatarHash = ""
+	return nil
+}
+
+// SetStatus sets the status of the user, increments its event sequence and sets it on the user.
+func (p *Postgres) SetStatus(ctx context.Context, user *User) error {
+	ctx, end := p.startQuery(ctx, "set_status")
+	defer end()
+
+	if err := p.db.GetContext(
+		ctx,
+		&user.EventSequence,
+		setUserStatusQuery,
+		user.Status,
+		user.UpdatedAt,
+		user.ID,
+		tenantOf(ctx),
+	); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("could not set user status: %w", ErrUserNotFound)
+		}
+		return fmt.Errorf("could not set user status: %w", err)
+	}
+	return nil
+}
+
+// SetAvatar sets the avatar key and hash of the user, increments its event sequence and sets it on the user.
+func (p *Postgres) SetAvatar(ctx context.Context, user *User) error {
+	ctx, end := p.startQuery(ctx, "set_avatar")
+	defer end()
+
+	if err := p.db.GetContext(
+		ctx,
+		&user.EventSequence,
+		setUserAvatarQuery,
+		user.AvatarKey,
+		user.AvatarHash,
+		user.UpdatedAt,
+		user.ID,
+		tenantOf(ctx),
+	); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("could not set user avatar: %w", ErrUserNotFound)
+		}
+		return fmt.Errorf("could not set user avatar: %w", err)
+	}
+	return nil
+}
+
+// Bootstrap inserts the first admin user and its API key, unless an admin user already exists.
+// Concurrent bootstraps are serialized with an advisory lock so only one of them can succeed.
+func (p *Postgres) Bootstrap(ctx context.Context, admin *User, key *APIKey) error {
+	ctx, end := p.startQuery(ctx, "bootstrap")
+	defer end()
+
+	tx, err := p.db.BeginTxx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("could not update user: %w", err)
+		return fmt.Errorf("could not begin bootstrap transaction: %w", err)
 	}
+	defer tx.Rollback()
 
-	if rows == 0 {
-		return fmt.Errorf("could not update user: %w", ErrUserNotFound)
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", bootstrapLockID); err != nil {
+		return fmt.Errorf("could not lock bootstrap: %w", err)
+	}
+
+	// Every tenant is bootstrapped on its own.
+	var bootstrapped bool
+	if err := tx.GetContext(
+		ctx,
+		&bootstrapped,
+		"SELECT EXISTS (SELECT 1 FROM users WHERE role = $1 AND tenant_id = $2)",
+		RoleAdmin,
+		tenant.OrDefault(ctx),
+	); err != nil {
+		return fmt.Errorf("could not check for admin users: %w", err)
+	}
+
+	if bootstrapped {
+		return fmt.Errorf("could not bootstrap: %w", ErrAlreadyBootstrapped)
+	}
+
+	admin.Role = RoleAdmin
+	if err := insertUser(ctx, tx, p.scope, admin); err != nil {
+		return fmt.Errorf("could not insert admin user: %w", err)
+	}
+
+	if _, err := tx.NamedExecContext(ctx, insertAPIKeyQuery, key); err != nil {
+		return fmt.Errorf("could not insert api key: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("could not commit bootstrap transaction: %w", err)
+	}
+	return nil
+}
+
+// InsertAPIKey inserts an API key.
+func (p *Postgres) InsertAPIKey(ctx context.Context, key *APIKey) error {
+	ctx, end := p.startQuery(ctx, "insert_api_key")
+	defer end()
+
+	if _, err := p.db.NamedExecContext(
+		ctx,
+		insertAPIKeyQuery,
+		key,
+	); err != nil {
+		var pgErr *pq.Error
+		if errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolationCode {
+			return fmt.Errorf("could not insert api key: %w", ErrUserNotFound)
+		}
+		return fmt.Errorf("could not insert api key: %w", err)
 	}
 	return nil
 }
 
-// Delete deletes a user by id.
-func (p *Postgres) Delete(ctx context.Context, id string) error {
-	if _, err := p.db.ExecContext(ctx, "DELETE FROM users WHERE id = $1", id); err != nil {
+// GetAPIKey returns an API key by id.
+func (p *Postgres) GetAPIKey(ctx context.Context, id string) (*APIKey, error) {
+	ctx, end := p.startQuery(ctx, "get_api_key")
+	defer end()
+
+	var key APIKey
+	if err := p.db.GetContext(
+		ctx,
+		&key,
+		getAPIKeyQuery,
+		id,
+	); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return fmt.Errorf("could not delete user: %w", ErrUserNotFound)
+			return nil, fmt.Errorf("could not get api key: %w", ErrAPIKeyNotFound)
 		}
-		return fmt.Errorf("could not delete user: %w", err)
+		return nil, fmt.Errorf("could not get api key: %w", err)
+	}
+	return &key, nil
+}
+
+// GetAPIKeys returns the API keys of a user, oldest first.
+func (p *Postgres) GetAPIKeys(ctx context.Context, userID string) ([]*APIKey, error) {
+	ctx, end := p.startQuery(ctx, "get_api_keys")
+	defer end()
+
+	var keys []*APIKey
+	if err := p.db.SelectContext(
+		ctx,
+		&keys,
+		getAPIKeysQuery,
+		userID,
+	); err != nil {
+		return nil, fmt.Errorf("could not get api keys: %w", err)
+	}
+	return keys, nil
+}
+
+// DeleteAPIKey deletes an API key by id.
+func (p *Postgres) DeleteAPIKey(ctx context.Context, id string) error {
+	ctx, end := p.startQuery(ctx, "delete_api_key")
+	defer end()
+
+	res, err := p.db.ExecContext(ctx, deleteAPIKeyQuery, id)
+	if err != nil {
+		return fmt.Errorf("could not delete api key: %w", err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("could not delete api key: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("could not delete api key: %w", ErrAPIKeyNotFound)
 	}
 	return nil
 }
 
-// CheckDatabaseHealth checks if the database is healthy by pinging it.
-func (p *Postgres) CheckDatabaseHealth(ctx context.Context) error {
-	if err := p.db.PingContext(ctx); err != nil {
-		return fmt.Errorf("could not ping database: %w", err)
+// LinkIdentity links an external identity to a user.
+func (p *Postgres) LinkIdentity(ctx context.Context, identity *LinkedIdentity) error {
+	ctx, end := p.startQuery(ctx, "link_identity")
+	defer end()
+
+	if _, err := p.db.NamedExecContext(
+		ctx,
+		linkIdentityQuery,
+		identity,
+	); err != nil {
+		var pgErr *pq.Error
+		if errors.As(err, &pgErr) {
+			switch pgErr.Code {
+			case uniqueViolationCode:
+				return fmt.Errorf("could not link identity: %w", ErrIdentityLinked)
+			case foreignKeyViolationCode:
+				return fmt.Errorf("could not link identity: %w", ErrUserNotFound)
+			}
+		}
+		return fmt.Errorf("could not link identity: %w", err)
 	}
 	return nil
 }
+
+// GetLinkedIdentities returns the external identities linked to a user, oldest first.
+func (p *Postgres) GetLinkedIdentities(ctx context.Context, userID string) ([]*LinkedIdentity, error) {
+	ctx, end := p.startQuery(ctx, "get_linked_identities")
+	defer end()
+
+	var identities []*LinkedIdentity
+	if err := p.db.SelectContext(
+		ctx,
+		&identities,
+		getLinkedIdentitiesQuery,
+		userID,
+	); err != nil {
+		return nil, fmt.Errorf("could not get linked identities: %w", err)
+	}
+	return identities, nil
+}
+
+// UnlinkIdentity unlinks an external identity from a user.
+func (p *Postgres) UnlinkIdentity(ctx context.Context, userID, provider, subject string) error {
+	ctx, end := p.startQuery(ctx, "unlink_identity")
+	defer end()
+
+	res, err := p.db.ExecContext(ctx, unlinkIdentityQuery, userID, provider, subject)
+	if err != nil {
+		return fmt.Errorf("could not unlink identity: %w", err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("could not unlink identity: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("could not unlink identity: %w", ErrIdentityNotFound)
+	}
+	return nil
+}
+
+// LockField locks a field of a user, replacing the reason of an existing lock.
+func (p *Postgres) LockField(ctx context.Context, lock *FieldLock) error {
+	ctx, end := p.startQuery(ctx, "lock_field")
+	defer end()
+
+	if _, err := p.db.NamedExecContext(
+		ctx,
+		lockFieldQuery,
+		lock,
+	); err != nil {
+		var pgErr *pq.Error
+		if errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolationCode {
+			return fmt.Errorf("could not lock field: %w", ErrUserNotFound)
+		}
+		return fmt.Errorf("could not lock field: %w", err)
+	}
+	return nil
+}
+
+// UnlockField unlocks a field of a user. Unlocking a field that is not locked is a no-op.
+func (p *Postgres) UnlockField(ctx context.Context, userID, field string) error {
+	ctx, end := p.startQuery(ctx, "unlock_field")
+	defer end()
+
+	if _, err := p.db.ExecContext(
+		ctx,
+		unlockFieldQuery,
+		userID,
+		field,
+	); err != nil {
+		return fmt.Errorf("could not unlock field: %w", err)
+	}
+	return nil
+}
+
+// GetFieldLocks returns the locked fields of a user, ordered by field.
+func (p *Postgres) GetFieldLocks(ctx context.Context, userID string) ([]*FieldLock, error) {
+	ctx, end := p.startQuery(ctx, "get_field_locks")
+	defer end()
+
+	var locks []*FieldLock
+	if err := p.db.SelectContext(
+		ctx,
+		&locks,
+		getFieldLocksQuery,
+		userID,
+	); err != nil {
+		return nil, fmt.Errorf("could not get field locks: %w", err)
+	}
+	return locks, nil
+}
+
+// GetByLinkedIdentity returns the user linked to the given external identity.
+func (p *Postgres) GetByLinkedIdentity(ctx context.Context, provider, subject string) (*User, error) {
+	ctx, end := p.startQuery(ctx, "get_by_linked_identity")
+	defer end()
+
+	var user User
+	if err := p.db.GetContext(
+		ctx,
+		&user,
+		getByLinkedIdentityQuery,
+		provider,
+		subject,
+		tenantOf(ctx),
+	); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("could not get user by linked identity: %w", ErrUserNotFound)
+		}
+		return nil, fmt.Errorf("could not get user by linked identity: %w", err)
+	}
+	return &user, nil
+}
+
+// RecordLogin records a successful login.
+func (p *Postgres) RecordLogin(ctx context.Context, login *Login) error {
+	ctx, end := p.startQuery(ctx, "record_login")
+	defer end()
+
+	if _, err := p.db.NamedExecContext(
+		ctx,
+		recordLoginQuery,
+		login,
+	); err != nil {
+		var pgErr *pq.Error
+		if errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolationCode {
+			return fmt.Errorf("could not record login: %w", ErrUserNotFound)
+		}
+		return fmt.Errorf("could not record login: %w", err)
+	}
+	return nil
+}
+
+// GetRecentLogins returns the last logins of a user, most recent first.
+func (p *Postgres) GetRecentLogins(ctx context.Context, userID string, limit int) ([]*Login, error) {
+	ctx, end := p.startQuery(ctx, "get_recent_logins")
+	defer end()
+
+	var logins []*Login
+	if err := p.db.SelectContext(
+		ctx,
+		&logins,
+		getRecentLoginsQuery,
+		userID,
+		limit,
+	); err != nil {
+		return nil, fmt.Errorf("could not get recent logins: %w", err)
+	}
+	return logins, nil
+}
+
+// GetLockout returns the failed logins of a user. A user without failed logins
+// gets a lockout with no attempts.
+func (p *Postgres) GetLockout(ctx context.Context, userID string) (*Lockout, error) {
+	ctx, end := p.startQuery(ctx, "get_lockout")
+	defer end()
+
+	var lockout Lockout
+	if err := p.db.GetContext(
+		ctx,
+		&lockout,
+		getLockoutQuery,
+		userID,
+	); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &Lockout{UserID: userID}, nil
+		}
+		return nil, fmt.Errorf("could not get lockout: %w", err)
+	}
+	return &lockout, nil
+}
+
+// RecordFailedLogin counts a failed login of a user and returns the updated lockout.
+func (p *Postgres) RecordFailedLogin(ctx context.Context, userID string) (*Lockout, error) {
+	ctx, end := p.startQuery(ctx, "record_failed_login")
+	defer end()
+
+	var lockout Lockout
+	if err := p.db.GetContext(
+		ctx,
+		&lockout,
+		recordFailedLoginQuery,
+		userID,
+		time.Time{},
+	); err != nil {
+		var pgErr *pq.Error
+		if errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolationCode {
+			return nil, fmt.Errorf("could not record failed login: %w", ErrUserNotFound)
+		}
+		return nil, fmt.Errorf("could not record failed login: %w", err)
+	}
+	return &lockout, nil
+}
+
+// LockUser locks a user out until the given time, starting over the count of failed logins.
+func (p *Postgres) LockUser(ctx context.Context, userID string, until time.Time) error {
+	ctx, end := p.startQuery(ctx, "lock_user")
+	defer end()
+
+	if _, err := p.db.ExecContext(
+		ctx,
+		lockUserQuery,
+		userID,
+		until,
+	); err != nil {
+		return fmt.Errorf("could not lock user: %w", err)
+	}
+	return nil
+}
+
+// ResetLockout forgets the failed logins and the lockouts of a user, unlocking it.
+func (p *Postgres) ResetLockout(ctx context.Context, userID string) error {
+	ctx, end := p.startQuery(ctx, "reset_lockout")
+	defer end()
+
+	if _, err := p.db.ExecContext(
+		ctx,
+		resetLockoutQuery,
+		userID,
+	); err != nil {
+		return fmt.Errorf("could not reset lockout: %w", err)
+	}
+	return nil
+}
+
+// InsertSession inserts a session. The expired sessions of the user are deleted on the way.
+func (p *Postgres) InsertSession(ctx context.Context, session *Session) error {
+	ctx, end := p.startQuery(ctx, "insert_session")
+	defer end()
+
+	tx, err := p.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not begin session transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(
+		ctx,
+		"DELETE FROM sessions WHERE user_id = $1 AND expires_at <= $2",
+		session.UserID,
+		session.CreatedAt,
+	); err != nil {
+		return fmt.Errorf("could not delete expired sessions: %w", err)
+	}
+
+	if _, err := tx.NamedExecContext(
+		ctx,
+		`INSERT INTO sessions (id, user_id, access_token_hash, refresh_token_hash, ip, created_at, refreshed_at,
+		access_token_expires_at, expires_at)
+		VALUES (:id, :user_id, :access_token_hash, :refresh_token_hash, :ip, :created_at, :refreshed_at,
+		:access_token_expires_at, :expires_at)`,
+		session,
+	); err != nil {
+		var pgErr *pq.Error
+		if errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolationCode {
+			return fmt.Errorf("could not insert session: %w", ErrUserNotFound)
+		}
+		return fmt.Errorf("could not insert session: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("could not commit session transaction: %w", err)
+	}
+	return nil
+}
+
+// GetSession returns a session by id.
+func (p *Postgres) GetSession(ctx context.Context, id string) (*Session, error) {
+	ctx, end := p.startQuery(ctx, "get_session")
+	defer end()
+
+	var session Session
+	if err := p.db.GetContext(
+		ctx,
+		&session,
+		getSessionQuery,
+		id,
+	); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("could not get session: %w", ErrSessionNotFound)
+		}
+		return nil, fmt.Errorf("could not get session: %w", err)
+	}
+	return &session, nil
+}
+
+// GetSessions returns the sessions of a user, most recent first.
+func (p *Postgres) GetSessions(ctx context.Context, userID string) ([]*Session, error) {
+	ctx, end := p.startQuery(ctx, "get_sessions")
+	defer end()
+
+	var sessions []*Session
+	if err := p.db.SelectContext(
+		ctx,
+		&sessions,
+		getSessionsQuery,
+		userID,
+	); err != nil {
+		return nil, fmt.Errorf("could not get sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+// RotateSession replaces the tokens and the expiry of the session, given the hash of its
+// current refresh token. It fails with ErrSessionNotFound if the refresh token was rotated
+// meanwhile, so a refresh token can only be used once.
+func (p *Postgres) RotateSession(ctx context.Context, session *Session, refreshTokenHash []byte) error {
+	ctx, end := p.startQuery(ctx, "rotate_session")
+	defer end()
+
+	res, err := p.db.ExecContext(
+		ctx,
+		rotateSessionQuery,
+		session.ID,
+		refreshTokenHash,
+		session.AccessTokenHash,
+		session.RefreshTokenHash,
+		session.RefreshedAt,
+		session.AccessTokenExpiresAt,
+		session.ExpiresAt,
+	)
+	if err != nil {
+		return fmt.Errorf("could not rotate session: %w", err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("could not rotate session: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("could not rotate session: %w", ErrSessionNotFound)
+	}
+	return nil
+}
+
+// DeleteSession deletes a session by id.
+func (p *Postgres) DeleteSession(ctx context.Context, id string) error {
+	ctx, end := p.startQuery(ctx, "delete_session")
+	defer end()
+
+	res, err := p.db.ExecContext(ctx, deleteSessionQuery, id)
+	if err != nil {
+		return fmt.Errorf("could not delete session: %w", err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("could not delete session: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("could not delete session: %w", ErrSessionNotFound)
+	}
+	return nil
+}
+
+// InsertTOTP inserts the pending TOTP enrollment of a user, replacing a pending one.
+// It fails with ErrTOTPEnabled if the user already enabled TOTP.
+func (p *Postgres) InsertTOTP(ctx context.Context, totp *TOTP) error {
+	ctx, end := p.startQuery(ctx, "insert_totp")
+	defer end()
+
+	res, err := p.db.NamedExecContext(ctx, insertTOTPQuery, totp)
+	if err != nil {
+		var pgErr *pq.Error
+		if errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolationCode {
+			return fmt.Errorf("could not insert totp: %w", ErrUserNotFound)
+		}
+		return fmt.Errorf("could not insert totp: %w", err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("could not insert totp: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("could not insert totp: %w", ErrTOTPEnabled)
+	}
+	return nil
+}
+
+// GetTOTP returns the TOTP enrollment of a user.
+func (p *Postgres) GetTOTP(ctx context.Context, userID string) (*TOTP, error) {
+	ctx, end := p.startQuery(ctx, "get_totp")
+	defer end()
+
+	var totp TOTP
+	if err := p.db.GetContext(ctx, &totp, getTOTPQuery, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("could not get totp: %w", ErrTOTPNotFound)
+		}
+		return nil, fmt.Errorf("could not get totp: %w", err)
+	}
+	return &totp, nil
+}
+
+// UseTOTP records the use of a code of the time step, enabling TOTP on first use. It fails
+// with ErrTOTPCodeUsed if a code of that step or a later one was used, so codes can't be replayed.
+func (p *Postgres) UseTOTP(ctx context.Context, userID string, step int64) error {
+	ctx, end := p.startQuery(ctx, "use_totp")
+	defer end()
+
+	res, err := p.db.ExecContext(ctx, useTOTPQuery, userID, step)
+	if err != nil {
+		return fmt.Errorf("could not use totp: %w", err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("could not use totp: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("could not use totp: %w", ErrTOTPCodeUsed)
+	}
+	return nil
+}
+
+// InsertPhoneVerification inserts the phone verification of a user, replacing a pending one.
+func (p *Postgres) InsertPhoneVerification(ctx context.Context, verification *PhoneVerification) error {
+	ctx, end := p.startQuery(ctx, "insert_phone_verification")
+	defer end()
+
+	if _, err := p.db.NamedExecContext(ctx, insertPhoneVerificationQuery, verification); err != nil {
+		var pgErr *pq.Error
+		if errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolationCode {
+			return fmt.Errorf("could not insert phone verification: %w", ErrUserNotFound)
+		}
+		return fmt.Errorf("could not insert phone verification: %w", err)
+	}
+	return nil
+}
+
+// AttemptPhoneVerification counts an attempt to verify the phone of a user and returns the
+// phone verification, attempt included, if it has not expired by now. Counting the attempt
+// before the code is checked bounds the guesses of concurrent attempts too.
+func (p *Postgres) AttemptPhoneVerification(ctx context.Context, userID string, now time.Time) (*PhoneVerification, error) {
+	ctx, end := p.startQuery(ctx, "attempt_phone_verification")
+	defer end()
+
+	var verification PhoneVerification
+	if err := p.db.GetContext(ctx, &verification, attemptPhoneVerificationQuery, userID, now); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("could not attempt phone verification: %w", ErrPhoneVerificationNotFound)
+		}
+		return nil, fmt.Errorf("could not attempt phone verification: %w", err)
+	}
+	return &verification, nil
+}
+
+// DeletePhoneVerification deletes the phone verification of a user, if any.
+func (p *Postgres) DeletePhoneVerification(ctx context.Context, userID string) error {
+	ctx, end := p.startQuery(ctx, "delete_phone_verification")
+	defer end()
+
+	if _, err := p.db.ExecContext(ctx, deletePhoneVerificationQuery, userID); err != nil {
+		return fmt.Errorf("could not delete phone verification: %w", err)
+	}
+	return nil
+}
+
+// InsertPasswordResetToken inserts a password reset token.
+// The expired tokens of the user are deleted on the way.
+func (p *Postgres) InsertPasswordResetToken(ctx context.Context, token *PasswordResetToken) error {
+	ctx, end := p.startQuery(ctx, "insert_password_reset_token")
+	defer end()
+
+	tx, err := p.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not begin password reset token transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(
+		ctx,
+		"DELETE FROM password_reset_tokens WHERE user_id = $1 AND expires_at <= $2",
+		token.UserID,
+		token.CreatedAt,
+	); err != nil {
+		return fmt.Errorf("could not delete expired password reset tokens: %w", err)
+	}
+
+	if _, err := tx.NamedExecContext(
+		ctx,
+		`INSERT INTO password_reset_tokens (token_hash, user_id, created_at, expires_at)
+		VALUES (:token_hash, :user_id, :created_at, :expires_at)`,
+		token,
+	); err != nil {
+		var pgErr *pq.Error
+		if errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolationCode {
+			return fmt.Errorf("could not insert password reset token: %w", ErrUserNotFound)
+		}
+		return fmt.Errorf("could not insert password reset token: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("could not commit password reset token transaction: %w", err)
+	}
+	return nil
+}
+
+// ResetPassword consumes the password reset token, if it has not expired by now, and sets
+// the password of its user in a single transaction. All the other reset tokens of the user
+// are deleted too, so a reset invalidates every pending reset link.
+func (p *Postgres) ResetPassword(ctx context.Context, tokenHash []byte, password string, now time.Time) (*User, error) {
+	ctx, end := p.startQuery(ctx, "reset_password")
+	defer end()
+
+	tx, err := p.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not begin reset password transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var userID string
+	if err := tx.GetContext(
+		ctx,
+		&userID,
+		"DELETE FROM password_reset_tokens WHERE token_hash = $1 AND expires_at > $2 RETURNING user_id",
+		tokenHash,
+		now,
+	); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("could not reset password: %w", ErrResetTokenNotFound)
+		}
+		return nil, fmt.Errorf("could not consume password reset token: %w", err)
+	}
+
+	var user User
+	if err := tx.GetContext(
+		ctx,
+		&user,
+		`UPDATE users SET password = $1, updated_at = $2, event_sequence = event_sequence + 1
+		WHERE id = $3 AND (tenant_id = $4 OR $4 = '')
+		RETURNING id, first_name, last_name, nickname, password, email, country, created_at, updated_at, event_sequence, role, anonymized, status, metadata, avatar_key, avatar_hash, phone, phone_verified, locale, timezone, birthdate, tenant_id`,
+		password,
+		now,
+		userID,
+		tenantOf(ctx),
+	); err != nil {
+		// The token of a user of another tenant is unknown to this one.
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("could not reset password: %w", ErrResetTokenNotFound)
+		}
+		return nil, fmt.Errorf("could not update password: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM password_reset_tokens WHERE user_id = $1", userID); err != nil {
+		return nil, fmt.Errorf("could not delete password reset tokens: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("could not commit reset password transaction: %w", err)
+	}
+	return &user, nil
+}
+
+// CountByCountry returns the number of users per country.
+func (p *Postgres) CountByCountry(ctx context.Context) (map[string]int64, error) {
+	ctx, end := p.startQuery(ctx, "count_by_country")
+	defer end()
+
+	var rows []struct {
+		Country string `db:"country"`
+		Count   int64  `db:"count"`
+	}
+	if err := p.db.SelectContext(
+		ctx,
+		&rows,
+		countByCountryQuery,
+		tenantOf(ctx),
+	); err != nil {
+		return nil, fmt.Errorf("could not count users by country: %w", err)
+	}
+
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Country] = row.Count
+	}
+	return counts, nil
+}
+
+// ApplyUniquenessScope recomputes the uniqueness key of the users stored under another
+// scope. It fails with ErrDuplicateEmail or ErrDuplicateNickname when the users conflict
+// in the new scope, leaving them unchanged.
+func (p *Postgres) ApplyUniquenessScope(ctx context.Context) (int64, error) {
+	ctx, end := p.startQuery(ctx, "apply_uniqueness_scope")
+	defer end()
+
+	key := p.scope.keyColumn()
+
+	res, err := p.db.ExecContext(ctx, fmt.Sprintf(
+		"UPDATE users SET uniqueness_key = %s WHERE uniqueness_key <> %s", key, key,
+	))
+	if err != nil {
+		if dupErr := uniqueViolationError(err); dupErr != nil {
+			return 0, fmt.Errorf("could not apply uniqueness scope: %w", dupErr)
+		}
+		return 0, fmt.Errorf("could not apply uniqueness scope: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// CheckDatabaseHealth checks if the database is healthy by pinging it.
+func (p *Postgres) CheckDatabaseHealth(ctx context.Context) error {
+	if err := p.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("could not ping database: %w", err)
+	}
+	return nil
+}
+
+// insertUser inserts the user. New users start at event sequence 1 with the user role,
+// users copied from another store (e.g. by a dual-write backfill) keep theirs.
+func insertUser(ctx context.Context, q sqlx.ExtContext, scope UniquenessScope, user *User) error {
+	user.setInsertDefaults()
+
+	if _, err := sqlx.NamedExecContext(
+		ctx,
+		q,
+		insertUserQuery,
+		scope.scoped(ctx, user),
+	); err != nil {
+		if dupErr := uniqueViolationError(err); dupErr != nil {
+			return dupErr
+		}
+		return err
+	}
+	return nil
+}
+
+// userUpdater is the database, or the transaction, updateUser runs on.
+type userUpdater interface {
+	BindNamed(query string, arg any) (string, []any, error)
+	GetContext(ctx context.Context, dest any, query string, args ...any) error
+}
+
+// updateUser updates the user, increments its event sequence and sets it on the user.
+func updateUser(ctx context.Context, q userUpdater, scope UniquenessScope, user *User) error {
+	query, args, err := q.BindNamed(updateUserQuery, scope.scoped(ctx, user))
+	if err != nil {
+		return err
+	}
+
+	if err := q.GetContext(ctx, &user.EventSequence, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrUserNotFound
+		}
+		if dupErr := uniqueViolationError(err); dupErr != nil {
+			return dupErr
+		}
+		return err
+	}
+	return nil
+}
+
+// uniqueViolationError maps a unique violation to ErrDuplicateNickname or ErrDuplicateEmail
+// based on the violated constraint. It returns nil for any other error.
+func uniqueViolationError(err error) error {
+	var pgErr *pq.Error
+	if !errors.As(err, &pgErr) || pgErr.Code != uniqueViolationCode {
+		return nil
+	}
+
+	if pgErr.Constraint == nicknameUniqueConstraint {
+		return ErrDuplicateNickname
+	}
+	return ErrDuplicateEmail
+}
+
+// startQuery starts a client span for the operation. The returned function ends
+// the span and reports the elapsed time to the query observer, if any.
+func (p *Postgres) startQuery(ctx context.Context, operation string) (context.Context, func()) {
+	start := time.Now()
+
+	ctx, span := tracer.Start(ctx, "postgres."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBOperation(operation),
+			semconv.DBSQLTable("users"),
+		),
+	)
+
+	return ctx, func() {
+		span.End()
+		if p.observer != nil {
+			p.observer.ObserveQuery(operation, time.Since(start))
+		}
+	}
+}
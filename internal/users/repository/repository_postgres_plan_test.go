@@ -0,0 +1,168 @@
+//go:build integration && queryplan
+// +build integration,queryplan
+
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// planSeedSize is the number of seeded users. The table must be large enough for the
+// planner to prefer the indexes over sequential scans on selective predicates.
+const planSeedSize int = 50000
+
+// planNode is a node of a plan explained with EXPLAIN (FORMAT JSON).
+type planNode struct {
+	NodeType     string     `json:"Node Type"`
+	RelationName string     `json:"Relation Name"`
+	IndexName    string     `json:"Index Name"`
+	Plans        []planNode `json:"Plans"`
+}
+
+// TestQueryPlans explains the list, count and search queries on the indexed paths and
+// fails if the plans scan the users table sequentially, e.g. when a new filter is added
+// without its index. The first and last name filters are not indexed and not checked.
+func TestQueryPlans(t *testing.T) {
+	db := setupDBHelper(t)
+	defer teardownDBHelper(t, db)
+
+	seedPlanDatasetHelper(t, db)
+
+	var (
+		cursor  = "00000000-0000-0000-0000-000000000000"
+		created = time.Date(2021, time.March, 1, 0, 0, 0, 0, time.UTC)
+	)
+
+	testCases := []struct {
+		name  string
+		query func() (string, []any)
+	}{
+		{
+			name:  "list",
+			query: func() (string, []any) { return listQuery("", Filter{}, Page{Limit: 50}) },
+		},
+		{
+			name:  "list after cursor",
+			query: func() (string, []any) { return listQuery("", Filter{}, Page{Cursor: cursor, Limit: 50}) },
+		},
+		{
+			name:  "list of a tenant",
+			query: func() (string, []any) { return listQuery("acme", Filter{}, Page{Limit: 50}) },
+		},
+		{
+			name:  "list by country",
+			query: func() (string, []any) { return listQuery("", Filter{Country: "QZ"}, Page{Limit: 50}) },
+		},
+		{
+			name:  "list by country after cursor",
+			query: func() (string, []any) { return listQuery("", Filter{Country: "QZ"}, Page{Cursor: cursor, Limit: 50}) },
+		},
+		{
+			name:  "list by nickname prefix",
+			query: func() (string, []any) { return listQuery("", Filter{NicknamePrefix: "nick1234"}, Page{Limit: 50}) },
+		},
+		{
+			name:  "list by email",
+			query: func() (string, []any) { return listQuery("", Filter{Email: "User1234@Example.com"}, Page{Limit: 50}) },
+		},
+		{
+			name: "list by creation range",
+			query: func() (string, []any) {
+				return listQuery("", Filter{CreatedAfter: created, CreatedBefore: created.Add(24 * time.Hour)}, Page{Limit: 50})
+			},
+		},
+		{
+			name:  "count by country",
+			query: func() (string, []any) { return countQuery("", Filter{Country: "QZ"}) },
+		},
+		{
+			name:  "count by nickname prefix",
+			query: func() (string, []any) { return countQuery("", Filter{NicknamePrefix: "nick1234"}) },
+		},
+		{
+			name: "count by creation range",
+			query: func() (string, []any) {
+				return countQuery("", Filter{CreatedAfter: created, CreatedBefore: created.Add(24 * time.Hour)})
+			},
+		},
+		{
+			name:  "nickname exists",
+			query: func() (string, []any) { return nicknameExistsQuery, []any{"", "nick1234"} },
+		},
+		{
+			name: "search",
+			query: func() (string, []any) {
+				return searchQuery, []any{prefixTSQuery(searchTerms("nick1234")), 50, 0}
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			query, args := tc.query()
+
+			// Act
+			plan := explainHelper(t, db, query, args...)
+
+			// Assert
+			assert.Empty(t, seqScans(plan, "users"), "sequential scan in the plan of %s", query)
+		})
+	}
+}
+
+// seqScans returns the sequential scans of the relation in the plan.
+func seqScans(node planNode, relation string) []planNode {
+	var scans []planNode
+	if node.NodeType == "Seq Scan" && node.RelationName == relation {
+		scans = append(scans, node)
+	}
+
+	for _, child := range node.Plans {
+		scans = append(scans, seqScans(child, relation)...)
+	}
+	return scans
+}
+
+func explainHelper(t *testing.T, db *sqlx.DB, query string, args ...any) planNode {
+	t.Helper()
+
+	var raw []byte
+	require.NoError(t, db.QueryRowContext(context.TODO(), "EXPLAIN (FORMAT JSON) "+query, args...).Scan(&raw))
+
+	var explained []struct {
+		Plan planNode `json:"Plan"`
+	}
+	require.NoError(t, json.Unmarshal(raw, &explained))
+	require.Len(t, explained, 1)
+
+	return explained[0].Plan
+}
+
+// seedPlanDatasetHelper inserts planSeedSize users and refreshes the statistics of the
+// table. The countries are two letter codes spread over the users, and the users are
+// created an hour apart, so the filters of the test cases are selective.
+func seedPlanDatasetHelper(t *testing.T, db *sqlx.DB) {
+	t.Helper()
+
+	_, err := db.Exec(
+		`INSERT INTO users (id, first_name, last_name, nickname, password, email, country, created_at, updated_at)
+		SELECT md5(i::text)::uuid, 'first' || i, 'last' || i, 'nick' || i, 'password',
+			'user' || i || '@example.com', chr(65 + i % 26) || chr(65 + (i / 26) % 26),
+			timestamptz '2020-01-01' + i * interval '1 hour', timestamptz '2020-01-01' + i * interval '1 hour'
+		FROM generate_series(1, $1) AS i`,
+		planSeedSize,
+	)
+	require.NoError(t, err)
+
+	_, err = db.Exec("VACUUM ANALYZE users")
+	require.NoError(t, err)
+}
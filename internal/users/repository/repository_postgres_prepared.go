@@ -0,0 +1,296 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// preparedQueries are the fixed queries prepared by Prepare, and the List queries of
+// every user and of the users of a country, the pages listed most. The other List and
+// Count queries depend on the filter, and the queries of the transactions are not
+// repeated often enough to be worth it.
+var preparedQueries = []string{
+	getUserQuery,
+	getUserByEmailQuery,
+	queryOf(listQuery("", Filter{}, Page{})),
+	queryOf(listQuery("", Filter{}, Page{Cursor: "cursor"})),
+	queryOf(listQuery("", Filter{Country: "country"}, Page{})),
+	queryOf(listQuery("", Filter{Country: "country"}, Page{Cursor: "cursor"})),
+	searchQuery,
+	deleteUserQuery,
+	setUserStatusQuery,
+	setUserAvatarQuery,
+	getAPIKeyQuery,
+	getAPIKeysQuery,
+	deleteAPIKeyQuery,
+	getLinkedIdentitiesQuery,
+	unlinkIdentityQuery,
+	unlockFieldQuery,
+	getFieldLocksQuery,
+	getByLinkedIdentityQuery,
+	getRecentLoginsQuery,
+	getLockoutQuery,
+	recordFailedLoginQuery,
+	lockUserQuery,
+	resetLockoutQuery,
+	getSessionQuery,
+	getSessionsQuery,
+	rotateSessionQuery,
+	deleteSessionQuery,
+	getTOTPQuery,
+	useTOTPQuery,
+	attemptPhoneVerificationQuery,
+	deletePhoneVerificationQuery,
+	countByCountryQuery,
+	nicknameExistsQuery,
+}
+
+// preparedNamedQueries are the fixed queries with named parameters prepared by Prepare.
+var preparedNamedQueries = []string{
+	insertUserQuery,
+	updateUserQuery,
+	insertAPIKeyQuery,
+	linkIdentityQuery,
+	lockFieldQuery,
+	recordLoginQuery,
+	insertPhoneVerificationQuery,
+}
+
+// queryOf returns the query built by a query function, without its arguments.
+func queryOf(query string, _ []any) string {
+	return query
+}
+
+// statementDB runs the queries on their prepared statement, when there is one, and on the
+// database otherwise. The named queries are bound to positional parameters before they
+// reach it, the same way they are when prepared, so they find their statement too.
+//
+// With row-level security, every query runs in a transaction scoped to the tenant (see
+// BeginTxx), or on the bypass database when it isn't scoped to any. The queries returning
+// rows must then go through GetContext or SelectContext, which read the rows before the
+// transaction ends. The queries failing with a transient
+// error are retried as a whole, with the transaction, following the retry policy.
+type statementDB struct {
+	*sqlx.DB
+
+	rowLevelSecurity bool
+	bypass           *sqlx.DB
+	retry            RetryPolicy
+
+	mu    sync.RWMutex
+	stmts map[string]*sqlx.Stmt
+}
+
+// stmt returns the prepared statement of the query, or nil.
+func (db *statementDB) stmt(query string) *sqlx.Stmt {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	return db.stmts[query]
+}
+
+// GetContext runs the query and scans the single row into dest.
+func (db *statementDB) GetContext(ctx context.Context, dest any, query string, args ...any) error {
+	return db.retry.do(ctx, func() error {
+		return db.get(ctx, dest, query, args...)
+	})
+}
+
+func (db *statementDB) get(ctx context.Context, dest any, query string, args ...any) error {
+	if bypass := db.bypassOf(ctx); bypass != nil {
+		return bypass.GetContext(ctx, dest, query, args...)
+	}
+
+	if db.rowLevelSecurity {
+		return db.inTenantTx(ctx, func(tx *sqlx.Tx) error {
+			if stmt := db.stmt(query); stmt != nil {
+				return tx.StmtxContext(ctx, stmt).GetContext(ctx, dest, args...)
+			}
+			return tx.GetContext(ctx, dest, query, args...)
+		})
+	}
+
+	if stmt := db.stmt(query); stmt != nil {
+		return stmt.GetContext(ctx, dest, args...)
+	}
+	return db.DB.GetContext(ctx, dest, query, args...)
+}
+
+// SelectContext runs the query and scans the rows into dest.
+func (db *statementDB) SelectContext(ctx context.Context, dest any, query string, args ...any) error {
+	return db.retry.do(ctx, func() error {
+		return db.selectRows(ctx, dest, query, args...)
+	})
+}
+
+func (db *statementDB) selectRows(ctx context.Context, dest any, query string, args ...any) error {
+	if bypass := db.bypassOf(ctx); bypass != nil {
+		return bypass.SelectContext(ctx, dest, query, args...)
+	}
+
+	if db.rowLevelSecurity {
+		return db.inTenantTx(ctx, func(tx *sqlx.Tx) error {
+			if stmt := db.stmt(query); stmt != nil {
+				return tx.StmtxContext(ctx, stmt).SelectContext(ctx, dest, args...)
+			}
+			return tx.SelectContext(ctx, dest, query, args...)
+		})
+	}
+
+	if stmt := db.stmt(query); stmt != nil {
+		return stmt.SelectContext(ctx, dest, args...)
+	}
+	return db.DB.SelectContext(ctx, dest, query, args...)
+}
+
+// ExecContext runs the statement.
+func (db *statementDB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	var result sql.Result
+	err := db.retry.do(ctx, func() (err error) {
+		result, err = db.exec(ctx, query, args...)
+		return err
+	})
+	return result, err
+}
+
+func (db *statementDB) exec(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	if bypass := db.bypassOf(ctx); bypass != nil {
+		return bypass.ExecContext(ctx, query, args...)
+	}
+
+	if db.rowLevelSecurity {
+		var result sql.Result
+		err := db.inTenantTx(ctx, func(tx *sqlx.Tx) (err error) {
+			if stmt := db.stmt(query); stmt != nil {
+				result, err = tx.StmtxContext(ctx, stmt).ExecContext(ctx, args...)
+				return err
+			}
+			result, err = tx.ExecContext(ctx, query, args...)
+			return err
+		})
+		return result, err
+	}
+
+	if stmt := db.stmt(query); stmt != nil {
+		return stmt.ExecContext(ctx, args...)
+	}
+	return db.DB.ExecContext(ctx, query, args...)
+}
+
+// BeginTxx begins a transaction. With row-level security, it is scoped to the tenant of ctx
+// with SET LOCAL app.tenant_id, through set_config to pass the tenant as a parameter, so the
+// policies of the users table hide the users of the other tenants. The transactions of the
+// unscoped contexts, e.g. of the background jobs, begin on the bypass database, or else set
+// it to an empty string, which the policies let see no user.
+func (db *statementDB) BeginTxx(ctx context.Context, opts *sql.TxOptions) (*sqlx.Tx, error) {
+	if bypass := db.bypassOf(ctx); bypass != nil {
+		return bypass.BeginTxx(ctx, opts)
+	}
+
+	tx, err := db.DB.BeginTxx(ctx, opts)
+	if err != nil || !db.rowLevelSecurity {
+		return tx, err
+	}
+
+	if _, err := tx.ExecContext(ctx, "SELECT set_config('app.tenant_id', $1, TRUE)", tenantOf(ctx)); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("could not set tenant: %w", err)
+	}
+	return tx, nil
+}
+
+// bypassOf returns the database bypassing the row-level security for the queries of ctx when
+// it isn't scoped to any tenant, or nil.
+func (db *statementDB) bypassOf(ctx context.Context) *sqlx.DB {
+	if !db.rowLevelSecurity || tenantOf(ctx) != "" {
+		return nil
+	}
+	return db.bypass
+}
+
+// inTenantTx runs fn in a transaction scoped to the tenant of ctx and commits it.
+func (db *statementDB) inTenantTx(ctx context.Context, fn func(tx *sqlx.Tx) error) error {
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// NamedExecContext binds the named query and runs it with ExecContext.
+func (db *statementDB) NamedExecContext(ctx context.Context, query string, arg any) (sql.Result, error) {
+	return sqlx.NamedExecContext(ctx, db, query, arg)
+}
+
+// prepare prepares the queries, replacing the statements prepared before.
+func (db *statementDB) prepare(ctx context.Context, queries, namedQueries []string) error {
+	stmts := make(map[string]*sqlx.Stmt, len(queries)+len(namedQueries))
+
+	for _, query := range queries {
+		stmt, err := db.DB.PreparexContext(ctx, query)
+		if err != nil {
+			closeStatements(stmts)
+			return fmt.Errorf("could not prepare query: %w", err)
+		}
+		stmts[query] = stmt
+	}
+
+	for _, query := range namedQueries {
+		named, err := db.DB.PrepareNamedContext(ctx, query)
+		if err != nil {
+			closeStatements(stmts)
+			return fmt.Errorf("could not prepare query: %w", err)
+		}
+		stmts[named.QueryString] = named.Stmt
+	}
+
+	db.mu.Lock()
+	previous := db.stmts
+	db.stmts = stmts
+	db.mu.Unlock()
+
+	return closeStatements(previous)
+}
+
+// close closes the prepared statements. The queries keep working without them.
+func (db *statementDB) close() error {
+	db.mu.Lock()
+	stmts := db.stmts
+	db.stmts = nil
+	db.mu.Unlock()
+
+	return closeStatements(stmts)
+}
+
+func closeStatements(stmts map[string]*sqlx.Stmt) error {
+	var errs []error
+	for _, stmt := range stmts {
+		if err := stmt.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Prepare prepares the fixed queries of the repository, so Postgres parses them once per
+// connection instead of on every request. It must be called after the migrations. Don't
+// use it behind a pooler in transaction mode (e.g. PgBouncer), which doesn't keep the
+// prepared statements of the connections.
+func (p *Postgres) Prepare(ctx context.Context) error {
+	return p.db.prepare(ctx, preparedQueries, preparedNamedQueries)
+}
+
+// Close closes the prepared statements, but not the database.
+func (p *Postgres) Close() error {
+	return p.db.close()
+}
@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// transientErrorCodes are the codes of the Postgres errors after which the statement can
+// be run again: it was rolled back, or never ran.
+var transientErrorCodes = map[pq.ErrorCode]bool{
+	"40001": true, // serialization_failure
+	"40P01": true, // deadlock_detected
+	"55P03": true, // lock_not_available
+	"53300": true, // too_many_connections
+	"57P03": true, // cannot_connect_now
+}
+
+// RetryPolicy retries the statements of the Postgres repository failing with a transient
+// error, e.g. a deadlock or a database restarting. The statements are retried up to
+// MaxAttempts times in all, after a random wait up to Backoff doubled on every retry, and
+// never past the deadline of their context. The statements of the transactions, e.g. of
+// a merge, are not retried.
+type RetryPolicy struct {
+	MaxAttempts int
+	Backoff     time.Duration
+}
+
+// WithRetryPolicy configures the retries of the statements failing with a transient
+// error. They are not retried by default.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(p *Postgres) {
+		p.db.retry = policy
+	}
+}
+
+// do runs fn until it succeeds, fails with an error that isn't transient or the policy
+// gives up.
+func (r RetryPolicy) do(ctx context.Context, fn func() error) error {
+	backoff := r.Backoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= r.MaxAttempts || !isTransient(err) {
+			return err
+		}
+
+		wait := time.Duration(rand.Int63n(int64(backoff) + 1))
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return err
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+// isTransient reports whether the error is one of transientErrorCodes.
+func isTransient(err error) bool {
+	var pgErr *pq.Error
+	return errors.As(err, &pgErr) && transientErrorCodes[pgErr.Code]
+}
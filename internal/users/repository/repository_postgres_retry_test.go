@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryPolicyDo(t *testing.T) {
+	t.Parallel()
+
+	deadlock := fmt.Errorf("could not update user: %w", &pq.Error{Code: "40P01"})
+
+	testCases := []struct {
+		name             string
+		policy           RetryPolicy
+		timeout          time.Duration
+		errs             []error
+		expectedErr      error
+		expectedAttempts int
+	}{
+		{
+			name:             "succeeds after transient errors",
+			policy:           RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond},
+			errs:             []error{deadlock, deadlock, nil},
+			expectedAttempts: 3,
+		},
+		{
+			name:             "gives up after max attempts",
+			policy:           RetryPolicy{MaxAttempts: 2, Backoff: time.Millisecond},
+			errs:             []error{deadlock, deadlock, nil},
+			expectedErr:      deadlock,
+			expectedAttempts: 2,
+		},
+		{
+			name:             "does not retry other errors",
+			policy:           RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond},
+			errs:             []error{ErrUserNotFound, nil},
+			expectedErr:      ErrUserNotFound,
+			expectedAttempts: 1,
+		},
+		{
+			name:             "does not retry without policy",
+			errs:             []error{deadlock, nil},
+			expectedErr:      deadlock,
+			expectedAttempts: 1,
+		},
+		{
+			name:             "does not wait past the deadline",
+			policy:           RetryPolicy{MaxAttempts: 3, Backoff: time.Hour},
+			timeout:          time.Millisecond,
+			errs:             []error{deadlock, nil},
+			expectedErr:      deadlock,
+			expectedAttempts: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Arrange
+			ctx := context.Background()
+			if tc.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tc.timeout)
+				defer cancel()
+			}
+
+			var attempts int
+
+			// Act
+			err := tc.policy.do(ctx, func() error {
+				attempts++
+				return tc.errs[attempts-1]
+			})
+
+			// Assert
+			assert.True(t, errors.Is(err, tc.expectedErr))
+			assert.Equal(t, tc.expectedAttempts, attempts)
+		})
+	}
+}
@@ -7,9 +7,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/alesr/usrsvc/internal/tenant"
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
 	"github.com/pressly/goose/v3"
@@ -65,7 +67,51 @@ func TestGet(t *testing.T) {
 	})
 }
 
-func TestGetByCountry(t *testing.T) {
+func TestGetByEmail(t *testing.T) {
+	db := setupDBHelper(t)
+	defer teardownDBHelper(t, db)
+
+	t.Run("happy case", func(t *testing.T) {
+		// Arrange
+		givenUser := &User{
+			ID:        uuid.New().String(),
+			FirstName: "John",
+			LastName:  "Doe",
+			Nickname:  "johndoe",
+			Password:  "password",
+			Email:     "joedoe@foo.bar",
+			Country:   "BR",
+			CreatedAt: time.Time{}.Add(1 * time.Second),
+			UpdatedAt: time.Time{}.Add(2 * time.Second),
+		}
+
+		repo := NewPostgres(db)
+
+		require.NoError(t, repo.Insert(context.TODO(), givenUser))
+
+		// Act
+		actualUser, actualErr := repo.GetByEmail(context.TODO(), givenUser.Email)
+		require.NoError(t, actualErr)
+
+		// Assert
+		require.Equal(t, givenUser, actualUser)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		// Arrange
+		repo := NewPostgres(db)
+
+		// Act
+		actualUser, actualErr := repo.GetByEmail(context.TODO(), "unknown@foo.bar")
+
+		// Assert
+		require.Error(t, actualErr)
+		assert.True(t, errors.Is(actualErr, ErrUserNotFound))
+		assert.Nil(t, actualUser)
+	})
+}
+
+func TestListByCountry(t *testing.T) {
 	db := setupDBHelper(t)
 	defer teardownDBHelper(t, db)
 
@@ -98,13 +144,13 @@ func TestGetByCountry(t *testing.T) {
 
 		repo := NewPostgres(db)
 
-		// Insert users so we can test the GetByCountry method
+		// Insert users so we can test listing them by country
 		for _, user := range givenUsers {
 			require.NoError(t, repo.Insert(context.TODO(), user))
 		}
 
 		// Act
-		actualUsers, actualErr := repo.GetByCountry(context.TODO(), "BR", "", 10)
+		actualUsers, actualErr := repo.List(context.TODO(), Filter{Country: "BR"}, Page{Limit: 10})
 		require.NoError(t, actualErr)
 
 		// Assert
@@ -119,7 +165,7 @@ func TestGetByCountry(t *testing.T) {
 		repo := NewPostgres(db)
 
 		// Act
-		actualUsers, actualErr := repo.GetByCountry(context.TODO(), "UK", "", 10)
+		actualUsers, actualErr := repo.List(context.TODO(), Filter{Country: "UK"}, Page{Limit: 10})
 
 		// Assert
 		require.NoError(t, actualErr)
@@ -127,6 +173,156 @@ func TestGetByCountry(t *testing.T) {
 	})
 }
 
+func TestListByFilter(t *testing.T) {
+	db := setupDBHelper(t)
+	defer teardownDBHelper(t, db)
+
+	// Arrange
+	createdAt := time.Date(2023, 1, 15, 0, 0, 0, 0, time.UTC)
+	givenUsers := []*User{
+		{
+			ID:        uuid.New().String(),
+			FirstName: "John",
+			LastName:  "Doe",
+			Nickname:  "johndoe",
+			Password:  "password",
+			Email:     "joedoe@foo.bar",
+			Country:   "BR",
+			CreatedAt: createdAt,
+			UpdatedAt: createdAt,
+		},
+		{
+			ID:        uuid.New().String(),
+			FirstName: "John",
+			LastName:  "Smith",
+			Nickname:  "john_smith",
+			Password:  "password",
+			Email:     "johnsmith@foo.bar",
+			Country:   "BR",
+			CreatedAt: createdAt.AddDate(0, 1, 0),
+			UpdatedAt: createdAt.AddDate(0, 1, 0),
+		},
+	}
+
+	repo := NewPostgres(db)
+	for _, user := range givenUsers {
+		require.NoError(t, repo.Insert(context.TODO(), user))
+	}
+
+	testCases := []struct {
+		name        string
+		givenFilter Filter
+		expected    []*User
+	}{
+		{
+			name:        "names ignoring case",
+			givenFilter: Filter{Country: "BR", FirstName: "JOHN", LastName: "doe"},
+			expected:    givenUsers[:1],
+		},
+		{
+			name:        "nickname prefix with wildcard",
+			givenFilter: Filter{NicknamePrefix: "john_"},
+			expected:    givenUsers[1:],
+		},
+		{
+			name:        "email ignoring case",
+			givenFilter: Filter{Email: "JohnSmith@foo.bar"},
+			expected:    givenUsers[1:],
+		},
+		{
+			name:        "creation range",
+			givenFilter: Filter{CreatedAfter: createdAt, CreatedBefore: createdAt.AddDate(0, 1, 0)},
+			expected:    givenUsers[:1],
+		},
+		{
+			name:        "no match",
+			givenFilter: Filter{Country: "US", NicknamePrefix: "john"},
+			expected:    nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			actualUsers, err := repo.List(context.TODO(), tc.givenFilter, Page{Limit: 10})
+
+			// Assert
+			require.NoError(t, err)
+			require.Len(t, actualUsers, len(tc.expected))
+			for i, user := range tc.expected {
+				assert.Equal(t, user.ID, actualUsers[i].ID)
+			}
+
+			count, err := repo.Count(context.TODO(), tc.givenFilter)
+			require.NoError(t, err)
+			assert.Equal(t, int64(len(tc.expected)), count)
+		})
+	}
+}
+
+func TestSearch(t *testing.T) {
+	db := setupDBHelper(t)
+	defer teardownDBHelper(t, db)
+
+	// Arrange
+	givenUsers := []*User{
+		{
+			ID:        uuid.New().String(),
+			FirstName: "John",
+			LastName:  "Doe",
+			Nickname:  "johndoe",
+			Password:  "password",
+			Email:     "joedoe@foo.bar",
+			Country:   "BR",
+			CreatedAt: time.Time{}.Add(1 * time.Second),
+			UpdatedAt: time.Time{}.Add(2 * time.Second),
+		},
+		{
+			ID:        uuid.New().String(),
+			FirstName: "Jane",
+			LastName:  "Doe",
+			Nickname:  "janed",
+			Password:  "password",
+			Email:     "janedoe@foo.bar",
+			Country:   "BR",
+			CreatedAt: time.Time{}.Add(1 * time.Second),
+			UpdatedAt: time.Time{}.Add(2 * time.Second),
+		},
+	}
+
+	repo := NewPostgres(db)
+	for _, user := range givenUsers {
+		require.NoError(t, repo.Insert(context.TODO(), user))
+	}
+
+	testCases := []struct {
+		name        string
+		givenQuery  string
+		givenOffset int
+		expectedLen int
+	}{
+		{name: "prefixes of several words", givenQuery: "Jo D", expectedLen: 1},
+		{name: "nickname", givenQuery: "janed", expectedLen: 1},
+		{name: "shared last name", givenQuery: "doe", expectedLen: 2},
+		{name: "offset", givenQuery: "doe", givenOffset: 1, expectedLen: 1},
+		{name: "tsquery operators are ignored", givenQuery: "doe & | !:*", expectedLen: 2},
+		{name: "no match", givenQuery: "smith", expectedLen: 0},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			actualUsers, err := repo.Search(context.TODO(), tc.givenQuery, tc.givenOffset, 10)
+
+			// Assert
+			require.NoError(t, err)
+			assert.Len(t, actualUsers, tc.expectedLen)
+		})
+	}
+}
+
 func TestInsert(t *testing.T) {
 	db := setupDBHelper(t)
 	defer teardownDBHelper(t, db)
@@ -182,6 +378,204 @@ func TestInsert(t *testing.T) {
 		require.Error(t, actualErr)
 		assert.True(t, errors.Is(actualErr, ErrDuplicateEmail))
 	})
+
+	t.Run("duplicate nickname", func(t *testing.T) {
+		// Arrange
+		// User was already inserted in the first test
+		repo := NewPostgres(db)
+
+		// Act
+
+		givenUser := &User{
+			ID:        uuid.New().String(),
+			FirstName: "John",
+			LastName:  "Doe",
+			Nickname:  "johndoe",
+			Password:  "password",
+			Email:     "anotherjoedoe@foo.bar",
+			Country:   "US",
+			CreatedAt: time.Time{}.Add(1 * time.Second),
+			UpdatedAt: time.Time{}.Add(2 * time.Second),
+		}
+
+		actualErr := repo.Insert(context.TODO(), givenUser)
+
+		// Assert
+		require.Error(t, actualErr)
+		assert.True(t, errors.Is(actualErr, ErrDuplicateNickname))
+	})
+}
+
+func TestInsertBatch(t *testing.T) {
+	db := setupDBHelper(t)
+	defer teardownDBHelper(t, db)
+
+	newUser := func(nickname, email string) *User {
+		return &User{
+			ID:        uuid.New().String(),
+			FirstName: "John",
+			LastName:  "Doe",
+			Nickname:  nickname,
+			Password:  "password",
+			Email:     email,
+			Country:   "BR",
+			CreatedAt: time.Time{}.Add(1 * time.Second),
+			UpdatedAt: time.Time{}.Add(2 * time.Second),
+		}
+	}
+
+	t.Run("happy case", func(t *testing.T) {
+		// Arrange
+		repo := NewPostgres(db)
+		batch := []*User{newUser("johndoe", "joedoe@foo.bar"), newUser("janedoe", "janedoe@foo.bar")}
+
+		// Act
+		err := repo.InsertBatch(context.TODO(), batch)
+		require.NoError(t, err)
+
+		// Assert
+		for _, givenUser := range batch {
+			actualUser, err := repo.Get(context.TODO(), givenUser.ID)
+			require.NoError(t, err)
+			assert.Equal(t, givenUser, actualUser)
+		}
+	})
+
+	t.Run("duplicate nickname", func(t *testing.T) {
+		// Arrange
+		// The second user takes the nickname inserted in the previous test.
+		repo := NewPostgres(db)
+		batch := []*User{newUser("alice", "alice@foo.bar"), newUser("johndoe", "other@foo.bar")}
+
+		// Act
+		err := repo.InsertBatch(context.TODO(), batch)
+
+		// Assert
+		assert.True(t, errors.Is(err, ErrDuplicateNickname))
+
+		_, getErr := repo.Get(context.TODO(), batch[0].ID)
+		assert.True(t, errors.Is(getErr, ErrUserNotFound))
+	})
+}
+
+func TestTenants(t *testing.T) {
+	db := setupDBHelper(t)
+	defer teardownDBHelper(t, db)
+
+	// Arrange
+	repo := NewPostgres(db)
+	acme := tenant.ContextWithTenant(context.TODO(), "acme")
+	globex := tenant.ContextWithTenant(context.TODO(), "globex")
+
+	john := newMemoryUserHelper(t, "johndoe@foo.bar", "US")
+	require.NoError(t, repo.Insert(acme, john))
+
+	// The emails and nicknames are unique per tenant.
+	otherTenant := newMemoryUserHelper(t, "johndoe@foo.bar", "US")
+	require.NoError(t, repo.Insert(globex, otherTenant))
+
+	sameTenant := newMemoryUserHelper(t, "johndoe@foo.bar", "US")
+	sameTenant.Nickname = "johndoe_us"
+
+	// Act
+	sameTenantErr := repo.Insert(acme, sameTenant)
+
+	visible, err := repo.Get(acme, john.ID)
+	require.NoError(t, err)
+
+	_, hiddenErr := repo.Get(globex, john.ID)
+
+	listed, err := repo.List(acme, Filter{}, Page{Limit: 10})
+	require.NoError(t, err)
+
+	// The operations that aren't scoped, e.g. the background jobs, see every tenant.
+	count, err := repo.Count(context.TODO(), Filter{})
+	require.NoError(t, err)
+
+	john.FirstName = "Johnny"
+	hiddenUpdateErr := repo.Update(globex, john)
+
+	// Assert
+	assert.True(t, errors.Is(sameTenantErr, ErrDuplicateEmail))
+	assert.Equal(t, "acme", visible.TenantID)
+	assert.True(t, errors.Is(hiddenErr, ErrUserNotFound))
+	require.Len(t, listed, 1)
+	assert.Equal(t, john.ID, listed[0].ID)
+	assert.Equal(t, int64(2), count)
+	assert.True(t, errors.Is(hiddenUpdateErr, ErrUserNotFound))
+}
+
+func TestRowLevelSecurity(t *testing.T) {
+	db := setupDBHelper(t)
+	defer teardownDBHelper(t, db)
+
+	// The policies do not apply to the superuser running the tests, which bypasses them for
+	// the unscoped queries, so the scoped ones run as a regular role, on a single connection
+	// to keep it.
+	scopedDB := setupDBHelper(t)
+	defer scopedDB.Close()
+
+	scopedDB.SetMaxOpenConns(1)
+
+	_, err := db.Exec(`DO $$
+	BEGIN
+		IF NOT EXISTS (SELECT FROM pg_roles WHERE rolname = 'usrsvc_rls') THEN
+			CREATE ROLE usrsvc_rls;
+		END IF;
+	END $$`)
+	require.NoError(t, err)
+
+	_, err = db.Exec("GRANT ALL ON ALL TABLES IN SCHEMA public TO usrsvc_rls")
+	require.NoError(t, err)
+
+	_, err = scopedDB.Exec("SET ROLE usrsvc_rls")
+	require.NoError(t, err)
+
+	// Arrange
+	repo := NewPostgres(scopedDB, WithRowLevelSecurity(db))
+	withoutBypass := NewPostgres(scopedDB, WithRowLevelSecurity(nil))
+
+	acme := tenant.ContextWithTenant(context.TODO(), "acme")
+	globex := tenant.ContextWithTenant(context.TODO(), "globex")
+
+	john := newMemoryUserHelper(t, "johndoe@foo.bar", "US")
+	require.NoError(t, repo.Insert(acme, john))
+
+	jane := newMemoryUserHelper(t, "janedoe@foo.bar", "US")
+	require.NoError(t, repo.Insert(globex, jane))
+
+	// Act
+
+	// The queries missing their tenant filter only see the users of the tenant, and the
+	// unscoped ones see every user through the bypass database only.
+	var acmeCount, unscopedCount, withoutBypassCount int64
+	require.NoError(t, repo.db.GetContext(acme, &acmeCount, "SELECT count(*) FROM users"))
+	require.NoError(t, repo.db.GetContext(context.TODO(), &unscopedCount, "SELECT count(*) FROM users"))
+	require.NoError(t, withoutBypass.db.GetContext(context.TODO(), &withoutBypassCount, "SELECT count(*) FROM users"))
+
+	_, deleteErr := repo.db.ExecContext(globex, "DELETE FROM users")
+	_, unscopedDeleteErr := withoutBypass.db.ExecContext(context.TODO(), "DELETE FROM users")
+
+	visible, err := repo.Get(acme, john.ID)
+	require.NoError(t, err)
+
+	_, hiddenErr := repo.Get(globex, john.ID)
+
+	john.FirstName = "Johnny"
+	updateErr := repo.Update(acme, john)
+
+	// Assert
+	assert.Equal(t, int64(1), acmeCount)
+	assert.Equal(t, int64(2), unscopedCount)
+	assert.Equal(t, int64(0), withoutBypassCount)
+	require.NoError(t, deleteErr)
+	require.NoError(t, unscopedDeleteErr)
+	assert.Equal(t, "acme", visible.TenantID)
+	assert.True(t, errors.Is(hiddenErr, ErrUserNotFound))
+	assert.NoError(t, updateErr)
+
+	_, err = repo.Get(acme, john.ID)
+	assert.NoError(t, err)
 }
 
 func TestUpdate(t *testing.T) {
@@ -207,6 +601,8 @@ func TestUpdate(t *testing.T) {
 		// Insert a user manually so we can test the Update method
 		require.NoError(t, repo.Insert(context.TODO(), givenUser))
 
+		birthdate := time.Date(1990, time.February, 28, 0, 0, 0, 0, time.UTC)
+
 		// Act
 		err := repo.Update(context.TODO(), &User{
 			ID:        givenUser.ID,
@@ -216,6 +612,9 @@ func TestUpdate(t *testing.T) {
 			Password:  "password",
 			Email:     "joedoe@foo.quz",
 			Country:   "US",
+			Locale:    "en-US",
+			Timezone:  "America/New_York",
+			Birthdate: &birthdate,
 			CreatedAt: time.Time{}.Add(1 * time.Second),
 			UpdatedAt: time.Time{}.Add(2 * time.Second),
 		})
@@ -232,6 +631,11 @@ func TestUpdate(t *testing.T) {
 		require.Equal(t, "password", actualUser.Password)
 		require.Equal(t, "joedoe@foo.quz", actualUser.Email)
 		require.Equal(t, "US", actualUser.Country)
+		require.Equal(t, "en-US", actualUser.Locale)
+		require.Equal(t, "America/New_York", actualUser.Timezone)
+		require.NotNil(t, actualUser.Birthdate)
+		require.True(t, birthdate.Equal(*actualUser.Birthdate))
+		require.Equal(t, int64(2), actualUser.EventSequence)
 	})
 
 	t.Run("not found", func(t *testing.T) {
@@ -257,19 +661,1137 @@ func TestUpdate(t *testing.T) {
 	})
 }
 
-// Possible these helper functions could be imported from the tests package
-// (with some refactoring) but, "A little copying is better than a little dependency".
-// https://go-proverbs.github.io/
+func TestDelete(t *testing.T) {
+	db := setupDBHelper(t)
+	defer teardownDBHelper(t, db)
 
-const (
-	migrationsDir      string = "../../../migrations"
-	postgresDriverName string = "postgres"
-	dbHost             string = "localhost"
-	dbPort             string = "5432"
-	dbUser             string = "user"
-	dbPass             string = "password"
-	dbName             string = "usrsvc"
-)
+	t.Run("happy case", func(t *testing.T) {
+		// Arrange
+		givenUser := &User{
+			ID:        uuid.New().String(),
+			FirstName: "John",
+			LastName:  "Doe",
+			Nickname:  "johndoe",
+			Password:  "password",
+			Email:     "joedoe@foo.bar",
+			Country:   "BR",
+			CreatedAt: time.Time{}.Add(1 * time.Second),
+			UpdatedAt: time.Time{}.Add(2 * time.Second),
+		}
+
+		repo := NewPostgres(db)
+		require.NoError(t, repo.Insert(context.TODO(), givenUser))
+
+		// Act
+		sequence, err := repo.Delete(context.TODO(), givenUser.ID)
+		require.NoError(t, err)
+
+		// Assert
+		assert.Equal(t, int64(2), sequence)
+
+		_, err = repo.Get(context.TODO(), givenUser.ID)
+		assert.True(t, errors.Is(err, ErrUserNotFound))
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		// Arrange
+		repo := NewPostgres(db)
+
+		// Act
+		_, err := repo.Delete(context.TODO(), uuid.New().String())
+
+		// Assert
+		assert.True(t, errors.Is(err, ErrUserNotFound))
+	})
+}
+
+func TestMerge(t *testing.T) {
+	db := setupDBHelper(t)
+	defer teardownDBHelper(t, db)
+
+	t.Run("happy case", func(t *testing.T) {
+		// Arrange
+		repo := NewPostgres(db)
+
+		survivor := &User{
+			ID:        uuid.New().String(),
+			FirstName: "John",
+			LastName:  "Doe",
+			Nickname:  "johndoe",
+			Password:  "password",
+			Email:     "joedoe@foo.bar",
+			Country:   "BR",
+			CreatedAt: time.Time{}.Add(1 * time.Second),
+			UpdatedAt: time.Time{}.Add(2 * time.Second),
+		}
+
+		duplicate := &User{
+			ID:        uuid.New().String(),
+			FirstName: "John",
+			LastName:  "Doe",
+			Nickname:  "jdoe",
+			Password:  "password",
+			Email:     "joe.doe@foo.bar",
+			Country:   "BR",
+			CreatedAt: time.Time{}.Add(3 * time.Second),
+			UpdatedAt: time.Time{}.Add(4 * time.Second),
+		}
+
+		require.NoError(t, repo.Insert(context.TODO(), survivor))
+		require.NoError(t, repo.Insert(context.TODO(), duplicate))
+
+		merged := *survivor
+		merged.Email = duplicate.Email
+		merged.UpdatedAt = time.Time{}.Add(5 * time.Second)
+
+		// Act
+		err := repo.Merge(context.TODO(), &merged, duplicate.ID)
+		require.NoError(t, err)
+
+		// Assert
+		actualUser, err := repo.Get(context.TODO(), survivor.ID)
+		require.NoError(t, err)
+		assert.Equal(t, duplicate.Email, actualUser.Email)
+
+		_, err = repo.Get(context.TODO(), duplicate.ID)
+		assert.True(t, errors.Is(err, ErrUserNotFound))
+
+		var mergedInto string
+		require.NoError(t, db.Get(&mergedInto, "SELECT merged_into FROM user_tombstones WHERE id = $1", duplicate.ID))
+		assert.Equal(t, survivor.ID, mergedInto)
+	})
+
+	t.Run("duplicate not found", func(t *testing.T) {
+		// Arrange
+		repo := NewPostgres(db)
+
+		// Act
+		err := repo.Merge(context.TODO(), &User{ID: uuid.New().String()}, uuid.New().String())
+
+		// Assert
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, ErrUserNotFound))
+	})
+}
+
+func TestAnonymize(t *testing.T) {
+	db := setupDBHelper(t)
+	defer teardownDBHelper(t, db)
+
+	t.Run("happy case", func(t *testing.T) {
+		// Arrange
+		repo := NewPostgres(db)
+
+		user := &User{
+			ID:        uuid.New().String(),
+			FirstName: "John",
+			LastName:  "Doe",
+			Nickname:  "johndoe",
+			Password:  "password",
+			Email:     "joedoe@foo.bar",
+			Country:   "BR",
+			CreatedAt: time.Time{}.Add(1 * time.Second),
+			UpdatedAt: time.Time{}.Add(2 * time.Second),
+		}
+
+		duplicate := &User{
+			ID:        uuid.New().String(),
+			FirstName: "John",
+			LastName:  "Doe",
+			Nickname:  "jdoe",
+			Password:  "password",
+			Email:     "joe.doe@foo.bar",
+			Country:   "BR",
+			CreatedAt: time.Time{}.Add(3 * time.Second),
+			UpdatedAt: time.Time{}.Add(4 * time.Second),
+		}
+
+		require.NoError(t, repo.Insert(context.TODO(), user))
+		require.NoError(t, repo.Insert(context.TODO(), duplicate))
+		require.NoError(t, repo.Merge(context.TODO(), user, duplicate.ID))
+
+		require.NoError(t, repo.LinkIdentity(context.TODO(), &LinkedIdentity{
+			Provider:  "google",
+			Subject:   "1234567890",
+			UserID:    user.ID,
+			Email:     "joedoe@gmail.com",
+			CreatedAt: time.Time{}.Add(5 * time.Second),
+		}))
+
+		anonymized := *user
+		anonymized.FirstName = "Anonymized"
+		anonymized.Email = "anonymized@anonymized.invalid"
+		anonymized.UpdatedAt = time.Time{}.Add(6 * time.Second)
+
+		// Act
+		err := repo.Anonymize(context.TODO(), &anonymized)
+		require.NoError(t, err)
+
+		// Assert
+		actualUser, err := repo.Get(context.TODO(), user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "anonymized@anonymized.invalid", actualUser.Email)
+		assert.True(t, actualUser.Anonymized)
+		assert.Equal(t, anonymized.EventSequence, actualUser.EventSequence)
+
+		_, err = repo.GetByLinkedIdentity(context.TODO(), "google", "1234567890")
+		assert.True(t, errors.Is(err, ErrUserNotFound))
+
+		var email string
+		require.NoError(t, db.Get(&email, "SELECT email FROM user_tombstones WHERE id = $1", duplicate.ID))
+		assert.Equal(t, "anonymized@anonymized.invalid", email)
+	})
+
+	t.Run("user not found", func(t *testing.T) {
+		// Arrange
+		repo := NewPostgres(db)
+
+		// Act
+		err := repo.Anonymize(context.TODO(), &User{ID: uuid.New().String()})
+
+		// Assert
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, ErrUserNotFound))
+	})
+}
+
+func TestSetStatus(t *testing.T) {
+	db := setupDBHelper(t)
+	defer teardownDBHelper(t, db)
+
+	t.Run("happy case", func(t *testing.T) {
+		// Arrange
+		repo := NewPostgres(db)
+
+		user := &User{
+			ID:        uuid.New().String(),
+			FirstName: "John",
+			LastName:  "Doe",
+			Nickname:  "johndoe",
+			Password:  "password",
+			Email:     "joedoe@foo.bar",
+			Country:   "BR",
+			CreatedAt: time.Time{}.Add(1 * time.Second),
+			UpdatedAt: time.Time{}.Add(2 * time.Second),
+		}
+		require.NoError(t, repo.Insert(context.TODO(), user))
+
+		suspended := *user
+		suspended.Status = StatusSuspended
+		suspended.UpdatedAt = time.Time{}.Add(3 * time.Second)
+
+		// Act
+		err := repo.SetStatus(context.TODO(), &suspended)
+		require.NoError(t, err)
+
+		// Updates keep the status.
+		require.NoError(t, repo.Update(context.TODO(), user))
+
+		// Assert
+		actualUser, err := repo.Get(context.TODO(), user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, StatusSuspended, actualUser.Status)
+		assert.Equal(t, int64(2), suspended.EventSequence)
+		assert.Equal(t, int64(3), actualUser.EventSequence)
+	})
+
+	t.Run("user not found", func(t *testing.T) {
+		// Arrange
+		repo := NewPostgres(db)
+
+		// Act
+		err := repo.SetStatus(context.TODO(), &User{ID: uuid.New().String(), Status: StatusActive})
+
+		// Assert
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, ErrUserNotFound))
+	})
+}
+
+func TestSetAvatar(t *testing.T) {
+	db := setupDBHelper(t)
+	defer teardownDBHelper(t, db)
+
+	t.Run("happy case", func(t *testing.T) {
+		// Arrange
+		repo := NewPostgres(db)
+
+		user := &User{
+			ID:        uuid.New().String(),
+			FirstName: "John",
+			LastName:  "Doe",
+			Nickname:  "johndoe",
+			Password:  "password",
+			Email:     "joedoe@foo.bar",
+			Country:   "BR",
+			CreatedAt: time.Time{}.Add(1 * time.Second),
+			UpdatedAt: time.Time{}.Add(2 * time.Second),
+		}
+		require.NoError(t, repo.Insert(context.TODO(), user))
+
+		withAvatar := *user
+		withAvatar.AvatarKey = "avatars/" + user.ID + "/abc"
+		withAvatar.AvatarHash = "abc"
+		withAvatar.UpdatedAt = time.Time{}.Add(3 * time.Second)
+
+		// Act
+		err := repo.SetAvatar(context.TODO(), &withAvatar)
+		require.NoError(t, err)
+
+		// Updates keep the avatar.
+		require.NoError(t, repo.Update(context.TODO(), user))
+
+		// Assert
+		actualUser, err := repo.Get(context.TODO(), user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "avatars/"+user.ID+"/abc", actualUser.AvatarKey)
+		assert.Equal(t, "abc", actualUser.AvatarHash)
+		assert.Equal(t, int64(2), withAvatar.EventSequence)
+		assert.Equal(t, int64(3), actualUser.EventSequence)
+	})
+
+	t.Run("user not found", func(t *testing.T) {
+		// Arrange
+		repo := NewPostgres(db)
+
+		// Act
+		err := repo.SetAvatar(context.TODO(), &User{ID: uuid.New().String()})
+
+		// Assert
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, ErrUserNotFound))
+	})
+}
+
+func TestMetadata(t *testing.T) {
+	db := setupDBHelper(t)
+	defer teardownDBHelper(t, db)
+
+	// Arrange
+	repo := NewPostgres(db)
+
+	user := &User{
+		ID:        uuid.New().String(),
+		FirstName: "John",
+		LastName:  "Doe",
+		Nickname:  "johndoe",
+		Password:  "password",
+		Email:     "joedoe@foo.bar",
+		Country:   "BR",
+		CreatedAt: time.Time{}.Add(1 * time.Second),
+		UpdatedAt: time.Time{}.Add(2 * time.Second),
+		Metadata:  Metadata{"theme": "dark"},
+	}
+	require.NoError(t, repo.Insert(context.TODO(), user))
+
+	inserted, err := repo.Get(context.TODO(), user.ID)
+	require.NoError(t, err)
+
+	user.Metadata = nil
+
+	// Act
+	err = repo.Update(context.TODO(), user)
+	require.NoError(t, err)
+
+	// Assert
+	assert.Equal(t, Metadata{"theme": "dark"}, inserted.Metadata)
+
+	actualUser, err := repo.Get(context.TODO(), user.ID)
+	require.NoError(t, err)
+	assert.Nil(t, actualUser.Metadata)
+}
+
+func TestPhoneVerification(t *testing.T) {
+	db := setupDBHelper(t)
+	defer teardownDBHelper(t, db)
+
+	// Arrange
+	repo := NewPostgres(db)
+
+	user := &User{
+		ID:        uuid.New().String(),
+		FirstName: "John",
+		LastName:  "Doe",
+		Nickname:  "johndoe",
+		Password:  "password",
+		Email:     "joedoe@foo.bar",
+		Country:   "BR",
+		CreatedAt: time.Time{}.Add(1 * time.Second),
+		UpdatedAt: time.Time{}.Add(2 * time.Second),
+	}
+	require.NoError(t, repo.Insert(context.TODO(), user))
+
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	verification := func(codeHash string) *PhoneVerification {
+		return &PhoneVerification{
+			UserID:    user.ID,
+			Phone:     "+5511999999999",
+			CodeHash:  []byte(codeHash),
+			CreatedAt: now,
+			ExpiresAt: now.Add(10 * time.Minute),
+		}
+	}
+
+	// Act
+	unknownErr := repo.InsertPhoneVerification(context.TODO(), &PhoneVerification{UserID: uuid.New().String(), CodeHash: []byte("unknown"), CreatedAt: now, ExpiresAt: now})
+	_, notPendingErr := repo.AttemptPhoneVerification(context.TODO(), user.ID, now)
+
+	require.NoError(t, repo.InsertPhoneVerification(context.TODO(), verification("first")))
+	_, err := repo.AttemptPhoneVerification(context.TODO(), user.ID, now)
+	require.NoError(t, err)
+
+	require.NoError(t, repo.InsertPhoneVerification(context.TODO(), verification("second")))
+	_, err = repo.AttemptPhoneVerification(context.TODO(), user.ID, now)
+	require.NoError(t, err)
+
+	attempted, err := repo.AttemptPhoneVerification(context.TODO(), user.ID, now)
+	require.NoError(t, err)
+
+	_, expiredErr := repo.AttemptPhoneVerification(context.TODO(), user.ID, now.Add(10*time.Minute))
+
+	require.NoError(t, repo.DeletePhoneVerification(context.TODO(), user.ID))
+	_, deletedErr := repo.AttemptPhoneVerification(context.TODO(), user.ID, now)
+
+	user.Phone = "+5511999999999"
+	user.PhoneVerified = true
+	require.NoError(t, repo.Update(context.TODO(), user))
+
+	stored, err := repo.Get(context.TODO(), user.ID)
+	require.NoError(t, err)
+
+	// Assert
+	assert.True(t, errors.Is(unknownErr, ErrUserNotFound))
+	assert.True(t, errors.Is(notPendingErr, ErrPhoneVerificationNotFound))
+
+	// A pending verification is replaced, attempts included.
+	assert.Equal(t, []byte("second"), attempted.CodeHash)
+	assert.Equal(t, "+5511999999999", attempted.Phone)
+	assert.Equal(t, 2, attempted.Attempts)
+
+	assert.True(t, errors.Is(expiredErr, ErrPhoneVerificationNotFound))
+	assert.True(t, errors.Is(deletedErr, ErrPhoneVerificationNotFound))
+
+	assert.Equal(t, "+5511999999999", stored.Phone)
+	assert.True(t, stored.PhoneVerified)
+}
+
+// Possible these helper functions could be imported from the tests package
+// (with some refactoring) but, "A little copying is better than a little dependency".
+// https://go-proverbs.github.io/
+
+const (
+	migrationsDir      string = "../../../migrations"
+	postgresDriverName string = "postgres"
+	dbHost             string = "localhost"
+	dbPort             string = "5432"
+	dbUser             string = "user"
+	dbPass             string = "password"
+	dbName             string = "usrsvc"
+)
+
+func TestBootstrap(t *testing.T) {
+	db := setupDBHelper(t)
+	defer teardownDBHelper(t, db)
+
+	newAdmin := func(email string) (*User, *APIKey) {
+		admin := &User{
+			ID:        uuid.New().String(),
+			FirstName: "Ada",
+			LastName:  "Admin",
+			Nickname:  strings.Split(email, "@")[0],
+			Password:  "password",
+			Email:     email,
+			Country:   "BR",
+			CreatedAt: time.Time{}.Add(1 * time.Second),
+			UpdatedAt: time.Time{}.Add(2 * time.Second),
+		}
+
+		return admin, &APIKey{
+			ID:         uuid.New().String(),
+			UserID:     admin.ID,
+			Name:       "terraform",
+			SecretHash: []byte("hash"),
+			CreatedAt:  time.Time{}.Add(1 * time.Second),
+		}
+	}
+
+	// Arrange
+	repo := NewPostgres(db)
+	admin, key := newAdmin("admin@foo.bar")
+
+	// Act
+	err := repo.Bootstrap(context.TODO(), admin, key)
+	require.NoError(t, err)
+
+	second, secondKey := newAdmin("admin2@foo.bar")
+	secondErr := repo.Bootstrap(context.TODO(), second, secondKey)
+
+	// Assert
+	assert.True(t, errors.Is(secondErr, ErrAlreadyBootstrapped))
+
+	stored, err := repo.Get(context.TODO(), admin.ID)
+	require.NoError(t, err)
+	assert.Equal(t, RoleAdmin, stored.Role)
+
+	storedKey, err := repo.GetAPIKey(context.TODO(), key.ID)
+	require.NoError(t, err)
+	assert.Equal(t, admin.ID, storedKey.UserID)
+	assert.Equal(t, key.SecretHash, storedKey.SecretHash)
+
+	_, err = repo.GetAPIKey(context.TODO(), secondKey.ID)
+	assert.True(t, errors.Is(err, ErrAPIKeyNotFound))
+
+	_, err = repo.Get(context.TODO(), second.ID)
+	assert.True(t, errors.Is(err, ErrUserNotFound))
+}
+
+func TestLinkIdentity(t *testing.T) {
+	db := setupDBHelper(t)
+	defer teardownDBHelper(t, db)
+
+	// Arrange
+	repo := NewPostgres(db)
+
+	user := &User{
+		ID:        uuid.New().String(),
+		FirstName: "John",
+		LastName:  "Doe",
+		Nickname:  "johndoe",
+		Password:  "password",
+		Email:     "joedoe@foo.bar",
+		Country:   "BR",
+		CreatedAt: time.Time{}.Add(1 * time.Second),
+		UpdatedAt: time.Time{}.Add(2 * time.Second),
+	}
+	require.NoError(t, repo.Insert(context.TODO(), user))
+
+	identity := &LinkedIdentity{
+		Provider:  "google",
+		Subject:   "1234567890",
+		UserID:    user.ID,
+		Email:     "joedoe@gmail.com",
+		CreatedAt: time.Now().UTC().Truncate(time.Millisecond),
+	}
+
+	// Act
+	err := repo.LinkIdentity(context.TODO(), identity)
+	require.NoError(t, err)
+
+	linkedErr := repo.LinkIdentity(context.TODO(), identity)
+
+	unknownUser := *identity
+	unknownUser.Provider = "azure"
+	unknownUser.UserID = uuid.New().String()
+	unknownErr := repo.LinkIdentity(context.TODO(), &unknownUser)
+
+	// Assert
+	assert.True(t, errors.Is(linkedErr, ErrIdentityLinked))
+	assert.True(t, errors.Is(unknownErr, ErrUserNotFound))
+
+	linkedUser, err := repo.GetByLinkedIdentity(context.TODO(), "google", "1234567890")
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, linkedUser.ID)
+
+	identities, err := repo.GetLinkedIdentities(context.TODO(), user.ID)
+	require.NoError(t, err)
+	require.Len(t, identities, 1)
+	assert.Equal(t, identity.Email, identities[0].Email)
+	assert.True(t, identity.CreatedAt.Equal(identities[0].CreatedAt))
+}
+
+func TestUnlinkIdentity(t *testing.T) {
+	db := setupDBHelper(t)
+	defer teardownDBHelper(t, db)
+
+	// Arrange
+	repo := NewPostgres(db)
+
+	user := &User{
+		ID:        uuid.New().String(),
+		FirstName: "John",
+		LastName:  "Doe",
+		Nickname:  "johndoe",
+		Password:  "password",
+		Email:     "joedoe@foo.bar",
+		Country:   "BR",
+		CreatedAt: time.Time{}.Add(1 * time.Second),
+		UpdatedAt: time.Time{}.Add(2 * time.Second),
+	}
+	require.NoError(t, repo.Insert(context.TODO(), user))
+
+	identity := &LinkedIdentity{
+		Provider:  "google",
+		Subject:   "1234567890",
+		UserID:    user.ID,
+		Email:     "joedoe@gmail.com",
+		CreatedAt: time.Now().UTC().Truncate(time.Millisecond),
+	}
+	require.NoError(t, repo.LinkIdentity(context.TODO(), identity))
+
+	// Act
+	otherUserErr := repo.UnlinkIdentity(context.TODO(), uuid.New().String(), "google", "1234567890")
+	require.NoError(t, repo.UnlinkIdentity(context.TODO(), user.ID, "google", "1234567890"))
+	unlinkAgainErr := repo.UnlinkIdentity(context.TODO(), user.ID, "google", "1234567890")
+
+	_, getErr := repo.GetByLinkedIdentity(context.TODO(), "google", "1234567890")
+
+	// Assert
+	assert.True(t, errors.Is(otherUserErr, ErrIdentityNotFound))
+	assert.True(t, errors.Is(unlinkAgainErr, ErrIdentityNotFound))
+	assert.True(t, errors.Is(getErr, ErrUserNotFound))
+
+	// The identity can be linked again once unlinked.
+	assert.NoError(t, repo.LinkIdentity(context.TODO(), identity))
+}
+
+func TestFieldLocks(t *testing.T) {
+	db := setupDBHelper(t)
+	defer teardownDBHelper(t, db)
+
+	// Arrange
+	repo := NewPostgres(db)
+
+	user := &User{
+		ID:        uuid.New().String(),
+		FirstName: "John",
+		LastName:  "Doe",
+		Nickname:  "johndoe",
+		Password:  "password",
+		Email:     "joedoe@foo.bar",
+		Country:   "BR",
+		CreatedAt: time.Time{}.Add(1 * time.Second),
+		UpdatedAt: time.Time{}.Add(2 * time.Second),
+	}
+	require.NoError(t, repo.Insert(context.TODO(), user))
+
+	lock := &FieldLock{
+		UserID:    user.ID,
+		Field:     "email",
+		Reason:    "investigation",
+		LockedBy:  "admin-id",
+		CreatedAt: time.Now().UTC().Truncate(time.Millisecond),
+	}
+
+	// Act
+	require.NoError(t, repo.LockField(context.TODO(), lock))
+
+	relocked := *lock
+	relocked.Reason = "new investigation"
+	require.NoError(t, repo.LockField(context.TODO(), &relocked))
+
+	nickname := *lock
+	nickname.Field = "nickname"
+	require.NoError(t, repo.LockField(context.TODO(), &nickname))
+	require.NoError(t, repo.UnlockField(context.TODO(), user.ID, "nickname"))
+
+	unknownUser := *lock
+	unknownUser.UserID = uuid.New().String()
+	unknownErr := repo.LockField(context.TODO(), &unknownUser)
+
+	// Assert
+	assert.True(t, errors.Is(unknownErr, ErrUserNotFound))
+
+	locks, err := repo.GetFieldLocks(context.TODO(), user.ID)
+	require.NoError(t, err)
+	require.Len(t, locks, 1)
+	assert.Equal(t, "email", locks[0].Field)
+	assert.Equal(t, "new investigation", locks[0].Reason)
+	assert.True(t, lock.CreatedAt.Equal(locks[0].CreatedAt))
+}
+
+func TestLogins(t *testing.T) {
+	db := setupDBHelper(t)
+	defer teardownDBHelper(t, db)
+
+	// Arrange
+	repo := NewPostgres(db)
+
+	user := &User{
+		ID:        uuid.New().String(),
+		FirstName: "John",
+		LastName:  "Doe",
+		Nickname:  "johndoe",
+		Password:  "password",
+		Email:     "joedoe@foo.bar",
+		Country:   "BR",
+		CreatedAt: time.Time{}.Add(1 * time.Second),
+		UpdatedAt: time.Time{}.Add(2 * time.Second),
+	}
+	require.NoError(t, repo.Insert(context.TODO(), user))
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	for i, country := range []string{"BR", "PT", "DE"} {
+		require.NoError(t, repo.RecordLogin(context.TODO(), &Login{
+			UserID:    user.ID,
+			IP:        "203.0.113.7",
+			Country:   country,
+			ASN:       64500,
+			CreatedAt: now.Add(time.Duration(i) * time.Hour),
+		}))
+	}
+
+	// Act
+	unknownErr := repo.RecordLogin(context.TODO(), &Login{UserID: uuid.New().String(), CreatedAt: now})
+
+	logins, err := repo.GetRecentLogins(context.TODO(), user.ID, 2)
+	require.NoError(t, err)
+
+	// Assert
+	assert.True(t, errors.Is(unknownErr, ErrUserNotFound))
+
+	require.Len(t, logins, 2)
+	assert.Equal(t, "DE", logins[0].Country)
+	assert.Equal(t, "PT", logins[1].Country)
+	assert.Equal(t, "203.0.113.7", logins[0].IP)
+	assert.Equal(t, int64(64500), logins[0].ASN)
+	assert.True(t, now.Add(2*time.Hour).Equal(logins[0].CreatedAt))
+}
+
+func TestLockouts(t *testing.T) {
+	db := setupDBHelper(t)
+	defer teardownDBHelper(t, db)
+
+	// Arrange
+	repo := NewPostgres(db)
+
+	user := &User{
+		ID:        uuid.New().String(),
+		FirstName: "John",
+		LastName:  "Doe",
+		Nickname:  "johndoe",
+		Password:  "password",
+		Email:     "joedoe@foo.bar",
+		Country:   "BR",
+		CreatedAt: time.Time{}.Add(1 * time.Second),
+		UpdatedAt: time.Time{}.Add(2 * time.Second),
+	}
+	require.NoError(t, repo.Insert(context.TODO(), user))
+
+	until := time.Now().UTC().Truncate(time.Millisecond)
+
+	// Act
+	_, unknownErr := repo.RecordFailedLogin(context.TODO(), uuid.New().String())
+
+	_, err := repo.RecordFailedLogin(context.TODO(), user.ID)
+	require.NoError(t, err)
+
+	failed, err := repo.RecordFailedLogin(context.TODO(), user.ID)
+	require.NoError(t, err)
+
+	require.NoError(t, repo.LockUser(context.TODO(), user.ID, until))
+
+	locked, err := repo.GetLockout(context.TODO(), user.ID)
+	require.NoError(t, err)
+
+	require.NoError(t, repo.ResetLockout(context.TODO(), user.ID))
+
+	reset, err := repo.GetLockout(context.TODO(), user.ID)
+	require.NoError(t, err)
+
+	// Assert
+	assert.True(t, errors.Is(unknownErr, ErrUserNotFound))
+	assert.Equal(t, 2, failed.FailedAttempts)
+
+	assert.Equal(t, 0, locked.FailedAttempts)
+	assert.Equal(t, 1, locked.Lockouts)
+	assert.True(t, until.Equal(locked.LockedUntil))
+
+	assert.Equal(t, &Lockout{UserID: user.ID}, reset)
+}
+
+func TestResetPassword(t *testing.T) {
+	db := setupDBHelper(t)
+	defer teardownDBHelper(t, db)
+
+	// Arrange
+	repo := NewPostgres(db)
+
+	user := &User{
+		ID:        uuid.New().String(),
+		FirstName: "John",
+		LastName:  "Doe",
+		Nickname:  "johndoe",
+		Password:  "password",
+		Email:     "joedoe@foo.bar",
+		Country:   "BR",
+		CreatedAt: time.Time{}.Add(1 * time.Second),
+		UpdatedAt: time.Time{}.Add(2 * time.Second),
+	}
+	require.NoError(t, repo.Insert(context.TODO(), user))
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	for _, token := range []*PasswordResetToken{
+		{TokenHash: []byte("valid"), UserID: user.ID, CreatedAt: now, ExpiresAt: now.Add(time.Hour)},
+		{TokenHash: []byte("other"), UserID: user.ID, CreatedAt: now, ExpiresAt: now.Add(time.Hour)},
+		{TokenHash: []byte("expired"), UserID: user.ID, CreatedAt: now, ExpiresAt: now.Add(time.Minute)},
+	} {
+		require.NoError(t, repo.InsertPasswordResetToken(context.TODO(), token))
+	}
+
+	// Act
+	unknownErr := repo.InsertPasswordResetToken(context.TODO(), &PasswordResetToken{
+		TokenHash: []byte("unknown"),
+		UserID:    uuid.New().String(),
+		CreatedAt: now,
+		ExpiresAt: now.Add(time.Hour),
+	})
+	_, expiredErr := repo.ResetPassword(context.TODO(), []byte("expired"), "new-password", now.Add(2*time.Minute))
+
+	reset, err := repo.ResetPassword(context.TODO(), []byte("valid"), "new-password", now.Add(2*time.Minute))
+	require.NoError(t, err)
+
+	_, reusedErr := repo.ResetPassword(context.TODO(), []byte("valid"), "new-password", now.Add(2*time.Minute))
+	_, otherErr := repo.ResetPassword(context.TODO(), []byte("other"), "new-password", now.Add(2*time.Minute))
+
+	// Assert
+	assert.True(t, errors.Is(unknownErr, ErrUserNotFound))
+	assert.True(t, errors.Is(expiredErr, ErrResetTokenNotFound))
+
+	assert.Equal(t, user.ID, reset.ID)
+	assert.Equal(t, "new-password", reset.Password)
+	assert.Equal(t, int64(2), reset.EventSequence)
+
+	stored, err := repo.Get(context.TODO(), user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "new-password", stored.Password)
+
+	assert.True(t, errors.Is(reusedErr, ErrResetTokenNotFound))
+	assert.True(t, errors.Is(otherErr, ErrResetTokenNotFound))
+}
+
+func TestAPIKeys(t *testing.T) {
+	db := setupDBHelper(t)
+	defer teardownDBHelper(t, db)
+
+	// Arrange
+	repo := NewPostgres(db)
+
+	user := &User{
+		ID:        uuid.New().String(),
+		FirstName: "John",
+		LastName:  "Doe",
+		Nickname:  "johndoe",
+		Password:  "password",
+		Email:     "joedoe@foo.bar",
+		Country:   "BR",
+		CreatedAt: time.Time{}.Add(1 * time.Second),
+		UpdatedAt: time.Time{}.Add(2 * time.Second),
+	}
+	require.NoError(t, repo.Insert(context.TODO(), user))
+
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	older := &APIKey{ID: uuid.New().String(), UserID: user.ID, Name: "older", SecretHash: []byte("older"), Scopes: "users:read", CreatedAt: now}
+	newer := &APIKey{ID: uuid.New().String(), UserID: user.ID, Name: "newer", SecretHash: []byte("newer"), Scopes: "users:read users:write", CreatedAt: now.Add(time.Hour)}
+
+	// Act
+	unknownErr := repo.InsertAPIKey(context.TODO(), &APIKey{ID: uuid.New().String(), UserID: uuid.New().String(), Name: "unknown", SecretHash: []byte("unknown"), CreatedAt: now})
+
+	for _, key := range []*APIKey{newer, older} {
+		require.NoError(t, repo.InsertAPIKey(context.TODO(), key))
+	}
+
+	keys, err := repo.GetAPIKeys(context.TODO(), user.ID)
+	require.NoError(t, err)
+
+	stored, err := repo.GetAPIKey(context.TODO(), newer.ID)
+	require.NoError(t, err)
+
+	require.NoError(t, repo.DeleteAPIKey(context.TODO(), newer.ID))
+	_, deletedErr := repo.GetAPIKey(context.TODO(), newer.ID)
+	deleteAgainErr := repo.DeleteAPIKey(context.TODO(), newer.ID)
+
+	// Assert
+	assert.True(t, errors.Is(unknownErr, ErrUserNotFound))
+
+	// The oldest keys come first.
+	require.Len(t, keys, 2)
+	assert.Equal(t, older.ID, keys[0].ID)
+	assert.Equal(t, newer.ID, keys[1].ID)
+
+	assert.Equal(t, "users:read users:write", stored.Scopes)
+	assert.Equal(t, []byte("newer"), stored.SecretHash)
+
+	assert.True(t, errors.Is(deletedErr, ErrAPIKeyNotFound))
+	assert.True(t, errors.Is(deleteAgainErr, ErrAPIKeyNotFound))
+}
+
+func TestSessions(t *testing.T) {
+	db := setupDBHelper(t)
+	defer teardownDBHelper(t, db)
+
+	// Arrange
+	repo := NewPostgres(db)
+
+	user := &User{
+		ID:        uuid.New().String(),
+		FirstName: "John",
+		LastName:  "Doe",
+		Nickname:  "johndoe",
+		Password:  "password",
+		Email:     "joedoe@foo.bar",
+		Country:   "BR",
+		CreatedAt: time.Time{}.Add(1 * time.Second),
+		UpdatedAt: time.Time{}.Add(2 * time.Second),
+	}
+	require.NoError(t, repo.Insert(context.TODO(), user))
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	expired := &Session{ID: uuid.New().String(), UserID: user.ID, AccessTokenHash: []byte("access"), RefreshTokenHash: []byte("expired"), CreatedAt: now, ExpiresAt: now.Add(time.Hour)}
+	older := &Session{ID: uuid.New().String(), UserID: user.ID, AccessTokenHash: []byte("access"), RefreshTokenHash: []byte("older"), CreatedAt: now.Add(time.Hour), ExpiresAt: now.Add(48 * time.Hour)}
+	newer := &Session{ID: uuid.New().String(), UserID: user.ID, AccessTokenHash: []byte("access"), RefreshTokenHash: []byte("newer"), CreatedAt: now.Add(2 * time.Hour), ExpiresAt: now.Add(48 * time.Hour)}
+
+	// Act
+	unknownErr := repo.InsertSession(context.TODO(), &Session{
+		ID:               uuid.New().String(),
+		UserID:           uuid.New().String(),
+		AccessTokenHash:  []byte("unknown"),
+		RefreshTokenHash: []byte("unknown"),
+		CreatedAt:        now,
+		ExpiresAt:        now.Add(time.Hour),
+	})
+
+	for _, session := range []*Session{expired, older, newer} {
+		require.NoError(t, repo.InsertSession(context.TODO(), session))
+	}
+
+	sessions, err := repo.GetSessions(context.TODO(), user.ID)
+	require.NoError(t, err)
+
+	rotated := *older
+	rotated.AccessTokenHash = []byte("rotated-access")
+	rotated.RefreshTokenHash = []byte("rotated")
+	rotated.ExpiresAt = now.Add(72 * time.Hour)
+	require.NoError(t, repo.RotateSession(context.TODO(), &rotated, []byte("older")))
+
+	reusedErr := repo.RotateSession(context.TODO(), &rotated, []byte("older"))
+
+	stored, err := repo.GetSession(context.TODO(), older.ID)
+	require.NoError(t, err)
+
+	require.NoError(t, repo.DeleteSession(context.TODO(), newer.ID))
+	deleteAgainErr := repo.DeleteSession(context.TODO(), newer.ID)
+
+	// Assert
+	assert.True(t, errors.Is(unknownErr, ErrUserNotFound))
+
+	require.Len(t, sessions, 2)
+	assert.Equal(t, newer.ID, sessions[0].ID)
+	assert.Equal(t, older.ID, sessions[1].ID)
+
+	assert.True(t, errors.Is(reusedErr, ErrSessionNotFound))
+	assert.Equal(t, []byte("rotated"), stored.RefreshTokenHash)
+	assert.Equal(t, []byte("rotated-access"), stored.AccessTokenHash)
+	assert.True(t, rotated.ExpiresAt.Equal(stored.ExpiresAt))
+
+	assert.True(t, errors.Is(deleteAgainErr, ErrSessionNotFound))
+}
+
+func TestTOTP(t *testing.T) {
+	db := setupDBHelper(t)
+	defer teardownDBHelper(t, db)
+
+	// Arrange
+	repo := NewPostgres(db)
+
+	user := &User{
+		ID:        uuid.New().String(),
+		FirstName: "John",
+		LastName:  "Doe",
+		Nickname:  "johndoe",
+		Password:  "password",
+		Email:     "joedoe@foo.bar",
+		Country:   "BR",
+		CreatedAt: time.Time{}.Add(1 * time.Second),
+		UpdatedAt: time.Time{}.Add(2 * time.Second),
+	}
+	require.NoError(t, repo.Insert(context.TODO(), user))
+
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Act
+	unknownErr := repo.InsertTOTP(context.TODO(), &TOTP{UserID: uuid.New().String(), Secret: []byte("unknown"), CreatedAt: now})
+	_, notEnrolledErr := repo.GetTOTP(context.TODO(), user.ID)
+
+	require.NoError(t, repo.InsertTOTP(context.TODO(), &TOTP{UserID: user.ID, Secret: []byte("first"), CreatedAt: now}))
+	require.NoError(t, repo.InsertTOTP(context.TODO(), &TOTP{UserID: user.ID, Secret: []byte("second"), CreatedAt: now}))
+
+	pending, err := repo.GetTOTP(context.TODO(), user.ID)
+	require.NoError(t, err)
+
+	require.NoError(t, repo.UseTOTP(context.TODO(), user.ID, 100))
+	replayedErr := repo.UseTOTP(context.TODO(), user.ID, 100)
+	olderErr := repo.UseTOTP(context.TODO(), user.ID, 99)
+	require.NoError(t, repo.UseTOTP(context.TODO(), user.ID, 101))
+
+	enabledErr := repo.InsertTOTP(context.TODO(), &TOTP{UserID: user.ID, Secret: []byte("third"), CreatedAt: now})
+
+	enabled, err := repo.GetTOTP(context.TODO(), user.ID)
+	require.NoError(t, err)
+
+	_, err = repo.Delete(context.TODO(), user.ID)
+	require.NoError(t, err)
+	_, userDeletedErr := repo.GetTOTP(context.TODO(), user.ID)
+
+	// Assert
+	assert.True(t, errors.Is(unknownErr, ErrUserNotFound))
+	assert.True(t, errors.Is(notEnrolledErr, ErrTOTPNotFound))
+
+	// A pending enrollment is replaced.
+	assert.Equal(t, []byte("second"), pending.Secret)
+	assert.False(t, pending.Enabled)
+
+	// Codes can't be replayed, and using one enables TOTP for good.
+	assert.True(t, errors.Is(replayedErr, ErrTOTPCodeUsed))
+	assert.True(t, errors.Is(olderErr, ErrTOTPCodeUsed))
+	assert.True(t, errors.Is(enabledErr, ErrTOTPEnabled))
+	assert.Equal(t, []byte("second"), enabled.Secret)
+	assert.True(t, enabled.Enabled)
+	assert.Equal(t, int64(101), enabled.LastUsedStep)
+
+	// Deleting the user deletes its TOTP.
+	assert.True(t, errors.Is(userDeletedErr, ErrTOTPNotFound))
+}
+
+func TestCountByCountry(t *testing.T) {
+	db := setupDBHelper(t)
+	defer teardownDBHelper(t, db)
+
+	t.Run("happy case", func(t *testing.T) {
+		// Arrange
+		repo := NewPostgres(db)
+
+		for i, country := range []string{"BR", "BR", "US"} {
+			require.NoError(t, repo.Insert(context.TODO(), &User{
+				ID:        uuid.New().String(),
+				FirstName: "John",
+				LastName:  "Doe",
+				Nickname:  fmt.Sprintf("johndoe%d", i),
+				Password:  "password",
+				Email:     fmt.Sprintf("joedoe%d@foo.bar", i),
+				Country:   country,
+				CreatedAt: time.Time{}.Add(1 * time.Second),
+				UpdatedAt: time.Time{}.Add(2 * time.Second),
+			}))
+		}
+
+		// Act
+		counts, err := repo.CountByCountry(context.TODO())
+		require.NoError(t, err)
+
+		// Assert
+		assert.Equal(t, map[string]int64{"BR": 2, "US": 1}, counts)
+	})
+}
+
+func TestUniquenessScope(t *testing.T) {
+	db := setupDBHelper(t)
+	defer teardownDBHelper(t, db)
+
+	newUser := func(nickname, email, country string) *User {
+		return &User{
+			ID:        uuid.New().String(),
+			FirstName: "John",
+			LastName:  "Doe",
+			Nickname:  nickname,
+			Password:  "password",
+			Email:     email,
+			Country:   country,
+			CreatedAt: time.Time{}.Add(1 * time.Second),
+			UpdatedAt: time.Time{}.Add(2 * time.Second),
+		}
+	}
+
+	t.Run("country scope", func(t *testing.T) {
+		// Arrange
+		repo := NewPostgres(db, WithUniquenessScope(ScopeCountry))
+		require.NoError(t, repo.Insert(context.TODO(), newUser("johndoe", "joedoe@foo.bar", "US")))
+
+		// Act
+		otherCountryErr := repo.Insert(context.TODO(), newUser("johndoe", "joedoe@foo.bar", "BR"))
+		sameCountryErr := repo.Insert(context.TODO(), newUser("johndoe_us", "joedoe@foo.bar", "US"))
+
+		_, ambiguousErr := repo.GetByEmail(context.TODO(), "joedoe@foo.bar")
+
+		// The users sharing the email conflict once the email is globally unique again.
+		_, globalErr := NewPostgres(db).ApplyUniquenessScope(context.TODO())
+
+		// Assert
+		require.NoError(t, otherCountryErr)
+		assert.True(t, errors.Is(sameCountryErr, ErrDuplicateEmail))
+		assert.True(t, errors.Is(ambiguousErr, ErrAmbiguousEmail))
+		assert.True(t, errors.Is(globalErr, ErrDuplicateNickname) || errors.Is(globalErr, ErrDuplicateEmail))
+	})
+}
+
+func TestNicknameExists(t *testing.T) {
+	db := setupDBHelper(t)
+	defer teardownDBHelper(t, db)
+
+	t.Run("happy case", func(t *testing.T) {
+		// Arrange
+		repo := NewPostgres(db)
+		require.NoError(t, repo.Insert(context.TODO(), &User{
+			ID:        uuid.New().String(),
+			FirstName: "John",
+			LastName:  "Doe",
+			Nickname:  "johndoe",
+			Password:  "password",
+			Email:     "joedoe@foo.bar",
+			Country:   "US",
+			CreatedAt: time.Time{}.Add(1 * time.Second),
+			UpdatedAt: time.Time{}.Add(2 * time.Second),
+		}))
+
+		// Act
+		taken, err := repo.NicknameExists(context.TODO(), "johndoe", "BR")
+		require.NoError(t, err)
+
+		free, err := repo.NicknameExists(context.TODO(), "janedoe", "US")
+		require.NoError(t, err)
+
+		// Assert
+		assert.True(t, taken)
+		assert.False(t, free)
+	})
+}
+
+func TestPrepare(t *testing.T) {
+	db := setupDBHelper(t)
+	defer teardownDBHelper(t, db)
+
+	t.Run("happy case", func(t *testing.T) {
+		// Arrange
+		repo := NewPostgres(db)
+
+		require.NoError(t, repo.Prepare(context.TODO()))
+		defer func() { require.NoError(t, repo.Close()) }()
+
+		givenUser := &User{
+			ID:        uuid.New().String(),
+			FirstName: "John",
+			LastName:  "Doe",
+			Nickname:  "johndoe",
+			Password:  "password",
+			Email:     "joedoe@foo.bar",
+			Country:   "BR",
+			CreatedAt: time.Time{}.Add(1 * time.Second),
+			UpdatedAt: time.Time{}.Add(2 * time.Second),
+		}
+
+		// Act
+		require.NoError(t, repo.Insert(context.TODO(), givenUser))
+
+		givenUser.FirstName = "Joe"
+		require.NoError(t, repo.Update(context.TODO(), givenUser))
+
+		actualUser, actualErr := repo.Get(context.TODO(), givenUser.ID)
+
+		// Assert
+		require.NoError(t, actualErr)
+		assert.Equal(t, "Joe", actualUser.FirstName)
+		assert.Len(t, repo.db.stmts, len(preparedQueries)+len(preparedNamedQueries))
+	})
+}
 
 func setupDBHelper(t *testing.T) *sqlx.DB {
 	t.Helper()
@@ -287,7 +1809,7 @@ func setupDBHelper(t *testing.T) *sqlx.DB {
 func teardownDBHelper(t *testing.T, db *sqlx.DB) {
 	t.Helper()
 
-	_, err := db.Exec("TRUNCATE TABLE users CASCADE")
+	_, err := db.Exec("TRUNCATE TABLE users, user_tombstones CASCADE")
 	require.NoError(t, err)
 
 	require.NoError(t, db.Close())
@@ -0,0 +1,1336 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alesr/usrsvc/internal/tenant"
+	"github.com/jmoiron/sqlx"
+	"github.com/mattn/go-sqlite3"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// SQLiteDriverName is the database/sql driver name of SQLite.
+	SQLiteDriverName string = "sqlite3"
+
+	// sqliteDSNParams enable the foreign keys, make LIKE case sensitive like in Postgres and
+	// take the write lock when transactions begin, so concurrent bootstraps are serialized.
+	sqliteDSNParams string = "_foreign_keys=1&_cslike=1&_txlock=immediate&_busy_timeout=5000"
+
+	nicknameUniqueColumn string = "users.nickname"
+)
+
+// SQLite is a repository implementation for SQLite, for embedded and CI usage where
+// running Postgres is not an option. It uses the schema of migrations/sqlite.
+type SQLite struct {
+	db    *sqlx.DB
+	scope UniquenessScope
+}
+
+// SQLiteOption is a function that configures the SQLite repository.
+type SQLiteOption func(*SQLite)
+
+// WithSQLiteUniquenessScope configures the scope of the email and nickname uniqueness.
+// It defaults to ScopeGlobal.
+func WithSQLiteUniquenessScope(scope UniquenessScope) SQLiteOption {
+	return func(s *SQLite) {
+		s.scope = scope
+	}
+}
+
+// OpenSQLite opens the SQLite database at path, created if missing, or an in-memory
+// database for ":memory:". The database is used through a single connection, since
+// SQLite serializes the writes anyway and every connection to ":memory:" opens a new
+// database.
+func OpenSQLite(path string) (*sqlx.DB, error) {
+	db, err := sqlx.Open(SQLiteDriverName, "file:"+path+"?"+sqliteDSNParams)
+	if err != nil {
+		return nil, fmt.Errorf("could not open sqlite database: %w", err)
+	}
+
+	db.SetMaxOpenConns(1)
+	db.SetConnMaxLifetime(0)
+	db.SetConnMaxIdleTime(0)
+	return db, nil
+}
+
+// NewSQLite creates a new SQLite repository on a database opened with OpenSQLite.
+func NewSQLite(db *sqlx.DB, opts ...SQLiteOption) *SQLite {
+	s := &SQLite{db: db, scope: ScopeGlobal}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Get returns a user by id.
+func (s *SQLite) Get(ctx context.Context, id string) (*User, error) {
+	ctx, end := s.startQuery(ctx, "get")
+	defer end()
+
+	var user User
+	if err := s.db.GetContext(
+		ctx,
+		&user,
+		`SELECT id, first_name, last_name, nickname, password, email,
+		country, created_at, updated_at, event_sequence, role, anonymized, status, metadata, avatar_key, avatar_hash, phone, phone_verified, locale, timezone, birthdate, tenant_id FROM users WHERE id = ? AND (tenant_id = ? OR ? = '')`,
+		id,
+		tenantOf(ctx),
+		tenantOf(ctx),
+	); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("could not get user: %w", ErrUserNotFound)
+		}
+		return nil, fmt.Errorf("could not get user: %w", err)
+	}
+	return &user, nil
+}
+
+// GetByEmail returns a user by email, like the Postgres repository.
+func (s *SQLite) GetByEmail(ctx context.Context, email string) (*User, error) {
+	ctx, end := s.startQuery(ctx, "get_by_email")
+	defer end()
+
+	var users []*User
+	if err := s.db.SelectContext(
+		ctx,
+		&users,
+		sqliteQuery(getUserByEmailQuery),
+		email,
+		tenantOf(ctx),
+	); err != nil {
+		return nil, fmt.Errorf("could not get user by email: %w", err)
+	}
+
+	user, err := oneByEmail(users)
+	if err != nil {
+		return nil, fmt.Errorf("could not get user by email: %w", err)
+	}
+	return user, nil
+}
+
+// List returns a page of the users selected by the filter.
+func (s *SQLite) List(ctx context.Context, filter Filter, page Page) ([]*User, error) {
+	ctx, end := s.startQuery(ctx, "list")
+	defer end()
+
+	query, args := listQuery(tenantOf(ctx), filter, page)
+
+	var users []*User
+	if err := s.db.SelectContext(ctx, &users, sqliteQuery(query), utc(args)...); err != nil {
+		return nil, fmt.Errorf("could not get users: %w", err)
+	}
+	return users, nil
+}
+
+// Count returns the number of users selected by the filter.
+func (s *SQLite) Count(ctx context.Context, filter Filter) (int64, error) {
+	ctx, end := s.startQuery(ctx, "count")
+	defer end()
+
+	query, args := countQuery(tenantOf(ctx), filter)
+
+	var count int64
+	if err := s.db.GetContext(ctx, &count, sqliteQuery(query), utc(args)...); err != nil {
+		return 0, fmt.Errorf("could not count users: %w", err)
+	}
+	return count, nil
+}
+
+// NicknameExists reports whether a user has the nickname within the uniqueness scope of
+// the country, like Postgres.NicknameExists.
+func (s *SQLite) NicknameExists(ctx context.Context, nickname, country string) (bool, error) {
+	ctx, end := s.startQuery(ctx, "nickname_exists")
+	defer end()
+
+	var exists bool
+	if err := s.db.GetContext(ctx, &exists, sqliteQuery(nicknameExistsQuery), s.scope.key(&User{Country: country, TenantID: tenant.OrDefault(ctx)}), nickname); err != nil {
+		return false, fmt.Errorf("could not check nickname: %w", err)
+	}
+	return exists, nil
+}
+
+// Search returns the users with names or nickname containing words starting with every
+// word of the query, ordered by id like the in-memory repository. The page starts at offset.
+// The candidates are selected with LIKE and matched like the Postgres search vector here.
+func (s *SQLite) Search(ctx context.Context, query string, offset, limit int) ([]*User, error) {
+	ctx, end := s.startQuery(ctx, "search")
+	defer end()
+
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	q := newQueryBuilder(`SELECT id, first_name, last_name, nickname, password, email, country,
+		created_at, updated_at, event_sequence, role, anonymized, status, metadata, avatar_key, avatar_hash, phone, phone_verified, locale, timezone, birthdate, tenant_id FROM users`)
+	q.where("(tenant_id = ? OR ? = '')", tenantOf(ctx), tenantOf(ctx))
+	for _, term := range terms {
+		q.where("lower(first_name || ' ' || last_name || ' ' || nickname) LIKE ?", "%"+term+"%")
+	}
+	q.orderBy("ORDER BY id ASC")
+
+	candidatesQuery, args := q.build()
+
+	var candidates []*User
+	if err := s.db.SelectContext(ctx, &candidates, sqliteQuery(candidatesQuery), args...); err != nil {
+		return nil, fmt.Errorf("could not search users: %w", err)
+	}
+
+	var users []*User
+	for _, u := range candidates {
+		if !matchSearch(u, terms) {
+			continue
+		}
+
+		if offset > 0 {
+			offset--
+			continue
+		}
+
+		users = append(users, u)
+		if len(users) == limit {
+			break
+		}
+	}
+	return users, nil
+}
+
+// Insert inserts a new user.
+func (s *SQLite) Insert(ctx context.Context, user *User) error {
+	ctx, end := s.startQuery(ctx, "insert")
+	defer end()
+
+	if err := sqliteInsertUser(ctx, s.db, s.scope, user); err != nil {
+		return fmt.Errorf("could not insert user: %w", err)
+	}
+	return nil
+}
+
+// InsertBatch inserts the users with a single multi-row INSERT: either all of them are
+// inserted or none is.
+func (s *SQLite) InsertBatch(ctx context.Context, users []*User) error {
+	ctx, end := s.startQuery(ctx, "insert_batch")
+	defer end()
+
+	if len(users) == 0 {
+		return nil
+	}
+
+	scoped := make([]*scopedUser, 0, len(users))
+	for _, user := range users {
+		user.setInsertDefaults()
+		scoped = append(scoped, s.scope.scoped(ctx, user))
+	}
+
+	if err := sqliteNamedExec(ctx, s.db, insertUserQuery, scoped); err != nil {
+		if dupErr := sqliteUniqueViolationError(err); dupErr != nil {
+			return fmt.Errorf("could not insert users: %w", dupErr)
+		}
+		return fmt.Errorf("could not insert users: %w", err)
+	}
+	return nil
+}
+
+// Update updates a user by id and sets the user event sequence to the incremented one.
+func (s *SQLite) Update(ctx context.Context, user *User) error {
+	ctx, end := s.startQuery(ctx, "update")
+	defer end()
+
+	if err := sqliteUpdateUser(ctx, s.db, s.scope, user); err != nil {
+		return fmt.Errorf("could not update user: %w", err)
+	}
+	return nil
+}
+
+// Delete deletes a user by id and returns the event sequence of the deletion.
+func (s *SQLite) Delete(ctx context.Context, id string) (int64, error) {
+	ctx, end := s.startQuery(ctx, "delete")
+	defer end()
+
+	var sequence int64
+	if err := s.db.QueryRowxContext(
+		ctx,
+		"DELETE FROM users WHERE id = ? AND (tenant_id = ? OR ? = '') RETURNING event_sequence + 1",
+		id,
+		tenantOf(ctx),
+		tenantOf(ctx),
+	).Scan(&sequence); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, fmt.Errorf("could not delete user: %w", ErrUserNotFound)
+		}
+		return 0, fmt.Errorf("could not delete user: %w", err)
+	}
+	return sequence, nil
+}
+
+// Merge merges the duplicate user into the survivor in a single transaction, like Postgres.Merge.
+func (s *SQLite) Merge(ctx context.Context, survivor *User, duplicateID string) error {
+	ctx, end := s.startQuery(ctx, "merge")
+	defer end()
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not begin merge transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(
+		ctx,
+		`INSERT INTO user_tombstones (id, merged_into, first_name, last_name, nickname, email, country, created_at, merged_at)
+		SELECT id, ?, first_name, last_name, nickname, email, country, created_at, ? FROM users
+		WHERE id = ? AND (tenant_id = ? OR ? = '')`,
+		survivor.ID,
+		survivor.UpdatedAt.UTC(),
+		duplicateID,
+		tenantOf(ctx),
+		tenantOf(ctx),
+	)
+	if err != nil {
+		return fmt.Errorf("could not tombstone duplicate user: %w", err)
+	}
+
+	if rows, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("could not tombstone duplicate user: %w", err)
+	} else if rows == 0 {
+		return fmt.Errorf("could not tombstone duplicate user: %w", ErrUserNotFound)
+	}
+
+	// Records referencing the duplicate in other tables must be moved to the survivor here.
+
+	if _, err := tx.ExecContext(ctx, "UPDATE api_keys SET user_id = ? WHERE user_id = ?", survivor.ID, duplicateID); err != nil {
+		return fmt.Errorf("could not move api keys: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE linked_identities SET user_id = ? WHERE user_id = ?", survivor.ID, duplicateID); err != nil {
+		return fmt.Errorf("could not move linked identities: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE user_logins SET user_id = ? WHERE user_id = ?", survivor.ID, duplicateID); err != nil {
+		return fmt.Errorf("could not move logins: %w", err)
+	}
+
+	// The survivor's own locks win over the duplicate's, and the rest are deleted with the duplicate.
+	if _, err := tx.ExecContext(
+		ctx,
+		`INSERT OR IGNORE INTO user_field_locks (user_id, field, reason, locked_by, created_at)
+		SELECT ?, field, reason, locked_by, created_at FROM user_field_locks WHERE user_id = ?`,
+		survivor.ID,
+		duplicateID,
+	); err != nil {
+		return fmt.Errorf("could not move field locks: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM users WHERE id = ?", duplicateID); err != nil {
+		return fmt.Errorf("could not delete duplicate user: %w", err)
+	}
+
+	if err := sqliteUpdateUser(ctx, tx, s.scope, survivor); err != nil {
+		return fmt.Errorf("could not update surviving user: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("could not commit merge transaction: %w", err)
+	}
+	return nil
+}
+
+// Anonymize anonymizes the user in a single transaction, like Postgres.Anonymize.
+func (s *SQLite) Anonymize(ctx context.Context, user *User) error {
+	ctx, end := s.startQuery(ctx, "anonymize")
+	defer end()
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not begin anonymize transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := sqliteUpdateUser(ctx, tx, s.scope, user); err != nil {
+		return fmt.Errorf("could not anonymize user: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE users SET anonymized = TRUE, avatar_key = '', avatar_hash = '' WHERE id = ?`, user.ID); err != nil {
+		return fmt.Errorf("could not anonymize user: %w", err)
+	}
+
+	if _, err := tx.ExecContext(
+		ctx,
+		"UPDATE user_tombstones SET first_name = ?, last_name = ?, nickname = ?, email = ? WHERE merged_into = ?",
+		user.FirstName,
+		user.LastName,
+		user.Nickname,
+		user.Email,
+		user.ID,
+	); err != nil {
+		return fmt.Errorf("could not anonymize tombstones: %w", err)
+	}
+
+	for _, table := range anonymizedTables {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE user_id = ?", user.ID); err != nil {
+			return fmt.Errorf("could not delete %s: %w", strings.ReplaceAll(table, "_", " "), err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("could not commit anonymize transaction: %w", err)
+	}
+
+	user.Anonymized = true
+	user.AvatarKey = ""
+	user.AvatarHash = ""
+	return nil
+}
+
+// SetStatus sets the status of the user, like Postgres.SetStatus.
+func (s *SQLite) SetStatus(ctx context.Context, user *User) error {
+	ctx, end := s.startQuery(ctx, "set_status")
+	defer end()
+
+	if err := s.db.QueryRowxContext(
+		ctx,
+		sqliteQuery(setUserStatusQuery),
+		user.Status,
+		user.UpdatedAt.UTC(),
+		user.ID,
+		tenantOf(ctx),
+	).Scan(&user.EventSequence); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("could not set user status: %w", ErrUserNotFound)
+		}
+		return fmt.Errorf("could not set user status: %w", err)
+	}
+	return nil
+}
+
+// SetAvatar sets the avatar key and hash of the user, like Postgres.SetAvatar.
+func (s *SQLite) SetAvatar(ctx context.Context, user *User) error {
+	ctx, end := s.startQuery(ctx, "set_avatar")
+	defer end()
+
+	if err := s.db.QueryRowxContext(
+		ctx,
+		sqliteQuery(setUserAvatarQuery),
+		user.AvatarKey,
+		user.AvatarHash,
+		user.UpdatedAt.UTC(),
+		user.ID,
+		tenantOf(ctx),
+	).Scan(&user.EventSequence); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("could not set user avatar: %w", ErrUserNotFound)
+		}
+		return fmt.Errorf("could not set user avatar: %w", err)
+	}
+	return nil
+}
+
+// Bootstrap inserts the first admin user and its API key, unless an admin user already exists.
+// The transactions take the write lock when they begin, which serializes concurrent bootstraps.
+func (s *SQLite) Bootstrap(ctx context.Context, admin *User, key *APIKey) error {
+	ctx, end := s.startQuery(ctx, "bootstrap")
+	defer end()
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not begin bootstrap transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Every tenant is bootstrapped on its own.
+	var bootstrapped bool
+	if err := tx.GetContext(
+		ctx,
+		&bootstrapped,
+		"SELECT EXISTS (SELECT 1 FROM users WHERE role = ? AND tenant_id = ?)",
+		RoleAdmin,
+		tenant.OrDefault(ctx),
+	); err != nil {
+		return fmt.Errorf("could not check for admin users: %w", err)
+	}
+
+	if bootstrapped {
+		return fmt.Errorf("could not bootstrap: %w", ErrAlreadyBootstrapped)
+	}
+
+	admin.Role = RoleAdmin
+	if err := sqliteInsertUser(ctx, tx, s.scope, admin); err != nil {
+		return fmt.Errorf("could not insert admin user: %w", err)
+	}
+
+	if err := sqliteNamedExec(ctx, tx, insertAPIKeyQuery, key); err != nil {
+		return fmt.Errorf("could not insert api key: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("could not commit bootstrap transaction: %w", err)
+	}
+	return nil
+}
+
+// InsertAPIKey inserts an API key.
+func (s *SQLite) InsertAPIKey(ctx context.Context, key *APIKey) error {
+	ctx, end := s.startQuery(ctx, "insert_api_key")
+	defer end()
+
+	if err := sqliteNamedExec(ctx, s.db, insertAPIKeyQuery, key); err != nil {
+		if isForeignKeyViolation(err) {
+			return fmt.Errorf("could not insert api key: %w", ErrUserNotFound)
+		}
+		return fmt.Errorf("could not insert api key: %w", err)
+	}
+	return nil
+}
+
+// GetAPIKey returns an API key by id.
+func (s *SQLite) GetAPIKey(ctx context.Context, id string) (*APIKey, error) {
+	ctx, end := s.startQuery(ctx, "get_api_key")
+	defer end()
+
+	var key APIKey
+	if err := s.db.GetContext(
+		ctx,
+		&key,
+		sqliteQuery(getAPIKeyQuery),
+		id,
+	); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("could not get api key: %w", ErrAPIKeyNotFound)
+		}
+		return nil, fmt.Errorf("could not get api key: %w", err)
+	}
+	return &key, nil
+}
+
+// GetAPIKeys returns the API keys of a user, oldest first.
+func (s *SQLite) GetAPIKeys(ctx context.Context, userID string) ([]*APIKey, error) {
+	ctx, end := s.startQuery(ctx, "get_api_keys")
+	defer end()
+
+	var keys []*APIKey
+	if err := s.db.SelectContext(
+		ctx,
+		&keys,
+		sqliteQuery(getAPIKeysQuery),
+		userID,
+	); err != nil {
+		return nil, fmt.Errorf("could not get api keys: %w", err)
+	}
+	return keys, nil
+}
+
+// DeleteAPIKey deletes an API key by id.
+func (s *SQLite) DeleteAPIKey(ctx context.Context, id string) error {
+	ctx, end := s.startQuery(ctx, "delete_api_key")
+	defer end()
+
+	res, err := s.db.ExecContext(ctx, sqliteQuery(deleteAPIKeyQuery), id)
+	if err != nil {
+		return fmt.Errorf("could not delete api key: %w", err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("could not delete api key: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("could not delete api key: %w", ErrAPIKeyNotFound)
+	}
+	return nil
+}
+
+// LinkIdentity links an external identity to a user.
+func (s *SQLite) LinkIdentity(ctx context.Context, identity *LinkedIdentity) error {
+	ctx, end := s.startQuery(ctx, "link_identity")
+	defer end()
+
+	if err := sqliteNamedExec(
+		ctx,
+		s.db,
+		`INSERT INTO linked_identities (provider, subject, user_id, email, created_at)
+		VALUES (:provider, :subject, :user_id, :email, :created_at)`,
+		identity,
+	); err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) {
+			switch sqliteErr.ExtendedCode {
+			case sqlite3.ErrConstraintPrimaryKey:
+				return fmt.Errorf("could not link identity: %w", ErrIdentityLinked)
+			case sqlite3.ErrConstraintForeignKey:
+				return fmt.Errorf("could not link identity: %w", ErrUserNotFound)
+			}
+		}
+		return fmt.Errorf("could not link identity: %w", err)
+	}
+	return nil
+}
+
+// GetLinkedIdentities returns the external identities linked to a user, oldest first.
+func (s *SQLite) GetLinkedIdentities(ctx context.Context, userID string) ([]*LinkedIdentity, error) {
+	ctx, end := s.startQuery(ctx, "get_linked_identities")
+	defer end()
+
+	var identities []*LinkedIdentity
+	if err := s.db.SelectContext(
+		ctx,
+		&identities,
+		`SELECT provider, subject, user_id, email, created_at FROM linked_identities
+		WHERE user_id = ? ORDER BY created_at, provider`,
+		userID,
+	); err != nil {
+		return nil, fmt.Errorf("could not get linked identities: %w", err)
+	}
+	return identities, nil
+}
+
+// GetByLinkedIdentity returns the user linked to the given external identity.
+func (s *SQLite) GetByLinkedIdentity(ctx context.Context, provider, subject string) (*User, error) {
+	ctx, end := s.startQuery(ctx, "get_by_linked_identity")
+	defer end()
+
+	var user User
+	if err := s.db.GetContext(
+		ctx,
+		&user,
+		`SELECT u.id, u.first_name, u.last_name, u.nickname, u.password, u.email,
+		u.country, u.created_at, u.updated_at, u.event_sequence, u.role, u.anonymized, u.status, u.metadata, u.avatar_key, u.avatar_hash, u.phone, u.phone_verified, u.locale, u.timezone, u.birthdate, u.tenant_id
+		FROM users u JOIN linked_identities li ON li.user_id = u.id
+		WHERE li.provider = ? AND li.subject = ? AND (u.tenant_id = ? OR ? = '')`,
+		provider,
+		subject,
+		tenantOf(ctx),
+		tenantOf(ctx),
+	); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("could not get user by linked identity: %w", ErrUserNotFound)
+		}
+		return nil, fmt.Errorf("could not get user by linked identity: %w", err)
+	}
+	return &user, nil
+}
+
+// UnlinkIdentity unlinks an external identity from a user.
+func (s *SQLite) UnlinkIdentity(ctx context.Context, userID, provider, subject string) error {
+	ctx, end := s.startQuery(ctx, "unlink_identity")
+	defer end()
+
+	res, err := s.db.ExecContext(ctx, sqliteQuery(unlinkIdentityQuery), userID, provider, subject)
+	if err != nil {
+		return fmt.Errorf("could not unlink identity: %w", err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("could not unlink identity: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("could not unlink identity: %w", ErrIdentityNotFound)
+	}
+	return nil
+}
+
+// LockField locks a field of a user, replacing the reason of an existing lock.
+func (s *SQLite) LockField(ctx context.Context, lock *FieldLock) error {
+	ctx, end := s.startQuery(ctx, "lock_field")
+	defer end()
+
+	if err := sqliteNamedExec(
+		ctx,
+		s.db,
+		`INSERT INTO user_field_locks (user_id, field, reason, locked_by, created_at)
+		VALUES (:user_id, :field, :reason, :locked_by, :created_at)
+		ON CONFLICT (user_id, field) DO UPDATE
+		SET reason = excluded.reason, locked_by = excluded.locked_by, created_at = excluded.created_at`,
+		lock,
+	); err != nil {
+		if isForeignKeyViolation(err) {
+			return fmt.Errorf("could not lock field: %w", ErrUserNotFound)
+		}
+		return fmt.Errorf("could not lock field: %w", err)
+	}
+	return nil
+}
+
+// UnlockField unlocks a field of a user. Unlocking a field that is not locked is a no-op.
+func (s *SQLite) UnlockField(ctx context.Context, userID, field string) error {
+	ctx, end := s.startQuery(ctx, "unlock_field")
+	defer end()
+
+	if _, err := s.db.ExecContext(
+		ctx,
+		"DELETE FROM user_field_locks WHERE user_id = ? AND field = ?",
+		userID,
+		field,
+	); err != nil {
+		return fmt.Errorf("could not unlock field: %w", err)
+	}
+	return nil
+}
+
+// GetFieldLocks returns the locked fields of a user, ordered by field.
+func (s *SQLite) GetFieldLocks(ctx context.Context, userID string) ([]*FieldLock, error) {
+	ctx, end := s.startQuery(ctx, "get_field_locks")
+	defer end()
+
+	var locks []*FieldLock
+	if err := s.db.SelectContext(
+		ctx,
+		&locks,
+		`SELECT user_id, field, reason, locked_by, created_at FROM user_field_locks
+		WHERE user_id = ? ORDER BY field`,
+		userID,
+	); err != nil {
+		return nil, fmt.Errorf("could not get field locks: %w", err)
+	}
+	return locks, nil
+}
+
+// RecordLogin records a successful login.
+func (s *SQLite) RecordLogin(ctx context.Context, login *Login) error {
+	ctx, end := s.startQuery(ctx, "record_login")
+	defer end()
+
+	if err := sqliteNamedExec(
+		ctx,
+		s.db,
+		`INSERT INTO user_logins (user_id, ip, country, asn, created_at)
+		VALUES (:user_id, :ip, :country, :asn, :created_at)`,
+		login,
+	); err != nil {
+		if isForeignKeyViolation(err) {
+			return fmt.Errorf("could not record login: %w", ErrUserNotFound)
+		}
+		return fmt.Errorf("could not record login: %w", err)
+	}
+	return nil
+}
+
+// GetRecentLogins returns the last logins of a user, most recent first.
+func (s *SQLite) GetRecentLogins(ctx context.Context, userID string, limit int) ([]*Login, error) {
+	ctx, end := s.startQuery(ctx, "get_recent_logins")
+	defer end()
+
+	var logins []*Login
+	if err := s.db.SelectContext(
+		ctx,
+		&logins,
+		`SELECT user_id, ip, country, asn, created_at FROM user_logins
+		WHERE user_id = ? ORDER BY created_at DESC, id DESC LIMIT ?`,
+		userID,
+		limit,
+	); err != nil {
+		return nil, fmt.Errorf("could not get recent logins: %w", err)
+	}
+	return logins, nil
+}
+
+// GetLockout returns the failed logins of a user. A user without failed logins
+// gets a lockout with no attempts.
+func (s *SQLite) GetLockout(ctx context.Context, userID string) (*Lockout, error) {
+	ctx, end := s.startQuery(ctx, "get_lockout")
+	defer end()
+
+	var lockout Lockout
+	if err := s.db.GetContext(
+		ctx,
+		&lockout,
+		sqliteQuery(getLockoutQuery),
+		userID,
+	); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &Lockout{UserID: userID}, nil
+		}
+		return nil, fmt.Errorf("could not get lockout: %w", err)
+	}
+	return &lockout, nil
+}
+
+// RecordFailedLogin counts a failed login of a user and returns the updated lockout.
+func (s *SQLite) RecordFailedLogin(ctx context.Context, userID string) (*Lockout, error) {
+	ctx, end := s.startQuery(ctx, "record_failed_login")
+	defer end()
+
+	var lockout Lockout
+	if err := s.db.GetContext(
+		ctx,
+		&lockout,
+		sqliteQuery(recordFailedLoginQuery),
+		utc([]any{userID, time.Time{}})...,
+	); err != nil {
+		if isForeignKeyViolation(err) {
+			return nil, fmt.Errorf("could not record failed login: %w", ErrUserNotFound)
+		}
+		return nil, fmt.Errorf("could not record failed login: %w", err)
+	}
+	return &lockout, nil
+}
+
+// LockUser locks a user out until the given time, starting over the count of failed logins.
+func (s *SQLite) LockUser(ctx context.Context, userID string, until time.Time) error {
+	ctx, end := s.startQuery(ctx, "lock_user")
+	defer end()
+
+	if _, err := s.db.ExecContext(
+		ctx,
+		sqliteQuery(lockUserQuery),
+		utc([]any{userID, until})...,
+	); err != nil {
+		return fmt.Errorf("could not lock user: %w", err)
+	}
+	return nil
+}
+
+// ResetLockout forgets the failed logins and the lockouts of a user, unlocking it.
+func (s *SQLite) ResetLockout(ctx context.Context, userID string) error {
+	ctx, end := s.startQuery(ctx, "reset_lockout")
+	defer end()
+
+	if _, err := s.db.ExecContext(
+		ctx,
+		sqliteQuery(resetLockoutQuery),
+		userID,
+	); err != nil {
+		return fmt.Errorf("could not reset lockout: %w", err)
+	}
+	return nil
+}
+
+// InsertSession inserts a session. The expired sessions of the user are deleted on the way.
+func (s *SQLite) InsertSession(ctx context.Context, session *Session) error {
+	ctx, end := s.startQuery(ctx, "insert_session")
+	defer end()
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not begin session transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(
+		ctx,
+		"DELETE FROM sessions WHERE user_id = ? AND expires_at <= ?",
+		utc([]any{session.UserID, session.CreatedAt})...,
+	); err != nil {
+		return fmt.Errorf("could not delete expired sessions: %w", err)
+	}
+
+	if err := sqliteNamedExec(
+		ctx,
+		tx,
+		`INSERT INTO sessions (id, user_id, access_token_hash, refresh_token_hash, ip, created_at, refreshed_at,
+		access_token_expires_at, expires_at)
+		VALUES (:id, :user_id, :access_token_hash, :refresh_token_hash, :ip, :created_at, :refreshed_at,
+		:access_token_expires_at, :expires_at)`,
+		session,
+	); err != nil {
+		if isForeignKeyViolation(err) {
+			return fmt.Errorf("could not insert session: %w", ErrUserNotFound)
+		}
+		return fmt.Errorf("could not insert session: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("could not commit session transaction: %w", err)
+	}
+	return nil
+}
+
+// GetSession returns a session by id.
+func (s *SQLite) GetSession(ctx context.Context, id string) (*Session, error) {
+	ctx, end := s.startQuery(ctx, "get_session")
+	defer end()
+
+	var session Session
+	if err := s.db.GetContext(
+		ctx,
+		&session,
+		sqliteQuery(getSessionQuery),
+		id,
+	); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("could not get session: %w", ErrSessionNotFound)
+		}
+		return nil, fmt.Errorf("could not get session: %w", err)
+	}
+	return &session, nil
+}
+
+// GetSessions returns the sessions of a user, most recent first.
+func (s *SQLite) GetSessions(ctx context.Context, userID string) ([]*Session, error) {
+	ctx, end := s.startQuery(ctx, "get_sessions")
+	defer end()
+
+	var sessions []*Session
+	if err := s.db.SelectContext(
+		ctx,
+		&sessions,
+		sqliteQuery(getSessionsQuery),
+		userID,
+	); err != nil {
+		return nil, fmt.Errorf("could not get sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+// RotateSession replaces the tokens and the expiry of the session, given the hash of its
+// current refresh token. It fails with ErrSessionNotFound if the refresh token was rotated
+// meanwhile, so a refresh token can only be used once.
+func (s *SQLite) RotateSession(ctx context.Context, session *Session, refreshTokenHash []byte) error {
+	ctx, end := s.startQuery(ctx, "rotate_session")
+	defer end()
+
+	res, err := s.db.ExecContext(
+		ctx,
+		sqliteQuery(rotateSessionQuery),
+		utc([]any{
+			session.ID,
+			refreshTokenHash,
+			session.AccessTokenHash,
+			session.RefreshTokenHash,
+			session.RefreshedAt,
+			session.AccessTokenExpiresAt,
+			session.ExpiresAt,
+		})...,
+	)
+	if err != nil {
+		return fmt.Errorf("could not rotate session: %w", err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("could not rotate session: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("could not rotate session: %w", ErrSessionNotFound)
+	}
+	return nil
+}
+
+// DeleteSession deletes a session by id.
+func (s *SQLite) DeleteSession(ctx context.Context, id string) error {
+	ctx, end := s.startQuery(ctx, "delete_session")
+	defer end()
+
+	res, err := s.db.ExecContext(ctx, sqliteQuery(deleteSessionQuery), id)
+	if err != nil {
+		return fmt.Errorf("could not delete session: %w", err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("could not delete session: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("could not delete session: %w", ErrSessionNotFound)
+	}
+	return nil
+}
+
+// InsertTOTP inserts the pending TOTP enrollment of a user, replacing a pending one.
+// It fails with ErrTOTPEnabled if the user already enabled TOTP.
+func (s *SQLite) InsertTOTP(ctx context.Context, totp *TOTP) error {
+	ctx, end := s.startQuery(ctx, "insert_totp")
+	defer end()
+
+	query, args, err := sqlx.Named(insertTOTPQuery, totp)
+	if err != nil {
+		return fmt.Errorf("could not insert totp: %w", err)
+	}
+
+	res, err := s.db.ExecContext(ctx, query, utc(args)...)
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return fmt.Errorf("could not insert totp: %w", ErrUserNotFound)
+		}
+		return fmt.Errorf("could not insert totp: %w", err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("could not insert totp: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("could not insert totp: %w", ErrTOTPEnabled)
+	}
+	return nil
+}
+
+// GetTOTP returns the TOTP enrollment of a user.
+func (s *SQLite) GetTOTP(ctx context.Context, userID string) (*TOTP, error) {
+	ctx, end := s.startQuery(ctx, "get_totp")
+	defer end()
+
+	var totp TOTP
+	if err := s.db.GetContext(ctx, &totp, sqliteQuery(getTOTPQuery), userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("could not get totp: %w", ErrTOTPNotFound)
+		}
+		return nil, fmt.Errorf("could not get totp: %w", err)
+	}
+	return &totp, nil
+}
+
+// UseTOTP records the use of a code of the time step, enabling TOTP on first use. It fails
+// with ErrTOTPCodeUsed if a code of that step or a later one was used, so codes can't be replayed.
+func (s *SQLite) UseTOTP(ctx context.Context, userID string, step int64) error {
+	ctx, end := s.startQuery(ctx, "use_totp")
+	defer end()
+
+	res, err := s.db.ExecContext(ctx, sqliteQuery(useTOTPQuery), userID, step)
+	if err != nil {
+		return fmt.Errorf("could not use totp: %w", err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("could not use totp: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("could not use totp: %w", ErrTOTPCodeUsed)
+	}
+	return nil
+}
+
+// InsertPhoneVerification inserts the phone verification of a user, replacing a pending one.
+func (s *SQLite) InsertPhoneVerification(ctx context.Context, verification *PhoneVerification) error {
+	ctx, end := s.startQuery(ctx, "insert_phone_verification")
+	defer end()
+
+	if err := sqliteNamedExec(ctx, s.db, insertPhoneVerificationQuery, verification); err != nil {
+		if isForeignKeyViolation(err) {
+			return fmt.Errorf("could not insert phone verification: %w", ErrUserNotFound)
+		}
+		return fmt.Errorf("could not insert phone verification: %w", err)
+	}
+	return nil
+}
+
+// AttemptPhoneVerification counts an attempt to verify the phone of a user and returns the
+// phone verification, attempt included, if it has not expired by now.
+func (s *SQLite) AttemptPhoneVerification(ctx context.Context, userID string, now time.Time) (*PhoneVerification, error) {
+	ctx, end := s.startQuery(ctx, "attempt_phone_verification")
+	defer end()
+
+	var verification PhoneVerification
+	if err := s.db.GetContext(
+		ctx,
+		&verification,
+		sqliteQuery(attemptPhoneVerificationQuery),
+		userID,
+		now.UTC(),
+	); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("could not attempt phone verification: %w", ErrPhoneVerificationNotFound)
+		}
+		return nil, fmt.Errorf("could not attempt phone verification: %w", err)
+	}
+	return &verification, nil
+}
+
+// DeletePhoneVerification deletes the phone verification of a user, if any.
+func (s *SQLite) DeletePhoneVerification(ctx context.Context, userID string) error {
+	ctx, end := s.startQuery(ctx, "delete_phone_verification")
+	defer end()
+
+	if _, err := s.db.ExecContext(ctx, sqliteQuery(deletePhoneVerificationQuery), userID); err != nil {
+		return fmt.Errorf("could not delete phone verification: %w", err)
+	}
+	return nil
+}
+
+// InsertPasswordResetToken inserts a password reset token.
+// The expired tokens of the user are deleted on the way.
+func (s *SQLite) InsertPasswordResetToken(ctx context.Context, token *PasswordResetToken) error {
+	ctx, end := s.startQuery(ctx, "insert_password_reset_token")
+	defer end()
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not begin password reset token transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(
+		ctx,
+		"DELETE FROM password_reset_tokens WHERE user_id = ? AND expires_at <= ?",
+		token.UserID,
+		token.CreatedAt.UTC(),
+	); err != nil {
+		return fmt.Errorf("could not delete expired password reset tokens: %w", err)
+	}
+
+	if err := sqliteNamedExec(
+		ctx,
+		tx,
+		`INSERT INTO password_reset_tokens (token_hash, user_id, created_at, expires_at)
+		VALUES (:token_hash, :user_id, :created_at, :expires_at)`,
+		token,
+	); err != nil {
+		if isForeignKeyViolation(err) {
+			return fmt.Errorf("could not insert password reset token: %w", ErrUserNotFound)
+		}
+		return fmt.Errorf("could not insert password reset token: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("could not commit password reset token transaction: %w", err)
+	}
+	return nil
+}
+
+// ResetPassword consumes the password reset token, if it has not expired by now, and sets
+// the password of its user in a single transaction, like Postgres.ResetPassword.
+func (s *SQLite) ResetPassword(ctx context.Context, tokenHash []byte, password string, now time.Time) (*User, error) {
+	ctx, end := s.startQuery(ctx, "reset_password")
+	defer end()
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not begin reset password transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var userID string
+	if err := tx.GetContext(
+		ctx,
+		&userID,
+		"DELETE FROM password_reset_tokens WHERE token_hash = ? AND expires_at > ? RETURNING user_id",
+		tokenHash,
+		now.UTC(),
+	); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("could not reset password: %w", ErrResetTokenNotFound)
+		}
+		return nil, fmt.Errorf("could not consume password reset token: %w", err)
+	}
+
+	result, err := tx.ExecContext(
+		ctx,
+		"UPDATE users SET password = ?, updated_at = ?, event_sequence = event_sequence + 1 WHERE id = ? AND (tenant_id = ? OR ? = '')",
+		password,
+		now.UTC(),
+		userID,
+		tenantOf(ctx),
+		tenantOf(ctx),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not update password: %w", err)
+	}
+
+	// The token of a user of another tenant is unknown to this one.
+	if rows, err := result.RowsAffected(); err != nil {
+		return nil, fmt.Errorf("could not update password: %w", err)
+	} else if rows == 0 {
+		return nil, fmt.Errorf("could not reset password: %w", ErrResetTokenNotFound)
+	}
+
+	// The returned columns have no declared type, so the user is read back to parse its timestamps.
+	var user User
+	if err := tx.GetContext(
+		ctx,
+		&user,
+		`SELECT id, first_name, last_name, nickname, password, email,
+		country, created_at, updated_at, event_sequence, role, anonymized, status, metadata, avatar_key, avatar_hash, phone, phone_verified, locale, timezone, birthdate, tenant_id FROM users WHERE id = ?`,
+		userID,
+	); err != nil {
+		return nil, fmt.Errorf("could not get updated user: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM password_reset_tokens WHERE user_id = ?", userID); err != nil {
+		return nil, fmt.Errorf("could not delete password reset tokens: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("could not commit reset password transaction: %w", err)
+	}
+	return &user, nil
+}
+
+// CountByCountry returns the number of users per country.
+func (s *SQLite) CountByCountry(ctx context.Context) (map[string]int64, error) {
+	ctx, end := s.startQuery(ctx, "count_by_country")
+	defer end()
+
+	var rows []struct {
+		Country string `db:"country"`
+		Count   int64  `db:"count"`
+	}
+	if err := s.db.SelectContext(
+		ctx,
+		&rows,
+		sqliteQuery(countByCountryQuery),
+		tenantOf(ctx),
+	); err != nil {
+		return nil, fmt.Errorf("could not count users by country: %w", err)
+	}
+
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Country] = row.Count
+	}
+	return counts, nil
+}
+
+// ApplyUniquenessScope recomputes the uniqueness key of the users like the Postgres repository.
+func (s *SQLite) ApplyUniquenessScope(ctx context.Context) (int64, error) {
+	ctx, end := s.startQuery(ctx, "apply_uniqueness_scope")
+	defer end()
+
+	key := s.scope.keyColumn()
+
+	res, err := s.db.ExecContext(ctx, fmt.Sprintf(
+		"UPDATE users SET uniqueness_key = %s WHERE uniqueness_key <> %s", key, key,
+	))
+	if err != nil {
+		if dupErr := sqliteUniqueViolationError(err); dupErr != nil {
+			return 0, fmt.Errorf("could not apply uniqueness scope: %w", dupErr)
+		}
+		return 0, fmt.Errorf("could not apply uniqueness scope: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// CheckDatabaseHealth checks if the database is healthy by pinging it.
+func (s *SQLite) CheckDatabaseHealth(ctx context.Context) error {
+	if err := s.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("could not ping database: %w", err)
+	}
+	return nil
+}
+
+// sqliteInsertUser inserts the user like insertUser.
+func sqliteInsertUser(ctx context.Context, q sqlx.ExtContext, scope UniquenessScope, user *User) error {
+	user.setInsertDefaults()
+
+	if err := sqliteNamedExec(
+		ctx,
+		q,
+		insertUserQuery,
+		scope.scoped(ctx, user),
+	); err != nil {
+		if dupErr := sqliteUniqueViolationError(err); dupErr != nil {
+			return dupErr
+		}
+		return err
+	}
+	return nil
+}
+
+// sqliteUpdateUser updates the user like updateUser.
+func sqliteUpdateUser(ctx context.Context, q sqlx.ExtContext, scope UniquenessScope, user *User) error {
+	scoped := scope.scoped(ctx, user)
+
+	var sequence int64
+	if err := q.QueryRowxContext(
+		ctx,
+		`UPDATE users SET first_name = ?, last_name = ?, nickname = ?, password = ?, email = ?,
+		country = ?, metadata = ?, phone = ?, phone_verified = ?, locale = ?, timezone = ?, birthdate = ?,
+		updated_at = ?, uniqueness_key = ?, event_sequence = event_sequence + 1
+		WHERE id = ? AND (tenant_id = ? OR ? = '') RETURNING event_sequence`,
+		user.FirstName,
+		user.LastName,
+		user.Nickname,
+		user.Password,
+		user.Email,
+		user.Country,
+		user.Metadata,
+		user.Phone,
+		user.PhoneVerified,
+		user.Locale,
+		user.Timezone,
+		user.Birthdate,
+		user.UpdatedAt.UTC(),
+		scoped.UniquenessKey,
+		user.ID,
+		scoped.ScopeTenant,
+		scoped.ScopeTenant,
+	).Scan(&sequence); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrUserNotFound
+		}
+
+		if dupErr := sqliteUniqueViolationError(err); dupErr != nil {
+			return dupErr
+		}
+		return err
+	}
+
+	user.EventSequence = sequence
+	return nil
+}
+
+// sqliteNamedExec executes the named query with the fields of arg, the times in UTC.
+func sqliteNamedExec(ctx context.Context, q sqlx.ExtContext, query string, arg any) error {
+	query, args, err := sqlx.Named(query, arg)
+	if err != nil {
+		return err
+	}
+
+	_, err = q.ExecContext(ctx, query, utc(args)...)
+	return err
+}
+
+// sqliteQuery replaces the numbered placeholders of the Postgres queries with the SQLite ones.
+func sqliteQuery(query string) string {
+	return strings.ReplaceAll(query, "$", "?")
+}
+
+// utc converts the times to UTC. The timestamps are stored as text, which compares
+// like the times only when every timestamp has the same offset.
+func utc(args []any) []any {
+	for i, arg := range args {
+		if t, ok := arg.(time.Time); ok {
+			args[i] = t.UTC()
+		}
+	}
+	return args
+}
+
+// sqliteUniqueViolationError maps a unique violation to ErrDuplicateNickname or
+// ErrDuplicateEmail based on the violated column. It returns nil for any other error.
+func sqliteUniqueViolationError(err error) error {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) {
+		return nil
+	}
+
+	switch sqliteErr.ExtendedCode {
+	case sqlite3.ErrConstraintUnique, sqlite3.ErrConstraintPrimaryKey:
+		if strings.Contains(sqliteErr.Error(), nicknameUniqueColumn) {
+			return ErrDuplicateNickname
+		}
+		return ErrDuplicateEmail
+	}
+	return nil
+}
+
+func isForeignKeyViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintForeignKey
+}
+
+// startQuery starts a client span for the operation. The returned function ends the span.
+func (s *SQLite) startQuery(ctx context.Context, operation string) (context.Context, func()) {
+	ctx, span := tracer.Start(ctx, "sqlite."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemSqlite,
+			semconv.DBOperation(operation),
+			semconv.DBSQLTable("users"),
+		),
+	)
+	return ctx, func() { span.End() }
+}
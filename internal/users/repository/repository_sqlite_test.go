@@ -0,0 +1,1072 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alesr/usrsvc/internal/tenant"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/pressly/goose/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sqliteMigrationsDir string = "../../../migrations/sqlite"
+
+func TestSQLiteGet(t *testing.T) {
+	t.Run("happy case", func(t *testing.T) {
+		// Arrange
+		repo := NewSQLite(setupSQLiteHelper(t))
+		givenUser := newMemoryUserHelper(t, "joedoe@foo.bar", "BR")
+		require.NoError(t, repo.Insert(context.TODO(), givenUser))
+
+		// Act
+		actualUser, err := repo.Get(context.TODO(), givenUser.ID)
+		require.NoError(t, err)
+
+		// Assert
+		assert.Equal(t, givenUser, actualUser)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		// Arrange
+		repo := NewSQLite(setupSQLiteHelper(t))
+
+		// Act
+		actualUser, err := repo.Get(context.TODO(), uuid.New().String())
+
+		// Assert
+		assert.True(t, errors.Is(err, ErrUserNotFound))
+		assert.Nil(t, actualUser)
+	})
+}
+
+func TestSQLitePagination(t *testing.T) {
+	// Arrange
+	repo := NewSQLite(setupSQLiteHelper(t))
+	for i := 0; i < 5; i++ {
+		country := "BR"
+		if i%2 == 0 {
+			country = "US"
+		}
+		require.NoError(t, repo.Insert(context.TODO(), newMemoryUserHelper(t, fmt.Sprintf("joedoe%d@foo.bar", i), country)))
+	}
+
+	// Act
+	var all []*User
+	cursor := ""
+	for {
+		page, err := repo.List(context.TODO(), Filter{}, Page{Cursor: cursor, Limit: 2})
+		require.NoError(t, err)
+		all = append(all, page...)
+		if len(page) < 2 {
+			break
+		}
+		cursor = page[len(page)-1].ID
+	}
+
+	byCountry, err := repo.List(context.TODO(), Filter{Country: "US"}, Page{Limit: 10})
+	require.NoError(t, err)
+
+	// Assert
+	require.Len(t, all, 5)
+	for i := 1; i < len(all); i++ {
+		assert.Less(t, all[i-1].ID, all[i].ID)
+	}
+
+	require.Len(t, byCountry, 3)
+	for _, user := range byCountry {
+		assert.Equal(t, "US", user.Country)
+	}
+}
+
+func TestSQLiteListByFilter(t *testing.T) {
+	// Arrange
+	repo := NewSQLite(setupSQLiteHelper(t))
+
+	john := newMemoryUserHelper(t, "johndoe@foo.bar", "US")
+	jane := newMemoryUserHelper(t, "janedoe@foo.bar", "US")
+	jane.FirstName = "Jane"
+	jane.CreatedAt = john.CreatedAt.Add(time.Hour)
+
+	for _, user := range []*User{john, jane, newMemoryUserHelper(t, "johnsmith@foo.bar", "BR")} {
+		require.NoError(t, repo.Insert(context.TODO(), user))
+	}
+
+	// Act
+	byNickname, err := repo.List(context.TODO(), Filter{Country: "US", NicknamePrefix: "john"}, Page{Limit: 10})
+	require.NoError(t, err)
+
+	// Nickname prefixes are case sensitive, like in Postgres.
+	byUpperNickname, err := repo.List(context.TODO(), Filter{NicknamePrefix: "JOHN"}, Page{Limit: 10})
+	require.NoError(t, err)
+
+	// Times with another offset are compared as the same instant.
+	after := jane.CreatedAt.In(time.FixedZone("UTC-3", -3*60*60))
+	byCreation, err := repo.List(context.TODO(), Filter{LastName: "DOE", CreatedAfter: after}, Page{Limit: 10})
+	require.NoError(t, err)
+
+	count, err := repo.Count(context.TODO(), Filter{Country: "US"})
+	require.NoError(t, err)
+
+	// Assert
+	require.Len(t, byNickname, 1)
+	assert.Equal(t, john.ID, byNickname[0].ID)
+
+	assert.Empty(t, byUpperNickname)
+
+	require.Len(t, byCreation, 1)
+	assert.Equal(t, jane.ID, byCreation[0].ID)
+
+	assert.Equal(t, int64(2), count)
+}
+
+func TestSQLiteSearch(t *testing.T) {
+	// Arrange
+	repo := NewSQLite(setupSQLiteHelper(t))
+
+	john := newMemoryUserHelper(t, "johndoe@foo.bar", "US")
+	jane := newMemoryUserHelper(t, "janedoe@foo.bar", "US")
+	jane.FirstName = "Jane"
+	smith := newMemoryUserHelper(t, "jsmith@foo.bar", "BR")
+	smith.LastName = "Smith"
+
+	for _, user := range []*User{john, jane, smith} {
+		require.NoError(t, repo.Insert(context.TODO(), user))
+	}
+
+	// Act
+	byPrefixes, err := repo.Search(context.TODO(), "JO d", 0, 10)
+	require.NoError(t, err)
+
+	byNickname, err := repo.Search(context.TODO(), "jsmi", 0, 10)
+	require.NoError(t, err)
+
+	pastTheEnd, err := repo.Search(context.TODO(), "doe", 2, 10)
+	require.NoError(t, err)
+
+	// "oe" is in the middle of the words, so it is not a match.
+	inTheMiddle, err := repo.Search(context.TODO(), "oe", 0, 10)
+	require.NoError(t, err)
+
+	// Assert
+	require.Len(t, byPrefixes, 1)
+	assert.Equal(t, john.ID, byPrefixes[0].ID)
+
+	require.Len(t, byNickname, 1)
+	assert.Equal(t, smith.ID, byNickname[0].ID)
+
+	assert.Empty(t, pastTheEnd)
+	assert.Empty(t, inTheMiddle)
+}
+
+func TestSQLiteInsertAndUpdate(t *testing.T) {
+	// Arrange
+	repo := NewSQLite(setupSQLiteHelper(t))
+
+	john := newMemoryUserHelper(t, "johndoe@foo.bar", "US")
+	jane := newMemoryUserHelper(t, "janedoe@foo.bar", "US")
+	require.NoError(t, repo.Insert(context.TODO(), john))
+	require.NoError(t, repo.Insert(context.TODO(), jane))
+
+	sameEmail := newMemoryUserHelper(t, "johndoe@foo.bar", "BR")
+	sameEmail.Nickname = "someoneelse"
+
+	sameNickname := newMemoryUserHelper(t, "jdoe@foo.bar", "BR")
+	sameNickname.Nickname = "johndoe"
+
+	// Act
+	duplicateEmailErr := repo.Insert(context.TODO(), sameEmail)
+	duplicateNicknameErr := repo.Insert(context.TODO(), sameNickname)
+
+	updated := *john
+	updated.FirstName = "Johnny"
+	updated.Locale = "pt-BR"
+	updated.Timezone = "America/Sao_Paulo"
+	birthdate := time.Date(1990, time.February, 28, 0, 0, 0, 0, time.UTC)
+	updated.Birthdate = &birthdate
+	updateErr := repo.Update(context.TODO(), &updated)
+
+	takenEmail := *john
+	takenEmail.Email = jane.Email
+	takenEmailErr := repo.Update(context.TODO(), &takenEmail)
+
+	notFoundErr := repo.Update(context.TODO(), newMemoryUserHelper(t, "unknown@foo.bar", "US"))
+
+	// Assert
+	assert.True(t, errors.Is(duplicateEmailErr, ErrDuplicateEmail))
+	assert.True(t, errors.Is(duplicateNicknameErr, ErrDuplicateNickname))
+
+	require.NoError(t, updateErr)
+	assert.Equal(t, int64(2), updated.EventSequence)
+
+	stored, err := repo.Get(context.TODO(), john.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Johnny", stored.FirstName)
+	assert.Equal(t, "pt-BR", stored.Locale)
+	assert.Equal(t, "America/Sao_Paulo", stored.Timezone)
+	require.NotNil(t, stored.Birthdate)
+	assert.True(t, birthdate.Equal(*stored.Birthdate))
+	assert.Equal(t, RoleUser, stored.Role)
+
+	assert.True(t, errors.Is(takenEmailErr, ErrDuplicateEmail))
+	assert.True(t, errors.Is(notFoundErr, ErrUserNotFound))
+}
+
+func TestSQLiteInsertBatch(t *testing.T) {
+	// Arrange
+	repo := NewSQLite(setupSQLiteHelper(t))
+	require.NoError(t, repo.Insert(context.TODO(), newMemoryUserHelper(t, "joedoe@foo.bar", "BR")))
+
+	batch := []*User{
+		newMemoryUserHelper(t, "janedoe@foo.bar", "US"),
+		newMemoryUserHelper(t, "jsmith@foo.bar", "PT"),
+	}
+
+	// The second user of the failed batch shares the email of the stored user.
+	failed := []*User{
+		newMemoryUserHelper(t, "alice@foo.bar", "US"),
+		newMemoryUserHelper(t, "joedoe@foo.bar", "PT"),
+	}
+	failed[1].Nickname = "someoneelse"
+
+	// Act
+	err := repo.InsertBatch(context.TODO(), batch)
+	require.NoError(t, err)
+
+	failedErr := repo.InsertBatch(context.TODO(), failed)
+
+	count, err := repo.Count(context.TODO(), Filter{})
+	require.NoError(t, err)
+
+	inserted, err := repo.Get(context.TODO(), batch[1].ID)
+	require.NoError(t, err)
+
+	_, notInsertedErr := repo.Get(context.TODO(), failed[0].ID)
+
+	// Assert
+	assert.True(t, errors.Is(failedErr, ErrDuplicateEmail))
+	assert.Equal(t, int64(3), count)
+	assert.Equal(t, StatusActive, inserted.Status)
+	assert.Equal(t, int64(1), inserted.EventSequence)
+	assert.True(t, errors.Is(notInsertedErr, ErrUserNotFound))
+}
+
+func TestSQLiteUniquenessScope(t *testing.T) {
+	// Arrange
+	db := setupSQLiteHelper(t)
+	repo := NewSQLite(db, WithSQLiteUniquenessScope(ScopeCountry))
+
+	john := newMemoryUserHelper(t, "johndoe@foo.bar", "US")
+	require.NoError(t, repo.Insert(context.TODO(), john))
+
+	otherCountry := newMemoryUserHelper(t, "johndoe@foo.bar", "BR")
+	otherCountry.Nickname = "johndoe_br"
+
+	sameCountry := newMemoryUserHelper(t, "johndoe@foo.bar", "US")
+	sameCountry.Nickname = "johndoe_us"
+
+	// Act
+	otherCountryErr := repo.Insert(context.TODO(), otherCountry)
+	sameCountryErr := repo.Insert(context.TODO(), sameCountry)
+
+	_, ambiguousErr := repo.GetByEmail(context.TODO(), "johndoe@foo.bar")
+
+	moved := *john
+	moved.Country = "BR"
+	movedErr := repo.Update(context.TODO(), &moved)
+
+	// The users sharing the email conflict once the email is globally unique again.
+	_, globalErr := NewSQLite(db).ApplyUniquenessScope(context.TODO())
+
+	// Assert
+	require.NoError(t, otherCountryErr)
+	assert.True(t, errors.Is(sameCountryErr, ErrDuplicateEmail))
+	assert.True(t, errors.Is(ambiguousErr, ErrAmbiguousEmail))
+	assert.True(t, errors.Is(movedErr, ErrDuplicateEmail))
+	assert.True(t, errors.Is(globalErr, ErrDuplicateEmail))
+}
+
+func TestSQLiteTenants(t *testing.T) {
+	// Arrange
+	repo := NewSQLite(setupSQLiteHelper(t))
+	acme := tenant.ContextWithTenant(context.TODO(), "acme")
+	globex := tenant.ContextWithTenant(context.TODO(), "globex")
+
+	john := newMemoryUserHelper(t, "johndoe@foo.bar", "US")
+	require.NoError(t, repo.Insert(acme, john))
+
+	// The emails and nicknames are unique per tenant.
+	otherTenant := newMemoryUserHelper(t, "johndoe@foo.bar", "US")
+	require.NoError(t, repo.Insert(globex, otherTenant))
+
+	sameTenant := newMemoryUserHelper(t, "johndoe@foo.bar", "US")
+	sameTenant.Nickname = "johndoe_us"
+
+	// Act
+	sameTenantErr := repo.Insert(acme, sameTenant)
+
+	visible, err := repo.Get(acme, john.ID)
+	require.NoError(t, err)
+
+	_, hiddenErr := repo.Get(globex, john.ID)
+
+	byEmail, err := repo.GetByEmail(globex, "johndoe@foo.bar")
+	require.NoError(t, err)
+
+	listed, err := repo.List(acme, Filter{}, Page{Limit: 10})
+	require.NoError(t, err)
+
+	// The operations that aren't scoped, e.g. the background jobs, see every tenant.
+	count, err := repo.Count(context.TODO(), Filter{})
+	require.NoError(t, err)
+
+	taken, err := repo.NicknameExists(globex, "johndoe", "US")
+	require.NoError(t, err)
+
+	free, err := repo.NicknameExists(context.TODO(), "johndoe", "US")
+	require.NoError(t, err)
+
+	_, deleteErr := repo.Delete(globex, john.ID)
+
+	// Assert
+	assert.True(t, errors.Is(sameTenantErr, ErrDuplicateEmail))
+	assert.Equal(t, "acme", visible.TenantID)
+	assert.True(t, errors.Is(hiddenErr, ErrUserNotFound))
+	assert.Equal(t, otherTenant.ID, byEmail.ID)
+	require.Len(t, listed, 1)
+	assert.Equal(t, john.ID, listed[0].ID)
+	assert.Equal(t, int64(2), count)
+	assert.True(t, taken)
+	assert.False(t, free)
+	assert.True(t, errors.Is(deleteErr, ErrUserNotFound))
+}
+
+func TestSQLiteNicknameExists(t *testing.T) {
+	// Arrange
+	repo := NewSQLite(setupSQLiteHelper(t), WithSQLiteUniquenessScope(ScopeCountry))
+	require.NoError(t, repo.Insert(context.TODO(), newMemoryUserHelper(t, "johndoe@foo.bar", "US")))
+
+	// Act
+	taken, err := repo.NicknameExists(context.TODO(), "johndoe", "US")
+	require.NoError(t, err)
+
+	otherCountry, err := repo.NicknameExists(context.TODO(), "johndoe", "BR")
+	require.NoError(t, err)
+
+	free, err := repo.NicknameExists(context.TODO(), "janedoe", "US")
+	require.NoError(t, err)
+
+	// Assert
+	assert.True(t, taken)
+	assert.False(t, otherCountry)
+	assert.False(t, free)
+}
+
+func TestSQLiteApplyUniquenessScope(t *testing.T) {
+	// Arrange
+	db := setupSQLiteHelper(t)
+
+	global := NewSQLite(db)
+	require.NoError(t, global.Insert(context.TODO(), newMemoryUserHelper(t, "johndoe@foo.bar", "US")))
+	require.NoError(t, global.Insert(context.TODO(), newMemoryUserHelper(t, "janedoe@foo.bar", "BR")))
+
+	perCountry := NewSQLite(db, WithSQLiteUniquenessScope(ScopeCountry))
+
+	// Act
+	rekeyed, err := perCountry.ApplyUniquenessScope(context.TODO())
+	require.NoError(t, err)
+
+	again, err := perCountry.ApplyUniquenessScope(context.TODO())
+	require.NoError(t, err)
+
+	otherCountryErr := perCountry.Insert(context.TODO(), newMemoryUserHelper(t, "johndoe@foo.bar", "BR"))
+
+	// Assert
+	assert.Equal(t, int64(2), rekeyed)
+	assert.Zero(t, again)
+	assert.NoError(t, otherCountryErr)
+}
+
+func TestSQLiteDeleteAndCount(t *testing.T) {
+	// Arrange
+	repo := NewSQLite(setupSQLiteHelper(t))
+
+	john := newMemoryUserHelper(t, "johndoe@foo.bar", "US")
+	for _, user := range []*User{john, newMemoryUserHelper(t, "janedoe@foo.bar", "US"), newMemoryUserHelper(t, "jsmith@foo.bar", "BR")} {
+		require.NoError(t, repo.Insert(context.TODO(), user))
+	}
+
+	// Act
+	sequence, err := repo.Delete(context.TODO(), john.ID)
+	require.NoError(t, err)
+
+	_, notFoundErr := repo.Delete(context.TODO(), john.ID)
+
+	counts, err := repo.CountByCountry(context.TODO())
+	require.NoError(t, err)
+
+	// Assert
+	assert.Equal(t, int64(2), sequence)
+	assert.True(t, errors.Is(notFoundErr, ErrUserNotFound))
+	assert.Equal(t, map[string]int64{"BR": 1, "US": 1}, counts)
+}
+
+func TestSQLiteMerge(t *testing.T) {
+	// Arrange
+	repo := NewSQLite(setupSQLiteHelper(t))
+
+	survivor := newMemoryUserHelper(t, "joedoe@foo.bar", "BR")
+	duplicate := newMemoryUserHelper(t, "joe.doe@foo.bar", "BR")
+	require.NoError(t, repo.Insert(context.TODO(), survivor))
+	require.NoError(t, repo.Insert(context.TODO(), duplicate))
+
+	require.NoError(t, repo.LinkIdentity(context.TODO(), &LinkedIdentity{
+		Provider:  "google",
+		Subject:   "1234567890",
+		UserID:    duplicate.ID,
+		CreatedAt: time.Time{}.Add(1 * time.Second),
+	}))
+
+	lock := func(userID, field, reason string) *FieldLock {
+		return &FieldLock{UserID: userID, Field: field, Reason: reason, LockedBy: "admin-id", CreatedAt: time.Time{}.Add(1 * time.Second)}
+	}
+	require.NoError(t, repo.LockField(context.TODO(), lock(survivor.ID, "email", "investigation")))
+	require.NoError(t, repo.LockField(context.TODO(), lock(duplicate.ID, "email", "other investigation")))
+	require.NoError(t, repo.LockField(context.TODO(), lock(duplicate.ID, "nickname", "reserved")))
+
+	// The survivor takes over the duplicate email.
+	merged := *survivor
+	merged.Email = duplicate.Email
+
+	// Act
+	err := repo.Merge(context.TODO(), &merged, duplicate.ID)
+	notFoundErr := repo.Merge(context.TODO(), &merged, uuid.New().String())
+
+	// Assert
+	require.NoError(t, err)
+	assert.True(t, errors.Is(notFoundErr, ErrUserNotFound))
+
+	stored, err := repo.Get(context.TODO(), survivor.ID)
+	require.NoError(t, err)
+	assert.Equal(t, duplicate.Email, stored.Email)
+
+	_, err = repo.Get(context.TODO(), duplicate.ID)
+	assert.True(t, errors.Is(err, ErrUserNotFound))
+
+	linked, err := repo.GetByLinkedIdentity(context.TODO(), "google", "1234567890")
+	require.NoError(t, err)
+	assert.Equal(t, survivor.ID, linked.ID)
+
+	locks, err := repo.GetFieldLocks(context.TODO(), survivor.ID)
+	require.NoError(t, err)
+	require.Len(t, locks, 2)
+	assert.Equal(t, "investigation", locks[0].Reason)
+	assert.Equal(t, "nickname", locks[1].Field)
+}
+
+func TestSQLiteAnonymize(t *testing.T) {
+	// Arrange
+	repo := NewSQLite(setupSQLiteHelper(t))
+
+	user := newMemoryUserHelper(t, "joedoe@foo.bar", "BR")
+	duplicate := newMemoryUserHelper(t, "joe.doe@foo.bar", "BR")
+	require.NoError(t, repo.Insert(context.TODO(), user))
+	require.NoError(t, repo.Insert(context.TODO(), duplicate))
+	require.NoError(t, repo.Merge(context.TODO(), user, duplicate.ID))
+
+	require.NoError(t, repo.LinkIdentity(context.TODO(), &LinkedIdentity{
+		Provider:  "google",
+		Subject:   "1234567890",
+		UserID:    user.ID,
+		Email:     "joedoe@gmail.com",
+		CreatedAt: time.Time{}.Add(1 * time.Second),
+	}))
+
+	anonymized := *user
+	anonymized.FirstName = "Anonymized"
+	anonymized.Email = "anonymized@anonymized.invalid"
+
+	// Act
+	err := repo.Anonymize(context.TODO(), &anonymized)
+	notFoundErr := repo.Anonymize(context.TODO(), newMemoryUserHelper(t, "other@foo.bar", "BR"))
+
+	// Assert
+	require.NoError(t, err)
+	assert.True(t, errors.Is(notFoundErr, ErrUserNotFound))
+
+	stored, err := repo.Get(context.TODO(), user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "anonymized@anonymized.invalid", stored.Email)
+	assert.True(t, stored.Anonymized)
+	assert.Equal(t, anonymized.EventSequence, stored.EventSequence)
+
+	_, err = repo.GetByLinkedIdentity(context.TODO(), "google", "1234567890")
+	assert.True(t, errors.Is(err, ErrUserNotFound))
+
+	// The tombstone of the merged duplicate is overwritten too.
+	var email string
+	require.NoError(t, repo.db.Get(&email, "SELECT email FROM user_tombstones WHERE id = ?", duplicate.ID))
+	assert.Equal(t, "anonymized@anonymized.invalid", email)
+}
+
+func TestSQLiteSetStatus(t *testing.T) {
+	// Arrange
+	repo := NewSQLite(setupSQLiteHelper(t))
+
+	user := newMemoryUserHelper(t, "joedoe@foo.bar", "BR")
+	require.NoError(t, repo.Insert(context.TODO(), user))
+
+	suspended := *user
+	suspended.Status = StatusSuspended
+	suspended.UpdatedAt = time.Time{}.Add(1 * time.Hour)
+
+	// Act
+	err := repo.SetStatus(context.TODO(), &suspended)
+	require.NoError(t, err)
+
+	require.NoError(t, repo.Update(context.TODO(), user))
+
+	notFoundErr := repo.SetStatus(context.TODO(), &User{ID: uuid.New().String(), Status: StatusActive})
+
+	// Assert
+	assert.Equal(t, int64(2), suspended.EventSequence)
+	assert.True(t, errors.Is(notFoundErr, ErrUserNotFound))
+
+	stored, err := repo.Get(context.TODO(), user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusSuspended, stored.Status)
+	assert.Equal(t, int64(3), stored.EventSequence)
+}
+
+func TestSQLiteSetAvatar(t *testing.T) {
+	// Arrange
+	repo := NewSQLite(setupSQLiteHelper(t))
+
+	user := newMemoryUserHelper(t, "joedoe@foo.bar", "BR")
+	require.NoError(t, repo.Insert(context.TODO(), user))
+
+	withAvatar := *user
+	withAvatar.AvatarKey = "avatars/" + user.ID + "/abc"
+	withAvatar.AvatarHash = "abc"
+
+	// Act
+	err := repo.SetAvatar(context.TODO(), &withAvatar)
+	require.NoError(t, err)
+
+	require.NoError(t, repo.Update(context.TODO(), user))
+
+	notFoundErr := repo.SetAvatar(context.TODO(), &User{ID: uuid.New().String()})
+
+	// Assert
+	assert.Equal(t, int64(2), withAvatar.EventSequence)
+	assert.True(t, errors.Is(notFoundErr, ErrUserNotFound))
+
+	stored, err := repo.Get(context.TODO(), user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "avatars/"+user.ID+"/abc", stored.AvatarKey)
+	assert.Equal(t, "abc", stored.AvatarHash)
+
+	require.NoError(t, repo.Anonymize(context.TODO(), stored))
+
+	stored, err = repo.Get(context.TODO(), user.ID)
+	require.NoError(t, err)
+	assert.Empty(t, stored.AvatarKey)
+	assert.Empty(t, stored.AvatarHash)
+}
+
+func TestSQLitePhoneVerification(t *testing.T) {
+	// Arrange
+	repo := NewSQLite(setupSQLiteHelper(t))
+
+	user := newMemoryUserHelper(t, "joedoe@foo.bar", "BR")
+	require.NoError(t, repo.Insert(context.TODO(), user))
+
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	verification := func(codeHash string) *PhoneVerification {
+		return &PhoneVerification{
+			UserID:    user.ID,
+			Phone:     "+5511999999999",
+			CodeHash:  []byte(codeHash),
+			CreatedAt: now,
+			ExpiresAt: now.Add(10 * time.Minute),
+		}
+	}
+
+	// Act
+	unknownErr := repo.InsertPhoneVerification(context.TODO(), &PhoneVerification{UserID: uuid.New().String(), CodeHash: []byte("unknown"), CreatedAt: now, ExpiresAt: now})
+	_, notPendingErr := repo.AttemptPhoneVerification(context.TODO(), user.ID, now)
+
+	require.NoError(t, repo.InsertPhoneVerification(context.TODO(), verification("first")))
+	_, err := repo.AttemptPhoneVerification(context.TODO(), user.ID, now)
+	require.NoError(t, err)
+
+	require.NoError(t, repo.InsertPhoneVerification(context.TODO(), verification("second")))
+	_, err = repo.AttemptPhoneVerification(context.TODO(), user.ID, now)
+	require.NoError(t, err)
+
+	attempted, err := repo.AttemptPhoneVerification(context.TODO(), user.ID, now)
+	require.NoError(t, err)
+
+	_, expiredErr := repo.AttemptPhoneVerification(context.TODO(), user.ID, now.Add(10*time.Minute))
+
+	require.NoError(t, repo.DeletePhoneVerification(context.TODO(), user.ID))
+	_, deletedErr := repo.AttemptPhoneVerification(context.TODO(), user.ID, now)
+
+	user.Phone = "+5511999999999"
+	user.PhoneVerified = true
+	require.NoError(t, repo.Update(context.TODO(), user))
+
+	stored, err := repo.Get(context.TODO(), user.ID)
+	require.NoError(t, err)
+
+	// Assert
+	assert.True(t, errors.Is(unknownErr, ErrUserNotFound))
+	assert.True(t, errors.Is(notPendingErr, ErrPhoneVerificationNotFound))
+
+	// A pending verification is replaced, attempts included.
+	assert.Equal(t, []byte("second"), attempted.CodeHash)
+	assert.Equal(t, "+5511999999999", attempted.Phone)
+	assert.Equal(t, 2, attempted.Attempts)
+
+	assert.True(t, errors.Is(expiredErr, ErrPhoneVerificationNotFound))
+	assert.True(t, errors.Is(deletedErr, ErrPhoneVerificationNotFound))
+
+	assert.Equal(t, "+5511999999999", stored.Phone)
+	assert.True(t, stored.PhoneVerified)
+}
+
+func TestSQLiteMetadata(t *testing.T) {
+	// Arrange
+	repo := NewSQLite(setupSQLiteHelper(t))
+
+	withoutMetadata := newMemoryUserHelper(t, "janedoe@foo.bar", "BR")
+	require.NoError(t, repo.Insert(context.TODO(), withoutMetadata))
+
+	user := newMemoryUserHelper(t, "joedoe@foo.bar", "BR")
+	user.Metadata = Metadata{"theme": "dark", "marketing_opt_in": "true"}
+	require.NoError(t, repo.Insert(context.TODO(), user))
+
+	inserted, err := repo.Get(context.TODO(), user.ID)
+	require.NoError(t, err)
+
+	user.Metadata = Metadata{"locale": "pt-BR"}
+
+	// Act
+	err = repo.Update(context.TODO(), user)
+	require.NoError(t, err)
+
+	// Assert
+	assert.Equal(t, Metadata{"theme": "dark", "marketing_opt_in": "true"}, inserted.Metadata)
+
+	stored, err := repo.Get(context.TODO(), user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, Metadata{"locale": "pt-BR"}, stored.Metadata)
+
+	var encoded string
+	require.NoError(t, repo.db.Get(&encoded, "SELECT metadata FROM users WHERE id = ?", withoutMetadata.ID))
+	assert.Equal(t, "{}", encoded)
+
+	stored, err = repo.Get(context.TODO(), withoutMetadata.ID)
+	require.NoError(t, err)
+	assert.Nil(t, stored.Metadata)
+}
+
+func TestSQLiteBootstrap(t *testing.T) {
+	newKey := func(userID string) *APIKey {
+		return &APIKey{
+			ID:         uuid.New().String(),
+			UserID:     userID,
+			Name:       "terraform",
+			SecretHash: []byte("hash"),
+			CreatedAt:  time.Time{}.Add(1 * time.Second),
+		}
+	}
+
+	// Arrange
+	repo := NewSQLite(setupSQLiteHelper(t))
+
+	admin := newMemoryUserHelper(t, "admin@foo.bar", "BR")
+	adminKey := newKey(admin.ID)
+
+	// Act
+	err := repo.Bootstrap(context.TODO(), admin, adminKey)
+	require.NoError(t, err)
+
+	second := newMemoryUserHelper(t, "admin2@foo.bar", "BR")
+	secondErr := repo.Bootstrap(context.TODO(), second, newKey(second.ID))
+
+	// Assert
+	assert.True(t, errors.Is(secondErr, ErrAlreadyBootstrapped))
+
+	storedKey, err := repo.GetAPIKey(context.TODO(), adminKey.ID)
+	require.NoError(t, err)
+	assert.Equal(t, adminKey, storedKey)
+
+	_, err = repo.GetAPIKey(context.TODO(), uuid.New().String())
+	assert.True(t, errors.Is(err, ErrAPIKeyNotFound))
+
+	stored, err := repo.Get(context.TODO(), admin.ID)
+	require.NoError(t, err)
+	assert.Equal(t, RoleAdmin, stored.Role)
+}
+
+func TestSQLiteLinkedIdentities(t *testing.T) {
+	// Arrange
+	repo := NewSQLite(setupSQLiteHelper(t))
+	user := newMemoryUserHelper(t, "joedoe@foo.bar", "BR")
+	require.NoError(t, repo.Insert(context.TODO(), user))
+
+	identity := &LinkedIdentity{
+		Provider:  "google",
+		Subject:   "1234567890",
+		UserID:    user.ID,
+		Email:     "joedoe@gmail.com",
+		CreatedAt: time.Time{}.Add(1 * time.Second),
+	}
+
+	// Act
+	require.NoError(t, repo.LinkIdentity(context.TODO(), identity))
+
+	linkedErr := repo.LinkIdentity(context.TODO(), &LinkedIdentity{Provider: "google", Subject: "1234567890", UserID: user.ID})
+	unknownErr := repo.LinkIdentity(context.TODO(), &LinkedIdentity{Provider: "azure", Subject: "1234567890", UserID: uuid.New().String()})
+
+	identities, err := repo.GetLinkedIdentities(context.TODO(), user.ID)
+	require.NoError(t, err)
+
+	// Assert
+	assert.True(t, errors.Is(linkedErr, ErrIdentityLinked))
+	assert.True(t, errors.Is(unknownErr, ErrUserNotFound))
+	assert.Equal(t, []*LinkedIdentity{identity}, identities)
+
+	// Deleting the user deletes its identities.
+	_, err = repo.Delete(context.TODO(), user.ID)
+	require.NoError(t, err)
+
+	_, err = repo.GetByLinkedIdentity(context.TODO(), "google", "1234567890")
+	assert.True(t, errors.Is(err, ErrUserNotFound))
+}
+
+func TestSQLiteUnlinkIdentity(t *testing.T) {
+	// Arrange
+	repo := NewSQLite(setupSQLiteHelper(t))
+	user := newMemoryUserHelper(t, "joedoe@foo.bar", "BR")
+	require.NoError(t, repo.Insert(context.TODO(), user))
+
+	identity := &LinkedIdentity{
+		Provider:  "google",
+		Subject:   "1234567890",
+		UserID:    user.ID,
+		Email:     "joedoe@gmail.com",
+		CreatedAt: time.Now().UTC().Truncate(time.Millisecond),
+	}
+	require.NoError(t, repo.LinkIdentity(context.TODO(), identity))
+
+	// Act
+	otherUserErr := repo.UnlinkIdentity(context.TODO(), uuid.New().String(), "google", "1234567890")
+	require.NoError(t, repo.UnlinkIdentity(context.TODO(), user.ID, "google", "1234567890"))
+	unlinkAgainErr := repo.UnlinkIdentity(context.TODO(), user.ID, "google", "1234567890")
+
+	_, getErr := repo.GetByLinkedIdentity(context.TODO(), "google", "1234567890")
+
+	// Assert
+	assert.True(t, errors.Is(otherUserErr, ErrIdentityNotFound))
+	assert.True(t, errors.Is(unlinkAgainErr, ErrIdentityNotFound))
+	assert.True(t, errors.Is(getErr, ErrUserNotFound))
+
+	// The identity can be linked again once unlinked.
+	assert.NoError(t, repo.LinkIdentity(context.TODO(), identity))
+}
+
+func TestSQLiteLogins(t *testing.T) {
+	// Arrange
+	repo := NewSQLite(setupSQLiteHelper(t))
+	user := newMemoryUserHelper(t, "joedoe@foo.bar", "BR")
+	require.NoError(t, repo.Insert(context.TODO(), user))
+
+	for i, country := range []string{"BR", "PT", "DE"} {
+		require.NoError(t, repo.RecordLogin(context.TODO(), &Login{
+			UserID:    user.ID,
+			Country:   country,
+			CreatedAt: time.Time{}.Add(time.Duration(i) * time.Hour),
+		}))
+	}
+
+	// Act
+	unknownErr := repo.RecordLogin(context.TODO(), &Login{UserID: uuid.New().String()})
+
+	recent, err := repo.GetRecentLogins(context.TODO(), user.ID, 2)
+	require.NoError(t, err)
+
+	// Assert
+	assert.True(t, errors.Is(unknownErr, ErrUserNotFound))
+
+	// The most recent logins come first.
+	require.Len(t, recent, 2)
+	assert.Equal(t, "DE", recent[0].Country)
+	assert.Equal(t, "PT", recent[1].Country)
+}
+
+func TestSQLiteLockouts(t *testing.T) {
+	// Arrange
+	repo := NewSQLite(setupSQLiteHelper(t))
+	user := newMemoryUserHelper(t, "joedoe@foo.bar", "BR")
+	require.NoError(t, repo.Insert(context.TODO(), user))
+
+	until := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Act
+	_, unknownErr := repo.RecordFailedLogin(context.TODO(), uuid.New().String())
+
+	none, err := repo.GetLockout(context.TODO(), user.ID)
+	require.NoError(t, err)
+
+	_, err = repo.RecordFailedLogin(context.TODO(), user.ID)
+	require.NoError(t, err)
+
+	failed, err := repo.RecordFailedLogin(context.TODO(), user.ID)
+	require.NoError(t, err)
+
+	require.NoError(t, repo.LockUser(context.TODO(), user.ID, until))
+
+	locked, err := repo.GetLockout(context.TODO(), user.ID)
+	require.NoError(t, err)
+
+	_, err = repo.Delete(context.TODO(), user.ID)
+	require.NoError(t, err)
+
+	deleted, err := repo.GetLockout(context.TODO(), user.ID)
+	require.NoError(t, err)
+
+	// Assert
+	assert.True(t, errors.Is(unknownErr, ErrUserNotFound))
+	assert.Equal(t, &Lockout{UserID: user.ID}, none)
+	assert.Equal(t, 2, failed.FailedAttempts)
+	assert.True(t, failed.LockedUntil.IsZero())
+
+	// Locking starts over the count of failed logins.
+	assert.Equal(t, 0, locked.FailedAttempts)
+	assert.Equal(t, 1, locked.Lockouts)
+	assert.True(t, until.Equal(locked.LockedUntil))
+
+	// Deleting the user deletes its lockout.
+	assert.Equal(t, &Lockout{UserID: user.ID}, deleted)
+}
+
+func TestSQLiteResetPassword(t *testing.T) {
+	// Arrange
+	repo := NewSQLite(setupSQLiteHelper(t))
+	user := newMemoryUserHelper(t, "joedoe@foo.bar", "BR")
+	require.NoError(t, repo.Insert(context.TODO(), user))
+
+	now := time.Now()
+	for _, token := range []*PasswordResetToken{
+		{TokenHash: []byte("valid"), UserID: user.ID, CreatedAt: now, ExpiresAt: now.Add(time.Hour)},
+		{TokenHash: []byte("other"), UserID: user.ID, CreatedAt: now, ExpiresAt: now.Add(time.Hour)},
+		{TokenHash: []byte("expired"), UserID: user.ID, CreatedAt: now, ExpiresAt: now.Add(time.Minute)},
+	} {
+		require.NoError(t, repo.InsertPasswordResetToken(context.TODO(), token))
+	}
+
+	// Act
+	unknownErr := repo.InsertPasswordResetToken(context.TODO(), &PasswordResetToken{TokenHash: []byte("unknown"), UserID: uuid.New().String()})
+	_, expiredErr := repo.ResetPassword(context.TODO(), []byte("expired"), "new-password", now.Add(2*time.Minute))
+
+	reset, err := repo.ResetPassword(context.TODO(), []byte("valid"), "new-password", now.Add(2*time.Minute))
+	require.NoError(t, err)
+
+	_, reusedErr := repo.ResetPassword(context.TODO(), []byte("valid"), "new-password", now.Add(2*time.Minute))
+	_, otherErr := repo.ResetPassword(context.TODO(), []byte("other"), "new-password", now.Add(2*time.Minute))
+
+	// Assert
+	assert.True(t, errors.Is(unknownErr, ErrUserNotFound))
+	assert.True(t, errors.Is(expiredErr, ErrResetTokenNotFound))
+
+	assert.Equal(t, "new-password", reset.Password)
+	assert.Equal(t, int64(2), reset.EventSequence)
+	assert.True(t, reset.UpdatedAt.Equal(now.Add(2*time.Minute)))
+
+	// Tokens are single use and a reset revokes the other tokens of the user.
+	assert.True(t, errors.Is(reusedErr, ErrResetTokenNotFound))
+	assert.True(t, errors.Is(otherErr, ErrResetTokenNotFound))
+}
+
+func TestSQLiteAPIKeys(t *testing.T) {
+	// Arrange
+	repo := NewSQLite(setupSQLiteHelper(t))
+	user := newMemoryUserHelper(t, "joedoe@foo.bar", "BR")
+	require.NoError(t, repo.Insert(context.TODO(), user))
+
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	older := &APIKey{ID: uuid.New().String(), UserID: user.ID, Name: "older", SecretHash: []byte("older"), Scopes: "users:read", CreatedAt: now}
+	newer := &APIKey{ID: uuid.New().String(), UserID: user.ID, Name: "newer", SecretHash: []byte("newer"), Scopes: "users:read users:write", CreatedAt: now.Add(time.Hour)}
+
+	// Act
+	unknownErr := repo.InsertAPIKey(context.TODO(), &APIKey{ID: uuid.New().String(), UserID: uuid.New().String(), Name: "unknown", SecretHash: []byte("unknown"), CreatedAt: now})
+
+	for _, key := range []*APIKey{newer, older} {
+		require.NoError(t, repo.InsertAPIKey(context.TODO(), key))
+	}
+
+	keys, err := repo.GetAPIKeys(context.TODO(), user.ID)
+	require.NoError(t, err)
+
+	stored, err := repo.GetAPIKey(context.TODO(), newer.ID)
+	require.NoError(t, err)
+
+	require.NoError(t, repo.DeleteAPIKey(context.TODO(), newer.ID))
+	_, deletedErr := repo.GetAPIKey(context.TODO(), newer.ID)
+	deleteAgainErr := repo.DeleteAPIKey(context.TODO(), newer.ID)
+
+	_, err = repo.Delete(context.TODO(), user.ID)
+	require.NoError(t, err)
+	_, userDeletedErr := repo.GetAPIKey(context.TODO(), older.ID)
+
+	// Assert
+	assert.True(t, errors.Is(unknownErr, ErrUserNotFound))
+
+	// The oldest keys come first.
+	require.Len(t, keys, 2)
+	assert.Equal(t, older.ID, keys[0].ID)
+	assert.Equal(t, newer.ID, keys[1].ID)
+
+	assert.Equal(t, "users:read users:write", stored.Scopes)
+	assert.Equal(t, []byte("newer"), stored.SecretHash)
+
+	assert.True(t, errors.Is(deletedErr, ErrAPIKeyNotFound))
+	assert.True(t, errors.Is(deleteAgainErr, ErrAPIKeyNotFound))
+
+	// Deleting the user deletes its keys.
+	assert.True(t, errors.Is(userDeletedErr, ErrAPIKeyNotFound))
+}
+
+func TestSQLiteSessions(t *testing.T) {
+	// Arrange
+	repo := NewSQLite(setupSQLiteHelper(t))
+	user := newMemoryUserHelper(t, "joedoe@foo.bar", "BR")
+	require.NoError(t, repo.Insert(context.TODO(), user))
+
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	expired := &Session{ID: uuid.New().String(), UserID: user.ID, AccessTokenHash: []byte("access"), RefreshTokenHash: []byte("expired"), CreatedAt: now, ExpiresAt: now.Add(time.Hour)}
+	older := &Session{ID: uuid.New().String(), UserID: user.ID, AccessTokenHash: []byte("access"), RefreshTokenHash: []byte("older"), CreatedAt: now.Add(time.Hour), ExpiresAt: now.Add(48 * time.Hour)}
+	newer := &Session{ID: uuid.New().String(), UserID: user.ID, AccessTokenHash: []byte("access"), RefreshTokenHash: []byte("newer"), CreatedAt: now.Add(2 * time.Hour), ExpiresAt: now.Add(48 * time.Hour)}
+
+	// Act
+	unknownErr := repo.InsertSession(context.TODO(), &Session{ID: uuid.New().String(), UserID: uuid.New().String(), AccessTokenHash: []byte("unknown"), RefreshTokenHash: []byte("unknown")})
+
+	for _, session := range []*Session{expired, older, newer} {
+		require.NoError(t, repo.InsertSession(context.TODO(), session))
+	}
+
+	sessions, err := repo.GetSessions(context.TODO(), user.ID)
+	require.NoError(t, err)
+
+	rotated := *older
+	rotated.AccessTokenHash = []byte("rotated-access")
+	rotated.RefreshTokenHash = []byte("rotated")
+	rotated.ExpiresAt = now.Add(72 * time.Hour)
+	require.NoError(t, repo.RotateSession(context.TODO(), &rotated, []byte("older")))
+
+	reusedErr := repo.RotateSession(context.TODO(), &rotated, []byte("older"))
+
+	stored, err := repo.GetSession(context.TODO(), older.ID)
+	require.NoError(t, err)
+
+	require.NoError(t, repo.DeleteSession(context.TODO(), newer.ID))
+	deleteAgainErr := repo.DeleteSession(context.TODO(), newer.ID)
+
+	_, err = repo.Delete(context.TODO(), user.ID)
+	require.NoError(t, err)
+	_, userDeletedErr := repo.GetSession(context.TODO(), older.ID)
+
+	// Assert
+	assert.True(t, errors.Is(unknownErr, ErrUserNotFound))
+
+	// Inserting a session deletes the expired ones of the user, and the most recent come first.
+	require.Len(t, sessions, 2)
+	assert.Equal(t, newer.ID, sessions[0].ID)
+	assert.Equal(t, older.ID, sessions[1].ID)
+
+	// A refresh token can only be rotated once.
+	assert.True(t, errors.Is(reusedErr, ErrSessionNotFound))
+	assert.Equal(t, []byte("rotated"), stored.RefreshTokenHash)
+	assert.Equal(t, []byte("rotated-access"), stored.AccessTokenHash)
+	assert.True(t, rotated.ExpiresAt.Equal(stored.ExpiresAt))
+
+	assert.True(t, errors.Is(deleteAgainErr, ErrSessionNotFound))
+
+	// Deleting the user deletes its sessions.
+	assert.True(t, errors.Is(userDeletedErr, ErrSessionNotFound))
+}
+
+// setupSQLiteHelper opens an in-memory SQLite database with the SQLite migrations applied.
+// The goose dialect is global, so it is set back to Postgres for the other tests.
+func TestSQLiteTOTP(t *testing.T) {
+	// Arrange
+	repo := NewSQLite(setupSQLiteHelper(t))
+	user := newMemoryUserHelper(t, "joedoe@foo.bar", "BR")
+	require.NoError(t, repo.Insert(context.TODO(), user))
+
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Act
+	unknownErr := repo.InsertTOTP(context.TODO(), &TOTP{UserID: uuid.New().String(), Secret: []byte("unknown"), CreatedAt: now})
+	_, notEnrolledErr := repo.GetTOTP(context.TODO(), user.ID)
+
+	require.NoError(t, repo.InsertTOTP(context.TODO(), &TOTP{UserID: user.ID, Secret: []byte("first"), CreatedAt: now}))
+	require.NoError(t, repo.InsertTOTP(context.TODO(), &TOTP{UserID: user.ID, Secret: []byte("second"), CreatedAt: now}))
+
+	pending, err := repo.GetTOTP(context.TODO(), user.ID)
+	require.NoError(t, err)
+
+	require.NoError(t, repo.UseTOTP(context.TODO(), user.ID, 100))
+	replayedErr := repo.UseTOTP(context.TODO(), user.ID, 100)
+	olderErr := repo.UseTOTP(context.TODO(), user.ID, 99)
+	require.NoError(t, repo.UseTOTP(context.TODO(), user.ID, 101))
+
+	enabledErr := repo.InsertTOTP(context.TODO(), &TOTP{UserID: user.ID, Secret: []byte("third"), CreatedAt: now})
+
+	enabled, err := repo.GetTOTP(context.TODO(), user.ID)
+	require.NoError(t, err)
+
+	_, err = repo.Delete(context.TODO(), user.ID)
+	require.NoError(t, err)
+	_, userDeletedErr := repo.GetTOTP(context.TODO(), user.ID)
+
+	// Assert
+	assert.True(t, errors.Is(unknownErr, ErrUserNotFound))
+	assert.True(t, errors.Is(notEnrolledErr, ErrTOTPNotFound))
+
+	// A pending enrollment is replaced.
+	assert.Equal(t, []byte("second"), pending.Secret)
+	assert.False(t, pending.Enabled)
+
+	// Codes can't be replayed, and using one enables TOTP for good.
+	assert.True(t, errors.Is(replayedErr, ErrTOTPCodeUsed))
+	assert.True(t, errors.Is(olderErr, ErrTOTPCodeUsed))
+	assert.True(t, errors.Is(enabledErr, ErrTOTPEnabled))
+	assert.Equal(t, []byte("second"), enabled.Secret)
+	assert.True(t, enabled.Enabled)
+	assert.Equal(t, int64(101), enabled.LastUsedStep)
+
+	// Deleting the user deletes its TOTP.
+	assert.True(t, errors.Is(userDeletedErr, ErrTOTPNotFound))
+}
+
+func setupSQLiteHelper(t *testing.T) *sqlx.DB {
+	t.Helper()
+
+	db, err := OpenSQLite(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	require.NoError(t, goose.SetDialect(SQLiteDriverName))
+	defer goose.SetDialect("postgres")
+
+	require.NoError(t, goose.Up(db.DB, sqliteMigrationsDir))
+	return db
+}
@@ -0,0 +1,648 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+var _ Store = (*Residency)(nil)
+
+// ErrRegionChange is returned when a write would move a user to another data region.
+var ErrRegionChange = errors.New("user cannot be moved to another data region")
+
+// Region is a data region: the users of its countries are only stored in its store.
+type Region struct {
+	Name      string
+	Store     Store
+	Countries []string
+}
+
+// Residency is a Store routing the users to the store of the region of their country,
+// for data residency requirements (e.g. EU users stored in the EU). The users of the
+// countries without a region are stored in the default store.
+//
+// Writes go to the region of the user. Reads by country go to its region, and the
+// other reads are federated: the stores are queried in turn, and pages are merged.
+// Users can't change region, since their data would have to be moved across stores.
+//
+// Uniqueness is enforced by each store. With the global scope, the email is also checked
+// in the other regions on insert, but the nickname is only unique within a region. The
+// other scopes never span regions.
+type Residency struct {
+	stores    []Store
+	byCountry map[string]Store
+	def       Store
+	scope     UniquenessScope
+}
+
+// NewResidency creates a store routing the users to the regions by country. The scope
+// must be the uniqueness scope of the stores.
+func NewResidency(scope UniquenessScope, def Store, regions ...Region) *Residency {
+	r := Residency{
+		stores:    []Store{def},
+		byCountry: make(map[string]Store),
+		def:       def,
+		scope:     scope,
+	}
+
+	for _, region := range regions {
+		r.stores = append(r.stores, region.Store)
+		for _, country := range region.Countries {
+			r.byCountry[country] = region.Store
+		}
+	}
+	return &r
+}
+
+// storeFor returns the store of the region of the country.
+func (r *Residency) storeFor(country string) Store {
+	if store, ok := r.byCountry[country]; ok {
+		return store
+	}
+	return r.def
+}
+
+// locate returns the user and the store of its region.
+func (r *Residency) locate(ctx context.Context, id string) (*User, Store, error) {
+	for _, store := range r.stores {
+		user, err := store.Get(ctx, id)
+		if errors.Is(err, ErrUserNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		return user, store, nil
+	}
+	return nil, nil, fmt.Errorf("could not locate user: %w", ErrUserNotFound)
+}
+
+// Get returns a user by id from the region storing it.
+func (r *Residency) Get(ctx context.Context, id string) (*User, error) {
+	user, _, err := r.locate(ctx, id)
+	return user, err
+}
+
+// GetByEmail returns a user by email from the region storing it. Unless the email is
+// globally unique, every region is queried to tell whether the email is ambiguous.
+func (r *Residency) GetByEmail(ctx context.Context, email string) (*User, error) {
+	var users []*User
+	for _, store := range r.stores {
+		user, err := store.GetByEmail(ctx, email)
+		if errors.Is(err, ErrUserNotFound) {
+			continue
+		}
+		if err != nil || r.scope == ScopeGlobal {
+			return user, err
+		}
+		users = append(users, user)
+	}
+
+	user, err := oneByEmail(users)
+	if err != nil {
+		return nil, fmt.Errorf("could not get user by email: %w", err)
+	}
+	return user, nil
+}
+
+// List returns a page of the users selected by the filter, from the region of the
+// country when the filter has one and from every region otherwise.
+func (r *Residency) List(ctx context.Context, filter Filter, page Page) ([]*User, error) {
+	if filter.Country != "" {
+		return r.storeFor(filter.Country).List(ctx, filter, page)
+	}
+
+	return r.federate(page.Limit, func(store Store) ([]*User, error) {
+		return store.List(ctx, filter, page)
+	})
+}
+
+// Count returns the number of users selected by the filter, in the region of the country
+// when the filter has one and in every region otherwise.
+func (r *Residency) Count(ctx context.Context, filter Filter) (int64, error) {
+	if filter.Country != "" {
+		return r.storeFor(filter.Country).Count(ctx, filter)
+	}
+
+	var total int64
+	for _, store := range r.stores {
+		count, err := store.Count(ctx, filter)
+		if err != nil {
+			return 0, err
+		}
+		total += count
+	}
+	return total, nil
+}
+
+// NicknameExists reports whether a user has the nickname within the uniqueness scope of the
+// country: in the region of the country when the nicknames are unique per country, and in
+// every region otherwise.
+func (r *Residency) NicknameExists(ctx context.Context, nickname, country string) (bool, error) {
+	if r.scope == ScopeCountry {
+		return r.storeFor(country).NicknameExists(ctx, nickname, country)
+	}
+
+	for _, store := range r.stores {
+		exists, err := store.NicknameExists(ctx, nickname, country)
+		if err != nil || exists {
+			return exists, err
+		}
+	}
+	return false, nil
+}
+
+// Search returns the users matching the query from every region. The ranks of different
+// regions can't be compared, so the results of each region follow the previous region's.
+func (r *Residency) Search(ctx context.Context, query string, offset, limit int) ([]*User, error) {
+	var users []*User
+	for _, store := range r.stores {
+		page, err := store.Search(ctx, query, 0, offset+limit)
+		if err != nil {
+			return nil, err
+		}
+
+		users = append(users, page...)
+		if len(users) >= offset+limit {
+			break
+		}
+	}
+
+	if offset >= len(users) {
+		return nil, nil
+	}
+
+	users = users[offset:]
+	if len(users) > limit {
+		users = users[:limit]
+	}
+	return users, nil
+}
+
+// Insert inserts the user in the region of its country.
+func (r *Residency) Insert(ctx context.Context, user *User) error {
+	if err := r.checkEmail(ctx, user); err != nil {
+		return fmt.Errorf("could not insert user: %w", err)
+	}
+	return r.storeFor(user.Country).Insert(ctx, user)
+}
+
+// InsertBatch inserts the users in the regions of their countries, with a batch per region.
+// Each region inserts all of its users or none, but the regions are not written atomically.
+func (r *Residency) InsertBatch(ctx context.Context, users []*User) error {
+	byStore := make(map[Store][]*User)
+	for _, user := range users {
+		if err := r.checkEmail(ctx, user); err != nil {
+			return fmt.Errorf("could not insert users: %w", err)
+		}
+
+		store := r.storeFor(user.Country)
+		byStore[store] = append(byStore[store], user)
+	}
+
+	for _, store := range r.stores {
+		if regionUsers, ok := byStore[store]; ok {
+			if err := store.InsertBatch(ctx, regionUsers); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Update updates the user in its region. It fails with ErrRegionChange if the new
+// country belongs to another region.
+func (r *Residency) Update(ctx context.Context, user *User) error {
+	_, store, err := r.locate(ctx, user.ID)
+	if err != nil {
+		return fmt.Errorf("could not update user: %w", err)
+	}
+
+	if r.storeFor(user.Country) != store {
+		return fmt.Errorf("could not update user: %w", ErrRegionChange)
+	}
+
+	if err := r.checkEmail(ctx, user); err != nil {
+		return fmt.Errorf("could not update user: %w", err)
+	}
+	return store.Update(ctx, user)
+}
+
+// Delete deletes the user from its region.
+func (r *Residency) Delete(ctx context.Context, id string) (int64, error) {
+	_, store, err := r.locate(ctx, id)
+	if err != nil {
+		return 0, fmt.Errorf("could not delete user: %w", err)
+	}
+	return store.Delete(ctx, id)
+}
+
+// Merge merges the duplicate into the survivor. Both must be stored in the survivor's region.
+func (r *Residency) Merge(ctx context.Context, survivor *User, duplicateID string) error {
+	_, store, err := r.locate(ctx, survivor.ID)
+	if err != nil {
+		return fmt.Errorf("could not merge users: %w", err)
+	}
+
+	_, duplicateStore, err := r.locate(ctx, duplicateID)
+	if err != nil {
+		return fmt.Errorf("could not merge users: %w", err)
+	}
+
+	if duplicateStore != store || r.storeFor(survivor.Country) != store {
+		return fmt.Errorf("could not merge users: %w", ErrRegionChange)
+	}
+	return store.Merge(ctx, survivor, duplicateID)
+}
+
+// Anonymize anonymizes the user in its region.
+func (r *Residency) Anonymize(ctx context.Context, user *User) error {
+	_, store, err := r.locate(ctx, user.ID)
+	if err != nil {
+		return fmt.Errorf("could not anonymize user: %w", err)
+	}
+	return store.Anonymize(ctx, user)
+}
+
+// SetStatus sets the status of the user in its region.
+func (r *Residency) SetStatus(ctx context.Context, user *User) error {
+	_, store, err := r.locate(ctx, user.ID)
+	if err != nil {
+		return fmt.Errorf("could not set user status: %w", err)
+	}
+	return store.SetStatus(ctx, user)
+}
+
+// SetAvatar sets the avatar key and hash of the user in its region.
+func (r *Residency) SetAvatar(ctx context.Context, user *User) error {
+	_, store, err := r.locate(ctx, user.ID)
+	if err != nil {
+		return fmt.Errorf("could not set user avatar: %w", err)
+	}
+	return store.SetAvatar(ctx, user)
+}
+
+// Bootstrap creates the admin user and its API key in the region of the admin country.
+// Only that region is checked for an existing admin.
+func (r *Residency) Bootstrap(ctx context.Context, admin *User, key *APIKey) error {
+	return r.storeFor(admin.Country).Bootstrap(ctx, admin, key)
+}
+
+// InsertAPIKey inserts the API key in the user's region.
+func (r *Residency) InsertAPIKey(ctx context.Context, key *APIKey) error {
+	_, store, err := r.locate(ctx, key.UserID)
+	if err != nil {
+		return fmt.Errorf("could not insert api key: %w", err)
+	}
+	return store.InsertAPIKey(ctx, key)
+}
+
+// GetAPIKey returns an API key by id from the region storing it.
+func (r *Residency) GetAPIKey(ctx context.Context, id string) (*APIKey, error) {
+	for _, store := range r.stores {
+		key, err := store.GetAPIKey(ctx, id)
+		if errors.Is(err, ErrAPIKeyNotFound) {
+			continue
+		}
+		return key, err
+	}
+	return nil, fmt.Errorf("could not get api key: %w", ErrAPIKeyNotFound)
+}
+
+// GetAPIKeys returns the API keys of the user from the user's region.
+func (r *Residency) GetAPIKeys(ctx context.Context, userID string) ([]*APIKey, error) {
+	_, store, err := r.locate(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get api keys: %w", err)
+	}
+	return store.GetAPIKeys(ctx, userID)
+}
+
+// DeleteAPIKey deletes the API key from the region storing it.
+func (r *Residency) DeleteAPIKey(ctx context.Context, id string) error {
+	for _, store := range r.stores {
+		err := store.DeleteAPIKey(ctx, id)
+		if errors.Is(err, ErrAPIKeyNotFound) {
+			continue
+		}
+		return err
+	}
+	return fmt.Errorf("could not delete api key: %w", ErrAPIKeyNotFound)
+}
+
+// LinkIdentity links the identity to the user in the user's region.
+func (r *Residency) LinkIdentity(ctx context.Context, identity *LinkedIdentity) error {
+	_, store, err := r.locate(ctx, identity.UserID)
+	if err != nil {
+		return fmt.Errorf("could not link identity: %w", err)
+	}
+	return store.LinkIdentity(ctx, identity)
+}
+
+// GetLinkedIdentities returns the identities linked to the user from the user's region.
+func (r *Residency) GetLinkedIdentities(ctx context.Context, userID string) ([]*LinkedIdentity, error) {
+	_, store, err := r.locate(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get linked identities: %w", err)
+	}
+	return store.GetLinkedIdentities(ctx, userID)
+}
+
+// GetByLinkedIdentity returns the user the identity is linked to from the region storing it.
+func (r *Residency) GetByLinkedIdentity(ctx context.Context, provider, subject string) (*User, error) {
+	for _, store := range r.stores {
+		user, err := store.GetByLinkedIdentity(ctx, provider, subject)
+		if errors.Is(err, ErrUserNotFound) {
+			continue
+		}
+		return user, err
+	}
+	return nil, fmt.Errorf("could not get user by linked identity: %w", ErrUserNotFound)
+}
+
+// UnlinkIdentity unlinks the identity from the user in the user's region.
+func (r *Residency) UnlinkIdentity(ctx context.Context, userID, provider, subject string) error {
+	_, store, err := r.locate(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("could not unlink identity: %w", err)
+	}
+	return store.UnlinkIdentity(ctx, userID, provider, subject)
+}
+
+// LockField locks the field in the user's region.
+func (r *Residency) LockField(ctx context.Context, lock *FieldLock) error {
+	_, store, err := r.locate(ctx, lock.UserID)
+	if err != nil {
+		return fmt.Errorf("could not lock field: %w", err)
+	}
+	return store.LockField(ctx, lock)
+}
+
+// UnlockField unlocks the field in the user's region.
+func (r *Residency) UnlockField(ctx context.Context, userID, field string) error {
+	_, store, err := r.locate(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("could not unlock field: %w", err)
+	}
+	return store.UnlockField(ctx, userID, field)
+}
+
+// GetFieldLocks returns the locked fields of the user from the user's region.
+func (r *Residency) GetFieldLocks(ctx context.Context, userID string) ([]*FieldLock, error) {
+	_, store, err := r.locate(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get field locks: %w", err)
+	}
+	return store.GetFieldLocks(ctx, userID)
+}
+
+// RecordLogin records the login in the user's region.
+func (r *Residency) RecordLogin(ctx context.Context, login *Login) error {
+	_, store, err := r.locate(ctx, login.UserID)
+	if err != nil {
+		return fmt.Errorf("could not record login: %w", err)
+	}
+	return store.RecordLogin(ctx, login)
+}
+
+// GetRecentLogins returns the recent logins of the user from the user's region.
+func (r *Residency) GetRecentLogins(ctx context.Context, userID string, limit int) ([]*Login, error) {
+	_, store, err := r.locate(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get recent logins: %w", err)
+	}
+	return store.GetRecentLogins(ctx, userID, limit)
+}
+
+// GetLockout returns the failed logins of the user from the user's region.
+func (r *Residency) GetLockout(ctx context.Context, userID string) (*Lockout, error) {
+	_, store, err := r.locate(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get lockout: %w", err)
+	}
+	return store.GetLockout(ctx, userID)
+}
+
+// RecordFailedLogin records the failed login in the user's region.
+func (r *Residency) RecordFailedLogin(ctx context.Context, userID string) (*Lockout, error) {
+	_, store, err := r.locate(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("could not record failed login: %w", err)
+	}
+	return store.RecordFailedLogin(ctx, userID)
+}
+
+// LockUser locks the user out in the user's region.
+func (r *Residency) LockUser(ctx context.Context, userID string, until time.Time) error {
+	_, store, err := r.locate(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("could not lock user: %w", err)
+	}
+	return store.LockUser(ctx, userID, until)
+}
+
+// ResetLockout resets the lockout in the user's region.
+func (r *Residency) ResetLockout(ctx context.Context, userID string) error {
+	_, store, err := r.locate(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("could not reset lockout: %w", err)
+	}
+	return store.ResetLockout(ctx, userID)
+}
+
+// InsertSession inserts the session in the user's region.
+func (r *Residency) InsertSession(ctx context.Context, session *Session) error {
+	_, store, err := r.locate(ctx, session.UserID)
+	if err != nil {
+		return fmt.Errorf("could not insert session: %w", err)
+	}
+	return store.InsertSession(ctx, session)
+}
+
+// GetSession returns a session by id from the region storing it.
+func (r *Residency) GetSession(ctx context.Context, id string) (*Session, error) {
+	for _, store := range r.stores {
+		session, err := store.GetSession(ctx, id)
+		if errors.Is(err, ErrSessionNotFound) {
+			continue
+		}
+		return session, err
+	}
+	return nil, fmt.Errorf("could not get session: %w", ErrSessionNotFound)
+}
+
+// GetSessions returns the sessions of the user from the user's region.
+func (r *Residency) GetSessions(ctx context.Context, userID string) ([]*Session, error) {
+	_, store, err := r.locate(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get sessions: %w", err)
+	}
+	return store.GetSessions(ctx, userID)
+}
+
+// RotateSession rotates the session in the user's region.
+func (r *Residency) RotateSession(ctx context.Context, session *Session, refreshTokenHash []byte) error {
+	_, store, err := r.locate(ctx, session.UserID)
+	if err != nil {
+		return fmt.Errorf("could not rotate session: %w", err)
+	}
+	return store.RotateSession(ctx, session, refreshTokenHash)
+}
+
+// DeleteSession deletes the session from the region storing it.
+func (r *Residency) DeleteSession(ctx context.Context, id string) error {
+	for _, store := range r.stores {
+		err := store.DeleteSession(ctx, id)
+		if errors.Is(err, ErrSessionNotFound) {
+			continue
+		}
+		return err
+	}
+	return fmt.Errorf("could not delete session: %w", ErrSessionNotFound)
+}
+
+// InsertTOTP inserts the TOTP enrollment in the user's region.
+func (r *Residency) InsertTOTP(ctx context.Context, totp *TOTP) error {
+	_, store, err := r.locate(ctx, totp.UserID)
+	if err != nil {
+		return fmt.Errorf("could not insert totp: %w", err)
+	}
+	return store.InsertTOTP(ctx, totp)
+}
+
+// GetTOTP returns the TOTP enrollment of the user from the user's region.
+func (r *Residency) GetTOTP(ctx context.Context, userID string) (*TOTP, error) {
+	_, store, err := r.locate(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get totp: %w", err)
+	}
+	return store.GetTOTP(ctx, userID)
+}
+
+// UseTOTP records the use of the code in the user's region.
+func (r *Residency) UseTOTP(ctx context.Context, userID string, step int64) error {
+	_, store, err := r.locate(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("could not use totp: %w", err)
+	}
+	return store.UseTOTP(ctx, userID, step)
+}
+
+// InsertPhoneVerification inserts the phone verification in the user's region.
+func (r *Residency) InsertPhoneVerification(ctx context.Context, verification *PhoneVerification) error {
+	_, store, err := r.locate(ctx, verification.UserID)
+	if err != nil {
+		return fmt.Errorf("could not insert phone verification: %w", err)
+	}
+	return store.InsertPhoneVerification(ctx, verification)
+}
+
+// AttemptPhoneVerification counts the attempt in the user's region.
+func (r *Residency) AttemptPhoneVerification(ctx context.Context, userID string, now time.Time) (*PhoneVerification, error) {
+	_, store, err := r.locate(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("could not attempt phone verification: %w", err)
+	}
+	return store.AttemptPhoneVerification(ctx, userID, now)
+}
+
+// DeletePhoneVerification deletes the phone verification in the user's region.
+func (r *Residency) DeletePhoneVerification(ctx context.Context, userID string) error {
+	_, store, err := r.locate(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("could not delete phone verification: %w", err)
+	}
+	return store.DeletePhoneVerification(ctx, userID)
+}
+
+// InsertPasswordResetToken inserts the token in the user's region.
+func (r *Residency) InsertPasswordResetToken(ctx context.Context, token *PasswordResetToken) error {
+	_, store, err := r.locate(ctx, token.UserID)
+	if err != nil {
+		return fmt.Errorf("could not insert password reset token: %w", err)
+	}
+	return store.InsertPasswordResetToken(ctx, token)
+}
+
+// ResetPassword consumes the token in the region storing it.
+func (r *Residency) ResetPassword(ctx context.Context, tokenHash []byte, password string, now time.Time) (*User, error) {
+	for _, store := range r.stores {
+		user, err := store.ResetPassword(ctx, tokenHash, password, now)
+		if errors.Is(err, ErrResetTokenNotFound) {
+			continue
+		}
+		return user, err
+	}
+	return nil, fmt.Errorf("could not reset password: %w", ErrResetTokenNotFound)
+}
+
+// CountByCountry returns the number of users per country across the regions.
+func (r *Residency) CountByCountry(ctx context.Context) (map[string]int64, error) {
+	counts := make(map[string]int64)
+	for _, store := range r.stores {
+		regionCounts, err := store.CountByCountry(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		for country, count := range regionCounts {
+			counts[country] += count
+		}
+	}
+	return counts, nil
+}
+
+// CheckDatabaseHealth checks the health of every region.
+func (r *Residency) CheckDatabaseHealth(ctx context.Context) error {
+	var errs []error
+	for _, store := range r.stores {
+		if err := store.CheckDatabaseHealth(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// checkEmail returns ErrDuplicateEmail if another user of any region uses the user's email.
+// It only checks the global scope, since the other scopes are enforced by the regions.
+func (r *Residency) checkEmail(ctx context.Context, user *User) error {
+	if r.scope != ScopeGlobal {
+		return nil
+	}
+
+	existing, err := r.GetByEmail(ctx, user.Email)
+	switch {
+	case errors.Is(err, ErrUserNotFound):
+		return nil
+	case err != nil:
+		return err
+	case existing.ID != user.ID:
+		return ErrDuplicateEmail
+	default:
+		return nil
+	}
+}
+
+// federate merges the pages ordered by id returned by every region into a single page.
+func (r *Residency) federate(limit int, page func(store Store) ([]*User, error)) ([]*User, error) {
+	var users []*User
+	for _, store := range r.stores {
+		regionUsers, err := page(store)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, regionUsers...)
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].ID < users[j].ID
+	})
+
+	if len(users) > limit {
+		users = users[:limit]
+	}
+	return users, nil
+}
@@ -0,0 +1,169 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newResidencyHelper(t *testing.T) (*Residency, *Memory, *Memory) {
+	t.Helper()
+
+	def, eu := NewMemory(), NewMemory()
+	return NewResidency(ScopeGlobal, def, Region{Name: "eu", Store: eu, Countries: []string{"DE", "FR"}}), def, eu
+}
+
+func TestResidencyInsert(t *testing.T) {
+	t.Parallel()
+
+	t.Run("routes the users by country", func(t *testing.T) {
+		// Arrange
+		store, def, eu := newResidencyHelper(t)
+		euUser := newMemoryUserHelper(t, "hans@foo.bar", "DE")
+		usUser := newMemoryUserHelper(t, "joe@foo.bar", "US")
+
+		// Act
+		require.NoError(t, store.Insert(context.TODO(), euUser))
+		require.NoError(t, store.Insert(context.TODO(), usUser))
+
+		// Assert
+		_, err := eu.Get(context.TODO(), euUser.ID)
+		assert.NoError(t, err)
+		_, err = def.Get(context.TODO(), euUser.ID)
+		assert.True(t, errors.Is(err, ErrUserNotFound))
+
+		_, err = def.Get(context.TODO(), usUser.ID)
+		assert.NoError(t, err)
+
+		observed, err := store.Get(context.TODO(), euUser.ID)
+		require.NoError(t, err)
+		assert.Equal(t, euUser.Email, observed.Email)
+	})
+
+	t.Run("email is unique across regions", func(t *testing.T) {
+		// Arrange
+		store, _, _ := newResidencyHelper(t)
+		require.NoError(t, store.Insert(context.TODO(), newMemoryUserHelper(t, "joe@foo.bar", "US")))
+
+		// Act
+		err := store.Insert(context.TODO(), newMemoryUserHelper(t, "joe@foo.bar", "FR"))
+
+		// Assert
+		assert.True(t, errors.Is(err, ErrDuplicateEmail))
+	})
+
+	t.Run("email is unique within the country", func(t *testing.T) {
+		// Arrange
+		def := NewMemory(WithMemoryUniquenessScope(ScopeCountry))
+		eu := NewMemory(WithMemoryUniquenessScope(ScopeCountry))
+		store := NewResidency(ScopeCountry, def, Region{Name: "eu", Store: eu, Countries: []string{"DE", "FR"}})
+
+		require.NoError(t, store.Insert(context.TODO(), newMemoryUserHelper(t, "joe@foo.bar", "US")))
+
+		// Act
+		err := store.Insert(context.TODO(), newMemoryUserHelper(t, "joe@foo.bar", "FR"))
+		_, ambiguousErr := store.GetByEmail(context.TODO(), "joe@foo.bar")
+
+		// Assert
+		require.NoError(t, err)
+		assert.True(t, errors.Is(ambiguousErr, ErrAmbiguousEmail))
+	})
+}
+
+func TestResidencyUpdate(t *testing.T) {
+	t.Parallel()
+
+	t.Run("within the region", func(t *testing.T) {
+		// Arrange
+		store, _, eu := newResidencyHelper(t)
+		user := newMemoryUserHelper(t, "hans@foo.bar", "DE")
+		require.NoError(t, store.Insert(context.TODO(), user))
+
+		// Act
+		user.Country = "FR"
+		err := store.Update(context.TODO(), user)
+
+		// Assert
+		require.NoError(t, err)
+		observed, err := eu.Get(context.TODO(), user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "FR", observed.Country)
+	})
+
+	t.Run("to another region", func(t *testing.T) {
+		// Arrange
+		store, _, _ := newResidencyHelper(t)
+		user := newMemoryUserHelper(t, "hans@foo.bar", "DE")
+		require.NoError(t, store.Insert(context.TODO(), user))
+
+		// Act
+		user.Country = "US"
+		err := store.Update(context.TODO(), user)
+
+		// Assert
+		assert.True(t, errors.Is(err, ErrRegionChange))
+	})
+}
+
+func TestResidencyLists(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	store, _, _ := newResidencyHelper(t)
+
+	var users []*User
+	for _, u := range []struct{ email, country string }{
+		{"hans@foo.bar", "DE"},
+		{"marie@foo.bar", "FR"},
+		{"joe@foo.bar", "US"},
+		{"ana@foo.bar", "BR"},
+	} {
+		user := newMemoryUserHelper(t, u.email, u.country)
+		require.NoError(t, store.Insert(context.TODO(), user))
+		users = append(users, user)
+	}
+
+	t.Run("federates the pages of every region", func(t *testing.T) {
+		// Act
+		first, err := store.List(context.TODO(), Filter{}, Page{Limit: 3})
+		require.NoError(t, err)
+
+		second, err := store.List(context.TODO(), Filter{}, Page{Cursor: first[len(first)-1].ID, Limit: 3})
+		require.NoError(t, err)
+
+		// Assert
+		require.Len(t, first, 3)
+		require.Len(t, second, 1)
+
+		seen := make(map[string]bool)
+		for i, user := range append(first, second...) {
+			seen[user.ID] = true
+			if i > 0 {
+				assert.Less(t, first[0].ID, user.ID)
+			}
+		}
+		assert.Len(t, seen, len(users))
+	})
+
+	t.Run("lists a country from its region", func(t *testing.T) {
+		// Act
+		observed, err := store.List(context.TODO(), Filter{Country: "FR"}, Page{Limit: 10})
+
+		// Assert
+		require.NoError(t, err)
+		require.Len(t, observed, 1)
+		assert.Equal(t, "marie@foo.bar", observed[0].Email)
+	})
+
+	t.Run("counts every region", func(t *testing.T) {
+		// Act
+		observed, err := store.CountByCountry(context.TODO())
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, map[string]int64{"DE": 1, "FR": 1, "US": 1, "BR": 1}, observed)
+	})
+}
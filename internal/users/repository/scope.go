@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/alesr/usrsvc/internal/tenant"
+)
+
+const (
+	// Enumerate the uniqueness scopes of the emails and nicknames.
+
+	ScopeGlobal  UniquenessScope = "global"
+	ScopeCountry UniquenessScope = "country"
+)
+
+// UniquenessScope is the scope within which the emails and nicknames of the users are unique:
+// globally, or within each country, so users of different countries can share an email.
+//
+// The stores enforce it with their unique constraints, on the uniqueness key of the user and
+// the email or nickname. The key is set on every write and recomputed for the stored users
+// by ApplyUniquenessScope, so the scope can be changed between deployments.
+type UniquenessScope string
+
+// ParseUniquenessScope returns the scope with the given name.
+func ParseUniquenessScope(name string) (UniquenessScope, error) {
+	switch scope := UniquenessScope(name); scope {
+	case ScopeGlobal, ScopeCountry:
+		return scope, nil
+	default:
+		return "", fmt.Errorf("%w: '%s', valid scopes are '%s' and '%s'", ErrUniquenessScope, name, ScopeGlobal, ScopeCountry)
+	}
+}
+
+// key returns the uniqueness key of the user. Users with the same key can't share
+// an email or a nickname. An empty scope is global. The key is prefixed with the tenant
+// of the user, but for the default tenant, whose users keep the keys they had before
+// the tenants.
+func (s UniquenessScope) key(user *User) string {
+	var key string
+	if s == ScopeCountry {
+		key = user.Country
+	}
+
+	if user.TenantID != "" && user.TenantID != tenant.Default {
+		return user.TenantID + "/" + key
+	}
+	return key
+}
+
+// keyColumn returns the SQL expression of the uniqueness key of the users.
+func (s UniquenessScope) keyColumn() string {
+	key := "''"
+	if s == ScopeCountry {
+		key = "country"
+	}
+	return fmt.Sprintf("CASE WHEN tenant_id = '%s' THEN %s ELSE tenant_id || '/' || %s END", tenant.Default, key, key)
+}
+
+// scopedUser is a user with its uniqueness key, for the writes, and the tenant the write
+// is scoped to, empty when it isn't.
+type scopedUser struct {
+	*User
+	UniquenessKey string `db:"uniqueness_key"`
+	ScopeTenant   string `db:"scope_tenant" bson:"-"`
+}
+
+// scoped returns the user with its uniqueness key and the tenant of ctx. The users without
+// tenant, i.e. the new ones and the ones built from the domain, are given the tenant of ctx.
+func (s UniquenessScope) scoped(ctx context.Context, user *User) *scopedUser {
+	if user.TenantID == "" {
+		user.TenantID = tenant.OrDefault(ctx)
+	}
+	return &scopedUser{User: user, UniquenessKey: s.key(user), ScopeTenant: tenantOf(ctx)}
+}
+
+// oneByEmail returns the only user of the lookup by email, ErrUserNotFound when there
+// is none and ErrAmbiguousEmail when the scope lets several users share the email.
+func oneByEmail(users []*User) (*User, error) {
+	switch len(users) {
+	case 0:
+		return nil, ErrUserNotFound
+	case 1:
+		return users[0], nil
+	default:
+		return nil, ErrAmbiguousEmail
+	}
+}
@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"strings"
+	"unicode"
+)
+
+// searchTerms splits the search query into lowercase words made of letters and digits,
+// the way Postgres splits the names into the search vector. Other characters separate words.
+func searchTerms(query string) []string {
+	return strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// prefixTSQuery returns a tsquery matching the documents with words starting with every term.
+// The terms must only contain letters and digits, so they don't need to be quoted.
+func prefixTSQuery(terms []string) string {
+	prefixes := make([]string, 0, len(terms))
+	for _, term := range terms {
+		prefixes = append(prefixes, term+":*")
+	}
+	return strings.Join(prefixes, " & ")
+}
+
+// matchSearch reports whether every term is a prefix of a word of the user names or nickname.
+func matchSearch(u *User, terms []string) bool {
+	words := searchTerms(u.FirstName + " " + u.LastName + " " + u.Nickname)
+	for _, term := range terms {
+		var found bool
+		for _, word := range words {
+			if strings.HasPrefix(word, term) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+var (
+	_ Store = (*Postgres)(nil)
+	_ Store = (*Memory)(nil)
+	_ Store = (*SQLite)(nil)
+	_ Store = (*Mongo)(nil)
+)
+
+// Store is implemented by every repository backend.
+type Store interface {
+	Get(ctx context.Context, id string) (*User, error)
+	GetByEmail(ctx context.Context, email string) (*User, error)
+	List(ctx context.Context, filter Filter, page Page) ([]*User, error)
+	Count(ctx context.Context, filter Filter) (int64, error)
+	NicknameExists(ctx context.Context, nickname, country string) (bool, error)
+	Search(ctx context.Context, query string, offset, limit int) ([]*User, error)
+	Insert(ctx context.Context, user *User) error
+	InsertBatch(ctx context.Context, users []*User) error
+	Update(ctx context.Context, user *User) error
+	Delete(ctx context.Context, id string) (int64, error)
+	Merge(ctx context.Context, survivor *User, duplicateID string) error
+	Anonymize(ctx context.Context, user *User) error
+	SetStatus(ctx context.Context, user *User) error
+	SetAvatar(ctx context.Context, user *User) error
+	Bootstrap(ctx context.Context, admin *User, key *APIKey) error
+	InsertAPIKey(ctx context.Context, key *APIKey) error
+	GetAPIKey(ctx context.Context, id string) (*APIKey, error)
+	GetAPIKeys(ctx context.Context, userID string) ([]*APIKey, error)
+	DeleteAPIKey(ctx context.Context, id string) error
+	LinkIdentity(ctx context.Context, identity *LinkedIdentity) error
+	GetLinkedIdentities(ctx context.Context, userID string) ([]*LinkedIdentity, error)
+	GetByLinkedIdentity(ctx context.Context, provider, subject string) (*User, error)
+	UnlinkIdentity(ctx context.Context, userID, provider, subject string) error
+	LockField(ctx context.Context, lock *FieldLock) error
+	UnlockField(ctx context.Context, userID, field string) error
+	GetFieldLocks(ctx context.Context, userID string) ([]*FieldLock, error)
+	RecordLogin(ctx context.Context, login *Login) error
+	GetRecentLogins(ctx context.Context, userID string, limit int) ([]*Login, error)
+	GetLockout(ctx context.Context, userID string) (*Lockout, error)
+	RecordFailedLogin(ctx context.Context, userID string) (*Lockout, error)
+	LockUser(ctx context.Context, userID string, until time.Time) error
+	ResetLockout(ctx context.Context, userID string) error
+	InsertSession(ctx context.Context, session *Session) error
+	GetSession(ctx context.Context, id string) (*Session, error)
+	GetSessions(ctx context.Context, userID string) ([]*Session, error)
+	RotateSession(ctx context.Context, session *Session, refreshTokenHash []byte) error
+	DeleteSession(ctx context.Context, id string) error
+	InsertTOTP(ctx context.Context, totp *TOTP) error
+	GetTOTP(ctx context.Context, userID string) (*TOTP, error)
+	UseTOTP(ctx context.Context, userID string, step int64) error
+	InsertPhoneVerification(ctx context.Context, verification *PhoneVerification) error
+	AttemptPhoneVerification(ctx context.Context, userID string, now time.Time) (*PhoneVerification, error)
+	DeletePhoneVerification(ctx context.Context, userID string) error
+	InsertPasswordResetToken(ctx context.Context, token *PasswordResetToken) error
+	ResetPassword(ctx context.Context, tokenHash []byte, password string, now time.Time) (*User, error)
+	CountByCountry(ctx context.Context) (map[string]int64, error)
+	CheckDatabaseHealth(ctx context.Context) error
+}
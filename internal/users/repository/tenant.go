@@ -0,0 +1,20 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/alesr/usrsvc/internal/tenant"
+)
+
+// tenantOf returns the tenant the operation is scoped to, or an empty string when it isn't,
+// e.g. for the background jobs working across the tenants. The SQL queries match every
+// user when it is empty.
+func tenantOf(ctx context.Context) string {
+	id, _ := tenant.FromContext(ctx)
+	return id
+}
+
+// inTenant reports whether the user is visible to the operation scoped by ctx.
+func inTenant(ctx context.Context, user *User) bool {
+	return tenant.Visible(ctx, user.TenantID)
+}
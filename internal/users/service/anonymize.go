@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alesr/usrsvc/internal/audit"
+	"github.com/alesr/usrsvc/internal/users/repository"
+	"github.com/alesr/usrsvc/pkg/events"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+const (
+	// Enumerate the values the personal data of the anonymized users is overwritten with.
+	// The nickname and the email embed the id, as both must stay unique.
+
+	anonymizedFirstName   string = "Anonymized"
+	anonymizedLastName    string = "User"
+	anonymizedNickname    string = "anonymized-"
+	anonymizedEmailDomain string = "@anonymized.invalid"
+)
+
+// AnonymizeUser erases the personal data of the user, for the right to be forgotten. Unlike
+// Delete, the user is kept with its id, so the records referencing it remain valid, but its
+// names, nickname, email and password are overwritten with values derived from the id only,
+// its metadata, phone, locale, timezone, birthdate and avatar are cleared, and its credentials,
+// linked identities, logins and field locks are deleted. The country is kept, it locates the
+// user's data region. Anonymized users can't be updated anymore. The values of the changes
+// of the user recorded so far in the audit log are redacted, the fields changed are kept.
+func (s *ServiceDefault) AnonymizeUser(ctx context.Context, id string) (*User, error) {
+	ctx, span := tracer.Start(ctx, "ServiceDefault.AnonymizeUser")
+	defer span.End()
+	span.SetAttributes(attribute.String("user.id", id))
+
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, fmt.Errorf("could not validate id '%s': %w", id, ErrInvalidID)
+	}
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	stored, err := s.repo.Get(repository.ContextWithPrimary(ctx), id)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return nil, fmt.Errorf("could not anonymize user '%s': %w", id, ErrUserNotFound)
+		}
+		return nil, fmt.Errorf("could not anonymize user '%s': %w", id, err)
+	}
+
+	if stored.Anonymized {
+		return nil, fmt.Errorf("could not anonymize user '%s': %w", id, ErrUserAnonymized)
+	}
+
+	before := *stored
+
+	stored.FirstName = anonymizedFirstName
+	stored.LastName = anonymizedLastName
+	stored.Nickname = anonymizedNickname + id
+	stored.Email = id + anonymizedEmailDomain
+	stored.Password = ""
+	stored.Metadata = nil
+	stored.Phone = ""
+	stored.PhoneVerified = false
+	stored.Locale = ""
+	stored.Timezone = ""
+	stored.Birthdate = nil
+	stored.UpdatedAt = time.Now()
+
+	if err := s.repo.Anonymize(ctx, stored); err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return nil, fmt.Errorf("could not anonymize user '%s': %w", id, ErrUserNotFound)
+		}
+		return nil, fmt.Errorf("could not anonymize user '%s': %w", id, err)
+	}
+
+	s.invalidateCachedUser(ctx, id)
+	s.deleteAvatar(ctx, before.AvatarKey)
+
+	if s.auditing() {
+		// The user is anonymized already, so a failure is logged rather than returned.
+		if err := s.auditLog.Redact(ctx, id); err != nil {
+			s.logger.Error("could not redact audit events", zap.String("user_id", id), zap.Error(err))
+		}
+
+		changes := audit.Diff(auditFields(&before), auditFields(stored))
+		for field, change := range changes {
+			change.Before = audit.Redacted
+			changes[field] = change
+		}
+		s.auditChanges(ctx, audit.ActionAnonymize, id, changes)
+	}
+
+	s.logger.Info("anonymized user", zap.String("id", id))
+
+	if s.publisher != nil {
+		s.publish(ctx, events.UserAnonymized, userEvent(id, stored.EventSequence, id))
+	}
+	return newUserDomainFromStore(stored), nil
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alesr/usrsvc/internal/audit"
+	"github.com/alesr/usrsvc/internal/users/repository"
+	"github.com/alesr/usrsvc/pkg/events"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestAnonymizeUser(t *testing.T) {
+	t.Parallel()
+
+	newUser := func(nickname, email string) *repository.User {
+		return &repository.User{
+			ID:        uuid.New().String(),
+			FirstName: "John",
+			LastName:  "Doe",
+			Nickname:  nickname,
+			Password:  "hash",
+			Email:     email,
+			Country:   "BR",
+			CreatedAt: time.Time{}.Add(1 * time.Second),
+			UpdatedAt: time.Time{}.Add(2 * time.Second),
+		}
+	}
+
+	t.Run("happy path", func(t *testing.T) {
+		// Arrange
+		repo := repository.NewMemory()
+		user := newUser("johndoe", "johndoe@foo.bar")
+		user.Phone = "+14155550123"
+		user.PhoneVerified = true
+		require.NoError(t, repo.Insert(context.TODO(), user))
+
+		require.NoError(t, repo.LinkIdentity(context.TODO(), &repository.LinkedIdentity{
+			Provider:  "google",
+			Subject:   "1234567890",
+			UserID:    user.ID,
+			Email:     "johndoe@gmail.com",
+			CreatedAt: time.Time{}.Add(3 * time.Second),
+		}))
+
+		var published []events.Event
+		publisher := &publisherMock{
+			PublishFunc: func(_ context.Context, event events.Event, data any) error {
+				published = append(published, event)
+				assert.Equal(t, events.Ordered{Key: user.ID, Sequence: 2, Data: user.ID}, data)
+				return nil
+			},
+		}
+
+		auditLog := audit.NewMemory()
+		require.NoError(t, auditLog.Record(context.TODO(), &audit.Event{
+			Action:  audit.ActionUpdate,
+			UserID:  user.ID,
+			Changes: map[string]audit.Change{"email": {Before: "jdoe@foo.bar", After: user.Email}},
+		}))
+
+		svc := NewServiceDefault(zap.NewNop(), repo, WithPublisher(publisher), WithAuditLog(auditLog))
+
+		// Act
+		anonymized, err := svc.AnonymizeUser(context.TODO(), user.ID)
+		require.NoError(t, err)
+
+		_, againErr := svc.AnonymizeUser(context.TODO(), user.ID)
+		_, updateErr := svc.Update(context.TODO(), &User{ID: user.ID, FirstName: "John", LastName: "Doe", Nickname: "johndoe", Email: "johndoe@foo.bar", Country: "BR"})
+
+		// Assert
+		assert.NoError(t, err)
+		assert.True(t, errors.Is(againErr, ErrUserAnonymized))
+		assert.True(t, errors.Is(updateErr, ErrUserAnonymized))
+		assert.Equal(t, []events.Event{events.UserAnonymized}, published)
+
+		assert.Equal(t, user.ID, anonymized.ID)
+		assert.Equal(t, "Anonymized", anonymized.FirstName)
+		assert.Equal(t, "anonymized-"+user.ID, anonymized.Nickname)
+		assert.Equal(t, user.ID+"@anonymized.invalid", anonymized.Email)
+		assert.Equal(t, "BR", anonymized.Country)
+		assert.Empty(t, anonymized.Password)
+		assert.Empty(t, anonymized.Phone)
+		assert.False(t, anonymized.PhoneVerified)
+		assert.True(t, anonymized.Anonymized)
+
+		identities, err := repo.GetLinkedIdentities(context.TODO(), user.ID)
+		require.NoError(t, err)
+		assert.Empty(t, identities)
+
+		// The audit log doesn't get the personal data back.
+		auditEvents, err := auditLog.List(context.TODO(), audit.Filter{UserID: user.ID, Limit: 10})
+		require.NoError(t, err)
+		require.Len(t, auditEvents, 2)
+		assert.Equal(t, audit.ActionAnonymize, auditEvents[0].Action)
+		assert.Equal(t, audit.Change{Before: audit.Redacted, After: user.ID + "@anonymized.invalid"}, auditEvents[0].Changes["email"])
+		assert.Equal(t, audit.Change{Before: audit.Redacted, After: audit.Redacted}, auditEvents[1].Changes["email"])
+	})
+
+	t.Run("anonymized users are not merged", func(t *testing.T) {
+		// Arrange
+		repo := repository.NewMemory()
+		survivor := newUser("johndoe", "johndoe@foo.bar")
+		duplicate := newUser("jdoe", "john.doe@foo.bar")
+		require.NoError(t, repo.Insert(context.TODO(), survivor))
+		require.NoError(t, repo.Insert(context.TODO(), duplicate))
+
+		svc := NewServiceDefault(zap.NewNop(), repo)
+
+		_, err := svc.AnonymizeUser(context.TODO(), duplicate.ID)
+		require.NoError(t, err)
+
+		// Act
+		_, err = svc.Merge(context.TODO(), MergeParams{SurvivorID: survivor.ID, DuplicateID: duplicate.ID})
+
+		// Assert
+		assert.True(t, errors.Is(err, ErrUserAnonymized))
+	})
+
+	t.Run("user not found", func(t *testing.T) {
+		// Arrange
+		svc := NewServiceDefault(zap.NewNop(), repository.NewMemory())
+
+		// Act
+		anonymized, err := svc.AnonymizeUser(context.TODO(), uuid.New().String())
+
+		// Assert
+		assert.Nil(t, anonymized)
+		assert.True(t, errors.Is(err, ErrUserNotFound))
+	})
+
+	t.Run("invalid id", func(t *testing.T) {
+		// Arrange
+		svc := NewServiceDefault(zap.NewNop(), repository.NewMemory())
+
+		// Act
+		anonymized, err := svc.AnonymizeUser(context.TODO(), "foo")
+
+		// Assert
+		assert.Nil(t, anonymized)
+		assert.True(t, errors.Is(err, ErrInvalidID))
+	})
+}
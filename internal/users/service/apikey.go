@@ -0,0 +1,257 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alesr/usrsvc/internal/users/repository"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	// apiKeyPrefix makes the keys easy to recognize, e.g. by secret scanners.
+	apiKeyPrefix string = "usrsvc_"
+
+	apiKeySecretLength int = 32
+)
+
+const (
+	// ScopeUsersRead grants the RPCs that only read data.
+	ScopeUsersRead string = "users:read"
+
+	// ScopeUsersWrite grants the RPCs that change data. It doesn't imply ScopeUsersRead.
+	ScopeUsersWrite string = "users:write"
+)
+
+// APIKeyScopes are the scopes API keys can be granted.
+var APIKeyScopes = []string{ScopeUsersRead, ScopeUsersWrite}
+
+// APIKey is an API key of a user, for services to call on behalf of the user.
+// The key itself is only handed out on creation.
+type APIKey struct {
+	ID        string
+	UserID    string
+	Name      string
+	Scopes    []string
+	CreatedAt time.Time
+}
+
+// HasScope reports whether the key was granted the scope. The keys created before scopes
+// existed have none and are granted every scope.
+func (k *APIKey) HasScope(scope string) bool {
+	if len(k.Scopes) == 0 {
+		return true
+	}
+
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// newAPIKey generates a new API key for the user. It returns the key to hand out,
+// formatted as "usrsvc_<key id>.<secret>", and the key to store, which only keeps
+// a hash of the secret.
+func newAPIKey(userID, name string, scopes []string) (string, *repository.APIKey, error) {
+	secret := make([]byte, apiKeySecretLength)
+	if _, err := rand.Read(secret); err != nil {
+		return "", nil, fmt.Errorf("could not generate api key secret: %w", err)
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(secret)
+	hash := sha256.Sum256([]byte(encoded))
+
+	key := &repository.APIKey{
+		ID:         uuid.New().String(),
+		UserID:     userID,
+		Name:       name,
+		SecretHash: hash[:],
+		Scopes:     strings.Join(scopes, " "),
+		CreatedAt:  time.Now(),
+	}
+	return apiKeyPrefix + key.ID + "." + encoded, key, nil
+}
+
+// CreateAPIKey creates an API key for the user, granted the given scopes.
+// It returns the key and the key to hand out, which cannot be retrieved later.
+func (s *ServiceDefault) CreateAPIKey(ctx context.Context, userID, name string, scopes []string) (*APIKey, string, error) {
+	ctx, span := tracer.Start(ctx, "ServiceDefault.CreateAPIKey")
+	defer span.End()
+	span.SetAttributes(attribute.String("user.id", userID))
+
+	if _, err := uuid.Parse(userID); err != nil {
+		return nil, "", fmt.Errorf("could not validate id '%s': %w", userID, ErrInvalidID)
+	}
+
+	scopes, err := normalizeAPIKeyScopes(scopes)
+	if err != nil {
+		return nil, "", fmt.Errorf("could not create api key for user '%s': %w", userID, err)
+	}
+
+	apiKey, key, err := newAPIKey(userID, name, scopes)
+	if err != nil {
+		return nil, "", fmt.Errorf("could not create api key for user '%s': %w", userID, err)
+	}
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	if err := s.repo.InsertAPIKey(ctx, key); err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			err = ErrUserNotFound
+		}
+		return nil, "", fmt.Errorf("could not create api key for user '%s': %w", userID, err)
+	}
+	return newAPIKeyDomainFromStore(key), apiKey, nil
+}
+
+// RevokeAPIKey deletes the API key with the given id. It stops working right away.
+func (s *ServiceDefault) RevokeAPIKey(ctx context.Context, id string) error {
+	ctx, span := tracer.Start(ctx, "ServiceDefault.RevokeAPIKey")
+	defer span.End()
+	span.SetAttributes(attribute.String("api_key.id", id))
+
+	if _, err := uuid.Parse(id); err != nil {
+		return fmt.Errorf("could not validate id '%s': %w", id, ErrInvalidID)
+	}
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	if err := s.repo.DeleteAPIKey(ctx, id); err != nil {
+		if errors.Is(err, repository.ErrAPIKeyNotFound) {
+			return fmt.Errorf("could not revoke api key '%s': %w", id, ErrAPIKeyNotFound)
+		}
+		return fmt.Errorf("could not revoke api key '%s': %w", id, err)
+	}
+	return nil
+}
+
+// APIKeys returns the API keys of the user, oldest first.
+func (s *ServiceDefault) APIKeys(ctx context.Context, userID string) ([]*APIKey, error) {
+	ctx, span := tracer.Start(ctx, "ServiceDefault.APIKeys")
+	defer span.End()
+	span.SetAttributes(attribute.String("user.id", userID))
+
+	if _, err := uuid.Parse(userID); err != nil {
+		return nil, fmt.Errorf("could not validate id '%s': %w", userID, ErrInvalidID)
+	}
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	stored, err := s.repo.GetAPIKeys(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get api keys of user '%s': %w", userID, err)
+	}
+
+	keys := make([]*APIKey, 0, len(stored))
+	for _, key := range stored {
+		keys = append(keys, newAPIKeyDomainFromStore(key))
+	}
+	return keys, nil
+}
+
+// AuthenticateAPIKey returns the owner of the API key and the key, for the caller to
+// check its scopes. Malformed, unknown and wrong keys all fail with ErrInvalidCredentials.
+func (s *ServiceDefault) AuthenticateAPIKey(ctx context.Context, apiKey string) (*User, *APIKey, error) {
+	ctx, span := tracer.Start(ctx, "ServiceDefault.AuthenticateAPIKey")
+	defer span.End()
+
+	id, secret, ok := strings.Cut(strings.TrimPrefix(apiKey, apiKeyPrefix), ".")
+	if !ok || !strings.HasPrefix(apiKey, apiKeyPrefix) {
+		return nil, nil, fmt.Errorf("could not parse api key: %w", ErrInvalidCredentials)
+	}
+
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, nil, fmt.Errorf("could not parse api key: %w", ErrInvalidCredentials)
+	}
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	key, err := s.repo.GetAPIKey(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrAPIKeyNotFound) {
+			return nil, nil, fmt.Errorf("could not authenticate api key '%s': %w", id, ErrInvalidCredentials)
+		}
+		return nil, nil, fmt.Errorf("could not authenticate api key '%s': %w", id, err)
+	}
+
+	hash := sha256.Sum256([]byte(secret))
+	if subtle.ConstantTimeCompare(hash[:], key.SecretHash) != 1 {
+		return nil, nil, fmt.Errorf("could not authenticate api key '%s': %w", id, ErrInvalidCredentials)
+	}
+
+	user, err := s.repo.Get(ctx, key.UserID)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return nil, nil, fmt.Errorf("could not authenticate api key '%s': %w", id, ErrInvalidCredentials)
+		}
+		return nil, nil, fmt.Errorf("could not authenticate api key '%s': %w", id, err)
+	}
+
+	if err := checkStatus(user); err != nil {
+		return nil, nil, fmt.Errorf("could not authenticate api key '%s': %w", id, err)
+	}
+
+	authenticated := newUserDomainFromStore(user)
+
+	// The hash never leaves the service.
+	authenticated.Password = ""
+	return authenticated, newAPIKeyDomainFromStore(key), nil
+}
+
+// normalizeAPIKeyScopes validates the scopes and returns them sorted, without duplicates.
+// At least one scope is required.
+func normalizeAPIKeyScopes(scopes []string) ([]string, error) {
+	seen := make(map[string]bool, len(scopes))
+	normalized := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if !isAPIKeyScope(scope) {
+			return nil, fmt.Errorf("unknown scope '%s': %w", scope, ErrAPIKeyScopesInvalid)
+		}
+
+		if !seen[scope] {
+			seen[scope] = true
+			normalized = append(normalized, scope)
+		}
+	}
+
+	if len(normalized) == 0 {
+		return nil, fmt.Errorf("no scope: %w", ErrAPIKeyScopesInvalid)
+	}
+
+	sort.Strings(normalized)
+	return normalized, nil
+}
+
+func isAPIKeyScope(scope string) bool {
+	for _, s := range APIKeyScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func newAPIKeyDomainFromStore(key *repository.APIKey) *APIKey {
+	return &APIKey{
+		ID:        key.ID,
+		UserID:    key.UserID,
+		Name:      key.Name,
+		Scopes:    strings.Fields(key.Scopes),
+		CreatedAt: key.CreatedAt,
+	}
+}
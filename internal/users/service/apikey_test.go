@@ -0,0 +1,189 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/alesr/usrsvc/internal/users/repository"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	t.Parallel()
+
+	owner := &repository.User{
+		ID:       uuid.New().String(),
+		Password: "some-hash",
+		Email:    "admin@foo.bar",
+		Role:     repository.RoleAdmin,
+	}
+
+	apiKey, storedKey, err := newAPIKey(owner.ID, "terraform", []string{ScopeUsersRead})
+	require.NoError(t, err)
+
+	repo := &repoMock{
+		GetAPIKeyFunc: func(ctx context.Context, id string) (*repository.APIKey, error) {
+			if id != storedKey.ID {
+				return nil, repository.ErrAPIKeyNotFound
+			}
+			return storedKey, nil
+		},
+		GetFunc: func(ctx context.Context, id string) (*repository.User, error) {
+			require.Equal(t, owner.ID, id)
+			return owner, nil
+		},
+	}
+
+	svc := NewServiceDefault(zap.NewNop(), repo)
+
+	t.Run("valid key", func(t *testing.T) {
+		// Act
+		user, key, err := svc.AuthenticateAPIKey(context.TODO(), apiKey)
+		require.NoError(t, err)
+
+		// Assert
+		assert.Equal(t, owner.ID, user.ID)
+		assert.Equal(t, RoleAdmin, user.Role)
+		assert.Empty(t, user.Password)
+
+		assert.Equal(t, storedKey.ID, key.ID)
+		assert.True(t, key.HasScope(ScopeUsersRead))
+		assert.False(t, key.HasScope(ScopeUsersWrite))
+	})
+
+	testCases := []struct {
+		name  string
+		given string
+	}{
+		{name: "wrong secret", given: apiKeyPrefix + storedKey.ID + ".wrong-secret"},
+		{name: "unknown key", given: apiKeyPrefix + uuid.New().String() + ".secret"},
+		{name: "missing prefix", given: storedKey.ID + ".secret"},
+		{name: "malformed key", given: "usrsvc_garbage"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			user, key, err := svc.AuthenticateAPIKey(context.TODO(), tc.given)
+
+			// Assert
+			assert.Nil(t, user)
+			assert.Nil(t, key)
+			assert.True(t, errors.Is(err, ErrInvalidCredentials))
+		})
+	}
+}
+
+func TestAPIKeys(t *testing.T) {
+	t.Parallel()
+
+	newServiceHelper := func(t *testing.T) (*ServiceDefault, *User) {
+		t.Helper()
+
+		hasher := &hasherMock{
+			HashFunc: func(ctx context.Context, password []byte) ([]byte, error) {
+				return password, nil
+			},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), repository.NewMemory(), WithHasher(hasher))
+
+		created, err := svc.Create(context.TODO(), &User{
+			FirstName: "John",
+			LastName:  "Doe",
+			Nickname:  "jdoe",
+			Password:  "password1!",
+			Email:     "joedoe@foo.bar",
+			Country:   "US",
+		})
+		require.NoError(t, err)
+		return svc, created
+	}
+
+	t.Run("created keys authenticate until revoked", func(t *testing.T) {
+		t.Parallel()
+
+		// Arrange
+		svc, user := newServiceHelper(t)
+
+		// Act
+		created, apiKey, err := svc.CreateAPIKey(context.TODO(), user.ID, "billing", []string{ScopeUsersWrite, ScopeUsersRead, ScopeUsersRead})
+		require.NoError(t, err)
+
+		keys, err := svc.APIKeys(context.TODO(), user.ID)
+		require.NoError(t, err)
+
+		owner, authenticated, err := svc.AuthenticateAPIKey(context.TODO(), apiKey)
+		require.NoError(t, err)
+
+		require.NoError(t, svc.RevokeAPIKey(context.TODO(), created.ID))
+		_, _, revokedErr := svc.AuthenticateAPIKey(context.TODO(), apiKey)
+		revokeAgainErr := svc.RevokeAPIKey(context.TODO(), created.ID)
+
+		// Assert
+		assert.True(t, strings.HasPrefix(apiKey, apiKeyPrefix+created.ID+"."))
+		assert.Equal(t, []string{ScopeUsersRead, ScopeUsersWrite}, created.Scopes)
+
+		require.Len(t, keys, 1)
+		assert.Equal(t, created, keys[0])
+
+		assert.Equal(t, user.ID, owner.ID)
+		assert.Equal(t, created.ID, authenticated.ID)
+
+		assert.True(t, errors.Is(revokedErr, ErrInvalidCredentials))
+		assert.True(t, errors.Is(revokeAgainErr, ErrAPIKeyNotFound))
+	})
+
+	testCases := []struct {
+		name        string
+		userID      string
+		scopes      []string
+		expectedErr error
+	}{
+		{name: "unknown scope", scopes: []string{ScopeUsersRead, "users:admin"}, expectedErr: ErrAPIKeyScopesInvalid},
+		{name: "no scope", scopes: nil, expectedErr: ErrAPIKeyScopesInvalid},
+		{name: "unknown user", userID: uuid.New().String(), scopes: []string{ScopeUsersRead}, expectedErr: ErrUserNotFound},
+		{name: "invalid user id", userID: "not-a-uuid", scopes: []string{ScopeUsersRead}, expectedErr: ErrInvalidID},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Arrange
+			svc, user := newServiceHelper(t)
+
+			userID := tc.userID
+			if userID == "" {
+				userID = user.ID
+			}
+
+			// Act
+			key, apiKey, err := svc.CreateAPIKey(context.TODO(), userID, "billing", tc.scopes)
+
+			// Assert
+			assert.Nil(t, key)
+			assert.Empty(t, apiKey)
+			assert.True(t, errors.Is(err, tc.expectedErr))
+		})
+	}
+}
+
+func TestAPIKeyHasScope(t *testing.T) {
+	t.Parallel()
+
+	// Keys created before scopes existed have none and are unrestricted.
+	legacy := &APIKey{}
+	scoped := &APIKey{Scopes: []string{ScopeUsersRead}}
+
+	assert.True(t, legacy.HasScope(ScopeUsersWrite))
+	assert.True(t, scoped.HasScope(ScopeUsersRead))
+	assert.False(t, scoped.HasScope(ScopeUsersWrite))
+}
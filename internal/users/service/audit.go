@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/alesr/usrsvc/internal/audit"
+	"github.com/alesr/usrsvc/internal/redact"
+	"github.com/alesr/usrsvc/internal/users/repository"
+	"go.uber.org/zap"
+)
+
+// passwordField is the audited field of the password hash, whose values are redacted.
+const passwordField string = "password"
+
+// AuditLog records the changes made to the users.
+type AuditLog interface {
+	Record(ctx context.Context, event *audit.Event) error
+	List(ctx context.Context, filter audit.Filter) ([]*audit.Event, error)
+
+	// Redact redacts the values of the changes recorded so far for the user.
+	Redact(ctx context.Context, userID string) error
+}
+
+// WithAuditLog configures the service to record every change made to the users,
+// with the actor found in the context (see audit.ContextWithActor).
+func WithAuditLog(log AuditLog) Option {
+	return func(s *ServiceDefault) {
+		s.auditLog = log
+	}
+}
+
+// WithRedaction configures the service to redact the audited changes with the policy.
+// Password hashes are always redacted.
+func WithRedaction(policy *redact.Policy) Option {
+	return func(s *ServiceDefault) {
+		s.redaction = policy
+	}
+}
+
+// AuditEvents lists the audit log, newest first.
+func (s *ServiceDefault) AuditEvents(ctx context.Context, filter audit.Filter) ([]*audit.Event, error) {
+	ctx, span := tracer.Start(ctx, "ServiceDefault.AuditEvents")
+	defer span.End()
+
+	if s.auditLog == nil {
+		return nil, fmt.Errorf("could not list audit events: %w", ErrAuditDisabled)
+	}
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	events, err := s.auditLog.List(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("could not list audit events: %w", err)
+	}
+	return events, nil
+}
+
+// auditing reports whether changes are audited, so callers only load
+// the previous version of a user when it is needed.
+func (s *ServiceDefault) auditing() bool {
+	return s.auditLog != nil
+}
+
+// audit records the change of the user from before to after, either of which may be nil.
+// The change has already been made, so a failure is logged rather than returned.
+func (s *ServiceDefault) audit(ctx context.Context, action audit.Action, userID string, before, after *repository.User) {
+	s.auditChanges(ctx, action, userID, audit.Diff(auditFields(before), auditFields(after)))
+}
+
+func (s *ServiceDefault) auditChanges(ctx context.Context, action audit.Action, userID string, changes map[string]audit.Change) {
+	if s.auditLog == nil {
+		return
+	}
+
+	for field, change := range changes {
+		if field == passwordField {
+			changes[field] = redactSecret(change)
+			continue
+		}
+
+		if s.redaction.Redacts(field) {
+			changes[field] = s.redactChange(field, change)
+			if changes[field] == (audit.Change{}) {
+				delete(changes, field)
+			}
+		}
+	}
+
+	event := audit.Event{
+		Actor:     audit.ActorFromContext(ctx),
+		Action:    action,
+		UserID:    userID,
+		Changes:   changes,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	if err := s.auditLog.Record(ctx, &event); err != nil {
+		s.logger.Error("could not record audit event",
+			zap.String("action", string(action)),
+			zap.String("user_id", userID),
+			zap.Error(err),
+		)
+	}
+}
+
+// auditFields returns the audited fields of the user. The metadata attributes are
+// audited one by one, prefixed with metadataFieldPrefix.
+func auditFields(user *repository.User) map[string]string {
+	if user == nil {
+		return nil
+	}
+
+	fields := map[string]string{
+		"first_name":  user.FirstName,
+		"last_name":   user.LastName,
+		"nickname":    user.Nickname,
+		"email":       user.Email,
+		"country":     user.Country,
+		"phone":       user.Phone,
+		"locale":      user.Locale,
+		"timezone":    user.Timezone,
+		"birthdate":   formatBirthdate(user.Birthdate),
+		passwordField: user.Password,
+	}
+
+	for key, value := range user.Metadata {
+		fields[metadataFieldPrefix+key] = value
+	}
+	return fields
+}
+
+// redactChange redacts the values of the change with the redaction policy.
+// Both values are empty when the field is dropped.
+func (s *ServiceDefault) redactChange(field string, change audit.Change) audit.Change {
+	before, keepBefore := s.redaction.Value(field, change.Before)
+	after, keepAfter := s.redaction.Value(field, change.After)
+	if !keepBefore || !keepAfter {
+		return audit.Change{}
+	}
+	return audit.Change{Before: before, After: after}
+}
+
+func redactSecret(change audit.Change) audit.Change {
+	if change.Before != "" {
+		change.Before = audit.Redacted
+	}
+
+	if change.After != "" {
+		change.After = audit.Redacted
+	}
+	return change
+}
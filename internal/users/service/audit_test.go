@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alesr/usrsvc/internal/audit"
+	"github.com/alesr/usrsvc/internal/redact"
+	"github.com/alesr/usrsvc/internal/users/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestAudit(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	hasher := &hasherMock{
+		HashFunc: func(ctx context.Context, password []byte) ([]byte, error) {
+			return bcrypt.GenerateFromPassword(password, bcrypt.MinCost)
+		},
+		CompareFunc: func(ctx context.Context, hash, password []byte) error {
+			return bcrypt.CompareHashAndPassword(hash, password)
+		},
+	}
+
+	auditLog := audit.NewMemory()
+	svc := NewServiceDefault(zap.NewNop(), repository.NewMemory(), WithHasher(hasher), WithAuditLog(auditLog))
+
+	ctx := audit.ContextWithActor(context.TODO(), "admin-id")
+
+	// Act
+	created, err := svc.Create(context.TODO(), &User{
+		FirstName: "John",
+		LastName:  "Doe",
+		Nickname:  "jdoe",
+		Password:  "password1!",
+		Email:     "joedoe@foo.bar",
+		Country:   "US",
+	})
+	require.NoError(t, err)
+
+	_, err = svc.Update(ctx, &User{
+		ID:        created.ID,
+		FirstName: "John",
+		LastName:  "Doe",
+		Nickname:  "jdoe",
+		Password:  "password1!",
+		Email:     "joedoe@foo.bar",
+		Country:   "PT",
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, svc.Delete(ctx, created.ID))
+
+	events, err := svc.AuditEvents(context.TODO(), audit.Filter{UserID: created.ID, Limit: 10})
+	require.NoError(t, err)
+
+	// Assert
+	require.Len(t, events, 3)
+
+	deleted, updated, inserted := events[0], events[1], events[2]
+
+	assert.Equal(t, audit.ActionCreate, inserted.Action)
+	assert.Equal(t, audit.Anonymous, inserted.Actor)
+	assert.Equal(t, audit.Change{After: "joedoe@foo.bar"}, inserted.Changes["email"])
+	assert.Equal(t, audit.Change{After: audit.Redacted}, inserted.Changes["password"])
+
+	// The password is the current one, so it is not rehashed and doesn't show up as changed.
+	assert.Equal(t, audit.ActionUpdate, updated.Action)
+	assert.Equal(t, "admin-id", updated.Actor)
+	assert.Equal(t, map[string]audit.Change{
+		"country": {Before: "US", After: "PT"},
+	}, updated.Changes)
+
+	assert.Equal(t, audit.ActionDelete, deleted.Action)
+	assert.Equal(t, audit.Change{Before: "PT"}, deleted.Changes["country"])
+}
+
+func TestAuditRedaction(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	hasher := &hasherMock{
+		HashFunc: func(ctx context.Context, password []byte) ([]byte, error) {
+			return bcrypt.GenerateFromPassword(password, bcrypt.MinCost)
+		},
+		CompareFunc: func(ctx context.Context, hash, password []byte) error {
+			return bcrypt.CompareHashAndPassword(hash, password)
+		},
+	}
+
+	policy := redact.New(map[string]redact.Strategy{"email": redact.Mask, "last_name": redact.Drop, "password": redact.Hash}, nil)
+	svc := NewServiceDefault(zap.NewNop(), repository.NewMemory(), WithHasher(hasher), WithAuditLog(audit.NewMemory()), WithRedaction(policy))
+
+	// Act
+	created, err := svc.Create(context.TODO(), &User{
+		FirstName: "John",
+		LastName:  "Doe",
+		Nickname:  "jdoe",
+		Password:  "password1!",
+		Email:     "joedoe@foo.bar",
+		Country:   "US",
+	})
+	require.NoError(t, err)
+
+	events, err := svc.AuditEvents(context.TODO(), audit.Filter{UserID: created.ID, Limit: 10})
+	require.NoError(t, err)
+
+	// Assert
+	require.Len(t, events, 1)
+	assert.Equal(t, map[string]audit.Change{
+		"first_name": {After: "John"},
+		"nickname":   {After: "jdoe"},
+		"email":      {After: "j***@foo.bar"},
+		"country":    {After: "US"},
+		"password":   {After: audit.Redacted},
+	}, events[0].Changes)
+}
+
+func TestAuditEventsDisabled(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	svc := NewServiceDefault(zap.NewNop(), &repoMock{})
+
+	// Act
+	events, err := svc.AuditEvents(context.TODO(), audit.Filter{Limit: 10})
+
+	// Assert
+	assert.Nil(t, events)
+	assert.True(t, errors.Is(err, ErrAuditDisabled))
+}
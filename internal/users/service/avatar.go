@@ -0,0 +1,235 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/alesr/usrsvc/internal/audit"
+	"github.com/alesr/usrsvc/internal/blob"
+	"github.com/alesr/usrsvc/internal/users/repository"
+	"github.com/alesr/usrsvc/pkg/events"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+const (
+	// MaxAvatarSize is the maximum size of the avatar images, in bytes.
+	MaxAvatarSize int = 1 << 20
+
+	// avatarKeyPrefix prefixes the blob keys of the avatars, followed by the user id
+	// and the hash of the image, so a new image never overwrites a served one.
+	avatarKeyPrefix string = "avatars/"
+
+	// avatarURLTTL is the lifetime of the signed URLs of the avatars.
+	avatarURLTTL time.Duration = 15 * time.Minute
+
+	// avatarField is the audited field of the avatar changes.
+	avatarField string = "avatar_hash"
+)
+
+// avatarContentTypes are the accepted image formats, as detected from their content.
+var avatarContentTypes = map[string]struct{}{
+	"image/gif":  {},
+	"image/jpeg": {},
+	"image/png":  {},
+	"image/webp": {},
+}
+
+// BlobStore is the interface of the store keeping the avatar images. See blob.Disk.
+type BlobStore interface {
+	Put(ctx context.Context, key string, data []byte) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// URLSigner is implemented by the blob stores serving their objects, e.g. with presigned
+// bucket URLs. The avatars are then handed out as URLs instead of their content.
+type URLSigner interface {
+	SignedURL(ctx context.Context, key string, ttl time.Duration) (string, error)
+}
+
+// WithAvatarStore enables the avatars, kept in the blob store. The users only
+// reference their avatar by key, with the hash of its content.
+func WithAvatarStore(store BlobStore) Option {
+	return func(s *ServiceDefault) {
+		s.avatars = store
+	}
+}
+
+// Avatar is the avatar image of a user, either its content or a signed URL to it.
+type Avatar struct {
+	Image       []byte
+	ContentType string
+
+	// Hash is the hex SHA-256 of the image, e.g. for caching.
+	Hash string
+
+	// URL is set instead of the image when the blob store signs URLs. It expires.
+	URL string
+}
+
+// SetAvatar sets the avatar image of the user, replacing the previous one. An empty
+// image removes the avatar. The images are PNG, JPEG, GIF or WebP, up to MaxAvatarSize.
+func (s *ServiceDefault) SetAvatar(ctx context.Context, id string, image []byte) (*User, error) {
+	ctx, span := tracer.Start(ctx, "ServiceDefault.SetAvatar")
+	defer span.End()
+	span.SetAttributes(attribute.String("user.id", id))
+
+	if s.avatars == nil {
+		return nil, fmt.Errorf("could not set avatar: %w", ErrAvatarsDisabled)
+	}
+
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, fmt.Errorf("could not validate id '%s': %w", id, ErrInvalidID)
+	}
+
+	var hash string
+	if len(image) > 0 {
+		if err := checkAvatar(image); err != nil {
+			return nil, err
+		}
+
+		sum := sha256.Sum256(image)
+		hash = hex.EncodeToString(sum[:])
+	}
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	stored, err := s.repo.Get(repository.ContextWithPrimary(ctx), id)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return nil, fmt.Errorf("could not set avatar of user '%s': %w", id, ErrUserNotFound)
+		}
+		return nil, fmt.Errorf("could not set avatar of user '%s': %w", id, err)
+	}
+
+	if stored.Anonymized {
+		return nil, fmt.Errorf("could not set avatar of user '%s': %w", id, ErrUserAnonymized)
+	}
+
+	before := *stored
+	if stored.AvatarHash != hash {
+		stored.AvatarKey = ""
+		stored.AvatarHash = hash
+		stored.UpdatedAt = time.Now()
+
+		if hash != "" {
+			stored.AvatarKey = avatarKeyPrefix + id + "/" + hash
+			if err := s.avatars.Put(ctx, stored.AvatarKey, image); err != nil {
+				return nil, fmt.Errorf("could not store avatar of user '%s': %w", id, err)
+			}
+		}
+
+		if err := s.repo.SetAvatar(ctx, stored); err != nil {
+			s.deleteAvatar(ctx, stored.AvatarKey)
+
+			if errors.Is(err, repository.ErrUserNotFound) {
+				return nil, fmt.Errorf("could not set avatar of user '%s': %w", id, ErrUserNotFound)
+			}
+			return nil, fmt.Errorf("could not set avatar of user '%s': %w", id, err)
+		}
+
+		// The previous image is not referenced anymore.
+		s.deleteAvatar(ctx, before.AvatarKey)
+
+		s.invalidateCachedUser(ctx, id)
+		s.auditChanges(ctx, audit.ActionUpdate, id, map[string]audit.Change{
+			avatarField: {Before: before.AvatarHash, After: hash},
+		})
+
+		if s.publisher != nil {
+			s.publish(ctx, events.UserUpdated, userEvent(id, stored.EventSequence, id))
+		}
+	}
+
+	user := newUserDomainFromStore(stored)
+
+	// The hash never leaves the service.
+	user.Password = ""
+	return user, nil
+}
+
+// GetAvatar returns the avatar of the user, as a signed URL when the blob store signs
+// URLs and as its content otherwise. ErrAvatarNotFound is returned without avatar.
+func (s *ServiceDefault) GetAvatar(ctx context.Context, id string) (*Avatar, error) {
+	ctx, span := tracer.Start(ctx, "ServiceDefault.GetAvatar")
+	defer span.End()
+	span.SetAttributes(attribute.String("user.id", id))
+
+	if s.avatars == nil {
+		return nil, fmt.Errorf("could not get avatar: %w", ErrAvatarsDisabled)
+	}
+
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, fmt.Errorf("could not validate id '%s': %w", id, ErrInvalidID)
+	}
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	stored, err := s.repo.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return nil, fmt.Errorf("could not get avatar of user '%s': %w", id, ErrUserNotFound)
+		}
+		return nil, fmt.Errorf("could not get avatar of user '%s': %w", id, err)
+	}
+
+	if stored.AvatarKey == "" {
+		return nil, fmt.Errorf("could not get avatar of user '%s': %w", id, ErrAvatarNotFound)
+	}
+
+	if signer, ok := s.avatars.(URLSigner); ok {
+		url, err := signer.SignedURL(ctx, stored.AvatarKey, avatarURLTTL)
+		if err != nil {
+			return nil, fmt.Errorf("could not sign avatar url of user '%s': %w", id, err)
+		}
+		return &Avatar{Hash: stored.AvatarHash, URL: url}, nil
+	}
+
+	image, err := s.avatars.Get(ctx, stored.AvatarKey)
+	if err != nil {
+		if errors.Is(err, blob.ErrNotFound) {
+			return nil, fmt.Errorf("could not get avatar of user '%s': %w", id, ErrAvatarNotFound)
+		}
+		return nil, fmt.Errorf("could not get avatar of user '%s': %w", id, err)
+	}
+
+	return &Avatar{
+		Image:       image,
+		ContentType: http.DetectContentType(image),
+		Hash:        stored.AvatarHash,
+	}, nil
+}
+
+// checkAvatar returns ErrAvatarInvalid for the images too large or of another format.
+func checkAvatar(image []byte) error {
+	if len(image) > MaxAvatarSize {
+		return fmt.Errorf("could not validate avatar of %d bytes: %w", len(image), ErrAvatarInvalid)
+	}
+
+	contentType := http.DetectContentType(image)
+	if _, ok := avatarContentTypes[contentType]; !ok {
+		return fmt.Errorf("could not validate avatar of type '%s': %w", contentType, ErrAvatarInvalid)
+	}
+	return nil
+}
+
+// deleteAvatar deletes the avatar image from the blob store. It is best effort: the
+// images left behind are not referenced by any user anymore.
+func (s *ServiceDefault) deleteAvatar(ctx context.Context, key string) {
+	if s.avatars == nil || key == "" {
+		return
+	}
+
+	if err := s.avatars.Delete(ctx, key); err != nil {
+		s.logger.Warn("could not delete avatar", zap.String("key", key), zap.Error(err))
+	}
+}
@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alesr/usrsvc/internal/blob"
+	"github.com/alesr/usrsvc/internal/users/repository"
+	"github.com/alesr/usrsvc/pkg/events"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// signingStore is a disk blob store signing URLs, like the bucket stores.
+type signingStore struct {
+	*blob.Disk
+}
+
+func (s signingStore) SignedURL(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	return "https://blobs.example.com/" + key + "?expires=" + ttl.String(), nil
+}
+
+func TestAvatar(t *testing.T) {
+	t.Parallel()
+
+	png := append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 64)...)
+	gif := append([]byte("GIF89a"), make([]byte, 64)...)
+
+	newUser := func(t *testing.T, repo *repository.Memory) *repository.User {
+		t.Helper()
+
+		user := &repository.User{
+			ID:        uuid.New().String(),
+			FirstName: "John",
+			LastName:  "Doe",
+			Nickname:  "johndoe",
+			Password:  "hash",
+			Email:     "johndoe@foo.bar",
+			Country:   "BR",
+			CreatedAt: time.Time{}.Add(1 * time.Second),
+			UpdatedAt: time.Time{}.Add(2 * time.Second),
+		}
+		require.NoError(t, repo.Insert(context.TODO(), user))
+		return user
+	}
+
+	newStore := func(t *testing.T) *blob.Disk {
+		t.Helper()
+
+		store, err := blob.NewDisk(t.TempDir())
+		require.NoError(t, err)
+		return store
+	}
+
+	t.Run("avatars can be set, replaced and removed", func(t *testing.T) {
+		// Arrange
+		repo := repository.NewMemory()
+		user := newUser(t, repo)
+		store := newStore(t)
+
+		var published []events.Event
+		publisher := &publisherMock{
+			PublishFunc: func(_ context.Context, event events.Event, data any) error {
+				published = append(published, event)
+				return nil
+			},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), repo, WithAvatarStore(store), WithPublisher(publisher))
+
+		// Act
+		first, err := svc.SetAvatar(context.TODO(), user.ID, png)
+		require.NoError(t, err)
+
+		// Setting the same image is a no-op.
+		_, err = svc.SetAvatar(context.TODO(), user.ID, png)
+		require.NoError(t, err)
+
+		firstStored, err := repo.Get(context.TODO(), user.ID)
+		require.NoError(t, err)
+
+		second, err := svc.SetAvatar(context.TODO(), user.ID, gif)
+		require.NoError(t, err)
+
+		avatar, err := svc.GetAvatar(context.TODO(), user.ID)
+		require.NoError(t, err)
+
+		_, firstBlobErr := store.Get(context.TODO(), firstStored.AvatarKey)
+
+		removed, err := svc.SetAvatar(context.TODO(), user.ID, nil)
+		require.NoError(t, err)
+
+		_, notFoundErr := svc.GetAvatar(context.TODO(), user.ID)
+
+		// Assert
+		assert.NotEmpty(t, first.AvatarHash)
+		assert.Empty(t, first.Password)
+		assert.NotEqual(t, first.AvatarHash, second.AvatarHash)
+		assert.Empty(t, removed.AvatarHash)
+
+		assert.Equal(t, gif, avatar.Image)
+		assert.Equal(t, "image/gif", avatar.ContentType)
+		assert.Equal(t, second.AvatarHash, avatar.Hash)
+		assert.Empty(t, avatar.URL)
+
+		assert.True(t, errors.Is(firstBlobErr, blob.ErrNotFound), "the replaced image is deleted")
+		assert.True(t, errors.Is(notFoundErr, ErrAvatarNotFound))
+		assert.Equal(t, []events.Event{events.UserUpdated, events.UserUpdated, events.UserUpdated}, published)
+	})
+
+	t.Run("signed urls", func(t *testing.T) {
+		// Arrange
+		repo := repository.NewMemory()
+		user := newUser(t, repo)
+
+		svc := NewServiceDefault(zap.NewNop(), repo, WithAvatarStore(signingStore{Disk: newStore(t)}))
+
+		updated, err := svc.SetAvatar(context.TODO(), user.ID, png)
+		require.NoError(t, err)
+
+		// Act
+		avatar, err := svc.GetAvatar(context.TODO(), user.ID)
+		require.NoError(t, err)
+
+		// Assert
+		assert.Nil(t, avatar.Image)
+		assert.Equal(t, updated.AvatarHash, avatar.Hash)
+		assert.Equal(t, "https://blobs.example.com/avatars/"+user.ID+"/"+updated.AvatarHash+"?expires=15m0s", avatar.URL)
+	})
+
+	t.Run("invalid images", func(t *testing.T) {
+		// Arrange
+		repo := repository.NewMemory()
+		user := newUser(t, repo)
+
+		svc := NewServiceDefault(zap.NewNop(), repo, WithAvatarStore(newStore(t)))
+
+		tooLarge := append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, MaxAvatarSize)...)
+
+		// Act
+		_, textErr := svc.SetAvatar(context.TODO(), user.ID, []byte("not an image"))
+		_, tooLargeErr := svc.SetAvatar(context.TODO(), user.ID, tooLarge)
+
+		// Assert
+		assert.True(t, errors.Is(textErr, ErrAvatarInvalid))
+		assert.True(t, errors.Is(tooLargeErr, ErrAvatarInvalid))
+	})
+
+	t.Run("anonymization deletes the avatar", func(t *testing.T) {
+		// Arrange
+		repo := repository.NewMemory()
+		user := newUser(t, repo)
+		store := newStore(t)
+
+		svc := NewServiceDefault(zap.NewNop(), repo, WithAvatarStore(store))
+
+		_, err := svc.SetAvatar(context.TODO(), user.ID, png)
+		require.NoError(t, err)
+
+		stored, err := repo.Get(context.TODO(), user.ID)
+		require.NoError(t, err)
+
+		// Act
+		anonymized, err := svc.AnonymizeUser(context.TODO(), user.ID)
+		require.NoError(t, err)
+
+		_, setErr := svc.SetAvatar(context.TODO(), user.ID, png)
+
+		// Assert
+		assert.Empty(t, anonymized.AvatarHash)
+		assert.True(t, errors.Is(setErr, ErrUserAnonymized))
+
+		_, blobErr := store.Get(context.TODO(), stored.AvatarKey)
+		assert.True(t, errors.Is(blobErr, blob.ErrNotFound))
+	})
+
+	t.Run("avatars disabled", func(t *testing.T) {
+		// Arrange
+		svc := NewServiceDefault(zap.NewNop(), repository.NewMemory())
+
+		// Act
+		_, setErr := svc.SetAvatar(context.TODO(), uuid.New().String(), png)
+		_, getErr := svc.GetAvatar(context.TODO(), uuid.New().String())
+
+		// Assert
+		assert.True(t, errors.Is(setErr, ErrAvatarsDisabled))
+		assert.True(t, errors.Is(getErr, ErrAvatarsDisabled))
+	})
+
+	t.Run("user not found", func(t *testing.T) {
+		// Arrange
+		svc := NewServiceDefault(zap.NewNop(), repository.NewMemory(), WithAvatarStore(newStore(t)))
+
+		// Act
+		_, setErr := svc.SetAvatar(context.TODO(), uuid.New().String(), png)
+		_, getErr := svc.GetAvatar(context.TODO(), uuid.New().String())
+
+		// Assert
+		assert.True(t, errors.Is(setErr, ErrUserNotFound))
+		assert.True(t, errors.Is(getErr, ErrUserNotFound))
+	})
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/alesr/usrsvc/pkg/uservalidation"
+)
+
+// WithMinimumAge rejects the users younger than the age, in years, given their birthdate,
+// with ErrUnderMinimumAge. The users without birthdate are not checked.
+func WithMinimumAge(years int) Option {
+	return func(s *ServiceDefault) {
+		s.minAge = years
+	}
+}
+
+// checkBirthdate returns ErrBirthdateInvalid if the birthdate, optional, is implausible
+// (see uservalidation.IsPlausibleBirthdate), or ErrUnderMinimumAge if it is too recent.
+func (s *ServiceDefault) checkBirthdate(birthdate time.Time) error {
+	if birthdate.IsZero() {
+		return nil
+	}
+
+	now := time.Now()
+	if !uservalidation.IsPlausibleBirthdate(birthdate, now) {
+		return fmt.Errorf("could not validate birthdate: %w", ErrBirthdateInvalid)
+	}
+
+	if uservalidation.Age(birthdate, now) < s.minAge {
+		return fmt.Errorf("could not validate birthdate: must be at least %d years old: %w", s.minAge, ErrUnderMinimumAge)
+	}
+	return nil
+}
+
+// birthdateToStore returns the birthdate as stored: nil if unknown.
+func birthdateToStore(birthdate time.Time) *time.Time {
+	if birthdate.IsZero() {
+		return nil
+	}
+	return &birthdate
+}
+
+// birthdateFromStore returns the stored birthdate: the zero time if unknown.
+func birthdateFromStore(birthdate *time.Time) time.Time {
+	if birthdate == nil {
+		return time.Time{}
+	}
+	return birthdate.UTC()
+}
+
+// formatBirthdate returns the stored birthdate in YYYY-MM-DD format, empty if unknown.
+func formatBirthdate(birthdate *time.Time) string {
+	if birthdate == nil {
+		return ""
+	}
+	return birthdate.UTC().Format(uservalidation.BirthdateLayout)
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alesr/usrsvc/internal/users/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestUserBirthdate(t *testing.T) {
+	t.Parallel()
+
+	newServiceHelper := func(t *testing.T, opts ...Option) *ServiceDefault {
+		t.Helper()
+
+		hasher := &hasherMock{
+			HashFunc: func(ctx context.Context, password []byte) ([]byte, error) {
+				return bcrypt.GenerateFromPassword(password, bcrypt.MinCost)
+			},
+		}
+		return NewServiceDefault(zap.NewNop(), repository.NewMemory(), append([]Option{WithHasher(hasher)}, opts...)...)
+	}
+
+	newUserHelper := func(birthdate time.Time) *User {
+		return &User{
+			FirstName: "John",
+			LastName:  "Doe",
+			Nickname:  "jdoe",
+			Password:  "password1!",
+			Email:     "joedoe@foo.bar",
+			Country:   "BR",
+			Birthdate: birthdate,
+		}
+	}
+
+	now := time.Now().UTC()
+
+	t.Run("birthdate is stored and kept on update", func(t *testing.T) {
+		// Arrange
+		svc := newServiceHelper(t, WithMinimumAge(13))
+
+		// Only the date is stored.
+		birthdate := time.Date(1990, time.February, 28, 15, 4, 5, 0, time.UTC)
+
+		// Act
+		created, err := svc.Create(context.TODO(), newUserHelper(birthdate))
+		require.NoError(t, err)
+
+		_, err = svc.Update(context.TODO(), &User{
+			ID:        created.ID,
+			FirstName: "Johnny",
+			LastName:  "Doe",
+			Nickname:  "jdoe",
+			Email:     "joedoe@foo.bar",
+			Country:   "BR",
+		})
+		require.NoError(t, err)
+
+		fetched, err := svc.Fetch(context.TODO(), created.ID)
+		require.NoError(t, err)
+
+		// Assert
+		expected := time.Date(1990, time.February, 28, 0, 0, 0, 0, time.UTC)
+		assert.True(t, expected.Equal(created.Birthdate))
+		assert.True(t, expected.Equal(fetched.Birthdate))
+	})
+
+	t.Run("users under the minimum age are rejected", func(t *testing.T) {
+		// Arrange
+		svc := newServiceHelper(t, WithMinimumAge(13))
+
+		// Act
+		underAge, underAgeErr := svc.Create(context.TODO(), newUserHelper(now.AddDate(-13, 0, 1)))
+		ofAge, ofAgeErr := svc.Create(context.TODO(), newUserHelper(now.AddDate(-13, 0, 0)))
+
+		// Assert
+		assert.Nil(t, underAge)
+		assert.True(t, errors.Is(underAgeErr, ErrUnderMinimumAge))
+
+		require.NoError(t, ofAgeErr)
+		assert.NotNil(t, ofAge)
+	})
+
+	t.Run("users under the minimum age can't be updated to it", func(t *testing.T) {
+		// Arrange
+		svc := newServiceHelper(t, WithMinimumAge(13))
+
+		created, err := svc.Create(context.TODO(), newUserHelper(time.Time{}))
+		require.NoError(t, err)
+
+		// Act
+		updated, err := svc.Update(context.TODO(), &User{
+			ID:        created.ID,
+			FirstName: "John",
+			LastName:  "Doe",
+			Nickname:  "jdoe",
+			Email:     "joedoe@foo.bar",
+			Country:   "BR",
+			Birthdate: now.AddDate(-10, 0, 0),
+		})
+
+		// Assert
+		assert.Nil(t, updated)
+		assert.True(t, errors.Is(err, ErrUnderMinimumAge))
+	})
+
+	t.Run("without minimum age", func(t *testing.T) {
+		// Arrange
+		svc := newServiceHelper(t)
+
+		// Act
+		created, err := svc.Create(context.TODO(), newUserHelper(now.AddDate(-5, 0, 0)))
+
+		// Assert
+		require.NoError(t, err)
+		assert.NotNil(t, created)
+	})
+
+	t.Run("implausible birthdate", func(t *testing.T) {
+		// Arrange
+		svc := newServiceHelper(t)
+
+		// Act
+		created, err := svc.Create(context.TODO(), newUserHelper(now.AddDate(0, 0, 2)))
+
+		// Assert
+		assert.Nil(t, created)
+		assert.True(t, errors.Is(err, ErrBirthdateInvalid))
+	})
+}
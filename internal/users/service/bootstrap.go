@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alesr/usrsvc/internal/users/repository"
+	"github.com/alesr/usrsvc/pkg/events"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// WithBootstrapToken enables the bootstrap of a fresh deployment with the given token.
+func WithBootstrapToken(token string) Option {
+	return func(s *ServiceDefault) {
+		s.bootstrapToken = token
+	}
+}
+
+// Bootstrap creates the initial admin user and an API key for it on a fresh deployment,
+// so provisioning can be automated. The token is only usable once: as soon as an admin
+// user exists, every bootstrap fails with ErrAlreadyBootstrapped.
+// It returns the admin user and the API key, which cannot be retrieved later.
+func (s *ServiceDefault) Bootstrap(ctx context.Context, token string, admin *User, keyName string) (*User, string, error) {
+	ctx, span := tracer.Start(ctx, "ServiceDefault.Bootstrap")
+	defer span.End()
+
+	if s.bootstrapToken == "" {
+		return nil, "", fmt.Errorf("could not bootstrap: %w", ErrBootstrapDisabled)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(token), []byte(s.bootstrapToken)) != 1 {
+		return nil, "", fmt.Errorf("could not bootstrap: %w", ErrBootstrapTokenInvalid)
+	}
+
+	admin.ID = uuid.New().String()
+	span.SetAttributes(attribute.String("user.id", admin.ID))
+	admin.CreatedAt = time.Now()
+	admin.UpdatedAt = admin.CreatedAt
+
+	hash, err := s.hasher.Hash(ctx, []byte(admin.Password))
+	if err != nil {
+		return nil, "", fmt.Errorf("could not hash password: %w", hashingError(err))
+	}
+	admin.Password = string(hash)
+
+	// The bootstrap key is granted every scope, to provision the service.
+	apiKey, key, err := newAPIKey(admin.ID, keyName, APIKeyScopes)
+	if err != nil {
+		return nil, "", fmt.Errorf("could not bootstrap: %w", err)
+	}
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	stored := newUserStoreFromDomain(admin)
+	if err := s.repo.Bootstrap(ctx, stored, key); err != nil {
+		switch dupErr := s.duplicateError(err); {
+		case errors.Is(err, repository.ErrAlreadyBootstrapped):
+			err = ErrAlreadyBootstrapped
+		case dupErr != nil:
+			err = dupErr
+		}
+		return nil, "", fmt.Errorf("could not bootstrap: %w", err)
+	}
+
+	s.logger.Warn("service bootstrapped", zap.String("admin_id", admin.ID), zap.String("api_key_id", key.ID))
+
+	if s.publisher != nil {
+		s.publish(ctx, events.UserCreated, userEvent(admin.ID, stored.EventSequence, admin.ID))
+	}
+
+	created := newUserDomainFromStore(stored)
+
+	// The hash never leaves the service.
+	created.Password = ""
+	return created, apiKey, nil
+}
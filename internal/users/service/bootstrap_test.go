@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/alesr/usrsvc/internal/users/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestBootstrap(t *testing.T) {
+	t.Parallel()
+
+	newAdmin := func() *User {
+		return &User{
+			FirstName: "Ada",
+			LastName:  "Admin",
+			Nickname:  "admin",
+			Password:  "some-passw0rd!",
+			Email:     "admin@foo.bar",
+			Country:   "BR",
+		}
+	}
+
+	t.Run("happy path", func(t *testing.T) {
+		// Arrange
+		var storedKey *repository.APIKey
+		repo := &repoMock{
+			BootstrapFunc: func(ctx context.Context, admin *repository.User, key *repository.APIKey) error {
+				assert.NotEqual(t, "some-passw0rd!", admin.Password)
+				storedKey = key
+				return nil
+			},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), repo, WithBootstrapToken("one-time-token"))
+
+		// Act
+		admin, apiKey, err := svc.Bootstrap(context.TODO(), "one-time-token", newAdmin(), "terraform")
+		require.NoError(t, err)
+
+		// Assert
+		assert.NotEmpty(t, admin.ID)
+		assert.Empty(t, admin.Password)
+
+		require.NotNil(t, storedKey)
+		assert.Equal(t, admin.ID, storedKey.UserID)
+		assert.Equal(t, "terraform", storedKey.Name)
+
+		require.True(t, strings.HasPrefix(apiKey, apiKeyPrefix+storedKey.ID+"."))
+		secretHash := sha256.Sum256([]byte(strings.TrimPrefix(apiKey, apiKeyPrefix+storedKey.ID+".")))
+		assert.Equal(t, secretHash[:], storedKey.SecretHash)
+	})
+
+	t.Run("only once", func(t *testing.T) {
+		// Arrange
+		svc := NewServiceDefault(zap.NewNop(), repository.NewMemory(), WithBootstrapToken("one-time-token"))
+
+		admin, _, err := svc.Bootstrap(context.TODO(), "one-time-token", newAdmin(), "terraform")
+		require.NoError(t, err)
+
+		// Act
+		second := newAdmin()
+		second.Email = "admin2@foo.bar"
+		second.Nickname = "admin2"
+
+		_, _, secondErr := svc.Bootstrap(context.TODO(), "one-time-token", second, "terraform")
+
+		// Assert
+		assert.Equal(t, repository.RoleAdmin, admin.Role)
+		assert.True(t, errors.Is(secondErr, ErrAlreadyBootstrapped))
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		// Arrange
+		svc := NewServiceDefault(zap.NewNop(), &repoMock{}, WithBootstrapToken("one-time-token"))
+
+		// Act
+		admin, apiKey, err := svc.Bootstrap(context.TODO(), "guess", newAdmin(), "terraform")
+
+		// Assert
+		assert.Nil(t, admin)
+		assert.Empty(t, apiKey)
+		assert.True(t, errors.Is(err, ErrBootstrapTokenInvalid))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		// Arrange
+		svc := NewServiceDefault(zap.NewNop(), &repoMock{})
+
+		// Act
+		_, _, err := svc.Bootstrap(context.TODO(), "", newAdmin(), "terraform")
+
+		// Assert
+		assert.True(t, errors.Is(err, ErrBootstrapDisabled))
+	})
+}
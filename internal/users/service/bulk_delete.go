@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alesr/usrsvc/internal/operations"
+	"github.com/alesr/usrsvc/internal/users/repository"
+	"github.com/alesr/usrsvc/pkg/events"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// bulkDeleteBatchSize is the number of users read per query by DeleteUsersByFilter.
+const bulkDeleteBatchSize int = 500
+
+// BulkDeleteFilter selects the users deleted by DeleteUsersByFilter. At least one field is required.
+type BulkDeleteFilter struct {
+	Country *string
+
+	// CreatedBefore (exclusive) bounds the creation time.
+	CreatedBefore *time.Time
+}
+
+// BulkDeleteReport is the outcome of DeleteUsersByFilter.
+type BulkDeleteReport struct {
+	// Scanned is the number of users selected by the filter, Matched the number of them
+	// to delete and Deleted the number of them deleted, none on dry runs.
+	Scanned int64
+	Matched int64
+	Deleted int64
+	DryRun  bool
+}
+
+// DeleteUsersByFilter soft-deletes the users selected by the filter, e.g. the stale test
+// accounts of a country, by deactivating them: they can't sign in anymore but keep their
+// data, and ReactivateUser undoes the deletion of a user. The admins, the anonymized users
+// and the users already deactivated are left as they are. A dry run only reports the users
+// it would delete.
+//
+// The users are read in batches, and the progress is reported when it runs as an operation.
+// On error, the users of the previous batches stay deleted.
+func (s *ServiceDefault) DeleteUsersByFilter(ctx context.Context, filter BulkDeleteFilter, dryRun bool) (*BulkDeleteReport, error) {
+	ctx, span := tracer.Start(ctx, "ServiceDefault.DeleteUsersByFilter")
+	defer span.End()
+
+	params := FilterParams{Country: filter.Country, CreatedBefore: filter.CreatedBefore}
+	params.normalize()
+
+	if params.Country == nil && params.CreatedBefore == nil {
+		return nil, fmt.Errorf("could not validate bulk delete filter: %w", ErrFilterRequired)
+	}
+
+	if err := params.validate(); err != nil {
+		return nil, fmt.Errorf("could not validate bulk delete filter: %w", err)
+	}
+
+	storeFilter := params.storeFilter()
+
+	var total int64
+	if operations.Tracked(ctx) {
+		countCtx, cancel := s.timeouts.WithTimeout(ctx)
+		count, err := s.repo.Count(countCtx, storeFilter)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("could not count users to delete: %w", err)
+		}
+		total = count
+	}
+
+	report := BulkDeleteReport{DryRun: dryRun}
+	defer func() {
+		span.SetAttributes(
+			attribute.Int64("users.scanned", report.Scanned),
+			attribute.Int64("users.deleted", report.Deleted),
+			attribute.Bool("dry_run", dryRun),
+		)
+		s.logger.Info("deleted users by filter",
+			zap.Int64("scanned", report.Scanned),
+			zap.Int64("matched", report.Matched),
+			zap.Int64("deleted", report.Deleted),
+			zap.Bool("dry_run", dryRun),
+		)
+	}()
+
+	var cursor string
+	for {
+		users, err := s.bulkDeletePage(ctx, storeFilter, cursor)
+		if err != nil {
+			return &report, fmt.Errorf("could not list users to delete: %w", err)
+		}
+
+		for _, user := range users {
+			if user.Role == RoleAdmin || user.Anonymized || user.Status == StatusDeactivated {
+				continue
+			}
+			report.Matched++
+
+			if dryRun {
+				continue
+			}
+
+			// The status is set again from the primary, skipping the users changed since listed.
+			if _, err := s.setStatus(ctx, user.ID, StatusDeactivated, events.UserDeactivated); err != nil {
+				if errors.Is(err, ErrUserNotFound) || errors.Is(err, ErrUserAnonymized) {
+					continue
+				}
+				return &report, fmt.Errorf("could not delete user '%s': %w", user.ID, err)
+			}
+			report.Deleted++
+		}
+
+		report.Scanned += int64(len(users))
+		operations.Report(ctx, report.Scanned, total)
+
+		if len(users) < bulkDeleteBatchSize {
+			break
+		}
+		cursor = users[len(users)-1].ID
+	}
+	return &report, nil
+}
+
+func (s *ServiceDefault) bulkDeletePage(ctx context.Context, filter repository.Filter, cursor string) ([]*repository.User, error) {
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	return s.repo.List(repository.ContextWithPrimary(ctx), filter, repository.Page{Cursor: cursor, Limit: bulkDeleteBatchSize})
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alesr/usrsvc/internal/users/repository"
+	"github.com/alesr/usrsvc/pkg/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestDeleteUsersByFilter(t *testing.T) {
+	t.Parallel()
+
+	storeHelper := func(t *testing.T) *repository.Memory {
+		t.Helper()
+
+		repo := repository.NewMemory()
+		for _, user := range []*repository.User{
+			{ID: "00000000-0000-0000-0000-000000000001", Nickname: "test1", Email: "test1@foo.bar", Country: "US"},
+			{ID: "00000000-0000-0000-0000-000000000002", Nickname: "test2", Email: "test2@foo.bar", Country: "US"},
+			{ID: "00000000-0000-0000-0000-000000000003", Nickname: "root", Email: "root@foo.bar", Country: "US", Role: RoleAdmin},
+			{ID: "00000000-0000-0000-0000-000000000004", Nickname: "jdoe", Email: "joedoe@foo.bar", Country: "BR"},
+		} {
+			require.NoError(t, repo.Insert(context.TODO(), user))
+		}
+		return repo
+	}
+
+	country := "US"
+
+	t.Run("deactivates the users selected by the filter", func(t *testing.T) {
+		// Arrange
+		repo := storeHelper(t)
+
+		var published []events.Event
+		publisher := &publisherMock{
+			PublishFunc: func(_ context.Context, event events.Event, data any) error {
+				published = append(published, event)
+				return nil
+			},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), repo, WithPublisher(publisher))
+
+		// Act
+		report, err := svc.DeleteUsersByFilter(context.TODO(), BulkDeleteFilter{Country: &country}, false)
+		require.NoError(t, err)
+
+		again, err := svc.DeleteUsersByFilter(context.TODO(), BulkDeleteFilter{Country: &country}, false)
+		require.NoError(t, err)
+
+		// Assert
+		assert.Equal(t, &BulkDeleteReport{Scanned: 3, Matched: 2, Deleted: 2}, report)
+		assert.Equal(t, &BulkDeleteReport{Scanned: 3}, again)
+		assert.Equal(t, []events.Event{events.UserDeactivated, events.UserDeactivated}, published)
+
+		for id, status := range map[string]string{
+			"00000000-0000-0000-0000-000000000001": StatusDeactivated,
+			"00000000-0000-0000-0000-000000000002": StatusDeactivated,
+			"00000000-0000-0000-0000-000000000003": StatusActive,
+			"00000000-0000-0000-0000-000000000004": StatusActive,
+		} {
+			stored, err := repo.Get(context.TODO(), id)
+			require.NoError(t, err)
+			assert.Equal(t, status, stored.Status, id)
+		}
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		// Arrange
+		repo := storeHelper(t)
+		svc := NewServiceDefault(zap.NewNop(), repo)
+
+		createdBefore := time.Now().Add(time.Hour)
+
+		// Act
+		report, err := svc.DeleteUsersByFilter(context.TODO(), BulkDeleteFilter{CreatedBefore: &createdBefore}, true)
+		require.NoError(t, err)
+
+		// Assert
+		assert.Equal(t, &BulkDeleteReport{Scanned: 4, Matched: 3, DryRun: true}, report)
+
+		stored, err := repo.Get(context.TODO(), "00000000-0000-0000-0000-000000000001")
+		require.NoError(t, err)
+		assert.Equal(t, StatusActive, stored.Status)
+	})
+
+	t.Run("when the filter is empty", func(t *testing.T) {
+		// Arrange
+		svc := NewServiceDefault(zap.NewNop(), storeHelper(t))
+
+		// Act
+		report, err := svc.DeleteUsersByFilter(context.TODO(), BulkDeleteFilter{}, false)
+
+		// Assert
+		assert.True(t, errors.Is(err, ErrFilterRequired))
+		assert.Nil(t, report)
+	})
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/alesr/usrsvc/internal/cache"
+	"github.com/alesr/usrsvc/internal/tenant"
+	"go.uber.org/zap"
+)
+
+// Cache is the key-value store used to cache users.
+type Cache interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, key string) error
+}
+
+// WithCache configures the service to cache fetched users for the given ttl.
+// Cached users are invalidated on update and delete.
+func WithCache(c Cache, ttl time.Duration) Option {
+	return func(s *ServiceDefault) {
+		s.cache = c
+		s.cacheTTL = ttl
+	}
+}
+
+func userCacheKey(id string) string {
+	return "user:" + id
+}
+
+// getCachedUser returns the cached user, if any and visible to the tenant of ctx. Cache
+// errors are logged and treated as misses so an unavailable cache only costs a trip to
+// the database.
+func (s *ServiceDefault) getCachedUser(ctx context.Context, id string) (*User, bool) {
+	if s.cache == nil {
+		return nil, false
+	}
+
+	value, err := s.cache.Get(ctx, userCacheKey(id))
+	if err != nil {
+		if !errors.Is(err, cache.ErrMiss) {
+			s.logger.Warn("could not get user from cache", zap.String("id", id), zap.Error(err))
+		}
+		return nil, false
+	}
+
+	var user User
+	if err := json.Unmarshal(value, &user); err != nil {
+		s.logger.Warn("could not unmarshal cached user", zap.String("id", id), zap.Error(err))
+		return nil, false
+	}
+
+	// The users of the other tenants are not found by the repository either.
+	if !tenant.Visible(ctx, user.TenantID) {
+		return nil, false
+	}
+	return &user, true
+}
+
+// cacheUser stores the user in the cache without the password hash.
+func (s *ServiceDefault) cacheUser(ctx context.Context, user *User) {
+	if s.cache == nil {
+		return
+	}
+
+	cached := *user
+	cached.Password = ""
+
+	value, err := json.Marshal(cached)
+	if err != nil {
+		s.logger.Warn("could not marshal user for cache", zap.String("id", user.ID), zap.Error(err))
+		return
+	}
+
+	if err := s.cache.Set(ctx, userCacheKey(user.ID), value, s.cacheTTL); err != nil {
+		s.logger.Warn("could not cache user", zap.String("id", user.ID), zap.Error(err))
+	}
+}
+
+// invalidateCachedUser removes the user from the cache.
+func (s *ServiceDefault) invalidateCachedUser(ctx context.Context, id string) {
+	if s.cache == nil {
+		return
+	}
+
+	if err := s.cache.Delete(ctx, userCacheKey(id)); err != nil {
+		s.logger.Error("could not invalidate cached user", zap.String("id", id), zap.Error(err))
+	}
+}
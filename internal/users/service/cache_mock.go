@@ -0,0 +1,27 @@
+package service
+
+import (
+	"context"
+	"time"
+)
+
+var _ Cache = (*cacheMock)(nil)
+
+// cacheMock is a mock implementation of the cache interface.
+type cacheMock struct {
+	GetFunc    func(ctx context.Context, key string) ([]byte, error)
+	SetFunc    func(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	DeleteFunc func(ctx context.Context, key string) error
+}
+
+func (c *cacheMock) Get(ctx context.Context, key string) ([]byte, error) {
+	return c.GetFunc(ctx, key)
+}
+
+func (c *cacheMock) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return c.SetFunc(ctx, key, value, ttl)
+}
+
+func (c *cacheMock) Delete(ctx context.Context, key string) error {
+	return c.DeleteFunc(ctx, key)
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alesr/usrsvc/internal/cache"
+	"github.com/alesr/usrsvc/internal/tenant"
+	"github.com/alesr/usrsvc/internal/users/repository"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// newMapCacheHelper returns a cache mock backed by a map.
+func newMapCacheHelper(t *testing.T) (*cacheMock, map[string][]byte) {
+	t.Helper()
+
+	entries := make(map[string][]byte)
+	return &cacheMock{
+		GetFunc: func(ctx context.Context, key string) ([]byte, error) {
+			value, ok := entries[key]
+			if !ok {
+				return nil, cache.ErrMiss
+			}
+			return value, nil
+		},
+		SetFunc: func(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+			entries[key] = value
+			return nil
+		},
+		DeleteFunc: func(ctx context.Context, key string) error {
+			delete(entries, key)
+			return nil
+		},
+	}, entries
+}
+
+func TestFetchWithCache(t *testing.T) {
+	t.Parallel()
+
+	storedUser := &repository.User{
+		ID:        uuid.New().String(),
+		FirstName: "John",
+		LastName:  "Doe",
+		Nickname:  "jdoe",
+		Password:  "hash",
+		Email:     "joedoe@foo.bar",
+		Country:   "US",
+		CreatedAt: time.Time{}.Add(1 * time.Second).UTC(),
+		UpdatedAt: time.Time{}.Add(2 * time.Second).UTC(),
+	}
+
+	t.Run("reads through the cache", func(t *testing.T) {
+		// Arrange
+		var getCalls int
+		repo := &repoMock{
+			GetFunc: func(ctx context.Context, id string) (*repository.User, error) {
+				getCalls++
+				return storedUser, nil
+			},
+		}
+
+		c, entries := newMapCacheHelper(t)
+		svc := NewServiceDefault(zap.NewNop(), repo, WithCache(c, time.Minute))
+
+		// Act
+		first, err := svc.Fetch(context.TODO(), storedUser.ID)
+		require.NoError(t, err)
+
+		second, err := svc.Fetch(context.TODO(), storedUser.ID)
+		require.NoError(t, err)
+
+		// Assert
+		assert.Equal(t, 1, getCalls)
+		assert.Equal(t, first.ID, second.ID)
+		assert.Equal(t, first.Email, second.Email)
+		assert.Equal(t, first.CreatedAt, second.CreatedAt)
+		assert.Empty(t, second.Password)
+		assert.NotContains(t, string(entries[userCacheKey(storedUser.ID)]), "hash")
+	})
+
+	t.Run("falls back to the repository when the cache fails", func(t *testing.T) {
+		// Arrange
+		repo := &repoMock{
+			GetFunc: func(ctx context.Context, id string) (*repository.User, error) {
+				return storedUser, nil
+			},
+		}
+
+		c := &cacheMock{
+			GetFunc: func(ctx context.Context, key string) ([]byte, error) {
+				return nil, errors.New("connection refused")
+			},
+			SetFunc: func(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+				return errors.New("connection refused")
+			},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), repo, WithCache(c, time.Minute))
+
+		// Act
+		user, err := svc.Fetch(context.TODO(), storedUser.ID)
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, storedUser.ID, user.ID)
+	})
+
+	t.Run("skips the users of other tenants", func(t *testing.T) {
+		// Arrange
+		repo := &repoMock{
+			GetFunc: func(ctx context.Context, id string) (*repository.User, error) {
+				stored := *storedUser
+				stored.TenantID = tenant.OrDefault(ctx)
+				return &stored, nil
+			},
+		}
+
+		c, _ := newMapCacheHelper(t)
+		svc := NewServiceDefault(zap.NewNop(), repo, WithCache(c, time.Minute))
+
+		_, err := svc.Fetch(tenant.ContextWithTenant(context.TODO(), "acme"), storedUser.ID)
+		require.NoError(t, err)
+
+		// Act
+		user, err := svc.Fetch(tenant.ContextWithTenant(context.TODO(), "globex"), storedUser.ID)
+		require.NoError(t, err)
+
+		// Assert
+		assert.Equal(t, "globex", user.TenantID)
+	})
+
+	t.Run("invalidates on update and delete", func(t *testing.T) {
+		// Arrange
+		repo := &repoMock{
+			GetFunc: func(ctx context.Context, id string) (*repository.User, error) {
+				stored := *storedUser
+				return &stored, nil
+			},
+			GetFieldLocksFunc: noFieldLocks,
+			UpdateFunc:        func(ctx context.Context, user *repository.User) error { return nil },
+			DeleteFunc:        func(ctx context.Context, id string) (int64, error) { return 2, nil },
+		}
+
+		c, entries := newMapCacheHelper(t)
+		svc := NewServiceDefault(zap.NewNop(), repo, WithCache(c, time.Minute))
+
+		key := userCacheKey(storedUser.ID)
+
+		// Act & Assert
+		entries[key] = []byte("{}")
+		_, err := svc.Update(context.TODO(), &User{ID: storedUser.ID})
+		require.NoError(t, err)
+		assert.NotContains(t, entries, key)
+
+		entries[key] = []byte("{}")
+		require.NoError(t, svc.Delete(context.TODO(), storedUser.ID))
+		assert.NotContains(t, entries, key)
+	})
+}
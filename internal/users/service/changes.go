@@ -0,0 +1,290 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alesr/usrsvc/internal/changes"
+	"github.com/alesr/usrsvc/internal/users/repository"
+	"github.com/alesr/usrsvc/pkg/events"
+	"github.com/alesr/usrsvc/pkg/uservalidation"
+	"go.uber.org/zap"
+)
+
+var _ Publisher = (*ChangeRecorder)(nil)
+
+// ChangeLog records the changes made to the users, for downstream systems to sync them.
+type ChangeLog interface {
+	Record(ctx context.Context, change *changes.Change) error
+	List(ctx context.Context, filter changes.Filter) ([]*changes.Change, error)
+
+	// EraseSnapshots erases the snapshots of the changes of the user recorded so far.
+	EraseSnapshots(ctx context.Context, userID string) error
+}
+
+// WithChangeLog configures the service to list the changes recorded by a ChangeRecorder.
+func WithChangeLog(log ChangeLog) Option {
+	return func(s *ServiceDefault) {
+		s.changeLog = log
+	}
+}
+
+// UserChange is a change of a user, with the user after the change. The user is nil
+// for the deletions, and for the changes whose snapshot was erased by a later one.
+type UserChange struct {
+	ID        int64
+	Type      changes.Type
+	UserID    string
+	User      *User
+	CreatedAt time.Time
+}
+
+// ListUserChanges lists up to limit changes recorded after the change since, oldest first.
+func (s *ServiceDefault) ListUserChanges(ctx context.Context, since int64, limit int) ([]*UserChange, error) {
+	ctx, span := tracer.Start(ctx, "ServiceDefault.ListUserChanges")
+	defer span.End()
+
+	if s.changeLog == nil {
+		return nil, fmt.Errorf("could not list user changes: %w", ErrChangeLogDisabled)
+	}
+
+	userChanges, err := s.readChanges(ctx, since, limit)
+	if err != nil {
+		return nil, fmt.Errorf("could not list user changes: %w", err)
+	}
+
+	// The hash never leaves the service.
+	for _, change := range userChanges {
+		if change.User != nil {
+			change.User.Password = ""
+		}
+	}
+	return userChanges, nil
+}
+
+// readChanges reads up to limit changes recorded after the change since, with the
+// password hash in the snapshots.
+func (s *ServiceDefault) readChanges(ctx context.Context, since int64, limit int) ([]*UserChange, error) {
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	recorded, err := s.changeLog.List(ctx, changes.Filter{Since: since, Limit: limit})
+	if err != nil {
+		return nil, err
+	}
+
+	userChanges := make([]*UserChange, 0, len(recorded))
+	for _, change := range recorded {
+		userChange := UserChange{
+			ID:        change.ID,
+			Type:      change.Type,
+			UserID:    change.UserID,
+			CreatedAt: change.CreatedAt,
+		}
+
+		if len(change.Snapshot) > 0 {
+			var snapshot userSnapshot
+			if err := json.Unmarshal(change.Snapshot, &snapshot); err != nil {
+				return nil, fmt.Errorf("could not unmarshal snapshot of user change %d: %w", change.ID, err)
+			}
+			userChange.User = snapshot.user()
+		}
+		userChanges = append(userChanges, &userChange)
+	}
+	return userChanges, nil
+}
+
+// userGetter reads the users after their change.
+type userGetter interface {
+	Get(ctx context.Context, id string) (*repository.User, error)
+}
+
+// ChangeRecorder records every change of the users in a change log, with a snapshot of
+// the user after the change. It implements Publisher so it can be fanned out the user
+// events: since they only carry the user id, the user is read from the primary. When a
+// user is deleted or anonymized, the snapshots recorded so far are erased, so the change
+// log doesn't keep the personal data the user had. The snapshots keep the password hash,
+// for ReplayChanges to rebuild the users, but ListUserChanges never returns it.
+type ChangeRecorder struct {
+	logger *zap.Logger
+	users  userGetter
+	log    ChangeLog
+	now    func() time.Time
+}
+
+// NewChangeRecorder creates a change recorder reading the users from the repository.
+func NewChangeRecorder(logger *zap.Logger, users userGetter, log ChangeLog) *ChangeRecorder {
+	return &ChangeRecorder{
+		logger: logger,
+		users:  users,
+		log:    log,
+		now:    time.Now,
+	}
+}
+
+// Publish records the change of the user event. The other events are ignored. The change
+// is recorded on its own deadline, so a request about to time out still records it.
+func (r *ChangeRecorder) Publish(_ context.Context, event events.Event, data any) error {
+	ordered, ok := data.(events.Ordered)
+	if !ok {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	switch event {
+	case events.UserCreated:
+		return r.record(ctx, changes.TypeCreated, ordered.Key)
+	case events.UserUpdated, events.UserSuspended, events.UserDeactivated, events.UserReactivated:
+		return r.record(ctx, changes.TypeUpdated, ordered.Key)
+	case events.UserAnonymized:
+		if err := r.erase(ctx, ordered.Key); err != nil {
+			return err
+		}
+		return r.record(ctx, changes.TypeUpdated, ordered.Key)
+	case events.UserDeleted:
+		return r.delete(ctx, ordered.Key)
+	case events.UserMerged:
+		if merged, ok := ordered.Data.(events.UserMergedData); ok {
+			if err := r.delete(ctx, merged.DuplicateID); err != nil {
+				return err
+			}
+		}
+		return r.record(ctx, changes.TypeUpdated, ordered.Key)
+	}
+	return nil
+}
+
+// record records the change with a snapshot of the user. A user deleted since the
+// change is skipped: its deletion is recorded next.
+func (r *ChangeRecorder) record(ctx context.Context, changeType changes.Type, userID string) error {
+	user, err := r.users.Get(repository.ContextWithPrimary(ctx), userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			r.logger.Info("skipping change of deleted user", zap.String("user_id", userID))
+			return nil
+		}
+		return fmt.Errorf("could not read user '%s' to record its change: %w", userID, err)
+	}
+
+	snapshot, err := json.Marshal(newUserSnapshot(user))
+	if err != nil {
+		return fmt.Errorf("could not marshal snapshot of user '%s': %w", userID, err)
+	}
+
+	return r.append(ctx, &changes.Change{
+		Type:      changeType,
+		UserID:    userID,
+		Snapshot:  snapshot,
+		CreatedAt: r.now().UTC(),
+	})
+}
+
+// delete records the deletion of the user and erases its snapshots.
+func (r *ChangeRecorder) delete(ctx context.Context, userID string) error {
+	if err := r.erase(ctx, userID); err != nil {
+		return err
+	}
+
+	return r.append(ctx, &changes.Change{
+		Type:      changes.TypeDeleted,
+		UserID:    userID,
+		CreatedAt: r.now().UTC(),
+	})
+}
+
+func (r *ChangeRecorder) erase(ctx context.Context, userID string) error {
+	if err := r.log.EraseSnapshots(ctx, userID); err != nil {
+		return fmt.Errorf("could not erase snapshots of user '%s': %w", userID, err)
+	}
+	return nil
+}
+
+func (r *ChangeRecorder) append(ctx context.Context, change *changes.Change) error {
+	if err := r.log.Record(ctx, change); err != nil {
+		return fmt.Errorf("could not record %s change of user '%s': %w", change.Type, change.UserID, err)
+	}
+	return nil
+}
+
+// userSnapshot is the stored form of the user after a change.
+type userSnapshot struct {
+	ID            string            `json:"id"`
+	FirstName     string            `json:"first_name"`
+	LastName      string            `json:"last_name"`
+	Nickname      string            `json:"nickname"`
+	Password      string            `json:"password,omitempty"`
+	Email         string            `json:"email"`
+	Country       string            `json:"country"`
+	Role          string            `json:"role"`
+	Status        string            `json:"status"`
+	Anonymized    bool              `json:"anonymized,omitempty"`
+	Metadata      map[string]string `json:"metadata,omitempty"`
+	AvatarHash    string            `json:"avatar_hash,omitempty"`
+	Phone         string            `json:"phone,omitempty"`
+	PhoneVerified bool              `json:"phone_verified,omitempty"`
+	Locale        string            `json:"locale,omitempty"`
+	Timezone      string            `json:"timezone,omitempty"`
+	Birthdate     string            `json:"birthdate,omitempty"`
+	TenantID      string            `json:"tenant_id,omitempty"`
+	CreatedAt     time.Time         `json:"created_at"`
+	UpdatedAt     time.Time         `json:"updated_at"`
+}
+
+func newUserSnapshot(user *repository.User) userSnapshot {
+	return userSnapshot{
+		ID:            user.ID,
+		FirstName:     user.FirstName,
+		LastName:      user.LastName,
+		Nickname:      user.Nickname,
+		Password:      user.Password,
+		Email:         user.Email,
+		Country:       user.Country,
+		Role:          user.Role,
+		Status:        user.Status,
+		Anonymized:    user.Anonymized,
+		Metadata:      map[string]string(user.Metadata),
+		AvatarHash:    user.AvatarHash,
+		Phone:         user.Phone,
+		PhoneVerified: user.PhoneVerified,
+		Locale:        user.Locale,
+		Timezone:      user.Timezone,
+		Birthdate:     formatBirthdate(user.Birthdate),
+		TenantID:      user.TenantID,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+	}
+}
+
+func (s userSnapshot) user() *User {
+	user := User{
+		ID:            s.ID,
+		FirstName:     s.FirstName,
+		LastName:      s.LastName,
+		Nickname:      s.Nickname,
+		Password:      s.Password,
+		Email:         s.Email,
+		Country:       s.Country,
+		Role:          s.Role,
+		Status:        s.Status,
+		CreatedAt:     s.CreatedAt,
+		UpdatedAt:     s.UpdatedAt,
+		Anonymized:    s.Anonymized,
+		Metadata:      s.Metadata,
+		AvatarHash:    s.AvatarHash,
+		Phone:         s.Phone,
+		PhoneVerified: s.PhoneVerified,
+		Locale:        s.Locale,
+		Timezone:      s.Timezone,
+		TenantID:      s.TenantID,
+	}
+
+	if s.Birthdate != "" {
+		user.Birthdate, _ = time.Parse(uservalidation.BirthdateLayout, s.Birthdate)
+	}
+	return &user
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alesr/usrsvc/internal/changes"
+	"github.com/alesr/usrsvc/internal/users/repository"
+	"github.com/alesr/usrsvc/pkg/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestChangeRecorder(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	hasher := &hasherMock{
+		HashFunc: func(ctx context.Context, password []byte) ([]byte, error) {
+			return bcrypt.GenerateFromPassword(password, bcrypt.MinCost)
+		},
+	}
+
+	repo := repository.NewMemory()
+	changeLog := changes.NewMemory()
+	svc := NewServiceDefault(zap.NewNop(), repo,
+		WithHasher(hasher),
+		WithPublisher(NewChangeRecorder(zap.NewNop(), repo, changeLog)),
+		WithChangeLog(changeLog),
+	)
+
+	newUser := func(nickname string) *User {
+		user, err := svc.Create(context.TODO(), &User{
+			FirstName: "John",
+			LastName:  "Doe",
+			Nickname:  nickname,
+			Password:  "password1!",
+			Email:     nickname + "@foo.bar",
+			Country:   "US",
+			Metadata:  map[string]string{"theme": "dark"},
+		})
+		require.NoError(t, err)
+		return user
+	}
+
+	kept, deleted := newUser("jdoe"), newUser("jroe")
+
+	// Act
+	_, err := svc.SuspendUser(context.TODO(), kept.ID)
+	require.NoError(t, err)
+
+	require.NoError(t, svc.Delete(context.TODO(), deleted.ID))
+
+	firstPage, err := svc.ListUserChanges(context.TODO(), 0, 3)
+	require.NoError(t, err)
+
+	secondPage, err := svc.ListUserChanges(context.TODO(), firstPage[2].ID, 3)
+	require.NoError(t, err)
+
+	// Assert
+	require.Len(t, firstPage, 3)
+	require.Len(t, secondPage, 1)
+
+	assert.Equal(t, changes.TypeCreated, firstPage[0].Type)
+	assert.Equal(t, kept.ID, firstPage[0].UserID)
+	require.NotNil(t, firstPage[0].User)
+	assert.Equal(t, "jdoe", firstPage[0].User.Nickname)
+	assert.Equal(t, map[string]string{"theme": "dark"}, firstPage[0].User.Metadata)
+	assert.Empty(t, firstPage[0].User.Password)
+
+	// The snapshots of the deleted user are erased.
+	assert.Equal(t, changes.TypeCreated, firstPage[1].Type)
+	assert.Equal(t, deleted.ID, firstPage[1].UserID)
+	assert.Nil(t, firstPage[1].User)
+
+	assert.Equal(t, changes.TypeUpdated, firstPage[2].Type)
+	require.NotNil(t, firstPage[2].User)
+	assert.Equal(t, StatusSuspended, firstPage[2].User.Status)
+
+	assert.Equal(t, changes.TypeDeleted, secondPage[0].Type)
+	assert.Equal(t, deleted.ID, secondPage[0].UserID)
+	assert.Nil(t, secondPage[0].User)
+}
+
+func TestChangeRecorderErrors(t *testing.T) {
+	t.Parallel()
+
+	t.Run("user deleted since the change", func(t *testing.T) {
+		// Arrange
+		changeLog := changes.NewMemory()
+		recorder := NewChangeRecorder(zap.NewNop(), repository.NewMemory(), changeLog)
+
+		// Act
+		err := recorder.Publish(context.TODO(), events.UserUpdated, userEvent("user-id", 2, "user-id"))
+
+		// Assert
+		require.NoError(t, err)
+
+		recorded, err := changeLog.List(context.TODO(), changes.Filter{Limit: 10})
+		require.NoError(t, err)
+		assert.Empty(t, recorded)
+	})
+
+	t.Run("repository failure", func(t *testing.T) {
+		// Arrange
+		repo := &repoMock{
+			GetFunc: func(ctx context.Context, id string) (*repository.User, error) {
+				return nil, errors.New("connection refused")
+			},
+		}
+		recorder := NewChangeRecorder(zap.NewNop(), repo, changes.NewMemory())
+
+		// Act
+		err := recorder.Publish(context.TODO(), events.UserUpdated, userEvent("user-id", 2, "user-id"))
+
+		// Assert
+		assert.Error(t, err)
+	})
+}
+
+func TestListUserChangesDisabled(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	svc := NewServiceDefault(zap.NewNop(), &repoMock{})
+
+	// Act
+	userChanges, err := svc.ListUserChanges(context.TODO(), 0, 10)
+
+	// Assert
+	assert.Nil(t, userChanges)
+	assert.True(t, errors.Is(err, ErrChangeLogDisabled))
+}
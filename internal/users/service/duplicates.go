@@ -0,0 +1,249 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/alesr/usrsvc/internal/operations"
+	"github.com/alesr/usrsvc/internal/users/repository"
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	// duplicateScanBatchSize is the number of users read per query while scanning for duplicates.
+	duplicateScanBatchSize int = 500
+
+	// minEmailSimilarity is the minimum similarity between the local parts of two emails
+	// on the same domain for the accounts to be reported as likely duplicates.
+	minEmailSimilarity float64 = 0.8
+)
+
+// DuplicateCandidate is a pair of accounts that likely belong to the same person.
+// Survivor is the account we suggest to keep when merging, Duplicate the one to merge into it.
+type DuplicateCandidate struct {
+	Survivor  *User
+	Duplicate *User
+	Score     float64
+	Reasons   []string
+}
+
+// DuplicateReport is the result of a duplicate accounts scan, meant to be reviewed by support.
+type DuplicateReport struct {
+	Candidates   []DuplicateCandidate
+	ScannedUsers int
+	GeneratedAt  time.Time
+}
+
+// FindDuplicates scans the users (optionally from a single country) for accounts with the same
+// normalized name and country and similar emails. Candidates are sorted by score, highest first.
+func (s *ServiceDefault) FindDuplicates(ctx context.Context, filter FilterParams) (*DuplicateReport, error) {
+	ctx, span := tracer.Start(ctx, "ServiceDefault.FindDuplicates")
+	defer span.End()
+
+	filter.normalize()
+	if filter.Country != nil {
+		if err := filter.validate(); err != nil {
+			return nil, fmt.Errorf("could not validate find duplicates filter: %w", err)
+		}
+	}
+
+	var storeFilter repository.Filter
+	if filter.Country != nil {
+		storeFilter.Country = *filter.Country
+	}
+
+	// The scan reports its progress when it runs as an operation.
+	var total int64
+	if operations.Tracked(ctx) {
+		countCtx, cancel := s.timeouts.WithTimeout(ctx)
+		count, err := s.repo.Count(countCtx, storeFilter)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("could not count users to scan for duplicates: %w", err)
+		}
+		total = count
+	}
+
+	groups := make(map[string][]*User)
+
+	var (
+		cursor  string
+		scanned int
+	)
+	for {
+		users, err := s.duplicateScanPage(ctx, storeFilter, cursor)
+		if err != nil {
+			return nil, fmt.Errorf("could not scan users for duplicates: %w", err)
+		}
+
+		for _, user := range users {
+			u := newUserDomainFromStore(user)
+			u.Password = ""
+
+			key := normalizeName(u.FirstName+" "+u.LastName) + "|" + u.Country
+			groups[key] = append(groups[key], u)
+		}
+
+		scanned += len(users)
+		operations.Report(ctx, int64(scanned), total)
+
+		if len(users) < duplicateScanBatchSize {
+			break
+		}
+		cursor = users[len(users)-1].ID
+	}
+
+	span.SetAttributes(attribute.Int("users.scanned", scanned))
+
+	report := DuplicateReport{
+		ScannedUsers: scanned,
+		GeneratedAt:  time.Now(),
+	}
+
+	for _, group := range groups {
+		for i := 0; i < len(group); i++ {
+			for j := i + 1; j < len(group); j++ {
+				if candidate, ok := compareAccounts(group[i], group[j]); ok {
+					report.Candidates = append(report.Candidates, candidate)
+				}
+			}
+		}
+	}
+
+	sort.Slice(report.Candidates, func(i, j int) bool {
+		a, b := report.Candidates[i], report.Candidates[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		return a.Survivor.ID < b.Survivor.ID
+	})
+	return &report, nil
+}
+
+func (s *ServiceDefault) duplicateScanPage(ctx context.Context, filter repository.Filter, cursor string) ([]*repository.User, error) {
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	return s.repo.List(ctx, filter, repository.Page{Cursor: cursor, Limit: duplicateScanBatchSize})
+}
+
+// compareAccounts compares two accounts that already share the same normalized name and country.
+// The oldest account is suggested as the survivor since it is the one most likely in use.
+func compareAccounts(a, b *User) (DuplicateCandidate, bool) {
+	reasons := []string{"same name and country"}
+
+	aLocal, aDomain := normalizeEmail(a.Email)
+	bLocal, bDomain := normalizeEmail(b.Email)
+
+	var score float64
+	switch {
+	case aLocal == bLocal && aDomain == bDomain:
+		score = 1
+		reasons = append(reasons, "same normalized email")
+	case aDomain == bDomain:
+		score = similarity(aLocal, bLocal)
+		if score < minEmailSimilarity {
+			return DuplicateCandidate{}, false
+		}
+		reasons = append(reasons, fmt.Sprintf("similar email (%.0f%%)", score*100))
+	case aLocal == bLocal:
+		score = minEmailSimilarity
+		reasons = append(reasons, "same email name on a different domain")
+	default:
+		return DuplicateCandidate{}, false
+	}
+
+	if strings.EqualFold(a.Nickname, b.Nickname) {
+		reasons = append(reasons, "same nickname")
+	}
+
+	survivor, duplicate := a, b
+	if b.CreatedAt.Before(a.CreatedAt) || (b.CreatedAt.Equal(a.CreatedAt) && b.ID < a.ID) {
+		survivor, duplicate = b, a
+	}
+
+	return DuplicateCandidate{
+		Survivor:  survivor,
+		Duplicate: duplicate,
+		Score:     score,
+		Reasons:   reasons,
+	}, true
+}
+
+// normalizeName lowercases the name, removes accents and collapses whitespace,
+// so "José  Silva" and "jose silva" are considered the same name.
+func normalizeName(name string) string {
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	folded, _, err := transform.String(t, name)
+	if err != nil {
+		folded = name
+	}
+	return strings.Join(strings.Fields(strings.ToLower(folded)), " ")
+}
+
+// normalizeEmail splits the email into its local part and domain, dropping
+// "+tag" suffixes and dots from the local part since most providers ignore them.
+func normalizeEmail(email string) (string, string) {
+	email = strings.ToLower(strings.TrimSpace(email))
+
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return email, ""
+	}
+
+	local, domain := email[:at], email[at+1:]
+	if plus := strings.Index(local, "+"); plus >= 0 {
+		local = local[:plus]
+	}
+	return strings.ReplaceAll(local, ".", ""), domain
+}
+
+// similarity returns a score between 0 and 1 based on the edit distance between a and b.
+func similarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			curr[j] = prev[j] + 1
+			if ins := curr[j-1] + 1; ins < curr[j] {
+				curr[j] = ins
+			}
+			if sub := prev[j-1] + cost; sub < curr[j] {
+				curr[j] = sub
+			}
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alesr/usrsvc/internal/operations"
+	"github.com/alesr/usrsvc/internal/users/repository"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestFindDuplicates(t *testing.T) {
+	t.Parallel()
+
+	newUser := func(firstName, lastName, email, country string, createdAt time.Duration) *repository.User {
+		return &repository.User{
+			ID:        uuid.New().String(),
+			FirstName: firstName,
+			LastName:  lastName,
+			Nickname:  email,
+			Password:  "hash",
+			Email:     email,
+			Country:   country,
+			CreatedAt: time.Time{}.Add(createdAt),
+			UpdatedAt: time.Time{}.Add(createdAt),
+		}
+	}
+
+	t.Run("reports likely duplicates", func(t *testing.T) {
+		// Arrange
+		repo := repository.NewMemory()
+
+		original := newUser("José", "Silva", "jose.silva@foo.bar", "BR", 1*time.Second)
+		sameEmail := newUser("jose", "silva ", "josesilva+news@foo.bar", "BR", 2*time.Second)
+		typo := newUser("Jose", "Silva", "josesilvaa@foo.bar", "BR", 3*time.Second)
+		otherCountry := newUser("José", "Silva", "jose.silva@foo.baz", "PT", 4*time.Second)
+		otherEmail := newUser("José", "Silva", "zezinho@foo.bar", "BR", 5*time.Second)
+
+		for _, user := range []*repository.User{typo, sameEmail, original, otherCountry, otherEmail} {
+			require.NoError(t, repo.Insert(context.TODO(), user))
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), repo)
+
+		// Act
+		report, err := svc.FindDuplicates(context.TODO(), FilterParams{})
+		require.NoError(t, err)
+
+		// Assert
+		assert.Equal(t, 5, report.ScannedUsers)
+		require.Len(t, report.Candidates, 3)
+
+		first := report.Candidates[0]
+		assert.Equal(t, original.ID, first.Survivor.ID)
+		assert.Equal(t, sameEmail.ID, first.Duplicate.ID)
+		assert.Equal(t, float64(1), first.Score)
+		assert.Contains(t, first.Reasons, "same normalized email")
+		assert.Empty(t, first.Survivor.Password)
+
+		for _, candidate := range report.Candidates[1:] {
+			assert.Less(t, candidate.Score, float64(1))
+			assert.Equal(t, typo.ID, candidate.Duplicate.ID)
+		}
+	})
+
+	t.Run("filters by country", func(t *testing.T) {
+		// Arrange
+		repo := repository.NewMemory()
+		require.NoError(t, repo.Insert(context.TODO(), newUser("John", "Doe", "johndoe@foo.bar", "US", 1*time.Second)))
+		require.NoError(t, repo.Insert(context.TODO(), newUser("John", "Doe", "john.doe@foo.bar", "US", 2*time.Second)))
+		require.NoError(t, repo.Insert(context.TODO(), newUser("Jane", "Doe", "janedoe@foo.bar", "BR", 3*time.Second)))
+
+		svc := NewServiceDefault(zap.NewNop(), repo)
+		country := "br"
+
+		// Act
+		report, err := svc.FindDuplicates(context.TODO(), FilterParams{Country: &country})
+		require.NoError(t, err)
+
+		// Assert
+		assert.Equal(t, 1, report.ScannedUsers)
+		assert.Empty(t, report.Candidates)
+	})
+
+	t.Run("reports the progress of the operation", func(t *testing.T) {
+		// Arrange
+		repo := repository.NewMemory()
+		require.NoError(t, repo.Insert(context.TODO(), newUser("John", "Doe", "johndoe@foo.bar", "US", 1*time.Second)))
+		require.NoError(t, repo.Insert(context.TODO(), newUser("Jane", "Doe", "janedoe@foo.bar", "BR", 2*time.Second)))
+
+		svc := NewServiceDefault(zap.NewNop(), repo)
+		manager := operations.NewManager(time.Hour)
+
+		// Act
+		started, err := manager.Start(context.TODO(), "find_duplicate_users", func(ctx context.Context) (any, error) {
+			return svc.FindDuplicates(ctx, FilterParams{})
+		})
+		require.NoError(t, err)
+
+		// Assert
+		var op *operations.Operation
+		require.Eventually(t, func() bool {
+			op, err = manager.Get(started.ID)
+			require.NoError(t, err)
+			return op.Done
+		}, time.Second, time.Millisecond)
+
+		require.NoError(t, op.Err)
+		assert.Equal(t, int64(2), op.Processed)
+		assert.Equal(t, int64(2), op.Total)
+	})
+
+	t.Run("invalid country", func(t *testing.T) {
+		// Arrange
+		svc := NewServiceDefault(zap.NewNop(), repository.NewMemory())
+		country := "BRA"
+
+		// Act
+		report, err := svc.FindDuplicates(context.TODO(), FilterParams{Country: &country})
+
+		// Assert
+		assert.Nil(t, report)
+		assert.True(t, errors.Is(err, ErrCountryCodeInvalid))
+	})
+}
+
+func TestSimilarity(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		a, b     string
+		expected float64
+	}{
+		{name: "equal", a: "johndoe", b: "johndoe", expected: 1},
+		{name: "one typo", a: "johndoe", b: "johndoa", expected: 1 - 1.0/7},
+		{name: "completely different", a: "abc", b: "xyz", expected: 0},
+		{name: "both empty", a: "", b: "", expected: 1},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.InDelta(t, tc.expected, similarity(tc.a, tc.b), 0.0001)
+		})
+	}
+}
@@ -5,8 +5,55 @@ import "errors"
 var (
 	// Enumerate all the errors that can be returned by the service.
 
-	ErrCountryCodeInvalid error = errors.New("invalid country code")
-	ErrInvalidID          error = errors.New("invalid id")
-	ErrUserAlreadyExists  error = errors.New("user already exists")
-	ErrUserNotFound       error = errors.New("user not found")
+	ErrAlreadyBootstrapped       error = errors.New("service already bootstrapped")
+	ErrAPIKeyNotFound            error = errors.New("api key not found")
+	ErrAPIKeyScopesInvalid       error = errors.New("invalid api key scopes")
+	ErrAuditDisabled             error = errors.New("audit log is disabled")
+	ErrAvatarInvalid             error = errors.New("invalid avatar image")
+	ErrAvatarNotFound            error = errors.New("avatar not found")
+	ErrAvatarsDisabled           error = errors.New("avatars are disabled")
+	ErrBirthdateInvalid          error = errors.New("invalid birthdate")
+	ErrBootstrapDisabled         error = errors.New("bootstrap is disabled")
+	ErrBootstrapTokenInvalid     error = errors.New("invalid bootstrap token")
+	ErrChangeLogDisabled         error = errors.New("change log is disabled")
+	ErrCountryCodeInvalid        error = errors.New("invalid country code")
+	ErrCreatedRangeInvalid       error = errors.New("invalid creation time range")
+	ErrEmailAmbiguous            error = errors.New("email is used by several users")
+	ErrFieldLocked               error = errors.New("field is locked")
+	ErrFieldNotLockable          error = errors.New("field cannot be locked")
+	ErrFilterRequired            error = errors.New("filter required")
+	ErrIdentityEmailMismatch     error = errors.New("identity email is not the verified email of the user")
+	ErrIdentityLinked            error = errors.New("identity already linked to a user")
+	ErrIdentityNotFound          error = errors.New("identity not linked to the user")
+	ErrIdentityProvider          error = errors.New("unknown identity provider")
+	ErrInvalidCredentials        error = errors.New("invalid credentials")
+	ErrInvalidID                 error = errors.New("invalid id")
+	ErrInvalidIDToken            error = errors.New("invalid id token")
+	ErrLocaleInvalid             error = errors.New("invalid locale")
+	ErrMergeSameUser             error = errors.New("cannot merge a user into itself")
+	ErrMetadataInvalid           error = errors.New("invalid metadata")
+	ErrNicknameTaken             error = errors.New("nickname already taken")
+	ErrPasswordBreached          error = errors.New("password found in a data breach")
+	ErrPhoneCodeInvalid          error = errors.New("invalid or expired phone verification code")
+	ErrPhoneInvalid              error = errors.New("invalid phone number")
+	ErrPhoneNotSet               error = errors.New("phone number not set")
+	ErrPhoneVerificationDisabled error = errors.New("phone verification is disabled")
+	ErrRegionChange              error = errors.New("user cannot be moved to another data region")
+	ErrResetTokenInvalid         error = errors.New("invalid or expired password reset token")
+	ErrSearchQueryInvalid        error = errors.New("invalid search query")
+	ErrServiceBusy               error = errors.New("service is busy")
+	ErrSessionNotFound           error = errors.New("session not found")
+	ErrTOTPDisabled              error = errors.New("totp is disabled")
+	ErrTOTPEnabled               error = errors.New("totp already enabled")
+	ErrTOTPInvalid               error = errors.New("invalid totp code")
+	ErrTOTPNotEnrolled           error = errors.New("totp not enrolled")
+	ErrTOTPRequired              error = errors.New("totp code required")
+	ErrTimezoneInvalid           error = errors.New("invalid timezone")
+	ErrUnderMinimumAge           error = errors.New("user is under the minimum age")
+	ErrUserAlreadyExists         error = errors.New("user already exists")
+	ErrUserAnonymized            error = errors.New("user is anonymized")
+	ErrUserDeactivated           error = errors.New("user is deactivated")
+	ErrUserLocked                error = errors.New("user is locked out after too many failed logins")
+	ErrUserNotFound              error = errors.New("user not found")
+	ErrUserSuspended             error = errors.New("user is suspended")
 )
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/alesr/usrsvc/internal/users/repository"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// exportBatchSize is the number of users read per query by ExportUsers.
+const exportBatchSize int = 500
+
+// ExportUsers calls fn with each user selected by the filter, ordered by id, e.g. to dump
+// them for the analytics. The users are read in batches, so the export can be as large as
+// the store, and without password. It stops at the first error of fn.
+func (s *ServiceDefault) ExportUsers(ctx context.Context, filter FilterParams, fn func(user *User) error) error {
+	ctx, span := tracer.Start(ctx, "ServiceDefault.ExportUsers")
+	defer span.End()
+
+	filter.normalize()
+	if err := filter.validate(); err != nil {
+		return fmt.Errorf("could not validate export filter: %w", err)
+	}
+
+	storeFilter := filter.storeFilter()
+
+	var (
+		cursor   string
+		exported int
+	)
+	defer func() {
+		span.SetAttributes(attribute.Int("users.exported", exported))
+		s.logger.Info("exported users", zap.Int("exported", exported))
+	}()
+
+	for {
+		users, err := s.exportPage(ctx, storeFilter, cursor)
+		if err != nil {
+			return fmt.Errorf("could not list users to export: %w", err)
+		}
+
+		for _, stored := range users {
+			user := newUserDomainFromStore(stored)
+
+			// The hash never leaves the service.
+			user.Password = ""
+
+			if err := fn(user); err != nil {
+				return err
+			}
+			exported++
+		}
+
+		if len(users) < exportBatchSize {
+			return nil
+		}
+		cursor = users[len(users)-1].ID
+	}
+}
+
+func (s *ServiceDefault) exportPage(ctx context.Context, filter repository.Filter, cursor string) ([]*repository.User, error) {
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	return s.repo.List(ctx, filter, repository.Page{Cursor: cursor, Limit: exportBatchSize})
+}
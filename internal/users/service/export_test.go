@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/alesr/usrsvc/internal/users/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestExportUsers(t *testing.T) {
+	t.Parallel()
+
+	repo := repository.NewMemory()
+	for i := 0; i < exportBatchSize+1; i++ {
+		country := "US"
+		if i%2 == 1 {
+			country = "BR"
+		}
+
+		require.NoError(t, repo.Insert(context.TODO(), &repository.User{
+			ID:       fmt.Sprintf("00000000-0000-0000-0000-%012d", i),
+			Nickname: fmt.Sprintf("user%d", i),
+			Email:    fmt.Sprintf("user%d@foo.bar", i),
+			Password: "hash",
+			Country:  country,
+		}))
+	}
+
+	svc := NewServiceDefault(zap.NewNop(), repo)
+
+	t.Run("exports every user across batches", func(t *testing.T) {
+		// Arrange
+		var exported []*User
+
+		// Act
+		err := svc.ExportUsers(context.TODO(), FilterParams{}, func(user *User) error {
+			exported = append(exported, user)
+			return nil
+		})
+
+		// Assert
+		require.NoError(t, err)
+		require.Len(t, exported, exportBatchSize+1)
+
+		for i, user := range exported {
+			assert.Equal(t, fmt.Sprintf("00000000-0000-0000-0000-%012d", i), user.ID)
+			assert.Empty(t, user.Password)
+		}
+	})
+
+	t.Run("exports the users selected by the filter", func(t *testing.T) {
+		// Arrange
+		country := "br"
+
+		var exported int
+
+		// Act
+		err := svc.ExportUsers(context.TODO(), FilterParams{Country: &country}, func(user *User) error {
+			assert.Equal(t, "BR", user.Country)
+			exported++
+			return nil
+		})
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, exportBatchSize/2, exported)
+	})
+
+	t.Run("stops at the first error", func(t *testing.T) {
+		// Arrange
+		someErr := errors.New("some error")
+
+		var calls int
+
+		// Act
+		err := svc.ExportUsers(context.TODO(), FilterParams{}, func(user *User) error {
+			calls++
+			return someErr
+		})
+
+		// Assert
+		assert.Equal(t, someErr, err)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("when the filter is invalid", func(t *testing.T) {
+		// Arrange
+		country := "BRA"
+
+		// Act
+		err := svc.ExportUsers(context.TODO(), FilterParams{Country: &country}, func(user *User) error {
+			return nil
+		})
+
+		// Assert
+		assert.True(t, errors.Is(err, ErrCountryCodeInvalid))
+	})
+}
@@ -0,0 +1,205 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alesr/usrsvc/internal/audit"
+	"github.com/alesr/usrsvc/internal/users/repository"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// lockableFields are the fields of a user admins can lock, named like the audited fields.
+var lockableFields = map[string]bool{
+	"first_name": true,
+	"last_name":  true,
+	"nickname":   true,
+	"email":      true,
+	"country":    true,
+}
+
+// FieldLock is a field of a user locked by an admin, e.g. the email frozen pending an
+// investigation. Changes to a locked field fail with a FieldLockedError.
+type FieldLock struct {
+	Field    string
+	Reason   string
+	LockedBy string
+	LockedAt time.Time
+}
+
+// FieldLockedError is returned when a change touches locked fields. It wraps ErrFieldLocked
+// and carries the locks, so callers can tell which fields were rejected and why.
+type FieldLockedError struct {
+	Locks []*FieldLock
+}
+
+func (e *FieldLockedError) Error() string {
+	fields := make([]string, 0, len(e.Locks))
+	for _, lock := range e.Locks {
+		fields = append(fields, lock.Field)
+	}
+	return fmt.Sprintf("%s: %s", ErrFieldLocked, strings.Join(fields, ", "))
+}
+
+func (e *FieldLockedError) Unwrap() error {
+	return ErrFieldLocked
+}
+
+// LockFields locks the fields of the user with the given reason and returns all its locks.
+// Locking a locked field replaces its reason. Locks are recorded in the audit log.
+func (s *ServiceDefault) LockFields(ctx context.Context, userID string, fields []string, reason string) ([]*FieldLock, error) {
+	ctx, span := tracer.Start(ctx, "ServiceDefault.LockFields")
+	defer span.End()
+	span.SetAttributes(attribute.String("user.id", userID))
+
+	if err := validateLockFields(userID, fields); err != nil {
+		return nil, fmt.Errorf("could not lock fields of user '%s': %w", userID, err)
+	}
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	changes := make(map[string]audit.Change, len(fields))
+	for _, field := range fields {
+		lock := repository.FieldLock{
+			UserID:    userID,
+			Field:     field,
+			Reason:    reason,
+			LockedBy:  audit.ActorFromContext(ctx),
+			CreatedAt: time.Now().UTC(),
+		}
+
+		if err := s.repo.LockField(ctx, &lock); err != nil {
+			if errors.Is(err, repository.ErrUserNotFound) {
+				err = ErrUserNotFound
+			}
+			return nil, fmt.Errorf("could not lock field '%s' of user '%s': %w", field, userID, err)
+		}
+		changes[field] = audit.Change{After: reason}
+	}
+
+	s.auditChanges(ctx, audit.ActionFieldLock, userID, changes)
+	return s.FieldLocks(ctx, userID)
+}
+
+// UnlockFields unlocks the fields of the user and returns its remaining locks.
+// Unlocking a field that is not locked is a no-op.
+func (s *ServiceDefault) UnlockFields(ctx context.Context, userID string, fields []string) ([]*FieldLock, error) {
+	ctx, span := tracer.Start(ctx, "ServiceDefault.UnlockFields")
+	defer span.End()
+	span.SetAttributes(attribute.String("user.id", userID))
+
+	if err := validateLockFields(userID, fields); err != nil {
+		return nil, fmt.Errorf("could not unlock fields of user '%s': %w", userID, err)
+	}
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	locks, err := s.repo.GetFieldLocks(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get field locks of user '%s': %w", userID, err)
+	}
+
+	reasons := make(map[string]string, len(locks))
+	for _, lock := range locks {
+		reasons[lock.Field] = lock.Reason
+	}
+
+	changes := make(map[string]audit.Change, len(fields))
+	for _, field := range fields {
+		reason, ok := reasons[field]
+		if !ok {
+			continue
+		}
+
+		if err := s.repo.UnlockField(ctx, userID, field); err != nil {
+			return nil, fmt.Errorf("could not unlock field '%s' of user '%s': %w", field, userID, err)
+		}
+		changes[field] = audit.Change{Before: reason}
+	}
+
+	if len(changes) > 0 {
+		s.auditChanges(ctx, audit.ActionFieldUnlock, userID, changes)
+	}
+	return s.FieldLocks(ctx, userID)
+}
+
+// FieldLocks returns the locked fields of the user, ordered by field.
+func (s *ServiceDefault) FieldLocks(ctx context.Context, userID string) ([]*FieldLock, error) {
+	ctx, span := tracer.Start(ctx, "ServiceDefault.FieldLocks")
+	defer span.End()
+	span.SetAttributes(attribute.String("user.id", userID))
+
+	if _, err := uuid.Parse(userID); err != nil {
+		return nil, fmt.Errorf("could not validate id '%s': %w", userID, ErrInvalidID)
+	}
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	locks, err := s.repo.GetFieldLocks(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get field locks of user '%s': %w", userID, err)
+	}
+
+	fieldLocks := make([]*FieldLock, 0, len(locks))
+	for _, lock := range locks {
+		fieldLocks = append(fieldLocks, newFieldLockDomainFromStore(lock))
+	}
+	return fieldLocks, nil
+}
+
+// checkFieldLocks returns a FieldLockedError if the change from before to after touches
+// fields locked by the locks. Rejected changes are recorded in the audit log.
+func (s *ServiceDefault) checkFieldLocks(ctx context.Context, locks []*repository.FieldLock, before, after *repository.User) error {
+	if len(locks) == 0 {
+		return nil
+	}
+
+	changes := audit.Diff(auditFields(before), auditFields(after))
+
+	var (
+		violated []*FieldLock
+		rejected = make(map[string]audit.Change)
+	)
+	for _, lock := range locks {
+		if change, ok := changes[lock.Field]; ok {
+			violated = append(violated, newFieldLockDomainFromStore(lock))
+			rejected[lock.Field] = change
+		}
+	}
+
+	if len(violated) == 0 {
+		return nil
+	}
+
+	s.auditChanges(ctx, audit.ActionLockedFieldRejected, after.ID, rejected)
+	return &FieldLockedError{Locks: violated}
+}
+
+func validateLockFields(userID string, fields []string) error {
+	if _, err := uuid.Parse(userID); err != nil {
+		return ErrInvalidID
+	}
+
+	for _, field := range fields {
+		if !lockableFields[field] {
+			return fmt.Errorf("%w: '%s'", ErrFieldNotLockable, field)
+		}
+	}
+	return nil
+}
+
+func newFieldLockDomainFromStore(lock *repository.FieldLock) *FieldLock {
+	return &FieldLock{
+		Field:    lock.Field,
+		Reason:   lock.Reason,
+		LockedBy: lock.LockedBy,
+		LockedAt: lock.CreatedAt,
+	}
+}
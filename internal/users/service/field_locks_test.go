@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alesr/usrsvc/internal/audit"
+	"github.com/alesr/usrsvc/internal/users/repository"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// noFieldLocks is the GetFieldLocksFunc of the mocked repositories of users without locks.
+func noFieldLocks(ctx context.Context, userID string) ([]*repository.FieldLock, error) {
+	return nil, nil
+}
+
+func TestFieldLocks(t *testing.T) {
+	t.Parallel()
+
+	newServiceHelper := func(t *testing.T) (*ServiceDefault, *User) {
+		t.Helper()
+
+		hasher := &hasherMock{
+			HashFunc: func(ctx context.Context, password []byte) ([]byte, error) {
+				return bcrypt.GenerateFromPassword(password, bcrypt.MinCost)
+			},
+			CompareFunc: func(ctx context.Context, hash, password []byte) error {
+				return bcrypt.CompareHashAndPassword(hash, password)
+			},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), repository.NewMemory(), WithHasher(hasher), WithAuditLog(audit.NewMemory()))
+
+		created, err := svc.Create(context.TODO(), &User{
+			FirstName: "John",
+			LastName:  "Doe",
+			Nickname:  "jdoe",
+			Password:  "password1!",
+			Email:     "joedoe@foo.bar",
+			Country:   "US",
+		})
+		require.NoError(t, err)
+
+		created.Password = "password1!"
+		return svc, created
+	}
+
+	t.Run("locked fields cannot be updated", func(t *testing.T) {
+		// Arrange
+		svc, user := newServiceHelper(t)
+		ctx := audit.ContextWithActor(context.TODO(), "admin-id")
+
+		locks, err := svc.LockFields(ctx, user.ID, []string{"email"}, "pending investigation")
+		require.NoError(t, err)
+		require.Len(t, locks, 1)
+		assert.Equal(t, "admin-id", locks[0].LockedBy)
+
+		// Act
+		update := *user
+		update.Email = "john@foo.bar"
+		_, err = svc.Update(context.TODO(), &update)
+
+		// Assert
+		var lockedErr *FieldLockedError
+		require.True(t, errors.As(err, &lockedErr))
+		assert.True(t, errors.Is(err, ErrFieldLocked))
+		require.Len(t, lockedErr.Locks, 1)
+		assert.Equal(t, "email", lockedErr.Locks[0].Field)
+		assert.Equal(t, "pending investigation", lockedErr.Locks[0].Reason)
+
+		events, err := svc.AuditEvents(context.TODO(), audit.Filter{UserID: user.ID, Limit: 10})
+		require.NoError(t, err)
+		require.NotEmpty(t, events)
+
+		assert.Equal(t, audit.ActionLockedFieldRejected, events[0].Action)
+		assert.Equal(t, map[string]audit.Change{
+			"email": {Before: "joedoe@foo.bar", After: "john@foo.bar"},
+		}, events[0].Changes)
+	})
+
+	t.Run("other fields can be updated", func(t *testing.T) {
+		// Arrange
+		svc, user := newServiceHelper(t)
+
+		_, err := svc.LockFields(context.TODO(), user.ID, []string{"email"}, "pending investigation")
+		require.NoError(t, err)
+
+		// Act
+		update := *user
+		update.Country = "PT"
+		updated, err := svc.Update(context.TODO(), &update)
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, "PT", updated.Country)
+	})
+
+	t.Run("unlocked fields can be updated again", func(t *testing.T) {
+		// Arrange
+		svc, user := newServiceHelper(t)
+
+		_, err := svc.LockFields(context.TODO(), user.ID, []string{"email", "nickname"}, "pending investigation")
+		require.NoError(t, err)
+
+		// Act
+		locks, err := svc.UnlockFields(context.TODO(), user.ID, []string{"email"})
+		require.NoError(t, err)
+
+		update := *user
+		update.Email = "john@foo.bar"
+		_, updateErr := svc.Update(context.TODO(), &update)
+
+		// Assert
+		require.Len(t, locks, 1)
+		assert.Equal(t, "nickname", locks[0].Field)
+		assert.NoError(t, updateErr)
+	})
+
+	t.Run("profile syncs respect the locks", func(t *testing.T) {
+		// Arrange
+		svc, user := newServiceHelper(t)
+
+		_, err := svc.LockFields(context.TODO(), user.ID, []string{"country"}, "legal hold")
+		require.NoError(t, err)
+
+		// Act
+		profile := *user
+		profile.Country = "PT"
+		_, err = svc.UpdateProfile(context.TODO(), &profile)
+
+		// Assert
+		assert.True(t, errors.Is(err, ErrFieldLocked))
+	})
+
+	t.Run("invalid fields", func(t *testing.T) {
+		// Arrange
+		svc := NewServiceDefault(zap.NewNop(), &repoMock{})
+
+		// Act
+		_, err := svc.LockFields(context.TODO(), uuid.New().String(), []string{"password"}, "some reason")
+
+		// Assert
+		assert.True(t, errors.Is(err, ErrFieldNotLockable))
+	})
+}
@@ -0,0 +1,17 @@
+package service
+
+import (
+	"context"
+	"net"
+)
+
+var _ GeoResolver = (*geoResolverMock)(nil)
+
+// geoResolverMock is a mock implementation of the geo resolver interface.
+type geoResolverMock struct {
+	ResolveFunc func(ctx context.Context, ip net.IP) (*GeoLocation, error)
+}
+
+func (g *geoResolverMock) Resolve(ctx context.Context, ip net.IP) (*GeoLocation, error) {
+	return g.ResolveFunc(ctx, ip)
+}
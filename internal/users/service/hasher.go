@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/alesr/usrsvc/internal/hashing"
+	"github.com/alesr/usrsvc/internal/users/repository"
+	"github.com/alesr/usrsvc/pkg/events"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var _ Hasher = bcryptHasher{}
+
+// Hasher hashes and verifies passwords.
+type Hasher interface {
+	Hash(ctx context.Context, password []byte) ([]byte, error)
+	Compare(ctx context.Context, hash, password []byte) error
+}
+
+// Rehasher is implemented by the hashers with configurable parameters. Hashes produced
+// with outdated parameters, e.g. a lower cost or another algorithm, are replaced on the next login.
+type Rehasher interface {
+	NeedsRehash(hash []byte) bool
+
+	// DummyHash returns a hash produced with the current parameters, compared against when
+	// authenticating unknown users so it takes as long as for the known ones.
+	DummyHash() []byte
+}
+
+// WithHasher configures the service to hash passwords with the given hasher,
+// e.g. a hashing.Pool to move the work off the request goroutines.
+func WithHasher(hasher Hasher) Option {
+	return func(s *ServiceDefault) {
+		s.hasher = hasher
+	}
+}
+
+// bcryptHasher hashes on the calling goroutine. It is the default hasher.
+type bcryptHasher struct{}
+
+func (bcryptHasher) Hash(_ context.Context, password []byte) ([]byte, error) {
+	return bcrypt.GenerateFromPassword(password, bcrypt.DefaultCost)
+}
+
+func (bcryptHasher) Compare(_ context.Context, hash, password []byte) error {
+	return bcrypt.CompareHashAndPassword(hash, password)
+}
+
+// rehash replaces the hash of the authenticated user if the hasher reports it as outdated.
+// It is best effort: the login succeeds anyway and the rehash is retried on the next one.
+func (s *ServiceDefault) rehash(ctx context.Context, user *repository.User, password string) {
+	rehasher, ok := s.hasher.(Rehasher)
+	if !ok || !rehasher.NeedsRehash([]byte(user.Password)) {
+		return
+	}
+
+	hash, err := s.hasher.Hash(ctx, []byte(password))
+	if err != nil {
+		s.logger.Warn("could not rehash password", zap.String("user_id", user.ID), zap.Error(err))
+		return
+	}
+
+	updated := *user
+	updated.Password = string(hash)
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	if err := s.repo.Update(ctx, &updated); err != nil {
+		s.logger.Warn("could not store rehashed password", zap.String("user_id", user.ID), zap.Error(err))
+		return
+	}
+
+	s.invalidateCachedUser(ctx, user.ID)
+
+	// The update increments the event sequence, so the consumers don't see a gap.
+	if s.publisher != nil {
+		s.publish(ctx, events.UserUpdated, userEvent(user.ID, updated.EventSequence, user.ID))
+	}
+}
+
+// dummyHashOf returns the hash to compare against when authenticating unknown users.
+func dummyHashOf(hasher Hasher) []byte {
+	if rehasher, ok := hasher.(Rehasher); ok {
+		return rehasher.DummyHash()
+	}
+	return dummyHash
+}
+
+// hashingError reports a saturated hashing pool as ErrServiceBusy.
+func hashingError(err error) error {
+	if errors.Is(err, hashing.ErrSaturated) {
+		return ErrServiceBusy
+	}
+	return err
+}
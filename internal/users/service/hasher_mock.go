@@ -0,0 +1,19 @@
+package service
+
+import "context"
+
+var _ Hasher = (*hasherMock)(nil)
+
+// hasherMock is a mock implementation of the hasher interface.
+type hasherMock struct {
+	HashFunc    func(ctx context.Context, password []byte) ([]byte, error)
+	CompareFunc func(ctx context.Context, hash, password []byte) error
+}
+
+func (h *hasherMock) Hash(ctx context.Context, password []byte) ([]byte, error) {
+	return h.HashFunc(ctx, password)
+}
+
+func (h *hasherMock) Compare(ctx context.Context, hash, password []byte) error {
+	return h.CompareFunc(ctx, hash, password)
+}
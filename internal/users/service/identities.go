@@ -0,0 +1,238 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alesr/usrsvc/internal/users/repository"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ExternalIdentity is the identity asserted by a verified OIDC ID token.
+// EmailVerified tells whether the provider verified the ownership of the email.
+type ExternalIdentity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+}
+
+// IdentityVerifier verifies the ID tokens issued by an OIDC provider.
+type IdentityVerifier interface {
+	Verify(ctx context.Context, rawIDToken string) (*ExternalIdentity, error)
+}
+
+// LinkedIdentity is an external identity linked to a user.
+type LinkedIdentity struct {
+	Provider string
+	Subject  string
+	Email    string
+	LinkedAt time.Time
+}
+
+// WithIdentityProviders configures the OIDC providers whose identities
+// can be linked to users, keyed by provider name (e.g. "google").
+func WithIdentityProviders(providers map[string]IdentityVerifier) Option {
+	return func(s *ServiceDefault) {
+		s.identities = providers
+	}
+}
+
+// LinkIdentity links the identity asserted by the ID token to the user, so the user
+// can later authenticate with the provider instead of a password. The provider must have
+// verified the email of the token, and it must be the email of the user, so a token of
+// someone else can't be linked to the user to sign in as them.
+func (s *ServiceDefault) LinkIdentity(ctx context.Context, userID, provider, idToken string) (*LinkedIdentity, error) {
+	ctx, span := tracer.Start(ctx, "ServiceDefault.LinkIdentity")
+	defer span.End()
+	span.SetAttributes(attribute.String("user.id", userID), attribute.String("identity.provider", provider))
+
+	if _, err := uuid.Parse(userID); err != nil {
+		return nil, fmt.Errorf("could not validate id '%s': %w", userID, ErrInvalidID)
+	}
+
+	external, err := s.verifyIDToken(ctx, provider, idToken)
+	if err != nil {
+		return nil, fmt.Errorf("could not link identity to user '%s': %w", userID, err)
+	}
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	user, err := s.repo.Get(repository.ContextWithPrimary(ctx), userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return nil, fmt.Errorf("could not link identity to user '%s': %w", userID, ErrUserNotFound)
+		}
+		return nil, fmt.Errorf("could not link identity to user '%s': %w", userID, err)
+	}
+
+	if !external.EmailVerified || !strings.EqualFold(external.Email, user.Email) {
+		return nil, fmt.Errorf("could not link identity to user '%s': %w", userID, ErrIdentityEmailMismatch)
+	}
+
+	identity := repository.LinkedIdentity{
+		Provider:  provider,
+		Subject:   external.Subject,
+		UserID:    userID,
+		Email:     external.Email,
+		CreatedAt: time.Now(),
+	}
+
+	if err := s.repo.LinkIdentity(ctx, &identity); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrIdentityLinked):
+			err = ErrIdentityLinked
+		case errors.Is(err, repository.ErrUserNotFound):
+			err = ErrUserNotFound
+		}
+		return nil, fmt.Errorf("could not link identity to user '%s': %w", userID, err)
+	}
+	return newLinkedIdentityDomainFromStore(&identity), nil
+}
+
+// LinkedIdentities returns the external identities linked to the user, oldest first.
+func (s *ServiceDefault) LinkedIdentities(ctx context.Context, userID string) ([]*LinkedIdentity, error) {
+	ctx, span := tracer.Start(ctx, "ServiceDefault.LinkedIdentities")
+	defer span.End()
+	span.SetAttributes(attribute.String("user.id", userID))
+
+	if _, err := uuid.Parse(userID); err != nil {
+		return nil, fmt.Errorf("could not validate id '%s': %w", userID, ErrInvalidID)
+	}
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	stored, err := s.repo.GetLinkedIdentities(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get identities linked to user '%s': %w", userID, err)
+	}
+
+	identities := make([]*LinkedIdentity, 0, len(stored))
+	for _, identity := range stored {
+		identities = append(identities, newLinkedIdentityDomainFromStore(identity))
+	}
+	return identities, nil
+}
+
+// UnlinkIdentity unlinks the external identity from the user, who can then no longer
+// authenticate with it.
+func (s *ServiceDefault) UnlinkIdentity(ctx context.Context, userID, provider, subject string) error {
+	ctx, span := tracer.Start(ctx, "ServiceDefault.UnlinkIdentity")
+	defer span.End()
+	span.SetAttributes(attribute.String("user.id", userID), attribute.String("identity.provider", provider))
+
+	if _, err := uuid.Parse(userID); err != nil {
+		return fmt.Errorf("could not validate id '%s': %w", userID, ErrInvalidID)
+	}
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	if err := s.repo.UnlinkIdentity(ctx, userID, provider, subject); err != nil {
+		if errors.Is(err, repository.ErrIdentityNotFound) {
+			return fmt.Errorf("could not unlink identity from user '%s': %w", userID, ErrIdentityNotFound)
+		}
+		return fmt.Errorf("could not unlink identity from user '%s': %w", userID, err)
+	}
+	return nil
+}
+
+// FetchByIdentity returns the user linked to the external identity. It lets the gateways
+// that verify the ID tokens themselves resolve the user of a token.
+func (s *ServiceDefault) FetchByIdentity(ctx context.Context, provider, subject string) (*User, error) {
+	ctx, span := tracer.Start(ctx, "ServiceDefault.FetchByIdentity")
+	defer span.End()
+	span.SetAttributes(attribute.String("identity.provider", provider))
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	user, err := s.repo.GetByLinkedIdentity(ctx, provider, subject)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return nil, fmt.Errorf("could not fetch user by identity: %w", ErrUserNotFound)
+		}
+		return nil, fmt.Errorf("could not fetch user by identity: %w", err)
+	}
+	return newUserDomainFromStore(user), nil
+}
+
+// AuthenticateWithIDToken returns the user linked to the identity asserted by the ID token.
+// Like Authenticate, it fails with ErrInvalidCredentials when no user is linked to it, and
+// the ID token stands for the password only: locked out users are rejected, and the users
+// who enabled TOTP must give a code.
+func (s *ServiceDefault) AuthenticateWithIDToken(ctx context.Context, provider, idToken, totpCode string) (*User, error) {
+	ctx, span := tracer.Start(ctx, "ServiceDefault.AuthenticateWithIDToken")
+	defer span.End()
+	span.SetAttributes(attribute.String("identity.provider", provider))
+
+	external, err := s.verifyIDToken(ctx, provider, idToken)
+	if err != nil {
+		return nil, fmt.Errorf("could not authenticate user: %w", err)
+	}
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	user, err := s.repo.GetByLinkedIdentity(ctx, provider, external.Subject)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return nil, fmt.Errorf("could not authenticate user: %w", ErrInvalidCredentials)
+		}
+		return nil, fmt.Errorf("could not authenticate user: %w", err)
+	}
+
+	lockout, err := s.checkLockout(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("could not authenticate user '%s': %w", user.ID, err)
+	}
+
+	if err := s.checkTOTP(ctx, user.ID, totpCode); err != nil {
+		// Wrong codes count towards the lockout, as with passwords.
+		if errors.Is(err, ErrTOTPInvalid) {
+			s.recordFailedLogin(ctx, user.ID)
+			return nil, fmt.Errorf("could not authenticate user '%s': %w", user.ID, ErrInvalidCredentials)
+		}
+		return nil, fmt.Errorf("could not authenticate user '%s': %w", user.ID, err)
+	}
+
+	if err := checkStatus(user); err != nil {
+		return nil, fmt.Errorf("could not authenticate user '%s': %w", user.ID, err)
+	}
+
+	s.resetLockout(ctx, lockout)
+	s.trackLogin(ctx, user.ID)
+
+	authenticated := newUserDomainFromStore(user)
+
+	// The hash never leaves the service.
+	authenticated.Password = ""
+	return authenticated, nil
+}
+
+func (s *ServiceDefault) verifyIDToken(ctx context.Context, provider, idToken string) (*ExternalIdentity, error) {
+	verifier, ok := s.identities[provider]
+	if !ok {
+		return nil, fmt.Errorf("could not verify id token from '%s': %w", provider, ErrIdentityProvider)
+	}
+
+	identity, err := verifier.Verify(ctx, idToken)
+	if err != nil {
+		return nil, fmt.Errorf("could not verify id token from '%s': %v: %w", provider, err, ErrInvalidIDToken)
+	}
+	return identity, nil
+}
+
+func newLinkedIdentityDomainFromStore(identity *repository.LinkedIdentity) *LinkedIdentity {
+	return &LinkedIdentity{
+		Provider: identity.Provider,
+		Subject:  identity.Subject,
+		Email:    identity.Email,
+		LinkedAt: identity.CreatedAt,
+	}
+}
@@ -0,0 +1,374 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alesr/usrsvc/internal/users/repository"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func newIdentityProvidersHelper(t *testing.T) map[string]IdentityVerifier {
+	t.Helper()
+
+	return map[string]IdentityVerifier{
+		"google": &identityVerifierMock{
+			VerifyFunc: func(ctx context.Context, rawIDToken string) (*ExternalIdentity, error) {
+				if rawIDToken != "valid-token" {
+					return nil, errors.New("signature mismatch")
+				}
+				return &ExternalIdentity{Subject: "1234567890", Email: "joedoe@gmail.com", EmailVerified: true}, nil
+			},
+		},
+	}
+}
+
+func TestLinkIdentity(t *testing.T) {
+	t.Parallel()
+
+	userID := uuid.New().String()
+
+	getUser := func(email string) func(ctx context.Context, id string) (*repository.User, error) {
+		return func(ctx context.Context, id string) (*repository.User, error) {
+			return &repository.User{ID: id, Email: email}, nil
+		}
+	}
+
+	t.Run("happy path", func(t *testing.T) {
+		// Arrange
+		var linked *repository.LinkedIdentity
+		repo := &repoMock{
+			GetFunc: getUser("JoeDoe@gmail.com"),
+			LinkIdentityFunc: func(ctx context.Context, identity *repository.LinkedIdentity) error {
+				linked = identity
+				return nil
+			},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), repo, WithIdentityProviders(newIdentityProvidersHelper(t)))
+
+		// Act
+		identity, err := svc.LinkIdentity(context.TODO(), userID, "google", "valid-token")
+		require.NoError(t, err)
+
+		// Assert
+		require.NotNil(t, linked)
+		assert.Equal(t, userID, linked.UserID)
+		assert.Equal(t, "google", linked.Provider)
+		assert.Equal(t, "1234567890", linked.Subject)
+
+		assert.Equal(t, "1234567890", identity.Subject)
+		assert.Equal(t, "joedoe@gmail.com", identity.Email)
+		assert.False(t, identity.LinkedAt.IsZero())
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		// Arrange
+		svc := NewServiceDefault(zap.NewNop(), &repoMock{}, WithIdentityProviders(newIdentityProvidersHelper(t)))
+
+		// Act
+		identity, err := svc.LinkIdentity(context.TODO(), userID, "google", "forged-token")
+
+		// Assert
+		assert.Nil(t, identity)
+		assert.True(t, errors.Is(err, ErrInvalidIDToken))
+	})
+
+	t.Run("unknown provider", func(t *testing.T) {
+		// Arrange
+		svc := NewServiceDefault(zap.NewNop(), &repoMock{}, WithIdentityProviders(newIdentityProvidersHelper(t)))
+
+		// Act
+		identity, err := svc.LinkIdentity(context.TODO(), userID, "github", "valid-token")
+
+		// Assert
+		assert.Nil(t, identity)
+		assert.True(t, errors.Is(err, ErrIdentityProvider))
+	})
+
+	t.Run("email of another user", func(t *testing.T) {
+		// Arrange
+		repo := &repoMock{
+			GetFunc: getUser("admin@foo.bar"),
+			LinkIdentityFunc: func(ctx context.Context, identity *repository.LinkedIdentity) error {
+				t.Fatal("the identity must not be linked")
+				return nil
+			},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), repo, WithIdentityProviders(newIdentityProvidersHelper(t)))
+
+		// Act
+		identity, err := svc.LinkIdentity(context.TODO(), userID, "google", "valid-token")
+
+		// Assert
+		assert.Nil(t, identity)
+		assert.True(t, errors.Is(err, ErrIdentityEmailMismatch))
+	})
+
+	t.Run("unverified email", func(t *testing.T) {
+		// Arrange
+		providers := map[string]IdentityVerifier{
+			"google": &identityVerifierMock{
+				VerifyFunc: func(ctx context.Context, rawIDToken string) (*ExternalIdentity, error) {
+					return &ExternalIdentity{Subject: "1234567890", Email: "joedoe@gmail.com"}, nil
+				},
+			},
+		}
+
+		repo := &repoMock{
+			GetFunc: getUser("joedoe@gmail.com"),
+			LinkIdentityFunc: func(ctx context.Context, identity *repository.LinkedIdentity) error {
+				t.Fatal("the identity must not be linked")
+				return nil
+			},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), repo, WithIdentityProviders(providers))
+
+		// Act
+		identity, err := svc.LinkIdentity(context.TODO(), userID, "google", "valid-token")
+
+		// Assert
+		assert.Nil(t, identity)
+		assert.True(t, errors.Is(err, ErrIdentityEmailMismatch))
+	})
+
+	t.Run("user not found", func(t *testing.T) {
+		// Arrange
+		repo := &repoMock{
+			GetFunc: func(ctx context.Context, id string) (*repository.User, error) {
+				return nil, repository.ErrUserNotFound
+			},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), repo, WithIdentityProviders(newIdentityProvidersHelper(t)))
+
+		// Act
+		_, err := svc.LinkIdentity(context.TODO(), userID, "google", "valid-token")
+
+		// Assert
+		assert.True(t, errors.Is(err, ErrUserNotFound))
+	})
+
+	t.Run("already linked", func(t *testing.T) {
+		// Arrange
+		repo := &repoMock{
+			GetFunc: getUser("joedoe@gmail.com"),
+			LinkIdentityFunc: func(ctx context.Context, identity *repository.LinkedIdentity) error {
+				return repository.ErrIdentityLinked
+			},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), repo, WithIdentityProviders(newIdentityProvidersHelper(t)))
+
+		// Act
+		_, err := svc.LinkIdentity(context.TODO(), userID, "google", "valid-token")
+
+		// Assert
+		assert.True(t, errors.Is(err, ErrIdentityLinked))
+	})
+}
+
+func TestLinkedIdentities(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	userID := uuid.New().String()
+	linkedAt := time.Time{}.Add(1 * time.Second)
+
+	repo := &repoMock{
+		GetLinkedIdentitiesFunc: func(ctx context.Context, id string) ([]*repository.LinkedIdentity, error) {
+			require.Equal(t, userID, id)
+			return []*repository.LinkedIdentity{
+				{Provider: "google", Subject: "1234567890", UserID: userID, Email: "joedoe@gmail.com", CreatedAt: linkedAt},
+			}, nil
+		},
+	}
+
+	svc := NewServiceDefault(zap.NewNop(), repo)
+
+	// Act
+	identities, err := svc.LinkedIdentities(context.TODO(), userID)
+	require.NoError(t, err)
+
+	// Assert
+	assert.Equal(t, []*LinkedIdentity{
+		{Provider: "google", Subject: "1234567890", Email: "joedoe@gmail.com", LinkedAt: linkedAt},
+	}, identities)
+}
+
+func TestAuthenticateWithIDToken(t *testing.T) {
+	t.Parallel()
+
+	storedUser := &repository.User{
+		ID:       uuid.New().String(),
+		Password: "some-hash",
+		Email:    "joedoe@foo.bar",
+	}
+
+	t.Run("linked identity", func(t *testing.T) {
+		// Arrange
+		repo := &repoMock{
+			GetByLinkedIdentityFunc: func(ctx context.Context, provider, subject string) (*repository.User, error) {
+				require.Equal(t, "google", provider)
+				require.Equal(t, "1234567890", subject)
+				return storedUser, nil
+			},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), repo, WithIdentityProviders(newIdentityProvidersHelper(t)))
+
+		// Act
+		user, err := svc.AuthenticateWithIDToken(context.TODO(), "google", "valid-token", "")
+		require.NoError(t, err)
+
+		// Assert
+		assert.Equal(t, storedUser.ID, user.ID)
+		assert.Empty(t, user.Password)
+	})
+
+	t.Run("locked out user", func(t *testing.T) {
+		// Arrange
+		repo := &repoMock{
+			GetByLinkedIdentityFunc: func(ctx context.Context, provider, subject string) (*repository.User, error) {
+				return storedUser, nil
+			},
+			GetLockoutFunc: func(ctx context.Context, userID string) (*repository.Lockout, error) {
+				return &repository.Lockout{UserID: userID, LockedUntil: time.Now().Add(time.Minute)}, nil
+			},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), repo,
+			WithIdentityProviders(newIdentityProvidersHelper(t)),
+			WithLockoutPolicy(LockoutPolicy{MaxAttempts: 3, Duration: time.Minute, MaxDuration: time.Hour}),
+		)
+
+		// Act
+		user, err := svc.AuthenticateWithIDToken(context.TODO(), "google", "valid-token", "")
+
+		// Assert
+		assert.Nil(t, user)
+		assert.True(t, errors.Is(err, ErrUserLocked))
+	})
+
+	t.Run("identity not linked", func(t *testing.T) {
+		// Arrange
+		repo := &repoMock{
+			GetByLinkedIdentityFunc: func(ctx context.Context, provider, subject string) (*repository.User, error) {
+				return nil, repository.ErrUserNotFound
+			},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), repo, WithIdentityProviders(newIdentityProvidersHelper(t)))
+
+		// Act
+		user, err := svc.AuthenticateWithIDToken(context.TODO(), "google", "valid-token", "")
+
+		// Assert
+		assert.Nil(t, user)
+		assert.True(t, errors.Is(err, ErrInvalidCredentials))
+	})
+}
+
+func TestUnlinkIdentity(t *testing.T) {
+	t.Parallel()
+
+	userID := uuid.New().String()
+
+	t.Run("happy path", func(t *testing.T) {
+		// Arrange
+		var unlinked []string
+		repo := &repoMock{
+			UnlinkIdentityFunc: func(ctx context.Context, userID, provider, subject string) error {
+				unlinked = []string{userID, provider, subject}
+				return nil
+			},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), repo)
+
+		// Act
+		err := svc.UnlinkIdentity(context.TODO(), userID, "google", "1234567890")
+		require.NoError(t, err)
+
+		// Assert
+		assert.Equal(t, []string{userID, "google", "1234567890"}, unlinked)
+	})
+
+	t.Run("identity not linked", func(t *testing.T) {
+		// Arrange
+		repo := &repoMock{
+			UnlinkIdentityFunc: func(ctx context.Context, userID, provider, subject string) error {
+				return repository.ErrIdentityNotFound
+			},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), repo)
+
+		// Act
+		err := svc.UnlinkIdentity(context.TODO(), userID, "google", "1234567890")
+
+		// Assert
+		assert.True(t, errors.Is(err, ErrIdentityNotFound))
+	})
+
+	t.Run("invalid id", func(t *testing.T) {
+		// Arrange
+		svc := NewServiceDefault(zap.NewNop(), &repoMock{})
+
+		// Act
+		err := svc.UnlinkIdentity(context.TODO(), "not-a-uuid", "google", "1234567890")
+
+		// Assert
+		assert.True(t, errors.Is(err, ErrInvalidID))
+	})
+}
+
+func TestFetchByIdentity(t *testing.T) {
+	t.Parallel()
+
+	t.Run("linked identity", func(t *testing.T) {
+		// Arrange
+		storedUser := &repository.User{ID: uuid.New().String(), Email: "joedoe@foo.bar"}
+		repo := &repoMock{
+			GetByLinkedIdentityFunc: func(ctx context.Context, provider, subject string) (*repository.User, error) {
+				require.Equal(t, "github", provider)
+				require.Equal(t, "42", subject)
+				return storedUser, nil
+			},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), repo)
+
+		// Act
+		user, err := svc.FetchByIdentity(context.TODO(), "github", "42")
+		require.NoError(t, err)
+
+		// Assert
+		assert.Equal(t, storedUser.ID, user.ID)
+	})
+
+	t.Run("identity not linked", func(t *testing.T) {
+		// Arrange
+		repo := &repoMock{
+			GetByLinkedIdentityFunc: func(ctx context.Context, provider, subject string) (*repository.User, error) {
+				return nil, repository.ErrUserNotFound
+			},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), repo)
+
+		// Act
+		user, err := svc.FetchByIdentity(context.TODO(), "github", "42")
+
+		// Assert
+		assert.Nil(t, user)
+		assert.True(t, errors.Is(err, ErrUserNotFound))
+	})
+}
@@ -0,0 +1,14 @@
+package service
+
+import "context"
+
+var _ IdentityVerifier = (*identityVerifierMock)(nil)
+
+// identityVerifierMock is a mock implementation of the identity verifier interface.
+type identityVerifierMock struct {
+	VerifyFunc func(ctx context.Context, rawIDToken string) (*ExternalIdentity, error)
+}
+
+func (v *identityVerifierMock) Verify(ctx context.Context, rawIDToken string) (*ExternalIdentity, error) {
+	return v.VerifyFunc(ctx, rawIDToken)
+}
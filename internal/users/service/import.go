@@ -0,0 +1,332 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/alesr/usrsvc/internal/audit"
+	"github.com/alesr/usrsvc/internal/users/repository"
+	"github.com/alesr/usrsvc/pkg/events"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+const (
+	// importBatchSize is the number of users inserted at once. It keeps the multi-row
+	// INSERTs well under the bind parameter limits of the SQL databases.
+	importBatchSize int = 500
+
+	// maxImportErrors bounds the record errors of the import report: the failures past
+	// it are only counted.
+	maxImportErrors int = 1000
+
+	defaultImportEventRate float64 = 500
+)
+
+// ImportRecord is a record of ImportUsers: a user to create, or the reason it is invalid.
+type ImportRecord struct {
+	User *User
+
+	// Err is the validation error of the record, reported without importing it.
+	Err error
+}
+
+// ImportError is the error of a record of ImportUsers, at Index in the import, from 0.
+type ImportError struct {
+	Index int64
+	Email string
+	Err   error
+}
+
+// ImportReport is the outcome of ImportUsers.
+type ImportReport struct {
+	Imported int64
+	Failed   int64
+
+	// Errors are the errors of the first failed records, up to maxImportErrors.
+	Errors []ImportError
+}
+
+// WithImportEventRate sets how many user.created events ImportUsers publishes per second,
+// so a migration doesn't flood the event consumers. Zero or less publishes without limit.
+func WithImportEventRate(rate float64) Option {
+	return func(s *ServiceDefault) {
+		s.importEventRate = rate
+	}
+}
+
+// ImportUsers creates the users read with next until it returns io.EOF, e.g. to migrate
+// them from a legacy system. Unlike Create, the creation date of the records is kept, and
+// the password policy is not enforced, the passwords having been accepted by the legacy
+// system. The users without password can only sign in after a password reset.
+//
+// The users are inserted in batches. The records failing validation or conflicting with
+// a stored user are skipped and reported, and the import goes on. Any other error stops
+// the import: the users of the previous batches stay imported.
+func (s *ServiceDefault) ImportUsers(ctx context.Context, next func() (*ImportRecord, error)) (*ImportReport, error) {
+	ctx, span := tracer.Start(ctx, "ServiceDefault.ImportUsers")
+	defer span.End()
+
+	imp := userImport{
+		s:     s,
+		pacer: newEventPacer(s.importEventRate),
+	}
+
+	defer func() {
+		span.SetAttributes(
+			attribute.Int64("import.imported", imp.report.Imported),
+			attribute.Int64("import.failed", imp.report.Failed),
+		)
+		s.logger.Info("imported users", zap.Int64("imported", imp.report.Imported), zap.Int64("failed", imp.report.Failed))
+	}()
+
+	for index := int64(0); ; index++ {
+		record, err := next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return &imp.report, fmt.Errorf("could not read import record %d: %w", index, err)
+		}
+
+		if record.Err != nil {
+			imp.fail(index, record.User, record.Err)
+			continue
+		}
+
+		if err := s.checkImportedUser(record.User); err != nil {
+			imp.fail(index, record.User, err)
+			continue
+		}
+
+		imp.batch = append(imp.batch, importedUser{index: index, user: record.User})
+		if len(imp.batch) == importBatchSize {
+			if err := imp.flush(ctx); err != nil {
+				return &imp.report, err
+			}
+		}
+	}
+
+	if err := imp.flush(ctx); err != nil {
+		return &imp.report, err
+	}
+	return &imp.report, nil
+}
+
+// checkImportedUser checks and prepares a user of ImportUsers like Create, but for its
+// password and creation date. Imported users are never admins.
+func (s *ServiceDefault) checkImportedUser(user *User) error {
+	user.normalize()
+
+	if err := s.checkNewUser(user); err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	user.ID = uuid.New().String()
+	user.Role = RoleUser
+	user.Status = ""
+	user.UpdatedAt = now
+
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = now
+	}
+	return nil
+}
+
+type importedUser struct {
+	index int64
+	user  *User
+}
+
+// userImport is the state of an ImportUsers call.
+type userImport struct {
+	s      *ServiceDefault
+	report ImportReport
+	batch  []importedUser
+	pacer  *eventPacer
+}
+
+// fail reports the failure of the record at index.
+func (i *userImport) fail(index int64, user *User, err error) {
+	i.report.Failed++
+	if len(i.report.Errors) >= maxImportErrors {
+		return
+	}
+
+	importErr := ImportError{Index: index, Err: err}
+	if user != nil {
+		importErr.Email = user.Email
+	}
+	i.report.Errors = append(i.report.Errors, importErr)
+}
+
+// flush hashes the passwords of the batch and inserts it. When the batch fails, its users
+// are inserted one by one to tell the conflicting records apart.
+func (i *userImport) flush(ctx context.Context) error {
+	if len(i.batch) == 0 {
+		return nil
+	}
+	defer func() { i.batch = i.batch[:0] }()
+
+	if err := i.hashPasswords(ctx); err != nil {
+		return err
+	}
+
+	stored := make([]*repository.User, 0, len(i.batch))
+	for _, imported := range i.batch {
+		stored = append(stored, newUserStoreFromDomain(imported.user))
+	}
+
+	dbCtx, cancel := i.s.timeouts.WithTimeout(ctx)
+	err := i.s.repo.InsertBatch(dbCtx, stored)
+	cancel()
+
+	if err == nil {
+		i.imported(ctx, stored)
+		return nil
+	}
+
+	if ctx.Err() != nil {
+		return fmt.Errorf("could not insert users: %w", err)
+	}
+
+	for k, imported := range i.batch {
+		if err := i.insertOne(ctx, imported, stored[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// insertOne inserts a user of a failed batch, reporting it when it conflicts with a stored user.
+func (i *userImport) insertOne(ctx context.Context, imported importedUser, user *repository.User) error {
+	dbCtx, cancel := i.s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	err := i.s.repo.Insert(dbCtx, user)
+	if err == nil {
+		i.imported(ctx, []*repository.User{user})
+		return nil
+	}
+
+	dupErr := i.s.duplicateError(err)
+	if dupErr == nil {
+		return fmt.Errorf("could not insert user: %w", err)
+	}
+
+	// A store split in regions may have inserted the user before another region failed the batch.
+	if _, getErr := i.s.repo.Get(repository.ContextWithPrimary(dbCtx), user.ID); getErr == nil {
+		i.imported(ctx, []*repository.User{user})
+		return nil
+	}
+
+	i.fail(imported.index, imported.user, dupErr)
+	return nil
+}
+
+// hashPasswords replaces the passwords of the batch with their hashes, hashing several
+// passwords at once. The users without password are left without.
+func (i *userImport) hashPasswords(ctx context.Context) error {
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+
+	users := make(chan *User)
+	for w := 0; w < runtime.GOMAXPROCS(0); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for user := range users {
+				hash, err := i.s.hasher.Hash(ctx, []byte(user.Password))
+				if err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					mu.Unlock()
+					continue
+				}
+				user.Password = string(hash)
+			}
+		}()
+	}
+
+	for _, imported := range i.batch {
+		if imported.user.Password != "" {
+			users <- imported.user
+		}
+	}
+	close(users)
+	wg.Wait()
+
+	if firstErr != nil {
+		return fmt.Errorf("could not hash password: %w", hashingError(firstErr))
+	}
+	return nil
+}
+
+// imported audits the creation of the imported users and publishes their events, paced.
+func (i *userImport) imported(ctx context.Context, users []*repository.User) {
+	for _, user := range users {
+		i.report.Imported++
+
+		i.s.audit(ctx, audit.ActionCreate, user.ID, nil, user)
+
+		if i.s.publisher != nil {
+			i.pacer.wait(ctx)
+			i.s.publish(ctx, events.UserCreated, userEvent(user.ID, user.EventSequence, user.ID))
+		}
+	}
+}
+
+// eventPacer spaces out the events of the bulk operations to a rate per second.
+type eventPacer struct {
+	interval time.Duration
+	next     time.Time
+}
+
+func newEventPacer(rate float64) *eventPacer {
+	if rate <= 0 {
+		return &eventPacer{}
+	}
+	return &eventPacer{interval: time.Duration(float64(time.Second) / rate)}
+}
+
+// wait blocks until the next event is due. The events of the users already stored must
+// still be published, so once the context is done it stops pacing instead of failing.
+func (p *eventPacer) wait(ctx context.Context) {
+	if p.interval == 0 {
+		return
+	}
+
+	now := time.Now()
+	if p.next.Before(now) {
+		p.next = now
+	}
+
+	delay := p.next.Sub(now)
+	p.next = p.next.Add(p.interval)
+
+	if delay <= 0 {
+		return
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/alesr/usrsvc/internal/users/repository"
+	"github.com/alesr/usrsvc/pkg/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestImportUsers(t *testing.T) {
+	t.Parallel()
+
+	hasher := &hasherMock{
+		HashFunc: func(ctx context.Context, password []byte) ([]byte, error) {
+			return bcrypt.GenerateFromPassword(password, bcrypt.MinCost)
+		},
+		CompareFunc: func(ctx context.Context, hash, password []byte) error {
+			return bcrypt.CompareHashAndPassword(hash, password)
+		},
+	}
+
+	// recordsHelper returns a next function reading the records in turn.
+	recordsHelper := func(records ...*ImportRecord) func() (*ImportRecord, error) {
+		return func() (*ImportRecord, error) {
+			if len(records) == 0 {
+				return nil, io.EOF
+			}
+
+			record := records[0]
+			records = records[1:]
+			return record, nil
+		}
+	}
+
+	t.Run("imports the valid records and reports the others", func(t *testing.T) {
+		// Arrange
+		var published []events.Event
+		publisher := &publisherMock{
+			PublishFunc: func(_ context.Context, event events.Event, data any) error {
+				published = append(published, event)
+				return nil
+			},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), repository.NewMemory(), WithHasher(hasher), WithPublisher(publisher), WithImportEventRate(0))
+
+		_, err := svc.Create(context.TODO(), &User{
+			FirstName: "John",
+			LastName:  "Doe",
+			Nickname:  "jdoe",
+			Password:  "password1!",
+			Email:     "joedoe@foo.bar",
+			Country:   "US",
+		})
+		require.NoError(t, err)
+		published = nil
+
+		createdAt := time.Date(2015, 3, 1, 10, 0, 0, 0, time.UTC)
+		invalidErr := errors.New("invalid email")
+
+		next := recordsHelper(
+			&ImportRecord{User: &User{FirstName: "Jane", LastName: "Doe", Nickname: "janedoe", Password: "legacy", Email: "janedoe@foo.bar", Country: "BR", CreatedAt: createdAt}},
+			&ImportRecord{User: &User{Email: "not-an-email"}, Err: invalidErr},
+			&ImportRecord{User: &User{FirstName: "Joe", LastName: "Doe", Nickname: "joe", Email: "joedoe@foo.bar", Country: "US"}},
+			&ImportRecord{User: &User{FirstName: "Bad", LastName: "Locale", Nickname: "bad", Email: "bad@foo.bar", Country: "US", Locale: "not a locale"}},
+			&ImportRecord{User: &User{FirstName: "John", LastName: "Smith", Nickname: "jsmith", Email: "jsmith@foo.bar", Country: "PT", Role: RoleAdmin}},
+		)
+
+		// Act
+		report, err := svc.ImportUsers(context.TODO(), next)
+		require.NoError(t, err)
+
+		jane, err := svc.Authenticate(context.TODO(), "janedoe@foo.bar", "legacy", "")
+		require.NoError(t, err)
+
+		country := "PT"
+		smith, err := svc.FetchAll(context.TODO(), FilterParams{Country: &country}, PaginationParams{Limit: 10})
+		require.NoError(t, err)
+
+		_, noPasswordErr := svc.Authenticate(context.TODO(), "jsmith@foo.bar", "", "")
+
+		// Assert
+		assert.Equal(t, int64(2), report.Imported)
+		assert.Equal(t, int64(3), report.Failed)
+
+		require.Len(t, report.Errors, 3)
+		assert.Equal(t, int64(1), report.Errors[0].Index)
+		assert.Equal(t, invalidErr, report.Errors[0].Err)
+		assert.Equal(t, int64(3), report.Errors[1].Index)
+		assert.True(t, errors.Is(report.Errors[1].Err, ErrLocaleInvalid))
+		assert.Equal(t, int64(2), report.Errors[2].Index)
+		assert.Equal(t, "joedoe@foo.bar", report.Errors[2].Email)
+		assert.True(t, errors.Is(report.Errors[2].Err, ErrUserAlreadyExists))
+
+		assert.True(t, jane.CreatedAt.Equal(createdAt))
+
+		require.Len(t, smith, 1)
+		assert.Equal(t, RoleUser, smith[0].Role)
+		assert.True(t, errors.Is(noPasswordErr, ErrInvalidCredentials))
+
+		assert.Equal(t, []events.Event{events.UserCreated, events.UserCreated}, published)
+	})
+
+	t.Run("inserts the users in batches", func(t *testing.T) {
+		// Arrange
+		var batches []int
+		repo := &repoMock{
+			InsertBatchFunc: func(ctx context.Context, users []*repository.User) error {
+				batches = append(batches, len(users))
+				return nil
+			},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), repo)
+
+		records := make([]*ImportRecord, 0, importBatchSize+1)
+		for i := 0; i < importBatchSize+1; i++ {
+			records = append(records, &ImportRecord{User: &User{Nickname: fmt.Sprintf("user%d", i), Email: fmt.Sprintf("user%d@foo.bar", i), Country: "US"}})
+		}
+
+		// Act
+		report, err := svc.ImportUsers(context.TODO(), recordsHelper(records...))
+		require.NoError(t, err)
+
+		// Assert
+		assert.Equal(t, int64(importBatchSize+1), report.Imported)
+		assert.Equal(t, []int{importBatchSize, 1}, batches)
+	})
+
+	t.Run("stops on repository errors", func(t *testing.T) {
+		// Arrange
+		repo := &repoMock{
+			InsertBatchFunc: func(ctx context.Context, users []*repository.User) error {
+				return errors.New("some error")
+			},
+			InsertFunc: func(ctx context.Context, user *repository.User) error {
+				return errors.New("some error")
+			},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), repo)
+
+		// Act
+		report, err := svc.ImportUsers(context.TODO(), recordsHelper(&ImportRecord{User: &User{Nickname: "jdoe", Email: "joedoe@foo.bar", Country: "US"}}))
+
+		// Assert
+		assert.Error(t, err)
+		assert.Zero(t, report.Imported)
+	})
+}
+
+func TestEventPacer(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	pacer := newEventPacer(100)
+	start := time.Now()
+
+	// Act
+	for i := 0; i < 5; i++ {
+		pacer.wait(context.TODO())
+	}
+
+	// Assert
+	assert.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond)
+}
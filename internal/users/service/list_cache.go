@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/alesr/usrsvc/internal/tenant"
+	"github.com/alesr/usrsvc/pkg/events"
+)
+
+var _ Publisher = (*ListCache)(nil)
+
+// ListCache is an in-memory cache of the first page of the most common user lists,
+// all users and the users of a country, which dashboards refresh every few seconds.
+// It implements Publisher so it can be fanned out the user events, which clear it:
+// since the events only carry the user id, any change invalidates every list.
+// Entries also expire after the TTL, which bounds how long writes made by other
+// instances go unnoticed.
+type ListCache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu         sync.Mutex
+	generation uint64
+	entries    map[listCacheKey]listCacheEntry
+}
+
+type listCacheKey struct {
+	tenant  string
+	country string
+	limit   int
+}
+
+type listCacheEntry struct {
+	users     []*User
+	expiresAt time.Time
+}
+
+// NewListCache creates a list cache keeping the lists for the given ttl.
+func NewListCache(ttl time.Duration) *ListCache {
+	return &ListCache{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[listCacheKey]listCacheEntry),
+	}
+}
+
+// WithListCache configures the service to cache the first page of the lists of all users
+// and of the users by country.
+func WithListCache(c *ListCache) Option {
+	return func(s *ServiceDefault) {
+		s.listCache = c
+	}
+}
+
+// listCacheKeyFor returns the cache key of the list in the tenant of ctx, and false if the
+// list is not cached: only the first page of the lists without other filter than the
+// country is.
+func listCacheKeyFor(ctx context.Context, filter FilterParams, pag PaginationParams) (listCacheKey, bool) {
+	if pag.Cursor != "" || !filter.countryOnly() {
+		return listCacheKey{}, false
+	}
+
+	key := listCacheKey{limit: pag.Limit}
+	key.tenant, _ = tenant.FromContext(ctx)
+	if filter.Country != nil {
+		key.country = *filter.Country
+	}
+	return key, true
+}
+
+// Publish clears the cache on every user change. It never fails.
+func (c *ListCache) Publish(ctx context.Context, event events.Event, data any) error {
+	switch event {
+	case events.UserCreated, events.UserUpdated, events.UserDeleted, events.UserMerged, events.UserAnonymized,
+		events.UserSuspended, events.UserDeactivated, events.UserReactivated:
+		c.mu.Lock()
+		c.generation++
+		c.entries = make(map[listCacheKey]listCacheEntry)
+		c.mu.Unlock()
+	}
+	return nil
+}
+
+// get returns a copy of the cached list and the current generation, to be passed to set
+// on a miss so a list loaded while the cache was being cleared is not stored.
+func (c *ListCache) get(key listCacheKey) ([]*User, uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || !c.now().Before(entry.expiresAt) {
+		return nil, c.generation, false
+	}
+	return copyUsers(entry.users), c.generation, true
+}
+
+// set caches a copy of the list, unless the cache was cleared since the generation was read.
+func (c *ListCache) set(key listCacheKey, generation uint64, users []*User) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+
+	c.entries[key] = listCacheEntry{
+		users:     copyUsers(users),
+		expiresAt: c.now().Add(c.ttl),
+	}
+}
+
+func copyUsers(users []*User) []*User {
+	if users == nil {
+		return nil
+	}
+
+	copied := make([]*User, 0, len(users))
+	for _, user := range users {
+		u := *user
+		copied = append(copied, &u)
+	}
+	return copied
+}
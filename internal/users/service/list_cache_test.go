@@ -0,0 +1,169 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alesr/usrsvc/internal/users/repository"
+	"github.com/alesr/usrsvc/pkg/events"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestListCache(t *testing.T) {
+	t.Parallel()
+
+	newServiceHelper := func(t *testing.T, listCache *ListCache) (*ServiceDefault, *int) {
+		t.Helper()
+
+		var calls int
+		repo := &repoMock{
+			ListFunc: func(ctx context.Context, filter repository.Filter, page repository.Page) ([]*repository.User, error) {
+				calls++
+				return []*repository.User{{ID: "some-id", Country: filter.Country}}, nil
+			},
+		}
+		return NewServiceDefault(zap.NewNop(), repo, WithListCache(listCache)), &calls
+	}
+
+	country := "BR"
+	firstPage := PaginationParams{Limit: 10}
+
+	t.Run("serves the first page from the cache", func(t *testing.T) {
+		// Arrange
+		svc, calls := newServiceHelper(t, NewListCache(time.Minute))
+
+		// Act
+		first, err := svc.FetchAll(context.TODO(), FilterParams{Country: &country}, firstPage)
+		require.NoError(t, err)
+
+		second, err := svc.FetchAll(context.TODO(), FilterParams{Country: &country}, firstPage)
+		require.NoError(t, err)
+
+		// Assert
+		assert.Equal(t, 1, *calls)
+		assert.Equal(t, first, second)
+	})
+
+	t.Run("does not cache the next pages and other filters", func(t *testing.T) {
+		// Arrange
+		svc, calls := newServiceHelper(t, NewListCache(time.Minute))
+		nickname := "jo"
+
+		// Act
+		for i := 0; i < 2; i++ {
+			_, err := svc.FetchAll(context.TODO(), FilterParams{Country: &country}, PaginationParams{Cursor: "some-id", Limit: 10})
+			require.NoError(t, err)
+
+			_, err = svc.FetchAll(context.TODO(), FilterParams{Country: &country, NicknamePrefix: &nickname}, firstPage)
+			require.NoError(t, err)
+		}
+
+		// Assert
+		assert.Equal(t, 4, *calls)
+	})
+
+	t.Run("user events clear the cache", func(t *testing.T) {
+		for _, event := range []events.Event{
+			events.UserCreated,
+			events.UserUpdated,
+			events.UserDeleted,
+			events.UserMerged,
+			events.UserAnonymized,
+			events.UserSuspended,
+			events.UserDeactivated,
+			events.UserReactivated,
+		} {
+			// Arrange
+			listCache := NewListCache(time.Minute)
+			svc, calls := newServiceHelper(t, listCache)
+
+			_, err := svc.FetchAll(context.TODO(), FilterParams{Country: &country}, firstPage)
+			require.NoError(t, err)
+
+			// Act
+			require.NoError(t, listCache.Publish(context.TODO(), event, "some-id"))
+
+			_, err = svc.FetchAll(context.TODO(), FilterParams{Country: &country}, firstPage)
+			require.NoError(t, err)
+
+			// Assert
+			assert.Equal(t, 2, *calls, "cache not cleared on %s", event)
+		}
+	})
+
+	t.Run("suspensions clear the cached page", func(t *testing.T) {
+		// Arrange
+		repo := repository.NewMemory()
+		user := &repository.User{
+			ID:        uuid.New().String(),
+			FirstName: "John",
+			LastName:  "Doe",
+			Nickname:  "jdoe",
+			Email:     "joedoe@foo.bar",
+			Country:   country,
+			Status:    repository.StatusActive,
+		}
+		require.NoError(t, repo.Insert(context.TODO(), user))
+
+		listCache := NewListCache(time.Minute)
+		svc := NewServiceDefault(zap.NewNop(), repo, WithListCache(listCache), WithPublisher(listCache))
+
+		cached, err := svc.FetchAll(context.TODO(), FilterParams{Country: &country}, firstPage)
+		require.NoError(t, err)
+		require.Len(t, cached, 1)
+
+		// Act
+		_, err = svc.SuspendUser(context.TODO(), user.ID)
+		require.NoError(t, err)
+
+		observed, err := svc.FetchAll(context.TODO(), FilterParams{Country: &country}, firstPage)
+		require.NoError(t, err)
+
+		// Assert
+		assert.Equal(t, StatusActive, cached[0].Status)
+		require.Len(t, observed, 1)
+		assert.Equal(t, StatusSuspended, observed[0].Status)
+	})
+
+	t.Run("entries expire after the ttl", func(t *testing.T) {
+		// Arrange
+		now := time.Now()
+		listCache := NewListCache(time.Minute)
+		listCache.now = func() time.Time { return now }
+
+		svc, calls := newServiceHelper(t, listCache)
+
+		_, err := svc.FetchAll(context.TODO(), FilterParams{Country: &country}, firstPage)
+		require.NoError(t, err)
+
+		// Act
+		now = now.Add(time.Minute)
+
+		_, err = svc.FetchAll(context.TODO(), FilterParams{Country: &country}, firstPage)
+		require.NoError(t, err)
+
+		// Assert
+		assert.Equal(t, 2, *calls)
+	})
+
+	t.Run("lists loaded while the cache is cleared are not cached", func(t *testing.T) {
+		// Arrange
+		listCache := NewListCache(time.Minute)
+		key := listCacheKey{country: country, limit: 10}
+
+		_, generation, ok := listCache.get(key)
+		require.False(t, ok)
+
+		// Act
+		require.NoError(t, listCache.Publish(context.TODO(), events.UserDeleted, "some-id"))
+		listCache.set(key, generation, []*User{{ID: "some-id"}})
+
+		// Assert
+		_, _, ok = listCache.get(key)
+		assert.False(t, ok)
+	})
+}
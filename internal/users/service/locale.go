@@ -0,0 +1,20 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/alesr/usrsvc/pkg/uservalidation"
+)
+
+// checkLocale returns ErrLocaleInvalid if the locale is not a BCP 47 language tag, or
+// ErrTimezoneInvalid if the timezone is not an IANA time zone. Both are optional.
+func checkLocale(locale, timezone string) error {
+	if locale != "" && !uservalidation.IsLocale(locale) {
+		return fmt.Errorf("could not validate locale '%s': %w", locale, ErrLocaleInvalid)
+	}
+
+	if timezone != "" && !uservalidation.IsTimezone(timezone) {
+		return fmt.Errorf("could not validate timezone '%s': %w", timezone, ErrTimezoneInvalid)
+	}
+	return nil
+}
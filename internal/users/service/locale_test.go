@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alesr/usrsvc/internal/users/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestUserLocale(t *testing.T) {
+	t.Parallel()
+
+	newServiceHelper := func(t *testing.T) *ServiceDefault {
+		t.Helper()
+
+		hasher := &hasherMock{
+			HashFunc: func(ctx context.Context, password []byte) ([]byte, error) {
+				return bcrypt.GenerateFromPassword(password, bcrypt.MinCost)
+			},
+		}
+		return NewServiceDefault(zap.NewNop(), repository.NewMemory(), WithHasher(hasher))
+	}
+
+	newUserHelper := func() *User {
+		return &User{
+			FirstName: "John",
+			LastName:  "Doe",
+			Nickname:  "jdoe",
+			Password:  "password1!",
+			Email:     "joedoe@foo.bar",
+			Country:   "BR",
+		}
+	}
+
+	t.Run("locale and timezone are stored and kept on update", func(t *testing.T) {
+		// Arrange
+		svc := newServiceHelper(t)
+
+		user := newUserHelper()
+		user.Locale = "pt_br"
+		user.Timezone = " America/Sao_Paulo "
+
+		// Act
+		created, err := svc.Create(context.TODO(), user)
+		require.NoError(t, err)
+
+		// Empty values keep the current ones.
+		_, err = svc.Update(context.TODO(), &User{
+			ID:        created.ID,
+			FirstName: "Johnny",
+			LastName:  "Doe",
+			Nickname:  "jdoe",
+			Email:     "joedoe@foo.bar",
+			Country:   "BR",
+		})
+		require.NoError(t, err)
+
+		kept, err := svc.Fetch(context.TODO(), created.ID)
+		require.NoError(t, err)
+
+		_, err = svc.Update(context.TODO(), &User{
+			ID:        created.ID,
+			FirstName: "Johnny",
+			LastName:  "Doe",
+			Nickname:  "jdoe",
+			Email:     "joedoe@foo.bar",
+			Country:   "BR",
+			Locale:    "en-GB",
+			Timezone:  "Europe/London",
+		})
+		require.NoError(t, err)
+
+		updated, err := svc.Fetch(context.TODO(), created.ID)
+		require.NoError(t, err)
+
+		// Assert
+		assert.Equal(t, "pt-BR", created.Locale)
+		assert.Equal(t, "America/Sao_Paulo", created.Timezone)
+
+		assert.Equal(t, "pt-BR", kept.Locale)
+		assert.Equal(t, "America/Sao_Paulo", kept.Timezone)
+
+		assert.Equal(t, "en-GB", updated.Locale)
+		assert.Equal(t, "Europe/London", updated.Timezone)
+	})
+
+	t.Run("invalid locale", func(t *testing.T) {
+		// Arrange
+		svc := newServiceHelper(t)
+
+		user := newUserHelper()
+		user.Locale = "english"
+
+		// Act
+		created, err := svc.Create(context.TODO(), user)
+
+		// Assert
+		assert.Nil(t, created)
+		assert.True(t, errors.Is(err, ErrLocaleInvalid))
+	})
+
+	t.Run("invalid timezone", func(t *testing.T) {
+		// Arrange
+		svc := newServiceHelper(t)
+
+		created, err := svc.Create(context.TODO(), newUserHelper())
+		require.NoError(t, err)
+
+		// Act
+		updated, err := svc.Update(context.TODO(), &User{
+			ID:        created.ID,
+			FirstName: "John",
+			LastName:  "Doe",
+			Nickname:  "jdoe",
+			Email:     "joedoe@foo.bar",
+			Country:   "BR",
+			Timezone:  "Brasilia",
+		})
+
+		// Assert
+		assert.Nil(t, updated)
+		assert.True(t, errors.Is(err, ErrTimezoneInvalid))
+	})
+}
@@ -0,0 +1,197 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alesr/usrsvc/internal/audit"
+	"github.com/alesr/usrsvc/internal/hashing"
+	"github.com/alesr/usrsvc/internal/users/repository"
+	"github.com/alesr/usrsvc/pkg/events"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// lockedUntilField is the audited field of the unlocks.
+const lockedUntilField string = "locked_until"
+
+// LockoutPolicy locks the users out after MaxAttempts consecutive failed logins, for
+// Duration at first and twice as long on every lockout since their last login, up to
+// MaxDuration. A zero MaxAttempts disables the lockouts.
+type LockoutPolicy struct {
+	MaxAttempts int
+	Duration    time.Duration
+	MaxDuration time.Duration
+}
+
+// lockoutDuration returns how long the user is locked out for, given the lockouts since their last login.
+func (p LockoutPolicy) lockoutDuration(lockouts int) time.Duration {
+	d := p.Duration
+	for i := 0; i < lockouts && d < p.MaxDuration; i++ {
+		d *= 2
+	}
+
+	if d > p.MaxDuration {
+		return p.MaxDuration
+	}
+	return d
+}
+
+// WithLockoutPolicy configures the service to lock the users out after too many failed logins.
+// Locked out users fail to authenticate with a UserLockedError, even with the right password,
+// until the lockout expires or an admin unlocks them.
+func WithLockoutPolicy(policy LockoutPolicy) Option {
+	return func(s *ServiceDefault) {
+		s.lockoutPolicy = policy
+	}
+}
+
+// UserLockedError is returned when a locked out user authenticates. It wraps ErrUserLocked
+// and carries the end of the lockout, so callers can tell when to retry.
+type UserLockedError struct {
+	LockedUntil time.Time
+}
+
+func (e *UserLockedError) Error() string {
+	return fmt.Sprintf("%s until %s", ErrUserLocked, e.LockedUntil.Format(time.RFC3339))
+}
+
+func (e *UserLockedError) Unwrap() error {
+	return ErrUserLocked
+}
+
+// checkLockout returns a UserLockedError if the user is locked out, and the lockout of the
+// user otherwise. The check fails open: if the lockout can't be read, the user isn't locked
+// out, so a database hiccup doesn't prevent users from logging in.
+func (s *ServiceDefault) checkLockout(ctx context.Context, userID string) (*repository.Lockout, error) {
+	if s.lockoutPolicy.MaxAttempts <= 0 {
+		return nil, nil
+	}
+
+	lockout, err := s.repo.GetLockout(ctx, userID)
+	if err != nil {
+		s.logger.Warn("could not get lockout", zap.String("user_id", userID), zap.Error(err))
+		return nil, nil
+	}
+
+	if lockout.LockedUntil.After(time.Now()) {
+		return nil, &UserLockedError{LockedUntil: lockout.LockedUntil}
+	}
+	return lockout, nil
+}
+
+// verifyPassword compares the password with the one of the user and returns the lockout
+// of the user, for resetLockout once the user is let in. Every RPC taking the current
+// password goes through it, so the wrong passwords count towards the lockout whatever
+// the RPC and none can be used to guess them. Locked out users are rejected before
+// their password is compared.
+func (s *ServiceDefault) verifyPassword(ctx context.Context, user *repository.User, password string) (*repository.Lockout, error) {
+	lockout, err := s.checkLockout(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.hasher.Compare(ctx, []byte(user.Password), []byte(password)); err != nil {
+		if errors.Is(err, hashing.ErrSaturated) {
+			return nil, ErrServiceBusy
+		}
+
+		s.recordFailedLogin(ctx, user.ID)
+		return nil, ErrInvalidCredentials
+	}
+	return lockout, nil
+}
+
+// recordFailedLogin counts the failed login of the user, and locks the user out
+// once it reaches the maximum attempts. Errors are only logged.
+func (s *ServiceDefault) recordFailedLogin(ctx context.Context, userID string) {
+	if s.lockoutPolicy.MaxAttempts <= 0 {
+		return
+	}
+
+	lockout, err := s.repo.RecordFailedLogin(ctx, userID)
+	if err != nil {
+		s.logger.Warn("could not record failed login", zap.String("user_id", userID), zap.Error(err))
+		return
+	}
+
+	if lockout.FailedAttempts < s.lockoutPolicy.MaxAttempts {
+		return
+	}
+
+	until := time.Now().UTC().Add(s.lockoutPolicy.lockoutDuration(lockout.Lockouts))
+	if err := s.repo.LockUser(ctx, userID, until); err != nil {
+		s.logger.Warn("could not lock user out", zap.String("user_id", userID), zap.Error(err))
+		return
+	}
+
+	s.logger.Info("user locked out after failed logins",
+		zap.String("user_id", userID),
+		zap.Int("failed_attempts", lockout.FailedAttempts),
+		zap.Time("locked_until", until),
+	)
+
+	if s.publisher != nil {
+		s.publish(ctx, events.UserLocked, events.UserLockedData{
+			UserID:         userID,
+			FailedAttempts: lockout.FailedAttempts,
+			LockedUntil:    until,
+		})
+	}
+}
+
+// resetLockout forgets the failed logins of the user after a successful login. Errors are only logged.
+func (s *ServiceDefault) resetLockout(ctx context.Context, lockout *repository.Lockout) {
+	if lockout == nil || (lockout.FailedAttempts == 0 && lockout.Lockouts == 0) {
+		return
+	}
+
+	if err := s.repo.ResetLockout(ctx, lockout.UserID); err != nil {
+		s.logger.Warn("could not reset lockout", zap.String("user_id", lockout.UserID), zap.Error(err))
+	}
+}
+
+// UnlockUser lifts the lockout of the user and forgets their failed logins.
+// Unlocking a user that is not locked out is a no-op. Unlocks are recorded in the audit log.
+func (s *ServiceDefault) UnlockUser(ctx context.Context, id string) error {
+	ctx, span := tracer.Start(ctx, "ServiceDefault.UnlockUser")
+	defer span.End()
+	span.SetAttributes(attribute.String("user.id", id))
+
+	if _, err := uuid.Parse(id); err != nil {
+		return fmt.Errorf("could not validate id '%s': %w", id, ErrInvalidID)
+	}
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	if _, err := s.repo.Get(repository.ContextWithPrimary(ctx), id); err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return fmt.Errorf("could not unlock user '%s': %w", id, ErrUserNotFound)
+		}
+		return fmt.Errorf("could not unlock user '%s': %w", id, err)
+	}
+
+	lockout, err := s.repo.GetLockout(ctx, id)
+	if err != nil {
+		return fmt.Errorf("could not get lockout of user '%s': %w", id, err)
+	}
+
+	if lockout.FailedAttempts == 0 && lockout.Lockouts == 0 {
+		return nil
+	}
+
+	if err := s.repo.ResetLockout(ctx, id); err != nil {
+		return fmt.Errorf("could not unlock user '%s': %w", id, err)
+	}
+
+	if lockout.LockedUntil.After(time.Now()) {
+		s.auditChanges(ctx, audit.ActionUnlock, id, map[string]audit.Change{
+			lockedUntilField: {Before: lockout.LockedUntil.UTC().Format(time.RFC3339)},
+		})
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alesr/usrsvc/internal/audit"
+	"github.com/alesr/usrsvc/internal/users/repository"
+	"github.com/alesr/usrsvc/pkg/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestLockout(t *testing.T) {
+	t.Parallel()
+
+	policy := LockoutPolicy{MaxAttempts: 3, Duration: time.Minute, MaxDuration: time.Hour}
+
+	newServiceHelper := func(t *testing.T) (*ServiceDefault, *repository.Memory, *[]any, *User) {
+		t.Helper()
+
+		hasher := &hasherMock{
+			HashFunc: func(ctx context.Context, password []byte) ([]byte, error) {
+				return bcrypt.GenerateFromPassword(password, bcrypt.MinCost)
+			},
+			CompareFunc: func(ctx context.Context, hash, password []byte) error {
+				return bcrypt.CompareHashAndPassword(hash, password)
+			},
+		}
+
+		var published []any
+		publisher := &publisherMock{
+			PublishFunc: func(_ context.Context, event events.Event, data any) error {
+				if event == events.UserLocked {
+					published = append(published, data)
+				}
+				return nil
+			},
+		}
+
+		repo := repository.NewMemory()
+		svc := NewServiceDefault(zap.NewNop(), repo,
+			WithHasher(hasher),
+			WithPublisher(publisher),
+			WithAuditLog(audit.NewMemory()),
+			WithLockoutPolicy(policy),
+		)
+
+		created, err := svc.Create(context.TODO(), &User{
+			FirstName: "John",
+			LastName:  "Doe",
+			Nickname:  "jdoe",
+			Password:  "password1!",
+			Email:     "joedoe@foo.bar",
+			Country:   "US",
+		})
+		require.NoError(t, err)
+		return svc, repo, &published, created
+	}
+
+	failLoginsHelper := func(t *testing.T, svc *ServiceDefault, n int) {
+		t.Helper()
+
+		for i := 0; i < n; i++ {
+			_, err := svc.Authenticate(context.TODO(), "joedoe@foo.bar", "wrong-password1!", "")
+			require.True(t, errors.Is(err, ErrInvalidCredentials))
+		}
+	}
+
+	t.Run("locks the user out after the maximum failed logins", func(t *testing.T) {
+		// Arrange
+		svc, _, published, user := newServiceHelper(t)
+		failLoginsHelper(t, svc, policy.MaxAttempts)
+
+		// Act
+		_, err := svc.Authenticate(context.TODO(), "joedoe@foo.bar", "password1!", "")
+
+		// Assert
+		var lockedErr *UserLockedError
+		require.True(t, errors.As(err, &lockedErr))
+		assert.True(t, errors.Is(err, ErrUserLocked))
+		assert.WithinDuration(t, time.Now().Add(policy.Duration), lockedErr.LockedUntil, 5*time.Second)
+
+		require.Len(t, *published, 1)
+		data, ok := (*published)[0].(events.UserLockedData)
+		require.True(t, ok)
+		assert.Equal(t, user.ID, data.UserID)
+		assert.Equal(t, policy.MaxAttempts, data.FailedAttempts)
+		assert.Equal(t, lockedErr.LockedUntil, data.LockedUntil)
+	})
+
+	t.Run("wrong current passwords lock the user out", func(t *testing.T) {
+		// Arrange
+		svc, _, published, user := newServiceHelper(t)
+
+		for i := 0; i < policy.MaxAttempts; i++ {
+			err := svc.ChangePassword(context.TODO(), user.ID, "wrong-password1!", "password2!")
+			require.True(t, errors.Is(err, ErrInvalidCredentials))
+		}
+
+		// Act
+		changeErr := svc.ChangePassword(context.TODO(), user.ID, "password1!", "password2!")
+		_, authErr := svc.Authenticate(context.TODO(), "joedoe@foo.bar", "password1!", "")
+
+		// Assert
+		assert.True(t, errors.Is(changeErr, ErrUserLocked))
+		assert.True(t, errors.Is(authErr, ErrUserLocked))
+		assert.Len(t, *published, 1)
+	})
+
+	t.Run("successful logins start over the count of failed logins", func(t *testing.T) {
+		// Arrange
+		svc, repo, published, user := newServiceHelper(t)
+		failLoginsHelper(t, svc, policy.MaxAttempts-1)
+
+		// Act
+		_, err := svc.Authenticate(context.TODO(), "joedoe@foo.bar", "password1!", "")
+		require.NoError(t, err)
+
+		failLoginsHelper(t, svc, policy.MaxAttempts-1)
+
+		// Assert
+		lockout, err := repo.GetLockout(context.TODO(), user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, policy.MaxAttempts-1, lockout.FailedAttempts)
+		assert.Empty(t, *published)
+	})
+
+	t.Run("unlocks the user and records it in the audit log", func(t *testing.T) {
+		// Arrange
+		svc, _, _, user := newServiceHelper(t)
+		failLoginsHelper(t, svc, policy.MaxAttempts)
+
+		// Act
+		err := svc.UnlockUser(audit.ContextWithActor(context.TODO(), "admin-id"), user.ID)
+		require.NoError(t, err)
+
+		_, authErr := svc.Authenticate(context.TODO(), "joedoe@foo.bar", "password1!", "")
+
+		// Assert
+		assert.NoError(t, authErr)
+
+		auditEvents, err := svc.AuditEvents(context.TODO(), audit.Filter{UserID: user.ID, Limit: 10})
+		require.NoError(t, err)
+		require.NotEmpty(t, auditEvents)
+		assert.Equal(t, audit.ActionUnlock, auditEvents[0].Action)
+		assert.Equal(t, "admin-id", auditEvents[0].Actor)
+		assert.Contains(t, auditEvents[0].Changes, lockedUntilField)
+	})
+
+	t.Run("doesn't unlock unknown users", func(t *testing.T) {
+		// Arrange
+		svc, _, _, _ := newServiceHelper(t)
+
+		// Act
+		err := svc.UnlockUser(context.TODO(), "8b2e0a53-3f0f-4d8c-9a3b-1f7c2d4e5a6b")
+
+		// Assert
+		assert.True(t, errors.Is(err, ErrUserNotFound))
+	})
+}
+
+func TestLockoutPolicyLockoutDuration(t *testing.T) {
+	t.Parallel()
+
+	policy := LockoutPolicy{MaxAttempts: 5, Duration: time.Minute, MaxDuration: 10 * time.Minute}
+
+	testCases := []struct {
+		name     string
+		lockouts int
+		expected time.Duration
+	}{
+		{name: "first lockout", lockouts: 0, expected: time.Minute},
+		{name: "second lockout", lockouts: 1, expected: 2 * time.Minute},
+		{name: "fourth lockout", lockouts: 3, expected: 8 * time.Minute},
+		{name: "capped", lockouts: 4, expected: 10 * time.Minute},
+		{name: "many lockouts", lockouts: 100, expected: 10 * time.Minute},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expected, policy.lockoutDuration(tc.lockouts))
+		})
+	}
+}
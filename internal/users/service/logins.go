@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/alesr/usrsvc/internal/users/repository"
+	"github.com/alesr/usrsvc/pkg/events"
+	"go.uber.org/zap"
+)
+
+const (
+	// loginHistorySize is the number of recent logins a login is compared with.
+	loginHistorySize int = 20
+
+	// minLoginHistory is the number of logins a user needs before logins from
+	// a new country are reported, so the first logins of a user are not.
+	minLoginHistory int = 3
+
+	// impossibleTravelWindow is the time within which a login from another
+	// country than the previous login is reported.
+	impossibleTravelWindow time.Duration = time.Hour
+
+	// Enumerate the reasons of the SuspiciousLogin event.
+
+	suspiciousReasonNewCountry       string = "new_country"
+	suspiciousReasonImpossibleTravel string = "impossible_travel"
+)
+
+// GeoLocation is the location of an IP address.
+type GeoLocation struct {
+	Country      string // ISO 3166-1 alpha-2 code
+	ASN          int64
+	Organization string
+}
+
+// GeoResolver resolves the location of IP addresses.
+type GeoResolver interface {
+	Resolve(ctx context.Context, ip net.IP) (*GeoLocation, error)
+}
+
+// WithGeoResolver enables login tracking: every successful authentication is recorded
+// and published as a UserAuthenticated event with the location of the client IP, and
+// a SuspiciousLogin event is published when the location deviates from the login history.
+func WithGeoResolver(resolver GeoResolver) Option {
+	return func(s *ServiceDefault) {
+		s.geoResolver = resolver
+	}
+}
+
+type clientIPKey struct{}
+
+// ContextWithClientIP returns a copy of ctx carrying the IP of the client
+// authenticating, which the transport layer knows about.
+func ContextWithClientIP(ctx context.Context, ip net.IP) context.Context {
+	return context.WithValue(ctx, clientIPKey{}, ip)
+}
+
+func clientIPFromContext(ctx context.Context) net.IP {
+	ip, _ := ctx.Value(clientIPKey{}).(net.IP)
+	return ip
+}
+
+// trackLogin records the login of the user and publishes the login events.
+// Errors are only logged: they must never prevent users from logging in.
+func (s *ServiceDefault) trackLogin(ctx context.Context, userID string) {
+	if s.geoResolver == nil {
+		return
+	}
+
+	login := repository.Login{
+		UserID:    userID,
+		CreatedAt: time.Now().UTC(),
+	}
+	data := events.LoginData{UserID: userID}
+
+	if ip := clientIPFromContext(ctx); ip != nil {
+		login.IP = ip.String()
+		data.IP = login.IP
+
+		location, err := s.geoResolver.Resolve(ctx, ip)
+		if err != nil {
+			s.logger.Warn("could not resolve client ip location", zap.String("user_id", userID), zap.Error(err))
+		} else {
+			login.Country = location.Country
+			login.ASN = location.ASN
+			data.Country = location.Country
+			data.ASN = location.ASN
+			data.Organization = location.Organization
+		}
+	}
+
+	// The history is read before recording the login, so the login is not compared with itself.
+	history, err := s.repo.GetRecentLogins(ctx, userID, loginHistorySize)
+	if err != nil {
+		s.logger.Warn("could not get login history", zap.String("user_id", userID), zap.Error(err))
+	}
+
+	if err := s.repo.RecordLogin(ctx, &login); err != nil {
+		s.logger.Warn("could not record login", zap.String("user_id", userID), zap.Error(err))
+	}
+
+	if s.publisher == nil {
+		return
+	}
+
+	s.publish(ctx, events.UserAuthenticated, data)
+
+	if reason, ok := suspiciousLogin(&login, history); ok {
+		previous := make([]string, 0, len(history))
+		for _, h := range history {
+			previous = append(previous, h.Country)
+		}
+
+		s.publish(ctx, events.SuspiciousLogin, events.SuspiciousLoginData{
+			LoginData:         data,
+			Reason:            reason,
+			PreviousCountries: previous,
+		})
+	}
+}
+
+// suspiciousLogin compares the login with the recent logins of the user, most recent
+// first, and returns why it is suspicious. Logins from unknown countries are never suspicious.
+func suspiciousLogin(login *repository.Login, history []*repository.Login) (string, bool) {
+	if login.Country == "" || len(history) == 0 {
+		return "", false
+	}
+
+	last := history[0]
+	if last.Country != "" && last.Country != login.Country && login.CreatedAt.Sub(last.CreatedAt) < impossibleTravelWindow {
+		return suspiciousReasonImpossibleTravel, true
+	}
+
+	if len(history) < minLoginHistory {
+		return "", false
+	}
+
+	for _, h := range history {
+		if h.Country == login.Country {
+			return "", false
+		}
+	}
+	return suspiciousReasonNewCountry, true
+}
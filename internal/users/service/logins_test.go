@@ -0,0 +1,243 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/alesr/usrsvc/internal/users/repository"
+	"github.com/alesr/usrsvc/pkg/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestAuthenticateWithGeoResolver(t *testing.T) {
+	t.Parallel()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("password1!"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	newRepo := func(history []*repository.Login, recorded *[]*repository.Login) *repoMock {
+		return &repoMock{
+			GetByEmailFunc: func(ctx context.Context, email string) (*repository.User, error) {
+				return &repository.User{ID: "some-id", Email: email, Password: string(hash)}, nil
+			},
+			GetRecentLoginsFunc: func(ctx context.Context, userID string, limit int) ([]*repository.Login, error) {
+				assert.Equal(t, "some-id", userID)
+				assert.Equal(t, loginHistorySize, limit)
+				return history, nil
+			},
+			RecordLoginFunc: func(ctx context.Context, login *repository.Login) error {
+				*recorded = append(*recorded, login)
+				return nil
+			},
+		}
+	}
+
+	resolver := &geoResolverMock{
+		ResolveFunc: func(ctx context.Context, ip net.IP) (*GeoLocation, error) {
+			assert.Equal(t, "203.0.113.7", ip.String())
+			return &GeoLocation{Country: "PT", ASN: 64500, Organization: "Example ISP"}, nil
+		},
+	}
+
+	type published struct {
+		event events.Event
+		data  any
+	}
+
+	newPublisher := func(got *[]published) *publisherMock {
+		return &publisherMock{
+			PublishFunc: func(_ context.Context, event events.Event, data any) error {
+				*got = append(*got, published{event, data})
+				return nil
+			},
+		}
+	}
+
+	ctx := ContextWithClientIP(context.TODO(), net.ParseIP("203.0.113.7"))
+
+	t.Run("records and publishes the login", func(t *testing.T) {
+		// Arrange
+		var recorded []*repository.Login
+		var got []published
+
+		history := []*repository.Login{
+			{Country: "PT", CreatedAt: time.Now().Add(-24 * time.Hour)},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), newRepo(history, &recorded), WithGeoResolver(resolver), WithPublisher(newPublisher(&got)))
+
+		// Act
+		_, err := svc.Authenticate(ctx, "joedoe@foo.bar", "password1!", "")
+		require.NoError(t, err)
+
+		// Assert
+		require.Len(t, recorded, 1)
+		assert.Equal(t, "some-id", recorded[0].UserID)
+		assert.Equal(t, "203.0.113.7", recorded[0].IP)
+		assert.Equal(t, "PT", recorded[0].Country)
+		assert.Equal(t, int64(64500), recorded[0].ASN)
+
+		expected := []published{
+			{
+				events.UserAuthenticated,
+				events.LoginData{UserID: "some-id", IP: "203.0.113.7", Country: "PT", ASN: 64500, Organization: "Example ISP"},
+			},
+		}
+		assert.Equal(t, expected, got)
+	})
+
+	t.Run("login from a new country", func(t *testing.T) {
+		// Arrange
+		var recorded []*repository.Login
+		var got []published
+
+		history := []*repository.Login{
+			{Country: "DE", CreatedAt: time.Now().Add(-24 * time.Hour)},
+			{Country: "DE", CreatedAt: time.Now().Add(-48 * time.Hour)},
+			{Country: "FR", CreatedAt: time.Now().Add(-72 * time.Hour)},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), newRepo(history, &recorded), WithGeoResolver(resolver), WithPublisher(newPublisher(&got)))
+
+		// Act
+		_, err := svc.Authenticate(ctx, "joedoe@foo.bar", "password1!", "")
+		require.NoError(t, err)
+
+		// Assert
+		require.Len(t, got, 2)
+		assert.Equal(t, events.SuspiciousLogin, got[1].event)
+
+		data, ok := got[1].data.(events.SuspiciousLoginData)
+		require.True(t, ok)
+		assert.Equal(t, suspiciousReasonNewCountry, data.Reason)
+		assert.Equal(t, []string{"DE", "DE", "FR"}, data.PreviousCountries)
+		assert.Equal(t, "PT", data.Country)
+	})
+
+	t.Run("client ip unresolved", func(t *testing.T) {
+		// Arrange
+		var recorded []*repository.Login
+		var got []published
+
+		failing := &geoResolverMock{
+			ResolveFunc: func(ctx context.Context, ip net.IP) (*GeoLocation, error) {
+				return nil, errors.New("address not found")
+			},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), newRepo(nil, &recorded), WithGeoResolver(failing), WithPublisher(newPublisher(&got)))
+
+		// Act
+		_, err := svc.Authenticate(ctx, "joedoe@foo.bar", "password1!", "")
+		require.NoError(t, err)
+
+		// Assert
+		require.Len(t, recorded, 1)
+		assert.Empty(t, recorded[0].Country)
+
+		require.Len(t, got, 1)
+		assert.Equal(t, events.UserAuthenticated, got[0].event)
+	})
+
+	t.Run("recording fails", func(t *testing.T) {
+		// Arrange
+		repo := &repoMock{
+			GetByEmailFunc: func(ctx context.Context, email string) (*repository.User, error) {
+				return &repository.User{ID: "some-id", Email: email, Password: string(hash)}, nil
+			},
+			GetRecentLoginsFunc: func(ctx context.Context, userID string, limit int) ([]*repository.Login, error) {
+				return nil, errors.New("connection refused")
+			},
+			RecordLoginFunc: func(ctx context.Context, login *repository.Login) error {
+				return errors.New("connection refused")
+			},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), repo, WithGeoResolver(resolver))
+
+		// Act
+		user, err := svc.Authenticate(ctx, "joedoe@foo.bar", "password1!", "")
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, "some-id", user.ID)
+	})
+}
+
+func TestSuspiciousLogin(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+
+	testCases := []struct {
+		name           string
+		login          *repository.Login
+		history        []*repository.Login
+		expectedReason string
+	}{
+		{
+			name:    "first login",
+			login:   &repository.Login{Country: "PT", CreatedAt: now},
+			history: nil,
+		},
+		{
+			name:  "unknown country",
+			login: &repository.Login{CreatedAt: now},
+			history: []*repository.Login{
+				{Country: "DE", CreatedAt: now.Add(-time.Minute)},
+			},
+		},
+		{
+			name:  "same country",
+			login: &repository.Login{Country: "PT", CreatedAt: now},
+			history: []*repository.Login{
+				{Country: "PT", CreatedAt: now.Add(-time.Minute)},
+			},
+		},
+		{
+			name:  "impossible travel",
+			login: &repository.Login{Country: "PT", CreatedAt: now},
+			history: []*repository.Login{
+				{Country: "JP", CreatedAt: now.Add(-10 * time.Minute)},
+			},
+			expectedReason: suspiciousReasonImpossibleTravel,
+		},
+		{
+			name:  "new country with short history",
+			login: &repository.Login{Country: "PT", CreatedAt: now},
+			history: []*repository.Login{
+				{Country: "JP", CreatedAt: now.Add(-48 * time.Hour)},
+				{Country: "JP", CreatedAt: now.Add(-72 * time.Hour)},
+			},
+		},
+		{
+			name:  "known country",
+			login: &repository.Login{Country: "PT", CreatedAt: now},
+			history: []*repository.Login{
+				{Country: "JP", CreatedAt: now.Add(-48 * time.Hour)},
+				{Country: "JP", CreatedAt: now.Add(-72 * time.Hour)},
+				{Country: "PT", CreatedAt: now.Add(-96 * time.Hour)},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Act
+			reason, ok := suspiciousLogin(tc.login, tc.history)
+
+			// Assert
+			assert.Equal(t, tc.expectedReason != "", ok)
+			assert.Equal(t, tc.expectedReason, reason)
+		})
+	}
+}
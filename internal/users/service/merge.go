@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alesr/usrsvc/internal/audit"
+	"github.com/alesr/usrsvc/internal/users/repository"
+	"github.com/alesr/usrsvc/pkg/events"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// MergeParams defines which accounts to merge and which values survive the merge.
+// By default the survivor keeps its own email and nickname.
+type MergeParams struct {
+	SurvivorID            string
+	DuplicateID           string
+	KeepDuplicateEmail    bool
+	KeepDuplicateNickname bool
+}
+
+// Merge consolidates the duplicate account into the survivor and returns the merged user.
+// The duplicate is tombstoned rather than deleted so the merge can be traced back.
+func (s *ServiceDefault) Merge(ctx context.Context, params MergeParams) (*User, error) {
+	ctx, span := tracer.Start(ctx, "ServiceDefault.Merge")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("user.id", params.SurvivorID),
+		attribute.String("user.duplicate_id", params.DuplicateID),
+	)
+
+	for _, id := range []string{params.SurvivorID, params.DuplicateID} {
+		if _, err := uuid.Parse(id); err != nil {
+			return nil, fmt.Errorf("could not validate id '%s': %w", id, ErrInvalidID)
+		}
+	}
+
+	if params.SurvivorID == params.DuplicateID {
+		return nil, fmt.Errorf("could not merge user '%s': %w", params.SurvivorID, ErrMergeSameUser)
+	}
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	// Both users are read from the primary, the merge must not lose recent changes.
+	primaryCtx := repository.ContextWithPrimary(ctx)
+
+	survivor, err := s.repo.Get(primaryCtx, params.SurvivorID)
+	if err != nil {
+		return nil, s.mergeError(params, err)
+	}
+
+	duplicate, err := s.repo.Get(primaryCtx, params.DuplicateID)
+	if err != nil {
+		return nil, s.mergeError(params, err)
+	}
+
+	// Anonymized users are frozen, they can neither take over a duplicate nor be merged.
+	if survivor.Anonymized || duplicate.Anonymized {
+		return nil, s.mergeError(params, ErrUserAnonymized)
+	}
+
+	before := *survivor
+
+	if params.KeepDuplicateEmail {
+		survivor.Email = duplicate.Email
+	}
+
+	if params.KeepDuplicateNickname {
+		survivor.Nickname = duplicate.Nickname
+	}
+
+	survivor.UpdatedAt = time.Now()
+
+	if err := s.checkMergeLocks(ctx, &before, survivor, duplicate.ID); err != nil {
+		return nil, s.mergeError(params, err)
+	}
+
+	if err := s.repo.Merge(ctx, survivor, duplicate.ID); err != nil {
+		return nil, s.mergeError(params, err)
+	}
+
+	s.invalidateCachedUser(ctx, survivor.ID)
+	s.invalidateCachedUser(ctx, duplicate.ID)
+	s.deleteAvatar(ctx, duplicate.AvatarKey)
+
+	if s.auditing() {
+		changes := audit.Diff(auditFields(&before), auditFields(survivor))
+		changes["merged_user_id"] = audit.Change{After: duplicate.ID}
+		s.auditChanges(ctx, audit.ActionMerge, survivor.ID, changes)
+
+		s.auditChanges(ctx, audit.ActionMerge, duplicate.ID, map[string]audit.Change{
+			"merged_into": {After: survivor.ID},
+		})
+	}
+
+	s.logger.Info("merged users", zap.String("survivor_id", survivor.ID), zap.String("duplicate_id", duplicate.ID))
+
+	if s.publisher != nil {
+		s.publish(ctx, events.UserMerged, userEvent(survivor.ID, survivor.EventSequence, events.UserMergedData{
+			SurvivorID:  survivor.ID,
+			DuplicateID: duplicate.ID,
+		}))
+	}
+
+	merged := newUserDomainFromStore(survivor)
+
+	// The hash never leaves the service.
+	merged.Password = ""
+	return merged, nil
+}
+
+// checkMergeLocks returns a FieldLockedError if the merge changes locked fields of the
+// survivor, or if the duplicate has locked fields: merging it would discard their values.
+func (s *ServiceDefault) checkMergeLocks(ctx context.Context, before, survivor *repository.User, duplicateID string) error {
+	locks, err := s.repo.GetFieldLocks(ctx, survivor.ID)
+	if err != nil {
+		return fmt.Errorf("could not get field locks: %w", err)
+	}
+
+	if err := s.checkFieldLocks(ctx, locks, before, survivor); err != nil {
+		return err
+	}
+
+	duplicateLocks, err := s.repo.GetFieldLocks(ctx, duplicateID)
+	if err != nil {
+		return fmt.Errorf("could not get field locks: %w", err)
+	}
+
+	if len(duplicateLocks) == 0 {
+		return nil
+	}
+
+	violated := make([]*FieldLock, 0, len(duplicateLocks))
+	for _, lock := range duplicateLocks {
+		violated = append(violated, newFieldLockDomainFromStore(lock))
+	}
+	return &FieldLockedError{Locks: violated}
+}
+
+func (s *ServiceDefault) mergeError(params MergeParams, err error) error {
+	switch dupErr := s.duplicateError(err); {
+	case errors.Is(err, repository.ErrUserNotFound):
+		err = ErrUserNotFound
+	case dupErr != nil:
+		err = dupErr
+	case errors.Is(err, repository.ErrRegionChange):
+		err = ErrRegionChange
+	}
+	return fmt.Errorf("could not merge user '%s' into '%s': %w", params.DuplicateID, params.SurvivorID, err)
+}
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alesr/usrsvc/internal/users/repository"
+	"github.com/alesr/usrsvc/pkg/events"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestMerge(t *testing.T) {
+	t.Parallel()
+
+	newUser := func(nickname, email string) *repository.User {
+		return &repository.User{
+			ID:        uuid.New().String(),
+			FirstName: "John",
+			LastName:  "Doe",
+			Nickname:  nickname,
+			Password:  "hash",
+			Email:     email,
+			Country:   "BR",
+			CreatedAt: time.Time{}.Add(1 * time.Second),
+			UpdatedAt: time.Time{}.Add(2 * time.Second),
+		}
+	}
+
+	t.Run("happy path", func(t *testing.T) {
+		// Arrange
+		repo := repository.NewMemory()
+
+		survivor := newUser("johndoe", "johndoe@foo.bar")
+		duplicate := newUser("jdoe", "john.doe@foo.bar")
+		require.NoError(t, repo.Insert(context.TODO(), survivor))
+		require.NoError(t, repo.Insert(context.TODO(), duplicate))
+
+		var published []events.Event
+		publisher := &publisherMock{
+			PublishFunc: func(_ context.Context, event events.Event, data any) error {
+				published = append(published, event)
+				assert.Equal(t, events.Ordered{
+					Key:      survivor.ID,
+					Sequence: 2,
+					Data:     events.UserMergedData{SurvivorID: survivor.ID, DuplicateID: duplicate.ID},
+				}, data)
+				return nil
+			},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), repo, WithPublisher(publisher))
+
+		// Act
+		merged, err := svc.Merge(context.TODO(), MergeParams{
+			SurvivorID:         survivor.ID,
+			DuplicateID:        duplicate.ID,
+			KeepDuplicateEmail: true,
+		})
+		require.NoError(t, err)
+
+		// Assert
+		assert.Equal(t, survivor.ID, merged.ID)
+		assert.Equal(t, "john.doe@foo.bar", merged.Email)
+		assert.Equal(t, "johndoe", merged.Nickname)
+		assert.Empty(t, merged.Password)
+		assert.Equal(t, []events.Event{events.UserMerged}, published)
+
+		stored, err := repo.Get(context.TODO(), survivor.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "john.doe@foo.bar", stored.Email)
+		assert.Equal(t, "hash", stored.Password)
+
+		_, err = repo.Get(context.TODO(), duplicate.ID)
+		assert.True(t, errors.Is(err, repository.ErrUserNotFound))
+	})
+
+	t.Run("same user", func(t *testing.T) {
+		// Arrange
+		svc := NewServiceDefault(zap.NewNop(), repository.NewMemory())
+		id := uuid.New().String()
+
+		// Act
+		merged, err := svc.Merge(context.TODO(), MergeParams{SurvivorID: id, DuplicateID: id})
+
+		// Assert
+		assert.Nil(t, merged)
+		assert.True(t, errors.Is(err, ErrMergeSameUser))
+	})
+
+	t.Run("invalid id", func(t *testing.T) {
+		// Arrange
+		svc := NewServiceDefault(zap.NewNop(), repository.NewMemory())
+
+		// Act
+		merged, err := svc.Merge(context.TODO(), MergeParams{SurvivorID: uuid.New().String(), DuplicateID: "foo"})
+
+		// Assert
+		assert.Nil(t, merged)
+		assert.True(t, errors.Is(err, ErrInvalidID))
+	})
+
+	t.Run("duplicate not found", func(t *testing.T) {
+		// Arrange
+		repo := repository.NewMemory()
+		survivor := newUser("johndoe", "johndoe@foo.bar")
+		require.NoError(t, repo.Insert(context.TODO(), survivor))
+
+		svc := NewServiceDefault(zap.NewNop(), repo)
+
+		// Act
+		merged, err := svc.Merge(context.TODO(), MergeParams{SurvivorID: survivor.ID, DuplicateID: uuid.New().String()})
+
+		// Assert
+		assert.Nil(t, merged)
+		assert.True(t, errors.Is(err, ErrUserNotFound))
+	})
+
+	t.Run("repository failure", func(t *testing.T) {
+		// Arrange
+		survivor := newUser("johndoe", "johndoe@foo.bar")
+		duplicate := newUser("jdoe", "john.doe@foo.bar")
+
+		repo := &repoMock{
+			GetFunc: func(ctx context.Context, id string) (*repository.User, error) {
+				if id == survivor.ID {
+					return survivor, nil
+				}
+				return duplicate, nil
+			},
+			GetFieldLocksFunc: noFieldLocks,
+			MergeFunc: func(ctx context.Context, s *repository.User, duplicateID string) error {
+				return errors.New("some error")
+			},
+		}
+
+		publisher := &publisherMock{
+			PublishFunc: func(_ context.Context, event events.Event, data any) error {
+				t.Fatal("no event must be published when the merge fails")
+				return nil
+			},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), repo, WithPublisher(publisher))
+
+		// Act
+		merged, err := svc.Merge(context.TODO(), MergeParams{SurvivorID: survivor.ID, DuplicateID: duplicate.ID})
+
+		// Assert
+		assert.Nil(t, merged)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/alesr/usrsvc/internal/users/repository"
+)
+
+const (
+	// Enumerate the limits of the user metadata. The metadata is meant for small
+	// attributes (e.g. a marketing opt-in or a theme), not for documents.
+
+	MaxMetadataEntries     int = 32
+	MaxMetadataKeyLength   int = 64
+	MaxMetadataValueLength int = 512
+
+	// metadataFieldPrefix prefixes the metadata keys in the audited fields.
+	metadataFieldPrefix string = "metadata."
+)
+
+// metadataKeyRegex matches the metadata keys, e.g. "marketing_opt_in" or "web.theme".
+var metadataKeyRegex = regexp.MustCompile(`^[a-z][a-z0-9_.-]*$`)
+
+// updatedMetadata returns the metadata to store for the metadata given on create or update.
+// The given attributes are set on the current ones and the ones with an empty value are
+// removed, so the clients don't need to know about the attributes set by other teams.
+func updatedMetadata(current repository.Metadata, metadata map[string]string) (repository.Metadata, error) {
+	if len(metadata) == 0 {
+		return current, nil
+	}
+
+	updated := make(repository.Metadata, len(current)+len(metadata))
+	for key, value := range current {
+		updated[key] = value
+	}
+
+	for key, value := range metadata {
+		if value == "" {
+			delete(updated, key)
+			continue
+		}
+
+		if len(key) > MaxMetadataKeyLength || !metadataKeyRegex.MatchString(key) {
+			return nil, fmt.Errorf("could not validate metadata key '%s': %w", key, ErrMetadataInvalid)
+		}
+
+		if len(value) > MaxMetadataValueLength {
+			return nil, fmt.Errorf("could not validate metadata value of key '%s': %w", key, ErrMetadataInvalid)
+		}
+		updated[key] = value
+	}
+
+	if len(updated) > MaxMetadataEntries {
+		return nil, fmt.Errorf("could not validate metadata with %d entries: %w", len(updated), ErrMetadataInvalid)
+	}
+
+	if len(updated) == 0 {
+		return nil, nil
+	}
+	return updated, nil
+}
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/alesr/usrsvc/internal/audit"
+	"github.com/alesr/usrsvc/internal/users/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestUpdatedMetadata(t *testing.T) {
+	t.Parallel()
+
+	tooMany := make(map[string]string, MaxMetadataEntries+1)
+	for i := 0; i <= MaxMetadataEntries; i++ {
+		tooMany["key_"+strings.Repeat("a", i)] = "value"
+	}
+
+	testCases := []struct {
+		name          string
+		givenCurrent  repository.Metadata
+		givenMetadata map[string]string
+		expected      repository.Metadata
+		expectedErr   error
+	}{
+		{
+			name:          "no metadata keeps the current one",
+			givenCurrent:  repository.Metadata{"theme": "dark"},
+			givenMetadata: nil,
+			expected:      repository.Metadata{"theme": "dark"},
+		},
+		{
+			name:          "attributes are set on the current ones",
+			givenCurrent:  repository.Metadata{"theme": "dark", "locale": "en-US"},
+			givenMetadata: map[string]string{"locale": "pt-BR", "marketing_opt_in": "true"},
+			expected:      repository.Metadata{"theme": "dark", "locale": "pt-BR", "marketing_opt_in": "true"},
+		},
+		{
+			name:          "empty values remove the attributes",
+			givenCurrent:  repository.Metadata{"theme": "dark"},
+			givenMetadata: map[string]string{"theme": "", "unknown": ""},
+			expected:      nil,
+		},
+		{
+			name:          "invalid key",
+			givenMetadata: map[string]string{"Theme": "dark"},
+			expectedErr:   ErrMetadataInvalid,
+		},
+		{
+			name:          "key too long",
+			givenMetadata: map[string]string{strings.Repeat("a", MaxMetadataKeyLength+1): "dark"},
+			expectedErr:   ErrMetadataInvalid,
+		},
+		{
+			name:          "value too long",
+			givenMetadata: map[string]string{"theme": strings.Repeat("a", MaxMetadataValueLength+1)},
+			expectedErr:   ErrMetadataInvalid,
+		},
+		{
+			name:          "too many entries",
+			givenMetadata: tooMany,
+			expectedErr:   ErrMetadataInvalid,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			observed, err := updatedMetadata(tc.givenCurrent, tc.givenMetadata)
+
+			assert.True(t, errors.Is(err, tc.expectedErr))
+			assert.Equal(t, tc.expected, observed)
+		})
+	}
+}
+
+func TestUserMetadata(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	hasher := &hasherMock{
+		HashFunc: func(ctx context.Context, password []byte) ([]byte, error) {
+			return bcrypt.GenerateFromPassword(password, bcrypt.MinCost)
+		},
+		CompareFunc: func(ctx context.Context, hash, password []byte) error {
+			return bcrypt.CompareHashAndPassword(hash, password)
+		},
+	}
+
+	auditLog := audit.NewMemory()
+	svc := NewServiceDefault(zap.NewNop(), repository.NewMemory(), WithHasher(hasher), WithAuditLog(auditLog))
+
+	user := &User{
+		FirstName: "John",
+		LastName:  "Doe",
+		Nickname:  "jdoe",
+		Password:  "password1!",
+		Email:     "joedoe@foo.bar",
+		Country:   "US",
+		Metadata:  map[string]string{"theme": "dark"},
+	}
+
+	// Act
+	created, err := svc.Create(context.TODO(), user)
+	require.NoError(t, err)
+
+	updated, err := svc.Update(context.TODO(), &User{
+		ID:        created.ID,
+		FirstName: "John",
+		LastName:  "Doe",
+		Nickname:  "jdoe",
+		Email:     "joedoe@foo.bar",
+		Country:   "US",
+		Metadata:  map[string]string{"theme": "", "locale": "en-US"},
+	})
+	require.NoError(t, err)
+
+	_, invalidErr := svc.Update(context.TODO(), &User{
+		ID:        created.ID,
+		FirstName: "John",
+		LastName:  "Doe",
+		Nickname:  "jdoe",
+		Email:     "joedoe@foo.bar",
+		Country:   "US",
+		Metadata:  map[string]string{"Not A Key": "true"},
+	})
+
+	fetched, err := svc.Fetch(context.TODO(), created.ID)
+	require.NoError(t, err)
+
+	// Assert
+	assert.Equal(t, map[string]string{"theme": "dark"}, created.Metadata)
+	assert.Equal(t, map[string]string{"locale": "en-US"}, updated.Metadata)
+	assert.Equal(t, map[string]string{"locale": "en-US"}, fetched.Metadata)
+	assert.True(t, errors.Is(invalidErr, ErrMetadataInvalid))
+
+	auditEvents, err := auditLog.List(context.TODO(), audit.Filter{UserID: created.ID, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, auditEvents, 2)
+	assert.Equal(t, audit.Change{Before: "dark"}, auditEvents[0].Changes["metadata.theme"])
+	assert.Equal(t, audit.Change{After: "en-US"}, auditEvents[0].Changes["metadata.locale"])
+}
@@ -6,6 +6,20 @@ import (
 	"time"
 
 	"github.com/alesr/usrsvc/internal/users/repository"
+	"github.com/alesr/usrsvc/pkg/uservalidation"
+)
+
+const (
+	// Enumerate the user roles.
+
+	RoleUser  string = repository.RoleUser
+	RoleAdmin string = repository.RoleAdmin
+
+	// Enumerate the user statuses. Suspended and deactivated users can't sign in.
+
+	StatusActive      string = repository.StatusActive
+	StatusSuspended   string = repository.StatusSuspended
+	StatusDeactivated string = repository.StatusDeactivated
 )
 
 // User defines domain model for a user.
@@ -17,8 +31,63 @@ type User struct {
 	Password  string
 	Email     string
 	Country   string
+	Role      string
+	Status    string
 	CreatedAt time.Time
 	UpdatedAt time.Time
+
+	// Anonymized is set on the users whose personal data was erased by AnonymizeUser.
+	Anonymized bool
+
+	// Metadata are the key/value attributes of the user. On update, they are set on
+	// the current ones and the attributes with an empty value are removed.
+	Metadata map[string]string
+
+	// AvatarHash is the hex SHA-256 of the avatar image, empty without avatar.
+	// It is only set by SetAvatar.
+	AvatarHash string
+
+	// Phone is the E.164 phone number of the user, optional. On update, an empty phone
+	// keeps the current one. PhoneVerified is only set by VerifyPhone, and cleared
+	// when the phone changes.
+	Phone         string
+	PhoneVerified bool
+
+	// Locale is the preferred BCP 47 locale of the user and Timezone its IANA time zone,
+	// both optional, for the notifications to be localized. On update, empty values keep
+	// the current ones.
+	Locale   string
+	Timezone string
+
+	// Birthdate is the date of birth of the user, optional: the zero time if unknown.
+	// On update, the zero time keeps the current one.
+	Birthdate time.Time
+
+	// TenantID is the tenant of the user, the one of the context it was created in. It is
+	// never changed.
+	TenantID string
+}
+
+// IsAdmin reports whether the user has the admin role.
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
+
+// normalize normalizes the fields of the user before storage, so equal values are stored alike.
+func (u *User) normalize() {
+	u.FirstName = uservalidation.NormalizeName(u.FirstName)
+	u.LastName = uservalidation.NormalizeName(u.LastName)
+	u.Nickname = uservalidation.NormalizeName(u.Nickname)
+	u.Country = uservalidation.NormalizeCountryCode(u.Country)
+	u.Phone = uservalidation.NormalizePhone(u.Phone)
+	u.Locale = uservalidation.NormalizeLocale(u.Locale)
+	u.Timezone = strings.TrimSpace(u.Timezone)
+
+	// Only the date is stored.
+	if !u.Birthdate.IsZero() {
+		year, month, day := u.Birthdate.Date()
+		u.Birthdate = time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	}
 }
 
 // newUserDomainFromStore converts a domain model user to a storage model user.
@@ -31,8 +100,16 @@ func newUserStoreFromDomain(user *User) *repository.User {
 		Password:  user.Password,
 		Email:     user.Email,
 		Country:   user.Country,
+		Role:      user.Role,
+		Status:    user.Status,
 		CreatedAt: user.CreatedAt,
 		UpdatedAt: user.UpdatedAt,
+		Metadata:  repository.Metadata(user.Metadata),
+		Phone:     user.Phone,
+		Locale:    user.Locale,
+		Timezone:  user.Timezone,
+		Birthdate: birthdateToStore(user.Birthdate),
+		TenantID:  user.TenantID,
 	}
 }
 
@@ -46,33 +123,108 @@ func newUserDomainFromStore(user *repository.User) *User {
 		Password:  user.Password,
 		Email:     user.Email,
 		Country:   user.Country,
+		Role:      user.Role,
+		Status:    user.Status,
 		CreatedAt: user.CreatedAt,
 		UpdatedAt: user.UpdatedAt,
+
+		Anonymized: user.Anonymized,
+		Metadata:   map[string]string(user.Metadata),
+		AvatarHash: user.AvatarHash,
+
+		Phone:         user.Phone,
+		PhoneVerified: user.PhoneVerified,
+
+		Locale:    user.Locale,
+		Timezone:  user.Timezone,
+		Birthdate: birthdateFromStore(user.Birthdate),
+
+		TenantID: user.TenantID,
 	}
 }
 
-const countryCodeLength = 2
-
+// FilterParams defines the filters for a query. Nil fields are ignored and the
+// other ones are combined.
 type FilterParams struct {
 	Country *string
+
+	// NicknamePrefix matches the nicknames starting with it.
+	NicknamePrefix *string
+
+	// Email, FirstName and LastName match the whole value, ignoring case.
+	Email     *string
+	FirstName *string
+	LastName  *string
+
+	// CreatedAfter (inclusive) and CreatedBefore (exclusive) bound the creation time.
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
 }
 
 func (f *FilterParams) normalize() {
 	if f.Country != nil {
-		country := *f.Country
-		normalized := strings.ToUpper(strings.TrimSpace(country))
+		normalized := uservalidation.NormalizeCountryCode(*f.Country)
 		f.Country = &normalized
 	}
 
+	for _, field := range []**string{&f.NicknamePrefix, &f.Email, &f.FirstName, &f.LastName} {
+		if *field == nil {
+			continue
+		}
+
+		trimmed := strings.TrimSpace(**field)
+		if trimmed == "" {
+			*field = nil
+			continue
+		}
+		*field = &trimmed
+	}
 }
 
 func (f *FilterParams) validate() error {
-	if len(*f.Country) != countryCodeLength {
+	if f.Country != nil && !uservalidation.IsCountryCode(*f.Country) {
 		return fmt.Errorf("could not validate country input '%s': %w", *f.Country, ErrCountryCodeInvalid)
 	}
+
+	if f.CreatedAfter != nil && f.CreatedBefore != nil && !f.CreatedAfter.Before(*f.CreatedBefore) {
+		return fmt.Errorf("could not validate creation time range: %w", ErrCreatedRangeInvalid)
+	}
 	return nil
 }
 
+// countryOnly reports whether the filter has no other field than the country.
+func (f *FilterParams) countryOnly() bool {
+	return f.NicknamePrefix == nil && f.Email == nil && f.FirstName == nil && f.LastName == nil &&
+		f.CreatedAfter == nil && f.CreatedBefore == nil
+}
+
+// storeFilter converts the filter to a storage filter.
+func (f *FilterParams) storeFilter() repository.Filter {
+	var filter repository.Filter
+	if f.Country != nil {
+		filter.Country = *f.Country
+	}
+	if f.NicknamePrefix != nil {
+		filter.NicknamePrefix = *f.NicknamePrefix
+	}
+	if f.Email != nil {
+		filter.Email = *f.Email
+	}
+	if f.FirstName != nil {
+		filter.FirstName = *f.FirstName
+	}
+	if f.LastName != nil {
+		filter.LastName = *f.LastName
+	}
+	if f.CreatedAfter != nil {
+		filter.CreatedAfter = *f.CreatedAfter
+	}
+	if f.CreatedBefore != nil {
+		filter.CreatedBefore = *f.CreatedBefore
+	}
+	return filter
+}
+
 // PaginationParams defines the pagination parameters for a query.
 // I'm keeping them as a struct for now so when we add more parameters
 // we don't need to change the method signature.
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alesr/usrsvc/internal/audit"
+	"github.com/alesr/usrsvc/internal/users/repository"
+	"github.com/alesr/usrsvc/pkg/events"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// PasswordChecker reports whether a password is known to be breached.
+type PasswordChecker interface {
+	Breached(ctx context.Context, password string) (bool, error)
+}
+
+// WithPasswordChecker configures the service to reject breached passwords when they are set.
+func WithPasswordChecker(checker PasswordChecker) Option {
+	return func(s *ServiceDefault) {
+		s.passwordChecker = checker
+	}
+}
+
+// checkPassword returns ErrPasswordBreached if the password is known to be breached.
+// The check fails open: if the checker is unavailable, the password is accepted
+// so an outage of the breach database doesn't block signups.
+func (s *ServiceDefault) checkPassword(ctx context.Context, password string) error {
+	if s.passwordChecker == nil {
+		return nil
+	}
+
+	breached, err := s.passwordChecker.Breached(ctx, password)
+	if err != nil {
+		s.logger.Warn("could not check password against breaches, accepting it", zap.Error(err))
+		return nil
+	}
+
+	if breached {
+		return fmt.Errorf("could not accept password: %w", ErrPasswordBreached)
+	}
+	return nil
+}
+
+// ChangePassword sets a new password for the user after verifying their current one.
+// A wrong current password fails with ErrInvalidCredentials and counts towards the lockout
+// like a failed login.
+func (s *ServiceDefault) ChangePassword(ctx context.Context, id, oldPassword, newPassword string) error {
+	ctx, span := tracer.Start(ctx, "ServiceDefault.ChangePassword")
+	defer span.End()
+	span.SetAttributes(attribute.String("user.id", id))
+
+	if _, err := uuid.Parse(id); err != nil {
+		return fmt.Errorf("could not validate id '%s': %w", id, ErrInvalidID)
+	}
+
+	dbCtx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	stored, err := s.repo.Get(repository.ContextWithPrimary(dbCtx), id)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return fmt.Errorf("could not change password of user '%s': %w", id, ErrUserNotFound)
+		}
+		return fmt.Errorf("could not change password of user '%s': %w", id, err)
+	}
+
+	if stored.Anonymized {
+		return fmt.Errorf("could not change password of user '%s': %w", id, ErrUserAnonymized)
+	}
+
+	lockout, err := s.verifyPassword(ctx, stored, oldPassword)
+	if err != nil {
+		return fmt.Errorf("could not change password of user '%s': %w", id, err)
+	}
+	s.resetLockout(ctx, lockout)
+
+	if err := s.checkPassword(ctx, newPassword); err != nil {
+		return err
+	}
+
+	hash, err := s.hasher.Hash(ctx, []byte(newPassword))
+	if err != nil {
+		return fmt.Errorf("could not hash password: %w", hashingError(err))
+	}
+
+	before := *stored
+
+	stored.Password = string(hash)
+	stored.UpdatedAt = time.Now()
+
+	// Hashing may take a while, so the update gets its own timeout.
+	dbCtx, cancel = s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	if err := s.repo.Update(dbCtx, stored); err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return fmt.Errorf("could not change password of user '%s': %w", id, ErrUserNotFound)
+		}
+		return fmt.Errorf("could not change password of user '%s': %w", id, err)
+	}
+
+	s.invalidateCachedUser(dbCtx, id)
+	s.audit(dbCtx, audit.ActionPasswordChange, id, &before, stored)
+
+	if s.publisher != nil {
+		s.publish(ctx, events.UserUpdated, userEvent(id, stored.EventSequence, id))
+	}
+	return nil
+}
@@ -0,0 +1,14 @@
+package service
+
+import "context"
+
+var _ PasswordChecker = (*passwordCheckerMock)(nil)
+
+// passwordCheckerMock is a mock implementation of the password checker interface.
+type passwordCheckerMock struct {
+	BreachedFunc func(ctx context.Context, password string) (bool, error)
+}
+
+func (p *passwordCheckerMock) Breached(ctx context.Context, password string) (bool, error) {
+	return p.BreachedFunc(ctx, password)
+}
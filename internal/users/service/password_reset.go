@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alesr/usrsvc/internal/audit"
+	"github.com/alesr/usrsvc/internal/users/repository"
+	"github.com/alesr/usrsvc/pkg/events"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	defaultResetTokenTTL time.Duration = time.Hour
+
+	resetTokenLength int = 32
+)
+
+// WithResetTokenTTL sets how long password reset tokens are valid for.
+func WithResetTokenTTL(ttl time.Duration) Option {
+	return func(s *ServiceDefault) {
+		s.resetTokenTTL = ttl
+	}
+}
+
+// RequestPasswordReset issues a one-time password reset token for the user with the given
+// email and publishes it in a UserPasswordResetRequested event, for the notification service
+// to email the reset link. Unknown emails are ignored without error, so callers can't use
+// this method to find out which emails are registered.
+func (s *ServiceDefault) RequestPasswordReset(ctx context.Context, email string) error {
+	ctx, span := tracer.Start(ctx, "ServiceDefault.RequestPasswordReset")
+	defer span.End()
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	user, err := s.repo.GetByEmail(ctx, email)
+	if err != nil {
+		// Like Authenticate, the shared emails are handled like the unknown ones.
+		if errors.Is(err, repository.ErrUserNotFound) || errors.Is(err, repository.ErrAmbiguousEmail) {
+			return nil
+		}
+		return fmt.Errorf("could not request password reset: %w", err)
+	}
+	span.SetAttributes(attribute.String("user.id", user.ID))
+
+	secret := make([]byte, resetTokenLength)
+	if _, err := rand.Read(secret); err != nil {
+		return fmt.Errorf("could not generate password reset token: %w", err)
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(secret)
+	hash := sha256.Sum256([]byte(token))
+
+	now := time.Now()
+	stored := repository.PasswordResetToken{
+		TokenHash: hash[:],
+		UserID:    user.ID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.resetTokenTTL),
+	}
+
+	if err := s.repo.InsertPasswordResetToken(ctx, &stored); err != nil {
+		return fmt.Errorf("could not insert password reset token for user '%s': %w", user.ID, err)
+	}
+
+	if s.publisher != nil {
+		s.publish(ctx, events.UserPasswordResetRequested, events.PasswordResetRequestedData{
+			UserID:    user.ID,
+			Email:     user.Email,
+			Token:     token,
+			ExpiresAt: stored.ExpiresAt,
+			Locale:    user.Locale,
+			Timezone:  user.Timezone,
+		})
+	}
+	return nil
+}
+
+// ConfirmPasswordReset sets the password of the user the reset token was issued for.
+// The token can only be used once, and the other pending tokens of the user are revoked.
+func (s *ServiceDefault) ConfirmPasswordReset(ctx context.Context, token, password string) error {
+	ctx, span := tracer.Start(ctx, "ServiceDefault.ConfirmPasswordReset")
+	defer span.End()
+
+	if err := s.checkPassword(ctx, password); err != nil {
+		return err
+	}
+
+	hash, err := s.hasher.Hash(ctx, []byte(password))
+	if err != nil {
+		return fmt.Errorf("could not hash password: %w", hashingError(err))
+	}
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	tokenHash := sha256.Sum256([]byte(token))
+
+	user, err := s.repo.ResetPassword(ctx, tokenHash[:], string(hash), time.Now())
+	if err != nil {
+		if errors.Is(err, repository.ErrResetTokenNotFound) {
+			return fmt.Errorf("could not reset password: %w", ErrResetTokenInvalid)
+		}
+		return fmt.Errorf("could not reset password: %w", err)
+	}
+	span.SetAttributes(attribute.String("user.id", user.ID))
+
+	s.invalidateCachedUser(ctx, user.ID)
+	s.auditChanges(ctx, audit.ActionPasswordReset, user.ID, map[string]audit.Change{
+		passwordField: {Before: audit.Redacted, After: audit.Redacted},
+	})
+
+	if s.publisher != nil {
+		s.publish(ctx, events.UserUpdated, userEvent(user.ID, user.EventSequence, user.ID))
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alesr/usrsvc/internal/users/repository"
+	"github.com/alesr/usrsvc/pkg/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestRequestPasswordReset(t *testing.T) {
+	t.Parallel()
+
+	t.Run("issues a token", func(t *testing.T) {
+		// Arrange
+		var stored *repository.PasswordResetToken
+		repo := &repoMock{
+			GetByEmailFunc: func(ctx context.Context, email string) (*repository.User, error) {
+				return &repository.User{ID: "some-id", Email: email, Locale: "pt-BR", Timezone: "America/Sao_Paulo"}, nil
+			},
+			InsertPasswordResetTokenFunc: func(ctx context.Context, token *repository.PasswordResetToken) error {
+				stored = token
+				return nil
+			},
+		}
+
+		var data events.PasswordResetRequestedData
+		publisher := &publisherMock{
+			PublishFunc: func(_ context.Context, event events.Event, d any) error {
+				assert.Equal(t, events.UserPasswordResetRequested, event)
+				data = d.(events.PasswordResetRequestedData)
+				return nil
+			},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), repo, WithPublisher(publisher), WithResetTokenTTL(15*time.Minute))
+
+		// Act
+		err := svc.RequestPasswordReset(context.TODO(), "joedoe@foo.bar")
+		require.NoError(t, err)
+
+		// Assert
+		require.NotNil(t, stored)
+		assert.Equal(t, "some-id", stored.UserID)
+		assert.Equal(t, 15*time.Minute, stored.ExpiresAt.Sub(stored.CreatedAt))
+
+		// Only the hash of the published token is stored.
+		hash := sha256.Sum256([]byte(data.Token))
+		assert.Equal(t, hash[:], stored.TokenHash)
+
+		assert.Equal(t, "some-id", data.UserID)
+		assert.Equal(t, "joedoe@foo.bar", data.Email)
+		assert.True(t, stored.ExpiresAt.Equal(data.ExpiresAt))
+		assert.Equal(t, "pt-BR", data.Locale)
+		assert.Equal(t, "America/Sao_Paulo", data.Timezone)
+	})
+
+	t.Run("unknown email", func(t *testing.T) {
+		// Arrange
+		repo := &repoMock{
+			GetByEmailFunc: func(ctx context.Context, email string) (*repository.User, error) {
+				return nil, repository.ErrUserNotFound
+			},
+		}
+
+		publisher := &publisherMock{
+			PublishFunc: func(_ context.Context, event events.Event, data any) error {
+				t.Fatal("no event must be published for unknown emails")
+				return nil
+			},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), repo, WithPublisher(publisher))
+
+		// Act
+		err := svc.RequestPasswordReset(context.TODO(), "joedoe@foo.bar")
+
+		// Assert
+		assert.NoError(t, err)
+	})
+}
+
+func TestConfirmPasswordReset(t *testing.T) {
+	t.Parallel()
+
+	hasher := &hasherMock{
+		HashFunc: func(ctx context.Context, password []byte) ([]byte, error) {
+			return []byte("hash:" + string(password)), nil
+		},
+	}
+
+	t.Run("resets the password", func(t *testing.T) {
+		// Arrange
+		repo := &repoMock{
+			ResetPasswordFunc: func(ctx context.Context, tokenHash []byte, password string, now time.Time) (*repository.User, error) {
+				hash := sha256.Sum256([]byte("some-token"))
+				assert.Equal(t, hash[:], tokenHash)
+				assert.Equal(t, "hash:password1!", password)
+				return &repository.User{ID: "some-id", EventSequence: 3}, nil
+			},
+		}
+
+		var published events.Ordered
+		publisher := &publisherMock{
+			PublishFunc: func(_ context.Context, event events.Event, data any) error {
+				assert.Equal(t, events.UserUpdated, event)
+				published = data.(events.Ordered)
+				return nil
+			},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), repo, WithHasher(hasher), WithPublisher(publisher))
+
+		// Act
+		err := svc.ConfirmPasswordReset(context.TODO(), "some-token", "password1!")
+		require.NoError(t, err)
+
+		// Assert
+		assert.Equal(t, "some-id", published.Key)
+		assert.Equal(t, int64(3), published.Sequence)
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		// Arrange
+		repo := &repoMock{
+			ResetPasswordFunc: func(ctx context.Context, tokenHash []byte, password string, now time.Time) (*repository.User, error) {
+				return nil, repository.ErrResetTokenNotFound
+			},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), repo, WithHasher(hasher))
+
+		// Act
+		err := svc.ConfirmPasswordReset(context.TODO(), "some-token", "password1!")
+
+		// Assert
+		assert.True(t, errors.Is(err, ErrResetTokenInvalid))
+	})
+}
@@ -0,0 +1,265 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alesr/usrsvc/internal/hashing"
+	"github.com/alesr/usrsvc/internal/users/repository"
+	"github.com/alesr/usrsvc/pkg/events"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestCreateWithPasswordChecker(t *testing.T) {
+	t.Parallel()
+
+	newUser := func() *User {
+		return &User{
+			FirstName: "John",
+			LastName:  "Doe",
+			Nickname:  "jdoe",
+			Password:  "password1!",
+			Email:     "joedoe@foo.bar",
+			Country:   "US",
+		}
+	}
+
+	hasher := &hasherMock{
+		HashFunc: func(ctx context.Context, password []byte) ([]byte, error) {
+			return bcrypt.GenerateFromPassword(password, bcrypt.MinCost)
+		},
+		CompareFunc: func(ctx context.Context, hash, password []byte) error {
+			return bcrypt.CompareHashAndPassword(hash, password)
+		},
+	}
+
+	t.Run("breached password", func(t *testing.T) {
+		// Arrange
+		checker := &passwordCheckerMock{
+			BreachedFunc: func(ctx context.Context, password string) (bool, error) {
+				assert.Equal(t, "password1!", password)
+				return true, nil
+			},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), &repoMock{}, WithHasher(hasher), WithPasswordChecker(checker))
+
+		// Act
+		user, err := svc.Create(context.TODO(), newUser())
+
+		// Assert
+		assert.Nil(t, user)
+		assert.True(t, errors.Is(err, ErrPasswordBreached))
+	})
+
+	t.Run("checker unavailable", func(t *testing.T) {
+		// Arrange
+		checker := &passwordCheckerMock{
+			BreachedFunc: func(ctx context.Context, password string) (bool, error) {
+				return false, errors.New("circuit breaker is open")
+			},
+		}
+
+		var insertFuncWasCalled bool
+		repo := &repoMock{
+			InsertFunc: func(ctx context.Context, user *repository.User) error {
+				insertFuncWasCalled = true
+				return nil
+			},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), repo, WithHasher(hasher), WithPasswordChecker(checker))
+
+		// Act
+		user, err := svc.Create(context.TODO(), newUser())
+		require.NoError(t, err)
+
+		// Assert
+		assert.True(t, insertFuncWasCalled)
+		assert.NotEmpty(t, user.ID)
+	})
+}
+
+func TestChangePasswordWithPasswordChecker(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	checker := &passwordCheckerMock{
+		BreachedFunc: func(ctx context.Context, password string) (bool, error) {
+			assert.Equal(t, "password2!", password)
+			return true, nil
+		},
+	}
+
+	hasher := &hasherMock{
+		CompareFunc: func(ctx context.Context, hash, password []byte) error {
+			return nil
+		},
+		HashFunc: func(ctx context.Context, password []byte) ([]byte, error) {
+			t.Fatal("breached passwords must not be hashed")
+			return nil, nil
+		},
+	}
+
+	repo := &repoMock{
+		GetFunc: func(ctx context.Context, id string) (*repository.User, error) {
+			return &repository.User{ID: id, Password: "some-hash"}, nil
+		},
+	}
+
+	svc := NewServiceDefault(zap.NewNop(), repo, WithHasher(hasher), WithPasswordChecker(checker))
+
+	// Act
+	err := svc.ChangePassword(context.TODO(), uuid.New().String(), "password1!", "password2!")
+
+	// Assert
+	assert.True(t, errors.Is(err, ErrPasswordBreached))
+}
+
+func TestChangePassword(t *testing.T) {
+	t.Parallel()
+
+	id := uuid.New().String()
+
+	oldHash, err := bcrypt.GenerateFromPassword([]byte("password1!"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	newRepo := func(updated **repository.User) *repoMock {
+		return &repoMock{
+			GetFunc: func(ctx context.Context, userID string) (*repository.User, error) {
+				return &repository.User{ID: userID, Email: "joedoe@foo.bar", Password: string(oldHash)}, nil
+			},
+			GetFieldLocksFunc: noFieldLocks,
+			UpdateFunc: func(ctx context.Context, user *repository.User) error {
+				user.EventSequence = 2
+				*updated = user
+				return nil
+			},
+		}
+	}
+
+	t.Run("changes the password", func(t *testing.T) {
+		// Arrange
+		var updated *repository.User
+
+		var published []events.Event
+		publisher := &publisherMock{
+			PublishFunc: func(_ context.Context, event events.Event, data any) error {
+				published = append(published, event)
+				return nil
+			},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), newRepo(&updated), WithPublisher(publisher))
+
+		// Act
+		err := svc.ChangePassword(context.TODO(), id, "password1!", "passw0rd2?")
+		require.NoError(t, err)
+
+		// Assert
+		require.NotNil(t, updated)
+		assert.Equal(t, "joedoe@foo.bar", updated.Email)
+		assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(updated.Password), []byte("passw0rd2?")))
+		assert.Equal(t, []events.Event{events.UserUpdated}, published)
+	})
+
+	t.Run("wrong current password", func(t *testing.T) {
+		// Arrange
+		var updated *repository.User
+		svc := NewServiceDefault(zap.NewNop(), newRepo(&updated))
+
+		// Act
+		err := svc.ChangePassword(context.TODO(), id, "wrong-password1!", "passw0rd2?")
+
+		// Assert
+		assert.True(t, errors.Is(err, ErrInvalidCredentials))
+		assert.Nil(t, updated)
+	})
+
+	t.Run("user not found", func(t *testing.T) {
+		// Arrange
+		repo := &repoMock{
+			GetFunc: func(ctx context.Context, userID string) (*repository.User, error) {
+				return nil, repository.ErrUserNotFound
+			},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), repo)
+
+		// Act
+		err := svc.ChangePassword(context.TODO(), id, "password1!", "passw0rd2?")
+
+		// Assert
+		assert.True(t, errors.Is(err, ErrUserNotFound))
+	})
+
+	t.Run("invalid id", func(t *testing.T) {
+		// Arrange
+		svc := NewServiceDefault(zap.NewNop(), &repoMock{})
+
+		// Act
+		err := svc.ChangePassword(context.TODO(), "some-id", "password1!", "passw0rd2?")
+
+		// Assert
+		assert.True(t, errors.Is(err, ErrInvalidID))
+	})
+}
+
+func TestRehashOnAuthenticate(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	legacy := hashing.NewPool(1, 1, hashing.WithCost(bcrypt.MinCost))
+	defer legacy.Close()
+
+	current := hashing.NewPool(1, 1, hashing.WithCost(bcrypt.MinCost+1))
+	defer current.Close()
+
+	repo := repository.NewMemory()
+
+	created, err := NewServiceDefault(zap.NewNop(), repo, WithHasher(legacy)).Create(context.TODO(), &User{
+		FirstName: "John",
+		LastName:  "Doe",
+		Nickname:  "jdoe",
+		Password:  "password1!",
+		Email:     "joedoe@foo.bar",
+		Country:   "US",
+	})
+	require.NoError(t, err)
+
+	var published []events.Event
+	publisher := &publisherMock{
+		PublishFunc: func(_ context.Context, event events.Event, data any) error {
+			published = append(published, event)
+			return nil
+		},
+	}
+
+	svc := NewServiceDefault(zap.NewNop(), repo, WithHasher(current), WithPublisher(publisher))
+
+	// Act
+	_, err = svc.Authenticate(context.TODO(), "joedoe@foo.bar", "password1!", "")
+	require.NoError(t, err)
+
+	// Assert
+	stored, err := repo.Get(context.TODO(), created.ID)
+	require.NoError(t, err)
+
+	cost, err := bcrypt.Cost([]byte(stored.Password))
+	require.NoError(t, err)
+	assert.Equal(t, bcrypt.MinCost+1, cost)
+	assert.Equal(t, []events.Event{events.UserUpdated}, published)
+
+	// The new hash is current, so the next login keeps it.
+	_, err = svc.Authenticate(context.TODO(), "joedoe@foo.bar", "password1!", "")
+	require.NoError(t, err)
+
+	again, err := repo.Get(context.TODO(), created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, stored.Password, again.Password)
+}
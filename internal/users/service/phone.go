@@ -0,0 +1,237 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/alesr/usrsvc/internal/audit"
+	"github.com/alesr/usrsvc/internal/users/repository"
+	"github.com/alesr/usrsvc/pkg/events"
+	"github.com/alesr/usrsvc/pkg/uservalidation"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultPhoneCodeTTL time.Duration = 10 * time.Minute
+
+	// phoneCodeDigits is the length of the verification codes, typed in by the users.
+	phoneCodeDigits int = 6
+
+	// maxPhoneCodeAttempts bounds the guesses of a code: past them, a new code must be requested.
+	maxPhoneCodeAttempts int = 5
+
+	// phoneCodeMessage is the text message sending the code.
+	phoneCodeMessage string = "Your verification code is %s. It expires in %d minutes."
+
+	// phoneVerifiedField is the audited field of the phone verifications.
+	phoneVerifiedField string = "phone_verified"
+)
+
+// SMSSender is the interface of the gateway sending the text messages. See sms.Twilio.
+type SMSSender interface {
+	Send(ctx context.Context, phone, message string) error
+}
+
+// WithSMSSender enables the phone verification, sending the codes with the sender.
+func WithSMSSender(sender SMSSender) Option {
+	return func(s *ServiceDefault) {
+		s.smsSender = sender
+	}
+}
+
+// WithPhoneCodeTTL sets how long the phone verification codes are valid for.
+func WithPhoneCodeTTL(ttl time.Duration) Option {
+	return func(s *ServiceDefault) {
+		s.phoneCodeTTL = ttl
+	}
+}
+
+// RequestPhoneVerification texts a verification code to the phone of the user, to be
+// confirmed with VerifyPhone. A new request replaces the pending code. Verified phones
+// are not verified again: the request is then a no-op.
+func (s *ServiceDefault) RequestPhoneVerification(ctx context.Context, id string) error {
+	ctx, span := tracer.Start(ctx, "ServiceDefault.RequestPhoneVerification")
+	defer span.End()
+	span.SetAttributes(attribute.String("user.id", id))
+
+	if s.smsSender == nil {
+		return fmt.Errorf("could not request phone verification: %w", ErrPhoneVerificationDisabled)
+	}
+
+	if _, err := uuid.Parse(id); err != nil {
+		return fmt.Errorf("could not validate id '%s': %w", id, ErrInvalidID)
+	}
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	stored, err := s.repo.Get(repository.ContextWithPrimary(ctx), id)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return fmt.Errorf("could not request phone verification for user '%s': %w", id, ErrUserNotFound)
+		}
+		return fmt.Errorf("could not request phone verification for user '%s': %w", id, err)
+	}
+
+	if stored.Phone == "" {
+		return fmt.Errorf("could not request phone verification for user '%s': %w", id, ErrPhoneNotSet)
+	}
+
+	if stored.PhoneVerified {
+		return nil
+	}
+
+	code, err := newPhoneCode()
+	if err != nil {
+		return fmt.Errorf("could not generate phone verification code: %w", err)
+	}
+
+	now := time.Now()
+	if err := s.repo.InsertPhoneVerification(ctx, &repository.PhoneVerification{
+		UserID:    id,
+		Phone:     stored.Phone,
+		CodeHash:  phoneCodeHash(code),
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.phoneCodeTTL),
+	}); err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return fmt.Errorf("could not request phone verification for user '%s': %w", id, ErrUserNotFound)
+		}
+		return fmt.Errorf("could not insert phone verification for user '%s': %w", id, err)
+	}
+
+	message := fmt.Sprintf(phoneCodeMessage, code, int(s.phoneCodeTTL.Minutes()))
+	if err := s.smsSender.Send(ctx, stored.Phone, message); err != nil {
+		return fmt.Errorf("could not send phone verification code to user '%s': %w", id, err)
+	}
+
+	s.logger.Info("sent phone verification code", zap.String("id", id))
+	return nil
+}
+
+// VerifyPhone marks the phone of the user verified if the code is the one last sent to it,
+// it has not expired and it is guessed within maxPhoneCodeAttempts. ErrPhoneCodeInvalid
+// is returned otherwise, including when the phone changed since the code was sent.
+func (s *ServiceDefault) VerifyPhone(ctx context.Context, id, code string) (*User, error) {
+	ctx, span := tracer.Start(ctx, "ServiceDefault.VerifyPhone")
+	defer span.End()
+	span.SetAttributes(attribute.String("user.id", id))
+
+	if s.smsSender == nil {
+		return nil, fmt.Errorf("could not verify phone: %w", ErrPhoneVerificationDisabled)
+	}
+
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, fmt.Errorf("could not validate id '%s': %w", id, ErrInvalidID)
+	}
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	// The attempt is counted before the code is checked, so concurrent guesses count too.
+	verification, err := s.repo.AttemptPhoneVerification(ctx, id, time.Now())
+	if err != nil {
+		if errors.Is(err, repository.ErrPhoneVerificationNotFound) || errors.Is(err, repository.ErrUserNotFound) {
+			return nil, fmt.Errorf("could not verify phone of user '%s': %w", id, ErrPhoneCodeInvalid)
+		}
+		return nil, fmt.Errorf("could not verify phone of user '%s': %w", id, err)
+	}
+
+	if verification.Attempts > maxPhoneCodeAttempts {
+		if err := s.repo.DeletePhoneVerification(ctx, id); err != nil {
+			s.logger.Error("could not delete phone verification", zap.String("id", id), zap.Error(err))
+		}
+		return nil, fmt.Errorf("could not verify phone of user '%s': too many attempts: %w", id, ErrPhoneCodeInvalid)
+	}
+
+	if subtle.ConstantTimeCompare(phoneCodeHash(code), verification.CodeHash) != 1 {
+		return nil, fmt.Errorf("could not verify phone of user '%s': %w", id, ErrPhoneCodeInvalid)
+	}
+
+	stored, err := s.repo.Get(repository.ContextWithPrimary(ctx), id)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return nil, fmt.Errorf("could not verify phone of user '%s': %w", id, ErrUserNotFound)
+		}
+		return nil, fmt.Errorf("could not verify phone of user '%s': %w", id, err)
+	}
+
+	// The code was sent to a phone the user has since replaced.
+	if stored.Phone != verification.Phone {
+		return nil, fmt.Errorf("could not verify phone of user '%s': %w", id, ErrPhoneCodeInvalid)
+	}
+
+	if !stored.PhoneVerified {
+		stored.PhoneVerified = true
+		stored.UpdatedAt = time.Now()
+
+		if err := s.repo.Update(ctx, stored); err != nil {
+			if errors.Is(err, repository.ErrUserNotFound) {
+				return nil, fmt.Errorf("could not verify phone of user '%s': %w", id, ErrUserNotFound)
+			}
+			return nil, fmt.Errorf("could not verify phone of user '%s': %w", id, err)
+		}
+
+		s.invalidateCachedUser(ctx, id)
+		s.auditChanges(ctx, audit.ActionUpdate, id, map[string]audit.Change{
+			phoneVerifiedField: {Before: "false", After: "true"},
+		})
+
+		if s.publisher != nil {
+			s.publish(ctx, events.UserUpdated, userEvent(id, stored.EventSequence, id))
+		}
+	}
+
+	// The code is single use.
+	if err := s.repo.DeletePhoneVerification(ctx, id); err != nil {
+		s.logger.Error("could not delete phone verification", zap.String("id", id), zap.Error(err))
+	}
+
+	user := newUserDomainFromStore(stored)
+
+	// The hash never leaves the service.
+	user.Password = ""
+	return user, nil
+}
+
+// checkPhone returns ErrPhoneInvalid if the phone, optional, is not in E.164 format.
+func checkPhone(phone string) error {
+	if phone != "" && !uservalidation.IsPhoneNumber(phone) {
+		return fmt.Errorf("could not validate phone: %w", ErrPhoneInvalid)
+	}
+	return nil
+}
+
+// newPhoneCode returns a random code of phoneCodeDigits digits, leading zeros included.
+func newPhoneCode() (string, error) {
+	limit := big.NewInt(1)
+	for i := 0; i < phoneCodeDigits; i++ {
+		limit.Mul(limit, big.NewInt(10))
+	}
+
+	n, err := rand.Int(rand.Reader, limit)
+	if err != nil {
+		return "", err
+	}
+
+	code := strconv.FormatInt(n.Int64(), 10)
+	for len(code) < phoneCodeDigits {
+		code = "0" + code
+	}
+	return code, nil
+}
+
+// phoneCodeHash returns the hash of the code stored in place of the code.
+func phoneCodeHash(code string) []byte {
+	hash := sha256.Sum256([]byte(code))
+	return hash[:]
+}
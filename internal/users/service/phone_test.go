@@ -0,0 +1,276 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/alesr/usrsvc/internal/audit"
+	"github.com/alesr/usrsvc/internal/users/repository"
+	"github.com/alesr/usrsvc/pkg/events"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestPhoneVerification(t *testing.T) {
+	t.Parallel()
+
+	codeRegex := regexp.MustCompile(`\b[0-9]{6}\b`)
+
+	// newServiceHelper returns a service texting the codes to the returned channel.
+	newServiceHelper := func(t *testing.T, opts ...Option) (*ServiceDefault, *User, <-chan string) {
+		t.Helper()
+
+		hasher := &hasherMock{
+			HashFunc: func(ctx context.Context, password []byte) ([]byte, error) {
+				return bcrypt.GenerateFromPassword(password, bcrypt.MinCost)
+			},
+			CompareFunc: func(ctx context.Context, hash, password []byte) error {
+				return bcrypt.CompareHashAndPassword(hash, password)
+			},
+		}
+
+		codes := make(chan string, 10)
+		sender := &smsSenderMock{
+			SendFunc: func(ctx context.Context, phone, message string) error {
+				assert.Equal(t, "+14155550123", phone)
+				codes <- codeRegex.FindString(message)
+				return nil
+			},
+		}
+
+		opts = append([]Option{WithHasher(hasher), WithSMSSender(sender)}, opts...)
+		svc := NewServiceDefault(zap.NewNop(), repository.NewMemory(), opts...)
+
+		created, err := svc.Create(context.TODO(), &User{
+			FirstName: "John",
+			LastName:  "Doe",
+			Nickname:  "jdoe",
+			Password:  "password1!",
+			Email:     "joedoe@foo.bar",
+			Country:   "US",
+			Phone:     "+1 (415) 555-0123",
+		})
+		require.NoError(t, err)
+		return svc, created, codes
+	}
+
+	t.Run("happy path", func(t *testing.T) {
+		// Arrange
+		var published []events.Event
+		publisher := &publisherMock{
+			PublishFunc: func(_ context.Context, event events.Event, data any) error {
+				published = append(published, event)
+				return nil
+			},
+		}
+
+		auditLog := audit.NewMemory()
+		svc, user, codes := newServiceHelper(t, WithPublisher(publisher), WithAuditLog(auditLog))
+
+		// Act
+		require.NoError(t, svc.RequestPhoneVerification(context.TODO(), user.ID))
+		code := <-codes
+
+		verified, err := svc.VerifyPhone(context.TODO(), user.ID, code)
+		require.NoError(t, err)
+
+		// The code is single use, and verified phones are not verified again.
+		_, reusedErr := svc.VerifyPhone(context.TODO(), user.ID, code)
+		require.NoError(t, svc.RequestPhoneVerification(context.TODO(), user.ID))
+
+		// Assert
+		assert.Equal(t, "+14155550123", user.Phone)
+		assert.False(t, user.PhoneVerified)
+
+		assert.Len(t, code, 6)
+		assert.True(t, verified.PhoneVerified)
+		assert.Empty(t, verified.Password)
+		assert.True(t, errors.Is(reusedErr, ErrPhoneCodeInvalid))
+		assert.Empty(t, codes)
+
+		assert.Equal(t, []events.Event{events.UserCreated, events.UserUpdated}, published)
+
+		auditEvents, err := auditLog.List(context.TODO(), audit.Filter{UserID: user.ID, Limit: 10})
+		require.NoError(t, err)
+		require.Len(t, auditEvents, 2)
+		assert.Equal(t, audit.Change{Before: "false", After: "true"}, auditEvents[0].Changes["phone_verified"])
+	})
+
+	t.Run("changing the phone requires a new verification", func(t *testing.T) {
+		// Arrange
+		svc, user, codes := newServiceHelper(t)
+
+		require.NoError(t, svc.RequestPhoneVerification(context.TODO(), user.ID))
+		_, err := svc.VerifyPhone(context.TODO(), user.ID, <-codes)
+		require.NoError(t, err)
+
+		update := func(phone string) (*User, error) {
+			return svc.Update(context.TODO(), &User{
+				ID:        user.ID,
+				FirstName: "John",
+				LastName:  "Doe",
+				Nickname:  "jdoe",
+				Email:     "joedoe@foo.bar",
+				Country:   "US",
+				Phone:     phone,
+			})
+		}
+
+		// Act
+		kept, err := update("")
+		require.NoError(t, err)
+
+		changed, err := update("+14155550199")
+		require.NoError(t, err)
+
+		_, invalidErr := update("4155550199")
+
+		// Assert
+		assert.Equal(t, "+14155550123", kept.Phone)
+		assert.True(t, kept.PhoneVerified)
+
+		assert.Equal(t, "+14155550199", changed.Phone)
+		assert.False(t, changed.PhoneVerified)
+
+		assert.True(t, errors.Is(invalidErr, ErrPhoneInvalid))
+	})
+
+	t.Run("a code sent to a replaced phone is rejected", func(t *testing.T) {
+		// Arrange
+		svc, user, codes := newServiceHelper(t)
+		require.NoError(t, svc.RequestPhoneVerification(context.TODO(), user.ID))
+
+		_, err := svc.Update(context.TODO(), &User{
+			ID:        user.ID,
+			FirstName: "John",
+			LastName:  "Doe",
+			Nickname:  "jdoe",
+			Email:     "joedoe@foo.bar",
+			Country:   "US",
+			Phone:     "+14155550199",
+		})
+		require.NoError(t, err)
+
+		// Act
+		_, err = svc.VerifyPhone(context.TODO(), user.ID, <-codes)
+
+		// Assert
+		assert.True(t, errors.Is(err, ErrPhoneCodeInvalid))
+	})
+
+	t.Run("too many attempts", func(t *testing.T) {
+		// Arrange
+		svc, user, codes := newServiceHelper(t)
+		require.NoError(t, svc.RequestPhoneVerification(context.TODO(), user.ID))
+		code := <-codes
+
+		wrong := "000000"
+		if code == wrong {
+			wrong = "111111"
+		}
+
+		for i := 0; i < maxPhoneCodeAttempts; i++ {
+			_, err := svc.VerifyPhone(context.TODO(), user.ID, wrong)
+			require.True(t, errors.Is(err, ErrPhoneCodeInvalid))
+		}
+
+		// Act
+		_, err := svc.VerifyPhone(context.TODO(), user.ID, code)
+
+		// Assert
+		assert.True(t, errors.Is(err, ErrPhoneCodeInvalid))
+	})
+
+	t.Run("expired code", func(t *testing.T) {
+		// Arrange
+		svc, user, codes := newServiceHelper(t, WithPhoneCodeTTL(0))
+		require.NoError(t, svc.RequestPhoneVerification(context.TODO(), user.ID))
+
+		// Act
+		_, err := svc.VerifyPhone(context.TODO(), user.ID, <-codes)
+
+		// Assert
+		assert.True(t, errors.Is(err, ErrPhoneCodeInvalid))
+	})
+
+	t.Run("phone not set", func(t *testing.T) {
+		// Arrange
+		svc := NewServiceDefault(zap.NewNop(), repository.NewMemory(), WithSMSSender(&smsSenderMock{}))
+
+		created, err := svc.Create(context.TODO(), &User{
+			FirstName: "John",
+			LastName:  "Doe",
+			Nickname:  "jdoe",
+			Password:  "password1!",
+			Email:     "joedoe@foo.bar",
+			Country:   "US",
+		})
+		require.NoError(t, err)
+
+		// Act
+		err = svc.RequestPhoneVerification(context.TODO(), created.ID)
+
+		// Assert
+		assert.True(t, errors.Is(err, ErrPhoneNotSet))
+	})
+
+	t.Run("invalid phone", func(t *testing.T) {
+		// Arrange
+		svc := NewServiceDefault(zap.NewNop(), repository.NewMemory())
+
+		// Act
+		created, err := svc.Create(context.TODO(), &User{
+			FirstName: "John",
+			LastName:  "Doe",
+			Nickname:  "jdoe",
+			Password:  "password1!",
+			Email:     "joedoe@foo.bar",
+			Country:   "US",
+			Phone:     "0415555",
+		})
+
+		// Assert
+		assert.Nil(t, created)
+		assert.True(t, errors.Is(err, ErrPhoneInvalid))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		// Arrange
+		svc := NewServiceDefault(zap.NewNop(), repository.NewMemory())
+
+		// Act
+		requestErr := svc.RequestPhoneVerification(context.TODO(), uuid.New().String())
+		_, verifyErr := svc.VerifyPhone(context.TODO(), uuid.New().String(), "123456")
+
+		// Assert
+		assert.True(t, errors.Is(requestErr, ErrPhoneVerificationDisabled))
+		assert.True(t, errors.Is(verifyErr, ErrPhoneVerificationDisabled))
+	})
+
+	t.Run("invalid id", func(t *testing.T) {
+		// Arrange
+		svc := NewServiceDefault(zap.NewNop(), repository.NewMemory(), WithSMSSender(&smsSenderMock{}))
+
+		// Act
+		verified, err := svc.VerifyPhone(context.TODO(), "foo", "123456")
+
+		// Assert
+		assert.Nil(t, verified)
+		assert.True(t, errors.Is(err, ErrInvalidID))
+	})
+}
+
+func TestNewPhoneCode(t *testing.T) {
+	t.Parallel()
+
+	for i := 0; i < 100; i++ {
+		code, err := newPhoneCode()
+		require.NoError(t, err)
+		assert.Regexp(t, `^[0-9]{6}$`, code)
+	}
+}
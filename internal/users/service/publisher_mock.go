@@ -1,6 +1,8 @@
 package service
 
 import (
+	"context"
+
 	"github.com/alesr/usrsvc/pkg/events"
 )
 
@@ -8,9 +10,9 @@ var _ Publisher = (*publisherMock)(nil)
 
 // publisherMock is a mock implementation of the publisher interface.
 type publisherMock struct {
-	PublishFunc func(event events.Event, data any) error
+	PublishFunc func(ctx context.Context, event events.Event, data any) error
 }
 
-func (p *publisherMock) Publish(event events.Event, data any) error {
-	return p.PublishFunc(event, data)
+func (p *publisherMock) Publish(ctx context.Context, event events.Event, data any) error {
+	return p.PublishFunc(ctx, event, data)
 }
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/alesr/usrsvc/internal/changes"
+	"github.com/alesr/usrsvc/internal/users/repository"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// replayBatchSize is the number of changes read per query by ReplayChanges.
+const replayBatchSize int = 500
+
+var _ Projection = (*UsersProjection)(nil)
+
+// Projection is a read model built from the change log, e.g. the users themselves or
+// a new view of them. The changes are applied oldest first and may be applied again
+// when a replay is resumed, so applying a change twice must be harmless.
+type Projection interface {
+	Apply(ctx context.Context, change *UserChange) error
+}
+
+// ReplayChanges applies the changes recorded after the change since to the projection,
+// oldest first, and returns the id of the last change applied, to resume from on error.
+// Unlike ListUserChanges, the snapshots have the password hash, for the users to be
+// rebuilt: projections must not expose it.
+func (s *ServiceDefault) ReplayChanges(ctx context.Context, since int64, projection Projection) (int64, error) {
+	ctx, span := tracer.Start(ctx, "ServiceDefault.ReplayChanges")
+	defer span.End()
+
+	if s.changeLog == nil {
+		return since, fmt.Errorf("could not replay user changes: %w", ErrChangeLogDisabled)
+	}
+
+	var replayed int
+	defer func() {
+		span.SetAttributes(attribute.Int("changes.replayed", replayed))
+		s.logger.Info("replayed user changes", zap.Int("replayed", replayed), zap.Int64("last_change", since))
+	}()
+
+	for {
+		userChanges, err := s.readChanges(ctx, since, replayBatchSize)
+		if err != nil {
+			return since, fmt.Errorf("could not read user changes to replay: %w", err)
+		}
+
+		for _, change := range userChanges {
+			if err := projection.Apply(ctx, change); err != nil {
+				return since, fmt.Errorf("could not apply user change %d: %w", change.ID, err)
+			}
+			since = change.ID
+			replayed++
+		}
+
+		if len(userChanges) < replayBatchSize {
+			return since, nil
+		}
+	}
+}
+
+// userRebuilder is the store the users are rebuilt in.
+type userRebuilder interface {
+	Get(ctx context.Context, id string) (*repository.User, error)
+	Insert(ctx context.Context, user *repository.User) error
+	Update(ctx context.Context, user *repository.User) error
+	SetStatus(ctx context.Context, user *repository.User) error
+	Anonymize(ctx context.Context, user *repository.User) error
+	Delete(ctx context.Context, id string) (int64, error)
+}
+
+// UsersProjection rebuilds the users in a store from the change log, e.g. after a disaster.
+// Every change overwrites the user with its snapshot. Only the users are rebuilt: their API
+// keys, sessions, logins and avatars are not in the change log.
+type UsersProjection struct {
+	store userRebuilder
+}
+
+// NewUsersProjection creates a projection rebuilding the users in the store.
+func NewUsersProjection(store userRebuilder) *UsersProjection {
+	return &UsersProjection{store: store}
+}
+
+// Apply writes the user of the change to the store, or deletes it.
+func (p *UsersProjection) Apply(ctx context.Context, change *UserChange) error {
+	if change.Type == changes.TypeDeleted {
+		if _, err := p.store.Delete(ctx, change.UserID); err != nil && !errors.Is(err, repository.ErrUserNotFound) {
+			return fmt.Errorf("could not delete user '%s': %w", change.UserID, err)
+		}
+		return nil
+	}
+
+	// The snapshot was erased by the deletion or anonymization of the user, which a
+	// later change replays.
+	if change.User == nil {
+		return nil
+	}
+
+	user := newUserStoreFromDomain(change.User)
+	user.PhoneVerified = change.User.PhoneVerified
+
+	stored, err := p.store.Get(repository.ContextWithPrimary(ctx), user.ID)
+	if err != nil {
+		if !errors.Is(err, repository.ErrUserNotFound) {
+			return fmt.Errorf("could not get user '%s': %w", user.ID, err)
+		}
+
+		user.Anonymized = change.User.Anonymized
+		if err := p.store.Insert(ctx, user); err != nil {
+			return fmt.Errorf("could not insert user '%s': %w", user.ID, err)
+		}
+		return nil
+	}
+
+	if change.User.Anonymized && !stored.Anonymized {
+		if err := p.store.Anonymize(ctx, user); err != nil {
+			return fmt.Errorf("could not anonymize user '%s': %w", user.ID, err)
+		}
+	} else if err := p.store.Update(ctx, user); err != nil {
+		return fmt.Errorf("could not update user '%s': %w", user.ID, err)
+	}
+
+	if user.Status != stored.Status {
+		if err := p.store.SetStatus(ctx, user); err != nil {
+			return fmt.Errorf("could not set status of user '%s': %w", user.ID, err)
+		}
+	}
+	return nil
+}
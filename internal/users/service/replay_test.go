@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alesr/usrsvc/internal/changes"
+	"github.com/alesr/usrsvc/internal/users/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestReplayChanges(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	hasher := &hasherMock{
+		HashFunc: func(ctx context.Context, password []byte) ([]byte, error) {
+			return bcrypt.GenerateFromPassword(password, bcrypt.MinCost)
+		},
+	}
+
+	repo := repository.NewMemory()
+	changeLog := changes.NewMemory()
+	svc := NewServiceDefault(zap.NewNop(), repo,
+		WithHasher(hasher),
+		WithPublisher(NewChangeRecorder(zap.NewNop(), repo, changeLog)),
+		WithChangeLog(changeLog),
+	)
+
+	newUser := func(nickname string) *User {
+		user, err := svc.Create(context.TODO(), &User{
+			FirstName: "John",
+			LastName:  "Doe",
+			Nickname:  nickname,
+			Password:  "password1!",
+			Email:     nickname + "@foo.bar",
+			Country:   "US",
+		})
+		require.NoError(t, err)
+		return user
+	}
+
+	updated, suspended, anonymized, deleted := newUser("jdoe"), newUser("jroe"), newUser("jpoe"), newUser("jmoe")
+
+	_, err := svc.Update(context.TODO(), &User{ID: updated.ID, Country: "PT"})
+	require.NoError(t, err)
+
+	_, err = svc.SuspendUser(context.TODO(), suspended.ID)
+	require.NoError(t, err)
+
+	_, err = svc.AnonymizeUser(context.TODO(), anonymized.ID)
+	require.NoError(t, err)
+
+	require.NoError(t, svc.Delete(context.TODO(), deleted.ID))
+
+	recorded, err := changeLog.List(context.TODO(), changes.Filter{Limit: 100})
+	require.NoError(t, err)
+
+	rebuilt := repository.NewMemory()
+
+	// Act
+	last, err := svc.ReplayChanges(context.TODO(), 0, NewUsersProjection(rebuilt))
+	require.NoError(t, err)
+
+	// Replaying again leaves the users as they are.
+	_, err = svc.ReplayChanges(context.TODO(), 0, NewUsersProjection(rebuilt))
+	require.NoError(t, err)
+
+	// Assert
+	assert.Equal(t, recorded[len(recorded)-1].ID, last)
+
+	for _, id := range []string{updated.ID, suspended.ID, anonymized.ID} {
+		expected, err := repo.Get(context.TODO(), id)
+		require.NoError(t, err)
+
+		observed, err := rebuilt.Get(context.TODO(), id)
+		require.NoError(t, err)
+
+		assert.Equal(t, expected.Email, observed.Email)
+		assert.Equal(t, expected.Country, observed.Country)
+		assert.Equal(t, expected.Status, observed.Status)
+		assert.Equal(t, expected.Anonymized, observed.Anonymized)
+		assert.Equal(t, expected.Password, observed.Password)
+	}
+
+	_, err = rebuilt.Get(context.TODO(), deleted.ID)
+	assert.True(t, errors.Is(err, repository.ErrUserNotFound))
+}
+
+func TestReplayChangesErrors(t *testing.T) {
+	t.Parallel()
+
+	t.Run("change log disabled", func(t *testing.T) {
+		// Arrange
+		svc := NewServiceDefault(zap.NewNop(), &repoMock{})
+
+		// Act
+		last, err := svc.ReplayChanges(context.TODO(), 7, NewUsersProjection(repository.NewMemory()))
+
+		// Assert
+		assert.Equal(t, int64(7), last)
+		assert.True(t, errors.Is(err, ErrChangeLogDisabled))
+	})
+
+	t.Run("projection failure", func(t *testing.T) {
+		// Arrange
+		changeLog := changes.NewMemory()
+		for _, userID := range []string{"first-id", "second-id"} {
+			require.NoError(t, changeLog.Record(context.TODO(), &changes.Change{Type: changes.TypeDeleted, UserID: userID}))
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), &repoMock{}, WithChangeLog(changeLog))
+
+		store := &repoMock{
+			DeleteFunc: func(ctx context.Context, id string) (int64, error) {
+				if id == "second-id" {
+					return 0, errors.New("connection refused")
+				}
+				return 1, nil
+			},
+		}
+
+		// Act
+		last, err := svc.ReplayChanges(context.TODO(), 0, NewUsersProjection(store))
+
+		// Assert
+		assert.Error(t, err)
+		assert.Equal(t, int64(1), last)
+	})
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"time"
 
 	"github.com/alesr/usrsvc/internal/users/repository"
 )
@@ -10,39 +11,239 @@ var _ repo = (*repoMock)(nil)
 
 // Mock is a mock implementation of the repository interface.
 type repoMock struct {
-	GetFunc                 func(ctx context.Context, id string) (*repository.User, error)
-	GetAllFunc              func(ctx context.Context, cursor string, limit int) ([]*repository.User, error)
-	GetByCountryFunc        func(ctx context.Context, country string, cursor string, limit int) ([]*repository.User, error)
-	InsertFunc              func(ctx context.Context, user *repository.User) error
-	UpdateFunc              func(ctx context.Context, user *repository.User) error
-	DeleteFunc              func(ctx context.Context, id string) error
-	CheckDatabaseHealthFunc func(ctx context.Context) error
+	GetFunc                      func(ctx context.Context, id string) (*repository.User, error)
+	GetByEmailFunc               func(ctx context.Context, email string) (*repository.User, error)
+	ListFunc                     func(ctx context.Context, filter repository.Filter, page repository.Page) ([]*repository.User, error)
+	CountFunc                    func(ctx context.Context, filter repository.Filter) (int64, error)
+	NicknameExistsFunc           func(ctx context.Context, nickname, country string) (bool, error)
+	SearchFunc                   func(ctx context.Context, query string, offset, limit int) ([]*repository.User, error)
+	InsertFunc                   func(ctx context.Context, user *repository.User) error
+	InsertBatchFunc              func(ctx context.Context, users []*repository.User) error
+	UpdateFunc                   func(ctx context.Context, user *repository.User) error
+	DeleteFunc                   func(ctx context.Context, id string) (int64, error)
+	MergeFunc                    func(ctx context.Context, survivor *repository.User, duplicateID string) error
+	AnonymizeFunc                func(ctx context.Context, user *repository.User) error
+	SetStatusFunc                func(ctx context.Context, user *repository.User) error
+	SetAvatarFunc                func(ctx context.Context, user *repository.User) error
+	BootstrapFunc                func(ctx context.Context, admin *repository.User, key *repository.APIKey) error
+	InsertAPIKeyFunc             func(ctx context.Context, key *repository.APIKey) error
+	GetAPIKeyFunc                func(ctx context.Context, id string) (*repository.APIKey, error)
+	GetAPIKeysFunc               func(ctx context.Context, userID string) ([]*repository.APIKey, error)
+	DeleteAPIKeyFunc             func(ctx context.Context, id string) error
+	LinkIdentityFunc             func(ctx context.Context, identity *repository.LinkedIdentity) error
+	GetLinkedIdentitiesFunc      func(ctx context.Context, userID string) ([]*repository.LinkedIdentity, error)
+	GetByLinkedIdentityFunc      func(ctx context.Context, provider, subject string) (*repository.User, error)
+	UnlinkIdentityFunc           func(ctx context.Context, userID, provider, subject string) error
+	LockFieldFunc                func(ctx context.Context, lock *repository.FieldLock) error
+	UnlockFieldFunc              func(ctx context.Context, userID, field string) error
+	GetFieldLocksFunc            func(ctx context.Context, userID string) ([]*repository.FieldLock, error)
+	RecordLoginFunc              func(ctx context.Context, login *repository.Login) error
+	GetRecentLoginsFunc          func(ctx context.Context, userID string, limit int) ([]*repository.Login, error)
+	GetLockoutFunc               func(ctx context.Context, userID string) (*repository.Lockout, error)
+	RecordFailedLoginFunc        func(ctx context.Context, userID string) (*repository.Lockout, error)
+	LockUserFunc                 func(ctx context.Context, userID string, until time.Time) error
+	ResetLockoutFunc             func(ctx context.Context, userID string) error
+	InsertSessionFunc            func(ctx context.Context, session *repository.Session) error
+	GetSessionFunc               func(ctx context.Context, id string) (*repository.Session, error)
+	GetSessionsFunc              func(ctx context.Context, userID string) ([]*repository.Session, error)
+	RotateSessionFunc            func(ctx context.Context, session *repository.Session, refreshTokenHash []byte) error
+	DeleteSessionFunc            func(ctx context.Context, id string) error
+	InsertTOTPFunc               func(ctx context.Context, enrollment *repository.TOTP) error
+	GetTOTPFunc                  func(ctx context.Context, userID string) (*repository.TOTP, error)
+	UseTOTPFunc                  func(ctx context.Context, userID string, step int64) error
+	InsertPhoneVerificationFunc  func(ctx context.Context, verification *repository.PhoneVerification) error
+	AttemptPhoneVerificationFunc func(ctx context.Context, userID string, now time.Time) (*repository.PhoneVerification, error)
+	DeletePhoneVerificationFunc  func(ctx context.Context, userID string) error
+	InsertPasswordResetTokenFunc func(ctx context.Context, token *repository.PasswordResetToken) error
+	ResetPasswordFunc            func(ctx context.Context, tokenHash []byte, password string, now time.Time) (*repository.User, error)
+	CountByCountryFunc           func(ctx context.Context) (map[string]int64, error)
+	CheckDatabaseHealthFunc      func(ctx context.Context) error
 }
 
 func (r *repoMock) Get(ctx context.Context, id string) (*repository.User, error) {
 	return r.GetFunc(ctx, id)
 }
 
-func (r *repoMock) GetAll(ctx context.Context, cursor string, limit int) ([]*repository.User, error) {
-	return r.GetAllFunc(ctx, cursor, limit)
+func (r *repoMock) GetByEmail(ctx context.Context, email string) (*repository.User, error) {
+	return r.GetByEmailFunc(ctx, email)
 }
 
-func (r *repoMock) GetByCountry(ctx context.Context, country string, cursor string, limit int) ([]*repository.User, error) {
-	return r.GetByCountryFunc(ctx, country, cursor, limit)
+func (r *repoMock) List(ctx context.Context, filter repository.Filter, page repository.Page) ([]*repository.User, error) {
+	return r.ListFunc(ctx, filter, page)
+}
+
+func (r *repoMock) Count(ctx context.Context, filter repository.Filter) (int64, error) {
+	return r.CountFunc(ctx, filter)
+}
+
+func (r *repoMock) NicknameExists(ctx context.Context, nickname, country string) (bool, error) {
+	return r.NicknameExistsFunc(ctx, nickname, country)
+}
+
+func (r *repoMock) Search(ctx context.Context, query string, offset, limit int) ([]*repository.User, error) {
+	return r.SearchFunc(ctx, query, offset, limit)
 }
 
 func (r *repoMock) Insert(ctx context.Context, user *repository.User) error {
 	return r.InsertFunc(ctx, user)
 }
 
+func (r *repoMock) InsertBatch(ctx context.Context, users []*repository.User) error {
+	return r.InsertBatchFunc(ctx, users)
+}
+
 func (r *repoMock) Update(ctx context.Context, user *repository.User) error {
 	return r.UpdateFunc(ctx, user)
 }
 
-func (r *repoMock) Delete(ctx context.Context, id string) error {
+func (r *repoMock) Delete(ctx context.Context, id string) (int64, error) {
 	return r.DeleteFunc(ctx, id)
 }
 
+func (r *repoMock) Merge(ctx context.Context, survivor *repository.User, duplicateID string) error {
+	return r.MergeFunc(ctx, survivor, duplicateID)
+}
+
+func (r *repoMock) Anonymize(ctx context.Context, user *repository.User) error {
+	return r.AnonymizeFunc(ctx, user)
+}
+
+func (r *repoMock) SetStatus(ctx context.Context, user *repository.User) error {
+	return r.SetStatusFunc(ctx, user)
+}
+
+func (r *repoMock) SetAvatar(ctx context.Context, user *repository.User) error {
+	return r.SetAvatarFunc(ctx, user)
+}
+
+func (r *repoMock) Bootstrap(ctx context.Context, admin *repository.User, key *repository.APIKey) error {
+	return r.BootstrapFunc(ctx, admin, key)
+}
+
+func (r *repoMock) InsertAPIKey(ctx context.Context, key *repository.APIKey) error {
+	return r.InsertAPIKeyFunc(ctx, key)
+}
+
+func (r *repoMock) GetAPIKey(ctx context.Context, id string) (*repository.APIKey, error) {
+	return r.GetAPIKeyFunc(ctx, id)
+}
+
+func (r *repoMock) GetAPIKeys(ctx context.Context, userID string) ([]*repository.APIKey, error) {
+	return r.GetAPIKeysFunc(ctx, userID)
+}
+
+func (r *repoMock) DeleteAPIKey(ctx context.Context, id string) error {
+	return r.DeleteAPIKeyFunc(ctx, id)
+}
+
+func (r *repoMock) LinkIdentity(ctx context.Context, identity *repository.LinkedIdentity) error {
+	return r.LinkIdentityFunc(ctx, identity)
+}
+
+func (r *repoMock) GetLinkedIdentities(ctx context.Context, userID string) ([]*repository.LinkedIdentity, error) {
+	return r.GetLinkedIdentitiesFunc(ctx, userID)
+}
+
+func (r *repoMock) GetByLinkedIdentity(ctx context.Context, provider, subject string) (*repository.User, error) {
+	return r.GetByLinkedIdentityFunc(ctx, provider, subject)
+}
+
+func (r *repoMock) UnlinkIdentity(ctx context.Context, userID, provider, subject string) error {
+	return r.UnlinkIdentityFunc(ctx, userID, provider, subject)
+}
+
+func (r *repoMock) LockField(ctx context.Context, lock *repository.FieldLock) error {
+	return r.LockFieldFunc(ctx, lock)
+}
+
+func (r *repoMock) UnlockField(ctx context.Context, userID, field string) error {
+	return r.UnlockFieldFunc(ctx, userID, field)
+}
+
+func (r *repoMock) GetFieldLocks(ctx context.Context, userID string) ([]*repository.FieldLock, error) {
+	return r.GetFieldLocksFunc(ctx, userID)
+}
+
+func (r *repoMock) RecordLogin(ctx context.Context, login *repository.Login) error {
+	return r.RecordLoginFunc(ctx, login)
+}
+
+func (r *repoMock) GetRecentLogins(ctx context.Context, userID string, limit int) ([]*repository.Login, error) {
+	return r.GetRecentLoginsFunc(ctx, userID, limit)
+}
+
+func (r *repoMock) GetLockout(ctx context.Context, userID string) (*repository.Lockout, error) {
+	return r.GetLockoutFunc(ctx, userID)
+}
+
+func (r *repoMock) RecordFailedLogin(ctx context.Context, userID string) (*repository.Lockout, error) {
+	return r.RecordFailedLoginFunc(ctx, userID)
+}
+
+func (r *repoMock) LockUser(ctx context.Context, userID string, until time.Time) error {
+	return r.LockUserFunc(ctx, userID, until)
+}
+
+func (r *repoMock) ResetLockout(ctx context.Context, userID string) error {
+	return r.ResetLockoutFunc(ctx, userID)
+}
+
+func (r *repoMock) InsertSession(ctx context.Context, session *repository.Session) error {
+	return r.InsertSessionFunc(ctx, session)
+}
+
+func (r *repoMock) GetSession(ctx context.Context, id string) (*repository.Session, error) {
+	return r.GetSessionFunc(ctx, id)
+}
+
+func (r *repoMock) GetSessions(ctx context.Context, userID string) ([]*repository.Session, error) {
+	return r.GetSessionsFunc(ctx, userID)
+}
+
+func (r *repoMock) RotateSession(ctx context.Context, session *repository.Session, refreshTokenHash []byte) error {
+	return r.RotateSessionFunc(ctx, session, refreshTokenHash)
+}
+
+func (r *repoMock) DeleteSession(ctx context.Context, id string) error {
+	return r.DeleteSessionFunc(ctx, id)
+}
+
+func (r *repoMock) InsertTOTP(ctx context.Context, enrollment *repository.TOTP) error {
+	return r.InsertTOTPFunc(ctx, enrollment)
+}
+
+func (r *repoMock) GetTOTP(ctx context.Context, userID string) (*repository.TOTP, error) {
+	return r.GetTOTPFunc(ctx, userID)
+}
+
+func (r *repoMock) UseTOTP(ctx context.Context, userID string, step int64) error {
+	return r.UseTOTPFunc(ctx, userID, step)
+}
+
+func (r *repoMock) InsertPhoneVerification(ctx context.Context, verification *repository.PhoneVerification) error {
+	return r.InsertPhoneVerificationFunc(ctx, verification)
+}
+
+func (r *repoMock) AttemptPhoneVerification(ctx context.Context, userID string, now time.Time) (*repository.PhoneVerification, error) {
+	return r.AttemptPhoneVerificationFunc(ctx, userID, now)
+}
+
+func (r *repoMock) DeletePhoneVerification(ctx context.Context, userID string) error {
+	return r.DeletePhoneVerificationFunc(ctx, userID)
+}
+
+func (r *repoMock) InsertPasswordResetToken(ctx context.Context, token *repository.PasswordResetToken) error {
+	return r.InsertPasswordResetTokenFunc(ctx, token)
+}
+
+func (r *repoMock) ResetPassword(ctx context.Context, tokenHash []byte, password string, now time.Time) (*repository.User, error) {
+	return r.ResetPasswordFunc(ctx, tokenHash, password, now)
+}
+
+func (r *repoMock) CountByCountry(ctx context.Context) (map[string]int64, error) {
+	return r.CountByCountryFunc(ctx)
+}
+
 func (r *repoMock) CheckDatabaseHealth(ctx context.Context) error {
 	return r.CheckDatabaseHealthFunc(ctx)
 }
@@ -0,0 +1,37 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"go.uber.org/zap"
+)
+
+// Search returns the users whose names or nickname contain words starting with every word
+// of the query, best matches first, skipping the first offset ones.
+func (s *ServiceDefault) Search(ctx context.Context, query string, offset, limit int) ([]*User, error) {
+	ctx, span := tracer.Start(ctx, "ServiceDefault.Search")
+	defer span.End()
+
+	if strings.IndexFunc(query, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) < 0 {
+		return nil, fmt.Errorf("could not validate search query: %w", ErrSearchQueryInvalid)
+	}
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	s.logger.Debug("searching users", zap.Int("offset", offset))
+
+	users, err := s.repo.Search(ctx, query, offset, limit)
+	if err != nil {
+		return nil, fmt.Errorf("could not search users: %w", err)
+	}
+
+	usersDomain := make([]*User, 0, len(users))
+	for _, user := range users {
+		usersDomain = append(usersDomain, newUserDomainFromStore(user))
+	}
+	return usersDomain, nil
+}
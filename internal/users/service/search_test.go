@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alesr/usrsvc/internal/users/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestSearch(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns the matching users", func(t *testing.T) {
+		// Arrange
+		repo := &repoMock{
+			SearchFunc: func(ctx context.Context, query string, offset, limit int) ([]*repository.User, error) {
+				assert.Equal(t, "john do", query)
+				assert.Equal(t, 10, offset)
+				assert.Equal(t, 5, limit)
+				return []*repository.User{{ID: "some-id", FirstName: "John", LastName: "Doe"}}, nil
+			},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), repo)
+
+		// Act
+		users, err := svc.Search(context.TODO(), "john do", 10, 5)
+
+		// Assert
+		require.NoError(t, err)
+		require.Len(t, users, 1)
+		assert.Equal(t, "some-id", users[0].ID)
+	})
+
+	t.Run("query without words", func(t *testing.T) {
+		// Arrange
+		svc := NewServiceDefault(zap.NewNop(), &repoMock{})
+
+		// Act
+		users, err := svc.Search(context.TODO(), " *&! ", 0, 5)
+
+		// Assert
+		assert.True(t, errors.Is(err, ErrSearchQueryInvalid))
+		assert.Nil(t, users)
+	})
+
+	t.Run("repository error", func(t *testing.T) {
+		// Arrange
+		repo := &repoMock{
+			SearchFunc: func(ctx context.Context, query string, offset, limit int) ([]*repository.User, error) {
+				return nil, errors.New("some error")
+			},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), repo)
+
+		// Act
+		users, err := svc.Search(context.TODO(), "john", 0, 5)
+
+		// Assert
+		assert.Error(t, err)
+		assert.Nil(t, users)
+	})
+}
@@ -6,23 +6,76 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/alesr/usrsvc/internal/audit"
+	"github.com/alesr/usrsvc/internal/redact"
+	"github.com/alesr/usrsvc/internal/tenant"
+	"github.com/alesr/usrsvc/internal/timeouts"
+	"github.com/alesr/usrsvc/internal/totp"
 	"github.com/alesr/usrsvc/internal/users/repository"
 	"github.com/alesr/usrsvc/pkg/events"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// dbTimeout is the default timeout of the operations called without a deadline.
 const dbTimeout time.Duration = 5 * time.Second
 
+var tracer = otel.Tracer("github.com/alesr/usrsvc/internal/users/service")
+
+// dummyHash is compared against when authenticating unknown users.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy-passw0rd!"), bcrypt.DefaultCost)
+
 // repo is the interface that provides the repository methods
 type repo interface {
 	Get(ctx context.Context, id string) (*repository.User, error)
-	GetAll(ctx context.Context, cursor string, limit int) ([]*repository.User, error)
-	GetByCountry(ctx context.Context, country string, cursor string, limit int) ([]*repository.User, error)
+	GetByEmail(ctx context.Context, email string) (*repository.User, error)
+	List(ctx context.Context, filter repository.Filter, page repository.Page) ([]*repository.User, error)
+	Count(ctx context.Context, filter repository.Filter) (int64, error)
+	NicknameExists(ctx context.Context, nickname, country string) (bool, error)
+	Search(ctx context.Context, query string, offset, limit int) ([]*repository.User, error)
 	Insert(ctx context.Context, user *repository.User) error
+	InsertBatch(ctx context.Context, users []*repository.User) error
 	Update(ctx context.Context, user *repository.User) error
-	Delete(ctx context.Context, id string) error
+	Delete(ctx context.Context, id string) (int64, error)
+	Merge(ctx context.Context, survivor *repository.User, duplicateID string) error
+	Anonymize(ctx context.Context, user *repository.User) error
+	SetStatus(ctx context.Context, user *repository.User) error
+	SetAvatar(ctx context.Context, user *repository.User) error
+	Bootstrap(ctx context.Context, admin *repository.User, key *repository.APIKey) error
+	InsertAPIKey(ctx context.Context, key *repository.APIKey) error
+	GetAPIKey(ctx context.Context, id string) (*repository.APIKey, error)
+	GetAPIKeys(ctx context.Context, userID string) ([]*repository.APIKey, error)
+	DeleteAPIKey(ctx context.Context, id string) error
+	LinkIdentity(ctx context.Context, identity *repository.LinkedIdentity) error
+	GetLinkedIdentities(ctx context.Context, userID string) ([]*repository.LinkedIdentity, error)
+	GetByLinkedIdentity(ctx context.Context, provider, subject string) (*repository.User, error)
+	UnlinkIdentity(ctx context.Context, userID, provider, subject string) error
+	LockField(ctx context.Context, lock *repository.FieldLock) error
+	UnlockField(ctx context.Context, userID, field string) error
+	GetFieldLocks(ctx context.Context, userID string) ([]*repository.FieldLock, error)
+	RecordLogin(ctx context.Context, login *repository.Login) error
+	GetRecentLogins(ctx context.Context, userID string, limit int) ([]*repository.Login, error)
+	GetLockout(ctx context.Context, userID string) (*repository.Lockout, error)
+	RecordFailedLogin(ctx context.Context, userID string) (*repository.Lockout, error)
+	LockUser(ctx context.Context, userID string, until time.Time) error
+	ResetLockout(ctx context.Context, userID string) error
+	InsertSession(ctx context.Context, session *repository.Session) error
+	GetSession(ctx context.Context, id string) (*repository.Session, error)
+	GetSessions(ctx context.Context, userID string) ([]*repository.Session, error)
+	RotateSession(ctx context.Context, session *repository.Session, refreshTokenHash []byte) error
+	DeleteSession(ctx context.Context, id string) error
+	InsertTOTP(ctx context.Context, enrollment *repository.TOTP) error
+	GetTOTP(ctx context.Context, userID string) (*repository.TOTP, error)
+	UseTOTP(ctx context.Context, userID string, step int64) error
+	InsertPhoneVerification(ctx context.Context, verification *repository.PhoneVerification) error
+	AttemptPhoneVerification(ctx context.Context, userID string, now time.Time) (*repository.PhoneVerification, error)
+	DeletePhoneVerification(ctx context.Context, userID string) error
+	InsertPasswordResetToken(ctx context.Context, token *repository.PasswordResetToken) error
+	ResetPassword(ctx context.Context, tokenHash []byte, password string, now time.Time) (*repository.User, error)
+	CountByCountry(ctx context.Context) (map[string]int64, error)
 	CheckDatabaseHealth(ctx context.Context) error
 }
 
@@ -31,11 +84,46 @@ type ServiceDefault struct {
 	logger    *zap.Logger
 	repo      repo
 	publisher Publisher
+	stats     *CountryStats
+	cache     Cache
+	cacheTTL  time.Duration
+	hasher    Hasher
+
+	passwordChecker PasswordChecker
+	geoResolver     GeoResolver
+	lockoutPolicy   LockoutPolicy
+	auditLog        AuditLog
+	changeLog       ChangeLog
+	listCache       *ListCache
+	redaction       *redact.Policy
+
+	bootstrapToken string
+	identities     map[string]IdentityVerifier
+	resetTokenTTL  time.Duration
+
+	accessTokenTTL  time.Duration
+	refreshTokenTTL time.Duration
+
+	totpCipher *totp.Cipher
+	totpIssuer string
+
+	avatars BlobStore
+
+	smsSender    SMSSender
+	phoneCodeTTL time.Duration
+
+	minAge int
+
+	importEventRate float64
+
+	uniquenessScope repository.UniquenessScope
+
+	timeouts timeouts.Policy
 }
 
 // Publisher is the interface that provides the publish method.
 type Publisher interface {
-	Publish(event events.Event, data any) error
+	Publish(ctx context.Context, event events.Event, data any) error
 }
 
 // Option is a function that configures the service.
@@ -48,11 +136,46 @@ func WithPublisher(publisher Publisher) Option {
 	}
 }
 
+// publish publishes the event with the trace and deadline of the request, logging the
+// failures. Events are published after the change is committed, so a failure must not
+// fail the request.
+func (s *ServiceDefault) publish(ctx context.Context, event events.Event, data any) {
+	if err := s.publisher.Publish(ctx, event, data); err != nil {
+		s.logger.Error("could not publish event", zap.String("event", string(event)), zap.Error(err))
+	}
+}
+
+// WithTimeoutPolicy configures the deadline of the operations. The operations called
+// with a deadline, e.g. by the RPCs, keep it within the maximum of the policy, and the
+// others, e.g. of the background jobs, get the default timeout (5s unless configured).
+func WithTimeoutPolicy(policy timeouts.Policy) Option {
+	return func(s *ServiceDefault) {
+		s.timeouts = policy
+	}
+}
+
+// WithCountryStats configures the service to serve the user stats from the given cache.
+// The cache must also receive the service events (see events.Fanout) to stay fresh.
+func WithCountryStats(stats *CountryStats) Option {
+	return func(s *ServiceDefault) {
+		s.stats = stats
+	}
+}
+
 // NewServiceDefault creates a new service.
 func NewServiceDefault(logger *zap.Logger, repo repo, opts ...Option) *ServiceDefault {
 	s := &ServiceDefault{
 		logger: logger,
 		repo:   repo,
+		hasher: bcryptHasher{},
+
+		resetTokenTTL:   defaultResetTokenTTL,
+		accessTokenTTL:  defaultAccessTokenTTL,
+		refreshTokenTTL: defaultRefreshTokenTTL,
+		phoneCodeTTL:    defaultPhoneCodeTTL,
+		importEventRate: defaultImportEventRate,
+		uniquenessScope: repository.ScopeGlobal,
+		timeouts:        timeouts.Policy{Default: dbTimeout},
 	}
 
 	for _, opt := range opts {
@@ -63,13 +186,21 @@ func NewServiceDefault(logger *zap.Logger, repo repo, opts ...Option) *ServiceDe
 
 // Get returns a user by id.
 func (s *ServiceDefault) Fetch(ctx context.Context, id string) (*User, error) {
+	ctx, span := tracer.Start(ctx, "ServiceDefault.Fetch")
+	defer span.End()
+	span.SetAttributes(attribute.String("user.id", id))
+
 	if _, err := uuid.Parse(id); err != nil {
 		return nil, fmt.Errorf("could not validate id '%s': %w", id, ErrInvalidID)
 	}
 
-	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
 	defer cancel()
 
+	if user, ok := s.getCachedUser(ctx, id); ok {
+		return user, nil
+	}
+
 	user, err := s.repo.Get(ctx, id)
 	if err != nil {
 		if errors.Is(err, repository.ErrUserNotFound) {
@@ -78,123 +209,359 @@ func (s *ServiceDefault) Fetch(ctx context.Context, id string) (*User, error) {
 
 		return nil, fmt.Errorf("could not fetch user with id '%s': %w", id, err)
 	}
+
+	fetched := newUserDomainFromStore(user)
+	s.cacheUser(ctx, fetched)
+	return fetched, nil
+}
+
+// FetchByEmail returns a user by email.
+func (s *ServiceDefault) FetchByEmail(ctx context.Context, email string) (*User, error) {
+	ctx, span := tracer.Start(ctx, "ServiceDefault.FetchByEmail")
+	defer span.End()
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	user, err := s.repo.GetByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return nil, fmt.Errorf("could not fetch user by email: %w", ErrUserNotFound)
+		}
+		if errors.Is(err, repository.ErrAmbiguousEmail) {
+			return nil, fmt.Errorf("could not fetch user by email: %w", ErrEmailAmbiguous)
+		}
+		return nil, fmt.Errorf("could not fetch user by email: %w", err)
+	}
 	return newUserDomainFromStore(user), nil
 }
 
-// FetchAll returns all users or users filtered by country.
+// FetchAll returns all users or the users selected by the filter.
 func (s *ServiceDefault) FetchAll(ctx context.Context, filter FilterParams, pag PaginationParams) ([]*User, error) {
-	filter.normalize()
+	ctx, span := tracer.Start(ctx, "ServiceDefault.FetchAll")
+	defer span.End()
 
-	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
-	defer cancel()
+	filter.normalize()
+	if err := filter.validate(); err != nil {
+		return nil, fmt.Errorf("could not validate fetch all filter: %w", err)
+	}
 
-	var (
-		users []*repository.User
-		err   error
-	)
+	cacheKey, cacheable := listCacheKeyFor(ctx, filter, pag)
+	cacheable = cacheable && s.listCache != nil
 
-	switch {
-	case filter.Country != nil:
-		if err := filter.validate(); err != nil {
-			return nil, fmt.Errorf("could not validate fetch all filter: %w", err)
+	var cacheGeneration uint64
+	if cacheable {
+		cached, generation, ok := s.listCache.get(cacheKey)
+		if ok {
+			return cached, nil
 		}
+		cacheGeneration = generation
+	}
 
-		s.logger.Debug("fetching users by country", zap.String("country", *filter.Country))
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
 
-		users, err = s.repo.GetByCountry(ctx, *filter.Country, pag.Cursor, pag.Limit)
-		if err != nil {
-			return nil, fmt.Errorf("could not fetch users by country: %w", err)
-		}
-	default:
-		s.logger.Debug("fetching all users")
+	s.logger.Debug("fetching users")
 
-		users, err = s.repo.GetAll(ctx, pag.Cursor, pag.Limit)
-		if err != nil {
-			return nil, fmt.Errorf("could not fetch users: %w", err)
-		}
+	users, err := s.repo.List(ctx, filter.storeFilter(), repository.Page{Cursor: pag.Cursor, Limit: pag.Limit})
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch users: %w", err)
 	}
 
 	var usersDomain []*User
 	for _, user := range users {
 		usersDomain = append(usersDomain, newUserDomainFromStore(user))
 	}
+
+	if cacheable {
+		s.listCache.set(cacheKey, cacheGeneration, usersDomain)
+	}
 	return usersDomain, nil
 }
 
+// Count returns the number of users selected by the filter. It runs a COUNT query,
+// so it is only called when the total is asked for.
+func (s *ServiceDefault) Count(ctx context.Context, filter FilterParams) (int64, error) {
+	ctx, span := tracer.Start(ctx, "ServiceDefault.Count")
+	defer span.End()
+
+	filter.normalize()
+	if err := filter.validate(); err != nil {
+		return 0, fmt.Errorf("could not validate count filter: %w", err)
+	}
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	count, err := s.repo.Count(ctx, filter.storeFilter())
+	if err != nil {
+		return 0, fmt.Errorf("could not count users: %w", err)
+	}
+	return count, nil
+}
+
 // Create creates a new user.
 // NOTE: I left the input validation only in the transport layer, but it could be done here too.
 func (s *ServiceDefault) Create(ctx context.Context, user *User) (*User, error) {
+	ctx, span := tracer.Start(ctx, "ServiceDefault.Create")
+	defer span.End()
+
+	user.normalize()
+
+	if err := s.checkPassword(ctx, user.Password); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkNewUser(user); err != nil {
+		return nil, err
+	}
+
 	user.ID = uuid.New().String()
+	span.SetAttributes(attribute.String("user.id", user.ID))
 	user.CreatedAt = time.Now()
 	user.UpdatedAt = time.Now()
 
-	hash, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+	hash, err := s.hasher.Hash(ctx, []byte(user.Password))
 	if err != nil {
-		return nil, fmt.Errorf("could not hash password: %s", err)
+		return nil, fmt.Errorf("could not hash password: %w", hashingError(err))
 	}
 
 	// Replace the password with the hash.
 	user.Password = string(hash)
 
-	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
 	defer cancel()
 
-	if err := s.repo.Insert(ctx, newUserStoreFromDomain(user)); err != nil {
-		if errors.Is(err, repository.ErrDuplicateEmail) {
-			return nil, fmt.Errorf("could not insert user: %w", ErrUserAlreadyExists)
+	stored := newUserStoreFromDomain(user)
+	if err := s.repo.Insert(ctx, stored); err != nil {
+		if dupErr := s.duplicateError(err); dupErr != nil {
+			return nil, fmt.Errorf("could not insert user: %w", dupErr)
 		}
 		return nil, fmt.Errorf("could not insert user: %w", err)
 	}
 
+	// The status of the new users is set by the repository.
+	user.Status = stored.Status
+
+	s.audit(ctx, audit.ActionCreate, user.ID, nil, stored)
+
 	if s.publisher != nil {
 		// Just keeping it simple. The most important thing is to not publish the user's password.
-		s.publisher.Publish(events.UserCreated, user.ID)
+		s.publish(ctx, events.UserCreated, userEvent(user.ID, stored.EventSequence, user.ID))
 	}
 	return user, nil
 }
 
-// Update updates an existing user.
+// checkNewUser checks the fields of a user to create, but its password, and normalizes
+// its metadata. The phone of new users is unverified.
+func (s *ServiceDefault) checkNewUser(user *User) error {
+	metadata, err := updatedMetadata(nil, user.Metadata)
+	if err != nil {
+		return err
+	}
+	user.Metadata = metadata
+
+	if err := checkPhone(user.Phone); err != nil {
+		return err
+	}
+	user.PhoneVerified = false
+
+	if err := checkLocale(user.Locale, user.Timezone); err != nil {
+		return err
+	}
+	return s.checkBirthdate(user.Birthdate)
+}
+
+// Update updates an existing user. The password is kept, it is changed with ChangePassword.
 // NOTE: I left the input validation only in the transport layer, but it could be done here too.
 func (s *ServiceDefault) Update(ctx context.Context, user *User) (*User, error) {
-	user.UpdatedAt = time.Now()
+	ctx, span := tracer.Start(ctx, "ServiceDefault.Update")
+	defer span.End()
+	span.SetAttributes(attribute.String("user.id", user.ID))
+
+	user.normalize()
+
+	dbCtx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
 
-	hash, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+	// Read from the primary, a stale user would overwrite the latest changes.
+	before, err := s.repo.Get(repository.ContextWithPrimary(dbCtx), user.ID)
 	if err != nil {
-		return nil, fmt.Errorf("could not hash password: %s", err)
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return nil, fmt.Errorf("could not update user: %w", ErrUserNotFound)
+		}
+		return nil, fmt.Errorf("could not update user: %w", err)
 	}
 
-	// Replace the password with the hash.
-	user.Password = string(hash)
+	if before.Anonymized {
+		return nil, fmt.Errorf("could not update user: %w", ErrUserAnonymized)
+	}
+
+	// The stored user is updated, so the fields that are not updatable are kept.
+	stored := *before
+	stored.FirstName = user.FirstName
+	stored.LastName = user.LastName
+	stored.Nickname = user.Nickname
+	stored.Email = user.Email
+	stored.Country = user.Country
+	stored.UpdatedAt = time.Now()
+
+	metadata, err := updatedMetadata(before.Metadata, user.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	stored.Metadata = metadata
 
-	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	if user.Phone != "" && user.Phone != before.Phone {
+		if err := checkPhone(user.Phone); err != nil {
+			return nil, err
+		}
+
+		// The new phone must be verified again.
+		stored.Phone = user.Phone
+		stored.PhoneVerified = false
+	}
+
+	if err := checkLocale(user.Locale, user.Timezone); err != nil {
+		return nil, err
+	}
+	if user.Locale != "" {
+		stored.Locale = user.Locale
+	}
+	if user.Timezone != "" {
+		stored.Timezone = user.Timezone
+	}
+
+	if !user.Birthdate.IsZero() {
+		if err := s.checkBirthdate(user.Birthdate); err != nil {
+			return nil, err
+		}
+		stored.Birthdate = birthdateToStore(user.Birthdate)
+	}
+
+	ctx, cancel = s.timeouts.WithTimeout(ctx)
 	defer cancel()
 
-	if err := s.repo.Update(ctx, newUserStoreFromDomain(user)); err != nil {
+	locks, err := s.repo.GetFieldLocks(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get field locks: %w", err)
+	}
+
+	if err := s.checkFieldLocks(ctx, locks, before, &stored); err != nil {
+		return nil, fmt.Errorf("could not update user: %w", err)
+	}
+
+	if err := s.repo.Update(ctx, &stored); err != nil {
 		if errors.Is(err, repository.ErrUserNotFound) {
 			return nil, fmt.Errorf("could not update user: %w", ErrUserNotFound)
 		}
-		if errors.Is(err, repository.ErrDuplicateEmail) {
-			return nil, fmt.Errorf("could not update user: %w", ErrUserAlreadyExists)
+		if dupErr := s.duplicateError(err); dupErr != nil {
+			return nil, fmt.Errorf("could not update user: %w", dupErr)
 		}
 		return nil, fmt.Errorf("could not update user: %w", err)
 	}
 
+	s.invalidateCachedUser(ctx, user.ID)
+	s.audit(ctx, audit.ActionUpdate, user.ID, before, &stored)
+
 	if s.publisher != nil {
-		s.publisher.Publish(events.UserUpdated, user.ID)
+		s.publish(ctx, events.UserUpdated, userEvent(user.ID, stored.EventSequence, user.ID))
 	}
-	return user, nil
+	return newUserDomainFromStore(&stored), nil
+}
+
+// UpdateProfile updates the names, nickname and country of an existing user,
+// keeping its email and password. It is meant for syncs from external directories.
+func (s *ServiceDefault) UpdateProfile(ctx context.Context, user *User) (*User, error) {
+	ctx, span := tracer.Start(ctx, "ServiceDefault.UpdateProfile")
+	defer span.End()
+	span.SetAttributes(attribute.String("user.id", user.ID))
+
+	user.normalize()
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	stored, err := s.repo.Get(repository.ContextWithPrimary(ctx), user.ID)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return nil, fmt.Errorf("could not fetch user with id '%s': %w", user.ID, ErrUserNotFound)
+		}
+		return nil, fmt.Errorf("could not fetch user with id '%s': %w", user.ID, err)
+	}
+
+	if stored.Anonymized {
+		return nil, fmt.Errorf("could not update user profile: %w", ErrUserAnonymized)
+	}
+
+	before := *stored
+
+	stored.FirstName = user.FirstName
+	stored.LastName = user.LastName
+	stored.Nickname = user.Nickname
+	stored.Country = user.Country
+	stored.UpdatedAt = time.Now()
+
+	locks, err := s.repo.GetFieldLocks(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get field locks: %w", err)
+	}
+
+	if err := s.checkFieldLocks(ctx, locks, &before, stored); err != nil {
+		return nil, fmt.Errorf("could not update user profile: %w", err)
+	}
+
+	if err := s.repo.Update(ctx, stored); err != nil {
+		switch dupErr := s.duplicateError(err); {
+		case errors.Is(err, repository.ErrUserNotFound):
+			err = ErrUserNotFound
+		case dupErr != nil:
+			err = dupErr
+		case errors.Is(err, repository.ErrRegionChange):
+			err = ErrRegionChange
+		}
+		return nil, fmt.Errorf("could not update user profile: %w", err)
+	}
+
+	s.invalidateCachedUser(ctx, user.ID)
+	s.audit(ctx, audit.ActionUpdate, user.ID, &before, stored)
+
+	if s.publisher != nil {
+		s.publish(ctx, events.UserUpdated, userEvent(user.ID, stored.EventSequence, user.ID))
+	}
+
+	updated := newUserDomainFromStore(stored)
+
+	// The hash never leaves the service.
+	updated.Password = ""
+	return updated, nil
 }
 
 // Delete deletes an existing user.
 func (s *ServiceDefault) Delete(ctx context.Context, id string) error {
+	ctx, span := tracer.Start(ctx, "ServiceDefault.Delete")
+	defer span.End()
+	span.SetAttributes(attribute.String("user.id", id))
+
 	if _, err := uuid.Parse(id); err != nil {
 		return fmt.Errorf("could not validate id '%s': %w", id, ErrInvalidID)
 	}
 
-	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
 	defer cancel()
 
-	if err := s.repo.Delete(ctx, id); err != nil {
+	// The user is read before its deletion for the audit log and its avatar.
+	var before *repository.User
+	if s.auditing() || s.avatars != nil {
+		before, _ = s.repo.Get(ctx, id)
+	}
+
+	sequence, err := s.repo.Delete(ctx, id)
+
+	// Invalidate even when the user is not found, it may have been deleted by another instance.
+	s.invalidateCachedUser(ctx, id)
+
+	if err != nil {
 		if errors.Is(err, repository.ErrUserNotFound) {
 			s.logger.Info("could not delete user non existing user", zap.String("id", id), zap.Error(err))
 			return nil
@@ -202,15 +569,107 @@ func (s *ServiceDefault) Delete(ctx context.Context, id string) error {
 		return fmt.Errorf("could not delete user with id '%s': %w", id, err)
 	}
 
+	if before != nil {
+		s.deleteAvatar(ctx, before.AvatarKey)
+	}
+
+	s.audit(ctx, audit.ActionDelete, id, before, nil)
+
 	if s.publisher != nil {
-		s.publisher.Publish(events.UserDeleted, id)
+		s.publish(ctx, events.UserDeleted, userEvent(id, sequence, id))
 	}
 	return nil
 }
 
+// Authenticate verifies the given credentials and returns the matching user.
+// The same error is returned for unknown emails and wrong passwords so callers
+// can't use this method to find out which emails are registered. The users who
+// enabled TOTP must also give a current code.
+func (s *ServiceDefault) Authenticate(ctx context.Context, email, password, totpCode string) (*User, error) {
+	ctx, span := tracer.Start(ctx, "ServiceDefault.Authenticate")
+	defer span.End()
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	user, err := s.repo.GetByEmail(ctx, email)
+	if err != nil {
+		// The emails shared within the uniqueness scope can't identify a user, so they
+		// are rejected like the unknown ones.
+		if errors.Is(err, repository.ErrUserNotFound) || errors.Is(err, repository.ErrAmbiguousEmail) {
+			// Compare against a dummy hash anyway so the response time
+			// doesn't tell whether the email exists or not.
+			_ = s.hasher.Compare(ctx, dummyHashOf(s.hasher), []byte(password))
+			return nil, fmt.Errorf("could not authenticate user: %w", ErrInvalidCredentials)
+		}
+		return nil, fmt.Errorf("could not authenticate user: %w", err)
+	}
+
+	// Locked out users are rejected before their password is compared, so it can't be guessed meanwhile.
+	lockout, err := s.verifyPassword(ctx, user, password)
+	if err != nil {
+		return nil, fmt.Errorf("could not authenticate user '%s': %w", user.ID, err)
+	}
+
+	if err := s.checkTOTP(ctx, user.ID, totpCode); err != nil {
+		// Wrong codes count towards the lockout, so they can't be guessed either.
+		if errors.Is(err, ErrTOTPInvalid) {
+			s.recordFailedLogin(ctx, user.ID)
+			return nil, fmt.Errorf("could not authenticate user '%s': %w", user.ID, ErrInvalidCredentials)
+		}
+		return nil, fmt.Errorf("could not authenticate user '%s': %w", user.ID, err)
+	}
+
+	// The status is only checked once the credentials are verified, so it isn't told to anyone else.
+	if err := checkStatus(user); err != nil {
+		return nil, fmt.Errorf("could not authenticate user '%s': %w", user.ID, err)
+	}
+
+	s.resetLockout(ctx, lockout)
+	s.rehash(ctx, user, password)
+	s.trackLogin(ctx, user.ID)
+
+	authenticated := newUserDomainFromStore(user)
+
+	// The hash never leaves the service.
+	authenticated.Password = ""
+	return authenticated, nil
+}
+
+// Stats returns the number of users per country. They are served from the
+// stats cache when it is configured and ready, otherwise they are counted in the database.
+func (s *ServiceDefault) Stats(ctx context.Context) (*UserStats, error) {
+	ctx, span := tracer.Start(ctx, "ServiceDefault.Stats")
+	defer span.End()
+
+	if s.stats != nil && tenant.OrDefault(ctx) == tenant.Default {
+		if stats, ok := s.stats.Snapshot(); ok {
+			return stats, nil
+		}
+	}
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	counts, err := s.repo.CountByCountry(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not count users by country: %w", err)
+	}
+	return &UserStats{CountByCountry: counts, RefreshedAt: time.Now()}, nil
+}
+
+// userEvent wraps the event data so it is ordered by the user event sequence,
+// which the repository increments on every write to the user.
+func userEvent(id string, sequence int64, data any) events.Ordered {
+	return events.Ordered{Key: id, Sequence: sequence, Data: data}
+}
+
 // CheckServiceHealth checks if the service is healthy.
 func (s *ServiceDefault) CheckServiceHealth(ctx context.Context) error {
-	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	ctx, span := tracer.Start(ctx, "ServiceDefault.CheckServiceHealth")
+	defer span.End()
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
 	defer cancel()
 
 	if err := s.repo.CheckDatabaseHealth(ctx); err != nil {
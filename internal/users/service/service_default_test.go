@@ -6,17 +6,66 @@ import (
 	"testing"
 	"time"
 
+	"github.com/alesr/usrsvc/internal/hashing"
+	"github.com/alesr/usrsvc/internal/timeouts"
 	"github.com/alesr/usrsvc/internal/users/repository"
 	"github.com/alesr/usrsvc/pkg/events"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func TestFetch(t *testing.T) {
 	t.Parallel()
 
+	t.Run("deadline of the timeout policy", func(t *testing.T) {
+		callerCtx, cancel := context.WithTimeout(context.TODO(), time.Minute)
+		defer cancel()
+		callerDeadline, _ := callerCtx.Deadline()
+
+		testCases := []struct {
+			name             string
+			ctx              context.Context
+			expectedDeadline time.Time
+		}{
+			{
+				name:             "default without deadline",
+				ctx:              context.TODO(),
+				expectedDeadline: time.Now().Add(time.Second),
+			},
+			{
+				name:             "caller deadline",
+				ctx:              callerCtx,
+				expectedDeadline: callerDeadline,
+			},
+		}
+
+		for _, tc := range testCases {
+			tc := tc
+			t.Run(tc.name, func(t *testing.T) {
+				// Arrange
+				var actualDeadline time.Time
+				repo := &repoMock{
+					GetFunc: func(ctx context.Context, id string) (*repository.User, error) {
+						actualDeadline, _ = ctx.Deadline()
+						return &repository.User{ID: id}, nil
+					},
+				}
+
+				svc := NewServiceDefault(zap.NewNop(), repo, WithTimeoutPolicy(timeouts.Policy{Default: time.Second}))
+
+				// Act
+				_, err := svc.Fetch(tc.ctx, uuid.New().String())
+
+				// Assert
+				require.NoError(t, err)
+				assert.WithinDuration(t, tc.expectedDeadline, actualDeadline, 100*time.Millisecond)
+			})
+		}
+	})
+
 	t.Run("success", func(t *testing.T) {
 		// Arrange
 
@@ -43,7 +92,7 @@ func TestFetch(t *testing.T) {
 
 		var publisherWasCalled bool
 		publisher := &publisherMock{
-			PublishFunc: func(event events.Event, data any) error {
+			PublishFunc: func(_ context.Context, event events.Event, data any) error {
 				publisherWasCalled = true
 				return nil
 			},
@@ -83,7 +132,7 @@ func TestFetch(t *testing.T) {
 
 		var publisherWasCalled bool
 		publisher := &publisherMock{
-			PublishFunc: func(event events.Event, data any) error {
+			PublishFunc: func(_ context.Context, event events.Event, data any) error {
 				publisherWasCalled = true
 				return nil
 			},
@@ -108,7 +157,7 @@ func TestFetch(t *testing.T) {
 
 		var publisherWasCalled bool
 		publisher := &publisherMock{
-			PublishFunc: func(event events.Event, data any) error {
+			PublishFunc: func(_ context.Context, event events.Event, data any) error {
 				publisherWasCalled = true
 				return nil
 			},
@@ -131,7 +180,7 @@ func TestFetch(t *testing.T) {
 
 		var publisherWasCalled bool
 		publisher := &publisherMock{
-			PublishFunc: func(event events.Event, data any) error {
+			PublishFunc: func(_ context.Context, event events.Event, data any) error {
 				publisherWasCalled = true
 				return nil
 			},
@@ -163,7 +212,7 @@ func TestFetch(t *testing.T) {
 
 		var publisherWasCalled bool
 		publisher := &publisherMock{
-			PublishFunc: func(event events.Event, data any) error {
+			PublishFunc: func(_ context.Context, event events.Event, data any) error {
 				publisherWasCalled = true
 				return nil
 			},
@@ -191,11 +240,11 @@ func TestFetchAll(t *testing.T) {
 		id1 := uuid.New().String()
 		id2 := uuid.New().String()
 
-		var getAllFuncWasCalled bool
+		var listFuncWasCalled bool
 
 		repo := &repoMock{
-			GetAllFunc: func(ctx context.Context, cursor string, limit int) ([]*repository.User, error) {
-				getAllFuncWasCalled = true
+			ListFunc: func(ctx context.Context, filter repository.Filter, page repository.Page) ([]*repository.User, error) {
+				listFuncWasCalled = true
 				return []*repository.User{
 					{
 						ID:        id1,
@@ -225,7 +274,7 @@ func TestFetchAll(t *testing.T) {
 
 		var publisherWasCalled bool
 		publisher := &publisherMock{
-			PublishFunc: func(event events.Event, data any) error {
+			PublishFunc: func(_ context.Context, event events.Event, data any) error {
 				publisherWasCalled = true
 				return nil
 			},
@@ -241,7 +290,7 @@ func TestFetchAll(t *testing.T) {
 
 		require.Len(t, actualUser, 2)
 
-		assert.True(t, getAllFuncWasCalled)
+		assert.True(t, listFuncWasCalled)
 		assert.False(t, publisherWasCalled)
 
 		assert.Equal(t, id1, actualUser[0].ID)
@@ -268,17 +317,17 @@ func TestFetchAll(t *testing.T) {
 	t.Run("empty list", func(t *testing.T) {
 		// Arrange
 
-		var getAllFuncWasCalled bool
+		var listFuncWasCalled bool
 		repo := &repoMock{
-			GetAllFunc: func(ctx context.Context, cursor string, limit int) ([]*repository.User, error) {
-				getAllFuncWasCalled = true
+			ListFunc: func(ctx context.Context, filter repository.Filter, page repository.Page) ([]*repository.User, error) {
+				listFuncWasCalled = true
 				return []*repository.User{}, nil
 			},
 		}
 
 		var publisherWasCalled bool
 		publisher := &publisherMock{
-			PublishFunc: func(event events.Event, data any) error {
+			PublishFunc: func(_ context.Context, event events.Event, data any) error {
 				publisherWasCalled = true
 				return nil
 			},
@@ -291,7 +340,7 @@ func TestFetchAll(t *testing.T) {
 		require.NoError(t, err)
 
 		// Assert
-		require.True(t, getAllFuncWasCalled)
+		require.True(t, listFuncWasCalled)
 		require.False(t, publisherWasCalled)
 		assert.Len(t, actualUser, 0)
 	})
@@ -302,11 +351,11 @@ func TestFetchAll(t *testing.T) {
 		id1 := uuid.New().String()
 		id2 := uuid.New().String()
 
-		var getByCountryFuncWasCalled bool
+		var listFuncWasCalled bool
 
 		repo := &repoMock{
-			GetByCountryFunc: func(ctx context.Context, country, cursor string, limit int) ([]*repository.User, error) {
-				getByCountryFuncWasCalled = true
+			ListFunc: func(ctx context.Context, filter repository.Filter, page repository.Page) ([]*repository.User, error) {
+				listFuncWasCalled = true
 				return []*repository.User{
 					{
 						ID:        id1,
@@ -336,7 +385,7 @@ func TestFetchAll(t *testing.T) {
 
 		var publisherWasCalled bool
 		publisher := &publisherMock{
-			PublishFunc: func(event events.Event, data any) error {
+			PublishFunc: func(_ context.Context, event events.Event, data any) error {
 				publisherWasCalled = true
 				return nil
 			},
@@ -354,7 +403,7 @@ func TestFetchAll(t *testing.T) {
 
 		require.Len(t, actualUser, 2)
 
-		assert.True(t, getByCountryFuncWasCalled)
+		assert.True(t, listFuncWasCalled)
 		assert.False(t, publisherWasCalled)
 		assert.Equal(t, id1, actualUser[0].ID)
 		assert.Equal(t, "John", actualUser[0].FirstName)
@@ -382,7 +431,7 @@ func TestFetchAll(t *testing.T) {
 
 		var publisherWasCalled bool
 		publisher := &publisherMock{
-			PublishFunc: func(event events.Event, data any) error {
+			PublishFunc: func(_ context.Context, event events.Event, data any) error {
 				publisherWasCalled = true
 				return nil
 			},
@@ -410,7 +459,7 @@ func TestFetchAll(t *testing.T) {
 		svc := NewServiceDefault(zap.NewNop(), &repoMock{})
 
 		// Act
-		country := "invalid-country"
+		country := "ZZ"
 		actualUser, actualErr := svc.FetchAll(
 			context.TODO(),
 			FilterParams{Country: &country},
@@ -423,21 +472,21 @@ func TestFetchAll(t *testing.T) {
 		assert.Nil(t, actualUser)
 	})
 
-	t.Run("repo get all error", func(t *testing.T) {
+	t.Run("repo list error", func(t *testing.T) {
 		// Arrange
 
-		var getAllFuncWasCalled bool
+		var listFuncWasCalled bool
 
 		repo := &repoMock{
-			GetAllFunc: func(ctx context.Context, cursor string, limit int) ([]*repository.User, error) {
-				getAllFuncWasCalled = true
+			ListFunc: func(ctx context.Context, filter repository.Filter, page repository.Page) ([]*repository.User, error) {
+				listFuncWasCalled = true
 				return nil, errors.New("repo error")
 			},
 		}
 
 		var publisherWasCalled bool
 		publisher := &publisherMock{
-			PublishFunc: func(event events.Event, data any) error {
+			PublishFunc: func(_ context.Context, event events.Event, data any) error {
 				publisherWasCalled = true
 				return nil
 			},
@@ -452,28 +501,28 @@ func TestFetchAll(t *testing.T) {
 		)
 
 		// Assert
-		assert.True(t, getAllFuncWasCalled)
+		assert.True(t, listFuncWasCalled)
 		assert.False(t, publisherWasCalled)
 		assert.Error(t, actualErr)
 		assert.Error(t, actualErr)
 		assert.Nil(t, actualUser)
 	})
 
-	t.Run("repo get by country error", func(t *testing.T) {
+	t.Run("repo list by country error", func(t *testing.T) {
 		// Arrange
 
-		var getByCountryFuncWasCalled bool
+		var listFuncWasCalled bool
 
 		repo := &repoMock{
-			GetByCountryFunc: func(ctx context.Context, country, cursor string, limit int) ([]*repository.User, error) {
-				getByCountryFuncWasCalled = true
+			ListFunc: func(ctx context.Context, filter repository.Filter, page repository.Page) ([]*repository.User, error) {
+				listFuncWasCalled = true
 				return nil, errors.New("repo error")
 			},
 		}
 
 		var publisherWasCalled bool
 		publisher := &publisherMock{
-			PublishFunc: func(event events.Event, data any) error {
+			PublishFunc: func(_ context.Context, event events.Event, data any) error {
 				publisherWasCalled = true
 				return nil
 			},
@@ -490,15 +539,128 @@ func TestFetchAll(t *testing.T) {
 
 		// Assert
 
-		assert.True(t, getByCountryFuncWasCalled)
+		assert.True(t, listFuncWasCalled)
 		assert.False(t, publisherWasCalled)
 		assert.Error(t, actualErr)
 		assert.Error(t, actualErr)
 		assert.Nil(t, actualUser)
 	})
+
+	t.Run("success with combined filters", func(t *testing.T) {
+		// Arrange
+		var givenFilter repository.Filter
+		repo := &repoMock{
+			ListFunc: func(ctx context.Context, filter repository.Filter, page repository.Page) ([]*repository.User, error) {
+				givenFilter = filter
+				return []*repository.User{{ID: uuid.New().String(), Nickname: "jdoe", Country: "US"}}, nil
+			},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), repo)
+
+		country := " us "
+		nicknamePrefix := " jd"
+		createdAfter := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+		createdBefore := createdAfter.AddDate(0, 1, 0)
+
+		// Act
+		actualUsers, err := svc.FetchAll(context.TODO(), FilterParams{
+			Country:        &country,
+			NicknamePrefix: &nicknamePrefix,
+			CreatedAfter:   &createdAfter,
+			CreatedBefore:  &createdBefore,
+		}, PaginationParams{Limit: 10})
+
+		// Assert
+		require.NoError(t, err)
+		require.Len(t, actualUsers, 1)
+		assert.Equal(t, repository.Filter{
+			Country:        "US",
+			NicknamePrefix: "jd",
+			CreatedAfter:   createdAfter,
+			CreatedBefore:  createdBefore,
+		}, givenFilter)
+	})
+
+	t.Run("invalid creation time range", func(t *testing.T) {
+		// Arrange
+		svc := NewServiceDefault(zap.NewNop(), &repoMock{})
+
+		createdAfter := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+		createdBefore := createdAfter
+
+		// Act
+		actualUsers, err := svc.FetchAll(context.TODO(), FilterParams{
+			CreatedAfter:  &createdAfter,
+			CreatedBefore: &createdBefore,
+		}, PaginationParams{})
+
+		// Assert
+		assert.True(t, errors.Is(err, ErrCreatedRangeInvalid))
+		assert.Nil(t, actualUsers)
+	})
+}
+
+func TestCount(t *testing.T) {
+	t.Parallel()
+
+	t.Run("success", func(t *testing.T) {
+		// Arrange
+		repo := &repoMock{
+			CountFunc: func(ctx context.Context, filter repository.Filter) (int64, error) {
+				assert.Equal(t, repository.Filter{Country: "BR", NicknamePrefix: "jo"}, filter)
+				return 42, nil
+			},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), repo)
+
+		country, nickname := "BR", " jo "
+
+		// Act
+		count, err := svc.Count(context.TODO(), FilterParams{Country: &country, NicknamePrefix: &nickname})
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, int64(42), count)
+	})
+
+	t.Run("invalid creation range", func(t *testing.T) {
+		// Arrange
+		svc := NewServiceDefault(zap.NewNop(), &repoMock{})
+		after := time.Now()
+
+		// Act
+		_, err := svc.Count(context.TODO(), FilterParams{CreatedAfter: &after, CreatedBefore: &after})
+
+		// Assert
+		assert.True(t, errors.Is(err, ErrCreatedRangeInvalid))
+	})
 }
 
 func TestCreate(t *testing.T) {
+	t.Run("normalizes the country code and the names", func(t *testing.T) {
+		// Arrange
+		var stored *repository.User
+		repo := &repoMock{
+			InsertFunc: func(ctx context.Context, user *repository.User) error {
+				stored = user
+				return nil
+			},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), repo)
+
+		// Act
+		actualUser, err := svc.Create(context.TODO(), &User{FirstName: "Zoe\u0301", Password: "password", Country: " br"})
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, "BR", stored.Country)
+		assert.Equal(t, "Zo\u00e9", stored.FirstName)
+		assert.Equal(t, "BR", actualUser.Country)
+	})
+
 	t.Run("success", func(t *testing.T) {
 		// Arrange
 
@@ -536,7 +698,7 @@ func TestCreate(t *testing.T) {
 
 		var publisherWasCalled bool
 		publisher := &publisherMock{
-			PublishFunc: func(event events.Event, data any) error {
+			PublishFunc: func(_ context.Context, event events.Event, data any) error {
 				publisherWasCalled = true
 				return nil
 			},
@@ -581,7 +743,7 @@ func TestCreate(t *testing.T) {
 
 		var publisherWasCalled bool
 		publisher := &publisherMock{
-			PublishFunc: func(event events.Event, data any) error {
+			PublishFunc: func(_ context.Context, event events.Event, data any) error {
 				publisherWasCalled = true
 				return nil
 			},
@@ -601,6 +763,32 @@ func TestCreate(t *testing.T) {
 		assert.Nil(t, actualUser)
 	})
 
+	t.Run("nickname already taken", func(t *testing.T) {
+		// Arrange
+
+		repo := &repoMock{
+			InsertFunc: func(ctx context.Context, user *repository.User) error {
+				return repository.ErrDuplicateNickname
+			},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), repo, WithUniquenessScope(repository.ScopeCountry))
+
+		// Act
+
+		actualUser, actualErr := svc.Create(context.TODO(), &User{})
+
+		// Assert
+		assert.True(t, errors.Is(actualErr, ErrNicknameTaken))
+		assert.False(t, errors.Is(actualErr, ErrUserAlreadyExists))
+		assert.Nil(t, actualUser)
+
+		var dupErr *DuplicateError
+		require.True(t, errors.As(actualErr, &dupErr))
+		assert.Equal(t, "nickname", dupErr.Field)
+		assert.Equal(t, repository.ScopeCountry, dupErr.Scope)
+	})
+
 	t.Run("repo insert error", func(t *testing.T) {
 		// Arrange
 
@@ -615,7 +803,7 @@ func TestCreate(t *testing.T) {
 
 		var publisherWasCalled bool
 		publisher := &publisherMock{
-			PublishFunc: func(event events.Event, data any) error {
+			PublishFunc: func(_ context.Context, event events.Event, data any) error {
 				publisherWasCalled = true
 				return nil
 			},
@@ -635,44 +823,58 @@ func TestCreate(t *testing.T) {
 }
 
 func TestUpdate(t *testing.T) {
-	t.Run("success", func(t *testing.T) {
-		// Arrange
+	newStoredUserHelper := func(t *testing.T) *repository.User {
+		t.Helper()
 
-		givenUser := &User{
+		hash, err := bcrypt.GenerateFromPassword([]byte("password1!"), bcrypt.MinCost)
+		require.NoError(t, err)
+
+		return &repository.User{
 			ID:        uuid.New().String(),
 			FirstName: "John",
 			LastName:  "Doe",
 			Nickname:  "jdoe",
-			Password:  "password",
+			Password:  string(hash),
 			Email:     "joedoe@foo.bar",
 			Country:   "US",
-			CreatedAt: time.Time{}.Add(time.Duration(1) * time.Second),
-			UpdatedAt: time.Time{}.Add(time.Duration(2) * time.Second),
+			Role:      RoleAdmin,
+			CreatedAt: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+			UpdatedAt: time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC),
 		}
+	}
 
-		var updateFuncWasCalled bool
-		repo := &repoMock{
-			UpdateFunc: func(ctx context.Context, user *repository.User) error {
-				updateFuncWasCalled = true
+	t.Run("success", func(t *testing.T) {
+		// Arrange
 
-				// Assert if the values passed to the repo are as expected
+		storedUser := newStoredUserHelper(t)
 
-				assert.Equal(t, givenUser.ID, user.ID)
-				assert.Equal(t, givenUser.FirstName, user.FirstName)
-				assert.Equal(t, givenUser.LastName, user.LastName)
-				assert.Equal(t, givenUser.Nickname, user.Nickname)
-				assert.Equal(t, givenUser.Password, user.Password)
-				assert.Equal(t, givenUser.Email, user.Email)
-				assert.Equal(t, givenUser.Country, user.Country)
-				assert.Equal(t, givenUser.CreatedAt, user.CreatedAt)
-				assert.NotEmpty(t, user.UpdatedAt)
+		givenUser := &User{
+			ID:        storedUser.ID,
+			FirstName: "Jane",
+			LastName:  "Doe",
+			Nickname:  "jane",
+			Password:  "password2!",
+			Email:     "janedoe@foo.bar",
+			Country:   "PT",
+			CreatedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		}
+
+		var updated *repository.User
+		repo := &repoMock{
+			GetFieldLocksFunc: noFieldLocks,
+			GetFunc: func(ctx context.Context, id string) (*repository.User, error) {
+				stored := *storedUser
+				return &stored, nil
+			},
+			UpdateFunc: func(ctx context.Context, user *repository.User) error {
+				updated = user
 				return nil
 			},
 		}
 
 		var publisherWasCalled bool
 		publisher := &publisherMock{
-			PublishFunc: func(event events.Event, data any) error {
+			PublishFunc: func(_ context.Context, event events.Event, data any) error {
 				publisherWasCalled = true
 				return nil
 			},
@@ -687,21 +889,87 @@ func TestUpdate(t *testing.T) {
 
 		// Assert
 
-		require.True(t, updateFuncWasCalled)
+		require.NotNil(t, updated)
 		require.True(t, publisherWasCalled)
 
-		assert.NotNil(t, actualUser.ID)
-		_, err = uuid.Parse(actualUser.ID)
-		assert.NoError(t, err)
+		assert.Equal(t, storedUser.ID, updated.ID)
+		assert.Equal(t, "Jane", updated.FirstName)
+		assert.Equal(t, "jane", updated.Nickname)
+		assert.Equal(t, "janedoe@foo.bar", updated.Email)
+		assert.Equal(t, "PT", updated.Country)
+		assert.Equal(t, storedUser.Password, updated.Password)
 
-		assert.Equal(t, givenUser.FirstName, actualUser.FirstName)
-		assert.Equal(t, givenUser.LastName, actualUser.LastName)
-		assert.Equal(t, givenUser.Nickname, actualUser.Nickname)
-		assert.Equal(t, givenUser.Password, actualUser.Password)
-		assert.Equal(t, givenUser.Email, actualUser.Email)
-		assert.Equal(t, givenUser.Country, actualUser.Country)
-		assert.NotEmpty(t, actualUser.CreatedAt)
-		assert.NotEmpty(t, actualUser.UpdatedAt)
+		// The creation time and the role are kept from the stored user.
+		assert.Equal(t, storedUser.CreatedAt, updated.CreatedAt)
+		assert.Equal(t, RoleAdmin, updated.Role)
+		assert.True(t, updated.UpdatedAt.After(storedUser.UpdatedAt))
+
+		assert.Equal(t, storedUser.CreatedAt, actualUser.CreatedAt)
+		assert.Equal(t, RoleAdmin, actualUser.Role)
+		assert.Equal(t, "Jane", actualUser.FirstName)
+	})
+
+	t.Run("never changes the hash", func(t *testing.T) {
+		testCases := []struct {
+			name     string
+			password string
+		}{
+			{name: "empty password", password: ""},
+			{name: "current password", password: "password1!"},
+			{name: "new password", password: "password2!"},
+		}
+
+		for _, tc := range testCases {
+			tc := tc
+			t.Run(tc.name, func(t *testing.T) {
+				// Arrange
+
+				storedUser := newStoredUserHelper(t)
+
+				var updated *repository.User
+				repo := &repoMock{
+					GetFieldLocksFunc: noFieldLocks,
+					GetFunc: func(ctx context.Context, id string) (*repository.User, error) {
+						stored := *storedUser
+						return &stored, nil
+					},
+					UpdateFunc: func(ctx context.Context, user *repository.User) error {
+						updated = user
+						return nil
+					},
+				}
+
+				hasher := &hasherMock{
+					CompareFunc: func(ctx context.Context, hash, password []byte) error {
+						return bcrypt.CompareHashAndPassword(hash, password)
+					},
+					HashFunc: func(ctx context.Context, password []byte) ([]byte, error) {
+						t.Fatal("the password is changed with ChangePassword only")
+						return nil, nil
+					},
+				}
+
+				svc := NewServiceDefault(zap.NewNop(), repo, WithHasher(hasher))
+
+				// Act
+
+				_, err := svc.Update(context.TODO(), &User{
+					ID:        storedUser.ID,
+					FirstName: "John",
+					LastName:  "Doe",
+					Nickname:  "jdoe",
+					Password:  tc.password,
+					Email:     "joedoe@foo.bar",
+					Country:   "PT",
+				})
+
+				// Assert
+
+				require.NoError(t, err)
+				require.NotNil(t, updated)
+				assert.Equal(t, storedUser.Password, updated.Password)
+			})
+		}
 	})
 
 	t.Run("user not found", func(t *testing.T) {
@@ -709,15 +977,18 @@ func TestUpdate(t *testing.T) {
 
 		var updateFuncWasCalled bool
 		repo := &repoMock{
+			GetFunc: func(ctx context.Context, id string) (*repository.User, error) {
+				return nil, repository.ErrUserNotFound
+			},
 			UpdateFunc: func(ctx context.Context, user *repository.User) error {
 				updateFuncWasCalled = true
-				return repository.ErrUserNotFound
+				return nil
 			},
 		}
 
 		var publisherWasCalled bool
 		publisher := &publisherMock{
-			PublishFunc: func(event events.Event, data any) error {
+			PublishFunc: func(_ context.Context, event events.Event, data any) error {
 				publisherWasCalled = true
 				return nil
 			},
@@ -731,7 +1002,7 @@ func TestUpdate(t *testing.T) {
 
 		// Assert
 
-		assert.True(t, updateFuncWasCalled)
+		assert.False(t, updateFuncWasCalled)
 		assert.False(t, publisherWasCalled)
 		assert.Error(t, actualErr)
 		assert.True(t, errors.Is(actualErr, ErrUserNotFound))
@@ -741,8 +1012,15 @@ func TestUpdate(t *testing.T) {
 	t.Run("repo update error", func(t *testing.T) {
 		// Arrange
 
+		storedUser := newStoredUserHelper(t)
+
 		var updateFuncWasCalled bool
 		repo := &repoMock{
+			GetFieldLocksFunc: noFieldLocks,
+			GetFunc: func(ctx context.Context, id string) (*repository.User, error) {
+				stored := *storedUser
+				return &stored, nil
+			},
 			UpdateFunc: func(ctx context.Context, user *repository.User) error {
 				updateFuncWasCalled = true
 				return errors.New("repo error")
@@ -751,7 +1029,7 @@ func TestUpdate(t *testing.T) {
 
 		var publisherWasCalled bool
 		publisher := &publisherMock{
-			PublishFunc: func(event events.Event, data any) error {
+			PublishFunc: func(_ context.Context, event events.Event, data any) error {
 				publisherWasCalled = true
 				return nil
 			},
@@ -761,7 +1039,7 @@ func TestUpdate(t *testing.T) {
 
 		// Act
 
-		actualUser, actualErr := svc.Update(context.TODO(), &User{})
+		actualUser, actualErr := svc.Update(context.TODO(), &User{ID: storedUser.ID})
 
 		// Assert
 
@@ -772,21 +1050,113 @@ func TestUpdate(t *testing.T) {
 	})
 }
 
+func TestUpdateProfile(t *testing.T) {
+	t.Parallel()
+
+	storedUser := repository.User{
+		ID:            uuid.New().String(),
+		FirstName:     "John",
+		LastName:      "Doe",
+		Nickname:      "jdoe",
+		Password:      "some-hash",
+		Email:         "joedoe@foo.bar",
+		Country:       "US",
+		CreatedAt:     time.Time{}.Add(1 * time.Second),
+		UpdatedAt:     time.Time{}.Add(2 * time.Second),
+		EventSequence: 3,
+	}
+
+	t.Run("success", func(t *testing.T) {
+		// Arrange
+		var updatedUser *repository.User
+		repo := &repoMock{
+			GetFunc: func(ctx context.Context, id string) (*repository.User, error) {
+				stored := storedUser
+				return &stored, nil
+			},
+			GetFieldLocksFunc: noFieldLocks,
+			UpdateFunc: func(ctx context.Context, user *repository.User) error {
+				updatedUser = user
+				user.EventSequence++
+				return nil
+			},
+		}
+
+		var publishedEvent events.Event
+		publisher := &publisherMock{
+			PublishFunc: func(_ context.Context, event events.Event, data any) error {
+				publishedEvent = event
+				assert.Equal(t, int64(4), data.(events.Ordered).Sequence)
+				return nil
+			},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), repo, WithPublisher(publisher))
+
+		// Act
+		actualUser, err := svc.UpdateProfile(context.TODO(), &User{
+			ID:        storedUser.ID,
+			FirstName: "Johnny",
+			LastName:  "Doe",
+			Nickname:  "johnny",
+			Email:     "other@foo.bar",
+			Password:  "ignored",
+			Country:   "BR",
+		})
+		require.NoError(t, err)
+
+		// Assert
+		require.NotNil(t, updatedUser)
+		assert.Equal(t, "Johnny", updatedUser.FirstName)
+		assert.Equal(t, "johnny", updatedUser.Nickname)
+		assert.Equal(t, "BR", updatedUser.Country)
+		assert.Equal(t, storedUser.Email, updatedUser.Email)
+		assert.Equal(t, storedUser.Password, updatedUser.Password)
+		assert.Equal(t, storedUser.CreatedAt, updatedUser.CreatedAt)
+
+		assert.Equal(t, events.UserUpdated, publishedEvent)
+		assert.Empty(t, actualUser.Password)
+	})
+
+	t.Run("nickname taken", func(t *testing.T) {
+		// Arrange
+		repo := &repoMock{
+			GetFunc: func(ctx context.Context, id string) (*repository.User, error) {
+				stored := storedUser
+				return &stored, nil
+			},
+			GetFieldLocksFunc: noFieldLocks,
+			UpdateFunc: func(ctx context.Context, user *repository.User) error {
+				return repository.ErrDuplicateNickname
+			},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), repo)
+
+		// Act
+		actualUser, err := svc.UpdateProfile(context.TODO(), &User{ID: storedUser.ID, Nickname: "taken"})
+
+		// Assert
+		assert.Nil(t, actualUser)
+		assert.True(t, errors.Is(err, ErrNicknameTaken))
+	})
+}
+
 func TestDelete(t *testing.T) {
 	t.Run("success", func(t *testing.T) {
 		// Arrange
 
 		var deleteFuncWasCalled bool
 		repo := &repoMock{
-			DeleteFunc: func(ctx context.Context, id string) error {
+			DeleteFunc: func(ctx context.Context, id string) (int64, error) {
 				deleteFuncWasCalled = true
-				return nil
+				return 2, nil
 			},
 		}
 
 		var publisherWasCalled bool
 		publisher := &publisherMock{
-			PublishFunc: func(event events.Event, data any) error {
+			PublishFunc: func(_ context.Context, event events.Event, data any) error {
 				publisherWasCalled = true
 				return nil
 			},
@@ -810,15 +1180,15 @@ func TestDelete(t *testing.T) {
 
 		var deleteFuncWasCalled bool
 		repo := &repoMock{
-			DeleteFunc: func(ctx context.Context, id string) error {
+			DeleteFunc: func(ctx context.Context, id string) (int64, error) {
 				deleteFuncWasCalled = true
-				return repository.ErrUserNotFound
+				return 0, repository.ErrUserNotFound
 			},
 		}
 
 		var publisherWasCalled bool
 		publisher := &publisherMock{
-			PublishFunc: func(event events.Event, data any) error {
+			PublishFunc: func(_ context.Context, event events.Event, data any) error {
 				publisherWasCalled = true
 				return nil
 			},
@@ -840,15 +1210,15 @@ func TestDelete(t *testing.T) {
 
 		var deleteFuncWasCalled bool
 		repo := &repoMock{
-			DeleteFunc: func(ctx context.Context, id string) error {
+			DeleteFunc: func(ctx context.Context, id string) (int64, error) {
 				deleteFuncWasCalled = true
-				return errors.New("repo error")
+				return 0, errors.New("repo error")
 			},
 		}
 
 		var publisherWasCalled bool
 		publisher := &publisherMock{
-			PublishFunc: func(event events.Event, data any) error {
+			PublishFunc: func(_ context.Context, event events.Event, data any) error {
 				publisherWasCalled = true
 				return nil
 			},
@@ -870,7 +1240,7 @@ func TestDelete(t *testing.T) {
 
 		var publisherWasCalled bool
 		publisher := &publisherMock{
-			PublishFunc: func(event events.Event, data any) error {
+			PublishFunc: func(_ context.Context, event events.Event, data any) error {
 				publisherWasCalled = true
 				return nil
 			},
@@ -887,3 +1257,311 @@ func TestDelete(t *testing.T) {
 		assert.True(t, errors.Is(actualErr, ErrInvalidID))
 	})
 }
+
+func TestAuthenticate(t *testing.T) {
+	t.Parallel()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("s0meP@ssw0rd"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	storedUser := &repository.User{
+		ID:        uuid.New().String(),
+		FirstName: "John",
+		LastName:  "Doe",
+		Nickname:  "jdoe",
+		Password:  string(hash),
+		Email:     "joedoe@foo.bar",
+		Country:   "US",
+		CreatedAt: time.Time{}.Add(1 * time.Second),
+		UpdatedAt: time.Time{}.Add(2 * time.Second),
+	}
+
+	t.Run("success", func(t *testing.T) {
+		// Arrange
+		var getByEmailFuncWasCalled bool
+		repo := &repoMock{
+			GetByEmailFunc: func(ctx context.Context, email string) (*repository.User, error) {
+				getByEmailFuncWasCalled = true
+				require.Equal(t, storedUser.Email, email)
+				return storedUser, nil
+			},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), repo)
+
+		// Act
+		actualUser, err := svc.Authenticate(context.TODO(), storedUser.Email, "s0meP@ssw0rd", "")
+		require.NoError(t, err)
+
+		// Assert
+		assert.True(t, getByEmailFuncWasCalled)
+		assert.Equal(t, storedUser.ID, actualUser.ID)
+		assert.Equal(t, storedUser.Email, actualUser.Email)
+		assert.Empty(t, actualUser.Password)
+	})
+
+	t.Run("wrong password", func(t *testing.T) {
+		// Arrange
+		repo := &repoMock{
+			GetByEmailFunc: func(ctx context.Context, email string) (*repository.User, error) {
+				return storedUser, nil
+			},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), repo)
+
+		// Act
+		actualUser, actualErr := svc.Authenticate(context.TODO(), storedUser.Email, "wr0ngP@ssword", "")
+
+		// Assert
+		assert.Nil(t, actualUser)
+		assert.True(t, errors.Is(actualErr, ErrInvalidCredentials))
+	})
+
+	t.Run("user not found", func(t *testing.T) {
+		// Arrange
+		repo := &repoMock{
+			GetByEmailFunc: func(ctx context.Context, email string) (*repository.User, error) {
+				return nil, repository.ErrUserNotFound
+			},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), repo)
+
+		// Act
+		actualUser, actualErr := svc.Authenticate(context.TODO(), "unknown@foo.bar", "s0meP@ssw0rd", "")
+
+		// Assert
+		assert.Nil(t, actualUser)
+		assert.True(t, errors.Is(actualErr, ErrInvalidCredentials))
+	})
+
+	t.Run("email shared by several users", func(t *testing.T) {
+		// Arrange
+		repo := &repoMock{
+			GetByEmailFunc: func(ctx context.Context, email string) (*repository.User, error) {
+				return nil, repository.ErrAmbiguousEmail
+			},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), repo)
+
+		// Act
+		actualUser, actualErr := svc.Authenticate(context.TODO(), storedUser.Email, "s0meP@ssw0rd", "")
+
+		// Assert
+		assert.Nil(t, actualUser)
+		assert.True(t, errors.Is(actualErr, ErrInvalidCredentials))
+	})
+
+	t.Run("repo error", func(t *testing.T) {
+		// Arrange
+		repo := &repoMock{
+			GetByEmailFunc: func(ctx context.Context, email string) (*repository.User, error) {
+				return nil, errors.New("repo error")
+			},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), repo)
+
+		// Act
+		actualUser, actualErr := svc.Authenticate(context.TODO(), storedUser.Email, "s0meP@ssw0rd", "")
+
+		// Assert
+		assert.Nil(t, actualUser)
+		assert.Error(t, actualErr)
+		assert.False(t, errors.Is(actualErr, ErrInvalidCredentials))
+	})
+}
+
+func TestStats(t *testing.T) {
+	t.Parallel()
+
+	t.Run("counts in the database without stats cache", func(t *testing.T) {
+		// Arrange
+
+		repo := &repoMock{
+			CountByCountryFunc: func(ctx context.Context) (map[string]int64, error) {
+				return map[string]int64{"BR": 2, "US": 1}, nil
+			},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), repo)
+
+		// Act
+		stats, err := svc.Stats(context.TODO())
+		require.NoError(t, err)
+
+		// Assert
+		assert.Equal(t, map[string]int64{"BR": 2, "US": 1}, stats.CountByCountry)
+		assert.Equal(t, int64(3), stats.Total())
+	})
+
+	t.Run("serves from the stats cache once loaded", func(t *testing.T) {
+		// Arrange
+
+		var calls int
+		repo := &repoMock{
+			CountByCountryFunc: func(ctx context.Context) (map[string]int64, error) {
+				calls++
+				return map[string]int64{"BR": 2}, nil
+			},
+		}
+
+		stats := NewCountryStats(zap.NewNop(), repo)
+		stats.reconcile(context.TODO())
+
+		svc := NewServiceDefault(zap.NewNop(), repo, WithCountryStats(stats))
+
+		// Act
+		observed, err := svc.Stats(context.TODO())
+		require.NoError(t, err)
+
+		// Assert
+		assert.Equal(t, map[string]int64{"BR": 2}, observed.CountByCountry)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("repo error", func(t *testing.T) {
+		// Arrange
+
+		repo := &repoMock{
+			CountByCountryFunc: func(ctx context.Context) (map[string]int64, error) {
+				return nil, errors.New("some error")
+			},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), repo)
+
+		// Act
+		stats, err := svc.Stats(context.TODO())
+
+		// Assert
+		assert.Error(t, err)
+		assert.Nil(t, stats)
+	})
+}
+
+func TestServiceWithMemoryRepository(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	svc := NewServiceDefault(zap.NewNop(), repository.NewMemory())
+
+	// Act
+	created, err := svc.Create(context.TODO(), &User{
+		FirstName: "John",
+		LastName:  "Doe",
+		Nickname:  "jdoe",
+		Password:  "some-passw0rd",
+		Email:     "joedoe@foo.bar",
+		Country:   "US",
+	})
+	require.NoError(t, err)
+
+	_, duplicateErr := svc.Create(context.TODO(), &User{
+		FirstName: "Jane",
+		LastName:  "Doe",
+		Nickname:  "jadoe",
+		Password:  "some-passw0rd",
+		Email:     "joedoe@foo.bar",
+		Country:   "BR",
+	})
+
+	authenticated, err := svc.Authenticate(context.TODO(), "joedoe@foo.bar", "some-passw0rd", "")
+	require.NoError(t, err)
+
+	require.NoError(t, svc.Delete(context.TODO(), created.ID))
+	_, fetchErr := svc.Fetch(context.TODO(), created.ID)
+
+	// Assert
+	assert.True(t, errors.Is(duplicateErr, ErrUserAlreadyExists))
+	assert.Equal(t, created.ID, authenticated.ID)
+	assert.True(t, errors.Is(fetchErr, ErrUserNotFound))
+}
+
+func TestEventSequence(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	var published []events.Ordered
+	publisher := &publisherMock{
+		PublishFunc: func(_ context.Context, event events.Event, data any) error {
+			ordered, ok := data.(events.Ordered)
+			require.True(t, ok, "event %s is not ordered", event)
+
+			published = append(published, ordered)
+			return nil
+		},
+	}
+
+	svc := NewServiceDefault(zap.NewNop(), repository.NewMemory(), WithPublisher(publisher))
+
+	// Act
+	created, err := svc.Create(context.TODO(), &User{
+		FirstName: "John",
+		LastName:  "Doe",
+		Nickname:  "jdoe",
+		Password:  "some-passw0rd",
+		Email:     "joedoe@foo.bar",
+		Country:   "US",
+	})
+	require.NoError(t, err)
+
+	created.Country = "BR"
+	_, err = svc.Update(context.TODO(), created)
+	require.NoError(t, err)
+
+	require.NoError(t, svc.Delete(context.TODO(), created.ID))
+
+	// Assert
+	require.Len(t, published, 3)
+	for i, ordered := range published {
+		assert.Equal(t, created.ID, ordered.Key)
+		assert.Equal(t, int64(i+1), ordered.Sequence)
+		assert.Equal(t, created.ID, ordered.Data)
+	}
+}
+
+func TestHashingPoolSaturated(t *testing.T) {
+	t.Parallel()
+
+	saturated := &hasherMock{
+		HashFunc: func(ctx context.Context, password []byte) ([]byte, error) {
+			return nil, hashing.ErrSaturated
+		},
+		CompareFunc: func(ctx context.Context, hash, password []byte) error {
+			return hashing.ErrSaturated
+		},
+	}
+
+	t.Run("create", func(t *testing.T) {
+		// Arrange
+		svc := NewServiceDefault(zap.NewNop(), &repoMock{}, WithHasher(saturated))
+
+		// Act
+		user, err := svc.Create(context.TODO(), &User{Password: "some-passw0rd"})
+
+		// Assert
+		assert.True(t, errors.Is(err, ErrServiceBusy))
+		assert.Nil(t, user)
+	})
+
+	t.Run("authenticate", func(t *testing.T) {
+		// Arrange
+		repo := &repoMock{
+			GetByEmailFunc: func(ctx context.Context, email string) (*repository.User, error) {
+				return &repository.User{ID: uuid.New().String(), Password: "hash"}, nil
+			},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), repo, WithHasher(saturated))
+
+		// Act
+		user, err := svc.Authenticate(context.TODO(), "joedoe@foo.bar", "some-passw0rd", "")
+
+		// Assert
+		assert.True(t, errors.Is(err, ErrServiceBusy))
+		assert.Nil(t, user)
+	})
+}
@@ -0,0 +1,344 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alesr/usrsvc/internal/users/repository"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultAccessTokenTTL  time.Duration = 15 * time.Minute
+	defaultRefreshTokenTTL time.Duration = 30 * 24 * time.Hour
+
+	// The token prefixes tell the access tokens from the refresh tokens and the API keys,
+	// which are all handed out as "<prefix><session or key id>.<secret>".
+	accessTokenPrefix  string = "usrsvc_at_"
+	refreshTokenPrefix string = "usrsvc_rt_"
+
+	sessionSecretLength int = 32
+)
+
+// WithSessionTTLs sets how long the access tokens and the sessions are valid for.
+// Refreshing a session extends it by the refresh TTL, so only idle sessions expire.
+func WithSessionTTLs(access, refresh time.Duration) Option {
+	return func(s *ServiceDefault) {
+		s.accessTokenTTL = access
+		s.refreshTokenTTL = refresh
+	}
+}
+
+// Session is a login session of a user. The access token of the session authenticates
+// the requests of the user until it expires, and the refresh token trades the session
+// tokens for new ones until the session expires.
+type Session struct {
+	ID                   string
+	UserID               string
+	IP                   string
+	CreatedAt            time.Time
+	RefreshedAt          time.Time
+	AccessTokenExpiresAt time.Time
+	ExpiresAt            time.Time
+}
+
+// SessionTokens are the tokens of a session, handed out on login and on every refresh.
+// Only their hashes are stored, so they can't be handed out again.
+type SessionTokens struct {
+	Session      *Session
+	AccessToken  string
+	RefreshToken string
+}
+
+// IsAccessToken reports whether the bearer token is a session access token rather than an API key.
+func IsAccessToken(token string) bool {
+	return strings.HasPrefix(token, accessTokenPrefix)
+}
+
+// CreateSession starts a session for the user, after a successful authentication.
+func (s *ServiceDefault) CreateSession(ctx context.Context, userID string) (*SessionTokens, error) {
+	ctx, span := tracer.Start(ctx, "ServiceDefault.CreateSession")
+	defer span.End()
+	span.SetAttributes(attribute.String("user.id", userID))
+
+	now := time.Now().UTC()
+	session := repository.Session{
+		ID:          uuid.New().String(),
+		UserID:      userID,
+		CreatedAt:   now,
+		RefreshedAt: now,
+	}
+
+	if ip := clientIPFromContext(ctx); ip != nil {
+		session.IP = ip.String()
+	}
+
+	tokens, err := s.issueSessionTokens(&session, now)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	if err := s.repo.InsertSession(ctx, &session); err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			err = ErrUserNotFound
+		}
+		return nil, fmt.Errorf("could not create session for user '%s': %w", userID, err)
+	}
+	return tokens, nil
+}
+
+// RefreshSession trades the refresh token of a session for new session tokens and extends
+// the session. Refresh tokens can only be used once: presenting a rotated refresh token
+// means it leaked, so the session is revoked. Malformed, unknown, reused and expired refresh
+// tokens all fail with ErrInvalidCredentials.
+func (s *ServiceDefault) RefreshSession(ctx context.Context, refreshToken string) (*SessionTokens, error) {
+	ctx, span := tracer.Start(ctx, "ServiceDefault.RefreshSession")
+	defer span.End()
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	stored, hash, err := s.sessionOf(repository.ContextWithPrimary(ctx), refreshTokenPrefix, refreshToken)
+	if err != nil {
+		return nil, fmt.Errorf("could not refresh session: %w", err)
+	}
+	span.SetAttributes(attribute.String("user.id", stored.UserID))
+
+	if subtle.ConstantTimeCompare(hash, stored.RefreshTokenHash) != 1 {
+		s.revokeReusedSession(ctx, stored)
+		return nil, fmt.Errorf("could not refresh session '%s': %w", stored.ID, ErrInvalidCredentials)
+	}
+
+	now := time.Now().UTC()
+	if !stored.ExpiresAt.After(now) {
+		return nil, fmt.Errorf("could not refresh session '%s': %w", stored.ID, ErrInvalidCredentials)
+	}
+
+	rotated := *stored
+	rotated.RefreshedAt = now
+
+	tokens, err := s.issueSessionTokens(&rotated, now)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.RotateSession(ctx, &rotated, hash); err != nil {
+		// Another refresh rotated the token first, so it was used twice.
+		if errors.Is(err, repository.ErrSessionNotFound) {
+			s.revokeReusedSession(ctx, stored)
+			return nil, fmt.Errorf("could not refresh session '%s': %w", stored.ID, ErrInvalidCredentials)
+		}
+		return nil, fmt.Errorf("could not refresh session '%s': %w", stored.ID, err)
+	}
+	return tokens, nil
+}
+
+// AuthenticateAccessToken returns the user of the session the access token was issued for.
+// Malformed, unknown, rotated and expired tokens all fail with ErrInvalidCredentials.
+func (s *ServiceDefault) AuthenticateAccessToken(ctx context.Context, accessToken string) (*User, error) {
+	ctx, span := tracer.Start(ctx, "ServiceDefault.AuthenticateAccessToken")
+	defer span.End()
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	session, hash, err := s.sessionOf(ctx, accessTokenPrefix, accessToken)
+	if err != nil {
+		return nil, fmt.Errorf("could not authenticate access token: %w", err)
+	}
+
+	if subtle.ConstantTimeCompare(hash, session.AccessTokenHash) != 1 || !session.AccessTokenExpiresAt.After(time.Now()) {
+		return nil, fmt.Errorf("could not authenticate access token of session '%s': %w", session.ID, ErrInvalidCredentials)
+	}
+
+	user, err := s.repo.Get(ctx, session.UserID)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return nil, fmt.Errorf("could not authenticate access token of session '%s': %w", session.ID, ErrInvalidCredentials)
+		}
+		return nil, fmt.Errorf("could not authenticate access token of session '%s': %w", session.ID, err)
+	}
+
+	if err := checkStatus(user); err != nil {
+		return nil, fmt.Errorf("could not authenticate access token of session '%s': %w", session.ID, err)
+	}
+
+	authenticated := newUserDomainFromStore(user)
+
+	// The hash never leaves the service.
+	authenticated.Password = ""
+	return authenticated, nil
+}
+
+// RevokeSession ends the session with the given id, e.g. for an admin to log a user out
+// of a lost device. Its tokens stop working right away.
+func (s *ServiceDefault) RevokeSession(ctx context.Context, id string) error {
+	ctx, span := tracer.Start(ctx, "ServiceDefault.RevokeSession")
+	defer span.End()
+	span.SetAttributes(attribute.String("session.id", id))
+
+	if _, err := uuid.Parse(id); err != nil {
+		return fmt.Errorf("could not validate id '%s': %w", id, ErrInvalidID)
+	}
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	if err := s.repo.DeleteSession(ctx, id); err != nil {
+		if errors.Is(err, repository.ErrSessionNotFound) {
+			return fmt.Errorf("could not revoke session '%s': %w", id, ErrSessionNotFound)
+		}
+		return fmt.Errorf("could not revoke session '%s': %w", id, err)
+	}
+	return nil
+}
+
+// RevokeRefreshToken ends the session of the refresh token, for users to log out.
+// Malformed, unknown and rotated refresh tokens fail with ErrInvalidCredentials.
+func (s *ServiceDefault) RevokeRefreshToken(ctx context.Context, refreshToken string) error {
+	ctx, span := tracer.Start(ctx, "ServiceDefault.RevokeRefreshToken")
+	defer span.End()
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	session, hash, err := s.sessionOf(repository.ContextWithPrimary(ctx), refreshTokenPrefix, refreshToken)
+	if err != nil {
+		return fmt.Errorf("could not revoke session: %w", err)
+	}
+
+	if subtle.ConstantTimeCompare(hash, session.RefreshTokenHash) != 1 {
+		return fmt.Errorf("could not revoke session '%s': %w", session.ID, ErrInvalidCredentials)
+	}
+
+	if err := s.repo.DeleteSession(ctx, session.ID); err != nil && !errors.Is(err, repository.ErrSessionNotFound) {
+		return fmt.Errorf("could not revoke session '%s': %w", session.ID, err)
+	}
+	return nil
+}
+
+// Sessions returns the active sessions of the user, most recent first.
+func (s *ServiceDefault) Sessions(ctx context.Context, userID string) ([]*Session, error) {
+	ctx, span := tracer.Start(ctx, "ServiceDefault.Sessions")
+	defer span.End()
+	span.SetAttributes(attribute.String("user.id", userID))
+
+	if _, err := uuid.Parse(userID); err != nil {
+		return nil, fmt.Errorf("could not validate id '%s': %w", userID, ErrInvalidID)
+	}
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	stored, err := s.repo.GetSessions(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get sessions of user '%s': %w", userID, err)
+	}
+
+	// The expired sessions are only deleted on the next login of the user.
+	now := time.Now()
+	sessions := make([]*Session, 0, len(stored))
+	for _, session := range stored {
+		if session.ExpiresAt.After(now) {
+			sessions = append(sessions, newSessionDomainFromStore(session))
+		}
+	}
+	return sessions, nil
+}
+
+// issueSessionTokens sets new token hashes and expiries on the session and returns the tokens to hand out.
+func (s *ServiceDefault) issueSessionTokens(session *repository.Session, now time.Time) (*SessionTokens, error) {
+	accessSecret, accessHash, err := newSessionSecret()
+	if err != nil {
+		return nil, fmt.Errorf("could not generate access token: %w", err)
+	}
+
+	refreshSecret, refreshHash, err := newSessionSecret()
+	if err != nil {
+		return nil, fmt.Errorf("could not generate refresh token: %w", err)
+	}
+
+	session.AccessTokenHash = accessHash
+	session.RefreshTokenHash = refreshHash
+	session.AccessTokenExpiresAt = now.Add(s.accessTokenTTL)
+	session.ExpiresAt = now.Add(s.refreshTokenTTL)
+
+	return &SessionTokens{
+		Session:      newSessionDomainFromStore(session),
+		AccessToken:  accessTokenPrefix + session.ID + "." + accessSecret,
+		RefreshToken: refreshTokenPrefix + session.ID + "." + refreshSecret,
+	}, nil
+}
+
+// sessionOf returns the session the token was issued for and the hash of the token secret.
+// Malformed tokens and unknown sessions fail with ErrInvalidCredentials.
+func (s *ServiceDefault) sessionOf(ctx context.Context, prefix, token string) (*repository.Session, []byte, error) {
+	id, secret, ok := strings.Cut(strings.TrimPrefix(token, prefix), ".")
+	if !ok || !strings.HasPrefix(token, prefix) {
+		return nil, nil, fmt.Errorf("could not parse token: %w", ErrInvalidCredentials)
+	}
+
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, nil, fmt.Errorf("could not parse token: %w", ErrInvalidCredentials)
+	}
+
+	session, err := s.repo.GetSession(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrSessionNotFound) {
+			return nil, nil, fmt.Errorf("could not get session '%s': %w", id, ErrInvalidCredentials)
+		}
+		return nil, nil, fmt.Errorf("could not get session '%s': %w", id, err)
+	}
+
+	hash := sha256.Sum256([]byte(secret))
+	return session, hash[:], nil
+}
+
+// revokeReusedSession revokes the session after its refresh token was used twice.
+// Errors are only logged: the refresh fails anyway.
+func (s *ServiceDefault) revokeReusedSession(ctx context.Context, session *repository.Session) {
+	s.logger.Warn("refresh token reused, revoking session",
+		zap.String("session_id", session.ID),
+		zap.String("user_id", session.UserID),
+	)
+
+	if err := s.repo.DeleteSession(ctx, session.ID); err != nil && !errors.Is(err, repository.ErrSessionNotFound) {
+		s.logger.Warn("could not revoke session", zap.String("session_id", session.ID), zap.Error(err))
+	}
+}
+
+// newSessionSecret returns a random token secret and its hash.
+func newSessionSecret() (string, []byte, error) {
+	secret := make([]byte, sessionSecretLength)
+	if _, err := rand.Read(secret); err != nil {
+		return "", nil, err
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(secret)
+	hash := sha256.Sum256([]byte(encoded))
+	return encoded, hash[:], nil
+}
+
+func newSessionDomainFromStore(session *repository.Session) *Session {
+	return &Session{
+		ID:                   session.ID,
+		UserID:               session.UserID,
+		IP:                   session.IP,
+		CreatedAt:            session.CreatedAt,
+		RefreshedAt:          session.RefreshedAt,
+		AccessTokenExpiresAt: session.AccessTokenExpiresAt,
+		ExpiresAt:            session.ExpiresAt,
+	}
+}
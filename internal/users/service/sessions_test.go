@@ -0,0 +1,185 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alesr/usrsvc/internal/users/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestSessions(t *testing.T) {
+	t.Parallel()
+
+	newServiceHelper := func(t *testing.T, opts ...Option) (*ServiceDefault, *repository.Memory, *repository.User) {
+		t.Helper()
+
+		repo := repository.NewMemory()
+		user := &repository.User{
+			ID:        "8b2e0a53-3f0f-4d8c-9a3b-1f7c2d4e5a6b",
+			FirstName: "John",
+			LastName:  "Doe",
+			Nickname:  "jdoe",
+			Password:  "hash",
+			Email:     "joedoe@foo.bar",
+			Country:   "US",
+		}
+		require.NoError(t, repo.Insert(context.TODO(), user))
+		return NewServiceDefault(zap.NewNop(), repo, opts...), repo, user
+	}
+
+	t.Run("access tokens authenticate the user of the session", func(t *testing.T) {
+		// Arrange
+		svc, _, user := newServiceHelper(t)
+		ctx := ContextWithClientIP(context.TODO(), net.ParseIP("10.0.0.1"))
+
+		// Act
+		tokens, err := svc.CreateSession(ctx, user.ID)
+		require.NoError(t, err)
+
+		authenticated, err := svc.AuthenticateAccessToken(context.TODO(), tokens.AccessToken)
+		require.NoError(t, err)
+
+		// Assert
+		assert.True(t, IsAccessToken(tokens.AccessToken))
+		assert.False(t, IsAccessToken(tokens.RefreshToken))
+		assert.True(t, strings.HasPrefix(tokens.RefreshToken, refreshTokenPrefix+tokens.Session.ID+"."))
+
+		assert.Equal(t, user.ID, authenticated.ID)
+		assert.Empty(t, authenticated.Password)
+
+		assert.Equal(t, "10.0.0.1", tokens.Session.IP)
+		assert.WithinDuration(t, time.Now().Add(defaultAccessTokenTTL), tokens.Session.AccessTokenExpiresAt, 5*time.Second)
+		assert.WithinDuration(t, time.Now().Add(defaultRefreshTokenTTL), tokens.Session.ExpiresAt, 5*time.Second)
+	})
+
+	t.Run("refreshing rotates the tokens", func(t *testing.T) {
+		// Arrange
+		svc, _, user := newServiceHelper(t)
+
+		tokens, err := svc.CreateSession(context.TODO(), user.ID)
+		require.NoError(t, err)
+
+		// Act
+		refreshed, err := svc.RefreshSession(context.TODO(), tokens.RefreshToken)
+		require.NoError(t, err)
+
+		_, oldAccessErr := svc.AuthenticateAccessToken(context.TODO(), tokens.AccessToken)
+		_, newAccessErr := svc.AuthenticateAccessToken(context.TODO(), refreshed.AccessToken)
+
+		// Assert
+		assert.Equal(t, tokens.Session.ID, refreshed.Session.ID)
+		assert.NotEqual(t, tokens.RefreshToken, refreshed.RefreshToken)
+		assert.True(t, errors.Is(oldAccessErr, ErrInvalidCredentials))
+		assert.NoError(t, newAccessErr)
+	})
+
+	t.Run("reusing a refresh token revokes the session", func(t *testing.T) {
+		// Arrange
+		svc, _, user := newServiceHelper(t)
+
+		tokens, err := svc.CreateSession(context.TODO(), user.ID)
+		require.NoError(t, err)
+
+		refreshed, err := svc.RefreshSession(context.TODO(), tokens.RefreshToken)
+		require.NoError(t, err)
+
+		// Act
+		_, reusedErr := svc.RefreshSession(context.TODO(), tokens.RefreshToken)
+		_, revokedErr := svc.RefreshSession(context.TODO(), refreshed.RefreshToken)
+		_, accessErr := svc.AuthenticateAccessToken(context.TODO(), refreshed.AccessToken)
+
+		// Assert
+		assert.True(t, errors.Is(reusedErr, ErrInvalidCredentials))
+		assert.True(t, errors.Is(revokedErr, ErrInvalidCredentials))
+		assert.True(t, errors.Is(accessErr, ErrInvalidCredentials))
+	})
+
+	t.Run("expired tokens are rejected", func(t *testing.T) {
+		// Arrange
+		svc, _, user := newServiceHelper(t, WithSessionTTLs(-time.Minute, -time.Minute))
+
+		tokens, err := svc.CreateSession(context.TODO(), user.ID)
+		require.NoError(t, err)
+
+		// Act
+		_, accessErr := svc.AuthenticateAccessToken(context.TODO(), tokens.AccessToken)
+		_, refreshErr := svc.RefreshSession(context.TODO(), tokens.RefreshToken)
+		sessions, err := svc.Sessions(context.TODO(), user.ID)
+		require.NoError(t, err)
+
+		// Assert
+		assert.True(t, errors.Is(accessErr, ErrInvalidCredentials))
+		assert.True(t, errors.Is(refreshErr, ErrInvalidCredentials))
+		assert.Empty(t, sessions)
+	})
+
+	t.Run("revoked sessions are logged out", func(t *testing.T) {
+		// Arrange
+		svc, _, user := newServiceHelper(t)
+
+		revokedByAdmin, err := svc.CreateSession(context.TODO(), user.ID)
+		require.NoError(t, err)
+
+		loggedOut, err := svc.CreateSession(context.TODO(), user.ID)
+		require.NoError(t, err)
+
+		active, err := svc.CreateSession(context.TODO(), user.ID)
+		require.NoError(t, err)
+
+		// Act
+		require.NoError(t, svc.RevokeSession(context.TODO(), revokedByAdmin.Session.ID))
+		require.NoError(t, svc.RevokeRefreshToken(context.TODO(), loggedOut.RefreshToken))
+
+		unknownErr := svc.RevokeSession(context.TODO(), revokedByAdmin.Session.ID)
+		malformedErr := svc.RevokeRefreshToken(context.TODO(), "usrsvc_rt_malformed")
+
+		sessions, err := svc.Sessions(context.TODO(), user.ID)
+		require.NoError(t, err)
+
+		_, accessErr := svc.AuthenticateAccessToken(context.TODO(), revokedByAdmin.AccessToken)
+
+		// Assert
+		assert.True(t, errors.Is(unknownErr, ErrSessionNotFound))
+		assert.True(t, errors.Is(malformedErr, ErrInvalidCredentials))
+		assert.True(t, errors.Is(accessErr, ErrInvalidCredentials))
+
+		require.Len(t, sessions, 1)
+		assert.Equal(t, active.Session.ID, sessions[0].ID)
+	})
+
+	t.Run("rejects malformed and misplaced tokens", func(t *testing.T) {
+		// Arrange
+		svc, _, user := newServiceHelper(t)
+
+		tokens, err := svc.CreateSession(context.TODO(), user.ID)
+		require.NoError(t, err)
+
+		// Act
+		_, refreshAsAccessErr := svc.AuthenticateAccessToken(context.TODO(), tokens.RefreshToken)
+		_, accessAsRefreshErr := svc.RefreshSession(context.TODO(), tokens.AccessToken)
+		_, malformedErr := svc.AuthenticateAccessToken(context.TODO(), "usrsvc_at_not-a-uuid.secret")
+
+		// Assert
+		assert.True(t, errors.Is(refreshAsAccessErr, ErrInvalidCredentials))
+		assert.True(t, errors.Is(accessAsRefreshErr, ErrInvalidCredentials))
+		assert.True(t, errors.Is(malformedErr, ErrInvalidCredentials))
+	})
+
+	t.Run("doesn't create sessions for unknown users", func(t *testing.T) {
+		// Arrange
+		svc, _, _ := newServiceHelper(t)
+
+		// Act
+		_, err := svc.CreateSession(context.TODO(), "5f0c3a1e-7d2b-4c6a-8e9f-0a1b2c3d4e5f")
+
+		// Assert
+		assert.True(t, errors.Is(err, ErrUserNotFound))
+	})
+}
@@ -0,0 +1,14 @@
+package service
+
+import "context"
+
+var _ SMSSender = (*smsSenderMock)(nil)
+
+// smsSenderMock is a mock implementation of the SMS sender interface.
+type smsSenderMock struct {
+	SendFunc func(ctx context.Context, phone, message string) error
+}
+
+func (s *smsSenderMock) Send(ctx context.Context, phone, message string) error {
+	return s.SendFunc(ctx, phone, message)
+}
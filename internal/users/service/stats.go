@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/alesr/usrsvc/internal/tenant"
+	"github.com/alesr/usrsvc/pkg/events"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultReconcileInterval time.Duration = 5 * time.Minute
+	defaultRefreshDebounce   time.Duration = 2 * time.Second
+)
+
+var _ Publisher = (*CountryStats)(nil)
+
+// UserStats holds the number of users per country.
+type UserStats struct {
+	CountByCountry map[string]int64
+	RefreshedAt    time.Time
+}
+
+// Total returns the number of users across all countries.
+func (s *UserStats) Total() int64 {
+	var total int64
+	for _, count := range s.CountByCountry {
+		total += count
+	}
+	return total
+}
+
+// countryCounter is the repository method used to rebuild the stats.
+type countryCounter interface {
+	CountByCountry(ctx context.Context) (map[string]int64, error)
+}
+
+// CountryStats is an in-memory cache of the number of users per country.
+// It implements Publisher so it can be fanned out the user events: since the
+// events only carry the user id, every event schedules a single grouped count
+// after a short debounce, so bursts of writes cost one query. The cache is also
+// reconciled periodically, which covers writes made by other instances. Only the
+// users of the default tenant are counted: the other tenants are counted on request.
+type CountryStats struct {
+	logger   *zap.Logger
+	counter  countryCounter
+	interval time.Duration
+	debounce time.Duration
+	refresh  chan struct{}
+
+	mu    sync.RWMutex
+	stats *UserStats
+}
+
+// StatsOption is a function that configures the stats cache.
+type StatsOption func(*CountryStats)
+
+// WithReconcileInterval sets how often the stats are rebuilt from the database.
+func WithReconcileInterval(d time.Duration) StatsOption {
+	return func(c *CountryStats) {
+		c.interval = d
+	}
+}
+
+// WithRefreshDebounce sets how long to wait after an event before rebuilding the stats.
+func WithRefreshDebounce(d time.Duration) StatsOption {
+	return func(c *CountryStats) {
+		c.debounce = d
+	}
+}
+
+// NewCountryStats creates a new stats cache. Call Run to start filling it.
+func NewCountryStats(logger *zap.Logger, counter countryCounter, opts ...StatsOption) *CountryStats {
+	c := &CountryStats{
+		logger:   logger,
+		counter:  counter,
+		interval: defaultReconcileInterval,
+		debounce: defaultRefreshDebounce,
+		refresh:  make(chan struct{}, 1),
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Publish schedules a refresh of the stats. It never blocks the caller.
+func (c *CountryStats) Publish(ctx context.Context, event events.Event, data any) error {
+	switch event {
+	case events.UserCreated, events.UserUpdated, events.UserDeleted, events.UserMerged, events.UserAnonymized,
+		events.UserSuspended, events.UserDeactivated, events.UserReactivated:
+		select {
+		case c.refresh <- struct{}{}:
+		default: // A refresh is already pending.
+		}
+	}
+	return nil
+}
+
+// Run fills the cache and keeps it up to date until the context is done.
+func (c *CountryStats) Run(ctx context.Context) {
+	c.reconcile(ctx)
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	var debounce <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.reconcile(ctx)
+		case <-c.refresh:
+			if debounce == nil {
+				debounce = time.After(c.debounce)
+			}
+		case <-debounce:
+			debounce = nil
+			c.reconcile(ctx)
+		}
+	}
+}
+
+// Snapshot returns a copy of the current stats.
+// It returns false until the stats have been loaded at least once.
+func (c *CountryStats) Snapshot() (*UserStats, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.stats == nil {
+		return nil, false
+	}
+
+	counts := make(map[string]int64, len(c.stats.CountByCountry))
+	for country, count := range c.stats.CountByCountry {
+		counts[country] = count
+	}
+	return &UserStats{CountByCountry: counts, RefreshedAt: c.stats.RefreshedAt}, true
+}
+
+func (c *CountryStats) reconcile(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(tenant.ContextWithTenant(ctx, tenant.Default), dbTimeout)
+	defer cancel()
+
+	counts, err := c.counter.CountByCountry(ctx)
+	if err != nil {
+		// Keep serving the previous stats; the next event or tick will try again.
+		c.logger.Error("could not reconcile country stats", zap.Error(err))
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.stats = &UserStats{CountByCountry: counts, RefreshedAt: time.Now()}
+}
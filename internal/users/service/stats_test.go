@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alesr/usrsvc/pkg/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestCountryStats(t *testing.T) {
+	t.Parallel()
+
+	t.Run("not ready before the first reconcile", func(t *testing.T) {
+		// Arrange
+		stats := NewCountryStats(zap.NewNop(), &repoMock{})
+
+		// Act
+		observed, ok := stats.Snapshot()
+
+		// Assert
+		assert.False(t, ok)
+		assert.Nil(t, observed)
+	})
+
+	t.Run("bursts of events trigger a single refresh", func(t *testing.T) {
+		// Arrange
+		var calls int64
+		repo := &repoMock{
+			CountByCountryFunc: func(ctx context.Context) (map[string]int64, error) {
+				n := atomic.AddInt64(&calls, 1)
+				return map[string]int64{"BR": n}, nil
+			},
+		}
+
+		stats := NewCountryStats(
+			zap.NewNop(),
+			repo,
+			WithReconcileInterval(time.Hour),
+			WithRefreshDebounce(50*time.Millisecond),
+		)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		go stats.Run(ctx)
+
+		require.Eventually(t, func() bool {
+			_, ok := stats.Snapshot()
+			return ok
+		}, time.Second, 10*time.Millisecond)
+
+		// Act
+		for i := 0; i < 10; i++ {
+			require.NoError(t, stats.Publish(context.TODO(), events.UserCreated, "some-id"))
+		}
+
+		// Assert
+		require.Eventually(t, func() bool {
+			return atomic.LoadInt64(&calls) == 2
+		}, time.Second, 10*time.Millisecond)
+
+		time.Sleep(100 * time.Millisecond)
+		assert.Equal(t, int64(2), atomic.LoadInt64(&calls))
+
+		observed, ok := stats.Snapshot()
+		require.True(t, ok)
+		assert.Equal(t, map[string]int64{"BR": 2}, observed.CountByCountry)
+	})
+
+	t.Run("user lifecycle events schedule a refresh", func(t *testing.T) {
+		for _, event := range []events.Event{
+			events.UserCreated,
+			events.UserUpdated,
+			events.UserDeleted,
+			events.UserMerged,
+			events.UserAnonymized,
+			events.UserSuspended,
+			events.UserDeactivated,
+			events.UserReactivated,
+		} {
+			// Arrange
+			stats := NewCountryStats(zap.NewNop(), &repoMock{})
+
+			// Act
+			require.NoError(t, stats.Publish(context.TODO(), event, "some-id"))
+
+			// Assert
+			assert.Len(t, stats.refresh, 1, "no refresh scheduled on %s", event)
+		}
+
+		// The other events don't change the counts.
+		stats := NewCountryStats(zap.NewNop(), &repoMock{})
+		require.NoError(t, stats.Publish(context.TODO(), events.UserLocked, "some-id"))
+		assert.Empty(t, stats.refresh)
+	})
+
+	t.Run("keeps the previous stats when the reconcile fails", func(t *testing.T) {
+		// Arrange
+		fail := false
+		repo := &repoMock{
+			CountByCountryFunc: func(ctx context.Context) (map[string]int64, error) {
+				if fail {
+					return nil, errors.New("some error")
+				}
+				return map[string]int64{"US": 1}, nil
+			},
+		}
+
+		stats := NewCountryStats(zap.NewNop(), repo)
+		stats.reconcile(context.TODO())
+
+		// Act
+		fail = true
+		stats.reconcile(context.TODO())
+
+		// Assert
+		observed, ok := stats.Snapshot()
+		require.True(t, ok)
+		assert.Equal(t, map[string]int64{"US": 1}, observed.CountByCountry)
+	})
+
+	t.Run("snapshots are copies", func(t *testing.T) {
+		// Arrange
+		repo := &repoMock{
+			CountByCountryFunc: func(ctx context.Context) (map[string]int64, error) {
+				return map[string]int64{"US": 1}, nil
+			},
+		}
+
+		stats := NewCountryStats(zap.NewNop(), repo)
+		stats.reconcile(context.TODO())
+
+		// Act
+		first, _ := stats.Snapshot()
+		first.CountByCountry["US"] = 100
+
+		// Assert
+		second, _ := stats.Snapshot()
+		assert.Equal(t, int64(1), second.CountByCountry["US"])
+	})
+}
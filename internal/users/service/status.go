@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alesr/usrsvc/internal/audit"
+	"github.com/alesr/usrsvc/internal/users/repository"
+	"github.com/alesr/usrsvc/pkg/events"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// statusField is the audited field of the status changes.
+const statusField string = "status"
+
+// SuspendUser suspends the user, e.g. pending an abuse investigation. Suspended users
+// can't sign in, with any credentials, until they are reactivated.
+func (s *ServiceDefault) SuspendUser(ctx context.Context, id string) (*User, error) {
+	ctx, span := tracer.Start(ctx, "ServiceDefault.SuspendUser")
+	defer span.End()
+	span.SetAttributes(attribute.String("user.id", id))
+
+	return s.setStatus(ctx, id, StatusSuspended, events.UserSuspended)
+}
+
+// DeactivateUser deactivates the user, e.g. when they close their account. Like suspended
+// users, deactivated users can't sign in until they are reactivated, but keep their data.
+func (s *ServiceDefault) DeactivateUser(ctx context.Context, id string) (*User, error) {
+	ctx, span := tracer.Start(ctx, "ServiceDefault.DeactivateUser")
+	defer span.End()
+	span.SetAttributes(attribute.String("user.id", id))
+
+	return s.setStatus(ctx, id, StatusDeactivated, events.UserDeactivated)
+}
+
+// ReactivateUser makes a suspended or deactivated user active again.
+func (s *ServiceDefault) ReactivateUser(ctx context.Context, id string) (*User, error) {
+	ctx, span := tracer.Start(ctx, "ServiceDefault.ReactivateUser")
+	defer span.End()
+	span.SetAttributes(attribute.String("user.id", id))
+
+	return s.setStatus(ctx, id, StatusActive, events.UserReactivated)
+}
+
+// setStatus sets the status of the user and publishes the event. Setting the current
+// status is a no-op, so retries don't publish the event twice.
+func (s *ServiceDefault) setStatus(ctx context.Context, id, status string, event events.Event) (*User, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, fmt.Errorf("could not validate id '%s': %w", id, ErrInvalidID)
+	}
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	stored, err := s.repo.Get(repository.ContextWithPrimary(ctx), id)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return nil, fmt.Errorf("could not set status of user '%s': %w", id, ErrUserNotFound)
+		}
+		return nil, fmt.Errorf("could not set status of user '%s': %w", id, err)
+	}
+
+	if stored.Anonymized {
+		return nil, fmt.Errorf("could not set status of user '%s': %w", id, ErrUserAnonymized)
+	}
+
+	before := stored.Status
+	if before != status {
+		stored.Status = status
+		stored.UpdatedAt = time.Now()
+
+		if err := s.repo.SetStatus(ctx, stored); err != nil {
+			if errors.Is(err, repository.ErrUserNotFound) {
+				return nil, fmt.Errorf("could not set status of user '%s': %w", id, ErrUserNotFound)
+			}
+			return nil, fmt.Errorf("could not set status of user '%s': %w", id, err)
+		}
+
+		s.invalidateCachedUser(ctx, id)
+		s.auditChanges(ctx, audit.ActionStatusChange, id, map[string]audit.Change{
+			statusField: {Before: before, After: status},
+		})
+
+		s.logger.Info("set user status", zap.String("id", id), zap.String("status", status))
+
+		if s.publisher != nil {
+			s.publish(ctx, event, userEvent(id, stored.EventSequence, id))
+		}
+	}
+
+	user := newUserDomainFromStore(stored)
+
+	// The hash never leaves the service.
+	user.Password = ""
+	return user, nil
+}
+
+// checkStatus returns ErrUserSuspended or ErrUserDeactivated for the users who can't sign in.
+func checkStatus(user *repository.User) error {
+	switch user.Status {
+	case StatusSuspended:
+		return ErrUserSuspended
+	case StatusDeactivated:
+		return ErrUserDeactivated
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alesr/usrsvc/internal/audit"
+	"github.com/alesr/usrsvc/internal/users/repository"
+	"github.com/alesr/usrsvc/pkg/events"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestUserStatus(t *testing.T) {
+	t.Parallel()
+
+	newServiceHelper := func(t *testing.T, opts ...Option) (*ServiceDefault, *User) {
+		t.Helper()
+
+		hasher := &hasherMock{
+			HashFunc: func(ctx context.Context, password []byte) ([]byte, error) {
+				return bcrypt.GenerateFromPassword(password, bcrypt.MinCost)
+			},
+			CompareFunc: func(ctx context.Context, hash, password []byte) error {
+				return bcrypt.CompareHashAndPassword(hash, password)
+			},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), repository.NewMemory(), append([]Option{WithHasher(hasher)}, opts...)...)
+
+		created, err := svc.Create(context.TODO(), &User{
+			FirstName: "John",
+			LastName:  "Doe",
+			Nickname:  "jdoe",
+			Password:  "password1!",
+			Email:     "joedoe@foo.bar",
+			Country:   "US",
+		})
+		require.NoError(t, err)
+		return svc, created
+	}
+
+	t.Run("suspended users can't sign in until reactivated", func(t *testing.T) {
+		// Arrange
+		var published []events.Event
+		publisher := &publisherMock{
+			PublishFunc: func(_ context.Context, event events.Event, data any) error {
+				published = append(published, event)
+				return nil
+			},
+		}
+
+		auditLog := audit.NewMemory()
+		svc, user := newServiceHelper(t, WithPublisher(publisher), WithAuditLog(auditLog))
+
+		// Act
+		suspended, err := svc.SuspendUser(context.TODO(), user.ID)
+		require.NoError(t, err)
+
+		// Suspending again is a no-op.
+		_, err = svc.SuspendUser(context.TODO(), user.ID)
+		require.NoError(t, err)
+
+		fetched, err := svc.Fetch(context.TODO(), user.ID)
+		require.NoError(t, err)
+
+		_, suspendedErr := svc.Authenticate(context.TODO(), "joedoe@foo.bar", "password1!", "")
+		_, wrongPasswordErr := svc.Authenticate(context.TODO(), "joedoe@foo.bar", "wrong-password1!", "")
+
+		reactivated, err := svc.ReactivateUser(context.TODO(), user.ID)
+		require.NoError(t, err)
+
+		_, reactivatedErr := svc.Authenticate(context.TODO(), "joedoe@foo.bar", "password1!", "")
+
+		// Assert
+		assert.Equal(t, StatusActive, user.Status)
+		assert.Equal(t, StatusSuspended, suspended.Status)
+		assert.Empty(t, suspended.Password)
+		assert.Equal(t, StatusSuspended, fetched.Status)
+		assert.Equal(t, StatusActive, reactivated.Status)
+
+		assert.True(t, errors.Is(suspendedErr, ErrUserSuspended))
+		assert.True(t, errors.Is(wrongPasswordErr, ErrInvalidCredentials))
+		assert.NoError(t, reactivatedErr)
+
+		assert.Equal(t, []events.Event{events.UserCreated, events.UserSuspended, events.UserReactivated}, published)
+
+		auditEvents, err := auditLog.List(context.TODO(), audit.Filter{UserID: user.ID, Limit: 10})
+		require.NoError(t, err)
+		require.Len(t, auditEvents, 3)
+		assert.Equal(t, audit.ActionStatusChange, auditEvents[0].Action)
+		assert.Equal(t, audit.Change{Before: StatusSuspended, After: StatusActive}, auditEvents[0].Changes["status"])
+	})
+
+	t.Run("deactivated users can't sign in", func(t *testing.T) {
+		// Arrange
+		svc, user := newServiceHelper(t)
+
+		// Act
+		deactivated, err := svc.DeactivateUser(context.TODO(), user.ID)
+		require.NoError(t, err)
+
+		_, authErr := svc.Authenticate(context.TODO(), "joedoe@foo.bar", "password1!", "")
+
+		// Assert
+		assert.Equal(t, StatusDeactivated, deactivated.Status)
+		assert.True(t, errors.Is(authErr, ErrUserDeactivated))
+	})
+
+	t.Run("anonymized users keep their status", func(t *testing.T) {
+		// Arrange
+		svc, user := newServiceHelper(t)
+
+		_, err := svc.AnonymizeUser(context.TODO(), user.ID)
+		require.NoError(t, err)
+
+		// Act
+		_, err = svc.SuspendUser(context.TODO(), user.ID)
+
+		// Assert
+		assert.True(t, errors.Is(err, ErrUserAnonymized))
+	})
+
+	t.Run("user not found", func(t *testing.T) {
+		// Arrange
+		svc := NewServiceDefault(zap.NewNop(), repository.NewMemory())
+
+		// Act
+		suspended, err := svc.SuspendUser(context.TODO(), uuid.New().String())
+
+		// Assert
+		assert.Nil(t, suspended)
+		assert.True(t, errors.Is(err, ErrUserNotFound))
+	})
+
+	t.Run("invalid id", func(t *testing.T) {
+		// Arrange
+		svc := NewServiceDefault(zap.NewNop(), repository.NewMemory())
+
+		// Act
+		reactivated, err := svc.ReactivateUser(context.TODO(), "foo")
+
+		// Assert
+		assert.Nil(t, reactivated)
+		assert.True(t, errors.Is(err, ErrInvalidID))
+	})
+}
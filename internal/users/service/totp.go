@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alesr/usrsvc/internal/totp"
+	"github.com/alesr/usrsvc/internal/users/repository"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// WithTOTP enables the TOTP multi-factor authentication. The secrets are encrypted at rest
+// with the cipher, and the issuer labels the accounts in the authenticator apps.
+func WithTOTP(cipher *totp.Cipher, issuer string) Option {
+	return func(s *ServiceDefault) {
+		s.totpCipher = cipher
+		s.totpIssuer = issuer
+	}
+}
+
+// TOTPEnrollment is the secret handed out on enrollment, for the user to add to an
+// authenticator app, either typed in or scanned from the URI as a QR code.
+type TOTPEnrollment struct {
+	Secret string
+	URI    string
+}
+
+// EnrollTOTP generates a new TOTP secret for the user, who must confirm their password.
+// Wrong passwords count towards the lockout like failed logins.
+// The enrollment is pending until a first code is verified with VerifyTOTP, and
+// enrolling again meanwhile replaces the secret.
+func (s *ServiceDefault) EnrollTOTP(ctx context.Context, id, password string) (*TOTPEnrollment, error) {
+	ctx, span := tracer.Start(ctx, "ServiceDefault.EnrollTOTP")
+	defer span.End()
+	span.SetAttributes(attribute.String("user.id", id))
+
+	if s.totpCipher == nil {
+		return nil, fmt.Errorf("could not enroll totp: %w", ErrTOTPDisabled)
+	}
+
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, fmt.Errorf("could not validate id '%s': %w", id, ErrInvalidID)
+	}
+
+	dbCtx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	stored, err := s.repo.Get(repository.ContextWithPrimary(dbCtx), id)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return nil, fmt.Errorf("could not enroll totp for user '%s': %w", id, ErrUserNotFound)
+		}
+		return nil, fmt.Errorf("could not enroll totp for user '%s': %w", id, err)
+	}
+
+	lockout, err := s.verifyPassword(ctx, stored, password)
+	if err != nil {
+		return nil, fmt.Errorf("could not enroll totp for user '%s': %w", id, err)
+	}
+	s.resetLockout(ctx, lockout)
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, fmt.Errorf("could not enroll totp for user '%s': %w", id, err)
+	}
+
+	encrypted, err := s.totpCipher.Encrypt(id, secret)
+	if err != nil {
+		return nil, fmt.Errorf("could not enroll totp for user '%s': %w", id, err)
+	}
+
+	// Comparing the password may take a while, so the insert gets its own timeout.
+	dbCtx, cancel = s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	if err := s.repo.InsertTOTP(dbCtx, &repository.TOTP{
+		UserID:    id,
+		Secret:    encrypted,
+		CreatedAt: time.Now().UTC(),
+	}); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrTOTPEnabled):
+			return nil, fmt.Errorf("could not enroll totp for user '%s': %w", id, ErrTOTPEnabled)
+		case errors.Is(err, repository.ErrUserNotFound):
+			return nil, fmt.Errorf("could not enroll totp for user '%s': %w", id, ErrUserNotFound)
+		}
+		return nil, fmt.Errorf("could not enroll totp for user '%s': %w", id, err)
+	}
+
+	return &TOTPEnrollment{
+		Secret: totp.EncodeSecret(secret),
+		URI:    totp.URI(s.totpIssuer, stored.Email, secret),
+	}, nil
+}
+
+// VerifyTOTP verifies a code of the TOTP secret of the user. Verifying the first code
+// after enrollment enables TOTP, which is then required to authenticate.
+func (s *ServiceDefault) VerifyTOTP(ctx context.Context, id, code string) error {
+	ctx, span := tracer.Start(ctx, "ServiceDefault.VerifyTOTP")
+	defer span.End()
+	span.SetAttributes(attribute.String("user.id", id))
+
+	if s.totpCipher == nil {
+		return fmt.Errorf("could not verify totp: %w", ErrTOTPDisabled)
+	}
+
+	if _, err := uuid.Parse(id); err != nil {
+		return fmt.Errorf("could not validate id '%s': %w", id, ErrInvalidID)
+	}
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	stored, err := s.repo.GetTOTP(repository.ContextWithPrimary(ctx), id)
+	if err != nil {
+		if errors.Is(err, repository.ErrTOTPNotFound) {
+			return fmt.Errorf("could not verify totp for user '%s': %w", id, ErrTOTPNotEnrolled)
+		}
+		return fmt.Errorf("could not verify totp for user '%s': %w", id, err)
+	}
+
+	if err := s.useTOTPCode(ctx, stored, code); err != nil {
+		return fmt.Errorf("could not verify totp for user '%s': %w", id, err)
+	}
+	return nil
+}
+
+// checkTOTP checks the code of the users who enabled TOTP, once their password is verified.
+// Unlike the lockout, failing to read the enrollment fails the login: skipping the check
+// would let the password alone through.
+func (s *ServiceDefault) checkTOTP(ctx context.Context, userID, code string) error {
+	if s.totpCipher == nil {
+		return nil
+	}
+
+	stored, err := s.repo.GetTOTP(repository.ContextWithPrimary(ctx), userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrTOTPNotFound) {
+			return nil
+		}
+		return fmt.Errorf("could not get totp: %w", err)
+	}
+
+	if !stored.Enabled {
+		return nil
+	}
+
+	if code == "" {
+		return ErrTOTPRequired
+	}
+	return s.useTOTPCode(ctx, stored, code)
+}
+
+// useTOTPCode validates the code against the stored secret and records its use,
+// so each code is accepted once.
+func (s *ServiceDefault) useTOTPCode(ctx context.Context, stored *repository.TOTP, code string) error {
+	secret, err := s.totpCipher.Decrypt(stored.UserID, stored.Secret)
+	if err != nil {
+		return err
+	}
+
+	step, ok := totp.Validate(secret, code, time.Now())
+	if !ok {
+		return ErrTOTPInvalid
+	}
+
+	if err := s.repo.UseTOTP(ctx, stored.UserID, step); err != nil {
+		if errors.Is(err, repository.ErrTOTPCodeUsed) {
+			return ErrTOTPInvalid
+		}
+		return fmt.Errorf("could not use totp: %w", err)
+	}
+	return nil
+}
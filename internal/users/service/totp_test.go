@@ -0,0 +1,192 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alesr/usrsvc/internal/totp"
+	"github.com/alesr/usrsvc/internal/users/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestTOTP(t *testing.T) {
+	t.Parallel()
+
+	newServiceHelper := func(t *testing.T, opts ...Option) (*ServiceDefault, *repository.Memory, *User) {
+		t.Helper()
+
+		hasher := &hasherMock{
+			HashFunc: func(ctx context.Context, password []byte) ([]byte, error) {
+				return bcrypt.GenerateFromPassword(password, bcrypt.MinCost)
+			},
+			CompareFunc: func(ctx context.Context, hash, password []byte) error {
+				return bcrypt.CompareHashAndPassword(hash, password)
+			},
+		}
+
+		cipher, err := totp.NewCipher(bytes.Repeat([]byte{1}, totp.KeyLength))
+		require.NoError(t, err)
+
+		repo := repository.NewMemory()
+		opts = append([]Option{WithHasher(hasher), WithTOTP(cipher, "usrsvc")}, opts...)
+		svc := NewServiceDefault(zap.NewNop(), repo, opts...)
+
+		created, err := svc.Create(context.TODO(), &User{
+			FirstName: "John",
+			LastName:  "Doe",
+			Nickname:  "jdoe",
+			Password:  "password1!",
+			Email:     "joedoe@foo.bar",
+			Country:   "US",
+		})
+		require.NoError(t, err)
+		return svc, repo, created
+	}
+
+	codeHelper := func(t *testing.T, enrollment *TOTPEnrollment, at time.Time) string {
+		t.Helper()
+
+		secret, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(enrollment.Secret)
+		require.NoError(t, err)
+		return totp.Code(secret, totp.Step(at))
+	}
+
+	t.Run("verified codes enable totp", func(t *testing.T) {
+		// Arrange
+		svc, _, user := newServiceHelper(t)
+
+		enrollment, err := svc.EnrollTOTP(context.TODO(), user.ID, "password1!")
+		require.NoError(t, err)
+
+		// Act
+		_, pendingErr := svc.Authenticate(context.TODO(), "joedoe@foo.bar", "password1!", "")
+
+		require.NoError(t, svc.VerifyTOTP(context.TODO(), user.ID, codeHelper(t, enrollment, time.Now())))
+
+		_, missingErr := svc.Authenticate(context.TODO(), "joedoe@foo.bar", "password1!", "")
+		_, replayedErr := svc.Authenticate(context.TODO(), "joedoe@foo.bar", "password1!", codeHelper(t, enrollment, time.Now()))
+		authenticated, err := svc.Authenticate(context.TODO(), "joedoe@foo.bar", "password1!", codeHelper(t, enrollment, time.Now().Add(totp.Period)))
+		require.NoError(t, err)
+
+		_, enrollAgainErr := svc.EnrollTOTP(context.TODO(), user.ID, "password1!")
+
+		// Assert
+		assert.NoError(t, pendingErr)
+		assert.True(t, errors.Is(missingErr, ErrTOTPRequired))
+		assert.True(t, errors.Is(replayedErr, ErrInvalidCredentials))
+		assert.Equal(t, user.ID, authenticated.ID)
+		assert.True(t, errors.Is(enrollAgainErr, ErrTOTPEnabled))
+
+		assert.True(t, strings.HasPrefix(enrollment.URI, "otpauth://totp/usrsvc:joedoe@foo.bar?"))
+		assert.Contains(t, enrollment.URI, "secret="+enrollment.Secret)
+	})
+
+	t.Run("id token logins require the code", func(t *testing.T) {
+		// Arrange
+		svc, repo, user := newServiceHelper(t, WithIdentityProviders(newIdentityProvidersHelper(t)))
+
+		require.NoError(t, repo.LinkIdentity(context.TODO(), &repository.LinkedIdentity{
+			Provider:  "google",
+			Subject:   "1234567890",
+			UserID:    user.ID,
+			Email:     "joedoe@gmail.com",
+			CreatedAt: time.Now(),
+		}))
+
+		enrollment, err := svc.EnrollTOTP(context.TODO(), user.ID, "password1!")
+		require.NoError(t, err)
+		require.NoError(t, svc.VerifyTOTP(context.TODO(), user.ID, codeHelper(t, enrollment, time.Now())))
+
+		// Act
+		_, missingErr := svc.AuthenticateWithIDToken(context.TODO(), "google", "valid-token", "")
+		_, wrongErr := svc.AuthenticateWithIDToken(context.TODO(), "google", "valid-token", "000000")
+		authenticated, err := svc.AuthenticateWithIDToken(context.TODO(), "google", "valid-token", codeHelper(t, enrollment, time.Now().Add(totp.Period)))
+		require.NoError(t, err)
+
+		// Assert
+		assert.True(t, errors.Is(missingErr, ErrTOTPRequired))
+		assert.True(t, errors.Is(wrongErr, ErrInvalidCredentials))
+		assert.Equal(t, user.ID, authenticated.ID)
+	})
+
+	t.Run("wrong codes count towards the lockout", func(t *testing.T) {
+		// Arrange
+		svc, repo, user := newServiceHelper(t, WithLockoutPolicy(LockoutPolicy{MaxAttempts: 3, Duration: time.Minute, MaxDuration: time.Hour}))
+
+		enrollment, err := svc.EnrollTOTP(context.TODO(), user.ID, "password1!")
+		require.NoError(t, err)
+		require.NoError(t, svc.VerifyTOTP(context.TODO(), user.ID, codeHelper(t, enrollment, time.Now())))
+
+		// Act
+		_, err = svc.Authenticate(context.TODO(), "joedoe@foo.bar", "password1!", "000000")
+
+		lockout, lockoutErr := repo.GetLockout(context.TODO(), user.ID)
+		require.NoError(t, lockoutErr)
+
+		// Assert
+		assert.True(t, errors.Is(err, ErrInvalidCredentials))
+		assert.Equal(t, 1, lockout.FailedAttempts)
+	})
+
+	t.Run("pending enrollments are replaced", func(t *testing.T) {
+		// Arrange
+		svc, _, user := newServiceHelper(t)
+
+		first, err := svc.EnrollTOTP(context.TODO(), user.ID, "password1!")
+		require.NoError(t, err)
+
+		second, err := svc.EnrollTOTP(context.TODO(), user.ID, "password1!")
+		require.NoError(t, err)
+
+		// Act
+		firstErr := svc.VerifyTOTP(context.TODO(), user.ID, codeHelper(t, first, time.Now()))
+		secondErr := svc.VerifyTOTP(context.TODO(), user.ID, codeHelper(t, second, time.Now()))
+
+		// Assert
+		assert.True(t, errors.Is(firstErr, ErrTOTPInvalid))
+		assert.NoError(t, secondErr)
+	})
+
+	t.Run("enrolling requires the password", func(t *testing.T) {
+		// Arrange
+		svc, _, user := newServiceHelper(t)
+
+		// Act
+		_, err := svc.EnrollTOTP(context.TODO(), user.ID, "wrong-password1!")
+
+		// Assert
+		assert.True(t, errors.Is(err, ErrInvalidCredentials))
+	})
+
+	t.Run("verifying requires an enrollment", func(t *testing.T) {
+		// Arrange
+		svc, _, user := newServiceHelper(t)
+
+		// Act
+		err := svc.VerifyTOTP(context.TODO(), user.ID, "123456")
+
+		// Assert
+		assert.True(t, errors.Is(err, ErrTOTPNotEnrolled))
+	})
+
+	t.Run("is disabled without a cipher", func(t *testing.T) {
+		// Arrange
+		svc := NewServiceDefault(zap.NewNop(), repository.NewMemory())
+
+		// Act
+		_, enrollErr := svc.EnrollTOTP(context.TODO(), "8b2e0a53-3f0f-4d8c-9a3b-1f7c2d4e5a6b", "password1!")
+		verifyErr := svc.VerifyTOTP(context.TODO(), "8b2e0a53-3f0f-4d8c-9a3b-1f7c2d4e5a6b", "123456")
+
+		// Assert
+		assert.True(t, errors.Is(enrollErr, ErrTOTPDisabled))
+		assert.True(t, errors.Is(verifyErr, ErrTOTPDisabled))
+	})
+}
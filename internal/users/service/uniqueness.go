@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/alesr/usrsvc/internal/users/repository"
+	"github.com/alesr/usrsvc/pkg/uservalidation"
+)
+
+// WithUniquenessScope configures the scope of the email and nickname uniqueness reported
+// by the DuplicateErrors. It must be the scope of the repository, ScopeGlobal by default.
+func WithUniquenessScope(scope repository.UniquenessScope) Option {
+	return func(s *ServiceDefault) {
+		s.uniquenessScope = scope
+	}
+}
+
+// CheckNicknameAvailable reports whether the nickname is free within its uniqueness scope,
+// for the signup forms to tell as it is typed. The country is required with ScopeCountry.
+// It reads the replica: Create still rejects a nickname taken in the meantime.
+func (s *ServiceDefault) CheckNicknameAvailable(ctx context.Context, nickname, country string) (bool, error) {
+	ctx, span := tracer.Start(ctx, "ServiceDefault.CheckNicknameAvailable")
+	defer span.End()
+
+	nickname = uservalidation.NormalizeName(nickname)
+	country = uservalidation.NormalizeCountryCode(country)
+
+	if s.uniquenessScope == repository.ScopeCountry && country == "" {
+		return false, fmt.Errorf("could not check nickname: country required by the %s scope: %w", s.uniquenessScope, ErrCountryCodeInvalid)
+	}
+
+	ctx, cancel := s.timeouts.WithTimeout(ctx)
+	defer cancel()
+
+	exists, err := s.repo.NicknameExists(ctx, nickname, country)
+	if err != nil {
+		return false, fmt.Errorf("could not check nickname: %w", err)
+	}
+	return !exists, nil
+}
+
+// DuplicateError is returned when the email or the nickname of a user is already used
+// within its uniqueness scope. It wraps ErrUserAlreadyExists for emails and
+// ErrNicknameTaken for nicknames.
+type DuplicateError struct {
+	Field string
+	Scope repository.UniquenessScope
+}
+
+func (e *DuplicateError) Error() string {
+	return fmt.Sprintf("%s: %s is already used within the %s scope", e.Unwrap(), e.Field, e.Scope)
+}
+
+func (e *DuplicateError) Unwrap() error {
+	if e.Field == "nickname" {
+		return ErrNicknameTaken
+	}
+	return ErrUserAlreadyExists
+}
+
+// duplicateError maps the duplicate errors of the repository to a DuplicateError.
+// It returns nil for any other error.
+func (s *ServiceDefault) duplicateError(err error) error {
+	switch {
+	case errors.Is(err, repository.ErrDuplicateEmail):
+		return &DuplicateError{Field: "email", Scope: s.uniquenessScope}
+	case errors.Is(err, repository.ErrDuplicateNickname):
+		return &DuplicateError{Field: "nickname", Scope: s.uniquenessScope}
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alesr/usrsvc/internal/users/repository"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestCheckNicknameAvailable(t *testing.T) {
+	t.Parallel()
+
+	t.Run("taken within the country only", func(t *testing.T) {
+		// Arrange
+		repo := repository.NewMemory(repository.WithMemoryUniquenessScope(repository.ScopeCountry))
+		require.NoError(t, repo.Insert(context.TODO(), &repository.User{
+			ID:       uuid.New().String(),
+			Nickname: "jdoe",
+			Email:    "joedoe@foo.bar",
+			Country:  "US",
+		}))
+
+		svc := NewServiceDefault(zap.NewNop(), repo, WithUniquenessScope(repository.ScopeCountry))
+
+		// Act
+		taken, err := svc.CheckNicknameAvailable(context.TODO(), "jdoe", "us")
+		require.NoError(t, err)
+
+		otherCountry, err := svc.CheckNicknameAvailable(context.TODO(), "jdoe", "BR")
+		require.NoError(t, err)
+
+		_, noCountryErr := svc.CheckNicknameAvailable(context.TODO(), "jdoe", "")
+
+		// Assert
+		assert.False(t, taken)
+		assert.True(t, otherCountry)
+		assert.True(t, errors.Is(noCountryErr, ErrCountryCodeInvalid))
+	})
+
+	t.Run("repository error", func(t *testing.T) {
+		// Arrange
+		repo := &repoMock{
+			NicknameExistsFunc: func(ctx context.Context, nickname, country string) (bool, error) {
+				return false, errors.New("some error")
+			},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), repo)
+
+		// Act
+		available, err := svc.CheckNicknameAvailable(context.TODO(), "jdoe", "")
+
+		// Assert
+		assert.False(t, available)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	userrepo "github.com/alesr/usrsvc/internal/users/repository"
+	"github.com/jmoiron/sqlx"
+	"github.com/pressly/goose/v3"
+	"go.uber.org/zap"
+)
+
+const migrateUsage = "usage: usrsvc migrate up|down|status|create <name>"
+
+// migrationNamePattern keeps the names of the new migrations in the snake case of the
+// existing files.
+var migrationNamePattern = regexp.MustCompile(`^[a-z0-9]+(_[a-z0-9]+)*$`)
+
+// migrationTemplate is the content of a new migration.
+const migrationTemplate = `-- +goose Up
+-- +goose StatementBegin
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- +goose StatementEnd
+`
+
+// migrationTarget is a database whose schema is managed by the service.
+type migrationTarget struct {
+	name string
+	db   *sqlx.DB
+}
+
+// migrationSource returns the goose dialect and the embedded migrations of a driver.
+func migrationSource(driver string) (string, string) {
+	if driver == sqliteDriverName {
+		return userrepo.SQLiteDriverName, sqliteMigrationsDir
+	}
+	return postgresDriverName, dbMigrationsDir
+}
+
+// migrateSchema brings the schema of db up to date and returns its version. With
+// auto-migration off, it only verifies that a migration job already did.
+func migrateSchema(db *sqlx.DB, driver string, auto bool) (int64, error) {
+	if !auto {
+		if err := checkMigrations(db, driver); err != nil {
+			return 0, fmt.Errorf("AUTO_MIGRATE is off and the schema is outdated, run usrsvc migrate up: %w", err)
+		}
+		return goose.GetDBVersion(db.DB)
+	}
+
+	goose.SetBaseFS(embedMigrations)
+
+	dialect, dir := migrationSource(driver)
+	if err := goose.SetDialect(dialect); err != nil {
+		return 0, fmt.Errorf("could not set goose dialect: %w", err)
+	}
+
+	if err := goose.Up(db.DB, dir); err != nil {
+		return 0, fmt.Errorf("could not run goose migrations: %w", err)
+	}
+	return goose.GetDBVersion(db.DB)
+}
+
+// runMigrate is `usrsvc migrate`, managing the schema outside of the startup, e.g. from
+// a deployment job when AUTO_MIGRATE is off. up, down and status apply to every database
+// the service migrates on startup: the primary, the dual-write target and the regional
+// databases. create adds an empty migration to the source tree.
+func runMigrate(ctx context.Context, logger *zap.Logger, cfg *config, args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	if cfg.DBDriver != postgresDriverName && cfg.DBDriver != sqliteDriverName {
+		return fmt.Errorf("migrations only apply to the '%s' and '%s' drivers, got '%s'",
+			postgresDriverName, sqliteDriverName, cfg.DBDriver)
+	}
+
+	_, dir := migrationSource(cfg.DBDriver)
+
+	if args[0] == "create" {
+		if len(args) != 2 {
+			return errors.New(migrateUsage)
+		}
+
+		path, err := createMigration(dir, args[1])
+		if err != nil {
+			return err
+		}
+
+		logger.Info("created migration", zap.String("path", path))
+		return nil
+	}
+
+	var migrate func(target migrationTarget) error
+	switch args[0] {
+	case "up":
+		migrate = func(target migrationTarget) error {
+			return goose.Up(target.db.DB, dir)
+		}
+	case "down":
+		migrate = func(target migrationTarget) error {
+			return goose.Down(target.db.DB, dir)
+		}
+	case "status":
+		goose.SetLogger(log.New(stdout, "", 0))
+		defer goose.SetLogger(log.New(os.Stderr, "", log.LstdFlags))
+
+		migrate = func(target migrationTarget) error {
+			fmt.Fprintf(stdout, "%s:\n", target.name)
+			return goose.Status(target.db.DB, dir)
+		}
+	default:
+		return fmt.Errorf("unknown migrate command '%s', %s", args[0], migrateUsage)
+	}
+
+	targets, err := openMigrationTargets(cfg)
+	defer func() {
+		for _, target := range targets {
+			target.db.Close()
+		}
+	}()
+	if err != nil {
+		return err
+	}
+
+	goose.SetBaseFS(embedMigrations)
+
+	dialect, _ := migrationSource(cfg.DBDriver)
+	if err := goose.SetDialect(dialect); err != nil {
+		return fmt.Errorf("could not set goose dialect: %w", err)
+	}
+
+	for _, target := range targets {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if err := migrate(target); err != nil {
+			return fmt.Errorf("could not run migrate %s on the %s database: %w", args[0], target.name, err)
+		}
+
+		version, err := goose.GetDBVersion(target.db.DB)
+		if err != nil {
+			return fmt.Errorf("could not get the %s database version: %w", target.name, err)
+		}
+
+		logger.Info("migrate "+args[0]+" done", zap.String("database", target.name), zap.Int64("version", version))
+	}
+	return nil
+}
+
+// openMigrationTargets opens the databases migrated on startup. The replica is migrated
+// through the primary.
+func openMigrationTargets(cfg *config) ([]migrationTarget, error) {
+	db, err := openDB(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("could not open the primary database: %w", err)
+	}
+
+	targets := []migrationTarget{{name: "primary", db: db}}
+	if cfg.DBDriver != postgresDriverName {
+		return targets, nil
+	}
+
+	if cfg.DualWriteDSN != "" {
+		targetDB, err := sqlx.Open(postgresDriverName, cfg.DualWriteDSN)
+		if err != nil {
+			return targets, fmt.Errorf("could not open the dual-write database: %w", err)
+		}
+		targets = append(targets, migrationTarget{name: "dual-write", db: targetDB})
+	}
+
+	if cfg.ResidencyRegions != "" {
+		regions, err := cfg.residencyRegions()
+		if err != nil {
+			return targets, fmt.Errorf("invalid data residency regions: %w", err)
+		}
+
+		for _, region := range regions {
+			regionDB, err := sqlx.Open(postgresDriverName, region.dsn)
+			if err != nil {
+				return targets, fmt.Errorf("could not open the %s regional database: %w", region.name, err)
+			}
+			targets = append(targets, migrationTarget{name: "region " + region.name, db: regionDB})
+		}
+	}
+	return targets, nil
+}
+
+// createMigration writes an empty migration numbered after the last one in dir and
+// returns its path.
+func createMigration(dir, name string) (string, error) {
+	if !migrationNamePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid migration name '%s', use lowercase letters, digits and underscores", name)
+	}
+
+	goose.SetBaseFS(nil)
+	defer goose.SetBaseFS(embedMigrations)
+
+	migrations, err := goose.CollectMigrations(dir, 0, goose.MaxVersion)
+	if err != nil {
+		return "", fmt.Errorf("could not collect migrations: %w", err)
+	}
+
+	var version int64 = 1
+	if last, err := migrations.Last(); err == nil {
+		version = last.Version + 1
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("%03d_%s.sql", version, name))
+	if err := os.WriteFile(path, []byte(migrationTemplate), 0o644); err != nil {
+		return "", fmt.Errorf("could not write migration: %w", err)
+	}
+	return path, nil
+}
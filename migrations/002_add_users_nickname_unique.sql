@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE users ADD CONSTRAINT users_nickname_key UNIQUE (nickname);
+
+-- +goose Down
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_nickname_key;
@@ -0,0 +1,17 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS user_tombstones (
+  id UUID PRIMARY KEY,
+  merged_into UUID NOT NULL,
+  first_name VARCHAR(256) NOT NULL,
+  last_name VARCHAR(256) NOT NULL,
+  nickname VARCHAR(256) NOT NULL,
+  email VARCHAR(256) NOT NULL,
+  country VARCHAR(256) NOT NULL,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+  merged_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_tombstones_merged_into ON user_tombstones (merged_into);
+
+-- +goose Down
+DROP TABLE IF EXISTS user_tombstones;
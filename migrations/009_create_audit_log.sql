@@ -0,0 +1,15 @@
+-- +goose Up
+-- The user id has no foreign key: the audit log must outlive the users.
+CREATE TABLE IF NOT EXISTS audit_log (
+  id BIGSERIAL PRIMARY KEY,
+  actor VARCHAR(256) NOT NULL,
+  action VARCHAR(64) NOT NULL,
+  user_id UUID NOT NULL,
+  changes JSONB NOT NULL DEFAULT '{}',
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_user_id ON audit_log (user_id, id DESC);
+
+-- +goose Down
+DROP TABLE IF EXISTS audit_log;
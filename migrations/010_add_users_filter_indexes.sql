@@ -0,0 +1,8 @@
+-- +goose Up
+-- Support the ListUsers filters: nickname prefixes (LIKE 'prefix%') and creation ranges.
+CREATE INDEX IF NOT EXISTS idx_users_nickname_pattern ON users (nickname text_pattern_ops);
+CREATE INDEX IF NOT EXISTS idx_users_created_at ON users (created_at);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_users_created_at;
+DROP INDEX IF EXISTS idx_users_nickname_pattern;
@@ -0,0 +1,10 @@
+-- +goose Up
+-- The 'simple' configuration doesn't stem the words, which suits names.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS search_vector TSVECTOR
+  GENERATED ALWAYS AS (to_tsvector('simple', first_name || ' ' || last_name || ' ' || nickname)) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_users_search_vector ON users USING GIN (search_vector);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_users_search_vector;
+ALTER TABLE users DROP COLUMN IF EXISTS search_vector;
@@ -0,0 +1,12 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS user_field_locks (
+  user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+  field VARCHAR(64) NOT NULL,
+  reason VARCHAR(512) NOT NULL DEFAULT '',
+  locked_by VARCHAR(256) NOT NULL,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+  PRIMARY KEY (user_id, field)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS user_field_locks;
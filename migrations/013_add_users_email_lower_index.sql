@@ -0,0 +1,6 @@
+-- +goose Up
+-- Support the ListUsers email filter, which ignores case (lower(email) = lower($1)).
+CREATE INDEX IF NOT EXISTS idx_users_email_lower ON users (lower(email));
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_users_email_lower;
@@ -0,0 +1,24 @@
+-- +goose Up
+-- The emails and nicknames are unique per uniqueness key, set by the repository from the
+-- uniqueness scope: empty when they are globally unique, or the country of the user.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS uniqueness_key VARCHAR(256) NOT NULL DEFAULT '';
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_email_key,
+  ADD CONSTRAINT users_email_key UNIQUE (uniqueness_key, email);
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_nickname_key,
+  ADD CONSTRAINT users_nickname_key UNIQUE (uniqueness_key, nickname);
+
+-- The lookups by email don't know the uniqueness key.
+CREATE INDEX IF NOT EXISTS idx_users_email ON users (email);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_users_email;
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_nickname_key,
+  ADD CONSTRAINT users_nickname_key UNIQUE (nickname);
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_email_key,
+  ADD CONSTRAINT users_email_key UNIQUE (email);
+
+ALTER TABLE users DROP COLUMN IF EXISTS uniqueness_key;
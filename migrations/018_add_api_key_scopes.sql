@@ -0,0 +1,6 @@
+-- +goose Up
+-- The scopes of a key, separated by spaces. The keys created before have none and are unrestricted.
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS scopes TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE api_keys DROP COLUMN IF EXISTS scopes;
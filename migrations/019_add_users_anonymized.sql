@@ -0,0 +1,7 @@
+-- +goose Up
+-- Anonymized users keep their row, for the references to their id, but their personal data
+-- is overwritten and they can't be updated anymore.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS anonymized BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN IF EXISTS anonymized;
@@ -0,0 +1,7 @@
+-- +goose Up
+-- The status of the users in their lifecycle. Suspended and deactivated users can't sign in.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'active'
+  CHECK (status IN ('active', 'suspended', 'deactivated'));
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN IF EXISTS status;
@@ -0,0 +1,6 @@
+-- +goose Up
+-- Small key/value attributes attached to the users by the product teams, validated by the service.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS metadata JSONB NOT NULL DEFAULT '{}';
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN IF EXISTS metadata;
@@ -0,0 +1,9 @@
+-- +goose Up
+-- The avatar images are kept in a blob store: the users only reference them by key,
+-- with the SHA-256 of their content. Both are empty for the users without avatar.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS avatar_key TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS avatar_hash TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN IF EXISTS avatar_hash;
+ALTER TABLE users DROP COLUMN IF EXISTS avatar_key;
@@ -0,0 +1,20 @@
+-- +goose Up
+-- The phone numbers are stored in E.164 format, empty for the users without phone.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS phone TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS phone_verified BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- The pending verification of the phone of a user: a single one per user, replaced
+-- by every new code sent. Only a hash of the code is stored.
+CREATE TABLE IF NOT EXISTS phone_verifications (
+  user_id UUID PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+  phone TEXT NOT NULL,
+  code_hash BYTEA NOT NULL,
+  attempts INTEGER NOT NULL DEFAULT 0,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+  expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS phone_verifications;
+ALTER TABLE users DROP COLUMN IF EXISTS phone_verified;
+ALTER TABLE users DROP COLUMN IF EXISTS phone;
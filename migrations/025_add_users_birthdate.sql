@@ -0,0 +1,6 @@
+-- +goose Up
+-- The birthdates are optional, NULL for the users who didn't give theirs.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS birthdate DATE;
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN IF EXISTS birthdate;
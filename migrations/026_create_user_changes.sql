@@ -0,0 +1,15 @@
+-- +goose Up
+-- The user id has no foreign key: the deletions are recorded too. The snapshot is the
+-- user after the change, NULL for the deletions.
+CREATE TABLE IF NOT EXISTS user_changes (
+  id BIGSERIAL PRIMARY KEY,
+  type VARCHAR(16) NOT NULL,
+  user_id UUID NOT NULL,
+  snapshot JSONB,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_changes_user_id ON user_changes (user_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS user_changes;
@@ -0,0 +1,11 @@
+-- +goose Up
+-- The users stored before the tenants belong to the default one, whose uniqueness keys
+-- are left as they were. The keys of the other tenants are prefixed with their id, so
+-- the emails and nicknames are unique per tenant.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(63) NOT NULL DEFAULT 'default';
+
+CREATE INDEX IF NOT EXISTS idx_users_tenant_id ON users (tenant_id, id);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_users_tenant_id;
+ALTER TABLE users DROP COLUMN IF EXISTS tenant_id;
@@ -0,0 +1,12 @@
+-- +goose Up
+-- The tenants without a row use the server defaults, like the zero values of a row.
+CREATE TABLE IF NOT EXISTS tenant_policies (
+  tenant_id VARCHAR(63) PRIMARY KEY,
+  min_password_length INTEGER NOT NULL DEFAULT 0,
+  name_separators VARCHAR(64) NOT NULL DEFAULT '',
+  min_age INTEGER NOT NULL DEFAULT 0,
+  updated_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS tenant_policies;
@@ -0,0 +1,16 @@
+-- +goose Up
+-- The users are only visible to the tenant set in app.tenant_id by the transactions of the
+-- repository (see POSTGRES_ROW_LEVEL_SECURITY), and to every tenant when it isn't set, so
+-- the deployments without it are left as they were. The policy is forced on the owner of
+-- the table too, but not on the superusers, which always bypass it.
+ALTER TABLE users ENABLE ROW LEVEL SECURITY;
+ALTER TABLE users FORCE ROW LEVEL SECURITY;
+
+CREATE POLICY users_tenant_isolation ON users
+  USING (COALESCE(current_setting('app.tenant_id', TRUE), '') IN ('', tenant_id))
+  WITH CHECK (COALESCE(current_setting('app.tenant_id', TRUE), '') IN ('', tenant_id));
+
+-- +goose Down
+DROP POLICY IF EXISTS users_tenant_isolation ON users;
+ALTER TABLE users NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE users DISABLE ROW LEVEL SECURITY;
@@ -0,0 +1,21 @@
+-- +goose Up
+-- The users are only visible to the tenant set in app.tenant_id, and to no tenant when it
+-- isn't set, so a query that isn't scoped to a tenant can't reach every tenant by mistake.
+-- The policy is no longer forced on the owner of the table: the deployments without
+-- POSTGRES_ROW_LEVEL_SECURITY connect as the owner, which bypasses it, and the ones with it
+-- connect as another role and run the unscoped queries as the owner or a role with
+-- BYPASSRLS (see POSTGRES_BYPASS_RLS_DSN).
+DROP POLICY users_tenant_isolation ON users;
+ALTER TABLE users NO FORCE ROW LEVEL SECURITY;
+
+CREATE POLICY users_tenant_isolation ON users
+  USING (tenant_id = current_setting('app.tenant_id', TRUE))
+  WITH CHECK (tenant_id = current_setting('app.tenant_id', TRUE));
+
+-- +goose Down
+DROP POLICY users_tenant_isolation ON users;
+ALTER TABLE users FORCE ROW LEVEL SECURITY;
+
+CREATE POLICY users_tenant_isolation ON users
+  USING (COALESCE(current_setting('app.tenant_id', TRUE), '') IN ('', tenant_id))
+  WITH CHECK (COALESCE(current_setting('app.tenant_id', TRUE), '') IN ('', tenant_id));
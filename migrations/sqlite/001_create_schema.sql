@@ -0,0 +1,108 @@
+-- +goose Up
+-- The SQLite schema mirrors the Postgres migrations up to 013, for embedded and CI usage.
+-- Timestamps are stored as text in UTC, so they compare and sort in their text form.
+CREATE TABLE IF NOT EXISTS users (
+  id TEXT PRIMARY KEY,
+  first_name TEXT NOT NULL,
+  last_name TEXT NOT NULL,
+  nickname TEXT NOT NULL,
+  password TEXT NOT NULL,
+  email TEXT NOT NULL,
+  country TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  event_sequence INTEGER NOT NULL DEFAULT 1,
+  role TEXT NOT NULL DEFAULT 'user',
+  CONSTRAINT users_email_key UNIQUE (email),
+  CONSTRAINT users_nickname_key UNIQUE (nickname)
+);
+
+CREATE INDEX IF NOT EXISTS idx_users_country ON users (country);
+CREATE INDEX IF NOT EXISTS idx_users_nickname ON users (nickname);
+CREATE INDEX IF NOT EXISTS idx_users_created_at ON users (created_at);
+CREATE INDEX IF NOT EXISTS idx_users_email_lower ON users (lower(email));
+
+CREATE TABLE IF NOT EXISTS user_tombstones (
+  id TEXT PRIMARY KEY,
+  merged_into TEXT NOT NULL,
+  first_name TEXT NOT NULL,
+  last_name TEXT NOT NULL,
+  nickname TEXT NOT NULL,
+  email TEXT NOT NULL,
+  country TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  merged_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_tombstones_merged_into ON user_tombstones (merged_into);
+
+CREATE TABLE IF NOT EXISTS api_keys (
+  id TEXT PRIMARY KEY,
+  user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+  name TEXT NOT NULL,
+  secret_hash BLOB NOT NULL,
+  created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON api_keys (user_id);
+
+CREATE TABLE IF NOT EXISTS linked_identities (
+  provider TEXT NOT NULL,
+  subject TEXT NOT NULL,
+  user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+  email TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (provider, subject)
+);
+
+CREATE INDEX IF NOT EXISTS idx_linked_identities_user_id ON linked_identities (user_id);
+
+CREATE TABLE IF NOT EXISTS user_logins (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+  ip TEXT NOT NULL DEFAULT '',
+  country TEXT NOT NULL DEFAULT '',
+  asn INTEGER NOT NULL DEFAULT 0,
+  created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_logins_user_id_created_at ON user_logins (user_id, created_at DESC);
+
+CREATE TABLE IF NOT EXISTS password_reset_tokens (
+  token_hash BLOB PRIMARY KEY,
+  user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+  created_at TIMESTAMP NOT NULL,
+  expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_user_id ON password_reset_tokens (user_id);
+
+CREATE TABLE IF NOT EXISTS audit_log (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  actor TEXT NOT NULL,
+  action TEXT NOT NULL,
+  user_id TEXT NOT NULL,
+  changes BLOB NOT NULL DEFAULT '{}',
+  created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_user_id ON audit_log (user_id, id DESC);
+
+CREATE TABLE IF NOT EXISTS user_field_locks (
+  user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+  field TEXT NOT NULL,
+  reason TEXT NOT NULL DEFAULT '',
+  locked_by TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (user_id, field)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS user_field_locks;
+DROP TABLE IF EXISTS audit_log;
+DROP TABLE IF EXISTS password_reset_tokens;
+DROP TABLE IF EXISTS user_logins;
+DROP TABLE IF EXISTS linked_identities;
+DROP TABLE IF EXISTS api_keys;
+DROP TABLE IF EXISTS user_tombstones;
+DROP TABLE IF EXISTS users;
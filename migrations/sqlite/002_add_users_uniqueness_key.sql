@@ -0,0 +1,78 @@
+-- +goose NO TRANSACTION
+-- +goose Up
+-- Mirrors the Postgres migration 014. SQLite can't change the constraints of a table, so the
+-- users are copied to a new table, with the foreign keys off so the other tables are kept.
+PRAGMA foreign_keys = OFF;
+
+BEGIN;
+
+CREATE TABLE users_new (
+  id TEXT PRIMARY KEY,
+  first_name TEXT NOT NULL,
+  last_name TEXT NOT NULL,
+  nickname TEXT NOT NULL,
+  password TEXT NOT NULL,
+  email TEXT NOT NULL,
+  country TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  event_sequence INTEGER NOT NULL DEFAULT 1,
+  role TEXT NOT NULL DEFAULT 'user',
+  uniqueness_key TEXT NOT NULL DEFAULT '',
+  CONSTRAINT users_email_key UNIQUE (uniqueness_key, email),
+  CONSTRAINT users_nickname_key UNIQUE (uniqueness_key, nickname)
+);
+
+INSERT INTO users_new (id, first_name, last_name, nickname, password, email, country, created_at, updated_at, event_sequence, role)
+SELECT id, first_name, last_name, nickname, password, email, country, created_at, updated_at, event_sequence, role FROM users;
+
+DROP TABLE users;
+
+ALTER TABLE users_new RENAME TO users;
+
+CREATE INDEX IF NOT EXISTS idx_users_country ON users (country);
+CREATE INDEX IF NOT EXISTS idx_users_nickname ON users (nickname);
+CREATE INDEX IF NOT EXISTS idx_users_created_at ON users (created_at);
+CREATE INDEX IF NOT EXISTS idx_users_email_lower ON users (lower(email));
+CREATE INDEX IF NOT EXISTS idx_users_email ON users (email);
+
+COMMIT;
+
+PRAGMA foreign_keys = ON;
+
+-- +goose Down
+PRAGMA foreign_keys = OFF;
+
+BEGIN;
+
+CREATE TABLE users_old (
+  id TEXT PRIMARY KEY,
+  first_name TEXT NOT NULL,
+  last_name TEXT NOT NULL,
+  nickname TEXT NOT NULL,
+  password TEXT NOT NULL,
+  email TEXT NOT NULL,
+  country TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  event_sequence INTEGER NOT NULL DEFAULT 1,
+  role TEXT NOT NULL DEFAULT 'user',
+  CONSTRAINT users_email_key UNIQUE (email),
+  CONSTRAINT users_nickname_key UNIQUE (nickname)
+);
+
+INSERT INTO users_old (id, first_name, last_name, nickname, password, email, country, created_at, updated_at, event_sequence, role)
+SELECT id, first_name, last_name, nickname, password, email, country, created_at, updated_at, event_sequence, role FROM users;
+
+DROP TABLE users;
+
+ALTER TABLE users_old RENAME TO users;
+
+CREATE INDEX IF NOT EXISTS idx_users_country ON users (country);
+CREATE INDEX IF NOT EXISTS idx_users_nickname ON users (nickname);
+CREATE INDEX IF NOT EXISTS idx_users_created_at ON users (created_at);
+CREATE INDEX IF NOT EXISTS idx_users_email_lower ON users (lower(email));
+
+COMMIT;
+
+PRAGMA foreign_keys = ON;
@@ -0,0 +1,11 @@
+-- +goose Up
+-- Mirrors the Postgres migration 015.
+CREATE TABLE IF NOT EXISTS user_lockouts (
+  user_id TEXT PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+  failed_attempts INTEGER NOT NULL DEFAULT 0,
+  lockouts INTEGER NOT NULL DEFAULT 0,
+  locked_until TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS user_lockouts;
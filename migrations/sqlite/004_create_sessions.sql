@@ -0,0 +1,18 @@
+-- +goose Up
+-- Mirrors the Postgres migration 016.
+CREATE TABLE IF NOT EXISTS sessions (
+  id TEXT PRIMARY KEY,
+  user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+  access_token_hash BLOB NOT NULL,
+  refresh_token_hash BLOB NOT NULL,
+  ip TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMP NOT NULL,
+  refreshed_at TIMESTAMP NOT NULL,
+  access_token_expires_at TIMESTAMP NOT NULL,
+  expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions (user_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS sessions;
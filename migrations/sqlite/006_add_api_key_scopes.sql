@@ -0,0 +1,6 @@
+-- +goose Up
+-- Mirrors the Postgres migration 018.
+ALTER TABLE api_keys ADD COLUMN scopes TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE api_keys DROP COLUMN scopes;
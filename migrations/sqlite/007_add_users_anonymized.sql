@@ -0,0 +1,6 @@
+-- +goose Up
+-- Mirrors the Postgres migration 019.
+ALTER TABLE users ADD COLUMN anonymized BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN anonymized;
@@ -0,0 +1,7 @@
+-- +goose Up
+-- Mirrors the Postgres migration 020.
+ALTER TABLE users ADD COLUMN status TEXT NOT NULL DEFAULT 'active'
+  CHECK (status IN ('active', 'suspended', 'deactivated'));
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN status;
@@ -0,0 +1,6 @@
+-- +goose Up
+-- Mirrors the Postgres migration 021, with the metadata encoded as a JSON object.
+ALTER TABLE users ADD COLUMN metadata TEXT NOT NULL DEFAULT '{}';
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN metadata;
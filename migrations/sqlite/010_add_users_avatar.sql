@@ -0,0 +1,8 @@
+-- +goose Up
+-- Mirrors the Postgres migration 022.
+ALTER TABLE users ADD COLUMN avatar_key TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN avatar_hash TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN avatar_hash;
+ALTER TABLE users DROP COLUMN avatar_key;
@@ -0,0 +1,6 @@
+-- +goose Up
+-- Mirrors the Postgres migration 025.
+ALTER TABLE users ADD COLUMN birthdate DATE;
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN birthdate;
@@ -0,0 +1,14 @@
+-- +goose Up
+-- Mirrors the Postgres migration 026.
+CREATE TABLE IF NOT EXISTS user_changes (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  type TEXT NOT NULL,
+  user_id TEXT NOT NULL,
+  snapshot BLOB,
+  created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_changes_user_id ON user_changes (user_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS user_changes;
@@ -0,0 +1,9 @@
+-- +goose Up
+-- Mirrors the Postgres migration 027.
+ALTER TABLE users ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default';
+
+CREATE INDEX IF NOT EXISTS idx_users_tenant_id ON users (tenant_id, id);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_users_tenant_id;
+ALTER TABLE users DROP COLUMN tenant_id;
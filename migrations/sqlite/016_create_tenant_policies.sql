@@ -0,0 +1,12 @@
+-- +goose Up
+-- Mirrors the Postgres migration 028.
+CREATE TABLE IF NOT EXISTS tenant_policies (
+  tenant_id TEXT PRIMARY KEY,
+  min_password_length INTEGER NOT NULL DEFAULT 0,
+  name_separators TEXT NOT NULL DEFAULT '',
+  min_age INTEGER NOT NULL DEFAULT 0,
+  updated_at TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS tenant_policies;
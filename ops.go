@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"os"
+	"sync"
+
+	"github.com/alesr/usrsvc/pkg/events"
+	"go.uber.org/zap"
+)
+
+// opsEvents publishes the lifecycle events of the instance on the ops stream.
+// The events are keyed by instance and sequenced, so the platform tooling can
+// tell when it missed a transition.
+type opsEvents struct {
+	logger    *zap.Logger
+	publisher events.Publisher
+	instance  string
+
+	mu       sync.Mutex
+	sequence int64
+}
+
+// newOpsEvents returns the ops events of this instance, identified by its hostname.
+func newOpsEvents(logger *zap.Logger, publisher events.Publisher) *opsEvents {
+	instance, err := os.Hostname()
+	if err != nil {
+		instance = serviceName
+	}
+
+	return &opsEvents{
+		logger:    logger,
+		publisher: publisher,
+		instance:  instance,
+	}
+}
+
+// publish publishes the event. Failures are only logged: they must never affect the instance.
+func (o *opsEvents) publish(event events.Event, data events.OpsData) {
+	data.Instance = o.instance
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.sequence++
+	if err := o.publisher.Publish(context.Background(), event, events.Ordered{Key: o.instance, Sequence: o.sequence, Data: data}); err != nil {
+		o.logger.Error("failed to publish ops event", zap.String("event", string(event)), zap.Error(err))
+	}
+}
+
+// maintenanceSwitch is the maintenance mode switch of the admin UI.
+type maintenanceSwitch interface {
+	Enabled() bool
+	SetEnabled(enabled bool)
+}
+
+// opsMaintenance publishes the read-only mode transitions of the maintenance switch.
+type opsMaintenance struct {
+	maintenanceSwitch
+	ops *opsEvents
+}
+
+// SetEnabled turns maintenance mode on or off and publishes the transition, if any.
+func (m *opsMaintenance) SetEnabled(enabled bool) {
+	if m.Enabled() == enabled {
+		return
+	}
+
+	m.maintenanceSwitch.SetEnabled(enabled)
+
+	if enabled {
+		m.ops.publish(events.OpsReadOnlyEntered, events.OpsData{})
+		return
+	}
+	m.ops.publish(events.OpsReadOnlyExited, events.OpsData{})
+}
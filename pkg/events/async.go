@@ -0,0 +1,202 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultAsyncQueueSize      int           = 1024
+	defaultAsyncMaxAttempts    int           = 5
+	defaultAsyncInitialBackoff time.Duration = 100 * time.Millisecond
+	defaultAsyncMaxBackoff     time.Duration = 10 * time.Second
+)
+
+var (
+	// ErrQueueFull is returned by Async.Publish when the queue is full. The event is dead-lettered.
+	ErrQueueFull = errors.New("event queue is full")
+
+	// ErrPublisherClosed is returned by Async.Publish after Close. The event is dead-lettered.
+	ErrPublisherClosed = errors.New("publisher is closed")
+)
+
+// DeadLetterFunc receives the envelopes that could not be published, with the last error.
+type DeadLetterFunc func(env *Envelope, err error)
+
+// AsyncConfig configures an Async publisher. Zero values use the defaults.
+type AsyncConfig struct {
+	// QueueSize bounds the number of events waiting to be published. Defaults to 1024.
+	QueueSize int
+
+	// MaxAttempts bounds the publish attempts of an event. Defaults to 5.
+	MaxAttempts int
+
+	// InitialBackoff is the wait before the first retry, doubled on each retry
+	// up to MaxBackoff. Defaults to 100ms and 10s.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+
+	// DeadLetter receives the events dropped after MaxAttempts or because the queue was full.
+	DeadLetter DeadLetterFunc
+}
+
+// Async publishes the events to the next publisher from a background worker, so a slow or
+// unavailable broker doesn't block the callers. Failed publishes are retried with exponential
+// backoff; events that still fail are handed to the dead-letter function.
+//
+// The envelope is built when the event is queued and passed to the next publisher, so
+// retries keep the envelope ID and consumers can discard the duplicates. It carries the
+// trace of the caller, but not its deadline: the events outlive the requests that
+// published them. Events are published in order by a single worker.
+type Async struct {
+	next Publisher
+	cfg  AsyncConfig
+
+	mu     sync.RWMutex
+	queue  chan *Envelope
+	closed bool
+	done   chan struct{}
+	stop   chan struct{}
+
+	deadLettered atomic.Uint64
+}
+
+// NewAsync starts the worker publishing to next. Close must be called to stop it.
+func NewAsync(next Publisher, cfg AsyncConfig) *Async {
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = defaultAsyncQueueSize
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = defaultAsyncMaxAttempts
+	}
+	if cfg.InitialBackoff <= 0 {
+		cfg.InitialBackoff = defaultAsyncInitialBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = defaultAsyncMaxBackoff
+	}
+	if cfg.DeadLetter == nil {
+		cfg.DeadLetter = func(*Envelope, error) {}
+	}
+
+	a := Async{
+		next:  next,
+		cfg:   cfg,
+		queue: make(chan *Envelope, cfg.QueueSize),
+		done:  make(chan struct{}),
+		stop:  make(chan struct{}),
+	}
+
+	go a.run()
+	return &a
+}
+
+// Publish queues the event without blocking. It fails with ErrQueueFull when the
+// queue is full and with ErrPublisherClosed after Close.
+func (a *Async) Publish(ctx context.Context, event Event, data any) error {
+	env, err := NewEnvelope(ctx, event, data)
+	if err != nil {
+		return err
+	}
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if a.closed {
+		a.deadLetter(env, ErrPublisherClosed)
+		return ErrPublisherClosed
+	}
+
+	select {
+	case a.queue <- env:
+		return nil
+	default:
+		a.deadLetter(env, ErrQueueFull)
+		return ErrQueueFull
+	}
+}
+
+// Pending returns the number of queued events.
+func (a *Async) Pending() int {
+	return len(a.queue)
+}
+
+// DeadLettered returns the number of events handed to the dead-letter function.
+func (a *Async) DeadLettered() uint64 {
+	return a.deadLettered.Load()
+}
+
+func (a *Async) deadLetter(env *Envelope, err error) {
+	a.deadLettered.Add(1)
+	a.cfg.DeadLetter(env, err)
+}
+
+// Close stops accepting events and waits for the queued ones to be published.
+// Once the deadline passes, retries stop and the remaining events are dead-lettered.
+func (a *Async) Close(deadline time.Duration) {
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return
+	}
+	a.closed = true
+	close(a.queue)
+	a.mu.Unlock()
+
+	timer := time.NewTimer(deadline)
+	defer timer.Stop()
+
+	select {
+	case <-a.done:
+	case <-timer.C:
+		close(a.stop)
+		<-a.done
+	}
+}
+
+func (a *Async) run() {
+	defer close(a.done)
+
+	for env := range a.queue {
+		select {
+		case <-a.stop:
+			a.deadLetter(env, ErrPublisherClosed)
+			continue
+		default:
+		}
+
+		if err := a.publish(env); err != nil {
+			a.deadLetter(env, err)
+		}
+	}
+}
+
+// publish publishes the envelope, retrying until it succeeds, MaxAttempts is
+// reached or Close gives up.
+func (a *Async) publish(env *Envelope) error {
+	backoff := a.cfg.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		err := a.next.Publish(env.Context(context.Background()), env.Event, env)
+		if err == nil {
+			return nil
+		}
+
+		if attempt >= a.cfg.MaxAttempts {
+			return err
+		}
+
+		select {
+		case <-a.stop:
+			return err
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > a.cfg.MaxBackoff {
+			backoff = a.cfg.MaxBackoff
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// deadLetters records the dead-lettered envelopes.
+type deadLetters struct {
+	mu   sync.Mutex
+	envs []*Envelope
+	errs []error
+}
+
+func (d *deadLetters) record(env *Envelope, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.envs = append(d.envs, env)
+	d.errs = append(d.errs, err)
+}
+
+func TestAsync(t *testing.T) {
+	t.Run("retries with the same envelope until it is published", func(t *testing.T) {
+		// Arrange
+		var (
+			mu       sync.Mutex
+			attempts []*Envelope
+		)
+		next := publisherFunc(func(ctx context.Context, event Event, data any) error {
+			mu.Lock()
+			defer mu.Unlock()
+
+			env, err := NewEnvelope(ctx, event, data)
+			require.NoError(t, err)
+
+			attempts = append(attempts, env)
+			if len(attempts) < 3 {
+				return errors.New("broker unavailable")
+			}
+			return nil
+		})
+
+		var dead deadLetters
+		async := NewAsync(next, AsyncConfig{InitialBackoff: time.Millisecond, DeadLetter: dead.record})
+
+		// Act
+		err := async.Publish(context.TODO(), UserCreated, Ordered{Key: "some-id", Sequence: 1, Data: "some-id"})
+		async.Close(time.Second)
+
+		// Assert
+		require.NoError(t, err)
+		require.Len(t, attempts, 3)
+		assert.Equal(t, attempts[0].ID, attempts[2].ID)
+		assert.Equal(t, "some-id", attempts[2].Key)
+		assert.Empty(t, dead.envs)
+	})
+
+	t.Run("dead-letters the events that keep failing", func(t *testing.T) {
+		// Arrange
+		brokerErr := errors.New("broker unavailable")
+		next := publisherFunc(func(ctx context.Context, event Event, data any) error {
+			return brokerErr
+		})
+
+		var dead deadLetters
+		async := NewAsync(next, AsyncConfig{MaxAttempts: 2, InitialBackoff: time.Millisecond, DeadLetter: dead.record})
+
+		// Act
+		require.NoError(t, async.Publish(context.TODO(), UserDeleted, "some-id"))
+		async.Close(time.Second)
+
+		// Assert
+		require.Len(t, dead.envs, 1)
+		assert.Equal(t, UserDeleted, dead.envs[0].Event)
+		assert.Equal(t, brokerErr, dead.errs[0])
+		assert.Equal(t, uint64(1), async.DeadLettered())
+	})
+
+	t.Run("does not block when the queue is full", func(t *testing.T) {
+		// Arrange
+		release := make(chan struct{})
+		next := publisherFunc(func(ctx context.Context, event Event, data any) error {
+			<-release
+			return nil
+		})
+
+		var dead deadLetters
+		async := NewAsync(next, AsyncConfig{QueueSize: 1, DeadLetter: dead.record})
+
+		// The worker holds the first event and the second one fills the queue.
+		require.NoError(t, async.Publish(context.TODO(), UserCreated, "first"))
+		require.Eventually(t, func() bool { return async.Pending() == 0 }, time.Second, time.Millisecond)
+		require.NoError(t, async.Publish(context.TODO(), UserCreated, "second"))
+
+		// Act
+		err := async.Publish(context.TODO(), UserCreated, "third")
+
+		// Assert
+		assert.True(t, errors.Is(err, ErrQueueFull))
+		require.Len(t, dead.envs, 1)
+
+		close(release)
+		async.Close(time.Second)
+		assert.Len(t, dead.envs, 1)
+	})
+
+	t.Run("dead-letters the queued events after the close deadline", func(t *testing.T) {
+		// Arrange
+		next := publisherFunc(func(ctx context.Context, event Event, data any) error {
+			return errors.New("broker unavailable")
+		})
+
+		var dead deadLetters
+		async := NewAsync(next, AsyncConfig{InitialBackoff: time.Hour, DeadLetter: dead.record})
+
+		require.NoError(t, async.Publish(context.TODO(), UserCreated, "first"))
+		require.NoError(t, async.Publish(context.TODO(), UserCreated, "second"))
+
+		// Act
+		async.Close(10 * time.Millisecond)
+		err := async.Publish(context.TODO(), UserCreated, "third")
+
+		// Assert
+		assert.True(t, errors.Is(err, ErrPublisherClosed))
+		assert.Len(t, dead.envs, 3)
+	})
+}
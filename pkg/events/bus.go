@@ -0,0 +1,165 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	_ Publisher  = (*Bus)(nil)
+	_ Subscriber = (*Bus)(nil)
+	_ Consumer   = (*Bus)(nil)
+)
+
+// ErrBusClosed is returned when publishing to or subscribing to a closed bus.
+var ErrBusClosed = errors.New("event bus closed")
+
+// Subscriber is implemented by the backends that deliver the published events
+// in process, e.g. to stream them to clients.
+type Subscriber interface {
+	// Subscribe returns a channel receiving the envelopes of the given events
+	// (all events when none is given), published after the call. The channel is
+	// closed when ctx is done or the subscriber is closed.
+	Subscribe(ctx context.Context, events ...Event) (<-chan *Envelope, error)
+}
+
+// Bus is an in-process publisher and subscriber, for single binary deployments and tests.
+// Every subscription has its own buffer, so a slow subscriber doesn't block the publishers
+// or the other subscribers: when its buffer is full, the envelope is dropped for it.
+// It is safe for concurrent use.
+type Bus struct {
+	bufferSize int
+	dropped    atomic.Int64
+
+	mu     sync.RWMutex
+	closed bool
+	done   chan struct{}
+	subs   map[*subscription]struct{}
+}
+
+type subscription struct {
+	events map[Event]bool
+	ch     chan *Envelope
+}
+
+// NewBus creates an event bus buffering up to bufferSize envelopes per subscription.
+func NewBus(bufferSize int) *Bus {
+	if bufferSize < 0 {
+		bufferSize = 0
+	}
+	return &Bus{
+		bufferSize: bufferSize,
+		done:       make(chan struct{}),
+		subs:       make(map[*subscription]struct{}),
+	}
+}
+
+// Publish wraps the event data into an envelope and delivers it to the subscriptions
+// matching the event. The data is marshaled once, whatever the number of subscriptions.
+func (b *Bus) Publish(ctx context.Context, event Event, data any) error {
+	env, err := NewEnvelope(ctx, event, data)
+	if err != nil {
+		return err
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if b.closed {
+		return ErrBusClosed
+	}
+
+	for sub := range b.subs {
+		if len(sub.events) > 0 && !sub.events[event] {
+			continue
+		}
+
+		select {
+		case sub.ch <- env:
+		default:
+			b.dropped.Add(1)
+		}
+	}
+	return nil
+}
+
+// Subscribe implements Subscriber.
+func (b *Bus) Subscribe(ctx context.Context, events ...Event) (<-chan *Envelope, error) {
+	sub := subscription{
+		events: make(map[Event]bool, len(events)),
+		ch:     make(chan *Envelope, b.bufferSize),
+	}
+	for _, event := range events {
+		sub.events[event] = true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil, ErrBusClosed
+	}
+	b.subs[&sub] = struct{}{}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			b.unsubscribe(&sub)
+		case <-b.done:
+		}
+	}()
+	return sub.ch, nil
+}
+
+// Consume implements Consumer. The bus doesn't redeliver: an envelope the handler fails
+// to handle is dropped. It returns ErrBusClosed when the bus is closed.
+func (b *Bus) Consume(ctx context.Context, handle Handler, events ...Event) error {
+	ch, err := b.Subscribe(ctx, events...)
+	if err != nil {
+		return err
+	}
+
+	for env := range ch {
+		_ = handle(env.Context(ctx), env)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return ErrBusClosed
+}
+
+func (b *Bus) unsubscribe(sub *subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subs[sub]; ok {
+		delete(b.subs, sub)
+		close(sub.ch)
+	}
+}
+
+// Dropped returns the number of envelopes dropped because a subscription buffer was full.
+func (b *Bus) Dropped() int64 {
+	return b.dropped.Load()
+}
+
+// Close closes every subscription. Publishing after Close fails with ErrBusClosed.
+func (b *Bus) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	close(b.done)
+
+	for sub := range b.subs {
+		delete(b.subs, sub)
+		close(sub.ch)
+	}
+	return nil
+}
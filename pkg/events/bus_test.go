@@ -0,0 +1,175 @@
+package events_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alesr/usrsvc/pkg/events"
+	"github.com/alesr/usrsvc/pkg/events/eventstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBus_Conformance(t *testing.T) {
+	eventstest.RunPublisherSuite(t, func(t *testing.T) *eventstest.Harness {
+		bus := events.NewBus(100)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+
+		ch, err := bus.Subscribe(ctx)
+		require.NoError(t, err)
+
+		return &eventstest.Harness{
+			Publisher: bus,
+			Receive: func(ctx context.Context) (*events.Envelope, error) {
+				select {
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				case env := <-ch:
+					return env, nil
+				}
+			},
+			Close:   bus.Close,
+			Timeout: time.Second,
+		}
+	})
+}
+
+func TestBus(t *testing.T) {
+	t.Run("delivers every event to every subscription", func(t *testing.T) {
+		// Arrange
+		bus := events.NewBus(10)
+		defer bus.Close()
+
+		first, err := bus.Subscribe(context.Background())
+		require.NoError(t, err)
+
+		second, err := bus.Subscribe(context.Background())
+		require.NoError(t, err)
+
+		// Act
+		err = bus.Publish(context.TODO(), events.UserCreated, "some-id")
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, events.UserCreated, (<-first).Event)
+		assert.Equal(t, events.UserCreated, (<-second).Event)
+	})
+
+	t.Run("delivers only the subscribed events", func(t *testing.T) {
+		// Arrange
+		bus := events.NewBus(10)
+		defer bus.Close()
+
+		ch, err := bus.Subscribe(context.Background(), events.UserDeleted)
+		require.NoError(t, err)
+
+		// Act
+		require.NoError(t, bus.Publish(context.TODO(), events.UserCreated, "some-id"))
+		require.NoError(t, bus.Publish(context.TODO(), events.UserDeleted, "some-id"))
+
+		// Assert
+		assert.Equal(t, events.UserDeleted, (<-ch).Event)
+		assert.Empty(t, ch)
+	})
+
+	t.Run("drops events when the subscription buffer is full", func(t *testing.T) {
+		// Arrange
+		bus := events.NewBus(1)
+		defer bus.Close()
+
+		ch, err := bus.Subscribe(context.Background())
+		require.NoError(t, err)
+
+		// Act
+		require.NoError(t, bus.Publish(context.TODO(), events.UserCreated, "first-id"))
+		require.NoError(t, bus.Publish(context.TODO(), events.UserCreated, "second-id"))
+
+		// Assert
+		assert.Len(t, ch, 1)
+		assert.Equal(t, int64(1), bus.Dropped())
+	})
+
+	t.Run("closes the subscription when the context is done", func(t *testing.T) {
+		// Arrange
+		bus := events.NewBus(10)
+		defer bus.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		ch, err := bus.Subscribe(ctx)
+		require.NoError(t, err)
+
+		// Act
+		cancel()
+
+		// Assert
+		select {
+		case _, ok := <-ch:
+			assert.False(t, ok)
+		case <-time.After(time.Second):
+			t.Fatal("subscription was not closed")
+		}
+	})
+
+	t.Run("closes the subscriptions and rejects publishing when closed", func(t *testing.T) {
+		// Arrange
+		bus := events.NewBus(10)
+
+		ch, err := bus.Subscribe(context.Background())
+		require.NoError(t, err)
+
+		// Act
+		require.NoError(t, bus.Close())
+
+		// Assert
+		_, ok := <-ch
+		assert.False(t, ok)
+		assert.ErrorIs(t, bus.Publish(context.TODO(), events.UserCreated, "some-id"), events.ErrBusClosed)
+
+		_, err = bus.Subscribe(context.Background())
+		assert.ErrorIs(t, err, events.ErrBusClosed)
+	})
+
+	t.Run("consumes the given events until closed", func(t *testing.T) {
+		// Arrange
+		bus := events.NewBus(10)
+
+		received := make(chan *events.Envelope, 10)
+		done := make(chan error, 1)
+
+		// Act
+		go func() {
+			done <- bus.Consume(context.Background(), func(ctx context.Context, env *events.Envelope) error {
+				received <- env
+				return nil
+			}, events.UserDeleteRequested)
+		}()
+
+		// Assert
+		// The events published before Consume subscribed are lost, publish until one is received.
+		var env *events.Envelope
+		require.Eventually(t, func() bool {
+			require.NoError(t, bus.Publish(context.TODO(), events.UserCreated, "some-id"))
+			require.NoError(t, bus.Publish(context.TODO(), events.UserDeleteRequested, events.UserCommandData{UserID: "some-id"}))
+
+			select {
+			case env = <-received:
+				return true
+			default:
+				return false
+			}
+		}, time.Second, 10*time.Millisecond)
+
+		assert.Equal(t, events.UserDeleteRequested, env.Event)
+
+		require.NoError(t, bus.Close())
+		assert.ErrorIs(t, <-done, events.ErrBusClosed)
+
+		close(received)
+		for env := range received {
+			assert.Equal(t, events.UserDeleteRequested, env.Event)
+		}
+	})
+}
@@ -0,0 +1,46 @@
+package events
+
+import (
+	"context"
+	"errors"
+)
+
+// Handler handles an envelope delivered by a Consumer. Its context continues the trace
+// of the publisher (see Envelope.Context). When it fails, the envelope is delivered
+// again, unless the error is marked with Permanent.
+type Handler func(ctx context.Context, env *Envelope) error
+
+// Consumer is implemented by the backends that deliver the events published by other
+// services, e.g. the commands sent to usrsvc (see UserDeleteRequested).
+type Consumer interface {
+	// Consume calls handle with the envelopes of the given events, one at a time, until
+	// ctx is done or the backend fails. An envelope is acknowledged once handle returns.
+	Consume(ctx context.Context, handle Handler, events ...Event) error
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent marks the error of a Handler as permanent, e.g. for a malformed envelope,
+// so the envelope is discarded instead of delivered again.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent reports whether the error was marked with Permanent.
+func IsPermanent(err error) bool {
+	var permanent *permanentError
+	return errors.As(err, &permanent)
+}
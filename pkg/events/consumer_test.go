@@ -0,0 +1,35 @@
+package events_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/alesr/usrsvc/pkg/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPermanent(t *testing.T) {
+	errMalformed := errors.New("malformed command")
+
+	testCases := []struct {
+		name     string
+		given    error
+		expected bool
+	}{
+		{name: "nil", given: events.Permanent(nil), expected: false},
+		{name: "not marked", given: errMalformed, expected: false},
+		{name: "marked", given: events.Permanent(errMalformed), expected: true},
+		{name: "wrapped", given: fmt.Errorf("could not handle: %w", events.Permanent(errMalformed)), expected: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, events.IsPermanent(tc.given))
+
+			if tc.given != nil {
+				assert.ErrorIs(t, tc.given, errMalformed)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Publisher is the interface implemented by the message broker backends.
+type Publisher interface {
+	// Publish publishes the event. The trace of ctx is propagated with the envelope
+	// (see Envelope.Metadata), and the backends waiting for the broker give up when
+	// ctx is done.
+	Publish(ctx context.Context, event Event, data any) error
+}
+
+// Envelope is the wire format shared by every publisher backend.
+// Consumers rely on the ID to deduplicate at-least-once deliveries.
+//
+// Events about the same entity carry its ID as Key and an increasing Sequence
+// (see Ordered), which publisher backends should use as the partition or ordering
+// key and consumers can use to detect gaps and reordering (see SequenceTracker).
+//
+// SchemaVersion is the version of the schema of the data (see Event.SchemaVersion). It
+// is missing from the envelopes published before it was introduced, which are at version 1.
+//
+// Metadata carries the trace context of the publisher, e.g. the W3C traceparent header,
+// which the publisher backends also set as message headers. Consumers continue the
+// trace with Context.
+type Envelope struct {
+	ID            string            `json:"id"`
+	Event         Event             `json:"event"`
+	SchemaVersion int               `json:"schema_version,omitempty"`
+	OccurredAt    time.Time         `json:"occurred_at"`
+	Key           string            `json:"key,omitempty"`
+	Sequence      int64             `json:"sequence,omitempty"`
+	Metadata      map[string]string `json:"metadata,omitempty"`
+	Data          json.RawMessage   `json:"data"`
+}
+
+// Ordered wraps the data of an event that is ordered among the events with the same key.
+// The sequence of the first event for a key is 1 and every following event increments it.
+type Ordered struct {
+	Key      string
+	Sequence int64
+	Data     any
+}
+
+// NewEnvelope wraps the event data into a new envelope, with the trace context of ctx
+// as metadata. When data is Ordered, its key and sequence are set on the envelope and
+// only the wrapped data is marshaled. When data is already an envelope (e.g. a retried
+// publish, see Async), it is returned as is, so it keeps its ID and trace.
+func NewEnvelope(ctx context.Context, event Event, data any) (*Envelope, error) {
+	if env, ok := data.(*Envelope); ok {
+		return env, nil
+	}
+
+	env := Envelope{
+		ID:            uuid.NewString(),
+		Event:         event,
+		SchemaVersion: event.SchemaVersion(),
+		OccurredAt:    time.Now().UTC(),
+	}
+
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	if len(carrier) > 0 {
+		env.Metadata = carrier
+	}
+
+	if ordered, ok := data.(Ordered); ok {
+		env.Key = ordered.Key
+		env.Sequence = ordered.Sequence
+		data = ordered.Data
+	}
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal event data: %w", err)
+	}
+
+	env.Data = raw
+	return &env, nil
+}
+
+// Version returns the schema version of the data, 1 when the envelope doesn't set it.
+func (e *Envelope) Version() int {
+	if e.SchemaVersion <= 0 {
+		return 1
+	}
+	return e.SchemaVersion
+}
+
+// Context returns a copy of ctx continuing the trace of the publisher, when the
+// envelope carries one.
+func (e *Envelope) Context(ctx context.Context) context.Context {
+	if len(e.Metadata) == 0 {
+		return ctx
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(e.Metadata))
+}
+
+// Decode unmarshals the envelope data into v.
+func (e *Envelope) Decode(v any) error {
+	if err := json.Unmarshal(e.Data, v); err != nil {
+		return fmt.Errorf("could not unmarshal event data: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package events
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestNewEnvelope(t *testing.T) {
+	t.Run("wraps the data", func(t *testing.T) {
+		// Act
+		env, err := NewEnvelope(context.TODO(), UserCreated, "some-id")
+		require.NoError(t, err)
+
+		// Assert
+		_, err = uuid.Parse(env.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, UserCreated, env.Event)
+		assert.Equal(t, UserCreated.SchemaVersion(), env.SchemaVersion)
+		assert.False(t, env.OccurredAt.IsZero())
+		assert.Nil(t, env.Metadata)
+
+		var data string
+		require.NoError(t, env.Decode(&data))
+		assert.Equal(t, "some-id", data)
+	})
+
+	t.Run("sets the key and sequence of ordered data", func(t *testing.T) {
+		// Act
+		env, err := NewEnvelope(context.TODO(), UserUpdated, Ordered{Key: "some-id", Sequence: 3, Data: "some-id"})
+		require.NoError(t, err)
+
+		// Assert
+		assert.Equal(t, "some-id", env.Key)
+		assert.Equal(t, int64(3), env.Sequence)
+
+		var data string
+		require.NoError(t, env.Decode(&data))
+		assert.Equal(t, "some-id", data)
+	})
+
+	t.Run("propagates the trace of the context", func(t *testing.T) {
+		// Arrange
+		propagator := otel.GetTextMapPropagator()
+		otel.SetTextMapPropagator(propagation.TraceContext{})
+		defer otel.SetTextMapPropagator(propagator)
+
+		spanContext := trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID:    trace.TraceID{1},
+			SpanID:     trace.SpanID{2},
+			TraceFlags: trace.FlagsSampled,
+		})
+		ctx := trace.ContextWithSpanContext(context.TODO(), spanContext)
+
+		// Act
+		env, err := NewEnvelope(ctx, UserCreated, "some-id")
+		require.NoError(t, err)
+
+		consumed := trace.SpanContextFromContext(env.Context(context.TODO()))
+
+		// Assert
+		assert.Equal(t, "00-01000000000000000000000000000000-0200000000000000-01", env.Metadata["traceparent"])
+		assert.Equal(t, spanContext.TraceID(), consumed.TraceID())
+		assert.Equal(t, spanContext.SpanID(), consumed.SpanID())
+		assert.True(t, consumed.IsRemote())
+	})
+
+	t.Run("fails on data that cannot be marshaled", func(t *testing.T) {
+		// Act
+		env, err := NewEnvelope(context.TODO(), UserCreated, make(chan int))
+
+		// Assert
+		assert.Error(t, err)
+		assert.Nil(t, env)
+	})
+}
+
+func TestEnvelopeVersion(t *testing.T) {
+	testCases := []struct {
+		name     string
+		env      Envelope
+		expected int
+	}{
+		{
+			name:     "set",
+			env:      Envelope{SchemaVersion: 2},
+			expected: 2,
+		},
+		{
+			name:     "missing",
+			env:      Envelope{},
+			expected: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expected, tc.env.Version())
+		})
+	}
+}
@@ -4,6 +4,8 @@
 
 package events
 
+import "time"
+
 type Event string
 
 const (
@@ -16,4 +18,173 @@ const (
 
 	// UserDeleted is the event that is published when a user is deleted.
 	UserDeleted Event = "user.deleted"
+
+	// UserMerged is the event that is published when a duplicate user is merged into another one.
+	// Its data is a UserMergedData.
+	UserMerged Event = "user.merged"
+
+	// UserSuspended is the event that is published when a user is suspended by an admin.
+	// Its data is the id of the user.
+	UserSuspended Event = "user.suspended"
+
+	// UserDeactivated is the event that is published when a user is deactivated, e.g. when
+	// they close their account. Its data is the id of the user.
+	UserDeactivated Event = "user.deactivated"
+
+	// UserReactivated is the event that is published when a suspended or deactivated user
+	// is reactivated. Its data is the id of the user.
+	UserReactivated Event = "user.reactivated"
+
+	// UserAnonymized is the event that is published when the personal data of a user is
+	// erased. The user is kept, so consumers must erase their copies of the data too.
+	UserAnonymized Event = "user.anonymized"
+
+	// UserPasswordResetRequested is the event that is published when a user requests a
+	// password reset, so the notification service can email them the reset link.
+	// Its data is a PasswordResetRequestedData.
+	UserPasswordResetRequested Event = "user.password_reset_requested"
+
+	// UserLocked is the event that is published when a user is locked out after too many
+	// failed logins. Its data is a UserLockedData.
+	UserLocked Event = "user.locked"
+
+	// Enumerate login events, published only when login tracking is enabled.
+
+	// UserAuthenticated is the event that is published when a user authenticates.
+	// Its data is a LoginData.
+	UserAuthenticated Event = "user.authenticated"
+
+	// SuspiciousLogin is the event that is published when a user authenticates from a
+	// location that deviates from their login history. Its data is a SuspiciousLoginData.
+	SuspiciousLogin Event = "user.suspicious_login"
+
+	// Enumerate inbound commands, published by other services for usrsvc to consume when
+	// the event consumer is enabled. Their data is a UserCommandData.
+
+	// UserDeleteRequested is the command to delete a user, e.g. from the GDPR service.
+	UserDeleteRequested Event = "user.delete.requested"
+
+	// UserAnonymizeRequested is the command to erase the personal data of a user but keep it,
+	// e.g. from the GDPR service when the user's records must be retained.
+	UserAnonymizeRequested Event = "user.anonymize.requested"
+
+	// Enumerate operational events, published on the ops stream (the events named ops.*)
+	// so the platform tooling can track the state of every service instance. Their data
+	// is an OpsData, ordered by instance.
+
+	// OpsStarted is the event that is published when an instance starts serving traffic.
+	OpsStarted Event = "ops.started"
+
+	// OpsMigrated is the event that is published when an instance has migrated
+	// the database on startup. The data carries the schema version.
+	OpsMigrated Event = "ops.migrated"
+
+	// OpsReadOnlyEntered is the event that is published when an instance enters
+	// maintenance mode, in which the RPCs that change data are rejected.
+	OpsReadOnlyEntered Event = "ops.read_only_entered"
+
+	// OpsReadOnlyExited is the event that is published when an instance leaves maintenance mode.
+	OpsReadOnlyExited Event = "ops.read_only_exited"
+
+	// OpsShutdownStarted is the event that is published when an instance begins to shut down.
+	OpsShutdownStarted Event = "ops.shutdown_started"
 )
+
+// schemaVersions are the versions of the schemas of the event data.
+var schemaVersions = map[Event]int{
+	UserCreated:                1,
+	UserUpdated:                1,
+	UserDeleted:                1,
+	UserMerged:                 1,
+	UserSuspended:              1,
+	UserDeactivated:            1,
+	UserReactivated:            1,
+	UserAnonymized:             1,
+	UserPasswordResetRequested: 1,
+	UserLocked:                 1,
+	UserAuthenticated:          1,
+	SuspiciousLogin:            1,
+	UserDeleteRequested:        1,
+	UserAnonymizeRequested:     1,
+	OpsStarted:                 1,
+	OpsMigrated:                1,
+	OpsReadOnlyEntered:         1,
+	OpsReadOnlyExited:          1,
+	OpsShutdownStarted:         1,
+}
+
+// SchemaVersion returns the version of the schema of the event data, set on its envelopes.
+// It starts at 1 and is incremented on every breaking change of the data, e.g. a field
+// removed, renamed or retyped; new fields do not change it. Consumers should not handle
+// the versions they don't know. The unknown events are at version 1.
+func (e Event) SchemaVersion() int {
+	if version, ok := schemaVersions[e]; ok {
+		return version
+	}
+	return 1
+}
+
+// UserMergedData is the data of the UserMerged event.
+type UserMergedData struct {
+	SurvivorID  string `json:"survivor_id"`
+	DuplicateID string `json:"duplicate_id"`
+}
+
+// PasswordResetRequestedData is the data of the UserPasswordResetRequested event.
+// The token is a secret: it must only be sent to the user's email. The email is
+// localized with the locale and the timezone of the user, when known.
+type PasswordResetRequestedData struct {
+	UserID    string    `json:"user_id"`
+	Email     string    `json:"email"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Locale    string    `json:"locale,omitempty"`
+	Timezone  string    `json:"timezone,omitempty"`
+}
+
+// UserLockedData is the data of the UserLocked event.
+type UserLockedData struct {
+	UserID         string    `json:"user_id"`
+	FailedAttempts int       `json:"failed_attempts"`
+	LockedUntil    time.Time `json:"locked_until"`
+}
+
+// LoginData is the data of the UserAuthenticated event.
+// Country and ASN are empty when the client IP could not be resolved.
+type LoginData struct {
+	UserID       string `json:"user_id"`
+	IP           string `json:"ip,omitempty"`
+	Country      string `json:"country,omitempty"`
+	ASN          int64  `json:"asn,omitempty"`
+	Organization string `json:"organization,omitempty"`
+}
+
+// SuspiciousLoginData is the data of the SuspiciousLogin event.
+type SuspiciousLoginData struct {
+	LoginData
+	Reason string `json:"reason"`
+
+	// PreviousCountries are the countries of the recent logins of the user, most recent first.
+	PreviousCountries []string `json:"previous_countries"`
+}
+
+// UserCommandData is the data of the inbound commands.
+type UserCommandData struct {
+	UserID string `json:"user_id"`
+
+	// RequestedBy identifies who requested the command, e.g. the id of the GDPR request.
+	// It is recorded as the actor in the audit log.
+	RequestedBy string `json:"requested_by,omitempty"`
+}
+
+// OpsData is the data of the ops events.
+type OpsData struct {
+	// Instance identifies the service instance, e.g. its hostname.
+	Instance string `json:"instance"`
+
+	// SchemaVersion is the version of the database schema, set on OpsMigrated.
+	SchemaVersion int64 `json:"schema_version,omitempty"`
+
+	// Reason tells what triggered the transition, e.g. the signal received.
+	Reason string `json:"reason,omitempty"`
+}
@@ -0,0 +1,197 @@
+// Package eventstest provides a conformance suite for events.Publisher
+// implementations, so every broker backend is verified the same way
+// before it is deployed.
+package eventstest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alesr/usrsvc/pkg/events"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	defaultTimeout  time.Duration = 10 * time.Second
+	defaultMessages int           = 50
+	retryInterval   time.Duration = 100 * time.Millisecond
+)
+
+// Harness wires a publisher backend into the suite.
+type Harness struct {
+	// Publisher is the implementation under test.
+	Publisher events.Publisher
+
+	// Receive blocks until the next envelope is delivered by the backend
+	// or the context is done.
+	Receive func(ctx context.Context) (*events.Envelope, error)
+
+	// Disconnect drops the connection to the broker. The publisher is
+	// expected to recover on its own. Leave it nil to skip the reconnect test.
+	Disconnect func() error
+
+	// Close releases the resources held by the harness. Optional.
+	Close func() error
+
+	// Timeout bounds how long the suite waits for deliveries. Defaults to 10s.
+	Timeout time.Duration
+}
+
+// payload is the data published by the suite.
+type payload struct {
+	Run string `json:"run"`
+	Seq int    `json:"seq"`
+}
+
+// RunPublisherSuite runs the conformance tests against the harness returned by
+// newHarness, which is called once per test so each one starts from a clean state.
+func RunPublisherSuite(t *testing.T, newHarness func(t *testing.T) *Harness) {
+	t.Helper()
+
+	t.Run("preserves the publishing order", func(t *testing.T) {
+		h := setup(t, newHarness)
+		run := uuid.NewString()
+
+		for i := 0; i < defaultMessages; i++ {
+			require.NoError(t, h.Publisher.Publish(context.TODO(), events.UserCreated, payload{Run: run, Seq: i}))
+		}
+
+		// Redeliveries are allowed, but the first delivery of each message must be in order.
+		seen := make(map[int]bool)
+		var order []int
+		collect(t, h, run, func(p payload) bool {
+			if !seen[p.Seq] {
+				seen[p.Seq] = true
+				order = append(order, p.Seq)
+			}
+			return len(seen) == defaultMessages
+		})
+
+		for i, seq := range order {
+			assert.Equal(t, i, seq, "message delivered out of order")
+		}
+	})
+
+	t.Run("delivers every message at least once", func(t *testing.T) {
+		h := setup(t, newHarness)
+		run := uuid.NewString()
+
+		for i := 0; i < defaultMessages; i++ {
+			require.NoError(t, h.Publisher.Publish(context.TODO(), events.UserUpdated, payload{Run: run, Seq: i}))
+		}
+
+		seen := make(map[int]bool)
+		collect(t, h, run, func(p payload) bool {
+			seen[p.Seq] = true
+			return len(seen) == defaultMessages
+		})
+
+		for i := 0; i < defaultMessages; i++ {
+			assert.True(t, seen[i], "message %d was not delivered", i)
+		}
+	})
+
+	t.Run("keeps the envelope intact", func(t *testing.T) {
+		h := setup(t, newHarness)
+		run := uuid.NewString()
+		before := time.Now().Add(-time.Minute)
+
+		require.NoError(t, h.Publisher.Publish(context.TODO(), events.UserDeleted, payload{Run: run, Seq: 42}))
+
+		ctx, cancel := context.WithTimeout(context.Background(), h.Timeout)
+		defer cancel()
+
+		for {
+			env, err := h.Receive(ctx)
+			require.NoError(t, err, "envelope was not delivered")
+
+			var p payload
+			require.NoError(t, env.Decode(&p))
+			if p.Run != run {
+				continue
+			}
+
+			assert.NotEmpty(t, env.ID)
+			_, err = uuid.Parse(env.ID)
+			assert.NoError(t, err, "envelope id must be a UUID")
+			assert.Equal(t, events.UserDeleted, env.Event)
+			assert.True(t, env.OccurredAt.After(before), "envelope timestamp is not set")
+			assert.Equal(t, 42, p.Seq)
+			return
+		}
+	})
+
+	t.Run("recovers after losing the connection", func(t *testing.T) {
+		h := setup(t, newHarness)
+		if h.Disconnect == nil {
+			t.Skip("harness does not support disconnects")
+		}
+
+		run := uuid.NewString()
+		require.NoError(t, h.Disconnect())
+
+		// The publisher may fail while reconnecting, but it must succeed before the timeout.
+		deadline := time.Now().Add(h.Timeout)
+		for {
+			err := h.Publisher.Publish(context.TODO(), events.UserCreated, payload{Run: run, Seq: 0})
+			if err == nil {
+				break
+			}
+			if time.Now().After(deadline) {
+				require.NoError(t, err, "publisher did not recover after disconnect")
+			}
+			time.Sleep(retryInterval)
+		}
+
+		collect(t, h, run, func(p payload) bool { return true })
+	})
+}
+
+func setup(t *testing.T, newHarness func(t *testing.T) *Harness) *Harness {
+	t.Helper()
+
+	h := newHarness(t)
+	require.NotNil(t, h.Publisher, "harness publisher is required")
+	require.NotNil(t, h.Receive, "harness receive function is required")
+
+	if h.Timeout == 0 {
+		h.Timeout = defaultTimeout
+	}
+
+	if h.Close != nil {
+		t.Cleanup(func() {
+			assert.NoError(t, h.Close())
+		})
+	}
+	return h
+}
+
+// collect receives envelopes belonging to the given run and hands their
+// payload to fn until it returns true. It fails the test on timeout.
+func collect(t *testing.T, h *Harness, run string, fn func(p payload) bool) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.Timeout)
+	defer cancel()
+
+	for {
+		env, err := h.Receive(ctx)
+		require.NoError(t, err, fmt.Sprintf("waiting for messages of run %s", run))
+
+		var p payload
+		require.NoError(t, env.Decode(&p))
+
+		// Ignore messages left over by other tests sharing the same broker.
+		if p.Run != run {
+			continue
+		}
+
+		if fn(p) {
+			return
+		}
+	}
+}
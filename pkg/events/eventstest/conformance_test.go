@@ -0,0 +1,77 @@
+package eventstest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/alesr/usrsvc/pkg/events"
+)
+
+var _ events.Publisher = (*chanPublisher)(nil)
+
+// chanPublisher is a minimal backend used to check the suite itself.
+// It serializes envelopes like a real broker and reconnects on the
+// publish that follows a disconnect.
+type chanPublisher struct {
+	mu           sync.Mutex
+	disconnected bool
+	messages     chan []byte
+}
+
+func (p *chanPublisher) Publish(ctx context.Context, event events.Event, data any) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.disconnected {
+		p.disconnected = false
+		return errors.New("connection lost")
+	}
+
+	env, err := events.NewEnvelope(ctx, event, data)
+	if err != nil {
+		return err
+	}
+
+	raw, err := json.Marshal(env)
+	if err != nil {
+		return err
+	}
+
+	p.messages <- raw
+	return nil
+}
+
+func (p *chanPublisher) receive(ctx context.Context) (*events.Envelope, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case raw := <-p.messages:
+		var env events.Envelope
+		if err := json.Unmarshal(raw, &env); err != nil {
+			return nil, err
+		}
+		return &env, nil
+	}
+}
+
+func (p *chanPublisher) disconnect() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.disconnected = true
+	return nil
+}
+
+func TestRunPublisherSuite(t *testing.T) {
+	RunPublisherSuite(t, func(t *testing.T) *Harness {
+		p := &chanPublisher{messages: make(chan []byte, 100)}
+		return &Harness{
+			Publisher:  p,
+			Receive:    p.receive,
+			Disconnect: p.disconnect,
+		}
+	})
+}
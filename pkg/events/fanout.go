@@ -0,0 +1,24 @@
+package events
+
+import (
+	"context"
+	"errors"
+)
+
+// Fanout returns a publisher that publishes every event to all the given publishers.
+// Every publisher is called even if a previous one fails; the errors are joined.
+func Fanout(publishers ...Publisher) Publisher {
+	return fanout(publishers)
+}
+
+type fanout []Publisher
+
+func (f fanout) Publish(ctx context.Context, event Event, data any) error {
+	var errs []error
+	for _, p := range f {
+		if err := p.Publish(ctx, event, data); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
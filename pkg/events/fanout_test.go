@@ -0,0 +1,36 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type publisherFunc func(ctx context.Context, event Event, data any) error
+
+func (f publisherFunc) Publish(ctx context.Context, event Event, data any) error {
+	return f(ctx, event, data)
+}
+
+func TestFanout(t *testing.T) {
+	t.Run("publishes to every publisher even when one fails", func(t *testing.T) {
+		// Arrange
+		var received []Event
+		failing := publisherFunc(func(ctx context.Context, event Event, data any) error {
+			return errors.New("some error")
+		})
+		recording := publisherFunc(func(ctx context.Context, event Event, data any) error {
+			received = append(received, event)
+			return nil
+		})
+
+		// Act
+		err := Fanout(failing, recording).Publish(context.TODO(), UserCreated, "some-id")
+
+		// Assert
+		assert.Error(t, err)
+		assert.Equal(t, []Event{UserCreated}, received)
+	})
+}
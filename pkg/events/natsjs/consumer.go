@@ -0,0 +1,147 @@
+package natsjs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alesr/usrsvc/pkg/events"
+	"github.com/nats-io/nats.go"
+)
+
+var _ events.Consumer = (*Publisher)(nil)
+
+const (
+	defaultDurable string = "usrsvc"
+
+	// fetchWait bounds how long a fetch waits for a message before pulling again.
+	fetchWait time.Duration = 5 * time.Second
+
+	// redeliveryDelay is how long JetStream waits to redeliver a failed message.
+	redeliveryDelay time.Duration = 10 * time.Second
+)
+
+// Consume implements events.Consumer with a durable pull consumer per event, created on
+// the stream if missing and named after the Durable of the config and the event. The
+// instances sharing the durable name share the messages, and a durable consumer resumes
+// where it stopped, so no command is lost while the instances are down.
+//
+// The events are consumed concurrently, one message at a time per event. A failed message
+// is redelivered after a delay, a permanently failed or malformed one is terminated.
+func (p *Publisher) Consume(ctx context.Context, handle events.Handler, evts ...events.Event) error {
+	if len(evts) == 0 {
+		return errors.New("could not consume: no event given")
+	}
+
+	subs := make([]*nats.Subscription, 0, len(evts))
+	defer func() {
+		// The consumers are bound, unsubscribing keeps them.
+		for _, sub := range subs {
+			sub.Unsubscribe()
+		}
+	}()
+
+	for _, event := range evts {
+		sub, err := p.pullSubscribe(event)
+		if err != nil {
+			return err
+		}
+		subs = append(subs, sub)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errs := make(chan error, len(subs))
+	for _, sub := range subs {
+		go func(sub *nats.Subscription) {
+			errs <- p.fetch(ctx, sub, handle)
+			cancel()
+		}(sub)
+	}
+
+	// The first error is the one that stopped the others.
+	err := <-errs
+	for i := 1; i < len(subs); i++ {
+		<-errs
+	}
+	return err
+}
+
+// Durable returns the name of the durable consumer of the event.
+func (p *Publisher) Durable(event events.Event) string {
+	return p.cfg.Durable + "_" + strings.ReplaceAll(string(event), ".", "_")
+}
+
+// pullSubscribe creates the durable consumer of the event if missing and binds to it.
+func (p *Publisher) pullSubscribe(event events.Event) (*nats.Subscription, error) {
+	durable := p.Durable(event)
+
+	if _, err := p.js.ConsumerInfo(p.cfg.Stream, durable); err != nil {
+		if !errors.Is(err, nats.ErrConsumerNotFound) {
+			return nil, fmt.Errorf("could not get consumer info: %w", err)
+		}
+
+		if _, err := p.js.AddConsumer(p.cfg.Stream, &nats.ConsumerConfig{
+			Durable:       durable,
+			FilterSubject: p.Subject(event),
+			AckPolicy:     nats.AckExplicitPolicy,
+			DeliverPolicy: nats.DeliverAllPolicy,
+		}); err != nil {
+			return nil, fmt.Errorf("could not create consumer: %w", err)
+		}
+	}
+
+	sub, err := p.js.PullSubscribe(p.Subject(event), durable, nats.Bind(p.cfg.Stream, durable))
+	if err != nil {
+		return nil, fmt.Errorf("could not subscribe to event '%s': %w", event, err)
+	}
+	return sub, nil
+}
+
+// fetch pulls the messages of the subscription and handles them until ctx is done.
+func (p *Publisher) fetch(ctx context.Context, sub *nats.Subscription, handle events.Handler) error {
+	for {
+		fetchCtx, cancel := context.WithTimeout(ctx, fetchWait)
+		msgs, err := sub.Fetch(1, nats.Context(fetchCtx))
+		cancel()
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, nats.ErrTimeout) {
+				continue
+			}
+			return fmt.Errorf("could not fetch events: %w", err)
+		}
+
+		for _, msg := range msgs {
+			p.handle(ctx, msg, handle)
+		}
+	}
+}
+
+// handle passes the envelope of the message to the handler and acks the message with the
+// outcome. A failed ack is redelivered, so the handlers must be idempotent anyway.
+func (p *Publisher) handle(ctx context.Context, msg *nats.Msg, handle events.Handler) {
+	var env events.Envelope
+	if err := json.Unmarshal(msg.Data, &env); err != nil {
+		msg.Term()
+		return
+	}
+
+	err := handle(env.Context(ctx), &env)
+	switch {
+	case err == nil:
+		msg.Ack()
+	case events.IsPermanent(err):
+		msg.Term()
+	default:
+		msg.NakWithDelay(redeliveryDelay)
+	}
+}
@@ -0,0 +1,75 @@
+//go:build integration
+// +build integration
+
+package natsjs_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alesr/usrsvc/pkg/events"
+	"github.com/alesr/usrsvc/pkg/events/natsjs"
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublisher_Consume(t *testing.T) {
+	// Arrange
+	id := strings.ReplaceAll(uuid.NewString(), "-", "")
+
+	publisher, err := natsjs.New(natsjs.Config{
+		URL:           natsURL(),
+		Stream:        "TEST_" + id,
+		SubjectPrefix: "test" + id,
+		Durable:       "test",
+	})
+	require.NoError(t, err)
+	defer publisher.Close()
+
+	conn, err := nats.Connect(natsURL())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	js, err := conn.JetStream()
+	require.NoError(t, err)
+	defer js.DeleteStream("TEST_" + id)
+
+	// The consumer is durable: the commands published before Consume are delivered.
+	require.NoError(t, publisher.Publish(context.TODO(), events.UserCreated, "user-id"))
+	require.NoError(t, publisher.Publish(context.TODO(), events.UserDeleteRequested, events.UserCommandData{UserID: "user-id"}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	received := make(chan *events.Envelope, 1)
+	done := make(chan error, 1)
+
+	// Act
+	go func() {
+		done <- publisher.Consume(ctx, func(ctx context.Context, env *events.Envelope) error {
+			received <- env
+			return nil
+		}, events.UserDeleteRequested)
+	}()
+
+	// Assert
+	env := <-received
+	assert.Equal(t, events.UserDeleteRequested, env.Event)
+
+	var data events.UserCommandData
+	require.NoError(t, env.Decode(&data))
+	assert.Equal(t, "user-id", data.UserID)
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+
+	// The consumer is kept, with the command acked.
+	assert.Eventually(t, func() bool {
+		info, err := js.ConsumerInfo("TEST_"+id, publisher.Durable(events.UserDeleteRequested))
+		return err == nil && info.NumPending == 0 && info.NumAckPending == 0
+	}, time.Second, 50*time.Millisecond)
+}
@@ -0,0 +1,158 @@
+// Package natsjs implements an events.Publisher and an events.Consumer backed by NATS JetStream.
+package natsjs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/alesr/usrsvc/pkg/events"
+	"github.com/nats-io/nats.go"
+)
+
+var _ events.Publisher = (*Publisher)(nil)
+
+const (
+	defaultStream         string        = "USERS"
+	defaultSubjectPrefix  string        = "usrsvc"
+	defaultPublishTimeout time.Duration = 5 * time.Second
+
+	// KeyHeader carries the ordering key of the envelope (see events.Ordered),
+	// so consumers can filter or shard on it without decoding the envelope.
+	KeyHeader string = "Usrsvc-Key"
+
+	// SchemaVersionHeader carries the schema version of the envelope data (see
+	// events.Event.SchemaVersion), so consumers can route the versions they don't know.
+	SchemaVersionHeader string = "Usrsvc-Schema-Version"
+)
+
+// Config configures the JetStream publisher.
+type Config struct {
+	URL string
+
+	// Stream is the JetStream stream storing the events. It is created on
+	// startup if missing, capturing every subject under SubjectPrefix.
+	Stream string
+
+	// SubjectPrefix is prepended to the event names to build the subjects,
+	// e.g. usrsvc.user.created.
+	SubjectPrefix string
+
+	// PublishTimeout bounds how long a publish waits for the stream ack.
+	PublishTimeout time.Duration
+
+	// Durable prefixes the names of the durable consumers of Consume.
+	Durable string
+}
+
+// Publisher publishes every event to its own subject of a JetStream stream. Publish only
+// returns once the stream has acked the message, so the events are delivered at least once.
+// The envelope id is set as the message id, so JetStream discards the retried publishes
+// within the stream duplicate window.
+type Publisher struct {
+	conn *nats.Conn
+	js   nats.JetStreamContext
+	cfg  Config
+}
+
+// New connects to the NATS server and makes sure the stream exists. The connection
+// reconnects on its own when lost.
+func New(cfg Config) (*Publisher, error) {
+	if cfg.Stream == "" {
+		cfg.Stream = defaultStream
+	}
+	if cfg.SubjectPrefix == "" {
+		cfg.SubjectPrefix = defaultSubjectPrefix
+	}
+	if cfg.PublishTimeout <= 0 {
+		cfg.PublishTimeout = defaultPublishTimeout
+	}
+	if cfg.Durable == "" {
+		cfg.Durable = defaultDurable
+	}
+
+	conn, err := nats.Connect(cfg.URL, nats.MaxReconnects(-1))
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to nats: %w", err)
+	}
+
+	js, err := conn.JetStream(nats.MaxWait(cfg.PublishTimeout))
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("could not create jetstream context: %w", err)
+	}
+
+	if _, err := js.StreamInfo(cfg.Stream); err != nil {
+		if !errors.Is(err, nats.ErrStreamNotFound) {
+			conn.Close()
+			return nil, fmt.Errorf("could not get stream info: %w", err)
+		}
+
+		if _, err := js.AddStream(&nats.StreamConfig{
+			Name:     cfg.Stream,
+			Subjects: []string{cfg.SubjectPrefix + ".>"},
+			Storage:  nats.FileStorage,
+		}); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("could not create stream: %w", err)
+		}
+	}
+
+	return &Publisher{conn: conn, js: js, cfg: cfg}, nil
+}
+
+// Subject returns the subject the event is published to.
+func (p *Publisher) Subject(event events.Event) string {
+	return p.cfg.SubjectPrefix + "." + string(event)
+}
+
+// Publish wraps the event data into an envelope and publishes it, waiting for the ack
+// until the publish timeout or ctx is done. The trace metadata of the envelope is set as
+// message headers.
+func (p *Publisher) Publish(ctx context.Context, event events.Event, data any) error {
+	env, err := events.NewEnvelope(ctx, event, data)
+	if err != nil {
+		return err
+	}
+
+	raw, err := json.Marshal(env)
+	if err != nil {
+		return fmt.Errorf("could not marshal envelope: %w", err)
+	}
+
+	msg := nats.NewMsg(p.Subject(event))
+	msg.Data = raw
+	msg.Header.Set(nats.MsgIdHdr, env.ID)
+	msg.Header.Set(SchemaVersionHeader, strconv.Itoa(env.Version()))
+	if env.Key != "" {
+		msg.Header.Set(KeyHeader, env.Key)
+	}
+	for key, value := range env.Metadata {
+		msg.Header.Set(key, value)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.cfg.PublishTimeout)
+	defer cancel()
+
+	if _, err := p.js.PublishMsg(msg, nats.Context(ctx)); err != nil {
+		return fmt.Errorf("could not publish event '%s': %w", event, err)
+	}
+	return nil
+}
+
+// Ping checks the connection to the server with a round trip.
+func (p *Publisher) Ping(ctx context.Context) error {
+	if err := p.conn.FlushWithContext(ctx); err != nil {
+		return fmt.Errorf("could not ping nats: %w", err)
+	}
+	return nil
+}
+
+// Close closes the connection to the server.
+func (p *Publisher) Close() error {
+	p.conn.Close()
+	return nil
+}
@@ -0,0 +1,112 @@
+//go:build integration
+// +build integration
+
+package natsjs_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/alesr/usrsvc/pkg/events"
+	"github.com/alesr/usrsvc/pkg/events/eventstest"
+	"github.com/alesr/usrsvc/pkg/events/natsjs"
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func natsURL() string {
+	if url := os.Getenv("NATS_URL"); url != "" {
+		return url
+	}
+	return nats.DefaultURL
+}
+
+func TestPublisher_Conformance(t *testing.T) {
+	eventstest.RunPublisherSuite(t, func(t *testing.T) *eventstest.Harness {
+		// Every harness gets its own stream, so the tests do not share messages.
+		id := strings.ReplaceAll(uuid.NewString(), "-", "")
+
+		publisher, err := natsjs.New(natsjs.Config{
+			URL:           natsURL(),
+			Stream:        "TEST_" + id,
+			SubjectPrefix: "test" + id,
+		})
+		require.NoError(t, err)
+
+		conn, err := nats.Connect(natsURL())
+		require.NoError(t, err)
+
+		js, err := conn.JetStream()
+		require.NoError(t, err)
+
+		sub, err := js.SubscribeSync("test"+id+".>", nats.OrderedConsumer())
+		require.NoError(t, err)
+
+		return &eventstest.Harness{
+			Publisher: publisher,
+			Receive: func(ctx context.Context) (*events.Envelope, error) {
+				msg, err := sub.NextMsgWithContext(ctx)
+				if err != nil {
+					return nil, err
+				}
+
+				var env events.Envelope
+				if err := json.Unmarshal(msg.Data, &env); err != nil {
+					return nil, err
+				}
+				return &env, nil
+			},
+			Close: func() error {
+				defer conn.Close()
+
+				if err := js.DeleteStream("TEST_" + id); err != nil {
+					return err
+				}
+				return publisher.Close()
+			},
+		}
+	})
+}
+
+func TestPublisher(t *testing.T) {
+	t.Run("publishes each event to its subject with the envelope id as message id", func(t *testing.T) {
+		// Arrange
+		id := strings.ReplaceAll(uuid.NewString(), "-", "")
+
+		publisher, err := natsjs.New(natsjs.Config{
+			URL:           natsURL(),
+			Stream:        "TEST_" + id,
+			SubjectPrefix: "test" + id,
+		})
+		require.NoError(t, err)
+		defer publisher.Close()
+
+		conn, err := nats.Connect(natsURL())
+		require.NoError(t, err)
+		defer conn.Close()
+
+		js, err := conn.JetStream()
+		require.NoError(t, err)
+		defer js.DeleteStream("TEST_" + id)
+
+		// Act
+		err = publisher.Publish(context.TODO(), events.UserCreated, map[string]string{"id": "user-id"})
+		require.NoError(t, err)
+
+		// Assert
+		msg, err := js.GetLastMsg("TEST_"+id, publisher.Subject(events.UserCreated))
+		require.NoError(t, err)
+
+		var env events.Envelope
+		require.NoError(t, json.Unmarshal(msg.Data, &env))
+
+		assert.Equal(t, "test"+id+"."+string(events.UserCreated), msg.Subject)
+		assert.Equal(t, env.ID, msg.Header.Get(nats.MsgIdHdr))
+		assert.NoError(t, publisher.Ping(context.Background()))
+	})
+}
@@ -0,0 +1,56 @@
+package events
+
+import "sync"
+
+// SequenceStatus describes how an event relates to the previous events with the same key.
+type SequenceStatus int
+
+const (
+	// InOrder means the event directly follows the last one seen for its key.
+	InOrder SequenceStatus = iota
+
+	// Gap means one or more events for the key were skipped (lost or not delivered yet).
+	Gap
+
+	// Stale means the event is a redelivery or arrived after a more recent one for its key.
+	Stale
+
+	// Unordered means the event has no key or sequence.
+	Unordered
+)
+
+// SequenceTracker is used by consumers to check the per-key ordering of the events they receive.
+// The first event seen for a key is always in order, since the consumer may have started
+// after the earlier events were published. It is safe for concurrent use.
+type SequenceTracker struct {
+	mu   sync.Mutex
+	last map[string]int64
+}
+
+// NewSequenceTracker creates an empty sequence tracker.
+func NewSequenceTracker() *SequenceTracker {
+	return &SequenceTracker{last: make(map[string]int64)}
+}
+
+// Observe records the envelope and reports its status. Stale envelopes are not recorded,
+// so consumers can safely skip them.
+func (t *SequenceTracker) Observe(env *Envelope) SequenceStatus {
+	if env.Key == "" || env.Sequence == 0 {
+		return Unordered
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	last, seen := t.last[env.Key]
+	switch {
+	case seen && env.Sequence <= last:
+		return Stale
+	case seen && env.Sequence > last+1:
+		t.last[env.Key] = env.Sequence
+		return Gap
+	default:
+		t.last[env.Key] = env.Sequence
+		return InOrder
+	}
+}
@@ -0,0 +1,35 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSequenceTracker(t *testing.T) {
+	// Arrange
+	tracker := NewSequenceTracker()
+
+	given := []struct {
+		key      string
+		sequence int64
+		expected SequenceStatus
+	}{
+		{key: "a", sequence: 3, expected: InOrder},
+		{key: "a", sequence: 4, expected: InOrder},
+		{key: "b", sequence: 1, expected: InOrder},
+		{key: "a", sequence: 4, expected: Stale},
+		{key: "a", sequence: 6, expected: Gap},
+		{key: "a", sequence: 5, expected: Stale},
+		{key: "a", sequence: 7, expected: InOrder},
+		{key: "", sequence: 0, expected: Unordered},
+	}
+
+	for _, g := range given {
+		// Act
+		status := tracker.Observe(&Envelope{Key: g.key, Sequence: g.sequence})
+
+		// Assert
+		assert.Equal(t, g.expected, status, "key %q sequence %d", g.key, g.sequence)
+	}
+}
@@ -0,0 +1,117 @@
+package rabbitmq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alesr/usrsvc/pkg/events"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+var _ events.Consumer = (*Publisher)(nil)
+
+const (
+	defaultQueue string = "usrsvc.commands"
+
+	// redeliveryDelay is how long a failed message is held before it is requeued,
+	// so a failing handler doesn't spin on it.
+	redeliveryDelay time.Duration = 10 * time.Second
+)
+
+// ErrConnectionLost is returned by Consume when the connection to the broker is lost.
+var ErrConnectionLost = errors.New("connection lost")
+
+// Consume implements events.Consumer on its own connection, consuming the durable queue of
+// the config, declared on connect and bound to the exchange with the events as routing keys.
+// The instances sharing the queue share the messages, and the queue keeps them while the
+// instances are down.
+//
+// The messages are handled one at a time. A failed message is requeued after a delay, a
+// permanently failed or malformed one is rejected. When the connection is lost, Consume
+// returns ErrConnectionLost: call it again to reconnect.
+func (p *Publisher) Consume(ctx context.Context, handle events.Handler, evts ...events.Event) error {
+	if len(evts) == 0 {
+		return errors.New("could not consume: no event given")
+	}
+
+	p.mu.Lock()
+	closed := p.closed
+	p.mu.Unlock()
+
+	if closed {
+		return ErrClosed
+	}
+
+	conn, err := amqp.Dial(p.cfg.URL)
+	if err != nil {
+		return fmt.Errorf("could not connect to rabbitmq: %w", err)
+	}
+	defer conn.Close()
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return fmt.Errorf("could not open channel: %w", err)
+	}
+
+	if err := ch.ExchangeDeclare(p.cfg.Exchange, amqp.ExchangeTopic, true, false, false, false, nil); err != nil {
+		return fmt.Errorf("could not declare exchange: %w", err)
+	}
+
+	if _, err := ch.QueueDeclare(p.cfg.Queue, true, false, false, false, nil); err != nil {
+		return fmt.Errorf("could not declare queue: %w", err)
+	}
+
+	for _, event := range evts {
+		if err := ch.QueueBind(p.cfg.Queue, p.RoutingKey(event), p.cfg.Exchange, false, nil); err != nil {
+			return fmt.Errorf("could not bind queue to event '%s': %w", event, err)
+		}
+	}
+
+	if err := ch.Qos(1, 0, false); err != nil {
+		return fmt.Errorf("could not set prefetch: %w", err)
+	}
+
+	deliveries, err := ch.Consume(p.cfg.Queue, "", false, false, false, false, nil)
+	if err != nil {
+		return fmt.Errorf("could not consume queue: %w", err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case delivery, ok := <-deliveries:
+			if !ok {
+				return ErrConnectionLost
+			}
+			p.handle(ctx, delivery, handle)
+		}
+	}
+}
+
+// handle passes the envelope of the delivery to the handler and acks the delivery with the
+// outcome. A failed ack is redelivered, so the handlers must be idempotent anyway.
+func (p *Publisher) handle(ctx context.Context, delivery amqp.Delivery, handle events.Handler) {
+	var env events.Envelope
+	if err := json.Unmarshal(delivery.Body, &env); err != nil {
+		delivery.Reject(false)
+		return
+	}
+
+	err := handle(env.Context(ctx), &env)
+	switch {
+	case err == nil:
+		delivery.Ack(false)
+	case events.IsPermanent(err):
+		delivery.Reject(false)
+	default:
+		select {
+		case <-ctx.Done():
+		case <-time.After(redeliveryDelay):
+		}
+		delivery.Nack(false, true)
+	}
+}
@@ -0,0 +1,73 @@
+//go:build integration
+// +build integration
+
+package rabbitmq
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alesr/usrsvc/pkg/events"
+	"github.com/google/uuid"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublisher_Consume(t *testing.T) {
+	// Arrange
+	exchange, queue := "test."+uuid.NewString(), "test."+uuid.NewString()
+
+	conn, err := amqp.Dial(rabbitMQURL())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	ch, err := conn.Channel()
+	require.NoError(t, err)
+	defer ch.ExchangeDelete(exchange, false, false)
+	defer ch.QueueDelete(queue, false, false, false)
+
+	publisher, err := New(Config{URL: rabbitMQURL(), Exchange: exchange, Queue: queue})
+	require.NoError(t, err)
+	defer publisher.Close()
+
+	// The queue is durable: the commands published before Consume are delivered.
+	_, err = ch.QueueDeclare(queue, true, false, false, false, nil)
+	require.NoError(t, err)
+	require.NoError(t, ch.QueueBind(queue, publisher.RoutingKey(events.UserDeleteRequested), exchange, false, nil))
+
+	require.NoError(t, publisher.Publish(context.TODO(), events.UserCreated, "user-id"))
+	require.NoError(t, publisher.Publish(context.TODO(), events.UserDeleteRequested, events.UserCommandData{UserID: "user-id"}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	received := make(chan *events.Envelope, 1)
+	done := make(chan error, 1)
+
+	// Act
+	go func() {
+		done <- publisher.Consume(ctx, func(ctx context.Context, env *events.Envelope) error {
+			received <- env
+			return nil
+		}, events.UserDeleteRequested)
+	}()
+
+	// Assert
+	env := <-received
+	assert.Equal(t, events.UserDeleteRequested, env.Event)
+
+	var data events.UserCommandData
+	require.NoError(t, env.Decode(&data))
+	assert.Equal(t, "user-id", data.UserID)
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+
+	// The command was acked.
+	assert.Eventually(t, func() bool {
+		state, err := ch.QueueInspect(queue)
+		return err == nil && state.Messages == 0
+	}, time.Second, 50*time.Millisecond)
+}
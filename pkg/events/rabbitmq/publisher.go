@@ -0,0 +1,226 @@
+// Package rabbitmq implements an events.Publisher and an events.Consumer backed by RabbitMQ.
+package rabbitmq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/alesr/usrsvc/pkg/events"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+var _ events.Publisher = (*Publisher)(nil)
+
+const (
+	defaultExchange       string        = "usrsvc.events"
+	defaultPublishTimeout time.Duration = 5 * time.Second
+
+	// KeyHeader carries the ordering key of the envelope (see events.Ordered),
+	// so consumers can route or shard on it without decoding the envelope.
+	KeyHeader string = "usrsvc-key"
+
+	// SchemaVersionHeader carries the schema version of the envelope data (see
+	// events.Event.SchemaVersion), so consumers can route the versions they don't know.
+	SchemaVersionHeader string = "usrsvc-schema-version"
+)
+
+// ErrClosed is returned when publishing with a closed publisher.
+var ErrClosed = errors.New("publisher is closed")
+
+// Config configures the RabbitMQ publisher.
+type Config struct {
+	URL string
+
+	// Exchange is the durable topic exchange the events are published to, with the
+	// event name as routing key (e.g. user.created). It is declared on connect.
+	Exchange string
+
+	// PublishTimeout bounds how long a publish waits for the broker confirm.
+	PublishTimeout time.Duration
+
+	// Queue is the durable queue Consume consumes the events from.
+	Queue string
+}
+
+// Publisher publishes the events as persistent messages to a topic exchange. The channel is
+// in confirm mode and Publish only returns once the broker has confirmed the message, so the
+// events are delivered at least once. The envelope id is set as the message id for consumers
+// to discard duplicates.
+//
+// When the connection is lost, the next Publish or Ping dials the broker again. Publishes are
+// serialized, which keeps them in order and lets Close wait for the in-flight one.
+type Publisher struct {
+	cfg Config
+
+	mu     sync.Mutex
+	conn   *amqp.Connection
+	ch     *amqp.Channel
+	closed bool
+}
+
+// New connects to the broker and declares the exchange.
+func New(cfg Config) (*Publisher, error) {
+	if cfg.Exchange == "" {
+		cfg.Exchange = defaultExchange
+	}
+	if cfg.PublishTimeout <= 0 {
+		cfg.PublishTimeout = defaultPublishTimeout
+	}
+	if cfg.Queue == "" {
+		cfg.Queue = defaultQueue
+	}
+
+	p := Publisher{cfg: cfg}
+	if err := p.connect(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// connect dials the broker and opens a channel in confirm mode. It must be called with mu held.
+func (p *Publisher) connect() error {
+	conn, err := amqp.Dial(p.cfg.URL)
+	if err != nil {
+		return fmt.Errorf("could not connect to rabbitmq: %w", err)
+	}
+
+	ch, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("could not open channel: %w", err)
+	}
+
+	if err := ch.ExchangeDeclare(p.cfg.Exchange, amqp.ExchangeTopic, true, false, false, false, nil); err != nil {
+		conn.Close()
+		return fmt.Errorf("could not declare exchange: %w", err)
+	}
+
+	if err := ch.Confirm(false); err != nil {
+		conn.Close()
+		return fmt.Errorf("could not enable publisher confirms: %w", err)
+	}
+
+	p.conn, p.ch = conn, ch
+	return nil
+}
+
+// channel returns the open channel, reconnecting if the connection was lost.
+// It must be called with mu held.
+func (p *Publisher) channel() (*amqp.Channel, error) {
+	if p.closed {
+		return nil, ErrClosed
+	}
+
+	if p.conn == nil || p.conn.IsClosed() || p.ch.IsClosed() {
+		if p.conn != nil {
+			p.conn.Close()
+		}
+
+		if err := p.connect(); err != nil {
+			return nil, err
+		}
+	}
+	return p.ch, nil
+}
+
+// RoutingKey returns the routing key the event is published with.
+func (p *Publisher) RoutingKey(event events.Event) string {
+	return string(event)
+}
+
+// Publish wraps the event data into an envelope and publishes it, waiting for the confirm
+// until the publish timeout or ctx is done. The trace metadata of the envelope is set as
+// message headers.
+func (p *Publisher) Publish(ctx context.Context, event events.Event, data any) error {
+	env, err := events.NewEnvelope(ctx, event, data)
+	if err != nil {
+		return err
+	}
+
+	raw, err := json.Marshal(env)
+	if err != nil {
+		return fmt.Errorf("could not marshal envelope: %w", err)
+	}
+
+	msg := amqp.Publishing{
+		ContentType:  "application/json",
+		DeliveryMode: amqp.Persistent,
+		MessageId:    env.ID,
+		Timestamp:    env.OccurredAt,
+		Type:         string(event),
+		Body:         raw,
+		Headers:      amqp.Table{SchemaVersionHeader: env.Version()},
+	}
+	if env.Key != "" {
+		msg.Headers[KeyHeader] = env.Key
+	}
+	for key, value := range env.Metadata {
+		msg.Headers[key] = value
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	ch, err := p.channel()
+	if err != nil {
+		return fmt.Errorf("could not publish event '%s': %w", event, err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.cfg.PublishTimeout)
+	defer cancel()
+
+	confirm, err := ch.PublishWithDeferredConfirmWithContext(ctx, p.cfg.Exchange, p.RoutingKey(event), false, false, msg)
+	if err != nil {
+		return fmt.Errorf("could not publish event '%s': %w", event, err)
+	}
+
+	acked, err := confirm.WaitContext(ctx)
+	if err != nil {
+		return fmt.Errorf("could not confirm event '%s': %w", event, err)
+	}
+
+	if !acked {
+		return fmt.Errorf("could not publish event '%s': rejected by the broker", event)
+	}
+	return nil
+}
+
+// Ping checks the connection to the broker, reconnecting if it was lost.
+func (p *Publisher) Ping(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, err := p.channel(); err != nil {
+		return fmt.Errorf("could not ping rabbitmq: %w", err)
+	}
+	return nil
+}
+
+// Close waits for the in-flight publish and closes the connection.
+// Publishing afterwards fails with ErrClosed.
+func (p *Publisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+
+	if p.conn == nil || p.conn.IsClosed() {
+		return nil
+	}
+
+	if err := p.conn.Close(); err != nil {
+		return fmt.Errorf("could not close rabbitmq connection: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+// Package fakeusers generates deterministic, valid users from a seed.
+// The same seed always produces the same sequence of users, so datasets
+// used by the seeder, the load tests and the test suites are reproducible.
+package fakeusers
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	minPasswordLength int    = 10
+	maxPasswordLength int    = 16
+	passwordLetters   string = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	passwordDigits    string = "0123456789"
+	passwordSpecials  string = "!@#$%^&*-_=+?"
+	emailDomain       string = "example.com"
+)
+
+// User is a generated user. The fields satisfy the validation rules of the API
+// (names with letters only, valid email, password with letters, numbers and
+// special characters, ISO 3166-1 alpha-2 country code).
+type User struct {
+	FirstName string
+	LastName  string
+	Nickname  string
+	Email     string
+	Password  string
+	Country   string
+}
+
+// Generator produces users from a seeded source. It is not safe for concurrent use.
+type Generator struct {
+	rnd       *rand.Rand
+	locales   []locale
+	seq       int
+	partition string
+}
+
+// Option configures the generator.
+type Option func(*Generator)
+
+// WithLocales restricts the generator to the given locales (e.g. "pt_BR", "de_DE").
+// Unknown locales are ignored; if none is known, all locales are used.
+func WithLocales(codes ...string) Option {
+	return func(g *Generator) {
+		var selected []locale
+		for _, code := range codes {
+			if l, ok := locales[code]; ok {
+				selected = append(selected, l)
+			}
+		}
+		if len(selected) > 0 {
+			g.locales = selected
+		}
+	}
+}
+
+// WithPartition tags the emails and nicknames with the partition, a non-negative number, so
+// the generators of different partitions never produce the same ones, e.g. the workers of a
+// load test each generating their users concurrently.
+func WithPartition(partition int) Option {
+	return func(g *Generator) {
+		g.partition = letterSuffix(partition + 1)
+	}
+}
+
+// New creates a new generator for the given seed.
+func New(seed int64, opts ...Option) *Generator {
+	g := &Generator{
+		rnd:     rand.New(rand.NewSource(seed)),
+		locales: allLocales(),
+	}
+
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
+}
+
+// Locales returns the supported locale codes.
+func Locales() []string {
+	codes := make([]string, 0, len(locales))
+	for code := range locales {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// Next returns the next user in the sequence.
+// Emails and nicknames are unique within the same generator, and across the generators
+// of different partitions.
+func (g *Generator) Next() User {
+	g.seq++
+
+	l := g.locales[g.rnd.Intn(len(g.locales))]
+
+	firstName := l.firstNames[g.rnd.Intn(len(l.firstNames))]
+	lastName := l.lastNames[g.rnd.Intn(len(l.lastNames))]
+	country := l.countries[g.rnd.Intn(len(l.countries))]
+
+	first, last := asciiFold(firstName), asciiFold(lastName)
+
+	// Nicknames only accept letters and separators, so the sequence is encoded as letters as well.
+	nickname := first + last + letterSuffix(g.seq)
+	local := fmt.Sprintf("%s.%s.%d", first, last, g.seq)
+
+	// The folded names have no hyphens, so the partition can't be mistaken for a part of them.
+	if g.partition != "" {
+		nickname += "-" + g.partition
+		local += "-" + g.partition
+	}
+
+	return User{
+		FirstName: firstName,
+		LastName:  lastName,
+		Nickname:  nickname,
+		Email:     local + "@" + emailDomain,
+		Password:  g.password(),
+		Country:   country,
+	}
+}
+
+// Generate returns the next n users in the sequence.
+func (g *Generator) Generate(n int) []User {
+	users := make([]User, 0, n)
+	for i := 0; i < n; i++ {
+		users = append(users, g.Next())
+	}
+	return users
+}
+
+// password returns a password with at least one letter, one number and one special character.
+func (g *Generator) password() string {
+	length := minPasswordLength + g.rnd.Intn(maxPasswordLength-minPasswordLength+1)
+
+	chars := []byte{
+		passwordLetters[g.rnd.Intn(len(passwordLetters))],
+		passwordDigits[g.rnd.Intn(len(passwordDigits))],
+		passwordSpecials[g.rnd.Intn(len(passwordSpecials))],
+	}
+
+	alphabet := passwordLetters + passwordDigits + passwordSpecials
+	for len(chars) < length {
+		chars = append(chars, alphabet[g.rnd.Intn(len(alphabet))])
+	}
+
+	g.rnd.Shuffle(len(chars), func(i, j int) {
+		chars[i], chars[j] = chars[j], chars[i]
+	})
+	return string(chars)
+}
+
+// asciiFold lowercases the name and strips accents and spaces so it can be used in emails.
+func asciiFold(name string) string {
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+
+	folded, _, err := transform.String(t, name)
+	if err != nil {
+		folded = name
+	}
+
+	var b strings.Builder
+	for _, r := range strings.ToLower(folded) {
+		if r >= 'a' && r <= 'z' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// letterSuffix encodes n using only lowercase letters (1 -> "a", 27 -> "aa").
+func letterSuffix(n int) string {
+	var b []byte
+	for n > 0 {
+		n--
+		b = append([]byte{byte('a' + n%26)}, b...)
+		n /= 26
+	}
+	return string(b)
+}
@@ -0,0 +1,104 @@
+package fakeusers
+
+import (
+	"testing"
+
+	"github.com/alesr/usrsvc/pkg/uservalidation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGeneratorIsDeterministic(t *testing.T) {
+	t.Parallel()
+
+	first := New(42).Generate(50)
+	second := New(42).Generate(50)
+	other := New(43).Generate(50)
+
+	assert.Equal(t, first, second)
+	assert.NotEqual(t, first, other)
+}
+
+func TestGeneratedUsersAreValid(t *testing.T) {
+	t.Parallel()
+
+	users := New(1).Generate(1000)
+
+	emails := make(map[string]bool)
+	nicknames := make(map[string]bool)
+
+	for _, user := range users {
+		require.NoError(t, uservalidation.DefaultPolicy.ValidateUser(uservalidation.User{
+			FirstName: user.FirstName,
+			LastName:  user.LastName,
+			Nickname:  user.Nickname,
+			Email:     user.Email,
+			Password:  user.Password,
+			Country:   user.Country,
+		}), "invalid user %+v", user)
+
+		require.False(t, emails[user.Email], "duplicate email %q", user.Email)
+		require.False(t, nicknames[user.Nickname], "duplicate nickname %q", user.Nickname)
+		emails[user.Email] = true
+		nicknames[user.Nickname] = true
+	}
+}
+
+func TestWithPartition(t *testing.T) {
+	t.Parallel()
+
+	// Generators of different partitions produce unique users even from the same seed, as
+	// the workers of a load test do from consecutive seeds.
+	emails := make(map[string]bool)
+	nicknames := make(map[string]bool)
+
+	for partition := 0; partition < 30; partition++ {
+		for _, user := range New(1, WithPartition(partition)).Generate(100) {
+			require.NoError(t, uservalidation.DefaultPolicy.ValidateName(user.Nickname), "invalid nickname %q", user.Nickname)
+			require.NoError(t, uservalidation.DefaultPolicy.ValidateEmail(user.Email), "invalid email %q", user.Email)
+
+			require.False(t, emails[user.Email], "duplicate email %q", user.Email)
+			require.False(t, nicknames[user.Nickname], "duplicate nickname %q", user.Nickname)
+			emails[user.Email] = true
+			nicknames[user.Nickname] = true
+		}
+	}
+}
+
+func TestWithLocales(t *testing.T) {
+	t.Parallel()
+
+	for _, user := range New(7, WithLocales("pt_BR")).Generate(100) {
+		assert.Contains(t, []string{"BR", "PT"}, user.Country)
+	}
+
+	// Unknown locales fall back to every locale.
+	assert.Equal(t, New(7).Generate(10), New(7, WithLocales("xx_XX")).Generate(10))
+}
+
+func TestAsciiFold(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		given    string
+		expected string
+	}{
+		{given: "João", expected: "joao"},
+		{given: "Müller", expected: "muller"},
+		{given: "Lefèvre", expected: "lefevre"},
+		{given: "Łukasz", expected: "ukasz"},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, asciiFold(tc.given))
+	}
+}
+
+func TestLetterSuffix(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "a", letterSuffix(1))
+	assert.Equal(t, "z", letterSuffix(26))
+	assert.Equal(t, "aa", letterSuffix(27))
+	assert.Equal(t, "ba", letterSuffix(53))
+}
@@ -0,0 +1,58 @@
+package fakeusers
+
+// locale groups the names and the ISO 3166-1 alpha-2 countries
+// that are plausible together.
+type locale struct {
+	countries  []string
+	firstNames []string
+	lastNames  []string
+}
+
+var locales = map[string]locale{
+	"en_US": {
+		countries:  []string{"US", "GB", "CA", "AU", "IE", "NZ"},
+		firstNames: []string{"James", "Mary", "John", "Patricia", "Robert", "Jennifer", "Michael", "Linda", "William", "Elizabeth"},
+		lastNames:  []string{"Smith", "Johnson", "Williams", "Brown", "Jones", "Miller", "Davis", "Wilson", "Taylor", "Clark"},
+	},
+	"pt_BR": {
+		countries:  []string{"BR", "PT"},
+		firstNames: []string{"João", "Maria", "José", "Ana", "Lucas", "Juliana", "Gabriel", "Fernanda", "Rafael", "Letícia"},
+		lastNames:  []string{"Silva", "Santos", "Oliveira", "Souza", "Pereira", "Lima", "Carvalho", "Ferreira", "Gonçalves", "Araújo"},
+	},
+	"es_ES": {
+		countries:  []string{"ES", "MX", "AR", "CO", "CL"},
+		firstNames: []string{"Alejandro", "Lucía", "Pablo", "Sofía", "Javier", "Martina", "Diego", "Valentina", "Andrés", "Inés"},
+		lastNames:  []string{"García", "Fernández", "González", "Rodríguez", "López", "Martínez", "Sánchez", "Pérez", "Gómez", "Díaz"},
+	},
+	"de_DE": {
+		countries:  []string{"DE", "AT", "CH"},
+		firstNames: []string{"Lukas", "Anna", "Jonas", "Lena", "Felix", "Marie", "Paul", "Sophie", "Maximilian", "Jürgen"},
+		lastNames:  []string{"Müller", "Schmidt", "Schneider", "Fischer", "Weber", "Meyer", "Wagner", "Becker", "Schulz", "Hoffmann"},
+	},
+	"fr_FR": {
+		countries:  []string{"FR", "BE", "LU"},
+		firstNames: []string{"Louis", "Camille", "Hugo", "Léa", "Jules", "Chloé", "Arthur", "Manon", "Théo", "Zoé"},
+		lastNames:  []string{"Martin", "Bernard", "Dubois", "Thomas", "Robert", "Richard", "Petit", "Durand", "Leroy", "Lefèvre"},
+	},
+	"it_IT": {
+		countries:  []string{"IT", "SM"},
+		firstNames: []string{"Francesco", "Giulia", "Alessandro", "Chiara", "Lorenzo", "Sara", "Matteo", "Martina", "Niccolò", "Aurora"},
+		lastNames:  []string{"Rossi", "Russo", "Ferrari", "Esposito", "Bianchi", "Romano", "Colombo", "Ricci", "Marino", "Greco"},
+	},
+	"pl_PL": {
+		countries:  []string{"PL"},
+		firstNames: []string{"Jakub", "Zofia", "Antoni", "Maja", "Łukasz", "Hanna", "Szymon", "Małgorzata", "Wojciech", "Agnieszka"},
+		lastNames:  []string{"Nowak", "Kowalski", "Wiśniewski", "Wójcik", "Kowalczyk", "Kamiński", "Lewandowski", "Zieliński", "Szymański", "Woźniak"},
+	},
+}
+
+// allLocales returns every locale in a stable order so generation stays deterministic.
+func allLocales() []locale {
+	codes := Locales()
+
+	all := make([]locale, 0, len(codes))
+	for _, code := range codes {
+		all = append(all, locales[code])
+	}
+	return all
+}
@@ -0,0 +1,46 @@
+package uservalidation
+
+import (
+	"strings"
+	"time"
+)
+
+// BirthdateLayout is the layout of the birthdates, an ISO 8601 calendar date.
+const BirthdateLayout string = "2006-01-02"
+
+// maxPlausibleAge is the age in years above which a birthdate is a typo, not a user.
+const maxPlausibleAge int = 150
+
+// ParseBirthdate parses a birthdate in the YYYY-MM-DD format, at midnight UTC.
+func ParseBirthdate(birthdate string) (time.Time, error) {
+	date, err := time.Parse(BirthdateLayout, strings.TrimSpace(birthdate))
+	if err != nil {
+		return time.Time{}, ErrBirthdateFormat
+	}
+	return date, nil
+}
+
+// IsPlausibleBirthdate reports whether someone born on the birthdate could be a user
+// now: the birthdate is not in the future and at most maxPlausibleAge years ago.
+func IsPlausibleBirthdate(birthdate, now time.Time) bool {
+	today := date(now)
+	return !date(birthdate).After(today) && Age(birthdate, now) <= maxPlausibleAge
+}
+
+// Age returns the age in full years, now, of someone born on the birthdate. Someone
+// born on February 29 gets one year older on March 1 in the common years.
+func Age(birthdate, now time.Time) int {
+	birthdate, now = date(birthdate), date(now)
+
+	age := now.Year() - birthdate.Year()
+	if now.Month() < birthdate.Month() || (now.Month() == birthdate.Month() && now.Day() < birthdate.Day()) {
+		age--
+	}
+	return age
+}
+
+// date returns the calendar date of the time in UTC, at midnight.
+func date(t time.Time) time.Time {
+	year, month, day := t.UTC().Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,45 @@
+package uservalidation
+
+import (
+	"strings"
+	"time"
+
+	// The time zones are embedded, so they validate the same on the hosts without
+	// the IANA database, e.g. in the distroless images.
+	_ "time/tzdata"
+
+	"golang.org/x/text/language"
+)
+
+// IsLocale reports whether the locale is a well-formed and known BCP 47 language tag,
+// e.g. "pt-BR", ignoring case and accepting underscores as separators.
+func IsLocale(locale string) bool {
+	tag, err := language.Parse(strings.TrimSpace(locale))
+	return err == nil && tag != language.Und
+}
+
+// NormalizeLocale returns the locale in its canonical form, as it is stored, e.g. "pt-BR"
+// for "pt_br". Invalid locales are returned trimmed.
+func NormalizeLocale(locale string) string {
+	locale = strings.TrimSpace(locale)
+
+	tag, err := language.Parse(locale)
+	if err != nil {
+		return locale
+	}
+	return tag.String()
+}
+
+// IsTimezone reports whether the timezone is the name of an IANA time zone, e.g.
+// "America/Sao_Paulo" or "UTC". Names are case-sensitive.
+func IsTimezone(timezone string) bool {
+	timezone = strings.TrimSpace(timezone)
+
+	// LoadLocation returns the local time zone for both, which depends on the host.
+	if timezone == "" || timezone == "Local" {
+		return false
+	}
+
+	_, err := time.LoadLocation(timezone)
+	return err == nil
+}
@@ -0,0 +1,24 @@
+package uservalidation
+
+import (
+	"regexp"
+	"strings"
+)
+
+// phoneRegex matches the E.164 phone numbers: a plus sign, then up to 15 digits
+// starting with the country calling code, which never starts with 0.
+var phoneRegex = regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`)
+
+// phoneSeparators are the characters commonly used to group the digits of a phone
+// number, e.g. in "+1 (555) 010-0123", removed by NormalizePhone.
+var phoneSeparators = strings.NewReplacer(" ", "", "-", "", ".", "", "(", "", ")", "")
+
+// IsPhoneNumber reports whether the normalized phone number is in E.164 format.
+func IsPhoneNumber(phone string) bool {
+	return phoneRegex.MatchString(NormalizePhone(phone))
+}
+
+// NormalizePhone returns the phone number trimmed and without separators, as it is stored.
+func NormalizePhone(phone string) string {
+	return phoneSeparators.Replace(strings.TrimSpace(phone))
+}
@@ -0,0 +1,342 @@
+// Package uservalidation holds the validation rules of the user fields (names, email,
+// password, country, phone, locale, timezone and birthdate), so other services and tools
+// validate users exactly like the server does before calling it.
+package uservalidation
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+var (
+	ErrBirthdateFormat     = errors.New("birthdate must be a date in YYYY-MM-DD format")
+	ErrBirthdateInvalid    = errors.New("birthdate must be in the past and at most 150 years ago")
+	ErrCountryCodeInvalid  = errors.New("invalid country")
+	ErrCountryCodeRequired = errors.New("country is required")
+	ErrEmailFormat         = errors.New("email is invalid")
+	ErrEmailRequired       = errors.New("email is required")
+	ErrNameFormat          = errors.New("name must only contain letters, spaces, hyphens and apostrophes")
+	ErrNameLength          = errors.New("name length is out of bounds")
+	ErrNameRequired        = errors.New("name is required")
+	ErrLocaleFormat        = errors.New("locale must be a BCP 47 language tag, e.g. pt-BR")
+	ErrPasswordFormat      = errors.New("password does not contain the required characters")
+	ErrPasswordLength      = errors.New("password length is out of bounds")
+	ErrPasswordRequired    = errors.New("password is required")
+	ErrPhoneFormat         = errors.New("phone must be in E.164 format, e.g. +14155550123")
+	ErrTimezoneFormat      = errors.New("timezone must be an IANA time zone, e.g. America/Sao_Paulo")
+	ErrUnderMinimumAge     = errors.New("user is under the minimum age")
+)
+
+// Codes of the validation warnings.
+const (
+	WarningWeakPassword          string = "weak_password"
+	WarningDeprecatedCountryCode string = "deprecated_country_code"
+)
+
+// Warning is a non-fatal validation finding: the value is accepted, but a stricter
+// policy may reject it in the future.
+type Warning struct {
+	Field string
+	Code  string
+}
+
+// String returns the warning as "field:code".
+func (w Warning) String() string {
+	return w.Field + ":" + w.Code
+}
+
+// deprecatedCountryCodes are codes still in use that are not, or no longer, assigned
+// in ISO 3166-1, e.g. UK for the United Kingdom, which is GB.
+var deprecatedCountryCodes = map[string]bool{
+	"AN": true,
+	"CS": true,
+	"SU": true,
+	"TP": true,
+	"UK": true,
+	"YU": true,
+	"ZR": true,
+}
+
+// nameSeparators are the characters allowed in names besides letters and spaces by
+// default, e.g. in "Jean-Luc" and "O'Brien".
+var nameSeparators = map[rune]bool{
+	'-':      true,
+	'\'':     true,
+	'\u2019': true, // Right single quotation mark, the typographic apostrophe.
+}
+
+// Policy configures the validation rules. Name lengths are in characters of the normalized
+// name (see NormalizeName) and password lengths in bytes, like the server checks them.
+type Policy struct {
+	MinNameLength int
+	MaxNameLength int
+
+	// NameSeparators are the characters allowed in names besides letters and spaces.
+	// Empty allows hyphens and apostrophes, e.g. in "Jean-Luc" and "O'Brien".
+	NameSeparators string
+
+	MinPasswordLength int
+	MaxPasswordLength int
+
+	// PasswordRequireLetter, PasswordRequireNumber and PasswordRequireSpecial require at
+	// least one character of the class. Special characters are neither letters nor numbers.
+	PasswordRequireLetter  bool
+	PasswordRequireNumber  bool
+	PasswordRequireSpecial bool
+
+	// RecommendedPasswordLength is the length below which valid passwords get
+	// a weak password warning. Zero disables the warning.
+	RecommendedPasswordLength int
+
+	// MinAge is the age in years below which users are rejected, given their birthdate.
+	// Zero disables the check. The server enforces the age configured by its operator.
+	MinAge int
+}
+
+// DefaultPolicy is the policy enforced by the server.
+var DefaultPolicy = Policy{
+	MinNameLength:          2,
+	MaxNameLength:          50,
+	MinPasswordLength:      8,
+	MaxPasswordLength:      128,
+	PasswordRequireLetter:  true,
+	PasswordRequireNumber:  true,
+	PasswordRequireSpecial: true,
+
+	RecommendedPasswordLength: 12,
+}
+
+// User holds the fields validated by ValidateUser.
+type User struct {
+	FirstName string
+	LastName  string
+	Nickname  string
+	Email     string
+	Password  string
+	Country   string
+
+	// Phone, Locale, Timezone and Birthdate, in YYYY-MM-DD format, are optional.
+	Phone     string
+	Locale    string
+	Timezone  string
+	Birthdate string
+}
+
+// ValidateUser validates every field of the user and returns the first error.
+func (p Policy) ValidateUser(user User) error {
+	for _, name := range []string{user.FirstName, user.LastName, user.Nickname} {
+		if err := p.ValidateName(name); err != nil {
+			return err
+		}
+	}
+
+	if err := p.ValidateEmail(user.Email); err != nil {
+		return err
+	}
+
+	if err := p.ValidatePassword(user.Password); err != nil {
+		return err
+	}
+
+	if err := p.ValidateCountryCode(user.Country); err != nil {
+		return err
+	}
+
+	if user.Phone != "" {
+		if err := p.ValidatePhone(user.Phone); err != nil {
+			return err
+		}
+	}
+
+	if user.Locale != "" {
+		if err := p.ValidateLocale(user.Locale); err != nil {
+			return err
+		}
+	}
+
+	if user.Timezone != "" {
+		if err := p.ValidateTimezone(user.Timezone); err != nil {
+			return err
+		}
+	}
+
+	if user.Birthdate != "" {
+		return p.ValidateBirthdate(user.Birthdate)
+	}
+	return nil
+}
+
+// ValidateName validates a first name, last name or nickname: letters, including their
+// accents, spaces, hyphens and apostrophes, with at least one letter.
+func (p Policy) ValidateName(name string) error {
+	if name == "" {
+		return ErrNameRequired
+	}
+
+	name = NormalizeName(name)
+
+	var hasLetter bool
+	for _, char := range name {
+		switch {
+		case unicode.IsLetter(char):
+			hasLetter = true
+		case unicode.IsMark(char), unicode.IsSpace(char), p.isNameSeparator(char):
+		default:
+			return ErrNameFormat
+		}
+	}
+
+	if !hasLetter {
+		return ErrNameFormat
+	}
+
+	if n := utf8.RuneCountInString(name); n < p.MinNameLength || n > p.MaxNameLength {
+		return fmt.Errorf("%w: must be between %d and %d characters", ErrNameLength, p.MinNameLength, p.MaxNameLength)
+	}
+	return nil
+}
+
+// isNameSeparator reports whether the character is one of the name separators of the policy.
+func (p Policy) isNameSeparator(char rune) bool {
+	if p.NameSeparators == "" {
+		return nameSeparators[char]
+	}
+	return strings.ContainsRune(p.NameSeparators, char)
+}
+
+// NormalizeName returns the name in Unicode normalization form C, as it is stored, so
+// a name typed with combining accents is stored like the same name with precomposed ones.
+func NormalizeName(name string) string {
+	return norm.NFC.String(name)
+}
+
+// ValidateEmail validates an email address as defined by RFC 5322.
+func (p Policy) ValidateEmail(email string) error {
+	if email == "" {
+		return ErrEmailRequired
+	}
+
+	if _, err := mail.ParseAddress(email); err != nil {
+		return ErrEmailFormat
+	}
+	return nil
+}
+
+// ValidatePassword validates the length and the character classes of a password.
+func (p Policy) ValidatePassword(password string) error {
+	if password == "" {
+		return ErrPasswordRequired
+	}
+
+	if len(password) < p.MinPasswordLength || len(password) > p.MaxPasswordLength {
+		return fmt.Errorf("%w: must be between %d and %d characters", ErrPasswordLength, p.MinPasswordLength, p.MaxPasswordLength)
+	}
+
+	var hasNumber, hasLetter, hasSpecial bool
+	for _, char := range password {
+		if unicode.IsNumber(char) {
+			hasNumber = true
+		}
+		if unicode.IsLetter(char) {
+			hasLetter = true
+		}
+		if !unicode.IsLetter(char) && !unicode.IsNumber(char) {
+			hasSpecial = true
+		}
+	}
+
+	if (p.PasswordRequireLetter && !hasLetter) || (p.PasswordRequireNumber && !hasNumber) || (p.PasswordRequireSpecial && !hasSpecial) {
+		return ErrPasswordFormat
+	}
+	return nil
+}
+
+// ValidateCountryCode validates an ISO 3166-1 alpha-2 country code, ignoring case.
+func (p Policy) ValidateCountryCode(country string) error {
+	if country == "" {
+		return ErrCountryCodeRequired
+	}
+
+	if !IsCountryCode(country) {
+		return ErrCountryCodeInvalid
+	}
+	return nil
+}
+
+// ValidatePhone validates a phone number in E.164 format, ignoring the separators
+// (see NormalizePhone). Phone numbers are optional: check for empty ones first.
+func (p Policy) ValidatePhone(phone string) error {
+	if !IsPhoneNumber(phone) {
+		return ErrPhoneFormat
+	}
+	return nil
+}
+
+// ValidateLocale validates a BCP 47 language tag, ignoring case (see NormalizeLocale).
+// Locales are optional: check for empty ones first.
+func (p Policy) ValidateLocale(locale string) error {
+	if !IsLocale(locale) {
+		return ErrLocaleFormat
+	}
+	return nil
+}
+
+// ValidateTimezone validates the name of an IANA time zone. Time zones are optional:
+// check for empty ones first.
+func (p Policy) ValidateTimezone(timezone string) error {
+	if !IsTimezone(timezone) {
+		return ErrTimezoneFormat
+	}
+	return nil
+}
+
+// ValidateBirthdate validates a birthdate in YYYY-MM-DD format: it must be plausible (see
+// IsPlausibleBirthdate) and at least MinAge years ago. Birthdates are optional: check for
+// empty ones first.
+func (p Policy) ValidateBirthdate(birthdate string) error {
+	date, err := ParseBirthdate(birthdate)
+	if err != nil {
+		return err
+	}
+	return p.CheckAge(date, time.Now())
+}
+
+// CheckAge checks that the parsed birthdate is plausible and at least MinAge years ago.
+func (p Policy) CheckAge(birthdate, now time.Time) error {
+	if !IsPlausibleBirthdate(birthdate, now) {
+		return ErrBirthdateInvalid
+	}
+
+	if Age(birthdate, now) < p.MinAge {
+		return fmt.Errorf("%w: must be at least %d years old", ErrUnderMinimumAge, p.MinAge)
+	}
+	return nil
+}
+
+// Warnings returns the warnings of the fields of a valid user.
+func (p Policy) Warnings(user User) []Warning {
+	return append(p.PasswordWarnings(user.Password), p.CountryCodeWarnings(user.Country)...)
+}
+
+// PasswordWarnings returns the warnings of a valid password.
+func (p Policy) PasswordWarnings(password string) []Warning {
+	if len(password) < p.RecommendedPasswordLength {
+		return []Warning{{Field: "password", Code: WarningWeakPassword}}
+	}
+	return nil
+}
+
+// CountryCodeWarnings returns the warnings of a valid country code.
+func (p Policy) CountryCodeWarnings(country string) []Warning {
+	if deprecatedCountryCodes[NormalizeCountryCode(country)] {
+		return []Warning{{Field: "country", Code: WarningDeprecatedCountryCode}}
+	}
+	return nil
+}
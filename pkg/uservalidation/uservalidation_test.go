@@ -0,0 +1,395 @@
+package uservalidation
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicyValidateUser(t *testing.T) {
+	t.Parallel()
+
+	valid := func() User {
+		return User{
+			FirstName: "John",
+			LastName:  "Doe",
+			Nickname:  "jdoe",
+			Email:     "joedoe@foo.bar",
+			Password:  "password1!",
+			Country:   "US",
+		}
+	}
+
+	testCases := []struct {
+		name        string
+		policy      Policy
+		given       func(u *User)
+		expectedErr error
+	}{
+		{
+			name:   "valid user",
+			policy: DefaultPolicy,
+			given:  func(u *User) {},
+		},
+		{
+			name:        "name with digits",
+			policy:      DefaultPolicy,
+			given:       func(u *User) { u.LastName = "Doe2" },
+			expectedErr: ErrNameFormat,
+		},
+		{
+			name:        "short nickname",
+			policy:      DefaultPolicy,
+			given:       func(u *User) { u.Nickname = "j" },
+			expectedErr: ErrNameLength,
+		},
+		{
+			name:        "invalid email",
+			policy:      DefaultPolicy,
+			given:       func(u *User) { u.Email = "joedoe" },
+			expectedErr: ErrEmailFormat,
+		},
+		{
+			name:        "password without special characters",
+			policy:      DefaultPolicy,
+			given:       func(u *User) { u.Password = "password1" },
+			expectedErr: ErrPasswordFormat,
+		},
+		{
+			name:        "missing country",
+			policy:      DefaultPolicy,
+			given:       func(u *User) { u.Country = "" },
+			expectedErr: ErrCountryCodeRequired,
+		},
+		{
+			name:   "names with hyphens and apostrophes",
+			policy: DefaultPolicy,
+			given: func(u *User) {
+				u.FirstName = "Jean-Luc"
+				u.LastName = "O’Brien"
+				u.Nickname = "d'Artagnan"
+			},
+		},
+		{
+			name:   "long name in characters but not in bytes",
+			policy: DefaultPolicy,
+			given:  func(u *User) { u.LastName = strings.Repeat("王", 20) },
+		},
+		{
+			name:        "short name in characters but not in bytes",
+			policy:      DefaultPolicy,
+			given:       func(u *User) { u.FirstName = "Ł" },
+			expectedErr: ErrNameLength,
+		},
+		{
+			name:   "name with combining accents",
+			policy: DefaultPolicy,
+			given:  func(u *User) { u.FirstName = "Zoe\u0301" },
+		},
+		{
+			name:        "name without letters",
+			policy:      DefaultPolicy,
+			given:       func(u *User) { u.Nickname = "-'-" },
+			expectedErr: ErrNameFormat,
+		},
+		{
+			name:        "unassigned country",
+			policy:      DefaultPolicy,
+			given:       func(u *User) { u.Country = "ZZ" },
+			expectedErr: ErrCountryCodeInvalid,
+		},
+		{
+			name:        "country code with a digit",
+			policy:      DefaultPolicy,
+			given:       func(u *User) { u.Country = "A1" },
+			expectedErr: ErrCountryCodeInvalid,
+		},
+		{
+			name:   "lower case country",
+			policy: DefaultPolicy,
+			given:  func(u *User) { u.Country = "br" },
+		},
+		{
+			name:   "deprecated country",
+			policy: DefaultPolicy,
+			given:  func(u *User) { u.Country = "UK" },
+		},
+		{
+			name:   "phone with separators",
+			policy: DefaultPolicy,
+			given:  func(u *User) { u.Phone = "+1 (415) 555-0123" },
+		},
+		{
+			name:        "phone without country calling code",
+			policy:      DefaultPolicy,
+			given:       func(u *User) { u.Phone = "4155550123" },
+			expectedErr: ErrPhoneFormat,
+		},
+		{
+			name:        "phone too long",
+			policy:      DefaultPolicy,
+			given:       func(u *User) { u.Phone = "+1415555012345678" },
+			expectedErr: ErrPhoneFormat,
+		},
+		{
+			name:        "phone with letters",
+			policy:      DefaultPolicy,
+			given:       func(u *User) { u.Phone = "+1415CALLNOW" },
+			expectedErr: ErrPhoneFormat,
+		},
+		{
+			name:   "locale with underscore",
+			policy: DefaultPolicy,
+			given:  func(u *User) { u.Locale = "pt_br" },
+		},
+		{
+			name:        "unknown locale",
+			policy:      DefaultPolicy,
+			given:       func(u *User) { u.Locale = "xx-YY" },
+			expectedErr: ErrLocaleFormat,
+		},
+		{
+			name:        "malformed locale",
+			policy:      DefaultPolicy,
+			given:       func(u *User) { u.Locale = "english" },
+			expectedErr: ErrLocaleFormat,
+		},
+		{
+			name:   "timezone",
+			policy: DefaultPolicy,
+			given:  func(u *User) { u.Timezone = "America/Sao_Paulo" },
+		},
+		{
+			name:        "unknown timezone",
+			policy:      DefaultPolicy,
+			given:       func(u *User) { u.Timezone = "Mars/Olympus_Mons" },
+			expectedErr: ErrTimezoneFormat,
+		},
+		{
+			name:        "local timezone",
+			policy:      DefaultPolicy,
+			given:       func(u *User) { u.Timezone = "Local" },
+			expectedErr: ErrTimezoneFormat,
+		},
+		{
+			name:   "birthdate",
+			policy: DefaultPolicy,
+			given:  func(u *User) { u.Birthdate = "1990-02-28" },
+		},
+		{
+			name:        "birthdate in another format",
+			policy:      DefaultPolicy,
+			given:       func(u *User) { u.Birthdate = "28/02/1990" },
+			expectedErr: ErrBirthdateFormat,
+		},
+		{
+			name:        "birthdate in the future",
+			policy:      DefaultPolicy,
+			given:       func(u *User) { u.Birthdate = time.Now().AddDate(0, 0, 2).Format(BirthdateLayout) },
+			expectedErr: ErrBirthdateInvalid,
+		},
+		{
+			name:        "implausible birthdate",
+			policy:      DefaultPolicy,
+			given:       func(u *User) { u.Birthdate = "1800-01-01" },
+			expectedErr: ErrBirthdateInvalid,
+		},
+		{
+			name: "user under the minimum age",
+			policy: func() Policy {
+				p := DefaultPolicy
+				p.MinAge = 13
+				return p
+			}(),
+			given:       func(u *User) { u.Birthdate = time.Now().AddDate(-12, 0, 0).Format(BirthdateLayout) },
+			expectedErr: ErrUnderMinimumAge,
+		},
+		{
+			name: "password allowed by a relaxed policy",
+			policy: func() Policy {
+				p := DefaultPolicy
+				p.PasswordRequireSpecial = false
+				return p
+			}(),
+			given: func(u *User) { u.Password = "password1" },
+		},
+		{
+			name: "password too short for a stricter policy",
+			policy: func() Policy {
+				p := DefaultPolicy
+				p.MinPasswordLength = 12
+				return p
+			}(),
+			given:       func(u *User) { u.Password = "password1!" },
+			expectedErr: ErrPasswordLength,
+		},
+		{
+			name: "name separator allowed by the policy",
+			policy: func() Policy {
+				p := DefaultPolicy
+				p.NameSeparators = "."
+				return p
+			}(),
+			given: func(u *User) { u.FirstName = "J. R." },
+		},
+		{
+			name: "default name separator not allowed by the policy",
+			policy: func() Policy {
+				p := DefaultPolicy
+				p.NameSeparators = "."
+				return p
+			}(),
+			given:       func(u *User) { u.FirstName = "Jean-Luc" },
+			expectedErr: ErrNameFormat,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Arrange
+			user := valid()
+			tc.given(&user)
+
+			// Act
+			err := tc.policy.ValidateUser(user)
+
+			// Assert
+			if tc.expectedErr == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.True(t, errors.Is(err, tc.expectedErr), "expected %v, got %v", tc.expectedErr, err)
+		})
+	}
+}
+
+func TestPolicyWarnings(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		policy   Policy
+		given    User
+		expected []Warning
+	}{
+		{
+			name:   "no warnings",
+			policy: DefaultPolicy,
+			given:  User{Password: "long-passw0rd!", Country: "GB"},
+		},
+		{
+			name:     "weak password",
+			policy:   DefaultPolicy,
+			given:    User{Password: "passw0rd!", Country: "GB"},
+			expected: []Warning{{Field: "password", Code: WarningWeakPassword}},
+		},
+		{
+			name:   "every warning",
+			policy: DefaultPolicy,
+			given:  User{Password: "passw0rd!", Country: "UK"},
+			expected: []Warning{
+				{Field: "password", Code: WarningWeakPassword},
+				{Field: "country", Code: WarningDeprecatedCountryCode},
+			},
+		},
+		{
+			name: "weak password warning disabled",
+			policy: func() Policy {
+				p := DefaultPolicy
+				p.RecommendedPasswordLength = 0
+				return p
+			}(),
+			given: User{Password: "passw0rd!", Country: "GB"},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Act
+			observed := tc.policy.Warnings(tc.given)
+
+			// Assert
+			assert.Equal(t, tc.expected, observed)
+		})
+	}
+}
+
+func TestNormalizeName(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "Zo\u00e9", NormalizeName("Zoe\u0301"))
+	assert.Equal(t, "Zo\u00e9", NormalizeName("Zo\u00e9"))
+}
+
+func TestNormalizePhone(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "+14155550123", NormalizePhone(" +1 (415) 555-0123 "))
+	assert.Equal(t, "+5511999999999", NormalizePhone("+55.11.99999.9999"))
+}
+
+func TestNormalizeLocale(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "pt-BR", NormalizeLocale(" pt_br "))
+	assert.Equal(t, "zh-Hant-TW", NormalizeLocale("ZH-hant-tw"))
+	assert.Equal(t, "english", NormalizeLocale("english"))
+}
+
+func TestAge(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name      string
+		birthdate string
+		now       time.Time
+		expected  int
+	}{
+		{
+			name:      "the day before the birthday",
+			birthdate: "2000-06-15",
+			now:       time.Date(2013, time.June, 14, 23, 59, 0, 0, time.UTC),
+			expected:  12,
+		},
+		{
+			name:      "on the birthday",
+			birthdate: "2000-06-15",
+			now:       time.Date(2013, time.June, 15, 0, 0, 0, 0, time.UTC),
+			expected:  13,
+		},
+		{
+			name:      "born on February 29, on February 28 of a common year",
+			birthdate: "2000-02-29",
+			now:       time.Date(2013, time.February, 28, 12, 0, 0, 0, time.UTC),
+			expected:  12,
+		},
+		{
+			name:      "born on February 29, on March 1 of a common year",
+			birthdate: "2000-02-29",
+			now:       time.Date(2013, time.March, 1, 12, 0, 0, 0, time.UTC),
+			expected:  13,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			birthdate, err := ParseBirthdate(tc.birthdate)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expected, Age(birthdate, tc.now))
+		})
+	}
+}
@@ -0,0 +1,177 @@
+// Package usrsvcclient provides helpers for the Go clients of the user service.
+package usrsvcclient
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	apiv1 "github.com/alesr/usrsvc/proto/users/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// MaxPageSize is the largest page the server returns. Larger page sizes are capped.
+	MaxPageSize int32 = 100
+
+	defaultMaxRetries   int           = 3
+	defaultRetryBackoff time.Duration = 200 * time.Millisecond
+)
+
+// Done is returned by Next when the iteration is complete.
+var Done = errors.New("no more items in iterator")
+
+// PageInfo describes the page the iterator is on.
+type PageInfo struct {
+	// Token is the page token of the current page. Pass it as page_token to
+	// resume the iteration from the start of this page.
+	Token string
+
+	// NextPageToken is the token of the next page, empty on the last page.
+	NextPageToken string
+
+	// TotalSize is the number of users matching the filters, set when the
+	// request asks for include_total_size.
+	TotalSize *int64
+}
+
+// IteratorOption configures a ListUsersIterator.
+type IteratorOption func(*ListUsersIterator)
+
+// WithMaxRetries sets how many times a page is retried after a transient error
+// (Unavailable, ResourceExhausted or DeadlineExceeded). Defaults to 3.
+func WithMaxRetries(retries int) IteratorOption {
+	return func(it *ListUsersIterator) {
+		it.maxRetries = retries
+	}
+}
+
+// WithRetryBackoff sets the wait before the first retry, doubled on each retry. Defaults to 200ms.
+func WithRetryBackoff(backoff time.Duration) IteratorOption {
+	return func(it *ListUsersIterator) {
+		it.retryBackoff = backoff
+	}
+}
+
+// ListUsersIterator iterates over the users returned by ListUsers, fetching
+// the pages as needed. It is not safe for concurrent use.
+//
+//	it := usrsvcclient.ListUsers(ctx, client, &apiv1.ListUsersRequest{Country: "PT"})
+//	for {
+//		user, err := it.Next()
+//		if errors.Is(err, usrsvcclient.Done) {
+//			break
+//		}
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+type ListUsersIterator struct {
+	ctx    context.Context
+	client apiv1.UserServiceClient
+	req    *apiv1.ListUsersRequest
+
+	maxRetries   int
+	retryBackoff time.Duration
+
+	users    []*apiv1.User
+	pageInfo PageInfo
+	started  bool
+	err      error
+}
+
+// ListUsers returns an iterator over the users matching the request, starting
+// from its page token. The request is not modified. Page sizes that are not set
+// or above MaxPageSize are set to MaxPageSize.
+func ListUsers(ctx context.Context, client apiv1.UserServiceClient, req *apiv1.ListUsersRequest, opts ...IteratorOption) *ListUsersIterator {
+	cloned := proto.Clone(req).(*apiv1.ListUsersRequest)
+	if cloned.PageSize <= 0 || cloned.PageSize > MaxPageSize {
+		cloned.PageSize = MaxPageSize
+	}
+
+	it := ListUsersIterator{
+		ctx:          ctx,
+		client:       client,
+		req:          cloned,
+		maxRetries:   defaultMaxRetries,
+		retryBackoff: defaultRetryBackoff,
+		pageInfo:     PageInfo{NextPageToken: cloned.PageToken},
+	}
+
+	for _, opt := range opts {
+		opt(&it)
+	}
+	return &it
+}
+
+// Next returns the next user. It returns Done when there are no more users, and
+// keeps returning the same error once the iteration failed.
+func (it *ListUsersIterator) Next() (*apiv1.User, error) {
+	for len(it.users) == 0 {
+		if it.err != nil {
+			return nil, it.err
+		}
+
+		if it.started && it.pageInfo.NextPageToken == "" {
+			it.err = Done
+			return nil, Done
+		}
+
+		if err := it.fetch(); err != nil {
+			it.err = err
+			return nil, err
+		}
+	}
+
+	user := it.users[0]
+	it.users = it.users[1:]
+	return user, nil
+}
+
+// PageInfo returns the information of the current page.
+func (it *ListUsersIterator) PageInfo() PageInfo {
+	return it.pageInfo
+}
+
+// fetch loads the next page, retrying transient errors.
+func (it *ListUsersIterator) fetch() error {
+	it.req.PageToken = it.pageInfo.NextPageToken
+
+	backoff := it.retryBackoff
+	for attempt := 0; ; attempt++ {
+		resp, err := it.client.ListUsers(it.ctx, it.req)
+		if err == nil {
+			it.started = true
+			it.users = resp.Users
+			it.pageInfo = PageInfo{
+				Token:         it.req.PageToken,
+				NextPageToken: resp.NextPageToken,
+				TotalSize:     resp.TotalSize,
+			}
+			return nil
+		}
+
+		if attempt >= it.maxRetries || !retryable(err) {
+			return err
+		}
+
+		select {
+		case <-it.ctx.Done():
+			return it.ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func retryable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.ResourceExhausted, codes.DeadlineExceeded:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,159 @@
+package usrsvcclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+
+	apiv1 "github.com/alesr/usrsvc/proto/users/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// clientStub serves ListUsers from users, using the index of the next user as page token.
+// The first failures calls fail with Unavailable.
+type clientStub struct {
+	apiv1.UserServiceClient
+
+	users    []*apiv1.User
+	failures int
+	requests []*apiv1.ListUsersRequest
+}
+
+func (c *clientStub) ListUsers(ctx context.Context, req *apiv1.ListUsersRequest, opts ...grpc.CallOption) (*apiv1.ListUsersResponse, error) {
+	c.requests = append(c.requests, req)
+
+	if c.failures > 0 {
+		c.failures--
+		return nil, status.Error(codes.Unavailable, "unavailable")
+	}
+
+	var start int
+	if req.PageToken != "" {
+		start, _ = strconv.Atoi(req.PageToken)
+	}
+
+	end := start + int(req.PageSize)
+	if end > len(c.users) {
+		end = len(c.users)
+	}
+
+	resp := apiv1.ListUsersResponse{Users: c.users[start:end]}
+	if end < len(c.users) {
+		resp.NextPageToken = strconv.Itoa(end)
+	}
+	return &resp, nil
+}
+
+func newUsersHelper(t *testing.T, n int) []*apiv1.User {
+	t.Helper()
+
+	users := make([]*apiv1.User, n)
+	for i := range users {
+		users[i] = &apiv1.User{Id: fmt.Sprintf("user-%d", i)}
+	}
+	return users
+}
+
+func collectHelper(t *testing.T, it *ListUsersIterator) ([]string, error) {
+	t.Helper()
+
+	var ids []string
+	for {
+		user, err := it.Next()
+		if err != nil {
+			return ids, err
+		}
+		ids = append(ids, user.Id)
+	}
+}
+
+func TestListUsersIterator(t *testing.T) {
+	t.Parallel()
+
+	t.Run("iterates over every page", func(t *testing.T) {
+		t.Parallel()
+
+		// Arrange
+		client := clientStub{users: newUsersHelper(t, 5)}
+		req := apiv1.ListUsersRequest{Country: "PT", PageSize: 2}
+
+		// Act
+		ids, err := collectHelper(t, ListUsers(context.TODO(), &client, &req))
+
+		// Assert
+		assert.True(t, errors.Is(err, Done))
+		assert.Equal(t, []string{"user-0", "user-1", "user-2", "user-3", "user-4"}, ids)
+		require.Len(t, client.requests, 3)
+		assert.Equal(t, "PT", client.requests[2].Country)
+		assert.Empty(t, req.PageToken, "the request must not be modified")
+	})
+
+	t.Run("caps the page size", func(t *testing.T) {
+		t.Parallel()
+
+		// Arrange
+		client := clientStub{users: newUsersHelper(t, 1)}
+
+		// Act
+		_, err := collectHelper(t, ListUsers(context.TODO(), &client, &apiv1.ListUsersRequest{PageSize: 1000}))
+
+		// Assert
+		assert.True(t, errors.Is(err, Done))
+		assert.Equal(t, MaxPageSize, client.requests[0].PageSize)
+	})
+
+	t.Run("exposes the page info", func(t *testing.T) {
+		t.Parallel()
+
+		// Arrange
+		client := clientStub{users: newUsersHelper(t, 3)}
+		it := ListUsers(context.TODO(), &client, &apiv1.ListUsersRequest{PageSize: 2})
+
+		// Act
+		for i := 0; i < 3; i++ {
+			_, err := it.Next()
+			require.NoError(t, err)
+		}
+
+		// Assert
+		assert.Equal(t, PageInfo{Token: "2"}, it.PageInfo())
+	})
+
+	t.Run("retries transient errors", func(t *testing.T) {
+		t.Parallel()
+
+		// Arrange
+		client := clientStub{users: newUsersHelper(t, 2), failures: 2}
+
+		// Act
+		ids, err := collectHelper(t, ListUsers(context.TODO(), &client, &apiv1.ListUsersRequest{}, WithRetryBackoff(0)))
+
+		// Assert
+		assert.True(t, errors.Is(err, Done))
+		assert.Len(t, ids, 2)
+		assert.Len(t, client.requests, 3)
+	})
+
+	t.Run("gives up after the max retries", func(t *testing.T) {
+		t.Parallel()
+
+		// Arrange
+		client := clientStub{users: newUsersHelper(t, 2), failures: 5}
+		it := ListUsers(context.TODO(), &client, &apiv1.ListUsersRequest{}, WithMaxRetries(1), WithRetryBackoff(0))
+
+		// Act
+		_, err := it.Next()
+		_, again := it.Next()
+
+		// Assert
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+		assert.Equal(t, err, again)
+		assert.Len(t, client.requests, 2)
+	})
+}
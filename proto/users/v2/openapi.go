@@ -0,0 +1,9 @@
+package apiv2
+
+import _ "embed"
+
+// OpenAPI is the OpenAPI v2 document of the API, generated from user.proto with
+// protoc-gen-openapiv2 by make proto.
+//
+//go:embed user.swagger.json
+var OpenAPI []byte
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/alesr/usrsvc/internal/changes"
+	userrepo "github.com/alesr/usrsvc/internal/users/repository"
+	userservice "github.com/alesr/usrsvc/internal/users/service"
+	"go.uber.org/zap"
+)
+
+const replayUsage = "usage: usrsvc replay [-since <change id>]"
+
+// userReplayer is the service the change log is replayed with.
+type userReplayer interface {
+	ReplayChanges(ctx context.Context, since int64, projection userservice.Projection) (int64, error)
+}
+
+// runReplay is `usrsvc replay`, rebuilding the users of the configured database from its
+// change log (see CHANGE_LOG_ENABLED), e.g. after restoring the user_changes table alone
+// from a backup. The users are written to the primary store as they were after their last
+// change; with -since, the replay resumes after the given change.
+func runReplay(ctx context.Context, logger *zap.Logger, cfg *config, args []string) error {
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+
+	since := flags.Int64("since", 0, "id of the last change replayed")
+	if err := flags.Parse(args); err != nil || flags.NArg() > 0 || *since < 0 {
+		return errors.New(replayUsage)
+	}
+
+	store, changeLog, closeStore, err := openReplayStore(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+
+	svc := userservice.NewServiceDefault(logger, store, userservice.WithChangeLog(changeLog))
+	return replayChanges(ctx, logger, svc, userservice.NewUsersProjection(store), *since)
+}
+
+// replayChanges replays the change log into the projection and logs where to resume from on error.
+func replayChanges(ctx context.Context, logger *zap.Logger, svc userReplayer, projection userservice.Projection, since int64) error {
+	last, err := svc.ReplayChanges(ctx, since, projection)
+	if err != nil {
+		return fmt.Errorf("could not replay the change log, resume with -since %d: %w", last, err)
+	}
+
+	logger.Info("replay done", zap.Int64("last_change", last))
+	return nil
+}
+
+// openReplayStore opens the primary store of the configured database with its change log.
+// The in-memory store has nothing to replay.
+func openReplayStore(ctx context.Context, cfg *config) (userrepo.Store, userservice.ChangeLog, func(), error) {
+	switch cfg.DBDriver {
+	case memoryDriverName:
+		return nil, nil, nil, fmt.Errorf("the '%s' driver has no change log to replay", memoryDriverName)
+	case mongoDriverName:
+		client, err := connectMongo(ctx, cfg)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("could not connect to mongo: %w", err)
+		}
+
+		closeStore := func() { client.Disconnect(context.Background()) }
+
+		store := userrepo.NewMongo(client, cfg.MongoDatabase)
+		if err := store.EnsureIndexes(ctx); err != nil {
+			closeStore()
+			return nil, nil, nil, fmt.Errorf("could not create mongo indexes: %w", err)
+		}
+		return store, changes.NewMongo(client.Database(cfg.MongoDatabase)), closeStore, nil
+	}
+
+	db, err := openDB(cfg)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("could not open the primary database: %w", err)
+	}
+
+	closeStore := func() { db.Close() }
+
+	if _, err := migrateSchema(db, cfg.DBDriver, cfg.AutoMigrate); err != nil {
+		closeStore()
+		return nil, nil, nil, fmt.Errorf("could not migrate the schema: %w", err)
+	}
+
+	if cfg.DBDriver == sqliteDriverName {
+		return userrepo.NewSQLite(db), changes.NewPostgres(db), closeStore, nil
+	}
+	return userrepo.NewPostgres(db), changes.NewPostgres(db), closeStore, nil
+}
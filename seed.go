@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"time"
+
+	userservice "github.com/alesr/usrsvc/internal/users/service"
+	"github.com/alesr/usrsvc/pkg/fakeusers"
+	"go.uber.org/zap"
+)
+
+const (
+	// seedRandomSeed is the seed of the fake users, so every environment gets the same ones.
+	seedRandomSeed int64 = 1
+
+	// seedCreatedWithin spreads the creation dates of the fake users over the past year,
+	// so the lists, stats and filters have something to show.
+	seedCreatedWithin time.Duration = 365 * 24 * time.Hour
+)
+
+// userSeeder is the service the fake users are imported with.
+type userSeeder interface {
+	Count(ctx context.Context, filter userservice.FilterParams) (int64, error)
+	ImportUsers(ctx context.Context, next func() (*userservice.ImportRecord, error)) (*userservice.ImportReport, error)
+}
+
+// runSeed fills an empty store with n fake users for the development and demo environments.
+// The users go through ImportUsers, so they are validated, audited and their events published
+// like any other. A store with users is left as it is, so restarts don't seed it again.
+// Unless a password is given, the users get random passwords.
+func runSeed(ctx context.Context, logger *zap.Logger, svc userSeeder, n int, locales []string, password string) error {
+	count, err := svc.Count(ctx, userservice.FilterParams{})
+	if err != nil {
+		return fmt.Errorf("could not count users: %w", err)
+	}
+
+	if count > 0 {
+		logger.Info("seeding skipped, the store has users", zap.Int64("users", count))
+		return nil
+	}
+
+	gen := fakeusers.New(seedRandomSeed, fakeusers.WithLocales(locales...))
+	rnd := rand.New(rand.NewSource(seedRandomSeed))
+	now := time.Now()
+
+	var seeded int
+	report, err := svc.ImportUsers(ctx, func() (*userservice.ImportRecord, error) {
+		if seeded == n {
+			return nil, io.EOF
+		}
+		seeded++
+
+		fake := gen.Next()
+		if password != "" {
+			fake.Password = password
+		}
+
+		return &userservice.ImportRecord{
+			User: &userservice.User{
+				FirstName: fake.FirstName,
+				LastName:  fake.LastName,
+				Nickname:  fake.Nickname,
+				Email:     fake.Email,
+				Password:  fake.Password,
+				Country:   fake.Country,
+				CreatedAt: now.Add(-time.Duration(rnd.Int63n(int64(seedCreatedWithin)))),
+			},
+		}, nil
+	})
+	if err != nil {
+		return fmt.Errorf("could not import fake users: %w", err)
+	}
+
+	for _, importErr := range report.Errors {
+		logger.Warn("failed to seed user", zap.String("email", importErr.Email), zap.Error(importErr.Err))
+	}
+
+	logger.Info("seeded fake users", zap.Int64("imported", report.Imported), zap.Int64("failed", report.Failed))
+	return nil
+}
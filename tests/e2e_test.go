@@ -9,10 +9,13 @@ import (
 	"testing"
 
 	apiv1 "github.com/alesr/usrsvc/proto/users/v1"
+	apiv2 "github.com/alesr/usrsvc/proto/users/v2"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
 )
 
 func Test_E2E(t *testing.T) {
@@ -65,6 +68,24 @@ func Test_E2E(t *testing.T) {
 	assert.Equal(t, expectedCreateResp.User.Email, observedCreateResp.User.Email)
 	assert.Equal(t, expectedCreateResp.User.Country, observedCreateResp.User.Country)
 
+	// The user can authenticate with the password given on creation,
+	// and the response carries the same user (but obviously not the hash).
+
+	observedAuthResp, err := grpcClient.Authenticate(context.TODO(), &apiv1.AuthenticateRequest{
+		Email:    givenCreateReq.Email,
+		Password: givenCreateReq.Password,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, observedCreateResp.User.Id, observedAuthResp.User.Id)
+
+	_, err = grpcClient.Authenticate(context.TODO(), &apiv1.AuthenticateRequest{
+		Email:    givenCreateReq.Email,
+		Password: "wr0ngP@ssw0rd",
+	})
+	require.Error(t, err)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
 	// Second, get the user
 
 	givenGetReq := &apiv1.GetUserRequest{Id: observedCreateResp.User.Id}
@@ -128,7 +149,6 @@ func Test_E2E(t *testing.T) {
 		LastName:  "Jordan",
 		Nickname:  "magic",
 		Email:     "magic@foo.bar",
-		Password:  "s0meP@ssw0rd2",
 		Country:   "BR",
 	}
 
@@ -169,3 +189,64 @@ func Test_E2E(t *testing.T) {
 	assert.True(t, errors.Is(err, status.Error(codes.NotFound, "user not found")))
 	assert.Nil(t, observedGetResp)
 }
+
+func Test_E2E_V2(t *testing.T) {
+	db := setupDBHelper(t)
+	defer teardownDBHelper(t, db)
+
+	stopServer := startGRPCServerHelper(t, db)
+	defer stopServer()
+
+	grpcClient, close := setupGRPClientV2Helper(t)
+	defer func() {
+		err := close()
+		require.NoError(t, err)
+	}()
+
+	grpcClientV1, closeV1 := setupGRPClientHelper(t)
+	defer func() {
+		err := closeV1()
+		require.NoError(t, err)
+	}()
+
+	// Users created in v2 are served by v1, the two versions share the users.
+
+	observedCreateResp, err := grpcClient.CreateUser(context.TODO(), &apiv2.CreateUserRequest{
+		User: &apiv2.User{
+			FirstName: "Michael",
+			LastName:  "Jackson",
+			Nickname:  "mj",
+			Email:     "mj@foo.bar",
+			Country:   "US",
+			Locale:    proto.String("en-US"),
+		},
+		Password: "s0meP@ssw0rd",
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, apiv2.Role_ROLE_USER, observedCreateResp.User.Role)
+	assert.Equal(t, apiv2.UserStatus_USER_STATUS_ACTIVE, observedCreateResp.User.Status)
+	assert.Nil(t, observedCreateResp.User.Phone)
+
+	observedGetResp, err := grpcClientV1.GetUser(context.TODO(), &apiv1.GetUserRequest{Id: observedCreateResp.User.Id})
+	require.NoError(t, err)
+
+	assert.Equal(t, "mj@foo.bar", observedGetResp.User.Email)
+
+	// Only the fields of the update mask change.
+
+	observedUpdateResp, err := grpcClient.UpdateUser(context.TODO(), &apiv2.UpdateUserRequest{
+		User:       &apiv2.User{Id: observedCreateResp.User.Id, Nickname: "magic"},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"nickname"}},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "magic", observedUpdateResp.User.Nickname)
+	assert.Equal(t, "Michael", observedUpdateResp.User.FirstName)
+	assert.Equal(t, "en-US", observedUpdateResp.User.GetLocale())
+
+	observedHealthResp, err := grpcClient.CheckHealth(context.TODO(), &apiv2.CheckHealthRequest{})
+	require.NoError(t, err)
+
+	assert.Equal(t, apiv2.CheckHealthResponse_SERVING_STATUS_SERVING, observedHealthResp.Status)
+}
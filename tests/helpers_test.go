@@ -9,6 +9,7 @@ import (
 	"github.com/alesr/usrsvc/internal/users/repository"
 	"github.com/alesr/usrsvc/internal/users/service"
 	apiv1 "github.com/alesr/usrsvc/proto/users/v1"
+	apiv2 "github.com/alesr/usrsvc/proto/users/v2"
 	"github.com/jmoiron/sqlx"
 	"github.com/pressly/goose/v3"
 	"github.com/stretchr/testify/require"
@@ -32,17 +33,17 @@ func startGRPCServerHelper(t *testing.T, db *sqlx.DB) func() {
 
 	grpcServer := grpc.NewServer()
 
-	grpcServer.RegisterService(
-		&apiv1.UserService_ServiceDesc,
-		app.NewGRPCServer(
+	grpcServerV1 := app.NewGRPCServer(
+		zap.NewNop(),
+		service.NewServiceDefault(
 			zap.NewNop(),
-			service.NewServiceDefault(
-				zap.NewNop(),
-				repository.NewPostgres(db),
-			),
+			repository.NewPostgres(db),
 		),
 	)
 
+	grpcServer.RegisterService(&apiv1.UserService_ServiceDesc, grpcServerV1)
+	grpcServer.RegisterService(&apiv2.UserService_ServiceDesc, app.NewGRPCServerV2(grpcServerV1))
+
 	lis, err := net.Listen("tcp", grpcPort)
 	require.NoError(t, err)
 
@@ -63,6 +64,15 @@ func setupGRPClientHelper(t *testing.T) (apiv1.UserServiceClient, func() error)
 	return apiv1.NewUserServiceClient(conn), conn.Close
 }
 
+func setupGRPClientV2Helper(t *testing.T) (apiv2.UserServiceClient, func() error) {
+	t.Helper()
+
+	conn, err := grpc.Dial(grpcPort, grpc.WithInsecure())
+	require.NoError(t, err)
+
+	return apiv2.NewUserServiceClient(conn), conn.Close
+}
+
 func setupDBHelper(t *testing.T) *sqlx.DB {
 	t.Helper()
 
@@ -79,7 +89,7 @@ func setupDBHelper(t *testing.T) *sqlx.DB {
 func teardownDBHelper(t *testing.T, db *sqlx.DB) {
 	t.Helper()
 
-	_, err := db.Exec("TRUNCATE TABLE users CASCADE")
+	_, err := db.Exec("TRUNCATE TABLE users, user_tombstones CASCADE")
 	require.NoError(t, err)
 
 	require.NoError(t, db.Close())
@@ -0,0 +1,215 @@
+//go:build soak
+// +build soak
+
+package tests
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alesr/usrsvc/pkg/fakeusers"
+	apiv1 "github.com/alesr/usrsvc/proto/users/v1"
+	"github.com/jmoiron/sqlx"
+	envars "github.com/netflix/go-env"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// soakConfig holds the knobs for the soak test. Everything can be overridden
+// through the environment, e.g. SOAK_DURATION=30m make test-soak.
+type soakConfig struct {
+	Duration        time.Duration `env:"SOAK_DURATION,default=1m"`
+	Workers         int           `env:"SOAK_WORKERS,default=8"`
+	SampleInterval  time.Duration `env:"SOAK_SAMPLE_INTERVAL,default=5s"`
+	SettleTimeout   time.Duration `env:"SOAK_SETTLE_TIMEOUT,default=15s"`
+	MaxGoroutineGap int           `env:"SOAK_MAX_GOROUTINE_GROWTH,default=20"`
+	MaxHeapGrowthMB uint64        `env:"SOAK_MAX_HEAP_GROWTH_MB,default=64"`
+	Seed            int64         `env:"SOAK_SEED,default=1"`
+}
+
+// soakSample is a point-in-time snapshot of the resources we expect to be stable.
+type soakSample struct {
+	goroutines    int
+	heapInuse     uint64
+	openConns     int
+	inUseConns    int
+	requestsTotal int64
+	errorsTotal   int64
+}
+
+func (s soakSample) String() string {
+	return fmt.Sprintf(
+		"goroutines=%d heap_inuse=%dKiB db_open=%d db_in_use=%d requests=%d errors=%d",
+		s.goroutines, s.heapInuse/1024, s.openConns, s.inUseConns, s.requestsTotal, s.errorsTotal,
+	)
+}
+
+// Test_Soak hammers the server with the full CRUD cycle for a configurable duration
+// while sampling goroutines, heap and database connections. Once the load stops,
+// everything must go back close to the baseline; otherwise we are leaking contexts,
+// streams or connections that the 5-second timeouts would otherwise hide.
+func Test_Soak(t *testing.T) {
+	var cfg soakConfig
+	_, err := envars.UnmarshalFromEnviron(&cfg)
+	require.NoError(t, err)
+
+	db := setupDBHelper(t)
+	defer teardownDBHelper(t, db)
+
+	stopServer := startGRPCServerHelper(t, db)
+	defer stopServer()
+
+	grpcClient, close := setupGRPClientHelper(t)
+	defer func() {
+		require.NoError(t, close())
+	}()
+
+	var requests, failures int64
+
+	// Give the server and the client a moment to settle before taking the baseline.
+	time.Sleep(time.Second)
+	baseline := takeSoakSample(db, &requests, &failures)
+	t.Logf("baseline: %s", baseline)
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Duration)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for i := 0; i < cfg.Workers; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+
+			// Each worker gets its own deterministic stream of users, partitioned so the
+			// workers never generate the same email or nickname.
+			gen := fakeusers.New(cfg.Seed+int64(worker), fakeusers.WithPartition(worker))
+			for ctx.Err() == nil {
+				if err := soakIteration(ctx, grpcClient, gen.Next(), gen.Next(), &requests); err != nil && ctx.Err() == nil {
+					atomic.AddInt64(&failures, 1)
+				}
+			}
+		}(i)
+	}
+
+	ticker := time.NewTicker(cfg.SampleInterval)
+	defer ticker.Stop()
+
+	peak := baseline
+sampling:
+	for {
+		select {
+		case <-ctx.Done():
+			break sampling
+		case <-ticker.C:
+			sample := takeSoakSample(db, &requests, &failures)
+			t.Logf("sample: %s", sample)
+			if sample.goroutines > peak.goroutines {
+				peak.goroutines = sample.goroutines
+			}
+			if sample.openConns > peak.openConns {
+				peak.openConns = sample.openConns
+			}
+		}
+	}
+
+	wg.Wait()
+	t.Logf("peak: goroutines=%d db_open=%d", peak.goroutines, peak.openConns)
+
+	// Wait for in-flight work to drain, then compare against the baseline.
+	final := waitForSoakSettle(db, baseline, cfg, &requests, &failures)
+	t.Logf("final: %s", final)
+
+	assert.NotZero(t, final.requestsTotal, "soak test did not issue any request")
+	assert.Zero(t, final.errorsTotal, "requests failed during the soak test")
+	assert.Zero(t, final.inUseConns, "database connections are still in use after the load stopped")
+	assert.LessOrEqual(t, final.goroutines, baseline.goroutines+cfg.MaxGoroutineGap, "goroutine leak detected")
+	if final.heapInuse > baseline.heapInuse {
+		assert.LessOrEqual(t, (final.heapInuse-baseline.heapInuse)/(1024*1024), cfg.MaxHeapGrowthMB, "heap grew beyond the threshold")
+	}
+}
+
+// soakIteration runs the full user lifecycle once, creating
+// the user from the first fake and updating it with the second.
+func soakIteration(ctx context.Context, client apiv1.UserServiceClient, user, update fakeusers.User, requests *int64) error {
+	count := func() { atomic.AddInt64(requests, 1) }
+
+	created, err := client.CreateUser(ctx, &apiv1.CreateUserRequest{
+		FirstName: user.FirstName,
+		LastName:  user.LastName,
+		Nickname:  user.Nickname,
+		Email:     user.Email,
+		Password:  user.Password,
+		Country:   user.Country,
+	})
+	count()
+	if err != nil {
+		return err
+	}
+
+	id := created.User.Id
+
+	if _, err := client.GetUser(ctx, &apiv1.GetUserRequest{Id: id}); err != nil {
+		return err
+	}
+	count()
+
+	if _, err := client.ListUsers(ctx, &apiv1.ListUsersRequest{Country: user.Country, PageSize: 10}); err != nil {
+		return err
+	}
+	count()
+
+	if _, err := client.UpdateUser(ctx, &apiv1.UpdateUserRequest{
+		Id:        id,
+		FirstName: update.FirstName,
+		LastName:  update.LastName,
+		Nickname:  update.Nickname,
+		Email:     update.Email,
+		Country:   update.Country,
+	}); err != nil {
+		return err
+	}
+	count()
+
+	if _, err := client.DeleteUser(ctx, &apiv1.DeleteUserRequest{Id: id}); err != nil {
+		return err
+	}
+	count()
+	return nil
+}
+
+func takeSoakSample(db *sqlx.DB, requests, failures *int64) soakSample {
+	runtime.GC()
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	stats := db.Stats()
+
+	return soakSample{
+		goroutines:    runtime.NumGoroutine(),
+		heapInuse:     mem.HeapInuse,
+		openConns:     stats.OpenConnections,
+		inUseConns:    stats.InUse,
+		requestsTotal: atomic.LoadInt64(requests),
+		errorsTotal:   atomic.LoadInt64(failures),
+	}
+}
+
+// waitForSoakSettle polls until goroutines and connections are back near the baseline
+// or the settle timeout expires, returning the last sample taken.
+func waitForSoakSettle(db *sqlx.DB, baseline soakSample, cfg soakConfig, requests, failures *int64) soakSample {
+	deadline := time.Now().Add(cfg.SettleTimeout)
+	for {
+		sample := takeSoakSample(db, requests, failures)
+		settled := sample.inUseConns == 0 && sample.goroutines <= baseline.goroutines+cfg.MaxGoroutineGap
+		if settled || time.Now().After(deadline) {
+			return sample
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	userservice "github.com/alesr/usrsvc/internal/users/service"
+	"go.uber.org/zap"
+)
+
+const (
+	// warmupPageSize is the default page size of ListUsers, so the warmed list is the cached one.
+	warmupPageSize int = 100
+
+	warmupPollInterval time.Duration = 50 * time.Millisecond
+)
+
+// warmupStep is a step of the startup warmup.
+type warmupStep struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+// runWarmup runs the steps before the gRPC listener accepts traffic, so the first requests
+// after a deploy don't pay for cold connections and caches. Failures are only logged:
+// a cold instance is still better than none. All steps share the timeout.
+func runWarmup(ctx context.Context, logger *zap.Logger, timeout time.Duration, steps []warmupStep) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	for _, step := range steps {
+		stepStart := time.Now()
+
+		err := step.run(ctx)
+		switch {
+		case err == nil:
+			logger.Info("warmup step done", zap.String("step", step.name), zap.Duration("duration", time.Since(stepStart)))
+		case errors.Is(err, errCheckSkipped):
+			logger.Debug("warmup step skipped", zap.String("step", step.name))
+		default:
+			logger.Warn("warmup step failed", zap.String("step", step.name), zap.Error(err))
+		}
+	}
+	logger.Info("warmup done", zap.Duration("duration", time.Since(start)))
+}
+
+// passwordHasher is the hashing pool method used to warm up the password hashing.
+type passwordHasher interface {
+	Hash(ctx context.Context, password []byte) ([]byte, error)
+}
+
+// newWarmupSteps returns the warmup steps of the service: they open the database connections,
+// load the caches, check the broker and hash a dummy password.
+func newWarmupSteps(
+	svc *userservice.ServiceDefault,
+	stats *userservice.CountryStats,
+	publisher userservice.Publisher,
+	hasher passwordHasher,
+) []warmupStep {
+	return []warmupStep{
+		{
+			name: "database",
+			run:  svc.CheckServiceHealth,
+		},
+		{
+			name: "users list",
+			run: func(ctx context.Context) error {
+				_, err := svc.FetchAll(ctx, userservice.FilterParams{}, userservice.PaginationParams{Limit: warmupPageSize})
+				return err
+			},
+		},
+		{
+			name: "stats",
+			run: func(ctx context.Context) error {
+				ticker := time.NewTicker(warmupPollInterval)
+				defer ticker.Stop()
+
+				for {
+					if _, ok := stats.Snapshot(); ok {
+						return nil
+					}
+
+					select {
+					case <-ctx.Done():
+						return fmt.Errorf("stats not loaded: %w", ctx.Err())
+					case <-ticker.C:
+					}
+				}
+			},
+		},
+		{
+			name: "broker",
+			run: func(ctx context.Context) error {
+				pinger, ok := publisher.(brokerPinger)
+				if !ok {
+					return errCheckSkipped
+				}
+				return pinger.Ping(ctx)
+			},
+		},
+		{
+			name: "password hashing",
+			run: func(ctx context.Context) error {
+				_, err := hasher.Hash(ctx, []byte("warmup-passw0rd!"))
+				return err
+			},
+		},
+	}
+}